@@ -236,6 +236,15 @@ func (c *SSHClient) CopyFile(localPath, remotePath string) error {
 	return session.Run(fmt.Sprintf("scp -qt %s", filepath.Dir(remotePath)))
 }
 
+// Dial opens a connection to addr from the remote host, tunnelled through the
+// SSH connection (equivalent to a local port forward).
+func (c *SSHClient) Dial(network, addr string) (net.Conn, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("ssh client is not connected")
+	}
+	return c.client.Dial(network, addr)
+}
+
 // IsAlive checks if the SSH connection is still active
 func (c *SSHClient) IsAlive() bool {
 	session, err := c.client.NewSession()
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Defaults of the adaptive sample estimation method.
+const (
+	// DefaultSampleTarget is the relative half-width of the 95% confidence
+	// interval the adaptive method samples until it reaches: ±5%.
+	DefaultSampleTarget = 0.05
+	// DefaultMaxSampleSize caps the bytes the adaptive method compresses per
+	// site.
+	DefaultMaxSampleSize int64 = 1 << 30
+	// adaptiveInitialSegments is how many segments the first round spreads
+	// the sample over: the beginning, quarters, middle and end of the tar
+	// stream.
+	adaptiveInitialSegments = 4
+	minSampleSegment        = 1 << 20
+)
+
+// SampleEstimate describes how an adaptive sample estimate was reached.
+type SampleEstimate struct {
+	Segments     int     `json:"segments"`
+	SegmentSize  int64   `json:"segment_size"`
+	SampledBytes int64   `json:"sampled_bytes"`
+	Ratio        float64 `json:"ratio"`
+	// RelativeCI is the half-width of the 95% confidence interval of the
+	// estimate relative to it, e.g. 0.03 for ±3%.
+	RelativeCI float64 `json:"relative_ci"`
+	Target     float64 `json:"target"`
+	// Converged is false when the sample budget ran out or the stream was
+	// exhausted before RelativeCI reached Target.
+	Converged bool `json:"converged"`
+}
+
+// String summarizes the achieved confidence, e.g. "±3.1% at 95% confidence
+// (9 × 20.0 MB segments)".
+func (s *SampleEstimate) String() string {
+	out := fmt.Sprintf("±%.1f%% at 95%% confidence (%d × %.1f MB segments)",
+		s.RelativeCI*100, s.Segments, float64(s.SegmentSize)/(1024*1024))
+	if !s.Converged {
+		out += fmt.Sprintf(", target ±%.1f%% not reached", s.Target*100)
+	}
+	return out
+}
+
+// SetAdaptiveSampling configures the adaptive estimation method: it samples
+// until the 95% confidence interval is within ±target of the estimate or
+// maxSample bytes were compressed. Zero values select the defaults.
+func (bm *BackupManager) SetAdaptiveSampling(target float64, maxSample int64) {
+	bm.sampleTarget = target
+	bm.sampleMax = maxSample
+}
+
+// samplePositions returns where n+1 segments sit in a stream, as fractions
+// of the range segments can start in. Positions for 2n include those for n,
+// so doubling n only needs the new ones sampled.
+func samplePositions(n int) []float64 {
+	pos := make([]float64, n+1)
+	for i := range pos {
+		pos[i] = float64(i) / float64(n)
+	}
+	return pos
+}
+
+// t95 holds two-sided 95% Student t critical values for 1-30 degrees of
+// freedom.
+var t95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+// ratioStats returns the mean of the segment compression ratios and the
+// half-width of its 95% confidence interval relative to the mean.
+func ratioStats(ratios []float64) (mean, relCI float64) {
+	n := len(ratios)
+	if n == 0 {
+		return 0, math.Inf(1)
+	}
+	for _, r := range ratios {
+		mean += r
+	}
+	mean /= float64(n)
+	if n < 2 || mean == 0 {
+		return mean, math.Inf(1)
+	}
+	var ss float64
+	for _, r := range ratios {
+		ss += (r - mean) * (r - mean)
+	}
+	t := 1.96
+	if n-1 <= len(t95) {
+		t = t95[n-2]
+	}
+	half := t * math.Sqrt(ss/float64(n-1)) / math.Sqrt(float64(n))
+	return mean, half / mean
+}
+
+// segmentSample is the compressed and raw size of one sampled segment; raw
+// falls short of the segment length at the end of the stream.
+type segmentSample struct {
+	Compressed, Raw int64
+}
+
+// segmentCommand returns a shell command that streams the tar of
+// workingDir (or parentDir/<base>) once, compresses a segment of length
+// bytes at each of the ascending offsets and prints each segment's
+// compressed and raw size on its own line (gzip -lq).
+func segmentCommand(workingDir, parentDir string, offsets []int64, length int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `d=%s; `, shellQuote(workingDir))
+	if parentDir != "" {
+		fmt.Fprintf(&b, `[ -d "$d" ] || d=%s; `, shellQuote(filepath.Join(parentDir, filepath.Base(workingDir))))
+	}
+	b.WriteString(`tar -cf - ` + tarExcludeArgs + ` "$d" 2>/dev/null | {`)
+	var pos int64
+	for _, off := range offsets {
+		if skip := off - pos; skip > 0 {
+			fmt.Fprintf(&b, ` head -c %d >/dev/null;`, skip)
+		}
+		fmt.Fprintf(&b, ` head -c %d | gzip -c | gzip -lq;`, length)
+		pos = off + length
+	}
+	b.WriteString(` }`)
+	return b.String()
+}
+
+// sampleSegments compresses a segment of length bytes at each offset.
+func (bm *BackupManager) sampleSegments(workingDir, parentDir string, offsets []int64, length int64) ([]segmentSample, error) {
+	stdout, stderr, err := bm.executeCommand(segmentCommand(workingDir, parentDir, offsets, length))
+	if err != nil {
+		return nil, fmt.Errorf("segment sampling failed: %w (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+	return parseSegmentSamples(stdout, len(offsets))
+}
+
+// parseSegmentSamples parses the gzip -lq lines of segmentCommand.
+func parseSegmentSamples(out string, want int) ([]segmentSample, error) {
+	var samples []segmentSample
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		c, err1 := strconv.ParseInt(fields[0], 10, 64)
+		r, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid segment sizes %q", line)
+		}
+		samples = append(samples, segmentSample{Compressed: c, Raw: r})
+	}
+	if len(samples) != want {
+		return nil, fmt.Errorf("segment sampling returned %d sizes for %d segments", len(samples), want)
+	}
+	return samples, nil
+}
+
+// estimateAdaptive samples segments spread evenly over the tar stream,
+// doubling their number until the compression ratio's 95% confidence
+// interval is within the target, the sample budget is spent or segments
+// would overlap. sampleSize is the size of the first round.
+func (bm *BackupManager) estimateAdaptive(workingDir, parentDir string, sampleSize, uncompressedSize int64) (int64, *SampleEstimate, error) {
+	target, maxSample := bm.sampleTarget, bm.sampleMax
+	if target <= 0 {
+		target = DefaultSampleTarget
+	}
+	if maxSample <= 0 {
+		maxSample = DefaultMaxSampleSize
+	}
+	return adaptiveEstimate(uncompressedSize, sampleSize, target, maxSample, func(offsets []int64, length int64) ([]segmentSample, error) {
+		return bm.sampleSegments(workingDir, parentDir, offsets, length)
+	})
+}
+
+// adaptiveEstimate runs the rounds of estimateAdaptive against sample,
+// which compresses the segments at offsets.
+func adaptiveEstimate(uncompressedSize, sampleSize int64, target float64, maxSample int64, sample func(offsets []int64, length int64) ([]segmentSample, error)) (int64, *SampleEstimate, error) {
+	if uncompressedSize <= 0 {
+		return 0, nil, fmt.Errorf("nothing to sample")
+	}
+	segLen := sampleSize / (adaptiveInitialSegments + 1)
+	if segLen < minSampleSegment {
+		segLen = minSampleSegment
+	}
+	est := &SampleEstimate{Target: target}
+
+	// A stream no bigger than the first round is compressed whole.
+	if uncompressedSize <= segLen*(adaptiveInitialSegments+1) {
+		sizes, err := sample([]int64{0}, uncompressedSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		if sizes[0].Raw == 0 {
+			return 0, nil, fmt.Errorf("no data captured in sample")
+		}
+		est.Segments, est.SegmentSize, est.SampledBytes = 1, uncompressedSize, sizes[0].Raw
+		est.Ratio = float64(sizes[0].Compressed) / float64(sizes[0].Raw)
+		est.Converged = true
+		return int64(est.Ratio * float64(uncompressedSize)), est, nil
+	}
+
+	span := float64(uncompressedSize - segLen)
+	// sampled holds the ratio of each offset; segments past the end of the
+	// stream (which can be shorter than the directory, minus its excludes)
+	// are kept as -1 so they aren't sampled again.
+	sampled := make(map[int64]float64)
+	for n := adaptiveInitialSegments; ; n *= 2 {
+		var offsets []int64
+		for _, p := range samplePositions(n) {
+			off := int64(p * span)
+			if _, ok := sampled[off]; !ok {
+				offsets = append(offsets, off)
+			}
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		sizes, err := sample(offsets, segLen)
+		if err != nil {
+			return 0, nil, err
+		}
+		for i, off := range offsets {
+			sampled[off] = -1
+			if sizes[i].Raw >= segLen/2 {
+				sampled[off] = float64(sizes[i].Compressed) / float64(sizes[i].Raw)
+				est.SampledBytes += sizes[i].Raw
+			}
+		}
+
+		ratios := make([]float64, 0, len(sampled))
+		for _, r := range sampled {
+			if r >= 0 {
+				ratios = append(ratios, r)
+			}
+		}
+		if len(ratios) == 0 {
+			return 0, nil, fmt.Errorf("no data captured in sample")
+		}
+		est.Ratio, est.RelativeCI = ratioStats(ratios)
+		est.Segments = len(ratios)
+		est.SegmentSize = segLen
+		if est.RelativeCI <= target {
+			est.Converged = true
+			break
+		}
+		// The next round adds n segments between the current ones.
+		if est.SampledBytes+int64(n)*segLen > maxSample || span/float64(n*2) < float64(segLen) {
+			break
+		}
+	}
+	return int64(est.Ratio * float64(uncompressedSize)), est, nil
+}
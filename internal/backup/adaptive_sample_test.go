@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSamplePositionsNest(t *testing.T) {
+	four := samplePositions(4)
+	eight := samplePositions(8)
+	if len(four) != 5 || four[0] != 0 || four[4] != 1 {
+		t.Fatalf("samplePositions(4) = %v", four)
+	}
+	for i, p := range four {
+		if eight[2*i] != p {
+			t.Errorf("samplePositions(8)[%d] = %v, want %v", 2*i, eight[2*i], p)
+		}
+	}
+}
+
+func TestRatioStats(t *testing.T) {
+	if mean, ci := ratioStats([]float64{0.5, 0.5, 0.5}); mean != 0.5 || ci != 0 {
+		t.Errorf("constant ratios = %v ±%v", mean, ci)
+	}
+	// mean 0.5, s = 0.1, t(2) = 4.303: half-width 4.303*0.1/sqrt(3).
+	mean, ci := ratioStats([]float64{0.4, 0.5, 0.6})
+	if mean < 0.4999 || mean > 0.5001 || ci < 0.496 || ci > 0.498 {
+		t.Errorf("ratioStats() = %v ±%v", mean, ci)
+	}
+}
+
+func TestParseSegmentSamples(t *testing.T) {
+	out := "       1038     2097152  99.9% stdout\n  2097190     2097152  -0.0% stdout\n"
+	got, err := parseSegmentSamples(out, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != (segmentSample{1038, 2097152}) || got[1].Compressed != 2097190 {
+		t.Errorf("parseSegmentSamples() = %+v", got)
+	}
+	if _, err := parseSegmentSamples(out, 3); err == nil {
+		t.Error("a missing segment should fail")
+	}
+}
+
+func TestAdaptiveEstimate(t *testing.T) {
+	const mb = 1 << 20
+	// The first half of the stream compresses to 10%, the second half not at
+	// all; the last 10MB are missing from the tar (excluded files).
+	size := int64(1000 * mb)
+	rounds := 0
+	sampler := func(offsets []int64, length int64) ([]segmentSample, error) {
+		rounds++
+		out := make([]segmentSample, len(offsets))
+		for i, off := range offsets {
+			raw := length
+			if end := size - 10*mb; off+raw > end {
+				raw = max(end-off, 0)
+			}
+			ratio := 1.0
+			if off < size/2 {
+				ratio = 0.1
+			}
+			out[i] = segmentSample{Compressed: int64(float64(raw) * ratio), Raw: raw}
+		}
+		return out, nil
+	}
+
+	got, est, err := adaptiveEstimate(size, 10*mb, 0.25, DefaultMaxSampleSize, sampler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !est.Converged || rounds < 2 || est.RelativeCI > 0.25 || est.SegmentSize != 2*mb {
+		t.Errorf("estimate = %+v after %d rounds", est, rounds)
+	}
+	if got < size*4/10 || got > size*7/10 {
+		t.Errorf("adaptiveEstimate() = %d MB, want about 550 MB", got/mb)
+	}
+
+	rounds = 0
+	_, est, err = adaptiveEstimate(size, 10*mb, 0.001, 20*mb, sampler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Converged || rounds != 2 || est.SampledBytes > 20*mb {
+		t.Errorf("budget-limited estimate = %+v after %d rounds", est, rounds)
+	}
+
+	// A stream no bigger than the first round is compressed whole.
+	_, est, err = adaptiveEstimate(3*mb, 10*mb, 0.05, DefaultMaxSampleSize, func(offsets []int64, length int64) ([]segmentSample, error) {
+		if len(offsets) != 1 || length != 3*mb {
+			t.Errorf("sampled %v × %d", offsets, length)
+		}
+		return []segmentSample{{Compressed: mb, Raw: 3 * mb}}, nil
+	})
+	if err != nil || est.Segments != 1 || !est.Converged {
+		t.Errorf("small stream = %+v, %v", est, err)
+	}
+}
+
+func TestEstimateAdaptiveLocal(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 3<<20)
+	rand.Read(random)
+	os.WriteFile(filepath.Join(dir, "a.bin"), random, 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), bytes.Repeat([]byte("compressible "), 300000), 0o644)
+
+	bm := NewBackupManager(nil, nil)
+	compressed, uncompressed, sample, err := bm.estimateCompressedSize(dir, "", "adaptive", 5<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample == nil || sample.Segments == 0 || compressed <= 0 || compressed >= uncompressed*2 {
+		t.Errorf("adaptive = %d of %d bytes, %+v", compressed, uncompressed, sample)
+	}
+}
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Capacity levels reported by the storage monitor.
+const (
+	CapacityOK       = "ok"
+	CapacityWarning  = "warning"
+	CapacityCritical = "critical"
+)
+
+// CapacityAlertConfig configures early-warning alerts for the storage monitor.
+// Crossing WarnThreshold only notifies; migration still waits for the
+// migration threshold passed to MonitorAndMigrateIfNeeded.
+type CapacityAlertConfig struct {
+	// WarnThreshold is the usage percentage that triggers a warning. Zero disables it.
+	WarnThreshold float64
+	// WebhookURL receives a JSON CapacityAlert via POST for warning and critical levels.
+	WebhookURL string
+	// MetricsFile is written in Prometheus text format on every check (e.g. for
+	// node_exporter's textfile collector).
+	MetricsFile string
+	// Source identifies the storage server in alerts and metrics.
+	Source string
+}
+
+// CapacityAlert is the payload sent to the alert webhook.
+type CapacityAlert struct {
+	Level            string    `json:"level"`
+	Source           string    `json:"source,omitempty"`
+	Path             string    `json:"path"`
+	UsedPercent      float64   `json:"used_percent"`
+	WarnThreshold    float64   `json:"warn_threshold,omitempty"`
+	MigrateThreshold float64   `json:"migrate_threshold"`
+	AvailableBytes   uint64    `json:"available_bytes"`
+	TotalBytes       uint64    `json:"total_bytes"`
+	Message          string    `json:"message"`
+	Time             time.Time `json:"time"`
+}
+
+// SetCapacityAlerts enables warning alerts and metrics for the storage monitor.
+func (bm *BackupManager) SetCapacityAlerts(cfg *CapacityAlertConfig) {
+	bm.capacityAlerts = cfg
+}
+
+// classifyCapacity returns the alert level for usedPercent. A warnThreshold of
+// zero (or one at/above the migration threshold) never yields a warning.
+func classifyCapacity(usedPercent, warnThreshold, migrateThreshold float64) string {
+	switch {
+	case usedPercent > migrateThreshold:
+		return CapacityCritical
+	case warnThreshold > 0 && warnThreshold < migrateThreshold && usedPercent >= warnThreshold:
+		return CapacityWarning
+	default:
+		return CapacityOK
+	}
+}
+
+// reportCapacity writes metrics and sends an alert for the current capacity.
+// Failures are printed as warnings; alerting never blocks the monitor.
+func (bm *BackupManager) reportCapacity(capacity *StorageCapacity, migrateThreshold float64, dryRun bool) string {
+	cfg := bm.capacityAlerts
+	if cfg == nil {
+		cfg = &CapacityAlertConfig{}
+	}
+	level := classifyCapacity(capacity.UsedPercent, cfg.WarnThreshold, migrateThreshold)
+
+	if cfg.MetricsFile != "" && !dryRun {
+		if err := writeCapacityMetrics(cfg.MetricsFile, capacity, level, cfg.WarnThreshold, migrateThreshold, cfg.Source); err != nil {
+			fmt.Printf("⚠️  Warning: failed to write capacity metrics: %v\n", err)
+		}
+	}
+
+	if level == CapacityWarning {
+		fmt.Printf("\n⚠ Storage usage (%.1f%%) crossed the warning threshold (%.1f%%); migration starts at %.1f%%\n",
+			capacity.UsedPercent, cfg.WarnThreshold, migrateThreshold)
+	}
+	if level == CapacityOK || cfg.WebhookURL == "" {
+		return level
+	}
+
+	alert := CapacityAlert{
+		Level:            level,
+		Source:           cfg.Source,
+		Path:             capacity.Path,
+		UsedPercent:      capacity.UsedPercent,
+		WarnThreshold:    cfg.WarnThreshold,
+		MigrateThreshold: migrateThreshold,
+		AvailableBytes:   capacity.Available,
+		TotalBytes:       capacity.Total,
+		Time:             time.Now().UTC(),
+	}
+	if level == CapacityCritical {
+		alert.Message = fmt.Sprintf("Backup storage at %.1f%% exceeds migration threshold %.1f%%; migrating oldest backups to Glacier", capacity.UsedPercent, migrateThreshold)
+	} else {
+		alert.Message = fmt.Sprintf("Backup storage at %.1f%% crossed warning threshold %.1f%% (migration at %.1f%%)", capacity.UsedPercent, cfg.WarnThreshold, migrateThreshold)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would send %s alert to webhook\n", level)
+		return level
+	}
+	if err := sendCapacityAlert(cfg.WebhookURL, &alert); err != nil {
+		fmt.Printf("⚠️  Warning: failed to send capacity alert: %v\n", err)
+	} else {
+		fmt.Printf("📣 Sent %s alert to webhook\n", level)
+	}
+	return level
+}
+
+func sendCapacityAlert(url string, alert *CapacityAlert) error {
+	return postJSON(url, alert)
+}
+
+// postJSON POSTs v as JSON to a webhook URL.
+func postJSON(url string, v any) error {
+	return sendJSON(url, nil, v)
+}
+
+// sendJSON POSTs v as JSON to url with the extra request headers.
+func sendJSON(url string, headers map[string]string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// formatCapacityMetrics renders capacity as Prometheus text exposition.
+func formatCapacityMetrics(capacity *StorageCapacity, level string, warnThreshold, migrateThreshold float64, source string) string {
+	labels := fmt.Sprintf(`path=%q`, capacity.Path)
+	if source != "" {
+		labels = fmt.Sprintf(`source=%q,%s`, source, labels)
+	}
+	levelValue := map[string]int{CapacityOK: 0, CapacityWarning: 1, CapacityCritical: 2}[level]
+
+	var b bytes.Buffer
+	write := func(name, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s{%s} %v\n", name, help, name, name, labels, value)
+	}
+	write("ciwg_backup_storage_used_percent", "Backup storage usage percentage.", capacity.UsedPercent)
+	write("ciwg_backup_storage_available_bytes", "Backup storage available bytes.", capacity.Available)
+	write("ciwg_backup_storage_total_bytes", "Backup storage total bytes.", capacity.Total)
+	write("ciwg_backup_storage_warn_threshold_percent", "Usage percentage that triggers a warning (0 = disabled).", warnThreshold)
+	write("ciwg_backup_storage_migrate_threshold_percent", "Usage percentage that triggers Glacier migration.", migrateThreshold)
+	write("ciwg_backup_storage_alert_level", "0 = ok, 1 = warning, 2 = critical.", levelValue)
+	return b.String()
+}
+
+// writeCapacityMetrics atomically replaces path with the current metrics.
+func writeCapacityMetrics(path string, capacity *StorageCapacity, level string, warnThreshold, migrateThreshold float64, source string) error {
+	return writeMetricsFile(path, formatCapacityMetrics(capacity, level, warnThreshold, migrateThreshold, source))
+}
+
+// writeMetricsFile atomically replaces path with text, so a textfile
+// collector never reads a partial file.
+func writeMetricsFile(path, text string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
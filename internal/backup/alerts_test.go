@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClassifyCapacity(t *testing.T) {
+	tests := []struct {
+		name                string
+		used, warn, migrate float64
+		want                string
+	}{
+		{"below warn", 80, 85, 95, CapacityOK},
+		{"at warn", 85, 85, 95, CapacityWarning},
+		{"between", 92, 85, 95, CapacityWarning},
+		{"at migrate", 95, 85, 95, CapacityWarning},
+		{"above migrate", 96, 85, 95, CapacityCritical},
+		{"warn disabled", 92, 0, 95, CapacityOK},
+		{"warn above migrate ignored", 92, 97, 95, CapacityOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyCapacity(tt.used, tt.warn, tt.migrate); got != tt.want {
+				t.Errorf("classifyCapacity(%v, %v, %v) = %s, want %s", tt.used, tt.warn, tt.migrate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCapacityMetrics(t *testing.T) {
+	c := &StorageCapacity{Total: 1000, Available: 120, UsedPercent: 88, Path: "/mnt/minio"}
+	out := formatCapacityMetrics(c, CapacityWarning, 85, 95, "storage1")
+	for _, want := range []string{
+		`ciwg_backup_storage_used_percent{source="storage1",path="/mnt/minio"} 88`,
+		`ciwg_backup_storage_alert_level{source="storage1",path="/mnt/minio"} 1`,
+		"# TYPE ciwg_backup_storage_available_bytes gauge",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestReportCapacityWebhook(t *testing.T) {
+	var got CapacityAlert
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	bm := NewBackupManager(nil, nil)
+	bm.SetCapacityAlerts(&CapacityAlertConfig{WarnThreshold: 85, WebhookURL: srv.URL})
+
+	if level := bm.reportCapacity(&StorageCapacity{UsedPercent: 70}, 95, false); level != CapacityOK || calls != 0 {
+		t.Fatalf("ok level should not alert: level=%s calls=%d", level, calls)
+	}
+	if level := bm.reportCapacity(&StorageCapacity{UsedPercent: 90, Path: "/data"}, 95, false); level != CapacityWarning {
+		t.Fatalf("level = %s, want warning", level)
+	}
+	if calls != 1 || got.Level != CapacityWarning || got.Path != "/data" || got.MigrateThreshold != 95 {
+		t.Errorf("webhook payload = %+v (calls=%d)", got, calls)
+	}
+}
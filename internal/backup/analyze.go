@@ -0,0 +1,358 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Group names used by the size breakdown besides directories.
+const (
+	AnalysisGroupDatabase = "database"
+	AnalysisGroupRoot     = "(root files)"
+)
+
+// SizeShare is one line of a size breakdown.
+type SizeShare struct {
+	Name    string  `json:"name"`
+	Bytes   int64   `json:"bytes"`
+	Files   int     `json:"files"`
+	Percent float64 `json:"percent"`
+}
+
+// FileSize is one file in the largest-files list.
+type FileSize struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// BackupAnalysis is the uncompressed size breakdown of one backup.
+type BackupAnalysis struct {
+	Objects         []string `json:"objects"`
+	CompressedBytes int64    `json:"compressed_bytes"`
+	TotalBytes      int64    `json:"total_bytes"`
+	Files           int      `json:"files"`
+	// Root is the directory shared by every file in the archive; directory
+	// groups are named relative to it.
+	Root        string      `json:"root"`
+	Directories []SizeShare `json:"directories"`
+	Types       []SizeShare `json:"types"`
+	Largest     []FileSize  `json:"largest"`
+}
+
+// analysisAcc accumulates file sizes while the archives stream by. Sizes are
+// kept per directory rather than per file so memory stays bounded by the
+// directory count, and the root is only known once every file has been seen.
+type analysisAcc struct {
+	dirs    map[string]*SizeShare
+	db      SizeShare
+	types   map[string]*SizeShare
+	largest []FileSize
+	top     int
+	total   int64
+	files   int
+}
+
+func newAnalysisAcc(top int) *analysisAcc {
+	return &analysisAcc{
+		dirs:  make(map[string]*SizeShare),
+		types: make(map[string]*SizeShare),
+		top:   top,
+	}
+}
+
+func (a *analysisAcc) add(name string, size int64, database bool) {
+	a.total += size
+	a.files++
+
+	if database || isSQLDump(name) {
+		a.db.Bytes += size
+		a.db.Files++
+	} else {
+		dir := path.Dir(name)
+		d := a.dirs[dir]
+		if d == nil {
+			d = &SizeShare{Name: dir}
+			a.dirs[dir] = d
+		}
+		d.Bytes += size
+		d.Files++
+	}
+
+	ext := calibrationExt(name)
+	if ext == "" {
+		ext = "(none)"
+	}
+	t := a.types[ext]
+	if t == nil {
+		t = &SizeShare{Name: ext}
+		a.types[ext] = t
+	}
+	t.Bytes += size
+	t.Files++
+
+	if a.top <= 0 {
+		return
+	}
+	if len(a.largest) == a.top && size <= a.largest[len(a.largest)-1].Bytes {
+		return
+	}
+	i := sort.Search(len(a.largest), func(i int) bool { return a.largest[i].Bytes < size })
+	a.largest = append(a.largest, FileSize{})
+	copy(a.largest[i+1:], a.largest[i:])
+	a.largest[i] = FileSize{Path: name, Bytes: size}
+	if len(a.largest) > a.top {
+		a.largest = a.largest[:a.top]
+	}
+}
+
+// result groups the per-directory totals below the common root. WordPress
+// keeps most of a site under wp-content, so its children (uploads, plugins,
+// themes) are reported as groups of their own.
+func (a *analysisAcc) result() *BackupAnalysis {
+	res := &BackupAnalysis{TotalBytes: a.total, Files: a.files, Largest: a.largest}
+
+	dirs := make([]string, 0, len(a.dirs))
+	for d := range a.dirs {
+		dirs = append(dirs, d)
+	}
+	res.Root = commonDir(dirs)
+
+	groups := make(map[string]*SizeShare)
+	for d, s := range a.dirs {
+		name := analysisGroup(res.Root, d)
+		g := groups[name]
+		if g == nil {
+			g = &SizeShare{Name: name}
+			groups[name] = g
+		}
+		g.Bytes += s.Bytes
+		g.Files += s.Files
+	}
+	if a.db.Files > 0 {
+		db := a.db
+		db.Name = AnalysisGroupDatabase
+		groups[db.Name] = &db
+	}
+
+	res.Directories = sortedShares(groups, a.total)
+	res.Types = sortedShares(a.types, a.total)
+	for i := range res.Largest {
+		res.Largest[i].Path = strings.TrimPrefix(strings.TrimPrefix(res.Largest[i].Path, res.Root), "/")
+	}
+	return res
+}
+
+// analysisGroup names the group of dir: its first component below root, or
+// wp-content/<child> for WordPress content.
+func analysisGroup(root, dir string) string {
+	rel := dir
+	if root != "" {
+		rel = strings.TrimPrefix(strings.TrimPrefix(dir, root), "/")
+	}
+	if rel == "" || rel == "." {
+		return AnalysisGroupRoot
+	}
+	parts := strings.SplitN(rel, "/", 3)
+	if parts[0] == "wp-content" && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// commonDir returns the longest directory shared by all of dirs.
+func commonDir(dirs []string) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	common := strings.Split(dirs[0], "/")
+	for _, d := range dirs[1:] {
+		parts := strings.Split(d, "/")
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	root := strings.Join(common, "/")
+	if root == "." {
+		return ""
+	}
+	return root
+}
+
+func sortedShares(m map[string]*SizeShare, total int64) []SizeShare {
+	out := make([]SizeShare, 0, len(m))
+	for _, s := range m {
+		if total > 0 {
+			s.Percent = float64(s.Bytes) * 100 / float64(total)
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func isSQLDump(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".sql") || strings.HasSuffix(lower, ".sql.gz")
+}
+
+// AnalyzeBackup streams the objects of one backup set and reports where its
+// uncompressed size goes, listing the top largest files. Shards of a part
+// are read back to back in shard order.
+func (bm *BackupManager) AnalyzeBackup(keys []string, top int) (*BackupAnalysis, error) {
+	type part struct {
+		kind string
+		keys []string
+	}
+	byName := make(map[string]*part)
+	var names []string
+	shardOf := make(map[string]int)
+	for _, k := range keys {
+		p := parseBackupPart(k)
+		name := k
+		if p.Shards > 0 {
+			name = shardPattern.ReplaceAllString(k, "$1$4")
+		}
+		shardOf[k] = p.Shard
+		if byName[name] == nil {
+			byName[name] = &part{kind: p.Kind}
+			names = append(names, name)
+		}
+		byName[name].keys = append(byName[name].keys, k)
+	}
+	sort.Strings(names)
+
+	acc := newAnalysisAcc(top)
+	var compressed int64
+	for _, name := range names {
+		p := byName[name]
+		sort.Slice(p.keys, func(i, j int) bool { return shardOf[p.keys[i]] < shardOf[p.keys[j]] })
+		// Progress goes to stderr so JSON on stdout stays clean.
+		fmt.Fprintf(os.Stderr, "Analyzing %s...\n", name)
+		n, err := bm.analyzeObject(name, p.kind, p.keys, acc)
+		compressed += n
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := acc.result()
+	res.Objects = append([]string(nil), keys...)
+	sort.Strings(res.Objects)
+	res.CompressedBytes = compressed
+	return res, nil
+}
+
+// analyzeObject feeds one (possibly sharded) object into acc and returns the
+// number of stored bytes read.
+func (bm *BackupManager) analyzeObject(name, kind string, keys []string, acc *analysisAcc) (int64, error) {
+	r := &shardReader{bm: bm, keys: keys}
+	defer r.Close()
+	counter := &countingReader{r: r}
+
+	lower := strings.ToLower(name)
+	if isZipBackup(lower) {
+		zr, err := openZip(counter)
+		if err != nil {
+			return counter.n, fmt.Errorf("failed to open zip archive %s: %w", name, err)
+		}
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() {
+				acc.add(strings.TrimPrefix(f.Name, "./"), int64(f.UncompressedSize64), false)
+			}
+		}
+		_, _ = io.Copy(io.Discard, counter)
+		return counter.n, nil
+	}
+	archive := strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".tar.gz")
+	var src io.Reader = counter
+	if archive || strings.HasSuffix(lower, ".gz") {
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			return counter.n, fmt.Errorf("failed to open gzip stream for %s: %w", name, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	if !archive || kind == PartDatabase {
+		n, err := io.Copy(io.Discard, src)
+		if err != nil {
+			return counter.n, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		acc.add(strings.TrimSuffix(path.Base(name), ".gz"), n, kind == PartDatabase)
+		return counter.n, nil
+	}
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return counter.n, fmt.Errorf("failed to read tarball %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		acc.add(strings.TrimPrefix(hdr.Name, "./"), hdr.Size, false)
+	}
+	// Drain the remainder so the counter sees the whole object.
+	_, _ = io.Copy(io.Discard, counter)
+	return counter.n, nil
+}
+
+// shardReader reads objects back to back, opening each only when the
+// previous one is exhausted.
+type shardReader struct {
+	bm   *BackupManager
+	keys []string
+	cur  io.ReadCloser
+}
+
+func (s *shardReader) Read(p []byte) (int, error) {
+	for {
+		if s.cur == nil {
+			if len(s.keys) == 0 {
+				return 0, io.EOF
+			}
+			obj, err := s.bm.DownloadBackup(s.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			s.cur, s.keys = obj, s.keys[1:]
+		}
+		n, err := s.cur.Read(p)
+		if err == io.EOF {
+			s.cur.Close()
+			s.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (s *shardReader) Close() error {
+	if s.cur == nil {
+		return nil
+	}
+	err := s.cur.Close()
+	s.cur = nil
+	return err
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+)
+
+func TestAnalyzeBackup(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	files := map[string]int{
+		"var/opt/a.com/www/wp-config.php":                   100,
+		"var/opt/a.com/www/wp-content/uploads/2024/big.jpg": 6000,
+		"var/opt/a.com/www/wp-content/uploads/small.jpg":    900,
+		"var/opt/a.com/www/wp-content/plugins/p/p.php":      2000,
+		"var/opt/a.com/www/wp-content/a.com.sql":            1000,
+	}
+	tw.WriteHeader(&tar.Header{Name: "var/opt/a.com/www/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for name, size := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(size)}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(make([]byte, size))
+	}
+	tw.Close()
+	gz.Close()
+
+	// Shard the archive in two to check the parts are read back to back.
+	data := archive.Bytes()
+	half := len(data) / 2
+	objects := map[string][]byte{
+		"backups/a.com/a-1.part-001-of-002.tgz": data[:half],
+		"backups/a.com/a-1.part-002-of-002.tgz": data[half:],
+	}
+	for key, body := range objects {
+		if _, err := bm.putObject(context.Background(), key, bytes.NewReader(body), int64(len(body)), "application/gzip", nil); err != nil {
+			t.Fatalf("putObject(%s) error = %v", key, err)
+		}
+	}
+
+	set, err := bm.LatestBackupSet("backups/a.com/")
+	if err != nil {
+		t.Fatalf("LatestBackupSet() error = %v", err)
+	}
+	res, err := bm.AnalyzeBackup(set.Keys(), 2)
+	if err != nil {
+		t.Fatalf("AnalyzeBackup() error = %v", err)
+	}
+
+	if res.Files != 5 || res.TotalBytes != 10000 {
+		t.Errorf("Files, TotalBytes = %d, %d, want 5, 10000", res.Files, res.TotalBytes)
+	}
+	if res.CompressedBytes != int64(len(data)) {
+		t.Errorf("CompressedBytes = %d, want %d", res.CompressedBytes, len(data))
+	}
+	if res.Root != "var/opt/a.com/www" {
+		t.Errorf("Root = %q", res.Root)
+	}
+
+	want := []SizeShare{
+		{Name: "wp-content/uploads", Bytes: 6900, Files: 2, Percent: 69},
+		{Name: "wp-content/plugins", Bytes: 2000, Files: 1, Percent: 20},
+		{Name: AnalysisGroupDatabase, Bytes: 1000, Files: 1, Percent: 10},
+		{Name: AnalysisGroupRoot, Bytes: 100, Files: 1, Percent: 1},
+	}
+	if len(res.Directories) != len(want) {
+		t.Fatalf("Directories = %+v", res.Directories)
+	}
+	for i, w := range want {
+		if res.Directories[i] != w {
+			t.Errorf("Directories[%d] = %+v, want %+v", i, res.Directories[i], w)
+		}
+	}
+
+	if len(res.Types) == 0 || res.Types[0].Name != ".jpg" || res.Types[0].Bytes != 6900 {
+		t.Errorf("Types = %+v, want .jpg first", res.Types)
+	}
+	if len(res.Largest) != 2 || res.Largest[0].Path != "wp-content/uploads/2024/big.jpg" || res.Largest[1].Bytes != 2000 {
+		t.Errorf("Largest = %+v", res.Largest)
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	tests := []struct {
+		dirs []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"a/b/c", "a/b/d"}, "a/b"},
+		{[]string{"a/b", "a/bc"}, "a"},
+		{[]string{".", "a"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonDir(tt.dirs); got != tt.want {
+			t.Errorf("commonDir(%v) = %q, want %q", tt.dirs, got, tt.want)
+		}
+	}
+}
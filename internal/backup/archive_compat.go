@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Archive features that not every tar on a restore host handles.
+const (
+	// ArchiveFeatureZstd is a .tar.zst archive. ciwg-cli decompresses it
+	// itself; the host only ever receives a plain tar stream.
+	ArchiveFeatureZstd = "zstd"
+	// ArchiveFeatureSparse is a GNU sparse file. ciwg-cli expands it into a
+	// regular file, so the host needs room for its full size.
+	ArchiveFeatureSparse = "sparse"
+	// ArchiveFeatureXattrs is an entry with extended attributes in PAX
+	// records, which only GNU tar 1.27+ and bsdtar restore.
+	ArchiveFeatureXattrs = "xattrs"
+	// ArchiveFeatureLongPaths is a name or link target that does not fit a
+	// ustar header and needs the GNU or PAX extensions.
+	ArchiveFeatureLongPaths = "long-paths"
+)
+
+// ArchiveFeatures records which features a backup archive uses, with the
+// first entry using each as an example for error messages.
+type ArchiveFeatures struct {
+	Zstd      bool `json:"zstd,omitempty"`
+	Sparse    bool `json:"sparse,omitempty"`
+	Xattrs    bool `json:"xattrs,omitempty"`
+	LongPaths bool `json:"long_paths,omitempty"`
+
+	examples map[string]string
+}
+
+// observe records the features used by hdr, as read by archive/tar.
+func (f *ArchiveFeatures) observe(hdr *tar.Header) {
+	if hdr.Typeflag == tar.TypeGNUSparse || hdr.PAXRecords["GNU.sparse.major"] != "" || hdr.PAXRecords["GNU.sparse.map"] != "" {
+		f.Sparse = true
+		f.example(ArchiveFeatureSparse, hdr.Name)
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "SCHILY.xattr.") || strings.HasPrefix(k, "LIBARCHIVE.xattr.") {
+			f.Xattrs = true
+			f.example(ArchiveFeatureXattrs, hdr.Name)
+			break
+		}
+	}
+	if !fitsUSTAR(hdr.Name) || len(hdr.Linkname) > 100 {
+		f.LongPaths = true
+		f.example(ArchiveFeatureLongPaths, hdr.Name)
+	}
+}
+
+func (f *ArchiveFeatures) example(feature, name string) {
+	if f.examples == nil {
+		f.examples = map[string]string{}
+	}
+	if _, ok := f.examples[feature]; !ok {
+		f.examples[feature] = name
+	}
+}
+
+// Names lists the features used, e.g. [sparse long-paths].
+func (f ArchiveFeatures) Names() []string {
+	var names []string
+	for _, x := range []struct {
+		on   bool
+		name string
+	}{{f.Zstd, ArchiveFeatureZstd}, {f.Sparse, ArchiveFeatureSparse}, {f.Xattrs, ArchiveFeatureXattrs}, {f.LongPaths, ArchiveFeatureLongPaths}} {
+		if x.on {
+			names = append(names, x.name)
+		}
+	}
+	return names
+}
+
+// fitsUSTAR reports whether name fits the 100-byte name and 155-byte prefix
+// fields of a ustar header.
+func fitsUSTAR(name string) bool {
+	if len(name) <= 100 {
+		return true
+	}
+	if len(name) > 256 {
+		return false
+	}
+	// The split has to fall on a slash, with at most 155 bytes before it.
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] == '/' && i <= 155 && len(name)-i-1 <= 100 {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeArchiveHeader prepares hdr, as read by archive/tar, to be written
+// to the plain tar stream a host extracts. Sparse files are always sent as
+// regular files: the reader has already expanded them and archive/tar cannot
+// write sparse entries. With compat, extended attributes and other PAX
+// records are dropped and the entry is written as ustar where it fits and
+// with GNU long names otherwise, which every tar in the fleet extracts.
+func normalizeArchiveHeader(hdr *tar.Header, compat bool) {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		hdr.Typeflag = tar.TypeReg
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			delete(hdr.PAXRecords, k)
+		}
+	}
+	if !compat {
+		return
+	}
+	// The reader fills the deprecated Xattrs too, and the writer turns it
+	// back into PAX records.
+	hdr.PAXRecords, hdr.Xattrs = nil, nil
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	hdr.Format = tar.FormatUSTAR | tar.FormatGNU
+}
+
+// HostTar is the tar implementation on a restore host.
+type HostTar struct {
+	// Kind is "gnu", "bsdtar", "busybox" or "unknown".
+	Kind    string `json:"kind"`
+	Version string `json:"version,omitempty"`
+	major   int
+	minor   int
+}
+
+// hostTarCommand prints the version banner of tar and where it resolves to,
+// which names busybox when tar is one of its applets.
+const hostTarCommand = `tar --version 2>&1 | head -n 2; readlink -f "$(command -v tar)" 2>/dev/null`
+
+var (
+	gnuTarVersion = regexp.MustCompile(`GNU tar\)?\s+(\d+)\.(\d+)`)
+	bsdTarVersion = regexp.MustCompile(`bsdtar\s+(\d+)\.(\d+)`)
+	busyboxBanner = regexp.MustCompile(`(?i)busybox(\s+v(\d+)\.(\d+))?`)
+)
+
+// parseHostTar identifies the tar implementation from the output of
+// hostTarCommand.
+func parseHostTar(out string) HostTar {
+	parse := func(kind string, m []string) HostTar {
+		h := HostTar{Kind: kind}
+		if len(m) >= 3 && m[1] != "" {
+			h.major, _ = strconv.Atoi(m[1])
+			h.minor, _ = strconv.Atoi(m[2])
+			h.Version = m[1] + "." + m[2]
+		}
+		return h
+	}
+	if m := gnuTarVersion.FindStringSubmatch(out); m != nil {
+		return parse("gnu", m)
+	}
+	if m := bsdTarVersion.FindStringSubmatch(out); m != nil {
+		return parse("bsdtar", m)
+	}
+	if m := busyboxBanner.FindStringSubmatch(out); m != nil {
+		return parse("busybox", m[1:])
+	}
+	return HostTar{Kind: "unknown"}
+}
+
+func (h HostTar) String() string {
+	name := map[string]string{"gnu": "GNU tar", "bsdtar": "bsdtar", "busybox": "BusyBox tar"}[h.Kind]
+	if name == "" {
+		return "an unrecognized tar"
+	}
+	if h.Version != "" {
+		name += " " + h.Version
+	}
+	return name
+}
+
+func (h HostTar) atLeast(major, minor int) bool {
+	return h.major > major || h.major == major && h.minor >= minor
+}
+
+// Supports reports whether the host's tar extracts archives using feature.
+// zstd and sparse files never reach the host (see ArchiveFeatureZstd and
+// ArchiveFeatureSparse), so they are always supported.
+func (h HostTar) Supports(feature string) bool {
+	switch feature {
+	case ArchiveFeatureZstd, ArchiveFeatureSparse:
+		return true
+	case ArchiveFeatureXattrs:
+		return h.Kind == "bsdtar" || h.Kind == "gnu" && h.atLeast(1, 27)
+	case ArchiveFeatureLongPaths:
+		return h.Kind == "gnu" || h.Kind == "bsdtar" || h.Kind == "busybox"
+	}
+	return false
+}
+
+// supportsAll reports whether the host extracts every feature, so restores
+// can skip scanning the archive first.
+func (h HostTar) supportsAll() bool {
+	return h.Supports(ArchiveFeatureXattrs) && h.Supports(ArchiveFeatureLongPaths)
+}
+
+// extractFlags returns the flags that make the host's tar restore extended
+// attributes, which GNU tar skips unless asked.
+func (h HostTar) extractFlags() string {
+	if h.Kind == "gnu" && h.atLeast(1, 27) {
+		return " --xattrs --xattrs-include='*'"
+	}
+	return ""
+}
+
+// ArchiveCompatError is returned when a restore host cannot extract an
+// archive faithfully.
+type ArchiveCompatError struct {
+	Object string
+	Host   HostTar
+	// Problems explains each unsupported feature.
+	Problems []string
+}
+
+func (e *ArchiveCompatError) Error() string {
+	return fmt.Sprintf("%s cannot be extracted faithfully by %s on the target host: %s; "+
+		"re-run with --compat to re-package it without those features (extended attributes are dropped)",
+		e.Object, e.Host, strings.Join(e.Problems, "; "))
+}
+
+// checkArchiveCompat returns an ArchiveCompatError listing the features of
+// f that host does not support, or nil.
+func checkArchiveCompat(objectName string, f ArchiveFeatures, host HostTar) error {
+	var problems []string
+	if f.Xattrs && !host.Supports(ArchiveFeatureXattrs) {
+		problems = append(problems, fmt.Sprintf("extended attributes (e.g. on %s) need GNU tar 1.27+ or bsdtar and would be lost", f.examples[ArchiveFeatureXattrs]))
+	}
+	if f.LongPaths && !host.Supports(ArchiveFeatureLongPaths) {
+		problems = append(problems, fmt.Sprintf("paths longer than ustar allows (e.g. %s) need GNU or PAX tar extensions", f.examples[ArchiveFeatureLongPaths]))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ArchiveCompatError{Object: objectName, Host: host, Problems: problems}
+}
+
+// detectHostTar identifies the tar implementation on the restore host.
+func (bm *BackupManager) detectHostTar() HostTar {
+	stdout, stderr, err := bm.executeCommand(hostTarCommand)
+	if err != nil {
+		bm.logVerbose("Could not identify tar on the host: %v (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+	host := parseHostTar(stdout)
+	bm.logVerbose("Host tar: %s", host)
+	return host
+}
+
+// scanArchiveFeatures reads the headers of the backup objectName, laid out
+// under site for a legacy zip, and returns the features it uses.
+func (bm *BackupManager) scanArchiveFeatures(objectName, site string) (ArchiveFeatures, error) {
+	f := ArchiveFeatures{Zstd: isZstdBackup(objectName)}
+	obj, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return f, err
+	}
+	defer obj.Close()
+	tr, err := openBackupTar(objectName, obj, site)
+	if err != nil {
+		return f, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return f, nil
+		}
+		if err != nil {
+			return f, fmt.Errorf("failed to read tarball: %w", err)
+		}
+		f.observe(hdr)
+	}
+}
+
+// preflightExtract scans objectName, unless compat re-packages the stream or
+// host handles every feature, and fails before anything is extracted when
+// host cannot extract it faithfully.
+func (bm *BackupManager) preflightExtract(host HostTar, objectName, site string, compat bool) error {
+	if compat || host.supportsAll() {
+		return nil
+	}
+	fmt.Printf("Checking that %s on the host can extract %s...\n", host, objectName)
+	f, err := bm.scanArchiveFeatures(objectName, site)
+	if err != nil {
+		return err
+	}
+	return checkArchiveCompat(objectName, f, host)
+}
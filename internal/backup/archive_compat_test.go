@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseHostTar(t *testing.T) {
+	for out, want := range map[string]string{
+		"tar (GNU tar) 1.34\nCopyright (C) 2021 Free Software Foundation, Inc.\n/usr/bin/tar":                 "GNU tar 1.34",
+		"bsdtar 3.6.2 - libarchive 3.6.2 zlib/1.2.12 liblzma/5.4.1\n/usr/bin/bsdtar":                          "bsdtar 3.6",
+		"tar: unrecognized option '--version'\nBusyBox v1.36.1 (2023-07-27) multi-call binary.\n/bin/busybox": "BusyBox tar 1.36",
+		"/bin/busybox": "BusyBox tar",
+		"":             "an unrecognized tar",
+	} {
+		if got := parseHostTar(out).String(); got != want {
+			t.Errorf("parseHostTar(%q) = %s, want %s", out, got, want)
+		}
+	}
+
+	old := parseHostTar("tar (GNU tar) 1.26")
+	if old.Supports(ArchiveFeatureXattrs) || !old.Supports(ArchiveFeatureLongPaths) || old.extractFlags() != "" {
+		t.Errorf("GNU tar 1.26 = %+v", old)
+	}
+	if !parseHostTar("tar (GNU tar) 1.30").supportsAll() {
+		t.Error("GNU tar 1.30 should extract every feature")
+	}
+}
+
+func TestArchiveFeatures(t *testing.T) {
+	long := "foo.com/www/" + strings.Repeat("d/", 60) + strings.Repeat("f", 101)
+	var f ArchiveFeatures
+	for _, hdr := range []*tar.Header{
+		{Name: "foo.com/www/index.php", Typeflag: tar.TypeReg},
+		{Name: "foo.com/www/" + strings.Repeat("a", 95) + "/index.php", Typeflag: tar.TypeReg},
+		{Name: "foo.com/db.img", Typeflag: tar.TypeGNUSparse},
+		{Name: "foo.com/www/secure", Typeflag: tar.TypeReg, PAXRecords: map[string]string{"SCHILY.xattr.security.selinux": "x"}},
+	} {
+		f.observe(hdr)
+	}
+	if got := strings.Join(f.Names(), ","); got != "sparse,xattrs" {
+		t.Errorf("Names() = %s; a name split over the ustar prefix is not long", got)
+	}
+	f.observe(&tar.Header{Name: long, Typeflag: tar.TypeReg})
+	if !f.LongPaths {
+		t.Error("observe() missed a path that no ustar header fits")
+	}
+
+	err := checkArchiveCompat("backups/foo.com/foo.com.tgz", f, parseHostTar("BusyBox v1.36.1"))
+	var compat *ArchiveCompatError
+	if !errors.As(err, &compat) || len(compat.Problems) != 1 || !strings.Contains(err.Error(), "foo.com/www/secure") || !strings.Contains(err.Error(), "--compat") {
+		t.Errorf("checkArchiveCompat(busybox) = %v", err)
+	}
+	if err := checkArchiveCompat("x", f, parseHostTar("")); !errors.As(err, &compat) || len(compat.Problems) != 2 {
+		t.Errorf("checkArchiveCompat(unknown tar) = %v", err)
+	}
+	if err := checkArchiveCompat("x", f, parseHostTar("bsdtar 3.6.2")); err != nil {
+		t.Errorf("checkArchiveCompat(bsdtar) = %v", err)
+	}
+}
+
+func TestNormalizeArchiveHeader(t *testing.T) {
+	long := "foo.com/" + strings.Repeat("x", 150)
+	for _, compat := range []bool{false, true} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		hdr := &tar.Header{Name: long, Typeflag: tar.TypeGNUSparse, Size: 2, Mode: 0o644,
+			PAXRecords: map[string]string{"SCHILY.xattr.user.tag": "v", "GNU.sparse.major": "1"}}
+		normalizeArchiveHeader(hdr, compat)
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader(compat=%v) error = %v", compat, err)
+		}
+		tw.Write([]byte("hi"))
+		tw.Close()
+
+		got, err := tar.NewReader(&buf).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != long || got.Typeflag != tar.TypeReg {
+			t.Errorf("compat=%v: read back %q type %q", compat, got.Name, got.Typeflag)
+		}
+		if _, ok := got.PAXRecords["GNU.sparse.major"]; ok {
+			t.Errorf("compat=%v: sparse records were kept", compat)
+		}
+		if _, ok := got.PAXRecords["SCHILY.xattr.user.tag"]; ok == compat {
+			t.Errorf("compat=%v: xattr kept = %v", compat, ok)
+		}
+		if compat && got.Format != tar.FormatGNU {
+			t.Errorf("compat: format = %v, want GNU long names", got.Format)
+		}
+	}
+}
+
+func TestOpenBackupTarZstd(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	tw.WriteHeader(&tar.Header{Name: "foo.com/index.php", Typeflag: tar.TypeReg, Size: 5, Mode: 0o644})
+	tw.Write([]byte("<?php"))
+	tw.Close()
+	zw.Close()
+
+	tr, err := openBackupTar("backups/foo.com/foo.com-20260501-020000.tar.zst", &buf, "foo.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != "foo.com/index.php" {
+		t.Fatalf("Next() = %v, %v", hdr, err)
+	}
+	if body, _ := io.ReadAll(tr); string(body) != "<?php" {
+		t.Errorf("body = %q", body)
+	}
+}
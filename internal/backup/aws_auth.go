@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/processcreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+// AWS credential sources selected by AWSConfig.Auth.
+const (
+	// AWSAuthStatic uses AccessKey and SecretKey.
+	AWSAuthStatic = "static"
+	// AWSAuthChain uses the default AWS credential chain: the AWS_ACCESS_KEY_ID
+	// environment variables, the shared config and credentials files (with
+	// SSO and assumed roles), web identity tokens and the ECS or EC2 instance
+	// role.
+	AWSAuthChain = "chain"
+)
+
+// ParseAWSAuth validates an --aws-auth value; "" picks the source from the
+// keys (see AWSConfig.AuthMode).
+func ParseAWSAuth(s string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case "", AWSAuthStatic, AWSAuthChain:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid AWS auth %q (use static or chain)", s)
+	}
+}
+
+// AuthMode returns the credential source in effect: Auth when set, else
+// static when an access key is configured and the default chain otherwise.
+func (c *AWSConfig) AuthMode() string {
+	if c.Auth != "" {
+		return c.Auth
+	}
+	if c.AccessKey != "" {
+		return AWSAuthStatic
+	}
+	return AWSAuthChain
+}
+
+// validateAuth reports an unknown credential source and a static one
+// without keys.
+func (c *AWSConfig) validateAuth() error {
+	switch c.Auth {
+	case "", AWSAuthStatic, AWSAuthChain:
+	default:
+		return fmt.Errorf("invalid --aws-auth %q (use static or chain)", c.Auth)
+	}
+	if c.AuthMode() == AWSAuthStatic && (c.AccessKey == "" || c.SecretKey == "") {
+		return fmt.Errorf("aws-access-key and aws-secret-access-key are required with --aws-auth static (or use --aws-auth chain for instance profiles, SSO and web identity)")
+	}
+	return nil
+}
+
+// credentialOptions returns the config options selecting the credential
+// source of c; the default chain needs none.
+func (c *AWSConfig) credentialOptions() []func(*awsconfig.LoadOptions) error {
+	if c.AuthMode() != AWSAuthStatic {
+		return nil
+	}
+	return []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithCredentialsProvider(awscredentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")),
+	}
+}
+
+// awsCredentialSource retrieves the credentials of cfg once, so a chain that
+// finds nothing fails with a clear error before the first request, and
+// describes where they came from.
+func awsCredentialSource(ctx context.Context, cfg aws.Config, mode string) (string, error) {
+	if cfg.Credentials == nil {
+		return "", fmt.Errorf("no AWS credentials configured")
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		if mode == AWSAuthChain {
+			return "", fmt.Errorf("no AWS credentials found in the default chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, ECS or EC2 instance role): %w", err)
+		}
+		return "", fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	return describeCredentialSource(creds.Source), nil
+}
+
+// describeCredentialSource turns the Source of retrieved AWS credentials
+// into a description for diagnostics.
+func describeCredentialSource(source string) string {
+	switch {
+	case source == awscredentials.StaticCredentialsName:
+		return "static keys (--aws-access-key)"
+	case source == awsconfig.CredentialsSourceName:
+		return "environment (AWS_ACCESS_KEY_ID)"
+	case strings.HasPrefix(source, "SharedConfigCredentials"):
+		if _, file, ok := strings.Cut(source, ": "); ok && file != "" {
+			return "shared credentials file " + file
+		}
+		return "shared credentials file"
+	case source == ssocreds.ProviderName:
+		return "SSO (shared config profile)"
+	case source == stscreds.WebIdentityProviderName:
+		return "web identity token (AWS_WEB_IDENTITY_TOKEN_FILE)"
+	case source == stscreds.ProviderName:
+		return "assumed role (shared config profile)"
+	case source == ec2rolecreds.ProviderName:
+		return "EC2 instance profile"
+	case source == endpointcreds.ProviderName:
+		return "container credentials endpoint (ECS task role)"
+	case source == processcreds.ProviderName:
+		return "credential process (shared config profile)"
+	case source == "":
+		return "unknown source"
+	default:
+		return source
+	}
+}
+
+// AWSCredentialSource describes where the AWS credentials in use came from,
+// e.g. "EC2 instance profile"; it is empty until the Glacier client is
+// initialized.
+func (bm *BackupManager) AWSCredentialSource() string {
+	return bm.awsCredentialSource
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+)
+
+func TestAWSAuthMode(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  AWSConfig
+		want string
+	}{
+		{AWSConfig{AccessKey: "ak", SecretKey: "sk"}, AWSAuthStatic},
+		{AWSConfig{}, AWSAuthChain},
+		{AWSConfig{AccessKey: "ak", SecretKey: "sk", Auth: AWSAuthChain}, AWSAuthChain},
+	} {
+		if got := tc.cfg.AuthMode(); got != tc.want {
+			t.Errorf("AuthMode(%+v) = %s, want %s", tc.cfg, got, tc.want)
+		}
+	}
+
+	if opts := (&AWSConfig{}).credentialOptions(); len(opts) != 0 {
+		t.Errorf("chain credentialOptions() = %d options, want none", len(opts))
+	}
+	if auth, err := ParseAWSAuth(" Chain "); err != nil || auth != AWSAuthChain {
+		t.Errorf("ParseAWSAuth(Chain) = %q, %v", auth, err)
+	}
+
+	err := (&CommandConfig{AWS: &AWSConfig{Vault: "vault", Auth: AWSAuthStatic, AccessKey: "ak"}}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "--aws-auth static") {
+		t.Errorf("Validate(static without a secret) error = %v", err)
+	}
+	if err := (&CommandConfig{AWS: &AWSConfig{Vault: "vault", Auth: "role"}}).Validate(); err == nil {
+		t.Error("Validate() accepted --aws-auth role")
+	}
+	if err := (&CommandConfig{AWS: &AWSConfig{Vault: "vault"}}).Validate(); err != nil {
+		t.Errorf("Validate(chain without keys) error = %v", err)
+	}
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{}, errors.New("no EC2 IMDS role found")
+}
+
+type sourceProvider string
+
+func (p sourceProvider) Retrieve(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "ak", SecretAccessKey: "sk", Source: string(p)}, nil
+}
+
+func TestAWSCredentialSource(t *testing.T) {
+	ctx := context.Background()
+	static := aws.Config{Credentials: awscredentials.NewStaticCredentialsProvider("ak", "sk", "")}
+	if got, err := awsCredentialSource(ctx, static, AWSAuthStatic); err != nil || !strings.Contains(got, "static keys") {
+		t.Errorf("awsCredentialSource(static) = %q, %v", got, err)
+	}
+	role := aws.Config{Credentials: sourceProvider(ec2rolecreds.ProviderName)}
+	if got, _ := awsCredentialSource(ctx, role, AWSAuthChain); got != "EC2 instance profile" {
+		t.Errorf("awsCredentialSource(instance role) = %q", got)
+	}
+	if got := describeCredentialSource("SharedConfigCredentials: /home/deploy/.aws/credentials"); got != "shared credentials file /home/deploy/.aws/credentials" {
+		t.Errorf("describeCredentialSource(shared) = %q", got)
+	}
+
+	_, err := awsCredentialSource(ctx, aws.Config{Credentials: failingProvider{}}, AWSAuthChain)
+	if err == nil || !strings.Contains(err.Error(), "default chain") || !strings.Contains(err.Error(), "IMDS") {
+		t.Errorf("awsCredentialSource(empty chain) error = %v", err)
+	}
+}
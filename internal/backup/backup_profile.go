@@ -0,0 +1,305 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultBackupProfileName is the profile applied when none is selected.
+const DefaultBackupProfileName = "default"
+
+// BackupProfile is an operator's connection settings written by
+// `backup init` to ~/.ciwg/profiles/<name>.yaml. The file holds credentials,
+// so it is written 0600 and refused when other users can read it:
+//
+//	minio:
+//	  endpoint: minio.example.com:9000
+//	  access_key: ...
+//	  secret_key: ...
+//	  bucket: backups
+//	aws:
+//	  vault: backups
+//	  region: us-east-1
+//	ssh:
+//	  user: deploy
+//	  key: ~/.ssh/id_ed25519
+//	replica:
+//	  profile: dr              # entry in ~/.ciwg/minio-profiles.yaml
+//	  propagate_deletes: false # write-once DR
+//	tuning:                    # written by backup bench
+//	  minio_part_size: 64MB
+//	  minio_concurrency: 4
+//	  glacier_part_size: 32MB
+type BackupProfile struct {
+	Minio   MinioProfile          `yaml:"minio"`
+	AWS     *BackupProfileAWS     `yaml:"aws,omitempty"`
+	SSH     BackupProfileSSH      `yaml:"ssh"`
+	Replica *BackupProfileReplica `yaml:"replica,omitempty"`
+	// Compression sets per-destination codecs for dual uploads.
+	Compression *BackupProfileCompression `yaml:"compression,omitempty"`
+	// Permissions restricts the operations the profile may run.
+	Permissions *BackupProfilePermissions `yaml:"permissions,omitempty"`
+	// Tuning holds upload settings, usually written by `backup bench`.
+	Tuning *BackupProfileTuning `yaml:"tuning,omitempty"`
+}
+
+// BackupProfileTuning holds the part sizes (e.g. 64MB) and concurrency of
+// uploads. Empty fields leave the defaults in place.
+type BackupProfileTuning struct {
+	MinioPartSize    string `yaml:"minio_part_size,omitempty"`
+	MinioConcurrency int    `yaml:"minio_concurrency,omitempty"`
+	GlacierPartSize  string `yaml:"glacier_part_size,omitempty"`
+}
+
+// BackupProfileCompression holds the compression of each destination, in
+// the form accepted by ParseCompressionSpec (e.g. gzip-1, zstd-19).
+type BackupProfileCompression struct {
+	Minio   string `yaml:"minio,omitempty"`
+	Glacier string `yaml:"glacier,omitempty"`
+}
+
+// BackupProfileReplica names the DR replica of the profile's bucket and
+// whether retention deletes on the primary apply to it.
+type BackupProfileReplica struct {
+	Profile          string `yaml:"profile"`
+	PropagateDeletes bool   `yaml:"propagate_deletes"`
+}
+
+// BackupProfileAWS holds the optional Glacier settings of a profile.
+type BackupProfileAWS struct {
+	Vault        string `yaml:"vault"`
+	AccountID    string `yaml:"account_id,omitempty"`
+	AccessKey    string `yaml:"access_key,omitempty"`
+	AccessKeyEnv string `yaml:"access_key_env,omitempty"`
+	SecretKey    string `yaml:"secret_key,omitempty"`
+	SecretKeyEnv string `yaml:"secret_key_env,omitempty"`
+	Region       string `yaml:"region,omitempty"`
+	// Auth is static or chain; see AWSConfig.Auth.
+	Auth string `yaml:"auth,omitempty"`
+}
+
+// BackupProfileSSH holds the SSH defaults of a profile.
+type BackupProfileSSH struct {
+	User    string `yaml:"user,omitempty"`
+	Port    string `yaml:"port,omitempty"`
+	Key     string `yaml:"key,omitempty"`
+	Agent   *bool  `yaml:"agent,omitempty"`
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// BackupProfilePath returns the file of the named profile
+// (~/.ciwg/profiles/<name>.yaml).
+func BackupProfilePath(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "profiles", name+".yaml")
+}
+
+// LoadBackupProfile reads a profile written by SaveBackupProfile. Profiles
+// readable by group or others are rejected because they hold credentials.
+func LoadBackupProfile(path string) (*BackupProfile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup profile: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("backup profile %s is accessible by other users (mode %s); run chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup profile: %w", err)
+	}
+	var p BackupProfile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse backup profile %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// SaveBackupProfile writes p to path with owner-only permissions, replacing
+// any existing file atomically.
+func SaveBackupProfile(path string, p *BackupProfile) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode backup profile: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".profile-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write backup profile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup profile: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup profile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup profile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write backup profile: %w", err)
+	}
+	return nil
+}
+
+// Validate checks that the profile has what every backup command needs.
+func (p *BackupProfile) Validate() error {
+	if p.Minio.Endpoint == "" {
+		return fmt.Errorf("minio endpoint is required")
+	}
+	if _, err := p.MinioConfig(); err != nil {
+		return err
+	}
+	if p.AWS != nil && p.AWS.Vault == "" {
+		return fmt.Errorf("aws vault is required when aws is configured")
+	}
+	if p.Replica != nil && p.Replica.Profile == "" {
+		return fmt.Errorf("replica profile is required when replica is configured")
+	}
+	if err := p.Permissions.Validate(); err != nil {
+		return err
+	}
+	if c := p.Compression; c != nil {
+		minio, err := ParseCompressionSpec(c.Minio)
+		if err != nil {
+			return fmt.Errorf("compression.minio: %w", err)
+		}
+		if minio.Codec != "" && minio.Codec != CodecGzip {
+			return fmt.Errorf("compression.minio must be gzip, got %s", minio)
+		}
+		glacier, err := ParseCompressionSpec(c.Glacier)
+		if err != nil {
+			return fmt.Errorf("compression.glacier: %w", err)
+		}
+		if glacier.Rsyncable {
+			return fmt.Errorf("compression.glacier cannot be rsyncable, got %s", glacier)
+		}
+	}
+	return nil
+}
+
+// MinioConfig returns the Minio connection of the profile.
+func (p *BackupProfile) MinioConfig() (*MinioConfig, error) {
+	return p.Minio.config("minio")
+}
+
+// AWSConfig returns the Glacier connection of the profile, or nil when the
+// profile has no AWS settings.
+func (p *BackupProfile) AWSConfig() *AWSConfig {
+	if p.AWS == nil {
+		return nil
+	}
+	a := p.AWS
+	cfg := &AWSConfig{
+		Vault:     a.Vault,
+		AccountID: a.AccountID,
+		AccessKey: envOr(a.AccessKeyEnv, a.AccessKey),
+		SecretKey: envOr(a.SecretKeyEnv, a.SecretKey),
+		Region:    a.Region,
+		Auth:      a.Auth,
+	}
+	if cfg.AccountID == "" {
+		cfg.AccountID = "-"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return cfg
+}
+
+// Env returns the profile as the environment variables read by the backup
+// command flags. Unset fields are left out so flag defaults still apply.
+func (p *BackupProfile) Env() map[string]string {
+	env := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			env[key] = value
+		}
+	}
+
+	m := p.Minio
+	set("MINIO_ENDPOINT", m.Endpoint)
+	set("MINIO_ACCESS_KEY", envOr(m.AccessKeyEnv, m.AccessKey))
+	set("MINIO_SECRET_KEY", envOr(m.SecretKeyEnv, m.SecretKey))
+	set("MINIO_BUCKET", m.Bucket)
+	set("MINIO_BUCKET_PATH", m.BucketPath)
+	if m.SSL != nil {
+		set("MINIO_SSL", strconv.FormatBool(*m.SSL))
+	}
+	set("MINIO_HTTP_TIMEOUT", m.HTTPTimeout)
+	set("MINIO_CA_BUNDLE", m.CABundle)
+	set("MINIO_CLIENT_CERT", m.ClientCert)
+	set("MINIO_CLIENT_KEY", m.ClientKey)
+	if m.InsecureSkipVerify {
+		set("MINIO_INSECURE_SKIP_VERIFY", "true")
+	}
+
+	if a := p.AWSConfig(); a != nil {
+		set("AWS_VAULT", a.Vault)
+		set("AWS_ACCOUNT_ID", a.AccountID)
+		set("AWS_ACCESS_KEY", a.AccessKey)
+		set("AWS_SECRET_ACCESS_KEY", a.SecretKey)
+		set("AWS_REGION", a.Region)
+		set("AWS_AUTH", a.Auth)
+	}
+
+	s := p.SSH
+	set("SSH_USER", s.User)
+	set("SSH_PORT", s.Port)
+	set("SSH_KEY", expandHome(s.Key))
+	if s.Agent != nil {
+		set("SSH_AGENT", strconv.FormatBool(*s.Agent))
+	}
+	set("SSH_TIMEOUT", s.Timeout)
+
+	if r := p.Replica; r != nil {
+		set("BACKUP_REPLICA_PROFILE", r.Profile)
+		set("BACKUP_PROPAGATE_DELETES", strconv.FormatBool(r.PropagateDeletes))
+	}
+	if c := p.Compression; c != nil {
+		set("BACKUP_MINIO_COMPRESSION", c.Minio)
+		set("BACKUP_GLACIER_COMPRESSION", c.Glacier)
+	}
+	if t := p.Tuning; t != nil {
+		set("MINIO_PART_SIZE", t.MinioPartSize)
+		if t.MinioConcurrency > 0 {
+			set("MINIO_UPLOAD_CONCURRENCY", strconv.Itoa(t.MinioConcurrency))
+		}
+		set("AWS_GLACIER_PART_SIZE", t.GlacierPartSize)
+	}
+	return env
+}
+
+// envOr returns the variable named by key when key is set, else value.
+func envOr(key, value string) string {
+	if key != "" {
+		return os.Getenv(key)
+	}
+	return value
+}
+
+// expandHome expands a leading ~/ to the user's home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
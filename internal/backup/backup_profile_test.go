@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBackupProfileSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles", "default.yaml")
+	ssl := false
+	p := &BackupProfile{
+		Minio: MinioProfile{Endpoint: "minio.example.com:9000", AccessKey: "ak", SecretKey: "sk", Bucket: "backups", SSL: &ssl},
+		AWS:   &BackupProfileAWS{Vault: "vault"},
+		SSH:   BackupProfileSSH{User: "deploy", Port: "2222"},
+	}
+	if err := SaveBackupProfile(path, p); err != nil {
+		t.Fatalf("SaveBackupProfile() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("profile mode = %s, want 0600", info.Mode().Perm())
+	}
+
+	got, err := LoadBackupProfile(path)
+	if err != nil {
+		t.Fatalf("LoadBackupProfile() error = %v", err)
+	}
+	if got.Minio.Endpoint != "minio.example.com:9000" || got.AWS == nil || got.AWS.Vault != "vault" || got.SSH.Port != "2222" {
+		t.Errorf("LoadBackupProfile() = %+v", got)
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBackupProfile(path); err == nil {
+		t.Error("LoadBackupProfile() accepted a world-readable profile")
+	}
+}
+
+func TestBackupProfileValidate(t *testing.T) {
+	if err := (&BackupProfile{}).Validate(); err == nil {
+		t.Error("Validate() accepted a profile without a Minio endpoint")
+	}
+	p := &BackupProfile{Minio: MinioProfile{Endpoint: "localhost:9000"}, AWS: &BackupProfileAWS{}}
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() accepted AWS settings without a vault")
+	}
+	p = &BackupProfile{Minio: MinioProfile{Endpoint: "localhost:9000", AccessKey: "ak", SecretKey: "sk"}, Compression: &BackupProfileCompression{Minio: "zstd-3"}}
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() accepted zstd for Minio")
+	}
+	p.Compression = &BackupProfileCompression{Minio: "gzip-1", Glacier: "zstd-19"}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestBackupProfileEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/op")
+	t.Setenv("PROFILE_TEST_SECRET", "from-env")
+	agent := true
+	p := &BackupProfile{
+		Minio:       MinioProfile{Endpoint: "localhost:9000", AccessKey: "ak", SecretKeyEnv: "PROFILE_TEST_SECRET"},
+		AWS:         &BackupProfileAWS{Vault: "vault"},
+		SSH:         BackupProfileSSH{User: "deploy", Key: "~/.ssh/id_ed25519", Agent: &agent},
+		Replica:     &BackupProfileReplica{Profile: "dr"},
+		Compression: &BackupProfileCompression{Glacier: "zstd-19"},
+		Tuning:      &BackupProfileTuning{MinioPartSize: "64MB", MinioConcurrency: 4},
+	}
+	env := p.Env()
+
+	want := map[string]string{
+		"MINIO_ENDPOINT":             "localhost:9000",
+		"MINIO_ACCESS_KEY":           "ak",
+		"MINIO_SECRET_KEY":           "from-env",
+		"AWS_VAULT":                  "vault",
+		"AWS_ACCOUNT_ID":             "-",
+		"AWS_REGION":                 "us-east-1",
+		"SSH_USER":                   "deploy",
+		"SSH_KEY":                    "/home/op/.ssh/id_ed25519",
+		"SSH_AGENT":                  "true",
+		"BACKUP_REPLICA_PROFILE":     "dr",
+		"BACKUP_PROPAGATE_DELETES":   "false",
+		"BACKUP_GLACIER_COMPRESSION": "zstd-19",
+		"MINIO_PART_SIZE":            "64MB",
+		"MINIO_UPLOAD_CONCURRENCY":   "4",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("Env()[%s] = %q, want %q", k, env[k], v)
+		}
+	}
+	for _, k := range []string{"MINIO_BUCKET", "SSH_PORT", "AWS_ACCESS_KEY", "BACKUP_MINIO_COMPRESSION", "AWS_GLACIER_PART_SIZE"} {
+		if _, ok := env[k]; ok {
+			t.Errorf("Env() set %s for an empty field", k)
+		}
+	}
+}
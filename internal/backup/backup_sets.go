@@ -0,0 +1,294 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backup set part kinds. A backup is normally a single <stem>.tgz archive,
+// but a split backup stores <stem>.files.tgz and <stem>.db.<ext> separately,
+// and any part can be sharded as <name>.part-NNN-of-MMM.<ext>. All objects
+// sharing a stem form one BackupSet that retention keeps or deletes as a unit.
+const (
+	PartArchive  = "archive"
+	PartFiles    = "files"
+	PartDatabase = "database"
+)
+
+var (
+	shardPattern    = regexp.MustCompile(`^(.+)\.part-(\d+)-of-(\d+)(\.[^./]+(?:\.[^./]+)?)$`)
+	databasePattern = regexp.MustCompile(`^(.+)\.db\.[A-Za-z0-9]+(?:\.[A-Za-z0-9]+)?$`)
+)
+
+// backupNameTime matches the <label>-YYYYMMDD-HHMMSS stem given to every
+// backup by processContainer.
+var backupNameTime = regexp.MustCompile(`^(.+)-(\d{8}-\d{6})$`)
+
+// parseBackupName returns the label and timestamp in the name of the backup
+// key belongs to. Names are stamped in the canonical timezone (see
+// SetTimezone).
+func parseBackupName(key string) (label string, t time.Time, ok bool) {
+	m := backupNameTime.FindStringSubmatch(path.Base(parseBackupPart(key).Stem))
+	if m == nil {
+		return "", time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102-150405", m[2], Timezone())
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return m[1], t, true
+}
+
+// BackupTime returns when the backup o belongs to was taken: the timestamp
+// in its name, or LastModified for names without one. LastModified changes
+// whenever an object is copied or migrated, so date ranges, retention and
+// reports all go by this instead.
+func BackupTime(o ObjectInfo) time.Time {
+	if _, t, ok := parseBackupName(o.Key); ok {
+		return t
+	}
+	return o.LastModified
+}
+
+// backupPart describes where one object fits in its backup set.
+type backupPart struct {
+	Stem   string
+	Kind   string
+	Shard  int
+	Shards int
+}
+
+// parseBackupPart classifies an object key by the set naming convention.
+// Keys that match none of it are single-object archive sets keyed by
+// themselves.
+func parseBackupPart(key string) backupPart {
+	p := backupPart{Kind: PartArchive}
+	name := key
+	if m := shardPattern.FindStringSubmatch(key); m != nil {
+		shard, err1 := strconv.Atoi(m[2])
+		shards, err2 := strconv.Atoi(m[3])
+		if err1 == nil && err2 == nil && shards > 0 {
+			name = m[1] + m[4]
+			p.Shard, p.Shards = shard, shards
+		}
+	}
+
+	dir, base := path.Split(name)
+	switch {
+	case strings.HasSuffix(base, ".files.tgz"):
+		p.Stem = dir + strings.TrimSuffix(base, ".files.tgz")
+		p.Kind = PartFiles
+	case databasePattern.MatchString(base):
+		p.Stem = dir + databasePattern.FindStringSubmatch(base)[1]
+		p.Kind = PartDatabase
+	case strings.HasSuffix(base, ".tgz"):
+		p.Stem = dir + strings.TrimSuffix(base, ".tgz")
+	case strings.HasSuffix(base, ".tar.zst"):
+		p.Stem = dir + strings.TrimSuffix(base, ".tar.zst")
+	case isZipBackup(base):
+		// Legacy backups of earlier tooling; see 'backup import'.
+		p.Stem = dir + base[:len(base)-len(".zip")]
+	default:
+		p.Stem = name
+	}
+	return p
+}
+
+// BackupSet is every object belonging to one backup.
+type BackupSet struct {
+	Stem    string
+	Objects []ObjectInfo
+	// LastModified is the newest member, i.e. when the backup finished.
+	LastModified time.Time
+	Size         int64
+	// Missing lists the parts needed for a restore that are not present.
+	Missing []string
+}
+
+// Time returns when the backup was taken; see BackupTime.
+func (s BackupSet) Time() time.Time {
+	return BackupTime(ObjectInfo{Key: s.Stem, LastModified: s.LastModified})
+}
+
+// Complete reports whether the set can be restored.
+func (s BackupSet) Complete() bool { return len(s.Missing) == 0 }
+
+// Keys returns the object keys of the set.
+func (s BackupSet) Keys() []string {
+	keys := make([]string, len(s.Objects))
+	for i, o := range s.Objects {
+		keys[i] = o.Key
+	}
+	return keys
+}
+
+// GroupBackupSets groups objects into backup sets, newest backup first.
+func GroupBackupSets(objs []ObjectInfo) []BackupSet {
+	byStem := make(map[string]*BackupSet)
+	parts := make(map[string][]backupPart)
+	var order []string
+	for _, o := range objs {
+		p := parseBackupPart(o.Key)
+		set, ok := byStem[p.Stem]
+		if !ok {
+			set = &BackupSet{Stem: p.Stem}
+			byStem[p.Stem] = set
+			order = append(order, p.Stem)
+		}
+		set.Objects = append(set.Objects, o)
+		set.Size += o.Size
+		if o.LastModified.After(set.LastModified) {
+			set.LastModified = o.LastModified
+		}
+		parts[p.Stem] = append(parts[p.Stem], p)
+	}
+
+	sets := make([]BackupSet, 0, len(order))
+	for _, stem := range order {
+		set := byStem[stem]
+		sort.Slice(set.Objects, func(i, j int) bool { return set.Objects[i].Key < set.Objects[j].Key })
+		set.Missing = missingParts(parts[stem])
+		sets = append(sets, *set)
+	}
+	sort.SliceStable(sets, func(i, j int) bool { return sets[i].Time().After(sets[j].Time()) })
+	return sets
+}
+
+// missingParts checks that split backups have both halves and that every
+// sharded part has all of its shards.
+func missingParts(parts []backupPart) []string {
+	kinds := make(map[string]bool)
+	shards := make(map[string]map[int]bool)
+	totals := make(map[string]int)
+	var missing []string
+	for _, p := range parts {
+		kinds[p.Kind] = true
+		if p.Shards == 0 {
+			continue
+		}
+		if shards[p.Kind] == nil {
+			shards[p.Kind] = make(map[int]bool)
+		}
+		shards[p.Kind][p.Shard] = true
+		if t, ok := totals[p.Kind]; ok && t != p.Shards {
+			missing = append(missing, fmt.Sprintf("%s shards disagree on count (%d vs %d)", p.Kind, t, p.Shards))
+		}
+		if p.Shards > totals[p.Kind] {
+			totals[p.Kind] = p.Shards
+		}
+	}
+
+	if kinds[PartFiles] && !kinds[PartDatabase] {
+		missing = append(missing, "database")
+	}
+	if kinds[PartDatabase] && !kinds[PartFiles] {
+		missing = append(missing, "files")
+	}
+	for _, kind := range []string{PartArchive, PartFiles, PartDatabase} {
+		for i := 1; i <= totals[kind]; i++ {
+			if !shards[kind][i] {
+				missing = append(missing, fmt.Sprintf("%s shard %d of %d", kind, i, totals[kind]))
+			}
+		}
+	}
+	return missing
+}
+
+// selectBackupSets applies an object-level selection to whole backup sets:
+// each set is represented by one object carrying its stem, total size and
+// newest modification time, and the sets picked by sel are expanded back to
+// all of their objects. Incomplete sets are left out unless includeIncomplete
+// is set, so retention neither counts them as kept backups nor deletes half
+// of a backup that may still be uploading.
+func (bm *BackupManager) selectBackupSets(objs []ObjectInfo, includeIncomplete bool, sel func([]ObjectInfo) []ObjectInfo) []ObjectInfo {
+	sets := GroupBackupSets(objs)
+	byStem := make(map[string]BackupSet, len(sets))
+	var reps []ObjectInfo
+	for _, s := range sets {
+		if !s.Complete() && !includeIncomplete {
+			fmt.Printf("⚠️  Skipping incomplete backup %s (missing %s)\n", s.Stem, strings.Join(s.Missing, ", "))
+			continue
+		}
+		byStem[s.Stem] = s
+		reps = append(reps, ObjectInfo{Key: s.Stem, Size: s.Size, LastModified: s.LastModified})
+	}
+
+	var selected []ObjectInfo
+	for _, rep := range sel(reps) {
+		selected = append(selected, byStem[rep.Key].Objects...)
+	}
+	if selected == nil {
+		selected = []ObjectInfo{}
+	}
+	return selected
+}
+
+// ExpandToBackupSets adds the other objects of every backup set touched by
+// keys, so deleting one part never leaves the rest of a backup behind. The
+// returned keys keep the original order followed by the added siblings.
+func (bm *BackupManager) ExpandToBackupSets(keys []string) ([]string, error) {
+	dirs := make(map[string]bool)
+	for _, k := range keys {
+		dirs[path.Dir(k)+"/"] = true
+	}
+
+	var all []ObjectInfo
+	for dir := range dirs {
+		prefix := dir
+		if prefix == "./" {
+			prefix = ""
+		}
+		objs, err := bm.ListBackups(prefix, 0)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, objs...)
+	}
+
+	want := make(map[string]bool)
+	for _, k := range keys {
+		want[parseBackupPart(k).Stem] = true
+	}
+	seen := make(map[string]bool, len(keys))
+	out := append([]string(nil), keys...)
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for _, s := range GroupBackupSets(all) {
+		if !want[s.Stem] {
+			continue
+		}
+		for _, k := range s.Keys() {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	return out, nil
+}
+
+// LatestBackupSet returns the newest complete backup set under prefix.
+func (bm *BackupManager) LatestBackupSet(prefix string) (*BackupSet, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	var usable []ObjectInfo
+	for _, o := range objs {
+		if !isInternalObject(o.Key) {
+			usable = append(usable, o)
+		}
+	}
+	for _, s := range GroupBackupSets(usable) {
+		if s.Complete() {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("no complete backup found under %s", prefix)
+}
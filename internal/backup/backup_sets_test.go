@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBackupPart(t *testing.T) {
+	tests := []struct {
+		key  string
+		want backupPart
+	}{
+		{"backups/a.com/a-20240101.tgz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartArchive}},
+		{"backups/a.com/a-20240101.files.tgz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartFiles}},
+		{"backups/a.com/a-20240101.db.sql.gz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartDatabase}},
+		{"backups/a.com/a-20240101.part-002-of-003.tgz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartArchive, Shard: 2, Shards: 3}},
+		{"backups/a.com/a-20240101.files.part-001-of-002.tgz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartFiles, Shard: 1, Shards: 2}},
+		{"backups/a.com/a-20240101.db.part-001-of-002.sql.gz", backupPart{Stem: "backups/a.com/a-20240101", Kind: PartDatabase, Shard: 1, Shards: 2}},
+		// A domain containing ".db." is not a database part.
+		{"backups/shop.db.example.com/shop.db.example.com-20240101.tgz", backupPart{Stem: "backups/shop.db.example.com/shop.db.example.com-20240101", Kind: PartArchive}},
+		{"notes.txt", backupPart{Stem: "notes.txt", Kind: PartArchive}},
+	}
+	for _, tt := range tests {
+		if got := parseBackupPart(tt.key); got != tt.want {
+			t.Errorf("parseBackupPart(%q) = %+v, want %+v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGroupBackupSetsCompleteness(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "s/old.tgz", Size: 1, LastModified: base},
+		{Key: "s/split.files.tgz", Size: 2, LastModified: base.Add(time.Hour)},
+		{Key: "s/split.db.sql.gz", Size: 3, LastModified: base.Add(time.Hour + time.Minute)},
+		{Key: "s/orphan.files.tgz", Size: 4, LastModified: base.Add(2 * time.Hour)},
+		{Key: "s/shard.part-001-of-003.tgz", Size: 5, LastModified: base.Add(3 * time.Hour)},
+		{Key: "s/shard.part-003-of-003.tgz", Size: 5, LastModified: base.Add(3 * time.Hour)},
+	}
+	sets := GroupBackupSets(objs)
+	got := make(map[string][]string)
+	var order []string
+	for _, s := range sets {
+		got[s.Stem] = s.Missing
+		order = append(order, s.Stem)
+	}
+	if want := []string{"s/shard", "s/orphan", "s/split", "s/old"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if len(got["s/old"]) != 0 || len(got["s/split"]) != 0 {
+		t.Errorf("complete sets reported missing parts: %v", got)
+	}
+	if !reflect.DeepEqual(got["s/orphan"], []string{"database"}) {
+		t.Errorf("orphan missing = %v", got["s/orphan"])
+	}
+	if !reflect.DeepEqual(got["s/shard"], []string{"archive shard 2 of 3"}) {
+		t.Errorf("shard missing = %v", got["s/shard"])
+	}
+	if sets[2].Size != 5 || sets[2].LastModified != base.Add(time.Hour+time.Minute) {
+		t.Errorf("split set = %+v", sets[2])
+	}
+}
+
+func TestRetentionKeepsBackupSetsTogether(t *testing.T) {
+	bm := &BackupManager{}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "s/b1.files.tgz", LastModified: base.Add(4 * time.Hour)},
+		{Key: "s/b1.db.sql.gz", LastModified: base.Add(4 * time.Hour)},
+		{Key: "s/b2.files.tgz", LastModified: base.Add(3 * time.Hour)},
+		{Key: "s/b2.db.sql.gz", LastModified: base.Add(3 * time.Hour)},
+		{Key: "s/b3.tgz", LastModified: base.Add(2 * time.Hour)},
+		// Incomplete: never counted as kept and never deleted.
+		{Key: "s/b4.files.tgz", LastModified: base.Add(time.Hour)},
+		{Key: "s/b5.part-001-of-002.tgz", LastModified: base},
+		{Key: "s/b5.part-002-of-002.tgz", LastModified: base},
+	}
+
+	keys := func(objs []ObjectInfo) []string {
+		var out []string
+		for _, o := range objs {
+			out = append(out, o.Key)
+		}
+		return out
+	}
+
+	got := keys(bm.SelectObjectsForOverwrite(objs, 2))
+	want := []string{"s/b3.tgz", "s/b5.part-001-of-002.tgz", "s/b5.part-002-of-002.tgz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectObjectsForOverwrite() = %v, want %v", got, want)
+	}
+
+	policy := &SmartRetentionPolicy{Enabled: true, KeepDaily: 1, WeeklyDay: -1}
+	got = keys(bm.SelectObjectsWithSmartRetention(objs, policy))
+	want = []string{"s/b2.db.sql.gz", "s/b2.files.tgz", "s/b3.tgz", "s/b5.part-001-of-002.tgz", "s/b5.part-002-of-002.tgz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectObjectsWithSmartRetention() = %v, want %v", got, want)
+	}
+
+	sel, err := bm.SelectObjectsByNumericRange(objs, 1, 1)
+	if err != nil {
+		t.Fatalf("SelectObjectsByNumericRange() error = %v", err)
+	}
+	if got := keys(sel); !reflect.DeepEqual(got, []string{"s/b1.db.sql.gz", "s/b1.files.tgz"}) {
+		t.Errorf("SelectObjectsByNumericRange(1-1) = %v", got)
+	}
+}
+
+func TestExpandToBackupSets(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"s/a.files.tgz", "s/a.db.sql.gz", "s/b.tgz", "s/a-other.tgz"} {
+		if _, err := bm.putObject(context.Background(), key, strings.NewReader(key), -1, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := bm.ExpandToBackupSets([]string{"s/a.db.sql.gz"})
+	if err != nil {
+		t.Fatalf("ExpandToBackupSets() error = %v", err)
+	}
+	if want := []string{"s/a.db.sql.gz", "s/a.files.tgz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandToBackupSets() = %v, want %v", got, want)
+	}
+}
+
+func TestSmartRetentionUsesBackupName(t *testing.T) {
+	bm := &BackupManager{}
+	// Migrated back from staging on the 15th: by LastModified neither is a
+	// monthly backup, by name the first one is.
+	copied := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "s/a.com-20240201-020000.tgz", LastModified: copied},
+		{Key: "s/a.com-20240202-020000.tgz", LastModified: copied},
+		{Key: "s/a.com-20240203-020000.tgz", LastModified: copied},
+	}
+	policy := &SmartRetentionPolicy{Enabled: true, KeepDaily: 1, KeepMonthly: 1, WeeklyDay: -1, MonthlyDay: 1}
+	var got []string
+	for _, o := range bm.SelectObjectsWithSmartRetention(objs, policy) {
+		got = append(got, o.Key)
+	}
+	if want := []string{"s/a.com-20240202-020000.tgz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectObjectsWithSmartRetention() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,318 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/minio/minio-go/v7"
+)
+
+// S3 multipart limits on the size of a part.
+const (
+	minioMinPartSize int64 = 5 * 1024 * 1024
+	minioMaxPartSize int64 = 5 * 1024 * 1024 * 1024
+)
+
+// Bench targets.
+const (
+	BenchTargetMinio   = "minio"
+	BenchTargetGlacier = "glacier"
+)
+
+// Default bench grids.
+var (
+	DefaultBenchPartSizes   = []int64{16 << 20, 64 << 20, 128 << 20}
+	DefaultBenchConcurrency = []int{1, 4, 8}
+)
+
+// benchTolerance is how close to the fastest run a cheaper setting must be
+// to be recommended instead: within 5% isn't worth the extra memory.
+const benchTolerance = 0.95
+
+// BenchOptions configures BenchUploads.
+type BenchOptions struct {
+	// Target is BenchTargetMinio or BenchTargetGlacier.
+	Target string
+	// Size is the bytes uploaded by every run.
+	Size int64
+	// PartSizes and Concurrency are the grid tried; every combination is
+	// one run. Nil uses the defaults. Glacier migrations send one part at a
+	// time, so the Glacier bench ignores Concurrency.
+	PartSizes   []int64
+	Concurrency []int
+	// Progress, when set, is called before each run.
+	Progress func(i, n int, partSize int64, concurrency int)
+}
+
+// BenchResult is one run of the bench.
+type BenchResult struct {
+	PartSize    int64   `json:"part_size"`
+	Concurrency int     `json:"concurrency"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	MBps        float64 `json:"mbps"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// BenchReport is the outcome of BenchUploads.
+type BenchReport struct {
+	Target  string        `json:"target"`
+	Size    int64         `json:"size"`
+	Results []BenchResult `json:"results"`
+	// Recommended is nil when every run failed.
+	Recommended *BenchResult `json:"recommended,omitempty"`
+}
+
+// RecommendBench picks the setting to use from results: the fastest run,
+// or the one buffering the least (part size times concurrency) among those
+// within benchTolerance of it.
+func RecommendBench(results []BenchResult) *BenchResult {
+	var best *BenchResult
+	for i := range results {
+		r := &results[i]
+		if r.Error == "" && (best == nil || r.MBps > best.MBps) {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	pick := best
+	for i := range results {
+		r := &results[i]
+		if r.Error != "" || r.MBps < best.MBps*benchTolerance {
+			continue
+		}
+		if r.PartSize*int64(r.Concurrency) < pick.PartSize*int64(pick.Concurrency) {
+			pick = r
+		}
+	}
+	rec := *pick
+	return &rec
+}
+
+// benchData returns n bytes of incompressible data, so compression on the
+// way (proxies, the server) can't flatter the numbers.
+func benchData(n int64) []byte {
+	buf := make([]byte, n)
+	rand.NewChaCha8([32]byte{'c', 'i', 'w', 'g'}).Read(buf)
+	return buf
+}
+
+// benchReader streams size bytes by repeating block.
+type benchReader struct {
+	block []byte
+	left  int64
+	off   int
+}
+
+func (r *benchReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n := copy(p, r.block[r.off:])
+	r.off = (r.off + n) % len(r.block)
+	r.left -= int64(n)
+	return n, nil
+}
+
+// BenchRuns validates opts and returns the runs BenchUploads makes with
+// them, in order, without uploading anything. Glacier part sizes are rounded
+// to valid ones first.
+func BenchRuns(opts BenchOptions) ([]BenchResult, error) {
+	if opts.Size <= 0 {
+		return nil, fmt.Errorf("bench size must be positive")
+	}
+	partSizes, concurrency := append([]int64(nil), opts.PartSizes...), opts.Concurrency
+	if len(partSizes) == 0 {
+		partSizes = append(partSizes, DefaultBenchPartSizes...)
+	}
+	if opts.Target == BenchTargetGlacier {
+		concurrency = []int{1}
+	} else if len(concurrency) == 0 {
+		concurrency = DefaultBenchConcurrency
+	}
+	for _, c := range concurrency {
+		if c < 1 {
+			return nil, fmt.Errorf("bench concurrency must be at least 1, got %d", c)
+		}
+	}
+
+	switch opts.Target {
+	case BenchTargetMinio:
+		for _, ps := range partSizes {
+			if ps < minioMinPartSize || ps > minioMaxPartSize {
+				return nil, fmt.Errorf("minio part size %d is outside 5MB to 5GB", ps)
+			}
+		}
+	case BenchTargetGlacier:
+		for i, ps := range partSizes {
+			valid, err := GlacierPartSize(opts.Size, ps)
+			if err != nil {
+				return nil, err
+			}
+			partSizes[i] = valid
+		}
+	default:
+		return nil, fmt.Errorf("unknown bench target %q (want %s or %s)", opts.Target, BenchTargetMinio, BenchTargetGlacier)
+	}
+
+	// Glacier rounding can map several requested sizes to the same one.
+	sort.Slice(partSizes, func(i, j int) bool { return partSizes[i] < partSizes[j] })
+	var runs []BenchResult
+	for _, ps := range slices.Compact(partSizes) {
+		for _, c := range concurrency {
+			runs = append(runs, BenchResult{PartSize: ps, Concurrency: c, Bytes: opts.Size})
+		}
+	}
+	return runs, nil
+}
+
+// BenchUploads uploads synthetic data to the target with every combination
+// of part size and concurrency and reports the throughput of each. Nothing
+// is left behind: Minio objects are deleted after each run and Glacier
+// multipart uploads are aborted rather than completed, so no archive (and
+// no early deletion fee) is created.
+func (bm *BackupManager) BenchUploads(opts BenchOptions) (*BenchReport, error) {
+	runs, err := BenchRuns(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := bm.guardWrite("bench upload to", opts.Target); err != nil {
+		return nil, err
+	}
+
+	var run func(ctx context.Context, partSize int64, concurrency int) (time.Duration, error)
+	var block []byte
+	switch opts.Target {
+	case BenchTargetMinio:
+		if err := bm.initMinioClient(); err != nil {
+			return nil, err
+		}
+		if bm.fileStore != nil {
+			return nil, fmt.Errorf("%s is a filesystem backend; there are no uploads to tune", bm.minioConfig.Endpoint)
+		}
+		block = benchData(16 << 20)
+		run = func(ctx context.Context, partSize int64, concurrency int) (time.Duration, error) {
+			return bm.benchMinio(ctx, block, opts.Size, partSize, concurrency)
+		}
+	case BenchTargetGlacier:
+		if err := bm.initAWSClient(); err != nil {
+			return nil, err
+		}
+		var largest int64
+		for _, r := range runs {
+			largest = max(largest, min(r.PartSize, opts.Size))
+		}
+		// Every part is a prefix of the same block.
+		block = benchData(largest)
+		run = func(ctx context.Context, partSize int64, _ int) (time.Duration, error) {
+			return bm.benchGlacier(ctx, block, opts.Size, partSize)
+		}
+	}
+
+	rep := &BenchReport{Target: opts.Target, Size: opts.Size}
+	for i, res := range runs {
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(runs), res.PartSize, res.Concurrency)
+		}
+		d, err := run(context.Background(), res.PartSize, res.Concurrency)
+		res.Seconds, res.MBps = d.Seconds(), mbps(opts.Size, d)
+		if err != nil {
+			res.Error = err.Error()
+			res.MBps = 0
+		}
+		rep.Results = append(rep.Results, res)
+	}
+	rep.Recommended = RecommendBench(rep.Results)
+	return rep, nil
+}
+
+// benchMinio uploads size bytes as one object and deletes it again.
+func (bm *BackupManager) benchMinio(ctx context.Context, block []byte, size, partSize int64, concurrency int) (time.Duration, error) {
+	host, _ := os.Hostname()
+	key := fmt.Sprintf("%sbench/%s-%d-%d-%d", internalObjectPrefix, host, time.Now().UnixNano(), partSize, concurrency)
+	opts := minio.PutObjectOptions{
+		ContentType:           "application/octet-stream",
+		PartSize:              uint64(partSize),
+		NumThreads:            uint(concurrency),
+		ConcurrentStreamParts: concurrency > 1,
+	}
+	start := time.Now()
+	_, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, key, &benchReader{block: block, left: size}, size, opts)
+	elapsed := time.Since(start)
+	if rerr := bm.removeObject(ctx, key); rerr != nil && err == nil {
+		fmt.Printf("  ⚠️  Failed to delete bench object %s: %v\n", key, rerr)
+	}
+	return elapsed, err
+}
+
+// benchGlacier uploads size bytes as the parts of a multipart upload, one
+// at a time like a migration, and aborts the upload.
+func (bm *BackupManager) benchGlacier(ctx context.Context, block []byte, size, partSize int64) (time.Duration, error) {
+	accountID := bm.glacierAccountID()
+	out, err := bm.awsClient.InitiateMultipartUpload(ctx, &glacier.InitiateMultipartUploadInput{
+		AccountId:          aws.String(accountID),
+		VaultName:          aws.String(bm.awsConfig.Vault),
+		ArchiveDescription: aws.String("ciwg-cli bench (never completed)"),
+		PartSize:           aws.String(strconv.FormatInt(partSize, 10)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	uploadID := aws.ToString(out.UploadId)
+	defer func() {
+		if _, err := bm.awsClient.AbortMultipartUpload(context.Background(), &glacier.AbortMultipartUploadInput{
+			AccountId: aws.String(accountID),
+			VaultName: aws.String(bm.awsConfig.Vault),
+			UploadId:  aws.String(uploadID),
+		}); err != nil {
+			fmt.Printf("  ⚠️  Failed to abort bench upload %s: %v\n", uploadID, err)
+		}
+	}()
+
+	// Only the last part can be shorter; hash both lengths once, outside
+	// the timed section.
+	type partHash struct{ tree, linear string }
+	hashes := map[int64]partHash{}
+	for _, length := range []int64{min(partSize, size), size % partSize} {
+		if _, ok := hashes[length]; ok || length == 0 {
+			continue
+		}
+		linear := sha256.Sum256(block[:length])
+		hashes[length] = partHash{tree: computeTreeHash(block[:length]), linear: hex.EncodeToString(linear[:])}
+	}
+
+	start := time.Now()
+	for offset := int64(0); offset < size; offset += partSize {
+		length := min(partSize, size-offset)
+		h := hashes[length]
+		if _, err := bm.awsClient.UploadMultipartPart(v4.SetPayloadHash(ctx, h.linear), &glacier.UploadMultipartPartInput{
+			AccountId: aws.String(accountID),
+			VaultName: aws.String(bm.awsConfig.Vault),
+			UploadId:  aws.String(uploadID),
+			Range:     aws.String(glacierPartRange(offset, length)),
+			Checksum:  aws.String(h.tree),
+			Body:      bytes.NewReader(block[:length]),
+		}, withPayloadHash(h.linear, length)); err != nil {
+			return time.Since(start), fmt.Errorf("part at offset %d: %w", offset, err)
+		}
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecommendBench(t *testing.T) {
+	results := []BenchResult{
+		{PartSize: 16 << 20, Concurrency: 1, MBps: 40},
+		{PartSize: 16 << 20, Concurrency: 4, MBps: 97},
+		{PartSize: 64 << 20, Concurrency: 4, MBps: 100},
+		{PartSize: 128 << 20, Concurrency: 8, MBps: 0, Error: "timeout"},
+	}
+	got := RecommendBench(results)
+	if got == nil || got.PartSize != 16<<20 || got.Concurrency != 4 {
+		t.Fatalf("RecommendBench() = %+v, want the cheaper run within 5%% of the fastest", got)
+	}
+
+	results[1].MBps = 90
+	if got := RecommendBench(results); got.PartSize != 64<<20 {
+		t.Fatalf("RecommendBench() = %+v, want the fastest run", got)
+	}
+	if got := RecommendBench([]BenchResult{{Error: "boom"}}); got != nil {
+		t.Fatalf("RecommendBench(all failed) = %+v, want nil", got)
+	}
+}
+
+func TestBenchReader(t *testing.T) {
+	block := benchData(1000)
+	if !bytes.Equal(block, benchData(1000)) {
+		t.Fatal("benchData() is not deterministic")
+	}
+	data, err := io.ReadAll(&benchReader{block: block, left: 2500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2500 || !bytes.Equal(data[1000:2000], block) || !bytes.Equal(data[2000:], block[:500]) {
+		t.Fatalf("benchReader returned %d bytes that don't repeat the block", len(data))
+	}
+}
+
+func TestBenchUploadsRejects(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if _, err := bm.BenchUploads(BenchOptions{Target: BenchTargetMinio, Size: 1 << 20}); err == nil || !strings.Contains(err.Error(), "filesystem") {
+		t.Errorf("BenchUploads(file://) error = %v", err)
+	}
+	if _, err := bm.BenchUploads(BenchOptions{Target: "s3", Size: 1 << 20}); err == nil {
+		t.Error("BenchUploads() accepted an unknown target")
+	}
+	if _, err := bm.BenchUploads(BenchOptions{Target: BenchTargetMinio, Size: 1 << 20, Concurrency: []int{0}}); err == nil {
+		t.Error("BenchUploads() accepted zero concurrency")
+	}
+}
+
+func TestBenchRuns(t *testing.T) {
+	runs, err := BenchRuns(BenchOptions{Target: BenchTargetGlacier, Size: 64 << 20, PartSizes: []int64{3 << 20, 4 << 20, 8 << 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3MB and 4MB both round to 4MB.
+	if len(runs) != 2 || runs[0].PartSize != 4<<20 || runs[1].PartSize != 8<<20 || runs[0].Concurrency != 1 {
+		t.Errorf("BenchRuns(glacier) = %+v", runs)
+	}
+	runs, err = BenchRuns(BenchOptions{Target: BenchTargetMinio, Size: 1 << 30, PartSizes: []int64{16 << 20}, Concurrency: []int{2, 4}})
+	if err != nil || len(runs) != 2 || runs[1].Concurrency != 4 || runs[1].Bytes != 1<<30 {
+		t.Errorf("BenchRuns(minio) = %+v, %v", runs, err)
+	}
+}
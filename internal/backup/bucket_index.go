@@ -0,0 +1,392 @@
+package backup
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/notification"
+)
+
+// Sources of bucket notifications for 'backup index --listen'.
+const (
+	// IndexSourceMinio subscribes with Minio's listen API; it needs no
+	// notification target configured on the server.
+	IndexSourceMinio = "minio"
+	// IndexSourceWebhook serves HTTP for a Minio webhook notification
+	// target (notify_webhook) to post events to.
+	IndexSourceWebhook = "webhook"
+)
+
+// Defaults of IndexListenOptions.
+const (
+	DefaultIndexPublishInterval = 30 * time.Second
+	DefaultIndexResyncInterval  = 24 * time.Hour
+	DefaultIndexListenAddr      = ":9431"
+)
+
+// indexEvents are the notifications the index follows.
+var indexEvents = []string{"s3:ObjectCreated:*", "s3:ObjectRemoved:*"}
+
+// BucketEvent is an object created in or removed from the bucket.
+type BucketEvent struct {
+	// Name is the S3 event name, e.g. s3:ObjectCreated:Put.
+	Name string    `json:"name"`
+	Key  string    `json:"key"`
+	Size int64     `json:"size,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Removed reports whether the event removed its object.
+func (e BucketEvent) Removed() bool {
+	return strings.HasPrefix(e.Name, "s3:ObjectRemoved:")
+}
+
+// bucketEvents converts the records of a notification about bucket into
+// BucketEvents, leaving out records of other buckets and other events.
+func bucketEvents(records []notification.Event, bucket string) []BucketEvent {
+	var events []BucketEvent
+	for _, r := range records {
+		if bucket != "" && r.S3.Bucket.Name != bucket {
+			continue
+		}
+		name := r.EventName
+		if !strings.HasPrefix(name, "s3:") {
+			name = "s3:" + name
+		}
+		if !strings.HasPrefix(name, "s3:ObjectCreated:") && !strings.HasPrefix(name, "s3:ObjectRemoved:") {
+			continue
+		}
+		// Keys arrive URL-encoded, as in S3 notifications.
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			key = r.S3.Object.Key
+		}
+		t, err := time.Parse(time.RFC3339Nano, r.EventTime)
+		if err != nil {
+			t = time.Now()
+		}
+		events = append(events, BucketEvent{Name: name, Key: key, Size: r.S3.Object.Size, Time: t.UTC()})
+	}
+	return events
+}
+
+// ParseWebhookEvents reads the body of a Minio webhook notification and
+// returns its events about bucket.
+func ParseWebhookEvents(body io.Reader, bucket string) ([]BucketEvent, error) {
+	var payload struct {
+		Records []notification.Event `json:"Records"`
+	}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid notification: %w", err)
+	}
+	return bucketEvents(payload.Records, bucket), nil
+}
+
+// BackupIndex is a catalog of the backups under a prefix kept up to date
+// from bucket notifications instead of listing the bucket again.
+type BackupIndex struct {
+	prefix string
+
+	mu      sync.Mutex
+	objects map[string]ObjectInfo
+	dirty   bool
+	events  int64
+}
+
+// NewBackupIndex returns an index of prefix holding objs.
+func NewBackupIndex(prefix string, objs []ObjectInfo) *BackupIndex {
+	ix := &BackupIndex{prefix: prefix}
+	ix.Reset(objs)
+	return ix
+}
+
+// Reset replaces the catalog with objs, a fresh listing.
+func (ix *BackupIndex) Reset(objs []ObjectInfo) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.objects = make(map[string]ObjectInfo, len(objs))
+	for _, o := range objs {
+		if !isInternalObject(o.Key) {
+			ix.objects[o.Key] = o
+		}
+	}
+	ix.dirty = true
+}
+
+// Apply updates the catalog with events and returns how many changed it.
+// Events outside the prefix and about internal objects are ignored.
+func (ix *BackupIndex) Apply(events []BucketEvent) int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	changed := 0
+	for _, e := range events {
+		if !strings.HasPrefix(e.Key, ix.prefix) || isInternalObject(e.Key) {
+			continue
+		}
+		ix.events++
+		if e.Removed() {
+			if _, ok := ix.objects[e.Key]; ok {
+				delete(ix.objects, e.Key)
+				changed++
+			}
+			continue
+		}
+		ix.objects[e.Key] = ObjectInfo{Key: e.Key, Size: e.Size, LastModified: e.Time}
+		changed++
+	}
+	if changed > 0 {
+		ix.dirty = true
+	}
+	return changed
+}
+
+// Objects returns the catalog sorted by key.
+func (ix *BackupIndex) Objects() []ObjectInfo {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	objs := make([]ObjectInfo, 0, len(ix.objects))
+	for _, o := range ix.objects {
+		objs = append(objs, o)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs
+}
+
+// takeDirty reports whether the catalog changed since the last call.
+func (ix *BackupIndex) takeDirty() bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	dirty := ix.dirty
+	ix.dirty = false
+	return dirty
+}
+
+// BuildBackupIndex lists prefix into a new index.
+func (bm *BackupManager) BuildBackupIndex(prefix string) (*BackupIndex, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return NewBackupIndex(prefix, objs), nil
+}
+
+// PublishBackupIndex stores the catalog of ix as the listing cache of its
+// prefix, which commands run with --list-cache-ttl read instead of listing
+// the bucket, updates the bucket summary with it, and writes the freshness
+// gauges and index gauges to metrics.
+func (bm *BackupManager) PublishBackupIndex(ix *BackupIndex, metrics FreshnessMetricsConfig) error {
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	objs := ix.Objects()
+	if err := bm.saveListingCache(context.Background(), ix.prefix, objs); err != nil {
+		return fmt.Errorf("failed to store the index: %w", err)
+	}
+	if err := bm.storeSummaryOf(context.Background(), ix.prefix, objs); err != nil {
+		fmt.Printf("⚠️  Failed to update the bucket summary: %v\n", err)
+	}
+	if !metrics.Enabled() {
+		return nil
+	}
+	now := time.Now()
+	text := formatFreshnessMetrics(siteFreshness(objs), bm.minioConfig.Bucket, now) + formatIndexMetrics(ix, objs, bm.minioConfig.Bucket, now)
+	return bm.writeFreshnessMetrics(metrics, text)
+}
+
+// formatIndexMetrics renders gauges about the index itself.
+func formatIndexMetrics(ix *BackupIndex, objs []ObjectInfo, bucket string, now time.Time) string {
+	var size int64
+	for _, o := range objs {
+		size += o.Size
+	}
+	ix.mu.Lock()
+	events := ix.events
+	ix.mu.Unlock()
+	var b strings.Builder
+	gauge := func(name, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s{bucket=%q,prefix=%q} %v\n", name, help, name, name, bucket, ix.prefix, value)
+	}
+	gauge("ciwg_backup_index_objects", "Objects in the backup index.", len(objs))
+	gauge("ciwg_backup_index_bytes", "Bytes of the objects in the backup index.", size)
+	gauge("ciwg_backup_index_events", "Bucket notifications applied to the backup index since it started.", events)
+	gauge("ciwg_backup_index_published_timestamp_seconds", "Unix time the backup index was last published.", now.Unix())
+	return b.String()
+}
+
+// ListenBucketEvents subscribes to object created and removed notifications
+// under prefix with Minio's listen API and passes them to handle until ctx
+// is done. Notification errors are reported and listening goes on.
+func (bm *BackupManager) ListenBucketEvents(ctx context.Context, prefix string, handle func([]BucketEvent)) error {
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	if bm.minioClient == nil {
+		return fmt.Errorf("bucket notifications need a Minio endpoint, not %s", bm.minioConfig.Endpoint)
+	}
+	for info := range bm.minioClient.ListenBucketNotification(ctx, bm.minioConfig.Bucket, prefix, "", indexEvents) {
+		if info.Err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Printf("⚠️  Bucket notifications: %v\n", info.Err)
+			continue
+		}
+		if events := bucketEvents(info.Records, bm.minioConfig.Bucket); len(events) > 0 {
+			handle(events)
+		}
+	}
+	return ctx.Err()
+}
+
+// BucketEventHandler serves a Minio webhook notification target: it passes
+// the events about bucket in every POST to handle. When token is set,
+// requests must carry it in the Authorization header, as Minio sends its
+// auth_token (with or without "Bearer ").
+func BucketEventHandler(bucket, token string, handle func([]BucketEvent)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			// Minio probes the endpoint before enabling the target.
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		events, err := ParseWebhookEvents(http.MaxBytesReader(w, r.Body, 8<<20), bucket)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(events) > 0 {
+			handle(events)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// IndexListenOptions configures ServeBackupIndex.
+type IndexListenOptions struct {
+	Prefix string
+	// Source is IndexSourceMinio or IndexSourceWebhook.
+	Source string
+	// ListenAddr and WebhookToken configure the webhook server.
+	ListenAddr   string
+	WebhookToken string
+	// PublishInterval is how often a changed index is published.
+	PublishInterval time.Duration
+	// ResyncInterval lists the bucket again this often to catch events
+	// missed while the listener was down; zero never does.
+	ResyncInterval time.Duration
+	Metrics        FreshnessMetricsConfig
+}
+
+// ServeBackupIndex lists the prefix once, then keeps its index up to date
+// from bucket notifications, publishing it (see PublishBackupIndex) at most
+// every PublishInterval, until ctx is done. The index is published once
+// more on the way out.
+func (bm *BackupManager) ServeBackupIndex(ctx context.Context, opts IndexListenOptions) error {
+	if opts.PublishInterval <= 0 {
+		opts.PublishInterval = DefaultIndexPublishInterval
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	ix, err := bm.BuildBackupIndex(opts.Prefix)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d object(s) under %q\n", len(ix.Objects()), opts.Prefix)
+	if err := bm.PublishBackupIndex(ix, opts.Metrics); err != nil {
+		return err
+	}
+	ix.takeDirty()
+
+	handle := func(events []BucketEvent) {
+		if n := ix.Apply(events); n > 0 {
+			bm.logVerbose("Applied %d bucket event(s)", n)
+		}
+	}
+	listenErr := make(chan error, 1)
+	switch opts.Source {
+	case IndexSourceMinio, "":
+		go func() { listenErr <- bm.ListenBucketEvents(ctx, opts.Prefix, handle) }()
+		fmt.Printf("Listening for bucket notifications on %s/%s\n", bm.minioConfig.Endpoint, bm.minioConfig.Bucket)
+	case IndexSourceWebhook:
+		addr := opts.ListenAddr
+		if addr == "" {
+			addr = DefaultIndexListenAddr
+		}
+		srv := &http.Server{Addr: addr, Handler: BucketEventHandler(bm.minioConfig.Bucket, opts.WebhookToken, handle), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			err := srv.ListenAndServe()
+			if errors.Is(err, http.ErrServerClosed) {
+				err = ctx.Err()
+			}
+			listenErr <- err
+		}()
+		go func() {
+			<-ctx.Done()
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdown)
+		}()
+		fmt.Printf("Serving webhook notifications on %s\n", addr)
+	default:
+		return fmt.Errorf("unknown notification source %q (use %s or %s)", opts.Source, IndexSourceMinio, IndexSourceWebhook)
+	}
+
+	publish := time.NewTicker(opts.PublishInterval)
+	defer publish.Stop()
+	var resync <-chan time.Time
+	if opts.ResyncInterval > 0 {
+		t := time.NewTicker(opts.ResyncInterval)
+		defer t.Stop()
+		resync = t.C
+	}
+	for {
+		select {
+		case err := <-listenErr:
+			if ctx.Err() == nil {
+				return fmt.Errorf("notification listener stopped: %w", err)
+			}
+			if ix.takeDirty() {
+				return bm.PublishBackupIndex(ix, opts.Metrics)
+			}
+			return nil
+		case <-publish.C:
+			if !ix.takeDirty() {
+				continue
+			}
+			if err := bm.PublishBackupIndex(ix, opts.Metrics); err != nil {
+				fmt.Printf("⚠️  Failed to publish the index: %v\n", err)
+				ix.mu.Lock()
+				ix.dirty = true
+				ix.mu.Unlock()
+				continue
+			}
+			bm.logVerbose("Published the index of %q", opts.Prefix)
+		case <-resync:
+			objs, err := bm.ListBackups(opts.Prefix, 0)
+			if err != nil {
+				fmt.Printf("⚠️  Failed to resync the index: %v\n", err)
+				continue
+			}
+			ix.Reset(objs)
+			bm.logVerbose("Resynced the index of %q: %d object(s)", opts.Prefix, len(objs))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const webhookBody = `{"EventName":"s3:ObjectCreated:Put","Key":"backups/backups/c.com/c.com-20261016-020000.tgz","Records":[
+  {"eventName":"s3:ObjectCreated:Put","eventTime":"2026-10-16T02:00:05.123Z",
+   "s3":{"bucket":{"name":"backups"},"object":{"key":"backups%2Fc.com%2Fc.com-20261016-020000.tgz","size":42}}},
+  {"eventName":"s3:ObjectRemoved:Delete","eventTime":"2026-10-16T02:01:00Z",
+   "s3":{"bucket":{"name":"backups"},"object":{"key":"backups%2Fa.com%2Fa+b.tgz"}}},
+  {"eventName":"s3:ObjectAccessed:Get","eventTime":"2026-10-16T02:02:00Z",
+   "s3":{"bucket":{"name":"backups"},"object":{"key":"backups%2Fa.com%2Fa-1.tgz"}}},
+  {"eventName":"s3:ObjectCreated:Put","eventTime":"2026-10-16T02:03:00Z",
+   "s3":{"bucket":{"name":"other"},"object":{"key":"backups%2Fz.com%2Fz-1.tgz","size":1}}}
+]}`
+
+func TestParseWebhookEvents(t *testing.T) {
+	events, err := ParseWebhookEvents(strings.NewReader(webhookBody), "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v", events)
+	}
+	created, removed := events[0], events[1]
+	if created.Key != "backups/c.com/c.com-20261016-020000.tgz" || created.Size != 42 || created.Removed() ||
+		!created.Time.Equal(time.Date(2026, 10, 16, 2, 0, 5, 123e6, time.UTC)) {
+		t.Errorf("created = %+v", created)
+	}
+	if removed.Key != "backups/a.com/a b.tgz" || !removed.Removed() {
+		t.Errorf("removed = %+v", removed)
+	}
+	if _, err := ParseWebhookEvents(strings.NewReader("not json"), "backups"); err == nil {
+		t.Error("invalid body accepted")
+	}
+}
+
+func TestBackupIndexApply(t *testing.T) {
+	ix := NewBackupIndex("backups/", []ObjectInfo{
+		{Key: "backups/a.com/a-1.tgz", Size: 1},
+		{Key: "backups/a.com/a-2.tgz", Size: 2},
+	})
+	ix.takeDirty()
+	at := time.Date(2026, 10, 16, 2, 0, 0, 0, time.UTC)
+	n := ix.Apply([]BucketEvent{
+		{Name: "s3:ObjectCreated:Put", Key: "backups/b.com/b-1.tgz", Size: 3, Time: at},
+		{Name: "s3:ObjectRemoved:Delete", Key: "backups/a.com/a-1.tgz"},
+		{Name: "s3:ObjectRemoved:Delete", Key: "backups/a.com/gone.tgz"},
+		{Name: "s3:ObjectCreated:Put", Key: "other/x.tgz", Size: 9},
+		{Name: "s3:ObjectCreated:Put", Key: ".ciwg/listing-cache/backups%2F.json.gz", Size: 9},
+	})
+	if n != 2 || !ix.takeDirty() || ix.takeDirty() {
+		t.Errorf("Apply() = %d", n)
+	}
+	objs := ix.Objects()
+	if len(objs) != 2 || objs[0].Key != "backups/a.com/a-2.tgz" || objs[1].Key != "backups/b.com/b-1.tgz" || !objs[1].LastModified.Equal(at) {
+		t.Errorf("objects = %+v", objs)
+	}
+}
+
+func TestBucketEventHandler(t *testing.T) {
+	var got []BucketEvent
+	h := BucketEventHandler("backups", "s3cr3t", func(events []BucketEvent) { got = append(got, events...) })
+	post := func(auth string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(webhookBody))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(""); code != http.StatusUnauthorized || len(got) != 0 {
+		t.Errorf("without token: %d, %d events", code, len(got))
+	}
+	if code := post("Bearer s3cr3t"); code != http.StatusNoContent || len(got) != 2 {
+		t.Errorf("with bearer token: %d, %d events", code, len(got))
+	}
+	if code := post("s3cr3t"); code != http.StatusNoContent || len(got) != 4 {
+		t.Errorf("with bare token: %d, %d events", code, len(got))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("probe = %d", rec.Code)
+	}
+}
+
+func TestPublishBackupIndex(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	ctx := context.Background()
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.putObject(ctx, "backups/a.com/a.com-20261015-020000.tgz", strings.NewReader("data"), -1, "application/gzip", nil); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := bm.BuildBackupIndex("backups/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix.Apply([]BucketEvent{{Name: "s3:ObjectCreated:Put", Key: "backups/b.com/b.com-20261016-020000.tgz", Size: 7, Time: time.Now()}})
+
+	metrics := filepath.Join(t.TempDir(), "ciwg.prom")
+	if err := bm.PublishBackupIndex(ix, FreshnessMetricsConfig{File: metrics}); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := bm.loadListingCache(ctx, "backups/", time.Hour)
+	if !ok || len(cached) != 2 || cached[1].Key != "backups/b.com/b.com-20261016-020000.tgz" {
+		t.Errorf("listing cache = %+v, %v", cached, ok)
+	}
+	data, err := os.ReadFile(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	if !strings.Contains(text, `site="b.com"} 7`) || !strings.Contains(text, `ciwg_backup_index_objects{bucket="",prefix="backups/"} 2`) ||
+		!strings.Contains(text, `ciwg_backup_index_events{bucket="",prefix="backups/"} 1`) {
+		t.Errorf("metrics = %s", text)
+	}
+}
@@ -0,0 +1,335 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bucketSummaryKey holds the bucket summary. Like all state objects it is
+// never returned by ListBackups.
+const bucketSummaryKey = stateObjectPrefix + "bucket-summary.json"
+
+// DefaultBucketSummaryMaxAge is how old the bucket summary may get before a
+// reader rebuilds it from a full listing.
+const DefaultBucketSummaryMaxAge = 24 * time.Hour
+
+// PrefixSummary aggregates the objects directly under one directory prefix
+// of the bucket (backups/<site>/). Newest and Oldest are backup times, by
+// the timestamp in the object names.
+type PrefixSummary struct {
+	Prefix  string    `json:"prefix"`
+	Site    string    `json:"site"`
+	Objects int       `json:"objects"`
+	Bytes   int64     `json:"bytes"`
+	Newest  time.Time `json:"newest"`
+	Oldest  time.Time `json:"oldest"`
+}
+
+// add counts o in s.
+func (s *PrefixSummary) add(o ObjectInfo) {
+	t := BackupTime(o)
+	if s.Objects == 0 || t.After(s.Newest) {
+		s.Newest = t
+	}
+	if s.Objects == 0 || t.Before(s.Oldest) {
+		s.Oldest = t
+	}
+	s.Objects++
+	s.Bytes += o.Size
+}
+
+// merge counts the objects of o in s.
+func (s *PrefixSummary) merge(o PrefixSummary) {
+	if o.Objects == 0 {
+		return
+	}
+	if s.Objects == 0 || o.Newest.After(s.Newest) {
+		s.Newest = o.Newest
+	}
+	if s.Objects == 0 || o.Oldest.Before(s.Oldest) {
+		s.Oldest = o.Oldest
+	}
+	s.Objects += o.Objects
+	s.Bytes += o.Bytes
+}
+
+// BucketSummary is the body of the bucket summary object: per-prefix
+// counts, sizes and backup times that grouped listings and status checks
+// read instead of listing every object. It is rebuilt from a full listing
+// (GeneratedAt) and updated in between, prefix by prefix, by the commands
+// that write to or delete from the bucket (UpdatedAt).
+type BucketSummary struct {
+	GeneratedAt time.Time                `json:"generated_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	Prefixes    map[string]PrefixSummary `json:"prefixes"`
+}
+
+// summaryPrefix returns the directory prefix key is summarized under.
+func summaryPrefix(key string) string {
+	dir := path.Dir(key)
+	if dir == "." {
+		return ""
+	}
+	return dir + "/"
+}
+
+// summarizeObjects builds a summary of objs, leaving out internal objects.
+func summarizeObjects(objs []ObjectInfo, now time.Time) *BucketSummary {
+	s := &BucketSummary{GeneratedAt: now.UTC(), UpdatedAt: now.UTC(), Prefixes: map[string]PrefixSummary{}}
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		p := summaryPrefix(o.Key)
+		ps, ok := s.Prefixes[p]
+		if !ok {
+			ps = PrefixSummary{Prefix: p, Site: ObjectSite(o.Key)}
+		}
+		ps.add(o)
+		s.Prefixes[p] = ps
+	}
+	return s
+}
+
+// Covers reports whether s can answer for prefix: the summary only knows
+// whole directories, so prefix must be empty or end in a slash.
+func (s *BucketSummary) Covers(prefix string) bool {
+	return prefix == "" || strings.HasSuffix(prefix, "/")
+}
+
+// BySite returns the totals of every site under prefix, sorted by site.
+func (s *BucketSummary) BySite(prefix string) []PrefixSummary {
+	bySite := map[string]*PrefixSummary{}
+	for _, ps := range s.Prefixes {
+		if !strings.HasPrefix(ps.Prefix, prefix) {
+			continue
+		}
+		site, ok := bySite[ps.Site]
+		if !ok {
+			site = &PrefixSummary{Prefix: prefix, Site: ps.Site}
+			bySite[ps.Site] = site
+		}
+		site.merge(ps)
+	}
+	sites := make([]PrefixSummary, 0, len(bySite))
+	for _, site := range bySite {
+		sites = append(sites, *site)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Site < sites[j].Site })
+	return sites
+}
+
+// LatestBackupTimes is LatestBackupTimes answered from the summary.
+func (s *BucketSummary) LatestBackupTimes(prefix string) map[string]time.Time {
+	latest := map[string]time.Time{}
+	for _, site := range s.BySite(prefix) {
+		latest[site.Site] = site.Newest
+	}
+	return latest
+}
+
+// SummarizeBackups lists prefix and summarizes it, for when the summary
+// cannot be used (--no-cache).
+func (bm *BackupManager) SummarizeBackups(prefix string) (*BucketSummary, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return summarizeObjects(objs, time.Now()), nil
+}
+
+// loadBucketSummary reads the summary object; it returns ErrObjectNotFound
+// (wrapped) when there is none yet.
+func (bm *BackupManager) loadBucketSummary(ctx context.Context) (*BucketSummary, error) {
+	r, err := bm.getObject(ctx, bucketSummaryKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var s BucketSummary
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid bucket summary: %w", err)
+	}
+	if s.Prefixes == nil {
+		s.Prefixes = map[string]PrefixSummary{}
+	}
+	return &s, nil
+}
+
+// saveBucketSummary stores s as the summary object.
+func (bm *BackupManager) saveBucketSummary(ctx context.Context, s *BucketSummary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bucket summary: %w", err)
+	}
+	if _, err := bm.putObject(ctx, bucketSummaryKey, bytes.NewReader(data), int64(len(data)), "application/json", nil); err != nil {
+		return fmt.Errorf("failed to store bucket summary: %w", err)
+	}
+	return nil
+}
+
+// storeSummaryOf replaces what the stored summary knows about prefix with a
+// summary of objs, a fresh listing of prefix. A listing of the whole bucket
+// replaces the summary; one of a narrower prefix is only merged into an
+// existing summary.
+func (bm *BackupManager) storeSummaryOf(ctx context.Context, prefix string, objs []ObjectInfo) error {
+	fresh := summarizeObjects(objs, time.Now())
+	if prefix == "" {
+		return bm.saveBucketSummary(ctx, fresh)
+	}
+	s, err := bm.loadBucketSummary(ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for p := range s.Prefixes {
+		if strings.HasPrefix(p, prefix) {
+			delete(s.Prefixes, p)
+		}
+	}
+	for p, ps := range fresh.Prefixes {
+		s.Prefixes[p] = ps
+	}
+	s.UpdatedAt = fresh.UpdatedAt
+	return bm.saveBucketSummary(ctx, s)
+}
+
+// BucketSummary returns the stored summary, rebuilding it first when there
+// is none or it was generated more than maxAge ago (never when maxAge is
+// 0). A summary that was rebuilt but could not be stored is still returned.
+func (bm *BackupManager) BucketSummary(maxAge time.Duration) (*BucketSummary, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	s, err := bm.loadBucketSummary(ctx)
+	switch {
+	case err == nil && (maxAge <= 0 || time.Since(s.GeneratedAt) <= maxAge):
+		bm.logVerbose("Using bucket summary from %s ago (%d prefixes)", time.Since(s.GeneratedAt).Round(time.Second), len(s.Prefixes))
+		return s, nil
+	case err == nil:
+		fmt.Fprintf(os.Stderr, "Bucket summary is %s old; rebuilding it from a full listing...\n", time.Since(s.GeneratedAt).Round(time.Minute))
+	case errors.Is(err, ErrObjectNotFound):
+		fmt.Fprintln(os.Stderr, "No bucket summary yet; building it from a full listing...")
+	default:
+		fmt.Fprintf(os.Stderr, "⚠️  %v; rebuilding it from a full listing...\n", err)
+	}
+	s, err = bm.SummarizeBackups("")
+	if err != nil {
+		return nil, err
+	}
+	if err := bm.saveBucketSummary(ctx, s); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: %v\n", err)
+	}
+	return s, nil
+}
+
+// pendingSummaries are the managers with prefixes to flush, so
+// FlushBucketSummaries reaches every manager a command built.
+var pendingSummaries struct {
+	sync.Mutex
+	managers map[*BackupManager]bool
+}
+
+// touchSummary records that an object under the prefix of key was written
+// or deleted, for FlushBucketSummary.
+func (bm *BackupManager) touchSummary(key string) {
+	if isInternalObject(key) {
+		return
+	}
+	bm.summaryMu.Lock()
+	if bm.summaryDirty == nil {
+		bm.summaryDirty = map[string]bool{}
+	}
+	bm.summaryDirty[summaryPrefix(key)] = true
+	bm.summaryMu.Unlock()
+
+	pendingSummaries.Lock()
+	defer pendingSummaries.Unlock()
+	if pendingSummaries.managers == nil {
+		pendingSummaries.managers = map[*BackupManager]bool{}
+	}
+	pendingSummaries.managers[bm] = true
+}
+
+// FlushBucketSummaries runs FlushBucketSummary on every manager that wrote
+// to or deleted from its bucket since the last call.
+func FlushBucketSummaries() error {
+	pendingSummaries.Lock()
+	managers := pendingSummaries.managers
+	pendingSummaries.managers = nil
+	pendingSummaries.Unlock()
+	var errs []error
+	for bm := range managers {
+		errs = append(errs, bm.FlushBucketSummary())
+	}
+	return errors.Join(errs...)
+}
+
+// FlushBucketSummary brings the prefixes this manager wrote to or deleted
+// from up to date in the stored summary by listing just those prefixes. It
+// does nothing when nothing changed or there is no summary yet (the next
+// reader builds it). Concurrent flushes from other hosts may overwrite each
+// other's prefixes until the next full rebuild.
+func (bm *BackupManager) FlushBucketSummary() error {
+	bm.summaryMu.Lock()
+	dirty := bm.summaryDirty
+	bm.summaryDirty = nil
+	bm.summaryMu.Unlock()
+	if len(dirty) == 0 || bm.dryRun {
+		return nil
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	s, err := bm.loadBucketSummary(ctx)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	prefixes := make([]string, 0, len(dirty))
+	for p := range dirty {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		objs, err := bm.listObjects(ctx, p, 0)
+		if err != nil {
+			return fmt.Errorf("failed to list %q for the bucket summary: %w", p, err)
+		}
+		ps := PrefixSummary{Prefix: p}
+		for _, o := range objs {
+			// Deeper directories are prefixes of their own.
+			if summaryPrefix(o.Key) != p || isInternalObject(o.Key) {
+				continue
+			}
+			ps.Site = ObjectSite(o.Key)
+			ps.add(o)
+		}
+		if ps.Objects == 0 {
+			delete(s.Prefixes, p)
+		} else {
+			s.Prefixes[p] = ps
+		}
+	}
+	s.UpdatedAt = time.Now().UTC()
+	if err := bm.saveBucketSummary(ctx, s); err != nil {
+		return err
+	}
+	bm.logVerbose("Updated %d prefix(es) of the bucket summary", len(prefixes))
+	return nil
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSummarizeObjectsBySite(t *testing.T) {
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a.com-20240101-010000.tgz", Size: 10},
+		{Key: "backups/a.com/a.com-20240301-010000.tgz", Size: 30},
+		{Key: "archive/a.com/a.com-20230101-010000.tgz", Size: 5},
+		{Key: "backups/b.com/b.com-20240201-010000.tgz", Size: 7},
+		{Key: stateObjectPrefix + "maintenance/a.com.json", Size: 99},
+	}
+	s := summarizeObjects(objs, time.Now())
+	if len(s.Prefixes) != 3 {
+		t.Fatalf("prefixes = %v, want 3", s.Prefixes)
+	}
+
+	sites := s.BySite("backups/")
+	if len(sites) != 2 || sites[0].Site != "a.com" || sites[1].Site != "b.com" {
+		t.Fatalf("BySite(backups/) = %+v", sites)
+	}
+	a := sites[0]
+	if a.Objects != 2 || a.Bytes != 40 {
+		t.Errorf("a.com = %d objects, %d bytes, want 2, 40", a.Objects, a.Bytes)
+	}
+	if got := InTimezone(a.Newest).Format("20060102"); got != "20240301" {
+		t.Errorf("a.com newest = %s", got)
+	}
+	if got := InTimezone(a.Oldest).Format("20060102"); got != "20240101" {
+		t.Errorf("a.com oldest = %s", got)
+	}
+
+	all := s.BySite("")
+	if all[0].Objects != 3 || all[0].Bytes != 45 {
+		t.Errorf("a.com across prefixes = %d objects, %d bytes, want 3, 45", all[0].Objects, all[0].Bytes)
+	}
+	if got := InTimezone(s.LatestBackupTimes("backups/")["b.com"]).Format("20060102"); got != "20240201" {
+		t.Errorf("LatestBackupTimes()[b.com] = %s", got)
+	}
+
+	if !s.Covers("backups/") || !s.Covers("") || s.Covers("backups/a.com/a.com-2024") {
+		t.Error("Covers() should accept whole directories only")
+	}
+}
+
+func TestBucketSummaryIncremental(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/a.com/a.com-20240101-010000.tgz", "aaaa")
+	putTestObject(t, bm, "backups/b.com/b.com-20240101-010000.tgz", "bb")
+	// Nothing to update before a summary exists.
+	if err := bm.FlushBucketSummary(); err != nil {
+		t.Fatalf("FlushBucketSummary() error = %v", err)
+	}
+
+	s, err := bm.BucketSummary(time.Hour)
+	if err != nil {
+		t.Fatalf("BucketSummary() error = %v", err)
+	}
+	if len(s.BySite("backups/")) != 2 {
+		t.Fatalf("initial summary = %+v", s.Prefixes)
+	}
+
+	putTestObject(t, bm, "backups/a.com/a.com-20240201-010000.tgz", "aaaaaa")
+	if err := bm.DeleteObjects([]string{"backups/b.com/b.com-20240101-010000.tgz"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.FlushBucketSummary(); err != nil {
+		t.Fatalf("FlushBucketSummary() error = %v", err)
+	}
+
+	s, err = bm.BucketSummary(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := s.BySite("backups/")
+	if len(sites) != 1 || sites[0].Site != "a.com" || sites[0].Objects != 2 || sites[0].Bytes != 10 {
+		t.Fatalf("summary after create and delete = %+v", sites)
+	}
+	if got := InTimezone(sites[0].Newest).Format("20060102"); got != "20240201" {
+		t.Errorf("newest = %s", got)
+	}
+
+	// The summary object itself never shows up in listings.
+	for _, k := range listKeys(t, bm) {
+		if k == bucketSummaryKey {
+			t.Errorf("listing returned %s", k)
+		}
+	}
+}
+
+func TestBucketSummaryRebuildsWhenStale(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/a.com/a.com-20240101-010000.tgz", "a")
+	stale := &BucketSummary{GeneratedAt: time.Now().Add(-48 * time.Hour), Prefixes: map[string]PrefixSummary{}}
+	if err := bm.saveBucketSummary(context.Background(), stale); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := bm.BucketSummary(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Prefixes) != 0 {
+		t.Errorf("max age 0 should keep the stored summary, got %+v", s.Prefixes)
+	}
+	s, err = bm.BucketSummary(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.BySite("")) != 1 || time.Since(s.GeneratedAt) > time.Minute {
+		t.Errorf("stale summary not rebuilt: %+v", s)
+	}
+}
+
+func TestFlushBucketSummaries(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/a.com/a.com-20240101-010000.tgz", "a")
+	if _, err := bm.BucketSummary(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// A manager built elsewhere in the command is flushed too.
+	other := NewBackupManager(nil, bm.minioConfig)
+	if err := other.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, other, "backups/b.com/b.com-20240101-010000.tgz", "bb")
+	if err := FlushBucketSummaries(); err != nil {
+		t.Fatalf("FlushBucketSummaries() error = %v", err)
+	}
+	s, err := bm.BucketSummary(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites := s.BySite("backups/"); len(sites) != 2 {
+		t.Errorf("summary after flush = %+v, want a.com and b.com", sites)
+	}
+}
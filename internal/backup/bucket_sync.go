@@ -0,0 +1,408 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// Per-object outcomes reported by SyncBuckets.
+const (
+	BucketSyncCopied    = "copied"
+	BucketSyncSkipped   = "skipped"
+	BucketSyncResumed   = "resumed"
+	BucketSyncWouldCopy = "would-copy"
+	BucketSyncFailed    = "failed"
+)
+
+// Copy methods reported by SyncBuckets.
+const (
+	BucketSyncServerSide = "server-side"
+	BucketSyncStreamed   = "streamed"
+)
+
+// BucketSyncOptions controls SyncBuckets.
+type BucketSyncOptions struct {
+	Prefix string
+	DryRun bool
+	// Verify reads every copied object back from the destination and compares
+	// its checksum (see CryptoPolicy) with the source.
+	Verify bool
+	// StateFile is a JSON-lines journal of finished objects. Objects recorded
+	// as copied with the same size and ETag are not copied again, so an
+	// interrupted sync can be rerun with the same file.
+	StateFile string
+}
+
+// BucketSyncObject is the outcome for one object.
+type BucketSyncObject struct {
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+	ETag     string `json:"etag,omitempty"`
+	Status   string `json:"status"`
+	Method   string `json:"method,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	// Algorithm is the checksum algorithm, one of ChecksumAlgorithms.
+	Algorithm string  `json:"algorithm,omitempty"`
+	Verified  bool    `json:"verified,omitempty"`
+	Seconds   float64 `json:"seconds,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// BucketSyncReport summarizes a SyncBuckets run.
+type BucketSyncReport struct {
+	Objects []BucketSyncObject `json:"objects"`
+	Copied  int                `json:"copied"`
+	Skipped int                `json:"skipped"`
+	Resumed int                `json:"resumed"`
+	Failed  int                `json:"failed"`
+	Bytes   int64              `json:"bytes"`
+}
+
+func (r *BucketSyncReport) add(o BucketSyncObject) {
+	r.Objects = append(r.Objects, o)
+	switch o.Status {
+	case BucketSyncCopied, BucketSyncWouldCopy:
+		r.Copied++
+		r.Bytes += o.Size
+	case BucketSyncSkipped:
+		r.Skipped++
+	case BucketSyncResumed:
+		r.Resumed++
+	case BucketSyncFailed:
+		r.Failed++
+	}
+}
+
+// DefaultBucketSyncStatePath returns the journal used for a sync between two
+// named profiles (~/.ciwg/sync/<src>-to-<dst>.jsonl).
+func DefaultBucketSyncStatePath(srcProfile, dstProfile string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "sync", srcProfile+"-to-"+dstProfile+".jsonl")
+}
+
+// objectAttrs is the metadata carried over to the destination.
+type objectAttrs struct {
+	ContentType string
+	UserMeta    map[string]string
+	Tags        map[string]string
+}
+
+// SyncBuckets copies every object under opts.Prefix from bm to dst, keeping
+// the same keys. Objects already on dst with the same size are skipped. When
+// both sides are the same Minio server and credentials the copy happens
+// server-side; otherwise the object is streamed through this host. Content
+// type, user metadata and tags are preserved where the backends support them.
+func (bm *BackupManager) SyncBuckets(dst *BackupManager, opts BucketSyncOptions) (*BucketSyncReport, error) {
+	src, err := bm.ListBackups(opts.Prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source objects: %w", err)
+	}
+	existing, err := dst.ListBackups(opts.Prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination objects: %w", err)
+	}
+	have := make(map[string]int64, len(existing))
+	for _, obj := range existing {
+		have[obj.Key] = obj.Size
+	}
+
+	done, err := loadBucketSyncState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	var journal *os.File
+	if opts.StateFile != "" && !opts.DryRun {
+		if err := os.MkdirAll(filepath.Dir(opts.StateFile), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create state directory: %w", err)
+		}
+		journal, err = os.OpenFile(opts.StateFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state file: %w", err)
+		}
+		defer journal.Close()
+	}
+
+	serverSide := bm.sameMinioServer(dst)
+	report := &BucketSyncReport{}
+	ctx := context.Background()
+	for _, obj := range src {
+		if isInternalObject(obj.Key) {
+			continue
+		}
+		res := BucketSyncObject{Key: obj.Key, Size: obj.Size, ETag: obj.ETag}
+
+		if prev, ok := done[obj.Key]; ok && prev.Size == obj.Size && prev.ETag == obj.ETag {
+			if size, ok := have[obj.Key]; ok && size == obj.Size {
+				res.Status = BucketSyncResumed
+				res.Method = prev.Method
+				res.Checksum, res.Algorithm = prev.Checksum, prev.Algorithm
+				res.Verified = prev.Verified
+				report.add(res)
+				continue
+			}
+		}
+		if size, ok := have[obj.Key]; ok && size == obj.Size {
+			res.Status = BucketSyncSkipped
+			report.add(res)
+			continue
+		}
+		if opts.DryRun {
+			res.Status = BucketSyncWouldCopy
+			report.add(res)
+			continue
+		}
+
+		start := time.Now()
+		if serverSide {
+			res.Method = BucketSyncServerSide
+			err = bm.copyObjectServerSide(ctx, dst, obj.Key, obj.Key)
+		} else {
+			res.Method = BucketSyncStreamed
+			res.Algorithm, res.Checksum, err = bm.streamObjectTo(ctx, dst, obj, obj.Key)
+		}
+		if err == nil && opts.Verify {
+			err = bm.verifyCopy(ctx, dst, obj.Key, &res)
+		}
+		res.Seconds = time.Since(start).Seconds()
+		if err != nil {
+			res.Status = BucketSyncFailed
+			res.Error = err.Error()
+		} else {
+			res.Status = BucketSyncCopied
+		}
+		report.add(res)
+
+		if journal != nil && res.Status == BucketSyncCopied {
+			line, _ := json.Marshal(res)
+			if _, err := journal.Write(append(line, '\n')); err != nil {
+				return report, fmt.Errorf("failed to update state file: %w", err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// sameMinioServer reports whether bm and dst reach the same Minio server with
+// the same credentials, so CopyObject can be used between their buckets.
+func (bm *BackupManager) sameMinioServer(dst *BackupManager) bool {
+	if bm.minioClient == nil || dst.minioClient == nil {
+		return false
+	}
+	a, b := bm.minioConfig, dst.minioConfig
+	return a.Endpoint == b.Endpoint && a.UseSSL == b.UseSSL && a.AccessKey == b.AccessKey &&
+		a.SecretKey == b.SecretKey && a.SSHTunnel == nil && b.SSHTunnel == nil
+}
+
+// copyObjectServerSide copies key into dst's bucket as dstKey without
+// downloading it.
+// ComposeObject is used so objects over the 5 GiB CopyObject limit work too.
+// It does not carry the content type or tags over by itself, so the source
+// attributes are set on the destination explicitly.
+func (bm *BackupManager) copyObjectServerSide(ctx context.Context, dst *BackupManager, key, dstKey string) error {
+	attrs, err := bm.objectAttrs(ctx, key)
+	if err != nil {
+		return err
+	}
+	meta := map[string]string{"Content-Type": attrs.ContentType}
+	for k, v := range attrs.UserMeta {
+		meta[k] = v
+	}
+	if err := dst.guardWrite("copy to", dstKey); err != nil {
+		return err
+	}
+	if err := dst.checkObjectResidency(ctx, "copy", dstKey, ResidencyMinio); err != nil {
+		return err
+	}
+	dst.listingDirty.Store(true)
+	dst.touchSummary(dstKey)
+	_, err = bm.minioClient.ComposeObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          dst.minioConfig.Bucket,
+			Object:          dstKey,
+			ReplaceMetadata: true,
+			UserMetadata:    meta,
+			ReplaceTags:     true,
+			UserTags:        attrs.Tags,
+		},
+		minio.CopySrcOptions{Bucket: bm.minioConfig.Bucket, Object: key},
+	)
+	if err != nil {
+		return fmt.Errorf("server-side copy failed: %w", err)
+	}
+	return nil
+}
+
+// streamObjectTo downloads obj from bm and uploads it to dst as dstKey,
+// returning the checksum algorithm and the checksum of the bytes read from
+// the source.
+func (bm *BackupManager) streamObjectTo(ctx context.Context, dst *BackupManager, obj ObjectInfo, dstKey string) (alg, sum string, err error) {
+	attrs, err := bm.objectAttrs(ctx, obj.Key)
+	if err != nil {
+		return "", "", err
+	}
+	r, err := bm.getObject(ctx, obj.Key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open source object: %w", err)
+	}
+	defer r.Close()
+
+	alg = currentCryptoPolicy().Checksum
+	h := newChecksum(alg)
+	tee := io.TeeReader(r, h)
+	var n int64
+	if err := dst.guardWrite("copy to", dstKey); err != nil {
+		return "", "", err
+	}
+	if err := dst.checkObjectResidency(ctx, "copy", dstKey, ResidencyMinio); err != nil {
+		return "", "", err
+	}
+	dst.listingDirty.Store(true)
+	dst.touchSummary(dstKey)
+	if dst.fileStore != nil {
+		n, err = dst.fileStore.put(dstKey, tee)
+	} else {
+		var info minio.UploadInfo
+		info, err = dst.minioClient.PutObject(ctx, dst.minioConfig.Bucket, dstKey, tee, obj.Size, minio.PutObjectOptions{
+			ContentType:  attrs.ContentType,
+			UserMetadata: attrs.UserMeta,
+			UserTags:     attrs.Tags,
+		})
+		n = info.Size
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload object: %w", err)
+	}
+	if n != obj.Size {
+		return "", "", fmt.Errorf("size mismatch: source has %d bytes, copied %d", obj.Size, n)
+	}
+	return alg, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// objectAttrs returns the content type, user metadata and tags of key. The
+// filesystem backend keeps none of these.
+func (bm *BackupManager) objectAttrs(ctx context.Context, key string) (objectAttrs, error) {
+	attrs := objectAttrs{ContentType: "application/octet-stream"}
+	if bm.fileStore != nil {
+		return attrs, nil
+	}
+	info, err := bm.minioClient.StatObject(ctx, bm.minioConfig.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return attrs, fmt.Errorf("failed to stat source object: %w", err)
+	}
+	if info.ContentType != "" {
+		attrs.ContentType = info.ContentType
+	}
+	attrs.UserMeta = info.UserMetadata
+
+	t, err := bm.minioClient.GetObjectTagging(ctx, bm.minioConfig.Bucket, key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return attrs, fmt.Errorf("failed to read source object tags: %w", err)
+	}
+	attrs.Tags = tagMap(t)
+	return attrs, nil
+}
+
+func tagMap(t *tags.Tags) map[string]string {
+	if t == nil {
+		return nil
+	}
+	m := t.ToMap()
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// verifyCopy reads key back from dst and compares its checksum with the
+// source. A mismatching copy is removed so the next run copies it again
+// instead of skipping it by size.
+func (bm *BackupManager) verifyCopy(ctx context.Context, dst *BackupManager, key string, res *BucketSyncObject) error {
+	if res.Checksum == "" || res.Algorithm != currentCryptoPolicy().Checksum {
+		alg, sum, err := hashObject(ctx, bm, key)
+		if err != nil {
+			return fmt.Errorf("failed to hash source object: %w", err)
+		}
+		res.Algorithm, res.Checksum = alg, sum
+	}
+	_, sum, err := hashObject(ctx, dst, key)
+	if err != nil {
+		return fmt.Errorf("failed to read back destination object: %w", err)
+	}
+	if sum != res.Checksum {
+		if rmErr := dst.removeObject(ctx, key); rmErr != nil {
+			bm.logVerbose("Failed to remove mismatched copy of %s: %v", key, rmErr)
+		}
+		return fmt.Errorf("%s checksum mismatch: source %s, destination %s", checksumLabel(res.Algorithm), res.Checksum, sum)
+	}
+	res.Verified = true
+	return nil
+}
+
+// hashObject returns the checksum algorithm and the checksum of key.
+func hashObject(ctx context.Context, bm *BackupManager, key string) (alg, sum string, err error) {
+	r, err := bm.getObject(ctx, key)
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close()
+	alg, sum, _, err = checksumReader(r)
+	if err != nil {
+		return "", "", err
+	}
+	return alg, sum, nil
+}
+
+// loadBucketSyncState reads the journal written by SyncBuckets. A missing file
+// is an empty journal; a truncated last line from an interrupted run is
+// ignored.
+func loadBucketSyncState(path string) (map[string]BucketSyncObject, error) {
+	done := make(map[string]BucketSyncObject)
+	if path == "" {
+		return done, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var o struct {
+			BucketSyncObject
+			// SHA256 is the checksum of journals written before the
+			// algorithm could be chosen.
+			SHA256 string `json:"sha256"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &o) != nil || o.Key == "" {
+			continue
+		}
+		if o.Checksum == "" && o.SHA256 != "" {
+			o.Checksum, o.Algorithm = o.SHA256, ChecksumSHA256
+		}
+		done[o.Key] = o.BucketSyncObject
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	return done, nil
+}
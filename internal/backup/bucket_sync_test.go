@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMinioProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	data := `profiles:
+  old:
+    endpoint: minio-old.internal:9000
+    access_key_env: TEST_OLD_ACCESS
+    secret_key: secret
+    ssl: false
+    http_timeout: 30s
+  nas:
+    endpoint: file:///mnt/nas
+  broken:
+    endpoint: minio.internal:9000
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_OLD_ACCESS", "access")
+
+	cfg, err := LoadMinioProfile(path, "old")
+	if err != nil {
+		t.Fatalf("LoadMinioProfile(old) error = %v", err)
+	}
+	if cfg.AccessKey != "access" || cfg.SecretKey != "secret" || cfg.UseSSL || cfg.Bucket != "backups" || cfg.HTTPTimeout.String() != "30s" {
+		t.Errorf("LoadMinioProfile(old) = %+v", cfg)
+	}
+
+	cfg, err = LoadMinioProfile(path, "nas")
+	if err != nil || cfg.Endpoint != "file:///mnt/nas" {
+		t.Errorf("LoadMinioProfile(nas) = %+v, %v", cfg, err)
+	}
+	if _, err := LoadMinioProfile(path, "broken"); err == nil || !strings.Contains(err.Error(), "credentials") {
+		t.Errorf("expected missing credentials error, got %v", err)
+	}
+	if _, err := LoadMinioProfile(path, "missing"); err == nil || !strings.Contains(err.Error(), "[broken nas old]") {
+		t.Errorf("expected not found error listing profiles, got %v", err)
+	}
+}
+
+func TestSyncBucketsVerifiesAndResumes(t *testing.T) {
+	src, _ := newFileBackedManager(t)
+	dst, dstDir := newFileBackedManager(t)
+	if err := src.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"backups/a.com/a-1.tgz", "backups/b.com/b-1.tgz", "other/c.tgz"} {
+		if _, err := src.putObject(ctx, key, strings.NewReader("data:"+key), -1, "application/gzip", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := filepath.Join(t.TempDir(), "sync", "state.jsonl")
+	opts := BucketSyncOptions{Prefix: "backups/", Verify: true, StateFile: state}
+
+	report, err := src.SyncBuckets(dst, opts)
+	if err != nil {
+		t.Fatalf("SyncBuckets() error = %v", err)
+	}
+	if report.Copied != 2 || report.Failed != 0 || len(report.Objects) != 2 {
+		t.Fatalf("first run report = %+v", report)
+	}
+	for _, o := range report.Objects {
+		if !o.Verified || o.Method != BucketSyncStreamed || o.Checksum == "" || o.Algorithm != ChecksumSHA256 {
+			t.Errorf("object %+v not verified", o)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "other", "c.tgz")); !os.IsNotExist(err) {
+		t.Errorf("object outside prefix was copied")
+	}
+
+	// Objects recorded in the journal are not copied or read again.
+	if err := os.WriteFile(filepath.Join(dstDir, "backups", "a.com", "a-1.tgz"), []byte("data:backups/a.com/a-X.tgz"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = src.SyncBuckets(dst, opts)
+	if err != nil {
+		t.Fatalf("SyncBuckets() second run error = %v", err)
+	}
+	if report.Resumed != 2 || report.Copied != 0 {
+		t.Errorf("second run report = %+v, want both resumed from journal", report)
+	}
+
+	// Without a journal, an object missing at the destination is copied again.
+	if err := os.Remove(filepath.Join(dstDir, "backups", "b.com", "b-1.tgz")); err != nil {
+		t.Fatal(err)
+	}
+	report, err = src.SyncBuckets(dst, BucketSyncOptions{Prefix: "backups/", DryRun: true})
+	if err != nil {
+		t.Fatalf("SyncBuckets() dry run error = %v", err)
+	}
+	if report.Copied != 1 || report.Skipped != 1 || report.Objects[1].Status != BucketSyncWouldCopy {
+		t.Errorf("dry run report = %+v", report)
+	}
+}
+
+func TestVerifyCopyRemovesMismatch(t *testing.T) {
+	src, _ := newFileBackedManager(t)
+	dst, dstDir := newFileBackedManager(t)
+	for _, bm := range []*BackupManager{src, dst} {
+		if err := bm.initMinioClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	src.putObject(ctx, "k.tgz", strings.NewReader("good"), -1, "", nil)
+	dst.putObject(ctx, "k.tgz", strings.NewReader("bad!"), -1, "", nil)
+
+	res := BucketSyncObject{Key: "k.tgz"}
+	if err := src.verifyCopy(ctx, dst, "k.tgz", &res); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("verifyCopy() error = %v, want checksum mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "k.tgz")); !os.IsNotExist(err) {
+		t.Errorf("mismatched copy should be removed")
+	}
+}
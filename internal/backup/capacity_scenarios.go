@@ -0,0 +1,78 @@
+package backup
+
+// DefaultAggressiveMigrateAfterDays is the cadence of the aggressive scenario
+// in capacity estimates: only the last two daily backups stay hot.
+const DefaultAggressiveMigrateAfterDays = 2
+
+// CapacityScenario is the storage a fleet needs under one Glacier migration
+// cadence.
+type CapacityScenario struct {
+	Name string `json:"name"`
+	// MigrateAfterDays is the age at which daily backups move to Glacier;
+	// 0 keeps all of them hot.
+	MigrateAfterDays  int     `json:"migrate_after_days"`
+	HotBackupsPerSite int     `json:"hot_backups_per_site"`
+	FleetHotStorage   int64   `json:"fleet_hot_storage"`
+	FleetColdStorage  int64   `json:"fleet_cold_storage"`
+	MonthlyCost       float64 `json:"monthly_cost,omitempty"`
+}
+
+// hotBackupsFor returns how many backups per site stay in Minio when daily
+// backups migrate after migrateAfterDays (0 = never).
+func (o *CapacityEstimateOptions) hotBackupsFor(migrateAfterDays int) int {
+	if migrateAfterDays > 0 && migrateAfterDays < o.DailyRetention {
+		return migrateAfterDays
+	}
+	return o.DailyRetention
+}
+
+// HotBackups returns how many backups per site stay in Minio under
+// MigrateAfterDays.
+func (o *CapacityEstimateOptions) HotBackups() int {
+	return o.hotBackupsFor(o.MigrateAfterDays)
+}
+
+// ColdBackups returns how many backups per site live in Glacier: the weekly
+// and monthly ones plus the daily ones migrated under MigrateAfterDays.
+func (o *CapacityEstimateOptions) ColdBackups() int {
+	return o.WeeklyRetention + o.MonthlyRetention + o.DailyRetention - o.HotBackups()
+}
+
+// CapacityScenarios compares the hot and cold storage of siteCount sites of
+// avgCompressedSize under no migration, the MigrateAfterDays cadence and the
+// aggressive AggressiveMigrateAfterDays cadence. Scenarios that would keep
+// the same number of backups hot as an earlier one are left out.
+func CapacityScenarios(avgCompressedSize int64, siteCount int, options *CapacityEstimateOptions) []CapacityScenario {
+	aggressive := options.AggressiveMigrateAfterDays
+	if aggressive <= 0 {
+		aggressive = DefaultAggressiveMigrateAfterDays
+	}
+	candidates := []CapacityScenario{
+		{Name: "no-migration"},
+		{Name: "current", MigrateAfterDays: options.MigrateAfterDays},
+		{Name: "aggressive", MigrateAfterDays: aggressive},
+	}
+
+	var scenarios []CapacityScenario
+	seen := make(map[int]bool)
+	for _, sc := range candidates {
+		hot := options.hotBackupsFor(sc.MigrateAfterDays)
+		if seen[hot] {
+			continue
+		}
+		seen[hot] = true
+		if hot == options.DailyRetention {
+			sc.MigrateAfterDays = 0
+		}
+		cold := options.WeeklyRetention + options.MonthlyRetention + options.DailyRetention - hot
+
+		sc.HotBackupsPerSite = hot
+		sc.FleetHotStorage = options.Erasure.OnDisk(avgCompressedSize*int64(hot)) * int64(siteCount)
+		sc.FleetColdStorage = avgCompressedSize * int64(cold) * int64(siteCount)
+		if options.GlacierPricePerGB > 0 {
+			sc.MonthlyCost = float64(sc.FleetColdStorage) / (1024 * 1024 * 1024) * options.GlacierPricePerGB
+		}
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios
+}
@@ -0,0 +1,53 @@
+package backup
+
+import "testing"
+
+func TestEstimateCapacityMigrateAfterDays(t *testing.T) {
+	opts := &CapacityEstimateOptions{DailyRetention: 14, WeeklyRetention: 4, MonthlyRetention: 2, MigrateAfterDays: 5}
+	est, err := NewBackupManager(nil, nil).EstimateCapacityFromManual(100, 3, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.HotBackupsPerSite != 5 || est.PerSiteHotStorage != 500 || est.FleetHotStorage != 1500 {
+		t.Errorf("hot = %d backups, %d/%d bytes, want 5, 500/1500", est.HotBackupsPerSite, est.PerSiteHotStorage, est.FleetHotStorage)
+	}
+	// 9 migrated dailies join the 4 weekly and 2 monthly backups.
+	if est.PerSiteColdStorage != 1500 || est.PerSiteTotalStorage != 2000 {
+		t.Errorf("cold = %d, total = %d, want 1500, 2000", est.PerSiteColdStorage, est.PerSiteTotalStorage)
+	}
+}
+
+func TestCapacityScenarios(t *testing.T) {
+	opts := &CapacityEstimateOptions{
+		DailyRetention:    14,
+		WeeklyRetention:   4,
+		MigrateAfterDays:  5,
+		GlacierPricePerGB: 1,
+		Erasure:           &ErasureCoding{Data: 4, Parity: 4},
+	}
+	got := CapacityScenarios(1<<30, 2, opts)
+	want := []CapacityScenario{
+		{Name: "no-migration", HotBackupsPerSite: 14, FleetHotStorage: 56 << 30, FleetColdStorage: 8 << 30, MonthlyCost: 8},
+		{Name: "current", MigrateAfterDays: 5, HotBackupsPerSite: 5, FleetHotStorage: 20 << 30, FleetColdStorage: 26 << 30, MonthlyCost: 26},
+		{Name: "aggressive", MigrateAfterDays: 2, HotBackupsPerSite: 2, FleetHotStorage: 8 << 30, FleetColdStorage: 32 << 30, MonthlyCost: 32},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("CapacityScenarios() = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("scenario %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Without a cadence the current scenario is the no-migration one, and a
+	// cadence beyond the daily retention migrates nothing.
+	opts.MigrateAfterDays = 0
+	if got := CapacityScenarios(1, 1, opts); len(got) != 2 || got[1].Name != "aggressive" {
+		t.Errorf("no cadence: %+v", got)
+	}
+	opts.MigrateAfterDays = 30
+	if got := CapacityScenarios(1, 1, opts); len(got) != 2 || got[0].HotBackupsPerSite != 14 {
+		t.Errorf("cadence beyond retention: %+v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package backup
+
+import (
+	"crypto/fips140"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/zeebo/blake3"
+)
+
+// Checksum algorithms for the checksums this tool computes itself: bucket
+// sync verification, export bundle checksum files and post-upload checks.
+// Glacier tree hashes are SHA-256 whatever the policy, as the AWS protocol
+// requires.
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+	ChecksumBLAKE3 = "blake3"
+)
+
+// ChecksumAlgorithms lists the supported algorithms, default first.
+var ChecksumAlgorithms = []string{ChecksumSHA256, ChecksumSHA512, ChecksumBLAKE3}
+
+// fipsChecksums are the algorithms approved under FIPS 140-3.
+var fipsChecksums = map[string]bool{ChecksumSHA256: true, ChecksumSHA512: true}
+
+// CryptoPolicy selects the checksum algorithm and whether cryptography is
+// restricted to FIPS-approved algorithms. It applies to the whole process,
+// like the FIPS mode of the Go runtime itself.
+type CryptoPolicy struct {
+	// Checksum is one of ChecksumAlgorithms; empty means ChecksumSHA256.
+	Checksum string
+	// FIPS rejects algorithms that are not FIPS-approved and restricts TLS
+	// connections to FIPS-approved versions, cipher suites and curves.
+	FIPS bool
+}
+
+var cryptoPolicy atomic.Pointer[CryptoPolicy]
+
+// ParseChecksumAlgorithm normalizes name ("SHA-256", "sha256", ...) to one
+// of ChecksumAlgorithms; empty yields ChecksumSHA256.
+func ParseChecksumAlgorithm(name string) (string, error) {
+	alg := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "")
+	if alg == "" {
+		return ChecksumSHA256, nil
+	}
+	for _, a := range ChecksumAlgorithms {
+		if alg == a {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown checksum algorithm %q (use %s)", name, strings.Join(ChecksumAlgorithms, ", "))
+}
+
+// SetCryptoPolicy validates p and makes it the policy of the process.
+func SetCryptoPolicy(p CryptoPolicy) error {
+	alg, err := ParseChecksumAlgorithm(p.Checksum)
+	if err != nil {
+		return err
+	}
+	if p.FIPS && !fipsChecksums[alg] {
+		return fmt.Errorf("checksum algorithm %s is not FIPS-approved (use %s or %s)", alg, ChecksumSHA256, ChecksumSHA512)
+	}
+	p.Checksum = alg
+	cryptoPolicy.Store(&p)
+	return nil
+}
+
+// currentCryptoPolicy returns the policy set by SetCryptoPolicy, or the
+// default SHA-256 without FIPS restrictions.
+func currentCryptoPolicy() CryptoPolicy {
+	if p := cryptoPolicy.Load(); p != nil {
+		return *p
+	}
+	return CryptoPolicy{Checksum: ChecksumSHA256}
+}
+
+// FIPSModuleEnabled reports whether the Go FIPS 140-3 module runs in FIPS
+// mode (GODEBUG=fips140=on), which --fips cannot switch on by itself.
+func FIPSModuleEnabled() bool {
+	return fips140.Enabled()
+}
+
+// newChecksum returns a hash of the algorithm alg.
+func newChecksum(alg string) hash.Hash {
+	switch alg {
+	case ChecksumSHA512:
+		return sha512.New()
+	case ChecksumBLAKE3:
+		return blake3.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// checksumReader hashes r to the end with the policy algorithm and returns
+// the algorithm, the hex digest and the number of bytes read.
+func checksumReader(r io.Reader) (alg, sum string, n int64, err error) {
+	alg = currentCryptoPolicy().Checksum
+	h := newChecksum(alg)
+	n, err = io.Copy(h, r)
+	return alg, hex.EncodeToString(h.Sum(nil)), n, err
+}
+
+// checksumLabel is how alg is named in reports, e.g. "SHA-256".
+func checksumLabel(alg string) string {
+	switch alg {
+	case ChecksumSHA512:
+		return "SHA-512"
+	case ChecksumBLAKE3:
+		return "BLAKE3"
+	default:
+		return "SHA-256"
+	}
+}
+
+// fipsCipherSuites are the FIPS-approved TLS 1.2 suites. TLS 1.3 suites are
+// not configurable; the Go FIPS module drops ChaCha20-Poly1305 from them.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// applyFIPSTLS restricts cfg (nil for the Go defaults) to FIPS-approved
+// versions, cipher suites and curves when the policy asks for FIPS.
+func applyFIPSTLS(cfg *tls.Config) *tls.Config {
+	if !currentCryptoPolicy().FIPS {
+		return cfg
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.CipherSuites = fipsCipherSuites
+	cfg.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+	return cfg
+}
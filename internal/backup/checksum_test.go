@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setTestCryptoPolicy(t *testing.T, p CryptoPolicy) {
+	t.Helper()
+	if err := SetCryptoPolicy(p); err != nil {
+		t.Fatalf("SetCryptoPolicy(%+v) error = %v", p, err)
+	}
+	t.Cleanup(func() { cryptoPolicy.Store(nil) })
+}
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	for in, want := range map[string]string{"": ChecksumSHA256, "SHA-256": ChecksumSHA256, "sha512": ChecksumSHA512, "BLAKE3": ChecksumBLAKE3} {
+		if got, err := ParseChecksumAlgorithm(in); err != nil || got != want {
+			t.Errorf("ParseChecksumAlgorithm(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseChecksumAlgorithm("md5"); err == nil {
+		t.Error("ParseChecksumAlgorithm(md5) error = nil")
+	}
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	// Digests of the empty input.
+	for alg, want := range map[string]string{
+		ChecksumSHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		ChecksumSHA512: "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e",
+		ChecksumBLAKE3: "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+	} {
+		if got := hex.EncodeToString(newChecksum(alg).Sum(nil)); got != want {
+			t.Errorf("newChecksum(%s) = %s, want %s", alg, got, want)
+		}
+	}
+
+	setTestCryptoPolicy(t, CryptoPolicy{Checksum: ChecksumBLAKE3})
+	alg, sum, n, err := checksumReader(strings.NewReader(""))
+	if err != nil || alg != ChecksumBLAKE3 || n != 0 || !strings.HasPrefix(sum, "af1349b9") {
+		t.Errorf("checksumReader() = %s, %s, %d, %v", alg, sum, n, err)
+	}
+	if d := newUploadDigest(); !strings.HasPrefix(d.checksum(), "blake3:af1349b9") {
+		t.Errorf("uploadDigest.checksum() = %s", d.checksum())
+	}
+}
+
+func TestFIPSPolicy(t *testing.T) {
+	if err := SetCryptoPolicy(CryptoPolicy{Checksum: ChecksumBLAKE3, FIPS: true}); err == nil {
+		t.Fatal("SetCryptoPolicy() accepted BLAKE3 in FIPS mode")
+	}
+	if cfg, err := buildTLSConfig("test", "", "", "", false); cfg != nil || err != nil {
+		t.Fatalf("buildTLSConfig() without FIPS = %v, %v", cfg, err)
+	}
+
+	setTestCryptoPolicy(t, CryptoPolicy{Checksum: ChecksumSHA512, FIPS: true})
+	cfg, err := buildTLSConfig("test", "", "", "", false)
+	if err != nil || cfg == nil {
+		t.Fatalf("buildTLSConfig() in FIPS mode = %v, %v", cfg, err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || len(cfg.CipherSuites) != len(fipsCipherSuites) || len(cfg.CurvePreferences) != 2 {
+		t.Errorf("buildTLSConfig() in FIPS mode = %+v", cfg)
+	}
+	if _, err := buildTLSConfig("test", "", "", "", true); err == nil {
+		t.Error("buildTLSConfig() allowed insecure-skip-verify in FIPS mode")
+	}
+}
+
+func TestBucketSyncStateLegacyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.jsonl")
+	data := `{"key":"a.tgz","size":1,"status":"copied","sha256":"abc"}` + "\n" +
+		`{"key":"b.tgz","size":1,"status":"copied","checksum":"def","algorithm":"blake3"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	done, err := loadBucketSyncState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := done["a.tgz"]; a.Checksum != "abc" || a.Algorithm != ChecksumSHA256 {
+		t.Errorf("legacy entry = %+v", a)
+	}
+	if b := done["b.tgz"]; b.Checksum != "def" || b.Algorithm != ChecksumBLAKE3 {
+		t.Errorf("entry = %+v", b)
+	}
+}
@@ -0,0 +1,259 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultCommandOutputLimit is how many bytes of each command's stdout and
+// stderr the audit log keeps.
+const DefaultCommandOutputLimit = 2048
+
+// CommandAuditConfig controls the audit trail of the shell commands a
+// BackupManager runs on hosts.
+type CommandAuditConfig struct {
+	// File is the JSON-lines log every command is appended to; empty
+	// records nothing.
+	File string
+	// Trace prints each command as it finishes.
+	Trace bool
+	// OutputLimit truncates the recorded output (DefaultCommandOutputLimit
+	// when 0, nothing kept when negative).
+	OutputLimit int
+}
+
+// CommandTrace is one shell command run on a host.
+type CommandTrace struct {
+	// RunID links the command to the backup run that ran it, if any.
+	RunID string `json:"run_id,omitempty"`
+	// Site is the object key of the site being backed up when the command
+	// ran; empty for commands of the run as a whole.
+	Site            string    `json:"site,omitempty"`
+	Host            string    `json:"host"`
+	Command         string    `json:"command"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// ExitStatus is -1 when the command did not exit normally, e.g. the
+	// session could not be opened.
+	ExitStatus int    `json:"exit_status"`
+	Error      string `json:"error,omitempty"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// DefaultCommandAuditPath returns the default location of the command audit
+// log (~/.ciwg/backup-command-audit.jsonl).
+func DefaultCommandAuditPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-backup-command-audit.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "backup-command-audit.jsonl")
+}
+
+// commandAudit is the audit state of a BackupManager; see SetCommandAudit.
+type commandAudit struct {
+	cfg   CommandAuditConfig
+	trace io.Writer
+	mu    sync.Mutex
+	// recorded counts the commands written to the log; err is the first
+	// failure to write it.
+	recorded int
+	err      error
+	// site is the site commands are currently run for; see auditSite.
+	site string
+}
+
+// SetCommandAudit records every shell command bm runs under cfg. Live traces
+// go to stderr.
+func (bm *BackupManager) SetCommandAudit(cfg CommandAuditConfig) {
+	if cfg.File == "" && !cfg.Trace {
+		bm.audit = nil
+		return
+	}
+	bm.audit = &commandAudit{cfg: cfg, trace: os.Stderr}
+}
+
+// auditSite tags the commands run from now on with site.
+func (bm *BackupManager) auditSite(site string) {
+	if bm.audit == nil {
+		return
+	}
+	bm.audit.mu.Lock()
+	defer bm.audit.mu.Unlock()
+	bm.audit.site = site
+}
+
+// AuditedCommands returns how many commands bm wrote to the audit log.
+func (bm *BackupManager) AuditedCommands() int {
+	if bm.audit == nil {
+		return 0
+	}
+	bm.audit.mu.Lock()
+	defer bm.audit.mu.Unlock()
+	return bm.audit.recorded
+}
+
+// CommandAuditError returns the first failure to write the audit log, so a
+// run can report that its trail is incomplete.
+func (bm *BackupManager) CommandAuditError() error {
+	if bm.audit == nil {
+		return nil
+	}
+	bm.audit.mu.Lock()
+	defer bm.audit.mu.Unlock()
+	return bm.audit.err
+}
+
+// auditCommand records cmd, started at started, with its output and
+// outcome. It is called by every path that runs a shell command.
+func (bm *BackupManager) auditCommand(cmd string, started time.Time, stdout, stderr string, err error) {
+	a := bm.audit
+	if a == nil {
+		return
+	}
+	t := CommandTrace{
+		Host:            bm.remoteHost(),
+		Command:         redactCommand(cmd),
+		StartedAt:       started.UTC(),
+		DurationSeconds: time.Since(started).Seconds(),
+		ExitStatus:      exitStatus(err),
+	}
+	if t.Host == "" {
+		t.Host = "local"
+	}
+	if bm.lastRun != nil {
+		t.RunID = bm.lastRun.ID
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	limit := a.cfg.OutputLimit
+	if limit == 0 {
+		limit = DefaultCommandOutputLimit
+	}
+	var cut1, cut2 bool
+	t.Stdout, cut1 = truncateOutput(stdout, limit)
+	t.Stderr, cut2 = truncateOutput(stderr, limit)
+	t.Truncated = cut1 || cut2
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t.Site = a.site
+	if a.cfg.Trace {
+		fmt.Fprintf(a.trace, "$ [%s] %s  (%.2fs, exit %d)\n", t.Host, t.Command, t.DurationSeconds, t.ExitStatus)
+	}
+	if a.cfg.File != "" {
+		if werr := appendCommandTrace(a.cfg.File, &t); werr == nil {
+			a.recorded++
+		} else if a.err == nil {
+			a.err = werr
+		}
+	}
+}
+
+// appendCommandTrace appends t to the JSON-lines audit log at path, creating
+// the file and its parent directory if needed.
+func appendCommandTrace(path string, t *CommandTrace) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command trace: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write command trace: %w", err)
+	}
+	return nil
+}
+
+// LoadCommandTraces reads the audit log at path, keeping the commands of
+// runID (all of them when empty). A missing file yields no traces and
+// malformed lines are skipped.
+func LoadCommandTraces(path, runID string) ([]CommandTrace, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var traces []CommandTrace
+	for _, line := range strings.Split(string(data), "\n") {
+		var t CommandTrace
+		if line == "" || json.Unmarshal([]byte(line), &t) != nil {
+			continue
+		}
+		if runID == "" || t.RunID == runID {
+			traces = append(traces, t)
+		}
+	}
+	return traces, nil
+}
+
+// exitStatus returns the exit status carried by err: 0 for success, the
+// process's status for local and SSH commands that exited, -1 otherwise.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var local *exec.ExitError
+	if errors.As(err, &local) {
+		return local.ExitCode()
+	}
+	var remote *ssh.ExitError
+	if errors.As(err, &remote) {
+		return remote.ExitStatus()
+	}
+	return -1
+}
+
+// truncateOutput keeps at most limit bytes of s, cutting on a rune boundary.
+func truncateOutput(s string, limit int) (string, bool) {
+	if limit < 0 {
+		return "", s != ""
+	}
+	if len(s) <= limit {
+		return s, false
+	}
+	cut := limit
+	for cut > 0 && cut < len(s) && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut], true
+}
+
+// commandSecrets match the credentials database commands carry on their
+// command line: mysqldump's -pSECRET and --password SECRET.
+var commandSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`(\s-p)\S+`),
+	regexp.MustCompile(`(--password[= ])\S+`),
+}
+
+// redactCommand hides the passwords in cmd before it is recorded.
+func redactCommand(cmd string) string {
+	for _, re := range commandSecrets {
+		cmd = re.ReplaceAllString(cmd, "${1}***")
+	}
+	return cmd
+}
@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactCommand(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"docker exec db mysqldump -u wp -ps3cret wp > /tmp/x.sql", "docker exec db mysqldump -u wp -p*** wp > /tmp/x.sql"},
+		{"mongodump --db x --username u --password hunter2", "mongodump --db x --username u --password ***"},
+		{"mkdir -p /tmp/x", "mkdir -p /tmp/x"},
+	}
+	for _, tt := range tests {
+		if got := redactCommand(tt.in); got != tt.want {
+			t.Errorf("redactCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	if got, cut := truncateOutput("héllo", 2); got != "h" || !cut {
+		t.Errorf("truncateOutput() = %q, %v; want a cut on the rune boundary", got, cut)
+	}
+	if got, cut := truncateOutput("ok", 10); got != "ok" || cut {
+		t.Errorf("truncateOutput() = %q, %v", got, cut)
+	}
+	if got, cut := truncateOutput("ok", -1); got != "" || !cut {
+		t.Errorf("truncateOutput(-1) = %q, %v", got, cut)
+	}
+}
+
+func TestCommandAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	bm := NewBackupManager(nil, nil)
+	bm.SetCommandAudit(CommandAuditConfig{File: path, Trace: true, OutputLimit: 4096})
+	var trace bytes.Buffer
+	bm.audit.trace = &trace
+	bm.lastRun = &RunRecord{ID: "run-1"}
+
+	if _, _, err := bm.executeCommand("echo hello; echo oops >&2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bm.executeCommand("exit 3"); err == nil {
+		t.Fatal("exit 3 should fail")
+	}
+	if _, err := bm.executeCommandWithStdin("cat >/dev/null", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	traces, err := LoadCommandTraces(path, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 3 || bm.AuditedCommands() != 3 {
+		t.Fatalf("traces = %+v, audited = %d", traces, bm.AuditedCommands())
+	}
+	first := traces[0]
+	if first.Host != "local" || first.ExitStatus != 0 || first.Stdout != "hello\n" || !strings.Contains(first.Stderr, "oops") || first.Truncated {
+		t.Errorf("first trace = %+v", first)
+	}
+	if traces[1].ExitStatus != 3 || traces[1].Error == "" {
+		t.Errorf("failed trace = %+v", traces[1])
+	}
+	if other, _ := LoadCommandTraces(path, "run-2"); len(other) != 0 {
+		t.Errorf("run-2 traces = %+v", other)
+	}
+	if got := strings.Count(trace.String(), "$ [local] "); got != 3 || !strings.Contains(trace.String(), "exit 3") {
+		t.Errorf("live trace:\n%s", trace.String())
+	}
+	if err := bm.CommandAuditError(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if got := exitStatus(errors.New("session failed")); got != -1 {
+		t.Errorf("exitStatus() = %d, want -1", got)
+	}
+	_, _, err := NewBackupManager(nil, nil).executeCommand("exit 7")
+	if got := exitStatus(&TarError{Op: "create", Err: err}); got != 7 {
+		t.Errorf("exitStatus(wrapped) = %d, want 7", got)
+	}
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ciwg-cli/internal/auth"
+)
+
+// CommandConfig is the connection configuration of one command: where
+// backups are stored, the optional Glacier vault and the SSH settings used to
+// reach hosts. It is built once, before any work starts, and only read
+// afterwards, so the goroutines of a server range run can share it.
+type CommandConfig struct {
+	// Minio is nil when no endpoint is configured.
+	Minio *MinioConfig
+	// AWS is nil when no vault is configured; Glacier is optional.
+	AWS *AWSConfig
+	// SSH holds the defaults applied to every SSH target. Its Hostname is
+	// empty; use SSHTarget to address a host.
+	SSH auth.SSHConfig
+}
+
+// Validate reports every problem with c at once rather than stopping at the
+// first, so a misconfigured cron job can be fixed in one edit.
+func (c *CommandConfig) Validate() error {
+	var errs []error
+	if m := c.Minio; m != nil && !IsFileEndpoint(m.Endpoint) {
+		if m.AccessKey == "" {
+			errs = append(errs, fmt.Errorf("minio-access-key is required (use --minio-access-key or set MINIO_ACCESS_KEY)"))
+		}
+		if m.SecretKey == "" {
+			errs = append(errs, fmt.Errorf("minio-secret-key is required (use --minio-secret-key or set MINIO_SECRET_KEY)"))
+		}
+		if (m.ClientCertFile == "") != (m.ClientKeyFile == "") {
+			errs = append(errs, fmt.Errorf("minio-client-cert and minio-client-key must be set together"))
+		}
+		if m.HTTPTimeout < 0 {
+			errs = append(errs, fmt.Errorf("minio-http-timeout must not be negative"))
+		}
+		if m.Listing.Parallelism < 0 || m.Listing.RequestsPerSecond < 0 || m.Listing.PageSize < 0 || m.Listing.CacheTTL < 0 {
+			errs = append(errs, fmt.Errorf("listing options must not be negative"))
+		}
+		if m.PartSize != 0 && (m.PartSize < minioMinPartSize || m.PartSize > minioMaxPartSize) {
+			errs = append(errs, fmt.Errorf("minio-part-size must be between 5MB and 5GB"))
+		}
+		if m.UploadConcurrency < 0 {
+			errs = append(errs, fmt.Errorf("minio-upload-concurrency must not be negative"))
+		}
+	}
+	if a := c.AWS; a != nil {
+		if err := a.validateAuth(); err != nil {
+			errs = append(errs, err)
+		}
+		if (a.ClientCertFile == "") != (a.ClientKeyFile == "") {
+			errs = append(errs, fmt.Errorf("aws-client-cert and aws-client-key must be set together"))
+		}
+		if a.HTTPTimeout < 0 {
+			errs = append(errs, fmt.Errorf("aws-http-timeout must not be negative"))
+		}
+		if a.PartSize != 0 && (a.PartSize < glacierMinPartSize || a.PartSize > glacierMaxPartSize) {
+			errs = append(errs, fmt.Errorf("aws-part-size must be between 1MB and 4GB"))
+		}
+	}
+	if c.SSH.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("ssh timeout must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// SSHTarget returns the SSH configuration for target ([user@]host). A user
+// in target overrides the configured one.
+func (c *CommandConfig) SSHTarget(target string) auth.SSHConfig {
+	cfg := c.SSH
+	if user, host, ok := strings.Cut(target, "@"); ok {
+		cfg.Username, cfg.Hostname = user, host
+	} else {
+		cfg.Hostname = target
+	}
+	return cfg
+}
+
+// NewBackupManagerFromConfig creates a BackupManager for the storage and
+// vault of cfg.
+func NewBackupManagerFromConfig(sshClient *auth.SSHClient, cfg *CommandConfig) *BackupManager {
+	return NewBackupManagerWithAWS(sshClient, cfg.Minio, cfg.AWS)
+}
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ciwg-cli/internal/auth"
+)
+
+func TestCommandConfigValidate(t *testing.T) {
+	valid := &CommandConfig{
+		Minio: &MinioConfig{Endpoint: "minio.example.com:9000", AccessKey: "ak", SecretKey: "sk"},
+		AWS:   &AWSConfig{Vault: "vault", PartSize: 64 * 1024 * 1024},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&CommandConfig{Minio: &MinioConfig{Endpoint: "file:///srv/backups"}}).Validate(); err != nil {
+		t.Errorf("Validate() rejected a file endpoint without credentials: %v", err)
+	}
+
+	bad := &CommandConfig{
+		Minio: &MinioConfig{Endpoint: "minio.example.com:9000", ClientCertFile: "client.pem", PartSize: 1024},
+		AWS:   &AWSConfig{Vault: "vault", PartSize: 1024},
+		SSH:   auth.SSHConfig{Timeout: -time.Second},
+	}
+	err := bad.Validate()
+	if err == nil {
+		t.Fatal("Validate() accepted an invalid configuration")
+	}
+	for _, want := range []string{"minio-access-key", "minio-secret-key", "minio-client-key", "minio-part-size", "aws-part-size", "ssh timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error %q does not report %s", err, want)
+		}
+	}
+}
+
+func TestCommandConfigSSHTarget(t *testing.T) {
+	cfg := &CommandConfig{SSH: auth.SSHConfig{Username: "deploy", Port: "2222"}}
+
+	got := cfg.SSHTarget("wp1.example.com")
+	if got.Hostname != "wp1.example.com" || got.Username != "deploy" || got.Port != "2222" {
+		t.Errorf("SSHTarget(host) = %+v", got)
+	}
+	got = cfg.SSHTarget("root@wp2.example.com")
+	if got.Hostname != "wp2.example.com" || got.Username != "root" {
+		t.Errorf("SSHTarget(user@host) = %+v", got)
+	}
+	if cfg.SSH.Hostname != "" || cfg.SSH.Username != "deploy" {
+		t.Errorf("SSHTarget() modified the shared defaults: %+v", cfg.SSH)
+	}
+}
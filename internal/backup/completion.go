@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// completionCacheTTL is how long shell completion reuses a listing. Every
+// TAB runs a new process, so without it each keystroke would LIST the
+// bucket again.
+var completionCacheTTL = 30 * time.Second
+
+// completionObjectLimit caps how many objects of one directory are offered,
+// newest first.
+const completionObjectLimit = 50
+
+// completionCache is the body of a completion cache file.
+type completionCache struct {
+	Dir         string    `json:"dir"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Keys        []string  `json:"keys"`
+}
+
+// DefaultCompletionCacheDir returns where shell completion caches listings
+// (~/.ciwg/completion-cache).
+func DefaultCompletionCacheDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-completion-cache")
+	}
+	return filepath.Join(home, ".ciwg", "completion-cache")
+}
+
+// CompleteKeys returns the object keys and directories that extend partial,
+// for shell completion: the site directories directly under partial's
+// directory (ending in "/") followed by its most recent backups, newest
+// first. Listings are cached in cacheDir for a short while; an empty cacheDir
+// disables the cache.
+func (bm *BackupManager) CompleteKeys(partial, cacheDir string) ([]string, error) {
+	dir := ""
+	if i := strings.LastIndex(partial, "/"); i >= 0 {
+		dir = partial[:i+1]
+	}
+	keys, ok := bm.loadCompletionCache(cacheDir, dir)
+	if !ok {
+		if err := bm.initMinioClient(); err != nil {
+			return nil, err
+		}
+		level, err := bm.listLevel(context.Background(), dir)
+		if err != nil {
+			return nil, err
+		}
+		keys = completionKeys(level)
+		bm.saveCompletionCache(cacheDir, dir, keys)
+	}
+
+	var out []string
+	for _, k := range keys {
+		if strings.HasPrefix(k, partial) {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+// listLevel lists what is directly under dir: objects, and subdirectories as
+// keys ending in "/".
+func (bm *BackupManager) listLevel(ctx context.Context, dir string) ([]ObjectInfo, error) {
+	if bm.fileStore == nil {
+		progress := bm.startListProgress(dir)
+		defer progress.done()
+		return bm.listPrefix(ctx, dir, false, 0, bm.listPacer(), progress)
+	}
+	objs, err := bm.fileStore.list(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []ObjectInfo
+	seen := map[string]bool{}
+	for _, o := range objs {
+		rest := strings.TrimPrefix(o.Key, dir)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			sub := dir + rest[:i+1]
+			if !seen[sub] {
+				seen[sub] = true
+				out = append(out, ObjectInfo{Key: sub})
+			}
+			continue
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// completionKeys orders one directory level for completion: subdirectories
+// by name, then the newest completionObjectLimit objects.
+func completionKeys(level []ObjectInfo) []string {
+	var dirs []string
+	var objs []ObjectInfo
+	for _, o := range level {
+		if strings.HasSuffix(o.Key, "/") {
+			dirs = append(dirs, o.Key)
+		} else {
+			objs = append(objs, o)
+		}
+	}
+	sort.Strings(dirs)
+	sort.SliceStable(objs, func(i, j int) bool { return BackupTime(objs[i]).After(BackupTime(objs[j])) })
+	if len(objs) > completionObjectLimit {
+		objs = objs[:completionObjectLimit]
+	}
+	keys := dirs
+	for _, o := range objs {
+		keys = append(keys, o.Key)
+	}
+	return keys
+}
+
+// completionCacheFile returns the cache file for dir of this manager's
+// bucket.
+func (bm *BackupManager) completionCacheFile(cacheDir, dir string) string {
+	sum := sha256.Sum256([]byte(bm.minioConfig.Endpoint + "\x00" + bm.minioConfig.Bucket + "\x00" + path.Clean("/"+dir)))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (bm *BackupManager) loadCompletionCache(cacheDir, dir string) ([]string, bool) {
+	if cacheDir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(bm.completionCacheFile(cacheDir, dir))
+	if err != nil {
+		return nil, false
+	}
+	var cache completionCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, false
+	}
+	if cache.Dir != dir || time.Since(cache.GeneratedAt) > completionCacheTTL {
+		return nil, false
+	}
+	return cache.Keys, true
+}
+
+// saveCompletionCache stores keys as the cached level of dir. Failures are
+// ignored: completion still works, only slower.
+func (bm *BackupManager) saveCompletionCache(cacheDir, dir string, keys []string) {
+	if cacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(completionCache{Dir: dir, GeneratedAt: time.Now().UTC(), Keys: keys})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(bm.completionCacheFile(cacheDir, dir), data, 0o644)
+}
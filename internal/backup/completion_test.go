@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompleteKeys(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{
+		"backups/a.com/a.com-20240101-020000.tgz",
+		"backups/a.com/a.com-20240301-020000.tgz",
+		"backups/a.com/a.com-20240201-020000.tgz",
+		"backups/b.com/b.com-20240101-020000.tgz",
+		"backups/readme.txt",
+	} {
+		putTestObject(t, bm, key, "x")
+	}
+	cacheDir := t.TempDir()
+
+	tests := []struct {
+		partial string
+		want    []string
+	}{
+		{"", []string{"backups/"}},
+		{"backups/", []string{"backups/a.com/", "backups/b.com/", "backups/readme.txt"}},
+		{"backups/a", []string{"backups/a.com/"}},
+		{"backups/a.com/", []string{
+			"backups/a.com/a.com-20240301-020000.tgz",
+			"backups/a.com/a.com-20240201-020000.tgz",
+			"backups/a.com/a.com-20240101-020000.tgz",
+		}},
+		{"backups/a.com/a.com-202402", []string{"backups/a.com/a.com-20240201-020000.tgz"}},
+	}
+	for _, tt := range tests {
+		got, err := bm.CompleteKeys(tt.partial, cacheDir)
+		if err != nil {
+			t.Fatalf("CompleteKeys(%q) error = %v", tt.partial, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CompleteKeys(%q) = %v, want %v", tt.partial, got, tt.want)
+		}
+	}
+
+	// A new object is not seen while the cached listing is fresh.
+	putTestObject(t, bm, "backups/c.com/c.com-20240101-020000.tgz", "x")
+	got, err := bm.CompleteKeys("backups/c", cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("CompleteKeys with fresh cache = %v, want none", got)
+	}
+	got, err = bm.CompleteKeys("backups/c", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"backups/c.com/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteKeys without cache = %v, want %v", got, want)
+	}
+}
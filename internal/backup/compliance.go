@@ -0,0 +1,253 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseObjective parses an RPO or RTO target: a Go duration such as 90m or
+// 6h, or a whole number of days (7d) or weeks (2w).
+func ParseObjective(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{{"d", 24 * time.Hour}, {"w", 7 * 24 * time.Hour}}
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid objective %q", s)
+			}
+			return time.Duration(v) * u.unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid objective %q (use e.g. 6h, 1d or 1w)", s)
+	}
+	return d, nil
+}
+
+// validateObjectives checks every RPO and RTO in the fleet file.
+func (f *Fleet) validateObjectives() error {
+	var errs []error
+	check := func(what, name, field, v string) {
+		if v == "" {
+			return
+		}
+		if _, err := ParseObjective(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %s: %w", what, name, field, err))
+		}
+	}
+	for name, h := range f.Hosts {
+		check("host", name, "rpo", h.RPO)
+		check("host", name, "rto", h.RTO)
+	}
+	for name, s := range f.Sites {
+		check("site", name, "rpo", s.RPO)
+		check("site", name, "rto", s.RTO)
+	}
+	return errors.Join(errs...)
+}
+
+// SiteObjectives returns the RPO and RTO of site: its own, or those of the
+// host named in its entry. Zero means no target.
+func (f *Fleet) SiteObjectives(site string) (rpo, rto time.Duration) {
+	entry := f.Sites[site]
+	host := f.Hosts[entry.Host]
+	pick := func(own, inherited string) time.Duration {
+		if own == "" {
+			own = inherited
+		}
+		if own == "" {
+			return 0
+		}
+		d, _ := ParseObjective(own)
+		return d
+	}
+	return pick(entry.RPO, host.RPO), pick(entry.RTO, host.RTO)
+}
+
+// Compliance statuses of one objective.
+const (
+	ComplianceOK       = "ok"
+	ComplianceViolated = "violated"
+	// ComplianceMissing means there is nothing to measure: no backup for the
+	// RPO, no recorded restore for the RTO.
+	ComplianceMissing = "missing"
+)
+
+// SiteCompliance is how one site measures up against its objectives.
+// Statuses are empty when the site has no such target.
+type SiteCompliance struct {
+	Site string `json:"site"`
+	Host string `json:"host,omitempty"`
+
+	RPO          string    `json:"rpo,omitempty"`
+	LastBackup   time.Time `json:"last_backup,omitempty"`
+	BackupAgeSec float64   `json:"backup_age_seconds,omitempty"`
+	RPOStatus    string    `json:"rpo_status,omitempty"`
+
+	RTO            string    `json:"rto,omitempty"`
+	LastRestore    time.Time `json:"last_restore,omitempty"`
+	RestoreSeconds float64   `json:"restore_seconds,omitempty"`
+	RTOStatus      string    `json:"rto_status,omitempty"`
+}
+
+// Compliant reports whether the site meets every target it declares.
+func (c SiteCompliance) Compliant() bool {
+	return (c.RPOStatus == "" || c.RPOStatus == ComplianceOK) && (c.RTOStatus == "" || c.RTOStatus == ComplianceOK)
+}
+
+// ComplianceReport is the RPO/RTO compliance of the sites that declare
+// targets.
+type ComplianceReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Sites       []SiteCompliance `json:"sites"`
+	Compliant   int              `json:"compliant"`
+	Violations  int              `json:"violations"`
+}
+
+// EvaluateCompliance checks every site of fleet with an RPO or RTO (and in
+// group, when set) against latest, the time of each site's newest backup,
+// and the restores recorded in history. A restore's duration is measured
+// from its run record; the most recent successful one counts.
+func EvaluateCompliance(fleet *Fleet, group *FleetGroup, latest map[string]time.Time, history []RunRecord, now time.Time) *ComplianceReport {
+	restores := map[string]RunRecord{}
+	for _, rec := range history {
+		if rec.Kind != RunKindRestore || rec.Restore == nil || rec.DryRun || rec.Failed > 0 {
+			continue
+		}
+		if prev, ok := restores[rec.Restore.Site]; !ok || rec.FinishedAt.After(prev.FinishedAt) {
+			restores[rec.Restore.Site] = rec
+		}
+	}
+
+	rep := &ComplianceReport{GeneratedAt: now}
+	for site, entry := range fleet.Sites {
+		rpo, rto := fleet.SiteObjectives(site)
+		if (rpo == 0 && rto == 0) || !group.HasSite(site, "") {
+			continue
+		}
+		c := SiteCompliance{Site: site, Host: entry.Host}
+		if rpo > 0 {
+			c.RPO = rpo.String()
+			c.RPOStatus = ComplianceMissing
+			if t, ok := latest[site]; ok {
+				age := now.Sub(t)
+				c.LastBackup, c.BackupAgeSec = t, age.Seconds()
+				c.RPOStatus = ComplianceOK
+				if age > rpo {
+					c.RPOStatus = ComplianceViolated
+				}
+			}
+		}
+		if rto > 0 {
+			c.RTO = rto.String()
+			c.RTOStatus = ComplianceMissing
+			if rec, ok := restores[site]; ok {
+				took := rec.FinishedAt.Sub(rec.StartedAt)
+				c.LastRestore, c.RestoreSeconds = rec.FinishedAt, took.Seconds()
+				c.RTOStatus = ComplianceOK
+				if took > rto {
+					c.RTOStatus = ComplianceViolated
+				}
+			}
+		}
+		if c.Compliant() {
+			rep.Compliant++
+		} else {
+			rep.Violations++
+		}
+		rep.Sites = append(rep.Sites, c)
+	}
+	// Violations first, then by site.
+	sort.Slice(rep.Sites, func(i, j int) bool {
+		a, b := rep.Sites[i], rep.Sites[j]
+		if a.Compliant() != b.Compliant() {
+			return !a.Compliant()
+		}
+		return a.Site < b.Site
+	})
+	return rep
+}
+
+// ObjectSite returns the site a backup object belongs to: the directory
+// holding it (backups/<site>/<file>).
+func ObjectSite(key string) string {
+	return inventorySite(key, "")
+}
+
+// LatestBackupTimes returns the time of the newest backup of every site
+// under prefix, by the timestamp in the backup names.
+func (bm *BackupManager) LatestBackupTimes(prefix string) (map[string]time.Time, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]time.Time{}
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		site := ObjectSite(o.Key)
+		if t := BackupTime(o); t.After(latest[site]) {
+			latest[site] = t
+		}
+	}
+	return latest, nil
+}
+
+var complianceHTML = template.Must(template.New("compliance").Funcs(template.FuncMap{
+	"when": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return FormatTime(t, "2006-01-02 15:04")
+	},
+	"seconds": func(s float64) string {
+		if s == 0 {
+			return "-"
+		}
+		return (time.Duration(s) * time.Second).Round(time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Backup RPO/RTO compliance</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+.ok { color: #1a7f37; }
+.violated { color: #fff; background: #cf222e; }
+.missing { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>Backup RPO/RTO compliance</h1>
+<p>Generated {{when .GeneratedAt}}: {{.Compliant}} compliant, {{.Violations}} in violation.</p>
+<table>
+<tr><th>Site</th><th>Host</th><th>RPO</th><th>Last backup</th><th>Age</th><th>RPO status</th><th>RTO</th><th>Last restore test</th><th>Took</th><th>RTO status</th></tr>
+{{range .Sites}}<tr>
+<td>{{.Site}}</td><td>{{.Host}}</td>
+<td>{{.RPO}}</td><td>{{when .LastBackup}}</td><td>{{seconds .BackupAgeSec}}</td><td class="{{.RPOStatus}}">{{.RPOStatus}}</td>
+<td>{{.RTO}}</td><td>{{when .LastRestore}}</td><td>{{seconds .RestoreSeconds}}</td><td class="{{.RTOStatus}}">{{.RTOStatus}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteComplianceHTML renders rep as a standalone HTML page.
+func WriteComplianceHTML(w io.Writer, rep *ComplianceReport) error {
+	return complianceHTML.Execute(w, rep)
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseObjective(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"90m": 90 * time.Minute,
+		"6h":  6 * time.Hour,
+		"1d":  24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+	} {
+		if got, err := ParseObjective(in); err != nil || got != want {
+			t.Errorf("ParseObjective(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0d", "-1h", "xd", "soon"} {
+		if _, err := ParseObjective(in); err == nil {
+			t.Errorf("ParseObjective(%q) accepted", in)
+		}
+	}
+}
+
+func writeComplianceFleet(t *testing.T, data string) (*Fleet, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadFleet(path)
+}
+
+func TestEvaluateCompliance(t *testing.T) {
+	fleet, err := writeComplianceFleet(t, `hosts:
+  wp1.example.com:
+    rpo: 1d
+    rto: 1h
+sites:
+  acme.com:
+    host: wp1.example.com
+  beta.com:
+    host: wp1.example.com
+    rpo: 6h
+  gamma.com:
+    rto: 30m
+  plain.com: {}
+`)
+	if err != nil {
+		t.Fatalf("LoadFleet() error = %v", err)
+	}
+	if rpo, rto := fleet.SiteObjectives("beta.com"); rpo != 6*time.Hour || rto != time.Hour {
+		t.Fatalf("SiteObjectives(beta.com) = %v, %v; want own RPO and the host's RTO", rpo, rto)
+	}
+
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	latest := map[string]time.Time{
+		"acme.com": now.Add(-2 * time.Hour),
+		"beta.com": now.Add(-8 * time.Hour),
+	}
+	restore := func(site string, took time.Duration, failed int) RunRecord {
+		return RunRecord{
+			Kind: RunKindRestore, StartedAt: now.Add(-took), FinishedAt: now, Failed: failed,
+			Restore: &RestoreStats{Site: site},
+		}
+	}
+	history := []RunRecord{
+		restore("acme.com", 20*time.Minute, 0),
+		restore("gamma.com", 10*time.Minute, 1),
+		restore("beta.com", 2*time.Hour, 0),
+	}
+
+	rep := EvaluateCompliance(fleet, nil, latest, history, now)
+	if len(rep.Sites) != 3 || rep.Compliant != 1 || rep.Violations != 2 {
+		t.Fatalf("EvaluateCompliance() = %+v", rep)
+	}
+	got := map[string]SiteCompliance{}
+	for _, c := range rep.Sites {
+		got[c.Site] = c
+	}
+	if c := got["acme.com"]; c.RPOStatus != ComplianceOK || c.RTOStatus != ComplianceOK {
+		t.Errorf("acme.com = %+v, want compliant", c)
+	}
+	if c := got["beta.com"]; c.RPOStatus != ComplianceViolated || c.RTOStatus != ComplianceViolated {
+		t.Errorf("beta.com = %+v, want both violated", c)
+	}
+	// The failed restore doesn't count as a test.
+	if c := got["gamma.com"]; c.RPOStatus != "" || c.RTOStatus != ComplianceMissing {
+		t.Errorf("gamma.com = %+v, want only a missing RTO", c)
+	}
+	if rep.Sites[2].Site != "acme.com" {
+		t.Errorf("EvaluateCompliance() order = %v, want violations first", rep.Sites)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteComplianceHTML(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `class="violated"`) || !strings.Contains(buf.String(), "beta.com") {
+		t.Errorf("WriteComplianceHTML() = %s", buf.String())
+	}
+}
+
+func TestLoadFleetRejectsObjective(t *testing.T) {
+	_, err := writeComplianceFleet(t, "sites:\n  acme.com:\n    rpo: daily\n")
+	if err == nil || !strings.Contains(err.Error(), "acme.com") {
+		t.Fatalf("LoadFleet() error = %v, want the bad rpo reported", err)
+	}
+}
@@ -0,0 +1,278 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression codecs for backup streams.
+const (
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+// rsyncableSuffix marks a spec whose gzip output is rsyncable.
+const rsyncableSuffix = "+rsyncable"
+
+// CompressionSpec is a codec and level, written "gzip-6" or "zstd-19". A zero
+// level is the codec's default; the zero spec is tar's own gzip.
+type CompressionSpec struct {
+	Codec string
+	Level int
+	// Rsyncable makes gzip restart its compression at content-defined
+	// boundaries (gzip --rsyncable), so a small change to a site only
+	// changes the compressed bytes around it. Written "gzip-6+rsyncable",
+	// it lets dedup and replication of the Minio data directory (ZFS
+	// dedup, borg, rsync) share most blocks between backups, for a ratio
+	// about 1% worse.
+	Rsyncable bool
+}
+
+// ParseCompressionSpec parses "gzip", "gzip-9", "zstd" or "zstd-19", with
+// "+rsyncable" allowed after gzip specs. An empty string yields the zero
+// spec.
+func ParseCompressionSpec(s string) (CompressionSpec, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return CompressionSpec{}, nil
+	}
+	s, rsyncable := strings.CutSuffix(s, rsyncableSuffix)
+	codec, level, hasLevel := strings.Cut(s, "-")
+	spec := CompressionSpec{Codec: codec, Rsyncable: rsyncable}
+	if hasLevel {
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return CompressionSpec{}, fmt.Errorf("invalid compression %q: level must be a number", s)
+		}
+		spec.Level = n
+	}
+	maxLevel := 0
+	switch codec {
+	case CodecGzip:
+		maxLevel = 9
+	case CodecZstd:
+		maxLevel = 22
+	default:
+		return CompressionSpec{}, fmt.Errorf("invalid compression %q: codec must be gzip or zstd", s)
+	}
+	if hasLevel && (spec.Level < 1 || spec.Level > maxLevel) {
+		return CompressionSpec{}, fmt.Errorf("invalid compression %q: %s levels are 1-%d", s, codec, maxLevel)
+	}
+	if rsyncable && codec != CodecGzip {
+		return CompressionSpec{}, fmt.Errorf("invalid compression %q: only gzip can be rsyncable", s+rsyncableSuffix)
+	}
+	return spec, nil
+}
+
+func (c CompressionSpec) String() string {
+	s := c.Codec
+	if s == "" {
+		s = CodecGzip
+	}
+	if c.Level != 0 {
+		s = fmt.Sprintf("%s-%d", s, c.Level)
+	}
+	if c.Rsyncable {
+		s += rsyncableSuffix
+	}
+	return s
+}
+
+// isTarDefault reports whether the spec is what `tar -z` produces.
+func (c CompressionSpec) isTarDefault() bool {
+	return (c.Codec == "" || c.Codec == CodecGzip) && c.Level == 0 && !c.Rsyncable
+}
+
+// gzipCommand is the gzip filter a spec other than tar's default pipes
+// through. --rsyncable needs GNU gzip 1.7+ or pigz.
+func (c CompressionSpec) gzipCommand() string {
+	level := c.Level
+	if level == 0 {
+		level = 6 // gzip's default
+	}
+	if c.Rsyncable {
+		return fmt.Sprintf("gzip --rsyncable -%d", level)
+	}
+	return fmt.Sprintf("gzip -%d", level)
+}
+
+// tarCreate returns the shell command that archives dir with this spec, in
+// the given order. Any level other than tar's default pipes through gzip, and
+// the smart order pipes a file list into tar; the caller must run either
+// with pipefail so every exit status is kept.
+func (c CompressionSpec) tarCreate(excludeArgs, dir, order string) string {
+	flags := "-czf"
+	if !c.isTarDefault() {
+		flags = "-cf"
+	}
+	var cmd string
+	if order == ArchiveOrderSmart {
+		cmd = fmt.Sprintf(`%s | tar %s - %s --no-recursion --null -T -`, smartFileList(dir), flags, excludeArgs)
+	} else {
+		cmd = fmt.Sprintf(`tar %s - %s "%s"`, flags, excludeArgs, dir)
+	}
+	if !c.isTarDefault() {
+		cmd += " | " + c.gzipCommand()
+	}
+	return cmd
+}
+
+// archiveName returns the key a stream compressed with this spec is named
+// after, swapping the .tgz extension of key for .tar.zst when needed.
+func (c CompressionSpec) archiveName(key string) string {
+	if c.Codec != CodecZstd {
+		return key
+	}
+	return strings.TrimSuffix(key, ".tgz") + ".tar.zst"
+}
+
+// compressionSettings is the per-destination compression of dual uploads.
+type compressionSettings struct {
+	minio   CompressionSpec
+	glacier CompressionSpec
+}
+
+// recompressGlacier reports whether the Glacier copy of a dual upload has to
+// be re-encoded from the Minio stream.
+func (s compressionSettings) recompressGlacier() bool {
+	if s.glacier.Codec == "" {
+		return false
+	}
+	// Glacier copies need no rsyncable blocks; they are not re-encoded
+	// just to drop them.
+	minio := s.minio
+	minio.Rsyncable = false
+	if minio.Codec == "" {
+		minio.Codec = CodecGzip
+	}
+	return s.glacier != minio
+}
+
+// SetCompression sets the codecs used for each destination when a backup is
+// uploaded to both Minio and Glacier. Minio backups are always gzip, since
+// read, restore and verification open them as .tgz; only their level can
+// change. A Glacier spec that differs from the Minio one makes the Glacier
+// copy be re-compressed from the Minio stream. Zero specs keep tar's gzip.
+func (bm *BackupManager) SetCompression(minio, glacier CompressionSpec) error {
+	if minio.Codec != "" && minio.Codec != CodecGzip {
+		return fmt.Errorf("minio backups must be gzip (got %s): read and restore expect .tgz tarballs", minio)
+	}
+	if glacier.Rsyncable {
+		return fmt.Errorf("glacier copies cannot be rsyncable (got %s): only the Minio stream is compressed by gzip on the host", glacier)
+	}
+	bm.compression = compressionSettings{minio: minio, glacier: glacier}
+	return nil
+}
+
+// tarCommand returns the shell command that archives workingDir for upload,
+// falling back to parentDir/<basename> when workingDir does not exist.
+func (bm *BackupManager) tarCommand(workingDir, parentDir, excludeArgs string) string {
+	spec, order := bm.compression.minio, bm.effectiveArchiveOrder()
+	var cmd string
+	if parentDir != "" {
+		alt := filepath.Join(parentDir, filepath.Base(workingDir))
+		cmd = fmt.Sprintf(`if [ -d "%s" ]; then %s; elif [ -d "%s" ]; then %s; else echo "tar: no such directory: %s" >&2; exit 2; fi`,
+			workingDir, spec.tarCreate(excludeArgs, workingDir, order), alt, spec.tarCreate(excludeArgs, alt, order), workingDir)
+	} else {
+		cmd = spec.tarCreate(excludeArgs, workingDir, order)
+	}
+	if !spec.isTarDefault() || order == ArchiveOrderSmart {
+		cmd = "set -o pipefail; " + cmd
+	}
+	return cmd
+}
+
+// tarListCommand returns the shell command that archives the NUL-separated
+// file list it reads on stdin, as produced by fileScan.smartFileList. Files
+// removed since the scan are skipped with a warning instead of failing the
+// backup; files created since are not archived.
+func (bm *BackupManager) tarListCommand(excludeArgs string) string {
+	spec := bm.compression.minio
+	if spec.isTarDefault() {
+		return fmt.Sprintf(`tar -czf - %s --no-recursion --null --ignore-failed-read -T -`, excludeArgs)
+	}
+	return fmt.Sprintf(`set -o pipefail; tar -cf - %s --no-recursion --null --ignore-failed-read -T - | %s`, excludeArgs, spec.gzipCommand())
+}
+
+// recompressBufferSize bounds how much re-encoded data is held between the
+// encoder and the Glacier upload.
+const recompressBufferSize = 4 << 20
+
+// uploadGlacierStream uploads the gzip stream r, a copy of what goes to
+// Minio, to Glacier, re-compressing it first when the Glacier codec differs.
+//
+// Re-compression runs through a bounded pipe, so a slow Glacier encoder
+// (zstd-19, say) slows the Minio upload down rather than buffering the
+// backup in memory. r is always read to the end, even when the Glacier
+// upload fails, so the Minio upload it is tee'd from never stalls. The
+// Glacier buffer is written into the temp space reserved by tmp.
+func (bm *BackupManager) uploadGlacierStream(objectName string, r io.Reader, tmp *tempReservation) (*GlacierUploadStats, error) {
+	settings := bm.effectiveCompression()
+	spec := settings.glacier
+	if !settings.recompressGlacier() {
+		return bm.uploadToAWSReserved(objectName, r, -1, tmp)
+	}
+	fmt.Printf("      [AWS] Re-compressing as %s\n", spec)
+	pr, pw := io.Pipe()
+	go func() {
+		err := transcodeGzip(pw, r, spec)
+		_, _ = io.Copy(io.Discard, r)
+		pw.CloseWithError(err)
+	}()
+	stats, err := bm.uploadToAWSReserved(spec.archiveName(objectName), pr, -1, tmp)
+	// Unblock the encoder if the upload stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	if stats != nil {
+		stats.Compression = spec.String()
+	}
+	return stats, err
+}
+
+// transcodeGzip decodes the gzip stream src and writes it to dst encoded
+// with spec.
+func transcodeGzip(dst io.Writer, src io.Reader, spec CompressionSpec) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	bufSize, zstdOpts := recompressBufferSize, []zstd.EOption(nil)
+	if LowMemory() {
+		bufSize, zstdOpts = lowMemoryRecompressBufferSize, []zstd.EOption{zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true)}
+	}
+	buf := bufio.NewWriterSize(dst, bufSize)
+	var enc io.WriteCloser
+	switch spec.Codec {
+	case CodecZstd:
+		level := zstd.SpeedDefault
+		if spec.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(spec.Level)
+		}
+		enc, err = zstd.NewWriter(buf, append(zstdOpts, zstd.WithEncoderLevel(level))...)
+	default:
+		level := gzip.DefaultCompression
+		if spec.Level > 0 {
+			level = spec.Level
+		}
+		enc, err = gzip.NewWriterLevel(buf, level)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s encoder: %w", spec, err)
+	}
+	if _, err := io.Copy(enc, gz); err != nil {
+		enc.Close()
+		return fmt.Errorf("failed to re-compress as %s: %w", spec, err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to re-compress as %s: %w", spec, err)
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,392 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tarExcludePatterns are passed to every tar invocation (real backups and the
+// sample/accurate estimators) and applied by the heuristic estimator, so all
+// of them agree on what ends up in a backup.
+var tarExcludePatterns = []string{"*.tgz", "*.tar.gz", "*.zip"}
+
+// tarExcludeArgs is tarExcludePatterns rendered as tar --exclude flags.
+var tarExcludeArgs = buildTarExcludeArgs(tarExcludePatterns)
+
+func buildTarExcludeArgs(patterns []string) string {
+	args := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		args = append(args, fmt.Sprintf(`--exclude="%s"`, p))
+	}
+	return strings.Join(args, " ")
+}
+
+// isTarExcluded mirrors GNU tar's default (unanchored) --exclude matching: an
+// entry is excluded when any of its path components matches a pattern, which
+// also drops everything below an excluded directory.
+func isTarExcluded(relPath string) bool {
+	for _, component := range strings.Split(relPath, "/") {
+		if component == "" {
+			continue
+		}
+		for _, p := range tarExcludePatterns {
+			if ok, _ := path.Match(p, component); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const (
+	tarBlockSize = 512
+	tarNameLimit = 100 // longer names need a GNU long-name entry
+	gzipFraming  = 18  // 10-byte gzip header + 8-byte trailer
+)
+
+// CompressionModel holds per-extension compression ratios (compressed bytes /
+// uncompressed bytes) used by the heuristic estimator. The defaults are
+// conservative guesses; `backup estimate calibrate` learns them from real backups.
+type CompressionModel struct {
+	Ratios       map[string]float64 `json:"ratios"`
+	DefaultRatio float64            `json:"default_ratio"`
+	// HeaderBytes is the compressed size of a single 512-byte tar header block.
+	HeaderBytes float64 `json:"header_bytes"`
+	// Scale corrects for cross-file redundancy that per-file ratios can't see
+	// (measured total / modelled total during calibration).
+	Scale         float64   `json:"scale,omitempty"`
+	CalibratedAt  time.Time `json:"calibrated_at,omitempty"`
+	SampleBytes   int64     `json:"sample_bytes,omitempty"`
+	SourceObjects []string  `json:"source_objects,omitempty"`
+}
+
+// DefaultCompressionModel returns the built-in, uncalibrated ratios.
+func DefaultCompressionModel() *CompressionModel {
+	m := &CompressionModel{
+		Ratios:       make(map[string]float64),
+		DefaultRatio: 0.50,
+		HeaderBytes:  64,
+		Scale:        1,
+	}
+	for _, ext := range []string{".txt", ".log", ".sql", ".php", ".html", ".css", ".js", ".json", ".xml", ".csv", ".md", ".yml", ".yaml"} {
+		m.Ratios[ext] = 0.30
+	}
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".mp4", ".mp3", ".avi", ".mov", ".zip", ".gz", ".tgz", ".bz2", ".pdf", ".woff", ".woff2", ".ttf", ".eot"} {
+		m.Ratios[ext] = 0.95
+	}
+	for _, ext := range []string{".svg", ".otf"} {
+		m.Ratios[ext] = 0.50
+	}
+	return m
+}
+
+// DefaultCompressionModelPath returns ~/.ciwg/compression-model.json.
+func DefaultCompressionModelPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "compression-model.json")
+}
+
+// LoadCompressionModel reads a calibrated model from path. A missing file
+// yields the default model; calibrated ratios override the defaults per extension.
+func LoadCompressionModel(path string) (*CompressionModel, error) {
+	m := DefaultCompressionModel()
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("failed to read compression model: %w", err)
+	}
+
+	var loaded CompressionModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse compression model: %w", err)
+	}
+	for ext, r := range loaded.Ratios {
+		m.Ratios[ext] = r
+	}
+	if loaded.DefaultRatio > 0 {
+		m.DefaultRatio = loaded.DefaultRatio
+	}
+	if loaded.HeaderBytes > 0 {
+		m.HeaderBytes = loaded.HeaderBytes
+	}
+	if loaded.Scale > 0 {
+		m.Scale = loaded.Scale
+	}
+	m.CalibratedAt = loaded.CalibratedAt
+	m.SampleBytes = loaded.SampleBytes
+	m.SourceObjects = loaded.SourceObjects
+	return m, nil
+}
+
+// Save writes the model as JSON, creating the parent directory if needed.
+func (m *CompressionModel) Save(path string) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create model directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal compression model: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write compression model: %w", err)
+	}
+	return nil
+}
+
+// RatioFor returns the compression ratio for a file name.
+func (m *CompressionModel) RatioFor(name string) float64 {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".tar.gz") {
+		if r, ok := m.Ratios[".tar.gz"]; ok {
+			return r
+		}
+	}
+	if r, ok := m.Ratios[filepath.Ext(lower)]; ok {
+		return r
+	}
+	return m.DefaultRatio
+}
+
+// tarEntry is one filesystem entry as reported by `find -printf "%y %s %P\n"`.
+type tarEntry struct {
+	Type byte // 'f' regular file, 'd' directory, 'l' symlink, ...
+	Size int64
+	Path string // relative to the archived directory; "" for the directory itself
+}
+
+// parseFindEntries parses `find DIR -printf "%y %s %P\n"` output. Paths may
+// contain spaces, so only the first two fields are split off.
+func parseFindEntries(output string) []tarEntry {
+	return parseFindEntriesSep(output, "\n")
+}
+
+// parseFindEntriesSep is parseFindEntries for entries terminated by sep.
+func parseFindEntriesSep(output, sep string) []tarEntry {
+	var entries []tarEntry
+	for _, line := range strings.Split(output, sep) {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 2 || len(parts[0]) != 1 {
+			continue
+		}
+		size, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		e := tarEntry{Type: parts[0][0], Size: size}
+		if len(parts) == 3 {
+			e.Path = parts[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// estimateTarGzSize models the size of `tar -czf - <root>` for the given
+// entries: excluded paths are skipped, every member costs a header block (plus
+// a GNU long-name entry for names over 100 bytes), file data is compressed at
+// its extension's ratio, and gzip adds its framing.
+// root is the archived path as tar records it (leading "/" stripped).
+func estimateTarGzSize(entries []tarEntry, root string, m *CompressionModel) int64 {
+	root = strings.Trim(root, "/")
+	var headers int64
+	var data float64
+
+	for _, e := range entries {
+		if e.Path != "" && isTarExcluded(e.Path) {
+			continue
+		}
+		name := root
+		if e.Path != "" {
+			name = root + "/" + e.Path
+		}
+		if e.Type == 'd' {
+			name += "/"
+		}
+
+		headers++
+		if len(name) > tarNameLimit {
+			headers += 1 + (int64(len(name))+tarBlockSize)/tarBlockSize
+		}
+		if e.Type == 'f' && e.Size > 0 {
+			data += float64(e.Size) * m.RatioFor(e.Path)
+		}
+	}
+
+	// Block padding after each file, the two end-of-archive blocks and the
+	// padding to a full record are all zeros; deflate squeezes them to a few
+	// bytes in total, so they are charged as two extra header blocks.
+	headers += 2
+
+	scale := m.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	total := (data+float64(headers)*m.HeaderBytes)*scale + gzipFraming
+	return int64(total)
+}
+
+// CalibrationResult summarizes a calibration run.
+type CalibrationResult struct {
+	Model        *CompressionModel
+	Objects      int
+	Files        int
+	Uncompressed int64
+	Compressed   int64
+	// Predicted is the modelled compressed size before Scale is applied.
+	Predicted int64
+}
+
+// extensionSample accumulates per-extension calibration data.
+type extensionSample struct {
+	raw, compressed int64
+}
+
+// CalibrateCompressionModel learns per-extension ratios from existing backup
+// tarballs: every file is re-compressed on its own to measure its extension's
+// ratio, and the model's Scale is set so the modelled total matches the real
+// compressed object sizes. Extensions with less than minSampleBytes of data
+// keep their default ratio.
+func (bm *BackupManager) CalibrateCompressionModel(objectKeys []string, minSampleBytes int64) (*CalibrationResult, error) {
+	samples := make(map[string]*extensionSample)
+	type fileSample struct {
+		ext  string
+		size int64
+	}
+	var files []fileSample
+	var headers int64
+	res := &CalibrationResult{}
+
+	for _, key := range objectKeys {
+		fmt.Printf("Analyzing %s...\n", key)
+		obj, err := bm.DownloadBackup(key)
+		if err != nil {
+			return nil, err
+		}
+		counter := &countingReader{r: obj}
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			obj.Close()
+			return nil, fmt.Errorf("failed to open gzip stream for %s: %w", key, err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				obj.Close()
+				return nil, fmt.Errorf("failed to read tarball %s: %w", key, err)
+			}
+			headers++
+			if len(hdr.Name) > tarNameLimit {
+				headers += 1 + (int64(len(hdr.Name))+tarBlockSize)/tarBlockSize
+			}
+			if hdr.Typeflag != tar.TypeReg || hdr.Size == 0 {
+				continue
+			}
+			cw := &countingWriter{}
+			zw := gzip.NewWriter(cw)
+			n, err := io.Copy(zw, tr)
+			if err != nil {
+				obj.Close()
+				return nil, fmt.Errorf("failed to read %s from %s: %w", hdr.Name, key, err)
+			}
+			zw.Close()
+
+			ext := calibrationExt(hdr.Name)
+			s := samples[ext]
+			if s == nil {
+				s = &extensionSample{}
+				samples[ext] = s
+			}
+			s.raw += n
+			// Drop per-file gzip framing; the stream only pays it once.
+			s.compressed += max(cw.written-gzipFraming, 0)
+			files = append(files, fileSample{ext: ext, size: n})
+			res.Uncompressed += n
+		}
+		// Drain the remainder so the counter sees the whole object.
+		_, _ = io.Copy(io.Discard, counter)
+		obj.Close()
+		res.Compressed += counter.n
+		res.Objects++
+	}
+	res.Files = len(files)
+
+	m := DefaultCompressionModel()
+	var sampleBytes int64
+	exts := make([]string, 0, len(samples))
+	for ext := range samples {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		s := samples[ext]
+		sampleBytes += s.raw
+		if s.raw < minSampleBytes || ext == "" {
+			continue
+		}
+		m.Ratios[ext] = float64(s.compressed) / float64(s.raw)
+	}
+
+	var modelled float64
+	for _, f := range files {
+		modelled += float64(f.size) * m.RatioFor("x"+f.ext)
+	}
+	modelled += float64(headers) * m.HeaderBytes
+	if modelled > 0 && res.Compressed > 0 {
+		m.Scale = float64(res.Compressed) / modelled
+	}
+	res.Predicted = int64(modelled)
+
+	m.CalibratedAt = time.Now().UTC()
+	m.SampleBytes = sampleBytes
+	m.SourceObjects = objectKeys
+	res.Model = m
+	return res, nil
+}
+
+// calibrationExt returns the lower-cased extension used as a ratio key.
+func calibrationExt(name string) string {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".tar.gz") {
+		return ".tar.gz"
+	}
+	return filepath.Ext(lower)
+}
+
+// countingReader counts bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsTarExcluded(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"www/wp-content/index.php", false},
+		{"www/old-site.tgz", true},
+		{"www/backup.tar.gz", true},
+		{"www/uploads/archive.zip", true},
+		{"www/exports.zip/readme.txt", true},
+		{"www/zipcodes.csv", false},
+	}
+	for _, tt := range tests {
+		if got := isTarExcluded(tt.path); got != tt.want {
+			t.Errorf("isTarExcluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseFindEntries(t *testing.T) {
+	output := "d 4096 \nf 120 wp-config.php\nf 2048 uploads/My Photo 1.jpg\nbogus line\nl 11 current\n"
+	entries := parseFindEntries(output)
+	if len(entries) != 4 {
+		t.Fatalf("parseFindEntries() returned %d entries, want 4", len(entries))
+	}
+	if entries[0].Type != 'd' || entries[0].Path != "" {
+		t.Errorf("root entry = %+v", entries[0])
+	}
+	if entries[2].Path != "uploads/My Photo 1.jpg" || entries[2].Size != 2048 {
+		t.Errorf("entry with spaces = %+v", entries[2])
+	}
+	if entries[3].Type != 'l' {
+		t.Errorf("symlink entry = %+v", entries[3])
+	}
+}
+
+func TestEstimateTarGzSize(t *testing.T) {
+	m := DefaultCompressionModel()
+	m.HeaderBytes = 10
+
+	base := []tarEntry{
+		{Type: 'd', Path: ""},
+		{Type: 'f', Size: 1000, Path: "index.php"},
+		{Type: 'f', Size: 1000, Path: "photo.jpg"},
+	}
+	// 3 members + 2 trailer blocks; 1000*0.30 + 1000*0.95 data.
+	want := int64(1250 + 5*10 + gzipFraming)
+	if got := estimateTarGzSize(base, "/var/opt/site", m); got != want {
+		t.Errorf("estimateTarGzSize() = %d, want %d", got, want)
+	}
+
+	withExcluded := append(append([]tarEntry{}, base...), tarEntry{Type: 'f', Size: 1 << 30, Path: "old.tgz"})
+	if got := estimateTarGzSize(withExcluded, "/var/opt/site", m); got != want {
+		t.Errorf("excluded entries should not count: got %d, want %d", got, want)
+	}
+
+	long := []tarEntry{{Type: 'f', Size: 0, Path: strings.Repeat("a", 120)}}
+	// 1 header + long-name entry (1 header + 1 data block) + 2 trailer blocks.
+	if got, want := estimateTarGzSize(long, "site", m), int64(5*10+gzipFraming); got != want {
+		t.Errorf("long name estimate = %d, want %d", got, want)
+	}
+
+	m.Scale = 2
+	if got, want := estimateTarGzSize(base, "/var/opt/site", m), int64((1250+5*10)*2+gzipFraming); got != want {
+		t.Errorf("scaled estimate = %d, want %d", got, want)
+	}
+}
+
+func TestCompressionModelRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model", "compression-model.json")
+
+	m, err := LoadCompressionModel(path)
+	if err != nil {
+		t.Fatalf("LoadCompressionModel() on missing file error = %v", err)
+	}
+	if m.RatioFor("style.css") != 0.30 || m.RatioFor("unknown.bin") != m.DefaultRatio {
+		t.Fatalf("unexpected default ratios")
+	}
+
+	m.Ratios[".php"] = 0.22
+	m.Scale = 0.9
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadCompressionModel(path)
+	if err != nil {
+		t.Fatalf("LoadCompressionModel() error = %v", err)
+	}
+	if got := loaded.RatioFor("INDEX.PHP"); got != 0.22 {
+		t.Errorf("RatioFor(.php) = %v, want 0.22", got)
+	}
+	if loaded.Scale != 0.9 {
+		t.Errorf("Scale = %v, want 0.9", loaded.Scale)
+	}
+	if got := loaded.RatioFor("photo.jpg"); got != 0.95 {
+		t.Errorf("default ratio for .jpg lost after round trip: %v", got)
+	}
+}
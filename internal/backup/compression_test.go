@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseCompressionSpec(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    CompressionSpec
+		wantErr bool
+	}{
+		{in: "", want: CompressionSpec{}},
+		{in: "gzip", want: CompressionSpec{Codec: CodecGzip}},
+		{in: "gzip-1", want: CompressionSpec{Codec: CodecGzip, Level: 1}},
+		{in: "ZSTD-19", want: CompressionSpec{Codec: CodecZstd, Level: 19}},
+		{in: "zstd", want: CompressionSpec{Codec: CodecZstd}},
+		{in: "gzip-10", wantErr: true},
+		{in: "zstd-0", wantErr: true},
+		{in: "zstd-fast", wantErr: true},
+		{in: "xz-6", wantErr: true},
+		{in: "gzip+rsyncable", want: CompressionSpec{Codec: CodecGzip, Rsyncable: true}},
+		{in: "gzip-6+rsyncable", want: CompressionSpec{Codec: CodecGzip, Level: 6, Rsyncable: true}},
+		{in: "zstd-19+rsyncable", wantErr: true},
+	} {
+		got, err := ParseCompressionSpec(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseCompressionSpec(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseCompressionSpec(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRecompressGlacier(t *testing.T) {
+	gzip1 := CompressionSpec{Codec: CodecGzip, Level: 1}
+	zstd19 := CompressionSpec{Codec: CodecZstd, Level: 19}
+	for _, tc := range []struct {
+		name           string
+		minio, glacier CompressionSpec
+		want           bool
+	}{
+		{name: "defaults", want: false},
+		{name: "glacier unset", minio: gzip1, want: false},
+		{name: "same gzip", glacier: CompressionSpec{Codec: CodecGzip}, want: false},
+		{name: "same level", minio: gzip1, glacier: gzip1, want: false},
+		{name: "zstd", glacier: zstd19, want: true},
+		{name: "other gzip level", minio: gzip1, glacier: CompressionSpec{Codec: CodecGzip, Level: 9}, want: true},
+		{name: "rsyncable minio", minio: CompressionSpec{Codec: CodecGzip, Level: 1, Rsyncable: true}, glacier: gzip1, want: false},
+	} {
+		s := compressionSettings{minio: tc.minio, glacier: tc.glacier}
+		if got := s.recompressGlacier(); got != tc.want {
+			t.Errorf("%s: recompressGlacier() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSetCompressionRejectsZstdForMinio(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{})
+	if err := bm.SetCompression(CompressionSpec{Codec: CodecZstd}, CompressionSpec{}); err == nil {
+		t.Error("SetCompression(zstd for Minio) error = nil")
+	}
+	if err := bm.SetCompression(CompressionSpec{Codec: CodecGzip, Level: 1}, CompressionSpec{Codec: CodecZstd, Level: 19}); err != nil {
+		t.Errorf("SetCompression() error = %v", err)
+	}
+	if err := bm.SetCompression(CompressionSpec{}, CompressionSpec{Codec: CodecGzip, Rsyncable: true}); err == nil {
+		t.Error("SetCompression(rsyncable Glacier) error = nil")
+	}
+}
+
+func TestTarCommand(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{})
+	want := `if [ -d "/srv/a.com" ]; then tar -czf - --exclude=x "/srv/a.com"; elif [ -d "/var/opt/sites/a.com" ]; then tar -czf - --exclude=x "/var/opt/sites/a.com"; else echo "tar: no such directory: /srv/a.com" >&2; exit 2; fi`
+	if got := bm.tarCommand("/srv/a.com", "/var/opt/sites", "--exclude=x"); got != want {
+		t.Errorf("tarCommand() =\n%s\nwant\n%s", got, want)
+	}
+
+	bm.SetCompression(CompressionSpec{Codec: CodecGzip, Level: 1}, CompressionSpec{})
+	want = `set -o pipefail; tar -cf - --exclude=x "/srv/a.com" | gzip -1`
+	if got := bm.tarCommand("/srv/a.com", "", "--exclude=x"); got != want {
+		t.Errorf("tarCommand(gzip-1) = %s, want %s", got, want)
+	}
+
+	bm.SetCompression(CompressionSpec{Codec: CodecGzip, Rsyncable: true}, CompressionSpec{})
+	want = `set -o pipefail; tar -cf - --exclude=x "/srv/a.com" | gzip --rsyncable -6`
+	if got := bm.tarCommand("/srv/a.com", "", "--exclude=x"); got != want {
+		t.Errorf("tarCommand(gzip+rsyncable) = %s, want %s", got, want)
+	}
+}
+
+// TestRsyncableGzipDedups checks that a small change near the start of an
+// archive leaves most of the rsyncable output intact, which plain gzip does
+// not.
+func TestRsyncableGzipDedups(t *testing.T) {
+	if err := exec.Command("bash", "-c", "gzip --rsyncable -c </dev/null >/dev/null").Run(); err != nil {
+		t.Skip("gzip --rsyncable not available")
+	}
+	var buf bytes.Buffer
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; buf.Len() < 4<<20; i++ {
+		fmt.Fprintf(&buf, "<p>post %d: %x</p>\n", i, rng.Int63())
+	}
+	data := buf.Bytes()
+	changed := bytes.Clone(data)
+	changed[100] ^= 0xff
+
+	shared := func(spec CompressionSpec) float64 {
+		compress := func(in []byte) []byte {
+			cmd := exec.Command("bash", "-c", spec.gzipCommand())
+			cmd.Stdin = bytes.NewReader(in)
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("%s: %v", spec.gzipCommand(), err)
+			}
+			return out
+		}
+		// Count the bytes the two outputs share before the CRC and size
+		// trailer.
+		a, b := compress(data), compress(changed)
+		a, b = a[:len(a)-8], b[:len(b)-8]
+		n := 0
+		for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+			n++
+		}
+		return float64(n) / float64(len(a))
+	}
+	plain, rsyncable := shared(CompressionSpec{Codec: CodecGzip, Level: 6}), shared(CompressionSpec{Codec: CodecGzip, Level: 6, Rsyncable: true})
+	if rsyncable < 0.5 || rsyncable <= plain {
+		t.Errorf("identical tail after a 1-byte change: rsyncable %.0f%%, plain %.0f%%", rsyncable*100, plain*100)
+	}
+}
+
+func TestTarCommandRuns(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+	dir := t.TempDir()
+	site := filepath.Join(dir, "a.com")
+	os.Mkdir(site, 0o755)
+	os.WriteFile(filepath.Join(site, "index.php"), []byte("<?php"), 0o644)
+
+	bm := NewBackupManager(nil, &MinioConfig{})
+	bm.SetCompression(CompressionSpec{Codec: CodecGzip, Level: 1}, CompressionSpec{})
+	out, err := exec.Command("bash", "-c", bm.tarCommand(site, "", "")).Output()
+	if err != nil {
+		t.Fatalf("tar command error = %v", err)
+	}
+	if _, err := gzip.NewReader(bytes.NewReader(out)); err != nil {
+		t.Errorf("output is not gzip: %v", err)
+	}
+
+	// pipefail keeps tar's failure even though gzip succeeds.
+	if err := exec.Command("bash", "-c", bm.tarCommand(filepath.Join(dir, "missing"), "", "")).Run(); err == nil {
+		t.Error("tar of a missing directory succeeded")
+	}
+}
+
+func TestTranscodeGzip(t *testing.T) {
+	original := makeLargeTarball(t)
+	gz, _ := gzip.NewReader(bytes.NewReader(original))
+	tarData, _ := io.ReadAll(gz)
+
+	var out bytes.Buffer
+	if err := transcodeGzip(&out, bytes.NewReader(original), CompressionSpec{Codec: CodecZstd, Level: 19}); err != nil {
+		t.Fatalf("transcodeGzip(zstd-19) error = %v", err)
+	}
+	dec, err := zstd.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("decoding zstd output: %v", err)
+	}
+	if !bytes.Equal(got, tarData) {
+		t.Error("zstd output does not decode to the original tar stream")
+	}
+
+	if err := transcodeGzip(io.Discard, bytes.NewReader([]byte("not gzip")), CompressionSpec{Codec: CodecZstd}); err == nil {
+		t.Error("transcodeGzip(not gzip) error = nil")
+	}
+}
+
+func TestZstdArchiveDescription(t *testing.T) {
+	key := CompressionSpec{Codec: CodecZstd}.archiveName("backups/a.com/a.com-20250101-020000.tgz")
+	if key != "backups/a.com/a.com-20250101-020000.tar.zst" {
+		t.Fatalf("archiveName() = %s", key)
+	}
+	d, ok := ParseGlacierDescription("Backup: " + key)
+	if !ok || d.Site != "a.com" || d.Timestamp.IsZero() {
+		t.Errorf("ParseGlacierDescription(%s) = %+v, %v", key, d, ok)
+	}
+}
@@ -65,6 +65,10 @@ type ContainerConfig struct {
 	// Skip this container if true
 	Skip bool `yaml:"skip,omitempty"`
 
+	// Freeze during the WordPress database export: none, maintenance or
+	// plugin:<slug>. Overrides --export-freeze for this container.
+	ExportFreeze string `yaml:"export_freeze,omitempty"`
+
 	// Optional bucket path prefix for this container. If set this overrides
 	// the top-level defaults.bucket_path value and will be used as the
 	// prefix within the Minio bucket (e.g. "customer-a/backups").
@@ -102,6 +106,9 @@ type DatabaseConfig struct {
 
 	// Path where database export should be saved (relative to working dir)
 	ExportPath string `yaml:"export_path,omitempty"`
+
+	// Copy the data directory consistently instead of exporting a dump
+	ConsistentCopy *ConsistentCopyConfig `yaml:"consistent_copy,omitempty"`
 }
 
 // PathsConfig defines custom paths for backup operations
@@ -157,6 +164,18 @@ func (c *BackupConfig) Validate() error {
 		if container.Type == "" {
 			return fmt.Errorf("container[%d]: type is required", i)
 		}
+		if _, err := ParseExportFreeze(container.ExportFreeze); err != nil {
+			return fmt.Errorf("container[%d]: export_freeze: %w", i, err)
+		}
+		if cc := container.Database.ConsistentCopy; cc != nil {
+			dbType := container.Database.Type
+			if dbType == "" {
+				dbType = container.Type
+			}
+			if err := cc.Validate(dbType); err != nil {
+				return fmt.Errorf("container[%d]: database.consistent_copy: %w", i, err)
+			}
+		}
 		// Validate database config if type requires it
 		if container.Type == "postgres" || container.Type == "mysql" || container.Type == "mariadb" {
 			if container.Database.Type == "" {
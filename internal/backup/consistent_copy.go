@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Consistent-copy modes, for databases whose logical dumps take too long.
+// Both copy the data directory on the host into the backed-up tree instead
+// of exporting a dump.
+const (
+	// ConsistentCopyPause flushes the database, freezes its container with
+	// `docker pause` while the data directory is copied and unpauses it. The
+	// copy is crash-consistent: the database recovers it like a snapshot
+	// taken at the moment of the pause. Works for any database type.
+	ConsistentCopyPause = "pause"
+	// ConsistentCopyBackupMode copies a PostgreSQL 15+ data directory between
+	// pg_backup_start and pg_backup_stop, writing the backup_label and the
+	// WAL up to the stop into the copy. The database stays online.
+	ConsistentCopyBackupMode = "backup-mode"
+)
+
+// Tools that copy the data directory.
+const (
+	// CopyRsync copies with rsync: a pass while the database runs, then a
+	// short pass while it is paused or in backup mode.
+	CopyRsync = "rsync"
+	// CopyReflink copies with cp --reflink=always, a near-instant
+	// copy-on-write snapshot of the files on btrfs and XFS.
+	CopyReflink = "reflink"
+)
+
+// DefaultConsistentCopyTimeout bounds how long the database stays paused or
+// in backup mode when consistent_copy.timeout is not set.
+const DefaultConsistentCopyTimeout = 60 * time.Second
+
+// consistentCopyGrace is how long after the timeout the safety nets step in:
+// the watchdog that unpauses a container left paused and the end of the
+// session holding PostgreSQL in backup mode.
+const consistentCopyGrace = 30 * time.Second
+
+// ConsistentCopyConfig configures a consistent copy of a database's data
+// directory in place of a dump.
+type ConsistentCopyConfig struct {
+	// DataDir is the host path of the database's data directory.
+	DataDir string `yaml:"data_dir"`
+	// Mode is pause (default) or backup-mode (PostgreSQL 15+).
+	Mode string `yaml:"mode,omitempty"`
+	// Target is where the copy is written; relative paths are below the
+	// working directory. It must be inside the backed-up directory. Defaults
+	// to <database_export_dir or working dir>/<database name>-datadir.
+	Target string `yaml:"target,omitempty"`
+	// Copy is rsync (default) or reflink.
+	Copy string `yaml:"copy,omitempty"`
+	// Timeout is the longest the database may stay paused or in backup
+	// mode, e.g. "90s"; the copy fails rather than exceed it. Default 60s.
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// Validate checks c for a database of dbType.
+func (c *ConsistentCopyConfig) Validate(dbType string) error {
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir is required")
+	}
+	switch c.Mode {
+	case "", ConsistentCopyPause:
+	case ConsistentCopyBackupMode:
+		if t := strings.ToLower(dbType); t != "postgres" && t != "postgresql" {
+			return fmt.Errorf("mode %s needs a postgres database, not %q", c.Mode, dbType)
+		}
+	default:
+		return fmt.Errorf("unknown mode %q (use %s or %s)", c.Mode, ConsistentCopyPause, ConsistentCopyBackupMode)
+	}
+	if c.Copy != "" && c.Copy != CopyRsync && c.Copy != CopyReflink {
+		return fmt.Errorf("unknown copy %q (use %s or %s)", c.Copy, CopyRsync, CopyReflink)
+	}
+	if _, err := c.timeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConsistentCopyConfig) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return DefaultConsistentCopyTimeout, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: use a positive duration such as 90s", c.Timeout)
+	}
+	return d, nil
+}
+
+// consistentCopyPlan is a consistent copy resolved for one container.
+type consistentCopyPlan struct {
+	Mode      string
+	Copy      string
+	DBType    string
+	Container string
+	User      string
+	Password  string
+	Name      string
+	DataDir   string
+	Target    string
+	Timeout   time.Duration
+}
+
+// consistentCopyPlanFor resolves the consistent copy configured for
+// container's database.
+func consistentCopyPlanFor(container ContainerInfo) (*consistentCopyPlan, error) {
+	db := container.Config.Database
+	cc := db.ConsistentCopy
+	if err := cc.Validate(db.Type); err != nil {
+		return nil, fmt.Errorf("consistent_copy: %w", err)
+	}
+	timeout, _ := cc.timeout()
+	p := &consistentCopyPlan{
+		Mode:      cc.Mode,
+		Copy:      cc.Copy,
+		DBType:    strings.ToLower(db.Type),
+		Container: db.Container,
+		User:      db.User,
+		Password:  db.Password,
+		Name:      db.Name,
+		DataDir:   filepath.Clean(cc.DataDir),
+		Target:    cc.Target,
+		Timeout:   timeout,
+	}
+	if p.Mode == "" {
+		p.Mode = ConsistentCopyPause
+	}
+	if p.Copy == "" {
+		p.Copy = CopyRsync
+	}
+	if p.Container == "" {
+		p.Container = container.Name
+	}
+	if p.Target == "" {
+		name := db.Name
+		if name == "" {
+			name = p.DBType
+		}
+		dir := container.WorkingDir
+		if container.Config.Paths.DatabaseExportDir != "" {
+			dir = container.Config.Paths.DatabaseExportDir
+		}
+		p.Target = filepath.Join(dir, name+"-datadir")
+	} else if !filepath.IsAbs(p.Target) {
+		p.Target = filepath.Join(container.WorkingDir, p.Target)
+	}
+	p.Target = filepath.Clean(p.Target)
+	if p.Target == p.DataDir || strings.HasPrefix(p.Target, p.DataDir+"/") || strings.HasPrefix(p.DataDir, p.Target+"/") {
+		return nil, fmt.Errorf("consistent_copy: target %s overlaps data_dir %s", p.Target, p.DataDir)
+	}
+	return p, nil
+}
+
+func (p *consistentCopyPlan) String() string {
+	if p.Mode == ConsistentCopyBackupMode {
+		return fmt.Sprintf("copy %s to %s in PostgreSQL backup mode (at most %s)", p.DataDir, p.Target, p.Timeout)
+	}
+	return fmt.Sprintf("copy %s to %s with %s paused (at most %s)", p.DataDir, p.Target, p.Container, p.Timeout)
+}
+
+// flushCommand returns the command that writes the database's dirty state
+// to disk before it is paused, so it has less to recover, or "" when the
+// type has none.
+func (p *consistentCopyPlan) flushCommand() string {
+	switch p.DBType {
+	case "mysql", "mariadb":
+		user := p.User
+		if user == "" {
+			user = "root"
+		}
+		pass := ""
+		if p.Password != "" {
+			pass = " -p" + shellQuote(p.Password)
+		}
+		return fmt.Sprintf(`docker exec %s mysql -u %s%s -e 'FLUSH TABLES'`, shellQuote(p.Container), shellQuote(user), pass)
+	case "postgres", "postgresql":
+		return fmt.Sprintf(`docker exec %s psql -X %s -c CHECKPOINT`, shellQuote(p.Container), p.psqlArgs())
+	}
+	return ""
+}
+
+func (p *consistentCopyPlan) psqlArgs() string {
+	user := p.User
+	if user == "" {
+		user = "postgres"
+	}
+	args := "-U " + shellQuote(user)
+	if p.Name != "" {
+		args += " -d " + shellQuote(p.Name)
+	}
+	return args
+}
+
+// copyCommand returns the command that copies $src to $dst, skipping the
+// excluded paths of the data directory. rsync's exit status 24 (files
+// vanished during the copy) is expected while the database runs and counts
+// as success.
+func (p *consistentCopyPlan) copyCommand(excludes ...string) string {
+	if p.Copy == CopyReflink {
+		cmd := `rm -rf "$dst" && mkdir -p "$dst" && cp -a --reflink=always "$src/." "$dst/"`
+		for _, e := range excludes {
+			cmd += fmt.Sprintf(` && rm -rf "$dst"/%s`, e)
+		}
+		return "{ " + cmd + "; }"
+	}
+	cmd := `rsync -a --delete`
+	for _, e := range excludes {
+		cmd += " --exclude=" + shellQuote("/"+e)
+	}
+	return `{ ` + cmd + ` "$src/" "$dst/"; rc=$?; [ $rc -eq 24 ] || exit_with $rc; }`
+}
+
+// script returns the shell script that makes the copy. It fails with exit
+// status 124 when the copy does not finish within the timeout.
+func (p *consistentCopyPlan) script() string {
+	var b strings.Builder
+	seconds := int(p.Timeout.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	fmt.Fprintf(&b, "src=%s; dst=%s; db=%s\n", shellQuote(p.DataDir), shellQuote(p.Target), shellQuote(p.Container))
+	b.WriteString("state=$(mktemp -d) || exit 1\n")
+	b.WriteString("exit_with() { return $1; }\n")
+	b.WriteString(`mkdir -p "$dst" || exit 1` + "\n")
+
+	if p.Mode == ConsistentCopyBackupMode {
+		p.backupModeScript(&b, seconds)
+		return b.String()
+	}
+
+	b.WriteString(`trap 'if [ -e "$state/paused" ]; then docker unpause "$db" >/dev/null 2>&1; fi; rm -rf "$state"' EXIT` + "\n")
+	if p.Copy == CopyRsync {
+		// The warm pass leaves only what changes meanwhile for the paused pass.
+		fmt.Fprintf(&b, "%s || exit $?\n", p.copyCommand())
+	}
+	if flush := p.flushCommand(); flush != "" {
+		fmt.Fprintf(&b, "%s >/dev/null 2>&1 || echo \"ciwg: flush failed; the copy is crash-consistent only\" >&2\n", flush)
+	}
+	b.WriteString(`docker pause "$db" || exit 1` + "\n")
+	b.WriteString(`touch "$state/paused"` + "\n")
+	// The watchdog unpauses the container even if this script is killed
+	// before its trap runs.
+	fmt.Fprintf(&b, "setsid sh -c 'sleep %d; [ -e \"$1\" ] && docker unpause \"$2\"' sh \"$state/paused\" \"$db\" >/dev/null 2>&1 </dev/null &\n",
+		seconds+int(consistentCopyGrace/time.Second))
+	fmt.Fprintf(&b, "timeout %d sh -c 'exit_with() { exit $1; }; src=$1; dst=$2; %s' sh \"$src\" \"$dst\"; rc=$?\n", seconds, strings.ReplaceAll(p.copyCommand(), "'", `'\''`))
+	b.WriteString(`if docker unpause "$db"; then rm -f "$state/paused"; else echo "ciwg: failed to unpause $db" >&2; exit 1; fi` + "\n")
+	fmt.Fprintf(&b, "[ $rc -ne 124 ] || echo \"ciwg: the copy did not finish within %ds\" >&2\n", seconds)
+	b.WriteString("exit $rc\n")
+	return b.String()
+}
+
+// backupModeScript writes the backup-mode part of script. A psql session
+// holds the backup open: it is fed pg_backup_start, then pg_backup_stop once
+// $state/stop appears. If the stop never comes, the feed ends after the
+// timeout and PostgreSQL aborts the backup when the session closes.
+func (p *consistentCopyPlan) backupModeScript(b *strings.Builder, seconds int) {
+	ticks := (seconds + int(consistentCopyGrace/time.Second)) * 10
+	b.WriteString(`trap 'touch "$state/stop"; wait; rm -rf "$state"' EXIT` + "\n")
+	if p.Copy == CopyRsync {
+		fmt.Fprintf(b, "%s || exit $?\n", p.copyCommand("pg_wal/*", "postmaster.pid", "postmaster.opts"))
+	}
+	b.WriteString("(\n")
+	b.WriteString(`  echo "SELECT 'ciwg-started:' || pg_backup_start('ciwg-cli', true);"` + "\n")
+	fmt.Fprintf(b, "  i=0; while [ ! -e \"$state/stop\" ] && [ $i -lt %d ]; do sleep 0.1; i=$((i+1)); done\n", ticks)
+	b.WriteString(`  [ -e "$state/stop" ] && echo "WITH s AS (SELECT * FROM pg_backup_stop(false)) SELECT 'ciwg-label:' || translate(encode(convert_to(labelfile, 'UTF8'), 'base64'), E'\n', '') FROM s UNION ALL SELECT 'ciwg-spcmap:' || translate(encode(convert_to(coalesce(spcmapfile, ''), 'UTF8'), 'base64'), E'\n', '') FROM s;"` + "\n")
+	fmt.Fprintf(b, ") | docker exec -i \"$db\" psql -XAtq -v ON_ERROR_STOP=1 %s >\"$state/out\" 2>&1 &\n", p.psqlArgs())
+	b.WriteString("session=$!\n")
+	fmt.Fprintf(b, `await() {
+  i=0
+  until grep -q "^$1" "$state/out"; do
+    if ! kill -0 $session 2>/dev/null; then
+      grep -q "^$1" "$state/out" && return 0
+      echo "ciwg: psql session ended: $(cat "$state/out")" >&2; return 1
+    fi
+    [ $i -lt %d ] || { echo "ciwg: timed out waiting for $1" >&2; return 124; }
+    sleep 0.1; i=$((i+1))
+  done
+}
+`, ticks)
+	b.WriteString("await ciwg-started: || exit $?\n")
+	fmt.Fprintf(b, "timeout %d sh -c 'exit_with() { exit $1; }; src=$1; dst=$2; %s' sh \"$src\" \"$dst\"; rc=$?\n",
+		seconds, strings.ReplaceAll(p.copyCommand("pg_wal/*", "postmaster.pid", "postmaster.opts"), "'", `'\''`))
+	fmt.Fprintf(b, "if [ $rc -ne 0 ]; then [ $rc -ne 124 ] || echo \"ciwg: the copy did not finish within %ds\" >&2; exit $rc; fi\n", seconds)
+	b.WriteString(`touch "$state/stop"` + "\n")
+	b.WriteString("await ciwg-label: || exit $?\n")
+	b.WriteString(`sed -n 's/^ciwg-label://p' "$state/out" | base64 -d >"$dst/backup_label" || exit 1` + "\n")
+	b.WriteString(`spcmap=$(sed -n 's/^ciwg-spcmap://p' "$state/out")` + "\n")
+	b.WriteString(`if [ -n "$spcmap" ]; then printf %s "$spcmap" | base64 -d >"$dst/tablespace_map" || exit 1; else rm -f "$dst/tablespace_map"; fi` + "\n")
+	// The WAL written up to the stop is what makes the copy recoverable.
+	b.WriteString(`src="$src/pg_wal"; dst="$dst/pg_wal"; mkdir -p "$dst" || exit 1` + "\n")
+	fmt.Fprintf(b, "%s || exit $?\n", p.copyCommand())
+}
+
+// consistentCopy copies the data directory of container's database as
+// configured by its consistent_copy.
+func (bm *BackupManager) consistentCopy(container ContainerInfo, options *BackupOptions) error {
+	plan, err := consistentCopyPlanFor(container)
+	if err != nil {
+		return err
+	}
+	if options.DryRun {
+		fmt.Printf("[DRY RUN] Would %s\n", plan)
+		return nil
+	}
+	fmt.Printf("Making a consistent copy of the %s data directory: %s...\n", plan.DBType, plan)
+	started := time.Now()
+	_, stderr, err := bm.executeCommand(plan.script())
+	if err != nil {
+		return fmt.Errorf("consistent copy of %s failed: %w (stderr: %s)", plan.DataDir, err, strings.TrimSpace(stderr))
+	}
+	if s := strings.TrimSpace(stderr); s != "" {
+		fmt.Printf("⚠️  %s\n", s)
+	}
+	fmt.Printf("Data directory copied to %s in %s\n", plan.Target, time.Since(started).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,194 @@
+package backup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsistentCopyConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		cc     ConsistentCopyConfig
+		dbType string
+		ok     bool
+	}{
+		{ConsistentCopyConfig{DataDir: "/srv/db"}, "mysql", true},
+		{ConsistentCopyConfig{DataDir: "/srv/db", Mode: "backup-mode", Timeout: "90s"}, "postgres", true},
+		{ConsistentCopyConfig{DataDir: "/srv/db", Mode: "backup-mode"}, "mysql", false},
+		{ConsistentCopyConfig{DataDir: "/srv/db", Mode: "snapshot"}, "mysql", false},
+		{ConsistentCopyConfig{DataDir: "/srv/db", Copy: "dd"}, "mysql", false},
+		{ConsistentCopyConfig{DataDir: "/srv/db", Timeout: "-1s"}, "mysql", false},
+		{ConsistentCopyConfig{}, "mysql", false},
+	} {
+		if err := tc.cc.Validate(tc.dbType); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v, %s) = %v", tc.cc, tc.dbType, err)
+		}
+	}
+}
+
+func TestConsistentCopyPlanFor(t *testing.T) {
+	container := ContainerInfo{Name: "app", WorkingDir: "/srv/app", Config: &ContainerConfig{
+		Database: DatabaseConfig{Type: "MySQL", Name: "shop", ConsistentCopy: &ConsistentCopyConfig{DataDir: "/srv/app/mysql/"}},
+	}}
+	p, err := consistentCopyPlanFor(container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Target != "/srv/app/shop-datadir" || p.Container != "app" || p.Mode != ConsistentCopyPause || p.Timeout != DefaultConsistentCopyTimeout {
+		t.Errorf("plan = %+v", p)
+	}
+
+	container.Config.Database.ConsistentCopy.Target = "mysql/copy"
+	if _, err := consistentCopyPlanFor(container); err == nil {
+		t.Error("a target inside the data directory was accepted")
+	}
+}
+
+// fakeTools writes docker and rsync stand-ins to a directory and returns a
+// PATH that prefers them. docker logs its calls to dir/docker.log, tracks
+// pause state in dir/paused and answers psql sessions like PostgreSQL
+// would; rsync copies with cp and notes whether the container was paused.
+func fakeTools(t *testing.T, dir string, slowWhenPaused bool) string {
+	t.Helper()
+	bin := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	docker := `#!/bin/bash
+echo "$*" >>` + dir + `/docker.log
+case "$1" in
+pause) touch ` + dir + `/paused ;;
+unpause) rm -f ` + dir + `/paused ;;
+exec)
+  if [ "$2" = -i ]; then
+    while read -r line; do
+      case "$line" in
+      *pg_backup_start*) echo "ciwg-started:0/2000028" ;;
+      *pg_backup_stop*)
+        echo "ciwg-label:$(printf 'START WAL LOCATION: 0/2000028\nLABEL: ciwg-cli\n' | base64 -w0)"
+        echo "ciwg-spcmap:" ;;
+      esac
+    done
+  fi ;;
+esac
+`
+	rsync := `#!/bin/bash
+excludes=()
+args=()
+for a in "$@"; do
+  case "$a" in
+  --exclude=*) excludes+=("${a#--exclude=}") ;;
+  -*) ;;
+  *) args+=("$a") ;;
+  esac
+done
+src=${args[0]}; dst=${args[1]}
+if [ -e ` + dir + `/paused ]; then
+  echo "$src" >>` + dir + `/paused-copies
+  ` + map[bool]string{true: "sleep 3", false: ":"}[slowWhenPaused] + `
+fi
+cp -a "$src." "$dst"
+for e in "${excludes[@]}"; do rm -rf "$dst"${e#/}; done
+`
+	for name, body := range map[string]string{"docker": docker, "rsync": rsync} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(body), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bin + string(os.PathListSeparator) + os.Getenv("PATH")
+}
+
+func runCopyScript(t *testing.T, p *consistentCopyPlan, path string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("bash", "-c", p.script())
+	cmd.Env = append(os.Environ(), "PATH="+path)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConsistentCopyPauseScript(t *testing.T) {
+	dir := t.TempDir()
+	data, target := filepath.Join(dir, "data"), filepath.Join(dir, "site", "db-datadir")
+	writeFiles(t, data, map[string]string{"ibdata1": "pages", "shop/orders.ibd": "orders"})
+	p := &consistentCopyPlan{Mode: ConsistentCopyPause, Copy: CopyRsync, DBType: "mysql", Container: "db", User: "root",
+		DataDir: data, Target: target, Timeout: 5 * time.Second}
+
+	if out, err := runCopyScript(t, p, fakeTools(t, dir, false)); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	if got, _ := os.ReadFile(filepath.Join(target, "shop", "orders.ibd")); string(got) != "orders" {
+		t.Errorf("copied orders.ibd = %q", got)
+	}
+	log, _ := os.ReadFile(filepath.Join(dir, "docker.log"))
+	if want := "exec db mysql -u root -e FLUSH TABLES\npause db\nunpause db\n"; string(log) != want {
+		t.Errorf("docker calls = %q, want %q", log, want)
+	}
+	if copies, _ := os.ReadFile(filepath.Join(dir, "paused-copies")); strings.Count(string(copies), "\n") != 1 {
+		t.Errorf("paused copies = %q, want one pass while paused", copies)
+	}
+}
+
+func TestConsistentCopyPauseScriptTimeout(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	writeFiles(t, data, map[string]string{"base/1": "x"})
+	p := &consistentCopyPlan{Mode: ConsistentCopyPause, Copy: CopyRsync, DBType: "mongodb", Container: "db",
+		DataDir: data, Target: filepath.Join(dir, "copy"), Timeout: time.Second}
+
+	out, err := runCopyScript(t, p, fakeTools(t, dir, true))
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 124 || !strings.Contains(out, "did not finish within 1s") {
+		t.Fatalf("script = %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "paused")); err == nil {
+		t.Error("container left paused after the timeout")
+	}
+}
+
+func TestConsistentCopyBackupModeScript(t *testing.T) {
+	dir := t.TempDir()
+	data, target := filepath.Join(dir, "pgdata"), filepath.Join(dir, "site", "pg-datadir")
+	writeFiles(t, data, map[string]string{
+		"base/1/1259":                       "catalog",
+		"pg_wal/000000010000000000000002":   "wal",
+		"postmaster.pid":                    "42",
+		"global/pg_control":                 "control",
+		"pg_wal/archive_status/placeholder": "",
+	})
+	p := &consistentCopyPlan{Mode: ConsistentCopyBackupMode, Copy: CopyRsync, DBType: "postgres", Container: "pg", User: "postgres", Name: "app",
+		DataDir: data, Target: target, Timeout: 5 * time.Second}
+
+	if out, err := runCopyScript(t, p, fakeTools(t, dir, false)); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	label, _ := os.ReadFile(filepath.Join(target, "backup_label"))
+	if !strings.HasPrefix(string(label), "START WAL LOCATION: 0/2000028\n") {
+		t.Errorf("backup_label = %q", label)
+	}
+	if got, _ := os.ReadFile(filepath.Join(target, "pg_wal", "000000010000000000000002")); string(got) != "wal" {
+		t.Errorf("WAL segment = %q", got)
+	}
+	for _, name := range []string{"postmaster.pid", "tablespace_map"} {
+		if _, err := os.Stat(filepath.Join(target, name)); err == nil {
+			t.Errorf("%s was copied", name)
+		}
+	}
+	if log, _ := os.ReadFile(filepath.Join(dir, "docker.log")); strings.Contains(string(log), "pause") {
+		t.Errorf("backup mode paused the container: %q", log)
+	}
+}
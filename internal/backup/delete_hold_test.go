@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDeleteHold(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	// Age goes by the timestamp in the name: the old backup was written (or
+	// copied) just now, the young one's file is backdated.
+	old := "backups/a.com/a-" + InTimezone(time.Now().Add(-72*time.Hour)).Format("20060102-150405") + ".tgz"
+	young := "backups/a.com/a-" + InTimezone(time.Now().Add(-time.Hour)).Format("20060102-150405") + ".tgz"
+	putTestObject(t, bm, old, "old")
+	putTestObject(t, bm, young, "young")
+	putTestObject(t, bm, stateObjectPrefix+"state.json", "{}")
+	aged := time.Now().Add(-72 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, young), aged, aged); err != nil {
+		t.Fatal(err)
+	}
+
+	SetDeleteHold(DefaultDeleteHold)
+	t.Cleanup(func() { SetDeleteHold(0) })
+
+	err := bm.DeleteObjects([]string{old, young, stateObjectPrefix + "state.json", "backups/a.com/gone.tgz"})
+	var hold *DeleteHoldError
+	if !errors.As(err, &hold) || !errors.Is(err, ErrDeleteHold) || len(hold.Held) != 1 || hold.Held[0].Key != young {
+		t.Fatalf("DeleteObjects() error = %v, want only %s held", err, young)
+	}
+	if keys := listKeys(t, bm); !slices.Equal(keys, []string{young}) {
+		t.Errorf("after DeleteObjects() = %v, want only the young backup left", keys)
+	}
+	if err := bm.DeleteObject(young); !errors.Is(err, ErrDeleteHold) {
+		t.Errorf("DeleteObject(young) error = %v", err)
+	}
+
+	SetDeleteHold(0)
+	if err := bm.DeleteObject(young); err != nil {
+		t.Errorf("DeleteObject(young) without a hold error = %v", err)
+	}
+}
+
+func TestDeleteHoldSkipsReplicaPropagation(t *testing.T) {
+	primary, _ := newFileBackedManager(t)
+	replica, _ := newFileBackedManager(t)
+	for _, bm := range []*BackupManager{primary, replica} {
+		if err := bm.initMinioClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Replicated just now, but the backup itself is old enough to delete.
+	key := "backups/a.com/a-" + InTimezone(time.Now().Add(-72*time.Hour)).Format("20060102-150405") + ".tgz"
+	putTestObject(t, primary, key, "x")
+	putTestObject(t, replica, key, "x")
+	primary.SetReplica("dr", replica, true)
+
+	SetDeleteHold(DefaultDeleteHold)
+	t.Cleanup(func() { SetDeleteHold(0) })
+	if err := primary.DeleteObjects([]string{key}); err != nil {
+		t.Fatalf("DeleteObjects() error = %v", err)
+	}
+	if keys := listKeys(t, replica); len(keys) != 0 {
+		t.Errorf("replica still holds %v", keys)
+	}
+
+	// A propagated deletion is not held again on the replica.
+	young := "backups/a.com/a-" + InTimezone(time.Now()).Format("20060102-150405") + ".tgz"
+	putTestObject(t, replica, young, "y")
+	if err := primary.propagateDeletions([]string{young}); err != nil {
+		t.Errorf("propagateDeletions() error = %v", err)
+	}
+}
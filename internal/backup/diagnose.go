@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Failure codes assigned by ClassifyBackupError. They are stable so cron
+// wrappers and dashboards can match on them.
+const (
+	FailureUnknown            = "unknown"
+	FailureWPCLIMissing       = "wpcli_missing"
+	FailureDBCredentials      = "db_credentials"
+	FailureDBUnreachable      = "db_unreachable"
+	FailureDiskFull           = "disk_full"
+	FailureContainerDown      = "container_not_running"
+	FailurePermissionDenied   = "permission_denied"
+	FailureSSHConnection      = "ssh_connection"
+	FailureStorageAuth        = "storage_auth"
+	FailureStorageBucket      = "storage_bucket_missing"
+	FailureStorageUnreachable = "storage_unreachable"
+	FailureFileChanged        = "files_changed_during_backup"
+	FailureTimeout            = "timeout"
+	FailureCapacityExceeded   = "capacity_exceeded"
+	FailureGzipRsyncable      = "gzip_rsyncable_unsupported"
+	FailureResidency          = "residency_violation"
+)
+
+// FailureDiagnosis is an actionable explanation of a backup failure.
+type FailureDiagnosis struct {
+	Code        string `json:"code"`
+	Summary     string `json:"summary"`
+	Remediation string `json:"remediation"`
+}
+
+// ContainerFailure records one failed container in the run history.
+type ContainerFailure struct {
+	Container string `json:"container"`
+	// Site is the object key of the container's site.
+	Site        string `json:"site,omitempty"`
+	Code        string `json:"code"`
+	Error       string `json:"error"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// failureSignature maps an error message pattern to a diagnosis. Signatures
+// are checked in order, so more specific patterns come first.
+type failureSignature struct {
+	pattern   *regexp.Regexp
+	diagnosis FailureDiagnosis
+}
+
+var bucketMissing = FailureDiagnosis{FailureStorageBucket, "The backup bucket does not exist",
+	"Create the bucket or fix --minio-bucket / MINIO_BUCKET"}
+
+// typedFailures diagnoses errors that wrap one of the package's sentinel
+// errors; they are checked before the message signatures.
+var typedFailures = []struct {
+	target    error
+	diagnosis FailureDiagnosis
+}{
+	{ErrBucketNotFound, bucketMissing},
+	{ErrStageTimeout, FailureDiagnosis{FailureTimeout, "A backup stage ran past its timeout and was aborted",
+		"Look for a wedged wp-cli or database in the container (docker top), or raise --export-timeout, --tar-timeout, --upload-timeout or --container-timeout"}},
+	{ErrResidencyViolation, FailureDiagnosis{FailureResidency, "The destination is outside the site's data residency",
+		"Point the backup at storage in one of the site's allowed regions or endpoints (residency in the fleet file), or pass --residency-override with a reason"}},
+	{ErrCapacityExceeded, FailureDiagnosis{FailureCapacityExceeded, "Storage usage is above the capacity threshold",
+		"Run 'backup monitor' to migrate old backups to Glacier, or raise --capacity-threshold"}},
+}
+
+var failureSignatures = []failureSignature{
+	{
+		regexp.MustCompile(`(?i)no space left on device|disk full|disk quota exceeded`),
+		FailureDiagnosis{FailureDiskFull, "Out of disk space",
+			"Free space on the host (docker system prune, old /tmp exports) or move Minio data to a volume with room; 'backup estimate-capacity' shows how much a run needs"},
+	},
+	{
+		regexp.MustCompile(`(?i)gzip: (unrecognized|invalid|unknown) option.*rsyncable`),
+		FailureDiagnosis{FailureGzipRsyncable, "The host's gzip cannot write rsyncable output",
+			"Install GNU gzip 1.7+ on the host (BusyBox gzip lacks --rsyncable), or drop +rsyncable from --minio-compression"},
+	},
+	{
+		regexp.MustCompile(`(?i)wp: (command )?not found|"wp": executable file not found|wp-cli.*not (found|installed)`),
+		FailureDiagnosis{FailureWPCLIMissing, "wp-cli is not available in the container",
+			"Install wp-cli in the image (or mount /usr/local/bin/wp), or set a custom database export command in the container config"},
+	},
+	{
+		regexp.MustCompile(`(?i)access denied for user|error 1045|error establishing a database connection|authentication plugin .* cannot be loaded`),
+		FailureDiagnosis{FailureDBCredentials, "Database credentials were rejected",
+			"Check DB_USER/DB_PASSWORD in wp-config.php or the container environment match the database, then retry with -vv to see the export command"},
+	},
+	{
+		regexp.MustCompile(`(?i)can't connect to (local )?mysql server|unknown mysql server host|error 2002|error 2003|error 2005|lost connection to mysql server`),
+		FailureDiagnosis{FailureDBUnreachable, "The database server could not be reached",
+			"Make sure the database container is running and on the same Docker network, and that DB_HOST in wp-config.php is correct"},
+	},
+	{
+		regexp.MustCompile(`(?i)no running container|container not found|no such container|is not running|is restarting`),
+		FailureDiagnosis{FailureContainerDown, "The container is not running",
+			"Start the container (docker compose up -d in its working directory) or remove it from the container list"},
+	},
+	{
+		regexp.MustCompile(`(?i)file changed as we read it|file shrank by`),
+		FailureDiagnosis{FailureFileChanged, "Files changed while the archive was being written",
+			"Schedule the backup outside busy hours (--blackout) or exclude cache/log directories that are written continuously"},
+	},
+	{
+		regexp.MustCompile(`(?i)permission denied|operation not permitted`),
+		FailureDiagnosis{FailurePermissionDenied, "Permission denied reading files or running commands",
+			"Run as a user in the docker group with read access to the site directory, or fix ownership of the files tar could not read"},
+	},
+	{
+		regexp.MustCompile(`(?i)invalidaccesskeyid|signaturedoesnotmatch|the access key id you provided|accessdenied`),
+		FailureDiagnosis{FailureStorageAuth, "Object storage rejected the credentials",
+			"Check MINIO_ACCESS_KEY/MINIO_SECRET_KEY (or AWS credentials) and the bucket policy; 'backup test-minio' reproduces the check"},
+	},
+	{
+		regexp.MustCompile(`(?i)nosuchbucket|bucket .* does not exist`),
+		bucketMissing,
+	},
+	{
+		regexp.MustCompile(`(?i)ssh: handshake failed|unable to authenticate|failed to create ssh session|ssh: connect|connection reset by peer`),
+		FailureDiagnosis{FailureSSHConnection, "The SSH connection failed",
+			"Check the host is reachable and the key/agent is authorized (ssh -v <host>), and consider raising --timeout"},
+	},
+	{
+		regexp.MustCompile(`(?i)failed to upload to minio.*(connection refused|no such host|tls|certificate)|dial tcp.*(connection refused|no such host)`),
+		FailureDiagnosis{FailureStorageUnreachable, "Object storage could not be reached",
+			"Check --minio-endpoint, TLS settings (--minio-ca-bundle) and firewall rules, or tunnel through SSH with --minio-via-ssh"},
+	},
+	{
+		regexp.MustCompile(`(?i)deadline exceeded|i/o timeout|timed out`),
+		FailureDiagnosis{FailureTimeout, "An operation timed out",
+			"Raise --timeout / --minio-http-timeout, or check for network congestion between the host and storage"},
+	},
+}
+
+// ClassifyBackupError maps err to a diagnosis using known failure signatures.
+// Unrecognized errors get FailureUnknown with a generic hint.
+func ClassifyBackupError(err error) FailureDiagnosis {
+	if err == nil {
+		return FailureDiagnosis{}
+	}
+	for _, tf := range typedFailures {
+		if errors.Is(err, tf.target) {
+			return tf.diagnosis
+		}
+	}
+	msg := err.Error()
+	for _, sig := range failureSignatures {
+		if sig.pattern.MatchString(msg) {
+			return sig.diagnosis
+		}
+	}
+	return FailureDiagnosis{
+		Code:        FailureUnknown,
+		Summary:     "Unrecognized failure",
+		Remediation: "Re-run this container with -vvv to see the commands being executed and their output",
+	}
+}
+
+// BackupFailureNotice is the payload posted to the failure webhook after a
+// run with failed containers.
+type BackupFailureNotice struct {
+	RunID     string             `json:"run_id"`
+	Host      string             `json:"host"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Failures  []ContainerFailure `json:"failures"`
+	Time      time.Time          `json:"time"`
+}
+
+// SendFailureNotice posts the failures recorded in rec to url.
+func SendFailureNotice(url string, rec *RunRecord) error {
+	return postJSON(url, &BackupFailureNotice{
+		RunID:     rec.ID,
+		Host:      rec.Host,
+		Succeeded: rec.Succeeded,
+		Failed:    rec.Failed,
+		Failures:  rec.Failures,
+		Time:      time.Now().UTC(),
+	})
+}
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifyBackupError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want string
+	}{
+		{"failed to export database: Process exited with status 127 (stderr: sh: wp: not found)", FailureWPCLIMissing},
+		{`failed to export database: exec: "wp": executable file not found in $PATH`, FailureWPCLIMissing},
+		{"database export failed: exit status 1 (stderr: mysqldump: Got error: 1045: Access denied for user 'wp'@'172.18.0.3')", FailureDBCredentials},
+		{"failed to export database: exit status 1 (stderr: Error establishing a database connection.)", FailureDBCredentials},
+		{"failed to export database: (stderr: ERROR 2002 (HY000): Can't connect to local MySQL server through socket)", FailureDBUnreachable},
+		{"tar command failed: exit status 2 (remote stderr: tar: write error: No space left on device)", FailureDiskFull},
+		{"failed to buffer data to temporary file (disk full): short write", FailureDiskFull},
+		{"no running container found for directory 'foo'", FailureContainerDown},
+		{"tar command failed: exit status 1 (remote stderr: tar: ./wp-content/cache/x: file changed as we read it)", FailureFileChanged},
+		{"tar command failed: exit status 2 (remote stderr: tar: ./wp-config.php: Cannot open: Permission denied)", FailurePermissionDenied},
+		{"tar command failed: exit status 1 (remote stderr: gzip: unrecognized option '--rsyncable')", FailureGzipRsyncable},
+		{"failed to upload to Minio: The Access Key Id you provided does not exist in our records.", FailureStorageAuth},
+		{"failed to upload to Minio: NoSuchBucket: The specified bucket does not exist", FailureStorageBucket},
+		{"failed to create SSH session: ssh: handshake failed: EOF", FailureSSHConnection},
+		{"failed to upload to Minio: dial tcp 10.0.0.5:9000: connect: connection refused", FailureStorageUnreachable},
+		{"failed to upload to Minio: context deadline exceeded", FailureTimeout},
+		{"something nobody has seen before", FailureUnknown},
+	}
+	for _, tt := range tests {
+		got := ClassifyBackupError(errors.New(tt.err))
+		if got.Code != tt.want {
+			t.Errorf("ClassifyBackupError(%q) = %s, want %s", tt.err, got.Code, tt.want)
+		}
+		if got.Remediation == "" {
+			t.Errorf("ClassifyBackupError(%q) has no remediation", tt.err)
+		}
+	}
+	if got := ClassifyBackupError(nil); got.Code != "" {
+		t.Errorf("ClassifyBackupError(nil) = %+v, want zero value", got)
+	}
+}
+
+func TestSendFailureNotice(t *testing.T) {
+	var got BackupFailureNotice
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	rec := &RunRecord{
+		ID:        "run-1",
+		Host:      "wp0.example.com",
+		Succeeded: 3,
+		Failed:    1,
+		Failures:  []ContainerFailure{{Container: "wp_foo", Code: FailureDiskFull, Error: "no space left on device"}},
+	}
+	if err := SendFailureNotice(srv.URL, rec); err != nil {
+		t.Fatalf("SendFailureNotice() error = %v", err)
+	}
+	if got.RunID != "run-1" || got.Host != "wp0.example.com" || len(got.Failures) != 1 || got.Failures[0].Code != FailureDiskFull {
+		t.Errorf("webhook received %+v", got)
+	}
+}
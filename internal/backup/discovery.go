@@ -0,0 +1,202 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Container discovery modes for BackupOptions.Discovery.
+const (
+	// DiscoveryCompose finds sites by docker compose project labels and backs
+	// up each WordPress stack once, whatever its containers are named.
+	DiscoveryCompose = "compose"
+	// DiscoveryPrefix finds sites by the legacy wp_ container name prefix.
+	DiscoveryPrefix = "prefix"
+)
+
+// Compose service roles assigned by composeServiceRole.
+const (
+	RoleWordPress = "wordpress"
+	RoleDatabase  = "database"
+	RoleCache     = "cache"
+	RoleCron      = "cron"
+	RoleOther     = "other"
+)
+
+// composeContainer is a running container with its compose labels.
+type composeContainer struct {
+	Name       string
+	Image      string
+	Project    string
+	Service    string
+	WorkingDir string
+}
+
+// composePSFormat lists running containers with the labels used for grouping.
+const composePSFormat = `{{.Names}}\t{{.Image}}\t{{.Label "com.docker.compose.project"}}\t{{.Label "com.docker.compose.service"}}\t{{.Label "com.docker.compose.project.working_dir"}}`
+
+// parseComposePS parses `docker ps --format composePSFormat` output.
+func parseComposePS(output string) []composeContainer {
+	var containers []composeContainer
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		for len(f) < 5 {
+			f = append(f, "")
+		}
+		containers = append(containers, composeContainer{
+			Name:       strings.TrimSpace(f[0]),
+			Image:      strings.TrimSpace(f[1]),
+			Project:    strings.TrimSpace(f[2]),
+			Service:    strings.TrimSpace(f[3]),
+			WorkingDir: strings.TrimSpace(f[4]),
+		})
+	}
+	return containers
+}
+
+// composeServiceRole classifies a container by its image, compose service name
+// and container name.
+func composeServiceRole(c composeContainer) string {
+	image := strings.ToLower(c.Image)
+	service := strings.ToLower(c.Service)
+	name := strings.ToLower(c.Name)
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(service, w) || strings.Contains(image, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("cron"):
+		return RoleCron
+	case has("mysql", "mariadb", "postgres", "percona"):
+		return RoleDatabase
+	case has("redis", "memcached", "valkey"):
+		return RoleCache
+	case has("wordpress") || service == "wp" || strings.HasPrefix(name, "wp_"):
+		return RoleWordPress
+	}
+	return RoleOther
+}
+
+// groupComposeStacks groups containers by compose project and returns one
+// ContainerInfo per project that runs WordPress. The WordPress container is
+// used for database exports; the rest of the stack is listed in Services.
+// Containers outside compose or without a working directory are returned in
+// skipped when they look like WordPress, unless overrides assigns them a
+// site; an overridden container outside compose becomes a site of its own.
+func groupComposeStacks(containers []composeContainer, overrides ContainerOverrides) (sites []ContainerInfo, skipped []string) {
+	isSite := func(c composeContainer) bool {
+		_, ok := overrides[c.Name]
+		return ok || composeServiceRole(c) == RoleWordPress
+	}
+	projects := make(map[string][]composeContainer)
+	for _, c := range containers {
+		if o, ok := overrides[c.Name]; ok {
+			c.WorkingDir = o.WorkingDir
+			if c.Project == "" {
+				c.Project = c.Name
+			}
+		}
+		if c.Project == "" || c.WorkingDir == "" {
+			if composeServiceRole(c) == RoleWordPress {
+				skipped = append(skipped, c.Name)
+			}
+			continue
+		}
+		// Projects with the same name in different directories are different sites.
+		key := c.Project + "\x00" + c.WorkingDir
+		projects[key] = append(projects[key], c)
+	}
+
+	for _, members := range projects {
+		var primary *composeContainer
+		for i := range members {
+			c := &members[i]
+			if !isSite(*c) {
+				continue
+			}
+			if primary == nil || preferPrimary(*c, *primary) {
+				primary = c
+			}
+		}
+		if primary == nil {
+			continue
+		}
+		site := overrides.apply(ContainerInfo{
+			Name:       primary.Name,
+			WorkingDir: primary.WorkingDir,
+			Type:       "wordpress",
+			Project:    primary.Project,
+		})
+		for _, c := range members {
+			if c.Name != primary.Name {
+				site.Services = append(site.Services, c.Name)
+			}
+		}
+		sort.Strings(site.Services)
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Project != sites[j].Project {
+			return sites[i].Project < sites[j].Project
+		}
+		return sites[i].WorkingDir < sites[j].WorkingDir
+	})
+	return sites, skipped
+}
+
+// preferPrimary reports whether a is a better database-export target than b
+// when a stack has several WordPress-looking containers: the "wordpress"
+// service wins, then wp_-named containers, then the lowest name.
+func preferPrimary(a, b composeContainer) bool {
+	rank := func(c composeContainer) int {
+		switch {
+		case c.Service == "wordpress":
+			return 0
+		case strings.HasPrefix(c.Name, "wp_"):
+			return 1
+		}
+		return 2
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra < rb
+	}
+	return a.Name < b.Name
+}
+
+// DiscoverComposeStacks finds WordPress sites by compose project labels and
+// container overrides. WordPress containers that could not be assigned a
+// working directory are returned in skipped.
+func (bm *BackupManager) DiscoverComposeStacks() (sites []ContainerInfo, skipped []string, err error) {
+	cmd := fmt.Sprintf(`docker ps --format '%s'`, composePSFormat)
+	output, stderr, err := bm.executeCommand(cmd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list containers: %w (stderr: %s)", err, stderr)
+	}
+	sites, skipped = groupComposeStacks(parseComposePS(output), bm.containerOverrides)
+	return sites, skipped, nil
+}
+
+// discoverComposeStacks finds WordPress sites by compose project labels.
+func (bm *BackupManager) discoverComposeStacks() ([]ContainerInfo, error) {
+	sites, skipped, err := bm.DiscoverComposeStacks()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range skipped {
+		fmt.Printf("Warning: %s is not part of a docker compose project; map it to a working directory in the container overrides file (see backup discover --write-overrides)\n", name)
+	}
+	for _, site := range sites {
+		if len(site.Services) > 0 {
+			bm.logVerbose("Discovered %s in %s with %s", site.Name, site.WorkingDir, strings.Join(site.Services, ", "))
+		}
+	}
+	return sites, nil
+}
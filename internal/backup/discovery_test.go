@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupComposeStacks(t *testing.T) {
+	ps := strings.Join([]string{
+		"wp_foo\twordpress:6.4-php8.2\tfoo\twordpress\t/var/opt/sites/foo",
+		"foo-redis-1\tredis:7\tfoo\tredis\t/var/opt/sites/foo",
+		"foo-cron-1\twordpress:cli\tfoo\tcron\t/var/opt/sites/foo",
+		"shop-app-1\tghcr.io/acme/wordpress:latest\tshop\tapp\t/var/opt/sites/shop",
+		"shop-db-1\tmariadb:11\tshop\tdb\t/var/opt/sites/shop",
+		"minio\tminio/minio\tstorage\tminio\t/opt/minio",
+		"wp_legacy\twordpress\t\t\t",
+	}, "\n") + "\n"
+
+	sites, skipped := groupComposeStacks(parseComposePS(ps), nil)
+	want := []ContainerInfo{
+		{Name: "wp_foo", WorkingDir: "/var/opt/sites/foo", Type: "wordpress", Project: "foo", Services: []string{"foo-cron-1", "foo-redis-1"}},
+		{Name: "shop-app-1", WorkingDir: "/var/opt/sites/shop", Type: "wordpress", Project: "shop", Services: []string{"shop-db-1"}},
+	}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("groupComposeStacks() sites =\n%+v\nwant\n%+v", sites, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"wp_legacy"}) {
+		t.Errorf("groupComposeStacks() skipped = %v, want [wp_legacy]", skipped)
+	}
+}
+
+func TestComposeServiceRole(t *testing.T) {
+	tests := []struct {
+		c    composeContainer
+		want string
+	}{
+		{composeContainer{Name: "wp_foo", Image: "custom/php-fpm"}, RoleWordPress},
+		{composeContainer{Name: "x", Image: "wordpress:6", Service: "web"}, RoleWordPress},
+		{composeContainer{Name: "x", Image: "wordpress:cli", Service: "cron"}, RoleCron},
+		{composeContainer{Name: "x", Image: "mysql:8", Service: "db"}, RoleDatabase},
+		{composeContainer{Name: "x", Image: "valkey/valkey", Service: "cache"}, RoleCache},
+		{composeContainer{Name: "x", Image: "nginx", Service: "proxy"}, RoleOther},
+	}
+	for _, tt := range tests {
+		if got := composeServiceRole(tt.c); got != tt.want {
+			t.Errorf("composeServiceRole(%+v) = %s, want %s", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestGroupComposeStacksPrefersWordPressService(t *testing.T) {
+	sites, _ := groupComposeStacks([]composeContainer{
+		{Name: "wp_blog_cli", Image: "wordpress:cli", Project: "blog", Service: "cli", WorkingDir: "/srv/blog"},
+		{Name: "blog-wordpress-1", Image: "wordpress", Project: "blog", Service: "wordpress", WorkingDir: "/srv/blog"},
+	}, nil)
+	if len(sites) != 1 || sites[0].Name != "blog-wordpress-1" {
+		t.Errorf("groupComposeStacks() = %+v, want blog-wordpress-1 as the primary container", sites)
+	}
+}
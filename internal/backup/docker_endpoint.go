@@ -0,0 +1,118 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultDockerHelperImage runs host commands on Docker endpoints; it only
+// needs chroot.
+const DefaultDockerHelperImage = "alpine:3"
+
+// DockerEndpoint reaches a host through its Docker API instead of SSH, for
+// hosts that expose the API (TLS on 2376) but no shell.
+//
+// Every command the backup would run on the host over SSH (docker ps and
+// inspect for discovery, docker exec database exports, tar of the site
+// directories) runs in a throwaway helper container started over the API
+// with the host filesystem mounted and chrooted into, so it sees the same
+// paths, binaries and Docker socket as an SSH session. Its output streams
+// back over the attach connection, and its logging is disabled so archives
+// don't land in the daemon's container logs.
+type DockerEndpoint struct {
+	// Host is the daemon address, e.g. tcp://wp3.example.com:2376.
+	Host string
+	// Context names a docker context to use instead of Host.
+	Context string
+	// TLSVerify verifies the daemon's certificate against the CA in CertPath.
+	TLSVerify bool
+	// CertPath holds ca.pem, cert.pem and key.pem (default: ~/.docker).
+	CertPath string
+	// HelperImage is the image of the helper container.
+	HelperImage string
+}
+
+// NewDockerEndpoint returns the endpoint of hostname's Docker API on port.
+func NewDockerEndpoint(hostname string, port int) *DockerEndpoint {
+	return &DockerEndpoint{Host: fmt.Sprintf("tcp://%s:%d", hostname, port), TLSVerify: true}
+}
+
+// Name identifies the endpoint in run records, traces and pending uploads:
+// the daemon's hostname, or the context name.
+func (d *DockerEndpoint) Name() string {
+	if d.Context != "" {
+		return d.Context
+	}
+	name := d.Host
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// env returns the environment of the local docker CLI talking to d.
+func (d *DockerEndpoint) env() []string {
+	env := os.Environ()
+	if d.Context != "" {
+		return append(env, "DOCKER_CONTEXT="+d.Context)
+	}
+	env = append(env, "DOCKER_HOST="+d.Host)
+	if d.TLSVerify {
+		env = append(env, "DOCKER_TLS_VERIFY=1")
+	} else {
+		env = append(env, "DOCKER_TLS_VERIFY=")
+	}
+	if d.CertPath != "" {
+		env = append(env, "DOCKER_CERT_PATH="+d.CertPath)
+	}
+	return env
+}
+
+// Command returns the local command running shellCmd on the host under
+// bash -lc, like an SSH session would. Its stdin, stdout and stderr are
+// attached to the helper container.
+func (d *DockerEndpoint) Command(shellCmd string) *exec.Cmd {
+	image := d.HelperImage
+	if image == "" {
+		image = DefaultDockerHelperImage
+	}
+	c := exec.Command("docker", "run", "--rm", "-i",
+		"--log-driver", "none",
+		"--network", "host",
+		"-v", "/:/host",
+		image, "chroot", "/host", "bash", "-lc", shellCmd)
+	c.Env = d.env()
+	return c
+}
+
+// SetDockerEndpoint makes bm run its host commands on d instead of locally.
+// It is used without an SSH client.
+func (bm *BackupManager) SetDockerEndpoint(d *DockerEndpoint) {
+	bm.dockerEndpoint = d
+}
+
+// shellCommand returns the local command running cmd on the host bm works
+// on: the Docker endpoint's helper container, or bash on this machine.
+func (bm *BackupManager) shellCommand(cmd string) *exec.Cmd {
+	if bm.dockerEndpoint != nil {
+		return bm.dockerEndpoint.Command(cmd)
+	}
+	return exec.Command("bash", "-lc", cmd)
+}
+
+// remoteHost names the host bm runs commands on, or "" when it is this
+// machine.
+func (bm *BackupManager) remoteHost() string {
+	switch {
+	case bm.sshClient != nil:
+		return bm.sshClient.GetHostname()
+	case bm.dockerEndpoint != nil:
+		return bm.dockerEndpoint.Name()
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDockerEndpointName(t *testing.T) {
+	ep := NewDockerEndpoint("wp3.example.com", 2376)
+	if ep.Host != "tcp://wp3.example.com:2376" || ep.Name() != "wp3.example.com" {
+		t.Fatalf("NewDockerEndpoint() = %+v, name %q", ep, ep.Name())
+	}
+	ep.Context = "wp3"
+	if ep.Name() != "wp3" {
+		t.Errorf("Name() with a context = %q", ep.Name())
+	}
+}
+
+func TestDockerEndpointCommand(t *testing.T) {
+	ep := &DockerEndpoint{Host: "tcp://wp3.example.com:2376", TLSVerify: true, CertPath: "/certs"}
+	c := ep.Command("docker ps -q")
+	if c.Args[0] != "docker" || c.Args[len(c.Args)-1] != "docker ps -q" || !slices.Contains(c.Args, DefaultDockerHelperImage) {
+		t.Errorf("Command() args = %q", c.Args)
+	}
+	if !slices.Contains(c.Args, "none") {
+		t.Errorf("Command() args = %q, want container logging disabled", c.Args)
+	}
+	for _, want := range []string{"DOCKER_HOST=tcp://wp3.example.com:2376", "DOCKER_TLS_VERIFY=1", "DOCKER_CERT_PATH=/certs"} {
+		if !slices.Contains(c.Env, want) {
+			t.Errorf("Command() env lacks %s", want)
+		}
+	}
+
+	bm := NewBackupManager(nil, nil)
+	if got := bm.shellCommand("true").Args; got[0] != "bash" || bm.remoteHost() != "" {
+		t.Errorf("shellCommand() without endpoint = %q", got)
+	}
+	bm.SetDockerEndpoint(ep)
+	if got := bm.shellCommand("true").Args; got[0] != "docker" || bm.remoteHost() != "wp3.example.com" {
+		t.Errorf("shellCommand() with endpoint = %q, host %q", got, bm.remoteHost())
+	}
+}
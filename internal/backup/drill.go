@@ -0,0 +1,290 @@
+package backup
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Recovery drill defaults, used when neither the flags nor the fleet file's
+// drill section set them.
+const (
+	DefaultDrillEvery = 7 * 24 * time.Hour
+	DefaultDrillSites = 3
+	// DefaultDrillGrace is how long past its schedule a drill may be before
+	// it counts as overdue.
+	DefaultDrillGrace = 24 * time.Hour
+)
+
+// DrillSchedule is the drill section of the fleet file.
+type DrillSchedule struct {
+	Every string `yaml:"every,omitempty"`
+	Sites int    `yaml:"sites,omitempty"`
+}
+
+// validateDrill checks the drill section of the fleet file.
+func (f *Fleet) validateDrill() error {
+	if f.Drill == nil {
+		return nil
+	}
+	if f.Drill.Every != "" {
+		if _, err := ParseObjective(f.Drill.Every); err != nil {
+			return fmt.Errorf("drill: every: %w", err)
+		}
+	}
+	if f.Drill.Sites < 0 {
+		return fmt.Errorf("drill: sites must not be negative")
+	}
+	return nil
+}
+
+// DrillResult is the outcome of the restore drill of one site.
+type DrillResult struct {
+	Site      string  `json:"site"`
+	ObjectKey string  `json:"object_key"`
+	Seconds   float64 `json:"seconds"`
+	Success   bool    `json:"success"`
+	// Files is how many regular files the restore produced.
+	Files int `json:"files"`
+	// Issues lists what went wrong, or notes on what was not checked.
+	Issues []string `json:"issues,omitempty"`
+}
+
+// DrillOptions controls RunDrill.
+type DrillOptions struct {
+	Prefix string
+	// Sites is how many sites to drill, picked at random.
+	Sites int
+	// SandboxDir holds the throwaway restores (default: os.TempDir()).
+	SandboxDir string
+	// KeepSandbox leaves the restored files for inspection.
+	KeepSandbox bool
+	// Rand picks the sites; nil seeds from the clock.
+	Rand *rand.Rand
+}
+
+// DrillStatus summarises the drills recorded in the run history.
+type DrillStatus struct {
+	LastDrill   time.Time `json:"last_drill,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	NextDue     time.Time `json:"next_due"`
+	Due         bool      `json:"due"`
+	Overdue     bool      `json:"overdue"`
+}
+
+// DrillStatusOf returns when drills last ran and succeeded, and whether the
+// next one is due (every has passed since the last drill) or overdue (no
+// successful drill within every plus grace).
+func DrillStatusOf(history []RunRecord, every, grace time.Duration, now time.Time) DrillStatus {
+	var s DrillStatus
+	for _, rec := range history {
+		if rec.Kind != RunKindDrill {
+			continue
+		}
+		if rec.StartedAt.After(s.LastDrill) {
+			s.LastDrill = rec.StartedAt
+		}
+		if rec.Failed == 0 && rec.Succeeded > 0 && rec.StartedAt.After(s.LastSuccess) {
+			s.LastSuccess = rec.StartedAt
+		}
+	}
+	if !s.LastDrill.IsZero() {
+		s.NextDue = s.LastDrill.Add(every)
+	}
+	s.Due = !now.Before(s.NextDue)
+	s.Overdue = s.LastSuccess.IsZero() || now.Sub(s.LastSuccess) > every+grace
+	return s
+}
+
+// RunDrill restores the latest complete backup of randomly picked sites into
+// a sandbox directory, without starting anything, and checks that the files
+// and database dump came back. The returned record (Kind RunKindDrill) is
+// meant for the run history; it counts one success or failure per site.
+func (bm *BackupManager) RunDrill(opts DrillOptions) (*RunRecord, error) {
+	started := time.Now()
+	objs, err := bm.ListBackups(opts.Prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	bySite := map[string][]ObjectInfo{}
+	for _, o := range objs {
+		if !isInternalObject(o.Key) {
+			site := ObjectSite(o.Key)
+			bySite[site] = append(bySite[site], o)
+		}
+	}
+	candidates := map[string]BackupSet{}
+	var sites []string
+	for site, objs := range bySite {
+		if set, ok := latestCompleteSet(objs); ok {
+			candidates[site] = set
+			sites = append(sites, site)
+		}
+	}
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("no complete backups under %s to drill", opts.Prefix)
+	}
+	sort.Strings(sites)
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(started.UnixNano()))
+	}
+	rng.Shuffle(len(sites), func(i, j int) { sites[i], sites[j] = sites[j], sites[i] })
+	n := opts.Sites
+	if n <= 0 {
+		n = DefaultDrillSites
+	}
+	if n < len(sites) {
+		sites = sites[:n]
+	}
+
+	rec := &RunRecord{ID: NewRunID(started), StartedAt: started, Kind: RunKindDrill}
+	for _, site := range sites {
+		fmt.Printf("\n--- Drill: %s ---\n", site)
+		res := bm.drillSite(site, candidates[site], opts)
+		if res.Success {
+			rec.Succeeded++
+			fmt.Printf("✓ Drill of %s passed in %.1fs (%d files)\n", site, res.Seconds, res.Files)
+		} else {
+			rec.Failed++
+			fmt.Printf("✗ Drill of %s failed: %s\n", site, strings.Join(res.Issues, "; "))
+		}
+		rec.Drills = append(rec.Drills, res)
+	}
+	rec.FinishedAt = time.Now()
+	return rec, nil
+}
+
+// drillSite restores set into a fresh sandbox and checks the result.
+func (bm *BackupManager) drillSite(site string, set BackupSet, opts DrillOptions) DrillResult {
+	started := time.Now()
+	res := DrillResult{Site: site}
+	fail := func(format string, args ...any) DrillResult {
+		res.Issues = append(res.Issues, fmt.Sprintf(format, args...))
+		res.Seconds = time.Since(started).Seconds()
+		return res
+	}
+
+	var archive, database string
+	for _, o := range set.Objects {
+		p := parseBackupPart(o.Key)
+		if p.Shards > 0 {
+			return fail("sharded backup %s cannot be restored by a drill", filepath.Base(set.Stem))
+		}
+		switch p.Kind {
+		case PartArchive, PartFiles:
+			archive = o.Key
+		case PartDatabase:
+			database = o.Key
+		}
+	}
+	res.ObjectKey = archive
+	if archive == "" {
+		return fail("backup %s has no file archive", filepath.Base(set.Stem))
+	}
+
+	sandbox, err := os.MkdirTemp(opts.SandboxDir, "ciwg-drill-*")
+	if err != nil {
+		return fail("failed to create sandbox: %v", err)
+	}
+	if opts.KeepSandbox {
+		fmt.Printf("Sandbox: %s (kept)\n", sandbox)
+	} else {
+		defer os.RemoveAll(sandbox)
+	}
+
+	// The copy is never started, so its database name only has to differ
+	// from the original's.
+	as := "drill-" + site
+	err = bm.RestoreSite(archive, &RestoreSiteOptions{As: as, From: site, TargetDir: sandbox, DBName: "ciwg_drill", NoStart: true})
+	if err != nil {
+		return fail("restore failed: %v", err)
+	}
+
+	var dumps []string
+	err = filepath.WalkDir(filepath.Join(sandbox, as), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		res.Files++
+		if strings.HasSuffix(p, ".sql") {
+			if info, err := d.Info(); err == nil && info.Size() > 0 {
+				dumps = append(dumps, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fail("failed to inspect the restored files: %v", err)
+	}
+	if res.Files == 0 {
+		return fail("the restore produced no files")
+	}
+	if database != "" {
+		if err := bm.drillDatabase(database); err != nil {
+			return fail("database part %s: %v", database, err)
+		}
+	} else if len(dumps) == 0 {
+		return fail("no SQL dump in the backup")
+	}
+	res.Success = true
+	res.Seconds = time.Since(started).Seconds()
+	return res
+}
+
+// drillDatabase reads the database part of a split backup to the end,
+// decompressing it when it is gzipped.
+func (bm *BackupManager) drillDatabase(key string) error {
+	r, err := bm.DownloadBackup(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var src io.Reader = r
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src = gz
+	}
+	n, err := io.Copy(io.Discard, src)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("empty dump")
+	}
+	return nil
+}
+
+// Drill alert events.
+const (
+	DrillEventFailed  = "failed"
+	DrillEventOverdue = "overdue"
+)
+
+// DrillAlert is posted to the drill webhook when drills fail or are overdue.
+type DrillAlert struct {
+	Event       string        `json:"event"`
+	RunID       string        `json:"run_id,omitempty"`
+	Host        string        `json:"host"`
+	Message     string        `json:"message"`
+	LastSuccess time.Time     `json:"last_success,omitempty"`
+	Failures    []DrillResult `json:"failures,omitempty"`
+	Time        time.Time     `json:"time"`
+}
+
+// SendDrillAlert posts alert to url.
+func SendDrillAlert(url string, alert *DrillAlert) error {
+	alert.Time = time.Now().UTC()
+	return postJSON(url, alert)
+}
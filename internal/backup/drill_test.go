@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrillStatusOf(t *testing.T) {
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+
+	s := DrillStatusOf(nil, week, DefaultDrillGrace, now)
+	if !s.Due || !s.Overdue {
+		t.Errorf("DrillStatusOf(no drills) = %+v, want due and overdue", s)
+	}
+
+	history := []RunRecord{
+		{Kind: RunKindDrill, StartedAt: now.Add(-10 * 24 * time.Hour), Succeeded: 3},
+		{Kind: RunKindDrill, StartedAt: now.Add(-2 * 24 * time.Hour), Succeeded: 2, Failed: 1},
+		{StartedAt: now.Add(-time.Hour), Succeeded: 5},
+	}
+	s = DrillStatusOf(history, week, DefaultDrillGrace, now)
+	if s.Due || !s.NextDue.Equal(now.Add(5*24*time.Hour)) {
+		t.Errorf("DrillStatusOf() = %+v, want the next drill due in 5 days", s)
+	}
+	if !s.LastSuccess.Equal(now.Add(-10*24*time.Hour)) || !s.Overdue {
+		t.Errorf("DrillStatusOf() = %+v, want overdue since the last success 10 days ago", s)
+	}
+
+	s = DrillStatusOf(history[:1], 14*24*time.Hour, DefaultDrillGrace, now)
+	if s.Due || s.Overdue {
+		t.Errorf("DrillStatusOf(every 14d) = %+v, want neither due nor overdue", s)
+	}
+}
+
+func TestRunDrill(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/foo.com/foo.com-20260501-020000.tgz", string(buildTarball(t, siteFiles, siteOrder)))
+	putSiteTarball(t, bm, "backups/bar.com/bar.com-20260501-020000.tgz", map[string]string{
+		"var/opt/sites/bar.com/www/index.php": "<?php",
+	})
+	putTestObject(t, bm, "backups/baz.com/baz.com-20260501-020000.db.sql.gz", "orphan")
+
+	sandbox := t.TempDir()
+	rec, err := bm.RunDrill(DrillOptions{Prefix: "backups/", Sites: 5, SandboxDir: sandbox, Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatalf("RunDrill() error = %v", err)
+	}
+	if rec.Kind != RunKindDrill || len(rec.Drills) != 2 || rec.Succeeded != 1 || rec.Failed != 1 {
+		t.Fatalf("RunDrill() = %+v, want foo.com passing and bar.com failing", rec)
+	}
+	for _, d := range rec.Drills {
+		switch d.Site {
+		case "foo.com":
+			if !d.Success || d.Files != len(siteOrder) {
+				t.Errorf("foo.com drill = %+v", d)
+			}
+		case "bar.com":
+			if d.Success || len(d.Issues) != 1 || !strings.Contains(d.Issues[0], "no SQL dump") {
+				t.Errorf("bar.com drill = %+v, want a missing dump", d)
+			}
+		default:
+			t.Errorf("drilled %s, which has no complete backup", d.Site)
+		}
+	}
+	if left, _ := os.ReadDir(sandbox); len(left) != 0 {
+		t.Errorf("sandboxes left behind: %v", left)
+	}
+
+	rec, err = bm.RunDrill(DrillOptions{Prefix: "backups/", Sites: 1, SandboxDir: sandbox})
+	if err != nil || len(rec.Drills) != 1 {
+		t.Errorf("RunDrill(sites=1) = %+v, %v", rec, err)
+	}
+	if _, err := bm.RunDrill(DrillOptions{Prefix: "backups/none/"}); err == nil {
+		t.Error("RunDrill() with nothing to drill error = nil")
+	}
+}
+
+func TestLoadFleetDrill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	os.WriteFile(path, []byte("drill:\n  every: 3d\n  sites: 2\n"), 0o644)
+	fleet, err := LoadFleet(path)
+	if err != nil || fleet.Drill == nil || fleet.Drill.Every != "3d" || fleet.Drill.Sites != 2 {
+		t.Fatalf("LoadFleet() = %+v, %v", fleet, err)
+	}
+	os.WriteFile(path, []byte("drill:\n  every: weekly\n"), 0o644)
+	if _, err := LoadFleet(path); err == nil {
+		t.Error("LoadFleet() with an invalid drill interval error = nil")
+	}
+}
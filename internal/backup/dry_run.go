@@ -0,0 +1,33 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDryRun is returned by a write to object storage or Glacier attempted
+// while the manager is in dry-run mode.
+var ErrDryRun = errors.New("dry run: refusing to change storage")
+
+// SetDryRun puts the manager in dry-run mode: every operation still plans
+// and reports what it would do, and any upload, copy or delete that slips
+// through fails with ErrDryRun instead of reaching the backend. Commands set
+// it from --dry-run so a preview can never change the bucket or vault.
+func (bm *BackupManager) SetDryRun(dryRun bool) {
+	bm.dryRun = dryRun
+}
+
+// DryRun reports whether the manager is in dry-run mode.
+func (bm *BackupManager) DryRun() bool {
+	return bm.dryRun
+}
+
+// guardWrite refuses a storage write in dry-run mode. It is called right
+// before every backend call that changes an object, archive or vault.
+func (bm *BackupManager) guardWrite(op, target string) error {
+	if !bm.dryRun {
+		return nil
+	}
+	bm.logVerbose("Dry run: skipped %s %s", op, target)
+	return fmt.Errorf("%w (%s %s)", ErrDryRun, op, target)
+}
@@ -0,0 +1,49 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDryRunGuardsWrites(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/foo.com/foo.com-20260501-020000.tgz", "foo")
+	bm.SetDryRun(true)
+	ctx := context.Background()
+
+	if err := bm.DeleteObjects([]string{"backups/foo.com/foo.com-20260501-020000.tgz"}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("DeleteObjects() error = %v, want ErrDryRun", err)
+	}
+	if err := bm.SetMaintenance(MaintenanceEntry{Site: "foo.com", SetAt: time.Now()}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("SetMaintenance() error = %v, want ErrDryRun", err)
+	}
+	if _, err := bm.BenchUploads(BenchOptions{Target: BenchTargetMinio, Size: 1 << 20, PartSizes: []int64{16 << 20}}); !errors.Is(err, ErrDryRun) {
+		t.Errorf("BenchUploads() error = %v, want ErrDryRun", err)
+	}
+
+	// Planning still works, and listing leaves no cache object behind.
+	if plan, err := bm.NormalizeSiteKeys("backups/", true); err != nil || len(plan) != 0 {
+		t.Errorf("NormalizeSiteKeys(dry run) = %+v, %v", plan, err)
+	}
+	if _, err := bm.ListBackups("backups/", 0); err != nil {
+		t.Fatal(err)
+	}
+	objs, err := bm.listObjects(ctx, "", 0)
+	if err != nil || len(objs) != 1 {
+		t.Errorf("objects after the dry run = %+v, %v; want only the backup", objs, err)
+	}
+
+	dst, _ := newFileBackedManager(t)
+	dst.SetDryRun(true)
+	if res, err := bm.SyncTo(dst, "backups/", true); err != nil || res.Copied != 1 {
+		t.Errorf("SyncTo(dry run) = %+v, %v", res, err)
+	}
+	if res, _ := bm.SyncTo(dst, "backups/", false); res == nil || res.Failed != 1 {
+		t.Errorf("SyncTo() into a dry-run destination = %+v, want the copy refused", res)
+	}
+}
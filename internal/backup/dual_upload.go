@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Destinations of a dual upload, as recorded in UploadStats and pending
+// uploads.
+const (
+	DestinationMinio   = "minio"
+	DestinationGlacier = "glacier"
+)
+
+// Success policies for dual uploads (see SetRequiredDestinations).
+const (
+	RequireMinio   = "minio"
+	RequireGlacier = "glacier"
+	RequireBoth    = "both"
+	RequireAny     = "any"
+)
+
+// SetRequiredDestinations sets which destinations of a dual upload (Minio
+// plus Glacier) must succeed for the backup to count as successful:
+// RequireMinio (the default), RequireGlacier, RequireBoth or RequireAny.
+//
+// When Minio is not required, a failed Minio upload no longer aborts the
+// backup: the rest of the archive is still streamed to Glacier. Backups
+// that only reached one destination record the missed one in UploadStats
+// so it can be queued for `backup retry-pending`.
+func (bm *BackupManager) SetRequiredDestinations(policy string) error {
+	switch policy {
+	case "":
+		policy = RequireMinio
+	case RequireMinio, RequireGlacier, RequireBoth, RequireAny:
+	default:
+		return fmt.Errorf("unknown destination policy %q (use both, any, minio or glacier)", policy)
+	}
+	bm.requireDestinations = policy
+	return nil
+}
+
+// requires reports whether the success policy needs dest to succeed on
+// its own.
+func (bm *BackupManager) requires(dest string) bool {
+	policy := bm.requireDestinations
+	if policy == "" {
+		policy = RequireMinio
+	}
+	return policy == RequireBoth || policy == dest
+}
+
+// checkRequiredDestinations applies policy to the outcome of each
+// destination of a dual upload.
+func checkRequiredDestinations(policy string, minioErr, glacierErr error) error {
+	if minioErr != nil {
+		minioErr = fmt.Errorf("minio: %w", minioErr)
+	}
+	if glacierErr != nil {
+		glacierErr = fmt.Errorf("glacier: %w", glacierErr)
+	}
+	var err error
+	switch policy {
+	case RequireBoth:
+		err = errors.Join(minioErr, glacierErr)
+	case RequireAny:
+		if minioErr != nil && glacierErr != nil {
+			err = errors.Join(minioErr, glacierErr)
+		}
+	case RequireGlacier:
+		err = glacierErr
+	default:
+		err = minioErr
+	}
+	if err != nil {
+		if policy == "" {
+			policy = RequireMinio
+		}
+		return fmt.Errorf("upload policy --require %s not met: %w", policy, err)
+	}
+	return nil
+}
+
+// recordDestination notes whether an upload reached dest.
+func (s *UploadStats) recordDestination(dest string, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.Missed = append(s.Missed, dest)
+		return
+	}
+	s.Destinations = append(s.Destinations, dest)
+}
+
+// dualUploadResult is the outcome of each destination of uploadDual.
+type dualUploadResult struct {
+	minioBytes    int64
+	minioDuration time.Duration
+	minioErr      error
+	glacierErr    error
+}
+
+// uploadDual streams source to Minio and, through a tee, to Glacier with
+// uploadGlacier. When drain is set, a failed Minio upload does not cut the
+// Glacier copy short: the rest of source is read into it. Otherwise the
+// Glacier upload is aborted with the Minio error.
+func (bm *BackupManager) uploadDual(ctx context.Context, objectName string, source io.Reader, stats *UploadStats, metadata map[string]string, drain bool, uploadGlacier func(io.Reader) (*GlacierUploadStats, error)) dualUploadResult {
+	pr, pw := io.Pipe()
+	reader := io.TeeReader(source, pw)
+
+	awsErrChan := make(chan error, 1)
+	go func() {
+		awsStartTime := time.Now()
+		fmt.Printf("   ☁️  Streaming to AWS Glacier...\n")
+		fmt.Printf("      [AWS] Starting upload at %s\n", FormatTime(awsStartTime, "15:04:05"))
+		glacierStats, err := uploadGlacier(pr)
+		// Keep reading so the Minio upload the stream is tee'd from never
+		// stalls on a Glacier upload that stopped early.
+		_, _ = io.Copy(io.Discard, pr)
+		awsDuration := time.Since(awsStartTime)
+		if stats != nil && glacierStats != nil {
+			// Kept on failure too so a checksum mismatch reaches the ledger.
+			stats.Glacier = glacierStats
+		}
+		if err != nil {
+			fmt.Printf("      [AWS] Failed after %s: %v\n", awsDuration, err)
+		} else {
+			fmt.Printf("      [AWS] Completed in %s\n", awsDuration)
+		}
+		awsErrChan <- err
+	}()
+
+	fmt.Printf("   📦 Streaming to Minio...\n")
+	var res dualUploadResult
+	minioStartTime := time.Now()
+	res.minioBytes, res.minioErr = bm.putObject(ctx, objectName, stats.digestReader(reader), -1, "application/gzip", metadata)
+	res.minioDuration = time.Since(minioStartTime)
+	if res.minioErr != nil {
+		fmt.Printf("      [Minio] Failed after %s: %v\n", res.minioDuration, res.minioErr)
+		if drain {
+			fmt.Printf("      [Minio] Finishing the Glacier copy\n")
+			if _, err := io.Copy(io.Discard, reader); err != nil {
+				pw.CloseWithError(err)
+			}
+		} else {
+			pw.CloseWithError(res.minioErr)
+		}
+	}
+	pw.Close()
+	res.glacierErr = <-awsErrChan
+	return res
+}
+
+// settleDestinations records and reports the outcome of each destination of
+// a dual upload and applies the success policy to it.
+func (bm *BackupManager) settleDestinations(stats *UploadStats, res dualUploadResult) error {
+	stats.recordDestination(DestinationMinio, res.minioErr)
+	stats.recordDestination(DestinationGlacier, res.glacierErr)
+	if res.glacierErr != nil {
+		fmt.Printf("⚠️  Warning: AWS upload failed: %v\n", res.glacierErr)
+	} else {
+		fmt.Printf("   ✓ AWS Glacier upload complete\n")
+	}
+	if res.minioErr != nil {
+		fmt.Printf("⚠️  Warning: Minio upload failed: %v\n", res.minioErr)
+	}
+	return checkRequiredDestinations(bm.requireDestinations, res.minioErr, res.glacierErr)
+}
+
+// missedDestinations lists "<object> (<destination>)" for every upload of
+// the run that reached only one destination.
+func (r *RunRecord) missedDestinations() []string {
+	var out []string
+	for _, u := range r.Uploads {
+		for _, dest := range u.Missed {
+			out = append(out, fmt.Sprintf("%s (%s)", u.ObjectKey, dest))
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSetRequiredDestinations(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{})
+	for _, policy := range []string{"", RequireMinio, RequireGlacier, RequireBoth, RequireAny} {
+		if err := bm.SetRequiredDestinations(policy); err != nil {
+			t.Errorf("SetRequiredDestinations(%q) error = %v", policy, err)
+		}
+	}
+	if err := bm.SetRequiredDestinations("all"); err == nil {
+		t.Error("SetRequiredDestinations(all) error = nil")
+	}
+}
+
+func TestCheckRequiredDestinations(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		policy               string
+		minioErr, glacierErr error
+		wantErr              bool
+	}{
+		{"", failed, nil, true},
+		{RequireMinio, nil, failed, false},
+		{RequireGlacier, failed, nil, false},
+		{RequireGlacier, nil, failed, true},
+		{RequireBoth, nil, failed, true},
+		{RequireBoth, nil, nil, false},
+		{RequireAny, failed, nil, false},
+		{RequireAny, nil, failed, false},
+		{RequireAny, failed, failed, true},
+	}
+	for _, tt := range tests {
+		err := checkRequiredDestinations(tt.policy, tt.minioErr, tt.glacierErr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkRequiredDestinations(%q, %v, %v) error = %v, wantErr %v", tt.policy, tt.minioErr, tt.glacierErr, err, tt.wantErr)
+		}
+	}
+}
+
+// fakeGlacier reads the stream like the Glacier upload and keeps it.
+func fakeGlacier(got *strings.Builder) func(io.Reader) (*GlacierUploadStats, error) {
+	return func(r io.Reader) (*GlacierUploadStats, error) {
+		n, err := io.Copy(got, r)
+		if err != nil {
+			return nil, err
+		}
+		return &GlacierUploadStats{ArchiveID: "archive", Bytes: n}, nil
+	}
+}
+
+func TestUploadDual(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Repeat("backup data ", 64<<10)
+	ctx := context.Background()
+
+	var glacier strings.Builder
+	stats := &UploadStats{}
+	res := bm.uploadDual(ctx, "backups/a.com/a.com-20240101-020000.tgz", strings.NewReader(payload), stats, nil, false, fakeGlacier(&glacier))
+	if res.minioErr != nil || res.glacierErr != nil {
+		t.Fatalf("uploadDual() errors = %v, %v", res.minioErr, res.glacierErr)
+	}
+	if res.minioBytes != int64(len(payload)) || glacier.Len() != len(payload) {
+		t.Errorf("uploadDual() sent %d bytes to Minio and %d to Glacier, want %d", res.minioBytes, glacier.Len(), len(payload))
+	}
+	if stats.Glacier == nil || stats.Glacier.ArchiveID != "archive" {
+		t.Errorf("Glacier stats = %+v", stats.Glacier)
+	}
+
+	// A Glacier upload that gives up early must not stall Minio.
+	res = bm.uploadDual(ctx, "backups/a.com/a.com-20240102-020000.tgz", strings.NewReader(payload), nil, nil, false, func(io.Reader) (*GlacierUploadStats, error) {
+		return nil, errors.New("vault unavailable")
+	})
+	if res.minioErr != nil || res.minioBytes != int64(len(payload)) || res.glacierErr == nil {
+		t.Errorf("uploadDual(glacier fails) = %+v", res)
+	}
+
+	// Make Minio fail: the object's parent is a file.
+	if _, err := bm.putObject(ctx, "backups/b.com", strings.NewReader("x"), -1, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	glacier.Reset()
+	res = bm.uploadDual(ctx, "backups/b.com/b.tgz", strings.NewReader(payload), nil, nil, true, fakeGlacier(&glacier))
+	if res.minioErr == nil || res.glacierErr != nil || glacier.Len() != len(payload) {
+		t.Errorf("uploadDual(minio fails, drain) = %+v with %d bytes on Glacier", res, glacier.Len())
+	}
+	glacier.Reset()
+	res = bm.uploadDual(ctx, "backups/b.com/b.tgz", strings.NewReader(payload), nil, nil, false, fakeGlacier(&glacier))
+	if res.minioErr == nil || res.glacierErr == nil {
+		t.Errorf("uploadDual(minio fails) = %+v, want the Glacier copy aborted", res)
+	}
+}
+
+func TestSettleDestinations(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{})
+	bm.SetRequiredDestinations(RequireAny)
+	stats := &UploadStats{}
+	if err := bm.settleDestinations(stats, dualUploadResult{minioErr: errors.New("disk full")}); err != nil {
+		t.Errorf("settleDestinations() error = %v", err)
+	}
+	if len(stats.Destinations) != 1 || stats.Destinations[0] != DestinationGlacier || len(stats.Missed) != 1 || stats.Missed[0] != DestinationMinio {
+		t.Errorf("destinations = %v, missed = %v", stats.Destinations, stats.Missed)
+	}
+}
@@ -0,0 +1,261 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultDuplicateThreshold is the share of content two sites must have in
+// common to be reported as near-duplicates.
+const DefaultDuplicateThreshold = 0.9
+
+// siteFingerprint is the content of one site's latest backup: the size of
+// every distinct file content, keyed by its checksum.
+type siteFingerprint struct {
+	Site    string
+	Backup  string
+	Content map[string]int64
+	Bytes   int64
+}
+
+// DuplicatePair is two sites whose latest backups share most of their files.
+type DuplicatePair struct {
+	Sites   [2]string `json:"sites"`
+	Backups [2]string `json:"backups"`
+	// SharedBytes and SharedFiles count the distinct file contents found in
+	// both backups.
+	SharedBytes int64 `json:"shared_bytes"`
+	SharedFiles int   `json:"shared_files"`
+	// Similarity is SharedBytes over the content of the smaller site.
+	Similarity float64 `json:"similarity"`
+	// Copy is the site that looks like a staging or dev clone of the other,
+	// if either does.
+	Copy       string `json:"copy,omitempty"`
+	Suggestion string `json:"suggestion"`
+}
+
+// DuplicateReport lists the near-duplicate sites of a bucket.
+type DuplicateReport struct {
+	Threshold float64         `json:"threshold"`
+	Sites     int             `json:"sites"`
+	Pairs     []DuplicatePair `json:"pairs"`
+	// Skipped lists sites that could not be compared, with the reason.
+	Skipped map[string]string `json:"skipped,omitempty"`
+	// ReclaimableBytes is the uncompressed content of the suspected copies
+	// that the pairs share, i.e. what excluding them would stop storing.
+	ReclaimableBytes int64 `json:"reclaimable_bytes"`
+}
+
+// stagingLabels are host name labels that mark a clone of a live site.
+var stagingLabels = []string{"staging", "stage", "stg", "dev", "test", "qa", "preview"}
+
+// looksLikeCopy reports whether site is named like a staging or dev clone of
+// other: other with a staging label added, e.g. staging.foo.com for foo.com
+// or foo-staging.com.
+func looksLikeCopy(site, other string) bool {
+	if len(site) <= len(other) {
+		return false
+	}
+	for _, l := range stagingLabels {
+		if site == l+"."+other || site == l+"-"+other {
+			return true
+		}
+		if base, ext, ok := strings.Cut(other, "."); ok && (site == base+"-"+l+"."+ext || site == base+"."+l+"."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDuplicateSites compares the latest complete backup of every site under
+// prefix (or only of sites, when given) file by file and reports the pairs
+// sharing at least threshold of their content. Only tarball backups are
+// read; database parts are left out, as a clone's database always differs.
+func (bm *BackupManager) FindDuplicateSites(prefix string, sites []string, threshold float64) (*DuplicateReport, error) {
+	if threshold <= 0 || threshold > 1 {
+		return nil, fmt.Errorf("threshold must be in (0, 1], got %g", threshold)
+	}
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	bySite := map[string][]ObjectInfo{}
+	for _, o := range objs {
+		if !isInternalObject(o.Key) {
+			site := ObjectSite(o.Key)
+			bySite[site] = append(bySite[site], o)
+		}
+	}
+	if len(sites) == 0 {
+		for site := range bySite {
+			sites = append(sites, site)
+		}
+	}
+	sort.Strings(sites)
+
+	rep := &DuplicateReport{Threshold: threshold, Pairs: []DuplicatePair{}}
+	skip := func(site, reason string) {
+		if rep.Skipped == nil {
+			rep.Skipped = map[string]string{}
+		}
+		rep.Skipped[site] = reason
+	}
+	var prints []*siteFingerprint
+	for _, site := range sites {
+		set, ok := latestCompleteSet(bySite[site])
+		if !ok {
+			skip(site, "no complete backup")
+			continue
+		}
+		// Progress goes to stderr so JSON on stdout stays clean.
+		fmt.Fprintf(os.Stderr, "Fingerprinting %s (%s)...\n", site, path.Base(set.Stem))
+		fp, err := bm.fingerprintBackup(site, set)
+		if err != nil {
+			skip(site, err.Error())
+			continue
+		}
+		prints = append(prints, fp)
+	}
+	rep.Sites = len(prints)
+
+	reclaim := map[string]int64{}
+	for i, a := range prints {
+		for _, b := range prints[i+1:] {
+			pair, ok := compareFingerprints(a, b, threshold)
+			if !ok {
+				continue
+			}
+			if pair.Copy != "" && pair.SharedBytes > reclaim[pair.Copy] {
+				reclaim[pair.Copy] = pair.SharedBytes
+			}
+			rep.Pairs = append(rep.Pairs, pair)
+		}
+	}
+	for _, n := range reclaim {
+		rep.ReclaimableBytes += n
+	}
+	sort.Slice(rep.Pairs, func(i, j int) bool {
+		if rep.Pairs[i].Similarity != rep.Pairs[j].Similarity {
+			return rep.Pairs[i].Similarity > rep.Pairs[j].Similarity
+		}
+		return rep.Pairs[i].Sites[0] < rep.Pairs[j].Sites[0]
+	})
+	return rep, nil
+}
+
+// compareFingerprints returns the pair a, b if they share at least threshold
+// of the smaller site's content.
+func compareFingerprints(a, b *siteFingerprint, threshold float64) (DuplicatePair, bool) {
+	small, large := a, b
+	if len(large.Content) < len(small.Content) {
+		small, large = large, small
+	}
+	pair := DuplicatePair{Sites: [2]string{a.Site, b.Site}, Backups: [2]string{a.Backup, b.Backup}}
+	for sum, size := range small.Content {
+		if _, ok := large.Content[sum]; ok {
+			pair.SharedBytes += size
+			pair.SharedFiles++
+		}
+	}
+	smaller := min(a.Bytes, b.Bytes)
+	if smaller == 0 {
+		return pair, false
+	}
+	pair.Similarity = float64(pair.SharedBytes) / float64(smaller)
+	if pair.Similarity < threshold {
+		return pair, false
+	}
+
+	switch {
+	case looksLikeCopy(a.Site, b.Site):
+		pair.Copy = a.Site
+	case looksLikeCopy(b.Site, a.Site):
+		pair.Copy = b.Site
+	}
+	if pair.Copy != "" {
+		pair.Suggestion = fmt.Sprintf("exclude %s from backups or back it up less often: it is a copy of the other site", pair.Copy)
+	} else {
+		pair.Suggestion = "check whether one site is a clone; keep one copy or deduplicate the shared files"
+	}
+	return pair, true
+}
+
+// fingerprintBackup reads the tarball parts of set and checksums every
+// regular file in them. The backup manifest, which differs per backup, and
+// empty files are left out.
+func (bm *BackupManager) fingerprintBackup(site string, set BackupSet) (*siteFingerprint, error) {
+	fp := &siteFingerprint{Site: site, Backup: path.Base(set.Stem), Content: map[string]int64{}}
+	parts := map[string][]ObjectInfo{}
+	var names []string
+	for _, o := range set.Objects {
+		p := parseBackupPart(o.Key)
+		if p.Kind == PartDatabase {
+			continue
+		}
+		name := shardPattern.ReplaceAllString(o.Key, "$1$4")
+		if !strings.HasSuffix(strings.ToLower(name), ".tgz") {
+			return nil, fmt.Errorf("%s is not a tarball", path.Base(name))
+		}
+		if parts[name] == nil {
+			names = append(names, name)
+		}
+		parts[name] = append(parts[name], o)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no file archive in %s", fp.Backup)
+	}
+	sort.Strings(names)
+
+	alg := currentCryptoPolicy().Checksum
+	for _, name := range names {
+		objs := parts[name]
+		sort.Slice(objs, func(i, j int) bool { return parseBackupPart(objs[i].Key).Shard < parseBackupPart(objs[j].Key).Shard })
+		keys := make([]string, len(objs))
+		for i, o := range objs {
+			keys[i] = o.Key
+		}
+		if err := bm.fingerprintTarball(name, keys, alg, fp); err != nil {
+			return nil, err
+		}
+	}
+	return fp, nil
+}
+
+func (bm *BackupManager) fingerprintTarball(name string, keys []string, alg string, fp *siteFingerprint) error {
+	r := &shardReader{bm: bm, keys: keys}
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream for %s: %w", name, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tarball %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size == 0 || path.Base(hdr.Name) == BackupManifestName {
+			continue
+		}
+		h := newChecksum(alg)
+		if _, err := io.Copy(h, tr); err != nil {
+			return fmt.Errorf("failed to read %s from %s: %w", hdr.Name, name, err)
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		if _, seen := fp.Content[sum]; !seen {
+			fp.Content[sum] = hdr.Size
+			fp.Bytes += hdr.Size
+		}
+	}
+}
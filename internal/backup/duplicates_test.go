@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+)
+
+func putSiteTarball(t *testing.T, bm *BackupManager, key string, files map[string]string) {
+	t.Helper()
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	gz.Close()
+	if _, err := bm.putObject(context.Background(), key, &archive, int64(archive.Len()), "application/gzip", nil); err != nil {
+		t.Fatalf("putObject(%s) error = %v", key, err)
+	}
+}
+
+func TestFindDuplicateSites(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	theme, upload := strings.Repeat("theme ", 1000), strings.Repeat("jpeg ", 2000)
+	putSiteTarball(t, bm, "backups/foo.com/foo.com-20260501-020000.tgz", map[string]string{
+		"var/opt/foo.com/www/theme.php":         theme,
+		"var/opt/foo.com/www/uploads/a.jpg":     upload,
+		"var/opt/foo.com/www/wp-config.php":     "define('DB_NAME', 'foo');",
+		"var/opt/foo.com/" + BackupManifestName: "{}",
+	})
+	// The clone moved a file and changed its config.
+	putSiteTarball(t, bm, "backups/staging.foo.com/staging.foo.com-20260501-020000.tgz", map[string]string{
+		"var/opt/staging.foo.com/www/theme.php":         theme,
+		"var/opt/staging.foo.com/www/old/a.jpg":         upload,
+		"var/opt/staging.foo.com/www/wp-config.php":     "define('DB_NAME', 'staging');",
+		"var/opt/staging.foo.com/" + BackupManifestName: "{\"site\":1}",
+	})
+	putSiteTarball(t, bm, "backups/bar.com/bar.com-20260501-020000.tgz", map[string]string{
+		"var/opt/bar.com/www/theme.php": theme,
+		"var/opt/bar.com/www/big.jpg":   strings.Repeat("other ", 5000),
+	})
+	putSiteTarball(t, bm, "backups/zip.com/zip.com-20260501-020000.db.sql.gz", map[string]string{})
+
+	rep, err := bm.FindDuplicateSites("backups/", nil, DefaultDuplicateThreshold)
+	if err != nil {
+		t.Fatalf("FindDuplicateSites() error = %v", err)
+	}
+	if rep.Sites != 3 || len(rep.Pairs) != 1 {
+		t.Fatalf("FindDuplicateSites() = %+v", rep)
+	}
+	p := rep.Pairs[0]
+	if p.Sites != [2]string{"foo.com", "staging.foo.com"} || p.Copy != "staging.foo.com" || p.SharedFiles != 2 {
+		t.Errorf("pair = %+v", p)
+	}
+	if p.Similarity < 0.9 || p.Similarity >= 1 {
+		t.Errorf("Similarity = %f", p.Similarity)
+	}
+	if want := int64(len(theme) + len(upload)); p.SharedBytes != want || rep.ReclaimableBytes != want {
+		t.Errorf("SharedBytes, ReclaimableBytes = %d, %d, want %d", p.SharedBytes, rep.ReclaimableBytes, want)
+	}
+	if _, ok := rep.Skipped["zip.com"]; !ok {
+		t.Errorf("Skipped = %v, want zip.com without a file archive", rep.Skipped)
+	}
+
+	if rep, _ := bm.FindDuplicateSites("backups/", []string{"foo.com", "bar.com"}, 0.3); len(rep.Pairs) != 1 || rep.Pairs[0].Copy != "" {
+		t.Errorf("FindDuplicateSites(foo, bar, 0.3) = %+v", rep.Pairs)
+	}
+	if _, err := bm.FindDuplicateSites("backups/", nil, 1.5); err == nil {
+		t.Error("FindDuplicateSites() accepted a threshold above 1")
+	}
+}
+
+func TestLooksLikeCopy(t *testing.T) {
+	for _, c := range []struct {
+		site, other string
+		want        bool
+	}{
+		{"staging.foo.com", "foo.com", true},
+		{"dev-foo.com", "foo.com", true},
+		{"foo-staging.com", "foo.com", true},
+		{"foo.test.com", "foo.com", true},
+		{"foo.com", "staging.foo.com", false},
+		{"shop.foo.com", "foo.com", false},
+	} {
+		if got := looksLikeCopy(c.site, c.other); got != c.want {
+			t.Errorf("looksLikeCopy(%s, %s) = %v, want %v", c.site, c.other, got, c.want)
+		}
+	}
+}
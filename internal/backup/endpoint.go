@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MinioEndpoint is a parsed Minio endpoint.
+type MinioEndpoint struct {
+	Host   string // hostname or IP address, IPv6 without brackets
+	Port   string
+	Secure bool
+}
+
+// Address returns the host:port form the Minio client expects, with IPv6
+// addresses in brackets.
+func (e MinioEndpoint) Address() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// ParseMinioEndpoint parses a Minio endpoint given as host, host:port, an IP
+// address (IPv6 in brackets when a port follows, e.g. [2001:db8::1]:9000)
+// or a URL such as https://minio.example.com:9000. A URL scheme decides
+// whether TLS is used; otherwise useSSL does. Without a port the default of
+// the scheme is used (443 or 80).
+func ParseMinioEndpoint(endpoint string, useSSL bool) (MinioEndpoint, error) {
+	ep := MinioEndpoint{Secure: useSSL}
+	fail := func(format string, args ...any) (MinioEndpoint, error) {
+		return MinioEndpoint{}, fmt.Errorf("invalid Minio endpoint %q: %s", endpoint, fmt.Sprintf(format, args...))
+	}
+
+	s := strings.TrimSpace(endpoint)
+	if s == "" {
+		return fail("empty")
+	}
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "https":
+			ep.Secure = true
+		case "http":
+			ep.Secure = false
+		default:
+			return fail("unsupported scheme %q (use http://, https:// or file:// for a directory)", scheme)
+		}
+		u, err := url.Parse("//" + rest)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if u.User != nil {
+			return fail("credentials do not belong in the endpoint (use --minio-access-key and --minio-secret-key)")
+		}
+		if u.Path != "" && u.Path != "/" {
+			return fail("endpoint must not contain a path (use --minio-bucket and --bucket-path)")
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return fail("endpoint must not contain a query or fragment")
+		}
+		s = u.Host
+	} else if strings.ContainsAny(s, "/?#@") {
+		return fail("expected host[:port] or a http(s):// URL")
+	}
+
+	host, port := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return fail("missing ] after IPv6 address")
+		}
+		host, rest := s[1:end], s[end+1:]
+		if net.ParseIP(stripZone(host)) == nil || !strings.Contains(host, ":") {
+			return fail("%q is not an IPv6 address", host)
+		}
+		ep.Host = host
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return fail("unexpected %q after IPv6 address", rest)
+			}
+			port = rest[1:]
+			if port == "" {
+				return fail("missing port after ':'")
+			}
+		}
+	case net.ParseIP(stripZone(s)) != nil && strings.Contains(s, ":"):
+		// A bare IPv6 address. A trailing :port cannot be told apart
+		// from the address itself, so ports require brackets.
+		ep.Host = s
+	case strings.Count(s, ":") > 1:
+		return fail("IPv6 addresses must be enclosed in brackets, e.g. [2001:db8::1]:9000")
+	default:
+		if h, p, ok := strings.Cut(s, ":"); ok {
+			host, port = h, p
+			if port == "" {
+				return fail("missing port after ':'")
+			}
+		}
+		if err := validateHostname(host); err != nil {
+			return fail("%v", err)
+		}
+		ep.Host = host
+	}
+
+	if port == "" {
+		port = "80"
+		if ep.Secure {
+			port = "443"
+		}
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fail("port %q is not a number between 1 and 65535", port)
+	}
+	ep.Port = strconv.Itoa(n)
+	return ep, nil
+}
+
+// NormalizeEndpoint rewrites the endpoint of m to host:port form and takes
+// UseSSL from its scheme, if it has one. File endpoints are left alone.
+func (m *MinioConfig) NormalizeEndpoint() error {
+	if IsFileEndpoint(m.Endpoint) {
+		return nil
+	}
+	ep, err := ParseMinioEndpoint(m.Endpoint, m.UseSSL)
+	if err != nil {
+		return err
+	}
+	m.Endpoint = ep.Address()
+	m.UseSSL = ep.Secure
+	return nil
+}
+
+func stripZone(host string) string {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		return host[:i]
+	}
+	return host
+}
+
+// validateHostname checks a DNS name or IPv4 address.
+func validateHostname(host string) error {
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("hostname is longer than 253 characters")
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("%q is not a valid hostname", host)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("%q is not a valid hostname", host)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("%q is not a valid hostname (unexpected %q)", host, c)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+)
+
+// ErasureCoding is the erasure-coding layout of a Minio deployment. Every
+// object is split into Data shards plus Parity shards, so it takes
+// (Data+Parity)/Data times its size on disk: EC:4 on 8 drives doubles it,
+// EC:4 on 16 drives adds a third.
+type ErasureCoding struct {
+	Data   int `json:"data"`
+	Parity int `json:"parity"`
+	// Detected is set when the layout was read from the Minio admin API
+	// rather than given on the command line.
+	Detected bool `json:"detected"`
+}
+
+// Validate checks that the layout describes a real erasure set.
+func (e *ErasureCoding) Validate() error {
+	if e.Data < 1 {
+		return fmt.Errorf("erasure coding needs at least one data shard (got %d)", e.Data)
+	}
+	if e.Parity < 0 {
+		return fmt.Errorf("erasure coding parity must not be negative (got %d)", e.Parity)
+	}
+	if e.Parity > e.Data {
+		return fmt.Errorf("erasure coding parity (%d) cannot exceed data shards (%d)", e.Parity, e.Data)
+	}
+	return nil
+}
+
+// Overhead is the factor between on-disk and object bytes; 1 for a nil
+// layout.
+func (e *ErasureCoding) Overhead() float64 {
+	if e == nil || e.Data < 1 {
+		return 1
+	}
+	return float64(e.Data+e.Parity) / float64(e.Data)
+}
+
+// OnDisk returns the disk space taken by n object bytes.
+func (e *ErasureCoding) OnDisk(n int64) int64 {
+	if e == nil {
+		return n
+	}
+	return int64(float64(n) * e.Overhead())
+}
+
+func (e *ErasureCoding) String() string {
+	return fmt.Sprintf("EC:%d (%d data + %d parity, %.2fx on disk)", e.Parity, e.Data, e.Parity, e.Overhead())
+}
+
+// DetectErasureCoding reads the Standard storage class layout from the Minio
+// admin API. It returns nil for deployments that do not erasure-code, such
+// as single-drive servers and the file backend. The access key needs the
+// admin:StorageInfo permission.
+func (bm *BackupManager) DetectErasureCoding() (*ErasureCoding, error) {
+	if bm.minioConfig == nil {
+		return nil, fmt.Errorf("minio configuration is required")
+	}
+	if IsFileEndpoint(bm.minioConfig.Endpoint) {
+		return nil, nil
+	}
+
+	ep, err := ParseMinioEndpoint(bm.minioConfig.Endpoint, bm.minioConfig.UseSSL)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := bm.minioTransport(ep.Secure)
+	if err != nil {
+		return nil, err
+	}
+	adm, err := madmin.New(ep.Address(), bm.minioConfig.AccessKey, bm.minioConfig.SecretKey, ep.Secure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Minio admin client: %w", err)
+	}
+	adm.SetCustomTransport(tr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	info, err := adm.StorageInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Minio storage info: %w", err)
+	}
+	return erasureFromBackend(info.Backend), nil
+}
+
+// erasureFromBackend picks the Standard storage class layout of the pool
+// with the highest overhead, so estimates err on the side of more disk.
+func erasureFromBackend(b madmin.BackendInfo) *ErasureCoding {
+	if b.Type != madmin.Erasure {
+		return nil
+	}
+	var best *ErasureCoding
+	for i, data := range b.StandardSCData {
+		parity := b.StandardSCParity
+		if i < len(b.StandardSCParities) {
+			parity = b.StandardSCParities[i]
+		}
+		ec := &ErasureCoding{Data: data, Parity: parity, Detected: true}
+		if ec.Validate() != nil {
+			continue
+		}
+		if best == nil || ec.Overhead() > best.Overhead() {
+			best = ec
+		}
+	}
+	return best
+}
@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/minio/madmin-go/v3"
+)
+
+func TestErasureCodingOverhead(t *testing.T) {
+	tests := []struct {
+		ec   *ErasureCoding
+		want float64
+	}{
+		{nil, 1},
+		{&ErasureCoding{Data: 4, Parity: 4}, 2},
+		{&ErasureCoding{Data: 12, Parity: 4}, 16.0 / 12},
+		{&ErasureCoding{Data: 1, Parity: 0}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.ec.Overhead(); got != tt.want {
+			t.Errorf("%v.Overhead() = %v, want %v", tt.ec, got, tt.want)
+		}
+	}
+	if got := (&ErasureCoding{Data: 4, Parity: 4}).OnDisk(100); got != 200 {
+		t.Errorf("OnDisk(100) = %d, want 200", got)
+	}
+
+	for _, bad := range []ErasureCoding{{Data: 0, Parity: 2}, {Data: 4, Parity: -1}, {Data: 2, Parity: 4}} {
+		if bad.Validate() == nil {
+			t.Errorf("Validate() accepted %+v", bad)
+		}
+	}
+}
+
+func TestErasureFromBackend(t *testing.T) {
+	if ec := erasureFromBackend(madmin.BackendInfo{Type: madmin.FS}); ec != nil {
+		t.Errorf("erasureFromBackend(FS) = %v, want nil", ec)
+	}
+	ec := erasureFromBackend(madmin.BackendInfo{
+		Type:               madmin.Erasure,
+		StandardSCData:     []int{12, 4},
+		StandardSCParities: []int{4, 4},
+	})
+	if ec == nil || ec.Data != 4 || ec.Parity != 4 || !ec.Detected {
+		t.Errorf("erasureFromBackend(pools) = %+v, want the 4+4 pool", ec)
+	}
+	ec = erasureFromBackend(madmin.BackendInfo{Type: madmin.Erasure, StandardSCData: []int{6}, StandardSCParity: 2})
+	if ec == nil || ec.Data != 6 || ec.Parity != 2 {
+		t.Errorf("erasureFromBackend(single parity) = %+v", ec)
+	}
+}
+
+func TestEstimateCapacityErasureOverhead(t *testing.T) {
+	opts := &CapacityEstimateOptions{DailyRetention: 10, WeeklyRetention: 4, Erasure: &ErasureCoding{Data: 4, Parity: 4}}
+	est, err := NewBackupManager(nil, nil).EstimateCapacityFromManual(100, 3, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.PerSiteHotStorage != 2000 || est.FleetHotStorage != 6000 {
+		t.Errorf("hot storage = %d/%d, want 2000/6000 on disk", est.PerSiteHotStorage, est.FleetHotStorage)
+	}
+	if est.PerSiteColdStorage != 400 {
+		t.Errorf("cold storage = %d, want 400 (Glacier is not erasure-coded here)", est.PerSiteColdStorage)
+	}
+	if est.FleetTotalStorage != 6000+1200 || est.Erasure == nil {
+		t.Errorf("estimate = %+v", est)
+	}
+}
@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Errors returned by the backup manager, wrapped with the bucket, object or
+// path they concern. Match them with errors.Is rather than on the message.
+var (
+	// ErrBucketNotFound is returned when the configured bucket is missing.
+	ErrBucketNotFound = errors.New("bucket does not exist")
+	// ErrObjectNotFound is returned when a backup object is missing, from
+	// Minio and the filesystem backend alike.
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrCapacityExceeded is returned when storage usage is above the
+	// threshold a backup or the monitor was given; see CapacityError.
+	ErrCapacityExceeded = errors.New("storage capacity exceeds threshold")
+	// ErrTarFailed is returned when tar fails to create or extract an
+	// archive; see TarError for its stderr.
+	ErrTarFailed = errors.New("tar failed")
+	// ErrGlacierChecksumMismatch is returned when the data sent to Glacier
+	// does not hash to the checksum AWS reports for it.
+	ErrGlacierChecksumMismatch = errors.New("glacier checksum mismatch")
+	// ErrStageTimeout is returned when a stage of a container's backup runs
+	// past its timeout; see StageTimeoutError.
+	ErrStageTimeout = errors.New("backup stage timed out")
+	// ErrResidencyViolation is returned when a write would store a site's
+	// backup outside its allowed regions or endpoints; see ResidencyError.
+	ErrResidencyViolation = errors.New("data residency violation")
+)
+
+// CapacityError reports storage usage above a threshold. It matches
+// ErrCapacityExceeded.
+type CapacityError struct {
+	Path        string
+	UsedPercent float64
+	Threshold   float64
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("storage capacity of %s exceeds %.1f%% (current: %.1f%%)", e.Path, e.Threshold, e.UsedPercent)
+}
+
+func (e *CapacityError) Is(target error) bool { return target == ErrCapacityExceeded }
+
+// TarError reports a failed tar command with what it wrote to stderr. It
+// matches ErrTarFailed and unwraps to the command's error.
+type TarError struct {
+	// Op is what tar was doing: "create" or "extract".
+	Op     string
+	Path   string
+	Stderr string
+	Err    error
+}
+
+func (e *TarError) Error() string {
+	return fmt.Sprintf("tar %s of %s failed: %v (stderr: %s)", e.Op, e.Path, e.Err, e.Stderr)
+}
+
+func (e *TarError) Is(target error) bool { return target == ErrTarFailed }
+
+func (e *TarError) Unwrap() error { return e.Err }
+
+// StageTimeoutError reports a stage of a container's backup that was
+// aborted at its timeout. It matches ErrStageTimeout and unwraps to the
+// error the aborted stage failed with, if any.
+type StageTimeoutError struct {
+	Container string
+	// Stage is StageExport, StageTar, StageUpload or StageContainer.
+	Stage   string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *StageTimeoutError) Error() string {
+	msg := fmt.Sprintf("%s stage timed out after %s", e.Stage, e.Timeout)
+	if e.Container != "" {
+		msg = fmt.Sprintf("%s stage of %s timed out after %s", e.Stage, e.Container, e.Timeout)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return msg
+}
+
+func (e *StageTimeoutError) Is(target error) bool { return target == ErrStageTimeout }
+
+func (e *StageTimeoutError) Unwrap() error { return e.Err }
+
+// objectError wraps err with ErrObjectNotFound or ErrBucketNotFound when the
+// backend reports the object or bucket as missing, and returns it unchanged
+// otherwise.
+func objectError(bucket, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s: %v", ErrObjectNotFound, key, err)
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey":
+		return fmt.Errorf("%w: %s: %v", ErrObjectNotFound, key, err)
+	case "NoSuchBucket":
+		return fmt.Errorf("%w: %s: %v", ErrBucketNotFound, bucket, err)
+	}
+	return err
+}
+
+// ResidencyError reports a write refused by the residency of its site. It
+// matches ErrResidencyViolation.
+type ResidencyError struct {
+	Site   string
+	Op     string
+	Object string
+	Target ResidencyTarget
+	// Violation says which rule the destination breaks.
+	Violation string
+}
+
+func (e *ResidencyError) Error() string {
+	return fmt.Sprintf("residency of %s does not allow %s %s to %s: %s", e.Site, e.Op, e.Object, e.Target, e.Violation)
+}
+
+func (e *ResidencyError) Is(target error) bool { return target == ErrResidencyViolation }
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestObjectError(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := bm.getObject(context.Background(), "backups/a.com/missing.tgz")
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("getObject(missing) error = %v, want ErrObjectNotFound", err)
+	}
+	if _, err := bm.statObject(context.Background(), "backups/a.com/missing.tgz"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("statObject(missing) error = %v, want ErrObjectNotFound", err)
+	}
+
+	if err := objectError("b", "k", minio.ErrorResponse{Code: "NoSuchKey"}); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("objectError(NoSuchKey) = %v", err)
+	}
+	if err := objectError("b", "k", minio.ErrorResponse{Code: "NoSuchBucket"}); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("objectError(NoSuchBucket) = %v", err)
+	}
+	other := errors.New("connection refused")
+	if err := objectError("b", "k", other); err != other {
+		t.Errorf("objectError(other) = %v, want it unchanged", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	capErr := fmt.Errorf("cannot create backup: %w", &CapacityError{Path: "/", UsedPercent: 97, Threshold: 95})
+	if !errors.Is(capErr, ErrCapacityExceeded) {
+		t.Error("CapacityError does not match ErrCapacityExceeded")
+	}
+	var ce *CapacityError
+	if !errors.As(capErr, &ce) || ce.UsedPercent != 97 {
+		t.Errorf("errors.As(CapacityError) = %+v", ce)
+	}
+
+	exitErr := exec.Command("false").Run()
+	tarErr := error(&TarError{Op: "create", Path: "/srv/a.com", Stderr: "tar: x: Cannot open: Permission denied", Err: exitErr})
+	var te *TarError
+	if !errors.Is(tarErr, ErrTarFailed) || !errors.As(tarErr, &te) || te.Stderr == "" {
+		t.Errorf("TarError does not match ErrTarFailed: %v", tarErr)
+	}
+	var ee *exec.ExitError
+	if !errors.As(tarErr, &ee) {
+		t.Error("TarError does not unwrap to the command error")
+	}
+	if got := ClassifyBackupError(tarErr).Code; got != FailurePermissionDenied {
+		t.Errorf("ClassifyBackupError(TarError) = %s, want %s", got, FailurePermissionDenied)
+	}
+	if got := ClassifyBackupError(capErr).Code; got != FailureCapacityExceeded {
+		t.Errorf("ClassifyBackupError(CapacityError) = %s", got)
+	}
+	if got := ClassifyBackupError(fmt.Errorf("%w: backups", ErrBucketNotFound)).Code; got != FailureStorageBucket {
+		t.Errorf("ClassifyBackupError(ErrBucketNotFound) = %s", got)
+	}
+}
@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ETagIndex records the ETag and size every backup object had when `backup
+// verify` first saw it, with the changes seen since. Backups are written
+// once, so a later ETag that differs means the object was overwritten or
+// corrupted behind our back.
+type ETagIndex struct {
+	// Objects is keyed by store ID (endpoint and bucket) + "/" + object key.
+	Objects map[string]*ETagEntry `json:"objects"`
+}
+
+// ETagEntry is the recorded state of one object.
+type ETagEntry struct {
+	ETag         string    `json:"etag,omitempty"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastVerified time.Time `json:"last_verified"`
+	// History lists the earlier states of the object that were accepted
+	// with --accept, oldest first.
+	History []ETagChange `json:"history,omitempty"`
+}
+
+// ETagChange is an earlier state of an object.
+type ETagChange struct {
+	ETag         string    `json:"etag,omitempty"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ReplacedAt   time.Time `json:"replaced_at"`
+}
+
+// DefaultETagIndexPath returns the default location of the ETag index
+// (~/.ciwg/etag-index.json).
+func DefaultETagIndexPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-etag-index.json")
+	}
+	return filepath.Join(home, ".ciwg", "etag-index.json")
+}
+
+// LoadETagIndex reads the index at path. A missing file yields an empty
+// index.
+func LoadETagIndex(path string) (*ETagIndex, error) {
+	idx := &ETagIndex{Objects: map[string]*ETagEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ETag index: %w", err)
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("invalid ETag index %s: %w", path, err)
+	}
+	if idx.Objects == nil {
+		idx.Objects = map[string]*ETagEntry{}
+	}
+	return idx, nil
+}
+
+// Save writes the index to path through a temp file, so a crash never
+// leaves it half written.
+func (idx *ETagIndex) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create ETag index directory: %w", err)
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ETag index: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".etag-index-*")
+	if err != nil {
+		return fmt.Errorf("failed to write ETag index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write ETag index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write ETag index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace ETag index: %w", err)
+	}
+	return nil
+}
+
+// ETag check results.
+const (
+	ETagNew       = "new"
+	ETagUnchanged = "unchanged"
+	ETagChanged   = "changed"
+	ETagAccepted  = "accepted"
+	ETagRemoved   = "removed"
+)
+
+// ETagCheck is the result of comparing one object with the index.
+type ETagCheck struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	// Recorded is the state in the index, Current the one in the bucket;
+	// either is nil when the object is new or removed.
+	Recorded *ETagEntry  `json:"recorded,omitempty"`
+	Current  *ObjectInfo `json:"current,omitempty"`
+}
+
+// ETagReport summarizes an ETag verification.
+type ETagReport struct {
+	Checks    []ETagCheck `json:"checks"`
+	New       int         `json:"new"`
+	Unchanged int         `json:"unchanged"`
+	Changed   int         `json:"changed"`
+	Accepted  int         `json:"accepted"`
+	Removed   int         `json:"removed"`
+}
+
+// sameObject reports whether obj still matches entry: same size and, where
+// the backend reports one, same ETag. Backends without ETags (file://)
+// compare the modification time instead.
+func (e *ETagEntry) sameObject(obj ObjectInfo) bool {
+	if e.Size != obj.Size {
+		return false
+	}
+	if e.ETag != "" || obj.ETag != "" {
+		return e.ETag == obj.ETag
+	}
+	return e.LastModified.Equal(obj.LastModified)
+}
+
+// CompareETags compares objs, the current listing of store under prefix,
+// with idx and updates it: new objects are recorded, unchanged ones marked
+// verified. Changed objects are flagged and keep their recorded state
+// unless accept is set, which records the current state and moves the old
+// one to the history. Recorded objects under prefix that are gone are
+// reported as removed and dropped, since pruning deletes backups routinely.
+func (idx *ETagIndex) CompareETags(store, prefix string, objs []ObjectInfo, accept bool, now time.Time) *ETagReport {
+	rep := &ETagReport{}
+	seen := make(map[string]bool, len(objs))
+	for _, obj := range objs {
+		if isInternalObject(obj.Key) {
+			continue
+		}
+		id := store + "/" + obj.Key
+		seen[id] = true
+		cur := obj
+		entry, ok := idx.Objects[id]
+		switch {
+		case !ok:
+			idx.Objects[id] = &ETagEntry{ETag: obj.ETag, Size: obj.Size, LastModified: obj.LastModified, FirstSeen: now, LastVerified: now}
+			rep.Checks = append(rep.Checks, ETagCheck{Key: obj.Key, Status: ETagNew, Current: &cur})
+			rep.New++
+		case entry.sameObject(obj):
+			entry.LastVerified = now
+			rep.Unchanged++
+		case accept:
+			recorded := *entry
+			entry.History = append(entry.History, ETagChange{ETag: entry.ETag, Size: entry.Size, LastModified: entry.LastModified, ReplacedAt: now})
+			entry.ETag, entry.Size, entry.LastModified, entry.LastVerified = obj.ETag, obj.Size, obj.LastModified, now
+			rep.Checks = append(rep.Checks, ETagCheck{Key: obj.Key, Status: ETagAccepted, Recorded: &recorded, Current: &cur})
+			rep.Accepted++
+		default:
+			recorded := *entry
+			rep.Checks = append(rep.Checks, ETagCheck{Key: obj.Key, Status: ETagChanged, Recorded: &recorded, Current: &cur})
+			rep.Changed++
+		}
+	}
+
+	scope := store + "/" + prefix
+	for id, entry := range idx.Objects {
+		if seen[id] || !strings.HasPrefix(id, scope) {
+			continue
+		}
+		rep.Checks = append(rep.Checks, ETagCheck{Key: strings.TrimPrefix(id, store+"/"), Status: ETagRemoved, Recorded: entry})
+		rep.Removed++
+		delete(idx.Objects, id)
+	}
+	sort.Slice(rep.Checks, func(i, j int) bool { return rep.Checks[i].Key < rep.Checks[j].Key })
+	return rep
+}
+
+// storeID names the backend objects are indexed under: the endpoint and
+// bucket, so buckets of the same name on a replica don't collide.
+func (bm *BackupManager) storeID() string {
+	if bm.minioConfig == nil {
+		return ""
+	}
+	if bm.fileStore != nil {
+		return strings.TrimSuffix(bm.minioConfig.Endpoint, "/")
+	}
+	return strings.TrimSuffix(bm.minioConfig.Endpoint, "/") + "/" + bm.minioConfig.Bucket
+}
+
+// VerifyETags lists every object under prefix and compares it with the
+// index at indexPath, saving the updated index unless dryRun is set.
+func (bm *BackupManager) VerifyETags(prefix, indexPath string, accept, dryRun bool) (*ETagReport, error) {
+	idx, err := LoadETagIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	rep := idx.CompareETags(bm.storeID(), prefix, objs, accept, time.Now().UTC())
+	if dryRun {
+		return rep, nil
+	}
+	return rep, idx.Save(indexPath)
+}
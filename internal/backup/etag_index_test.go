@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompareETags(t *testing.T) {
+	idx := &ETagIndex{Objects: map[string]*ETagEntry{}}
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a-1.tgz", Size: 10, ETag: "e1", LastModified: t0},
+		{Key: "backups/a.com/a-2.tgz", Size: 20, ETag: "e2", LastModified: t0},
+		{Key: "backups/b.com/b-1.tgz", Size: 30, ETag: "e3", LastModified: t0},
+	}
+	rep := idx.CompareETags("s3/bkt", "backups/", objs, false, t0)
+	if rep.New != 3 || rep.Changed != 0 || len(idx.Objects) != 3 {
+		t.Fatalf("first run = %+v", rep)
+	}
+
+	t1 := t0.Add(24 * time.Hour)
+	objs = []ObjectInfo{
+		{Key: "backups/a.com/a-1.tgz", Size: 10, ETag: "e1", LastModified: t0},
+		{Key: "backups/a.com/a-2.tgz", Size: 20, ETag: "other", LastModified: t1},
+	}
+	rep = idx.CompareETags("s3/bkt", "backups/", objs, false, t1)
+	if rep.Unchanged != 1 || rep.Changed != 1 || rep.Removed != 1 || rep.New != 0 {
+		t.Fatalf("second run = %+v", rep)
+	}
+	if e := idx.Objects["s3/bkt/backups/a.com/a-2.tgz"]; e.ETag != "e2" || len(e.History) != 0 {
+		t.Fatalf("changed object updated without --accept: %+v", e)
+	}
+	if _, ok := idx.Objects["s3/bkt/backups/b.com/b-1.tgz"]; ok {
+		t.Fatal("removed object kept in the index")
+	}
+
+	rep = idx.CompareETags("s3/bkt", "backups/a.com/", objs, true, t1)
+	if rep.Accepted != 1 || rep.Changed != 0 {
+		t.Fatalf("accept run = %+v", rep)
+	}
+	e := idx.Objects["s3/bkt/backups/a.com/a-2.tgz"]
+	if e.ETag != "other" || len(e.History) != 1 || e.History[0].ETag != "e2" || !e.History[0].ReplacedAt.Equal(t1) {
+		t.Fatalf("accepted entry = %+v", e)
+	}
+
+	// Another store and other prefixes are left alone.
+	rep = idx.CompareETags("replica/bkt", "backups/", nil, false, t1)
+	if rep.Removed != 0 || len(idx.Objects) != 2 {
+		t.Fatalf("other store run = %+v, index %d", rep, len(idx.Objects))
+	}
+}
+
+func TestETagEntrySameObjectWithoutETags(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	e := &ETagEntry{Size: 3, LastModified: t0}
+	if !e.sameObject(ObjectInfo{Size: 3, LastModified: t0}) {
+		t.Fatal("same size and mtime reported as changed")
+	}
+	if e.sameObject(ObjectInfo{Size: 3, LastModified: t0.Add(time.Second)}) {
+		t.Fatal("new mtime not reported as changed")
+	}
+}
+
+func TestVerifyETagsSavesIndex(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+	putTestObject(t, bm, "backups/a.com/a-1.tgz", "one")
+	path := filepath.Join(t.TempDir(), "etag-index.json")
+
+	rep, err := bm.VerifyETags("backups/", path, false, true)
+	if err != nil || rep.New != 1 {
+		t.Fatalf("VerifyETags(dry run) = %+v, %v", rep, err)
+	}
+	if idx, _ := LoadETagIndex(path); len(idx.Objects) != 0 {
+		t.Fatalf("dry run saved the index: %+v", idx.Objects)
+	}
+
+	if _, err := bm.VerifyETags("backups/", path, false, false); err != nil {
+		t.Fatalf("VerifyETags() error = %v", err)
+	}
+	rep, err = bm.VerifyETags("backups/", path, false, false)
+	if err != nil || rep.Unchanged != 1 || rep.New != 0 {
+		t.Fatalf("VerifyETags() second run = %+v, %v", rep, err)
+	}
+
+	putTestObject(t, bm, "backups/a.com/a-1.tgz", "overwritten")
+	rep, err = bm.VerifyETags("backups/", path, false, false)
+	if err != nil || rep.Changed != 1 {
+		t.Fatalf("VerifyETags() after overwrite = %+v, %v", rep, err)
+	}
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Process exit codes of the backup commands. They are part of the CLI's
+// contract, so cron jobs and wrappers can branch on the kind of failure
+// without parsing messages; never renumber them.
+const (
+	// ExitOK means the command did everything it was asked to.
+	ExitOK = 0
+	// ExitFailure is any failure not covered by a more specific code.
+	ExitFailure = 1
+	// ExitPartial means some sites, hosts or objects failed while the rest
+	// succeeded.
+	ExitPartial = 2
+	// ExitConfig means invalid flags, arguments or configuration; nothing
+	// was attempted.
+	ExitConfig = 3
+	// ExitConnectivity means a host, object storage, Glacier or a database
+	// could not be reached or timed out.
+	ExitConnectivity = 4
+	// ExitCapacity means the run aborted because storage is above its
+	// capacity threshold.
+	ExitCapacity = 5
+	// ExitVerification means a backup failed integrity, checksum or
+	// recovery drill verification.
+	ExitVerification = 6
+	// ExitRefused means a safety guard refused the operation: a permission
+	// gate, the delete hold, a retrieval budget cap or data residency.
+	ExitRefused = 7
+)
+
+// ExitError attaches an exit code to an error.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// WithExitCode returns err carrying code, or nil when err is nil.
+func WithExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// PartialFailure marks err as a partial failure when succeeded items went
+// through alongside the failed ones; when nothing succeeded err is returned
+// as it is.
+func PartialFailure(err error, succeeded int) error {
+	if succeeded > 0 {
+		return WithExitCode(ExitPartial, err)
+	}
+	return err
+}
+
+// configErrorPattern matches the usage errors cobra and pflag return and the
+// flag validation errors of the backup commands ("invalid --tier: ...",
+// "--out is required", "minio-endpoint is required").
+var configErrorPattern = regexp.MustCompile(`^(unknown (command|flag|shorthand flag)|invalid argument|bad flag syntax|flag needs an argument|required flag|accepts |requires (at least|at most|between)|invalid |--[a-z0-9-]+ |[^:]* is required|[^:]* not configured \(set |error loading \.env|failed to load env file|error parsing server range)`)
+
+// connectivityFailures are the diagnoses of ClassifyBackupError that mean
+// something could not be reached.
+var connectivityFailures = map[string]bool{
+	FailureSSHConnection:      true,
+	FailureStorageUnreachable: true,
+	FailureDBUnreachable:      true,
+	FailureTimeout:            true,
+}
+
+// ExitCode maps an error returned by a command to its process exit code. An
+// ExitError decides for itself; other errors are classified by the sentinel
+// they wrap, then by their message.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	var denied *OperationDeniedError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.Is(err, ErrCapacityExceeded):
+		return ExitCapacity
+	case errors.Is(err, ErrGlacierChecksumMismatch):
+		return ExitVerification
+	case errors.As(err, &denied), errors.Is(err, ErrDeleteHold), errors.Is(err, ErrRetrievalBudget),
+		errors.Is(err, ErrResidencyViolation):
+		return ExitRefused
+	case errors.Is(err, ErrBucketNotFound), configErrorPattern.MatchString(err.Error()):
+		return ExitConfig
+	}
+	switch code := ClassifyBackupError(err).Code; {
+	case connectivityFailures[code]:
+		return ExitConnectivity
+	case code == FailureStorageAuth || code == FailureStorageBucket:
+		return ExitConfig
+	case code == FailureDiskFull:
+		return ExitCapacity
+	}
+	return ExitFailure
+}
@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{errors.New("something odd happened"), ExitFailure},
+		{PartialFailure(errors.New("2 object(s) failed to sync"), 5), ExitPartial},
+		{PartialFailure(errors.New("2 object(s) failed to sync"), 0), ExitFailure},
+		{errors.New(`unknown flag: --bogus`), ExitConfig},
+		{errors.New(`accepts 1 arg(s), received 2`), ExitConfig},
+		{fmt.Errorf("invalid --tier: %w", errors.New("unknown retrieval tier")), ExitConfig},
+		{errors.New("--out is required"), ExitConfig},
+		{errors.New("minio-endpoint is required (use --minio-endpoint or set MINIO_ENDPOINT)"), ExitConfig},
+		{errors.New("failed to export: mysqldump: option is required"), ExitFailure},
+		{fmt.Errorf("list: %w: backups", ErrBucketNotFound), ExitConfig},
+		{errors.New("failed to connect to web1:22: dial tcp 10.0.0.1:22: connect: connection refused"), ExitConnectivity},
+		{errors.New("Get https://minio:9000/: i/o timeout"), ExitConnectivity},
+		{fmt.Errorf("cannot create backup: %w", &CapacityError{Path: "/", UsedPercent: 97, Threshold: 95}), ExitCapacity},
+		{fmt.Errorf("upload: %w", ErrGlacierChecksumMismatch), ExitVerification},
+		{fmt.Errorf("%w; hint", &OperationDeniedError{Op: "delete", By: "--read-only"}), ExitRefused},
+		{&DeleteHoldError{Hold: DefaultDeleteHold}, ExitRefused},
+		// An explicit code wins over the classification of the cause.
+		{WithExitCode(ExitVerification, errors.New("--accept needed: 3 object(s) changed")), ExitVerification},
+	} {
+		if got := ExitCode(tc.err); got != tc.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+	if WithExitCode(ExitPartial, nil) != nil {
+		t.Error("WithExitCode(nil) is not nil")
+	}
+}
@@ -0,0 +1,445 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files of an export bundle, relative to its directory. The backup objects
+// themselves go under BundleBackupDir.
+const (
+	BundleBackupDir     = "backup"
+	BundleManifestFile  = "manifest.json"
+	BundleInventoryFile = "inventory.csv"
+	BundleSummaryFile   = "SUMMARY.md"
+	BundleSignatureFile = "SUMMARY.md.sig"
+	BundlePublicKeyFile = "signing-key.pub.pem"
+	// BundleChecksumsFile is the checksums file with the default SHA-256;
+	// see BundleChecksumsFileFor.
+	BundleChecksumsFile = "SHA256SUMS"
+)
+
+// BundleChecksumsFileFor returns the checksums file of a bundle hashed with
+// alg, named as its coreutils/b3sum checker expects.
+func BundleChecksumsFileFor(alg string) string {
+	switch alg {
+	case ChecksumSHA512:
+		return "SHA512SUMS"
+	case ChecksumBLAKE3:
+		return "B3SUMS"
+	}
+	return BundleChecksumsFile
+}
+
+// checksumTool is the command that checks a checksums file of alg.
+func checksumTool(alg string) string {
+	switch alg {
+	case ChecksumSHA512:
+		return "sha512sum"
+	case ChecksumBLAKE3:
+		return "b3sum"
+	}
+	return "sha256sum"
+}
+
+// ExportBundleOptions controls ExportBundle.
+type ExportBundleOptions struct {
+	Site string
+	// Prefix holds the site's backups; empty means backups/<Site>/.
+	Prefix string
+	// OutDir receives the bundle. It must not exist or be empty.
+	OutDir string
+	// Sanitize hands over the backup with Rules applied instead of as
+	// stored. Only single-archive backups can be sanitized.
+	Sanitize bool
+	Rules    *SanitizeRules
+	// History is the run history, the ledger of Glacier copies listed in
+	// the inventory.
+	History []RunRecord
+	// SigningKey signs the summary. A nil key leaves the bundle unsigned.
+	SigningKey ed25519.PrivateKey
+	// Operator is named in the summary as who produced the bundle.
+	Operator string
+}
+
+// BundleFile is one file of an export bundle.
+type BundleFile struct {
+	Path     string // relative to the bundle directory, slash-separated
+	Size     int64
+	Checksum string // with ExportBundleResult.Algorithm
+}
+
+// ExportBundleResult describes a written export bundle.
+type ExportBundleResult struct {
+	Dir       string
+	Backup    BackupSet
+	Sanitized bool
+	// Manifest is the runtime manifest found in the backup, if any.
+	Manifest *BackupFacts
+	// History is the number of inventory rows, one per stored copy.
+	History int
+	Files   []BundleFile
+	Signed  bool
+	// Algorithm is the checksum algorithm of Files, one of
+	// ChecksumAlgorithms.
+	Algorithm string
+}
+
+// ExportBundle writes the hand-over bundle of a site to OutDir: its newest
+// complete backup (optionally sanitized), the runtime manifest stored in the
+// backup, an inventory of every historical backup copy, a Markdown summary
+// and checksums of every file (see CryptoPolicy). The summary lists the checksums of the
+// other files, so signing it covers the whole bundle.
+func (bm *BackupManager) ExportBundle(opts ExportBundleOptions) (*ExportBundleResult, error) {
+	if opts.Site == "" {
+		return nil, fmt.Errorf("site is required")
+	}
+	if opts.OutDir == "" {
+		return nil, fmt.Errorf("output directory is required")
+	}
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = fmt.Sprintf("backups/%s/", opts.Site)
+	}
+	if entries, err := os.ReadDir(opts.OutDir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("output directory %s is not empty", opts.OutDir)
+	}
+
+	set, err := bm.LatestBackupSet(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Sanitize && (len(set.Objects) != 1 || !strings.HasSuffix(set.Objects[0].Key, ".tgz")) {
+		return nil, fmt.Errorf("cannot sanitize %s: only single .tgz backups can be sanitized", set.Stem)
+	}
+	if err := os.MkdirAll(filepath.Join(opts.OutDir, BundleBackupDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	res := &ExportBundleResult{Dir: opts.OutDir, Backup: *set, Algorithm: currentCryptoPolicy().Checksum}
+
+	var backupFiles []string
+	for _, o := range set.Objects {
+		rel := path.Join(BundleBackupDir, path.Base(o.Key))
+		fmt.Printf("⬇️  Downloading %s...\n", o.Key)
+		if err := bm.downloadToFile(o.Key, filepath.Join(opts.OutDir, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+		backupFiles = append(backupFiles, rel)
+	}
+
+	if opts.Sanitize {
+		raw := filepath.Join(opts.OutDir, filepath.FromSlash(backupFiles[0]))
+		rel := path.Join(BundleBackupDir, strings.TrimSuffix(path.Base(set.Objects[0].Key), ".tgz")+".sanitized.tgz")
+		fmt.Printf("🧹 Sanitizing %s...\n", path.Base(raw))
+		err := bm.SanitizeBackup(&SanitizeOptions{
+			InputPath:    raw,
+			OutputPath:   filepath.Join(opts.OutDir, filepath.FromSlash(rel)),
+			ExtractFiles: []string{"*"},
+			Rules:        opts.Rules,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sanitize backup: %w", err)
+		}
+		// Only the sanitized copy is handed over.
+		if err := os.Remove(raw); err != nil {
+			return nil, fmt.Errorf("failed to remove unsanitized backup: %w", err)
+		}
+		backupFiles = []string{rel}
+		res.Sanitized = true
+	}
+
+	files := append([]string(nil), backupFiles...)
+	manifest, err := findBundleManifest(opts.OutDir, backupFiles)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		res.Manifest = manifest
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(opts.OutDir, BundleManifestFile), append(data, '\n'), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write manifest: %w", err)
+		}
+		files = append(files, BundleManifestFile)
+	}
+
+	rows, err := bm.BuildInventory(InventoryOptions{Prefix: prefix, History: opts.History})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build inventory: %w", err)
+	}
+	res.History = len(rows)
+	if err := writeFileWith(filepath.Join(opts.OutDir, BundleInventoryFile), func(w io.Writer) error {
+		return WriteInventoryCSV(w, rows)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write inventory: %w", err)
+	}
+	files = append(files, BundleInventoryFile)
+
+	for _, rel := range files {
+		f, err := hashBundleFile(opts.OutDir, rel)
+		if err != nil {
+			return nil, err
+		}
+		res.Files = append(res.Files, f)
+	}
+
+	res.Signed = opts.SigningKey != nil
+	summary := renderBundleSummary(opts, res, rows, time.Now())
+	summaryPath := filepath.Join(opts.OutDir, BundleSummaryFile)
+	if err := os.WriteFile(summaryPath, summary, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write summary: %w", err)
+	}
+	files = append(files, BundleSummaryFile)
+	if opts.SigningKey != nil {
+		sig := ed25519.Sign(opts.SigningKey, summary)
+		if err := os.WriteFile(filepath.Join(opts.OutDir, BundleSignatureFile), sig, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write signature: %w", err)
+		}
+		pub, err := x509.MarshalPKIXPublicKey(opts.SigningKey.Public())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode public key: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(opts.OutDir, BundlePublicKeyFile), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write public key: %w", err)
+		}
+		files = append(files, BundleSignatureFile, BundlePublicKeyFile)
+	}
+
+	// The checksums file covers every file, in `sha256sum -c` format.
+	for _, rel := range files[len(res.Files):] {
+		f, err := hashBundleFile(opts.OutDir, rel)
+		if err != nil {
+			return nil, err
+		}
+		res.Files = append(res.Files, f)
+	}
+	var sums strings.Builder
+	for _, f := range res.Files {
+		fmt.Fprintf(&sums, "%s  %s\n", f.Checksum, f.Path)
+	}
+	if err := os.WriteFile(filepath.Join(opts.OutDir, BundleChecksumsFileFor(res.Algorithm)), []byte(sums.String()), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write checksums: %w", err)
+	}
+	return res, nil
+}
+
+// LoadSigningKey reads an ed25519 private key in PKCS#8 PEM form, as written
+// by `openssl genpkey -algorithm ed25519`.
+func LoadSigningKey(filePath string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", filePath)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", filePath, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an ed25519 key", filePath)
+	}
+	return priv, nil
+}
+
+// downloadToFile writes objectName to dst.
+func (bm *BackupManager) downloadToFile(objectName, dst string) error {
+	r, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return writeFileWith(dst, func(w io.Writer) error {
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("failed to download %s: %w", objectName, err)
+		}
+		return nil
+	})
+}
+
+// writeFileWith creates dst and fills it with write.
+func writeFileWith(dst string, write func(io.Writer) error) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hashBundleFile(dir, rel string) (BundleFile, error) {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return BundleFile{}, err
+	}
+	defer f.Close()
+	_, sum, n, err := checksumReader(f)
+	if err != nil {
+		return BundleFile{}, fmt.Errorf("failed to hash %s: %w", rel, err)
+	}
+	return BundleFile{Path: rel, Size: n, Checksum: sum}, nil
+}
+
+// findBundleManifest looks for the runtime manifest in the archive parts of
+// the downloaded backup. Shards of a part are read back to back. Backups
+// made before manifests were written have none.
+func findBundleManifest(dir string, files []string) (*BackupFacts, error) {
+	parts := map[string][]string{}
+	var names []string
+	for _, rel := range files {
+		p := parseBackupPart(rel)
+		if p.Kind == PartDatabase {
+			continue
+		}
+		name := shardPattern.ReplaceAllString(rel, "$1$4")
+		if !strings.HasSuffix(strings.ToLower(name), ".tgz") {
+			continue
+		}
+		if parts[name] == nil {
+			names = append(names, name)
+		}
+		parts[name] = append(parts[name], rel)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shards := parts[name]
+		sort.Slice(shards, func(i, j int) bool { return parseBackupPart(shards[i]).Shard < parseBackupPart(shards[j]).Shard })
+		facts, err := readManifestFromArchive(dir, shards)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if facts != nil {
+			return facts, nil
+		}
+	}
+	return nil, nil
+}
+
+func readManifestFromArchive(dir string, shards []string) (*BackupFacts, error) {
+	var readers []io.Reader
+	for _, rel := range shards {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	gz, err := gzip.NewReader(io.MultiReader(readers...))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != BackupManifestName {
+			continue
+		}
+		var facts BackupFacts
+		if err := json.NewDecoder(tr).Decode(&facts); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", BackupManifestName, err)
+		}
+		return &facts, nil
+	}
+}
+
+// renderBundleSummary writes the human-readable summary of a bundle.
+func renderBundleSummary(opts ExportBundleOptions, res *ExportBundleResult, rows []InventoryRow, now time.Time) []byte {
+	var b strings.Builder
+	mb := func(n int64) float64 { return float64(n) / (1024 * 1024) }
+
+	fmt.Fprintf(&b, "# Backup export: %s\n\n", opts.Site)
+	fmt.Fprintf(&b, "- Generated: %s\n", InTimezone(now).Format(time.RFC3339))
+	if opts.Operator != "" {
+		fmt.Fprintf(&b, "- Generated by: %s\n", opts.Operator)
+	}
+	fmt.Fprintf(&b, "- Backup: %s\n", path.Base(res.Backup.Stem))
+	fmt.Fprintf(&b, "- Taken: %s\n", InTimezone(res.Backup.Time()).Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Stored size: %.2f MB in %d object(s)\n", mb(res.Backup.Size), len(res.Backup.Objects))
+	if res.Sanitized {
+		b.WriteString("- Sanitized: yes, sensitive options, tables and files were removed before export\n")
+	} else {
+		b.WriteString("- Sanitized: no, the backup is exactly as stored\n")
+	}
+
+	b.WriteString("\n## Runtime\n\n")
+	if f := res.Manifest; f != nil {
+		fmt.Fprintf(&b, "- Container: %s\n", f.Container)
+		for _, kv := range [][2]string{{"Host", f.Host}, {"Image", f.Image}, {"PHP", f.PHPVersion}, {"WordPress", f.WPVersion}} {
+			if kv[1] != "" {
+				fmt.Fprintf(&b, "- %s: %s\n", kv[0], kv[1])
+			}
+		}
+		fmt.Fprintf(&b, "- Plugins: %d (see %s)\n", len(f.Plugins), BundleManifestFile)
+	} else {
+		b.WriteString("The backup contains no runtime manifest.\n")
+	}
+
+	b.WriteString("\n## Backup history\n\n")
+	hot, cold := 0, 0
+	var first, last time.Time
+	for _, r := range rows {
+		if r.Class == StorageClassCold {
+			cold++
+		} else {
+			hot++
+		}
+		t := BackupTime(ObjectInfo{Key: r.Key, LastModified: r.LastModified})
+		if t.IsZero() {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	fmt.Fprintf(&b, "%d stored cop(ies): %d in object storage, %d in AWS Glacier", len(rows), hot, cold)
+	if !first.IsZero() {
+		fmt.Fprintf(&b, ", from %s to %s", InTimezone(first).Format("2006-01-02"), InTimezone(last).Format("2006-01-02"))
+	}
+	fmt.Fprintf(&b, ". Every copy is listed in %s.\n", BundleInventoryFile)
+
+	fmt.Fprintf(&b, "\n## Files\n\n| File | Size | %s |\n|---|---:|---|\n", checksumLabel(res.Algorithm))
+	for _, f := range res.Files {
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", f.Path, f.Size, f.Checksum)
+	}
+
+	b.WriteString("\n## Verification\n\n")
+	fmt.Fprintf(&b, "Check every file with `%s -c %s`.", checksumTool(res.Algorithm), BundleChecksumsFileFor(res.Algorithm))
+	if res.Signed {
+		fmt.Fprintf(&b, " This summary is signed with ed25519; verify it with\n`openssl pkeyutl -verify -pubin -inkey %s -rawin -in %s -sigfile %s`.\n",
+			BundlePublicKeyFile, BundleSummaryFile, BundleSignatureFile)
+	} else {
+		b.WriteString(" This summary is not signed.\n")
+	}
+	return []byte(b.String())
+}
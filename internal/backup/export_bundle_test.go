@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportBundle(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"created_at":"2024-03-01T02:00:00Z","container":"wp_foo","wp_version":"6.4.3","plugins":[{"name":"akismet","status":"active","version":"5.3"}]}`
+	newest := buildTarball(t, map[string]string{
+		"foo.com/index.php":                   "<?php",
+		"foo.com/" + BackupManifestName:       manifest,
+		"foo.com/wp-content/uploads/logo.png": "png",
+	}, []string{"foo.com/index.php", "foo.com/" + BackupManifestName, "foo.com/wp-content/uploads/logo.png"})
+	putTestObject(t, bm, "backups/foo.com/foo.com-20240101-020000.tgz", string(buildTarball(t, map[string]string{"a": "old"}, []string{"a"})))
+	putTestObject(t, bm, "backups/foo.com/foo.com-20240301-020000.tgz", string(newest))
+	putTestObject(t, bm, "backups/bar.com/bar.com-20240401-020000.tgz", "other site")
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "bundle")
+	res, err := bm.ExportBundle(ExportBundleOptions{Site: "foo.com", OutDir: out, SigningKey: key, Operator: "ops"})
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	if res.Backup.Stem != "backups/foo.com/foo.com-20240301-020000" {
+		t.Errorf("Backup = %s, want the newest backup", res.Backup.Stem)
+	}
+	if res.Manifest == nil || res.Manifest.WPVersion != "6.4.3" {
+		t.Errorf("Manifest = %+v, want the one stored in the backup", res.Manifest)
+	}
+	if res.History != 2 {
+		t.Errorf("History = %d, want 2 (other sites excluded)", res.History)
+	}
+	got, err := os.ReadFile(filepath.Join(out, BundleBackupDir, "foo.com-20240301-020000.tgz"))
+	if err != nil || string(got) != string(newest) {
+		t.Fatalf("bundled backup differs from the stored object (err = %v)", err)
+	}
+
+	// Every file is listed in SHA256SUMS with its real checksum.
+	f, err := os.Open(filepath.Join(out, BundleChecksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	listed := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		sum, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			t.Fatalf("malformed checksum line %q", sc.Text())
+		}
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := sha256.Sum256(data); hex.EncodeToString(h[:]) != sum {
+			t.Errorf("checksum of %s does not match", name)
+		}
+		listed[name] = true
+	}
+	for _, name := range []string{"backup/foo.com-20240301-020000.tgz", BundleManifestFile, BundleInventoryFile, BundleSummaryFile, BundleSignatureFile, BundlePublicKeyFile} {
+		if !listed[name] {
+			t.Errorf("%s missing from %s", name, BundleChecksumsFile)
+		}
+	}
+
+	// The summary verifies against the bundled public key.
+	summary, _ := os.ReadFile(filepath.Join(out, BundleSummaryFile))
+	sig, _ := os.ReadFile(filepath.Join(out, BundleSignatureFile))
+	pemData, _ := os.ReadFile(filepath.Join(out, BundlePublicKeyFile))
+	block, _ := pem.Decode(pemData)
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub.(ed25519.PublicKey), summary, sig) {
+		t.Error("summary signature does not verify")
+	}
+	for _, want := range []string{"# Backup export: foo.com", "Generated by: ops", "WordPress: 6.4.3", "Plugins: 1"} {
+		if !strings.Contains(string(summary), want) {
+			t.Errorf("summary missing %q", want)
+		}
+	}
+
+	if _, err := bm.ExportBundle(ExportBundleOptions{Site: "foo.com", OutDir: out}); err == nil {
+		t.Error("ExportBundle() into a non-empty directory succeeded, want error")
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadSigningKey(p)
+	if err != nil {
+		t.Fatalf("LoadSigningKey() error = %v", err)
+	}
+	if !got.Equal(key) {
+		t.Error("LoadSigningKey() returned a different key")
+	}
+}
+
+func TestExportBundleSanitized(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/foo.com/foo.com-20240301-020000.tgz", string(buildTarball(t, map[string]string{
+		"foo.com/index.php": "<?php",
+		"foo.com/db.sql":    "INSERT INTO wp_options VALUES (1,'siteurl','https://foo.com');",
+	}, []string{"foo.com/index.php", "foo.com/db.sql"})))
+
+	out := filepath.Join(t.TempDir(), "bundle")
+	res, err := bm.ExportBundle(ExportBundleOptions{Site: "foo.com", OutDir: out, Sanitize: true})
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+	if !res.Sanitized || res.Signed {
+		t.Errorf("Sanitized = %v, Signed = %v, want true, false", res.Sanitized, res.Signed)
+	}
+	entries, _ := os.ReadDir(filepath.Join(out, BundleBackupDir))
+	if len(entries) != 1 || entries[0].Name() != "foo.com-20240301-020000.sanitized.tgz" {
+		t.Errorf("bundled backups = %v, want only the sanitized copy", entries)
+	}
+	if _, err := os.Stat(filepath.Join(out, BundleSignatureFile)); !os.IsNotExist(err) {
+		t.Errorf("unsigned bundle has a signature file (err = %v)", err)
+	}
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Export freezes that keep editors from writing while a WordPress database
+// is exported, so the dump matches the uploads archived with it.
+const (
+	// ExportFreezeNone exports without freezing the site.
+	ExportFreezeNone = "none"
+	// ExportFreezeMaintenance uses `wp maintenance-mode activate`, which
+	// shows visitors and editors the maintenance page.
+	ExportFreezeMaintenance = "maintenance"
+	// ExportFreezePlugin activates a read-only plugin (plugin:<slug>) that
+	// keeps the front end up but blocks logins and writes.
+	ExportFreezePlugin = "plugin"
+)
+
+// ExportFreeze is how a site is frozen during its database export.
+type ExportFreeze struct {
+	Mode string
+	// Plugin is the slug of the read-only plugin for ExportFreezePlugin.
+	Plugin string
+}
+
+// ParseExportFreeze parses none, maintenance or plugin:<slug>; an empty
+// string is none.
+func ParseExportFreeze(s string) (ExportFreeze, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == ExportFreezeNone:
+		return ExportFreeze{Mode: ExportFreezeNone}, nil
+	case s == ExportFreezeMaintenance:
+		return ExportFreeze{Mode: ExportFreezeMaintenance}, nil
+	case strings.HasPrefix(s, ExportFreezePlugin+":"):
+		slug := strings.TrimPrefix(s, ExportFreezePlugin+":")
+		if slug == "" || strings.ContainsAny(slug, " '\"/\\;&|$`") {
+			return ExportFreeze{}, fmt.Errorf("invalid plugin slug %q", slug)
+		}
+		return ExportFreeze{Mode: ExportFreezePlugin, Plugin: slug}, nil
+	}
+	return ExportFreeze{}, fmt.Errorf("unknown export freeze %q (use none, maintenance or plugin:<slug>)", s)
+}
+
+// Enabled reports whether f freezes the site.
+func (f ExportFreeze) Enabled() bool {
+	return f.Mode == ExportFreezeMaintenance || f.Mode == ExportFreezePlugin
+}
+
+func (f ExportFreeze) String() string {
+	if f.Mode == ExportFreezePlugin {
+		return "read-only plugin " + f.Plugin
+	}
+	if f.Enabled() {
+		return "maintenance mode"
+	}
+	return ExportFreezeNone
+}
+
+// commands returns the wp-cli commands that report whether the freeze is
+// already on (exit 0), switch it on and switch it off.
+func (f ExportFreeze) commands() (isActive, activate, deactivate string) {
+	if f.Mode == ExportFreezePlugin {
+		return "plugin is-active " + f.Plugin, "plugin activate " + f.Plugin, "plugin deactivate " + f.Plugin
+	}
+	return "maintenance-mode is-active", "maintenance-mode activate", "maintenance-mode deactivate"
+}
+
+// exportFreezeFor returns the freeze for container: its export_freeze
+// config when set, otherwise the run's option. Only WordPress sites can be
+// frozen.
+func exportFreezeFor(container ContainerInfo, options *BackupOptions) (ExportFreeze, error) {
+	spec := options.ExportFreeze
+	if container.Config != nil && container.Config.ExportFreeze != "" {
+		spec = container.Config.ExportFreeze
+	}
+	f, err := ParseExportFreeze(spec)
+	if err != nil {
+		return f, fmt.Errorf("container %s: %w", container.Name, err)
+	}
+	if container.Type != "wordpress" && container.Type != "" {
+		return ExportFreeze{Mode: ExportFreezeNone}, nil
+	}
+	return f, nil
+}
+
+// withExportFreeze runs export with the WordPress site in container frozen
+// by f; see freezeDuring.
+func (bm *BackupManager) withExportFreeze(container ContainerInfo, f ExportFreeze, export func() error) error {
+	if !f.Enabled() {
+		return export()
+	}
+	_, _, deactivate := f.commands()
+	wp := func(args string) error {
+		run := bm.executeCommand
+		if args == deactivate {
+			// Switching the freeze off must happen even after the export
+			// ran past its deadline.
+			run = bm.cleanupCommand
+		}
+		_, stderr, err := run(fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root %s`, container.Name, args))
+		if err != nil {
+			return fmt.Errorf("%w (stderr: %s)", err, strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+	return freezeDuring(container.Name, f, wp, export)
+}
+
+// freezeDuring runs export with site frozen by f, running wp-cli through
+// wp. A site that is already frozen, e.g. by an admin, is left as it is.
+// When freezing fails the export runs anyway with a warning, since a backup
+// of a busy site beats none. Once the freeze is on, it is switched off again
+// whether export succeeds, fails or panics, and a failure to switch it off
+// is returned so the run reports a site left frozen.
+func freezeDuring(site string, f ExportFreeze, wp func(args string) error, export func() error) (err error) {
+	isActive, activate, deactivate := f.commands()
+	if wp(isActive) == nil {
+		fmt.Printf("🔒 %s is already in %s; leaving it as it is\n", site, f)
+		return export()
+	}
+	fmt.Printf("🔒 Switching on %s in %s for the database export...\n", f, site)
+	if aerr := wp(activate); aerr != nil {
+		// A command that failed halfway may still have frozen the site.
+		if wp(isActive) != nil {
+			fmt.Printf("⚠️  Warning: could not switch on %s: %v; exporting without it\n", f, aerr)
+			return export()
+		}
+	}
+	defer func() {
+		fmt.Printf("🔓 Switching off %s in %s...\n", f, site)
+		if derr := wp(deactivate); derr != nil {
+			derr = fmt.Errorf("failed to switch off %s in %s, the site is still frozen: %w", f, site, derr)
+			fmt.Printf("❌ %v\n", derr)
+			err = errors.Join(err, derr)
+		}
+	}()
+	return export()
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseExportFreeze(t *testing.T) {
+	for in, want := range map[string]ExportFreeze{
+		"":                       {Mode: ExportFreezeNone},
+		"none":                   {Mode: ExportFreezeNone},
+		"maintenance":            {Mode: ExportFreezeMaintenance},
+		"plugin:wp-read-only":    {Mode: ExportFreezePlugin, Plugin: "wp-read-only"},
+		" plugin:read-only-mode": {Mode: ExportFreezePlugin, Plugin: "read-only-mode"},
+	} {
+		if got, err := ParseExportFreeze(in); err != nil || got != want {
+			t.Errorf("ParseExportFreeze(%q) = %+v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"plugin:", "plugin:x; rm -rf /", "readonly"} {
+		if _, err := ParseExportFreeze(in); err == nil {
+			t.Errorf("ParseExportFreeze(%q) succeeded", in)
+		}
+	}
+}
+
+// fakeWP runs maintenance-mode commands against *active and logs them.
+func fakeWP(active *bool, calls *[]string) func(string) error {
+	return func(args string) error {
+		*calls = append(*calls, args)
+		switch args {
+		case "maintenance-mode is-active":
+			if !*active {
+				return errors.New("exit status 1")
+			}
+		case "maintenance-mode activate":
+			*active = true
+		case "maintenance-mode deactivate":
+			*active = false
+		}
+		return nil
+	}
+}
+
+func TestFreezeDuring(t *testing.T) {
+	freeze := ExportFreeze{Mode: ExportFreezeMaintenance}
+	var active bool
+	var calls []string
+	wp := fakeWP(&active, &calls)
+
+	exportErr := errors.New("mysqldump: Got error 2013")
+	var frozen bool
+	err := freezeDuring("wp_foo", freeze, wp, func() error {
+		frozen = active
+		return exportErr
+	})
+	if !errors.Is(err, exportErr) || !frozen || active {
+		t.Fatalf("freezeDuring() = %v, frozen during export = %v, left on = %v", err, frozen, active)
+	}
+
+	func() {
+		defer func() { recover() }()
+		freezeDuring("wp_foo", freeze, wp, func() error { panic("boom") })
+	}()
+	if active {
+		t.Error("maintenance mode left on after a panic")
+	}
+
+	// A site an admin put in maintenance stays in it.
+	active, calls = true, nil
+	if err := freezeDuring("wp_foo", freeze, wp, func() error { return nil }); err != nil || !active || slices.Contains(calls, "maintenance-mode deactivate") {
+		t.Errorf("already-frozen site: err = %v, calls = %v", err, calls)
+	}
+
+	// A failed switch-off is reported.
+	active, calls = false, nil
+	err = freezeDuring("wp_foo", freeze, func(args string) error {
+		if args == "maintenance-mode deactivate" {
+			return errors.New("exit status 1")
+		}
+		return wp(args)
+	}, func() error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "still frozen") {
+		t.Errorf("freezeDuring(deactivate fails) = %v", err)
+	}
+}
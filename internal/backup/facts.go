@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupManifestName is the file written into the site directory (and thus
+// into the tarball) describing the runtime the backup was taken from.
+const BackupManifestName = ".ciwg-backup-manifest.json"
+
+// PluginFact is one entry from `wp plugin list --format=json`.
+type PluginFact struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Version string `json:"version"`
+}
+
+// BackupFacts captures the container and host environment at backup time so a
+// restore months later knows exactly which runtime produced the backup. Every
+// field is best-effort; facts that can't be gathered are left empty.
+type BackupFacts struct {
+	CreatedAt  time.Time    `json:"created_at"`
+	Host       string       `json:"host,omitempty"`
+	Kernel     string       `json:"kernel,omitempty"`
+	Container  string       `json:"container"`
+	Image      string       `json:"image,omitempty"`
+	ImageID    string       `json:"image_id,omitempty"`
+	RepoDigest string       `json:"repo_digest,omitempty"`
+	PHPVersion string       `json:"php_version,omitempty"`
+	WPVersion  string       `json:"wp_version,omitempty"`
+	Plugins    []PluginFact `json:"plugins,omitempty"`
+	// MediaOffload is set when an offload plugin keeps the uploads in object
+	// storage.
+	MediaOffload *MediaOffload `json:"media_offload,omitempty"`
+	// Multisite is set for a WordPress multisite network.
+	Multisite *MultisiteInfo `json:"multisite,omitempty"`
+	// ImportedFrom is the legacy backup this one was converted from by
+	// 'backup import'; the runtime facts of such backups are unknown.
+	ImportedFrom string `json:"imported_from,omitempty"`
+	// HostConfig is the key of the side archive holding the host's crontabs
+	// and config paths at backup time.
+	HostConfig string `json:"host_config,omitempty"`
+	// Git is set when the site is a git checkout.
+	Git *GitInfo `json:"git,omitempty"`
+}
+
+// collectBackupFacts gathers runtime facts for container. Failures are logged
+// and skipped; they never fail the backup.
+func (bm *BackupManager) collectBackupFacts(container ContainerInfo) *BackupFacts {
+	facts := &BackupFacts{CreatedAt: time.Now().UTC(), Container: container.Name}
+
+	run := func(what, cmd string) string {
+		stdout, stderr, err := bm.executeCommand(cmd)
+		if err != nil {
+			bm.logVerbose("Could not determine %s for %s: %v (stderr: %s)", what, container.Name, err, strings.TrimSpace(stderr))
+			return ""
+		}
+		return strings.TrimSpace(stdout)
+	}
+
+	facts.Host = run("hostname", "hostname")
+	facts.Kernel = run("kernel version", "uname -r")
+
+	if out := run("image", fmt.Sprintf(`docker inspect -f '{{.Config.Image}}|{{.Image}}' "%s"`, container.Name)); out != "" {
+		parts := strings.SplitN(out, "|", 2)
+		facts.Image = parts[0]
+		if len(parts) == 2 {
+			facts.ImageID = parts[1]
+			facts.RepoDigest = firstLine(run("image digest", fmt.Sprintf(`docker image inspect -f '{{range .RepoDigests}}{{println .}}{{end}}' "%s"`, facts.ImageID)))
+		}
+	}
+
+	facts.PHPVersion = run("PHP version", fmt.Sprintf(`docker exec "%s" php -r 'echo PHP_VERSION;'`, container.Name))
+
+	if container.Type == "wordpress" || container.Type == "" {
+		facts.WPVersion = run("WordPress version", fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root core version`, container.Name))
+		if out := run("plugin list", fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root plugin list --format=json`, container.Name)); out != "" {
+			plugins, err := parsePluginList(out)
+			if err != nil {
+				bm.logVerbose("Could not parse plugin list for %s: %v", container.Name, err)
+			}
+			facts.Plugins = plugins
+		}
+	}
+	return facts
+}
+
+// parsePluginList decodes `wp plugin list --format=json`, ignoring any
+// warnings wp-cli prints before the JSON array.
+func parsePluginList(out string) ([]PluginFact, error) {
+	if i := strings.Index(out, "["); i > 0 {
+		out = out[i:]
+	}
+	var plugins []PluginFact
+	if err := json.Unmarshal([]byte(out), &plugins); err != nil {
+		return nil, err
+	}
+	return plugins, nil
+}
+
+// Metadata returns the facts that fit in object user metadata. The full
+// plugin list only lives in the manifest inside the tarball.
+func (f *BackupFacts) Metadata() map[string]string {
+	if f == nil {
+		return nil
+	}
+	meta := map[string]string{}
+	set := func(k, v string) {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	set("Ciwg-Host", f.Host)
+	set("Ciwg-Kernel", f.Kernel)
+	set("Ciwg-Container", f.Container)
+	set("Ciwg-Image", f.Image)
+	set("Ciwg-Image-Id", f.ImageID)
+	set("Ciwg-Repo-Digest", f.RepoDigest)
+	set("Ciwg-Php-Version", f.PHPVersion)
+	set("Ciwg-Wp-Version", f.WPVersion)
+	set("Ciwg-Imported-From", f.ImportedFrom)
+	set("Ciwg-Host-Config", f.HostConfig)
+	if len(f.Plugins) > 0 {
+		meta["Ciwg-Plugin-Count"] = strconv.Itoa(len(f.Plugins))
+	}
+	if f.MediaOffload != nil {
+		meta["Ciwg-Media-Offload"] = f.MediaOffload.Plugin
+		set("Ciwg-Media-Remote", f.MediaOffload.Remote())
+		if f.MediaOffload.UploadsSkipped {
+			meta["Ciwg-Uploads-Skipped"] = "true"
+		}
+	}
+	if f.Git != nil {
+		meta["Ciwg-Git-Commit"] = f.Git.Commit
+		set("Ciwg-Git-Branch", f.Git.Branch)
+		if f.Git.Dirty {
+			meta["Ciwg-Git-Dirty"] = "true"
+		}
+	}
+	if f.Multisite != nil {
+		meta["Ciwg-Multisite-Sites"] = strconv.Itoa(len(f.Multisite.Sites))
+		set("Ciwg-Table-Prefix", f.Multisite.TablePrefix)
+	}
+	return meta
+}
+
+// writeBackupManifest writes facts into dir so they are captured by tar, and
+// returns the manifest path so the caller can remove it afterwards.
+func (bm *BackupManager) writeBackupManifest(dir string, facts *BackupFacts) (string, error) {
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	path := filepath.Join(dir, BackupManifestName)
+	if stderr, err := bm.executeCommandWithStdin(fmt.Sprintf(`cat > "%s"`, path), bytes.NewReader(append(data, '\n'))); err != nil {
+		return "", fmt.Errorf("failed to write backup manifest: %w (stderr: %s)", err, stderr)
+	}
+	return path, nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
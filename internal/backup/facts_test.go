@@ -0,0 +1,48 @@
+package backup
+
+import "testing"
+
+func TestParsePluginList(t *testing.T) {
+	out := "PHP Warning:  something deprecated\n" +
+		`[{"name":"akismet","status":"active","update":"none","version":"5.3"},{"name":"hello","status":"inactive","update":"available","version":"1.7.2"}]`
+	plugins, err := parsePluginList(out)
+	if err != nil {
+		t.Fatalf("parsePluginList() error = %v", err)
+	}
+	if len(plugins) != 2 || plugins[0].Name != "akismet" || plugins[1].Version != "1.7.2" {
+		t.Errorf("parsePluginList() = %+v", plugins)
+	}
+
+	if _, err := parsePluginList("Error: not a WordPress install"); err == nil {
+		t.Error("expected error for non-JSON output")
+	}
+}
+
+func TestBackupFactsMetadata(t *testing.T) {
+	var nilFacts *BackupFacts
+	if nilFacts.Metadata() != nil {
+		t.Error("nil facts should yield nil metadata")
+	}
+
+	f := &BackupFacts{
+		Container:  "wp_site",
+		Image:      "wordpress:6.4-php8.2",
+		PHPVersion: "8.2.14",
+		Plugins:    []PluginFact{{Name: "a"}, {Name: "b"}},
+	}
+	meta := f.Metadata()
+	if meta["Ciwg-Image"] != "wordpress:6.4-php8.2" || meta["Ciwg-Php-Version"] != "8.2.14" || meta["Ciwg-Plugin-Count"] != "2" {
+		t.Errorf("Metadata() = %v", meta)
+	}
+	if _, ok := meta["Ciwg-Wp-Version"]; ok {
+		t.Error("empty facts should be omitted from metadata")
+	}
+}
+
+func TestBackupFactsMetadataMediaOffload(t *testing.T) {
+	f := &BackupFacts{MediaOffload: &MediaOffload{Plugin: "wp-stateless", Provider: "gcs", Bucket: "media", UploadsSkipped: true}}
+	meta := f.Metadata()
+	if meta["Ciwg-Media-Offload"] != "wp-stateless" || meta["Ciwg-Media-Remote"] != "gs://media" || meta["Ciwg-Uploads-Skipped"] != "true" {
+		t.Errorf("Metadata() = %v", meta)
+	}
+}
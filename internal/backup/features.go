@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Features that can be rolled out host by host from the fleet file before
+// they become defaults. A feature only changes a setting left at its
+// default; an explicit flag still wins.
+const (
+	// FeatureSmartOrder archives with ArchiveOrderSmart.
+	FeatureSmartOrder = "smart-order"
+	// FeatureGlacierZstd re-compresses Glacier copies as zstd.
+	FeatureGlacierZstd = "glacier-zstd"
+	// FeaturePostUploadCheck runs the quick post-upload check.
+	FeaturePostUploadCheck = "post-upload-check"
+)
+
+// FeatureInfo describes a rollout feature for `backup features list`.
+type FeatureInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// KnownFeatures lists the rollout features, in the order they are shown.
+var KnownFeatures = []FeatureInfo{
+	{FeatureSmartOrder, "--archive-order smart unless given"},
+	{FeatureGlacierZstd, "--glacier-compression zstd unless given"},
+	{FeaturePostUploadCheck, "--post-upload-check quick unless given"},
+}
+
+// Features switches rollout features on or off by name. Names left out are
+// off.
+type Features map[string]bool
+
+// Enabled reports whether feature name is on.
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+// Names returns the features that are on, sorted.
+func (f Features) Names() []string {
+	var names []string
+	for name, on := range f {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f Features) String() string {
+	names := f.Names()
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func isKnownFeature(name string) bool {
+	for _, k := range KnownFeatures {
+		if k.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFeatures rejects unknown feature names in the fleet file, which
+// would otherwise be a rollout that silently never happens.
+func (f *Fleet) validateFeatures() error {
+	var errs []error
+	check := func(where string, features Features) {
+		for name := range features {
+			if !isKnownFeature(name) {
+				errs = append(errs, fmt.Errorf("%s: unknown feature %q", where, name))
+			}
+		}
+	}
+	check("features", f.Features)
+	for name, h := range f.Hosts {
+		check("host "+name, h.Features)
+	}
+	return errors.Join(errs...)
+}
+
+// HostFeatures returns the features of host: the fleet-wide features,
+// overridden by those of the host. host may be given as user@host.
+func (f *Fleet) HostFeatures(host string) Features {
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	features := Features{}
+	for k, v := range f.Features {
+		features[k] = v
+	}
+	for k, v := range f.Hosts[host].Features {
+		features[k] = v
+	}
+	return features
+}
+
+// SetFeatures turns on the rollout features of the host the manager backs
+// up. They are recorded in the run history.
+func (bm *BackupManager) SetFeatures(f Features) {
+	bm.features = f
+}
+
+// effectiveArchiveOrder is the archive order, or smart when the host has
+// FeatureSmartOrder.
+func (bm *BackupManager) effectiveArchiveOrder() string {
+	if bm.archiveOrder == ArchiveOrderSmart || bm.features.Enabled(FeatureSmartOrder) {
+		return ArchiveOrderSmart
+	}
+	return ArchiveOrderWalk
+}
+
+// effectiveCompression is the per-destination compression, with Glacier
+// copies in zstd when the host has FeatureGlacierZstd and no Glacier codec
+// was chosen.
+func (bm *BackupManager) effectiveCompression() compressionSettings {
+	s := bm.compression
+	if s.glacier.Codec == "" && bm.features.Enabled(FeatureGlacierZstd) {
+		s.glacier = CompressionSpec{Codec: CodecZstd}
+	}
+	return s
+}
+
+// effectivePostUploadCheck is the post-upload check of options, or the
+// quick check when none was asked for and the host has
+// FeaturePostUploadCheck.
+func (bm *BackupManager) effectivePostUploadCheck(options *BackupOptions) string {
+	if (options.PostUploadCheck == "" || options.PostUploadCheck == PostUploadCheckNone) && bm.features.Enabled(FeaturePostUploadCheck) {
+		return PostUploadCheckQuick
+	}
+	return options.PostUploadCheck
+}
@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFleetHostFeatures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	data := `features:
+  post-upload-check: true
+hosts:
+  wp1.example.com:
+    features: {smart-order: true, glacier-zstd: true}
+  wp2.example.com:
+    features: {post-upload-check: false}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fleet, err := LoadFleet(path)
+	if err != nil {
+		t.Fatalf("LoadFleet() error = %v", err)
+	}
+
+	for host, want := range map[string][]string{
+		"root@wp1.example.com": {FeatureGlacierZstd, FeaturePostUploadCheck, FeatureSmartOrder},
+		"wp2.example.com":      nil,
+		"wp3.example.com":      {FeaturePostUploadCheck},
+	} {
+		if got := fleet.HostFeatures(host).Names(); !reflect.DeepEqual(got, want) {
+			t.Errorf("HostFeatures(%s) = %v, want %v", host, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("hosts:\n  wp1.example.com:\n    features: {native-tar: true}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFleet(path); err == nil || !strings.Contains(err.Error(), `unknown feature "native-tar"`) {
+		t.Errorf("LoadFleet() error = %v, want unknown feature", err)
+	}
+}
+
+func TestFeaturesFillDefaults(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{})
+	options := &BackupOptions{PostUploadCheck: PostUploadCheckNone}
+	if bm.effectiveArchiveOrder() != ArchiveOrderWalk || bm.effectiveCompression().recompressGlacier() || bm.effectivePostUploadCheck(options) != PostUploadCheckNone {
+		t.Fatal("settings changed without features")
+	}
+
+	bm.SetFeatures(Features{FeatureSmartOrder: true, FeatureGlacierZstd: true, FeaturePostUploadCheck: true})
+	if got := bm.effectiveArchiveOrder(); got != ArchiveOrderSmart {
+		t.Errorf("effectiveArchiveOrder() = %s", got)
+	}
+	if got := bm.effectiveCompression().glacier; got.Codec != CodecZstd {
+		t.Errorf("effectiveCompression().glacier = %s", got)
+	}
+	if got := bm.effectivePostUploadCheck(options); got != PostUploadCheckQuick {
+		t.Errorf("effectivePostUploadCheck() = %s", got)
+	}
+	if !strings.Contains(bm.tarCommand("/srv/a.com", "", ""), "sort -z") {
+		t.Error("tarCommand() does not use the smart order")
+	}
+
+	// Explicit settings win.
+	if err := bm.SetCompression(CompressionSpec{}, CompressionSpec{Codec: CodecGzip, Level: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if got := bm.effectiveCompression().glacier; got.String() != "gzip-9" {
+		t.Errorf("effectiveCompression().glacier = %s, want the explicit gzip-9", got)
+	}
+	options.PostUploadCheck = PostUploadCheckFull
+	if got := bm.effectivePostUploadCheck(options); got != PostUploadCheckFull {
+		t.Errorf("effectivePostUploadCheck() = %s, want the explicit full", got)
+	}
+}
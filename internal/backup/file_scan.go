@@ -0,0 +1,215 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultScanCacheTTL is how long a site's file scan is reused by the later
+// phases of the same run (sizing, estimation, the smart-order tar walk).
+const DefaultScanCacheTTL = 10 * time.Minute
+
+// fileScan is one walk of a site directory: what `find -printf "%y %s %P"`
+// reported for every entry.
+type fileScan struct {
+	// Root is the directory that was listed: the working directory or its
+	// parent-dir fallback.
+	Root      string
+	Entries   []tarEntry
+	ScannedAt time.Time
+}
+
+// size is the apparent size of the tree, as `du -sb` reports it.
+func (s *fileScan) size() int64 {
+	var n int64
+	for _, e := range s.Entries {
+		n += e.Size
+	}
+	return n
+}
+
+// sizeUnder is the apparent size of the subtree rel (relative to Root).
+func (s *fileScan) sizeUnder(rel string) int64 {
+	if rel == "" {
+		return s.size()
+	}
+	var n int64
+	for _, e := range s.Entries {
+		if e.Path == rel || strings.HasPrefix(e.Path, rel+"/") {
+			n += e.Size
+		}
+	}
+	return n
+}
+
+// smartFileList is the scan in ArchiveOrderSmart order, NUL-separated for
+// tar --null -T -, with extra appended to the files: directories first in
+// walk order, then files by extension and path, the same order the
+// smartFileList shell pipeline produces.
+func (s *fileScan) smartFileList(extra ...string) []byte {
+	var dirs, files []string
+	for _, e := range s.Entries {
+		p := s.Root
+		if e.Path != "" {
+			p = s.Root + "/" + e.Path
+		}
+		if e.Type == 'd' {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, p)
+		}
+	}
+	for _, p := range extra {
+		if !slices.Contains(files, p) {
+			files = append(files, p)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		ei, ej := smartExtension(files[i]), smartExtension(files[j])
+		if ei != ej {
+			return ei < ej
+		}
+		return files[i] < files[j]
+	})
+	var b bytes.Buffer
+	for _, p := range append(dirs, files...) {
+		b.WriteString(p)
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// smartExtension is the sort key smartFileList's sed extracts: what follows
+// the last dot of the file name, or "" when it holds a tab.
+func smartExtension(p string) string {
+	name := p[strings.LastIndex(p, "/")+1:]
+	i := strings.LastIndex(name, ".")
+	if i < 0 || strings.Contains(name[i+1:], "\t") {
+		return ""
+	}
+	return name[i+1:]
+}
+
+// scanCache holds the file scans of the current run, keyed by the directory
+// and fallback they were requested for.
+type scanCache struct {
+	mu    sync.Mutex
+	scans map[string]*fileScan
+}
+
+func scanKey(workingDir, parentDir string) string {
+	return workingDir + "\x00" + parentDir
+}
+
+// fresh returns the scan of key if it is younger than ttl.
+func (c *scanCache) fresh(key string, ttl time.Duration) *fileScan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.scans[key]
+	if s == nil || ttl <= 0 || time.Since(s.ScannedAt) > ttl {
+		return nil
+	}
+	return s
+}
+
+// covering returns a scan younger than ttl whose root is dir or one of its
+// ancestors, with dir relative to that root.
+func (c *scanCache) covering(dir string, ttl time.Duration) (*fileScan, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.scans {
+		if ttl <= 0 || time.Since(s.ScannedAt) > ttl {
+			continue
+		}
+		if dir == s.Root {
+			return s, ""
+		}
+		if rel, ok := strings.CutPrefix(dir, s.Root+"/"); ok {
+			return s, rel
+		}
+	}
+	return nil, ""
+}
+
+func (c *scanCache) put(key string, s *fileScan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scans == nil {
+		c.scans = map[string]*fileScan{}
+	}
+	c.scans[key] = s
+}
+
+// clear drops every scan; CreateBackups calls it after each container so a
+// run never holds more than one site's listing.
+func (c *scanCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scans = nil
+}
+
+// SetScanCacheTTL lets the phases of a backup share one file scan per site:
+// the listing taken for sizing or estimation is reused by later phases for
+// up to ttl, after which it is considered stale and the directory is walked
+// again. Zero disables the cache, so every phase walks the tree itself.
+func (bm *BackupManager) SetScanCacheTTL(ttl time.Duration) {
+	bm.scanCacheTTL = ttl
+}
+
+// scanDirectory lists workingDir, or parentDir/<basename> when workingDir
+// does not exist, reusing a fresh scan from earlier in the run. The scan is
+// cached when the scan cache is enabled.
+func (bm *BackupManager) scanDirectory(workingDir, parentDir string) (*fileScan, error) {
+	key := scanKey(workingDir, parentDir)
+	if s := bm.scans.fresh(key, bm.scanCacheTTL); s != nil {
+		bm.logVerbose("Reusing file scan of %s from %s", s.Root, s.ScannedAt.Format(time.TimeOnly))
+		return s, nil
+	}
+
+	// The first field is the directory that was actually listed, which is
+	// the root tar records member names under. Entries are NUL-terminated
+	// so that no file name can break the listing.
+	list := func(dir string) string {
+		return fmt.Sprintf(`printf '%%s\0' "%[1]s"; find "%[1]s" -printf "%%y %%s %%P\0" 2>/dev/null`, dir)
+	}
+	listCmd := list(workingDir)
+	if parentDir != "" {
+		alt := filepath.Join(parentDir, filepath.Base(workingDir))
+		listCmd = fmt.Sprintf(`if [ -d "%s" ]; then %s; elif [ -d "%s" ]; then %s; fi`, workingDir, list(workingDir), alt, list(alt))
+	}
+	started := time.Now()
+	output, stderr, err := bm.executeCommand(listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w (stderr: %s)", err, stderr)
+	}
+	root, listing, _ := strings.Cut(output, "\x00")
+	if root == "" {
+		return nil, fmt.Errorf("directory %s not found", workingDir)
+	}
+	s := &fileScan{Root: root, Entries: parseFindEntriesSep(listing, "\x00"), ScannedAt: started}
+	if bm.scanCacheTTL > 0 {
+		bm.scans.put(key, s)
+	}
+	return s, nil
+}
+
+// scannedFileList returns the smart-order tar input for workingDir from a
+// fresh scan, or nil when there is none and tar has to walk the tree. The
+// backup manifest, written after the scan, is always listed.
+func (bm *BackupManager) scannedFileList(workingDir, parentDir string) []byte {
+	if bm.effectiveArchiveOrder() != ArchiveOrderSmart {
+		return nil
+	}
+	s := bm.scans.fresh(scanKey(workingDir, parentDir), bm.scanCacheTTL)
+	if s == nil {
+		return nil
+	}
+	bm.logVerbose("Archiving %s from the file scan of %s", s.Root, s.ScannedAt.Format(time.TimeOnly))
+	return s.smartFileList(s.Root + "/" + BackupManifestName)
+}
@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanCacheReuse(t *testing.T) {
+	site := makeSiteTree(t, 2)
+	bm := NewBackupManager(nil, &MinioConfig{})
+	bm.SetScanCacheTTL(time.Minute)
+
+	first, err := bm.scanDirectory(site, "")
+	if err != nil {
+		t.Fatalf("scanDirectory() error = %v", err)
+	}
+	if first.Root != site || len(first.Entries) == 0 {
+		t.Fatalf("scanDirectory() = %+v", first)
+	}
+	if again, _ := bm.scanDirectory(site, ""); again != first {
+		t.Error("scanDirectory() walked the tree again within the TTL")
+	}
+	size, err := bm.getDirectorySize(site, "")
+	if err != nil || size != first.size() {
+		t.Errorf("getDirectorySize() = %d, %v, want %d from the scan", size, err, first.size())
+	}
+	uploads, err := bm.getDirectorySize(uploadsDir(site), "")
+	if err != nil || uploads != first.sizeUnder("www/wp-content/uploads") || uploads < int64(len("excluded")) {
+		t.Errorf("getDirectorySize(uploads) = %d, %v", uploads, err)
+	}
+
+	first.ScannedAt = time.Now().Add(-2 * time.Minute)
+	if again, _ := bm.scanDirectory(site, ""); again == first {
+		t.Error("scanDirectory() reused a stale scan")
+	}
+
+	bm.scans.clear()
+	bm.SetScanCacheTTL(0)
+	if _, err := bm.scanDirectory(site, ""); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := bm.scans.covering(site, time.Minute); s != nil {
+		t.Error("scan cached with the cache disabled")
+	}
+}
+
+func TestScanParentDirFallback(t *testing.T) {
+	site := makeSiteTree(t, 1)
+	bm := NewBackupManager(nil, &MinioConfig{})
+	scan, err := bm.scanDirectory("/nonexistent/a.com", filepath.Dir(site))
+	if err != nil {
+		t.Fatalf("scanDirectory() error = %v", err)
+	}
+	if scan.Root != site {
+		t.Errorf("scanDirectory() root = %s, want the fallback %s", scan.Root, site)
+	}
+	if _, err := bm.scanDirectory("/nonexistent/a.com", ""); err == nil {
+		t.Error("scanDirectory() of a missing directory succeeded")
+	}
+}
+
+// The list built from a scan must match the smartFileList pipeline.
+func TestScanSmartFileListMatchesShell(t *testing.T) {
+	for _, tool := range []string{"find", "sed", "sort"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	site := makeSiteTree(t, 3)
+	if err := os.WriteFile(filepath.Join(site, "www", ".htaccess"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("bash", "-c", smartFileList(site)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm := NewBackupManager(nil, &MinioConfig{})
+	scan, err := bm.scanDirectory(site, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(scan.smartFileList()), "\x00"), "\x00")
+	want := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+
+	// Directories come in walk order, which only has to be parents-first;
+	// the files must be in the very same order.
+	nDirs := slices.IndexFunc(got, func(p string) bool {
+		fi, err := os.Lstat(p)
+		return err != nil || !fi.IsDir()
+	})
+	if !slices.Equal(got[nDirs:], want[nDirs:]) {
+		t.Errorf("smartFileList() files =\n%v\nwant\n%v", got[nDirs:], want[nDirs:])
+	}
+}
+
+func TestTarFromScannedList(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+	site := makeSiteTree(t, 2)
+	bm := NewBackupManager(nil, &MinioConfig{})
+	bm.SetArchiveOrder(ArchiveOrderSmart)
+	bm.SetScanCacheTTL(time.Minute)
+	if _, err := bm.scanDirectory(site, ""); err != nil {
+		t.Fatal(err)
+	}
+	list := bm.scannedFileList(site, "")
+	if list == nil {
+		t.Fatal("scannedFileList() = nil with a fresh scan")
+	}
+	// Deleted between the scan and the archive walk.
+	gone := filepath.Join(site, "www", "wp-content", "plugins", "plugin-00", "script.js")
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("bash", "-c", bm.tarListCommand(tarExcludeArgs))
+	cmd.Stdin = bytes.NewReader(list)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tar from scanned list error = %v (stderr: %s)", err, stderr.String())
+	}
+	members := tarMembers(t, out)
+	if slices.ContainsFunc(members, func(n string) bool { return strings.HasSuffix(n, ".zip") }) {
+		t.Error("excluded file archived from the scanned list")
+	}
+	if !slices.ContainsFunc(members, func(n string) bool { return strings.HasSuffix(n, "plugin-01/script.js") }) {
+		t.Errorf("members = %v, missing plugin-01/script.js", members)
+	}
+
+	bm.scans.clear()
+	if bm.scannedFileList(site, "") != nil {
+		t.Error("scannedFileList() without a scan != nil")
+	}
+}
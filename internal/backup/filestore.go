@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// fileEndpointScheme selects the filesystem backend when used as the Minio
+// endpoint, e.g. file:///mnt/nas/backups.
+const fileEndpointScheme = "file://"
+
+// IsFileEndpoint reports whether endpoint selects the local filesystem backend.
+func IsFileEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, fileEndpointScheme)
+}
+
+// fileStore stores backups as plain files under root, using the object key as
+// the relative path so the tree mirrors the bucket layout and can be synced to
+// Minio later without renaming anything.
+type fileStore struct {
+	root string
+}
+
+func newFileStore(endpoint string) (*fileStore, error) {
+	root := strings.TrimPrefix(endpoint, fileEndpointScheme)
+	if root == "" || !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("file endpoint must be an absolute path (e.g. file:///mnt/backups), got %q", endpoint)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("backup directory %s is not accessible: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("backup directory %s is not a directory", root)
+	}
+	return &fileStore{root: filepath.Clean(root)}, nil
+}
+
+// path maps an object key to a file path, refusing keys that escape root.
+func (s *fileStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.root, clean), nil
+}
+
+// put writes r to key via a temporary file and rename so readers never see a
+// partial backup, then verifies the size on disk matches what was written.
+func (s *fileStore) put(key string, r io.Reader) (int64, error) {
+	dst, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return 0, fmt.Errorf("failed to move %s into place: %w", key, err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify %s: %w", key, err)
+	}
+	if info.Size() != n {
+		return 0, fmt.Errorf("verification failed for %s: wrote %d bytes but %d are on disk", key, n, info.Size())
+	}
+	return n, nil
+}
+
+func (s *fileStore) open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// list returns every file under prefix, ordered by key. Temporary upload files
+// are skipped.
+func (s *fileStore) list(prefix string) ([]ObjectInfo, error) {
+	var out []ObjectInfo
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || hiddenFromListing(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out = append(out, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.root, err)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (s *fileStore) stat(key string) (ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if info.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("%s is not an object", key)
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+// remove deletes key and prunes any directories it leaves empty. Removing a
+// missing key is not an error, matching S3 semantics.
+func (s *fileStore) remove(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(p); dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// putObject uploads r to objectName on the configured backend and returns the
+// number of bytes stored. userMeta is stored as object metadata on Minio; the
+// filesystem backend has nowhere to keep it and ignores it.
+func (bm *BackupManager) putObject(ctx context.Context, objectName string, r io.Reader, size int64, contentType string, userMeta map[string]string) (int64, error) {
+	if err := bm.guardWrite("upload", objectName); err != nil {
+		return 0, err
+	}
+	if err := bm.checkObjectResidency(ctx, "upload", objectName, ResidencyMinio); err != nil {
+		return 0, err
+	}
+	bm.listingDirty.Store(true)
+	bm.touchSummary(objectName)
+	if bm.fileStore != nil {
+		return bm.fileStore.put(objectName, r)
+	}
+	partSize, concurrency := bm.uploadTuning()
+	opts := minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: userMeta,
+		PartSize:     uint64(partSize),
+		StorageClass: bm.storageClassPolicy().ClassFor(objectName),
+	}
+	if concurrency > 1 {
+		opts.NumThreads = uint(concurrency)
+		opts.ConcurrentStreamParts = true
+	}
+	info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, objectName, r, size, opts)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// getObject opens objectName on the configured backend.
+func (bm *BackupManager) getObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	if bm.fileStore != nil {
+		r, err := bm.fileStore.open(objectName)
+		return r, objectError("", objectName, err)
+	}
+	obj, err := bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, objectError(bm.minioConfig.Bucket, objectName, err)
+	}
+	// GetObject is lazy; Stat sends the request so a missing object is
+	// reported here rather than on the first read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, objectError(bm.minioConfig.Bucket, objectName, err)
+	}
+	return obj, nil
+}
+
+// statObject returns the size and modification time of objectName on the
+// configured backend.
+func (bm *BackupManager) statObject(ctx context.Context, objectName string) (ObjectInfo, error) {
+	if bm.fileStore != nil {
+		info, err := bm.fileStore.stat(objectName)
+		return info, objectError("", objectName, err)
+	}
+	info, err := bm.minioClient.StatObject(ctx, bm.minioConfig.Bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, objectError(bm.minioConfig.Bucket, objectName, err)
+	}
+	return ObjectInfo{Key: objectName, Size: info.Size, LastModified: info.LastModified, ETag: info.ETag}, nil
+}
+
+// listObjects lists every object under prefix on the configured backend,
+// stopping after limit objects when limit > 0.
+func (bm *BackupManager) listObjects(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error) {
+	if bm.fileStore != nil {
+		objs, err := bm.fileStore.list(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(objs) > limit {
+			objs = objs[:limit]
+		}
+		return objs, nil
+	}
+	return bm.listMinio(ctx, prefix, limit)
+}
+
+// removeObject deletes objectName from the configured backend.
+func (bm *BackupManager) removeObject(ctx context.Context, objectName string) error {
+	if err := bm.guardWrite("delete", objectName); err != nil {
+		return err
+	}
+	bm.listingDirty.Store(true)
+	bm.touchSummary(objectName)
+	if bm.fileStore != nil {
+		return bm.fileStore.remove(objectName)
+	}
+	return bm.minioClient.RemoveObject(ctx, bm.minioConfig.Bucket, objectName, minio.RemoveObjectOptions{})
+}
+
+// SyncResult summarizes a SyncTo run.
+type SyncResult struct {
+	Copied  int
+	Skipped int
+	Failed  int
+	Bytes   int64
+}
+
+// SyncTo copies objects under prefix that are missing (or differ in size) on
+// dst. It is used to push a filesystem backup tree into Minio once object
+// storage becomes available, but works between any two backends.
+func (bm *BackupManager) SyncTo(dst *BackupManager, prefix string, dryRun bool) (*SyncResult, error) {
+	src, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source objects: %w", err)
+	}
+	existing, err := dst.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination objects: %w", err)
+	}
+	have := make(map[string]int64, len(existing))
+	for _, obj := range existing {
+		have[obj.Key] = obj.Size
+	}
+
+	res := &SyncResult{}
+	ctx := context.Background()
+	for _, obj := range src {
+		if isInternalObject(obj.Key) {
+			continue
+		}
+		if size, ok := have[obj.Key]; ok && size == obj.Size {
+			res.Skipped++
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would copy %s (%.2f MB)\n", obj.Key, float64(obj.Size)/(1024*1024))
+			res.Copied++
+			res.Bytes += obj.Size
+			continue
+		}
+
+		start := time.Now()
+		r, err := bm.getObject(ctx, obj.Key)
+		if err != nil {
+			fmt.Printf("⚠ Failed to open %s: %v\n", obj.Key, err)
+			res.Failed++
+			continue
+		}
+		n, err := dst.putObject(ctx, obj.Key, r, obj.Size, "application/gzip", nil)
+		r.Close()
+		if err != nil {
+			fmt.Printf("⚠ Failed to copy %s: %v\n", obj.Key, err)
+			res.Failed++
+			continue
+		}
+		fmt.Printf("✓ Copied %s (%.2f MB) in %s\n", obj.Key, float64(n)/(1024*1024), time.Since(start).Round(time.Second))
+		res.Copied++
+		res.Bytes += n
+	}
+	return res, nil
+}
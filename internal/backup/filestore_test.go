@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFileBackedManager(t *testing.T) (*BackupManager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return NewBackupManager(nil, &MinioConfig{Endpoint: "file://" + dir}), dir
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+	for _, key := range []string{"backups/a.com/a-1.tgz", "backups/a.com/a-2.tgz", "backups/b.com/b-1.tgz"} {
+		if _, err := bm.putObject(context.Background(), key, strings.NewReader("data:"+key), -1, "application/gzip", nil); err != nil {
+			t.Fatalf("putObject(%s) error = %v", key, err)
+		}
+	}
+
+	objs, err := bm.ListBackups("backups/a.com/", 0)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(objs) != 2 || objs[0].Key != "backups/a.com/a-1.tgz" {
+		t.Fatalf("ListBackups() = %+v", objs)
+	}
+
+	r, err := bm.DownloadBackup("backups/b.com/b-1.tgz")
+	if err != nil {
+		t.Fatalf("DownloadBackup() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "data:backups/b.com/b-1.tgz" {
+		t.Errorf("DownloadBackup() content = %q", data)
+	}
+
+	if err := bm.DeleteObjects([]string{"backups/b.com/b-1.tgz", "backups/missing.tgz"}); err != nil {
+		t.Fatalf("DeleteObjects() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups", "b.com")); !os.IsNotExist(err) {
+		t.Errorf("expected empty site directory to be pruned, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups", "a.com")); err != nil {
+		t.Errorf("non-empty site directory should remain: %v", err)
+	}
+}
+
+func TestFileStorePathEscape(t *testing.T) {
+	s := &fileStore{root: "/srv/backups"}
+	p, err := s.path("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("path() error = %v", err)
+	}
+	if p != "/srv/backups/etc/passwd" {
+		t.Errorf("path() = %s, want key confined to root", p)
+	}
+	if _, err := s.path("/"); err == nil {
+		t.Error("expected error for empty key")
+	}
+}
+
+func TestNewFileStoreRequiresAbsoluteDir(t *testing.T) {
+	if _, err := newFileStore("file://relative/dir"); err == nil {
+		t.Error("expected error for relative path")
+	}
+	if _, err := newFileStore("file://" + filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestSyncTo(t *testing.T) {
+	src, _ := newFileBackedManager(t)
+	dst, _ := newFileBackedManager(t)
+	if err := src.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"backups/a.com/a-1.tgz", "backups/a.com/a-2.tgz", ".locks/upload-semaphore/t1"} {
+		if _, err := src.putObject(context.Background(), key, strings.NewReader(key), -1, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dst.putObject(context.Background(), "backups/a.com/a-1.tgz", strings.NewReader("backups/a.com/a-1.tgz"), -1, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := src.SyncTo(dst, "", false)
+	if err != nil {
+		t.Fatalf("SyncTo() error = %v", err)
+	}
+	if res.Copied != 1 || res.Skipped != 1 || res.Failed != 0 {
+		t.Errorf("SyncTo() = %+v, want 1 copied, 1 skipped", res)
+	}
+	objs, _ := dst.ListBackups("", 0)
+	if len(objs) != 2 {
+		t.Errorf("destination has %d objects, want 2 (internal objects are not synced)", len(objs))
+	}
+}
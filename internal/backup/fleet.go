@@ -0,0 +1,339 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fleet labels the hosts and sites of the fleet so commands can target a
+// subset of them with --group. It is read from the fleet file
+// (~/.ciwg/fleet.yaml):
+//
+//	features:                    # rollout features on every host
+//	  post-upload-check: true
+//	hosts:
+//	  wp1.example.com:
+//	    labels: {tier: gold}
+//	    ssh_user: deploy           # also address, ssh_port, parent_dir
+//	    rpo: 24h
+//	    features: {smart-order: true}
+//	sites:
+//	  acme.com:
+//	    host: wp1.example.com      # optional, lets --group find the host
+//	    labels: {client: acme}
+//	    rpo: 6h                    # newest backup at most this old
+//	    rto: 1h                    # restore tests at most this long
+//	    residency:                 # where its backups may be stored
+//	      regions: [eu-central-1]
+//	retention_presets:           # for --retention-preset, next to the built-ins
+//	  client-longterm:
+//	    keep_daily: 7
+//	    keep_weekly: 12
+//	    keep_monthly: 24
+//
+// A site carries the labels and recovery objectives of its host, overridden
+// by its own. A host has the fleet-wide features, overridden by its own (see
+// KnownFeatures).
+type Fleet struct {
+	Features Features             `yaml:"features,omitempty"`
+	Drill    *DrillSchedule       `yaml:"drill,omitempty"`
+	Hosts    map[string]FleetHost `yaml:"hosts"`
+	Sites    map[string]FleetSite `yaml:"sites"`
+
+	RetentionPresets map[string]RetentionPreset `yaml:"retention_presets,omitempty"`
+}
+
+// FleetHost is one host of the fleet file.
+type FleetHost struct {
+	Labels   map[string]string `yaml:"labels"`
+	RPO      string            `yaml:"rpo,omitempty"`
+	RTO      string            `yaml:"rto,omitempty"`
+	Features Features          `yaml:"features,omitempty"`
+
+	// Address, SSHUser and SSHPort override how the host is reached over
+	// SSH; ParentDir is the directory holding its sites. They are usually
+	// read from an inventory (see LoadHostInventory).
+	Address   string `yaml:"address,omitempty"`
+	SSHUser   string `yaml:"ssh_user,omitempty"`
+	SSHPort   string `yaml:"ssh_port,omitempty"`
+	ParentDir string `yaml:"parent_dir,omitempty"`
+}
+
+// FleetSite is one site of the fleet file.
+type FleetSite struct {
+	Host   string            `yaml:"host,omitempty"`
+	Labels map[string]string `yaml:"labels"`
+	RPO    string            `yaml:"rpo,omitempty"`
+	RTO    string            `yaml:"rto,omitempty"`
+	// Residency restricts the destinations of the site's backups; see
+	// SiteResidency.
+	Residency *SiteResidency `yaml:"residency,omitempty"`
+}
+
+// DefaultFleetPath returns the default location of the fleet file
+// (~/.ciwg/fleet.yaml).
+func DefaultFleetPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "fleet.yaml")
+}
+
+// LoadFleet reads the fleet file at path. A missing file yields an empty
+// fleet.
+func LoadFleet(path string) (*Fleet, error) {
+	fleet := &Fleet{}
+	if path == "" {
+		return fleet, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fleet, nil
+		}
+		return nil, fmt.Errorf("failed to read fleet file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, fleet); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet file %s: %w", path, err)
+	}
+	if err := errors.Join(fleet.validateObjectives(), fleet.validateFeatures(), fleet.validateDrill(), fleet.validateResidency(), fleet.validateRetentionPresets()); err != nil {
+		return nil, fmt.Errorf("invalid fleet file %s: %w", path, err)
+	}
+	return fleet, nil
+}
+
+// SiteLabels returns the labels of site on host: the labels of the host,
+// overridden by those of the site. host may be given as user@host; an empty
+// host means the host named in the site's entry, if any.
+func (f *Fleet) SiteLabels(site, host string) map[string]string {
+	entry := f.Sites[site]
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	if host == "" {
+		host = entry.Host
+	}
+	labels := map[string]string{}
+	for k, v := range f.Hosts[host].Labels {
+		labels[k] = v
+	}
+	for k, v := range entry.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// LabelSelector matches labels: every key=value term must be present, and a
+// bare key only requires the label to be set.
+type LabelSelector map[string]string
+
+// ParseLabelSelector parses a --group value such as "tier=gold,client=acme".
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	sel := LabelSelector{}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(term, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || (hasValue && value == "") {
+			return nil, fmt.Errorf("invalid group %q (use key=value[,key=value...])", s)
+		}
+		if !hasValue {
+			value = "*"
+		}
+		sel[key] = value
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("invalid group %q (use key=value[,key=value...])", s)
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy every term of the selector.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, want := range s {
+		got, ok := labels[k]
+		if !ok || (want != "*" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	terms := make([]string, 0, len(s))
+	for k, v := range s {
+		if v == "*" {
+			terms = append(terms, k)
+		} else {
+			terms = append(terms, k+"="+v)
+		}
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+// FleetGroup is the part of the fleet selected by one or more --group
+// selectors; a site or host belongs to it when any selector matches.
+type FleetGroup struct {
+	Fleet     *Fleet
+	Selectors []LabelSelector
+}
+
+// NewFleetGroup parses the --group values against fleet. It returns nil when
+// no values are given, which selects everything.
+func NewFleetGroup(fleet *Fleet, groups []string) (*FleetGroup, error) {
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	g := &FleetGroup{Fleet: fleet}
+	for _, s := range groups {
+		sel, err := ParseLabelSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		g.Selectors = append(g.Selectors, sel)
+	}
+	return g, nil
+}
+
+func (g *FleetGroup) String() string {
+	terms := make([]string, len(g.Selectors))
+	for i, sel := range g.Selectors {
+		terms[i] = sel.String()
+	}
+	return strings.Join(terms, " or ")
+}
+
+func (g *FleetGroup) matches(labels map[string]string) bool {
+	for _, sel := range g.Selectors {
+		if sel.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSite reports whether site, as hosted on host, is in the group. A nil
+// group has every site. An empty host means the host of the site's entry.
+func (g *FleetGroup) HasSite(site, host string) bool {
+	if g == nil {
+		return true
+	}
+	return g.matches(g.Fleet.SiteLabels(site, host))
+}
+
+// Hosts returns the hosts to visit for the group, sorted: hosts whose labels
+// match and the hosts named by matching sites.
+func (g *FleetGroup) Hosts() []string {
+	seen := map[string]bool{}
+	for host, h := range g.Fleet.Hosts {
+		if g.matches(h.Labels) {
+			seen[host] = true
+		}
+	}
+	for site, s := range g.Fleet.Sites {
+		if s.Host != "" && g.HasSite(site, "") {
+			seen[s.Host] = true
+		}
+	}
+	hosts := make([]string, 0, len(seen))
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// FilterContainers returns the containers on host whose site is in the
+// group, reporting how many were left out.
+func (g *FleetGroup) FilterContainers(containers []ContainerInfo, host string) []ContainerInfo {
+	if g == nil {
+		return containers
+	}
+	kept := containers[:0:0]
+	for _, c := range containers {
+		if g.HasSite(containerSite(c), host) {
+			kept = append(kept, c)
+		}
+	}
+	if skipped := len(containers) - len(kept); skipped > 0 {
+		fmt.Printf("⏭  %d container(s) outside group %s skipped\n", skipped, g)
+	}
+	return kept
+}
+
+// FilterRunRecords keeps the uploads of sites in the group, dropping records
+// left without any.
+func (g *FleetGroup) FilterRunRecords(records []RunRecord) []RunRecord {
+	if g == nil {
+		return records
+	}
+	var out []RunRecord
+	for _, rec := range records {
+		var uploads []UploadStats
+		for _, u := range rec.Uploads {
+			if g.HasSite(inventorySite(u.ObjectKey, u.Site), rec.Host) {
+				uploads = append(uploads, u)
+			}
+		}
+		if len(uploads) == 0 {
+			continue
+		}
+		rec.Uploads = uploads
+		out = append(out, rec)
+	}
+	return out
+}
+
+// SetGroup limits backup runs on host to the sites of group; containers of
+// other sites are skipped and recorded in the run. A nil group backs up
+// every site.
+func (bm *BackupManager) SetGroup(group *FleetGroup, host string) {
+	bm.group = group
+	bm.groupHost = host
+}
+
+// SkipReasonGroup marks containers skipped because their site is outside the
+// --group selection.
+const SkipReasonGroup = "group"
+
+// skipOutsideGroup drops containers whose site is not in the manager's group
+// and records them in the run.
+func (bm *BackupManager) skipOutsideGroup(containers []ContainerInfo) []ContainerInfo {
+	if bm.group == nil {
+		return containers
+	}
+	kept := containers[:0:0]
+	for _, c := range containers {
+		site := containerSite(c)
+		if bm.group.HasSite(site, bm.groupHost) {
+			kept = append(kept, c)
+			continue
+		}
+		if bm.verbosity >= 2 {
+			fmt.Printf("⏭  Skipping container %s: site %s is not in group %s\n", c.Name, site, bm.group)
+		}
+		if bm.lastRun != nil {
+			bm.lastRun.Skipped = append(bm.lastRun.Skipped, ContainerSkip{
+				Container: c.Name,
+				Site:      site,
+				Reason:    SkipReasonGroup,
+				Detail:    bm.group.String(),
+			})
+		}
+	}
+	if skipped := len(containers) - len(kept); skipped > 0 {
+		fmt.Printf("⏭  %d container(s) outside group %s skipped\n", skipped, bm.group)
+	}
+	return kept
+}
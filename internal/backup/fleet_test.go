@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testFleet(t *testing.T) *Fleet {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	data := `hosts:
+  wp1.example.com:
+    labels: {tier: gold}
+  wp2.example.com:
+    labels: {tier: silver}
+sites:
+  acme.com:
+    host: wp2.example.com
+    labels: {tier: gold, client: acme}
+  beta.com:
+    labels: {client: beta}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fleet, err := LoadFleet(path)
+	if err != nil {
+		t.Fatalf("LoadFleet() error = %v", err)
+	}
+	return fleet
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	sel, err := ParseLabelSelector("tier=gold, client")
+	if err != nil {
+		t.Fatalf("ParseLabelSelector() error = %v", err)
+	}
+	if !sel.Matches(map[string]string{"tier": "gold", "client": "x"}) {
+		t.Error("selector should match gold site with a client")
+	}
+	if sel.Matches(map[string]string{"tier": "gold"}) {
+		t.Error("selector should require the client label")
+	}
+	if sel.String() != "client,tier=gold" {
+		t.Errorf("String() = %q", sel.String())
+	}
+	for _, bad := range []string{"", ",", "=gold", "tier="} {
+		if _, err := ParseLabelSelector(bad); err == nil {
+			t.Errorf("ParseLabelSelector(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestFleetGroup(t *testing.T) {
+	fleet := testFleet(t)
+	group, err := NewFleetGroup(fleet, []string{"tier=gold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := group.Hosts(); !reflect.DeepEqual(got, []string{"wp1.example.com", "wp2.example.com"}) {
+		t.Errorf("Hosts() = %v", got)
+	}
+	// Sites inherit their host's labels; their own win.
+	if !group.HasSite("beta.com", "root@wp1.example.com") {
+		t.Error("beta.com on wp1 should be gold")
+	}
+	if group.HasSite("beta.com", "wp2.example.com") || group.HasSite("beta.com", "") {
+		t.Error("beta.com outside wp1 should not be gold")
+	}
+	if !group.HasSite("acme.com", "wp2.example.com") || !group.HasSite("acme.com", "") {
+		t.Error("acme.com is labelled gold")
+	}
+
+	either, _ := NewFleetGroup(fleet, []string{"client=beta", "client=acme"})
+	if !either.HasSite("beta.com", "") || !either.HasSite("acme.com", "") || either.HasSite("other.com", "wp1.example.com") {
+		t.Error("repeated groups should match any of them")
+	}
+
+	if g, err := NewFleetGroup(fleet, nil); err != nil || g != nil || !g.HasSite("anything.com", "") {
+		t.Errorf("NewFleetGroup(nil) = %v, %v; want nil group matching everything", g, err)
+	}
+}
+
+func TestFleetGroupFilters(t *testing.T) {
+	group, _ := NewFleetGroup(testFleet(t), []string{"client=acme"})
+
+	containers := []ContainerInfo{
+		{Name: "wp_acme", WorkingDir: "/var/opt/sites/acme.com"},
+		{Name: "wp_beta", WorkingDir: "/var/opt/sites/beta.com"},
+	}
+	kept := group.FilterContainers(containers, "wp2.example.com")
+	if len(kept) != 1 || kept[0].Name != "wp_acme" {
+		t.Errorf("FilterContainers() = %+v", kept)
+	}
+
+	records := []RunRecord{
+		{ID: "1", Host: "wp2.example.com", Uploads: []UploadStats{{Site: "acme.com"}, {ObjectKey: "backups/beta.com/b.tgz"}}},
+		{ID: "2", Host: "wp2.example.com", Uploads: []UploadStats{{Site: "beta.com"}}},
+	}
+	out := group.FilterRunRecords(records)
+	if len(out) != 1 || len(out[0].Uploads) != 1 || out[0].Uploads[0].Site != "acme.com" {
+		t.Errorf("FilterRunRecords() = %+v", out)
+	}
+	if len(records[0].Uploads) != 2 {
+		t.Error("FilterRunRecords() modified its input")
+	}
+}
+
+func TestSkipOutsideGroup(t *testing.T) {
+	bm := NewBackupManager(nil, nil)
+	bm.lastRun = &RunRecord{}
+	group, _ := NewFleetGroup(testFleet(t), []string{"tier=gold"})
+	bm.SetGroup(group, "wp1.example.com")
+
+	kept := bm.skipOutsideGroup([]ContainerInfo{
+		{Name: "wp_beta", WorkingDir: "/var/opt/sites/beta.com"},
+		{Name: "wp_other", WorkingDir: "/var/opt/sites/other.com"},
+	})
+	if len(kept) != 2 {
+		t.Fatalf("every site on a gold host is gold, kept %+v", kept)
+	}
+
+	bm.SetGroup(group, "wp2.example.com")
+	kept = bm.skipOutsideGroup([]ContainerInfo{
+		{Name: "wp_acme", WorkingDir: "/var/opt/sites/acme.com"},
+		{Name: "wp_other", WorkingDir: "/var/opt/sites/other.com"},
+	})
+	if len(kept) != 1 || kept[0].Name != "wp_acme" {
+		t.Fatalf("skipOutsideGroup() = %+v", kept)
+	}
+	if len(bm.lastRun.Skipped) != 1 || bm.lastRun.Skipped[0].Reason != SkipReasonGroup || bm.lastRun.Skipped[0].Site != "other.com" {
+		t.Errorf("Skipped = %+v", bm.lastRun.Skipped)
+	}
+}
+
+func TestBuildInventoryGroup(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/acme.com/acme-1.tgz", "a")
+	putTestObject(t, bm, "backups/beta.com/beta-1.tgz", "b")
+	group, _ := NewFleetGroup(testFleet(t), []string{"client=beta"})
+
+	rows, err := bm.BuildInventory(InventoryOptions{Prefix: "backups/", Group: group})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Site != "beta.com" {
+		t.Errorf("BuildInventory() = %+v", rows)
+	}
+}
@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultFreshnessJob is the Pushgateway job the site freshness gauges are
+// pushed under.
+const DefaultFreshnessJob = "ciwg_backup_freshness"
+
+// SiteFreshness is when a site was last backed up successfully, i.e. the
+// newest backup set with all of its parts, and how big that backup is.
+type SiteFreshness struct {
+	Site        string    `json:"site"`
+	LastSuccess time.Time `json:"last_success"`
+	LatestBytes int64     `json:"latest_bytes"`
+}
+
+// FreshnessMetricsConfig selects where the per-site freshness gauges go.
+// Either destination may be empty.
+type FreshnessMetricsConfig struct {
+	// File is replaced with the gauges in Prometheus text format (e.g. for
+	// node_exporter's textfile collector).
+	File string
+	// PushgatewayURL receives the gauges under DefaultFreshnessJob, grouped
+	// by bucket, replacing the previous push.
+	PushgatewayURL string
+	// Prefix is where the site directories are listed (default "backups/").
+	Prefix string
+}
+
+// Enabled reports whether the gauges go anywhere.
+func (c FreshnessMetricsConfig) Enabled() bool {
+	return c.File != "" || c.PushgatewayURL != ""
+}
+
+// SiteFreshness returns the freshness of every site under prefix, sorted by
+// site. Sites without a complete backup are left out.
+func (bm *BackupManager) SiteFreshness(prefix string) ([]SiteFreshness, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return siteFreshness(objs), nil
+}
+
+// siteFreshness returns the freshness of the sites of objs.
+func siteFreshness(objs []ObjectInfo) []SiteFreshness {
+	bySite := map[string][]ObjectInfo{}
+	for _, o := range objs {
+		if !isInternalObject(o.Key) {
+			site := ObjectSite(o.Key)
+			bySite[site] = append(bySite[site], o)
+		}
+	}
+	var sites []SiteFreshness
+	for site, objs := range bySite {
+		if set, ok := latestCompleteSet(objs); ok {
+			sites = append(sites, SiteFreshness{Site: site, LastSuccess: set.LastModified, LatestBytes: set.Size})
+		}
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Site < sites[j].Site })
+	return sites
+}
+
+// formatFreshnessMetrics renders sites as Prometheus text exposition, one
+// series per site, so alerting rules can be written per site.
+func formatFreshnessMetrics(sites []SiteFreshness, bucket string, now time.Time) string {
+	var b bytes.Buffer
+	write := func(name, help string, value func(SiteFreshness) any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, s := range sites {
+			fmt.Fprintf(&b, "%s{bucket=%q,site=%q} %v\n", name, bucket, s.Site, value(s))
+		}
+	}
+	write("ciwg_backup_site_last_success_age_seconds", "Seconds since the site's last complete backup.", func(s SiteFreshness) any {
+		return int64(now.Sub(s.LastSuccess).Seconds())
+	})
+	write("ciwg_backup_site_last_success_timestamp_seconds", "Unix time of the site's last complete backup.", func(s SiteFreshness) any {
+		return s.LastSuccess.Unix()
+	})
+	write("ciwg_backup_site_latest_size_bytes", "Size of the site's last complete backup.", func(s SiteFreshness) any {
+		return s.LatestBytes
+	})
+	fmt.Fprintf(&b, "# HELP ciwg_backup_freshness_updated_timestamp_seconds Unix time the site freshness gauges were computed.\n# TYPE ciwg_backup_freshness_updated_timestamp_seconds gauge\nciwg_backup_freshness_updated_timestamp_seconds{bucket=%q} %d\n", bucket, now.Unix())
+	return b.String()
+}
+
+// ExportSiteFreshness computes the freshness of every site and writes the
+// gauges to the metrics file and/or Pushgateway of cfg.
+func (bm *BackupManager) ExportSiteFreshness(cfg FreshnessMetricsConfig) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "backups/"
+	}
+	sites, err := bm.SiteFreshness(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to compute site freshness: %w", err)
+	}
+	if err := bm.writeFreshnessMetrics(cfg, formatFreshnessMetrics(sites, bm.minioConfig.Bucket, time.Now())); err != nil {
+		return err
+	}
+	bm.logVerbose("Exported freshness of %d site(s)", len(sites))
+	return nil
+}
+
+// writeFreshnessMetrics writes text to the metrics file and/or Pushgateway
+// of cfg.
+func (bm *BackupManager) writeFreshnessMetrics(cfg FreshnessMetricsConfig, text string) error {
+	var errs []string
+	if cfg.File != "" {
+		if err := writeMetricsFile(cfg.File, text); err != nil {
+			errs = append(errs, fmt.Sprintf("metrics file: %v", err))
+		}
+	}
+	if cfg.PushgatewayURL != "" {
+		if err := pushMetrics(cfg.PushgatewayURL, DefaultFreshnessJob, bm.minioConfig.Bucket, text); err != nil {
+			errs = append(errs, fmt.Sprintf("pushgateway: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to export site freshness: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// pushMetrics replaces the metrics of the job's group on a Pushgateway with
+// text; the group is keyed by bucket so several buckets can share a job.
+func pushMetrics(gateway, job, bucket, text string) error {
+	u := strings.TrimRight(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	if bucket != "" {
+		u += "/bucket/" + url.PathEscape(bucket)
+	}
+	req, err := http.NewRequest(http.MethodPut, u, strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
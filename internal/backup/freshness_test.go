@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSiteFreshness(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	bm.minioConfig.Bucket = "wp-backups"
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, k := range []string{
+		"backups/a.com/a.com-20260401-020000.tgz",
+		"backups/a.com/a.com-20260402-020000.tgz",
+		"backups/b.com/b.com-20260402-020000.tgz",
+		"backups/c.com/c.com-20260402-020000.db.sql.gz",
+	} {
+		if _, err := bm.putObject(ctx, k, strings.NewReader("data:"+k), -1, "application/gzip", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sites, err := bm.SiteFreshness("backups/")
+	if err != nil {
+		t.Fatalf("SiteFreshness() error = %v", err)
+	}
+	if len(sites) != 2 || sites[0].Site != "a.com" || sites[1].Site != "b.com" {
+		t.Fatalf("SiteFreshness() = %+v, want a.com and b.com without an incomplete c.com", sites)
+	}
+	if want := int64(len("data:backups/a.com/a.com-20260402-020000.tgz")); sites[0].LatestBytes != want {
+		t.Errorf("LatestBytes = %d, want the newest backup's %d", sites[0].LatestBytes, want)
+	}
+
+	text := formatFreshnessMetrics(sites, "wp-backups", sites[0].LastSuccess.Add(90*time.Second))
+	if !strings.Contains(text, `ciwg_backup_site_last_success_age_seconds{bucket="wp-backups",site="a.com"} 90`) {
+		t.Errorf("metrics lack a.com's age:\n%s", text)
+	}
+
+	var pushed, path string
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushed, path = string(body), r.Method+" "+r.URL.Path
+	}))
+	defer gw.Close()
+	file := filepath.Join(t.TempDir(), "freshness.prom")
+	if err := bm.ExportSiteFreshness(FreshnessMetricsConfig{File: file, PushgatewayURL: gw.URL}); err != nil {
+		t.Fatalf("ExportSiteFreshness() error = %v", err)
+	}
+	if path != "PUT /metrics/job/"+DefaultFreshnessJob+"/bucket/wp-backups" {
+		t.Errorf("pushed to %s", path)
+	}
+	written, err := os.ReadFile(file)
+	if err != nil || string(written) != pushed || !strings.Contains(pushed, `ciwg_backup_site_latest_size_bytes{bucket="wp-backups",site="b.com"}`) {
+		t.Errorf("metrics file = %q (%v), pushed %q", written, err, pushed)
+	}
+
+	if err := bm.ExportSiteFreshness(FreshnessMetricsConfig{PushgatewayURL: "http://127.0.0.1:1"}); err == nil {
+		t.Error("ExportSiteFreshness() error = nil with an unreachable Pushgateway")
+	}
+}
@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultGCMinAge leaves zero-byte objects younger than this alone: an upload
+// that was just created may still be replaced.
+const DefaultGCMinAge = time.Hour
+
+// Reasons an object is collected by gc.
+const (
+	GCReasonZeroByte = "zero-byte"
+	GCReasonMarker   = "empty-prefix"
+	GCReasonStale    = "stale-prefix"
+)
+
+// GCOptions configures CollectGarbage.
+type GCOptions struct {
+	// Prefix limits gc to the objects under it (default backups/).
+	Prefix string
+	// MinAge is the age a zero-byte object must reach to be collected.
+	MinAge time.Duration
+	// StaleAfter marks a site prefix stale when its newest object is older;
+	// zero disables the check.
+	StaleAfter time.Duration
+	// PruneStale deletes the objects of stale prefixes. Without it they are
+	// only reported.
+	PruneStale bool
+	Group      *FleetGroup
+	DryRun     bool
+}
+
+// GCObject is an object gc collects.
+type GCObject struct {
+	Key          string    `json:"key"`
+	Site         string    `json:"site,omitempty"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Reason       string    `json:"reason"`
+}
+
+// StalePrefix is a site prefix without objects newer than GCOptions.StaleAfter,
+// typically left by a deleted site.
+type StalePrefix struct {
+	Site    string    `json:"site"`
+	Prefix  string    `json:"prefix"`
+	Objects int       `json:"objects"`
+	Bytes   int64     `json:"bytes"`
+	Newest  time.Time `json:"newest"`
+	// Skipped explains why the prefix was left alone, e.g. maintenance.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// GCReport is the outcome of CollectGarbage.
+type GCReport struct {
+	ZeroByte      []GCObject    `json:"zero_byte"`
+	StalePrefixes []StalePrefix `json:"stale_prefixes"`
+	// EmptyDirs counts the directories a filesystem backend had left without
+	// files.
+	EmptyDirs  int   `json:"empty_dirs"`
+	Deleted    int   `json:"deleted"`
+	Failed     int   `json:"failed"`
+	FreedBytes int64 `json:"freed_bytes"`
+	DryRun     bool  `json:"dry_run,omitempty"`
+}
+
+// PlanGC finds the zero-byte objects and stale site prefixes among objs as
+// of now. Internal objects (locks, state) are never collected.
+func PlanGC(objs []ObjectInfo, opts GCOptions, now time.Time) *GCReport {
+	rep := &GCReport{ZeroByte: []GCObject{}, StalePrefixes: []StalePrefix{}, DryRun: opts.DryRun}
+	stale := map[string]*StalePrefix{}
+	var sites []string
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		site := inventorySite(strings.TrimSuffix(o.Key, "/"), "")
+		if strings.HasSuffix(o.Key, "/") {
+			// A directory marker names the prefix itself.
+			site = path.Base(strings.TrimSuffix(o.Key, "/"))
+		}
+		if !opts.Group.HasSite(site, "") {
+			continue
+		}
+		if o.Size == 0 && now.Sub(o.LastModified) >= opts.MinAge {
+			reason := GCReasonZeroByte
+			if strings.HasSuffix(o.Key, "/") {
+				reason = GCReasonMarker
+			}
+			rep.ZeroByte = append(rep.ZeroByte, GCObject{Key: o.Key, Site: site, LastModified: o.LastModified, Reason: reason})
+			continue
+		}
+		if opts.StaleAfter <= 0 || o.Size == 0 || site == "" {
+			continue
+		}
+		sp, ok := stale[site]
+		if !ok {
+			sp = &StalePrefix{Site: site, Prefix: path.Dir(o.Key) + "/"}
+			stale[site] = sp
+			sites = append(sites, site)
+		}
+		sp.Objects++
+		sp.Bytes += o.Size
+		if o.LastModified.After(sp.Newest) {
+			sp.Newest = o.LastModified
+		}
+	}
+	sort.Strings(sites)
+	for _, site := range sites {
+		if sp := stale[site]; now.Sub(sp.Newest) > opts.StaleAfter {
+			rep.StalePrefixes = append(rep.StalePrefixes, *sp)
+		}
+	}
+	return rep
+}
+
+// CollectGarbage deletes zero-byte objects (failed uploads and empty prefix
+// markers) under opts.Prefix and reports site prefixes without recent
+// backups, deleting their objects too with opts.PruneStale. Stale prefixes
+// of sites in maintenance are left alone. On a filesystem backend it also
+// removes directories left empty.
+func (bm *BackupManager) CollectGarbage(opts GCOptions) (*GCReport, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "backups/"
+	}
+	ctx := context.Background()
+	objs, err := bm.listObjects(ctx, opts.Prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", opts.Prefix, err)
+	}
+	now := time.Now()
+	rep := PlanGC(objs, opts, now)
+
+	if len(rep.StalePrefixes) > 0 {
+		maintenance, err := bm.MaintenanceSites(now)
+		if err != nil {
+			return nil, err
+		}
+		for i := range rep.StalePrefixes {
+			if e, ok := maintenance[rep.StalePrefixes[i].Site]; ok {
+				rep.StalePrefixes[i].Skipped = "maintenance " + e.String()
+			}
+		}
+	}
+
+	remove := func(key string, size int64) {
+		if opts.DryRun {
+			fmt.Printf("[DRY RUN] Would delete %s\n", key)
+			return
+		}
+		if err := bm.removeBackupObject(ctx, key); err != nil {
+			fmt.Printf("⚠️  Failed to delete %s: %v\n", key, err)
+			rep.Failed++
+			return
+		}
+		rep.Deleted++
+		rep.FreedBytes += size
+	}
+	for _, o := range rep.ZeroByte {
+		remove(o.Key, 0)
+	}
+	if opts.PruneStale {
+		for _, sp := range rep.StalePrefixes {
+			if sp.Skipped != "" {
+				continue
+			}
+			for _, o := range objs {
+				if strings.HasPrefix(o.Key, sp.Prefix) && o.Size > 0 && !isInternalObject(o.Key) {
+					remove(o.Key, o.Size)
+				}
+			}
+		}
+	}
+
+	if bm.fileStore != nil {
+		n, err := bm.fileStore.pruneEmptyDirs(opts.Prefix, opts.DryRun)
+		if err != nil {
+			return rep, err
+		}
+		rep.EmptyDirs = n
+	}
+	return rep, nil
+}
+
+// pruneEmptyDirs removes the directories under prefix that hold no files,
+// deepest first, and returns how many there were.
+func (s *fileStore) pruneEmptyDirs(prefix string, dryRun bool) (int, error) {
+	var dirs []string
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == s.root {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel) + "/"
+		if strings.HasPrefix(key, prefix) && !isInternalObject(key) {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to walk %s: %w", s.root, err)
+	}
+	// Children sort after their parents; walk backwards so a directory is
+	// emptied of empty subdirectories before it is checked.
+	n := 0
+	removed := map[string]bool{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return n, err
+		}
+		empty := true
+		for _, e := range entries {
+			if !removed[filepath.Join(dirs[i], e.Name())] {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("[DRY RUN] Would remove empty directory %s\n", dirs[i])
+		} else if err := os.Remove(dirs[i]); err != nil {
+			return n, err
+		}
+		removed[dirs[i]] = true
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanGC(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "backups/acme.com/acme-20260430.tgz", Size: 100, LastModified: now.Add(-24 * time.Hour)},
+		{Key: "backups/acme.com/acme-20260501.tgz", Size: 0, LastModified: now.Add(-2 * time.Hour)},
+		{Key: "backups/acme.com/acme-now.tgz", Size: 0, LastModified: now.Add(-time.Minute)},
+		{Key: "backups/gone.com/", Size: 0, LastModified: now.Add(-90 * 24 * time.Hour)},
+		{Key: "backups/gone.com/gone-20260101.tgz", Size: 50, LastModified: now.Add(-120 * 24 * time.Hour)},
+		{Key: ".locks/upload-semaphore/t1", Size: 0, LastModified: now.Add(-48 * time.Hour)},
+	}
+	rep := PlanGC(objs, GCOptions{MinAge: time.Hour, StaleAfter: 60 * 24 * time.Hour}, now)
+
+	if len(rep.ZeroByte) != 2 {
+		t.Fatalf("PlanGC() zero-byte = %+v, want the old failed upload and the marker", rep.ZeroByte)
+	}
+	if rep.ZeroByte[0].Site != "acme.com" || rep.ZeroByte[1].Reason != GCReasonMarker || rep.ZeroByte[1].Site != "gone.com" {
+		t.Errorf("PlanGC() zero-byte = %+v", rep.ZeroByte)
+	}
+	if len(rep.StalePrefixes) != 1 || rep.StalePrefixes[0].Prefix != "backups/gone.com/" || rep.StalePrefixes[0].Bytes != 50 {
+		t.Errorf("PlanGC() stale = %+v, want gone.com only", rep.StalePrefixes)
+	}
+
+	if rep := PlanGC(objs, GCOptions{MinAge: time.Hour}, now); len(rep.StalePrefixes) != 0 {
+		t.Errorf("PlanGC() without StaleAfter reported %+v", rep.StalePrefixes)
+	}
+}
+
+func TestCollectGarbageFileStore(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+	old := time.Now().Add(-200 * 24 * time.Hour)
+	write := func(key, data string) {
+		p := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("backups/acme.com/acme-1.tgz", "")
+	write("backups/old.com/old-1.tgz", "data")
+	if err := os.MkdirAll(filepath.Join(dir, "backups", "empty.com", "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := bm.CollectGarbage(GCOptions{MinAge: time.Hour, StaleAfter: 30 * 24 * time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("CollectGarbage(dry run) error = %v", err)
+	}
+	if rep.Deleted != 0 || rep.EmptyDirs != 2 || len(rep.StalePrefixes) != 1 {
+		t.Fatalf("CollectGarbage(dry run) = %+v", rep)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backups", "acme.com", "acme-1.tgz")); err != nil {
+		t.Fatal("dry run deleted the zero-byte object")
+	}
+
+	rep, err = bm.CollectGarbage(GCOptions{MinAge: time.Hour, StaleAfter: 30 * 24 * time.Hour, PruneStale: true})
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if rep.Deleted != 2 || rep.FreedBytes != 4 {
+		t.Errorf("CollectGarbage() = %+v, want the zero-byte object and the stale backup deleted", rep)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "backups"))
+	if err == nil && len(entries) != 0 {
+		t.Errorf("backups/ still holds %d entries", len(entries))
+	}
+}
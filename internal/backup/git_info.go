@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GitInfo is the state of the git checkout a site was deployed from when it
+// was backed up, so a restore can be matched with the deployed code.
+type GitInfo struct {
+	Commit string `json:"commit"`
+	// Branch is empty for a detached HEAD.
+	Branch string `json:"branch,omitempty"`
+	// Dirty is set when tracked files had uncommitted changes.
+	Dirty bool `json:"dirty"`
+	// Excluded is set when .git was left out of the archive; the code has
+	// to be checked out from the repository at Commit on restore.
+	Excluded bool `json:"excluded,omitempty"`
+}
+
+// gitInfoCommand prints the commit, branch and number of changed tracked
+// files of the checkout in dir (or parentDir/<basename of dir> when dir does
+// not exist), or nothing when it is not a git checkout. safe.directory
+// allows reading checkouts owned by the deploy user as root.
+func gitInfoCommand(dir, parentDir string) string {
+	fallback := dir
+	if parentDir != "" {
+		fallback = filepath.Join(parentDir, filepath.Base(dir))
+	}
+	git := `git -c safe.directory='*'`
+	return fmt.Sprintf(`d="%s"; [ -d "$d" ] || d="%s"; cd "$d" 2>/dev/null && [ -e .git ] || exit 0; `+
+		`%s rev-parse HEAD && %s rev-parse --abbrev-ref HEAD && %s status --porcelain --untracked-files=no | wc -l`,
+		dir, fallback, git, git, git)
+}
+
+// parseGitInfo parses the output of gitInfoCommand; nil means no checkout.
+func parseGitInfo(out string) (*GitInfo, error) {
+	lines := strings.Fields(out)
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if len(lines) != 3 {
+		return nil, fmt.Errorf("unexpected git output %q", out)
+	}
+	changed, err := strconv.Atoi(lines[2])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected git status count %q", lines[2])
+	}
+	info := &GitInfo{Commit: lines[0], Branch: lines[1], Dirty: changed > 0}
+	if info.Branch == "HEAD" {
+		info.Branch = ""
+	}
+	return info, nil
+}
+
+// detectGitInfo returns the git state of the site in dir, or nil when it is
+// not a git checkout or git is not available on the host.
+func (bm *BackupManager) detectGitInfo(dir, parentDir string) *GitInfo {
+	stdout, stderr, err := bm.executeCommand(gitInfoCommand(dir, parentDir))
+	if err != nil {
+		bm.logVerbose("Could not read the git state of %s: %v (stderr: %s)", dir, err, strings.TrimSpace(stderr))
+		return nil
+	}
+	info, err := parseGitInfo(stdout)
+	if err != nil {
+		bm.logVerbose("Could not read the git state of %s: %v", dir, err)
+		return nil
+	}
+	return info
+}
+
+// String describes the checkout for the backup log, e.g. "main@1a2b3c4d
+// (dirty)".
+func (g *GitInfo) String() string {
+	commit := g.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	s := commit
+	if g.Branch != "" {
+		s = g.Branch + "@" + commit
+	}
+	if g.Dirty {
+		s += " (dirty)"
+	}
+	return s
+}
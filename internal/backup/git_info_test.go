@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGitInfo(t *testing.T) {
+	info, err := parseGitInfo("1a2b3c4d5e6f7a8b9c0d1a2b3c4d5e6f7a8b9c0d\nmain\n       2\n")
+	if err != nil || info == nil || info.Branch != "main" || !info.Dirty {
+		t.Fatalf("parseGitInfo() = %+v, %v", info, err)
+	}
+	if got := info.String(); got != "main@1a2b3c4d5e6f (dirty)" {
+		t.Errorf("String() = %q", got)
+	}
+	info, _ = parseGitInfo("1a2b3c4d\nHEAD\n0\n")
+	if info.Branch != "" || info.Dirty {
+		t.Errorf("parseGitInfo(detached) = %+v", info)
+	}
+	if info, err := parseGitInfo(""); info != nil || err != nil {
+		t.Errorf("parseGitInfo(no checkout) = %+v, %v", info, err)
+	}
+	if _, err := parseGitInfo("fatal: not a git repository"); err == nil {
+		t.Error("parseGitInfo(garbage) error = nil")
+	}
+
+	meta := (&BackupFacts{Git: &GitInfo{Commit: "1a2b3c4d", Branch: "main", Dirty: true}}).Metadata()
+	if meta["Ciwg-Git-Commit"] != "1a2b3c4d" || meta["Ciwg-Git-Branch"] != "main" || meta["Ciwg-Git-Dirty"] != "true" {
+		t.Errorf("Metadata() = %v", meta)
+	}
+}
+
+func TestDetectGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	parent := t.TempDir()
+	site := filepath.Join(parent, "foo.com")
+	if err := os.Mkdir(site, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	bm := NewBackupManager(nil, nil)
+	if info := bm.detectGitInfo(site, ""); info != nil {
+		t.Errorf("detectGitInfo(plain dir) = %+v", info)
+	}
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", site, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "deploy")
+	os.WriteFile(filepath.Join(site, "index.php"), []byte("<?php"), 0o644)
+	git("add", "index.php")
+	git("commit", "-q", "-m", "initial")
+
+	info := bm.detectGitInfo(filepath.Join(t.TempDir(), "foo.com"), parent)
+	if info == nil || len(info.Commit) != 40 || info.Branch != "deploy" || info.Dirty {
+		t.Fatalf("detectGitInfo(parent fallback) = %+v", info)
+	}
+	os.WriteFile(filepath.Join(site, "index.php"), []byte("<?php // hotfix"), 0o644)
+	if info := bm.detectGitInfo(site, ""); info == nil || !info.Dirty {
+		t.Errorf("detectGitInfo(modified) = %+v, want dirty", info)
+	}
+}
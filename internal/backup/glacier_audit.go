@@ -0,0 +1,344 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// GlacierInventory is the JSON document produced by a Glacier
+// inventory-retrieval job (`aws glacier get-job-output`).
+type GlacierInventory struct {
+	VaultARN      string           `json:"VaultARN"`
+	InventoryDate time.Time        `json:"InventoryDate"`
+	ArchiveList   []GlacierArchive `json:"ArchiveList"`
+}
+
+// GlacierArchive is one archive in a vault inventory.
+type GlacierArchive struct {
+	ArchiveID          string    `json:"ArchiveId"`
+	ArchiveDescription string    `json:"ArchiveDescription"`
+	CreationDate       time.Time `json:"CreationDate"`
+	Size               int64     `json:"Size"`
+	SHA256TreeHash     string    `json:"SHA256TreeHash"`
+}
+
+// ObjectKey recovers the Minio object key from the archive description
+// written by uploadToAWS ("Backup: <key>") or the migration ("Migrated from
+// Minio: <key>").
+func (a GlacierArchive) ObjectKey() string {
+	d, _ := ParseGlacierDescription(a.ArchiveDescription)
+	return d.ObjectKey
+}
+
+// ReadGlacierInventory parses a vault inventory document.
+func ReadGlacierInventory(r io.Reader) (*GlacierInventory, error) {
+	var inv GlacierInventory
+	if err := json.NewDecoder(r).Decode(&inv); err != nil {
+		return nil, fmt.Errorf("failed to parse Glacier inventory: %w", err)
+	}
+	return &inv, nil
+}
+
+// InitiateGlacierInventory starts an inventory-retrieval job for the vault and
+// returns its job ID. Glacier typically completes these in 3-5 hours.
+func (bm *BackupManager) InitiateGlacierInventory() (string, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return "", err
+	}
+	out, err := bm.awsClient.InitiateJob(context.Background(), &glacier.InitiateJobInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobParameters: &types.JobParameters{
+			Type:   aws.String("inventory-retrieval"),
+			Format: aws.String("JSON"),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate inventory job: %w", err)
+	}
+	return aws.ToString(out.JobId), nil
+}
+
+// FetchGlacierInventory downloads the output of a completed inventory job.
+func (bm *BackupManager) FetchGlacierInventory(jobID string) (*GlacierInventory, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	job, err := bm.awsClient.DescribeJob(ctx, &glacier.DescribeJobInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobId:     aws.String(jobID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe job %s: %w", jobID, err)
+	}
+	if !job.Completed {
+		return nil, fmt.Errorf("inventory job %s is not complete yet (status: %s); try again later", jobID, job.StatusCode)
+	}
+	out, err := bm.awsClient.GetJobOutput(ctx, &glacier.GetJobOutputInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobId:     aws.String(jobID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output of job %s: %w", jobID, err)
+	}
+	defer out.Body.Close()
+	return ReadGlacierInventory(out.Body)
+}
+
+func (bm *BackupManager) glacierAccountID() string {
+	if bm.awsConfig.AccountID == "" {
+		return "-"
+	}
+	return bm.awsConfig.AccountID
+}
+
+// LedgerEntry is a Glacier upload recorded in the run history.
+type LedgerEntry struct {
+	RunID      string    `json:"run_id"`
+	ObjectKey  string    `json:"object_key"`
+	ArchiveID  string    `json:"archive_id"`
+	TreeHash   string    `json:"tree_hash,omitempty"`
+	Bytes      int64     `json:"bytes"`
+	RecordedAt time.Time `json:"recorded_at"`
+	// Verification is the checksum verification state recorded at upload.
+	Verification string `json:"verification,omitempty"`
+}
+
+// GlacierLedger extracts the Glacier uploads with an archive ID from the run
+// history.
+func GlacierLedger(records []RunRecord) []LedgerEntry {
+	var entries []LedgerEntry
+	for _, rec := range records {
+		for _, u := range rec.Uploads {
+			if u.Glacier == nil || u.Glacier.ArchiveID == "" {
+				continue
+			}
+			recorded := rec.FinishedAt
+			if !u.Glacier.CreatedAt.IsZero() {
+				recorded = u.Glacier.CreatedAt
+			}
+			entries = append(entries, LedgerEntry{
+				RunID:        rec.ID,
+				ObjectKey:    u.ObjectKey,
+				ArchiveID:    u.Glacier.ArchiveID,
+				TreeHash:     u.Glacier.TreeHash,
+				Bytes:        u.Glacier.Bytes,
+				RecordedAt:   recorded,
+				Verification: u.Glacier.Verification,
+			})
+		}
+	}
+	return entries
+}
+
+// GlacierMismatch pairs a ledger entry with the vault archive it disagrees with.
+type GlacierMismatch struct {
+	Entry   LedgerEntry    `json:"ledger"`
+	Archive GlacierArchive `json:"vault"`
+}
+
+// GlacierAuditReport is the reconciliation of a vault inventory and the ledger.
+type GlacierAuditReport struct {
+	InventoryDate time.Time `json:"inventory_date"`
+	Matched       int       `json:"matched"`
+	// UnknownArchives are in the vault but not in the ledger.
+	UnknownArchives []GlacierArchive `json:"unknown_archives"`
+	// MissingArchives are in the ledger but not in the vault.
+	MissingArchives []LedgerEntry `json:"missing_archives"`
+	// TooRecent are ledger entries newer than the inventory, which cannot be
+	// checked until the next inventory.
+	TooRecent      []LedgerEntry     `json:"too_recent"`
+	SizeMismatches []GlacierMismatch `json:"size_mismatches"`
+	HashMismatches []GlacierMismatch `json:"hash_mismatches"`
+}
+
+// Clean reports whether the vault and ledger agree.
+func (r *GlacierAuditReport) Clean() bool {
+	return len(r.UnknownArchives) == 0 && len(r.MissingArchives) == 0 &&
+		len(r.SizeMismatches) == 0 && len(r.HashMismatches) == 0
+}
+
+// AuditGlacierInventory diffs the vault inventory against the ledger.
+func AuditGlacierInventory(inv *GlacierInventory, ledger []LedgerEntry) *GlacierAuditReport {
+	report := &GlacierAuditReport{InventoryDate: inv.InventoryDate}
+
+	vault := make(map[string]GlacierArchive, len(inv.ArchiveList))
+	for _, a := range inv.ArchiveList {
+		vault[a.ArchiveID] = a
+	}
+	known := make(map[string]bool, len(ledger))
+	for _, e := range ledger {
+		known[e.ArchiveID] = true
+		a, ok := vault[e.ArchiveID]
+		switch {
+		case !ok && !inv.InventoryDate.IsZero() && e.RecordedAt.After(inv.InventoryDate):
+			report.TooRecent = append(report.TooRecent, e)
+		case !ok:
+			report.MissingArchives = append(report.MissingArchives, e)
+		case e.Bytes != a.Size:
+			report.SizeMismatches = append(report.SizeMismatches, GlacierMismatch{Entry: e, Archive: a})
+		case e.TreeHash != "" && a.SHA256TreeHash != "" && !strings.EqualFold(e.TreeHash, a.SHA256TreeHash):
+			report.HashMismatches = append(report.HashMismatches, GlacierMismatch{Entry: e, Archive: a})
+		default:
+			report.Matched++
+		}
+	}
+	for _, a := range inv.ArchiveList {
+		if !known[a.ArchiveID] {
+			report.UnknownArchives = append(report.UnknownArchives, a)
+		}
+	}
+	sort.Slice(report.UnknownArchives, func(i, j int) bool {
+		return report.UnknownArchives[i].CreationDate.Before(report.UnknownArchives[j].CreationDate)
+	})
+	return report
+}
+
+// RepairGlacierLedger brings the history file in line with the vault: Glacier
+// stats of archives missing from the vault are dropped, sizes and tree hashes
+// are corrected from the inventory, and unknown archives are adopted in a new
+// run record so later commands (inventory export, targeted deletes) see them.
+// The file is rewritten atomically.
+func RepairGlacierLedger(historyPath string, report *GlacierAuditReport) (*RunRecord, error) {
+	records, err := LoadRunRecords(historyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string]bool, len(report.MissingArchives))
+	for _, e := range report.MissingArchives {
+		missing[e.ArchiveID] = true
+	}
+	fix := make(map[string]GlacierArchive)
+	for _, m := range append(append([]GlacierMismatch{}, report.SizeMismatches...), report.HashMismatches...) {
+		fix[m.Archive.ArchiveID] = m.Archive
+	}
+	for i := range records {
+		for j := range records[i].Uploads {
+			g := records[i].Uploads[j].Glacier
+			if g == nil {
+				continue
+			}
+			if missing[g.ArchiveID] {
+				records[i].Uploads[j].Glacier = nil
+				continue
+			}
+			if a, ok := fix[g.ArchiveID]; ok {
+				g.Bytes = a.Size
+				g.TreeHash = a.SHA256TreeHash
+			}
+		}
+	}
+
+	adopted := adoptArchives("ledger-repair-", "aws-audit", report.UnknownArchives)
+	if adopted != nil {
+		records = append(records, *adopted)
+	}
+
+	if err := writeRunRecords(historyPath, records); err != nil {
+		return nil, err
+	}
+	return adopted, nil
+}
+
+// BackfillGlacierLedger adopts the unknown archives of report whose
+// description was written by this tool, typically archives uploaded before
+// the run history recorded Glacier uploads. Site and object key come from
+// the description and the ledger time from the archive's creation date, so
+// the archives can be exported, audited and deleted like recorded ones.
+// Archives with foreign descriptions are returned untouched.
+func BackfillGlacierLedger(historyPath string, report *GlacierAuditReport) (*RunRecord, []GlacierArchive, error) {
+	var legacy, foreign []GlacierArchive
+	for _, a := range report.UnknownArchives {
+		if _, ok := ParseGlacierDescription(a.ArchiveDescription); ok {
+			legacy = append(legacy, a)
+		} else {
+			foreign = append(foreign, a)
+		}
+	}
+	adopted := adoptArchives("ledger-backfill-", "aws-backfill", legacy)
+	if adopted == nil {
+		return nil, foreign, nil
+	}
+	if err := AppendRunRecord(historyPath, adopted); err != nil {
+		return nil, nil, err
+	}
+	return adopted, foreign, nil
+}
+
+// adoptArchives builds a run record holding archives that are in the vault
+// but not in the ledger, or nil when there are none.
+func adoptArchives(idPrefix, host string, archives []GlacierArchive) *RunRecord {
+	if len(archives) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rec := &RunRecord{ID: idPrefix + NewRunID(now), Host: host, StartedAt: now, FinishedAt: now}
+	for _, a := range archives {
+		d, _ := ParseGlacierDescription(a.ArchiveDescription)
+		created := a.CreationDate
+		if created.IsZero() {
+			created = d.Timestamp
+		}
+		rec.Uploads = append(rec.Uploads, UploadStats{
+			Site:      d.Site,
+			ObjectKey: d.ObjectKey,
+			Bytes:     a.Size,
+			Glacier:   &GlacierUploadStats{ArchiveID: a.ArchiveID, TreeHash: a.SHA256TreeHash, Bytes: a.Size, CreatedAt: created},
+		})
+	}
+	rec.Succeeded = len(rec.Uploads)
+	return rec
+}
+
+// writeRunRecords replaces the history file with records.
+func writeRunRecords(path string, records []RunRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".backup-history-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary history file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for i := range records {
+		data, err := json.Marshal(&records[i])
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal run record: %w", err)
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+	return nil
+}
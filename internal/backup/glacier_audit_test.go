@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleGlacierInventory = `{
+  "VaultARN": "arn:aws:glacier:us-east-1:123456789012:vaults/backups",
+  "InventoryDate": "2026-03-10T08:00:00Z",
+  "ArchiveList": [
+    {"ArchiveId": "arch-ok", "ArchiveDescription": "Backup: backups/foo/foo-1.tgz", "CreationDate": "2026-03-01T02:00:00Z", "Size": 100, "SHA256TreeHash": "aa"},
+    {"ArchiveId": "arch-size", "ArchiveDescription": "Backup: backups/bar/bar-1.tgz", "CreationDate": "2026-03-01T02:00:00Z", "Size": 250, "SHA256TreeHash": "bb"},
+    {"ArchiveId": "arch-hash", "ArchiveDescription": "Backup: backups/baz/baz-1.tgz", "CreationDate": "2026-03-01T02:00:00Z", "Size": 300, "SHA256TreeHash": "cc"},
+    {"ArchiveId": "arch-unknown", "ArchiveDescription": "Migrated from Minio: backups/old/old-1.tgz", "CreationDate": "2026-02-01T02:00:00Z", "Size": 400, "SHA256TreeHash": "dd"}
+  ]
+}`
+
+func TestGlacierArchiveObjectKey(t *testing.T) {
+	tests := map[string]string{
+		"Backup: backups/foo/foo-1.tgz":              "backups/foo/foo-1.tgz",
+		"Migrated from Minio: backups/foo/foo-1.tgz": "backups/foo/foo-1.tgz",
+		"uploaded by hand":                           "",
+	}
+	for desc, want := range tests {
+		if got := (GlacierArchive{ArchiveDescription: desc}).ObjectKey(); got != want {
+			t.Errorf("ObjectKey(%q) = %q, want %q", desc, got, want)
+		}
+	}
+}
+
+func auditHistory() []RunRecord {
+	at := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	glacier := func(key, id, hash string, size int64) UploadStats {
+		return UploadStats{ObjectKey: key, Bytes: size, Glacier: &GlacierUploadStats{ArchiveID: id, TreeHash: hash, Bytes: size}}
+	}
+	return []RunRecord{
+		{ID: "run-1", Host: "wp0", FinishedAt: at, Uploads: []UploadStats{
+			glacier("backups/foo/foo-1.tgz", "arch-ok", "AA", 100),
+			glacier("backups/bar/bar-1.tgz", "arch-size", "bb", 200),
+			glacier("backups/baz/baz-1.tgz", "arch-hash", "xx", 300),
+			glacier("backups/qux/qux-1.tgz", "arch-gone", "ee", 500),
+			{ObjectKey: "backups/minio-only/m-1.tgz", Bytes: 10},
+		}},
+		{ID: "run-2", Host: "wp0", FinishedAt: at.Add(10 * 24 * time.Hour), Uploads: []UploadStats{
+			glacier("backups/foo/foo-2.tgz", "arch-new", "ff", 110),
+		}},
+	}
+}
+
+func TestAuditGlacierInventory(t *testing.T) {
+	inv, err := ReadGlacierInventory(strings.NewReader(sampleGlacierInventory))
+	if err != nil {
+		t.Fatalf("ReadGlacierInventory() error = %v", err)
+	}
+	if len(inv.ArchiveList) != 4 || inv.ArchiveList[0].ArchiveID != "arch-ok" {
+		t.Fatalf("inventory = %+v", inv)
+	}
+
+	ledger := GlacierLedger(auditHistory())
+	if len(ledger) != 5 {
+		t.Fatalf("GlacierLedger() returned %d entries, want 5", len(ledger))
+	}
+	report := AuditGlacierInventory(inv, ledger)
+	if report.Matched != 1 {
+		t.Errorf("Matched = %d, want 1", report.Matched)
+	}
+	if len(report.UnknownArchives) != 1 || report.UnknownArchives[0].ArchiveID != "arch-unknown" {
+		t.Errorf("UnknownArchives = %+v", report.UnknownArchives)
+	}
+	if len(report.MissingArchives) != 1 || report.MissingArchives[0].ArchiveID != "arch-gone" {
+		t.Errorf("MissingArchives = %+v", report.MissingArchives)
+	}
+	if len(report.TooRecent) != 1 || report.TooRecent[0].ArchiveID != "arch-new" {
+		t.Errorf("TooRecent = %+v", report.TooRecent)
+	}
+	if len(report.SizeMismatches) != 1 || report.SizeMismatches[0].Archive.Size != 250 {
+		t.Errorf("SizeMismatches = %+v", report.SizeMismatches)
+	}
+	if len(report.HashMismatches) != 1 || report.HashMismatches[0].Entry.ArchiveID != "arch-hash" {
+		t.Errorf("HashMismatches = %+v", report.HashMismatches)
+	}
+	if report.Clean() {
+		t.Error("Clean() = true, want false")
+	}
+}
+
+func TestRepairGlacierLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	for _, rec := range auditHistory() {
+		if err := AppendRunRecord(path, &rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inv, err := ReadGlacierInventory(strings.NewReader(sampleGlacierInventory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadRunRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := AuditGlacierInventory(inv, GlacierLedger(records))
+
+	adopted, err := RepairGlacierLedger(path, report)
+	if err != nil {
+		t.Fatalf("RepairGlacierLedger() error = %v", err)
+	}
+	if adopted == nil || len(adopted.Uploads) != 1 || adopted.Uploads[0].ObjectKey != "backups/old/old-1.tgz" {
+		t.Fatalf("adopted = %+v", adopted)
+	}
+
+	records, err = LoadRunRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("history has %d records after repair, want 3", len(records))
+	}
+	after := AuditGlacierInventory(inv, GlacierLedger(records))
+	if !after.Clean() || after.Matched != 4 {
+		t.Errorf("audit after repair = %+v, want clean with 4 matches", after)
+	}
+	if len(after.TooRecent) != 1 {
+		t.Errorf("repair dropped the too-recent entry: %+v", after.TooRecent)
+	}
+}
+
+func TestParseGlacierDescription(t *testing.T) {
+	d, ok := ParseGlacierDescription("Backup: backups/foo.com/wp_foo-20260301-020304.part-2-of-3.tgz")
+	if !ok || d.Kind != GlacierDescBackup || d.ObjectKey != "backups/foo.com/wp_foo-20260301-020304.part-2-of-3.tgz" || d.Site != "foo.com" {
+		t.Errorf("ParseGlacierDescription(backup) = %+v, %v", d, ok)
+	}
+	if want := time.Date(2026, 3, 1, 2, 3, 4, 0, Timezone()); !d.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", d.Timestamp, want)
+	}
+
+	d, ok = ParseGlacierDescription("Migrated from Minio: wp_bar-20250102-030405.tgz")
+	if !ok || d.Kind != GlacierDescMigrated || d.Site != "wp_bar" || d.Timestamp.IsZero() {
+		t.Errorf("ParseGlacierDescription(migrated) = %+v, %v", d, ok)
+	}
+
+	d, ok = ParseGlacierDescription("Backup: backups/foo.com/custom.tgz")
+	if !ok || !d.Timestamp.IsZero() {
+		t.Errorf("ParseGlacierDescription(no timestamp) = %+v, %v", d, ok)
+	}
+
+	for _, desc := range []string{"", "uploaded by hand", "Backup: "} {
+		if _, ok := ParseGlacierDescription(desc); ok {
+			t.Errorf("ParseGlacierDescription(%q) accepted a foreign description", desc)
+		}
+	}
+}
+
+func TestBackfillGlacierLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	inv := &GlacierInventory{
+		InventoryDate: time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+		ArchiveList: []GlacierArchive{
+			{ArchiveID: "arch-legacy", ArchiveDescription: "Backup: backups/old.com/wp_old-20250101-010000.tgz", CreationDate: time.Date(2025, 1, 1, 1, 5, 0, 0, time.UTC), Size: 10, SHA256TreeHash: "ee"},
+			{ArchiveID: "arch-foreign", ArchiveDescription: "uploaded by hand", CreationDate: time.Date(2025, 1, 1, 1, 5, 0, 0, time.UTC), Size: 20, SHA256TreeHash: "ff"},
+		},
+	}
+	report := AuditGlacierInventory(inv, nil)
+
+	adopted, foreign, err := BackfillGlacierLedger(path, report)
+	if err != nil {
+		t.Fatalf("BackfillGlacierLedger() error = %v", err)
+	}
+	if adopted == nil || len(adopted.Uploads) != 1 {
+		t.Fatalf("adopted = %+v", adopted)
+	}
+	u := adopted.Uploads[0]
+	if u.Site != "old.com" || u.ObjectKey != "backups/old.com/wp_old-20250101-010000.tgz" || !u.Glacier.CreatedAt.Equal(inv.ArchiveList[0].CreationDate) {
+		t.Errorf("adopted upload = %+v (glacier %+v)", u, u.Glacier)
+	}
+	if len(foreign) != 1 || foreign[0].ArchiveID != "arch-foreign" {
+		t.Errorf("foreign = %+v", foreign)
+	}
+
+	records, err := LoadRunRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := GlacierLedger(records)
+	if len(ledger) != 1 || !ledger[0].RecordedAt.Equal(inv.ArchiveList[0].CreationDate) {
+		t.Fatalf("ledger after backfill = %+v", ledger)
+	}
+	after := AuditGlacierInventory(inv, ledger)
+	if after.Matched != 1 || len(after.UnknownArchives) != 1 || after.UnknownArchives[0].ArchiveID != "arch-foreign" {
+		t.Errorf("audit after backfill = %+v", after)
+	}
+}
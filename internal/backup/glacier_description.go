@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"strings"
+	"time"
+)
+
+// Archive description kinds. Archives have never carried more than one of
+// these descriptions, so everything else about a legacy archive is
+// reconstructed from the object key inside it.
+const (
+	// GlacierDescBackup is "Backup: <key>", written by `backup create`.
+	GlacierDescBackup = "backup"
+	// GlacierDescMigrated is "Migrated from Minio: <key>", written when the
+	// storage monitor or migrate-aws moves an object to Glacier.
+	GlacierDescMigrated = "migrated"
+)
+
+var glacierDescPrefixes = []struct{ prefix, kind string }{
+	{"Backup: ", GlacierDescBackup},
+	{"Migrated from Minio: ", GlacierDescMigrated},
+}
+
+// GlacierDescription is what an archive description tells about the backup
+// inside the archive.
+type GlacierDescription struct {
+	Kind      string `json:"kind"`
+	ObjectKey string `json:"object_key"`
+	// Site is the site directory of the key (backups/<site>/...), or the
+	// label of the backup name for keys stored elsewhere.
+	Site string `json:"site,omitempty"`
+	// Timestamp is when the backup was taken, from the backup name; it is
+	// zero for names without one. Names are stamped in the backup host's
+	// local time, which is assumed to match this host's.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// ParseGlacierDescription parses an archive description written by this
+// tool. It returns false for descriptions it did not write, such as the
+// connection test archives or archives uploaded by hand.
+func ParseGlacierDescription(desc string) (GlacierDescription, bool) {
+	var d GlacierDescription
+	for _, p := range glacierDescPrefixes {
+		if strings.HasPrefix(desc, p.prefix) {
+			d.Kind = p.kind
+			d.ObjectKey = strings.TrimSpace(strings.TrimPrefix(desc, p.prefix))
+			break
+		}
+	}
+	if d.ObjectKey == "" {
+		return GlacierDescription{}, false
+	}
+
+	d.Site = inventorySite(d.ObjectKey, "")
+	if label, t, ok := parseBackupName(d.ObjectKey); ok {
+		d.Timestamp = t
+		if d.Site == "" {
+			d.Site = label
+		}
+	}
+	return d, true
+}
@@ -0,0 +1,442 @@
+package backup
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/minio/minio-go/v7"
+)
+
+// Glacier multipart limits: parts are 1 MiB times a power of two, at most
+// 4 GiB, and an archive has at most 10,000 parts.
+const (
+	glacierMinPartSize  int64 = 1024 * 1024
+	glacierMaxPartSize  int64 = 4 * 1024 * 1024 * 1024
+	glacierMaxParts     int64 = 10000
+	glacierPartAttempts       = 3
+
+	// DefaultGlacierPartSize is the part size used for migrations when
+	// AWSConfig.PartSize is not set. Only one part is buffered on disk at a time.
+	DefaultGlacierPartSize int64 = 128 * 1024 * 1024
+)
+
+// GlacierPartSize returns the part size to use for an archive of size bytes:
+// requested (or DefaultGlacierPartSize) rounded up to a valid Glacier part
+// size, doubled as needed to stay within the 10,000 part limit.
+func GlacierPartSize(size, requested int64) (int64, error) {
+	if requested <= 0 {
+		requested = DefaultGlacierPartSize
+	}
+	if requested > glacierMaxPartSize {
+		return 0, fmt.Errorf("part size %d exceeds the Glacier maximum of 4 GiB", requested)
+	}
+	part := glacierMinPartSize
+	for part < requested {
+		part *= 2
+	}
+	for size > part*glacierMaxParts {
+		if part == glacierMaxPartSize {
+			return 0, fmt.Errorf("object of %d bytes exceeds the Glacier archive limit", size)
+		}
+		part *= 2
+	}
+	return part, nil
+}
+
+// glacierPartRange formats the Content-Range of the part at offset.
+func glacierPartRange(offset, length int64) string {
+	return fmt.Sprintf("bytes %d-%d/*", offset, offset+length-1)
+}
+
+// parseGlacierPartRange parses the "start-end" RangeInBytes of ListParts.
+func parseGlacierPartRange(s string) (start, end int64, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid part range %q", s)
+	}
+	if start, err = strconv.ParseInt(a, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid part range %q", s)
+	}
+	if end, err = strconv.ParseInt(b, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid part range %q", s)
+	}
+	return start, end, nil
+}
+
+// combinePartTreeHashes computes the archive tree hash from the tree hashes of
+// its parts in order. This is valid because every part but the last spans a
+// power-of-two number of 1 MiB chunks.
+func combinePartTreeHashes(hashes []string) (string, error) {
+	chunks := make([]hashChunk, len(hashes))
+	for i, h := range hashes {
+		b, err := hex.DecodeString(h)
+		if err != nil || len(b) != len(chunks[i]) {
+			return "", fmt.Errorf("invalid tree hash for part %d: %q", i+1, h)
+		}
+		copy(chunks[i][:], b)
+	}
+	return computeTreeHashFromChunks(chunks), nil
+}
+
+// withPayloadHash sets the x-amz-content-sha256 and Content-Length headers
+// Glacier requires for uploads from a seekable temp file.
+func withPayloadHash(contentHash string, contentLength int64) func(*glacier.Options) {
+	return func(o *glacier.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Build.Add(middleware.BuildMiddlewareFunc(
+				"AddContentSHA256Header",
+				func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (
+					middleware.BuildOutput, middleware.Metadata, error,
+				) {
+					if req, ok := in.Request.(*smithyhttp.Request); ok {
+						req.Header.Set("x-amz-content-sha256", contentHash)
+						req.Header.Set("Content-Length", fmt.Sprintf("%d", contentLength))
+					}
+					return next.HandleBuild(ctx, in)
+				},
+			), middleware.Before)
+		})
+	}
+}
+
+// getObjectFrom opens objectName on the configured backend starting at offset.
+func (bm *BackupManager) getObjectFrom(ctx context.Context, objectName string, offset int64) (io.ReadCloser, error) {
+	if bm.fileStore != nil {
+		rc, err := bm.fileStore.open(objectName)
+		if err != nil || offset == 0 {
+			return rc, err
+		}
+		if s, ok := rc.(io.Seeker); ok {
+			if _, err := s.Seek(offset, io.SeekStart); err != nil {
+				rc.Close()
+				return nil, err
+			}
+			return rc, nil
+		}
+		if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+			rc.Close()
+			return nil, err
+		}
+		return rc, nil
+	}
+	opts := minio.GetObjectOptions{}
+	if offset > 0 {
+		if err := opts.SetRange(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	return bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, objectName, opts)
+}
+
+// findGlacierUpload returns the ID of an unfinished multipart upload with the
+// given description and part size, and the tree hashes of its uploaded parts
+// keyed by byte offset. An empty ID means there is nothing to resume.
+func (bm *BackupManager) findGlacierUpload(ctx context.Context, description string, partSize, size int64) (string, map[int64]string, error) {
+	accountID := bm.glacierAccountID()
+	uploads := glacier.NewListMultipartUploadsPaginator(bm.awsClient, &glacier.ListMultipartUploadsInput{
+		AccountId: aws.String(accountID),
+		VaultName: aws.String(bm.awsConfig.Vault),
+	})
+	for uploads.HasMorePages() {
+		page, err := uploads.NextPage(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list multipart uploads: %w", err)
+		}
+		for _, u := range page.UploadsList {
+			if aws.ToString(u.ArchiveDescription) != description || u.PartSizeInBytes != partSize {
+				continue
+			}
+			uploadID := aws.ToString(u.MultipartUploadId)
+			done := make(map[int64]string)
+			parts := glacier.NewListPartsPaginator(bm.awsClient, &glacier.ListPartsInput{
+				AccountId: aws.String(accountID),
+				VaultName: aws.String(bm.awsConfig.Vault),
+				UploadId:  aws.String(uploadID),
+			})
+			for parts.HasMorePages() {
+				pp, err := parts.NextPage(ctx)
+				if err != nil {
+					return "", nil, fmt.Errorf("failed to list parts of upload %s: %w", uploadID, err)
+				}
+				for _, p := range pp.Parts {
+					start, end, err := parseGlacierPartRange(aws.ToString(p.RangeInBytes))
+					if err != nil {
+						return "", nil, err
+					}
+					if end >= size {
+						// The object changed since this upload started.
+						bm.logVerbose("Ignoring upload %s: part %d-%d is beyond the object size %d", uploadID, start, end, size)
+						done = nil
+						break
+					}
+					done[start] = aws.ToString(p.SHA256TreeHash)
+				}
+				if done == nil {
+					break
+				}
+			}
+			if done != nil {
+				return uploadID, done, nil
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// MigrateObjectToGlacier copies objectName from Minio to Glacier using a
+// multipart upload. The object is streamed in fixed-size parts; each part is
+// buffered to a temp file, tree-hashed and uploaded on its own, so no more
+// than one part of temp space is needed regardless of the object size. An
+// interrupted migration of the same object resumes from the parts already in
+// the vault. With AWSConfig.VerifyChecksums a checksum mismatch fails the
+// migration, so callers keep the Minio copy. Every migration is added to the
+// manager's run record (see LastRunRecord) for the ledger.
+func (bm *BackupManager) MigrateObjectToGlacier(objectName string, size int64) (*GlacierUploadStats, error) {
+	started := time.Now()
+	stats, err := bm.migrateObjectToGlacier(bm, objectName, objectName, size)
+	bm.recordMigration(started, objectName, stats, err)
+	return stats, err
+}
+
+// recordMigration adds one migration to the current migration run record,
+// starting a new record when the manager has none.
+func (bm *BackupManager) recordMigration(started time.Time, objectName string, stats *GlacierUploadStats, err error) {
+	if bm.lastRun == nil || bm.lastRun.Kind != RunKindMigration {
+		bm.lastRun = &RunRecord{ID: NewRunID(started), Kind: RunKindMigration, StartedAt: started}
+	}
+	rec := bm.lastRun
+	rec.FinishedAt = time.Now()
+	if err != nil {
+		rec.Failed++
+	} else {
+		rec.Succeeded++
+	}
+	// Only completed archives belong in the ledger, including ones that
+	// failed verification: they exist in the vault and must be accounted for.
+	if stats != nil && stats.ArchiveID != "" {
+		rec.Uploads = append(rec.Uploads, UploadStats{
+			Site:      inventorySite(objectName, ""),
+			ObjectKey: objectName,
+			Bytes:     stats.Bytes,
+			Glacier:   stats,
+		})
+	}
+}
+
+// migrateObjectToGlacier archives objectName, reading its bytes from srcKey
+// on src; src differs from bm for objects parked in a staging tier.
+func (bm *BackupManager) migrateObjectToGlacier(src *BackupManager, srcKey, objectName string, size int64) (*GlacierUploadStats, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("cannot migrate empty object %s", objectName)
+	}
+	if err := bm.guardWrite("migrate to Glacier", objectName); err != nil {
+		return nil, err
+	}
+	if err := bm.checkObjectResidency(context.Background(), "migrate", objectName, ResidencyGlacier); err != nil {
+		return nil, err
+	}
+	partSize, err := GlacierPartSize(size, bm.awsConfig.PartSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	accountID := bm.glacierAccountID()
+	description := fmt.Sprintf("Migrated from Minio: %s", objectName)
+	numParts := (size + partSize - 1) / partSize
+
+	uploadID, done, err := bm.findGlacierUpload(ctx, description, partSize, size)
+	if err != nil {
+		return nil, err
+	}
+	if uploadID != "" {
+		fmt.Printf("  ↻ Resuming multipart upload (%d/%d parts already in Glacier)\n", len(done), numParts)
+		bm.logVerbose("Upload ID: %s", uploadID)
+	} else {
+		out, err := bm.awsClient.InitiateMultipartUpload(ctx, &glacier.InitiateMultipartUploadInput{
+			AccountId:          aws.String(accountID),
+			VaultName:          aws.String(bm.awsConfig.Vault),
+			ArchiveDescription: aws.String(description),
+			PartSize:           aws.String(strconv.FormatInt(partSize, 10)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initiate multipart upload: %w", err)
+		}
+		uploadID = aws.ToString(out.UploadId)
+		done = map[int64]string{}
+		bm.logVerbose("Started multipart upload %s", uploadID)
+	}
+	fmt.Printf("  ℹ️  Uploading %d part(s) of %.0f MB\n", numParts, float64(partSize)/(1024*1024))
+
+	// Start reading at the first part that still needs uploading.
+	var start int64
+	for start < size && done[start] != "" {
+		start += partSize
+	}
+	var object io.ReadCloser
+	if start < size {
+		if object, err = src.getObjectFrom(ctx, srcKey, start); err != nil {
+			return nil, fmt.Errorf("failed to download %s from Minio: %w", srcKey, err)
+		}
+		defer object.Close()
+	}
+
+	stats := &GlacierUploadStats{Bytes: size}
+	hashes := make([]string, 0, numParts)
+	for offset := int64(0); offset < size; offset += partSize {
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		if h := done[offset]; h != "" {
+			hashes = append(hashes, h)
+			if offset < start {
+				continue
+			}
+			// Parts uploaded by an earlier run sit between parts still to
+			// upload; when verifying, hash them on the way past instead of
+			// discarding them.
+			if bm.awsConfig.VerifyChecksums {
+				verifyStart := time.Now()
+				local, err := treeHashStream(object, length)
+				stats.VerifySeconds += time.Since(verifyStart).Seconds()
+				if err != nil {
+					return nil, fmt.Errorf("failed to read uploaded part at offset %d: %w", offset, err)
+				}
+				if local != h {
+					return nil, fmt.Errorf("part %d/%d: %w: object has %s, vault has %s", offset/partSize+1, numParts, ErrGlacierChecksumMismatch, local, h)
+				}
+			} else if _, err := io.CopyN(io.Discard, object, length); err != nil {
+				return nil, fmt.Errorf("failed to skip uploaded part at offset %d: %w", offset, err)
+			}
+			continue
+		}
+
+		part := offset/partSize + 1
+		treeHash, err := bm.uploadGlacierPart(ctx, uploadID, object, offset, length, stats)
+		if err != nil {
+			return nil, fmt.Errorf("part %d/%d: %w", part, numParts, err)
+		}
+		hashes = append(hashes, treeHash)
+		fmt.Printf("  ✓ Part %d/%d uploaded (%.2f MB)\n", part, numParts, float64(length)/(1024*1024))
+	}
+
+	treeHash, err := combinePartTreeHashes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	out, err := bm.awsClient.CompleteMultipartUpload(ctx, &glacier.CompleteMultipartUploadInput{
+		AccountId:   aws.String(accountID),
+		VaultName:   aws.String(bm.awsConfig.Vault),
+		UploadId:    aws.String(uploadID),
+		ArchiveSize: aws.String(strconv.FormatInt(size, 10)),
+		Checksum:    aws.String(treeHash),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	stats.ArchiveID = aws.ToString(out.ArchiveId)
+	stats.TreeHash = treeHash
+	stats.UploadMBps = mbps(size, time.Duration(stats.UploadSeconds*float64(time.Second)))
+	if bm.awsConfig.VerifyChecksums {
+		// Every part was checked as it was sent; the archive checksum covers
+		// the assembly of those parts.
+		if err := compareGlacierChecksums(treeHash, treeHash, aws.ToString(out.Checksum)); err != nil {
+			stats.Verification = GlacierChecksumMismatch
+			return stats, fmt.Errorf("archive %s: %w", stats.ArchiveID, err)
+		}
+		stats.Verification = GlacierVerified
+		fmt.Printf("  ✓ Archive checksum verified\n")
+	}
+	return stats, nil
+}
+
+// uploadGlacierPart buffers the next length bytes of r to a temp file and
+// uploads them as the part at offset, retrying the upload a few times. It
+// returns the part's tree hash.
+func (bm *BackupManager) uploadGlacierPart(ctx context.Context, uploadID string, r io.Reader, offset, length int64, stats *GlacierUploadStats) (string, error) {
+	reservation, err := bm.reserveTemp(fmt.Sprintf("Glacier part at offset %d", offset), length)
+	if err != nil {
+		return "", err
+	}
+	defer reservation.release()
+	tmpDir := os.TempDir()
+	tmpFile, err := os.CreateTemp(tmpDir, "glacier-part-*.tmp")
+	if err != nil && errors.Is(err, syscall.ENOSPC) {
+		if deleted, derr := cleanupGlacierTempFiles(tmpDir); derr == nil && deleted > 0 {
+			fmt.Printf("  🧹 Removed %d old glacier temp file(s)\n", deleted)
+		}
+		tmpFile, err = os.CreateTemp(tmpDir, "glacier-part-*.tmp")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	reservation.track(tmpFile.Name())
+
+	bufferStart := time.Now()
+	if _, err := io.CopyN(tmpFile, r, length); err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return "", fmt.Errorf("failed to buffer part to temporary file (disk full): %w", err)
+		}
+		return "", fmt.Errorf("failed to buffer part from Minio: %w", err)
+	}
+	stats.BufferSeconds += time.Since(bufferStart).Seconds()
+
+	checksumStart := time.Now()
+	treeHash, linearHash, _, err := computeHashesFromFile(tmpFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate checksums: %w", err)
+	}
+	stats.ChecksumSeconds += time.Since(checksumStart).Seconds()
+
+	for attempt := 1; ; attempt++ {
+		if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+			return "", fmt.Errorf("failed to seek temporary file for upload: %w", err)
+		}
+		uploadStart := time.Now()
+		out, err := bm.awsClient.UploadMultipartPart(v4.SetPayloadHash(ctx, linearHash), &glacier.UploadMultipartPartInput{
+			AccountId: aws.String(bm.glacierAccountID()),
+			VaultName: aws.String(bm.awsConfig.Vault),
+			UploadId:  aws.String(uploadID),
+			Range:     aws.String(glacierPartRange(offset, length)),
+			Checksum:  aws.String(treeHash),
+			Body:      tmpFile,
+		}, withPayloadHash(linearHash, length))
+		stats.UploadSeconds += time.Since(uploadStart).Seconds()
+		if err == nil && bm.awsConfig.VerifyChecksums {
+			verifyStart := time.Now()
+			err = verifyGlacierBuffer(tmpFile, treeHash, aws.ToString(out.Checksum))
+			stats.VerifySeconds += time.Since(verifyStart).Seconds()
+			if err != nil {
+				// Re-sending the same buffer cannot fix a mismatch.
+				return "", err
+			}
+		}
+		if err == nil {
+			return treeHash, nil
+		}
+		if attempt == glacierPartAttempts {
+			return "", fmt.Errorf("failed to upload part after %d attempts: %w", attempt, err)
+		}
+		fmt.Printf("  ⚠ Part upload failed (attempt %d/%d): %v\n", attempt, glacierPartAttempts, err)
+		time.Sleep(time.Duration(attempt) * 5 * time.Second)
+	}
+}
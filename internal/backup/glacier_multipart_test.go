@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlacierPartSize(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		size, requested, want int64
+	}{
+		{10 * mb, 0, DefaultGlacierPartSize},
+		{10 * mb, 3 * mb, 4 * mb},
+		{10 * mb, 1, mb},
+		// 400 GB does not fit in 10,000 parts of 32 MB.
+		{400 * 1024 * mb, 32 * mb, 64 * mb},
+	}
+	for _, tt := range tests {
+		got, err := GlacierPartSize(tt.size, tt.requested)
+		if err != nil || got != tt.want {
+			t.Errorf("GlacierPartSize(%d, %d) = %d, %v; want %d", tt.size, tt.requested, got, err, tt.want)
+		}
+	}
+	if _, err := GlacierPartSize(mb, 8*1024*mb); err == nil {
+		t.Error("GlacierPartSize() accepted a part size above 4 GiB")
+	}
+	if _, err := GlacierPartSize(50000*1024*mb, 0); err == nil {
+		t.Error("GlacierPartSize() accepted an object beyond 10,000 parts of 4 GiB")
+	}
+}
+
+func TestCombinePartTreeHashes(t *testing.T) {
+	const mb = 1024 * 1024
+	data := make([]byte, 5*mb+1234)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	want := computeTreeHash(data)
+
+	for _, partSize := range []int{mb, 2 * mb, 4 * mb, 8 * mb} {
+		var hashes []string
+		for off := 0; off < len(data); off += partSize {
+			end := min(off+partSize, len(data))
+			hashes = append(hashes, computeTreeHash(data[off:end]))
+		}
+		got, err := combinePartTreeHashes(hashes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("part size %d MB: combined tree hash %s, want %s", partSize/mb, got, want)
+		}
+	}
+
+	sum := sha256.Sum256(nil)
+	if _, err := combinePartTreeHashes([]string{hex.EncodeToString(sum[:4])}); err == nil {
+		t.Error("combinePartTreeHashes() accepted a truncated hash")
+	}
+}
+
+func TestParseGlacierPartRange(t *testing.T) {
+	start, end, err := parseGlacierPartRange("4194304-8388607")
+	if err != nil || start != 4194304 || end != 8388607 {
+		t.Errorf("parseGlacierPartRange() = %d, %d, %v", start, end, err)
+	}
+	if got := glacierPartRange(start, end-start+1); got != "bytes 4194304-8388607/*" {
+		t.Errorf("glacierPartRange() = %q", got)
+	}
+	if _, _, err := parseGlacierPartRange("bytes"); err == nil {
+		t.Error("parseGlacierPartRange() accepted an invalid range")
+	}
+}
+
+func TestGetObjectFromOffset(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "backups"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "backups", "a.tgz"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BackupManager{fileStore: &fileStore{root: root}}
+	rc, err := bm.getObjectFrom(context.Background(), "backups/a.tgz", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	if string(got) != "456789" {
+		t.Errorf("getObjectFrom(offset 4) read %q", got)
+	}
+}
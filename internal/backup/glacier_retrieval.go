@@ -0,0 +1,264 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+)
+
+// Glacier archive retrieval tiers, fastest and dearest first.
+const (
+	RetrievalTierExpedited = "Expedited"
+	RetrievalTierStandard  = "Standard"
+	RetrievalTierBulk      = "Bulk"
+)
+
+// RetrievalPrice is what a retrieval tier costs in USD.
+type RetrievalPrice struct {
+	PerGB      float64
+	PerRequest float64
+}
+
+// retrievalPrices are the us-east-1 list prices of Glacier vault
+// retrievals; --price-per-gb overrides the per-GB part for other regions or
+// negotiated rates.
+var retrievalPrices = map[string]RetrievalPrice{
+	RetrievalTierExpedited: {PerGB: 0.03, PerRequest: 0.01},
+	RetrievalTierStandard:  {PerGB: 0.01, PerRequest: 0.00005},
+	RetrievalTierBulk:      {PerGB: 0.0025, PerRequest: 0.000025},
+}
+
+// ParseRetrievalTier parses a tier name case-insensitively.
+func ParseRetrievalTier(s string) (string, error) {
+	for _, tier := range []string{RetrievalTierExpedited, RetrievalTierStandard, RetrievalTierBulk} {
+		if strings.EqualFold(strings.TrimSpace(s), tier) {
+			return tier, nil
+		}
+	}
+	return "", fmt.Errorf("unknown retrieval tier %q (use expedited, standard or bulk)", s)
+}
+
+// RetrievalEstimate is the expected cost of retrieving one archive.
+type RetrievalEstimate struct {
+	Tier  string  `json:"tier"`
+	Bytes int64   `json:"bytes"`
+	Cost  float64 `json:"cost_usd"`
+}
+
+// EstimateRetrievalCost estimates retrieving bytes with tier. A positive
+// perGB replaces the list price per GB.
+func EstimateRetrievalCost(bytes int64, tier string, perGB float64) RetrievalEstimate {
+	price := retrievalPrices[tier]
+	if perGB > 0 {
+		price.PerGB = perGB
+	}
+	gb := float64(bytes) / (1024 * 1024 * 1024)
+	return RetrievalEstimate{Tier: tier, Bytes: bytes, Cost: gb*price.PerGB + price.PerRequest}
+}
+
+// RetrievalRecord is a retrieval job recorded in the spend ledger.
+type RetrievalRecord struct {
+	JobID         string    `json:"job_id"`
+	ArchiveID     string    `json:"archive_id"`
+	ObjectKey     string    `json:"object_key,omitempty"`
+	Tier          string    `json:"tier"`
+	Bytes         int64     `json:"bytes"`
+	EstimatedCost float64   `json:"estimated_cost_usd"`
+	InitiatedAt   time.Time `json:"initiated_at"`
+}
+
+// DefaultRetrievalLedgerPath returns the default location of the retrieval
+// spend ledger (~/.ciwg/glacier-retrievals.jsonl).
+func DefaultRetrievalLedgerPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-glacier-retrievals.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "glacier-retrievals.jsonl")
+}
+
+// AppendRetrievalRecord adds rec to the ledger at path, creating the file
+// and its parent directory if needed.
+func AppendRetrievalRecord(path string, rec RetrievalRecord) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create retrieval ledger directory: %w", err)
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retrieval record: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open retrieval ledger: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write retrieval record: %w", err)
+	}
+	return nil
+}
+
+// LoadRetrievalRecords reads the ledger at path. A missing file yields an
+// empty ledger. Malformed lines are skipped.
+func LoadRetrievalRecords(path string) ([]RetrievalRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open retrieval ledger: %w", err)
+	}
+	defer f.Close()
+
+	var out []RetrievalRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec RetrievalRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil && rec.JobID != "" {
+			out = append(out, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read retrieval ledger: %w", err)
+	}
+	return out, nil
+}
+
+// MonthlyRetrievalSpend sums the estimated cost of the retrievals started in
+// the calendar month of now, in now's time zone.
+func MonthlyRetrievalSpend(records []RetrievalRecord, now time.Time) float64 {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var spend float64
+	for _, rec := range records {
+		if !rec.InitiatedAt.Before(start) && !rec.InitiatedAt.After(now) {
+			spend += rec.EstimatedCost
+		}
+	}
+	return spend
+}
+
+// ErrRetrievalBudget is returned when a retrieval would exceed a hard
+// monthly budget cap.
+var ErrRetrievalBudget = errors.New("retrieval would exceed the monthly budget")
+
+// RetrievalBudget guards retrieval jobs against surprise bills.
+type RetrievalBudget struct {
+	// ConfirmAbove is the estimated cost in USD above which a retrieval
+	// needs confirmation; 0 confirms every retrieval.
+	ConfirmAbove float64
+	// MonthlyCap is the retrieval spend allowed per calendar month in USD;
+	// 0 means no cap.
+	MonthlyCap float64
+	// Soft only warns when the cap would be exceeded instead of refusing.
+	Soft bool
+}
+
+// RetrievalDecision is the outcome of checking a retrieval against a budget.
+type RetrievalDecision struct {
+	// Confirm is set when the retrieval needs the operator's confirmation.
+	Confirm bool
+	// Warning explains a soft cap the retrieval exceeds.
+	Warning string
+}
+
+// Check decides whether a retrieval estimated at est may start with spent
+// already used this month. A retrieval over a hard cap fails with
+// ErrRetrievalBudget; over a soft cap it needs confirmation and carries a
+// warning.
+func (b RetrievalBudget) Check(est RetrievalEstimate, spent float64) (RetrievalDecision, error) {
+	d := RetrievalDecision{Confirm: est.Cost > b.ConfirmAbove}
+	if b.MonthlyCap > 0 && spent+est.Cost > b.MonthlyCap {
+		msg := fmt.Sprintf("$%.2f spent this month plus $%.2f for this retrieval exceeds the $%.2f monthly cap", spent, est.Cost, b.MonthlyCap)
+		if !b.Soft {
+			return d, fmt.Errorf("%w: %s", ErrRetrievalBudget, msg)
+		}
+		d.Confirm, d.Warning = true, msg
+	}
+	return d, nil
+}
+
+// InitiateGlacierRetrieval starts an archive-retrieval job for archiveID
+// with tier and returns its job ID. Expedited jobs usually finish in
+// minutes, Standard in 3-5 hours and Bulk in 5-12 hours.
+func (bm *BackupManager) InitiateGlacierRetrieval(archiveID, tier, description string) (string, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return "", err
+	}
+	if err := bm.guardWrite("start Glacier retrieval of", archiveID); err != nil {
+		return "", err
+	}
+	out, err := bm.awsClient.InitiateJob(context.Background(), &glacier.InitiateJobInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobParameters: &types.JobParameters{
+			Type:        aws.String("archive-retrieval"),
+			ArchiveId:   aws.String(archiveID),
+			Tier:        aws.String(tier),
+			Description: aws.String(description),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate retrieval job: %w", err)
+	}
+	return aws.ToString(out.JobId), nil
+}
+
+// FetchGlacierRetrieval writes the output of a completed archive-retrieval
+// job to w and returns the number of bytes written.
+func (bm *BackupManager) FetchGlacierRetrieval(jobID string, w io.Writer) (int64, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	job, err := bm.awsClient.DescribeJob(ctx, &glacier.DescribeJobInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobId:     aws.String(jobID),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe job %s: %w", jobID, err)
+	}
+	if !job.Completed {
+		return 0, fmt.Errorf("retrieval job %s is not complete yet (status: %s); try again later", jobID, job.StatusCode)
+	}
+	out, err := bm.awsClient.GetJobOutput(ctx, &glacier.GetJobOutputInput{
+		AccountId: aws.String(bm.glacierAccountID()),
+		VaultName: aws.String(bm.awsConfig.Vault),
+		JobId:     aws.String(jobID),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get output of job %s: %w", jobID, err)
+	}
+	defer out.Body.Close()
+	n, err := io.Copy(w, out.Body)
+	if err != nil {
+		return n, fmt.Errorf("failed to download output of job %s: %w", jobID, err)
+	}
+	return n, nil
+}
+
+// FindLedgerEntry returns the newest ledger entry whose object key or
+// archive ID is ref.
+func FindLedgerEntry(ledger []LedgerEntry, ref string) (LedgerEntry, bool) {
+	var found LedgerEntry
+	ok := false
+	for _, e := range ledger {
+		if (e.ObjectKey == ref || e.ArchiveID == ref) && (!ok || e.RecordedAt.After(found.RecordedAt)) {
+			found, ok = e, true
+		}
+	}
+	return found, ok
+}
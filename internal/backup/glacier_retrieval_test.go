@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEstimateRetrievalCost(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	for _, tc := range []struct {
+		tier  string
+		perGB float64
+		want  float64
+	}{
+		{RetrievalTierExpedited, 0, 100*0.03 + 0.01},
+		{RetrievalTierStandard, 0, 100*0.01 + 0.00005},
+		{RetrievalTierBulk, 0, 100*0.0025 + 0.000025},
+		{RetrievalTierStandard, 0.02, 100*0.02 + 0.00005},
+	} {
+		if got := EstimateRetrievalCost(100*gb, tc.tier, tc.perGB).Cost; math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("EstimateRetrievalCost(100 GB, %s, %v) = %v, want %v", tc.tier, tc.perGB, got, tc.want)
+		}
+	}
+	if tier, err := ParseRetrievalTier(" bulk"); err != nil || tier != RetrievalTierBulk {
+		t.Errorf("ParseRetrievalTier(bulk) = %q, %v", tier, err)
+	}
+	if _, err := ParseRetrievalTier("instant"); err == nil {
+		t.Error("ParseRetrievalTier(instant) succeeded")
+	}
+}
+
+func TestRetrievalBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retrievals.jsonl")
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	for _, rec := range []RetrievalRecord{
+		{JobID: "last-month", EstimatedCost: 50, InitiatedAt: now.AddDate(0, -1, 0)},
+		{JobID: "a", EstimatedCost: 6, InitiatedAt: now.AddDate(0, 0, -10)},
+		{JobID: "b", EstimatedCost: 2.5, InitiatedAt: now.Add(-time.Hour)},
+	} {
+		if err := AppendRetrievalRecord(path, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := LoadRetrievalRecords(path)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("LoadRetrievalRecords() = %d records, %v", len(records), err)
+	}
+	spent := MonthlyRetrievalSpend(records, now)
+	if spent != 8.5 {
+		t.Fatalf("MonthlyRetrievalSpend() = %v, want 8.5", spent)
+	}
+
+	est := RetrievalEstimate{Tier: RetrievalTierStandard, Cost: 2}
+	if d, err := (RetrievalBudget{ConfirmAbove: 5, MonthlyCap: 20}).Check(est, spent); err != nil || d.Confirm {
+		t.Errorf("Check(within budget) = %+v, %v", d, err)
+	}
+	if d, _ := (RetrievalBudget{ConfirmAbove: 1}).Check(est, spent); !d.Confirm {
+		t.Error("Check() did not ask to confirm a retrieval above --confirm-above")
+	}
+	if _, err := (RetrievalBudget{ConfirmAbove: 5, MonthlyCap: 10}).Check(est, spent); !errors.Is(err, ErrRetrievalBudget) {
+		t.Errorf("Check(over hard cap) error = %v", err)
+	}
+	d, err := (RetrievalBudget{ConfirmAbove: 5, MonthlyCap: 10, Soft: true}).Check(est, spent)
+	if err != nil || !d.Confirm || d.Warning == "" {
+		t.Errorf("Check(over soft cap) = %+v, %v", d, err)
+	}
+}
+
+func TestFindLedgerEntry(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	ledger := []LedgerEntry{
+		{ObjectKey: "backups/a.com/a.tgz", ArchiveID: "old", RecordedAt: t0},
+		{ObjectKey: "backups/a.com/a.tgz", ArchiveID: "new", RecordedAt: t0.Add(time.Hour)},
+	}
+	if e, ok := FindLedgerEntry(ledger, "backups/a.com/a.tgz"); !ok || e.ArchiveID != "new" {
+		t.Errorf("FindLedgerEntry(key) = %+v, %v", e, ok)
+	}
+	if e, ok := FindLedgerEntry(ledger, "old"); !ok || e.ArchiveID != "old" {
+		t.Errorf("FindLedgerEntry(archive ID) = %+v, %v", e, ok)
+	}
+	if _, ok := FindLedgerEntry(ledger, "missing"); ok {
+		t.Error("FindLedgerEntry(missing) found an entry")
+	}
+}
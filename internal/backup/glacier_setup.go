@@ -0,0 +1,460 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/glacier"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+	"github.com/aws/smithy-go"
+)
+
+// Statuses of a setup check.
+const (
+	SetupOK      = "ok"
+	SetupWarning = "warning"
+	SetupMissing = "missing"
+	SetupDenied  = "denied"
+	SetupError   = "error"
+)
+
+// GlacierJobEvents are the vault notifications restore and inventory
+// automation wait for.
+var GlacierJobEvents = []string{"ArchiveRetrievalCompleted", "InventoryRetrievalCompleted"}
+
+// Data retrieval policy strategies.
+const (
+	RetrievalBytesPerHour = "BytesPerHour"
+	RetrievalFreeTier     = "FreeTier"
+	RetrievalNone         = "None"
+)
+
+// SetupCheck is one finding of VerifyGlacierSetup.
+type SetupCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Action is the IAM action a denied check needs.
+	Action string `json:"action,omitempty"`
+	// UsedBy names the commands that need Action.
+	UsedBy string `json:"used_by,omitempty"`
+	// Fix tells how to resolve a failed check.
+	Fix string `json:"fix,omitempty"`
+}
+
+// GlacierSetupOptions are the expectations checked by VerifyGlacierSetup and
+// put in place by ApplyGlacierSetup.
+type GlacierSetupOptions struct {
+	// SNSTopic is the topic job notifications must go to; empty accepts any.
+	SNSTopic string
+	// RetrievalStrategy is the data retrieval policy expected; empty accepts
+	// any. BytesPerHour goes with a limit.
+	RetrievalStrategy string
+	BytesPerHour      int64
+	// DryRun makes ApplyGlacierSetup print the changes instead.
+	DryRun bool
+}
+
+// GlacierSetupReport is the outcome of VerifyGlacierSetup.
+type GlacierSetupReport struct {
+	Vault    string       `json:"vault"`
+	VaultARN string       `json:"vault_arn,omitempty"`
+	Checks   []SetupCheck `json:"checks"`
+	// Denied lists the IAM actions the keys lack.
+	Denied []string `json:"denied,omitempty"`
+}
+
+// OK reports whether no check failed; warnings don't count.
+func (r *GlacierSetupReport) OK() bool {
+	for _, c := range r.Checks {
+		if c.Status != SetupOK && c.Status != SetupWarning {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *GlacierSetupReport) add(c SetupCheck) {
+	if c.Status == SetupDenied && c.Action != "" && !slices.Contains(r.Denied, c.Action) {
+		r.Denied = append(r.Denied, c.Action)
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+// PolicyStatement returns an IAM policy granting the denied actions on the
+// vault, to paste into the policy of the keys' user or role.
+func (r *GlacierSetupReport) PolicyStatement() string {
+	if len(r.Denied) == 0 {
+		return ""
+	}
+	resource := r.VaultARN
+	if resource == "" {
+		resource = "arn:aws:glacier:*:*:vaults/" + r.Vault
+	}
+	policy := map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":   "Allow",
+			"Action":   r.Denied,
+			"Resource": resource,
+		}},
+	}
+	b, _ := json.MarshalIndent(policy, "", "  ")
+	return string(b)
+}
+
+// probeStatus classifies the error of a permission probe: AWS authorizes a
+// request before validating it, so any API error but AccessDenied means the
+// action is allowed.
+func probeStatus(err error) string {
+	if err == nil {
+		return SetupOK
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return SetupError
+	}
+	if apiErr.ErrorCode() == "AccessDeniedException" {
+		return SetupDenied
+	}
+	return SetupOK
+}
+
+// isNotFound reports whether err is Glacier's ResourceNotFoundException.
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFoundException"
+}
+
+// checkNotifications checks the vault notification configuration against
+// the job events and, when set, the expected topic.
+func checkNotifications(cfg *types.VaultNotificationConfig, wantTopic string) SetupCheck {
+	c := SetupCheck{Name: "notifications", Status: SetupOK}
+	fix := "ciwg-cli backup aws-setup apply --sns-topic <topic-arn>"
+	if cfg == nil || aws.ToString(cfg.SNSTopic) == "" {
+		c.Status, c.Detail, c.Fix = SetupMissing, "no SNS topic is notified of completed jobs; restores and inventories must be polled", fix
+		return c
+	}
+	topic := aws.ToString(cfg.SNSTopic)
+	if wantTopic != "" && topic != wantTopic {
+		c.Status, c.Detail, c.Fix = SetupMissing, fmt.Sprintf("notifications go to %s, not %s", topic, wantTopic), fix
+		return c
+	}
+	var missing []string
+	for _, e := range GlacierJobEvents {
+		if !slices.Contains(cfg.Events, e) {
+			missing = append(missing, e)
+		}
+	}
+	if len(missing) > 0 {
+		c.Status, c.Detail, c.Fix = SetupMissing, fmt.Sprintf("%s notifies %s but not %s", topic, strings.Join(cfg.Events, ", "), strings.Join(missing, ", ")), fix
+		return c
+	}
+	c.Detail = topic
+	return c
+}
+
+// checkRetrievalPolicy checks the region's data retrieval policy against the
+// expected strategy.
+func checkRetrievalPolicy(p *types.DataRetrievalPolicy, want string, bytesPerHour int64) SetupCheck {
+	c := SetupCheck{Name: "retrieval-policy", Status: SetupOK}
+	strategy, limit := RetrievalNone, int64(0)
+	if p != nil && len(p.Rules) > 0 {
+		strategy = aws.ToString(p.Rules[0].Strategy)
+		limit = aws.ToInt64(p.Rules[0].BytesPerHour)
+	}
+	switch strategy {
+	case RetrievalBytesPerHour:
+		c.Detail = fmt.Sprintf("at most %.1f GB per hour", float64(limit)/(1024*1024*1024))
+	case RetrievalFreeTier:
+		c.Detail = "free tier only; retrievals beyond it are rejected"
+	default:
+		c.Detail = "no limit; large restores are billed in full"
+	}
+	fix := fmt.Sprintf("ciwg-cli backup aws-setup apply --retrieval-strategy %s", want)
+	if want == RetrievalBytesPerHour {
+		fix += fmt.Sprintf(" --bytes-per-hour %d", bytesPerHour)
+	}
+	switch {
+	case want != "" && strategy != want:
+		c.Status, c.Detail, c.Fix = SetupMissing, fmt.Sprintf("strategy is %s, want %s", strategy, want), fix
+	case want == RetrievalBytesPerHour && bytesPerHour > 0 && limit != bytesPerHour:
+		c.Status, c.Detail, c.Fix = SetupMissing, fmt.Sprintf("limit is %d bytes per hour, want %d", limit, bytesPerHour), fix
+	case want == "" && strategy != RetrievalBytesPerHour:
+		c.Status = SetupWarning
+	}
+	return c
+}
+
+// glacierProbe is an IAM action and the harmless request that shows whether
+// the keys have it.
+type glacierProbe struct {
+	action string
+	usedBy string
+	call   func(ctx context.Context) error
+}
+
+// bogusGlacierID is the archive, job and upload ID of probes; Glacier
+// rejects it after authorizing the request.
+const bogusGlacierID = "ciwg-cli-permission-probe"
+
+// glacierProbes returns the probes of every action the backup commands use.
+// None changes the vault: uploads are sent with a wrong checksum, jobs and
+// deletes name an archive that doesn't exist, and the multipart upload is
+// aborted.
+func (bm *BackupManager) glacierProbes() []glacierProbe {
+	account, vault := aws.String(bm.glacierAccountID()), aws.String(bm.awsConfig.Vault)
+	body := []byte("x")
+	linear := sha256.Sum256(body)
+	payload := hex.EncodeToString(linear[:])
+	wrongTree := strings.Repeat("0", 64)
+	var uploadID string
+	return []glacierProbe{
+		{"glacier:ListJobs", "aws-audit, restore", func(ctx context.Context) error {
+			_, err := bm.awsClient.ListJobs(ctx, &glacier.ListJobsInput{AccountId: account, VaultName: vault, Limit: aws.Int32(1)})
+			return err
+		}},
+		{"glacier:ListMultipartUploads", "create, migrate-aws (resuming uploads)", func(ctx context.Context) error {
+			_, err := bm.awsClient.ListMultipartUploads(ctx, &glacier.ListMultipartUploadsInput{AccountId: account, VaultName: vault, Limit: aws.Int32(1)})
+			return err
+		}},
+		{"glacier:UploadArchive", "create, migrate-aws", func(ctx context.Context) error {
+			_, err := bm.awsClient.UploadArchive(v4.SetPayloadHash(ctx, payload), &glacier.UploadArchiveInput{
+				AccountId: account, VaultName: vault, Checksum: aws.String(wrongTree),
+				ArchiveDescription: aws.String("ciwg-cli permission probe"),
+				Body:               strings.NewReader(string(body)),
+			}, withPayloadHash(payload, int64(len(body))))
+			return err
+		}},
+		{"glacier:InitiateMultipartUpload", "create, migrate-aws (large archives)", func(ctx context.Context) error {
+			out, err := bm.awsClient.InitiateMultipartUpload(ctx, &glacier.InitiateMultipartUploadInput{
+				AccountId: account, VaultName: vault, PartSize: aws.String("1048576"),
+				ArchiveDescription: aws.String("ciwg-cli permission probe (never completed)"),
+			})
+			if err == nil {
+				uploadID = aws.ToString(out.UploadId)
+			}
+			return err
+		}},
+		{"glacier:UploadMultipartPart", "create, migrate-aws (large archives)", func(ctx context.Context) error {
+			id := uploadID
+			if id == "" {
+				id = bogusGlacierID
+			}
+			_, err := bm.awsClient.UploadMultipartPart(v4.SetPayloadHash(ctx, payload), &glacier.UploadMultipartPartInput{
+				AccountId: account, VaultName: vault, UploadId: aws.String(id),
+				Range: aws.String(glacierPartRange(0, int64(len(body)))), Checksum: aws.String(wrongTree),
+				Body: strings.NewReader(string(body)),
+			}, withPayloadHash(payload, int64(len(body))))
+			return err
+		}},
+		{"glacier:CompleteMultipartUpload", "create, migrate-aws (large archives)", func(ctx context.Context) error {
+			_, err := bm.awsClient.CompleteMultipartUpload(ctx, &glacier.CompleteMultipartUploadInput{
+				AccountId: account, VaultName: vault, UploadId: aws.String(bogusGlacierID),
+				ArchiveSize: aws.String("1"), Checksum: aws.String(wrongTree),
+			})
+			return err
+		}},
+		{"glacier:AbortMultipartUpload", "create, migrate-aws, bench", func(ctx context.Context) error {
+			id := uploadID
+			if id == "" {
+				id = bogusGlacierID
+			}
+			_, err := bm.awsClient.AbortMultipartUpload(ctx, &glacier.AbortMultipartUploadInput{AccountId: account, VaultName: vault, UploadId: aws.String(id)})
+			return err
+		}},
+		{"glacier:InitiateJob", "restore, aws-audit --initiate", func(ctx context.Context) error {
+			_, err := bm.awsClient.InitiateJob(ctx, &glacier.InitiateJobInput{
+				AccountId: account, VaultName: vault,
+				JobParameters: &types.JobParameters{Type: aws.String("archive-retrieval"), ArchiveId: aws.String(bogusGlacierID)},
+			})
+			return err
+		}},
+		{"glacier:DescribeJob", "restore, aws-audit --job-id", func(ctx context.Context) error {
+			_, err := bm.awsClient.DescribeJob(ctx, &glacier.DescribeJobInput{AccountId: account, VaultName: vault, JobId: aws.String(bogusGlacierID)})
+			return err
+		}},
+		{"glacier:GetJobOutput", "restore, aws-audit --job-id", func(ctx context.Context) error {
+			_, err := bm.awsClient.GetJobOutput(ctx, &glacier.GetJobOutputInput{AccountId: account, VaultName: vault, JobId: aws.String(bogusGlacierID)})
+			return err
+		}},
+		{"glacier:DeleteArchive", "delete, retention of archived backups", func(ctx context.Context) error {
+			_, err := bm.awsClient.DeleteArchive(ctx, &glacier.DeleteArchiveInput{AccountId: account, VaultName: vault, ArchiveId: aws.String(bogusGlacierID)})
+			return err
+		}},
+	}
+}
+
+// VerifyGlacierSetup checks that the vault exists, notifies an SNS topic of
+// completed jobs, that the region's data retrieval policy is as expected,
+// and that the keys may perform every action the backup commands use.
+func (bm *BackupManager) VerifyGlacierSetup(opts GlacierSetupOptions) (*GlacierSetupReport, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	account, vault := aws.String(bm.glacierAccountID()), aws.String(bm.awsConfig.Vault)
+	rep := &GlacierSetupReport{Vault: bm.awsConfig.Vault}
+
+	desc, err := bm.awsClient.DescribeVault(ctx, &glacier.DescribeVaultInput{AccountId: account, VaultName: vault})
+	switch {
+	case err == nil:
+		rep.VaultARN = aws.ToString(desc.VaultARN)
+		rep.add(SetupCheck{Name: "vault", Status: SetupOK, Detail: fmt.Sprintf("%s (%d archives, %.2f GB)", rep.VaultARN, desc.NumberOfArchives, float64(desc.SizeInBytes)/(1024*1024*1024))})
+	case isNotFound(err):
+		rep.add(SetupCheck{Name: "vault", Status: SetupMissing, Detail: fmt.Sprintf("vault %s does not exist in %s", rep.Vault, bm.awsConfig.Region), Fix: "ciwg-cli backup aws-setup apply"})
+		return rep, nil
+	default:
+		if status := probeStatus(err); status != SetupDenied {
+			return nil, fmt.Errorf("failed to describe vault %s: %w", rep.Vault, err)
+		}
+		rep.add(SetupCheck{Name: "vault", Status: SetupDenied, Action: "glacier:DescribeVault", UsedBy: "aws-setup, test-aws", Detail: err.Error()})
+	}
+
+	notif, err := bm.awsClient.GetVaultNotifications(ctx, &glacier.GetVaultNotificationsInput{AccountId: account, VaultName: vault})
+	switch {
+	case err == nil:
+		rep.add(checkNotifications(notif.VaultNotificationConfig, opts.SNSTopic))
+	case isNotFound(err):
+		// Glacier answers ResourceNotFound for a vault without notifications.
+		rep.add(checkNotifications(nil, opts.SNSTopic))
+	case probeStatus(err) == SetupDenied:
+		rep.add(SetupCheck{Name: "notifications", Status: SetupDenied, Action: "glacier:GetVaultNotifications", UsedBy: "aws-setup", Detail: err.Error()})
+	default:
+		return nil, fmt.Errorf("failed to get vault notifications: %w", err)
+	}
+
+	policy, err := bm.awsClient.GetDataRetrievalPolicy(ctx, &glacier.GetDataRetrievalPolicyInput{AccountId: account})
+	switch {
+	case err == nil:
+		rep.add(checkRetrievalPolicy(policy.Policy, opts.RetrievalStrategy, opts.BytesPerHour))
+	case probeStatus(err) == SetupDenied:
+		rep.add(SetupCheck{Name: "retrieval-policy", Status: SetupDenied, Action: "glacier:GetDataRetrievalPolicy", UsedBy: "aws-setup", Detail: err.Error()})
+	default:
+		return nil, fmt.Errorf("failed to get data retrieval policy: %w", err)
+	}
+
+	for _, p := range bm.glacierProbes() {
+		c := SetupCheck{Name: "permission", Action: p.action, UsedBy: p.usedBy}
+		err := p.call(ctx)
+		c.Status = probeStatus(err)
+		switch c.Status {
+		case SetupOK:
+			c.Detail = "allowed"
+		case SetupDenied:
+			c.Detail = err.Error()
+			c.Fix = "grant " + p.action + " on " + rep.Vault
+		default:
+			c.Detail = fmt.Sprintf("could not be checked: %v", err)
+		}
+		rep.add(c)
+	}
+	return rep, nil
+}
+
+// ApplyGlacierSetup creates the vault if it is missing, points its job
+// notifications at opts.SNSTopic and sets the data retrieval policy, each
+// only when requested and not already in place, then verifies the result.
+func (bm *BackupManager) ApplyGlacierSetup(opts GlacierSetupOptions) (*GlacierSetupReport, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	account, vault := aws.String(bm.glacierAccountID()), aws.String(bm.awsConfig.Vault)
+	apply := func(what string, fn func() error) error {
+		if opts.DryRun || bm.dryRun {
+			fmt.Printf("[DRY RUN] Would %s\n", what)
+			return nil
+		}
+		if err := fn(); err != nil {
+			if probeStatus(err) == SetupDenied {
+				return fmt.Errorf("failed to %s: the keys lack the permission (%w)", what, err)
+			}
+			return fmt.Errorf("failed to %s: %w", what, err)
+		}
+		fmt.Printf("✓ %s\n", strings.ToUpper(what[:1])+what[1:])
+		return nil
+	}
+
+	if _, err := bm.awsClient.DescribeVault(ctx, &glacier.DescribeVaultInput{AccountId: account, VaultName: vault}); isNotFound(err) {
+		if err := apply("create vault "+bm.awsConfig.Vault, func() error {
+			_, err := bm.awsClient.CreateVault(ctx, &glacier.CreateVaultInput{AccountId: account, VaultName: vault})
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	} else if err != nil && probeStatus(err) != SetupDenied {
+		return nil, fmt.Errorf("failed to describe vault %s: %w", bm.awsConfig.Vault, err)
+	}
+
+	if opts.SNSTopic != "" {
+		notif, err := bm.awsClient.GetVaultNotifications(ctx, &glacier.GetVaultNotificationsInput{AccountId: account, VaultName: vault})
+		var cfg *types.VaultNotificationConfig
+		if err == nil {
+			cfg = notif.VaultNotificationConfig
+		}
+		if checkNotifications(cfg, opts.SNSTopic).Status != SetupOK {
+			if err := apply("notify "+opts.SNSTopic+" of completed jobs", func() error {
+				_, err := bm.awsClient.SetVaultNotifications(ctx, &glacier.SetVaultNotificationsInput{
+					AccountId: account, VaultName: vault,
+					VaultNotificationConfig: &types.VaultNotificationConfig{SNSTopic: aws.String(opts.SNSTopic), Events: GlacierJobEvents},
+				})
+				return err
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.RetrievalStrategy != "" {
+		current, err := bm.awsClient.GetDataRetrievalPolicy(ctx, &glacier.GetDataRetrievalPolicyInput{AccountId: account})
+		var policy *types.DataRetrievalPolicy
+		if err == nil {
+			policy = current.Policy
+		}
+		if checkRetrievalPolicy(policy, opts.RetrievalStrategy, opts.BytesPerHour).Status != SetupOK {
+			rule := types.DataRetrievalRule{Strategy: aws.String(opts.RetrievalStrategy)}
+			if opts.RetrievalStrategy == RetrievalBytesPerHour {
+				rule.BytesPerHour = aws.Int64(opts.BytesPerHour)
+			}
+			if err := apply("set the data retrieval policy to "+opts.RetrievalStrategy, func() error {
+				_, err := bm.awsClient.SetDataRetrievalPolicy(ctx, &glacier.SetDataRetrievalPolicyInput{
+					AccountId: account, Policy: &types.DataRetrievalPolicy{Rules: []types.DataRetrievalRule{rule}},
+				})
+				return err
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return nil, nil
+	}
+	return bm.VerifyGlacierSetup(opts)
+}
+
+// ValidateRetrievalStrategy checks a --retrieval-strategy value.
+func ValidateRetrievalStrategy(strategy string, bytesPerHour int64) error {
+	switch strategy {
+	case "", RetrievalFreeTier, RetrievalNone:
+		if bytesPerHour > 0 {
+			return fmt.Errorf("a bytes-per-hour limit needs the %s strategy", RetrievalBytesPerHour)
+		}
+		return nil
+	case RetrievalBytesPerHour:
+		if bytesPerHour <= 0 {
+			return fmt.Errorf("the %s strategy needs a positive bytes-per-hour limit", RetrievalBytesPerHour)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown retrieval strategy %q (want %s, %s or %s)", strategy, RetrievalBytesPerHour, RetrievalFreeTier, RetrievalNone)
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glacier/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestProbeStatus(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+	notFound := &smithy.GenericAPIError{Code: "ResourceNotFoundException"}
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{nil, SetupOK},
+		{fmt.Errorf("operation error: %w", denied), SetupDenied},
+		{notFound, SetupOK},
+		{&smithy.GenericAPIError{Code: "InvalidParameterValueException"}, SetupOK},
+		{errors.New("dial tcp: timeout"), SetupError},
+	} {
+		if got := probeStatus(tc.err); got != tc.want {
+			t.Errorf("probeStatus(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+	if !isNotFound(notFound) || isNotFound(denied) {
+		t.Error("isNotFound() misclassifies")
+	}
+}
+
+func TestCheckNotifications(t *testing.T) {
+	topic := "arn:aws:sns:us-east-1:1:glacier"
+	if c := checkNotifications(nil, ""); c.Status != SetupMissing {
+		t.Errorf("checkNotifications(nil) = %+v", c)
+	}
+	partial := &types.VaultNotificationConfig{SNSTopic: aws.String(topic), Events: []string{"ArchiveRetrievalCompleted"}}
+	if c := checkNotifications(partial, ""); c.Status != SetupMissing || !strings.Contains(c.Detail, "InventoryRetrievalCompleted") {
+		t.Errorf("checkNotifications(partial) = %+v", c)
+	}
+	full := &types.VaultNotificationConfig{SNSTopic: aws.String(topic), Events: GlacierJobEvents}
+	if c := checkNotifications(full, topic); c.Status != SetupOK {
+		t.Errorf("checkNotifications(full) = %+v", c)
+	}
+	if c := checkNotifications(full, "arn:aws:sns:us-east-1:1:other"); c.Status != SetupMissing {
+		t.Errorf("checkNotifications(other topic) = %+v", c)
+	}
+}
+
+func TestCheckRetrievalPolicy(t *testing.T) {
+	policy := func(strategy string, bph int64) *types.DataRetrievalPolicy {
+		rule := types.DataRetrievalRule{Strategy: aws.String(strategy)}
+		if bph > 0 {
+			rule.BytesPerHour = aws.Int64(bph)
+		}
+		return &types.DataRetrievalPolicy{Rules: []types.DataRetrievalRule{rule}}
+	}
+	for _, tc := range []struct {
+		policy *types.DataRetrievalPolicy
+		want   string
+		bph    int64
+		status string
+	}{
+		{nil, "", 0, SetupWarning},
+		{policy(RetrievalBytesPerHour, 1<<30), "", 0, SetupOK},
+		{policy(RetrievalFreeTier, 0), RetrievalFreeTier, 0, SetupOK},
+		{policy(RetrievalNone, 0), RetrievalBytesPerHour, 1 << 30, SetupMissing},
+		{policy(RetrievalBytesPerHour, 1<<30), RetrievalBytesPerHour, 2 << 30, SetupMissing},
+	} {
+		if c := checkRetrievalPolicy(tc.policy, tc.want, tc.bph); c.Status != tc.status {
+			t.Errorf("checkRetrievalPolicy(%v, %q) = %+v, want %s", tc.policy, tc.want, c, tc.status)
+		}
+	}
+}
+
+func TestGlacierSetupReportPolicy(t *testing.T) {
+	rep := &GlacierSetupReport{Vault: "backups"}
+	rep.add(SetupCheck{Name: "permission", Status: SetupOK, Action: "glacier:ListJobs"})
+	rep.add(SetupCheck{Name: "permission", Status: SetupDenied, Action: "glacier:DeleteArchive"})
+	rep.add(SetupCheck{Name: "permission", Status: SetupDenied, Action: "glacier:DeleteArchive"})
+	if rep.OK() || len(rep.Denied) != 1 {
+		t.Fatalf("report = %+v", rep)
+	}
+	policy := rep.PolicyStatement()
+	if !strings.Contains(policy, `"glacier:DeleteArchive"`) || !strings.Contains(policy, "vaults/backups") || strings.Contains(policy, "ListJobs") {
+		t.Errorf("PolicyStatement() = %s", policy)
+	}
+}
+
+func TestValidateRetrievalStrategy(t *testing.T) {
+	if err := ValidateRetrievalStrategy(RetrievalBytesPerHour, 0); err == nil {
+		t.Error("BytesPerHour without a limit accepted")
+	}
+	if err := ValidateRetrievalStrategy(RetrievalFreeTier, 10); err == nil {
+		t.Error("a limit with FreeTier accepted")
+	}
+	if err := ValidateRetrievalStrategy("Unlimited", 0); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if err := ValidateRetrievalStrategy("", 0); err != nil {
+		t.Errorf("empty strategy: %v", err)
+	}
+}
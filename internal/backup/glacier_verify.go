@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Glacier verification states recorded in GlacierUploadStats.Verification.
+// An empty state means the upload was not verified.
+const (
+	GlacierVerified         = "verified"
+	GlacierChecksumMismatch = "mismatch"
+)
+
+// verifyGlacierBuffer re-reads the buffered upload in f after it was sent and
+// checks that its tree hash still equals the hash it was uploaded with and the
+// checksum AWS returned. It runs after the upload so a slow disk only delays
+// the next part, never the transfer itself.
+func verifyGlacierBuffer(f *os.File, uploaded, returned string) error {
+	treeHash, _, _, err := computeHashesFromFile(f)
+	if err != nil {
+		return fmt.Errorf("failed to re-hash buffered data: %w", err)
+	}
+	return compareGlacierChecksums(treeHash, uploaded, returned)
+}
+
+// compareGlacierChecksums checks a locally computed tree hash against the
+// hash sent with the upload and the checksum in the AWS response.
+func compareGlacierChecksums(local, uploaded, returned string) error {
+	if returned == "" {
+		return fmt.Errorf("%w: AWS returned no checksum", ErrGlacierChecksumMismatch)
+	}
+	if local != uploaded {
+		return fmt.Errorf("%w: buffered data changed after upload (uploaded %s, now %s)", ErrGlacierChecksumMismatch, uploaded, local)
+	}
+	if local != returned {
+		return fmt.Errorf("%w: local tree hash %s, AWS returned %s", ErrGlacierChecksumMismatch, local, returned)
+	}
+	return nil
+}
+
+// treeHashStream computes the Glacier tree hash of the next n bytes of r.
+func treeHashStream(r io.Reader, n int64) (string, error) {
+	const chunkSize = 1024 * 1024
+	buf := make([]byte, chunkSize)
+	var tree treeHasher
+	for n > 0 {
+		size := int64(chunkSize)
+		if n < size {
+			size = n
+		}
+		if _, err := io.ReadFull(r, buf[:size]); err != nil {
+			return "", err
+		}
+		tree.add(sha256.Sum256(buf[:size]))
+		n -= size
+	}
+	return tree.sum(), nil
+}
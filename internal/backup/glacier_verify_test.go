@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTreeHashStreamMatchesTreeHash(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 160*1024) // 2.5 MiB
+	r := bytes.NewReader(append(append([]byte(nil), data...), "trailing"...))
+	got, err := treeHashStream(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("treeHashStream() error = %v", err)
+	}
+	if want := computeTreeHash(data); got != want {
+		t.Errorf("treeHashStream() = %s, want %s", got, want)
+	}
+	if r.Len() != len("trailing") {
+		t.Errorf("treeHashStream() consumed %d extra bytes", len("trailing")-r.Len())
+	}
+	if _, err := treeHashStream(bytes.NewReader(data[:10]), 20); err == nil {
+		t.Error("expected error for short stream")
+	}
+}
+
+func TestVerifyGlacierBuffer(t *testing.T) {
+	data := []byte("archive contents")
+	f, err := os.Create(filepath.Join(t.TempDir(), "part.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write(data)
+	hash := computeTreeHash(data)
+
+	if err := verifyGlacierBuffer(f, hash, hash); err != nil {
+		t.Errorf("verifyGlacierBuffer() matching error = %v", err)
+	}
+	for name, tc := range map[string][2]string{
+		"aws differs":      {hash, computeTreeHash([]byte("other"))},
+		"buffer changed":   {computeTreeHash([]byte("other")), hash},
+		"missing checksum": {hash, ""},
+	} {
+		if err := verifyGlacierBuffer(f, tc[0], tc[1]); !errors.Is(err, ErrGlacierChecksumMismatch) {
+			t.Errorf("%s: verifyGlacierBuffer() error = %v, want checksum mismatch", name, err)
+		}
+	}
+}
+
+func TestRecordMigrationBuildsLedgerRecord(t *testing.T) {
+	bm := &BackupManager{}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	bm.recordMigration(start, "backups/a.com/a-1.tgz", &GlacierUploadStats{ArchiveID: "arch-1", Bytes: 10, Verification: GlacierVerified}, nil)
+	bm.recordMigration(start, "backups/b.com/b-1.tgz", &GlacierUploadStats{ArchiveID: "arch-2", Bytes: 20, Verification: GlacierChecksumMismatch}, ErrGlacierChecksumMismatch)
+	bm.recordMigration(start, "backups/c.com/c-1.tgz", nil, errors.New("network down"))
+
+	rec := bm.LastRunRecord()
+	if rec == nil || rec.Kind != RunKindMigration || rec.Succeeded != 1 || rec.Failed != 2 || len(rec.Uploads) != 2 {
+		t.Fatalf("LastRunRecord() = %+v", rec)
+	}
+	if rec.Uploads[0].Site != "a.com" {
+		t.Errorf("upload site = %q, want a.com", rec.Uploads[0].Site)
+	}
+
+	ledger := GlacierLedger([]RunRecord{*rec})
+	if len(ledger) != 2 || ledger[1].Verification != GlacierChecksumMismatch {
+		t.Errorf("GlacierLedger() = %+v", ledger)
+	}
+
+	summaries, err := SummarizePerformance([]RunRecord{*rec}, "site", time.Time{})
+	if err != nil || len(summaries) != 0 {
+		t.Errorf("SummarizePerformance() should skip migration runs, got %+v, %v", summaries, err)
+	}
+}
@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RunRecord captures the outcome of a single `backup create` invocation
+// against one host. Records are appended to a JSON-lines history file so
+// later commands (reports, targeted deletes) can reason about past runs.
+type RunRecord struct {
+	ID         string        `json:"id"`
+	Host       string        `json:"host"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	DryRun     bool          `json:"dry_run,omitempty"`
+	Succeeded  int           `json:"succeeded"`
+	Failed     int           `json:"failed"`
+	Uploads    []UploadStats `json:"uploads,omitempty"`
+	// Failures lists the containers that failed, with their diagnosis.
+	Failures []ContainerFailure `json:"failures,omitempty"`
+	// Skipped lists the containers left out of the run, e.g. sites in
+	// maintenance.
+	Skipped []ContainerSkip `json:"skipped,omitempty"`
+	// Kind is empty for backup runs, RunKindMigration for runs that moved
+	// existing objects to Glacier, RunKindRestore for site restores and
+	// RunKindDrill for recovery drills.
+	Kind string `json:"kind,omitempty"`
+	// Restore describes the restore of a RunKindRestore record; the run's
+	// start and finish times measure how long it took.
+	Restore *RestoreStats `json:"restore,omitempty"`
+	// Drills are the per-site results of a RunKindDrill record.
+	Drills []DrillResult `json:"drills,omitempty"`
+	// AuditedCommands is how many shell commands the run wrote to the
+	// command audit log under its ID; see CommandAuditConfig.
+	AuditedCommands int `json:"audited_commands,omitempty"`
+	// Features lists the rollout features that were on for the run; see
+	// Fleet.HostFeatures.
+	Features []string `json:"features,omitempty"`
+	// TarWarnings counts the warnings tar printed across the run by
+	// category (TarWarningFileChanged, ...).
+	TarWarnings map[string]int `json:"tar_warnings,omitempty"`
+	// CompletedWithWarnings is set when TarWarnings reached the run's
+	// warning threshold.
+	CompletedWithWarnings bool `json:"completed_with_warnings,omitempty"`
+}
+
+// Run record kinds.
+const (
+	// RunKindMigration marks run records written by Glacier migrations.
+	RunKindMigration = "migration"
+	// RunKindRestore marks run records written by `backup restore`.
+	RunKindRestore = "restore"
+	// RunKindDrill marks run records written by `backup drill`.
+	RunKindDrill = "drill"
+	// RunKindSpool marks run records written by `backup upload-spool`.
+	RunKindSpool = "spool-upload"
+)
+
+// RestoreStats identifies what a restore run restored.
+type RestoreStats struct {
+	Site      string `json:"site"`
+	ObjectKey string `json:"object_key"`
+	As        string `json:"as,omitempty"`
+}
+
+// UploadStats holds per-object throughput measurements for a backup upload.
+type UploadStats struct {
+	Site             string  `json:"site"`
+	Container        string  `json:"container"`
+	ObjectKey        string  `json:"object_key"`
+	Bytes            int64   `json:"bytes"`
+	UncompressedSize int64   `json:"uncompressed_size,omitempty"`
+	MinioSeconds     float64 `json:"minio_seconds"`
+	MinioMBps        float64 `json:"minio_mbps"`
+
+	// PostUploadCheck is the check the upload passed ("quick" or "full"), or
+	// "failed"; empty when none was run.
+	PostUploadCheck string `json:"post_upload_check,omitempty"`
+	// Checksum is the checksum of the uploaded stream as "<algorithm>:<hex>",
+	// recorded when a post-upload check hashed it.
+	Checksum string `json:"checksum,omitempty"`
+	// StorageClass is the storage class the object was uploaded with when
+	// MinioConfig.StorageClasses mapped its retention tier to one.
+	StorageClass string `json:"storage_class,omitempty"`
+	// TarWarnings counts the warnings tar printed for this backup by
+	// category.
+	TarWarnings map[string]int `json:"tar_warnings,omitempty"`
+	// NestedArchives lists the large archives found in the site directory,
+	// which are left out of the backup unless Included.
+	NestedArchives []NestedArchive `json:"nested_archives,omitempty"`
+
+	// Glacier figures are only populated when the run also uploaded to AWS.
+	Glacier *GlacierUploadStats `json:"glacier,omitempty"`
+
+	// Destinations and Missed list which destinations of a dual upload
+	// (DestinationMinio, DestinationGlacier) the backup reached.
+	Destinations []string `json:"destinations,omitempty"`
+	Missed       []string `json:"missed,omitempty"`
+	// Spooled is set when the archive went to the local spool, for `backup
+	// upload-spool` to upload; ObjectKey is where it will be stored.
+	Spooled bool `json:"spooled,omitempty"`
+
+	digest *uploadDigest
+}
+
+// GlacierUploadStats breaks an AWS Glacier upload down into its phases:
+// buffering to a temp file, computing tree/linear hashes and the upload itself.
+type GlacierUploadStats struct {
+	ArchiveID       string  `json:"archive_id,omitempty"`
+	TreeHash        string  `json:"tree_hash,omitempty"`
+	Bytes           int64   `json:"bytes"`
+	BufferSeconds   float64 `json:"buffer_seconds"`
+	ChecksumSeconds float64 `json:"checksum_seconds"`
+	UploadSeconds   float64 `json:"upload_seconds"`
+	UploadMBps      float64 `json:"upload_mbps"`
+	// Verification is GlacierVerified or GlacierChecksumMismatch when the
+	// upload was checked against the checksum AWS returned, empty otherwise.
+	Verification  string  `json:"verification,omitempty"`
+	VerifySeconds float64 `json:"verify_seconds,omitempty"`
+	// Compression is the codec the archive was re-compressed with when it
+	// differs from the Minio copy, e.g. "zstd-19"; empty for the same gzip.
+	Compression string `json:"compression,omitempty"`
+	// CreatedAt is the vault creation date of archives adopted from an
+	// inventory rather than uploaded by a recorded run.
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// DefaultHistoryPath returns the default location of the run history file
+// (~/.ciwg/backup-history.jsonl).
+func DefaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-backup-history.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "backup-history.jsonl")
+}
+
+// NewRunID returns a sortable, reasonably unique identifier for a run.
+func NewRunID(now time.Time) string {
+	b := make([]byte, 3)
+	stamp := InTimezone(now).Format("20060102-150405")
+	if _, err := rand.Read(b); err != nil {
+		return stamp
+	}
+	return fmt.Sprintf("%s-%s", stamp, hex.EncodeToString(b))
+}
+
+// AppendRunRecord appends a run record to the JSON-lines history file at path,
+// creating the file and its parent directory if needed.
+func AppendRunRecord(path string, rec *RunRecord) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create history directory: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run record: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write run record: %w", err)
+	}
+	return nil
+}
+
+// LoadRunRecords reads every run record from the history file. A missing file
+// yields an empty slice. Malformed lines are skipped.
+func LoadRunRecords(path string) ([]RunRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RunRecord{}, nil
+		}
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var records []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartedAt.Before(records[j].StartedAt)
+	})
+	return records, nil
+}
+
+// FindRunRecord returns the run record with the given ID.
+func FindRunRecord(path, id string) (*RunRecord, error) {
+	records, err := LoadRunRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].ID == id {
+			return &records[i], nil
+		}
+	}
+	return nil, fmt.Errorf("run %s not found in %s", id, path)
+}
+
+// mbps converts a byte count and duration into MB/s, guarding against zero durations.
+func mbps(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1024 * 1024) / d.Seconds()
+}
+
+// ObjectKeys returns the Minio object keys uploaded during the run.
+func (r *RunRecord) ObjectKeys() []string {
+	keys := make([]string, 0, len(r.Uploads))
+	for _, u := range r.Uploads {
+		if u.ObjectKey != "" {
+			keys = append(keys, u.ObjectKey)
+		}
+	}
+	return keys
+}
+
+// RunDeletionPlan describes what `backup delete --run` would do for a run.
+type RunDeletionPlan struct {
+	RunID string
+	// Delete holds objects that will be removed.
+	Delete []string
+	// Missing holds objects recorded by the run that no longer exist.
+	Missing []string
+	// Protected holds objects kept because they are the last backup of their site.
+	Protected []string
+}
+
+// PlanRunDeletion resolves the objects produced by rec against the bucket.
+// Unless allowLast is set, an object is protected when deleting it would leave
+// its site prefix without any backup.
+func (bm *BackupManager) PlanRunDeletion(rec *RunRecord, allowLast bool) (*RunDeletionPlan, error) {
+	listings := make(map[string][]ObjectInfo)
+	for _, key := range rec.ObjectKeys() {
+		prefix := path.Dir(key) + "/"
+		if _, ok := listings[prefix]; ok {
+			continue
+		}
+		objs, err := bm.ListBackups(prefix, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backups under %s: %w", prefix, err)
+		}
+		listings[prefix] = objs
+	}
+	return planRunDeletion(rec, listings, allowLast), nil
+}
+
+// planRunDeletion is the pure part of PlanRunDeletion; listings maps each
+// site prefix to the objects currently stored under it.
+func planRunDeletion(rec *RunRecord, listings map[string][]ObjectInfo, allowLast bool) *RunDeletionPlan {
+	plan := &RunDeletionPlan{RunID: rec.ID}
+
+	byPrefix := make(map[string][]string)
+	for _, key := range rec.ObjectKeys() {
+		prefix := path.Dir(key) + "/"
+		byPrefix[prefix] = append(byPrefix[prefix], key)
+	}
+
+	prefixes := make([]string, 0, len(byPrefix))
+	for p := range byPrefix {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		existing := make(map[string]bool, len(listings[prefix]))
+		for _, o := range listings[prefix] {
+			existing[o.Key] = true
+		}
+		var present []string
+		for _, key := range byPrefix[prefix] {
+			if existing[key] {
+				present = append(present, key)
+			} else {
+				plan.Missing = append(plan.Missing, key)
+			}
+		}
+		if len(present) == 0 {
+			continue
+		}
+		if !allowLast && len(present) == len(existing) {
+			plan.Protected = append(plan.Protected, present...)
+			continue
+		}
+		plan.Delete = append(plan.Delete, present...)
+	}
+	return plan
+}
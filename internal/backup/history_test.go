@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunRecordHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Append out of order to confirm LoadRunRecords sorts by start time.
+	for _, rec := range []*RunRecord{
+		{ID: "second", Host: "wp1", StartedAt: base.Add(time.Hour)},
+		{ID: "first", Host: "wp0", StartedAt: base},
+	} {
+		if err := AppendRunRecord(path, rec); err != nil {
+			t.Fatalf("AppendRunRecord() error = %v", err)
+		}
+	}
+
+	records, err := LoadRunRecords(path)
+	if err != nil {
+		t.Fatalf("LoadRunRecords() error = %v", err)
+	}
+	if len(records) != 2 || records[0].ID != "first" || records[1].ID != "second" {
+		t.Fatalf("LoadRunRecords() = %+v, want [first second]", records)
+	}
+
+	if _, err := FindRunRecord(path, "missing"); err == nil {
+		t.Error("FindRunRecord() expected error for unknown ID")
+	}
+
+	missing, err := LoadRunRecords(filepath.Join(t.TempDir(), "none.jsonl"))
+	if err != nil || len(missing) != 0 {
+		t.Errorf("LoadRunRecords() on missing file = %v, %v; want empty, nil", missing, err)
+	}
+}
+
+func TestPlanRunDeletion(t *testing.T) {
+	rec := &RunRecord{
+		ID: "run-1",
+		Uploads: []UploadStats{
+			{Site: "a.com", ObjectKey: "backups/a.com/a.com-20240102-000000.tgz"},
+			{Site: "b.com", ObjectKey: "backups/b.com/b.com-20240102-000000.tgz"},
+			{Site: "c.com", ObjectKey: "backups/c.com/c.com-20240102-000000.tgz"},
+		},
+	}
+	listings := map[string][]ObjectInfo{
+		"backups/a.com/": {
+			{Key: "backups/a.com/a.com-20240101-000000.tgz"},
+			{Key: "backups/a.com/a.com-20240102-000000.tgz"},
+		},
+		"backups/b.com/": {
+			{Key: "backups/b.com/b.com-20240102-000000.tgz"},
+		},
+		"backups/c.com/": {},
+	}
+
+	plan := planRunDeletion(rec, listings, false)
+	if len(plan.Delete) != 1 || plan.Delete[0] != "backups/a.com/a.com-20240102-000000.tgz" {
+		t.Errorf("Delete = %v", plan.Delete)
+	}
+	if len(plan.Protected) != 1 || plan.Protected[0] != "backups/b.com/b.com-20240102-000000.tgz" {
+		t.Errorf("Protected = %v", plan.Protected)
+	}
+	if len(plan.Missing) != 1 || plan.Missing[0] != "backups/c.com/c.com-20240102-000000.tgz" {
+		t.Errorf("Missing = %v", plan.Missing)
+	}
+
+	plan = planRunDeletion(rec, listings, true)
+	if len(plan.Delete) != 2 || len(plan.Protected) != 0 {
+		t.Errorf("allowLast plan = %+v", plan)
+	}
+}
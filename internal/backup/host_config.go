@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HostConfigPrefix is where host-config side archives are stored, apart from
+// the site backups under backups/ so that site data and host configuration
+// never mix.
+const HostConfigPrefix = "host-config/"
+
+// HostConfigMissingFile lists, inside a side archive, the configured paths
+// that did not exist on the host when it was captured.
+const HostConfigMissingFile = "MISSING"
+
+// HostConfig selects the host configuration captured next to each site
+// backup: the crontabs a restored site relies on and the reverse-proxy
+// config routing to it. It is read from the host-config file
+// (~/.ciwg/host-config.yaml); {site} in a path is replaced by the site name:
+//
+//	crontab_users: [root, www-data]   # default: root
+//	paths:
+//	  - /etc/nginx/sites-enabled/{site}.conf
+//	  - /etc/traefik/dynamic/{site}.yml
+//	  - /etc/cron.d
+//	sites:
+//	  legacy.com:
+//	    paths: [/etc/logrotate.d/legacy]
+type HostConfig struct {
+	CrontabUsers []string                  `yaml:"crontab_users,omitempty"`
+	Paths        []string                  `yaml:"paths,omitempty"`
+	Sites        map[string]HostConfigSite `yaml:"sites,omitempty"`
+}
+
+// HostConfigSite adds paths captured for one site only.
+type HostConfigSite struct {
+	Paths []string `yaml:"paths"`
+}
+
+// DefaultHostConfigPath returns the default location of the host-config file
+// (~/.ciwg/host-config.yaml).
+func DefaultHostConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "host-config.yaml")
+}
+
+// LoadHostConfig reads the host-config file at path. A missing file yields
+// nil, which disables the capture.
+func LoadHostConfig(path string) (*HostConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read host config: %w", err)
+	}
+	var hc HostConfig
+	if err := yaml.Unmarshal(data, &hc); err != nil {
+		return nil, fmt.Errorf("failed to parse host config %s: %w", path, err)
+	}
+	check := func(p string) error {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("host config %s: path must be absolute, got %s", path, p)
+		}
+		return nil
+	}
+	for _, p := range hc.Paths {
+		if err := check(p); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range hc.Sites {
+		for _, p := range s.Paths {
+			if err := check(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if hc.CrontabUsers == nil {
+		hc.CrontabUsers = []string{"root"}
+	}
+	return &hc, nil
+}
+
+// SitePaths returns the paths captured for site, with {site} expanded,
+// without duplicates and in a stable order.
+func (hc *HostConfig) SitePaths(site string) []string {
+	paths := append([]string{}, hc.Paths...)
+	paths = append(paths, hc.Sites[site].Paths...)
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range paths {
+		p = path.Clean(strings.ReplaceAll(p, "{site}", site))
+		if p == "/" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hostConfigObjectName is the key of the side archive of the backup
+// backupName of site.
+func hostConfigObjectName(site, backupName string) string {
+	return HostConfigPrefix + site + "/" + backupName
+}
+
+// hostConfigCommand builds the shell command writing the side archive of
+// site to stdout: crontab/<user> for each user with a crontab, the
+// configured paths under their absolute location (etc/nginx/...), and
+// MISSING listing the paths that do not exist.
+func (hc *HostConfig) hostConfigCommand(site string) string {
+	var users, paths []string
+	for _, u := range hc.CrontabUsers {
+		users = append(users, shellQuote(u))
+	}
+	for _, p := range hc.SitePaths(site) {
+		paths = append(paths, shellQuote(p))
+	}
+	var b strings.Builder
+	b.WriteString(`d=$(mktemp -d) || exit 1; trap 'rm -rf "$d"' EXIT; mkdir "$d/crontab"; `)
+	if len(users) > 0 {
+		fmt.Fprintf(&b, `for u in %s; do crontab -l -u "$u" > "$d/crontab/$u" 2>/dev/null || rm -f "$d/crontab/$u"; done; `, strings.Join(users, " "))
+	}
+	fmt.Fprintf(&b, `: > "$d/%s"; set --; `, HostConfigMissingFile)
+	if len(paths) > 0 {
+		fmt.Fprintf(&b, `for p in %s; do if [ -e "$p" ]; then set -- "$@" "${p#/}"; else echo "$p" >> "$d/%s"; fi; done; `, strings.Join(paths, " "), HostConfigMissingFile)
+	}
+	b.WriteString(`tar czf - -C "$d" . -C / "$@"`)
+	return b.String()
+}
+
+// captureHostConfig archives the host configuration of site and uploads it
+// as the side archive of backupName, returning its key. The side archive is
+// stored under HostConfigPrefix so it never mixes with the site data.
+func (bm *BackupManager) captureHostConfig(hc *HostConfig, site, backupName string) (string, error) {
+	stdout, stderr, err := bm.executeCommand(hc.hostConfigCommand(site))
+	if err != nil {
+		return "", fmt.Errorf("failed to capture host config: %w (stderr: %s)", err, strings.TrimSpace(stderr))
+	}
+	key := hostConfigObjectName(site, backupName)
+	if bm.minioConfig != nil && bm.minioConfig.BucketPath != "" {
+		key = path.Join(bm.minioConfig.BucketPath, key)
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return "", err
+	}
+	data := []byte(stdout)
+	if _, err := bm.putObject(context.Background(), key, bytes.NewReader(data), int64(len(data)), "application/gzip", nil); err != nil {
+		return "", fmt.Errorf("failed to upload host config %s: %w", key, err)
+	}
+	return key, nil
+}
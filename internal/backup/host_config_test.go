@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadHostConfig(t *testing.T) {
+	if hc, err := LoadHostConfig(filepath.Join(t.TempDir(), "missing.yaml")); hc != nil || err != nil {
+		t.Fatalf("LoadHostConfig(missing) = %v, %v", hc, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "host-config.yaml")
+	data := "paths:\n  - /etc/nginx/sites-enabled/{site}.conf\n  - /etc/cron.d\nsites:\n  acme.com:\n    paths: [/etc/cron.d, /etc/logrotate.d/acme]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hc, err := LoadHostConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHostConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(hc.CrontabUsers, []string{"root"}) {
+		t.Errorf("CrontabUsers = %v, want [root]", hc.CrontabUsers)
+	}
+	want := []string{"/etc/cron.d", "/etc/logrotate.d/acme", "/etc/nginx/sites-enabled/acme.com.conf"}
+	if got := hc.SitePaths("acme.com"); !reflect.DeepEqual(got, want) {
+		t.Errorf("SitePaths(acme.com) = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("paths: [etc/nginx]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHostConfig(path); err == nil {
+		t.Error("LoadHostConfig() accepted a relative path")
+	}
+}
+
+func TestCaptureHostConfig(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+	src := t.TempDir()
+	conf := filepath.Join(src, "acme.com.conf")
+	if err := os.WriteFile(conf, []byte("server_name acme.com;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hc := &HostConfig{Paths: []string{filepath.Join(src, "{site}.conf"), filepath.Join(src, "gone")}}
+
+	key, err := bm.captureHostConfig(hc, "acme.com", "acme.com-20260501-020000.tgz")
+	if err != nil {
+		t.Fatalf("captureHostConfig() error = %v", err)
+	}
+	if key != "host-config/acme.com/acme.com-20260501-020000.tgz" {
+		t.Errorf("captureHostConfig() key = %s", key)
+	}
+
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		files[strings.TrimPrefix(h.Name, "./")] = string(b)
+	}
+	if got := files[strings.TrimPrefix(conf, "/")]; got != "server_name acme.com;\n" {
+		t.Errorf("archive %s = %q, files %v", conf, got, files)
+	}
+	if got := files[HostConfigMissingFile]; got != filepath.Join(src, "gone")+"\n" {
+		t.Errorf("archive MISSING = %q", got)
+	}
+
+	objs, err := bm.listObjects(context.Background(), "backups/", 0)
+	if err != nil || len(objs) != 0 {
+		t.Errorf("backups/ = %v, %v; host config must stay out of the site prefix", objs, err)
+	}
+}
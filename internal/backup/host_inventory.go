@@ -0,0 +1,562 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"ciwg-cli/internal/auth"
+)
+
+// Host variables read from an inventory. The ansible_* names are Ansible's
+// own connection variables; ciwg_parent_dir sets the directory whose
+// subdirectories hold the host's sites (--container-parent-dir).
+const (
+	InventoryVarHost      = "ansible_host"
+	InventoryVarUser      = "ansible_user"
+	InventoryVarPort      = "ansible_port"
+	InventoryVarParentDir = "ciwg_parent_dir"
+)
+
+// inventoryVarAliases maps the deprecated Ansible spellings to the current
+// ones.
+var inventoryVarAliases = map[string]string{
+	"ansible_ssh_host": InventoryVarHost,
+	"ansible_ssh_user": InventoryVarUser,
+	"ansible_ssh_port": InventoryVarPort,
+}
+
+// canonicalInventoryVars returns vars with the deprecated spellings renamed;
+// the current spelling wins when both are set.
+func canonicalInventoryVars(vars map[string]string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if name, ok := inventoryVarAliases[k]; ok {
+			if _, set := vars[name]; set {
+				continue
+			}
+			k = name
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// InventoryGroupLabel is the value of the label every group of an inventory
+// puts on its hosts, so --group <name> (a bare key) selects the group.
+const InventoryGroupLabel = "true"
+
+// hostInventory is an Ansible inventory: groups of hosts with variables,
+// nested through children.
+type hostInventory struct {
+	groups map[string]*inventoryGroup
+	// hostVars are the variables set on the hosts themselves.
+	hostVars map[string]map[string]string
+}
+
+type inventoryGroup struct {
+	hosts    []string
+	vars     map[string]string
+	children []string
+}
+
+func newHostInventory() *hostInventory {
+	return &hostInventory{groups: map[string]*inventoryGroup{}, hostVars: map[string]map[string]string{}}
+}
+
+func (inv *hostInventory) group(name string) *inventoryGroup {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &inventoryGroup{vars: map[string]string{}}
+		inv.groups[name] = g
+	}
+	return g
+}
+
+// addHost adds the hosts of pattern to group with vars.
+func (inv *hostInventory) addHost(group, pattern string, vars map[string]string) error {
+	hosts, err := expandHostPattern(pattern)
+	if err != nil {
+		return err
+	}
+	g := inv.group(group)
+	for _, host := range hosts {
+		g.hosts = append(g.hosts, host)
+		hv, ok := inv.hostVars[host]
+		if !ok {
+			hv = map[string]string{}
+			inv.hostVars[host] = hv
+		}
+		for k, v := range vars {
+			hv[k] = v
+		}
+	}
+	return nil
+}
+
+// LoadHostInventory reads the hosts of an Ansible inventory (INI or YAML) or
+// of Terraform outputs (the JSON of 'terraform output -json' or a state file)
+// as a fleet. Each host is labelled with the groups it belongs to, directly
+// or through children, and carries its connection variables; see
+// InventoryVarHost and friends. In Terraform outputs every output is a group
+// whose value lists its hosts, or maps them to their variables or address.
+func LoadHostInventory(path string) (*Fleet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	var inv *hostInventory
+	trimmed := bytes.TrimSpace(data)
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".json" || ext == ".tfstate" || bytes.HasPrefix(trimmed, []byte("{")):
+		inv, err = parseTerraformInventory(data)
+	case ext == ".yml" || ext == ".yaml":
+		inv, err = parseYAMLInventory(data)
+	default:
+		inv, err = parseINIInventory(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+	fleet, err := inv.fleet()
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+	return fleet, nil
+}
+
+func parseINIInventory(data []byte) (*hostInventory, error) {
+	inv := newHostInventory()
+	section, kind := "ungrouped", "hosts"
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section %q", n, line)
+			}
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			if kind == "" {
+				kind = "hosts"
+			}
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("line %d: unknown section type %q", n, kind)
+			}
+			inv.group(section)
+			continue
+		}
+		fields, err := splitInventoryFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		switch kind {
+		case "children":
+			inv.group(section).children = append(inv.group(section).children, fields[0])
+			inv.group(fields[0])
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value", n)
+			}
+			inv.group(section).vars[strings.TrimSpace(key)] = unquoteInventoryValue(strings.TrimSpace(value))
+		default:
+			vars := map[string]string{}
+			for _, f := range fields[1:] {
+				key, value, ok := strings.Cut(f, "=")
+				if !ok {
+					return nil, fmt.Errorf("line %d: expected key=value, got %q", n, f)
+				}
+				vars[key] = unquoteInventoryValue(value)
+			}
+			if err := inv.addHost(section, fields[0], vars); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+	}
+	return inv, scanner.Err()
+}
+
+// splitInventoryFields splits an INI inventory line on whitespace, keeping
+// quoted values together.
+func splitInventoryFields(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		case r == '#' && cur.Len() == 0:
+			return fields, nil
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+func unquoteInventoryValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// yamlInventoryGroup is a group of a YAML inventory.
+type yamlInventoryGroup struct {
+	Hosts    map[string]map[string]any      `yaml:"hosts"`
+	Vars     map[string]any                 `yaml:"vars"`
+	Children map[string]*yamlInventoryGroup `yaml:"children"`
+}
+
+func parseYAMLInventory(data []byte) (*hostInventory, error) {
+	var top map[string]*yamlInventoryGroup
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	inv := newHostInventory()
+	var add func(name string, g *yamlInventoryGroup) error
+	add = func(name string, g *yamlInventoryGroup) error {
+		group := inv.group(name)
+		if g == nil {
+			return nil
+		}
+		for k, v := range g.Vars {
+			group.vars[k] = fmt.Sprint(v)
+		}
+		for pattern, vars := range g.Hosts {
+			if err := inv.addHost(name, pattern, stringVars(vars)); err != nil {
+				return err
+			}
+		}
+		for child, cg := range g.Children {
+			group.children = append(group.children, child)
+			if err := add(child, cg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for name, g := range top {
+		if err := add(name, g); err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+func stringVars(vars map[string]any) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if v != nil {
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+func parseTerraformInventory(data []byte) (*hostInventory, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// A state file keeps the outputs under "outputs"; 'terraform output
+	// -json' prints them at the top level.
+	if raw, ok := doc["outputs"]; ok {
+		if _, isState := doc["terraform_version"]; isState {
+			doc = nil
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				return nil, fmt.Errorf("outputs: %w", err)
+			}
+		}
+	}
+	inv := newHostInventory()
+	for name, raw := range doc {
+		var out struct {
+			Value any `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil, fmt.Errorf("output %s: %w", name, err)
+		}
+		inv.group(name)
+		switch v := out.Value.(type) {
+		case []any:
+			for _, h := range v {
+				host, ok := h.(string)
+				if !ok {
+					return nil, fmt.Errorf("output %s: host %v is not a string", name, h)
+				}
+				if err := inv.addHost(name, host, nil); err != nil {
+					return nil, fmt.Errorf("output %s: %w", name, err)
+				}
+			}
+		case map[string]any:
+			for host, hv := range v {
+				var vars map[string]string
+				switch hv := hv.(type) {
+				case string:
+					vars = map[string]string{InventoryVarHost: hv}
+				case map[string]any:
+					vars = stringVars(hv)
+				default:
+					return nil, fmt.Errorf("output %s: host %s must map to an address or variables", name, host)
+				}
+				if err := inv.addHost(name, host, vars); err != nil {
+					return nil, fmt.Errorf("output %s: %w", name, err)
+				}
+			}
+		default:
+			// Outputs that are not host lists (IDs, URLs) are no groups.
+			delete(inv.groups, name)
+		}
+	}
+	return inv, nil
+}
+
+// expandHostPattern expands Ansible host ranges such as wp[01:20].example.com
+// or db-[a:c]; a pattern without one is the host itself.
+func expandHostPattern(pattern string) ([]string, error) {
+	open := strings.IndexByte(pattern, '[')
+	if open < 0 {
+		return []string{pattern}, nil
+	}
+	end := strings.IndexByte(pattern[open:], ']')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated range in host %q", pattern)
+	}
+	end += open
+	from, to, ok := strings.Cut(pattern[open+1:end], ":")
+	if !ok || from == "" || to == "" {
+		return nil, fmt.Errorf("invalid range in host %q (use [start:end])", pattern)
+	}
+	var items []string
+	if lo, err := strconv.Atoi(from); err == nil {
+		hi, err := strconv.Atoi(to)
+		if err != nil || hi < lo {
+			return nil, fmt.Errorf("invalid range in host %q", pattern)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from)
+		}
+		for i := lo; i <= hi; i++ {
+			items = append(items, fmt.Sprintf("%0*d", width, i))
+		}
+	} else if len(from) == 1 && len(to) == 1 && from[0] <= to[0] {
+		for c := from[0]; c <= to[0]; c++ {
+			items = append(items, string(c))
+		}
+	} else {
+		return nil, fmt.Errorf("invalid range in host %q", pattern)
+	}
+	var hosts []string
+	for _, item := range items {
+		rest, err := expandHostPattern(pattern[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			hosts = append(hosts, pattern[:open]+item+r)
+		}
+	}
+	return hosts, nil
+}
+
+// fleet resolves the groups and variables of every host. Variables of a
+// group override those of its parents, and the host's own override all of
+// them; "all" is the root of every group.
+func (inv *hostInventory) fleet() (*Fleet, error) {
+	parents := map[string][]string{}
+	for name, g := range inv.groups {
+		for _, child := range g.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	// depth orders a host's groups from the most general to the most
+	// specific.
+	depth := map[string]int{}
+	var depthOf func(name string, seen map[string]bool) (int, error)
+	depthOf = func(name string, seen map[string]bool) (int, error) {
+		if d, ok := depth[name]; ok {
+			return d, nil
+		}
+		if seen[name] {
+			return 0, fmt.Errorf("group %s is its own ancestor", name)
+		}
+		seen[name] = true
+		d := 0
+		if name != "all" {
+			d = 1
+		}
+		for _, p := range parents[name] {
+			pd, err := depthOf(p, seen)
+			if err != nil {
+				return 0, err
+			}
+			if pd+1 > d {
+				d = pd + 1
+			}
+		}
+		depth[name] = d
+		return d, nil
+	}
+
+	fleet := &Fleet{Hosts: map[string]FleetHost{}}
+	for host := range inv.hostVars {
+		groups := map[string]bool{}
+		var visit func(name string)
+		visit = func(name string) {
+			if groups[name] {
+				return
+			}
+			groups[name] = true
+			for _, p := range parents[name] {
+				visit(p)
+			}
+		}
+		for name, g := range inv.groups {
+			for _, h := range g.hosts {
+				if h == host {
+					visit(name)
+				}
+			}
+		}
+		ordered := make([]string, 0, len(groups)+1)
+		for name := range groups {
+			ordered = append(ordered, name)
+		}
+		if !groups["all"] && inv.groups["all"] != nil {
+			ordered = append(ordered, "all")
+		}
+		for _, name := range ordered {
+			if _, err := depthOf(name, map[string]bool{}); err != nil {
+				return nil, err
+			}
+		}
+		sort.Slice(ordered, func(i, j int) bool {
+			if depth[ordered[i]] != depth[ordered[j]] {
+				return depth[ordered[i]] < depth[ordered[j]]
+			}
+			return ordered[i] < ordered[j]
+		})
+
+		vars := map[string]string{}
+		labels := map[string]string{}
+		for _, name := range ordered {
+			for k, v := range canonicalInventoryVars(inv.groups[name].vars) {
+				vars[k] = v
+			}
+			if name != "all" && name != "ungrouped" {
+				labels[name] = InventoryGroupLabel
+			}
+		}
+		for k, v := range canonicalInventoryVars(inv.hostVars[host]) {
+			vars[k] = v
+		}
+		h := FleetHost{
+			Labels:    labels,
+			Address:   vars[InventoryVarHost],
+			SSHUser:   vars[InventoryVarUser],
+			SSHPort:   vars[InventoryVarPort],
+			ParentDir: vars[InventoryVarParentDir],
+		}
+		if h.SSHPort != "" {
+			if _, err := strconv.Atoi(h.SSHPort); err != nil {
+				return nil, fmt.Errorf("host %s: invalid %s %q", host, InventoryVarPort, h.SSHPort)
+			}
+		}
+		fleet.Hosts[host] = h
+	}
+	return fleet, nil
+}
+
+// MergeInventory adds the hosts of inv, as read by LoadHostInventory, to f.
+// Labels and connection settings of the fleet file win over those of the
+// inventory.
+func (f *Fleet) MergeInventory(inv *Fleet) {
+	if f.Hosts == nil {
+		f.Hosts = map[string]FleetHost{}
+	}
+	for name, ih := range inv.Hosts {
+		h, ok := f.Hosts[name]
+		if !ok {
+			f.Hosts[name] = ih
+			continue
+		}
+		labels := map[string]string{}
+		for k, v := range ih.Labels {
+			labels[k] = v
+		}
+		for k, v := range h.Labels {
+			labels[k] = v
+		}
+		h.Labels = labels
+		if h.Address == "" {
+			h.Address = ih.Address
+		}
+		if h.SSHUser == "" {
+			h.SSHUser = ih.SSHUser
+		}
+		if h.SSHPort == "" {
+			h.SSHPort = ih.SSHPort
+		}
+		if h.ParentDir == "" {
+			h.ParentDir = ih.ParentDir
+		}
+		f.Hosts[name] = h
+	}
+}
+
+// HostNames returns the names of the fleet's hosts, sorted.
+func (f *Fleet) HostNames() []string {
+	names := make([]string, 0, len(f.Hosts))
+	for name := range f.Hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SSHTarget returns cfg, the SSH settings for the host as given on the
+// command line, with the host's address, user and port applied. A user given
+// as user@host wins over the host's.
+func (h FleetHost) SSHTarget(cfg auth.SSHConfig, target string) auth.SSHConfig {
+	if h.Address != "" {
+		cfg.Hostname = h.Address
+	}
+	if h.SSHUser != "" && !strings.Contains(target, "@") {
+		cfg.Username = h.SSHUser
+	}
+	if h.SSHPort != "" {
+		cfg.Port = h.SSHPort
+	}
+	return cfg
+}
@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"ciwg-cli/internal/auth"
+)
+
+func writeInventory(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHostInventoryINI(t *testing.T) {
+	path := writeInventory(t, "hosts", `# fleet
+bastion.example.com
+
+[wp_servers]
+wp[01:03].example.com ansible_user=deploy
+legacy ansible_host=10.0.0.7 ansible_ssh_port=2222 ciwg_parent_dir="/srv/old sites"
+
+[wp_servers:vars]
+ciwg_parent_dir=/srv/sites
+ansible_port=22
+
+[prod:children]
+wp_servers
+
+[all:vars]
+ansible_user=root
+`)
+	fleet, err := LoadHostInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fleet.HostNames(); !reflect.DeepEqual(got, []string{"bastion.example.com", "legacy", "wp01.example.com", "wp02.example.com", "wp03.example.com"}) {
+		t.Fatalf("hosts = %v", got)
+	}
+	wp := fleet.Hosts["wp02.example.com"]
+	if wp.SSHUser != "deploy" || wp.SSHPort != "22" || wp.ParentDir != "/srv/sites" ||
+		!reflect.DeepEqual(wp.Labels, map[string]string{"wp_servers": "true", "prod": "true"}) {
+		t.Errorf("wp02 = %+v", wp)
+	}
+	legacy := fleet.Hosts["legacy"]
+	if legacy.Address != "10.0.0.7" || legacy.SSHUser != "root" || legacy.ParentDir != "/srv/old sites" {
+		t.Errorf("legacy = %+v", legacy)
+	}
+	// The deprecated spelling on the host wins over ansible_port of its group.
+	if legacy.SSHPort != "2222" {
+		t.Errorf("legacy port = %q", legacy.SSHPort)
+	}
+	if b := fleet.Hosts["bastion.example.com"]; len(b.Labels) != 0 || b.SSHUser != "root" {
+		t.Errorf("bastion = %+v", b)
+	}
+
+	group, err := NewFleetGroup(fleet, []string{"wp_servers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := group.Hosts(); len(got) != 4 || !group.HasSite("acme.com", "deploy@wp01.example.com") {
+		t.Errorf("group hosts = %v", got)
+	}
+}
+
+func TestLoadHostInventoryYAML(t *testing.T) {
+	path := writeInventory(t, "inventory.yml", `all:
+  vars:
+    ansible_user: root
+  children:
+    wp_servers:
+      vars:
+        ciwg_parent_dir: /srv/sites
+      hosts:
+        wp1.example.com:
+        wp2.example.com:
+          ansible_port: 2222
+          ansible_user: deploy
+      children:
+        wp_gold:
+          hosts:
+            wp1.example.com:
+              ciwg_parent_dir: /srv/gold
+`)
+	fleet, err := LoadHostInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wp1, wp2 := fleet.Hosts["wp1.example.com"], fleet.Hosts["wp2.example.com"]
+	if wp1.ParentDir != "/srv/gold" || wp1.SSHUser != "root" || wp1.Labels["wp_gold"] != InventoryGroupLabel || wp1.Labels["wp_servers"] != InventoryGroupLabel {
+		t.Errorf("wp1 = %+v", wp1)
+	}
+	if wp2.ParentDir != "/srv/sites" || wp2.SSHUser != "deploy" || wp2.SSHPort != "2222" || len(wp2.Labels) != 1 {
+		t.Errorf("wp2 = %+v", wp2)
+	}
+}
+
+func TestLoadHostInventoryTerraform(t *testing.T) {
+	path := writeInventory(t, "outputs.json", `{
+  "wp_servers": {"sensitive": false, "type": ["list", "string"], "value": ["wp1.example.com", "wp2.example.com"]},
+  "db_servers": {"value": {"db1": {"ansible_host": "10.0.1.5", "ansible_user": "admin"}, "db2": "10.0.1.6"}},
+  "vpc_id": {"value": "vpc-123"}
+}`)
+	fleet, err := LoadHostInventory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fleet.HostNames(); !reflect.DeepEqual(got, []string{"db1", "db2", "wp1.example.com", "wp2.example.com"}) {
+		t.Fatalf("hosts = %v", got)
+	}
+	if db1 := fleet.Hosts["db1"]; db1.Address != "10.0.1.5" || db1.SSHUser != "admin" || db1.Labels["db_servers"] != InventoryGroupLabel {
+		t.Errorf("db1 = %+v", db1)
+	}
+	if fleet.Hosts["db2"].Address != "10.0.1.6" {
+		t.Errorf("db2 = %+v", fleet.Hosts["db2"])
+	}
+
+	state := writeInventory(t, "terraform.tfstate", `{"version": 4, "terraform_version": "1.7.0",
+  "outputs": {"wp_servers": {"value": ["wp9.example.com"], "type": ["list", "string"]}}, "resources": []}`)
+	if fleet, err = LoadHostInventory(state); err != nil || fleet.Hosts["wp9.example.com"].Labels["wp_servers"] != InventoryGroupLabel {
+		t.Errorf("state inventory = %+v, %v", fleet, err)
+	}
+}
+
+func TestLoadHostInventoryErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"hosts":        "[wp\nwp1\n",
+		"bad-range":    "[wp]\nwp[3:1].example.com\n",
+		"bad-vars":     "[wp:vars]\nno_equals\n",
+		"bad-port":     "[wp]\nwp1 ansible_port=ssh\n",
+		"cycle":        "[a:children]\nb\n[b:children]\na\n[a]\nwp1\n",
+		"bad-host.yml": "wp:\n  hosts:\n    wp[1:x]:\n",
+	} {
+		if _, err := LoadHostInventory(writeInventory(t, name, data)); err == nil {
+			t.Errorf("%s: inventory accepted", name)
+		}
+	}
+}
+
+func TestMergeInventory(t *testing.T) {
+	fleet := testFleet(t)
+	fleet.Hosts["wp1.example.com"] = FleetHost{Labels: map[string]string{"tier": "gold"}, SSHUser: "ops"}
+	fleet.MergeInventory(&Fleet{Hosts: map[string]FleetHost{
+		"wp1.example.com": {Labels: map[string]string{"tier": "bronze", "wp_servers": "true"}, SSHUser: "deploy", SSHPort: "2222"},
+		"wp9.example.com": {Labels: map[string]string{"wp_servers": "true"}},
+	}})
+	wp1 := fleet.Hosts["wp1.example.com"]
+	if wp1.Labels["tier"] != "gold" || wp1.Labels["wp_servers"] != "true" || wp1.SSHUser != "ops" || wp1.SSHPort != "2222" {
+		t.Errorf("wp1 = %+v", wp1)
+	}
+	if _, ok := fleet.Hosts["wp9.example.com"]; !ok {
+		t.Error("inventory host not added")
+	}
+}
+
+func TestFleetHostSSHTarget(t *testing.T) {
+	base := auth.SSHConfig{Hostname: "legacy", Username: "me", Port: "22"}
+	h := FleetHost{Address: "10.0.0.7", SSHUser: "deploy", SSHPort: "2222"}
+	if got := h.SSHTarget(base, "legacy"); got.Hostname != "10.0.0.7" || got.Username != "deploy" || got.Port != "2222" {
+		t.Errorf("SSHTarget() = %+v", got)
+	}
+	if got := h.SSHTarget(base, "me@legacy"); got.Username != "me" {
+		t.Errorf("SSHTarget(user@host) user = %q", got.Username)
+	}
+	if got := (FleetHost{}).SSHTarget(base, "legacy"); got != base {
+		t.Errorf("SSHTarget() without settings = %+v", got)
+	}
+}
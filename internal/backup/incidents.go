@@ -0,0 +1,398 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Incident providers.
+const (
+	IncidentPagerDuty = "pagerduty"
+	IncidentOpsgenie  = "opsgenie"
+)
+
+// DefaultIncidentThreshold is how many backups of a site in a row have to
+// fail before an incident is opened for it.
+const DefaultIncidentThreshold = 2
+
+// Default API endpoints of the incident providers.
+const (
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com"
+)
+
+// IncidentConfig configures the PagerDuty and Opsgenie integrations. A
+// provider is enabled by its key; both can be on at once.
+type IncidentConfig struct {
+	// PagerDutyRoutingKey is the integration key of an Events API v2
+	// integration.
+	PagerDutyRoutingKey string
+	PagerDutyURL        string
+	// OpsgenieAPIKey is the key of an Opsgenie API integration.
+	OpsgenieAPIKey string
+	// OpsgenieURL is the API base URL, e.g. https://api.eu.opsgenie.com for
+	// EU accounts.
+	OpsgenieURL string
+	// Threshold is how many failures of a site in a row open an incident.
+	Threshold int
+	// StateFile keeps the failure streaks and open incidents between runs.
+	StateFile string
+	// Source names the host the backups run on in incidents.
+	Source string
+}
+
+// Enabled reports whether any provider is configured.
+func (c *IncidentConfig) Enabled() bool {
+	return c != nil && (c.PagerDutyRoutingKey != "" || c.OpsgenieAPIKey != "")
+}
+
+// DefaultIncidentStatePath returns the default location of the incident
+// state (~/.ciwg/backup-incidents.json).
+func DefaultIncidentStatePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-backup-incidents.json")
+	}
+	return filepath.Join(home, ".ciwg", "backup-incidents.json")
+}
+
+// SiteIncident is the failure streak of a site and whether an incident is
+// open for it.
+type SiteIncident struct {
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Open                bool      `json:"open,omitempty"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	LastCode            string    `json:"last_code,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastRunID           string    `json:"last_run_id,omitempty"`
+	Host                string    `json:"host,omitempty"`
+}
+
+// IncidentState maps sites to their SiteIncident.
+type IncidentState struct {
+	Sites map[string]*SiteIncident `json:"sites"`
+}
+
+// LoadIncidentState reads the state at path. A missing file yields an empty
+// state.
+func LoadIncidentState(path string) (*IncidentState, error) {
+	state := &IncidentState{Sites: map[string]*SiteIncident{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incident state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid incident state %s: %w", path, err)
+	}
+	if state.Sites == nil {
+		state.Sites = map[string]*SiteIncident{}
+	}
+	return state, nil
+}
+
+// Save writes the state to path through a temp file.
+func (s *IncidentState) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create incident state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal incident state: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".backup-incidents-*")
+	if err != nil {
+		return fmt.Errorf("failed to write incident state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write incident state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write incident state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace incident state: %w", err)
+	}
+	return nil
+}
+
+// Incident actions.
+const (
+	IncidentTrigger = "trigger"
+	IncidentResolve = "resolve"
+)
+
+// IncidentEvent opens or resolves the incident of a site.
+type IncidentEvent struct {
+	Action string
+	Site   string
+	// Failures is the failure streak that opened the incident.
+	Failures int
+	Code     string
+	Error    string
+	RunID    string
+	Host     string
+}
+
+// incidentDedupKey identifies the incident of site with the providers, so
+// repeated triggers update one incident and a resolve closes it.
+func incidentDedupKey(site string) string {
+	return "ciwg-backup-" + site
+}
+
+func (e IncidentEvent) summary() string {
+	if e.Action == IncidentResolve {
+		return fmt.Sprintf("Backup of %s succeeded again", e.Site)
+	}
+	s := fmt.Sprintf("Backup of %s failed %d times in a row", e.Site, e.Failures)
+	if e.Code != "" {
+		s += " (" + e.Code + ")"
+	}
+	if e.Host != "" {
+		s += " on " + e.Host
+	}
+	return s
+}
+
+// observe updates state with the outcome of rec and returns the incidents
+// to open and resolve. A site that failed opens one once its streak reaches
+// threshold; a site that was backed up resolves its open incident. Sites
+// the run did not touch keep their streak. Open is only set by markSent, so
+// an event that could not be delivered is retried on the next run.
+func (s *IncidentState) observe(rec *RunRecord, host string, threshold int) []IncidentEvent {
+	if threshold < 1 {
+		threshold = 1
+	}
+	failed := map[string]ContainerFailure{}
+	for _, f := range rec.Failures {
+		site := f.Site
+		if site == "" {
+			site = f.Container
+		}
+		if _, ok := failed[site]; !ok {
+			failed[site] = f
+		}
+	}
+	succeeded := map[string]bool{}
+	for _, u := range rec.Uploads {
+		if u.Site != "" {
+			if _, ok := failed[u.Site]; !ok {
+				succeeded[u.Site] = true
+			}
+		}
+	}
+
+	var events []IncidentEvent
+	for site, f := range failed {
+		inc := s.Sites[site]
+		if inc == nil {
+			inc = &SiteIncident{}
+			s.Sites[site] = inc
+		}
+		inc.ConsecutiveFailures++
+		inc.LastCode, inc.LastError, inc.LastRunID, inc.Host = f.Code, f.Error, rec.ID, host
+		if !inc.Open && inc.ConsecutiveFailures >= threshold {
+			events = append(events, IncidentEvent{Action: IncidentTrigger, Site: site, Failures: inc.ConsecutiveFailures,
+				Code: f.Code, Error: f.Error, RunID: rec.ID, Host: host})
+		}
+	}
+	for site := range succeeded {
+		inc := s.Sites[site]
+		if inc == nil {
+			continue
+		}
+		if inc.Open {
+			events = append(events, IncidentEvent{Action: IncidentResolve, Site: site, RunID: rec.ID, Host: host})
+			inc.ConsecutiveFailures = 0
+			continue
+		}
+		delete(s.Sites, site)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Action != events[j].Action {
+			return events[i].Action == IncidentTrigger
+		}
+		return events[i].Site < events[j].Site
+	})
+	return events
+}
+
+// markSent records that e was delivered.
+func (s *IncidentState) markSent(e IncidentEvent, now time.Time) {
+	inc := s.Sites[e.Site]
+	if inc == nil {
+		return
+	}
+	if e.Action == IncidentTrigger {
+		inc.Open, inc.OpenedAt = true, now
+		return
+	}
+	delete(s.Sites, e.Site)
+}
+
+// pagerDutyEvent is the Events API v2 payload.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Group         string         `json:"group,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+func sendPagerDuty(cfg *IncidentConfig, e IncidentEvent) error {
+	ev := pagerDutyEvent{RoutingKey: cfg.PagerDutyRoutingKey, EventAction: e.Action, DedupKey: incidentDedupKey(e.Site)}
+	if e.Action == IncidentTrigger {
+		source := e.Host
+		if source == "" {
+			source = "ciwg-cli"
+		}
+		ev.Payload = &pagerDutyPayload{
+			Summary:   e.summary(),
+			Source:    source,
+			Severity:  "error",
+			Component: e.Site,
+			Group:     e.Host,
+			Class:     e.Code,
+			CustomDetails: map[string]any{
+				"consecutive_failures": e.Failures,
+				"error":                e.Error,
+				"run_id":               e.RunID,
+			},
+		}
+	}
+	endpoint := cfg.PagerDutyURL
+	if endpoint == "" {
+		endpoint = DefaultPagerDutyURL
+	}
+	return postJSON(endpoint, &ev)
+}
+
+// opsgenieMessageLimit is the longest alert message Opsgenie accepts.
+const opsgenieMessageLimit = 130
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func sendOpsgenie(cfg *IncidentConfig, e IncidentEvent) error {
+	base := strings.TrimRight(cfg.OpsgenieURL, "/")
+	if base == "" {
+		base = DefaultOpsgenieURL
+	}
+	headers := map[string]string{"Authorization": "GenieKey " + cfg.OpsgenieAPIKey}
+	alias := incidentDedupKey(e.Site)
+	if e.Action == IncidentResolve {
+		endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", base, url.PathEscape(alias))
+		return sendJSON(endpoint, headers, &opsgenieClose{Source: e.Host, Note: e.summary()})
+	}
+	message := e.summary()
+	if len(message) > opsgenieMessageLimit {
+		message = message[:opsgenieMessageLimit]
+	}
+	tags := []string{"backup"}
+	if e.Code != "" {
+		tags = append(tags, e.Code)
+	}
+	return sendJSON(base+"/v2/alerts", headers, &opsgenieAlert{
+		Message:     message,
+		Alias:       alias,
+		Description: e.Error,
+		Source:      e.Host,
+		Entity:      e.Site,
+		Tags:        tags,
+		Details: map[string]string{
+			"consecutive_failures": fmt.Sprint(e.Failures),
+			"code":                 e.Code,
+			"run_id":               e.RunID,
+		},
+		Priority: "P2",
+	})
+}
+
+// sendIncident delivers e to every configured provider.
+func sendIncident(cfg *IncidentConfig, e IncidentEvent) error {
+	var errs []error
+	if cfg.PagerDutyRoutingKey != "" {
+		if err := sendPagerDuty(cfg, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", IncidentPagerDuty, err))
+		}
+	}
+	if cfg.OpsgenieAPIKey != "" {
+		if err := sendOpsgenie(cfg, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", IncidentOpsgenie, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyIncidents opens incidents for the sites of rec that failed
+// cfg.Threshold times in a row and resolves those of sites that were backed
+// up again. Events that fail to send are printed as warnings and retried on
+// the next run; the returned error is only about the state file.
+func NotifyIncidents(cfg *IncidentConfig, rec *RunRecord) error {
+	if !cfg.Enabled() || rec == nil || rec.DryRun {
+		return nil
+	}
+	path := cfg.StateFile
+	if path == "" {
+		path = DefaultIncidentStatePath()
+	}
+	state, err := LoadIncidentState(path)
+	if err != nil {
+		return err
+	}
+	host := cfg.Source
+	if host == "" {
+		host = rec.Host
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = DefaultIncidentThreshold
+	}
+	for _, e := range state.observe(rec, host, threshold) {
+		if err := sendIncident(cfg, e); err != nil {
+			fmt.Printf("⚠️  Warning: failed to %s the incident of %s: %v\n", e.Action, e.Site, err)
+			continue
+		}
+		state.markSent(e, time.Now().UTC())
+		if e.Action == IncidentTrigger {
+			fmt.Printf("🚨 Opened an incident for %s after %d failed backups\n", e.Site, e.Failures)
+		} else {
+			fmt.Printf("📣 Resolved the incident of %s\n", e.Site)
+		}
+	}
+	return state.Save(path)
+}
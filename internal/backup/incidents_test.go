@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncidentStateObserve(t *testing.T) {
+	s := &IncidentState{Sites: map[string]*SiteIncident{}}
+	failed := &RunRecord{ID: "r1", Failures: []ContainerFailure{{Container: "wp_a", Site: "a.com", Code: FailureDiskFull, Error: "no space"}},
+		Uploads: []UploadStats{{Site: "b.com"}}}
+
+	if events := s.observe(failed, "host1", 2); len(events) != 0 {
+		t.Fatalf("first failure = %+v", events)
+	}
+	events := s.observe(failed, "host1", 2)
+	if len(events) != 1 || events[0].Action != IncidentTrigger || events[0].Site != "a.com" || events[0].Failures != 2 {
+		t.Fatalf("second failure = %+v", events)
+	}
+	// Until it is delivered, the trigger is retried.
+	if events := s.observe(failed, "host1", 2); len(events) != 1 {
+		t.Fatalf("undelivered trigger = %+v", events)
+	}
+	s.markSent(events[0], time.Now())
+	if events := s.observe(failed, "host1", 2); len(events) != 0 {
+		t.Fatalf("open incident triggered again: %+v", events)
+	}
+	if _, ok := s.Sites["b.com"]; ok {
+		t.Error("a site that never failed is tracked")
+	}
+
+	// A run without the site leaves it alone; a success resolves it.
+	if events := s.observe(&RunRecord{ID: "r2"}, "host1", 2); len(events) != 0 || !s.Sites["a.com"].Open {
+		t.Fatalf("unrelated run = %+v", events)
+	}
+	events = s.observe(&RunRecord{ID: "r3", Uploads: []UploadStats{{Site: "a.com"}}}, "host1", 2)
+	if len(events) != 1 || events[0].Action != IncidentResolve {
+		t.Fatalf("success = %+v", events)
+	}
+	s.markSent(events[0], time.Now())
+	if len(s.Sites) != 0 {
+		t.Errorf("state after resolve = %+v", s.Sites)
+	}
+}
+
+func TestIncidentStateSuccessResetsStreak(t *testing.T) {
+	s := &IncidentState{Sites: map[string]*SiteIncident{}}
+	fail := &RunRecord{Failures: []ContainerFailure{{Container: "wp_a", Site: "a.com"}}}
+	s.observe(fail, "", 2)
+	s.observe(&RunRecord{Uploads: []UploadStats{{Site: "a.com"}}}, "", 2)
+	if events := s.observe(fail, "", 2); len(events) != 0 {
+		t.Errorf("streak was not reset: %+v", events)
+	}
+}
+
+type incidentRequest struct {
+	Path  string
+	Query string
+	Auth  string
+	Body  map[string]any
+}
+
+func incidentServer(t *testing.T) (*httptest.Server, func() []incidentRequest) {
+	var mu sync.Mutex
+	var reqs []incidentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, incidentRequest{Path: r.URL.EscapedPath(), Query: r.URL.RawQuery, Auth: r.Header.Get("Authorization"), Body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []incidentRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]incidentRequest(nil), reqs...)
+	}
+}
+
+func TestNotifyIncidents(t *testing.T) {
+	pd, pdRequests := incidentServer(t)
+	og, ogRequests := incidentServer(t)
+	cfg := &IncidentConfig{
+		PagerDutyRoutingKey: "routing",
+		PagerDutyURL:        pd.URL + "/v2/enqueue",
+		OpsgenieAPIKey:      "genie",
+		OpsgenieURL:         og.URL + "/",
+		Threshold:           2,
+		StateFile:           filepath.Join(t.TempDir(), "incidents.json"),
+	}
+	failed := &RunRecord{ID: "r1", Host: "host1",
+		Failures: []ContainerFailure{{Container: "wp_a", Site: "a.com", Code: FailureDiskFull, Error: "no space left on device"}}}
+	for range 3 {
+		if err := NotifyIncidents(cfg, failed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := NotifyIncidents(cfg, &RunRecord{ID: "r2", Host: "host1", Uploads: []UploadStats{{Site: "a.com"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := pdRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("PagerDuty requests = %+v", reqs)
+	}
+	trigger, resolve := reqs[0].Body, reqs[1].Body
+	payload, _ := trigger["payload"].(map[string]any)
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "routing" || trigger["dedup_key"] != "ciwg-backup-a.com" ||
+		payload["component"] != "a.com" || payload["source"] != "host1" || payload["class"] != FailureDiskFull {
+		t.Errorf("PagerDuty trigger = %+v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "ciwg-backup-a.com" || resolve["payload"] != nil {
+		t.Errorf("PagerDuty resolve = %+v", resolve)
+	}
+
+	reqs = ogRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("Opsgenie requests = %+v", reqs)
+	}
+	if reqs[0].Path != "/v2/alerts" || reqs[0].Auth != "GenieKey genie" || reqs[0].Body["alias"] != "ciwg-backup-a.com" ||
+		!strings.Contains(reqs[0].Body["message"].(string), "failed 2 times in a row") {
+		t.Errorf("Opsgenie alert = %+v", reqs[0])
+	}
+	if reqs[1].Path != "/v2/alerts/ciwg-backup-a.com/close" || reqs[1].Query != "identifierType=alias" {
+		t.Errorf("Opsgenie close = %+v", reqs[1])
+	}
+
+	state, err := LoadIncidentState(cfg.StateFile)
+	if err != nil || len(state.Sites) != 0 {
+		t.Errorf("state = %+v, %v", state, err)
+	}
+}
+
+func TestNotifyIncidentsRetriesFailedDelivery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	cfg := &IncidentConfig{PagerDutyRoutingKey: "routing", PagerDutyURL: srv.URL, Threshold: 1,
+		StateFile: filepath.Join(t.TempDir(), "incidents.json")}
+	if err := NotifyIncidents(cfg, &RunRecord{Failures: []ContainerFailure{{Container: "wp_a", Site: "a.com"}}}); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := LoadIncidentState(cfg.StateFile)
+	if inc := state.Sites["a.com"]; inc == nil || inc.Open || inc.ConsecutiveFailures != 1 {
+		t.Errorf("state after a failed delivery = %+v", inc)
+	}
+}
@@ -0,0 +1,282 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage classes reported in the inventory.
+const (
+	StorageClassHot  = "hot"  // Minio (or filesystem) object
+	StorageClassCold = "cold" // AWS Glacier archive recorded in the run history
+)
+
+// Verification statuses reported in the inventory.
+const (
+	VerifyUnchecked = "unchecked"
+	VerifyOK        = "ok"
+	VerifyFailed    = "failed"
+)
+
+// InventoryRow is one object in the backup inventory export.
+type InventoryRow struct {
+	Key          string
+	Site         string
+	Class        string
+	Size         int64
+	LastModified time.Time
+	RecordedAt   time.Time // when a run history entry recorded the upload, if any
+	Checksum     string    // Minio ETag for hot objects, SHA-256 tree hash for cold archives
+	ArchiveID    string
+	Verification string
+	VerifyError  string
+}
+
+// InventoryOptions controls BuildInventory.
+type InventoryOptions struct {
+	Prefix string
+	// History is the run history used as the ledger of cold (Glacier) copies.
+	History []RunRecord
+	// Verify streams every hot object through gzip and tar to check integrity.
+	Verify bool
+	// Group limits the inventory to the sites of a --group selection; nil
+	// includes every site.
+	Group *FleetGroup
+}
+
+// BuildInventory lists every backup object under Prefix plus the Glacier
+// archives recorded in the run history, one row per copy.
+func (bm *BackupManager) BuildInventory(opts InventoryOptions) ([]InventoryRow, error) {
+	objs, err := bm.ListBackups(opts.Prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make(map[string]UploadStats)
+	recorded := make(map[string]time.Time)
+	for _, rec := range opts.History {
+		for _, u := range rec.Uploads {
+			if u.ObjectKey == "" || !strings.HasPrefix(u.ObjectKey, opts.Prefix) {
+				continue
+			}
+			uploads[u.ObjectKey] = u
+			recorded[u.ObjectKey] = rec.FinishedAt
+		}
+	}
+
+	var rows []InventoryRow
+	for i, obj := range objs {
+		if isInternalObject(obj.Key) || !opts.Group.HasSite(inventorySite(obj.Key, uploads[obj.Key].Site), "") {
+			continue
+		}
+		row := InventoryRow{
+			Key:          obj.Key,
+			Site:         inventorySite(obj.Key, uploads[obj.Key].Site),
+			Class:        StorageClassHot,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+			RecordedAt:   recorded[obj.Key],
+			Checksum:     obj.ETag,
+			Verification: VerifyUnchecked,
+		}
+		if opts.Verify {
+			// Progress goes to stderr so CSV on stdout stays clean.
+			fmt.Fprintf(os.Stderr, "Verifying [%d/%d] %s...\n", i+1, len(objs), obj.Key)
+			if err := bm.VerifyBackupObject(obj.Key); err != nil {
+				row.Verification = VerifyFailed
+				row.VerifyError = err.Error()
+			} else {
+				row.Verification = VerifyOK
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	for key, u := range uploads {
+		if u.Glacier == nil || !opts.Group.HasSite(inventorySite(key, u.Site), "") {
+			continue
+		}
+		rows = append(rows, InventoryRow{
+			Key:          key,
+			Site:         inventorySite(key, u.Site),
+			Class:        StorageClassCold,
+			Size:         u.Glacier.Bytes,
+			RecordedAt:   recorded[key],
+			Checksum:     u.Glacier.TreeHash,
+			ArchiveID:    u.Glacier.ArchiveID,
+			Verification: VerifyUnchecked,
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Key != rows[j].Key {
+			return rows[i].Key < rows[j].Key
+		}
+		return rows[i].Class > rows[j].Class // hot before cold
+	})
+	return rows, nil
+}
+
+// inventorySite returns the recorded site name, falling back to the directory
+// holding the object (backups/<site>/<file>).
+func inventorySite(key, recorded string) string {
+	if recorded != "" {
+		return recorded
+	}
+	dir := path.Dir(key)
+	if dir == "." {
+		return ""
+	}
+	return path.Base(dir)
+}
+
+// VerifyFailure is a backup that failed VerifyBackupObject.
+type VerifyFailure struct {
+	Key string
+	Err error
+}
+
+// VerifyBackupObjects runs VerifyBackupObject on every backup under prefix,
+// reporting progress to progress (when set), and returns how many it read
+// and those that failed.
+func (bm *BackupManager) VerifyBackupObjects(prefix string, progress func(i, n int, key string)) (int, []VerifyFailure, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	keys := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if !isInternalObject(obj.Key) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	var failed []VerifyFailure
+	for i, key := range keys {
+		if progress != nil {
+			progress(i+1, len(keys), key)
+		}
+		if err := bm.VerifyBackupObject(key); err != nil {
+			failed = append(failed, VerifyFailure{Key: key, Err: err})
+		}
+	}
+	return len(keys), failed, nil
+}
+
+// VerifyBackupObject reads objectName end to end, checking that it is a
+// complete gzip stream and, for tarballs, a readable tar archive.
+func (bm *BackupManager) VerifyBackupObject(objectName string) error {
+	obj, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	if isZipBackup(objectName) {
+		return verifyZip(obj)
+	}
+	if !strings.HasSuffix(objectName, ".gz") && !strings.HasSuffix(objectName, ".tgz") {
+		_, err := io.Copy(io.Discard, obj)
+		return err
+	}
+	gz, err := gzip.NewReader(obj)
+	if err != nil {
+		return fmt.Errorf("invalid gzip stream: %w", err)
+	}
+	if strings.HasSuffix(objectName, ".sql.gz") {
+		if _, err := io.Copy(io.Discard, gz); err != nil {
+			return fmt.Errorf("corrupt gzip stream: %w", err)
+		}
+		return nil
+	}
+	tr := tar.NewReader(gz)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt tar archive: %w", err)
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return fmt.Errorf("corrupt tar entry: %w", err)
+		}
+	}
+	// Drain trailing padding so gzip validates its checksum.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return fmt.Errorf("corrupt gzip stream: %w", err)
+	}
+	return nil
+}
+
+var inventoryHeader = []string{"key", "site", "class", "size_bytes", "last_modified", "recorded_at", "checksum", "archive_id", "verification", "verify_error"}
+
+func formatInventoryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// WriteInventoryCSV writes rows as CSV with a header line.
+func WriteInventoryCSV(w io.Writer, rows []InventoryRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(inventoryHeader); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write([]string{
+			r.Key, r.Site, r.Class, strconv.FormatInt(r.Size, 10),
+			formatInventoryTime(r.LastModified), formatInventoryTime(r.RecordedAt),
+			r.Checksum, r.ArchiveID, r.Verification, r.VerifyError,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteInventoryParquet writes rows as a Parquet file. Timestamps are stored
+// as TIMESTAMP_MILLIS (UTC); unknown timestamps are 0.
+func WriteInventoryParquet(w io.Writer, rows []InventoryRow) error {
+	str := func(name string) parquetColumn {
+		return parquetColumn{Name: name, Type: parquetByteArray, ConvertedType: parquetUTF8, Strings: make([]string, 0, len(rows))}
+	}
+	ts := func(name string) parquetColumn {
+		return parquetColumn{Name: name, Type: parquetInt64, ConvertedType: parquetTimestampMillis, Int64s: make([]int64, 0, len(rows))}
+	}
+	millis := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.UnixMilli()
+	}
+	cols := []parquetColumn{
+		str("key"), str("site"), str("class"),
+		{Name: "size_bytes", Type: parquetInt64, ConvertedType: parquetNoConvertedType},
+		ts("last_modified"), ts("recorded_at"),
+		str("checksum"), str("archive_id"), str("verification"), str("verify_error"),
+	}
+	for _, r := range rows {
+		cols[0].Strings = append(cols[0].Strings, r.Key)
+		cols[1].Strings = append(cols[1].Strings, r.Site)
+		cols[2].Strings = append(cols[2].Strings, r.Class)
+		cols[3].Int64s = append(cols[3].Int64s, r.Size)
+		cols[4].Int64s = append(cols[4].Int64s, millis(r.LastModified))
+		cols[5].Int64s = append(cols[5].Int64s, millis(r.RecordedAt))
+		cols[6].Strings = append(cols[6].Strings, r.Checksum)
+		cols[7].Strings = append(cols[7].Strings, r.ArchiveID)
+		cols[8].Strings = append(cols[8].Strings, r.Verification)
+		cols[9].Strings = append(cols[9].Strings, r.VerifyError)
+	}
+	return writeParquet(w, cols)
+}
@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeTarball(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	body := []byte("<?php echo 'hi';")
+	if err := tw.WriteHeader(&tar.Header{Name: "index.php", Mode: 0o644, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestBuildInventory(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+	good := makeTarball(t)
+	objects := map[string][]byte{
+		"backups/a.com/a-1.tgz": good,
+		"backups/a.com/a-2.tgz": good[:len(good)/2],
+		"backups/b.com/b-1.tgz": good,
+	}
+	for key, data := range objects {
+		if _, err := bm.putObject(context.Background(), key, bytes.NewReader(data), int64(len(data)), "application/gzip", nil); err != nil {
+			t.Fatalf("putObject(%s) error = %v", key, err)
+		}
+	}
+
+	finished := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	history := []RunRecord{{
+		FinishedAt: finished,
+		Uploads: []UploadStats{
+			{Site: "alpha.com", ObjectKey: "backups/a.com/a-1.tgz", Glacier: &GlacierUploadStats{ArchiveID: "arch-1", TreeHash: "abc", Bytes: 10}},
+			{Site: "old.com", ObjectKey: "backups/old.com/old-1.tgz", Glacier: &GlacierUploadStats{ArchiveID: "arch-2", TreeHash: "def", Bytes: 20}},
+			{Site: "other.com", ObjectKey: "elsewhere/x.tgz", Glacier: &GlacierUploadStats{ArchiveID: "arch-3"}},
+		},
+	}}
+
+	rows, err := bm.BuildInventory(InventoryOptions{Prefix: "backups/", History: history, Verify: true})
+	if err != nil {
+		t.Fatalf("BuildInventory() error = %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("BuildInventory() returned %d rows, want 5: %+v", len(rows), rows)
+	}
+
+	want := []struct{ key, site, class, verification string }{
+		{"backups/a.com/a-1.tgz", "alpha.com", StorageClassHot, VerifyOK},
+		{"backups/a.com/a-1.tgz", "alpha.com", StorageClassCold, VerifyUnchecked},
+		{"backups/a.com/a-2.tgz", "a.com", StorageClassHot, VerifyFailed},
+		{"backups/b.com/b-1.tgz", "b.com", StorageClassHot, VerifyOK},
+		{"backups/old.com/old-1.tgz", "old.com", StorageClassCold, VerifyUnchecked},
+	}
+	for i, w := range want {
+		r := rows[i]
+		if r.Key != w.key || r.Site != w.site || r.Class != w.class || r.Verification != w.verification {
+			t.Errorf("row %d = {%s %s %s %s}, want %+v", i, r.Key, r.Site, r.Class, r.Verification, w)
+		}
+	}
+	if rows[1].ArchiveID != "arch-1" || rows[1].Checksum != "abc" || !rows[1].RecordedAt.Equal(finished) {
+		t.Errorf("cold row = %+v", rows[1])
+	}
+	if !rows[0].RecordedAt.Equal(finished) || !rows[3].RecordedAt.IsZero() {
+		t.Errorf("RecordedAt not taken from history: %v / %v", rows[0].RecordedAt, rows[3].RecordedAt)
+	}
+}
+
+func TestInventorySite(t *testing.T) {
+	tests := []struct{ key, recorded, want string }{
+		{"backups/a.com/a-1.tgz", "", "a.com"},
+		{"backups/a.com/a-1.tgz", "alpha.com", "alpha.com"},
+		{"top.tgz", "", ""},
+	}
+	for _, tt := range tests {
+		if got := inventorySite(tt.key, tt.recorded); got != tt.want {
+			t.Errorf("inventorySite(%q, %q) = %q, want %q", tt.key, tt.recorded, got, tt.want)
+		}
+	}
+}
+
+func TestWriteInventoryCSV(t *testing.T) {
+	rows := []InventoryRow{{
+		Key:          "backups/a.com/a-1.tgz",
+		Site:         "a.com",
+		Class:        StorageClassHot,
+		Size:         42,
+		LastModified: time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+		Checksum:     "etag",
+		Verification: VerifyUnchecked,
+	}}
+	var buf bytes.Buffer
+	if err := WriteInventoryCSV(&buf, rows); err != nil {
+		t.Fatalf("WriteInventoryCSV() error = %v", err)
+	}
+	want := "key,site,class,size_bytes,last_modified,recorded_at,checksum,archive_id,verification,verify_error\n" +
+		"backups/a.com/a-1.tgz,a.com,hot,42,2024-05-01T03:00:00Z,,etag,,unchecked,\n"
+	if buf.String() != want {
+		t.Errorf("WriteInventoryCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteInventoryParquet(t *testing.T) {
+	rows := []InventoryRow{
+		{Key: "backups/a.com/a-1.tgz", Site: "a.com", Class: StorageClassHot, Size: 42, Verification: VerifyOK},
+		{Key: "backups/a.com/a-1.tgz", Site: "a.com", Class: StorageClassCold, Size: 40, ArchiveID: "arch-1"},
+	}
+	var buf bytes.Buffer
+	if err := WriteInventoryParquet(&buf, rows); err != nil {
+		t.Fatalf("WriteInventoryParquet() error = %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen <= 0 || footerLen > len(data)-12 {
+		t.Fatalf("footer length %d out of range (file %d bytes)", footerLen, len(data))
+	}
+	footer := string(data[len(data)-8-footerLen : len(data)-8])
+	for _, name := range []string{"key", "site", "class", "size_bytes", "last_modified", "checksum", "archive_id", "verification"} {
+		if !strings.Contains(footer, name) {
+			t.Errorf("footer does not mention column %q", name)
+		}
+	}
+	// Values are PLAIN encoded and uncompressed, so they appear verbatim.
+	if !bytes.Contains(data, []byte("arch-1")) {
+		t.Errorf("data pages do not contain archive id")
+	}
+}
+
+func TestWriteParquetRejectsRaggedColumns(t *testing.T) {
+	cols := []parquetColumn{
+		{Name: "a", Type: parquetInt64, ConvertedType: parquetNoConvertedType, Int64s: []int64{1, 2}},
+		{Name: "b", Type: parquetInt64, ConvertedType: parquetNoConvertedType, Int64s: []int64{1}},
+	}
+	if err := writeParquet(&bytes.Buffer{}, cols); err == nil {
+		t.Error("writeParquet() with ragged columns succeeded, want error")
+	}
+}
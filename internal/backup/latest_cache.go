@@ -0,0 +1,204 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// LatestCacheResult summarizes a CacheLatest run.
+type LatestCacheResult struct {
+	Sites int
+	// Cached counts sites whose latest backup was copied into the cache and
+	// Fresh those whose cached copy was already current.
+	Cached  int
+	Fresh   int
+	Evicted int
+	Failed  int
+	Bytes   int64
+}
+
+// CacheLatest keeps the newest complete backup of every site under prefix
+// mirrored to cache, a fast local directory (file:// endpoint) or a nearby
+// bucket. A site's older backups are evicted from the cache once its newest
+// one is copied, and sites that no longer have backups are evicted entirely,
+// so the cache only ever holds one backup per site.
+func (bm *BackupManager) CacheLatest(cache *BackupManager, prefix string, dryRun bool) (*LatestCacheResult, error) {
+	src, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	cached, err := cache.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache: %w", err)
+	}
+
+	bySite := func(objs []ObjectInfo) map[string][]ObjectInfo {
+		m := make(map[string][]ObjectInfo)
+		for _, o := range objs {
+			if isInternalObject(o.Key) || bm.IsStagedKey(o.Key) {
+				continue
+			}
+			site := inventorySite(o.Key, "")
+			m[site] = append(m[site], o)
+		}
+		return m
+	}
+	srcSites, cacheSites := bySite(src), bySite(cached)
+
+	sites := make([]string, 0, len(srcSites))
+	for site := range srcSites {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	res := &LatestCacheResult{}
+	ctx := context.Background()
+	for _, site := range sites {
+		latest, ok := latestCompleteSet(srcSites[site])
+		if !ok {
+			continue
+		}
+		res.Sites++
+
+		have := make(map[string]int64, len(cacheSites[site]))
+		for _, o := range cacheSites[site] {
+			have[o.Key] = o.Size
+		}
+		keep := make(map[string]bool, len(latest.Objects))
+		copied, failed := false, false
+		for _, o := range latest.Objects {
+			keep[o.Key] = true
+			if size, ok := have[o.Key]; ok && size == o.Size {
+				continue
+			}
+			copied = true
+			if dryRun {
+				fmt.Printf("[DRY RUN] Would cache %s (%.2f MB)\n", o.Key, float64(o.Size)/(1024*1024))
+				res.Bytes += o.Size
+				continue
+			}
+			n, err := copyObject(ctx, bm, cache, o)
+			if err != nil {
+				fmt.Printf("⚠ Failed to cache %s: %v\n", o.Key, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("✓ Cached %s (%.2f MB)\n", o.Key, float64(n)/(1024*1024))
+			res.Bytes += n
+		}
+
+		switch {
+		case failed:
+			// Keep the previous backup around until the new one is complete.
+			res.Failed++
+			continue
+		case copied:
+			res.Cached++
+		default:
+			res.Fresh++
+		}
+		for _, o := range cacheSites[site] {
+			if !keep[o.Key] {
+				res.Evicted += cache.evictCached(ctx, o.Key, dryRun)
+			}
+		}
+	}
+
+	for site, objs := range cacheSites {
+		if _, ok := srcSites[site]; ok {
+			continue
+		}
+		for _, o := range objs {
+			res.Evicted += cache.evictCached(ctx, o.Key, dryRun)
+		}
+	}
+	return res, nil
+}
+
+// latestCompleteSet returns the newest backup set in objs that has all of
+// its parts.
+func latestCompleteSet(objs []ObjectInfo) (BackupSet, bool) {
+	var latest BackupSet
+	found := false
+	for _, set := range GroupBackupSets(objs) {
+		if !set.Complete() {
+			continue
+		}
+		if !found || set.Time().After(latest.Time()) {
+			latest, found = set, true
+		}
+	}
+	return latest, found
+}
+
+func copyObject(ctx context.Context, src, dst *BackupManager, o ObjectInfo) (int64, error) {
+	r, err := src.getObject(ctx, o.Key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return dst.putObject(ctx, o.Key, r, o.Size, "application/gzip", nil)
+}
+
+// evictCached removes key from the cache bm and returns 1 when it was (or
+// would be) removed.
+func (bm *BackupManager) evictCached(ctx context.Context, key string, dryRun bool) int {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would evict %s\n", key)
+		return 1
+	}
+	if err := bm.removeObject(ctx, key); err != nil {
+		fmt.Printf("⚠ Failed to evict %s: %v\n", key, err)
+		return 0
+	}
+	fmt.Printf("🗑️  Evicted %s\n", key)
+	return 1
+}
+
+// SetReadCache makes DownloadBackup and ReadBackup, and so read, restore and
+// inventory verification, serve objects from cache when it holds a copy of
+// the same size as the bucket's. Anything else is read from the bucket.
+func (bm *BackupManager) SetReadCache(cache *BackupManager) {
+	bm.readCache = cache
+}
+
+// openBackupObject opens objectName from the read cache when it holds a
+// current copy, and from the bucket otherwise.
+func (bm *BackupManager) openBackupObject(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	if bm.readCache != nil {
+		if r, ok := bm.openCached(ctx, objectName); ok {
+			return r, nil
+		}
+	}
+	return bm.getObject(ctx, objectName)
+}
+
+func (bm *BackupManager) openCached(ctx context.Context, objectName string) (io.ReadCloser, bool) {
+	cache := bm.readCache
+	if err := cache.initMinioClient(); err != nil {
+		bm.logVerbose("Read cache unavailable: %v", err)
+		return nil, false
+	}
+	cached, err := cache.statObject(ctx, objectName)
+	if err != nil {
+		return nil, false
+	}
+	// A same-named object of another size means the bucket copy was
+	// replaced after caching; never serve that.
+	current, err := bm.statObject(ctx, objectName)
+	if err != nil || current.Size != cached.Size {
+		bm.logVerbose("Cached copy of %s is stale, reading from the bucket", objectName)
+		return nil, false
+	}
+	r, err := cache.getObject(ctx, objectName)
+	if err != nil {
+		return nil, false
+	}
+	// Downloads may stream to stdout, so report the hit on stderr.
+	fmt.Fprintf(os.Stderr, "⚡ Serving %s from the latest-backup cache (cached %s)\n", objectName, FormatTime(cached.LastModified, time.RFC3339))
+	return r, true
+}
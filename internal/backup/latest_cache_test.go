@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheLatest(t *testing.T) {
+	src, dir := newFileBackedManager(t)
+	cache, _ := newFileBackedManager(t)
+	for _, bm := range []*BackupManager{src, cache} {
+		if err := bm.initMinioClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age := func(key string, d time.Duration) {
+		ts := time.Now().Add(-d)
+		if err := os.Chtimes(filepath.Join(dir, key), ts, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putTestObject(t, src, "backups/a.com/a.com-20240101-000000.tgz", "old")
+	age("backups/a.com/a.com-20240101-000000.tgz", 48*time.Hour)
+	putTestObject(t, src, "backups/a.com/a.com-20240102-000000.tgz", "new")
+	// An incomplete split backup is newer but must not be cached.
+	putTestObject(t, src, "backups/a.com/a.com-20240103-000000.part-1-of-2.tgz", "p1")
+	putTestObject(t, src, "backups/b.com/b.com-20240102-000000.tgz", "b")
+	putTestObject(t, cache, "backups/a.com/a.com-20240101-000000.tgz", "old")
+	putTestObject(t, cache, "backups/gone.com/gone.com-20230101-000000.tgz", "x")
+
+	res, err := src.CacheLatest(cache, "backups/", false)
+	if err != nil {
+		t.Fatalf("CacheLatest() error = %v", err)
+	}
+	want := []string{"backups/a.com/a.com-20240102-000000.tgz", "backups/b.com/b.com-20240102-000000.tgz"}
+	if got := listKeys(t, cache); !reflect.DeepEqual(got, want) {
+		t.Errorf("cache keys = %v, want %v", got, want)
+	}
+	if res.Sites != 2 || res.Cached != 2 || res.Evicted != 2 || res.Failed != 0 {
+		t.Errorf("result = %+v", res)
+	}
+
+	res, err = src.CacheLatest(cache, "backups/", false)
+	if err != nil || res.Fresh != 2 || res.Cached != 0 || res.Evicted != 0 {
+		t.Errorf("second run = %+v, %v; want everything fresh", res, err)
+	}
+}
+
+func TestReadCacheServesCurrentCopies(t *testing.T) {
+	src, cache := newReplicaPair(t)
+	putTestObject(t, src, "backups/a.com/a.tgz", "bucket")
+	putTestObject(t, cache, "backups/a.com/a.tgz", "cached")
+	putTestObject(t, src, "backups/a.com/b.tgz", "bucket")
+	putTestObject(t, cache, "backups/a.com/b.tgz", "stale copy")
+	src.SetReadCache(cache)
+
+	read := func(key string) string {
+		r, err := src.openBackupObject(context.Background(), key)
+		if err != nil {
+			t.Fatalf("openBackupObject(%s) error = %v", key, err)
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		return string(data)
+	}
+	if got := read("backups/a.com/a.tgz"); got != "cached" {
+		t.Errorf("same-size object read %q, want the cached copy", got)
+	}
+	if got := read("backups/a.com/b.tgz"); got != "bucket" {
+		t.Errorf("stale object read %q, want the bucket copy", got)
+	}
+}
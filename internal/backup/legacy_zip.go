@@ -0,0 +1,469 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// isZipBackup reports whether name is a legacy .zip backup of the tooling
+// before this CLI.
+func isZipBackup(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+// openZip returns a zip reader over r. Objects and files are read in place,
+// entry by entry, through their ReadAt; other streams are first spooled to
+// a temp file that is unlinked right away, so nothing is left behind when
+// the reader is dropped.
+func openZip(r io.Reader) (*zip.Reader, error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := ra.Seek(0, io.SeekEnd)
+		if err == nil {
+			if _, err = ra.Seek(0, io.SeekStart); err == nil {
+				return zip.NewReader(ra, size)
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "ciwg-zip-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to spool zip archive: %w", err)
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return zr, nil
+}
+
+// zipRoot returns the single top-level directory every entry of files is
+// under, or "" when the archive has several top-level entries.
+func zipRoot(files []*zip.File) string {
+	root := ""
+	for _, f := range files {
+		top, _, nested := strings.Cut(strings.TrimPrefix(f.Name, "./"), "/")
+		if !nested && !f.FileInfo().IsDir() {
+			return ""
+		}
+		if root == "" {
+			root = top
+		} else if top != root {
+			return ""
+		}
+	}
+	return root
+}
+
+// zipEntryName returns the name of a zip entry in the standard layout: under
+// a directory named site, replacing the archive's own top-level directory
+// root if it has one. Entries that would escape it are skipped.
+func zipEntryName(name, root, site string) (string, bool) {
+	name = strings.TrimPrefix(name, "./")
+	if root != "" {
+		name = strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+	}
+	if name == "" {
+		return site + "/", true
+	}
+	clean := path.Clean("/" + name)[1:]
+	if clean == "" || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	if strings.HasSuffix(name, "/") {
+		clean += "/"
+	}
+	return site + "/" + clean, true
+}
+
+// writeZipAsTar copies the zip entries files to tw in the standard layout
+// under site (see zipEntryName) and returns how many files it wrote.
+func writeZipAsTar(files []*zip.File, tw *tar.Writer, site string) (int, error) {
+	root := zipRoot(files)
+	written := 0
+	for _, f := range files {
+		name, ok := zipEntryName(f.Name, root, site)
+		if !ok {
+			continue
+		}
+		info := f.FileInfo()
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: f.Modified,
+		}
+		switch {
+		case info.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			if !strings.HasSuffix(hdr.Name, "/") {
+				hdr.Name += "/"
+			}
+		case info.Mode().IsRegular():
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(f.UncompressedSize64)
+		default:
+			// Symlinks and devices don't survive the zip tooling anyway.
+			continue
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+			if info.IsDir() {
+				hdr.Mode = 0o755
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return written, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return written, fmt.Errorf("failed to open %s in zip: %w", f.Name, err)
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return written, fmt.Errorf("failed to read %s in zip: %w", f.Name, err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+// zipTarReader streams the entries of zr as a tar archive in the standard
+// layout under site, so the tar-based restore paths read zips unchanged.
+func zipTarReader(zr *zip.Reader, site string) *tar.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		_, err := writeZipAsTar(zr.File, tw, site)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return tar.NewReader(pr)
+}
+
+// openBackupTar returns a tar reader over the backup archive r: a .tgz, a
+// .tar.zst, or a legacy .zip laid out under site like a .tgz.
+func openBackupTar(objectName string, r io.Reader, site string) (*tar.Reader, error) {
+	if isZipBackup(objectName) {
+		zr, err := openZip(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		return zipTarReader(zr, site), nil
+	}
+	if isZstdBackup(objectName) {
+		// A single-threaded decoder runs in the caller and needs no Close.
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return tar.NewReader(zr), nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	return tar.NewReader(gz), nil
+}
+
+// isZstdBackup reports whether name is a zstd tarball, the form Glacier
+// copies are re-compressed to (see CompressionSpec.archiveName).
+func isZstdBackup(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".tar.zst")
+}
+
+// findSQLInZip returns the first .sql entry of zr whose name contains match
+// (any .sql entry when match is empty).
+func findSQLInZip(zr *zip.Reader, match string) (io.Reader, string, error) {
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(f.Name, ".sql") {
+			continue
+		}
+		if match != "" && !strings.Contains(f.Name, match) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open %s in zip: %w", f.Name, err)
+		}
+		return rc, f.Name, nil
+	}
+	if match != "" {
+		return nil, "", fmt.Errorf("%w matching %q", ErrNoSQLDump, match)
+	}
+	return nil, "", ErrNoSQLDump
+}
+
+// verifyZip reads every entry of the zip archive r, which checks their
+// CRC-32.
+func verifyZip(r io.Reader) error {
+	zr, err := openZip(r)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("corrupt zip entry %s: %w", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("corrupt zip entry %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// legacyNameTime matches the dates legacy tooling put in backup file names:
+// 20240131, 2024-01-31, optionally followed by a time.
+var legacyNameTime = regexp.MustCompile(`[-_.]?(\d{4})-?(\d{2})-?(\d{2})(?:[-_T]?(\d{2})-?(\d{2})-?(\d{2}))?`)
+
+// LegacyBackupName derives the site label and backup time of a legacy
+// backup file name, e.g. example.com_2024-01-31.zip. Names without a date
+// use modTime. site overrides the label.
+func LegacyBackupName(filename string, modTime time.Time, site string) (string, time.Time) {
+	base := path.Base(filepath.ToSlash(filename))
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(base), ext) {
+			base = base[:len(base)-len(ext)]
+			break
+		}
+	}
+	if label, t, ok := parseBackupName(base + ".tgz"); ok {
+		if site == "" {
+			site = label
+		}
+		return site, t
+	}
+
+	t := modTime
+	label := base
+	if m := legacyNameTime.FindStringSubmatchIndex(base); m != nil {
+		s := func(i int) string {
+			if m[2*i] < 0 {
+				return "00"
+			}
+			return base[m[2*i]:m[2*i+1]]
+		}
+		stamp := s(1) + s(2) + s(3) + "-" + s(4) + s(5) + s(6)
+		if parsed, err := time.ParseInLocation("20060102-150405", stamp, Timezone()); err == nil {
+			t = parsed
+			label = strings.Trim(base[:m[0]]+base[m[1]:], "-_. ")
+		}
+	}
+	if site == "" {
+		site = label
+	}
+	return site, t
+}
+
+// ImportOptions controls importing legacy backups into the standard layout.
+type ImportOptions struct {
+	// Site overrides the site label derived from the file name.
+	Site string
+	// DeleteSource removes a bucket object after it was converted.
+	DeleteSource bool
+	DryRun       bool
+}
+
+// ImportResult describes one imported backup.
+type ImportResult struct {
+	Source    string
+	ObjectKey string
+	Files     int
+	Size      int64
+	Converted bool
+}
+
+// importKey returns the standard object key of a backup of site taken at t.
+func (bm *BackupManager) importKey(site string, t time.Time) string {
+	name := fmt.Sprintf("%s-%s.tgz", site, InTimezone(t).Format("20060102-150405"))
+	if bm.minioConfig != nil && bm.minioConfig.BucketPath != "" {
+		return path.Join(bm.minioConfig.BucketPath, name)
+	}
+	return fmt.Sprintf("backups/%s/%s", site, name)
+}
+
+// ImportBackupFile uploads the local legacy backup src in the standard
+// layout: a .zip is converted to a .tgz with a generated manifest, a .tgz is
+// uploaded as it is.
+func (bm *BackupManager) ImportBackupFile(src string, opts *ImportOptions) (*ImportResult, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return bm.importBackup(src, f, info.Size(), info.ModTime(), opts)
+}
+
+// ImportBackupObject converts the legacy .zip object key in the bucket to a
+// .tgz in the standard layout, reading the zip in place.
+func (bm *BackupManager) ImportBackupObject(key string, opts *ImportOptions) (*ImportResult, error) {
+	if !isZipBackup(key) {
+		return nil, fmt.Errorf("%s is not a .zip backup", key)
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	info, err := bm.statObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := bm.getObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	res, err := bm.importBackup(key, obj, info.Size, info.LastModified, opts)
+	if err != nil || opts.DryRun || !opts.DeleteSource {
+		return res, err
+	}
+	if err := bm.removeObject(ctx, key); err != nil {
+		return res, fmt.Errorf("imported as %s but failed to delete %s: %w", res.ObjectKey, key, err)
+	}
+	return res, nil
+}
+
+func (bm *BackupManager) importBackup(source string, r io.Reader, size int64, modTime time.Time, opts *ImportOptions) (*ImportResult, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	lower := strings.ToLower(source)
+	zipped := isZipBackup(lower)
+	if !zipped && !strings.HasSuffix(lower, ".tgz") && !strings.HasSuffix(lower, ".tar.gz") {
+		return nil, fmt.Errorf("%s is not a .zip or .tgz backup", source)
+	}
+	site, t := LegacyBackupName(source, modTime, opts.Site)
+	if site == "" || strings.ContainsAny(site, "/ ") {
+		return nil, fmt.Errorf("cannot tell the site of %s (got %q); pass it explicitly", source, site)
+	}
+	res := &ImportResult{Source: source, ObjectKey: bm.importKey(site, t), Converted: zipped}
+	if res.ObjectKey == source {
+		return nil, fmt.Errorf("%s is already in the standard layout", source)
+	}
+
+	if !zipped {
+		res.Size = size
+		if opts.DryRun {
+			return res, nil
+		}
+		if err := bm.initMinioClient(); err != nil {
+			return nil, err
+		}
+		n, err := bm.putObject(context.Background(), res.ObjectKey, r, size, "application/gzip", map[string]string{"Ciwg-Imported-From": source})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", res.ObjectKey, err)
+		}
+		res.Size = n
+		return res, nil
+	}
+
+	zr, err := openZip(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", source, err)
+	}
+	facts := &BackupFacts{CreatedAt: t.UTC(), ImportedFrom: source}
+	if opts.DryRun {
+		n, err := convertZipToTgz(zr, io.Discard, site, facts)
+		res.Files = n
+		return res, err
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	files := make(chan int, 1)
+	go func() {
+		n, err := convertZipToTgz(zr, pw, site, facts)
+		files <- n
+		pw.CloseWithError(err)
+	}()
+	n, err := bm.putObject(context.Background(), res.ObjectKey, pr, -1, "application/gzip", facts.Metadata())
+	pr.CloseWithError(err)
+	res.Files = <-files
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", res.ObjectKey, err)
+	}
+	res.Size = n
+	return res, nil
+}
+
+// convertZipToTgz writes zr to w as a gzipped tarball in the standard layout
+// under site, with facts as its manifest, and returns how many files it
+// holds. A manifest in the zip is replaced.
+func convertZipToTgz(zr *zip.Reader, w io.Writer, site string, facts *BackupFacts) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	data = append(data, '\n')
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     site + "/" + BackupManifestName,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  facts.CreatedAt,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(tw, bytes.NewReader(data)); err != nil {
+		return 0, err
+	}
+
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if path.Base(f.Name) != BackupManifestName {
+			files = append(files, f)
+		}
+	}
+	n, err := writeZipAsTar(files, tw, site)
+	if err != nil {
+		return n, err
+	}
+	if err := tw.Close(); err != nil {
+		return n, err
+	}
+	return n, gz.Close()
+}
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func buildZip(t *testing.T, files map[string]string, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range order {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLegacyBackupName(t *testing.T) {
+	mod := time.Date(2023, 5, 6, 7, 8, 9, 0, time.Local)
+	tests := []struct {
+		name, site string
+		wantSite   string
+		wantTime   time.Time
+	}{
+		{"/srv/old/example.com_2024-01-31.zip", "", "example.com", time.Date(2024, 1, 31, 0, 0, 0, 0, Timezone())},
+		{"shop.com-20240101-120000.tgz", "", "shop.com", time.Date(2024, 1, 1, 12, 0, 0, 0, Timezone())},
+		{"backup_20240215T101112.zip", "blog.com", "blog.com", time.Date(2024, 2, 15, 10, 11, 12, 0, Timezone())},
+		{"example.org.zip", "", "example.org", mod},
+	}
+	for _, tt := range tests {
+		site, ts := LegacyBackupName(tt.name, mod, tt.site)
+		if site != tt.wantSite || !ts.Equal(tt.wantTime) {
+			t.Errorf("LegacyBackupName(%q) = %q, %v; want %q, %v", tt.name, site, ts, tt.wantSite, tt.wantTime)
+		}
+	}
+}
+
+func TestZipEntryName(t *testing.T) {
+	tests := []struct {
+		name, root string
+		want       string
+		ok         bool
+	}{
+		{"old/www/index.php", "old", "site.com/www/index.php", true},
+		{"old/", "old", "site.com/", true},
+		{"www/", "", "site.com/www/", true},
+		{"./db.sql", "", "site.com/db.sql", true},
+		{"../etc/passwd", "", "site.com/etc/passwd", true},
+	}
+	for _, tt := range tests {
+		got, ok := zipEntryName(tt.name, tt.root, "site.com")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("zipEntryName(%q, %q) = %q, %v; want %q, %v", tt.name, tt.root, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	zipFiles := func(names ...string) []*zip.File {
+		data := buildZip(t, map[string]string{}, names)
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zr.File
+	}
+	if root := zipRoot(zipFiles("a/x", "a/y/z")); root != "a" {
+		t.Errorf("zipRoot() = %q, want a", root)
+	}
+	if root := zipRoot(zipFiles("a/x", "db.sql")); root != "" {
+		t.Errorf("zipRoot() = %q, want none", root)
+	}
+}
+
+var legacyZipFiles = map[string]string{
+	"old.example.com/www/index.php":              "<?php",
+	"old.example.com/db.sql":                     "CREATE TABLE t (id int);\n",
+	"old.example.com/.ciwg-backup-manifest.json": "{}",
+}
+
+var legacyZipOrder = []string{"old.example.com/www/index.php", "old.example.com/db.sql", "old.example.com/.ciwg-backup-manifest.json"}
+
+func readTgz(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(body)
+	}
+	return got
+}
+
+func TestConvertZipToTgz(t *testing.T) {
+	data := buildZip(t, legacyZipFiles, legacyZipOrder)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	n, err := convertZipToTgz(zr, &out, "example.com", &BackupFacts{ImportedFrom: "old.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readTgz(t, &out)
+	if n != 2 || got["example.com/www/index.php"] != "<?php" || got["example.com/db.sql"] == "" {
+		t.Errorf("convertZipToTgz() = %d files, %v", n, got)
+	}
+	if m := got["example.com/"+BackupManifestName]; !bytes.Contains([]byte(m), []byte(`"imported_from": "old.zip"`)) {
+		t.Errorf("manifest = %s", m)
+	}
+}
+
+func TestOpenSQLDumpZip(t *testing.T) {
+	data := buildZip(t, legacyZipFiles, legacyZipOrder)
+	r, name, err := openSQLDump("legacy/example.com.zip", bytes.NewReader(data), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(r)
+	if name != "old.example.com/db.sql" || string(body) != legacyZipFiles["old.example.com/db.sql"] {
+		t.Errorf("openSQLDump() = %q, %q", name, body)
+	}
+	if _, _, err := openSQLDump("x.zip", bytes.NewReader(data), "missing"); err == nil {
+		t.Error("a non-matching --sql-path should fail")
+	}
+}
+
+func TestImportBackupFile(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "example.com_2024-01-31.zip")
+	if err := os.WriteFile(src, buildZip(t, legacyZipFiles, legacyZipOrder), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := bm.ImportBackupFile(src, &ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "backups/example.com/example.com-20240131-000000.tgz"
+	if res.ObjectKey != want || res.Files != 2 || !res.Converted {
+		t.Errorf("dry run = %+v", res)
+	}
+	if _, err := bm.statObject(context.Background(), want); err == nil {
+		t.Error("a dry run uploaded the backup")
+	}
+
+	if _, err := bm.ImportBackupFile(src, nil); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := bm.getObject(context.Background(), want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	if got := readTgz(t, obj); got["example.com/www/index.php"] != "<?php" {
+		t.Errorf("imported backup = %v", got)
+	}
+
+	if _, err := bm.ImportBackupFile(filepath.Join(t.TempDir(), "notes.txt"), nil); err == nil {
+		t.Error("importing a missing non-backup file should fail")
+	}
+}
+
+func TestParseBackupPartZip(t *testing.T) {
+	p := parseBackupPart("legacy/example.com-20240131-000000.zip")
+	if p.Stem != "legacy/example.com-20240131-000000" || p.Kind != PartArchive {
+		t.Errorf("parseBackupPart() = %+v", p)
+	}
+}
@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LifecycleOptions describes the bucket rules rendered next to a retention
+// policy by BuildLifecyclePlan.
+type LifecycleOptions struct {
+	Bucket string
+	// Prefix holds the backups the retention policy applies to. It must not
+	// be empty: the bucket also keeps the CLI's state under .ciwg/.
+	Prefix string
+	// GraceDays is added to the retention horizon, covering missed backups
+	// and lifecycle scans that run a day late.
+	GraceDays int
+	// TransitionDays moves backups to StorageClass after that many days; 0
+	// leaves them where they are.
+	TransitionDays int
+	StorageClass   string
+	// StagingPrefix, when set, tiers staged migrations to
+	// StagingStorageClass as soon as they land (see SetStaging).
+	StagingPrefix       string
+	StagingStorageClass string
+	// AbortMultipartDays cleans up interrupted multipart uploads; 0 omits
+	// the rule.
+	AbortMultipartDays int
+}
+
+// LifecycleTransition moves objects to another storage class or tier.
+type LifecycleTransition struct {
+	Days         int    `json:"Days"`
+	StorageClass string `json:"StorageClass"`
+}
+
+// LifecycleRule is one bucket lifecycle rule.
+type LifecycleRule struct {
+	ID             string
+	Prefix         string
+	ExpirationDays int
+	Transitions    []LifecycleTransition
+	// AbortMultipartDays aborts incomplete multipart uploads after that many
+	// days.
+	AbortMultipartDays int
+}
+
+// LifecyclePlan is the bucket lifecycle that matches a retention policy.
+type LifecyclePlan struct {
+	Bucket string
+	// HorizonDays is the age of the oldest backup the policy can keep when
+	// one backup is taken per day.
+	HorizonDays int
+	Rules       []LifecycleRule
+}
+
+// RetentionHorizonDays returns the age in days of the oldest backup policy
+// keeps when a backup is taken every day: the longest of the daily, weekly
+// and monthly tails, counting a month as 31 days.
+func RetentionHorizonDays(policy *SmartRetentionPolicy) int {
+	days := policy.KeepDaily
+	if w := policy.KeepWeekly * 7; w > days {
+		days = w
+	}
+	if m := policy.KeepMonthly * 31; m > days {
+		days = m
+	}
+	return days
+}
+
+// BuildLifecyclePlan renders policy and the tiering of opts as lifecycle
+// rules. Smart retention counts backups per site while lifecycle rules only
+// know an object's age, so the expiration is a backstop at the retention
+// horizon plus the grace days: it never removes a backup the CLI would keep
+// on a daily schedule, and the CLI's pruning still thins out the backups in
+// between.
+func BuildLifecyclePlan(policy *SmartRetentionPolicy, opts LifecycleOptions) (*LifecyclePlan, error) {
+	if policy == nil || !policy.Enabled {
+		return nil, fmt.Errorf("lifecycle export needs a smart retention policy")
+	}
+	if opts.Prefix == "" || strings.HasPrefix(stateObjectPrefix, opts.Prefix) {
+		return nil, fmt.Errorf("prefix %q would expire the CLI's state under %s; use the backups prefix, e.g. backups/", opts.Prefix, stateObjectPrefix)
+	}
+	if opts.GraceDays < 0 || opts.TransitionDays < 0 || opts.AbortMultipartDays < 0 {
+		return nil, fmt.Errorf("lifecycle days must not be negative")
+	}
+	horizon := RetentionHorizonDays(policy)
+	if horizon == 0 {
+		return nil, fmt.Errorf("retention policy keeps no backups")
+	}
+
+	plan := &LifecyclePlan{Bucket: opts.Bucket, HorizonDays: horizon}
+	rule := LifecycleRule{
+		ID:             "ciwg-backup-retention",
+		Prefix:         opts.Prefix,
+		ExpirationDays: horizon + opts.GraceDays,
+	}
+	if opts.TransitionDays > 0 {
+		if opts.StorageClass == "" {
+			return nil, fmt.Errorf("a transition needs a storage class")
+		}
+		if opts.TransitionDays >= rule.ExpirationDays {
+			return nil, fmt.Errorf("transition after %d days is not before the expiration after %d days", opts.TransitionDays, rule.ExpirationDays)
+		}
+		rule.Transitions = []LifecycleTransition{{Days: opts.TransitionDays, StorageClass: opts.StorageClass}}
+	}
+	plan.Rules = append(plan.Rules, rule)
+
+	if opts.StagingPrefix != "" {
+		if opts.StagingStorageClass == "" {
+			return nil, fmt.Errorf("tiering %s needs a storage class", opts.StagingPrefix)
+		}
+		plan.Rules = append(plan.Rules, LifecycleRule{
+			ID:          "ciwg-staging-tier",
+			Prefix:      opts.StagingPrefix,
+			Transitions: []LifecycleTransition{{Days: 0, StorageClass: opts.StagingStorageClass}},
+		})
+	}
+	if opts.AbortMultipartDays > 0 {
+		plan.Rules = append(plan.Rules, LifecycleRule{
+			ID:                 "ciwg-abort-incomplete-uploads",
+			AbortMultipartDays: opts.AbortMultipartDays,
+		})
+	}
+	return plan, nil
+}
+
+// S3JSON renders the plan as an S3 lifecycle configuration, as accepted by
+// `aws s3api put-bucket-lifecycle-configuration` and `mc ilm import`.
+func (p *LifecyclePlan) S3JSON() ([]byte, error) {
+	type days struct {
+		Days int `json:"Days"`
+	}
+	type abort struct {
+		DaysAfterInitiation int `json:"DaysAfterInitiation"`
+	}
+	type rule struct {
+		ID                             string                `json:"ID"`
+		Status                         string                `json:"Status"`
+		Filter                         map[string]string     `json:"Filter"`
+		Expiration                     *days                 `json:"Expiration,omitempty"`
+		Transitions                    []LifecycleTransition `json:"Transitions,omitempty"`
+		AbortIncompleteMultipartUpload *abort                `json:"AbortIncompleteMultipartUpload,omitempty"`
+	}
+	rules := make([]rule, 0, len(p.Rules))
+	for _, r := range p.Rules {
+		out := rule{ID: r.ID, Status: "Enabled", Filter: map[string]string{"Prefix": r.Prefix}, Transitions: r.Transitions}
+		if r.ExpirationDays > 0 {
+			out.Expiration = &days{r.ExpirationDays}
+		}
+		if r.AbortMultipartDays > 0 {
+			out.AbortIncompleteMultipartUpload = &abort{r.AbortMultipartDays}
+		}
+		rules = append(rules, out)
+	}
+	return json.MarshalIndent(map[string]any{"Rules": rules}, "", "  ")
+}
+
+var terraformNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Terraform renders the plan as an aws_s3_bucket_lifecycle_configuration
+// resource. header is written as comments above it.
+func (p *LifecyclePlan) Terraform(header []string) string {
+	name := terraformNameInvalid.ReplaceAllString(p.Bucket, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "backups_" + name
+	}
+
+	var b strings.Builder
+	for _, line := range header {
+		fmt.Fprintf(&b, "# %s\n", line)
+	}
+	fmt.Fprintf(&b, "resource \"aws_s3_bucket_lifecycle_configuration\" %q {\n", name)
+	fmt.Fprintf(&b, "  bucket = %q\n", p.Bucket)
+	for _, r := range p.Rules {
+		fmt.Fprintf(&b, "\n  rule {\n")
+		fmt.Fprintf(&b, "    id     = %q\n", r.ID)
+		fmt.Fprintf(&b, "    status = \"Enabled\"\n\n")
+		fmt.Fprintf(&b, "    filter {\n      prefix = %q\n    }\n", r.Prefix)
+		for _, t := range r.Transitions {
+			fmt.Fprintf(&b, "\n    transition {\n      days          = %d\n      storage_class = %q\n    }\n", t.Days, t.StorageClass)
+		}
+		if r.ExpirationDays > 0 {
+			fmt.Fprintf(&b, "\n    expiration {\n      days = %d\n    }\n", r.ExpirationDays)
+		}
+		if r.AbortMultipartDays > 0 {
+			fmt.Fprintf(&b, "\n    abort_incomplete_multipart_upload {\n      days_after_initiation = %d\n    }\n", r.AbortMultipartDays)
+		}
+		fmt.Fprintf(&b, "  }\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRetentionHorizonDays(t *testing.T) {
+	tests := []struct {
+		policy SmartRetentionPolicy
+		want   int
+	}{
+		{SmartRetentionPolicy{KeepDaily: 14, KeepWeekly: 26, KeepMonthly: 6}, 186},
+		{SmartRetentionPolicy{KeepDaily: 7, KeepWeekly: 52, KeepMonthly: 1}, 364},
+		{SmartRetentionPolicy{KeepDaily: 30}, 30},
+	}
+	for _, tt := range tests {
+		if got := RetentionHorizonDays(&tt.policy); got != tt.want {
+			t.Errorf("RetentionHorizonDays(%+v) = %d, want %d", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestBuildLifecyclePlan(t *testing.T) {
+	policy := RetentionPreset{KeepDaily: 14, KeepWeekly: 26, KeepMonthly: 6}.Policy()
+	plan, err := BuildLifecyclePlan(policy, LifecycleOptions{
+		Bucket:              "backups",
+		Prefix:              "backups/",
+		GraceDays:           7,
+		TransitionDays:      30,
+		StorageClass:        "GLACIER",
+		StagingPrefix:       "staging/",
+		StagingStorageClass: "COLD",
+		AbortMultipartDays:  3,
+	})
+	if err != nil {
+		t.Fatalf("BuildLifecyclePlan() error = %v", err)
+	}
+	if len(plan.Rules) != 3 || plan.Rules[0].ExpirationDays != 193 || plan.Rules[1].Prefix != "staging/" || plan.Rules[2].AbortMultipartDays != 3 {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	data, err := plan.S3JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Rules []struct {
+			ID          string
+			Status      string
+			Filter      struct{ Prefix string }
+			Expiration  *struct{ Days int }
+			Transitions []struct {
+				Days         int
+				StorageClass string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("S3JSON() is not JSON: %v", err)
+	}
+	r := doc.Rules[0]
+	if r.Status != "Enabled" || r.Filter.Prefix != "backups/" || r.Expiration == nil || r.Expiration.Days != 193 ||
+		len(r.Transitions) != 1 || r.Transitions[0].Days != 30 || r.Transitions[0].StorageClass != "GLACIER" {
+		t.Errorf("S3JSON() rule = %+v", r)
+	}
+	if doc.Rules[1].Expiration != nil {
+		t.Error("staging rule must not expire staged objects")
+	}
+
+	tf := plan.Terraform([]string{"generated"})
+	for _, want := range []string{
+		"# generated\n",
+		`resource "aws_s3_bucket_lifecycle_configuration" "backups" {`,
+		`prefix = "staging/"`,
+		"days = 193",
+		`storage_class = "COLD"`,
+		"days_after_initiation = 3",
+	} {
+		if !strings.Contains(tf, want) {
+			t.Errorf("Terraform() missing %q:\n%s", want, tf)
+		}
+	}
+}
+
+func TestBuildLifecyclePlanErrors(t *testing.T) {
+	policy := RetentionPreset{KeepDaily: 14}.Policy()
+	tests := map[string]LifecycleOptions{
+		"empty prefix":        {Prefix: ""},
+		"state prefix":        {Prefix: ".ciwg/"},
+		"late transition":     {Prefix: "backups/", TransitionDays: 14, StorageClass: "GLACIER"},
+		"no storage class":    {Prefix: "backups/", TransitionDays: 5},
+		"staging no class":    {Prefix: "backups/", StagingPrefix: "staging/"},
+		"negative grace days": {Prefix: "backups/", GraceDays: -1},
+	}
+	for name, opts := range tests {
+		if _, err := BuildLifecyclePlan(policy, opts); err == nil {
+			t.Errorf("%s: BuildLifecyclePlan() error = nil", name)
+		}
+	}
+	if _, err := BuildLifecyclePlan(&SmartRetentionPolicy{Enabled: true}, LifecycleOptions{Prefix: "backups/"}); err == nil {
+		t.Error("a policy keeping nothing should be rejected")
+	}
+}
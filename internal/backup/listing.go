@@ -0,0 +1,352 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ListingOptions tunes how ListBackups lists very large buckets. The zero
+// value lists each prefix in a single unthrottled pass.
+type ListingOptions struct {
+	// Parallelism lists the top-level directories under the prefix (one per
+	// site) as separate shards, this many at a time. Values below 2 list the
+	// prefix in one pass.
+	Parallelism int
+	// RequestsPerSecond caps LIST requests across all shards; 0 is unlimited.
+	RequestsPerSecond float64
+	// PageSize is the number of keys requested per LIST call; 0 uses the
+	// server default (1000).
+	PageSize int
+	// CacheTTL serves full listings from a listing cache object stored in the
+	// bucket while it is younger than CacheTTL, and refreshes the cache
+	// object when it is older. Objects written by other hosts within the TTL
+	// are not seen, so keep it short or leave it 0 for destructive commands.
+	CacheTTL time.Duration
+}
+
+// listingCachePrefix holds cached listings. Like all state objects they are
+// never returned by ListBackups.
+const listingCachePrefix = stateObjectPrefix + "listing-cache/"
+
+// hiddenFromListing reports whether key is a state object that a listing of
+// prefix must leave out. State is only listed when asked for explicitly.
+func hiddenFromListing(key, prefix string) bool {
+	return strings.HasPrefix(key, stateObjectPrefix) && !strings.HasPrefix(prefix, stateObjectPrefix)
+}
+
+// listProgressInterval is how often a running listing reports its progress.
+var listProgressInterval = 10 * time.Second
+
+// listingCache is the body of a listing cache object.
+type listingCache struct {
+	Prefix      string       `json:"prefix"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Objects     []ObjectInfo `json:"objects"`
+}
+
+// listingCacheKey returns the cache object for prefix.
+func listingCacheKey(prefix string) string {
+	if prefix == "" {
+		return listingCachePrefix + "_all.json.gz"
+	}
+	return listingCachePrefix + url.PathEscape(prefix) + ".json.gz"
+}
+
+// loadListingCache returns the cached listing of prefix when it is younger
+// than ttl. Listings are never served from the cache after this manager has
+// written to or deleted from the bucket.
+func (bm *BackupManager) loadListingCache(ctx context.Context, prefix string, ttl time.Duration) ([]ObjectInfo, bool) {
+	if bm.listingDirty.Load() {
+		return nil, false
+	}
+	r, err := bm.getObject(ctx, listingCacheKey(prefix))
+	if err != nil {
+		return nil, false
+	}
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		bm.logDebug("Ignoring unreadable listing cache for %q: %v", prefix, err)
+		return nil, false
+	}
+	defer gz.Close()
+	var cache listingCache
+	if err := json.NewDecoder(gz).Decode(&cache); err != nil {
+		bm.logDebug("Ignoring unreadable listing cache for %q: %v", prefix, err)
+		return nil, false
+	}
+	age := time.Since(cache.GeneratedAt)
+	if cache.Prefix != prefix || age > ttl {
+		return nil, false
+	}
+	bm.logVerbose("Using listing cache for %q from %s ago (%d objects)", prefix, age.Round(time.Second), len(cache.Objects))
+	return cache.Objects, true
+}
+
+// saveListingCache stores objs as the cached listing of prefix.
+func (bm *BackupManager) saveListingCache(ctx context.Context, prefix string, objs []ObjectInfo) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(listingCache{Prefix: prefix, GeneratedAt: time.Now().UTC(), Objects: objs}); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if _, err := bm.putObject(ctx, listingCacheKey(prefix), &buf, int64(buf.Len()), "application/gzip", nil); err != nil {
+		return err
+	}
+	// The listing was taken after any earlier writes, so the cache is current.
+	bm.listingDirty.Store(false)
+	return nil
+}
+
+// listPacer spaces LIST requests so they never exceed a fixed rate.
+type listPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newListPacer(perSecond float64) *listPacer {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &listPacer{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next request may be sent. A nil pacer never waits.
+func (p *listPacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// listProgress reports the running object count of a long listing on stderr,
+// so it never mixes with JSON or CSV results on stdout.
+type listProgress struct {
+	prefix  string
+	started time.Time
+	count   atomic.Int64
+	shards  atomic.Int64
+	total   atomic.Int64
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	printed atomic.Bool
+}
+
+func (bm *BackupManager) startListProgress(prefix string) *listProgress {
+	p := &listProgress{prefix: prefix, started: time.Now(), stop: make(chan struct{})}
+	if bm.verbosity < 1 {
+		return p
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		t := time.NewTicker(listProgressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.printed.Store(true)
+				shards := ""
+				if total := p.total.Load(); total > 0 {
+					shards = fmt.Sprintf(", %d/%d shards", p.shards.Load(), total)
+				}
+				fmt.Fprintf(os.Stderr, "Listing %s: %d objects so far (%s%s)\n",
+					p.label(), p.count.Load(), time.Since(p.started).Round(time.Second), shards)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *listProgress) label() string {
+	if p.prefix == "" {
+		return "bucket"
+	}
+	return p.prefix
+}
+
+// done stops the reporter and prints a summary if progress was shown.
+func (p *listProgress) done() {
+	close(p.stop)
+	p.wg.Wait()
+	if p.printed.Load() {
+		fmt.Fprintf(os.Stderr, "Listed %s: %d objects in %s\n", p.label(), p.count.Load(), time.Since(p.started).Round(time.Second))
+	}
+}
+
+// listMinio lists prefix on the Minio backend using the configured listing
+// options, stopping after limit objects when limit > 0.
+func (bm *BackupManager) listMinio(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error) {
+	opts := bm.listingOptions()
+	full := limit <= 0
+	// Coordination objects (semaphore tickets) must always be listed live.
+	cached := full && opts.CacheTTL > 0 && !isInternalObject(prefix)
+	if cached {
+		if objs, ok := bm.loadListingCache(ctx, prefix, opts.CacheTTL); ok {
+			return objs, nil
+		}
+	}
+
+	progress := bm.startListProgress(prefix)
+	defer progress.done()
+	pacer := bm.listPacer()
+
+	var results []ObjectInfo
+	var err error
+	if full && opts.Parallelism > 1 {
+		results, err = bm.listSharded(ctx, prefix, opts.Parallelism, pacer, progress)
+	} else {
+		results, err = bm.listPrefix(ctx, prefix, true, limit, pacer, progress)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && !bm.dryRun {
+		if err := bm.saveListingCache(ctx, prefix, results); err != nil {
+			fmt.Printf("⚠️  Failed to update listing cache: %v\n", err)
+		}
+	}
+	return results, nil
+}
+
+// listPacer returns the manager's shared LIST pacer, creating it on first use.
+func (bm *BackupManager) listPacer() *listPacer {
+	bm.listPacerOnce.Do(func() {
+		bm.listPacerShared = newListPacer(bm.minioConfig.Listing.RequestsPerSecond)
+	})
+	return bm.listPacerShared
+}
+
+// listPrefix runs one paginated LIST of prefix. The pacer is consulted before
+// every page; since the client fetches the next page only after the current
+// one has been consumed, pausing here delays the request itself.
+func (bm *BackupManager) listPrefix(ctx context.Context, prefix string, recursive bool, limit int, pacer *listPacer, progress *listProgress) ([]ObjectInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pageSize := bm.minioConfig.Listing.PageSize
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	if err := pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+	ch := bm.minioClient.ListObjects(ctx, bm.minioConfig.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: recursive,
+		MaxKeys:   bm.minioConfig.Listing.PageSize,
+	})
+
+	var results []ObjectInfo
+	seen := 0
+	for obj := range ch {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		seen++
+		if seen%pageSize == 0 {
+			if err := pacer.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if hiddenFromListing(obj.Key, prefix) {
+			continue
+		}
+		results = append(results, ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+			ETag:         obj.ETag,
+		})
+		progress.count.Add(1)
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// listSharded lists the objects directly under prefix, then every
+// subdirectory of prefix as its own recursive listing, parallelism at a time.
+// Results are returned in key order, as a single listing would.
+func (bm *BackupManager) listSharded(ctx context.Context, prefix string, parallelism int, pacer *listPacer, progress *listProgress) ([]ObjectInfo, error) {
+	top, err := bm.listPrefix(ctx, prefix, false, 0, pacer, progress)
+	if err != nil {
+		return nil, err
+	}
+	var shards []string
+	var direct []ObjectInfo
+	for _, o := range top {
+		if strings.HasSuffix(o.Key, "/") {
+			shards = append(shards, o.Key)
+			continue
+		}
+		direct = append(direct, o)
+	}
+	progress.count.Store(int64(len(direct)))
+	progress.total.Store(int64(len(shards)))
+	bm.logVerbose("Listing %d shard(s) under %q with parallelism %d", len(shards), prefix, parallelism)
+
+	parts := make([][]ObjectInfo, len(shards))
+	errs := make([]error, len(shards))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, shard string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			parts[i], errs[i] = bm.listPrefix(ctx, shard, true, 0, pacer, progress)
+			progress.shards.Add(1)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	objs := direct
+	for i, part := range parts {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", shards[i], errs[i])
+		}
+		objs = append(objs, part...)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListingCacheKey(t *testing.T) {
+	tests := map[string]string{
+		"":                  ".ciwg/listing-cache/_all.json.gz",
+		"backups/site.com/": ".ciwg/listing-cache/backups%2Fsite.com%2F.json.gz",
+	}
+	for prefix, want := range tests {
+		if got := listingCacheKey(prefix); got != want {
+			t.Errorf("listingCacheKey(%q) = %s, want %s", prefix, got, want)
+		}
+	}
+}
+
+func TestListingCacheRoundTrip(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{Endpoint: "file://" + t.TempDir()})
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a-20250101-000000.tgz", Size: 10, LastModified: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), ETag: "e1"},
+		{Key: "backups/b.com/b-20250102-000000.tgz", Size: 20, LastModified: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), ETag: "e2"},
+	}
+	if err := bm.saveListingCache(ctx, "backups/", objs); err != nil {
+		t.Fatalf("saveListingCache() error = %v", err)
+	}
+
+	got, ok := bm.loadListingCache(ctx, "backups/", time.Hour)
+	if !ok || !reflect.DeepEqual(got, objs) {
+		t.Fatalf("loadListingCache() = %+v, %v, want cached objects", got, ok)
+	}
+	if _, ok := bm.loadListingCache(ctx, "backups/a.com/", time.Hour); ok {
+		t.Error("loadListingCache() served a different prefix")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := bm.loadListingCache(ctx, "backups/", time.Millisecond); ok {
+		t.Error("loadListingCache() served an expired cache")
+	}
+
+	// A write through the manager makes the cache stale for this manager.
+	if _, err := bm.putObject(ctx, "backups/c.com/c.tgz", strings.NewReader("x"), 1, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bm.loadListingCache(ctx, "backups/", time.Hour); ok {
+		t.Error("loadListingCache() served the cache after a write")
+	}
+}
+
+func TestListPacer(t *testing.T) {
+	var nilPacer *listPacer
+	if err := nilPacer.wait(context.Background()); err != nil {
+		t.Fatalf("nil pacer wait() error = %v", err)
+	}
+	if newListPacer(0) != nil {
+		t.Error("newListPacer(0) should be unlimited (nil)")
+	}
+
+	p := newListPacer(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes out at once, the next four 10ms apart.
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("5 requests at 100/s took %s, want at least 40ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := newListPacer(0.001)
+	slow.wait(ctx)
+	if err := slow.wait(ctx); err == nil {
+		t.Error("wait() on a cancelled context should fail while throttled")
+	}
+}
@@ -0,0 +1,64 @@
+package backup
+
+import "sync/atomic"
+
+// LowMemoryPartSize is the multipart part size of uploads in low-memory
+// mode. Backups stream with an unknown size, and minio-go buffers a whole
+// part of such a stream in memory; left to choose, it picks parts of over
+// 500 MiB.
+const LowMemoryPartSize int64 = 16 << 20
+
+// LowMemoryHeapLimit is the soft Go heap limit the CLI sets in low-memory
+// mode, so garbage is collected well before a small host runs out.
+const LowMemoryHeapLimit int64 = 256 << 20
+
+// lowMemoryRecompressBufferSize replaces recompressBufferSize in low-memory
+// mode.
+const lowMemoryRecompressBufferSize = 256 << 10
+
+var lowMemory atomic.Bool
+
+// SetLowMemory turns process-wide low-memory mode on or off, for hosts with
+// around 1 GB of RAM that run out of memory during backups. In it every
+// manager
+//
+//   - uploads to Minio in LowMemoryPartSize parts, one at a time, whatever
+//     --minio-part-size and --minio-upload-concurrency say;
+//   - lists buckets in one pass without shards and never reads or writes
+//     the listing cache, which is held whole in memory;
+//   - analyzes one container at a time in capacity estimates;
+//   - re-compresses Glacier copies with a small buffer and a single-threaded
+//     zstd encoder.
+//
+// The trade-offs: uploads make many more requests and are slower over
+// high-latency links, a streamed upload is limited to 10000 parts (about
+// 156 GiB), listings of large buckets take longer, and zstd
+// re-compression uses one core. Glacier buffers stay on disk, and tree
+// hashes are always computed incrementally.
+func SetLowMemory(on bool) {
+	lowMemory.Store(on)
+}
+
+// LowMemory reports whether SetLowMemory turned low-memory mode on.
+func LowMemory() bool {
+	return lowMemory.Load()
+}
+
+// uploadTuning returns the part size and number of parts sent at once of
+// Minio uploads, capped in low-memory mode.
+func (bm *BackupManager) uploadTuning() (partSize int64, concurrency int) {
+	if LowMemory() {
+		return LowMemoryPartSize, 1
+	}
+	return bm.minioConfig.PartSize, bm.minioConfig.UploadConcurrency
+}
+
+// listingOptions returns the listing options of bm without sharding or the
+// listing cache in low-memory mode.
+func (bm *BackupManager) listingOptions() ListingOptions {
+	opts := bm.minioConfig.Listing
+	if LowMemory() {
+		opts.Parallelism, opts.CacheTTL = 1, 0
+	}
+	return opts
+}
@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// levelTreeHash is the Glacier tree hash as AWS describes it: adjacent
+// hashes are paired level by level, an odd one out carried up unchanged.
+func levelTreeHash(chunks []hashChunk) string {
+	if len(chunks) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
+	}
+	for len(chunks) > 1 {
+		var next []hashChunk
+		for i := 0; i < len(chunks); i += 2 {
+			if i+1 < len(chunks) {
+				next = append(next, combineTreeHashes(chunks[i], chunks[i+1]))
+			} else {
+				next = append(next, chunks[i])
+			}
+		}
+		chunks = next
+	}
+	return hex.EncodeToString(chunks[0][:])
+}
+
+func TestTreeHasherMatchesLevelPairing(t *testing.T) {
+	var chunks []hashChunk
+	for n := 0; n <= 70; n++ {
+		var h treeHasher
+		for _, c := range chunks {
+			h.add(c)
+		}
+		if got, want := h.sum(), levelTreeHash(chunks); got != want {
+			t.Fatalf("%d chunks: tree hash %s, want %s", n, got, want)
+		}
+		if len(h.nodes) > 7 {
+			t.Fatalf("%d chunks: %d hashes kept", n, len(h.nodes))
+		}
+		chunks = append(chunks, sha256.Sum256([]byte{byte(n)}))
+	}
+}
+
+func withLowMemory(t *testing.T) {
+	t.Helper()
+	SetLowMemory(true)
+	t.Cleanup(func() { SetLowMemory(false) })
+}
+
+func TestLowMemoryCaps(t *testing.T) {
+	bm := NewBackupManager(nil, &MinioConfig{
+		PartSize:          512 << 20,
+		UploadConcurrency: 8,
+		Listing:           ListingOptions{Parallelism: 16, CacheTTL: time.Hour, PageSize: 500},
+	})
+	if size, threads := bm.uploadTuning(); size != 512<<20 || threads != 8 {
+		t.Errorf("uploadTuning() = %d, %d", size, threads)
+	}
+
+	withLowMemory(t)
+	if size, threads := bm.uploadTuning(); size != LowMemoryPartSize || threads != 1 {
+		t.Errorf("low-memory uploadTuning() = %d, %d", size, threads)
+	}
+	opts := bm.listingOptions()
+	if opts.Parallelism != 1 || opts.CacheTTL != 0 || opts.PageSize != 500 {
+		t.Errorf("low-memory listingOptions() = %+v", opts)
+	}
+}
+
+func TestLowMemoryTranscode(t *testing.T) {
+	withLowMemory(t)
+	payload := bytes.Repeat([]byte("wp-content/uploads "), 50000)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(payload)
+	w.Close()
+
+	var out bytes.Buffer
+	if err := transcodeGzip(&out, &gz, CompressionSpec{Codec: CodecZstd, Level: 19}); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	got, err := dec.DecodeAll(out.Bytes(), nil)
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("round trip = %d bytes, %v", len(got), err)
+	}
+}
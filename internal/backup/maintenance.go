@@ -0,0 +1,230 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maintenancePrefix holds one maintenance flag object per site.
+const maintenancePrefix = stateObjectPrefix + "maintenance/"
+
+// MaintenanceEntry puts a site in maintenance: fleet-wide create, prune and
+// migrate runs skip it until the entry expires or is cleared, e.g. while the
+// site is mid-migration between servers.
+type MaintenanceEntry struct {
+	Site string `json:"site"`
+	// Until is when the site leaves maintenance; zero means until cleared.
+	Until  time.Time `json:"until,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	SetBy  string    `json:"set_by,omitempty"`
+	SetAt  time.Time `json:"set_at"`
+}
+
+// Active reports whether the entry still applies at now.
+func (e MaintenanceEntry) Active(now time.Time) bool {
+	return e.Until.IsZero() || now.Before(e.Until)
+}
+
+// String describes the entry for skip messages, e.g. "until 2025-01-01
+// (moving to wp3)".
+func (e MaintenanceEntry) String() string {
+	s := "until cleared"
+	if !e.Until.IsZero() {
+		s = "until " + FormatTime(e.Until, "2006-01-02 15:04")
+	}
+	if e.Reason != "" {
+		s += " (" + e.Reason + ")"
+	}
+	return s
+}
+
+// ParseMaintenanceUntil parses a --until value: a date (midnight in the
+// canonical timezone) or an RFC 3339 timestamp.
+func ParseMaintenanceUntil(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, Timezone()); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD or RFC 3339)", s)
+	}
+	return t, nil
+}
+
+func maintenanceKey(site string) string {
+	return maintenancePrefix + url.PathEscape(site) + ".json"
+}
+
+// SetMaintenance stores entry in the bucket, replacing any existing entry for
+// the site.
+func (bm *BackupManager) SetMaintenance(entry MaintenanceEntry) error {
+	if entry.Site == "" {
+		return fmt.Errorf("site is required")
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := bm.putObject(context.Background(), maintenanceKey(entry.Site), bytes.NewReader(data), int64(len(data)), "application/json", nil); err != nil {
+		return fmt.Errorf("failed to store maintenance flag for %s: %w", entry.Site, err)
+	}
+	return nil
+}
+
+// ClearMaintenance takes site out of maintenance. Clearing a site that is not
+// in maintenance is not an error.
+func (bm *BackupManager) ClearMaintenance(site string) error {
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if _, err := bm.statObject(ctx, maintenanceKey(site)); err != nil {
+		return nil
+	}
+	if err := bm.removeObject(ctx, maintenanceKey(site)); err != nil {
+		return fmt.Errorf("failed to clear maintenance flag for %s: %w", site, err)
+	}
+	return nil
+}
+
+// ListMaintenance returns every stored maintenance entry, expired ones
+// included, sorted by site.
+func (bm *BackupManager) ListMaintenance() ([]MaintenanceEntry, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	objs, err := bm.listObjects(ctx, maintenancePrefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance flags: %w", err)
+	}
+	var entries []MaintenanceEntry
+	for _, o := range objs {
+		if !strings.HasSuffix(o.Key, ".json") {
+			continue
+		}
+		r, err := bm.getObject(ctx, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", o.Key, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", o.Key, err)
+		}
+		var e MaintenanceEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			bm.logVerbose("Ignoring unreadable maintenance flag %s: %v", o.Key, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Site < entries[j].Site })
+	return entries, nil
+}
+
+// MaintenanceSites returns the entries in effect at now, keyed by site.
+func (bm *BackupManager) MaintenanceSites(now time.Time) (map[string]MaintenanceEntry, error) {
+	entries, err := bm.ListMaintenance()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]MaintenanceEntry, len(entries))
+	for _, e := range entries {
+		if e.Active(now) {
+			active[e.Site] = e
+		}
+	}
+	return active, nil
+}
+
+// ExcludeMaintenance drops the objects of sites in maintenance from objs and
+// reports each skipped site once.
+func (bm *BackupManager) ExcludeMaintenance(objs []ObjectInfo) ([]ObjectInfo, error) {
+	sites, err := bm.MaintenanceSites(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(sites) == 0 {
+		return objs, nil
+	}
+	kept := make([]ObjectInfo, 0, len(objs))
+	skipped := map[string]int{}
+	for _, o := range objs {
+		if _, ok := sites[inventorySite(o.Key, "")]; ok {
+			skipped[inventorySite(o.Key, "")]++
+			continue
+		}
+		kept = append(kept, o)
+	}
+	names := make([]string, 0, len(skipped))
+	for site := range skipped {
+		names = append(names, site)
+	}
+	sort.Strings(names)
+	for _, site := range names {
+		fmt.Printf("⏭  %s: skipped (maintenance %s), %d object(s) left alone\n", site, sites[site], skipped[site])
+	}
+	return kept, nil
+}
+
+// SkipReasonMaintenance marks containers skipped because their site is in
+// maintenance.
+const SkipReasonMaintenance = "maintenance"
+
+// ContainerSkip records one container left out of a run.
+type ContainerSkip struct {
+	Container string `json:"container"`
+	Site      string `json:"site"`
+	Reason    string `json:"reason"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// skipMaintenance drops containers whose site is in maintenance and records
+// them in the run. If the flags can't be read the run goes ahead with every
+// container; a missed skip is safer than a missed backup.
+func (bm *BackupManager) skipMaintenance(containers []ContainerInfo) []ContainerInfo {
+	sites, err := bm.MaintenanceSites(time.Now())
+	if err != nil {
+		fmt.Printf("⚠️  Warning: could not read maintenance flags, backing up every site: %v\n", err)
+		return containers
+	}
+	if len(sites) == 0 {
+		return containers
+	}
+	kept := containers[:0:0]
+	for _, c := range containers {
+		site := containerSite(c)
+		entry, ok := sites[site]
+		if !ok {
+			kept = append(kept, c)
+			continue
+		}
+		fmt.Printf("⏭  Skipping container %s: site %s is in maintenance %s\n", c.Name, site, entry)
+		if bm.lastRun != nil {
+			bm.lastRun.Skipped = append(bm.lastRun.Skipped, ContainerSkip{
+				Container: c.Name,
+				Site:      site,
+				Reason:    SkipReasonMaintenance,
+				Detail:    entry.String(),
+			})
+		}
+	}
+	return kept
+}
+
+// containerSite is the site name a container's backups are stored under.
+func containerSite(c ContainerInfo) string {
+	return filepath.Base(c.WorkingDir)
+}
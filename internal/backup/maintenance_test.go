@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceUntil(t *testing.T) {
+	got, err := ParseMaintenanceUntil("2025-01-01")
+	if err != nil {
+		t.Fatalf("ParseMaintenanceUntil(date) error = %v", err)
+	}
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, Timezone()); !got.Equal(want) {
+		t.Errorf("ParseMaintenanceUntil(date) = %v, want %v", got, want)
+	}
+	got, err = ParseMaintenanceUntil("2025-01-01T12:00:00Z")
+	if err != nil {
+		t.Fatalf("ParseMaintenanceUntil(RFC 3339) error = %v", err)
+	}
+	if want := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseMaintenanceUntil(RFC 3339) = %v, want %v", got, want)
+	}
+	if _, err := ParseMaintenanceUntil("next week"); err == nil {
+		t.Error("ParseMaintenanceUntil(next week) error = nil")
+	}
+}
+
+func TestMaintenanceRoundTrip(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	now := time.Now()
+
+	sites, err := bm.MaintenanceSites(now)
+	if err != nil {
+		t.Fatalf("MaintenanceSites() on an empty bucket error = %v", err)
+	}
+	if len(sites) != 0 {
+		t.Fatalf("MaintenanceSites() = %v, want none", sites)
+	}
+
+	for _, e := range []MaintenanceEntry{
+		{Site: "foo.com", Until: now.Add(24 * time.Hour), Reason: "moving to wp3"},
+		{Site: "bar.com"},
+		{Site: "old.com", Until: now.Add(-time.Hour)},
+	} {
+		if err := bm.SetMaintenance(e); err != nil {
+			t.Fatalf("SetMaintenance(%s) error = %v", e.Site, err)
+		}
+	}
+
+	entries, err := bm.ListMaintenance()
+	if err != nil {
+		t.Fatalf("ListMaintenance() error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Site)
+	}
+	if got := strings.Join(names, ","); got != "bar.com,foo.com,old.com" {
+		t.Errorf("ListMaintenance() sites = %s", got)
+	}
+
+	sites, err = bm.MaintenanceSites(now)
+	if err != nil {
+		t.Fatalf("MaintenanceSites() error = %v", err)
+	}
+	if _, ok := sites["old.com"]; ok || len(sites) != 2 {
+		t.Errorf("MaintenanceSites() = %v, want foo.com and bar.com", sites)
+	}
+
+	if err := bm.ClearMaintenance("bar.com"); err != nil {
+		t.Fatalf("ClearMaintenance() error = %v", err)
+	}
+	if err := bm.ClearMaintenance("never.com"); err != nil {
+		t.Errorf("ClearMaintenance(not in maintenance) error = %v", err)
+	}
+	sites, _ = bm.MaintenanceSites(now)
+	if _, ok := sites["bar.com"]; ok {
+		t.Error("bar.com still in maintenance after ClearMaintenance")
+	}
+}
+
+func TestMaintenanceHiddenFromBackups(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.SetMaintenance(MaintenanceEntry{Site: "foo.com"}); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/foo.com/foo-1.tgz", "data")
+
+	objs, err := bm.ListBackups("", 0)
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(objs) != 1 || objs[0].Key != "backups/foo.com/foo-1.tgz" {
+		t.Errorf("ListBackups() = %v, want only the backup", objs)
+	}
+	if !isInternalObject(maintenanceKey("foo.com")) {
+		t.Error("maintenance flags must be internal objects")
+	}
+}
+
+func TestExcludeMaintenance(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.SetMaintenance(MaintenanceEntry{Site: "foo.com"}); err != nil {
+		t.Fatal(err)
+	}
+	objs := []ObjectInfo{
+		{Key: "backups/foo.com/foo-1.tgz"},
+		{Key: "backups/foo.com/foo-2.tgz"},
+		{Key: "backups/bar.com/bar-1.tgz"},
+	}
+	kept, err := bm.ExcludeMaintenance(objs)
+	if err != nil {
+		t.Fatalf("ExcludeMaintenance() error = %v", err)
+	}
+	if len(kept) != 1 || kept[0].Key != "backups/bar.com/bar-1.tgz" {
+		t.Errorf("ExcludeMaintenance() = %v, want only bar.com", kept)
+	}
+}
+
+func TestSkipMaintenance(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.SetMaintenance(MaintenanceEntry{Site: "foo.com", Reason: "migrating"}); err != nil {
+		t.Fatal(err)
+	}
+	bm.lastRun = &RunRecord{}
+	containers := []ContainerInfo{
+		{Name: "wp_foo", WorkingDir: "/var/opt/sites/foo.com"},
+		{Name: "wp_bar", WorkingDir: "/var/opt/sites/bar.com"},
+	}
+	kept := bm.skipMaintenance(containers)
+	if len(kept) != 1 || kept[0].Name != "wp_bar" {
+		t.Errorf("skipMaintenance() = %v, want only wp_bar", kept)
+	}
+	if containers[0].Name != "wp_foo" {
+		t.Error("skipMaintenance() modified its input")
+	}
+	skipped := bm.lastRun.Skipped
+	if len(skipped) != 1 || skipped[0].Container != "wp_foo" || skipped[0].Reason != SkipReasonMaintenance {
+		t.Errorf("Skipped = %+v", skipped)
+	}
+	if !strings.Contains(skipped[0].Detail, "migrating") {
+		t.Errorf("Skipped detail = %q, want the reason", skipped[0].Detail)
+	}
+}
+
+func TestMigrateSkipsMaintenance(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.SetMaintenance(MaintenanceEntry{Site: "foo.com"}); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/foo.com/foo-1.tgz", "data")
+	// Deleting is the simplest bucket-wide relief that needs no Glacier.
+	if err := bm.DeleteOldestBackups(100, false); err != nil {
+		t.Fatalf("DeleteOldestBackups() error = %v", err)
+	}
+	if got := listKeys(t, bm); len(got) != 1 || got[0] != "backups/foo.com/foo-1.tgz" {
+		t.Errorf("objects after DeleteOldestBackups() = %v, want the site in maintenance untouched", got)
+	}
+	if _, err := bm.statObject(context.Background(), maintenanceKey("foo.com")); err != nil {
+		t.Errorf("maintenance flag removed: %v", err)
+	}
+}
@@ -12,9 +12,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -27,12 +30,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/glacier"
 	"github.com/aws/smithy-go/middleware"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
 
 	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/output"
 )
 
 // ProgressReader wraps an io.Reader and reports progress
@@ -105,6 +108,31 @@ type MinioConfig struct {
 	// HTTPTimeout is an optional overall timeout for the HTTP client used by the Minio SDK.
 	// Zero means no timeout (requests can run indefinitely).
 	HTTPTimeout time.Duration
+	// CACertFile is an optional PEM bundle used to verify the Minio server
+	// certificate (e.g. an internal/private CA).
+	CACertFile string
+	// ClientCertFile and ClientKeyFile enable mutual TLS when both are set.
+	ClientCertFile string
+	ClientKeyFile  string
+	// InsecureSkipVerify disables certificate verification. Last resort only.
+	InsecureSkipVerify bool
+	// SSHTunnel, when set, routes all Minio traffic through an SSH connection to
+	// this host (e.g. the storage server), encrypting plaintext HTTP endpoints on
+	// a private LAN. Endpoint is resolved from the SSH host's side.
+	SSHTunnel *auth.SSHConfig
+	// Listing tunes sharding, rate limiting and caching of bucket listings.
+	Listing ListingOptions
+	// PartSize is the multipart part size of uploads; zero lets the SDK
+	// choose. UploadConcurrency uploads that many parts at once, buffering
+	// PartSize bytes in memory for each; zero or one uploads them in turn.
+	PartSize          int64
+	UploadConcurrency int
+	// StorageClasses uploads backups with the storage class of their
+	// retention tier on S3 backends; nil leaves the bucket's default.
+	StorageClasses *StorageClassPolicy
+	// Region is the region of the bucket for residency checks; empty asks
+	// the server. Set it when the server does not report the real one.
+	Region string
 }
 
 type AWSConfig struct {
@@ -113,9 +141,26 @@ type AWSConfig struct {
 	AccessKey string
 	SecretKey string
 	Region    string
+	// Auth selects the credential source, AWSAuthStatic or AWSAuthChain;
+	// empty uses the keys when set and the default chain otherwise.
+	Auth string
 	// HTTPTimeout is an optional overall timeout for the AWS HTTP client.
 	// Zero means no timeout (requests can run indefinitely).
 	HTTPTimeout time.Duration
+	// CACertFile is an optional PEM bundle used to verify AWS endpoints
+	// (useful behind TLS-intercepting proxies).
+	CACertFile string
+	// ClientCertFile and ClientKeyFile present a client certificate when both are set.
+	ClientCertFile string
+	ClientKeyFile  string
+	// InsecureSkipVerify disables certificate verification. Last resort only.
+	InsecureSkipVerify bool
+	// PartSize is the multipart part size used when migrating objects from
+	// Minio; zero uses DefaultGlacierPartSize.
+	PartSize int64
+	// VerifyChecksums re-hashes the buffered data after every Glacier upload
+	// and fails the upload when it does not match the checksum AWS returned.
+	VerifyChecksums bool
 }
 
 type BackupOptions struct {
@@ -149,12 +194,59 @@ type BackupOptions struct {
 	CapacityThreshold float64
 	// IncludeAWSGlacier enables uploading backups to AWS Glacier in addition to Minio
 	IncludeAWSGlacier bool
-	// EstimateMethod specifies compression estimation for dry-run: "heuristic", "sample", or "accurate"
+	// EstimateMethod specifies compression estimation for dry-run: "heuristic", "sample", "adaptive" or "accurate"
 	EstimateMethod string
 	// SampleSize specifies the number of bytes to sample for "sample" estimation method
 	SampleSize int64
 	// SmartRetention enables date-aware retention policy (preserves weekly/monthly backups)
 	SmartRetention *SmartRetentionPolicy
+	// UploadSemaphore optionally limits concurrent uploads fleet-wide via Minio lock objects
+	UploadSemaphore *UploadSemaphoreConfig
+	// SkipFacts disables recording runtime facts (image, PHP/WP versions, kernel) in the backup
+	SkipFacts bool
+	// SkipOffloadedUploads leaves wp-content/uploads out of WordPress backups
+	// when an active media offload plugin already keeps the media in object storage
+	SkipOffloadedUploads bool
+	// ExcludeGit leaves the .git directory of sites deployed from git out of
+	// the archive; the commit is still recorded with the runtime facts
+	ExcludeGit bool
+	// Window optionally forbids running inside blackout time ranges
+	Window *BackupWindow
+	// WindowAction is what to do when started inside a blackout: "abort" (default) or "wait"
+	WindowAction string
+	// ResumeFile persists the remaining containers when a run pauses at a blackout,
+	// and is consumed by the next run. Empty disables resume tokens.
+	ResumeFile string
+	// Discovery selects how sites are found when no containers are given:
+	// DiscoveryCompose (default) or DiscoveryPrefix.
+	Discovery string
+	// PostUploadCheck validates each tarball right after it is uploaded:
+	// PostUploadCheckNone (default), PostUploadCheckQuick or PostUploadCheckFull.
+	PostUploadCheck string
+	// PendingUploadsFile queues destinations a dual upload missed for
+	// `backup retry-pending`. Empty disables the queue.
+	PendingUploadsFile string
+	// HostConfig captures crontabs and host config paths into a side
+	// archive next to each backup. Nil disables the capture.
+	HostConfig *HostConfig
+	// TarWarningThreshold is how many tar warnings make the run complete
+	// with warnings; 0 never does.
+	TarWarningThreshold int
+	// Timeouts bound the export, tar and upload stages of each container
+	// and its whole backup. A container that runs past one is aborted,
+	// cleaned up and marked failed, and the run goes on with the next.
+	Timeouts StageTimeouts
+	// ExportFreeze freezes WordPress sites while their database is
+	// exported: none (default), maintenance or plugin:<slug>. A container's
+	// export_freeze config overrides it.
+	ExportFreeze string
+	// NestedArchiveThreshold is the size from which archives inside a site
+	// directory (earlier backups, full exports) are reported and left out
+	// of its backup; 0 disables the check.
+	NestedArchiveThreshold int64
+	// IncludeNestedArchives keeps the archives found by the check in the
+	// backup; they are still reported.
+	IncludeNestedArchives bool
 }
 
 // SmartRetentionPolicy defines intelligent backup retention based on backup dates
@@ -175,6 +267,8 @@ type SanitizeOptions struct {
 	ExtractDirs  []string // Directories to extract from tarball
 	ExtractFiles []string // File patterns to extract (e.g., *.sql)
 	DryRun       bool     // Preview mode without making changes
+	// Rules selects what is removed; nil uses DefaultSanitizeRules.
+	Rules *SanitizeRules
 }
 
 // StorageCapacity represents disk usage statistics
@@ -193,6 +287,15 @@ type ContainerInfo struct {
 	Type string
 	// Config holds custom configuration from YAML file
 	Config *ContainerConfig
+	// Project is the docker compose project the container belongs to, when
+	// discovered by compose labels.
+	Project string
+	// Services lists the other containers of the compose stack (cache, cron,
+	// database); they are stopped together with the site on --delete.
+	Services []string
+	// ParentDir replaces BackupOptions.ParentDir for this container when set
+	// by a container override.
+	ParentDir string
 }
 
 // DefaultLicenseKeysToRemove is the default list of WordPress option names
@@ -217,6 +320,13 @@ type CapacityEstimateOptions struct {
 	WeeklyRetention  int
 	MonthlyRetention int
 
+	// MigrateAfterDays models the Glacier migration cadence: daily backups
+	// older than that many days live in Glacier instead of Minio (0 = all
+	// daily backups stay hot). AggressiveMigrateAfterDays is the cadence
+	// compared against it (default: DefaultAggressiveMigrateAfterDays).
+	MigrateAfterDays           int
+	AggressiveMigrateAfterDays int
+
 	// Growth modeling
 	GrowthRate       float64 // Monthly growth percentage
 	ProjectionMonths int
@@ -229,6 +339,14 @@ type CapacityEstimateOptions struct {
 	// Baseline data (one of these will be set)
 	AvgCompressedSize int64 // Manual input in bytes
 	SiteCount         int
+
+	// Parallelism is the number of containers analyzed at once during a scan
+	// (<= 1 analyzes them one at a time).
+	Parallelism int
+
+	// Erasure is the erasure coding of the Minio deployment; hot storage is
+	// sized on disk including its parity when set.
+	Erasure *ErasureCoding
 }
 
 // SiteEstimate represents capacity estimates for a single site
@@ -237,9 +355,11 @@ type SiteEstimate struct {
 	UncompressedSize int64   `json:"uncompressed_size"`
 	CompressedSize   int64   `json:"compressed_size"`
 	CompressionRatio float64 `json:"compression_ratio"`
-	HotStorageSize   int64   `json:"hot_storage_size"`  // Daily backups in Minio
-	ColdStorageSize  int64   `json:"cold_storage_size"` // Weekly+Monthly in Glacier
-	TotalStorageSize int64   `json:"total_storage_size"`
+	// Sample describes how an adaptive estimate was reached.
+	Sample           *SampleEstimate `json:"sample,omitempty"`
+	HotStorageSize   int64           `json:"hot_storage_size"`  // Daily backups in Minio
+	ColdStorageSize  int64           `json:"cold_storage_size"` // Weekly+Monthly in Glacier
+	TotalStorageSize int64           `json:"total_storage_size"`
 }
 
 // CapacityEstimate represents the complete capacity estimation results
@@ -254,11 +374,18 @@ type CapacityEstimate struct {
 	MonthlyRetention    int `json:"monthly_retention"`
 	TotalBackupsPerSite int `json:"total_backups_per_site"`
 
+	// Glacier migration cadence and the backups per site it keeps hot
+	MigrateAfterDays  int `json:"migrate_after_days,omitempty"`
+	HotBackupsPerSite int `json:"hot_backups_per_site"`
+
 	// Baseline measurements
 	AvgUncompressedSize int64   `json:"avg_uncompressed_size"`
 	AvgCompressedSize   int64   `json:"avg_compressed_size"`
 	AvgCompressionRatio float64 `json:"avg_compression_ratio"`
 
+	// Erasure coding applied to hot storage sizes, if any
+	Erasure *ErasureCoding `json:"erasure_coding,omitempty"`
+
 	// Per-site storage requirements
 	PerSiteHotStorage   int64 `json:"per_site_hot_storage"`
 	PerSiteColdStorage  int64 `json:"per_site_cold_storage"`
@@ -280,6 +407,9 @@ type CapacityEstimate struct {
 	MonthlyCost        float64 `json:"monthly_cost,omitempty"`
 	RetrievalCost10Pct float64 `json:"retrieval_cost_10pct,omitempty"`
 
+	// Storage under other migration cadences
+	Scenarios []CapacityScenario `json:"scenarios,omitempty"`
+
 	// Per-site details
 	Sites []SiteEstimate `json:"sites,omitempty"`
 }
@@ -296,10 +426,100 @@ type GrowthProjection struct {
 type BackupManager struct {
 	sshClient   *auth.SSHClient
 	minioClient *minio.Client
-	minioConfig *MinioConfig
-	awsClient   *glacier.Client
-	awsConfig   *AWSConfig
-	verbosity   int // 0=quiet, 1=normal, 2=verbose, 3=debug, 4=trace
+	// dockerEndpoint runs host commands over a Docker API when there is no
+	// SSH client (see SetDockerEndpoint).
+	dockerEndpoint *DockerEndpoint
+	fileStore      *fileStore
+	minioConfig    *MinioConfig
+	awsClient      *glacier.Client
+	awsConfig      *AWSConfig
+	// awsCredentialSource describes where initAWSClient found the AWS
+	// credentials; see AWSCredentialSource.
+	awsCredentialSource string
+	verbosity           int // 0=quiet, 1=normal, 2=verbose, 3=debug, 4=trace
+
+	// lastRun holds the outcome of the most recent CreateBackups call.
+	lastRun *RunRecord
+	// compressionModel drives the heuristic estimator (defaults when nil).
+	compressionModel *CompressionModel
+	// capacityAlerts configures warning alerts and metrics for the storage monitor.
+	capacityAlerts *CapacityAlertConfig
+	// minioTunnel carries Minio traffic when MinioConfig.SSHTunnel is set.
+	minioTunnel *auth.SSHClient
+	// containerOverrides map containers to sites before docker inspection.
+	containerOverrides ContainerOverrides
+	// listingDirty is set once this manager writes to the bucket, so later
+	// listings bypass the listing cache.
+	listingDirty atomic.Bool
+	// summaryDirty holds the prefixes written to or deleted from since the
+	// last FlushBucketSummary.
+	summaryMu    sync.Mutex
+	summaryDirty map[string]bool
+	// listPacerShared rate-limits LIST requests across all listings; it is
+	// created by listPacerOnce.
+	listPacerOnce   sync.Once
+	listPacerShared *listPacer
+	// status tracks the running CreateBackups call for RunStatus.
+	status runTracker
+	// replica is the DR copy of the bucket that deletions may propagate to.
+	replica          *BackupManager
+	replicaName      string
+	propagateDeletes bool
+	// staging is the tier objects are parked in between leaving the hot
+	// bucket and reaching Glacier; see SetStaging.
+	staging staging
+	// readCache holds copies of the latest backups that downloads are
+	// served from when present; see SetReadCache.
+	readCache *BackupManager
+	// compression holds the per-destination codecs; see SetCompression.
+	compression compressionSettings
+	// archiveOrder is the order tar writes entries in; see SetArchiveOrder.
+	archiveOrder string
+	// scans holds the file scans the phases of a backup share for up to
+	// scanCacheTTL; see SetScanCacheTTL.
+	scans        scanCache
+	scanCacheTTL time.Duration
+	// migratePerSite caps each site's share of a migration round; see
+	// SetMigrationFairness.
+	migratePerSite int
+	// requireDestinations is the success policy of dual uploads; see
+	// SetRequiredDestinations.
+	requireDestinations string
+	// tempHeadroom and tempMaxWait configure the temp space budget; see
+	// SetTempBudget.
+	tempHeadroom int64
+	tempMaxWait  time.Duration
+	// pendingDeletesFile lists objects migrated to Glacier whose delete from
+	// Minio failed; see SetPendingDeletesFile.
+	pendingDeletesFile string
+	// group limits backup runs to the sites of a --group selection on
+	// groupHost; nil backs up every site.
+	group     *FleetGroup
+	groupHost string
+	// features are the rollout features of the host; see SetFeatures.
+	features Features
+	// storageClasses overrides MinioConfig.StorageClasses with the retention
+	// calendar of the run (see SetRetentionCalendar).
+	storageClasses *StorageClassPolicy
+	// siteKeys caches the object key names of sites; see ResolveSiteKey.
+	siteKeys siteKeyCache
+	// audit records the shell commands run on hosts; see SetCommandAudit.
+	audit *commandAudit
+	// sampleTarget and sampleMax bound the adaptive estimation method; see
+	// SetAdaptiveSampling.
+	sampleTarget float64
+	sampleMax    int64
+	// dryRun refuses every storage write; see SetDryRun.
+	dryRun bool
+	// stages holds the deadlines of the container being backed up; see
+	// BackupOptions.Timeouts.
+	stages stageClock
+	// residency restricts where the backups of sites may be written; see
+	// SetResidency.
+	residency residencyGuard
+	// spool, when set, receives archives in place of the bucket; see
+	// SetSpool.
+	spool *Spool
 }
 
 // ObjectInfo is a lightweight representation of an object in Minio
@@ -307,6 +527,7 @@ type ObjectInfo struct {
 	Key          string    `json:"key"`
 	Size         int64     `json:"size"`
 	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag,omitempty"`
 }
 
 func NewBackupManager(sshClient *auth.SSHClient, minioConfig *MinioConfig) *BackupManager {
@@ -353,6 +574,11 @@ func (bm *BackupManager) logTrace(format string, args ...interface{}) {
 	}
 }
 
+// SetCompressionModel sets the per-extension ratios used by heuristic estimation.
+func (bm *BackupManager) SetCompressionModel(m *CompressionModel) {
+	bm.compressionModel = m
+}
+
 // GetBucketPath returns the configured bucket path prefix
 func (bm *BackupManager) GetBucketPath() string {
 	if bm.minioConfig == nil {
@@ -362,49 +588,54 @@ func (bm *BackupManager) GetBucketPath() string {
 }
 
 // executeCommand runs a shell command either over SSH (when sshClient is present)
-// or locally (when sshClient is nil). It returns stdout, stderr and any error.
-func (bm *BackupManager) executeCommand(cmd string) (string, string, error) {
+// or locally (when sshClient is nil), where a Docker endpoint carries it to
+// its host. It returns stdout, stderr and any error. While a container's
+// backup has a deadline (BackupOptions.Timeouts) the command is killed at it.
+func (bm *BackupManager) executeCommand(cmd string) (stdout, stderr string, err error) {
+	defer func(started time.Time) { bm.auditCommand(cmd, started, stdout, stderr, err) }(time.Now())
+	if deadline, timeoutErr := bm.stages.deadline(); !deadline.IsZero() {
+		return bm.runCommandUntil(cmd, deadline, timeoutErr)
+	}
 	if bm.sshClient == nil {
-		c := exec.Command("bash", "-lc", cmd)
-		var out bytes.Buffer
-		var stderr bytes.Buffer
+		c := bm.shellCommand(cmd)
+		var out, errOut bytes.Buffer
 		c.Stdout = &out
-		c.Stderr = &stderr
+		c.Stderr = &errOut
 		err := c.Run()
-		return out.String(), stderr.String(), err
+		return out.String(), errOut.String(), err
 	}
 	return bm.sshClient.ExecuteCommand(cmd)
 }
 
 func (bm *BackupManager) initMinioClient() error {
-	if bm.minioClient != nil {
+	if bm.minioClient != nil || bm.fileStore != nil {
 		return nil
 	}
+	if bm.minioConfig == nil {
+		return fmt.Errorf("minio configuration is required")
+	}
 
-	// Create custom transport for Minio client using configured timeouts.
-	dialer := &net.Dialer{
-		Timeout:   60 * time.Second,
-		KeepAlive: 30 * time.Second,
+	if IsFileEndpoint(bm.minioConfig.Endpoint) {
+		store, err := newFileStore(bm.minioConfig.Endpoint)
+		if err != nil {
+			return err
+		}
+		bm.fileStore = store
+		return nil
 	}
-	tr := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialer.DialContext,
-		TLSHandshakeTimeout:   5 * time.Minute,
-		ExpectContinueTimeout: 1 * time.Second,
-		IdleConnTimeout:       5 * time.Minute,
-		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   100,
-		// ResponseHeaderTimeout controls how long to wait for a server's
-		// response headers after writing the request. When non-zero, it will
-		// help prevent long stalls waiting for headers; leave zero for no timeout.
+
+	ep, err := ParseMinioEndpoint(bm.minioConfig.Endpoint, bm.minioConfig.UseSSL)
+	if err != nil {
+		return err
 	}
-	if bm.minioConfig.HTTPTimeout > 0 {
-		tr.ResponseHeaderTimeout = bm.minioConfig.HTTPTimeout
+	tr, err := bm.minioTransport(ep.Secure)
+	if err != nil {
+		return err
 	}
 
-	client, err := minio.New(bm.minioConfig.Endpoint, &minio.Options{
+	client, err := minio.New(ep.Address(), &minio.Options{
 		Creds:     credentials.NewStaticV4(bm.minioConfig.AccessKey, bm.minioConfig.SecretKey, ""),
-		Secure:    bm.minioConfig.UseSSL,
+		Secure:    ep.Secure,
 		Transport: tr,
 	})
 	if err != nil {
@@ -421,12 +652,58 @@ func (bm *BackupManager) initMinioClient() error {
 	}
 
 	if !exists {
-		return fmt.Errorf("bucket %s does not exist", bm.minioConfig.Bucket)
+		return fmt.Errorf("%w: %s", ErrBucketNotFound, bm.minioConfig.Bucket)
 	}
 
 	return nil
 }
 
+// minioTransport builds the HTTP transport used for Minio requests from the
+// configured timeouts, SSH tunnel and TLS settings. secure is whether the
+// endpoint uses TLS.
+func (bm *BackupManager) minioTransport(secure bool) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   60 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Minute,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       5 * time.Minute,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   100,
+		// ResponseHeaderTimeout controls how long to wait for a server's
+		// response headers after writing the request. When non-zero, it will
+		// help prevent long stalls waiting for headers; leave zero for no timeout.
+	}
+	if bm.minioConfig.HTTPTimeout > 0 {
+		tr.ResponseHeaderTimeout = bm.minioConfig.HTTPTimeout
+	}
+	if bm.minioConfig.SSHTunnel != nil {
+		tunnel, err := bm.openMinioTunnel()
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = nil
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return tunnel.Dial(network, addr)
+		}
+	}
+	if secure {
+		tlsConfig, err := buildTLSConfig("Minio", bm.minioConfig.CACertFile, bm.minioConfig.ClientCertFile,
+			bm.minioConfig.ClientKeyFile, bm.minioConfig.InsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig = tlsConfig
+	} else if bm.minioConfig.CACertFile != "" || bm.minioConfig.ClientCertFile != "" || bm.minioConfig.InsecureSkipVerify {
+		fmt.Fprintln(os.Stderr, "⚠️  Warning: Minio TLS options are ignored because SSL is disabled (--minio-ssl=false)")
+	}
+	return tr, nil
+}
+
 func (bm *BackupManager) TestMinioConnection() error {
 	if err := bm.initMinioClient(); err != nil {
 		return err
@@ -435,15 +712,19 @@ func (bm *BackupManager) TestMinioConnection() error {
 	ctx := context.Background()
 
 	// Step 1: Test bucket existence
-	fmt.Printf("1. Testing bucket existence...\n")
-	exists, err := bm.minioClient.BucketExists(ctx, bm.minioConfig.Bucket)
-	if err != nil {
-		return fmt.Errorf("failed to check bucket existence: %w", err)
-	}
-	if !exists {
-		return fmt.Errorf("bucket '%s' does not exist", bm.minioConfig.Bucket)
+	if bm.fileStore != nil {
+		fmt.Printf("1. Using filesystem backend at %s\n\n", bm.fileStore.root)
+	} else {
+		fmt.Printf("1. Testing bucket existence...\n")
+		exists, err := bm.minioClient.BucketExists(ctx, bm.minioConfig.Bucket)
+		if err != nil {
+			return fmt.Errorf("failed to check bucket existence: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrBucketNotFound, bm.minioConfig.Bucket)
+		}
+		fmt.Printf("   ✓ Bucket '%s' exists\n\n", bm.minioConfig.Bucket)
 	}
-	fmt.Printf("   ✓ Bucket '%s' exists\n\n", bm.minioConfig.Bucket)
 
 	// Step 2: Test write operation
 	fmt.Printf("2. Testing write operation...\n")
@@ -456,18 +737,16 @@ func (bm *BackupManager) TestMinioConnection() error {
 
 	testContent := []byte("This is a connection test file created by ciwg-cli")
 
-	info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, testObjectName,
-		strings.NewReader(string(testContent)), int64(len(testContent)), minio.PutObjectOptions{
-			ContentType: "text/plain",
-		})
+	written, err := bm.putObject(ctx, testObjectName,
+		strings.NewReader(string(testContent)), int64(len(testContent)), "text/plain", nil)
 	if err != nil {
 		return fmt.Errorf("failed to write test object: %w", err)
 	}
-	fmt.Printf("   ✓ Successfully wrote test object '%s' (%d bytes)\n\n", testObjectName, info.Size)
+	fmt.Printf("   ✓ Successfully wrote test object '%s' (%d bytes)\n\n", testObjectName, written)
 
 	// Step 3: Test read operation
 	fmt.Printf("3. Testing read operation...\n")
-	object, err := bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, testObjectName, minio.GetObjectOptions{})
+	object, err := bm.getObject(ctx, testObjectName)
 	if err != nil {
 		return fmt.Errorf("failed to read test object: %w", err)
 	}
@@ -488,7 +767,7 @@ func (bm *BackupManager) TestMinioConnection() error {
 
 	// Step 4: Test delete operation
 	fmt.Printf("4. Testing delete operation...\n")
-	err = bm.minioClient.RemoveObject(ctx, bm.minioConfig.Bucket, testObjectName, minio.RemoveObjectOptions{})
+	err = bm.removeObject(ctx, testObjectName)
 	if err != nil {
 		return fmt.Errorf("failed to delete test object: %w", err)
 	}
@@ -527,6 +806,13 @@ func (bm *BackupManager) initAWSClient() error {
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   100,
 	}
+	tlsConfig, err := buildTLSConfig("AWS", bm.awsConfig.CACertFile, bm.awsConfig.ClientCertFile,
+		bm.awsConfig.ClientKeyFile, bm.awsConfig.InsecureSkipVerify)
+	if err != nil {
+		bm.logDebug("Failed to build AWS TLS config: %v", err)
+		return err
+	}
+	tr.TLSClientConfig = tlsConfig
 	var httpClient *http.Client
 	if bm.awsConfig.HTTPTimeout > 0 {
 		bm.logDebug("Using AWS HTTP timeout: %s", bm.awsConfig.HTTPTimeout)
@@ -536,20 +822,24 @@ func (bm *BackupManager) initAWSClient() error {
 		httpClient = &http.Client{Transport: tr}
 	}
 
-	bm.logTrace("Loading AWS default config")
-	cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+	mode := bm.awsConfig.AuthMode()
+	bm.logTrace("Loading AWS default config (credentials: %s)", mode)
+	opts := append([]func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(bm.awsConfig.Region),
-		awsconfig.WithCredentialsProvider(awscredentials.NewStaticCredentialsProvider(
-			bm.awsConfig.AccessKey,
-			bm.awsConfig.SecretKey,
-			"",
-		)),
 		awsconfig.WithHTTPClient(httpClient),
-	)
+	}, bm.awsConfig.credentialOptions()...)
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
 	if err != nil {
 		bm.logDebug("Failed to load AWS config: %v", err)
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	source, err := awsCredentialSource(context.Background(), cfg, mode)
+	if err != nil {
+		bm.logDebug("Failed to retrieve AWS credentials: %v", err)
+		return err
+	}
+	bm.awsCredentialSource = source
+	bm.logVerbose("AWS credentials: %s (%s)", source, mode)
 
 	bm.logTrace("Creating Glacier client from config")
 	bm.awsClient = glacier.NewFromConfig(cfg)
@@ -646,43 +936,62 @@ type hashChunk [sha256.Size]byte
 // hashing each chunk, then building a binary tree of hashes
 func computeTreeHash(data []byte) string {
 	const chunkSize = 1024 * 1024 // 1 MB
-	var chunks []hashChunk
+	var tree treeHasher
 	for i := 0; i < len(data); i += chunkSize {
 		end := i + chunkSize
 		if end > len(data) {
 			end = len(data)
 		}
-		chunkHash := sha256.Sum256(data[i:end])
-		chunks = append(chunks, chunkHash)
+		tree.add(sha256.Sum256(data[i:end]))
 	}
-	return computeTreeHashFromChunks(chunks)
+	return tree.sum()
 }
 
 func computeTreeHashFromChunks(hashes []hashChunk) string {
-	if len(hashes) == 0 {
-		empty := sha256.Sum256(nil)
-		return hex.EncodeToString(empty[:])
+	var h treeHasher
+	for _, c := range hashes {
+		h.add(c)
 	}
+	return h.sum()
+}
+
+// treeHasher computes a Glacier tree hash from its 1 MiB chunk hashes as
+// they arrive. It keeps one hash per complete subtree, so memory stays
+// logarithmic in the archive size rather than one hash per chunk.
+type treeHasher struct {
+	nodes  []hashChunk
+	levels []int
+}
 
-	current := make([]hashChunk, len(hashes))
-	copy(current, hashes)
+func (h *treeHasher) add(c hashChunk) {
+	h.nodes, h.levels = append(h.nodes, c), append(h.levels, 0)
+	for n := len(h.nodes); n > 1 && h.levels[n-1] == h.levels[n-2]; n-- {
+		h.nodes[n-2] = combineTreeHashes(h.nodes[n-2], h.nodes[n-1])
+		h.levels[n-2]++
+		h.nodes, h.levels = h.nodes[:n-1], h.levels[:n-1]
+	}
+}
 
-	for len(current) > 1 {
-		var next []hashChunk
-		for i := 0; i < len(current); i += 2 {
-			if i+1 < len(current) {
-				var combined [sha256.Size * 2]byte
-				copy(combined[:sha256.Size], current[i][:])
-				copy(combined[sha256.Size:], current[i+1][:])
-				next = append(next, sha256.Sum256(combined[:]))
-			} else {
-				next = append(next, current[i])
-			}
-		}
-		current = next
+// sum returns the tree hash of the chunks added so far. Subtrees left over
+// are joined right to left, which is where pairing level by level (with an
+// odd hash out carried up) joins them too.
+func (h *treeHasher) sum() string {
+	if len(h.nodes) == 0 {
+		empty := sha256.Sum256(nil)
+		return hex.EncodeToString(empty[:])
 	}
+	root := h.nodes[len(h.nodes)-1]
+	for i := len(h.nodes) - 2; i >= 0; i-- {
+		root = combineTreeHashes(h.nodes[i], root)
+	}
+	return hex.EncodeToString(root[:])
+}
 
-	return hex.EncodeToString(current[0][:])
+func combineTreeHashes(left, right hashChunk) hashChunk {
+	var combined [sha256.Size * 2]byte
+	copy(combined[:sha256.Size], left[:])
+	copy(combined[sha256.Size:], right[:])
+	return sha256.Sum256(combined[:])
 }
 
 func computeHashesFromFile(f *os.File) (string, string, int64, error) {
@@ -692,7 +1001,7 @@ func computeHashesFromFile(f *os.File) (string, string, int64, error) {
 
 	const chunkSize = 1024 * 1024
 	linear := sha256.New()
-	var chunks []hashChunk
+	var tree treeHasher
 	buf := make([]byte, chunkSize)
 	var total int64
 
@@ -700,8 +1009,7 @@ func computeHashesFromFile(f *os.File) (string, string, int64, error) {
 		n, err := f.Read(buf)
 		if n > 0 {
 			linear.Write(buf[:n])
-			chunk := sha256.Sum256(buf[:n])
-			chunks = append(chunks, chunk)
+			tree.add(sha256.Sum256(buf[:n]))
 			total += int64(n)
 		}
 		if err == io.EOF {
@@ -716,7 +1024,7 @@ func computeHashesFromFile(f *os.File) (string, string, int64, error) {
 		return "", "", total, fmt.Errorf("failed to reset file pointer after hashing: %w", err)
 	}
 
-	treeHash := computeTreeHashFromChunks(chunks)
+	treeHash := tree.sum()
 	linearHash := hex.EncodeToString(linear.Sum(nil))
 	return treeHash, linearHash, total, nil
 }
@@ -725,11 +1033,35 @@ func computeHashesFromFile(f *os.File) (string, string, int64, error) {
 // For streaming data, we need to buffer it first because Glacier requires
 // calculating a tree-hash checksum which needs seekable data
 func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size int64) error {
+	_, err := bm.uploadToAWS(objectName, reader, size)
+	return err
+}
+
+// uploadToAWS implements UploadToAWS and additionally reports how long each
+// phase (buffering, checksumming, uploading) took.
+func (bm *BackupManager) uploadToAWS(objectName string, reader io.Reader, size int64) (*GlacierUploadStats, error) {
+	tmp, err := bm.reserveTemp("Glacier buffer of "+objectName, max(size, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.release()
+	return bm.uploadToAWSReserved(objectName, reader, size, tmp)
+}
+
+// uploadToAWSReserved is uploadToAWS buffering into temp space reserved by
+// the caller.
+func (bm *BackupManager) uploadToAWSReserved(objectName string, reader io.Reader, size int64, tmp *tempReservation) (*GlacierUploadStats, error) {
 	bm.logDebug("UploadToAWS called with objectName=%s, size=%d", objectName, size)
+	if err := bm.guardWrite("upload to Glacier", objectName); err != nil {
+		return nil, err
+	}
+	if err := bm.checkObjectResidency(context.Background(), "upload", objectName, ResidencyGlacier); err != nil {
+		return nil, err
+	}
 
 	if err := bm.initAWSClient(); err != nil {
 		bm.logDebug("Failed to initialize AWS client: %v", err)
-		return err
+		return nil, err
 	}
 	bm.logTrace("AWS client initialized successfully")
 
@@ -769,14 +1101,15 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 			tmpFile, err = os.CreateTemp(tmpDir, "glacier-upload-*.tmp")
 			if err != nil {
 				bm.logDebug("Failed to create temp file after cleanup: %v", err)
-				return fmt.Errorf("failed to create temporary file after cleanup: %w", err)
+				return nil, fmt.Errorf("failed to create temporary file after cleanup: %w", err)
 			}
 			bm.logVerbose("Successfully created temp file after cleanup: %s", tmpFile.Name())
 		} else {
-			return fmt.Errorf("failed to create temporary file: %w", err)
+			return nil, fmt.Errorf("failed to create temporary file: %w", err)
 		}
 	}
 	bm.logVerbose("Created temporary buffer file: %s", tmpFile.Name())
+	tmp.track(tmpFile.Name())
 	// Ensure the file is closed and removed. We remove explicitly after upload
 	// completes successfully to free space immediately.
 	defer func() {
@@ -811,9 +1144,9 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 				bm.logVerbose("Cleanup removed %d files", deleted)
 			}
 			// Can't reliably resume the copy for non-seekable readers, so return an error
-			return fmt.Errorf("failed to buffer data to temporary file (disk full): %w", err)
+			return nil, fmt.Errorf("failed to buffer data to temporary file (disk full): %w", err)
 		}
-		return fmt.Errorf("failed to buffer data to temporary file: %w", err)
+		return nil, fmt.Errorf("failed to buffer data to temporary file: %w", err)
 	}
 	fmt.Printf("      [AWS] Buffered %d bytes (%.2f MB) in %s (%.2f MB/s)\n",
 		written,
@@ -826,7 +1159,7 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 	checksumStartTime := time.Now()
 	treeHash, linearHashHex, fileSize, err := computeHashesFromFile(tmpFile)
 	if err != nil {
-		return fmt.Errorf("failed to calculate checksums: %w", err)
+		return nil, fmt.Errorf("failed to calculate checksums: %w", err)
 	}
 	checksumDuration := time.Since(checksumStartTime)
 	fmt.Printf("      [AWS] Checksums calculated in %s\n", checksumDuration)
@@ -840,7 +1173,7 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 	bm.logTrace("Seeking back to beginning for upload")
 	if _, err := tmpFile.Seek(0, 0); err != nil {
 		bm.logDebug("Seek failed: %v", err)
-		return fmt.Errorf("failed to seek temporary file for upload: %w", err)
+		return nil, fmt.Errorf("failed to seek temporary file for upload: %w", err)
 	}
 
 	fmt.Printf("      [AWS] Initiating upload to Glacier vault '%s'...\n", bm.awsConfig.Vault)
@@ -896,7 +1229,21 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 	if err != nil {
 		fmt.Printf("      [AWS] Upload failed after %s: %v\n", uploadDuration, err)
 		bm.logVerbose("Full error: %+v", err)
-		return fmt.Errorf("failed to upload to AWS Glacier: %w", err)
+		return nil, fmt.Errorf("failed to upload to AWS Glacier: %w", err)
+	}
+
+	var verification string
+	var verifyErr error
+	var verifySeconds float64
+	if bm.awsConfig.VerifyChecksums {
+		fmt.Printf("      [AWS] Verifying archive checksum...\n")
+		verifyStart := time.Now()
+		verifyErr = verifyGlacierBuffer(tmpFile, treeHash, aws.ToString(uploadResult.Checksum))
+		verifySeconds = time.Since(verifyStart).Seconds()
+		verification = GlacierVerified
+		if verifyErr != nil {
+			verification = GlacierChecksumMismatch
+		}
 	}
 
 	// Upload success - remove the temp buffer file immediately
@@ -917,8 +1264,26 @@ func (bm *BackupManager) UploadToAWS(objectName string, reader io.Reader, size i
 		bm.logDebug("Warning: ArchiveId is nil in upload result")
 	}
 
+	stats := &GlacierUploadStats{
+		ArchiveID:       aws.ToString(uploadResult.ArchiveId),
+		TreeHash:        treeHash,
+		Bytes:           fileSize,
+		BufferSeconds:   bufferDuration.Seconds(),
+		ChecksumSeconds: checksumDuration.Seconds(),
+		UploadSeconds:   uploadDuration.Seconds(),
+		UploadMBps:      mbps(fileSize, uploadDuration),
+		Verification:    verification,
+		VerifySeconds:   verifySeconds,
+	}
+	if verifyErr != nil {
+		fmt.Printf("      [AWS] ✗ Checksum verification failed: %v\n", verifyErr)
+		return stats, verifyErr
+	}
+	if verification == GlacierVerified {
+		fmt.Printf("      [AWS] ✓ Archive checksum verified\n")
+	}
 	bm.logDebug("UploadToAWS completed successfully")
-	return nil
+	return stats, nil
 }
 
 // ListAWSBackups lists archives in the AWS Glacier vault
@@ -980,6 +1345,9 @@ func (bm *BackupManager) DeleteAWSObjects(archiveIDs []string) error {
 	if len(archiveIDs) == 0 {
 		return nil
 	}
+	if err := bm.guardWrite("delete Glacier archives", fmt.Sprintf("(%d)", len(archiveIDs))); err != nil {
+		return err
+	}
 
 	ctx := context.Background()
 	accountID := bm.awsConfig.AccountID
@@ -1008,8 +1376,8 @@ func (bm *BackupManager) GetStorageCapacity(path string) (*StorageCapacity, erro
 		path = "/" // Default to root filesystem
 	}
 
-	// Check if we have an SSH client (remote check)
-	if bm.sshClient != nil {
+	// Check if we have an SSH client or Docker endpoint (remote check)
+	if bm.sshClient != nil || bm.dockerEndpoint != nil {
 		return bm.getRemoteStorageCapacity(path)
 	}
 
@@ -1051,7 +1419,7 @@ func (bm *BackupManager) GetStorageCapacity(path string) (*StorageCapacity, erro
 func (bm *BackupManager) getRemoteStorageCapacity(path string) (*StorageCapacity, error) {
 	// Use df command to get disk usage for the path
 	cmd := fmt.Sprintf("df -B1 %s | tail -n 1", path)
-	stdout, stderr, err := bm.sshClient.ExecuteCommand(cmd)
+	stdout, stderr, err := bm.executeCommand(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute df command on remote server: %w (stderr: %s)", err, stderr)
 	}
@@ -1141,40 +1509,72 @@ func (bm *BackupManager) MigrateOldestBackupsToGlacier(percent float64, dryRun b
 		Size         int64
 	}
 
-	var backups []BackupInfo
-	objectCh := bm.minioClient.ListObjects(ctx, bm.minioConfig.Bucket, minio.ListObjectsOptions{
-		Recursive: true,
-	})
+	objects, err := bm.listObjects(ctx, "", 0)
+	if err != nil {
+		return fmt.Errorf("error listing objects: %w", err)
+	}
+	if objects, err = bm.ExcludeMaintenance(objects); err != nil {
+		return fmt.Errorf("failed to read maintenance flags: %w", err)
+	}
+
+	if objects, err = bm.ExcludePendingDeletes(objects); err != nil {
+		return fmt.Errorf("failed to read pending deletes: %w", err)
+	}
 
-	for object := range objectCh {
-		if object.Err != nil {
-			return fmt.Errorf("error listing objects: %w", object.Err)
+	var candidates []ObjectInfo
+	var resident int
+	for _, object := range objects {
+		if isInternalObject(object.Key) || bm.IsStagedKey(object.Key) {
+			continue
 		}
-		backups = append(backups, BackupInfo{
-			Name:         object.Key,
-			LastModified: object.LastModified,
-			Size:         object.Size,
-		})
+		if !bm.residencyAllows(ctx, object.Key, ResidencyGlacier) {
+			resident++
+			continue
+		}
+		candidates = append(candidates, object)
+	}
+	if resident > 0 {
+		fmt.Printf("🔒 Keeping %d backup(s) in Minio: the residency of their site does not allow Glacier\n", resident)
 	}
 
-	if len(backups) == 0 {
+	if len(candidates) == 0 {
 		fmt.Println("No backups found in Minio to migrate.")
 		return nil
 	}
 
 	// Sort backups by date (oldest first)
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].LastModified.Before(backups[j].LastModified)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return BackupTime(candidates[i]).Before(BackupTime(candidates[j]))
 	})
 
 	// Calculate how many backups to migrate
-	numToMigrate := int(math.Ceil(float64(len(backups)) * percent / 100.0))
+	numToMigrate := int(math.Ceil(float64(len(candidates)) * percent / 100.0))
 	if numToMigrate == 0 {
 		fmt.Println("No backups to migrate based on the specified percentage.")
 		return nil
 	}
+	if bm.migratePerSite > 0 {
+		candidates = fairMigrationOrder(candidates, bm.migratePerSite, numToMigrate)
+		if len(candidates) == 0 {
+			fmt.Println("No backups to migrate: every site is down to its newest hot backup.")
+			return nil
+		}
+		if len(candidates) < numToMigrate {
+			fmt.Printf("Only %d of %d backups can be migrated while keeping each site's newest backup hot.\n", len(candidates), numToMigrate)
+		}
+		numToMigrate = len(candidates)
+	}
+
+	backups := make([]BackupInfo, len(candidates))
+	for i, object := range candidates {
+		backups[i] = BackupInfo{Name: object.Key, LastModified: BackupTime(object), Size: object.Size}
+	}
 
-	fmt.Printf("Migrating %d oldest backups (%.1f%%) from Minio to AWS Glacier...\n", numToMigrate, percent)
+	if bm.migratePerSite > 0 {
+		fmt.Printf("Migrating %d oldest backups (%.1f%%) from Minio to AWS Glacier, at most %d per site per round...\n", numToMigrate, percent, bm.migratePerSite)
+	} else {
+		fmt.Printf("Migrating %d oldest backups (%.1f%%) from Minio to AWS Glacier...\n", numToMigrate, percent)
+	}
 	if dryRun {
 		fmt.Println("\n📋 MIGRATION PLAN (no changes will be made):")
 		fmt.Println()
@@ -1188,8 +1588,8 @@ func (bm *BackupManager) MigrateOldestBackupsToGlacier(percent float64, dryRun b
 		backup := backups[i]
 
 		// Format timestamps in both international (ISO 8601) and US formats
-		intlDate := backup.LastModified.Format("2006-01-02 15:04:05 MST")  // International: YYYY-MM-DD
-		usDate := backup.LastModified.Format("01/02/2006 03:04:05 PM MST") // US: MM/DD/YYYY
+		intlDate := FormatTime(backup.LastModified, "2006-01-02 15:04:05 MST")  // International: YYYY-MM-DD
+		usDate := FormatTime(backup.LastModified, "01/02/2006 03:04:05 PM MST") // US: MM/DD/YYYY
 
 		if dryRun {
 			fmt.Printf("\n[%d/%d] WOULD MIGRATE: %s\n", i+1, numToMigrate, backup.Name)
@@ -1198,6 +1598,9 @@ func (bm *BackupManager) MigrateOldestBackupsToGlacier(percent float64, dryRun b
 				float64(backup.Size)/(1024*1024*1024))
 			fmt.Printf("  📅 Modified (Intl): %s\n", intlDate)
 			fmt.Printf("  📅 Modified (US):   %s\n", usDate)
+			if bm.StagingEnabled() {
+				fmt.Printf("  📥 Would stage to:  %s\n", bm.StagingLocation())
+			}
 			fmt.Printf("  📤 Would upload to: AWS Glacier vault '%s'\n", bm.awsConfig.Vault)
 			fmt.Printf("  🗑️  Would delete from: Minio bucket '%s'\n", bm.minioConfig.Bucket)
 			totalFreed += backup.Size
@@ -1211,99 +1614,44 @@ func (bm *BackupManager) MigrateOldestBackupsToGlacier(percent float64, dryRun b
 		fmt.Printf("  📅 Modified (Intl): %s\n", intlDate)
 		fmt.Printf("  📅 Modified (US):   %s\n", usDate)
 
-		// Download from Minio
-		object, err := bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, backup.Name, minio.GetObjectOptions{})
-		if err != nil {
-			fmt.Printf("  ⚠ Failed to download %s from Minio: %v\n", backup.Name, err)
-			continue
-		}
-
-		// Buffer to temporary file (memory-efficient and provides seekable handle for AWS SDK)
-		// This mimics the robust logic from UploadToAWS and allows the SDK to calculate
-		// both x-amz-content-sha256 (linear hash) and x-amz-sha256-tree-hash (tree hash)
-		tmpFile, err := os.CreateTemp("", "glacier-migrate-*.tmp")
-		if err != nil {
-			fmt.Printf("  ⚠ Failed to create temporary file: %v\n", err)
-			object.Close()
-			continue
-		}
-		// Ensure we close and remove the temp file when done
-		defer os.Remove(tmpFile.Name())
-		defer tmpFile.Close()
-
-		// Copy data from Minio stream to the temporary file
-		if _, err := io.Copy(tmpFile, object); err != nil {
-			fmt.Printf("  ⚠ Failed to buffer data to temporary file: %v\n", err)
-			object.Close()
-			continue
-		}
-		object.Close() // Done with the Minio stream
-
-		fmt.Printf("  ℹ️  Calculating checksums for %s...\n", backup.Name)
-		treeHash, linearHashHex, fileSize, err := computeHashesFromFile(tmpFile)
-		if err != nil {
-			fmt.Printf("  ⚠ Failed to calculate checksums: %v\n", err)
+		// Stream to Glacier part by part so temp space never exceeds one part
+		if backup.Size == 0 {
+			fmt.Printf("  ⚠ Skipping empty file: %s\n", backup.Name)
 			continue
 		}
-		fmt.Printf("  ℹ️  File size: %d bytes (%.2f MB)\n", fileSize, float64(fileSize)/(1024*1024))
-
-		// Skip empty files
-		if fileSize == 0 {
-			fmt.Printf("  ⚠ Skipping empty file: %s\n", backup.Name)
+		if bm.StagingEnabled() {
+			if err := bm.StageObject(ObjectInfo{Key: backup.Name, Size: backup.Size, LastModified: backup.LastModified}); err != nil {
+				fmt.Printf("  ⚠ Failed to stage %s: %v\n", backup.Name, err)
+				continue
+			}
+			fmt.Printf("  ✓ Staged to %s and deleted from Minio\n", bm.StagingLocation())
+			totalFreed += backup.Size
+			migratedCount++
+			// Start archiving with the first staged object so uploads overlap
+			// with staging the rest.
+			bm.StartStagingDrain()
 			continue
 		}
 
-		accountID := bm.awsConfig.AccountID
-		if accountID == "" || accountID == "-" {
-			accountID = "-"
-		}
-
-		// Set the payload hash in the context for the AWS signer to use in signature calculation
-		ctx = v4.SetPayloadHash(ctx, linearHashHex)
-
-		// Capture values for closure to avoid variable capture issues
-		contentHash := linearHashHex
-		contentLength := fileSize
-
-		// Upload with explicitly calculated checksums
-		// We need to add the x-amz-content-sha256 header explicitly via middleware
-		uploadResult, err := bm.awsClient.UploadArchive(ctx, &glacier.UploadArchiveInput{
-			VaultName:          aws.String(bm.awsConfig.Vault),
-			AccountId:          aws.String(accountID),
-			ArchiveDescription: aws.String(fmt.Sprintf("Migrated from Minio: %s", backup.Name)),
-			Body:               tmpFile,
-			Checksum:           aws.String(treeHash),
-		}, func(o *glacier.Options) {
-			// Add middleware to set x-amz-content-sha256 header and Content-Length
-			// This is required by Glacier and must match the hash used in signature calculation
-			o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
-				return stack.Build.Add(middleware.BuildMiddlewareFunc(
-					"AddContentSHA256Header",
-					func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (
-						middleware.BuildOutput, middleware.Metadata, error,
-					) {
-						req, ok := in.Request.(*smithyhttp.Request)
-						if ok {
-							req.Header.Set("x-amz-content-sha256", contentHash)
-							req.Header.Set("Content-Length", fmt.Sprintf("%d", contentLength))
-						}
-						return next.HandleBuild(ctx, in)
-					},
-				), middleware.Before)
-			})
-		})
+		stats, err := bm.MigrateObjectToGlacier(backup.Name, backup.Size)
 		if err != nil {
-			fmt.Printf("  ⚠ Failed to upload %s to Glacier: %v\n", backup.Name, err)
+			fmt.Printf("  ⚠ Failed to migrate %s to Glacier: %v\n", backup.Name, err)
+			if errors.Is(err, ErrGlacierChecksumMismatch) {
+				fmt.Printf("  ℹ️  Checksum mismatch; keeping %s in Minio\n", backup.Name)
+			} else {
+				fmt.Printf("  ℹ️  Uploaded parts are kept; the next run resumes this object\n")
+			}
 			continue
 		}
 
-		fmt.Printf("  ✓ Uploaded to Glacier (Archive ID: %s...)\n", (*uploadResult.ArchiveId)[:40])
+		fmt.Printf("  ✓ Uploaded to Glacier (Archive ID: %s...)\n", stats.ArchiveID[:40])
 
 		// Delete from Minio
-		err = bm.minioClient.RemoveObject(ctx, bm.minioConfig.Bucket, backup.Name, minio.RemoveObjectOptions{})
+		err = bm.removeBackupObject(ctx, backup.Name)
 		if err != nil {
 			fmt.Printf("  ⚠ Failed to delete %s from Minio after migration: %v\n", backup.Name, err)
 			// Continue anyway - backup is already in Glacier
+			bm.QueuePendingDelete(ObjectInfo{Key: backup.Name, Size: backup.Size}, stats.ArchiveID, err)
 		} else {
 			fmt.Printf("  ✓ Deleted from Minio\n")
 			totalFreed += backup.Size
@@ -1331,6 +1679,9 @@ func (bm *BackupManager) MigrateOldestBackupsToGlacier(percent float64, dryRun b
 			migratedCount, numToMigrate,
 			float64(totalFreed)/(1024*1024),
 			float64(totalFreed)/(1024*1024*1024))
+		if bm.StagingEnabled() && migratedCount > 0 {
+			fmt.Printf("ℹ️  Staged backups are archiving to Glacier in the background from %s\n", bm.StagingLocation())
+		}
 	}
 
 	return nil
@@ -1345,35 +1696,31 @@ func (bm *BackupManager) DeleteOldestBackups(percent float64, dryRun bool) error
 	}
 
 	ctx := context.Background()
-	var backups []struct {
-		Name         string
-		LastModified time.Time
-		Size         int64
+	objects, err := bm.listObjects(ctx, "", 0)
+	if err != nil {
+		return fmt.Errorf("error listing objects: %w", err)
 	}
-
-	objectCh := bm.minioClient.ListObjects(ctx, bm.minioConfig.Bucket, minio.ListObjectsOptions{Recursive: true})
-	for object := range objectCh {
-		if object.Err != nil {
-			return fmt.Errorf("error listing objects: %w", object.Err)
+	if objects, err = bm.ExcludeMaintenance(objects); err != nil {
+		return fmt.Errorf("failed to read maintenance flags: %w", err)
+	}
+	var backupObjects []ObjectInfo
+	for _, object := range objects {
+		if isInternalObject(object.Key) || bm.IsStagedKey(object.Key) {
+			continue
 		}
-		backups = append(backups, struct {
-			Name         string
-			LastModified time.Time
-			Size         int64
-		}{
-			Name:         object.Key,
-			LastModified: object.LastModified,
-			Size:         object.Size,
-		})
+		backupObjects = append(backupObjects, object)
 	}
 
+	// Split database/files pairs and shards are deleted together so a forced
+	// cleanup never leaves half a backup behind.
+	backups := GroupBackupSets(backupObjects)
 	if len(backups) == 0 {
 		fmt.Println("No backups found in Minio to delete.")
 		return nil
 	}
 
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].LastModified.Before(backups[j].LastModified)
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Time().Before(backups[j].Time())
 	})
 
 	numToDelete := int(math.Ceil(float64(len(backups)) * percent / 100.0))
@@ -1392,15 +1739,25 @@ func (bm *BackupManager) DeleteOldestBackups(percent float64, dryRun bool) error
 	var totalFreed int64
 	for i := 0; i < numToDelete; i++ {
 		backup := backups[i]
-		fmt.Printf("  [%d/%d] %s (%.2f MB)\n", i+1, numToDelete, backup.Name, float64(backup.Size)/(1024*1024))
-		fmt.Printf("      Modified: %s\n", backup.LastModified.Format(time.RFC3339))
+		fmt.Printf("  [%d/%d] %s (%.2f MB)\n", i+1, numToDelete, backup.Stem, float64(backup.Size)/(1024*1024))
+		fmt.Printf("      Taken: %s\n", FormatTime(backup.Time(), time.RFC3339))
+		if len(backup.Objects) > 1 {
+			fmt.Printf("      Objects: %s\n", strings.Join(backup.Keys(), ", "))
+		}
 
 		if dryRun {
+			totalFreed += backup.Size
 			continue
 		}
 
-		if err := bm.minioClient.RemoveObject(ctx, bm.minioConfig.Bucket, backup.Name, minio.RemoveObjectOptions{}); err != nil {
-			fmt.Printf("      ⚠ Failed to delete %s: %v\n", backup.Name, err)
+		failed := false
+		for _, key := range backup.Keys() {
+			if err := bm.removeBackupObject(ctx, key); err != nil {
+				fmt.Printf("      ⚠ Failed to delete %s: %v\n", key, err)
+				failed = true
+			}
+		}
+		if failed {
 			continue
 		}
 
@@ -1429,6 +1786,18 @@ func (bm *BackupManager) MonitorAndMigrateIfNeeded(storagePath string, threshold
 		fmt.Println("🔍 DRY RUN MODE: No actual migrations will be performed")
 	}
 
+	if bm.pendingDeletesFile != "" {
+		// Deletes left over from earlier migrations free space without
+		// uploading anything, so they go first.
+		res, err := bm.ReconcilePendingDeletes(bm.pendingDeletesFile, &ReconcileOptions{DryRun: dryRun})
+		if err != nil {
+			fmt.Printf("⚠ Failed to reconcile pending deletes: %v\n", err)
+		} else if res.Deleted+res.Gone+res.Failed+res.Changed > 0 {
+			fmt.Printf("Pending deletes: %d deleted (%.2f MB freed), %d already gone, %d failed, %d changed\n",
+				res.Deleted, float64(res.FreedBytes)/(1024*1024), res.Gone, res.Failed, res.Changed)
+		}
+	}
+
 	maxIterations := 10 // Prevent infinite loops
 	iteration := 0
 
@@ -1446,6 +1815,10 @@ func (bm *BackupManager) MonitorAndMigrateIfNeeded(storagePath string, threshold
 			float64(capacity.Used)/(1024*1024*1024), capacity.UsedPercent)
 		fmt.Printf("  Available: %.2f GB\n", float64(capacity.Available)/(1024*1024*1024))
 
+		if iteration == 1 {
+			bm.reportCapacity(capacity, threshold, dryRun)
+		}
+
 		if capacity.UsedPercent <= threshold {
 			fmt.Printf("\n✓ Storage usage (%.1f%%) is within threshold (%.1f%%)\n",
 				capacity.UsedPercent, threshold)
@@ -1492,12 +1865,18 @@ func (bm *BackupManager) MonitorAndMigrateIfNeeded(storagePath string, threshold
 			fmt.Println("\nℹ️  Dry run complete. Only one iteration performed for preview.")
 			return nil
 		}
+		if bm.staging.tier == bm {
+			// Staging within the hot bucket frees no disk until the Glacier
+			// uploads finish, so re-checking now would only stage more.
+			fmt.Println("\nℹ️  Backups were staged within the hot bucket; space is freed as the Glacier uploads complete.")
+			return nil
+		}
 
 		// Wait a moment for filesystem to update
 		time.Sleep(2 * time.Second)
 	}
 
-	return fmt.Errorf("storage capacity still exceeds threshold after %d iterations", maxIterations)
+	return fmt.Errorf("%w after %d iterations", ErrCapacityExceeded, maxIterations)
 }
 
 func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
@@ -1522,7 +1901,7 @@ func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
 		}
 
 		if capacity.UsedPercent > threshold {
-			return fmt.Errorf("storage capacity exceeds %.1f%% (current: %.1f%%). Cannot create backup. Please run 'backup monitor' to free up space", threshold, capacity.UsedPercent)
+			return fmt.Errorf("cannot create backup, run 'backup monitor' to free up space: %w", &CapacityError{Path: storagePath, UsedPercent: capacity.UsedPercent, Threshold: threshold})
 		}
 
 		fmt.Printf("✓ Storage capacity check passed: %.1f%% used (threshold: %.1f%%)\n", capacity.UsedPercent, threshold)
@@ -1532,6 +1911,19 @@ func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
 		return err
 	}
 
+	startedAt := time.Now()
+	bm.lastRun = &RunRecord{
+		ID:        NewRunID(startedAt),
+		StartedAt: startedAt,
+		DryRun:    options.DryRun,
+		Features:  bm.features.Names(),
+	}
+	defer func() { bm.lastRun.FinishedAt = time.Now() }()
+
+	if err := checkBackupWindow(options); err != nil {
+		return err
+	}
+
 	containers, err := bm.getContainers(options)
 	if err != nil {
 		return err
@@ -1542,6 +1934,29 @@ func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
 		return nil
 	}
 
+	if options.ResumeFile != "" {
+		tok, err := LoadResumeToken(options.ResumeFile)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: ignoring resume token: %v\n", err)
+		} else if tok != nil {
+			resumed := applyResumeToken(containers, tok)
+			if len(resumed) < len(containers) {
+				fmt.Printf("▶  Resuming run %s paused at %s: %d of %d container(s) remaining\n",
+					tok.RunID, FormatTime(tok.PausedAt, time.RFC3339), len(resumed), len(containers))
+				containers = resumed
+			}
+		}
+	}
+
+	containers = bm.skipOutsideGroup(bm.skipMaintenance(containers))
+	if len(containers) == 0 {
+		fmt.Println("All containers are skipped (maintenance or group).")
+		return nil
+	}
+
+	bm.status.begin(bm.lastRun.ID, startedAt, containers)
+	defer bm.status.end()
+
 	total := len(containers)
 	processed := 0
 	successCount := 0
@@ -1551,22 +1966,42 @@ func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
 	awsUploads := 0
 
 	for idx, container := range containers {
+		if idx > 0 {
+			if blocked, until := options.Window.Blocked(time.Now()); blocked {
+				return bm.pauseForWindow(containers[idx:], options, until)
+			}
+		}
 		processed++
 		fmt.Printf("\n--- [%d/%d] Processing container: %s ---\n", idx+1, total, container.Name)
+		bm.status.startContainer(idx + 1)
 		compressedSize, awsUploaded, err := bm.processContainer(container, options)
+		bm.status.finishContainer(err == nil)
 		if err != nil {
 			fmt.Printf("Error processing container %s: %v\n", container.Name, err)
+			diag := ClassifyBackupError(err)
+			if diag.Code != FailureUnknown {
+				fmt.Printf("💡 %s [%s]: %s\n", diag.Summary, diag.Code, diag.Remediation)
+			}
 			failedCount++
+			bm.lastRun.Failed = failedCount
+			bm.lastRun.Failures = append(bm.lastRun.Failures, ContainerFailure{
+				Container:   container.Name,
+				Site:        bm.siteKeyOf(filepath.Base(container.WorkingDir)),
+				Code:        diag.Code,
+				Error:       err.Error(),
+				Remediation: diag.Remediation,
+			})
 			continue
 		}
 		successCount++
+		bm.lastRun.Succeeded = successCount
 		totalCompressed += compressedSize
 		if awsUploaded {
 			awsUploads++
 		}
 		// Attempt to calculate uncompressed size for the container if available
 		if container.WorkingDir != "" {
-			if size, err := bm.getDirectorySize(container.WorkingDir, options.ParentDir); err == nil {
+			if size, err := bm.getDirectorySize(container.WorkingDir, container.parentDir(options)); err == nil {
 				totalUncompressed += size
 			}
 		}
@@ -1583,10 +2018,55 @@ func (bm *BackupManager) CreateBackups(options *BackupOptions) error {
 			fmt.Printf("AWS Glacier uploads: %d\n", awsUploads)
 		}
 	}
+	if missed := bm.lastRun.missedDestinations(); len(missed) > 0 {
+		fmt.Printf("Missed destinations: %s\n", strings.Join(missed, ", "))
+	}
+	if n := len(bm.lastRun.Skipped); n > 0 {
+		fmt.Printf("Skipped (maintenance): %d container(s)\n", n)
+	}
+	if warnings := bm.lastRun.TarWarnings; len(warnings) > 0 {
+		fmt.Printf("Tar warnings: %s\n", FormatTarWarnings(warnings))
+		if n := totalTarWarnings(warnings); options.TarWarningThreshold > 0 && n >= options.TarWarningThreshold {
+			bm.lastRun.CompletedWithWarnings = true
+			fmt.Printf("⚠️  Run completed with warnings: %d tar warning(s), threshold %d\n", n, options.TarWarningThreshold)
+		}
+	}
+	bm.lastRun.nestedArchiveReport()
 
+	if options.ResumeFile != "" && !options.DryRun {
+		if err := os.Remove(options.ResumeFile); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("⚠️  Warning: failed to clear resume token: %v\n", err)
+		}
+	}
 	return nil
 }
 
+// pauseForWindow stops a run that reached a blackout, saving the remaining
+// containers to the resume token so the next invocation continues from there.
+func (bm *BackupManager) pauseForWindow(remaining []ContainerInfo, options *BackupOptions, until time.Time) error {
+	fmt.Printf("\n⏸  Backup blackout %s reached; pausing with %d container(s) remaining (until %s)\n",
+		options.Window, len(remaining), FormatTime(until, "15:04 MST"))
+	if options.ResumeFile != "" && !options.DryRun {
+		tok := &ResumeToken{RunID: bm.lastRun.ID, PausedAt: time.Now().UTC(), Host: bm.remoteHost()}
+		for _, c := range remaining {
+			tok.Remaining = append(tok.Remaining, c.Name)
+		}
+		if err := tok.Save(options.ResumeFile); err != nil {
+			fmt.Printf("⚠️  Warning: %v\n", err)
+		} else {
+			fmt.Printf("   Resume token written to %s\n", options.ResumeFile)
+		}
+	}
+	return fmt.Errorf("%w: paused with %d container(s) remaining", ErrBackupWindowClosed, len(remaining))
+}
+
+// LastRunRecord returns the record of the most recent CreateBackups call, or
+// nil if no backups have been created yet. Callers are expected to fill in
+// the Host before persisting it.
+func (bm *BackupManager) LastRunRecord() *RunRecord {
+	return bm.lastRun
+}
+
 // GetContainersFromOptions returns the list of containers that would be processed
 // based on the provided options. This is useful for determining which backups to clean up.
 func (bm *BackupManager) GetContainersFromOptions(options *BackupOptions) ([]ContainerInfo, error) {
@@ -1638,13 +2118,12 @@ func (bm *BackupManager) getContainers(options *BackupOptions) ([]ContainerInfo,
 		}
 	}
 
-	// If no inputs, get all wp_ containers
+	// If no inputs, discover all sites
 	if len(containerInputs) == 0 {
-		containers, err := bm.getWPContainers()
-		if err != nil {
-			return nil, err
+		if options.Discovery == DiscoveryPrefix {
+			return bm.getWPContainers()
 		}
-		return containers, nil
+		return bm.discoverComposeStacks()
 	}
 
 	// Process inputs
@@ -1683,10 +2162,10 @@ func (bm *BackupManager) getWPContainers() ([]ContainerInfo, error) {
 			continue
 		}
 
-		containers = append(containers, ContainerInfo{
+		containers = append(containers, bm.containerOverrides.apply(ContainerInfo{
 			Name:       line,
 			WorkingDir: workingDir,
-		})
+		}))
 	}
 
 	return containers, nil
@@ -1699,26 +2178,31 @@ func (bm *BackupManager) resolveContainer(input string) (ContainerInfo, error) {
 		if err != nil {
 			return ContainerInfo{}, fmt.Errorf("no running container found for directory '%s'", input)
 		}
-		return ContainerInfo{Name: containerName, WorkingDir: input}, nil
+		return bm.containerOverrides.apply(ContainerInfo{Name: containerName, WorkingDir: input}), nil
 	}
 
 	// Try as container name first
 	workingDir, err := bm.getContainerWorkingDir(input)
 	if err == nil {
-		return ContainerInfo{Name: input, WorkingDir: workingDir}, nil
+		return bm.containerOverrides.apply(ContainerInfo{Name: input, WorkingDir: workingDir}), nil
 	}
 
 	// Try as directory under /var/opt
 	candidateDir := "/var/opt/" + input
 	containerName, err := bm.findContainerByWorkingDir(candidateDir)
 	if err == nil {
-		return ContainerInfo{Name: containerName, WorkingDir: candidateDir}, nil
+		return bm.containerOverrides.apply(ContainerInfo{Name: containerName, WorkingDir: candidateDir}), nil
 	}
 
 	return ContainerInfo{}, fmt.Errorf("no running container or directory found for '%s'", input)
 }
 
 func (bm *BackupManager) getContainerWorkingDir(containerName string) (string, error) {
+	if o, ok := bm.containerOverrides[containerName]; ok {
+		bm.logVerbose("Using override working dir %s for %s", o.WorkingDir, containerName)
+		return o.WorkingDir, nil
+	}
+
 	cmd := fmt.Sprintf(`docker inspect "%s" | jq -r '.[].Config.Labels."com.docker.compose.project.working_dir"'`, containerName)
 	output, stderr, err := bm.executeCommand(cmd)
 	if err != nil {
@@ -1734,6 +2218,10 @@ func (bm *BackupManager) getContainerWorkingDir(containerName string) (string, e
 }
 
 func (bm *BackupManager) findContainerByWorkingDir(workingDir string) (string, error) {
+	if name, ok := bm.containerOverrides.byWorkingDir(workingDir); ok {
+		return name, nil
+	}
+
 	cmd := `docker ps --format '{{.Names}}'`
 	output, stderr, err := bm.executeCommand(cmd)
 	if err != nil {
@@ -1760,16 +2248,40 @@ func (bm *BackupManager) findContainerByWorkingDir(workingDir string) (string, e
 	return "", fmt.Errorf("container not found")
 }
 
+// parentDir returns the directory to look for the site in when its working
+// directory is not found, preferring the container's override.
+func (c ContainerInfo) parentDir(options *BackupOptions) string {
+	if c.ParentDir != "" {
+		return c.ParentDir
+	}
+	return options.ParentDir
+}
+
 func (bm *BackupManager) processContainer(container ContainerInfo, options *BackupOptions) (int64, bool, error) {
+	// Scans of the previous site are of no use any more.
+	bm.scans.clear()
 	fmt.Printf("Processing container: %s (type: %s)\n", container.Name, container.Type)
 	fmt.Printf("Working directory: %s\n", container.WorkingDir)
+	if container.Project != "" && len(container.Services) > 0 {
+		fmt.Printf("Compose stack: %s (with %s)\n", container.Project, strings.Join(container.Services, ", "))
+	}
+
+	timestamp := InTimezone(time.Now()).Format("20060102-150405")
 
-	timestamp := time.Now().Format("20060102-150405")
+	// Site names that break shell tooling and URLs (spaces, unicode, very
+	// long names) are stored under a normalized key; see ResolveSiteKey.
+	siteName := filepath.Base(container.WorkingDir)
+	siteKey, err := bm.cachedSiteKey(siteName, !options.DryRun)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: failed to resolve the object key of %s, using %s: %v\n", siteName, siteKey, err)
+	}
+	bm.auditSite(siteKey)
+	defer bm.auditSite("")
 
-	// Use custom label if provided, otherwise use working dir basename
-	label := filepath.Base(container.WorkingDir)
+	// Use custom label if provided, otherwise use the site key
+	label := siteKey
 	if container.Config != nil && container.Config.Label != "" {
-		label = container.Config.Label
+		label = SiteKey(container.Config.Label)
 	}
 	backupName := fmt.Sprintf("%s-%s.tgz", label, timestamp)
 
@@ -1778,6 +2290,10 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 		if container.Type == "wordpress" || container.Type == "" {
 			fmt.Printf("[DRY RUN] Would clean old SQL files in %s\n", container.Name)
 			fmt.Printf("[DRY RUN] Would export WordPress DB in %s\n", container.Name)
+		} else if container.Config != nil && container.Config.Database.ConsistentCopy != nil {
+			if err := bm.consistentCopy(container, options); err != nil {
+				return 0, false, err
+			}
 		} else if container.Config != nil && container.Config.Database.Type != "" {
 			fmt.Printf("[DRY RUN] Would export %s database\n", container.Config.Database.Type)
 		}
@@ -1789,9 +2305,9 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 			fmt.Printf("\n[DRY RUN] Estimating compressed size using '%s' method...\n", options.EstimateMethod)
 			startTime := time.Now()
 
-			compressedSize, uncompressedSize, err := bm.EstimateCompressedSize(
+			compressedSize, uncompressedSize, sample, err := bm.estimateCompressedSize(
 				container.WorkingDir,
-				options.ParentDir,
+				container.parentDir(options),
 				options.EstimateMethod,
 				options.SampleSize,
 			)
@@ -1821,6 +2337,8 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 				case "sample":
 					sampleMB := float64(options.SampleSize) / (1024 * 1024)
 					fmt.Printf("[DRY RUN]    Accuracy: ~90%% (%.0f MB sample compressed)\n", sampleMB)
+				case "adaptive":
+					fmt.Printf("[DRY RUN]    Accuracy: %s\n", sample)
 				case "accurate":
 					fmt.Printf("[DRY RUN]    Accuracy: 100%% (full compression simulation)\n")
 				}
@@ -1828,14 +2346,32 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 			fmt.Println()
 		}
 
+		if archives := bm.checkNestedArchives(container, options); len(archives) > 0 {
+			printNestedArchives("[DRY RUN] ", archives)
+		}
+
+		if freeze, err := exportFreezeFor(container, options); err != nil {
+			return 0, false, err
+		} else if freeze.Enabled() {
+			fmt.Printf("[DRY RUN] Would switch on %s during the database export\n", freeze)
+		}
+
+		if options.HostConfig != nil {
+			fmt.Printf("[DRY RUN] Would capture host config (%d path(s)) to %s\n", len(options.HostConfig.SitePaths(filepath.Base(container.WorkingDir))), hostConfigObjectName(siteKey, backupName))
+		}
+
 		if options.Delete {
-			fmt.Printf("[DRY RUN] Would stop and remove container %s\n", container.Name)
+			fmt.Printf("[DRY RUN] Would stop and remove container(s) %s\n", strings.Join(append([]string{container.Name}, container.Services...), ", "))
 			fmt.Printf("[DRY RUN] Would remove directory %s\n", container.WorkingDir)
 		}
 		fmt.Printf("Done with %s\n\n", container.Name)
 		return estimatedCompressed, false, nil
 	}
 
+	if options.Timeouts.Enabled() {
+		defer bm.beginContainerTimeouts(container.Name, options.Timeouts)()
+	}
+
 	// Run pre-backup commands if specified
 	if container.Config != nil && len(container.Config.PreBackupCommands) > 0 {
 		fmt.Printf("Running pre-backup commands...\n")
@@ -1848,21 +2384,35 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 	}
 
 	// Handle database export based on container type
+	var multisite *MultisiteInfo
 	if container.Type == "wordpress" || container.Type == "" {
 		// WordPress-specific backup logic
-		if err := bm.exportWordPressDatabase(container); err != nil {
+		freeze, err := exportFreezeFor(container, options)
+		if err != nil {
+			return 0, false, err
+		}
+		err = bm.withExportFreeze(container, freeze, func() error {
+			return bm.exportStage(container, func() error {
+				var err error
+				multisite, err = bm.exportWordPressDatabase(container)
+				return err
+			})
+		})
+		if err != nil {
 			return 0, false, err
 		}
 	} else if container.Config != nil && container.Config.Database.Type != "" {
 		// Custom database export
-		if err := bm.exportDatabase(container, options); err != nil {
+		if err := bm.exportStage(container, func() error { return bm.exportDatabase(container, options) }); err != nil {
 			return 0, false, err
 		}
 	}
 
 	// Create and stream tarball to Minio
-	siteName := filepath.Base(container.WorkingDir)
 	fmt.Printf("\n📦 Creating tarball for %s...\n", siteName)
+	if siteKey != siteName {
+		fmt.Printf("   Stored as: %s\n", siteKey)
+	}
 
 	// Determine backup directory - use custom app dir if specified
 	backupDir := container.WorkingDir
@@ -1880,7 +2430,7 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 
 	// Get uncompressed directory size for compression ratio calculation
 	fmt.Printf("   Calculating source size...\n")
-	uncompressedSize, err := bm.getDirectorySize(backupDir, options.ParentDir)
+	uncompressedSize, err := bm.getDirectorySize(backupDir, container.parentDir(options))
 	if err != nil {
 		fmt.Printf("   ⚠️  Warning: Could not determine source size: %v\n", err)
 		uncompressedSize = 0 // Continue anyway
@@ -1889,12 +2439,169 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 		fmt.Printf("   Uncompressed: %.2f MB\n", uncompressedMB)
 	}
 
-	fmt.Printf("   Compressing and streaming...\n")
+	var facts *BackupFacts
+	if !options.SkipFacts {
+		fmt.Printf("   Recording runtime facts...\n")
+		facts = bm.collectBackupFacts(container)
+	}
 
-	compressedSize, awsUploaded, err := bm.streamBackupToMinio(backupDir, backupName, options.ParentDir, containerBucketPath, uncompressedSize, options.IncludeAWSGlacier)
-	if err != nil {
+	excludeArgs := tarExcludeArgs
+	nested := bm.checkNestedArchives(container, options)
+	if len(nested) > 0 {
+		printNestedArchives("   ", nested)
+		if args := nestedArchiveExcludeArgs(backupDir, nested); args != "" {
+			excludeArgs += " " + args
+		}
+		if skipped := excludedSize(nested); uncompressedSize > skipped {
+			uncompressedSize -= skipped
+		}
+	}
+	if facts != nil {
+		if git := bm.detectGitInfo(backupDir, container.parentDir(options)); git != nil {
+			fmt.Printf("   🔖 Git checkout: %s\n", git)
+			facts.Git = git
+		}
+	}
+	if container.Config != nil && container.Config.Database.ConsistentCopy != nil {
+		// The live data directory is inconsistent; its copy is archived.
+		dataDir := filepath.Clean(container.Config.Database.ConsistentCopy.DataDir)
+		if rel, err := filepath.Rel(backupDir, dataDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			excludeArgs += " " + buildTarExcludeArgs([]string{filepath.Join(filepath.Base(backupDir), rel)})
+		}
+	}
+	if options.ExcludeGit {
+		// Anchored on the site directory name like the uploads exclude
+		// below; a nested repository (e.g. a theme) is kept.
+		excludeArgs += " " + buildTarExcludeArgs([]string{filepath.Join(filepath.Base(backupDir), ".git")})
+		if facts != nil && facts.Git != nil {
+			facts.Git.Excluded = true
+			fmt.Printf("   ⏭️  Skipping .git\n")
+		}
+	}
+	if (container.Type == "wordpress" || container.Type == "") && (facts != nil || options.SkipOffloadedUploads) {
+		var plugins []PluginFact
+		if facts != nil {
+			plugins = facts.Plugins
+		}
+		if offload := bm.detectMediaOffload(container, plugins); offload != nil {
+			fmt.Printf("   ☁️  Media offloaded by %s", offload.Plugin)
+			if remote := offload.Remote(); remote != "" {
+				fmt.Printf(" to %s", remote)
+			}
+			fmt.Println()
+			if options.SkipOffloadedUploads {
+				skipped, err := bm.getDirectorySize(uploadsDir(backupDir), "")
+				if err != nil {
+					bm.logVerbose("Could not size %s: %v", uploadsDir(backupDir), err)
+				}
+				offload.UploadsSkipped = true
+				offload.SkippedBytes = skipped
+				if uncompressedSize > skipped {
+					uncompressedSize -= skipped
+				}
+				// Anchor on the site directory name, which is the same for
+				// the working dir and its parent-dir fallback.
+				excludeArgs += " " + buildTarExcludeArgs([]string{filepath.Join(filepath.Base(backupDir), "www", "wp-content", "uploads")})
+				fmt.Printf("   ⏭️  Skipping wp-content/uploads (%.2f MB)\n", float64(skipped)/(1024*1024))
+				if !offload.RemovesLocal {
+					fmt.Printf("   ⚠️  %s keeps local copies; media uploaded before it was enabled may not be offloaded\n", offload.Plugin)
+				}
+			}
+			offload.RestoreHint = offload.restoreHint()
+			if facts == nil {
+				// Skipped uploads must always be recorded for the restore.
+				facts = &BackupFacts{CreatedAt: time.Now().UTC(), Container: container.Name}
+			}
+			facts.MediaOffload = offload
+		}
+	}
+
+	if options.HostConfig != nil {
+		fmt.Printf("   Capturing host config...\n")
+		if key, err := bm.captureHostConfig(options.HostConfig, siteKey, backupName); err != nil {
+			// Site data is still worth backing up without it.
+			fmt.Printf("   ⚠️  Warning: %v\n", err)
+		} else {
+			fmt.Printf("   🗂️  Host config: %s\n", key)
+			if facts == nil {
+				// The restore needs to find the side archive.
+				facts = &BackupFacts{CreatedAt: time.Now().UTC(), Container: container.Name}
+			}
+			facts.HostConfig = key
+		}
+	}
+
+	if multisite != nil {
+		if facts == nil {
+			// A sub-site restore needs the network's table prefix.
+			facts = &BackupFacts{CreatedAt: time.Now().UTC(), Container: container.Name}
+		}
+		facts.Multisite = multisite
+	}
+
+	var metadata map[string]string
+	if facts != nil {
+		metadata = facts.Metadata()
+		manifestPath, err := bm.writeBackupManifest(backupDir, facts)
+		if err != nil {
+			fmt.Printf("   ⚠️  Warning: %v\n", err)
+		} else {
+			defer func() {
+				if _, stderr, err := bm.cleanupCommand(fmt.Sprintf(`rm -f "%s"`, manifestPath)); err != nil {
+					fmt.Printf("Warning: failed to remove backup manifest: %v (stderr: %s)\n", err, stderr)
+				}
+			}()
+		}
+	}
+
+	metadata = siteNameMetadata(metadata, siteName, siteKey)
+
+	fmt.Printf("   Compressing and streaming...\n")
+
+	stats := &UploadStats{
+		Site:             siteKey,
+		Container:        container.Name,
+		UncompressedSize: uncompressedSize,
+		NestedArchives:   nested,
+	}
+	postUploadCheck := bm.effectivePostUploadCheck(options)
+	if postUploadCheck != "" && postUploadCheck != PostUploadCheckNone {
+		stats.digest = newUploadDigest()
+	}
+	slot, err := bm.acquireUploadSlot(options.UploadSemaphore)
+	if err != nil {
+		return 0, false, err
+	}
+	compressedSize, awsUploaded, err := bm.streamBackupToMinio(backupDir, backupName, container.parentDir(options), containerBucketPath, excludeArgs, uncompressedSize, options.IncludeAWSGlacier, stats, metadata)
+	slot.Release()
+	bm.queueMissedDestinations(options, container, backupDir, excludeArgs, stats)
+	if err != nil {
+		if len(stats.Destinations) > 0 && bm.lastRun != nil {
+			// One copy exists; keep it in the history (and Glacier ledger).
+			bm.lastRun.Uploads = append(bm.lastRun.Uploads, *stats)
+		}
 		return 0, false, fmt.Errorf("failed to stream backup to Minio: %w", err)
 	}
+	if stats.digest != nil && stats.Spooled {
+		fmt.Printf("   ⏭️  Skipping post-upload check: the archive is spooled\n")
+	} else if stats.digest != nil && slices.Contains(stats.Missed, DestinationMinio) {
+		fmt.Printf("   ⏭️  Skipping post-upload check: no Minio copy\n")
+	} else if stats.digest != nil {
+		fmt.Printf("   🔎 Running %s post-upload check...\n", postUploadCheck)
+		if err := bm.checkUpload(context.Background(), postUploadCheck, stats.ObjectKey, stats.digest); err != nil {
+			stats.PostUploadCheck = "failed"
+			if bm.lastRun != nil {
+				bm.lastRun.Uploads = append(bm.lastRun.Uploads, *stats)
+			}
+			return 0, false, fmt.Errorf("post-upload check of %s failed: %w", stats.ObjectKey, err)
+		}
+		stats.PostUploadCheck = postUploadCheck
+		stats.Checksum = stats.digest.checksum()
+		fmt.Printf("   ✓ Post-upload check passed\n")
+	}
+	if bm.lastRun != nil {
+		bm.lastRun.Uploads = append(bm.lastRun.Uploads, *stats)
+	}
 
 	// Calculate and display compression ratio
 	if uncompressedSize > 0 && compressedSize > 0 {
@@ -1914,12 +2621,14 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 	}
 
 	if options.Delete {
-		fmt.Printf("Stopping and removing container %s...\n", container.Name)
-		stopCmd := fmt.Sprintf(`docker stop "%s" 2>/dev/null || true`, container.Name)
-		bm.executeCommand(stopCmd)
+		for _, name := range append([]string{container.Name}, container.Services...) {
+			fmt.Printf("Stopping and removing container %s...\n", name)
+			stopCmd := fmt.Sprintf(`docker stop "%s" 2>/dev/null || true`, name)
+			bm.executeCommand(stopCmd)
 
-		removeCmd := fmt.Sprintf(`docker rm "%s" 2>/dev/null || true`, container.Name)
-		bm.executeCommand(removeCmd)
+			removeCmd := fmt.Sprintf(`docker rm "%s" 2>/dev/null || true`, name)
+			bm.executeCommand(removeCmd)
+		}
 
 		fmt.Printf("Removing directory %s...\n", container.WorkingDir)
 		rmCmd := fmt.Sprintf(`rm -rf "%s"`, container.WorkingDir)
@@ -1932,8 +2641,9 @@ func (bm *BackupManager) processContainer(container ContainerInfo, options *Back
 	return compressedSize, awsUploaded, nil
 }
 
-// exportWordPressDatabase handles WordPress-specific database export
-func (bm *BackupManager) exportWordPressDatabase(container ContainerInfo) error {
+// exportWordPressDatabase handles WordPress-specific database export. It
+// returns the network when the site is a multisite install.
+func (bm *BackupManager) exportWordPressDatabase(container ContainerInfo) (*MultisiteInfo, error) {
 	// Clean all SQL files
 	fmt.Printf("Cleaning all SQL files in %s...\n", container.Name)
 	cleanCmd := fmt.Sprintf(`docker exec -u 0 "%s" find /var/www/html -name "*.sql" -type f -exec rm -f {} \;`, container.Name)
@@ -1949,17 +2659,35 @@ func (bm *BackupManager) exportWordPressDatabase(container ContainerInfo) error
 		fmt.Printf("Warning: failed to remove existing SQL files from host wp-content: %v (stderr: %s)\n", err, stderr)
 	}
 
+	multisite := bm.detectMultisite(container)
+	exportArgs := ""
+	if multisite != nil {
+		fmt.Printf("🌐 Multisite network with %d site(s)\n", len(multisite.Sites))
+		// Export the network's tables by name, every sub-site's and the
+		// global ones; wp-cli lists the main site's only without --network.
+		exportArgs = ` --tables="$(wp --allow-root db tables --network --all-tables-with-prefix --format=csv)"`
+	}
+
 	fmt.Printf("Exporting DB in %s...\n", container.Name)
-	exportCmd := fmt.Sprintf(`docker exec -u 0 "%s" sh -c 'wp --allow-root db export && mv *.sql /var/www/html/wp-content/'`, container.Name)
+	exportCmd := fmt.Sprintf(`docker exec -u 0 "%s" sh -c 'wp --allow-root db export%s && mv *.sql /var/www/html/wp-content/'`, container.Name, exportArgs)
 	if _, stderr, err := bm.executeCommand(exportCmd); err != nil {
-		return fmt.Errorf("failed to export database: %w (stderr: %s)", err, stderr)
+		return nil, fmt.Errorf("failed to export database: %w (stderr: %s)", err, stderr)
 	}
 
-	return nil
+	return multisite, nil
 }
 
 // getDirectorySize returns the total size of a directory in bytes
 func (bm *BackupManager) getDirectorySize(dirPath string, parentDir string) (int64, error) {
+	if bm.scanCacheTTL > 0 {
+		if scan, rel := bm.scans.covering(dirPath, bm.scanCacheTTL); scan != nil {
+			return scan.sizeUnder(rel), nil
+		}
+		if scan, err := bm.scanDirectory(dirPath, parentDir); err == nil {
+			return scan.size(), nil
+		}
+	}
+
 	// Try the primary path first
 	var duCmd string
 	duCmd = fmt.Sprintf(`du -sb "%s" 2>/dev/null | awk '{print $1}'`, dirPath)
@@ -1990,28 +2718,104 @@ func (bm *BackupManager) getDirectorySize(dirPath string, parentDir string) (int
 	return size, nil
 }
 
-func (bm *BackupManager) streamBackupToMinio(workingDir, backupName, parentDir, containerBucketPath string, uncompressedSize int64, includeAWSGlacier bool) (int64, bool, error) {
+// streamBackupToMinio tars workingDir and streams it to Minio (and optionally
+// AWS Glacier), passing excludeArgs to tar. When stats is non-nil it is
+// filled with the object key and per-destination throughput measurements.
+func (bm *BackupManager) streamBackupToMinio(workingDir, backupName, parentDir, containerBucketPath, excludeArgs string, uncompressedSize int64, includeAWSGlacier bool, stats *UploadStats, metadata map[string]string) (_ int64, _ bool, err error) {
 	// Build a tar command that attempts the provided workingDir first and
 	// falls back to parentDir/<basename> if the first path doesn't exist.
 	// This works for both local and remote execution because we run the
 	// command under a shell (bash -lc).
-	var tarCmd string
-	if parentDir != "" {
-		alt := filepath.Join(parentDir, filepath.Base(workingDir))
-		// Use a shell conditional so remote execution can choose the right path.
-		tarCmd = fmt.Sprintf(`if [ -d "%s" ]; then tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"; elif [ -d "%s" ]; then tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"; else echo "tar: no such directory: %s" >&2; exit 2; fi`, workingDir, workingDir, alt, alt, workingDir)
+	tarCmd := bm.tarCommand(workingDir, parentDir, excludeArgs)
+	var tarInput io.Reader
+	if list := bm.scannedFileList(workingDir, parentDir); list != nil {
+		// The smart order is already known from the file scan; tar reads
+		// it instead of walking the tree once more.
+		tarCmd = bm.tarListCommand(excludeArgs)
+		tarInput = bytes.NewReader(list)
+	}
+	// Store backups in a directory named after the site (basename of
+	// workingDir, normalized)
+	siteName := bm.siteKeyOf(filepath.Base(workingDir))
+
+	// If a container-specific bucket path is configured, it supersedes the
+	// default `backups/<siteName>/...` structure. In that case place the
+	// backup directly under the configured prefix. Otherwise if a global
+	// MinioConfig.BucketPath is set use that. If neither is set, fall back
+	// to the default backups/<siteName>/<backupName> layout.
+	var objectName string
+	if containerBucketPath != "" {
+		objectName = filepath.Join(containerBucketPath, backupName)
+	} else if bm.minioConfig != nil && bm.minioConfig.BucketPath != "" {
+		objectName = filepath.Join(bm.minioConfig.BucketPath, backupName)
 	} else {
-		tarCmd = fmt.Sprintf(`tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"`, workingDir)
+		objectName = fmt.Sprintf("backups/%s/%s", siteName, backupName)
 	}
 
-	// Track whether an AWS upload completed successfully
-	awsUploaded := false
+	// Residency is checked for every destination before tar starts, so no
+	// part of the site leaves its region.
+	if err := bm.checkResidency(context.Background(), siteName, "upload", objectName, ResidencyMinio); err != nil {
+		return 0, false, err
+	}
+	if includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != "" {
+		if err := bm.checkResidency(context.Background(), siteName, "upload", objectName, ResidencyGlacier); err != nil {
+			return 0, false, err
+		}
+	}
+
+	// Tar and the upload are one stream, timed as both stages. At the
+	// deadline tar is killed, on the host too, and the upload fails rather
+	// than completing a truncated tarball; what it left is then removed.
+	// A spooled archive is only tar's.
+	spool := bm.reserveSpool(uncompressedSize)
+	if spool != nil {
+		defer spool.release()
+		defer bm.beginStage(StageTar)()
+	} else {
+		defer bm.beginStage(StageTar, StageUpload)()
+	}
+	ctx, cancel, timeoutErr := bm.stageContext()
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok {
+		tarCmd = timeoutCommand(tarCmd, time.Until(deadline))
+	}
+
+	label := filepath.Base(workingDir)
+	if stats != nil && stats.Container != "" {
+		label = stats.Container
+	}
+	tarStderr := bm.newTarStderr(label)
+	defer func(started time.Time) {
+		tarStderr.flush()
+		bm.auditCommand(tarCmd, started, "", tarStderr.String(), err)
+		if counts := tarStderr.Counts(); len(counts) > 0 {
+			if stats != nil {
+				stats.TarWarnings = counts
+			}
+			bm.lastRun.addTarWarnings(counts)
+		}
+	}(time.Now())
+	defer func() {
+		if err != nil && timeoutErr != nil && ctx.Err() != nil {
+			timeoutErr.Err = err
+			err = timeoutErr
+			if spool == nil {
+				bm.discardPartialUpload(objectName)
+			}
+		}
+	}()
+
+	if spool != nil {
+		dual := includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != ""
+		n, err := bm.spoolArchive(ctx, spool, tarCmd, tarInput, tarStderr, workingDir, objectName, dual, stats, metadata)
+		return n, false, err
+	}
 
 	// If running locally (no ssh client) run tar locally and stream stdout to Minio
 	if bm.sshClient == nil {
-		cmd := exec.Command("bash", "-lc", tarCmd)
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+		cmd := bm.shellCommand(tarCmd)
+		cmd.Stdin = tarInput
+		cmd.Stderr = tarStderr
 		stdout, err := cmd.StdoutPipe()
 		if err != nil {
 			return 0, false, fmt.Errorf("failed to create stdout pipe for local tar: %w", err)
@@ -2019,125 +2823,86 @@ func (bm *BackupManager) streamBackupToMinio(workingDir, backupName, parentDir,
 		if err := cmd.Start(); err != nil {
 			return 0, false, fmt.Errorf("failed to start local tar command: %w", err)
 		}
+		stop := context.AfterFunc(ctx, func() { _ = cmd.Process.Kill() })
+		defer stop()
 
-		ctx := context.Background()
-		// Store backups in a directory named after the site (basename of workingDir)
-		siteName := filepath.Base(workingDir)
-
-		// If a container-specific bucket path is configured, it supersedes the
-		// default `backups/<siteName>/...` structure. In that case place the
-		// backup directly under the configured prefix. Otherwise if a global
-		// MinioConfig.BucketPath is set use that. If neither is set, fall back
-		// to the default backups/<siteName>/<backupName> layout.
-		var objectName string
-		if containerBucketPath != "" {
-			objectName = filepath.Join(containerBucketPath, backupName)
-		} else if bm.minioConfig != nil && bm.minioConfig.BucketPath != "" {
-			objectName = filepath.Join(bm.minioConfig.BucketPath, backupName)
-		} else {
-			objectName = fmt.Sprintf("backups/%s/%s", siteName, backupName)
-		}
-
-		// If AWS is configured and includeAWSGlacier flag is set, upload to AWS first using TeeReader to capture data
-		var reader io.Reader = stdout
-		if includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != "" {
-			if err := bm.initAWSClient(); err != nil {
-				fmt.Printf("Warning: failed to initialize AWS client, skipping AWS upload: %v\n", err)
-			} else {
-				// Create a pipe to capture the tar output for AWS
-				pr, pw := io.Pipe()
-
-				// Use TeeReader to duplicate the stream
-				reader = io.TeeReader(stdout, pw)
-
-				// Upload to AWS in a goroutine
-				awsErrChan := make(chan error, 1)
-				go func() {
-					defer pw.Close()
-					awsStartTime := time.Now()
-					fmt.Printf("   ☁️  Streaming to AWS Glacier...\n")
-					fmt.Printf("      [AWS] Starting upload at %s\n", awsStartTime.Format("15:04:05"))
-					err := bm.UploadToAWS(objectName, pr, -1)
-					awsEndTime := time.Now()
-					awsDuration := awsEndTime.Sub(awsStartTime)
-					if err != nil {
-						fmt.Printf("      [AWS] Failed after %s: %v\n", awsDuration, err)
-						awsErrChan <- fmt.Errorf("AWS upload failed: %w", err)
-					} else {
-						fmt.Printf("      [AWS] Completed in %s\n", awsDuration)
-						awsErrChan <- nil
+		// If AWS is configured and includeAWSGlacier flag is set, tee the stream to AWS as well
+		var reader io.Reader = stageReader{ctx, bm.status.trackUpload(objectName, stdout)}
+		dual := includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != ""
+		if dual {
+			if tmp, err := bm.prepareGlacier(objectName, uncompressedSize); err != nil {
+				if bm.requires(DestinationGlacier) {
+					if cmd.Process != nil {
+						_ = cmd.Process.Kill()
 					}
-				}()
-
-				// Continue with Minio upload using the TeeReader
-				fmt.Printf("   📦 Streaming to Minio...\n")
-				info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, objectName, reader, -1, minio.PutObjectOptions{
-					ContentType: "application/gzip",
+					return 0, false, err
+				}
+				fmt.Printf("Warning: skipping AWS upload: %v\n", err)
+				stats.recordDestination(DestinationGlacier, err)
+			} else {
+				res := bm.uploadDual(ctx, objectName, reader, stats, metadata, !bm.requires(DestinationMinio), func(r io.Reader) (*GlacierUploadStats, error) {
+					return bm.uploadGlacierStream(objectName, r, tmp)
 				})
-				if err != nil {
+				tmp.release()
+				if res.minioErr != nil && bm.requires(DestinationMinio) {
 					if cmd.Process != nil {
 						_ = cmd.Process.Kill()
 					}
-					return 0, false, fmt.Errorf("failed to upload to Minio: %w", err)
-				}
-
-				// Wait for AWS upload to complete
-				awsErr := <-awsErrChan
-				awsUploaded := false
-				if awsErr != nil {
-					fmt.Printf("⚠️  Warning: %v\n", awsErr)
-				} else {
-					fmt.Printf("   ✓ AWS Glacier upload complete\n")
+					stats.recordDestination(DestinationMinio, res.minioErr)
+					stats.recordDestination(DestinationGlacier, res.glacierErr)
+					return 0, false, fmt.Errorf("failed to upload to Minio: %w", res.minioErr)
 				}
 
 				if err := cmd.Wait(); err != nil {
 					// Treat tar exit code 1 for "file changed as we read it" as a non-fatal warning
 					var exitErr *exec.ExitError
 					if errors.As(err, &exitErr) {
-						if exitErr.ExitCode() == 1 && strings.Contains(stderr.String(), "file changed as we read it") {
-							fmt.Printf("⚠️  Warning: tar reported non-fatal issue: %s\n", strings.TrimSpace(stderr.String()))
+						if exitErr.ExitCode() == 1 && tarStderr.fileChanged() {
+							fmt.Printf("⚠️  Warning: tar finished with non-fatal warnings (%s)\n", FormatTarWarnings(tarStderr.Counts()))
 						} else {
-							return 0, false, fmt.Errorf("local tar command failed: %w (stderr: %s)", err, stderr.String())
+							return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 						}
 					} else {
-						return 0, false, fmt.Errorf("local tar command failed: %w (stderr: %s)", err, stderr.String())
+						return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 					}
 				}
 
-				sizeMB := float64(info.Size) / (1024 * 1024)
-				fmt.Printf("✓ Successfully uploaded to Minio: %s (%.2f MB)\n", objectName, sizeMB)
-				return info.Size, awsUploaded, nil
+				return bm.finishDualUpload(stats, objectName, res)
 			}
 		}
 
 		// Standard Minio-only upload (no AWS configured or AWS init failed)
-		info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, objectName, reader, -1, minio.PutObjectOptions{
-			ContentType: "application/gzip",
-		})
+		minioStartTime := time.Now()
+		uploaded, err := bm.putObject(ctx, objectName, stats.digestReader(reader), -1, "application/gzip", metadata)
+		minioDuration := time.Since(minioStartTime)
 		if err != nil {
 			if cmd.Process != nil {
 				_ = cmd.Process.Kill()
 			}
 			return 0, false, fmt.Errorf("failed to upload to Minio: %w", err)
 		}
+		if dual {
+			stats.recordDestination(DestinationMinio, nil)
+		}
 
 		if err := cmd.Wait(); err != nil {
 			// Treat tar exit code 1 for "file changed as we read it" as a non-fatal warning
 			var exitErr *exec.ExitError
 			if errors.As(err, &exitErr) {
-				if exitErr.ExitCode() == 1 && strings.Contains(stderr.String(), "file changed as we read it") {
-					fmt.Printf("⚠️  Warning: tar reported non-fatal issue: %s\n", strings.TrimSpace(stderr.String()))
+				if exitErr.ExitCode() == 1 && tarStderr.fileChanged() {
+					fmt.Printf("⚠️  Warning: tar finished with non-fatal warnings (%s)\n", FormatTarWarnings(tarStderr.Counts()))
 				} else {
-					return 0, false, fmt.Errorf("local tar command failed: %w (stderr: %s)", err, stderr.String())
+					return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 				}
 			} else {
-				return 0, false, fmt.Errorf("local tar command failed: %w (stderr: %s)", err, stderr.String())
+				return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 			}
 		}
 
-		sizeMB := float64(info.Size) / (1024 * 1024)
+		bm.fillUploadStats(stats, objectName, uploaded, minioDuration)
+		sizeMB := float64(uploaded) / (1024 * 1024)
 		fmt.Printf("✓ Successfully uploaded to Minio: %s (%.2f MB)\n", objectName, sizeMB)
-		return info.Size, awsUploaded, nil
+		return uploaded, false, nil
 	}
 
 	// Remote (ssh) path - run the tarCmd under bash -lc on the remote side
@@ -2146,6 +2911,7 @@ func (bm *BackupManager) streamBackupToMinio(workingDir, backupName, parentDir,
 		return 0, false, fmt.Errorf("failed to create SSH session: %w", err)
 	}
 	defer session.Close()
+	session.Stdin = tarInput
 
 	// Get stdout pipe
 	stdout, err := session.StdoutPipe()
@@ -2160,124 +2926,130 @@ func (bm *BackupManager) streamBackupToMinio(workingDir, backupName, parentDir,
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to get stderr pipe from SSH session: %w", err)
 	}
-	var remoteStderr bytes.Buffer
+	stderrDone := make(chan struct{})
 	go func() {
-		_, _ = io.Copy(&remoteStderr, remoteStderrPipe)
+		_, _ = io.Copy(tarStderr, remoteStderrPipe)
+		close(stderrDone)
 	}()
 
 	// Start the tar command
 	if err := session.Start(remoteCmd); err != nil {
 		return 0, false, fmt.Errorf("failed to start tar command: %w", err)
 	}
-
-	// Stream directly to Minio
-	ctx := context.Background()
-	// Store backups in a directory named after the site (basename of workingDir)
-	siteName := filepath.Base(workingDir)
-
-	// Build objectName with same supersede semantics as local branch
-	var objectName string
-	if containerBucketPath != "" {
-		objectName = filepath.Join(containerBucketPath, backupName)
-	} else if bm.minioConfig != nil && bm.minioConfig.BucketPath != "" {
-		objectName = filepath.Join(bm.minioConfig.BucketPath, backupName)
-	} else {
-		objectName = fmt.Sprintf("backups/%s/%s", siteName, backupName)
-	}
-
-	// If AWS is configured and includeAWSGlacier flag is set, upload to AWS first using TeeReader
-	var reader io.Reader = stdout
-	if includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != "" {
-		if err := bm.initAWSClient(); err != nil {
-			fmt.Printf("Warning: failed to initialize AWS client, skipping AWS upload: %v\n", err)
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal("KILL")
+		session.Close()
+	})
+	defer stop()
+
+	// If AWS is configured and includeAWSGlacier flag is set, tee the stream to AWS as well
+	var reader io.Reader = stageReader{ctx, bm.status.trackUpload(objectName, stdout)}
+	dual := includeAWSGlacier && bm.awsConfig != nil && bm.awsConfig.Vault != ""
+	if dual {
+		if tmp, err := bm.prepareGlacier(objectName, uncompressedSize); err != nil {
+			if bm.requires(DestinationGlacier) {
+				session.Signal("KILL")
+				return 0, false, err
+			}
+			fmt.Printf("Warning: skipping AWS upload: %v\n", err)
+			stats.recordDestination(DestinationGlacier, err)
 		} else {
-			// Create a pipe to capture the tar output for AWS
-			pr, pw := io.Pipe()
-
-			// Use TeeReader to duplicate the stream
-			reader = io.TeeReader(stdout, pw)
-
-			// Upload to AWS in a goroutine
-			awsErrChan := make(chan error, 1)
-			go func() {
-				defer pw.Close()
-				awsStartTime := time.Now()
-				fmt.Printf("   ☁️  Streaming to AWS Glacier...\n")
-				fmt.Printf("      [AWS] Starting upload at %s\n", awsStartTime.Format("15:04:05"))
-				err := bm.UploadToAWS(objectName, pr, -1)
-				awsEndTime := time.Now()
-				awsDuration := awsEndTime.Sub(awsStartTime)
-				if err != nil {
-					fmt.Printf("      [AWS] Failed after %s: %v\n", awsDuration, err)
-					awsErrChan <- fmt.Errorf("AWS upload failed: %w", err)
-				} else {
-					fmt.Printf("      [AWS] Completed in %s\n", awsDuration)
-					awsErrChan <- nil
-				}
-			}()
-
-			// Continue with Minio upload using the TeeReader
-			fmt.Printf("   📦 Streaming to Minio...\n")
-			info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, objectName, reader, -1, minio.PutObjectOptions{
-				ContentType: "application/gzip",
+			res := bm.uploadDual(ctx, objectName, reader, stats, metadata, !bm.requires(DestinationMinio), func(r io.Reader) (*GlacierUploadStats, error) {
+				return bm.uploadGlacierStream(objectName, r, tmp)
 			})
-			if err != nil {
+			tmp.release()
+			if res.minioErr != nil && bm.requires(DestinationMinio) {
 				session.Signal("KILL") // Kill the session if upload fails
-				return 0, false, fmt.Errorf("failed to upload to Minio: %w", err)
-			}
-
-			// Wait for AWS upload to complete
-			awsErr := <-awsErrChan
-			if awsErr != nil {
-				fmt.Printf("⚠️  Warning: %v\n", awsErr)
-			} else {
-				fmt.Printf("   ✓ AWS Glacier upload complete\n")
-				awsUploaded = true
+				stats.recordDestination(DestinationMinio, res.minioErr)
+				stats.recordDestination(DestinationGlacier, res.glacierErr)
+				return 0, false, fmt.Errorf("failed to upload to Minio: %w", res.minioErr)
 			}
 
 			// Wait for command to complete
-			if err := session.Wait(); err != nil {
+			err = session.Wait()
+			<-stderrDone
+			if err != nil {
 				// If remote tar printed "file changed as we read it" consider it a warning
-				if strings.Contains(remoteStderr.String(), "file changed as we read it") {
-					fmt.Printf("⚠️  Warning: remote tar reported non-fatal issue: %s\n", strings.TrimSpace(remoteStderr.String()))
+				if tarStderr.fileChanged() {
+					fmt.Printf("⚠️  Warning: remote tar finished with non-fatal warnings (%s)\n", FormatTarWarnings(tarStderr.Counts()))
 				} else {
-					return 0, false, fmt.Errorf("tar command failed: %w (remote stderr: %s)", err, remoteStderr.String())
+					return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 				}
 			}
 
-			sizeMB := float64(info.Size) / (1024 * 1024)
-			fmt.Printf("✓ Successfully uploaded to Minio: %s (%.2f MB)\n", objectName, sizeMB)
-			return info.Size, awsUploaded, nil
+			return bm.finishDualUpload(stats, objectName, res)
 		}
 	}
 
 	// Standard Minio-only upload (no AWS configured or AWS init failed)
-	info, err := bm.minioClient.PutObject(ctx, bm.minioConfig.Bucket, objectName, reader, -1, minio.PutObjectOptions{
-		ContentType: "application/gzip",
-	})
+	minioStartTime := time.Now()
+	uploaded, err := bm.putObject(ctx, objectName, stats.digestReader(reader), -1, "application/gzip", metadata)
+	minioDuration := time.Since(minioStartTime)
 	if err != nil {
 		session.Signal("KILL") // Kill the session if upload fails
 		return 0, false, fmt.Errorf("failed to upload to Minio: %w", err)
 	}
+	if dual {
+		stats.recordDestination(DestinationMinio, nil)
+	}
 
 	// Wait for command to complete
-	if err := session.Wait(); err != nil {
+	err = session.Wait()
+	<-stderrDone
+	if err != nil {
 		// If remote tar printed "file changed as we read it" consider it a warning
-		if strings.Contains(remoteStderr.String(), "file changed as we read it") {
-			fmt.Printf("⚠️  Warning: remote tar reported non-fatal issue: %s\n", strings.TrimSpace(remoteStderr.String()))
+		if tarStderr.fileChanged() {
+			fmt.Printf("⚠️  Warning: remote tar finished with non-fatal warnings (%s)\n", FormatTarWarnings(tarStderr.Counts()))
 		} else {
-			return 0, false, fmt.Errorf("tar command failed: %w (remote stderr: %s)", err, remoteStderr.String())
+			return 0, false, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: err}
 		}
 	}
 
-	sizeMB := float64(info.Size) / (1024 * 1024)
+	bm.fillUploadStats(stats, objectName, uploaded, minioDuration)
+	sizeMB := float64(uploaded) / (1024 * 1024)
 	fmt.Printf("✓ Successfully uploaded to Minio: %s (%.2f MB)\n", objectName, sizeMB)
-	return info.Size, awsUploaded, nil
+	return uploaded, false, nil
+}
+
+// finishDualUpload settles a dual upload whose tar finished, returning the
+// size stored and whether Glacier got its copy.
+func (bm *BackupManager) finishDualUpload(stats *UploadStats, objectName string, res dualUploadResult) (int64, bool, error) {
+	policyErr := bm.settleDestinations(stats, res)
+	uploaded := res.minioBytes
+	if res.minioErr == nil {
+		bm.fillUploadStats(stats, objectName, uploaded, res.minioDuration)
+		fmt.Printf("✓ Successfully uploaded to Minio: %s (%.2f MB)\n", objectName, float64(uploaded)/(1024*1024))
+	} else if stats != nil {
+		stats.ObjectKey = objectName
+		if stats.Glacier != nil {
+			uploaded = stats.Glacier.Bytes
+			stats.Bytes = uploaded
+		}
+	}
+	if policyErr != nil {
+		return 0, false, policyErr
+	}
+	return uploaded, res.glacierErr == nil, nil
+}
+
+// fillUploadStats records the Minio side of an upload into stats, if provided.
+func (bm *BackupManager) fillUploadStats(stats *UploadStats, objectName string, size int64, minioDuration time.Duration) {
+	if stats == nil {
+		return
+	}
+	stats.ObjectKey = objectName
+	stats.Bytes = size
+	stats.MinioSeconds = minioDuration.Seconds()
+	stats.MinioMBps = mbps(size, minioDuration)
+	if bm.fileStore == nil {
+		stats.StorageClass = bm.storageClassPolicy().ClassFor(objectName)
+	}
+	bm.logVerbose("Minio upload: %.2f MB in %s (%.2f MB/s)", float64(size)/(1024*1024), minioDuration, stats.MinioMBps)
 }
 
 func (bm *BackupManager) readRemoteFile(filePath string) ([]byte, error) {
 	// If running locally, read the file from disk directly
-	if bm.sshClient == nil {
+	if bm.sshClient == nil && bm.dockerEndpoint == nil {
 		data, err := os.ReadFile(filePath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read file: %w", err)
@@ -2301,7 +3073,7 @@ func (bm *BackupManager) ReadBackup(objectName, outputPath string) error {
 
 	ctx := context.Background()
 
-	obj, err := bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, objectName, minio.GetObjectOptions{})
+	obj, err := bm.openBackupObject(ctx, objectName)
 	if err != nil {
 		return fmt.Errorf("failed to get object '%s': %w", objectName, err)
 	}
@@ -2309,7 +3081,7 @@ func (bm *BackupManager) ReadBackup(objectName, outputPath string) error {
 
 	if outputPath == "" {
 		// Stream to stdout
-		if _, err := io.Copy(os.Stdout, obj); err != nil {
+		if _, err := io.Copy(output.Data(), obj); err != nil {
 			return fmt.Errorf("failed to stream object to stdout: %w", err)
 		}
 		return nil
@@ -2346,7 +3118,7 @@ func (bm *BackupManager) DownloadBackup(objectName string) (io.ReadCloser, error
 	bm.logDebug("DownloadBackup called for object: %s", objectName)
 
 	ctx := context.Background()
-	obj, err := bm.minioClient.GetObject(ctx, bm.minioConfig.Bucket, objectName, minio.GetObjectOptions{})
+	obj, err := bm.openBackupObject(ctx, objectName)
 	if err != nil {
 		bm.logDebug("Failed to get object from Minio: %v", err)
 		return nil, fmt.Errorf("failed to get object '%s': %w", objectName, err)
@@ -2363,28 +3135,10 @@ func (bm *BackupManager) ListBackups(prefix string, limit int) ([]ObjectInfo, er
 		return nil, err
 	}
 
-	ctx := context.Background()
-	opts := minio.ListObjectsOptions{
-		Prefix:    prefix,
-		Recursive: true,
-	}
-
-	var results []ObjectInfo
-	ch := bm.minioClient.ListObjects(ctx, bm.minioConfig.Bucket, opts)
-	for obj := range ch {
-		if obj.Err != nil {
-			return nil, fmt.Errorf("error listing object: %w", obj.Err)
-		}
-		results = append(results, ObjectInfo{
-			Key:          obj.Key,
-			Size:         obj.Size,
-			LastModified: obj.LastModified,
-		})
-		if limit > 0 && len(results) >= limit {
-			break
-		}
+	results, err := bm.listObjects(context.Background(), prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing object: %w", err)
 	}
-
 	return results, nil
 }
 
@@ -2398,10 +3152,10 @@ func (bm *BackupManager) GetLatestObject(prefix string) (string, error) {
 		return "", fmt.Errorf("no objects found for prefix '%s'", prefix)
 	}
 
-	// Find the latest by LastModified
+	// Find the latest by backup time
 	latest := objs[0]
 	for _, o := range objs[1:] {
-		if o.LastModified.After(latest.LastModified) {
+		if BackupTime(o).After(BackupTime(latest)) {
 			latest = o
 		}
 	}
@@ -2416,7 +3170,7 @@ func (bm *BackupManager) DeleteObject(objectName string) error {
 	}
 
 	ctx := context.Background()
-	if err := bm.minioClient.RemoveObject(ctx, bm.minioConfig.Bucket, objectName, minio.RemoveObjectOptions{}); err != nil {
+	if err := bm.removeBackupObject(ctx, objectName); err != nil {
 		return fmt.Errorf("failed to delete object '%s': %w", objectName, err)
 	}
 	return nil
@@ -2424,13 +3178,49 @@ func (bm *BackupManager) DeleteObject(objectName string) error {
 
 // DeleteObjects removes multiple objects from the configured Minio bucket.
 // It attempts to delete each object and aggregates any errors into a single error.
+// Once every object is gone, the deletion is propagated to the replica set
+// with SetReplica, if deletes propagate. Objects inside the delete hold-back
+// window (see SetDeleteHold) are kept and reported in a *DeleteHoldError
+// while the rest are deleted.
 func (bm *BackupManager) DeleteObjects(objectNames []string) error {
+	return bm.deleteObjects(objectNames, true)
+}
+
+// deleteObjects is DeleteObjects, applying the delete hold only when hold
+// is set.
+func (bm *BackupManager) deleteObjects(objectNames []string, hold bool) error {
 	if err := bm.initMinioClient(); err != nil {
 		return err
 	}
 
-	// Use Minio batch RemoveObjects API for performance when deleting many objects.
+	if len(objectNames) > 0 {
+		if err := bm.guardWrite("delete", strings.Join(objectNames, ", ")); err != nil {
+			return err
+		}
+	}
 	ctx := context.Background()
+	var holdErr error
+	if hold {
+		objectNames, holdErr = bm.holdBack(ctx, objectNames)
+	}
+	if len(objectNames) == 0 {
+		return holdErr
+	}
+	if bm.fileStore != nil {
+		var errs []string
+		for _, k := range objectNames {
+			bm.touchSummary(k)
+			if err := bm.fileStore.remove(k); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", k, err))
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(fmt.Errorf("errors deleting objects: %s", strings.Join(errs, "; ")), holdErr)
+		}
+		return errors.Join(bm.propagateDeletions(objectNames), holdErr)
+	}
+
+	// Use Minio batch RemoveObjects API for performance when deleting many objects.
 	objectsCh := make(chan minio.ObjectInfo, len(objectNames))
 	go func() {
 		defer close(objectsCh)
@@ -2439,6 +3229,10 @@ func (bm *BackupManager) DeleteObjects(objectNames []string) error {
 		}
 	}()
 
+	bm.listingDirty.Store(true)
+	for _, k := range objectNames {
+		bm.touchSummary(k)
+	}
 	errCh := bm.minioClient.RemoveObjects(ctx, bm.minioConfig.Bucket, objectsCh, minio.RemoveObjectsOptions{})
 
 	var errs []string
@@ -2448,10 +3242,10 @@ func (bm *BackupManager) DeleteObjects(objectNames []string) error {
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("errors deleting objects: %s", strings.Join(errs, "; "))
+		return errors.Join(fmt.Errorf("errors deleting objects: %s", strings.Join(errs, "; ")), holdErr)
 	}
 
-	return nil
+	return errors.Join(bm.propagateDeletions(objectNames), holdErr)
 }
 
 // ParseNumericRange parses a numeric range string like "1-10" and returns start and end indices.
@@ -2483,33 +3277,43 @@ func (bm *BackupManager) ParseNumericRange(rangeStr string) (int, int, error) {
 	return start, end, nil
 }
 
-// SelectObjectsByNumericRange selects objects by numeric range (1-based, where 1 is most recent).
-// Objects are sorted by LastModified in descending order before selection.
+// SelectObjectsByNumericRange selects backups by numeric range (1-based, where 1 is most recent).
+// Backups are sorted by backup time (see BackupTime) in descending order before selection. Objects that belong
+// to the same backup set (split database/files, shards) count as one backup and are selected
+// together.
 func (bm *BackupManager) SelectObjectsByNumericRange(objs []ObjectInfo, start, end int) ([]ObjectInfo, error) {
 	if len(objs) == 0 {
 		return nil, fmt.Errorf("no objects available")
 	}
 
-	// Sort by LastModified descending (most recent first)
-	sorted := make([]ObjectInfo, len(objs))
-	copy(sorted, objs)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].LastModified.After(sorted[j].LastModified)
-	})
+	var rangeErr error
+	selected := bm.selectBackupSets(objs, true, func(sets []ObjectInfo) []ObjectInfo {
+		// Sort by backup time descending (most recent first)
+		sorted := make([]ObjectInfo, len(sets))
+		copy(sorted, sets)
+		sort.Slice(sorted, func(i, j int) bool {
+			return BackupTime(sorted[i]).After(BackupTime(sorted[j]))
+		})
 
-	// Convert 1-based indices to 0-based
-	startIdx := start - 1
-	endIdx := end - 1
+		// Convert 1-based indices to 0-based
+		startIdx := start - 1
+		endIdx := end - 1
 
-	if startIdx >= len(sorted) {
-		return nil, fmt.Errorf("start index %d exceeds number of objects (%d)", start, len(sorted))
-	}
+		if startIdx >= len(sorted) {
+			rangeErr = fmt.Errorf("start index %d exceeds number of backups (%d)", start, len(sorted))
+			return nil
+		}
 
-	if endIdx >= len(sorted) {
-		endIdx = len(sorted) - 1
-	}
+		if endIdx >= len(sorted) {
+			endIdx = len(sorted) - 1
+		}
 
-	return sorted[startIdx : endIdx+1], nil
+		return sorted[startIdx : endIdx+1]
+	})
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+	return selected, nil
 }
 
 // ParseDateRange parses a date range string in format YYYYMMDD-YYYYMMDD or YYYYMMDD:HHMMSS-YYYYMMDD:HHMMSS
@@ -2552,12 +3356,13 @@ func (bm *BackupManager) ParseDateRange(rangeStr string) (time.Time, time.Time,
 	return startTime, endTime, nil
 }
 
-// FilterObjectsByDateRange filters objects to only include those with LastModified between start and end times (inclusive).
+// FilterObjectsByDateRange filters objects to only include those whose backup
+// time (see BackupTime) is between start and end times (inclusive).
 func (bm *BackupManager) FilterObjectsByDateRange(objs []ObjectInfo, start, end time.Time) []ObjectInfo {
 	var filtered []ObjectInfo
 	for _, o := range objs {
-		if (o.LastModified.Equal(start) || o.LastModified.After(start)) &&
-			(o.LastModified.Equal(end) || o.LastModified.Before(end)) {
+		t := BackupTime(o)
+		if !t.Before(start) && !t.After(end) {
 			filtered = append(filtered, o)
 		}
 	}
@@ -2565,25 +3370,29 @@ func (bm *BackupManager) FilterObjectsByDateRange(objs []ObjectInfo, start, end
 }
 
 // SelectObjectsForOverwrite selects objects for deletion when using the overwrite mode.
-// It sorts objects by LastModified descending (most recent first) and returns all objects
-// except the N most recent ones (where N is the remainder parameter).
-// If remainder is 0, all objects are selected for deletion.
-// If remainder >= total objects, an empty slice is returned (nothing to delete).
+// It sorts backups by backup time descending (most recent first) and returns all objects
+// except those of the N most recent backups (where N is the remainder parameter).
+// If remainder is 0, all complete backups are selected for deletion.
+// If remainder >= total backups, an empty slice is returned (nothing to delete).
+// A backup made of several objects is kept or deleted as a whole, and incomplete
+// backups are never selected.
 func (bm *BackupManager) SelectObjectsForOverwrite(objs []ObjectInfo, remainder int) []ObjectInfo {
-	if len(objs) <= remainder {
-		// Keep all objects if we have fewer or equal to the remainder
-		return []ObjectInfo{}
-	}
+	return bm.selectBackupSets(objs, false, func(sets []ObjectInfo) []ObjectInfo {
+		if len(sets) <= remainder {
+			// Keep all backups if we have fewer or equal to the remainder
+			return nil
+		}
 
-	// Sort by LastModified descending (most recent first)
-	sorted := make([]ObjectInfo, len(objs))
-	copy(sorted, objs)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].LastModified.After(sorted[j].LastModified)
-	})
+		// Sort by backup time descending (most recent first)
+		sorted := make([]ObjectInfo, len(sets))
+		copy(sorted, sets)
+		sort.Slice(sorted, func(i, j int) bool {
+			return BackupTime(sorted[i]).After(BackupTime(sorted[j]))
+		})
 
-	// Return all objects after the first N (remainder) items
-	return sorted[remainder:]
+		// Return all backups after the first N (remainder) items
+		return sorted[remainder:]
+	})
 }
 
 // SelectObjectsWithSmartRetention selects backups to delete using date-aware retention policy
@@ -2593,12 +3402,20 @@ func (bm *BackupManager) SelectObjectsWithSmartRetention(objs []ObjectInfo, poli
 		// Fallback to simple retention: keep all objects
 		return []ObjectInfo{}
 	}
+	// Backups made of several objects are classified once and kept or deleted
+	// as a whole; incomplete ones are left alone.
+	return bm.selectBackupSets(objs, false, func(sets []ObjectInfo) []ObjectInfo {
+		return bm.selectWithSmartRetention(sets, policy)
+	})
+}
 
-	// Sort by LastModified descending (most recent first)
+func (bm *BackupManager) selectWithSmartRetention(objs []ObjectInfo, policy *SmartRetentionPolicy) []ObjectInfo {
+
+	// Sort by backup time descending (most recent first)
 	sorted := make([]ObjectInfo, len(objs))
 	copy(sorted, objs)
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].LastModified.After(sorted[j].LastModified)
+		return BackupTime(sorted[i]).After(BackupTime(sorted[j]))
 	})
 
 	// Classify backups into categories
@@ -2613,14 +3430,17 @@ func (bm *BackupManager) SelectObjectsWithSmartRetention(objs []ObjectInfo, poli
 
 	for _, obj := range sorted {
 		c := classifiedBackup{obj: obj}
+		// Weekdays and days of the month are those of the canonical
+		// timezone, whatever the timezone of the host pruning.
+		taken := InTimezone(BackupTime(obj))
 
 		// Check if this backup qualifies as monthly (day of month matches policy)
-		if obj.LastModified.Day() == policy.MonthlyDay {
+		if taken.Day() == policy.MonthlyDay {
 			c.isMonthly = true
 		}
 
 		// Check if this backup qualifies as weekly (day of week matches policy)
-		if int(obj.LastModified.Weekday()) == policy.WeeklyDay {
+		if int(taken.Weekday()) == policy.WeeklyDay {
 			c.isWeekly = true
 		}
 
@@ -2726,6 +3546,11 @@ func (bm *BackupManager) exportDatabase(container ContainerInfo, options *Backup
 		return fmt.Errorf("no database type specified")
 	}
 
+	// Databases too large to dump are copied at the file level instead
+	if dbConfig.ConsistentCopy != nil {
+		return bm.consistentCopy(container, options)
+	}
+
 	// Use custom export command if provided
 	if dbConfig.ExportCommand != "" {
 		fmt.Printf("Running custom database export command...\n")
@@ -2896,6 +3721,11 @@ func (bm *BackupManager) buildMongoExportCommand(container ContainerInfo, dbConf
 
 // SanitizeBackup extracts specific content from a backup tarball and removes sensitive data
 func (bm *BackupManager) SanitizeBackup(options *SanitizeOptions) error {
+	rules := options.Rules
+	if rules == nil {
+		rules = DefaultSanitizeRules()
+	}
+
 	// Create temporary directory for extraction
 	tmpDir, err := os.MkdirTemp("", "backup-sanitize-*")
 	if err != nil {
@@ -2912,9 +3742,9 @@ func (bm *BackupManager) SanitizeBackup(options *SanitizeOptions) error {
 		fmt.Printf("2. Create temp directory: %s\n", tmpDir)
 		fmt.Printf("3. Extract directories: %v\n", options.ExtractDirs)
 		fmt.Printf("4. Extract files matching: %v\n", options.ExtractFiles)
-		fmt.Println("5. Remove license keys from SQL files")
+		fmt.Printf("5. Apply sanitize rules: %d option(s) removed, %d replacement(s), %d table drop(s), %d file exclusion(s)\n",
+			len(rules.RemoveOptions), len(rules.Replacements), len(rules.DropTables), len(rules.ExcludeFiles))
 		fmt.Printf("6. Create sanitized tarball: %s\n", options.OutputPath)
-		return nil
 	}
 
 	// Create extraction directories
@@ -2925,18 +3755,36 @@ func (bm *BackupManager) SanitizeBackup(options *SanitizeOptions) error {
 		return fmt.Errorf("failed to create sanitized directory: %w", err)
 	}
 
+	report := newSanitizeReport()
+	if options.DryRun {
+		// Preview rule matches against the real content; nothing is written
+		// outside the temporary directory.
+		fmt.Println("\n[DRY RUN] Matched rules:")
+		if err := bm.extractTarball(options.InputPath, extractedDir); err != nil {
+			return fmt.Errorf("failed to extract tarball: %w", err)
+		}
+		if err := bm.filterAndCopyContent(extractedDir, sanitizedDir, options, rules, report); err != nil {
+			return fmt.Errorf("failed to filter content: %w", err)
+		}
+		if err := bm.sanitizeSQLFiles(sanitizedDir, rules, report, true); err != nil {
+			return fmt.Errorf("failed to sanitize SQL files: %w", err)
+		}
+		report.Print()
+		return nil
+	}
+
 	fmt.Println("Step 1: Extracting backup tarball...")
 	if err := bm.extractTarball(options.InputPath, extractedDir); err != nil {
 		return fmt.Errorf("failed to extract tarball: %w", err)
 	}
 
 	fmt.Println("Step 2: Filtering and copying content...")
-	if err := bm.filterAndCopyContent(extractedDir, sanitizedDir, options); err != nil {
+	if err := bm.filterAndCopyContent(extractedDir, sanitizedDir, options, rules, report); err != nil {
 		return fmt.Errorf("failed to filter content: %w", err)
 	}
 
 	fmt.Println("Step 3: Sanitizing SQL files...")
-	if err := bm.sanitizeSQLFiles(sanitizedDir); err != nil {
+	if err := bm.sanitizeSQLFiles(sanitizedDir, rules, report, false); err != nil {
 		return fmt.Errorf("failed to sanitize SQL files: %w", err)
 	}
 
@@ -2945,6 +3793,8 @@ func (bm *BackupManager) SanitizeBackup(options *SanitizeOptions) error {
 		return fmt.Errorf("failed to create sanitized tarball: %w", err)
 	}
 
+	fmt.Println("Rules applied:")
+	report.Print()
 	return nil
 }
 
@@ -2955,14 +3805,16 @@ func (bm *BackupManager) extractTarball(tarballPath, destDir string) error {
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("tar extraction failed: %w (stderr: %s)", err, stderr.String())
+		return &TarError{Op: "extract", Path: tarballPath, Stderr: stderr.String(), Err: err}
 	}
 
 	return nil
 }
 
-// filterAndCopyContent filters and copies content based on extract options
-func (bm *BackupManager) filterAndCopyContent(srcDir, destDir string, options *SanitizeOptions) error {
+// filterAndCopyContent filters and copies content based on extract options and
+// the exclude_files rules. In dry-run mode only SQL files are copied, since
+// they are all the rule preview needs.
+func (bm *BackupManager) filterAndCopyContent(srcDir, destDir string, options *SanitizeOptions, rules *SanitizeRules, report *sanitizeReport) error {
 	// Walk through the extracted content
 	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -2980,6 +3832,14 @@ func (bm *BackupManager) filterAndCopyContent(srcDir, destDir string, options *S
 			return nil
 		}
 
+		if pattern, excluded := rules.excludes(filepath.ToSlash(relPath)); excluded {
+			report.add("exclude_files: "+pattern, 1, "- "+relPath)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Check if this path matches any of the extract directories
 		shouldExtractDir := false
 		for _, extractDir := range options.ExtractDirs {
@@ -3021,6 +3881,9 @@ func (bm *BackupManager) filterAndCopyContent(srcDir, destDir string, options *S
 			if info.IsDir() {
 				return os.MkdirAll(destPath, info.Mode())
 			}
+			if options.DryRun && !strings.HasSuffix(strings.ToLower(path), ".sql") {
+				return nil
+			}
 
 			// Copy file
 			return bm.copyFile(path, destPath, info.Mode())
@@ -3053,12 +3916,9 @@ func (bm *BackupManager) copyFile(src, dst string, mode os.FileMode) error {
 	return err
 }
 
-// sanitizeSQLFiles removes license keys from SQL files
-func (bm *BackupManager) sanitizeSQLFiles(dir string) error {
-	// Use the default list of license keys to remove
-	// This list can be extended or customized as needed
-	optionsToRemove := DefaultLicenseKeysToRemove
-
+// sanitizeSQLFiles applies the SQL rules (option removal, replacements, table
+// drops) to every SQL file under dir. With dryRun the files are only scanned.
+func (bm *BackupManager) sanitizeSQLFiles(dir string, rules *SanitizeRules, report *sanitizeReport, dryRun bool) error {
 	// Find all SQL files
 	var sqlFiles []string
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -3083,7 +3943,7 @@ func (bm *BackupManager) sanitizeSQLFiles(dir string) error {
 
 	for _, sqlFile := range sqlFiles {
 		fmt.Printf("   Sanitizing: %s\n", filepath.Base(sqlFile))
-		if err := bm.removeLicenseKeysFromSQL(sqlFile, optionsToRemove); err != nil {
+		if err := bm.sanitizeSQLFile(sqlFile, rules, report, dryRun); err != nil {
 			fmt.Printf("   Warning: failed to sanitize %s: %v\n", sqlFile, err)
 			continue
 		}
@@ -3092,75 +3952,18 @@ func (bm *BackupManager) sanitizeSQLFiles(dir string) error {
 	return nil
 }
 
-// removeLicenseKeysFromSQL removes license-related entries from a SQL file
-func (bm *BackupManager) removeLicenseKeysFromSQL(sqlFile string, optionsToRemove []string) error {
-	// Read the SQL file
+// sanitizeSQLFile rewrites a SQL file with the rules applied.
+// NOTE: Rules are applied line by line, which works for mysqldump and
+// `wp db export` output. Multi-line INSERT statements and option names that
+// only appear inside other values are not handled specially.
+func (bm *BackupManager) sanitizeSQLFile(sqlFile string, rules *SanitizeRules, report *sanitizeReport, dryRun bool) error {
 	content, err := os.ReadFile(sqlFile)
 	if err != nil {
 		return err
 	}
 
-	sqlContent := string(content)
-	modified := false
-
-	// For each option to remove, delete SQL statements that insert or update it
-	// NOTE: This is a simplified line-based approach that works for most WordPress database dumps.
-	// Potential edge cases this approach might miss:
-	// - Multi-line SQL statements (e.g., INSERT with line breaks)
-	// - Quoted option names that appear in comments or string values
-	// - Complex SQL syntax with subqueries or nested statements
-	// - Different quoting styles (backticks, single quotes, double quotes)
-	// - Escaped characters within option values
-	// - REPLACE, UPSERT, or other non-standard INSERT variations
-	//
-	// For a production-grade solution, consider using a proper SQL parser library like:
-	// - github.com/xwb1989/sqlparser (MySQL)
-	// - github.com/akamensky/sql-parser
-	// - Or calling mysql/mysqldump with specific filtering options
-	for _, option := range optionsToRemove {
-		// Simple line-based removal for statements containing the option
-		lines := strings.Split(sqlContent, "\n")
-		var newLines []string
-		for _, line := range lines {
-			if !strings.Contains(line, option) {
-				newLines = append(newLines, line)
-			} else {
-				modified = true
-			}
-		}
-		sqlContent = strings.Join(newLines, "\n")
-	}
-
-	// Also update the _transient_astra-addon_license_status to 0
-	// This transient should be set to 0 to indicate no license
-	// NOTE: This is a simplified line-based approach. In production, you might want
-	// more sophisticated SQL parsing to handle edge cases like:
-	// - Multi-line statements
-	// - Quoted option names with similar patterns
-	// - Complex SQL syntax with subqueries
-	// - Different quoting styles
-	// For a more robust solution, consider using a proper SQL parser library.
-	lines := strings.Split(sqlContent, "\n")
-	var newLines []string
-	for _, line := range lines {
-		if strings.Contains(line, "_transient_astra-addon_license_status") {
-			// Try to replace common value patterns with 0
-			// This handles INSERT statements like: INSERT INTO `wp_options` VALUES (...,'_transient_astra-addon_license_status','1','yes');
-			modified = true
-			// Replace single-quoted values after the option name
-			// Pattern: '..._transient_astra-addon_license_status','<any_value>','yes'
-			// Replace with: '..._transient_astra-addon_license_status','0','yes'
-			newLine := strings.ReplaceAll(line, "'_transient_astra-addon_license_status','1'", "'_transient_astra-addon_license_status','0'")
-			newLine = strings.ReplaceAll(newLine, "'_transient_astra-addon_license_status',\"1\"", "'_transient_astra-addon_license_status','0'")
-			newLines = append(newLines, newLine)
-		} else {
-			newLines = append(newLines, line)
-		}
-	}
-	sqlContent = strings.Join(newLines, "\n")
-
-	// Write back if modified
-	if modified {
+	sqlContent, modified := rules.sanitizeSQL(string(content), report)
+	if modified && !dryRun {
 		if err := os.WriteFile(sqlFile, []byte(sqlContent), 0644); err != nil {
 			return err
 		}
@@ -3181,7 +3984,7 @@ func (bm *BackupManager) createTarball(srcDir, tarballPath string) error {
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("tar creation failed: %w (stderr: %s)", err, stderr.String())
+		return &TarError{Op: "create", Path: srcDir, Stderr: stderr.String(), Err: err}
 	}
 
 	return nil
@@ -3189,10 +3992,17 @@ func (bm *BackupManager) createTarball(srcDir, tarballPath string) error {
 
 // EstimateCompressedSize estimates the compressed size of a backup using the specified method
 func (bm *BackupManager) EstimateCompressedSize(workingDir, parentDir, method string, sampleSize int64) (compressedSize, uncompressedSize int64, err error) {
+	compressedSize, uncompressedSize, _, err = bm.estimateCompressedSize(workingDir, parentDir, method, sampleSize)
+	return compressedSize, uncompressedSize, err
+}
+
+// estimateCompressedSize is EstimateCompressedSize that also returns how an
+// adaptive estimate was reached (nil for the other methods).
+func (bm *BackupManager) estimateCompressedSize(workingDir, parentDir, method string, sampleSize int64) (compressedSize, uncompressedSize int64, sample *SampleEstimate, err error) {
 	// Get uncompressed size
 	uncompressedSize, err = bm.getDirectorySize(workingDir, parentDir)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get directory size: %w", err)
+		return 0, 0, nil, fmt.Errorf("failed to get directory size: %w", err)
 	}
 
 	switch method {
@@ -3200,117 +4010,58 @@ func (bm *BackupManager) EstimateCompressedSize(workingDir, parentDir, method st
 		compressedSize, err = bm.estimateHeuristic(workingDir, parentDir, uncompressedSize)
 	case "sample":
 		compressedSize, err = bm.estimateSample(workingDir, parentDir, sampleSize, uncompressedSize)
+	case "adaptive":
+		compressedSize, sample, err = bm.estimateAdaptive(workingDir, parentDir, sampleSize, uncompressedSize)
 	case "accurate":
 		compressedSize, err = bm.estimateAccurate(workingDir, parentDir)
 	default:
-		return 0, uncompressedSize, fmt.Errorf("unknown estimation method: %s (use 'heuristic', 'sample', or 'accurate')", method)
+		return 0, uncompressedSize, nil, fmt.Errorf("unknown estimation method: %s (use 'heuristic', 'sample', 'adaptive' or 'accurate')", method)
 	}
 
 	if err != nil {
-		return 0, uncompressedSize, err
+		return 0, uncompressedSize, nil, err
 	}
 
-	return compressedSize, uncompressedSize, nil
+	return compressedSize, uncompressedSize, sample, nil
 }
 
-// estimateHeuristic uses file extension heuristics to estimate compression (instant, ~80% accurate)
+// estimateHeuristic models the compressed size from a file listing: per-extension
+// ratios (calibrated via `backup estimate calibrate` when available), the same
+// excludes as the real tar command, and tar/gzip framing overhead (instant, ~80% accurate)
 func (bm *BackupManager) estimateHeuristic(workingDir, parentDir string, uncompressedSize int64) (int64, error) {
-	// Build command to list all files with sizes and extensions
-	var listCmd string
-	if parentDir != "" {
-		alt := filepath.Join(parentDir, filepath.Base(workingDir))
-		listCmd = fmt.Sprintf(`if [ -d "%s" ]; then find "%s" -type f -printf "%%s %%f\n" 2>/dev/null; elif [ -d "%s" ]; then find "%s" -type f -printf "%%s %%f\n" 2>/dev/null; fi`,
-			workingDir, workingDir, alt, alt)
-	} else {
-		listCmd = fmt.Sprintf(`find "%s" -type f -printf "%%s %%f\n" 2>/dev/null`, workingDir)
-	}
-
-	var output string
-	var stderr string
-	var err error
-
-	if bm.sshClient == nil {
-		// Local execution
-		cmd := exec.Command("bash", "-c", listCmd)
-		var stderrBuf bytes.Buffer
-		cmd.Stderr = &stderrBuf
-		outBytes, execErr := cmd.Output()
-		output = string(outBytes)
-		stderr = stderrBuf.String()
-		err = execErr
-	} else {
-		// Remote execution
-		output, stderr, err = bm.executeCommand(listCmd)
-	}
-
+	// List every entry tar would see: type, size and path relative to the
+	// root, shared with the other phases through the scan cache
+	scan, err := bm.scanDirectory(workingDir, parentDir)
 	if err != nil {
-		return 0, fmt.Errorf("failed to list files: %w (stderr: %s)", err, stderr)
+		return 0, err
 	}
 
-	var estimatedSize int64
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
-
-		size, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			continue
-		}
-
-		filename := parts[1]
-		ext := strings.ToLower(filepath.Ext(filename))
-
-		// Apply compression ratio based on file type
-		switch ext {
-		case ".txt", ".log", ".sql", ".php", ".html", ".css", ".js", ".json", ".xml", ".csv", ".md", ".yml", ".yaml":
-			// Text files: assume 70% compression (keep 30%)
-			estimatedSize += size * 30 / 100
-		case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".mp4", ".mp3", ".avi", ".mov", ".zip", ".gz", ".tgz", ".bz2", ".pdf", ".woff", ".woff2", ".ttf", ".eot":
-			// Already compressed: assume 5% compression (keep 95%)
-			estimatedSize += size * 95 / 100
-		case ".svg", ".otf":
-			// SVG and fonts: assume 50% compression (keep 50%)
-			estimatedSize += size * 50 / 100
-		default:
-			// Unknown: assume 50% compression (keep 50%)
-			estimatedSize += size * 50 / 100
-		}
+	model := bm.compressionModel
+	if model == nil {
+		model = DefaultCompressionModel()
 	}
-
-	// Add tar header overhead (~512 bytes per file)
-	fileCount := len(lines)
-	tarOverhead := int64(fileCount * 512)
-	estimatedSize += tarOverhead
-
-	return estimatedSize, nil
+	return estimateTarGzSize(scan.Entries, scan.Root, model), nil
 }
 
 // estimateSample compresses a sample and extrapolates (fast, ~90% accurate)
-func (bm *BackupManager) estimateSample(workingDir, parentDir string, sampleSize, uncompressedSize int64) (int64, error) {
+func (bm *BackupManager) estimateSample(workingDir, parentDir string, sampleSize, uncompressedSize int64) (_ int64, err error) {
 	// Build tar command that samples data
 	var tarCmd string
 	if parentDir != "" {
 		alt := filepath.Join(parentDir, filepath.Base(workingDir))
-		tarCmd = fmt.Sprintf(`if [ -d "%s" ]; then tar -cf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s" | head -c %d | gzip -c; elif [ -d "%s" ]; then tar -cf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s" | head -c %d | gzip -c; fi`,
+		tarCmd = fmt.Sprintf(`if [ -d "%s" ]; then tar -cf - `+tarExcludeArgs+` "%s" | head -c %d | gzip -c; elif [ -d "%s" ]; then tar -cf - `+tarExcludeArgs+` "%s" | head -c %d | gzip -c; fi`,
 			workingDir, workingDir, sampleSize, alt, alt, sampleSize)
 	} else {
-		tarCmd = fmt.Sprintf(`tar -cf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s" | head -c %d | gzip -c`,
+		tarCmd = fmt.Sprintf(`tar -cf - `+tarExcludeArgs+` "%s" | head -c %d | gzip -c`,
 			workingDir, sampleSize)
 	}
 
 	counter := &countingWriter{}
+	defer func(started time.Time) { bm.auditCommand(tarCmd, started, "", "", err) }(time.Now())
 
 	if bm.sshClient == nil {
 		// Local execution
-		cmd := exec.Command("bash", "-c", tarCmd)
+		cmd := bm.shellCommand(tarCmd)
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
 		cmd.Stdout = counter
@@ -3365,22 +4116,17 @@ func (bm *BackupManager) estimateSample(workingDir, parentDir string, sampleSize
 }
 
 // estimateAccurate performs full compression to a discard writer (100% accurate, same speed as real backup)
-func (bm *BackupManager) estimateAccurate(workingDir, parentDir string) (int64, error) {
-	// Build tar command identical to the real backup
-	var tarCmd string
-	if parentDir != "" {
-		alt := filepath.Join(parentDir, filepath.Base(workingDir))
-		tarCmd = fmt.Sprintf(`if [ -d "%s" ]; then tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"; elif [ -d "%s" ]; then tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"; fi`,
-			workingDir, workingDir, alt, alt)
-	} else {
-		tarCmd = fmt.Sprintf(`tar -czf - --exclude="*.tgz" --exclude="*.tar.gz" --exclude="*.zip" "%s"`, workingDir)
-	}
+func (bm *BackupManager) estimateAccurate(workingDir, parentDir string) (_ int64, err error) {
+	// Build tar command identical to the real backup, so its compression
+	// and archive order are measured too
+	tarCmd := bm.tarCommand(workingDir, parentDir, tarExcludeArgs)
 
 	counter := &countingWriter{}
+	defer func(started time.Time) { bm.auditCommand(tarCmd, started, "", "", err) }(time.Now())
 
 	if bm.sshClient == nil {
 		// Local execution
-		cmd := exec.Command("bash", "-lc", tarCmd)
+		cmd := bm.shellCommand(tarCmd)
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
 		cmd.Stdout = counter
@@ -3432,7 +4178,10 @@ func (bm *BackupManager) EstimateCapacityFromScan(containers []ContainerInfo, es
 		WeeklyRetention:     options.WeeklyRetention,
 		MonthlyRetention:    options.MonthlyRetention,
 		TotalBackupsPerSite: options.DailyRetention + options.WeeklyRetention + options.MonthlyRetention,
+		MigrateAfterDays:    options.MigrateAfterDays,
+		HotBackupsPerSite:   options.HotBackups(),
 		BufferPercent:       options.BufferPercent,
+		Erasure:             options.Erasure,
 		Sites:               make([]SiteEstimate, 0, len(containers)),
 	}
 
@@ -3445,66 +4194,118 @@ func (bm *BackupManager) EstimateCapacityFromScan(containers []ContainerInfo, es
 		fmt.Printf("   Consider using 'heuristic' (~instant) or 'sample' (~5-10 sec/site) for faster estimates.\n\n")
 	}
 
-	fmt.Printf("Scanning %d container(s)...\n", len(containers))
+	parallelism := options.Parallelism
+	if parallelism > 1 && LowMemory() {
+		fmt.Printf("Low-memory mode: scanning one container at a time instead of %d.\n", parallelism)
+		parallelism = 1
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > len(containers) {
+		parallelism = len(containers)
+	}
+	if parallelism > 1 {
+		fmt.Printf("Scanning %d container(s), %d at a time...\n", len(containers), parallelism)
+	} else {
+		fmt.Printf("Scanning %d container(s)...\n", len(containers))
+	}
 	startTime := time.Now()
 
+	// Each worker fills its own slot so Sites keeps the container order no
+	// matter which estimate finishes first; the mutex guards progress output
+	// and the ETA bookkeeping.
+	estimates := make([]*SiteEstimate, len(containers))
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		busyTime time.Duration
+		sem      = make(chan struct{}, parallelism)
+	)
 	for i, container := range containers {
-		containerStart := time.Now()
-		fmt.Printf("  [%d/%d] Analyzing %s...\n", i+1, len(containers), container.Name)
-
-		// Estimate compressed size for this container
-		compressedSize, uncompressedSize, err := bm.EstimateCompressedSize(
-			container.WorkingDir,
-			"", // parentDir not needed for estimation
-			estimateMethod,
-			sampleSize,
-		)
+		wg.Add(1)
+		go func(i int, container ContainerInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			fmt.Printf("  [%d/%d] Analyzing %s...\n", i+1, len(containers), container.Name)
+			mu.Unlock()
+
+			containerStart := time.Now()
+			// Estimate compressed size for this container
+			compressedSize, uncompressedSize, sample, err := bm.estimateCompressedSize(
+				container.WorkingDir,
+				"", // parentDir not needed for estimation
+				estimateMethod,
+				sampleSize,
+			)
+			containerDuration := time.Since(containerStart)
 
-		containerDuration := time.Since(containerStart)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			busyTime += containerDuration
+			if err != nil {
+				fmt.Printf("    ⚠️  Warning: Could not estimate %s: %v\n", container.Name, err)
+				return
+			}
 
-		if err != nil {
-			fmt.Printf("    ⚠️  Warning: Could not estimate %s: %v\n", container.Name, err)
-			continue
-		}
+			compressionRatio := 0.0
+			if uncompressedSize > 0 {
+				compressionRatio = (1.0 - float64(compressedSize)/float64(uncompressedSize)) * 100
+			}
+			estimates[i] = &SiteEstimate{
+				SiteName:         filepath.Base(container.WorkingDir),
+				UncompressedSize: uncompressedSize,
+				CompressedSize:   compressedSize,
+				CompressionRatio: compressionRatio,
+				Sample:           sample,
+			}
 
-		compressionRatio := 0.0
-		if uncompressedSize > 0 {
-			compressionRatio = (1.0 - float64(compressedSize)/float64(uncompressedSize)) * 100
-		}
+			fmt.Printf("    %s: Compressed: %.2f MB, Uncompressed: %.2f MB (%.1f%% saved) [took %s]\n",
+				container.Name,
+				float64(compressedSize)/(1024*1024),
+				float64(uncompressedSize)/(1024*1024),
+				compressionRatio,
+				containerDuration.Round(time.Second))
+			if sample != nil {
+				fmt.Printf("    Sample: %s\n", sample)
+			}
 
-		siteEst := SiteEstimate{
-			SiteName:         filepath.Base(container.WorkingDir),
-			UncompressedSize: uncompressedSize,
-			CompressedSize:   compressedSize,
-			CompressionRatio: compressionRatio,
-		}
+			// Remaining containers run parallelism at a time, except at the
+			// tail where fewer are left than there are workers.
+			remaining := len(containers) - done
+			if remaining > 0 {
+				avgTimePerSite := busyTime / time.Duration(done)
+				lanes := parallelism
+				if remaining < lanes {
+					lanes = remaining
+				}
+				eta := avgTimePerSite * time.Duration(remaining) / time.Duration(lanes)
+				fmt.Printf("    ⏱️  Avg: %s/site, ETA: %s for %d remaining\n",
+					avgTimePerSite.Round(time.Second),
+					eta.Round(time.Second),
+					remaining)
+			}
+		}(i, container)
+	}
+	wg.Wait()
 
+	for _, siteEst := range estimates {
+		if siteEst == nil {
+			continue
+		}
 		// Calculate storage requirements
-		siteEst.HotStorageSize = compressedSize * int64(options.DailyRetention)
-		siteEst.ColdStorageSize = compressedSize * int64(options.WeeklyRetention+options.MonthlyRetention)
+		siteEst.HotStorageSize = options.Erasure.OnDisk(siteEst.CompressedSize * int64(options.HotBackups()))
+		siteEst.ColdStorageSize = siteEst.CompressedSize * int64(options.ColdBackups())
 		siteEst.TotalStorageSize = siteEst.HotStorageSize + siteEst.ColdStorageSize
 
-		result.Sites = append(result.Sites, siteEst)
-		totalCompressed += compressedSize
-		totalUncompressed += uncompressedSize
-
-		// Show timing and ETA
-		avgTimePerSite := time.Since(startTime) / time.Duration(i+1)
-		remaining := len(containers) - (i + 1)
-		eta := avgTimePerSite * time.Duration(remaining)
-
-		fmt.Printf("    Compressed: %.2f MB, Uncompressed: %.2f MB (%.1f%% saved) [took %s]\n",
-			float64(compressedSize)/(1024*1024),
-			float64(uncompressedSize)/(1024*1024),
-			compressionRatio,
-			containerDuration.Round(time.Second))
-
-		if remaining > 0 {
-			fmt.Printf("    ⏱️  Avg: %s/site, ETA: %s for %d remaining\n",
-				avgTimePerSite.Round(time.Second),
-				eta.Round(time.Second),
-				remaining)
-		}
+		result.Sites = append(result.Sites, *siteEst)
+		totalCompressed += siteEst.CompressedSize
+		totalUncompressed += siteEst.UncompressedSize
 	}
 
 	if len(result.Sites) == 0 {
@@ -3524,8 +4325,8 @@ func (bm *BackupManager) EstimateCapacityFromScan(containers []ContainerInfo, es
 	}
 
 	// Calculate per-site storage
-	result.PerSiteHotStorage = result.AvgCompressedSize * int64(options.DailyRetention)
-	result.PerSiteColdStorage = result.AvgCompressedSize * int64(options.WeeklyRetention+options.MonthlyRetention)
+	result.PerSiteHotStorage = options.Erasure.OnDisk(result.AvgCompressedSize * int64(options.HotBackups()))
+	result.PerSiteColdStorage = result.AvgCompressedSize * int64(options.ColdBackups())
 	result.PerSiteTotalStorage = result.PerSiteHotStorage + result.PerSiteColdStorage
 
 	// Calculate fleet-wide storage
@@ -3558,6 +4359,8 @@ func (bm *BackupManager) EstimateCapacityFromScan(containers []ContainerInfo, es
 		}
 	}
 
+	result.Scenarios = CapacityScenarios(result.AvgCompressedSize, result.SitesScanned, options)
+
 	return result, nil
 }
 
@@ -3570,13 +4373,16 @@ func (bm *BackupManager) EstimateCapacityFromManual(avgCompressedSize int64, sit
 		WeeklyRetention:     options.WeeklyRetention,
 		MonthlyRetention:    options.MonthlyRetention,
 		TotalBackupsPerSite: options.DailyRetention + options.WeeklyRetention + options.MonthlyRetention,
+		MigrateAfterDays:    options.MigrateAfterDays,
+		HotBackupsPerSite:   options.HotBackups(),
 		AvgCompressedSize:   avgCompressedSize,
 		BufferPercent:       options.BufferPercent,
+		Erasure:             options.Erasure,
 	}
 
 	// Calculate per-site storage
-	result.PerSiteHotStorage = avgCompressedSize * int64(options.DailyRetention)
-	result.PerSiteColdStorage = avgCompressedSize * int64(options.WeeklyRetention+options.MonthlyRetention)
+	result.PerSiteHotStorage = options.Erasure.OnDisk(avgCompressedSize * int64(options.HotBackups()))
+	result.PerSiteColdStorage = avgCompressedSize * int64(options.ColdBackups())
 	result.PerSiteTotalStorage = result.PerSiteHotStorage + result.PerSiteColdStorage
 
 	// Calculate fleet-wide storage
@@ -3608,6 +4414,8 @@ func (bm *BackupManager) EstimateCapacityFromManual(avgCompressedSize int64, sit
 		}
 	}
 
+	result.Scenarios = CapacityScenarios(result.AvgCompressedSize, result.SitesScanned, options)
+
 	return result, nil
 }
 
@@ -3639,13 +4447,16 @@ func (bm *BackupManager) EstimateCapacityFromBackup(backupKey string, siteCount
 		WeeklyRetention:     options.WeeklyRetention,
 		MonthlyRetention:    options.MonthlyRetention,
 		TotalBackupsPerSite: options.DailyRetention + options.WeeklyRetention + options.MonthlyRetention,
+		MigrateAfterDays:    options.MigrateAfterDays,
+		HotBackupsPerSite:   options.HotBackups(),
 		AvgCompressedSize:   backup.Size,
 		BufferPercent:       options.BufferPercent,
+		Erasure:             options.Erasure,
 	}
 
 	// Calculate per-site storage
-	result.PerSiteHotStorage = backup.Size * int64(options.DailyRetention)
-	result.PerSiteColdStorage = backup.Size * int64(options.WeeklyRetention+options.MonthlyRetention)
+	result.PerSiteHotStorage = options.Erasure.OnDisk(backup.Size * int64(options.HotBackups()))
+	result.PerSiteColdStorage = backup.Size * int64(options.ColdBackups())
 	result.PerSiteTotalStorage = result.PerSiteHotStorage + result.PerSiteColdStorage
 
 	// Calculate fleet-wide storage
@@ -3677,6 +4488,8 @@ func (bm *BackupManager) EstimateCapacityFromBackup(backupKey string, siteCount
 		}
 	}
 
+	result.Scenarios = CapacityScenarios(result.AvgCompressedSize, result.SitesScanned, options)
+
 	return result, nil
 }
 
@@ -3712,3 +4525,24 @@ func calculateGrowthProjections(hotStorage, coldStorage int64, growthRate float6
 
 	return projections
 }
+
+// openMinioTunnel returns the SSH connection Minio traffic is tunnelled
+// through, reusing the manager's own SSH client when it targets the same host.
+func (bm *BackupManager) openMinioTunnel() (*auth.SSHClient, error) {
+	if bm.minioTunnel != nil {
+		return bm.minioTunnel, nil
+	}
+	cfg := bm.minioConfig.SSHTunnel
+	if bm.sshClient != nil && bm.sshClient.GetHostname() == cfg.Hostname {
+		bm.logVerbose("Tunnelling Minio traffic through existing SSH connection to %s", cfg.Hostname)
+		bm.minioTunnel = bm.sshClient
+		return bm.minioTunnel, nil
+	}
+	client, err := auth.NewSSHClient(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSH tunnel to %s for Minio: %w", cfg.Hostname, err)
+	}
+	bm.logVerbose("Tunnelling Minio traffic through SSH to %s", cfg.Hostname)
+	bm.minioTunnel = client
+	return client, nil
+}
@@ -325,3 +325,42 @@ func TestListAndReadRoundTrip(t *testing.T) {
 		t.Fatalf("GetLatestObject returned empty key")
 	}
 }
+
+func TestEstimateCapacityFromScanParallel(t *testing.T) {
+	root := t.TempDir()
+	var containers []ContainerInfo
+	for i, name := range []string{"alpha.com", "bravo.com", "charlie.com"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Join(dir, "www"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := strings.Repeat("<?php echo 'hello'; ?>\n", 1000*(i+1))
+		if err := os.WriteFile(filepath.Join(dir, "www", "index.php"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		containers = append(containers, ContainerInfo{Name: "wp_" + name, WorkingDir: dir})
+	}
+
+	bm := NewBackupManager(nil, nil)
+	serial, err := bm.EstimateCapacityFromScan(containers, "heuristic", 0, &CapacityEstimateOptions{DailyRetention: 14, Parallelism: 1})
+	if err != nil {
+		t.Fatalf("serial EstimateCapacityFromScan() error = %v", err)
+	}
+	parallel, err := bm.EstimateCapacityFromScan(containers, "heuristic", 0, &CapacityEstimateOptions{DailyRetention: 14, Parallelism: 2})
+	if err != nil {
+		t.Fatalf("parallel EstimateCapacityFromScan() error = %v", err)
+	}
+
+	if len(parallel.Sites) != len(containers) {
+		t.Fatalf("parallel scan returned %d sites, want %d", len(parallel.Sites), len(containers))
+	}
+	for i, site := range parallel.Sites {
+		if site != serial.Sites[i] {
+			t.Errorf("site %d: parallel %+v != serial %+v", i, site, serial.Sites[i])
+		}
+	}
+	if parallel.AvgCompressedSize != serial.AvgCompressedSize || parallel.FleetTotalStorage != serial.FleetTotalStorage {
+		t.Errorf("aggregates differ: parallel %d/%d, serial %d/%d",
+			parallel.AvgCompressedSize, parallel.FleetTotalStorage, serial.AvgCompressedSize, serial.FleetTotalStorage)
+	}
+}
@@ -0,0 +1,59 @@
+package backup
+
+// SetMigrationFairness makes oldest-first migration fair across sites: each
+// round takes at most perSite of the oldest objects from every site (the
+// directory of the key) in turn, and the newest backup of a site is never
+// migrated, so no site loses all of its hot backups to Glacier while others
+// keep theirs. Zero restores plain oldest-first selection.
+func (bm *BackupManager) SetMigrationFairness(perSite int) {
+	bm.migratePerSite = perSite
+}
+
+// fairMigrationOrder picks up to n objects from objs, which must be sorted
+// oldest first, round-robin by site, perSite at a time. Sites take turns in
+// the order of their oldest object. Objects of each site's newest backup are
+// held back, so fewer than n may be returned.
+func fairMigrationOrder(objs []ObjectInfo, perSite, n int) []ObjectInfo {
+	var sites []string
+	queues := make(map[string][]ObjectInfo)
+	newest := make(map[string]ObjectInfo)
+	for _, o := range objs {
+		site := inventorySite(o.Key, "")
+		if _, ok := queues[site]; !ok {
+			sites = append(sites, site)
+		}
+		queues[site] = append(queues[site], o)
+		if latest, ok := newest[site]; !ok || !BackupTime(o).Before(BackupTime(latest)) {
+			newest[site] = o
+		}
+	}
+	for site, q := range queues {
+		keep := parseBackupPart(newest[site].Key).Stem
+		eligible := q[:0:0]
+		for _, o := range q {
+			if parseBackupPart(o.Key).Stem != keep {
+				eligible = append(eligible, o)
+			}
+		}
+		queues[site] = eligible
+	}
+
+	var out []ObjectInfo
+	for len(out) < n {
+		progressed := false
+		for _, site := range sites {
+			q := queues[site]
+			take := min(perSite, len(q), n-len(out))
+			if take <= 0 {
+				continue
+			}
+			out = append(out, q[:take]...)
+			queues[site] = q[take:]
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+	return out
+}
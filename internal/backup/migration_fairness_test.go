@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFairMigrationOrder(t *testing.T) {
+	// Oldest first: a.com has a long history, b.com and c.com only a few.
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a.com-20240101-020000.tgz"},
+		{Key: "backups/a.com/a.com-20240102-020000.tgz"},
+		{Key: "backups/a.com/a.com-20240103-020000.tgz"},
+		{Key: "backups/a.com/a.com-20240104-020000.tgz"},
+		{Key: "backups/b.com/b.com-20240105-020000.files.tgz"},
+		{Key: "backups/b.com/b.com-20240105-020000.db.sql.gz"},
+		{Key: "backups/c.com/c.com-20240106-020000.tgz"},
+		{Key: "backups/b.com/b.com-20240107-020000.tgz"},
+		{Key: "backups/a.com/a.com-20240108-020000.tgz"},
+	}
+	keys := func(objs []ObjectInfo) []string {
+		var out []string
+		for _, o := range objs {
+			out = append(out, o.Key)
+		}
+		return out
+	}
+
+	// Plain oldest-first would take four a.com backups.
+	got := keys(fairMigrationOrder(objs, 1, 4))
+	want := []string{
+		"backups/a.com/a.com-20240101-020000.tgz",
+		"backups/b.com/b.com-20240105-020000.files.tgz",
+		"backups/a.com/a.com-20240102-020000.tgz",
+		"backups/b.com/b.com-20240105-020000.db.sql.gz",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fairMigrationOrder(1, 4) = %v, want %v", got, want)
+	}
+
+	got = keys(fairMigrationOrder(objs, 2, 3))
+	want = []string{
+		"backups/a.com/a.com-20240101-020000.tgz",
+		"backups/a.com/a.com-20240102-020000.tgz",
+		"backups/b.com/b.com-20240105-020000.files.tgz",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fairMigrationOrder(2, 3) = %v, want %v", got, want)
+	}
+
+	// Every site keeps its newest backup, even when more is asked for.
+	got = keys(fairMigrationOrder(objs, 10, len(objs)))
+	for _, kept := range []string{
+		"backups/a.com/a.com-20240108-020000.tgz",
+		"backups/b.com/b.com-20240107-020000.tgz",
+		"backups/c.com/c.com-20240106-020000.tgz",
+	} {
+		for _, k := range got {
+			if k == kept {
+				t.Errorf("fairMigrationOrder() migrated %s, the newest backup of its site", kept)
+			}
+		}
+	}
+	if len(got) != 6 {
+		t.Errorf("fairMigrationOrder() selected %d objects, want 6", len(got))
+	}
+}
@@ -0,0 +1,247 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MultisiteInfo describes a WordPress multisite network. It is recorded in
+// the backup manifest so a restore can tell which tables and uploads belong
+// to which sub-site.
+type MultisiteInfo struct {
+	// TablePrefix is the network's base prefix; sub-site N > 1 keeps its
+	// tables under TablePrefix + "N_".
+	TablePrefix      string          `json:"table_prefix,omitempty"`
+	SubdomainInstall bool            `json:"subdomain_install,omitempty"`
+	Sites            []MultisiteSite `json:"sites,omitempty"`
+}
+
+// MultisiteSite is one entry from `wp site list --format=json`.
+type MultisiteSite struct {
+	BlogID   int    `json:"blog_id"`
+	URL      string `json:"url"`
+	Domain   string `json:"domain,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Archived bool   `json:"archived,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"`
+}
+
+// multisiteGlobalTables are the network tables without a blog ID. A sub-site
+// restore never touches them: they hold the network and its users.
+var multisiteGlobalTables = map[string]bool{
+	"blogs":            true,
+	"blog_versions":    true,
+	"blogmeta":         true,
+	"registration_log": true,
+	"signups":          true,
+	"site":             true,
+	"sitemeta":         true,
+	"users":            true,
+	"usermeta":         true,
+}
+
+// detectMultisite returns the network of a WordPress container, or nil for
+// a single site. `wp core is-installed --network` exits non-zero on single
+// sites; metadata that can't be read is left empty.
+func (bm *BackupManager) detectMultisite(container ContainerInfo) *MultisiteInfo {
+	wp := fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root`, container.Name)
+	if _, _, err := bm.executeCommand(wp + " core is-installed --network"); err != nil {
+		return nil
+	}
+
+	info := &MultisiteInfo{}
+	if stdout, _, err := bm.executeCommand(wp + " db prefix"); err == nil {
+		info.TablePrefix = strings.TrimSpace(stdout)
+	}
+	if stdout, _, err := bm.executeCommand(wp + " config get SUBDOMAIN_INSTALL --format=json"); err == nil {
+		info.SubdomainInstall = truthy(unquoteWPOption(stdout))
+	}
+	stdout, stderr, err := bm.executeCommand(wp + " site list --fields=blog_id,url,domain,path,archived,deleted --format=json")
+	if err != nil {
+		bm.logVerbose("Could not list sub-sites of %s: %v (stderr: %s)", container.Name, err, strings.TrimSpace(stderr))
+		return info
+	}
+	if info.Sites, err = parseSiteList(strings.TrimSpace(stdout)); err != nil {
+		bm.logVerbose("Could not parse sub-site list of %s: %v", container.Name, err)
+	}
+	return info
+}
+
+// parseSiteList decodes `wp site list --format=json`, which renders every
+// field as a string, ignoring any warnings wp-cli prints before the array.
+func parseSiteList(out string) ([]MultisiteSite, error) {
+	if i := strings.Index(out, "["); i > 0 {
+		out = out[i:]
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal([]byte(out), &rows); err != nil {
+		return nil, err
+	}
+	str := func(row map[string]any, k string) string {
+		if v, ok := row[k]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	sites := make([]MultisiteSite, 0, len(rows))
+	for _, row := range rows {
+		id, err := strconv.Atoi(str(row, "blog_id"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid blog_id %q", str(row, "blog_id"))
+		}
+		sites = append(sites, MultisiteSite{
+			BlogID:   id,
+			URL:      str(row, "url"),
+			Domain:   str(row, "domain"),
+			Path:     str(row, "path"),
+			Archived: truthy(str(row, "archived")),
+			Deleted:  truthy(str(row, "deleted")),
+		})
+	}
+	return sites, nil
+}
+
+// subsiteTable reports whether table belongs to sub-site blogID of the
+// network with the given prefix. The main site (blog 1) owns the prefixed
+// tables that are neither another sub-site's nor global.
+func subsiteTable(table, prefix string, blogID int) bool {
+	rest, ok := strings.CutPrefix(table, prefix)
+	if !ok {
+		return false
+	}
+	if id, _, ok := strings.Cut(rest, "_"); ok {
+		if n, err := strconv.Atoi(id); err == nil && n > 0 {
+			return n == blogID && blogID > 1
+		}
+	}
+	return blogID == 1 && !multisiteGlobalTables[rest]
+}
+
+// subsiteSQLToTemp writes the statements of r that concern sub-site
+// blogID's tables to a temp file and returns it rewound with the tables it
+// holds. Lines are handled like sanitizeSQL does: one statement per line,
+// apart from multi-line CREATE TABLE.
+func subsiteSQLToTemp(r io.Reader, prefix string, blogID int) (*os.File, []string, error) {
+	tmp, err := os.CreateTemp("", "ciwg-restore-*.sql")
+	if err != nil {
+		return nil, nil, err
+	}
+	fail := func(err error) (*os.File, []string, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, nil, err
+	}
+
+	var tables []string
+	seen := make(map[string]bool)
+	keep, inCreate := true, false
+	br := bufio.NewReaderSize(r, 1<<20)
+	bw := bufio.NewWriterSize(tmp, 1<<20)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case inCreate:
+				inCreate = !strings.HasSuffix(trimmed, ";")
+			case strings.HasPrefix(trimmed, "UNLOCK TABLES"):
+				// Belongs to the preceding LOCK TABLES.
+			default:
+				if m := sqlTableStatement.FindStringSubmatch(line); m != nil {
+					keep = subsiteTable(m[1], prefix, blogID)
+					inCreate = strings.HasPrefix(line, "CREATE TABLE") && !strings.HasSuffix(trimmed, ";")
+					if keep && !seen[m[1]] {
+						seen[m[1]] = true
+						tables = append(tables, m[1])
+					}
+				} else {
+					// Session settings and comments between tables.
+					keep = true
+				}
+			}
+			if keep {
+				if _, werr := bw.WriteString(line); werr != nil {
+					return fail(werr)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if len(tables) == 0 {
+		return fail(fmt.Errorf("no tables of sub-site %d (prefix %q) in the dump", blogID, prefix))
+	}
+	if err := bw.Flush(); err != nil {
+		return fail(err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return tmp, tables, nil
+}
+
+// subsiteUploadsPath returns the path of a site tarball entry relative to
+// wp-content/uploads when it is one of sub-site blogID's uploads: those
+// under sites/<blogID>/ for a sub-site, everything but sites/ for the main
+// site.
+func subsiteUploadsPath(name string, blogID int) (string, bool) {
+	_, rel, ok := strings.Cut(name, "/wp-content/uploads/")
+	if !ok || rel == "" {
+		return "", false
+	}
+	rel = path.Clean(rel)
+	if blogID == 1 {
+		return rel, rel != "sites" && !strings.HasPrefix(rel, "sites/")
+	}
+	dir := fmt.Sprintf("sites/%d", blogID)
+	return rel, rel == dir || strings.HasPrefix(rel, dir+"/")
+}
+
+// copySubsiteUploads copies sub-site blogID's uploads from the site tarball
+// tr to w as a tar rooted at wp-content/uploads, and returns how many files
+// it copied. compat re-packages them as normalizeArchiveHeader describes.
+func copySubsiteUploads(tr *tar.Reader, w io.Writer, blogID int, compat bool) (int, error) {
+	tw := tar.NewWriter(w)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read tarball: %w", err)
+		}
+		normalizeArchiveHeader(hdr, compat)
+		rel, ok := subsiteUploadsPath(hdr.Name, blogID)
+		if !ok || (hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir) {
+			continue
+		}
+		out := *hdr
+		out.Name = rel
+		if hdr.Typeflag == tar.TypeDir {
+			out.Name += "/"
+		}
+		if err := tw.WriteHeader(&out); err != nil {
+			return files, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, tr); err != nil {
+				return files, err
+			}
+			files++
+		}
+	}
+	return files, tw.Close()
+}
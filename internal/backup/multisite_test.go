@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseSiteList(t *testing.T) {
+	out := `Warning: something
+[{"blog_id":"1","url":"https://net.com/","domain":"net.com","path":"/","archived":"0","deleted":"0"},` +
+		`{"blog_id":"3","url":"https://net.com/shop/","domain":"net.com","path":"/shop/","archived":"1","deleted":"0"}]`
+	sites, err := parseSiteList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 2 || sites[1].BlogID != 3 || sites[1].Path != "/shop/" || !sites[1].Archived || sites[0].Archived {
+		t.Errorf("parseSiteList() = %+v", sites)
+	}
+	if _, err := parseSiteList(`[{"blog_id":"x"}]`); err == nil {
+		t.Error("invalid blog_id should fail")
+	}
+}
+
+func TestSubsiteTable(t *testing.T) {
+	tests := []struct {
+		table  string
+		blogID int
+		want   bool
+	}{
+		{"wp_posts", 1, true},
+		{"wp_woocommerce_order_items", 1, true},
+		{"wp_users", 1, false},
+		{"wp_sitemeta", 1, false},
+		{"wp_3_posts", 1, false},
+		{"wp_3_posts", 3, true},
+		{"wp_33_posts", 3, false},
+		{"wp_posts", 3, false},
+		{"other_3_posts", 3, false},
+	}
+	for _, tt := range tests {
+		if got := subsiteTable(tt.table, "wp_", tt.blogID); got != tt.want {
+			t.Errorf("subsiteTable(%q, %d) = %v, want %v", tt.table, tt.blogID, got, tt.want)
+		}
+	}
+}
+
+const multisiteDump = "/*!40101 SET NAMES utf8mb4 */;\n" +
+	"DROP TABLE IF EXISTS `wp_3_posts`;\n" +
+	"CREATE TABLE `wp_3_posts` (\n  `ID` bigint,\n  PRIMARY KEY (`ID`)\n);\n" +
+	"LOCK TABLES `wp_3_posts` WRITE;\n" +
+	"INSERT INTO `wp_3_posts` VALUES (1);\n" +
+	"UNLOCK TABLES;\n" +
+	"DROP TABLE IF EXISTS `wp_posts`;\n" +
+	"CREATE TABLE `wp_posts` (\n  `ID` bigint\n);\n" +
+	"LOCK TABLES `wp_posts` WRITE;\n" +
+	"INSERT INTO `wp_posts` VALUES (2);\n" +
+	"UNLOCK TABLES;\n" +
+	"DROP TABLE IF EXISTS `wp_users`;\n" +
+	"CREATE TABLE `wp_users` (`ID` bigint);\n" +
+	"INSERT INTO `wp_users` VALUES (3);\n" +
+	"/*!40101 SET CHARACTER_SET_CLIENT=@OLD */;\n"
+
+func TestSubsiteSQLToTemp(t *testing.T) {
+	tmp, tables, err := subsiteSQLToTemp(strings.NewReader(multisiteDump), "wp_", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	data, _ := io.ReadAll(tmp)
+	got := string(data)
+	want := "/*!40101 SET NAMES utf8mb4 */;\n" +
+		"DROP TABLE IF EXISTS `wp_3_posts`;\n" +
+		"CREATE TABLE `wp_3_posts` (\n  `ID` bigint,\n  PRIMARY KEY (`ID`)\n);\n" +
+		"LOCK TABLES `wp_3_posts` WRITE;\n" +
+		"INSERT INTO `wp_3_posts` VALUES (1);\n" +
+		"UNLOCK TABLES;\n" +
+		"/*!40101 SET CHARACTER_SET_CLIENT=@OLD */;\n"
+	if got != want {
+		t.Errorf("sub-site 3 dump:\n%s\nwant:\n%s", got, want)
+	}
+	if len(tables) != 1 || tables[0] != "wp_3_posts" {
+		t.Errorf("tables = %v", tables)
+	}
+
+	tmp, tables, err = subsiteSQLToTemp(strings.NewReader(multisiteDump), "wp_", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+	data, _ = io.ReadAll(tmp)
+	if len(tables) != 1 || tables[0] != "wp_posts" || strings.Contains(string(data), "wp_users") || strings.Contains(string(data), "VALUES (1)") {
+		t.Errorf("main site: tables %v, dump:\n%s", tables, data)
+	}
+
+	if _, _, err := subsiteSQLToTemp(strings.NewReader(multisiteDump), "wp_", 7); err == nil {
+		t.Error("a sub-site without tables should fail")
+	}
+}
+
+func TestCopySubsiteUploads(t *testing.T) {
+	files := map[string]string{
+		"net.com/www/wp-content/uploads/2024/01/a.jpg":         "main",
+		"net.com/www/wp-content/uploads/sites/3/2024/01/b.jpg": "three",
+		"net.com/www/wp-content/uploads/sites/33/c.jpg":        "thirty-three",
+		"net.com/www/wp-content/themes/x/style.css":            "theme",
+	}
+	order := []string{
+		"net.com/www/wp-content/uploads/2024/01/a.jpg",
+		"net.com/www/wp-content/uploads/sites/3/2024/01/b.jpg",
+		"net.com/www/wp-content/uploads/sites/33/c.jpg",
+		"net.com/www/wp-content/themes/x/style.css",
+	}
+	extract := func(blogID int) map[string]string {
+		gz, err := gzip.NewReader(bytes.NewReader(buildTarball(t, files, order)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		n, err := copySubsiteUploads(tar.NewReader(gz), &out, blogID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		tr := tar.NewReader(&out)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(tr)
+			got[hdr.Name] = string(body)
+		}
+		if n != len(got) {
+			t.Errorf("copySubsiteUploads() = %d files, tar has %d", n, len(got))
+		}
+		return got
+	}
+
+	if got := extract(3); len(got) != 1 || got["sites/3/2024/01/b.jpg"] != "three" {
+		t.Errorf("sub-site 3 uploads = %v", got)
+	}
+	if got := extract(1); len(got) != 1 || got["2024/01/a.jpg"] != "main" {
+		t.Errorf("main site uploads = %v", got)
+	}
+}
+
+func TestBackupFactsMetadataMultisite(t *testing.T) {
+	facts := &BackupFacts{Container: "wp_net", Multisite: &MultisiteInfo{TablePrefix: "wp_", Sites: []MultisiteSite{{BlogID: 1}, {BlogID: 3}}}}
+	meta := facts.Metadata()
+	if meta["Ciwg-Multisite-Sites"] != "2" || meta["Ciwg-Table-Prefix"] != "wp_" {
+		t.Errorf("Metadata() = %v", meta)
+	}
+}
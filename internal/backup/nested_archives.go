@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DefaultNestedArchiveThreshold is the size from which an archive found
+// inside a site directory is treated as a nested backup or export.
+const DefaultNestedArchiveThreshold int64 = 100 << 20
+
+// nestedArchiveSuffixes are the file name endings of archives that are
+// usually earlier backups or full-site exports: plain and compressed
+// tarballs, zips and the formats of the common WordPress backup plugins
+// (All-in-One WP Migration, Akeeba, Duplicator).
+var nestedArchiveSuffixes = []string{
+	".tar", ".tgz", ".gz", ".zip", ".zst", ".bz2", ".tbz2", ".xz", ".txz",
+	".7z", ".rar", ".wpress", ".jpa", ".daf",
+}
+
+// NestedArchive is a large archive found inside a site directory.
+type NestedArchive struct {
+	// Path is relative to the site directory.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Included is set when the archive was kept in the backup
+	// (--include-nested-archives).
+	Included bool `json:"included,omitempty"`
+}
+
+// isArchiveName reports whether name ends in one of nestedArchiveSuffixes.
+func isArchiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range nestedArchiveSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedArchives returns the regular files of entries that are archives of
+// at least threshold bytes, largest first. Paths in keep (relative, like the
+// entries) are never reported; they are the site's own database export.
+func nestedArchives(entries []tarEntry, threshold int64, keep ...string) []NestedArchive {
+	var found []NestedArchive
+	for _, e := range entries {
+		if e.Type != 'f' || e.Size < threshold || e.Path == "" || !isArchiveName(filepath.Base(e.Path)) {
+			continue
+		}
+		if slices.Contains(keep, e.Path) {
+			continue
+		}
+		found = append(found, NestedArchive{Path: e.Path, Size: e.Size})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Size != found[j].Size {
+			return found[i].Size > found[j].Size
+		}
+		return found[i].Path < found[j].Path
+	})
+	return found
+}
+
+// databaseExportPaths returns the configured database export of container
+// relative to backupDir when it is written inside it, so a compressed export
+// is not mistaken for a nested archive.
+func databaseExportPaths(container ContainerInfo, backupDir string) []string {
+	if container.Config == nil || container.Config.Database.ExportPath == "" {
+		return nil
+	}
+	rel, err := filepath.Rel(backupDir, filepath.Clean(container.Config.Database.ExportPath))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	return []string{rel}
+}
+
+// findNestedArchives lists the archives of at least threshold bytes under
+// workingDir, or parentDir/<basename> when workingDir does not exist. A fresh
+// file scan from earlier in the run is reused; otherwise find only reports
+// the candidates, so a large site is not listed in full.
+func (bm *BackupManager) findNestedArchives(workingDir, parentDir string, threshold int64, keep ...string) ([]NestedArchive, error) {
+	if s := bm.scans.fresh(scanKey(workingDir, parentDir), bm.scanCacheTTL); s != nil {
+		return nestedArchives(s.Entries, threshold, keep...), nil
+	}
+
+	names := make([]string, 0, len(nestedArchiveSuffixes))
+	for _, s := range nestedArchiveSuffixes {
+		names = append(names, fmt.Sprintf(`-iname "*%s"`, s))
+	}
+	list := func(dir string) string {
+		return fmt.Sprintf(`find "%s" -type f -size +%dc \( %s \) -printf "f %%s %%P\0" 2>/dev/null`,
+			dir, threshold-1, strings.Join(names, " -o "))
+	}
+	listCmd := list(workingDir)
+	if parentDir != "" {
+		alt := filepath.Join(parentDir, filepath.Base(workingDir))
+		listCmd = fmt.Sprintf(`if [ -d "%s" ]; then %s; elif [ -d "%s" ]; then %s; fi`, workingDir, list(workingDir), alt, list(alt))
+	}
+	output, stderr, err := bm.executeCommand(listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for nested archives: %w (stderr: %s)", err, stderr)
+	}
+	return nestedArchives(parseFindEntriesSep(output, "\x00"), threshold, keep...), nil
+}
+
+// markIncluded sets Included on the archives tar's default excludes do not
+// drop anyway, for --include-nested-archives.
+func markIncluded(archives []NestedArchive) {
+	for i := range archives {
+		archives[i].Included = !isTarExcluded(archives[i].Path)
+	}
+}
+
+// checkNestedArchives looks for nested archives in the directory container is
+// backed up from when options enable the check. A failed check only warns:
+// the backup goes ahead without the guard.
+func (bm *BackupManager) checkNestedArchives(container ContainerInfo, options *BackupOptions) []NestedArchive {
+	if options.NestedArchiveThreshold <= 0 {
+		return nil
+	}
+	backupDir := container.WorkingDir
+	if container.Config != nil && container.Config.Paths.AppDir != "" {
+		backupDir = container.Config.Paths.AppDir
+	}
+	archives, err := bm.findNestedArchives(backupDir, container.parentDir(options), options.NestedArchiveThreshold, databaseExportPaths(container, backupDir)...)
+	if err != nil {
+		fmt.Printf("   ⚠️  Warning: %v\n", err)
+		return nil
+	}
+	if options.IncludeNestedArchives {
+		markIncluded(archives)
+	}
+	return archives
+}
+
+// nestedArchiveExcludeArgs renders tar --exclude flags for the archives of
+// the site directory siteDir that are not kept and that tar's default
+// excludes do not already cover. The patterns are anchored on the site directory name like the
+// other per-site excludes; file names are shell-quoted and their wildcard
+// characters escaped, since they come from the site.
+func nestedArchiveExcludeArgs(siteDir string, archives []NestedArchive) string {
+	var args []string
+	for _, a := range archives {
+		if a.Included || isTarExcluded(a.Path) {
+			continue
+		}
+		pattern := escapeTarWildcards(filepath.Join(filepath.Base(siteDir), a.Path))
+		args = append(args, "--exclude="+shellQuote(pattern))
+	}
+	return strings.Join(args, " ")
+}
+
+var tarWildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`)
+
+func escapeTarWildcards(s string) string {
+	return tarWildcardEscaper.Replace(s)
+}
+
+// excludedSize is the total size of the archives nestedArchiveExcludeArgs
+// leaves out.
+func excludedSize(archives []NestedArchive) int64 {
+	var n int64
+	for _, a := range archives {
+		if !a.Included && !isTarExcluded(a.Path) {
+			n += a.Size
+		}
+	}
+	return n
+}
+
+// printNestedArchives prints the loud per-site notice.
+func printNestedArchives(prefix string, archives []NestedArchive) {
+	var total int64
+	for _, a := range archives {
+		total += a.Size
+	}
+	fmt.Printf("%s🚨 Found %d nested archive(s) (%.2f MB) in the site directory; remove them at the source:\n",
+		prefix, len(archives), float64(total)/(1024*1024))
+	for _, a := range archives {
+		fmt.Printf("%s   - %s (%.2f MB, %s)\n", prefix, a.Path, float64(a.Size)/(1024*1024), a.state())
+	}
+}
+
+func (a NestedArchive) state() string {
+	if a.Included {
+		return "kept in the backup"
+	}
+	return "excluded"
+}
+
+// nestedArchiveReport prints the nested archives of every upload of the run,
+// so they can be cleaned up where they were left.
+func (r *RunRecord) nestedArchiveReport() {
+	var count int
+	var total int64
+	for _, u := range r.Uploads {
+		for _, a := range u.NestedArchives {
+			count++
+			total += a.Size
+		}
+	}
+	if count == 0 {
+		return
+	}
+	fmt.Printf("Nested archives: %d file(s), %.2f MB; clean them up at the source:\n", count, float64(total)/(1024*1024))
+	for _, u := range r.Uploads {
+		for _, a := range u.NestedArchives {
+			fmt.Printf("   %s: %s (%.2f MB, %s)\n", u.Site, a.Path, float64(a.Size)/(1024*1024), a.state())
+		}
+	}
+}
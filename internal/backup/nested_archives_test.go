@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNestedArchives(t *testing.T) {
+	entries := []tarEntry{
+		{Type: 'd', Path: "www"},
+		{Type: 'f', Size: 300 << 20, Path: "www/old-site.wpress"},
+		{Type: 'f', Size: 500 << 20, Path: "www/backup-2024.tar.gz"},
+		{Type: 'f', Size: 200 << 20, Path: "www/wp-content/uploads/video.mp4"},
+		{Type: 'f', Size: 1 << 20, Path: "www/wp-content/plugins/small.zip"},
+		{Type: 'f', Size: 150 << 20, Path: "db/export.sql.gz"},
+		{Type: 'l', Size: 900 << 20, Path: "www/link.tar"},
+	}
+	got := nestedArchives(entries, 100<<20, "db/export.sql.gz")
+	if len(got) != 2 || got[0].Path != "www/backup-2024.tar.gz" || got[1].Path != "www/old-site.wpress" {
+		t.Fatalf("nestedArchives() = %+v", got)
+	}
+
+	args := nestedArchiveExcludeArgs("/var/opt/sites/a.com", got)
+	if args != `--exclude='a.com/www/old-site.wpress'` {
+		t.Errorf("exclude args = %s", args)
+	}
+	if excludedSize(got) != 300<<20 {
+		t.Errorf("excludedSize() = %d", excludedSize(got))
+	}
+
+	markIncluded(got)
+	if got[0].Included || !got[1].Included || nestedArchiveExcludeArgs("/var/opt/sites/a.com", got) != "" || excludedSize(got) != 0 {
+		t.Errorf("included = %+v", got)
+	}
+}
+
+func TestNestedArchiveExcludeArgsEscapes(t *testing.T) {
+	args := nestedArchiveExcludeArgs("/sites/a.com", []NestedArchive{{Path: "it's [old]*.7z"}})
+	if args != `--exclude='a.com/it'\''s \[old]\*.7z'` {
+		t.Errorf("exclude args = %s", args)
+	}
+}
+
+func TestFindNestedArchives(t *testing.T) {
+	dir := t.TempDir()
+	site := filepath.Join(dir, "a.com")
+	os.MkdirAll(filepath.Join(site, "www"), 0o755)
+	os.WriteFile(filepath.Join(site, "www", "full-export.zip"), make([]byte, 4096), 0o644)
+	os.WriteFile(filepath.Join(site, "www", "tiny.tar"), make([]byte, 10), 0o644)
+	os.WriteFile(filepath.Join(site, "www", "index.php"), make([]byte, 8192), 0o644)
+	os.WriteFile(filepath.Join(site, "dump.sql.gz"), make([]byte, 4096), 0o644)
+
+	bm := NewBackupManager(nil, nil)
+	workingDir := filepath.Join(dir, "missing", "a.com")
+	container := ContainerInfo{Name: "wp_a", WorkingDir: workingDir,
+		Config: &ContainerConfig{Database: DatabaseConfig{ExportPath: filepath.Join(workingDir, "dump.sql.gz")}}}
+	// The working dir is gone; the site is found under the parent dir.
+	got := bm.checkNestedArchives(container, &BackupOptions{ParentDir: dir, NestedArchiveThreshold: 4096})
+	if len(got) != 1 || got[0].Path != "www/full-export.zip" || got[0].Size != 4096 {
+		t.Fatalf("checkNestedArchives() = %+v", got)
+	}
+	if got := bm.checkNestedArchives(container, &BackupOptions{ParentDir: dir}); got != nil {
+		t.Errorf("disabled check = %+v", got)
+	}
+}
+
+func TestValidationNestedArchives(t *testing.T) {
+	rec := &RunRecord{ID: "r1", Uploads: []UploadStats{{Site: "a.com", ObjectKey: "backups/a.com/a.tgz",
+		NestedArchives: []NestedArchive{{Path: "www/old.wpress", Size: 1}}}}}
+	v := siteValidation(rec, "a.com")
+	if len(v.Uploads) != 1 || len(v.Uploads[0].NestedArchives) != 1 || !strings.HasSuffix(v.Uploads[0].NestedArchives[0].Path, ".wpress") {
+		t.Errorf("validation = %+v", v)
+	}
+}
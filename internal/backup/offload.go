@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MediaOffload describes a media offload plugin that keeps a site's uploads
+// in object storage. It is recorded in the backup manifest so a restore knows
+// where media lives, in particular when the local copies were left out.
+type MediaOffload struct {
+	Plugin   string `json:"plugin"`
+	Provider string `json:"provider,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	// RemovesLocal is set when the plugin deletes local files after
+	// offloading them, so the remote bucket is the only copy of the media.
+	RemovesLocal bool `json:"removes_local,omitempty"`
+	// UploadsSkipped is set when wp-content/uploads was left out of the
+	// tarball; SkippedBytes is how much was left out.
+	UploadsSkipped bool   `json:"uploads_skipped,omitempty"`
+	SkippedBytes   int64  `json:"skipped_bytes,omitempty"`
+	RestoreHint    string `json:"restore_hint,omitempty"`
+}
+
+// Remote returns the offload location as a URL, e.g. s3://bucket/prefix, or
+// "" when the bucket is not known.
+func (m *MediaOffload) Remote() string {
+	if m == nil || m.Bucket == "" {
+		return ""
+	}
+	scheme := "s3"
+	switch strings.ToLower(m.Provider) {
+	case "gcp", "gcs", "google":
+		scheme = "gs"
+	}
+	remote := scheme + "://" + m.Bucket
+	if prefix := strings.Trim(m.Prefix, "/"); prefix != "" {
+		remote += "/" + prefix
+	}
+	return remote
+}
+
+func (m *MediaOffload) restoreHint() string {
+	where := m.Remote()
+	if where == "" {
+		where = "the bucket configured in " + m.Plugin
+	}
+	if m.UploadsSkipped {
+		return fmt.Sprintf("wp-content/uploads is not in this backup; its media is in %s. Restore the %s settings, or copy the bucket into wp-content/uploads if the plugin will not be used.", where, m.Plugin)
+	}
+	return fmt.Sprintf("Media is offloaded to %s by %s; keep the bucket when restoring this backup elsewhere.", where, m.Plugin)
+}
+
+// offloadPlugin is how settings are read for one offload plugin: the wp
+// options to fetch and how to turn them into a MediaOffload.
+type offloadPlugin struct {
+	options []string
+	parse   func(opts map[string]string) MediaOffload
+}
+
+// mediaOffloadPlugins maps plugin slugs to their settings readers.
+var mediaOffloadPlugins = map[string]offloadPlugin{
+	"amazon-s3-and-cloudfront":     {options: []string{"tantan_wordpress_s3"}, parse: parseWPOffloadMedia},
+	"amazon-s3-and-cloudfront-pro": {options: []string{"tantan_wordpress_s3"}, parse: parseWPOffloadMedia},
+	"ilab-media-tools": {
+		options: []string{"mcloud-storage-provider", "mcloud-storage-s3-bucket", "mcloud-storage-s3-region", "mcloud-storage-prefix", "mcloud-storage-delete-uploads"},
+		parse: func(opts map[string]string) MediaOffload {
+			return MediaOffload{
+				Provider:     opts["mcloud-storage-provider"],
+				Bucket:       opts["mcloud-storage-s3-bucket"],
+				Region:       opts["mcloud-storage-s3-region"],
+				Prefix:       opts["mcloud-storage-prefix"],
+				RemovesLocal: truthy(opts["mcloud-storage-delete-uploads"]),
+			}
+		},
+	},
+	"wp-stateless": {
+		options: []string{"sm_bucket", "sm_mode", "sm_root_dir"},
+		parse: func(opts map[string]string) MediaOffload {
+			mode := opts["sm_mode"]
+			return MediaOffload{
+				Provider:     "gcs",
+				Bucket:       opts["sm_bucket"],
+				Prefix:       opts["sm_root_dir"],
+				RemovesLocal: mode == "stateless" || mode == "ephemeral",
+			}
+		},
+	},
+}
+
+// parseWPOffloadMedia reads the settings array of WP Offload Media, which
+// `wp option get --format=json` renders as a JSON object.
+func parseWPOffloadMedia(opts map[string]string) MediaOffload {
+	var settings map[string]any
+	if err := json.Unmarshal([]byte(opts["tantan_wordpress_s3"]), &settings); err != nil {
+		return MediaOffload{}
+	}
+	str := func(k string) string {
+		if v, ok := settings[k]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	return MediaOffload{
+		Provider:     str("provider"),
+		Bucket:       str("bucket"),
+		Region:       str("region"),
+		Prefix:       str("object-prefix"),
+		RemovesLocal: truthy(str("remove-local-file")),
+	}
+}
+
+func truthy(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// findOffloadPlugin returns the first active offload plugin in plugins.
+func findOffloadPlugin(plugins []PluginFact) (string, offloadPlugin, bool) {
+	for _, p := range plugins {
+		if p.Status != "active" && p.Status != "active-network" {
+			continue
+		}
+		if op, ok := mediaOffloadPlugins[p.Name]; ok {
+			return p.Name, op, true
+		}
+	}
+	return "", offloadPlugin{}, false
+}
+
+// detectMediaOffload looks for an active media offload plugin in a WordPress
+// container and reads its bucket settings. plugins is the plugin list from
+// the backup facts; it is fetched when nil. Settings that can't be read are
+// left empty; nil means no offload plugin is active.
+func (bm *BackupManager) detectMediaOffload(container ContainerInfo, plugins []PluginFact) *MediaOffload {
+	wp := fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root`, container.Name)
+	if plugins == nil {
+		stdout, stderr, err := bm.executeCommand(wp + " plugin list --format=json")
+		if err != nil {
+			bm.logVerbose("Could not list plugins for %s: %v (stderr: %s)", container.Name, err, strings.TrimSpace(stderr))
+			return nil
+		}
+		if plugins, err = parsePluginList(strings.TrimSpace(stdout)); err != nil {
+			bm.logVerbose("Could not parse plugin list for %s: %v", container.Name, err)
+			return nil
+		}
+	}
+
+	slug, op, ok := findOffloadPlugin(plugins)
+	if !ok {
+		return nil
+	}
+	opts := make(map[string]string, len(op.options))
+	for _, name := range op.options {
+		stdout, _, err := bm.executeCommand(fmt.Sprintf("%s option get %s --format=json", wp, name))
+		if err != nil {
+			bm.logVerbose("Option %s not set for %s", name, container.Name)
+			continue
+		}
+		opts[name] = unquoteWPOption(stdout)
+	}
+	offload := op.parse(opts)
+	offload.Plugin = slug
+	return &offload
+}
+
+// unquoteWPOption turns the JSON rendering of a scalar option back into its
+// value; arrays and objects are returned as JSON.
+func unquoteWPOption(out string) string {
+	out = strings.TrimSpace(out)
+	var s string
+	if err := json.Unmarshal([]byte(out), &s); err == nil {
+		return s
+	}
+	return out
+}
+
+// uploadsDir is where a WordPress site keeps wp-content/uploads on the host.
+func uploadsDir(siteDir string) string {
+	return filepath.Join(siteDir, "www", "wp-content", "uploads")
+}
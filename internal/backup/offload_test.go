@@ -0,0 +1,50 @@
+package backup
+
+import "testing"
+
+func TestFindOffloadPlugin(t *testing.T) {
+	plugins := []PluginFact{
+		{Name: "akismet", Status: "active"},
+		{Name: "wp-stateless", Status: "inactive"},
+		{Name: "amazon-s3-and-cloudfront", Status: "active"},
+	}
+	slug, _, ok := findOffloadPlugin(plugins)
+	if !ok || slug != "amazon-s3-and-cloudfront" {
+		t.Errorf("findOffloadPlugin() = %q, %v", slug, ok)
+	}
+	if _, _, ok := findOffloadPlugin(plugins[:2]); ok {
+		t.Error("findOffloadPlugin() matched an inactive plugin")
+	}
+}
+
+func TestParseWPOffloadMedia(t *testing.T) {
+	opts := map[string]string{
+		"tantan_wordpress_s3": unquoteWPOption(`{"provider":"aws","bucket":"site-media","region":"us-east-2","object-prefix":"wp-content/uploads/","remove-local-file":"1"}`),
+	}
+	got := parseWPOffloadMedia(opts)
+	if got.Bucket != "site-media" || got.Region != "us-east-2" || !got.RemovesLocal {
+		t.Errorf("parseWPOffloadMedia() = %+v", got)
+	}
+	if remote := got.Remote(); remote != "s3://site-media/wp-content/uploads" {
+		t.Errorf("Remote() = %q", remote)
+	}
+	if got := parseWPOffloadMedia(map[string]string{}); got.Bucket != "" {
+		t.Errorf("parseWPOffloadMedia(no settings) = %+v", got)
+	}
+}
+
+func TestMediaCloudAndStatelessSettings(t *testing.T) {
+	mc := mediaOffloadPlugins["ilab-media-tools"].parse(map[string]string{
+		"mcloud-storage-provider":       "s3",
+		"mcloud-storage-s3-bucket":      "mc-bucket",
+		"mcloud-storage-delete-uploads": unquoteWPOption(`"1"`),
+	})
+	if mc.Bucket != "mc-bucket" || !mc.RemovesLocal {
+		t.Errorf("Media Cloud settings = %+v", mc)
+	}
+
+	ws := mediaOffloadPlugins["wp-stateless"].parse(map[string]string{"sm_bucket": "gcs-media", "sm_mode": "cdn"})
+	if ws.Remote() != "gs://gcs-media" || ws.RemovesLocal {
+		t.Errorf("WP-Stateless settings = %+v (%s)", ws, ws.Remote())
+	}
+}
@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerOverride assigns a site to a container whose compose labels are
+// missing or wrong. Overrides are read from the overrides file
+// (~/.ciwg/container-overrides.yaml) and win over docker inspection:
+//
+//	containers:
+//	  wp_legacy:
+//	    working_dir: /var/opt/sites/legacy.com
+//	    type: wordpress          # optional, default wordpress
+//	    parent_dir: /srv/old     # optional, replaces --container-parent-dir
+type ContainerOverride struct {
+	WorkingDir string `yaml:"working_dir"`
+	Type       string `yaml:"type,omitempty"`
+	ParentDir  string `yaml:"parent_dir,omitempty"`
+}
+
+// ContainerOverrides maps container names to their overrides.
+type ContainerOverrides map[string]ContainerOverride
+
+type containerOverridesFile struct {
+	Containers ContainerOverrides `yaml:"containers"`
+}
+
+// DefaultContainerOverridesPath returns the default location of the overrides
+// file (~/.ciwg/container-overrides.yaml).
+func DefaultContainerOverridesPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "container-overrides.yaml")
+}
+
+// LoadContainerOverrides reads the overrides file at path. A missing file
+// yields no overrides.
+func LoadContainerOverrides(path string) (ContainerOverrides, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read container overrides: %w", err)
+	}
+	var file containerOverridesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse container overrides %s: %w", path, err)
+	}
+	for name, o := range file.Containers {
+		if o.WorkingDir == "" {
+			return nil, fmt.Errorf("container %q in %s has no working_dir", name, path)
+		}
+		if !filepath.IsAbs(o.WorkingDir) {
+			return nil, fmt.Errorf("container %q in %s: working_dir must be absolute, got %s", name, path, o.WorkingDir)
+		}
+		if o.ParentDir != "" && !filepath.IsAbs(o.ParentDir) {
+			return nil, fmt.Errorf("container %q in %s: parent_dir must be absolute, got %s", name, path, o.ParentDir)
+		}
+	}
+	return file.Containers, nil
+}
+
+// SetContainerOverrides sets the overrides consulted before docker inspection
+// when resolving and discovering containers.
+func (bm *BackupManager) SetContainerOverrides(o ContainerOverrides) {
+	bm.containerOverrides = o
+}
+
+// apply fills in the site details of c from its override, if any.
+func (o ContainerOverrides) apply(c ContainerInfo) ContainerInfo {
+	ov, ok := o[c.Name]
+	if !ok {
+		return c
+	}
+	c.WorkingDir = ov.WorkingDir
+	if ov.Type != "" {
+		c.Type = ov.Type
+	}
+	if ov.ParentDir != "" {
+		c.ParentDir = ov.ParentDir
+	}
+	return c
+}
+
+// byWorkingDir returns the overridden container whose working directory is
+// dir. Names are checked in order so the answer is stable.
+func (o ContainerOverrides) byWorkingDir(dir string) (string, bool) {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if o[name].WorkingDir == dir {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// StarterContainerOverrides renders an overrides file from the current docker
+// state: every discovered site with its working directory, and every skipped
+// container with an empty working_dir to be filled in by hand.
+func StarterContainerOverrides(sites []ContainerInfo, skipped []string) ([]byte, error) {
+	containers := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(name string, o ContainerOverride, comment string) {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: name, HeadComment: comment}
+		value := &yaml.Node{Kind: yaml.MappingNode}
+		fields := [][2]string{{"working_dir", o.WorkingDir}, {"type", o.Type}}
+		if o.ParentDir != "" {
+			fields = append(fields, [2]string{"parent_dir", o.ParentDir})
+		}
+		for _, f := range fields {
+			value.Content = append(value.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: f[0]},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: f[1], Style: yaml.DoubleQuotedStyle})
+		}
+		containers.Content = append(containers.Content, key, value)
+	}
+
+	for _, s := range sites {
+		typ := s.Type
+		if typ == "" {
+			typ = "wordpress"
+		}
+		comment := ""
+		if s.Project != "" && s.Project != s.Name {
+			comment = "compose project " + s.Project
+		}
+		if len(s.Services) > 0 {
+			comment = strings.TrimSpace(comment + " with " + strings.Join(s.Services, ", "))
+		}
+		add(s.Name, ContainerOverride{WorkingDir: s.WorkingDir, Type: typ, ParentDir: s.ParentDir}, comment)
+	}
+	for _, name := range skipped {
+		add(name, ContainerOverride{Type: "wordpress"}, "TODO: no compose working_dir label; set working_dir or remove this entry")
+	}
+
+	doc := &yaml.Node{
+		Kind: yaml.MappingNode,
+		HeadComment: "Container overrides for ciwg-cli backup. Entries here are used instead of\n" +
+			"the docker compose working_dir label when resolving containers.",
+		Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "containers"}, containers},
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode container overrides: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode container overrides: %w", err)
+	}
+	return buf.Bytes(), nil
+}
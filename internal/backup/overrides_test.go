@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadContainerOverrides(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := LoadContainerOverrides(filepath.Join(dir, "missing.yaml"))
+	if err != nil || got != nil {
+		t.Fatalf("LoadContainerOverrides(missing) = %v, %v, want nil, nil", got, err)
+	}
+
+	path := filepath.Join(dir, "overrides.yaml")
+	data := `containers:
+  wp_legacy:
+    working_dir: /var/opt/sites/legacy.com
+  old_app:
+    working_dir: /srv/old/app
+    type: custom
+    parent_dir: /srv/old
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadContainerOverrides(path)
+	if err != nil {
+		t.Fatalf("LoadContainerOverrides() error = %v", err)
+	}
+	want := ContainerOverrides{
+		"wp_legacy": {WorkingDir: "/var/opt/sites/legacy.com"},
+		"old_app":   {WorkingDir: "/srv/old/app", Type: "custom", ParentDir: "/srv/old"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadContainerOverrides() = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{
+		"containers:\n  wp_x:\n    type: wordpress\n",
+		"containers:\n  wp_x:\n    working_dir: sites/x\n",
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadContainerOverrides(path); err == nil {
+			t.Errorf("LoadContainerOverrides(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestGroupComposeStacksWithOverrides(t *testing.T) {
+	containers := []composeContainer{
+		{Name: "wp_legacy", Image: "wordpress"},
+		{Name: "old_app", Image: "acme/php-app"},
+		{Name: "wp_other", Image: "wordpress"},
+	}
+	overrides := ContainerOverrides{
+		"wp_legacy": {WorkingDir: "/var/opt/sites/legacy.com"},
+		"old_app":   {WorkingDir: "/srv/old/app", Type: "custom", ParentDir: "/srv/old"},
+	}
+
+	sites, skipped := groupComposeStacks(containers, overrides)
+	want := []ContainerInfo{
+		{Name: "old_app", WorkingDir: "/srv/old/app", Type: "custom", Project: "old_app", ParentDir: "/srv/old"},
+		{Name: "wp_legacy", WorkingDir: "/var/opt/sites/legacy.com", Type: "wordpress", Project: "wp_legacy"},
+	}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("groupComposeStacks() sites =\n%+v\nwant\n%+v", sites, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"wp_other"}) {
+		t.Errorf("groupComposeStacks() skipped = %v, want [wp_other]", skipped)
+	}
+}
+
+func TestContainerOverridesByWorkingDir(t *testing.T) {
+	o := ContainerOverrides{
+		"b": {WorkingDir: "/srv/site"},
+		"a": {WorkingDir: "/srv/site"},
+	}
+	if name, ok := o.byWorkingDir("/srv/site"); !ok || name != "a" {
+		t.Errorf("byWorkingDir() = %q, %v, want a, true", name, ok)
+	}
+	if _, ok := o.byWorkingDir("/srv/other"); ok {
+		t.Error("byWorkingDir(/srv/other) found a container, want none")
+	}
+}
+
+func TestStarterContainerOverrides(t *testing.T) {
+	sites := []ContainerInfo{
+		{Name: "wp_foo", WorkingDir: "/var/opt/sites/foo", Type: "wordpress", Project: "foo", Services: []string{"foo-redis-1"}},
+	}
+	out, err := StarterContainerOverrides(sites, []string{"wp_legacy"})
+	if err != nil {
+		t.Fatalf("StarterContainerOverrides() error = %v", err)
+	}
+	if !strings.Contains(string(out), "TODO") {
+		t.Errorf("starter file does not mark skipped containers:\n%s", out)
+	}
+
+	// The skipped entry must be filled in before the file loads.
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadContainerOverrides(path); err == nil {
+		t.Error("LoadContainerOverrides() accepted an unfilled starter entry")
+	}
+
+	filled := strings.Replace(string(out), `working_dir: ""`, `working_dir: "/var/opt/sites/legacy.com"`, 1)
+	if err := os.WriteFile(path, []byte(filled), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadContainerOverrides(path)
+	if err != nil {
+		t.Fatalf("LoadContainerOverrides() error = %v", err)
+	}
+	want := ContainerOverrides{
+		"wp_foo":    {WorkingDir: "/var/opt/sites/foo", Type: "wordpress"},
+		"wp_legacy": {WorkingDir: "/var/opt/sites/legacy.com", Type: "wordpress"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round-tripped overrides = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// This is a deliberately small Parquet writer: flat schema, REQUIRED columns,
+// PLAIN encoding, no compression, a single row group with one data page per
+// column. That is all the inventory export needs and it is readable by
+// Spark, DuckDB, pandas/pyarrow and warehouse loaders.
+
+// Parquet physical and converted types used by the writer.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetNoConvertedType = -1
+)
+
+// parquetColumn is one column of values; exactly one of the value slices
+// matching Type is used.
+type parquetColumn struct {
+	Name          string
+	Type          int32
+	ConvertedType int32
+	Strings       []string
+	Int64s        []int64
+	Bools         []bool
+}
+
+func (c *parquetColumn) len() int {
+	switch c.Type {
+	case parquetByteArray:
+		return len(c.Strings)
+	case parquetInt64:
+		return len(c.Int64s)
+	default:
+		return len(c.Bools)
+	}
+}
+
+// plainValues encodes the column values with the PLAIN encoding.
+func (c *parquetColumn) plainValues() []byte {
+	var b bytes.Buffer
+	switch c.Type {
+	case parquetByteArray:
+		for _, s := range c.Strings {
+			_ = binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+			b.WriteString(s)
+		}
+	case parquetInt64:
+		for _, v := range c.Int64s {
+			_ = binary.Write(&b, binary.LittleEndian, v)
+		}
+	default:
+		packed := make([]byte, (len(c.Bools)+7)/8)
+		for i, v := range c.Bools {
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		b.Write(packed)
+	}
+	return b.Bytes()
+}
+
+// writeParquet writes cols as a Parquet file. All columns must have the same
+// number of values.
+func writeParquet(w io.Writer, cols []parquetColumn) error {
+	numRows := 0
+	if len(cols) > 0 {
+		numRows = cols[0].len()
+	}
+	for i := range cols {
+		if cols[i].len() != numRows {
+			return fmt.Errorf("parquet column %s has %d values, want %d", cols[i].Name, cols[i].len(), numRows)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("PAR1")
+
+	type chunkInfo struct {
+		offset int64
+		size   int64
+	}
+	chunks := make([]chunkInfo, len(cols))
+	var totalSize int64
+	for i := range cols {
+		data := cols[i].plainValues()
+
+		var hdr thriftCompactWriter
+		hdr.i32(1, 0) // type = DATA_PAGE
+		hdr.i32(2, int32(len(data)))
+		hdr.i32(3, int32(len(data)))
+		hdr.structBegin(5) // data_page_header
+		hdr.i32(1, int32(numRows))
+		hdr.i32(2, 0) // encoding = PLAIN
+		hdr.i32(3, 3) // definition_level_encoding = RLE
+		hdr.i32(4, 3) // repetition_level_encoding = RLE
+		hdr.structEnd()
+		hdr.stop()
+
+		chunks[i].offset = int64(out.Len())
+		out.Write(hdr.buf.Bytes())
+		out.Write(data)
+		chunks[i].size = int64(out.Len()) - chunks[i].offset
+		totalSize += chunks[i].size
+	}
+
+	var meta thriftCompactWriter
+	meta.i32(1, 1) // version
+	meta.listBegin(2, thriftStruct, len(cols)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(cols)))
+	meta.elemEnd()
+	for _, c := range cols {
+		meta.elemBegin()
+		meta.i32(1, c.Type)
+		meta.i32(3, 0) // repetition_type = REQUIRED
+		meta.binary(4, c.Name)
+		if c.ConvertedType != parquetNoConvertedType {
+			meta.i32(6, c.ConvertedType)
+		}
+		meta.elemEnd()
+	}
+	meta.i64(3, int64(numRows))
+	meta.listBegin(4, thriftStruct, 1)
+	meta.elemBegin() // RowGroup
+	meta.listBegin(1, thriftStruct, len(cols))
+	for i, c := range cols {
+		meta.elemBegin() // ColumnChunk
+		meta.i64(2, chunks[i].offset)
+		meta.structBegin(3) // ColumnMetaData
+		meta.i32(1, c.Type)
+		meta.listBegin(2, thriftI32, 1)
+		meta.rawI32(0) // PLAIN
+		meta.listBegin(3, thriftBinary, 1)
+		meta.rawBinary(c.Name)
+		meta.i32(4, 0) // codec = UNCOMPRESSED
+		meta.i64(5, int64(numRows))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.structEnd()
+		meta.elemEnd()
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(numRows))
+	meta.elemEnd()
+	meta.binary(6, "ciwg-cli")
+	meta.stop()
+
+	out.Write(meta.buf.Bytes())
+	_ = binary.Write(&out, binary.LittleEndian, uint32(meta.buf.Len()))
+	out.WriteString("PAR1")
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Thrift compact protocol element types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompactWriter encodes the handful of Thrift compact protocol
+// constructs needed for Parquet page headers and file metadata.
+type thriftCompactWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftCompactWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	t.buf.Write(tmp[:n])
+}
+
+func zigzag64(v int64) uint64 { return uint64((v << 1) ^ (v >> 63)) }
+
+func (t *thriftCompactWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag64(int64(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftCompactWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.rawI32(v)
+}
+
+func (t *thriftCompactWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag64(v))
+}
+
+func (t *thriftCompactWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftCompactWriter) rawI32(v int32) { t.varint(zigzag64(int64(v))) }
+
+func (t *thriftCompactWriter) rawBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompactWriter) listBegin(id int16, elemType byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(n))
+	}
+}
+
+// structBegin starts a nested struct field; elemBegin starts a struct that is
+// a list element (no field header).
+func (t *thriftCompactWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftCompactWriter) elemBegin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftCompactWriter) structEnd() { t.elemEnd() }
+
+func (t *thriftCompactWriter) elemEnd() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop writes the field stop marker that ends a struct.
+func (t *thriftCompactWriter) stop() { t.buf.WriteByte(0) }
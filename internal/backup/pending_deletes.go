@@ -0,0 +1,292 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PendingDelete is a Minio object that was archived to Glacier by a
+// migration but could not be deleted afterwards. Until it is, it takes up
+// hot space and would be migrated again, so migrations skip it and `backup
+// reconcile` (or the monitor, before migrating) retries the delete.
+type PendingDelete struct {
+	ObjectKey string `json:"object_key"`
+	Bucket    string `json:"bucket"`
+	// Size is the size of the object that was archived. An object of another
+	// size under the same key is not the one that was archived and is not
+	// deleted.
+	Size      int64     `json:"size"`
+	Vault     string    `json:"vault,omitempty"`
+	ArchiveID string    `json:"archive_id,omitempty"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Attempts  int       `json:"attempts,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// DefaultPendingDeletesPath returns the default location of the pending
+// deletes list (~/.ciwg/pending-deletes.jsonl).
+func DefaultPendingDeletesPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-pending-deletes.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "pending-deletes.jsonl")
+}
+
+// SetPendingDeletesFile makes migrations record objects they archived but
+// failed to delete in the list at path, and skip the objects already on it.
+// An empty path disables the list.
+func (bm *BackupManager) SetPendingDeletesFile(path string) {
+	bm.pendingDeletesFile = path
+}
+
+// AppendPendingDelete adds p to the list at path, creating the file and its
+// parent directory if needed.
+func AppendPendingDelete(path string, p *PendingDelete) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create pending deletes directory: %w", err)
+		}
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending delete: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open pending deletes file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write pending delete: %w", err)
+	}
+	return nil
+}
+
+// LoadPendingDeletes reads the list at path. A missing file yields an empty
+// list. Malformed lines are skipped.
+func LoadPendingDeletes(path string) ([]PendingDelete, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open pending deletes file: %w", err)
+	}
+	defer f.Close()
+
+	var out []PendingDelete
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var p PendingDelete
+		if err := json.Unmarshal(line, &p); err != nil {
+			continue
+		}
+		out = append(out, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending deletes file: %w", err)
+	}
+	return out, nil
+}
+
+// savePendingDeletes replaces the first `loaded` entries of the list at path
+// with remaining, keeping entries appended since they were loaded.
+func savePendingDeletes(path string, loaded int, remaining []PendingDelete) error {
+	current, err := LoadPendingDeletes(path)
+	if err != nil {
+		return err
+	}
+	if len(current) > loaded {
+		remaining = append(remaining, current[loaded:]...)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pending-deletes-*")
+	if err != nil {
+		return fmt.Errorf("failed to write pending deletes file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, p := range remaining {
+		data, err := json.Marshal(p)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal pending delete: %w", err)
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write pending deletes file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pending deletes file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace pending deletes file: %w", err)
+	}
+	return nil
+}
+
+// QueuePendingDelete records that obj was archived to Glacier (as archiveID)
+// but deleting it from Minio failed with cause. It does nothing when no
+// pending deletes file is set.
+func (bm *BackupManager) QueuePendingDelete(obj ObjectInfo, archiveID string, cause error) {
+	if bm.pendingDeletesFile == "" {
+		return
+	}
+	p := &PendingDelete{
+		ObjectKey: obj.Key,
+		Bucket:    bm.minioConfig.Bucket,
+		Size:      obj.Size,
+		ArchiveID: archiveID,
+		QueuedAt:  time.Now().UTC(),
+	}
+	if bm.awsConfig != nil {
+		p.Vault = bm.awsConfig.Vault
+	}
+	if cause != nil {
+		p.LastError = cause.Error()
+	}
+	if err := AppendPendingDelete(bm.pendingDeletesFile, p); err != nil {
+		fmt.Printf("  ⚠ Failed to record pending delete of %s: %v\n", obj.Key, err)
+		return
+	}
+	fmt.Printf("  ⏳ Recorded %s as a pending delete; `backup reconcile` retries it\n", obj.Key)
+}
+
+// ExcludePendingDeletes drops the objects of this bucket that are already
+// archived and waiting to be deleted from objs, so they are not migrated
+// again.
+func (bm *BackupManager) ExcludePendingDeletes(objs []ObjectInfo) ([]ObjectInfo, error) {
+	if bm.pendingDeletesFile == "" {
+		return objs, nil
+	}
+	pending, err := LoadPendingDeletes(bm.pendingDeletesFile)
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[string]bool, len(pending))
+	for _, p := range pending {
+		if p.Bucket == bm.minioConfig.Bucket {
+			archived[p.ObjectKey] = true
+		}
+	}
+	if len(archived) == 0 {
+		return objs, nil
+	}
+	kept := make([]ObjectInfo, 0, len(objs))
+	for _, o := range objs {
+		if !archived[o.Key] {
+			kept = append(kept, o)
+		}
+	}
+	if skipped := len(objs) - len(kept); skipped > 0 {
+		fmt.Printf("⏭  %d object(s) already archived to Glacier and waiting to be deleted were skipped (see `backup reconcile`)\n", skipped)
+	}
+	return kept, nil
+}
+
+// ReconcileOptions controls ReconcilePendingDeletes.
+type ReconcileOptions struct {
+	DryRun bool
+}
+
+// ReconcileResult counts what ReconcilePendingDeletes did with the list.
+type ReconcileResult struct {
+	Deleted int
+	// Gone counts objects that were already deleted, e.g. by hand.
+	Gone int
+	// Changed counts objects whose size no longer matches the archived copy.
+	// They are left in place and on the list for an operator to decide.
+	Changed int
+	Failed  int
+	// Skipped counts entries of other buckets and, in a dry run, the ones
+	// that would be deleted.
+	Skipped    int
+	FreedBytes int64
+}
+
+// ReconcilePendingDeletes retries the deletes on the list at path that
+// belong to this manager's bucket. Entries leave the list once their object
+// is deleted or found gone; failed ones stay with their attempt count and
+// last error.
+func (bm *BackupManager) ReconcilePendingDeletes(path string, opts *ReconcileOptions) (*ReconcileResult, error) {
+	if opts == nil {
+		opts = &ReconcileOptions{}
+	}
+	list, err := LoadPendingDeletes(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &ReconcileResult{}
+	if len(list) == 0 {
+		return res, nil
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var remaining []PendingDelete
+	for _, p := range list {
+		if p.Bucket != bm.minioConfig.Bucket {
+			res.Skipped++
+			remaining = append(remaining, p)
+			continue
+		}
+		info, err := bm.statObject(ctx, p.ObjectKey)
+		if errors.Is(err, ErrObjectNotFound) {
+			fmt.Printf("✓ %s: already gone\n", p.ObjectKey)
+			res.Gone++
+			continue
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", p.ObjectKey, err)
+			p.Attempts++
+			p.LastError = err.Error()
+			res.Failed++
+			remaining = append(remaining, p)
+			continue
+		}
+		if info.Size != p.Size {
+			fmt.Printf("⚠️  %s: size changed since it was archived (%d → %d bytes); not deleted\n", p.ObjectKey, p.Size, info.Size)
+			res.Changed++
+			remaining = append(remaining, p)
+			continue
+		}
+		if opts.DryRun {
+			fmt.Printf("[DRY RUN] Would delete %s (%.2f MB, archived %s)\n", p.ObjectKey, float64(p.Size)/(1024*1024), FormatTime(p.QueuedAt, time.RFC3339))
+			res.Skipped++
+			remaining = append(remaining, p)
+			continue
+		}
+		if err := bm.removeBackupObject(ctx, p.ObjectKey); err != nil {
+			fmt.Printf("❌ %s: delete failed again: %v\n", p.ObjectKey, err)
+			p.Attempts++
+			p.LastError = err.Error()
+			res.Failed++
+			remaining = append(remaining, p)
+			continue
+		}
+		fmt.Printf("✓ %s: deleted (%.2f MB freed)\n", p.ObjectKey, float64(p.Size)/(1024*1024))
+		res.Deleted++
+		res.FreedBytes += p.Size
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+	return res, savePendingDeletes(path, len(list), remaining)
+}
@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPendingDeletesQueueAndExclude(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	path := filepath.Join(t.TempDir(), "pending-deletes.jsonl")
+
+	// Without a file nothing is recorded or excluded.
+	bm.QueuePendingDelete(ObjectInfo{Key: "backups/a.com/a-1.tgz", Size: 3}, "arch-1", errors.New("boom"))
+	objs := []ObjectInfo{{Key: "backups/a.com/a-1.tgz"}, {Key: "backups/a.com/a-2.tgz"}}
+	if got, err := bm.ExcludePendingDeletes(objs); err != nil || len(got) != 2 {
+		t.Fatalf("ExcludePendingDeletes() without file = %v, %v", got, err)
+	}
+
+	bm.SetPendingDeletesFile(path)
+	bm.QueuePendingDelete(ObjectInfo{Key: "backups/a.com/a-1.tgz", Size: 3}, "arch-1", errors.New("boom"))
+	list, err := LoadPendingDeletes(path)
+	if err != nil {
+		t.Fatalf("LoadPendingDeletes() error = %v", err)
+	}
+	if len(list) != 1 || list[0].ObjectKey != "backups/a.com/a-1.tgz" || list[0].ArchiveID != "arch-1" || list[0].LastError != "boom" || list[0].Size != 3 {
+		t.Fatalf("LoadPendingDeletes() = %+v", list)
+	}
+
+	got, err := bm.ExcludePendingDeletes(objs)
+	if err != nil {
+		t.Fatalf("ExcludePendingDeletes() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Key != "backups/a.com/a-2.tgz" {
+		t.Fatalf("ExcludePendingDeletes() = %+v", got)
+	}
+}
+
+func TestReconcilePendingDeletes(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+	putTestObject(t, bm, "backups/a.com/a-1.tgz", "one")
+	putTestObject(t, bm, "backups/a.com/a-2.tgz", "changed")
+
+	path := filepath.Join(t.TempDir(), "pending-deletes.jsonl")
+	bucket := bm.minioConfig.Bucket
+	for _, p := range []PendingDelete{
+		{ObjectKey: "backups/a.com/a-1.tgz", Bucket: bucket, Size: 3},
+		{ObjectKey: "backups/a.com/a-2.tgz", Bucket: bucket, Size: 3},
+		{ObjectKey: "backups/a.com/a-0.tgz", Bucket: bucket, Size: 3},
+		{ObjectKey: "backups/b.com/b-1.tgz", Bucket: "other", Size: 3},
+	} {
+		p := p
+		if err := AppendPendingDelete(path, &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := bm.ReconcilePendingDeletes(path, &ReconcileOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ReconcilePendingDeletes(dry run) error = %v", err)
+	}
+	if res.Deleted != 0 || res.Skipped != 2 || res.Gone != 1 || res.Changed != 1 {
+		t.Fatalf("dry run result = %+v", res)
+	}
+	if _, err := bm.statObject(t.Context(), "backups/a.com/a-1.tgz"); err != nil {
+		t.Fatalf("dry run deleted the object: %v", err)
+	}
+	if list, _ := LoadPendingDeletes(path); len(list) != 4 {
+		t.Fatalf("dry run changed the list: %+v", list)
+	}
+
+	res, err = bm.ReconcilePendingDeletes(path, nil)
+	if err != nil {
+		t.Fatalf("ReconcilePendingDeletes() error = %v", err)
+	}
+	if res.Deleted != 1 || res.Gone != 1 || res.Changed != 1 || res.Skipped != 1 || res.FreedBytes != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+	if _, err := bm.statObject(t.Context(), "backups/a.com/a-1.tgz"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("statObject() after reconcile error = %v, want not found", err)
+	}
+	if _, err := bm.statObject(t.Context(), "backups/a.com/a-2.tgz"); err != nil {
+		t.Fatalf("changed object was deleted: %v", err)
+	}
+
+	list, err := LoadPendingDeletes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ObjectKey != "backups/a.com/a-2.tgz" || list[1].Bucket != "other" {
+		t.Fatalf("remaining list = %+v", list)
+	}
+}
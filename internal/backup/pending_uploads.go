@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// PendingUpload is a destination a dual upload missed while the other one
+// succeeded. Pending uploads are appended to a JSON-lines queue by `backup
+// create` and retried by `backup retry-pending`.
+type PendingUpload struct {
+	ObjectKey string `json:"object_key"`
+	// Destination is the one that was missed: DestinationMinio or
+	// DestinationGlacier.
+	Destination string    `json:"destination"`
+	Site        string    `json:"site,omitempty"`
+	Container   string    `json:"container,omitempty"`
+	QueuedAt    time.Time `json:"queued_at"`
+	Attempts    int       `json:"attempts,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// A missed Minio copy cannot be read back from Glacier in reasonable
+	// time, so it is re-created from the site directory on the host that
+	// made the backup (empty for the local host).
+	Host        string `json:"host,omitempty"`
+	WorkingDir  string `json:"working_dir,omitempty"`
+	ParentDir   string `json:"parent_dir,omitempty"`
+	ExcludeArgs string `json:"exclude_args,omitempty"`
+}
+
+// DefaultPendingUploadsPath returns the default location of the pending
+// uploads queue (~/.ciwg/pending-uploads.jsonl).
+func DefaultPendingUploadsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-pending-uploads.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "pending-uploads.jsonl")
+}
+
+// AppendPendingUpload adds p to the queue at path, creating the file and its
+// parent directory if needed.
+func AppendPendingUpload(path string, p *PendingUpload) error {
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create pending uploads directory: %w", err)
+		}
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending upload: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open pending uploads file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write pending upload: %w", err)
+	}
+	return nil
+}
+
+// LoadPendingUploads reads the queue at path. A missing file yields an empty
+// queue. Malformed lines are skipped.
+func LoadPendingUploads(path string) ([]PendingUpload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open pending uploads file: %w", err)
+	}
+	defer f.Close()
+
+	var out []PendingUpload
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var p PendingUpload
+		if err := json.Unmarshal(line, &p); err != nil {
+			continue
+		}
+		out = append(out, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending uploads file: %w", err)
+	}
+	return out, nil
+}
+
+// savePendingUploads replaces the first `loaded` entries of the queue at
+// path with remaining, keeping entries appended since they were loaded.
+func savePendingUploads(path string, loaded int, remaining []PendingUpload) error {
+	current, err := LoadPendingUploads(path)
+	if err != nil {
+		return err
+	}
+	if len(current) > loaded {
+		remaining = append(remaining, current[loaded:]...)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pending-uploads-*")
+	if err != nil {
+		return fmt.Errorf("failed to write pending uploads file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, p := range remaining {
+		data, err := json.Marshal(p)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal pending upload: %w", err)
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write pending uploads file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write pending uploads file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace pending uploads file: %w", err)
+	}
+	return nil
+}
+
+// queueMissedDestinations queues the destinations an upload missed, when it
+// reached at least one: a backup that reached neither has nothing to copy.
+func (bm *BackupManager) queueMissedDestinations(options *BackupOptions, container ContainerInfo, workingDir, excludeArgs string, stats *UploadStats) {
+	if options.PendingUploadsFile == "" || len(stats.Destinations) == 0 || len(stats.Missed) == 0 {
+		return
+	}
+	for _, dest := range stats.Missed {
+		p := &PendingUpload{
+			ObjectKey:   stats.ObjectKey,
+			Destination: dest,
+			Site:        stats.Site,
+			Container:   container.Name,
+			QueuedAt:    time.Now().UTC(),
+		}
+		if dest == DestinationMinio {
+			p.Host = bm.remoteHost()
+			p.WorkingDir = workingDir
+			p.ParentDir = container.parentDir(options)
+			p.ExcludeArgs = excludeArgs
+		}
+		if err := AppendPendingUpload(options.PendingUploadsFile, p); err != nil {
+			fmt.Printf("   ⚠️  Warning: failed to queue %s upload for retry: %v\n", dest, err)
+			continue
+		}
+		fmt.Printf("   ⏳ Queued missed %s upload for `backup retry-pending`\n", dest)
+	}
+}
+
+// RetryPendingOptions controls RetryPendingUploads.
+type RetryPendingOptions struct {
+	// Host is the server the manager runs commands on, empty for the local
+	// host. Missed Minio copies are only re-created for backups made there.
+	Host string
+	// SkipMinio leaves missed Minio copies queued, e.g. when no host to
+	// re-create them on was given.
+	SkipMinio bool
+	DryRun    bool
+}
+
+// RetryPendingResult counts what RetryPendingUploads did with the queue.
+type RetryPendingResult struct {
+	Completed int
+	Failed    int
+	Skipped   int
+}
+
+// RetryPendingUploads works through the queue at path. A missed Glacier copy
+// is archived from the Minio object, like a migration (and recorded in the
+// manager's run record for the ledger); a missed Minio copy is re-created
+// by archiving the site directory again, so it holds the site as it is now
+// rather than at backup time. Completed entries leave the queue; failed ones
+// stay with their attempt count and last error.
+func (bm *BackupManager) RetryPendingUploads(path string, opts *RetryPendingOptions) (*RetryPendingResult, error) {
+	if opts == nil {
+		opts = &RetryPendingOptions{}
+	}
+	queue, err := LoadPendingUploads(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &RetryPendingResult{}
+	if len(queue) == 0 {
+		fmt.Println("No pending uploads.")
+		return res, nil
+	}
+	if !opts.DryRun {
+		if err := bm.initMinioClient(); err != nil {
+			return nil, err
+		}
+	}
+
+	var remaining []PendingUpload
+	for _, p := range queue {
+		if p.Destination == DestinationMinio && (opts.SkipMinio || p.Host != opts.Host) {
+			where := "the local host (--local)"
+			if p.Host != "" {
+				where = "--host " + p.Host
+			}
+			fmt.Printf("⏭️  %s → minio: needs %s\n", p.ObjectKey, where)
+			res.Skipped++
+			remaining = append(remaining, p)
+			continue
+		}
+		if opts.DryRun {
+			fmt.Printf("[DRY RUN] Would retry %s → %s (queued %s, %d attempt(s))\n", p.ObjectKey, p.Destination, FormatTime(p.QueuedAt, time.RFC3339), p.Attempts)
+			res.Skipped++
+			remaining = append(remaining, p)
+			continue
+		}
+
+		fmt.Printf("🔁 Retrying %s → %s\n", p.ObjectKey, p.Destination)
+		var err error
+		switch p.Destination {
+		case DestinationGlacier:
+			err = bm.retryGlacierCopy(p.ObjectKey)
+		case DestinationMinio:
+			err = bm.retryMinioCopy(p)
+		default:
+			err = fmt.Errorf("unknown destination %q", p.Destination)
+		}
+		if err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			p.Attempts++
+			p.LastError = err.Error()
+			res.Failed++
+			remaining = append(remaining, p)
+			continue
+		}
+		fmt.Printf("   ✓ Done\n")
+		res.Completed++
+	}
+
+	if opts.DryRun {
+		return res, nil
+	}
+	return res, savePendingUploads(path, len(queue), remaining)
+}
+
+// retryGlacierCopy archives the Minio object objectName to Glacier.
+func (bm *BackupManager) retryGlacierCopy(objectName string) error {
+	info, err := bm.statObject(context.Background(), objectName)
+	if err != nil {
+		return fmt.Errorf("Minio copy is gone: %w", err)
+	}
+	_, err = bm.MigrateObjectToGlacier(objectName, info.Size)
+	return err
+}
+
+// retryMinioCopy archives the site directory of p to its original object key.
+func (bm *BackupManager) retryMinioCopy(p PendingUpload) error {
+	if p.WorkingDir == "" {
+		return fmt.Errorf("no site directory recorded")
+	}
+	stats := &UploadStats{Site: p.Site, Container: p.Container}
+	_, _, err := bm.streamBackupToMinio(p.WorkingDir, path.Base(p.ObjectKey), p.ParentDir, path.Dir(p.ObjectKey), p.ExcludeArgs, 0, false, stats, nil)
+	return err
+}
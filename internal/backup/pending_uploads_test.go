@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPendingUploadsQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ciwg", "pending.jsonl")
+	if queue, err := LoadPendingUploads(path); err != nil || len(queue) != 0 {
+		t.Fatalf("LoadPendingUploads(missing) = %v, %v", queue, err)
+	}
+	for _, key := range []string{"backups/a.com/a-1.tgz", "backups/b.com/b-1.tgz"} {
+		if err := AppendPendingUpload(path, &PendingUpload{ObjectKey: key, Destination: DestinationGlacier}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue, err := LoadPendingUploads(path)
+	if err != nil || len(queue) != 2 {
+		t.Fatalf("LoadPendingUploads() = %v, %v", queue, err)
+	}
+
+	// An entry queued while the first two were being retried survives.
+	if err := AppendPendingUpload(path, &PendingUpload{ObjectKey: "backups/c.com/c-1.tgz", Destination: DestinationMinio}); err != nil {
+		t.Fatal(err)
+	}
+	queue[1].Attempts = 1
+	if err := savePendingUploads(path, 2, queue[1:]); err != nil {
+		t.Fatal(err)
+	}
+	queue, _ = LoadPendingUploads(path)
+	if len(queue) != 2 || queue[0].ObjectKey != "backups/b.com/b-1.tgz" || queue[0].Attempts != 1 || queue[1].ObjectKey != "backups/c.com/c-1.tgz" {
+		t.Errorf("queue after save = %+v", queue)
+	}
+}
+
+func TestRetryPendingUploads(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	site := filepath.Join(t.TempDir(), "a.com")
+	if err := os.MkdirAll(filepath.Join(site, "www"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(site, "www", "index.php"), []byte("<?php"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pending.jsonl")
+	key := "backups/a.com/a.com-20240101-020000.tgz"
+	for _, p := range []PendingUpload{
+		{ObjectKey: key, Destination: DestinationMinio, WorkingDir: site, QueuedAt: time.Now()},
+		{ObjectKey: "backups/b.com/b.com-20240101-020000.tgz", Destination: DestinationMinio, Host: "wp1.example.com", WorkingDir: "/var/opt/sites/b.com"},
+		{ObjectKey: "backups/c.com/c.com-20240101-020000.tgz", Destination: DestinationGlacier},
+	} {
+		if err := AppendPendingUpload(path, &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := bm.RetryPendingUploads(path, &RetryPendingOptions{DryRun: true})
+	if err != nil || res.Skipped != 3 {
+		t.Fatalf("RetryPendingUploads(dry run) = %+v, %v", res, err)
+	}
+
+	res, err = bm.RetryPendingUploads(path, &RetryPendingOptions{})
+	if err != nil {
+		t.Fatalf("RetryPendingUploads() error = %v", err)
+	}
+	// The local Minio copy is re-created, the other host's entry is left
+	// alone and the Glacier copy fails: its Minio object does not exist.
+	if res.Completed != 1 || res.Skipped != 1 || res.Failed != 1 {
+		t.Errorf("RetryPendingUploads() = %+v", res)
+	}
+	if info, err := bm.statObject(t.Context(), key); err != nil || info.Size == 0 {
+		t.Errorf("re-created object %s: %+v, %v", key, info, err)
+	}
+	queue, _ := LoadPendingUploads(path)
+	if len(queue) != 2 || queue[0].Host != "wp1.example.com" || queue[1].Attempts != 1 || queue[1].LastError == "" {
+		t.Errorf("queue after retry = %+v", queue)
+	}
+}
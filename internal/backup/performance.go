@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DegradationThresholdPercent is the drop in Minio throughput between the
+// older and more recent halves of a group's samples that flags it as degrading.
+const DegradationThresholdPercent = 25.0
+
+// PerformanceSummary aggregates upload throughput for a host or site.
+type PerformanceSummary struct {
+	Group              string  `json:"group"`
+	Runs               int     `json:"runs"`
+	Uploads            int     `json:"uploads"`
+	TotalBytes         int64   `json:"total_bytes"`
+	AvgMinioMBps       float64 `json:"avg_minio_mbps"`
+	GlacierUploads     int     `json:"glacier_uploads"`
+	AvgGlacierMBps     float64 `json:"avg_glacier_mbps,omitempty"`
+	AvgBufferSeconds   float64 `json:"avg_buffer_seconds,omitempty"`
+	AvgChecksumSeconds float64 `json:"avg_checksum_seconds,omitempty"`
+	EarlierMinioMBps   float64 `json:"earlier_minio_mbps,omitempty"`
+	RecentMinioMBps    float64 `json:"recent_minio_mbps,omitempty"`
+	TrendPercent       float64 `json:"trend_percent"`
+	Degrading          bool    `json:"degrading"`
+}
+
+// SummarizePerformance groups upload stats from run records by "host" or
+// "site" and computes average throughput, phase timings and a simple trend
+// comparing the older half of samples against the most recent half.
+// Records that started before since are ignored (zero since keeps everything).
+func SummarizePerformance(records []RunRecord, groupBy string, since time.Time) ([]PerformanceSummary, error) {
+	if groupBy != "host" && groupBy != "site" {
+		return nil, fmt.Errorf("invalid group-by: %s (use 'host' or 'site')", groupBy)
+	}
+
+	type sample struct {
+		at    time.Time
+		stats UploadStats
+	}
+	samples := make(map[string][]sample)
+	runs := make(map[string]map[string]bool)
+
+	for _, rec := range records {
+		// Migrations have no Minio upload to measure.
+		if rec.DryRun || rec.Kind == RunKindMigration || (!since.IsZero() && rec.StartedAt.Before(since)) {
+			continue
+		}
+		for _, u := range rec.Uploads {
+			key := rec.Host
+			if groupBy == "site" {
+				key = u.Site
+			}
+			samples[key] = append(samples[key], sample{at: rec.StartedAt, stats: u})
+			if runs[key] == nil {
+				runs[key] = make(map[string]bool)
+			}
+			runs[key][rec.ID] = true
+		}
+	}
+
+	summaries := make([]PerformanceSummary, 0, len(samples))
+	for key, list := range samples {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+
+		s := PerformanceSummary{Group: key, Runs: len(runs[key]), Uploads: len(list)}
+		var minioSum, glacierSum, bufferSum, checksumSum float64
+		for _, smp := range list {
+			s.TotalBytes += smp.stats.Bytes
+			minioSum += smp.stats.MinioMBps
+			if g := smp.stats.Glacier; g != nil {
+				s.GlacierUploads++
+				glacierSum += g.UploadMBps
+				bufferSum += g.BufferSeconds
+				checksumSum += g.ChecksumSeconds
+			}
+		}
+		s.AvgMinioMBps = minioSum / float64(len(list))
+		if s.GlacierUploads > 0 {
+			n := float64(s.GlacierUploads)
+			s.AvgGlacierMBps = glacierSum / n
+			s.AvgBufferSeconds = bufferSum / n
+			s.AvgChecksumSeconds = checksumSum / n
+		}
+
+		// A trend needs at least two samples in each half to mean anything.
+		if len(list) >= 4 {
+			half := len(list) / 2
+			var earlier, recent float64
+			for _, smp := range list[:half] {
+				earlier += smp.stats.MinioMBps
+			}
+			for _, smp := range list[len(list)-half:] {
+				recent += smp.stats.MinioMBps
+			}
+			s.EarlierMinioMBps = earlier / float64(half)
+			s.RecentMinioMBps = recent / float64(half)
+			if s.EarlierMinioMBps > 0 {
+				s.TrendPercent = (s.RecentMinioMBps - s.EarlierMinioMBps) / s.EarlierMinioMBps * 100
+				s.Degrading = s.TrendPercent <= -DegradationThresholdPercent
+			}
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Group < summaries[j].Group })
+	return summaries, nil
+}
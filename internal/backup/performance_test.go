@@ -0,0 +1,68 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizePerformance(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(id, host string, day int, minio float64, glacier *GlacierUploadStats) RunRecord {
+		return RunRecord{
+			ID:        id,
+			Host:      host,
+			StartedAt: base.AddDate(0, 0, day),
+			Uploads: []UploadStats{{
+				Site:      "site-" + host,
+				Bytes:     1024 * 1024,
+				MinioMBps: minio,
+				Glacier:   glacier,
+			}},
+		}
+	}
+
+	records := []RunRecord{
+		run("a", "wp0", 0, 20, &GlacierUploadStats{UploadMBps: 4, BufferSeconds: 2, ChecksumSeconds: 1}),
+		run("b", "wp0", 1, 20, nil),
+		run("c", "wp0", 2, 10, nil),
+		run("d", "wp0", 3, 10, nil),
+		run("e", "wp1", 0, 50, nil),
+		{ID: "dry", Host: "wp1", StartedAt: base, DryRun: true, Uploads: []UploadStats{{Site: "x", MinioMBps: 1}}},
+	}
+
+	summaries, err := SummarizePerformance(records, "host", time.Time{})
+	if err != nil {
+		t.Fatalf("SummarizePerformance() error = %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(summaries))
+	}
+
+	wp0 := summaries[0]
+	if wp0.Group != "wp0" || wp0.Runs != 4 || wp0.AvgMinioMBps != 15 {
+		t.Errorf("wp0 summary = %+v", wp0)
+	}
+	if wp0.GlacierUploads != 1 || wp0.AvgGlacierMBps != 4 || wp0.AvgBufferSeconds != 2 {
+		t.Errorf("wp0 glacier figures = %+v", wp0)
+	}
+	if !wp0.Degrading || wp0.TrendPercent != -50 {
+		t.Errorf("wp0 should be degrading by 50%%, got %+v", wp0)
+	}
+
+	wp1 := summaries[1]
+	if wp1.Uploads != 1 || wp1.Degrading || wp1.EarlierMinioMBps != 0 {
+		t.Errorf("wp1 summary = %+v (dry runs must be ignored, no trend for one sample)", wp1)
+	}
+
+	recent, err := SummarizePerformance(records, "site", base.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("SummarizePerformance() error = %v", err)
+	}
+	if len(recent) != 1 || recent[0].Group != "site-wp0" || recent[0].Uploads != 2 {
+		t.Errorf("since filter = %+v", recent)
+	}
+
+	if _, err := SummarizePerformance(records, "vault", time.Time{}); err == nil {
+		t.Error("expected error for invalid group-by")
+	}
+}
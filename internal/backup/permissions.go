@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Operations a profile's permissions can deny. Listing, reading, verifying
+// and reporting are always allowed.
+const (
+	OpCreate      = "create"
+	OpDelete      = "delete"
+	OpPrune       = "prune"
+	OpMigrate     = "migrate"
+	OpRestore     = "restore"
+	OpSync        = "sync"
+	OpMaintenance = "maintenance"
+)
+
+// Operations lists every gated operation.
+var Operations = []string{OpCreate, OpDelete, OpPrune, OpMigrate, OpRestore, OpSync, OpMaintenance}
+
+// BackupProfilePermissions restricts what a profile may do, e.g. for a junior
+// operator who should list, read and verify backups but never delete them:
+//
+//	permissions:
+//	  read_only: true          # deny every gated operation
+//	  deny: [delete, migrate]  # or only these
+//
+// They are enforced by the CLI, not by Minio; give such profiles Minio
+// credentials with a read-only policy as well.
+type BackupProfilePermissions struct {
+	ReadOnly bool     `yaml:"read_only,omitempty"`
+	Deny     []string `yaml:"deny,omitempty"`
+}
+
+// Allows reports whether op is permitted. A nil receiver allows everything.
+func (p *BackupProfilePermissions) Allows(op string) bool {
+	if p == nil {
+		return true
+	}
+	return !p.ReadOnly && !slices.Contains(p.Deny, op)
+}
+
+// Validate rejects unknown operations in Deny.
+func (p *BackupProfilePermissions) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, op := range p.Deny {
+		if !slices.Contains(Operations, op) {
+			return fmt.Errorf("unknown operation %q in permissions.deny (use %s)", op, strings.Join(Operations, ", "))
+		}
+	}
+	return nil
+}
+
+// OperationDeniedError is returned when a gated operation is attempted
+// without permission.
+type OperationDeniedError struct {
+	Op string
+	// By names what denied it: a profile or the --read-only flag.
+	By string
+}
+
+func (e *OperationDeniedError) Error() string {
+	return fmt.Sprintf("%s is not permitted: %s", e.Op, e.By)
+}
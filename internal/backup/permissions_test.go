@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBackupProfilePermissionsAllows(t *testing.T) {
+	var none *BackupProfilePermissions
+	for _, op := range Operations {
+		if !none.Allows(op) {
+			t.Errorf("nil permissions deny %s", op)
+		}
+	}
+
+	readOnly := &BackupProfilePermissions{ReadOnly: true}
+	for _, op := range Operations {
+		if readOnly.Allows(op) {
+			t.Errorf("read-only permissions allow %s", op)
+		}
+	}
+
+	deny := &BackupProfilePermissions{Deny: []string{OpDelete, OpMigrate, OpPrune}}
+	for op, want := range map[string]bool{OpDelete: false, OpMigrate: false, OpPrune: false, OpCreate: true, OpRestore: true} {
+		if got := deny.Allows(op); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", op, got, want)
+		}
+	}
+}
+
+func TestBackupProfilePermissionsValidate(t *testing.T) {
+	if err := (&BackupProfilePermissions{Deny: []string{OpDelete, OpMaintenance}}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (&BackupProfilePermissions{Deny: []string{"delet"}}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown operation")
+	}
+	p := &BackupProfile{
+		Minio:       MinioProfile{Endpoint: "localhost:9000", AccessKey: "ak", SecretKey: "sk"},
+		Permissions: &BackupProfilePermissions{Deny: []string{"purge"}},
+	}
+	if err := p.Validate(); err == nil {
+		t.Error("BackupProfile.Validate() accepted unknown permissions")
+	}
+}
+
+func TestOperationDeniedError(t *testing.T) {
+	err := fmt.Errorf("%w; hint", &OperationDeniedError{Op: OpDelete, By: `profile "junior" is read-only`})
+	var denied *OperationDeniedError
+	if !errors.As(err, &denied) || denied.Op != OpDelete {
+		t.Fatalf("errors.As() = %v", err)
+	}
+	if want := `delete is not permitted: profile "junior" is read-only; hint`; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
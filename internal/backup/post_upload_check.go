@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Post-upload checks, selected with BackupOptions.PostUploadCheck.
+const (
+	PostUploadCheckNone  = "none"
+	PostUploadCheckQuick = "quick"
+	PostUploadCheckFull  = "full"
+)
+
+// postUploadWindow is how much of the head and tail of an upload the quick
+// check reads back.
+const postUploadWindow = 1 << 20
+
+// uploadDigest records what was sent while a backup streams to the bucket:
+// its length, checksum (with the CryptoPolicy algorithm) and the first and
+// last postUploadWindow bytes.
+type uploadDigest struct {
+	n    int64
+	alg  string
+	sum  hash.Hash
+	head []byte
+	tail []byte
+}
+
+func newUploadDigest() *uploadDigest {
+	alg := currentCryptoPolicy().Checksum
+	return &uploadDigest{alg: alg, sum: newChecksum(alg)}
+}
+
+// checksum is the checksum of what was sent, as "<algorithm>:<hex>".
+func (d *uploadDigest) checksum() string {
+	return d.alg + ":" + hex.EncodeToString(d.sum.Sum(nil))
+}
+
+func (d *uploadDigest) Write(p []byte) (int, error) {
+	d.n += int64(len(p))
+	d.sum.Write(p)
+	if room := postUploadWindow - len(d.head); room > 0 {
+		d.head = append(d.head, p[:min(room, len(p))]...)
+	}
+	if len(p) >= postUploadWindow {
+		d.tail = append(d.tail[:0], p[len(p)-postUploadWindow:]...)
+	} else {
+		d.tail = append(d.tail, p...)
+		if over := len(d.tail) - postUploadWindow; over > 0 {
+			d.tail = append(d.tail[:0], d.tail[over:]...)
+		}
+	}
+	return len(p), nil
+}
+
+// digestReader tees r into the upload digest when a post-upload check was
+// requested for this upload.
+func (s *UploadStats) digestReader(r io.Reader) io.Reader {
+	if s == nil || s.digest == nil {
+		return r
+	}
+	return io.TeeReader(r, s.digest)
+}
+
+// checkUpload validates objectName after it was uploaded, catching a
+// truncated or damaged upload at creation time instead of at restore time.
+//
+// The quick check compares the stored size with what was sent, reads back
+// the head and tail with ranged reads and compares them byte for byte, and
+// decodes the first tar header. The full check streams the whole object
+// back, compares its checksum with the one computed while sending, and walks
+// the gzip stream and tar archive to the end-of-archive blocks.
+func (bm *BackupManager) checkUpload(ctx context.Context, mode, objectName string, d *uploadDigest) error {
+	switch mode {
+	case PostUploadCheckQuick:
+		return bm.quickCheckUpload(ctx, objectName, d)
+	case PostUploadCheckFull:
+		return bm.fullCheckUpload(ctx, objectName, d)
+	}
+	return nil
+}
+
+func (bm *BackupManager) quickCheckUpload(ctx context.Context, objectName string, d *uploadDigest) error {
+	info, err := bm.statObject(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if info.Size != d.n {
+		return fmt.Errorf("stored size %d does not match the %d bytes sent", info.Size, d.n)
+	}
+
+	head, err := bm.readRange(ctx, objectName, 0, int64(len(d.head)))
+	if err != nil {
+		return fmt.Errorf("failed to read head: %w", err)
+	}
+	if !bytes.Equal(head, d.head) {
+		return fmt.Errorf("first %d bytes differ from what was sent", len(d.head))
+	}
+	tail, err := bm.readRange(ctx, objectName, d.n-int64(len(d.tail)), int64(len(d.tail)))
+	if err != nil {
+		return fmt.Errorf("failed to read tail: %w", err)
+	}
+	if !bytes.Equal(tail, d.tail) {
+		return fmt.Errorf("last %d bytes differ from what was sent", len(d.tail))
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(head))
+	if err != nil {
+		return fmt.Errorf("invalid gzip header: %w", err)
+	}
+	if _, err := tar.NewReader(gz).Next(); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid tar header: %w", err)
+	}
+	return nil
+}
+
+func (bm *BackupManager) fullCheckUpload(ctx context.Context, objectName string, d *uploadDigest) error {
+	obj, err := bm.getObject(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	sum := newChecksum(d.alg)
+	counted := &countingReader{r: io.TeeReader(obj, sum)}
+	gz, err := gzip.NewReader(counted)
+	if err != nil {
+		return fmt.Errorf("invalid gzip stream: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt tar archive: %w", err)
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return fmt.Errorf("corrupt tar entry: %w", err)
+		}
+	}
+	// Drain the tar padding and gzip trailer so gzip validates its checksum,
+	// then whatever follows so the checksum covers the whole object.
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return fmt.Errorf("corrupt gzip stream: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, counted); err != nil {
+		return fmt.Errorf("failed to read object: %w", err)
+	}
+	if counted.n != d.n {
+		return fmt.Errorf("read back %d bytes, sent %d", counted.n, d.n)
+	}
+	if got, want := sum.Sum(nil), d.sum.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("%s %x does not match %x computed while sending", checksumLabel(d.alg), got, want)
+	}
+	return nil
+}
+
+// readRange reads length bytes of objectName starting at offset.
+func (bm *BackupManager) readRange(ctx context.Context, objectName string, offset, length int64) ([]byte, error) {
+	r, err := bm.getObjectFrom(ctx, objectName, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
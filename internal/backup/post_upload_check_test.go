@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeLargeTarball returns a tarball whose compressed size exceeds twice the
+// quick check window, so its middle is only covered by the full check.
+func makeLargeTarball(t *testing.T) []byte {
+	t.Helper()
+	body := make([]byte, 3*postUploadWindow)
+	rand.New(rand.NewSource(1)).Read(body)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "wp-content/uploads/big.bin", Mode: 0o644, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// uploadWithDigest uploads data in uneven chunks the way a tar pipe would
+// deliver it and returns the digest recorded on the way.
+func uploadWithDigest(t *testing.T, bm *BackupManager, key string, data []byte) *uploadDigest {
+	t.Helper()
+	stats := &UploadStats{digest: newUploadDigest()}
+	if _, err := bm.putObject(context.Background(), key, stats.digestReader(&chunkedReader{r: bytes.NewReader(data), n: 7919}), -1, "application/gzip", nil); err != nil {
+		t.Fatalf("putObject(%s) error = %v", key, err)
+	}
+	return stats.digest
+}
+
+type chunkedReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	return c.r.Read(p)
+}
+
+func TestUploadDigest(t *testing.T) {
+	data := makeLargeTarball(t)
+	d := newUploadDigest()
+	io.Copy(d, &chunkedReader{r: bytes.NewReader(data), n: 7919})
+	if d.n != int64(len(data)) {
+		t.Errorf("n = %d, want %d", d.n, len(data))
+	}
+	if !bytes.Equal(d.head, data[:postUploadWindow]) {
+		t.Error("head does not match the first window of the upload")
+	}
+	if !bytes.Equal(d.tail, data[len(data)-postUploadWindow:]) {
+		t.Error("tail does not match the last window of the upload")
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatalf("initMinioClient() error = %v", err)
+	}
+	ctx := context.Background()
+	const key = "backups/a.com/a-1.tgz"
+	path := filepath.Join(dir, "backups", "a.com", "a-1.tgz")
+
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		damage    func(data []byte) []byte
+		wantQuick bool
+		wantFull  bool
+	}{
+		{name: "intact small", data: makeTarball(t), wantQuick: true, wantFull: true},
+		{name: "intact large", data: makeLargeTarball(t), wantQuick: true, wantFull: true},
+		{
+			name:   "truncated",
+			data:   makeLargeTarball(t),
+			damage: func(data []byte) []byte { return data[:len(data)-4096] },
+		},
+		{
+			name: "corrupt middle",
+			data: makeLargeTarball(t),
+			damage: func(data []byte) []byte {
+				data = bytes.Clone(data)
+				data[len(data)/2] ^= 0xff
+				return data
+			},
+			// Ranged reads never look at the middle of the object.
+			wantQuick: true,
+		},
+		{
+			name:   "not gzip",
+			data:   []byte("<html>502 Bad Gateway</html>"),
+			damage: func(data []byte) []byte { return data },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := uploadWithDigest(t, bm, key, tc.data)
+			if tc.damage != nil {
+				if err := os.WriteFile(path, tc.damage(tc.data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := bm.checkUpload(ctx, PostUploadCheckQuick, key, d); (err == nil) != tc.wantQuick {
+				t.Errorf("quick check error = %v, want pass = %v", err, tc.wantQuick)
+			}
+			if err := bm.checkUpload(ctx, PostUploadCheckFull, key, d); (err == nil) != tc.wantFull {
+				t.Errorf("full check error = %v, want pass = %v", err, tc.wantFull)
+			}
+			if err := bm.checkUpload(ctx, PostUploadCheckNone, key, d); err != nil {
+				t.Errorf("check none error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MinioProfile is a named object storage connection from the profiles file
+// (~/.ciwg/minio-profiles.yaml):
+//
+//	profiles:
+//	  old:
+//	    endpoint: minio-old.internal:9000
+//	    access_key_env: OLD_MINIO_ACCESS_KEY   # or access_key: ...
+//	    secret_key_env: OLD_MINIO_SECRET_KEY   # or secret_key: ...
+//	    bucket: backups
+//	    ssl: true
+//	    region: eu-central-1                   # optional, for residency
+//	  nas:
+//	    endpoint: file:///mnt/nas/backups
+type MinioProfile struct {
+	Endpoint           string `yaml:"endpoint"`
+	AccessKey          string `yaml:"access_key,omitempty"`
+	AccessKeyEnv       string `yaml:"access_key_env,omitempty"`
+	SecretKey          string `yaml:"secret_key,omitempty"`
+	SecretKeyEnv       string `yaml:"secret_key_env,omitempty"`
+	Bucket             string `yaml:"bucket,omitempty"`
+	BucketPath         string `yaml:"bucket_path,omitempty"`
+	SSL                *bool  `yaml:"ssl,omitempty"`
+	HTTPTimeout        string `yaml:"http_timeout,omitempty"`
+	CABundle           string `yaml:"ca_bundle,omitempty"`
+	ClientCert         string `yaml:"client_cert,omitempty"`
+	ClientKey          string `yaml:"client_key,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	// Region is the region of the bucket for residency checks.
+	Region string `yaml:"region,omitempty"`
+}
+
+type minioProfilesFile struct {
+	Profiles map[string]MinioProfile `yaml:"profiles"`
+}
+
+// DefaultMinioProfilesPath returns the default location of the profiles file
+// (~/.ciwg/minio-profiles.yaml).
+func DefaultMinioProfilesPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "minio-profiles.yaml")
+}
+
+// LoadMinioProfile returns the connection settings of the named profile.
+// Credentials given as *_env are read from the environment.
+func LoadMinioProfile(filePath, name string) (*MinioConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Minio profiles file: %w", err)
+	}
+	var file minioProfilesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse Minio profiles file %s: %w", filePath, err)
+	}
+	p, ok := file.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for n := range file.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found in %s (available: %v)", name, filePath, names)
+	}
+	return p.config(name)
+}
+
+func (p MinioProfile) config(name string) (*MinioConfig, error) {
+	if p.Endpoint == "" {
+		return nil, fmt.Errorf("profile %q has no endpoint", name)
+	}
+	if IsFileEndpoint(p.Endpoint) {
+		return &MinioConfig{Endpoint: p.Endpoint, BucketPath: p.BucketPath, Region: p.Region}, nil
+	}
+
+	accessKey, secretKey := p.AccessKey, p.SecretKey
+	if p.AccessKeyEnv != "" {
+		accessKey = os.Getenv(p.AccessKeyEnv)
+	}
+	if p.SecretKeyEnv != "" {
+		secretKey = os.Getenv(p.SecretKeyEnv)
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("profile %q is missing credentials (set access_key/secret_key or the variables named by access_key_env/secret_key_env)", name)
+	}
+
+	cfg := &MinioConfig{
+		Endpoint:           p.Endpoint,
+		AccessKey:          accessKey,
+		SecretKey:          secretKey,
+		Bucket:             p.Bucket,
+		BucketPath:         p.BucketPath,
+		UseSSL:             p.SSL == nil || *p.SSL,
+		CACertFile:         p.CABundle,
+		ClientCertFile:     p.ClientCert,
+		ClientKeyFile:      p.ClientKey,
+		InsecureSkipVerify: p.InsecureSkipVerify,
+		Region:             p.Region,
+	}
+	if err := cfg.NormalizeEndpoint(); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	if cfg.Bucket == "" {
+		cfg.Bucket = "backups"
+	}
+	if p.HTTPTimeout != "" {
+		d, err := time.ParseDuration(p.HTTPTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: invalid http_timeout: %w", name, err)
+		}
+		cfg.HTTPTimeout = d
+	}
+	return cfg, nil
+}
@@ -0,0 +1,261 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prunePlanPrefix holds one object per two-phase prune plan.
+const prunePlanPrefix = stateObjectPrefix + "prune-plans/"
+
+// Prune plan states.
+const (
+	PrunePlanPlanned   = "planned"
+	PrunePlanExecuting = "executing"
+	PrunePlanCompleted = "completed"
+	// PrunePlanIncomplete marks a plan whose execution left objects behind,
+	// because they changed since planning or could not be deleted.
+	PrunePlanIncomplete = "incomplete"
+)
+
+// PrunePlan is the first phase of a two-phase prune: every object a prune
+// is going to delete, with the size and ETag it was listed with. The plan is
+// stored in the bucket before anything is deleted and updated as it is
+// executed, so an interrupted prune can be resumed and a finished one
+// audited.
+type PrunePlan struct {
+	ID        string           `json:"id"`
+	Host      string           `json:"host,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	Status    string           `json:"status"`
+	Entries   []PrunePlanEntry `json:"entries"`
+	// StartedAt and FinishedAt bound the latest execution.
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// PrunePlanEntry is one object of a prune plan.
+type PrunePlanEntry struct {
+	Site         string    `json:"site"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+	// DeletedAt is set once the object is gone.
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	// Error is why the object was left in place on the latest execution.
+	Error string `json:"error,omitempty"`
+}
+
+// NewPrunePlan starts an empty plan for host.
+func NewPrunePlan(host string, now time.Time) *PrunePlan {
+	return &PrunePlan{ID: NewRunID(now), Host: host, CreatedAt: now, Status: PrunePlanPlanned}
+}
+
+// Add appends the objects to delete for site.
+func (p *PrunePlan) Add(site string, objs []ObjectInfo) {
+	for _, o := range objs {
+		p.Entries = append(p.Entries, PrunePlanEntry{Site: site, Key: o.Key, Size: o.Size, ETag: o.ETag, LastModified: o.LastModified})
+	}
+}
+
+// PlanPrune adds to plan the objects policy deletes from every site under
+// prefix, as 'backup create --prune' would, leaving out the sites skip
+// reports true for.
+func (bm *BackupManager) PlanPrune(plan *PrunePlan, prefix string, policy RetentionPolicy, skip func(site string) bool) error {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return err
+	}
+	bySite, sites := objectsBySite(objs)
+	for _, site := range sites {
+		if skip != nil && skip(site) {
+			continue
+		}
+		plan.Add(site, bm.selectForDeletion(bySite[site], policy))
+	}
+	return nil
+}
+
+// Pending returns the number of objects not deleted yet and their size.
+func (p *PrunePlan) Pending() (objects int, bytes int64) {
+	for _, e := range p.Entries {
+		if e.DeletedAt.IsZero() {
+			objects++
+			bytes += e.Size
+		}
+	}
+	return objects, bytes
+}
+
+func prunePlanKey(id string) string {
+	return prunePlanPrefix + id + ".json"
+}
+
+// SavePrunePlan stores plan in the bucket, replacing its previous state.
+func (bm *BackupManager) SavePrunePlan(plan *PrunePlan) error {
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := bm.putObject(context.Background(), prunePlanKey(plan.ID), bytes.NewReader(data), int64(len(data)), "application/json", nil); err != nil {
+		return fmt.Errorf("failed to store prune plan %s: %w", plan.ID, err)
+	}
+	return nil
+}
+
+// LoadPrunePlan reads the plan with the given ID from the bucket.
+func (bm *BackupManager) LoadPrunePlan(id string) (*PrunePlan, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	r, err := bm.getObject(context.Background(), prunePlanKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prune plan %s: %w", id, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prune plan %s: %w", id, err)
+	}
+	var plan PrunePlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid prune plan %s: %w", id, err)
+	}
+	return &plan, nil
+}
+
+// ListPrunePlans returns every stored plan, newest first.
+func (bm *BackupManager) ListPrunePlans() ([]*PrunePlan, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	objs, err := bm.listObjects(context.Background(), prunePlanPrefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prune plans: %w", err)
+	}
+	var plans []*PrunePlan
+	for _, o := range objs {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(o.Key, prunePlanPrefix), ".json")
+		if !ok {
+			continue
+		}
+		plan, err := bm.LoadPrunePlan(id)
+		if err != nil {
+			bm.logVerbose("Ignoring unreadable prune plan %s: %v", o.Key, err)
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].CreatedAt.After(plans[j].CreatedAt) })
+	return plans, nil
+}
+
+// errChangedSincePlan leaves an object in place that was replaced after the
+// plan was made.
+var errChangedSincePlan = errors.New("changed since the plan was made")
+
+// ExecutePrunePlan deletes the objects of plan that are not deleted yet,
+// site by site, saving the plan after each site so an interrupted run can be
+// resumed by executing the same plan again. An object whose size or ETag
+// differs from the plan is left in place; one that is already gone counts as
+// deleted. The plan ends up PrunePlanCompleted, or PrunePlanIncomplete when
+// objects were left behind.
+func (bm *BackupManager) ExecutePrunePlan(plan *PrunePlan) error {
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	plan.Status = PrunePlanExecuting
+	plan.StartedAt = time.Now().UTC()
+	plan.FinishedAt = time.Time{}
+	if err := bm.SavePrunePlan(plan); err != nil {
+		return err
+	}
+
+	var sites []string
+	bySite := map[string][]int{}
+	for i, e := range plan.Entries {
+		if !e.DeletedAt.IsZero() {
+			continue
+		}
+		if bySite[e.Site] == nil {
+			sites = append(sites, e.Site)
+		}
+		bySite[e.Site] = append(bySite[e.Site], i)
+	}
+
+	failed := 0
+	for _, site := range sites {
+		var keys []string
+		var deleting []int
+		for _, i := range bySite[site] {
+			e := &plan.Entries[i]
+			e.Error = ""
+			info, err := bm.statObject(ctx, e.Key)
+			switch {
+			case errors.Is(err, ErrObjectNotFound):
+				e.DeletedAt = time.Now().UTC()
+			case err != nil:
+				e.Error = err.Error()
+			case info.Size != e.Size || (info.ETag != "" && e.ETag != "" && info.ETag != e.ETag):
+				e.Error = errChangedSincePlan.Error()
+			default:
+				keys = append(keys, e.Key)
+				deleting = append(deleting, i)
+			}
+		}
+		if len(keys) > 0 {
+			if err := bm.DeleteObjects(keys); err != nil {
+				// The batch error names the objects that failed; check
+				// which are actually gone.
+				for _, i := range deleting {
+					if _, serr := bm.statObject(ctx, plan.Entries[i].Key); errors.Is(serr, ErrObjectNotFound) {
+						plan.Entries[i].DeletedAt = time.Now().UTC()
+					} else {
+						plan.Entries[i].Error = err.Error()
+					}
+				}
+			} else {
+				for _, i := range deleting {
+					plan.Entries[i].DeletedAt = time.Now().UTC()
+				}
+			}
+		}
+		n := 0
+		for _, i := range bySite[site] {
+			if plan.Entries[i].DeletedAt.IsZero() {
+				failed++
+			} else {
+				n++
+			}
+		}
+		fmt.Printf("Site %s: deleted %d of %d planned object(s)\n", site, n, len(bySite[site]))
+		if err := bm.SavePrunePlan(plan); err != nil {
+			return err
+		}
+	}
+
+	plan.FinishedAt = time.Now().UTC()
+	plan.Status = PrunePlanCompleted
+	if failed > 0 {
+		plan.Status = PrunePlanIncomplete
+	}
+	if err := bm.SavePrunePlan(plan); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("prune plan %s: %d object(s) left in place (see 'backup prune-plan show %s')", plan.ID, failed, plan.ID)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecutePrunePlan(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	keys := []string{
+		"backups/a.com/a.com-20260401-020000.tgz",
+		"backups/a.com/a.com-20260402-020000.tgz",
+		"backups/b.com/b.com-20260401-020000.tgz",
+		"backups/b.com/b.com-20260402-020000.tgz",
+	}
+	for _, k := range keys {
+		if _, err := bm.putObject(ctx, k, strings.NewReader("data:"+k), -1, "application/gzip", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objs, err := bm.ListBackups("backups/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPrunePlan("wp1", time.Now().UTC())
+	plan.Add("a.com", objs[:2])
+	plan.Add("b.com", objs[2:])
+	if err := bm.SavePrunePlan(plan); err != nil {
+		t.Fatalf("SavePrunePlan() error = %v", err)
+	}
+	if objs, _ := bm.ListBackups("backups/", 0); len(objs) != 4 {
+		t.Fatalf("plan deleted objects before execution: %v", objs)
+	}
+
+	// Replaced after planning, and deleted by someone else.
+	if _, err := bm.putObject(ctx, keys[2], strings.NewReader("a new and longer backup"), -1, "application/gzip", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.DeleteObject(keys[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := bm.LoadPrunePlan(plan.ID)
+	if err != nil || loaded.Status != PrunePlanPlanned || len(loaded.Entries) != 4 {
+		t.Fatalf("LoadPrunePlan() = %+v, %v", loaded, err)
+	}
+	if err := bm.ExecutePrunePlan(loaded); err == nil {
+		t.Fatal("ExecutePrunePlan() error = nil with a changed object")
+	}
+
+	stored, err := bm.LoadPrunePlan(plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != PrunePlanIncomplete || stored.FinishedAt.IsZero() {
+		t.Errorf("plan status = %s, finished %v", stored.Status, stored.FinishedAt)
+	}
+	for _, e := range stored.Entries {
+		left := e.Key == keys[2]
+		if left != e.DeletedAt.IsZero() {
+			t.Errorf("entry %s deleted at %v", e.Key, e.DeletedAt)
+		}
+		if left && e.Error != errChangedSincePlan.Error() {
+			t.Errorf("entry %s error = %q", e.Key, e.Error)
+		}
+	}
+	if _, err := bm.statObject(ctx, keys[2]); err != nil {
+		t.Errorf("changed object was deleted: %v", err)
+	}
+	if _, err := bm.statObject(ctx, keys[0]); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("planned object still there: %v", err)
+	}
+
+	// Resuming only retries what is left.
+	if n, _ := stored.Pending(); n != 1 {
+		t.Errorf("Pending() = %d, want 1", n)
+	}
+	stored.Entries[2].Size = 23
+	stored.Entries[2].ETag = ""
+	if err := bm.ExecutePrunePlan(stored); err != nil {
+		t.Fatalf("ExecutePrunePlan() resume error = %v", err)
+	}
+	plans, err := bm.ListPrunePlans()
+	if err != nil || len(plans) != 1 || plans[0].Status != PrunePlanCompleted {
+		t.Errorf("ListPrunePlans() = %v, %v", plans, err)
+	}
+	if objs, _ := bm.ListBackups("backups/", 0); len(objs) != 0 {
+		t.Errorf("objects left after the plan completed: %v", objs)
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{
+		"backups/a.com/a.com-20260401-020000.tgz",
+		"backups/a.com/a.com-20260402-020000.tgz",
+		"backups/a.com/a.com-20260403-020000.tgz",
+		"backups/b.com/b.com-20260401-020000.tgz",
+		"backups/b.com/b.com-20260402-020000.tgz",
+		"backups/c.com/c.com-20260401-020000.tgz",
+		"backups/c.com/c.com-20260402-020000.tgz",
+	} {
+		putTestObject(t, bm, k, "data")
+	}
+
+	plan := NewPrunePlan("wp1", time.Now().UTC())
+	skip := func(site string) bool { return site == "c.com" }
+	if err := bm.PlanPrune(plan, "backups/", RetentionPolicy{Remainder: 1}, skip); err != nil {
+		t.Fatalf("PlanPrune() error = %v", err)
+	}
+	var got []string
+	for _, e := range plan.Entries {
+		got = append(got, e.Key)
+	}
+	want := []string{
+		"backups/a.com/a.com-20260401-020000.tgz",
+		"backups/a.com/a.com-20260402-020000.tgz",
+		"backups/b.com/b.com-20260401-020000.tgz",
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+	if objs, _ := bm.ListBackups("backups/", 0); len(objs) != 7 {
+		t.Errorf("PlanPrune() deleted objects: %d left", len(objs))
+	}
+}
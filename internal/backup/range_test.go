@@ -288,6 +288,50 @@ func TestFilterObjectsByDateRange(t *testing.T) {
 	}
 }
 
+func TestBackupTime(t *testing.T) {
+	migrated := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		key  string
+		want time.Time
+	}{
+		{key: "backups/a.com/a.com-20240105-020304.tgz", want: time.Date(2024, 1, 5, 2, 3, 4, 0, Timezone())},
+		{key: "backups/a.com/a.com-20240105-020304.db.sql.gz", want: time.Date(2024, 1, 5, 2, 3, 4, 0, Timezone())},
+		{key: "backups/a.com/a.com-20240105-020304.files.part-001-of-002.tgz", want: time.Date(2024, 1, 5, 2, 3, 4, 0, Timezone())},
+		{key: "backups/a.com/a.com-20241305-020304.tgz", want: migrated},
+		{key: "backups/a.com/manual.tgz", want: migrated},
+	} {
+		if got := BackupTime(ObjectInfo{Key: tt.key, LastModified: migrated}); !got.Equal(tt.want) {
+			t.Errorf("BackupTime(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFilterObjectsByDateRangeUsesBackupName(t *testing.T) {
+	bm := &BackupManager{}
+	// Both objects were copied on the same day, long after they were taken.
+	copied := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a.com-20240105-020000.tgz", LastModified: copied},
+		{Key: "backups/a.com/a.com-20240210-020000.tgz", LastModified: copied},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, 1, 31, 23, 59, 59, 0, time.Local)
+	filtered := bm.FilterObjectsByDateRange(objs, start, end)
+	if len(filtered) != 1 || filtered[0].Key != objs[0].Key {
+		t.Errorf("FilterObjectsByDateRange() = %v, want only the January backup", filtered)
+	}
+
+	// Numeric ranges count from the newest backup by name, not by copy time.
+	objs[0].LastModified = copied.Add(time.Hour)
+	selected, err := bm.SelectObjectsByNumericRange(objs, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 1 || selected[0].Key != objs[1].Key {
+		t.Errorf("SelectObjectsByNumericRange(1-1) = %v, want the February backup", selected)
+	}
+}
+
 func TestSelectObjectsForOverwrite(t *testing.T) {
 	bm := &BackupManager{}
 
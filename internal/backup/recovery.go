@@ -0,0 +1,251 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Default age thresholds of the startup recovery scan.
+const (
+	// DefaultRecoveryTempAge leaves temp files of uploads that may still be
+	// running alone; a Glacier buffer is written once and read for at most a
+	// few hours.
+	DefaultRecoveryTempAge = 24 * time.Hour
+	// DefaultRecoveryStateAge applies to the temporary files state files are
+	// written through, which live for milliseconds unless a crash leaves them.
+	DefaultRecoveryStateAge = time.Hour
+	// DefaultRecoveryResumeAge expires resume tokens; a run paused that long
+	// ago is not resumed, it is superseded.
+	DefaultRecoveryResumeAge = 7 * 24 * time.Hour
+
+	// recoveryCacheAge drops completion listings of directories nobody
+	// completed in a day; they expire after seconds but are only replaced
+	// when the same directory is completed again.
+	recoveryCacheAge = 24 * time.Hour
+)
+
+// Kinds of leftovers removed by RecoveryScan.
+const (
+	RecoveryKindTemp   = "temp"
+	RecoveryKindState  = "state"
+	RecoveryKindResume = "resume"
+	RecoveryKindCache  = "cache"
+	RecoveryKindLock   = "lock"
+)
+
+// recoveryTempPatterns match the temp files and directories commands create
+// in the temp dir.
+var recoveryTempPatterns = []string{
+	"glacier-upload-*.tmp",
+	"glacier-part-*.tmp",
+	"glacier-migrate-*",
+	"ciwg-restore-*.sql",
+	"ciwg-zip-*.zip",
+	"backup-sanitize-*",
+}
+
+// recoveryStatePatterns match the temporary files state files are replaced
+// through (see savePendingUploads and friends).
+var recoveryStatePatterns = []string{
+	".pending-uploads-*",
+	".pending-deletes-*",
+	".backup-history-*",
+	".profile-*.yaml",
+	".capacity-metrics-*",
+	".metrics-*",
+	".upload-*.tmp",
+	".etag-index-*",
+}
+
+// RecoveryOptions configures RecoveryScan. Zero ages use the defaults.
+type RecoveryOptions struct {
+	// TempDir is scanned for temp files (default: os.TempDir()).
+	TempDir string
+	// StateDir is scanned for partial state files, resume tokens and the
+	// completion cache (default: ~/.ciwg).
+	StateDir string
+	// StatusSocket is removed when it is a socket nobody listens on.
+	StatusSocket string
+
+	TempAge   time.Duration
+	StateAge  time.Duration
+	ResumeAge time.Duration
+}
+
+// RecoveredItem is one leftover found by RecoveryScan.
+type RecoveredItem struct {
+	Kind string
+	Path string
+	Size int64
+	Age  time.Duration
+	Err  error
+}
+
+// RecoveryReport lists what RecoveryScan removed and what it failed to
+// remove.
+type RecoveryReport struct {
+	Removed    []RecoveredItem
+	Failed     []RecoveredItem
+	FreedBytes int64
+}
+
+// DefaultRecoveryStateDir returns the directory holding the CLI's local state
+// (~/.ciwg).
+func DefaultRecoveryStateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg")
+}
+
+// RecoveryScan removes what crashed runs leave behind: temp files older than
+// TempAge, partial state files older than StateAge, resume tokens older than
+// ResumeAge, expired completion cache entries and a status socket nobody
+// listens on. It is cheap enough to run at every command start: it reads two
+// directories and a few small subdirectories. Removing a temp file another
+// process still has open does not break that process on Unix; the space is
+// freed when it closes the file.
+func RecoveryScan(opts RecoveryOptions) *RecoveryReport {
+	if opts.TempDir == "" {
+		opts.TempDir = os.TempDir()
+	}
+	if opts.TempAge <= 0 {
+		opts.TempAge = DefaultRecoveryTempAge
+	}
+	if opts.StateAge <= 0 {
+		opts.StateAge = DefaultRecoveryStateAge
+	}
+	if opts.ResumeAge <= 0 {
+		opts.ResumeAge = DefaultRecoveryResumeAge
+	}
+	r := &recoveryRun{opts: opts, now: time.Now(), report: &RecoveryReport{}}
+
+	r.scanDir(opts.TempDir, RecoveryKindTemp, recoveryTempPatterns, opts.TempAge)
+	if opts.StateDir != "" {
+		r.scanDir(opts.StateDir, RecoveryKindState, recoveryStatePatterns, opts.StateAge)
+		r.scanDir(filepath.Join(opts.StateDir, "profiles"), RecoveryKindState, recoveryStatePatterns, opts.StateAge)
+		r.scanDir(filepath.Join(opts.StateDir, "resume"), RecoveryKindResume, []string{"*.json"}, opts.ResumeAge)
+		r.scanDir(filepath.Join(opts.StateDir, "completion-cache"), RecoveryKindCache, []string{"*.json"}, recoveryCacheAge)
+	}
+	if opts.StatusSocket != "" {
+		r.checkSocket(opts.StatusSocket)
+	}
+	return r.report
+}
+
+type recoveryRun struct {
+	opts   RecoveryOptions
+	now    time.Time
+	report *RecoveryReport
+}
+
+// scanDir removes the entries of dir matching one of patterns that were last
+// modified more than maxAge ago. A missing dir is skipped.
+func (r *recoveryRun) scanDir(dir, kind string, patterns []string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !matchAny(patterns, e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		age := r.now.Sub(info.ModTime())
+		if age < maxAge {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		size := info.Size()
+		if info.IsDir() {
+			size = dirSize(path)
+		}
+		r.remove(RecoveredItem{Kind: kind, Path: path, Size: size, Age: age}, info.IsDir())
+	}
+}
+
+// checkSocket removes path when it is a unix socket that refuses
+// connections, i.e. its run is gone.
+func (r *recoveryRun) checkSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return
+	}
+	r.remove(RecoveredItem{Kind: RecoveryKindLock, Path: path, Age: r.now.Sub(info.ModTime())}, false)
+}
+
+func (r *recoveryRun) remove(item RecoveredItem, dir bool) {
+	if dir {
+		item.Err = os.RemoveAll(item.Path)
+	} else {
+		item.Err = os.Remove(item.Path)
+	}
+	if os.IsNotExist(item.Err) {
+		// Another process cleaned it up first.
+		return
+	}
+	if item.Err != nil {
+		r.report.Failed = append(r.report.Failed, item)
+		return
+	}
+	r.report.Removed = append(r.report.Removed, item)
+	r.report.FreedBytes += item.Size
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// Write prints the report: one line per item and a total. Nothing is written
+// when the scan found nothing.
+func (rep *RecoveryReport) Write(w io.Writer) {
+	for _, it := range rep.Removed {
+		fmt.Fprintf(w, "🧹 Removed %s %s (%s, %s old)\n", it.Kind, it.Path, formatRecoverySize(it.Size), it.Age.Round(time.Minute))
+	}
+	for _, it := range rep.Failed {
+		fmt.Fprintf(w, "⚠️  Failed to remove %s %s: %v\n", it.Kind, it.Path, it.Err)
+	}
+	if len(rep.Removed) > 0 {
+		fmt.Fprintf(w, "🧹 Recovery scan: removed %d leftover(s) of earlier runs, %s freed\n",
+			len(rep.Removed), formatRecoverySize(rep.FreedBytes))
+	}
+}
+
+func formatRecoverySize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeAged(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(-age)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoveryScan(t *testing.T) {
+	tmp, state := t.TempDir(), t.TempDir()
+
+	writeAged(t, filepath.Join(tmp, "glacier-upload-1.tmp"), 1000, 48*time.Hour)
+	writeAged(t, filepath.Join(tmp, "glacier-part-2.tmp"), 10, time.Hour) // may still be in use
+	writeAged(t, filepath.Join(tmp, "unrelated.tmp"), 10, 48*time.Hour)   // not ours
+	writeAged(t, filepath.Join(tmp, "backup-sanitize-3", "db.sql"), 500, 48*time.Hour)
+	os.Chtimes(filepath.Join(tmp, "backup-sanitize-3"), time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	writeAged(t, filepath.Join(state, ".pending-uploads-9"), 5, 2*time.Hour)
+	writeAged(t, filepath.Join(state, "pending-uploads.jsonl"), 5, 30*24*time.Hour) // the state itself
+	writeAged(t, filepath.Join(state, "profiles", ".profile-1.yaml"), 5, 2*time.Hour)
+	writeAged(t, filepath.Join(state, "resume", "wp1.json"), 5, 8*24*time.Hour)
+	writeAged(t, filepath.Join(state, "resume", "wp2.json"), 5, 24*time.Hour)
+
+	report := RecoveryScan(RecoveryOptions{TempDir: tmp, StateDir: state})
+	if len(report.Failed) != 0 {
+		t.Fatalf("Failed = %+v", report.Failed)
+	}
+	var removed []string
+	for _, it := range report.Removed {
+		rel, _ := filepath.Rel(filepath.Dir(it.Path), it.Path)
+		removed = append(removed, it.Kind+":"+rel)
+	}
+	want := []string{"temp:backup-sanitize-3", "temp:glacier-upload-1.tmp", "state:.pending-uploads-9", "state:.profile-1.yaml", "resume:wp1.json"}
+	if strings.Join(removed, " ") != strings.Join(want, " ") {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	if report.FreedBytes != 1000+500+5+5+5 {
+		t.Errorf("FreedBytes = %d", report.FreedBytes)
+	}
+	for _, kept := range []string{
+		filepath.Join(tmp, "glacier-part-2.tmp"),
+		filepath.Join(tmp, "unrelated.tmp"),
+		filepath.Join(state, "pending-uploads.jsonl"),
+		filepath.Join(state, "resume", "wp2.json"),
+	} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s was removed", kept)
+		}
+	}
+
+	var out bytes.Buffer
+	report.Write(&out)
+	if !strings.Contains(out.String(), "removed 5 leftover(s)") {
+		t.Errorf("Write() = %q", out.String())
+	}
+
+	// A second scan finds nothing and prints nothing.
+	again := RecoveryScan(RecoveryOptions{TempDir: tmp, StateDir: state})
+	out.Reset()
+	again.Write(&out)
+	if len(again.Removed) != 0 || out.Len() != 0 {
+		t.Errorf("second scan removed %+v, wrote %q", again.Removed, out.String())
+	}
+}
+
+func TestRecoveryScanTempAge(t *testing.T) {
+	tmp := t.TempDir()
+	writeAged(t, filepath.Join(tmp, "glacier-upload-1.tmp"), 10, 2*time.Hour)
+	if r := RecoveryScan(RecoveryOptions{TempDir: tmp}); len(r.Removed) != 0 {
+		t.Errorf("default age removed %+v", r.Removed)
+	}
+	if r := RecoveryScan(RecoveryOptions{TempDir: tmp, TempAge: time.Hour}); len(r.Removed) != 1 {
+		t.Errorf("TempAge 1h removed %+v", r.Removed)
+	}
+}
+
+func TestRecoveryScanStatusSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.sock")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	if r := RecoveryScan(RecoveryOptions{TempDir: dir, StatusSocket: path}); len(r.Removed) != 0 {
+		t.Fatalf("live socket removed: %+v", r.Removed)
+	}
+
+	// Leave the socket file behind as a crashed run would.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	r := RecoveryScan(RecoveryOptions{TempDir: dir, StatusSocket: path})
+	if len(r.Removed) != 1 || r.Removed[0].Kind != RecoveryKindLock {
+		t.Fatalf("stale socket not removed: %+v", r.Removed)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists: %v", err)
+	}
+}
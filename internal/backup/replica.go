@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SetReplica registers the DR replica of the bucket. When propagateDeletes
+// is set, objects removed through DeleteObjects are removed from the replica
+// too; otherwise the replica is treated as write-once and keeps them.
+func (bm *BackupManager) SetReplica(name string, replica *BackupManager, propagateDeletes bool) {
+	bm.replicaName = name
+	bm.replica = replica
+	bm.propagateDeletes = propagateDeletes
+}
+
+// propagateDeletions applies a completed deletion on the primary to the
+// replica, if one is registered.
+func (bm *BackupManager) propagateDeletions(keys []string) error {
+	if bm.replica == nil || len(keys) == 0 {
+		return nil
+	}
+	if !bm.propagateDeletes {
+		fmt.Printf("ℹ️  Replica %s is write-once; keeping %d object(s) there\n", bm.replicaName, len(keys))
+		return nil
+	}
+	// The primary already applied the delete hold to keys; the replica's
+	// copies would fail it whenever they were replicated recently.
+	if err := bm.replica.deleteObjects(keys, false); err != nil {
+		return fmt.Errorf("failed to propagate deletions to replica %s: %w", bm.replicaName, err)
+	}
+	fmt.Printf("🔁 Deleted %d object(s) from replica %s\n", len(keys), bm.replicaName)
+	return nil
+}
+
+// ReplicaMismatch is an object present on both sides with different sizes.
+type ReplicaMismatch struct {
+	Key         string `json:"key"`
+	PrimarySize int64  `json:"primary_size"`
+	ReplicaSize int64  `json:"replica_size"`
+}
+
+// ReplicaDiff is the divergence between a prefix on the primary and on its
+// replica.
+type ReplicaDiff struct {
+	Prefix         string `json:"prefix"`
+	PrimaryObjects int    `json:"primary_objects"`
+	ReplicaObjects int    `json:"replica_objects"`
+	// MissingOnReplica have not been replicated (yet).
+	MissingOnReplica []ObjectInfo `json:"missing_on_replica"`
+	// OnlyOnReplica were deleted on the primary. This is expected for a
+	// write-once replica and a missed propagation otherwise.
+	OnlyOnReplica []ObjectInfo      `json:"only_on_replica"`
+	SizeMismatch  []ReplicaMismatch `json:"size_mismatch"`
+}
+
+// Diverged reports whether the replica differs from the primary in a way
+// that needs attention. Objects only on the replica count unless the replica
+// is write-once.
+func (d *ReplicaDiff) Diverged(writeOnce bool) bool {
+	if len(d.MissingOnReplica) > 0 || len(d.SizeMismatch) > 0 {
+		return true
+	}
+	return !writeOnce && len(d.OnlyOnReplica) > 0
+}
+
+// CompareReplica lists prefix on bm and replica and reports the objects that
+// differ by presence or size.
+func (bm *BackupManager) CompareReplica(replica *BackupManager, prefix string) (*ReplicaDiff, error) {
+	primaryObjs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary: %w", err)
+	}
+	replicaObjs, err := replica.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replica: %w", err)
+	}
+
+	diff := &ReplicaDiff{
+		Prefix:           prefix,
+		MissingOnReplica: []ObjectInfo{},
+		OnlyOnReplica:    []ObjectInfo{},
+		SizeMismatch:     []ReplicaMismatch{},
+	}
+	onReplica := make(map[string]ObjectInfo, len(replicaObjs))
+	for _, o := range replicaObjs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		onReplica[o.Key] = o
+		diff.ReplicaObjects++
+	}
+	for _, o := range primaryObjs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		diff.PrimaryObjects++
+		r, ok := onReplica[o.Key]
+		if !ok {
+			diff.MissingOnReplica = append(diff.MissingOnReplica, o)
+			continue
+		}
+		delete(onReplica, o.Key)
+		if r.Size != o.Size {
+			diff.SizeMismatch = append(diff.SizeMismatch, ReplicaMismatch{Key: o.Key, PrimarySize: o.Size, ReplicaSize: r.Size})
+		}
+	}
+	for _, o := range onReplica {
+		diff.OnlyOnReplica = append(diff.OnlyOnReplica, o)
+	}
+
+	sort.Slice(diff.MissingOnReplica, func(i, j int) bool { return diff.MissingOnReplica[i].Key < diff.MissingOnReplica[j].Key })
+	sort.Slice(diff.OnlyOnReplica, func(i, j int) bool { return diff.OnlyOnReplica[i].Key < diff.OnlyOnReplica[j].Key })
+	sort.Slice(diff.SizeMismatch, func(i, j int) bool { return diff.SizeMismatch[i].Key < diff.SizeMismatch[j].Key })
+	return diff, nil
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func newReplicaPair(t *testing.T) (*BackupManager, *BackupManager) {
+	t.Helper()
+	primary := NewBackupManager(nil, &MinioConfig{Endpoint: "file://" + t.TempDir()})
+	replica := NewBackupManager(nil, &MinioConfig{Endpoint: "file://" + t.TempDir()})
+	for _, bm := range []*BackupManager{primary, replica} {
+		if err := bm.initMinioClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return primary, replica
+}
+
+func putTestObject(t *testing.T, bm *BackupManager, key, body string) {
+	t.Helper()
+	if _, err := bm.putObject(context.Background(), key, bytes.NewReader([]byte(body)), int64(len(body)), "", nil); err != nil {
+		t.Fatalf("putObject(%s) error = %v", key, err)
+	}
+}
+
+func listKeys(t *testing.T, bm *BackupManager) []string {
+	t.Helper()
+	objs, err := bm.ListBackups("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, o := range objs {
+		keys = append(keys, o.Key)
+	}
+	return keys
+}
+
+func TestDeleteObjectsPropagatesToReplica(t *testing.T) {
+	for _, propagate := range []bool{true, false} {
+		primary, replica := newReplicaPair(t)
+		for _, bm := range []*BackupManager{primary, replica} {
+			putTestObject(t, bm, "backups/a.com/a-1.tgz", "one")
+			putTestObject(t, bm, "backups/a.com/a-2.tgz", "two")
+		}
+		primary.SetReplica("dr", replica, propagate)
+
+		if err := primary.DeleteObjects([]string{"backups/a.com/a-1.tgz"}); err != nil {
+			t.Fatalf("DeleteObjects() error = %v", err)
+		}
+		if got := listKeys(t, primary); len(got) != 1 {
+			t.Errorf("primary keys = %v, want one left", got)
+		}
+		want := 2
+		if propagate {
+			want = 1
+		}
+		if got := listKeys(t, replica); len(got) != want {
+			t.Errorf("propagate=%v: replica keys = %v, want %d", propagate, got, want)
+		}
+	}
+}
+
+func TestCompareReplica(t *testing.T) {
+	primary, replica := newReplicaPair(t)
+	putTestObject(t, primary, "backups/a.com/same.tgz", "same")
+	putTestObject(t, replica, "backups/a.com/same.tgz", "same")
+	putTestObject(t, primary, "backups/a.com/new.tgz", "new")
+	putTestObject(t, primary, "backups/a.com/size.tgz", "primary")
+	putTestObject(t, replica, "backups/a.com/size.tgz", "r")
+	putTestObject(t, replica, "backups/a.com/pruned.tgz", "old")
+	putTestObject(t, replica, ".locks/upload-semaphore/x", "t")
+
+	diff, err := primary.CompareReplica(replica, "")
+	if err != nil {
+		t.Fatalf("CompareReplica() error = %v", err)
+	}
+	if diff.PrimaryObjects != 3 || diff.ReplicaObjects != 3 {
+		t.Errorf("object counts = %d/%d, want 3/3", diff.PrimaryObjects, diff.ReplicaObjects)
+	}
+	if len(diff.MissingOnReplica) != 1 || diff.MissingOnReplica[0].Key != "backups/a.com/new.tgz" {
+		t.Errorf("MissingOnReplica = %+v", diff.MissingOnReplica)
+	}
+	if len(diff.SizeMismatch) != 1 || diff.SizeMismatch[0] != (ReplicaMismatch{Key: "backups/a.com/size.tgz", PrimarySize: 7, ReplicaSize: 1}) {
+		t.Errorf("SizeMismatch = %+v", diff.SizeMismatch)
+	}
+	if len(diff.OnlyOnReplica) != 1 || diff.OnlyOnReplica[0].Key != "backups/a.com/pruned.tgz" {
+		t.Errorf("OnlyOnReplica = %+v", diff.OnlyOnReplica)
+	}
+
+	pruned := &ReplicaDiff{OnlyOnReplica: diff.OnlyOnReplica}
+	if pruned.Diverged(true) {
+		t.Error("objects kept by a write-once replica counted as divergence")
+	}
+	if !pruned.Diverged(false) {
+		t.Error("unpropagated deletions not counted as divergence")
+	}
+}
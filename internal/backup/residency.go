@@ -0,0 +1,320 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SiteResidency restricts where the backups of a site may be stored. It is
+// set per site in the fleet file:
+//
+//	sites:
+//	  acme.de:
+//	    residency:
+//	      regions: [eu-central-1, eu-west-1]
+//	      endpoints: [minio-fra.example.com]
+//
+// A destination must satisfy both lists; an empty list does not restrict.
+type SiteResidency struct {
+	// Regions are the allowed regions of the Minio/S3 bucket and the
+	// Glacier vault.
+	Regions []string `yaml:"regions,omitempty" json:"regions,omitempty"`
+	// Endpoints are the allowed storage hosts, with or without a port. The
+	// Glacier endpoint is glacier.<region>.amazonaws.com.
+	Endpoints []string `yaml:"endpoints,omitempty" json:"endpoints,omitempty"`
+}
+
+// ResidencyPolicy maps sites to their residency. Sites without an entry are
+// not restricted.
+type ResidencyPolicy map[string]SiteResidency
+
+// ResidencyPolicy returns the residency of every site of the fleet file
+// that has one, under its name and under its object key name.
+func (f *Fleet) ResidencyPolicy() ResidencyPolicy {
+	policy := ResidencyPolicy{}
+	for name, s := range f.Sites {
+		if s.Residency == nil {
+			continue
+		}
+		policy[name] = *s.Residency
+		if key := SiteKey(name); key != name {
+			policy[key] = *s.Residency
+		}
+	}
+	return policy
+}
+
+// validateResidency checks the residency entries of the fleet file.
+func (f *Fleet) validateResidency() error {
+	var errs []error
+	for name, s := range f.Sites {
+		if s.Residency == nil {
+			continue
+		}
+		if len(s.Residency.Regions) == 0 && len(s.Residency.Endpoints) == 0 {
+			errs = append(errs, fmt.Errorf("site %s: residency needs regions or endpoints", name))
+		}
+		for _, v := range append(slices.Clone(s.Residency.Regions), s.Residency.Endpoints...) {
+			if strings.TrimSpace(v) == "" {
+				errs = append(errs, fmt.Errorf("site %s: empty residency entry", name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Destinations checked against residency.
+const (
+	ResidencyMinio   = "minio"
+	ResidencyGlacier = "glacier"
+)
+
+// ResidencyTarget is a storage destination as residency sees it.
+type ResidencyTarget struct {
+	Kind     string `json:"kind"`
+	Endpoint string `json:"endpoint"`
+	// Region is empty when it could not be determined.
+	Region string `json:"region,omitempty"`
+}
+
+func (t ResidencyTarget) String() string {
+	region := t.Region
+	if region == "" {
+		region = "unknown region"
+	}
+	return fmt.Sprintf("%s %s (%s)", t.Kind, t.Endpoint, region)
+}
+
+// residencyAddress returns the lower-cased host[:port] of an endpoint given
+// as a URL, host:port or host.
+func residencyAddress(endpoint string) string {
+	endpoint = strings.ToLower(strings.TrimSpace(endpoint))
+	if _, rest, ok := strings.Cut(endpoint, "://"); ok {
+		endpoint = rest
+	}
+	endpoint, _, _ = strings.Cut(endpoint, "/")
+	return endpoint
+}
+
+// violation returns why r does not allow t, or "" when it does.
+func (r SiteResidency) violation(t ResidencyTarget) string {
+	if len(r.Regions) > 0 {
+		if t.Region == "" {
+			return "the region of the destination is unknown"
+		}
+		if !slices.ContainsFunc(r.Regions, func(allowed string) bool { return strings.EqualFold(strings.TrimSpace(allowed), t.Region) }) {
+			return fmt.Sprintf("region %s is not one of %s", t.Region, strings.Join(r.Regions, ", "))
+		}
+	}
+	if len(r.Endpoints) > 0 {
+		// An allowed host without a port allows every port of it.
+		addr, host := residencyAddress(t.Endpoint), residencyAddress(t.Endpoint)
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			host = h
+		}
+		if !slices.ContainsFunc(r.Endpoints, func(allowed string) bool {
+			allowed = residencyAddress(allowed)
+			return allowed == addr || allowed == host
+		}) {
+			return fmt.Sprintf("endpoint %s is not one of %s", t.Endpoint, strings.Join(r.Endpoints, ", "))
+		}
+	}
+	return ""
+}
+
+// ResidencyOverride lets writes that violate residency go ahead, recording
+// each of them in the override audit log.
+type ResidencyOverride struct {
+	// Reason enables the override; it is required so every entry of the
+	// audit log says why.
+	Reason string
+	// AuditLog is the JSON-lines file overrides are appended to;
+	// DefaultResidencyAuditPath when empty.
+	AuditLog string
+	// Host and User identify who overrode.
+	Host string
+	User string
+}
+
+// DefaultResidencyAuditPath returns the default location of the residency
+// override audit log (~/.ciwg/residency-overrides.jsonl).
+func DefaultResidencyAuditPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-residency-overrides.jsonl")
+	}
+	return filepath.Join(home, ".ciwg", "residency-overrides.jsonl")
+}
+
+// ResidencyOverrideRecord is one entry of the override audit log.
+type ResidencyOverrideRecord struct {
+	Time      time.Time       `json:"time"`
+	Host      string          `json:"host,omitempty"`
+	User      string          `json:"user,omitempty"`
+	RunID     string          `json:"run_id,omitempty"`
+	Site      string          `json:"site"`
+	Op        string          `json:"op"`
+	Object    string          `json:"object"`
+	Target    ResidencyTarget `json:"target"`
+	Residency SiteResidency   `json:"residency"`
+	Violation string          `json:"violation"`
+	Reason    string          `json:"reason"`
+}
+
+// residencyGuard holds the residency settings of a manager.
+type residencyGuard struct {
+	mu       sync.Mutex
+	policy   ResidencyPolicy
+	override ResidencyOverride
+	// targets caches the resolved destinations by kind.
+	targets map[string]ResidencyTarget
+	// overridden are the kind/object pairs already written to the audit
+	// log, so a write checked twice is recorded once.
+	overridden map[string]bool
+}
+
+// SetResidency makes every write of a backup to Minio or Glacier check the
+// residency of its site in policy. Violations fail the write unless
+// override.Reason is set.
+func (bm *BackupManager) SetResidency(policy ResidencyPolicy, override ResidencyOverride) {
+	bm.residency.mu.Lock()
+	defer bm.residency.mu.Unlock()
+	bm.residency.policy, bm.residency.override = policy, override
+	bm.residency.targets, bm.residency.overridden = map[string]ResidencyTarget{}, map[string]bool{}
+}
+
+// residencyTarget returns the destination of kind. The region of the Minio
+// bucket is MinioConfig.Region when set, otherwise what the server reports
+// for the bucket.
+func (bm *BackupManager) residencyTarget(ctx context.Context, kind string) ResidencyTarget {
+	if t, ok := bm.residency.targets[kind]; ok {
+		return t
+	}
+	t := ResidencyTarget{Kind: kind}
+	switch kind {
+	case ResidencyGlacier:
+		if bm.awsConfig != nil {
+			t.Region = bm.awsConfig.Region
+			t.Endpoint = fmt.Sprintf("glacier.%s.amazonaws.com", t.Region)
+		}
+	case ResidencyMinio:
+		if bm.minioConfig != nil {
+			t.Endpoint, t.Region = bm.minioConfig.Endpoint, bm.minioConfig.Region
+		}
+		if t.Region == "" && bm.fileStore == nil && bm.minioClient != nil {
+			if region, err := bm.minioClient.GetBucketLocation(ctx, bm.minioConfig.Bucket); err == nil {
+				t.Region = region
+			} else {
+				bm.logVerbose("Could not read the region of bucket %s: %v", bm.minioConfig.Bucket, err)
+			}
+		}
+	}
+	bm.residency.targets[kind] = t
+	return t
+}
+
+// checkResidency fails a write of objectName, a backup of site, to the
+// destination kind when the site's residency does not allow it. An override
+// lets it through once it is in the audit log.
+func (bm *BackupManager) checkResidency(ctx context.Context, site, op, objectName, kind string) error {
+	g := &bm.residency
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.policy) == 0 || site == "" {
+		return nil
+	}
+	residency, ok := g.policy[site]
+	if !ok {
+		return nil
+	}
+	target := bm.residencyTarget(ctx, kind)
+	violation := residency.violation(target)
+	if violation == "" {
+		return nil
+	}
+	if g.override.Reason == "" {
+		return &ResidencyError{Site: site, Op: op, Object: objectName, Target: target, Violation: violation}
+	}
+	if g.overridden[kind+"\x00"+objectName] {
+		return nil
+	}
+	rec := &ResidencyOverrideRecord{
+		Time:      time.Now().UTC(),
+		Host:      g.override.Host,
+		User:      g.override.User,
+		Site:      site,
+		Op:        op,
+		Object:    objectName,
+		Target:    target,
+		Residency: residency,
+		Violation: violation,
+		Reason:    g.override.Reason,
+	}
+	if bm.lastRun != nil {
+		rec.RunID = bm.lastRun.ID
+	}
+	path := g.override.AuditLog
+	if path == "" {
+		path = DefaultResidencyAuditPath()
+	}
+	// An override that cannot be recorded is no override.
+	if err := appendResidencyOverride(path, rec); err != nil {
+		return fmt.Errorf("%w; residency override not applied: %v",
+			&ResidencyError{Site: site, Op: op, Object: objectName, Target: target, Violation: violation}, err)
+	}
+	g.overridden[kind+"\x00"+objectName] = true
+	fmt.Printf("⚠️  Residency override for %s: %s %s to %s (%s); reason: %s\n", site, op, objectName, target, violation, g.override.Reason)
+	return nil
+}
+
+// appendResidencyOverride appends rec to the JSON-lines audit log at path.
+func appendResidencyOverride(path string, rec *ResidencyOverrideRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create residency audit log directory: %w", err)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal residency override: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open residency audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write residency audit log: %w", err)
+	}
+	return nil
+}
+
+// checkObjectResidency is checkResidency for a backup identified only by
+// its key (backups/<site>/...). Internal objects are not site data.
+func (bm *BackupManager) checkObjectResidency(ctx context.Context, op, objectName, kind string) error {
+	if isInternalObject(objectName) {
+		return nil
+	}
+	return bm.checkResidency(ctx, inventorySite(objectName, ""), op, objectName, kind)
+}
+
+// residencyAllows reports whether the backup objectName may be written to
+// kind, with an override counting as allowed. Migrations use it to leave
+// the backups they may not move out of their plan.
+func (bm *BackupManager) residencyAllows(ctx context.Context, objectName, kind string) bool {
+	g := &bm.residency
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	residency, ok := g.policy[inventorySite(objectName, "")]
+	if !ok || g.override.Reason != "" {
+		return true
+	}
+	return residency.violation(bm.residencyTarget(ctx, kind)) == ""
+}
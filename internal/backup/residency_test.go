@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSiteResidencyViolation(t *testing.T) {
+	r := SiteResidency{Regions: []string{"eu-central-1", "EU-WEST-1"}, Endpoints: []string{"https://minio-fra.example.com", "s3.eu.example.com:9000"}}
+	tests := []struct {
+		target ResidencyTarget
+		want   string
+	}{
+		{ResidencyTarget{Endpoint: "minio-fra.example.com:443", Region: "eu-central-1"}, ""},
+		{ResidencyTarget{Endpoint: "s3.eu.example.com:9000", Region: "eu-west-1"}, ""},
+		{ResidencyTarget{Endpoint: "s3.eu.example.com:9001", Region: "eu-west-1"}, "endpoint"},
+		{ResidencyTarget{Endpoint: "minio-fra.example.com", Region: "us-east-1"}, "region us-east-1"},
+		{ResidencyTarget{Endpoint: "minio-fra.example.com"}, "unknown"},
+	}
+	for _, tt := range tests {
+		got := r.violation(tt.target)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("violation(%+v) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestFleetResidency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	os.WriteFile(path, []byte("sites:\n  acme.de:\n    residency:\n      regions: [eu-central-1]\n  other.com: {}\n"), 0o644)
+	fleet, err := LoadFleet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := fleet.ResidencyPolicy()
+	if len(policy) != 1 || policy["acme.de"].Regions[0] != "eu-central-1" {
+		t.Errorf("ResidencyPolicy() = %+v", policy)
+	}
+
+	os.WriteFile(path, []byte("sites:\n  acme.de:\n    residency: {}\n"), 0o644)
+	if _, err := LoadFleet(path); err == nil || !strings.Contains(err.Error(), "residency needs regions or endpoints") {
+		t.Errorf("LoadFleet() with an empty residency = %v", err)
+	}
+}
+
+func TestPutObjectResidency(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	bm.minioConfig.Region = "us-east-1"
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	policy := ResidencyPolicy{"acme.de": {Regions: []string{"eu-central-1"}}}
+	bm.SetResidency(policy, ResidencyOverride{})
+	ctx := context.Background()
+
+	_, err := bm.putObject(ctx, "backups/acme.de/acme.de-20261016-020000.tgz", strings.NewReader("x"), -1, "application/gzip", nil)
+	if !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("putObject() = %v, want a residency violation", err)
+	}
+	if diag := ClassifyBackupError(err); diag.Code != FailureResidency {
+		t.Errorf("diagnosis = %+v", diag)
+	}
+	if code := ExitCode(err); code != ExitRefused {
+		t.Errorf("ExitCode() = %d", code)
+	}
+	// Other sites and internal objects are not restricted.
+	for _, key := range []string{"backups/other.com/other.com-20261016-020000.tgz", "_meta/acme.de/r1/summary.json"} {
+		if _, err := bm.putObject(ctx, key, strings.NewReader("x"), -1, "application/json", nil); err != nil {
+			t.Errorf("putObject(%s) = %v", key, err)
+		}
+	}
+	if bm.residencyAllows(ctx, "backups/acme.de/a.tgz", ResidencyMinio) || !bm.residencyAllows(ctx, "backups/other.com/a.tgz", ResidencyMinio) {
+		t.Error("residencyAllows() disagrees with the policy")
+	}
+}
+
+func TestResidencyOverrideAudit(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	bm.awsConfig = &AWSConfig{Vault: "v", Region: "us-east-1"}
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	auditLog := filepath.Join(t.TempDir(), "residency-overrides.jsonl")
+	bm.SetResidency(ResidencyPolicy{"acme.de": {Regions: []string{"eu-central-1"}}},
+		ResidencyOverride{Reason: "client approved move, ticket 42", AuditLog: auditLog, Host: "wp1", User: "ops"})
+	ctx := context.Background()
+	key := "backups/acme.de/acme.de-20261016-020000.tgz"
+
+	// The stream checks first and the upload again; the audit log gets one entry.
+	if err := bm.checkResidency(ctx, "acme.de", "upload", key, ResidencyMinio); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.putObject(ctx, key, strings.NewReader("x"), -1, "application/gzip", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.checkObjectResidency(ctx, "migrate", key, ResidencyGlacier); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log = %s", data)
+	}
+	var rec ResidencyOverrideRecord
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Site != "acme.de" || rec.Op != "migrate" || rec.Target.Kind != ResidencyGlacier || rec.Target.Endpoint != "glacier.us-east-1.amazonaws.com" ||
+		rec.User != "ops" || rec.Host != "wp1" || rec.Reason != "client approved move, ticket 42" || !strings.Contains(rec.Violation, "us-east-1") {
+		t.Errorf("audit record = %+v", rec)
+	}
+}
+
+func TestResidencyOverrideNeedsAuditLog(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	blocker := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocker, nil, 0o644)
+	bm.SetResidency(ResidencyPolicy{"acme.de": {Endpoints: []string{"minio-fra.example.com"}}},
+		ResidencyOverride{Reason: "approved", AuditLog: filepath.Join(blocker, "overrides.jsonl")})
+	err := bm.checkResidency(context.Background(), "acme.de", "upload", "backups/acme.de/a.tgz", ResidencyMinio)
+	if !errors.Is(err, ErrResidencyViolation) || !strings.Contains(err.Error(), "override not applied") {
+		t.Errorf("checkResidency() without a writable audit log = %v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RestoreDBOptions controls a database-only restore into a running container.
+type RestoreDBOptions struct {
+	// Container is the name of the running container to import into.
+	Container string
+
+	// ImportMethod is either "wp" (wp db import, default) or "mysql" (the
+	// mysql client inside the container, authenticated via its MYSQL_* env).
+	ImportMethod string
+
+	// SQLPath optionally narrows which .sql entry in the tarball is used; the
+	// first entry whose path contains this string is imported.
+	SQLPath string
+
+	// SafetyExportDir is the directory on the target host that receives an
+	// export of the current database before it is overwritten.
+	SafetyExportDir string
+
+	// SkipSafetyExport disables the pre-restore export of the current database.
+	SkipSafetyExport bool
+	// RollbackFile is the registry the safety export is recorded in as a
+	// rollback point (DefaultRollbackPath when empty).
+	RollbackFile string
+
+	// Subsite restores only the tables of this blog ID of a multisite
+	// network; 0 imports the whole dump, i.e. the full network. The network
+	// tables and users are never part of a sub-site restore.
+	Subsite int
+	// TablePrefix is the network's base table prefix (default: wp db prefix
+	// in the container).
+	TablePrefix string
+	// UploadsDir, with Subsite, is the wp-content/uploads directory on the
+	// target host that receives the sub-site's uploads from the tarball.
+	UploadsDir string
+	// Compat re-packages the uploads for an old tar on the host; see
+	// RestoreSiteOptions.Compat.
+	Compat bool
+
+	DryRun bool
+}
+
+// ErrNoSQLDump is returned when a backup tarball contains no matching SQL dump.
+var ErrNoSQLDump = errors.New("no SQL dump found in backup")
+
+// RestoreDatabase streams objectName from Minio, locates the SQL dump and pipes
+// it into the target container without touching any site files. objectName may
+// be a full site tarball (.tgz / .tar.gz), a legacy .zip backup or a standalone
+// dump (.sql / .sql.gz).
+func (bm *BackupManager) RestoreDatabase(objectName string, opts *RestoreDBOptions) error {
+	if opts == nil || opts.Container == "" {
+		return fmt.Errorf("a target container is required")
+	}
+	method := opts.ImportMethod
+	if method == "" {
+		method = "wp"
+	}
+	importCmd, err := buildDBImportCommand(opts.Container, method)
+	if err != nil {
+		return err
+	}
+	exportDB, err := buildDBExportCommand(opts.Container, method)
+	if err != nil {
+		return err
+	}
+	if opts.Subsite < 0 {
+		return fmt.Errorf("invalid sub-site %d", opts.Subsite)
+	}
+	if opts.UploadsDir != "" && opts.Subsite == 0 {
+		return fmt.Errorf("restoring uploads needs a sub-site")
+	}
+	if opts.UploadsDir != "" && (strings.HasSuffix(objectName, ".sql") || strings.HasSuffix(objectName, ".sql.gz")) {
+		return fmt.Errorf("%s holds no uploads; use a site tarball", objectName)
+	}
+
+	// Make sure the container is actually running before downloading anything.
+	checkCmd := fmt.Sprintf(`docker inspect -f '{{.State.Running}}' "%s"`, opts.Container)
+	stdout, stderr, err := bm.executeCommand(checkCmd)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w (stderr: %s)", opts.Container, err, stderr)
+	}
+	if strings.TrimSpace(stdout) != "true" {
+		return fmt.Errorf("container %s is not running", opts.Container)
+	}
+	// Check the uploads can be extracted before the database is replaced.
+	var host HostTar
+	if opts.UploadsDir != "" {
+		host = bm.detectHostTar()
+		if !opts.DryRun {
+			if err := bm.preflightExtract(host, objectName, "site", opts.Compat); err != nil {
+				return err
+			}
+		}
+	}
+
+	obj, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	sqlReader, entryName, err := openSQLDump(objectName, obj, opts.SQLPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found SQL dump: %s\n", entryName)
+
+	if opts.Subsite > 0 {
+		prefix := bm.restoreTablePrefix(opts)
+		tmp, tables, err := subsiteSQLToTemp(sqlReader, prefix, opts.Subsite)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		sqlReader = tmp
+		fmt.Printf("Sub-site %d: %d table(s) with prefix %s\n", opts.Subsite, len(tables), prefix)
+		if opts.DryRun {
+			for _, table := range tables {
+				fmt.Printf("  %s\n", table)
+			}
+		}
+	}
+
+	if opts.DryRun {
+		if !opts.SkipSafetyExport {
+			fmt.Printf("[DRY RUN] Would export current database of %s to %s\n", opts.Container, safetyExportPath(opts, time.Now()))
+		}
+		fmt.Printf("[DRY RUN] Would import %s into %s using %s\n", entryName, opts.Container, importCmd)
+		if opts.UploadsDir != "" {
+			fmt.Printf("[DRY RUN] Would extract the uploads of sub-site %d into %s\n", opts.Subsite, opts.UploadsDir)
+		}
+		return nil
+	}
+
+	if !opts.SkipSafetyExport {
+		exportPath := safetyExportPath(opts, time.Now())
+		fmt.Printf("Exporting current database of %s to %s...\n", opts.Container, exportPath)
+		exportCmd := fmt.Sprintf(`mkdir -p "%s" && %s > "%s"`, filepath.Dir(exportPath), exportDB, exportPath)
+		if _, stderr, err := bm.executeCommand(exportCmd); err != nil {
+			return fmt.Errorf("safety export failed, aborting restore: %w (stderr: %s)", err, stderr)
+		}
+		fmt.Printf("✓ Safety export written to %s\n", exportPath)
+		now := time.Now()
+		point := RollbackPoint{
+			ID:           NewRunID(now),
+			Kind:         RollbackDatabase,
+			Host:         bm.remoteHost(),
+			Site:         ObjectSite(objectName),
+			ObjectKey:    objectName,
+			CreatedAt:    now.UTC(),
+			DatabaseDump: exportPath,
+			Container:    opts.Container,
+			ImportMethod: method,
+		}
+		if err := registerRollbackPoint(opts.RollbackFile, point); err != nil {
+			fmt.Printf("⚠️  Warning: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Importing %s into %s...\n", entryName, opts.Container)
+	startTime := time.Now()
+	pr := NewProgressReader(sqlReader, -1, "Import")
+	if stderr, err := bm.executeCommandWithStdin(importCmd, pr); err != nil {
+		if !opts.SkipSafetyExport {
+			fmt.Printf("💡 Revert to the safety export with `backup restore --rollback-last`\n")
+		}
+		return fmt.Errorf("database import failed: %w (stderr: %s)", err, stderr)
+	}
+
+	fmt.Printf("✓ Database restored into %s in %s\n", opts.Container, time.Since(startTime).Round(time.Second))
+
+	if opts.UploadsDir != "" {
+		return bm.restoreSubsiteUploads(objectName, opts, host)
+	}
+	return nil
+}
+
+// restoreTablePrefix returns the table prefix of the network a sub-site is
+// restored into.
+func (bm *BackupManager) restoreTablePrefix(opts *RestoreDBOptions) string {
+	if opts.TablePrefix != "" {
+		return opts.TablePrefix
+	}
+	stdout, stderr, err := bm.executeCommand(fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root db prefix`, opts.Container))
+	if prefix := strings.TrimSpace(stdout); err == nil && prefix != "" {
+		return prefix
+	}
+	fmt.Printf("⚠️  Could not read the table prefix of %s (%v, stderr: %s); assuming wp_\n", opts.Container, err, strings.TrimSpace(stderr))
+	return "wp_"
+}
+
+// restoreSubsiteUploads streams objectName again and extracts the uploads
+// of opts.Subsite into opts.UploadsDir on the target host.
+func (bm *BackupManager) restoreSubsiteUploads(objectName string, opts *RestoreDBOptions, host HostTar) error {
+	obj, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	tr, err := openBackupTar(objectName, obj, "site")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Extracting the uploads of sub-site %d into %s...\n", opts.Subsite, opts.UploadsDir)
+	pr, pw := io.Pipe()
+	type copyResult struct {
+		files int
+		err   error
+	}
+	done := make(chan copyResult, 1)
+	go func() {
+		n, err := copySubsiteUploads(tr, pw, opts.Subsite, opts.Compat)
+		pw.CloseWithError(err)
+		done <- copyResult{n, err}
+	}()
+	extractCmd := fmt.Sprintf(`mkdir -p %s && tar -xf - -C %s%s`, shellQuote(opts.UploadsDir), shellQuote(opts.UploadsDir), host.extractFlags())
+	stderr, err := bm.executeCommandWithStdin(extractCmd, pr)
+	pr.CloseWithError(err)
+	res := <-done
+	if res.err != nil {
+		return fmt.Errorf("failed to read uploads from %s: %w", objectName, res.err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract uploads: %w (stderr: %s)", err, stderr)
+	}
+	n := res.files
+	fmt.Printf("✓ Restored %d upload(s) of sub-site %d\n", n, opts.Subsite)
+	return nil
+}
+
+// buildDBImportCommand returns the shell command that reads a SQL dump on
+// stdin and imports it into the given container.
+func buildDBImportCommand(container, method string) (string, error) {
+	switch method {
+	case "wp":
+		return fmt.Sprintf(`docker exec -i -u 0 "%s" wp --allow-root db import -`, container), nil
+	case "mysql":
+		return fmt.Sprintf(`docker exec -i "%s" sh -c 'exec mysql -u"$MYSQL_USER" -p"$MYSQL_PASSWORD" "$MYSQL_DATABASE"'`, container), nil
+	default:
+		return "", fmt.Errorf("invalid import method: %s (use 'wp' or 'mysql')", method)
+	}
+}
+
+// buildDBExportCommand returns the shell command that writes a SQL dump of
+// the given container's database to stdout, through the same client as
+// buildDBImportCommand so containers without wp-cli can be exported too.
+func buildDBExportCommand(container, method string) (string, error) {
+	switch method {
+	case "wp":
+		return fmt.Sprintf(`docker exec -u 0 "%s" wp --allow-root db export -`, container), nil
+	case "mysql":
+		return fmt.Sprintf(`docker exec "%s" sh -c 'exec mysqldump -u"$MYSQL_USER" -p"$MYSQL_PASSWORD" "$MYSQL_DATABASE"'`, container), nil
+	default:
+		return "", fmt.Errorf("invalid import method: %s (use 'wp' or 'mysql')", method)
+	}
+}
+
+// safetyExportPath returns where the pre-restore export of the current
+// database is written on the target host.
+func safetyExportPath(opts *RestoreDBOptions, now time.Time) string {
+	dir := opts.SafetyExportDir
+	if dir == "" {
+		dir = "/var/tmp/ciwg-restore"
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-pre-restore-%s.sql", opts.Container, InTimezone(now).Format("20060102-150405")))
+}
+
+// openSQLDump returns a reader positioned at the SQL dump inside r along with
+// the name of the dump. Standalone .sql/.sql.gz objects are returned directly;
+// tarballs and zips are scanned for the first .sql entry matching sqlPath.
+func openSQLDump(objectName string, r io.Reader, sqlPath string) (io.Reader, string, error) {
+	switch {
+	case strings.HasSuffix(objectName, ".sql"):
+		return r, objectName, nil
+	case strings.HasSuffix(objectName, ".sql.gz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return gz, objectName, nil
+	case isZipBackup(objectName):
+		zr, err := openZip(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		return findSQLInZip(zr, sqlPath)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	return findSQLInTar(gz, sqlPath)
+}
+
+// findSQLInTar advances a tar stream to the first regular .sql entry whose
+// name contains match (any .sql entry when match is empty).
+func findSQLInTar(r io.Reader, match string) (io.Reader, string, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			if match != "" {
+				return nil, "", fmt.Errorf("%w matching %q", ErrNoSQLDump, match)
+			}
+			return nil, "", ErrNoSQLDump
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read tarball: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, ".sql") {
+			continue
+		}
+		if match != "" && !strings.Contains(hdr.Name, match) {
+			continue
+		}
+		return tr, hdr.Name, nil
+	}
+}
+
+// executeCommandWithStdin runs cmd locally (or on the Docker endpoint) or over SSH with stdin attached and
+// returns the captured stderr.
+func (bm *BackupManager) executeCommandWithStdin(cmd string, stdin io.Reader) (_ string, err error) {
+	var stderr bytes.Buffer
+	defer func(started time.Time) { bm.auditCommand(cmd, started, "", stderr.String(), err) }(time.Now())
+	if bm.sshClient == nil {
+		c := bm.shellCommand(cmd)
+		c.Stdin = stdin
+		c.Stderr = &stderr
+		err := c.Run()
+		return stderr.String(), err
+	}
+
+	session, err := bm.sshClient.GetSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stderr = &stderr
+	err = session.Run(remoteShellCommand(cmd))
+	return stderr.String(), err
+}
+
+// remoteShellCommand wraps cmd for a login shell on the SSH host. It is
+// single-quoted so the remote shell passes $VARIABLES meant for the
+// container (e.g. $MYSQL_PASSWORD) through unexpanded.
+func remoteShellCommand(cmd string) string {
+	return "bash -lc " + shellQuote(cmd)
+}
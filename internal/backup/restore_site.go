@@ -0,0 +1,562 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rewrite methods for RestoreSiteOptions.RewriteMethod.
+const (
+	// RewriteWP runs wp search-replace in the restored site after import,
+	// which also fixes the lengths of PHP-serialized strings.
+	RewriteWP = "wp"
+	// RewriteSQL rewrites the SQL dump line by line while it is extracted
+	// and imports it with the mysql client, for sites that are not WordPress.
+	RewriteSQL = "sql"
+)
+
+// SearchReplace is one URL or string rewrite applied to a restored database.
+type SearchReplace struct {
+	From string
+	To   string
+}
+
+// ParseSearchReplace parses "from=to".
+func ParseSearchReplace(s string) (SearchReplace, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" {
+		return SearchReplace{}, fmt.Errorf("invalid search-replace %q (use from=to, e.g. https://foo.com=https://staging.foo.com)", s)
+	}
+	return SearchReplace{From: from, To: to}, nil
+}
+
+// RestoreSiteOptions controls restoring a full site backup under another
+// site name, e.g. production into staging.
+type RestoreSiteOptions struct {
+	// As is the new site name. It names the working directory, replaces the
+	// old site name in the compose file and .env, and with it the compose
+	// project.
+	As string
+	// From is the site name in the backup: the directory its files are
+	// under. It defaults to the label of the backup name.
+	From string
+	// TargetDir is the directory on the host the site directory is created
+	// in; by default the one the site was backed up from.
+	TargetDir string
+	// DBName replaces the database name in the compose file and .env. It is
+	// required when they name one, so the copy never imports over the
+	// database of the site it was taken from.
+	DBName string
+	// SearchReplace is applied to the database after import.
+	SearchReplace []SearchReplace
+	// RewriteMethod is RewriteWP (default) or RewriteSQL.
+	RewriteMethod string
+	// Container is the container to import into; by default the wp_
+	// container of the restored compose project, or its only container.
+	Container string
+	// NoStart only extracts and rewrites the files.
+	NoStart bool
+	// Replace restores over an existing site directory, including the site
+	// the backup was taken from. The existing site is first stopped, its
+	// database exported and its directory moved aside, and registered as a
+	// rollback point in RollbackFile (DefaultRollbackPath when empty).
+	Replace      bool
+	RollbackFile string
+	// Compat re-packages the archive for old tar implementations on the
+	// host (see normalizeArchiveHeader). Without it, a restore onto a host
+	// whose tar cannot extract the archive faithfully is refused before
+	// anything is extracted.
+	Compat bool
+	DryRun bool
+}
+
+// composeFiles are the files at the site root that name the site, its
+// containers and its database.
+var composeFiles = map[string]bool{
+	"docker-compose.yml":  true,
+	"docker-compose.yaml": true,
+	"compose.yml":         true,
+	"compose.yaml":        true,
+	".env":                true,
+}
+
+var (
+	dbNamePattern        = regexp.MustCompile(`\b(WORDPRESS_DB_NAME|MYSQL_DATABASE|DB_NAME)(["']?\s*[:=]\s*["']?)([A-Za-z0-9_-]+)`)
+	containerNamePattern = regexp.MustCompile(`(?m)^(\s*container_name:\s*["']?)([^"'\s#]+)`)
+	projectNamePattern   = regexp.MustCompile(`(?m)^(name:\s*["']?)([^"'\s#]+)`)
+)
+
+// RestoreSite restores the site backup objectName into TargetDir/As: the
+// files are extracted under the new name with the compose file and .env
+// rewritten for it, then the stack is started, the database imported and
+// the search-replace rewrites applied. The new directory must not exist
+// unless opts.Replace is set.
+func (bm *BackupManager) RestoreSite(objectName string, opts *RestoreSiteOptions) (err error) {
+	if opts == nil || opts.As == "" {
+		return fmt.Errorf("a new site name is required")
+	}
+	if strings.ContainsAny(opts.As, "/ ") {
+		return fmt.Errorf("invalid site name %q", opts.As)
+	}
+	method := opts.RewriteMethod
+	if method == "" {
+		method = RewriteWP
+	}
+	if method != RewriteWP && method != RewriteSQL {
+		return fmt.Errorf("invalid rewrite method: %s (use '%s' or '%s')", method, RewriteWP, RewriteSQL)
+	}
+	from := opts.From
+	if from == "" {
+		label, _, ok := parseBackupName(objectName)
+		if !ok && isZipBackup(objectName) {
+			label, _ = LegacyBackupName(objectName, time.Time{}, "")
+			ok = label != ""
+		}
+		if !ok {
+			return fmt.Errorf("cannot tell the site name from %s; pass it explicitly", objectName)
+		}
+		from = label
+	}
+	if from == opts.As && !opts.Replace {
+		return fmt.Errorf("%s is the site the backup was taken from; use restore-db to restore its database in place, or --replace to restore all of it", from)
+	}
+
+	host := bm.detectHostTar()
+	if !opts.DryRun {
+		if err := bm.preflightExtract(host, objectName, from, opts.Compat); err != nil {
+			return err
+		}
+	}
+
+	obj, err := bm.DownloadBackup(objectName)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	// A legacy zip is laid out under the site directory like a tarball.
+	tr, err := openBackupTar(objectName, obj, from)
+	if err != nil {
+		return err
+	}
+	first, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read tarball: %w", err)
+	}
+
+	rw := &siteRewriter{from: from, to: opts.As, dbName: opts.DBName, compat: opts.Compat}
+	rw.features.Zstd = isZstdBackup(objectName)
+	if method == RewriteSQL && len(opts.SearchReplace) > 0 {
+		rw.sql = searchReplacer(opts.SearchReplace)
+	}
+	targetDir := opts.TargetDir
+	if targetDir == "" {
+		if targetDir, err = rw.sourceDir(first.Name); err != nil {
+			return err
+		}
+	}
+	siteDir := path.Join(targetDir, opts.As)
+
+	_, _, missing := bm.executeCommand(fmt.Sprintf(`test -e %s`, shellQuote(siteDir)))
+	exists := missing == nil
+	if exists && !opts.Replace {
+		return fmt.Errorf("%s already exists; remove it, pick another name or pass --replace", siteDir)
+	}
+
+	fmt.Printf("Restoring %s as %s into %s...\n", from, opts.As, siteDir)
+	if opts.DryRun {
+		if err := rw.rewrite(io.Discard, tr, first); err != nil {
+			return err
+		}
+		rw.report()
+		if err := rw.checkDatabase(); err != nil {
+			return err
+		}
+		if names := rw.features.Names(); len(names) > 0 {
+			fmt.Printf("Archive features: %s\n", strings.Join(names, ", "))
+		}
+		if err := checkArchiveCompat(objectName, rw.features, host); err != nil && !opts.Compat {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		if exists {
+			fmt.Printf("[DRY RUN] Would stop %s, export its database and move it aside as a rollback point\n", siteDir)
+		}
+		fmt.Printf("[DRY RUN] Would extract %d entries into %s\n", rw.entries, siteDir)
+		if !opts.NoStart {
+			fmt.Printf("[DRY RUN] Would start the stack in %s and import %s\n", siteDir, rw.sqlDump)
+			if method == RewriteWP {
+				for _, sr := range opts.SearchReplace {
+					fmt.Printf("[DRY RUN] Would run wp search-replace %s %s\n", sr.From, sr.To)
+				}
+			}
+		}
+		return nil
+	}
+
+	if exists {
+		point, err := bm.snapshotSite(siteDir, opts.Container, objectName, opts.As)
+		if err != nil {
+			return err
+		}
+		if err := registerRollbackPoint(opts.RollbackFile, *point); err != nil {
+			fmt.Printf("⚠️  Warning: %v; the previous site is in %s\n", err, point.SnapshotDir)
+		} else {
+			defer func() {
+				if err != nil {
+					fmt.Printf("💡 The previous %s is kept in %s; revert with `backup restore --rollback-last --as %s`\n", opts.As, point.SnapshotDir, opts.As)
+				}
+			}()
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(rw.rewrite(pw, tr, first)) }()
+	extractCmd := fmt.Sprintf(`mkdir -p %s && tar -xf - -C %s%s`, shellQuote(targetDir), shellQuote(targetDir), host.extractFlags())
+	stderr, err := bm.executeCommandWithStdin(extractCmd, NewProgressReader(pr, -1, "Extract"))
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return &TarError{Op: "extract", Path: siteDir, Stderr: stderr, Err: err}
+	}
+	fmt.Printf("✓ Extracted %d entries into %s\n", rw.entries, siteDir)
+	rw.report()
+
+	if err := rw.checkDatabase(); err != nil {
+		return fmt.Errorf("%w; the files are in %s but nothing was started", err, siteDir)
+	}
+	if opts.NoStart {
+		fmt.Printf("ℹ️  Not started (--no-start). Start it with: cd %s && docker compose up -d\n", siteDir)
+		return nil
+	}
+
+	fmt.Printf("Starting %s...\n", siteDir)
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`cd %s && docker compose up -d`, shellQuote(siteDir))); err != nil {
+		return fmt.Errorf("failed to start %s: %w (stderr: %s)", siteDir, err, stderr)
+	}
+	container, err := bm.restoredContainer(siteDir, opts.Container)
+	if err != nil {
+		return err
+	}
+
+	if rw.sqlDump == "" {
+		fmt.Println("ℹ️  No SQL dump in the backup; skipping the database import")
+		return nil
+	}
+	importMethod := "wp"
+	if method == RewriteSQL {
+		importMethod = "mysql"
+	}
+	importCmd, err := buildDBImportCommand(container, importMethod)
+	if err != nil {
+		return err
+	}
+	if err := bm.waitForDatabase(container, importMethod); err != nil {
+		return err
+	}
+	dump := path.Join(targetDir, rw.sqlDump)
+	fmt.Printf("Importing %s into %s...\n", rw.sqlDump, container)
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`%s < %s`, importCmd, shellQuote(dump))); err != nil {
+		return fmt.Errorf("database import failed: %w (stderr: %s)", err, stderr)
+	}
+	// The dump sits in the web root; don't leave it downloadable.
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`rm -f %s`, shellQuote(dump))); err != nil {
+		fmt.Printf("⚠️  Failed to remove %s: %v (stderr: %s)\n", dump, err, stderr)
+	}
+
+	if method == RewriteWP {
+		for _, sr := range opts.SearchReplace {
+			fmt.Printf("Replacing %s with %s...\n", sr.From, sr.To)
+			cmd := fmt.Sprintf(`docker exec -u 0 %s wp --allow-root search-replace %s %s --all-tables --skip-columns=guid --report-changed-only`,
+				shellQuote(container), shellQuote(sr.From), shellQuote(sr.To))
+			stdout, stderr, err := bm.executeCommand(cmd)
+			if err != nil {
+				return fmt.Errorf("search-replace %s failed: %w (stderr: %s)", sr.From, err, stderr)
+			}
+			fmt.Print(stdout)
+		}
+	}
+
+	fmt.Printf("✓ Restored %s as %s (container %s)\n", from, opts.As, container)
+	return nil
+}
+
+// restoredContainer returns the container to import into: name if given,
+// otherwise the wp_ container of the compose project in siteDir, or its
+// only container.
+func (bm *BackupManager) restoredContainer(siteDir, name string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	stdout, stderr, err := bm.executeCommand(fmt.Sprintf(`docker ps --filter %s --format '{{.Names}}'`,
+		shellQuote("label=com.docker.compose.project.working_dir="+siteDir)))
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers of %s: %w (stderr: %s)", siteDir, err, stderr)
+	}
+	names := strings.Fields(stdout)
+	for _, n := range names {
+		if strings.HasPrefix(n, "wp_") {
+			return n, nil
+		}
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	return "", fmt.Errorf("cannot tell which container of %s to import into (found %s); pass it explicitly", siteDir, strings.Join(names, ", "))
+}
+
+// waitForDatabase waits up to a minute for a freshly started container to
+// reach its database.
+func (bm *BackupManager) waitForDatabase(container, importMethod string) error {
+	check := fmt.Sprintf(`docker exec -u 0 %s wp --allow-root db check`, shellQuote(container))
+	if importMethod == "mysql" {
+		check = fmt.Sprintf(`docker exec %s sh -c 'exec mysqladmin ping -u"$MYSQL_USER" -p"$MYSQL_PASSWORD"'`, shellQuote(container))
+	}
+	var err error
+	var stderr string
+	for i := 0; i < 30; i++ {
+		if _, stderr, err = bm.executeCommand(check); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("database of %s is not reachable: %w (stderr: %s)", container, err, stderr)
+}
+
+// siteRewriter copies the entries of a site tarball under a new site name.
+type siteRewriter struct {
+	from, to string
+	dbName   string
+	// sql rewrites SQL dumps when set.
+	sql *strings.Replacer
+	// compat re-packages every entry; see normalizeArchiveHeader.
+	compat bool
+	// features are the archive features seen so far.
+	features ArchiveFeatures
+
+	entries int
+	// sqlDump is the first SQL dump, relative to the target directory.
+	sqlDump string
+	// dbNames are the database names found in the compose files.
+	dbNames []string
+	changes []string
+}
+
+// rewrite writes hdr and the rest of tr to dst as an uncompressed tar
+// stream rooted at the new site directory.
+func (w *siteRewriter) rewrite(dst io.Writer, tr *tar.Reader, hdr *tar.Header) error {
+	tw := tar.NewWriter(dst)
+	var err error
+	for {
+		rel, ok := w.relocate(hdr.Name)
+		if !ok {
+			return fmt.Errorf("%s is not under a %s directory; pass the site name the backup was taken from", hdr.Name, w.from)
+		}
+		hdr.Name = rel
+		if hdr.Typeflag == tar.TypeLink {
+			if link, ok := w.relocate(hdr.Linkname); ok {
+				hdr.Linkname = link
+			}
+		}
+		w.features.observe(hdr)
+		normalizeArchiveHeader(hdr, w.compat)
+
+		var body io.Reader = tr
+		var tmp *os.File
+		base := strings.TrimPrefix(rel, w.to+"/")
+		switch {
+		case hdr.Typeflag == tar.TypeReg && composeFiles[base]:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+			}
+			data = []byte(w.rewriteCompose(base, string(data)))
+			hdr.Size = int64(len(data))
+			body = strings.NewReader(string(data))
+		case hdr.Typeflag == tar.TypeReg && strings.HasSuffix(rel, ".sql"):
+			if w.sqlDump == "" {
+				w.sqlDump = rel
+			}
+			if w.sql != nil {
+				var size int64
+				if tmp, size, err = replaceToTemp(tr, w.sql); err != nil {
+					return fmt.Errorf("failed to rewrite %s: %w", hdr.Name, err)
+				}
+				hdr.Size = size
+				body = tmp
+				w.changes = append(w.changes, "rewrote URLs in "+rel)
+			}
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err == nil && hdr.Typeflag == tar.TypeReg {
+			_, err = io.Copy(tw, body)
+		}
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		if err != nil {
+			return err
+		}
+		w.entries++
+
+		if hdr, err = tr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read tarball: %w", err)
+		}
+	}
+	return tw.Close()
+}
+
+// sourceDir returns the directory the site directory of entry name was in
+// when it was backed up.
+func (w *siteRewriter) sourceDir(name string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(name, "./"), "/")
+	for i, p := range parts {
+		if p == w.from {
+			return "/" + path.Join(parts[:i]...), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not under a %s directory; pass the site name the backup was taken from", name, w.from)
+}
+
+// relocate maps an entry name to the new site directory: everything up to
+// and including the first component named after the old site is replaced
+// with the new name.
+func (w *siteRewriter) relocate(name string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(name, "./"), "/")
+	for i, p := range parts {
+		if p == w.from {
+			return path.Join(append([]string{w.to}, parts[i+1:]...)...) + trailingSlash(name), true
+		}
+	}
+	return "", false
+}
+
+func trailingSlash(name string) string {
+	if strings.HasSuffix(name, "/") {
+		return "/"
+	}
+	return ""
+}
+
+// rewriteCompose renames the site in a compose file or .env: the old site
+// name becomes the new one, container names and the project name are made
+// unique, and database names are replaced with dbName.
+func (w *siteRewriter) rewriteCompose(file, text string) string {
+	if w.from == w.to {
+		// Restored in place: the site keeps its names and database.
+		return text
+	}
+	text = strings.ReplaceAll(text, w.from, w.to)
+	slug := composeSlug(w.to)
+	text = containerNamePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := containerNamePattern.FindStringSubmatch(m)
+		if strings.Contains(sm[2], w.to) {
+			return m
+		}
+		w.changes = append(w.changes, fmt.Sprintf("container %s → %s_%s", sm[2], sm[2], slug))
+		return sm[1] + sm[2] + "_" + slug
+	})
+	text = projectNamePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := projectNamePattern.FindStringSubmatch(m)
+		w.changes = append(w.changes, fmt.Sprintf("compose project %s → %s", sm[2], slug))
+		return sm[1] + slug
+	})
+	text = dbNamePattern.ReplaceAllStringFunc(text, func(m string) string {
+		sm := dbNamePattern.FindStringSubmatch(m)
+		w.dbNames = append(w.dbNames, sm[3])
+		if w.dbName == "" {
+			return m
+		}
+		w.changes = append(w.changes, fmt.Sprintf("%s %s → %s in %s", sm[1], sm[3], w.dbName, file))
+		return sm[1] + sm[2] + w.dbName
+	})
+	return text
+}
+
+// checkDatabase refuses a copy that still names the database of the site it
+// was taken from.
+func (w *siteRewriter) checkDatabase() error {
+	if len(w.dbNames) == 0 || w.dbName != "" || w.from == w.to {
+		return nil
+	}
+	return fmt.Errorf("the compose files use database %s, which belongs to %s; pass a new database name so the copy does not overwrite it", w.dbNames[0], w.from)
+}
+
+func (w *siteRewriter) report() {
+	for _, c := range w.changes {
+		fmt.Printf("   • %s\n", c)
+	}
+}
+
+// composeSlug is name as a compose project name: lowercase letters, digits,
+// dashes and underscores.
+func composeSlug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func searchReplacer(pairs []SearchReplace) *strings.Replacer {
+	args := make([]string, 0, 2*len(pairs))
+	for _, sr := range pairs {
+		args = append(args, sr.From, sr.To)
+	}
+	return strings.NewReplacer(args...)
+}
+
+// replaceToTemp writes r, rewritten line by line, to a temp file and returns
+// it rewound along with its size.
+func replaceToTemp(r io.Reader, rep *strings.Replacer) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", "ciwg-restore-*.sql")
+	if err != nil {
+		return nil, 0, err
+	}
+	fail := func(err error) (*os.File, int64, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	br := bufio.NewReaderSize(r, 1<<20)
+	bw := bufio.NewWriterSize(tmp, 1<<20)
+	for {
+		line, err := br.ReadString('\n')
+		if _, werr := rep.WriteString(bw, line); werr != nil {
+			return fail(werr)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fail(err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return tmp, size, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
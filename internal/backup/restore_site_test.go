@@ -0,0 +1,188 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var siteFiles = map[string]string{
+	"var/opt/sites/foo.com/docker-compose.yml": `name: foocom
+services:
+  wordpress:
+    container_name: wp_foo
+    environment:
+      VIRTUAL_HOST: foo.com,www.foo.com
+      WORDPRESS_DB_NAME: wp_foo
+      WORDPRESS_DB_USER: foo
+    volumes:
+      - /var/opt/sites/foo.com/www:/var/www/html
+`,
+	"var/opt/sites/foo.com/www/index.php":              "<?php",
+	"var/opt/sites/foo.com/www/wp-content/wp_foo.sql":  "INSERT INTO wp_options VALUES ('siteurl','https://foo.com');\n",
+	"var/opt/sites/foo.com/www/wp-content/uploads/a.z": "zip",
+}
+
+var siteOrder = []string{
+	"var/opt/sites/foo.com/docker-compose.yml",
+	"var/opt/sites/foo.com/www/index.php",
+	"var/opt/sites/foo.com/www/wp-content/wp_foo.sql",
+	"var/opt/sites/foo.com/www/wp-content/uploads/a.z",
+}
+
+func TestParseSearchReplace(t *testing.T) {
+	sr, err := ParseSearchReplace("https://foo.com=https://staging.foo.com")
+	if err != nil || sr.From != "https://foo.com" || sr.To != "https://staging.foo.com" {
+		t.Errorf("ParseSearchReplace() = %+v, %v", sr, err)
+	}
+	for _, bad := range []string{"https://foo.com", "=https://staging.foo.com"} {
+		if _, err := ParseSearchReplace(bad); err == nil {
+			t.Errorf("ParseSearchReplace(%q) error = nil", bad)
+		}
+	}
+}
+
+func rewriteSite(t *testing.T, w *siteRewriter) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(buildTarball(t, siteFiles, siteOrder)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	first, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir, err := w.sourceDir(first.Name); err != nil || dir != "/var/opt/sites" {
+		t.Errorf("sourceDir() = %q, %v", dir, err)
+	}
+	var out bytes.Buffer
+	if err := w.rewrite(&out, tr, first); err != nil {
+		t.Fatalf("rewrite() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	rt := tar.NewReader(&out)
+	for {
+		hdr, err := rt.Next()
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rt)
+		got[hdr.Name] = string(body)
+	}
+}
+
+func TestSiteRewriter(t *testing.T) {
+	w := &siteRewriter{
+		from:   "foo.com",
+		to:     "staging.foo.com",
+		dbName: "wp_staging_foo",
+		sql:    searchReplacer([]SearchReplace{{From: "https://foo.com", To: "https://staging.foo.com"}}),
+	}
+	got := rewriteSite(t, w)
+
+	if len(got) != len(siteOrder) {
+		t.Errorf("rewrite() wrote %d entries, want %d", len(got), len(siteOrder))
+	}
+	compose, ok := got["staging.foo.com/docker-compose.yml"]
+	if !ok {
+		t.Fatalf("compose file not relocated: %v", got)
+	}
+	for _, want := range []string{
+		"name: stagingfoocom",
+		"container_name: wp_foo_stagingfoocom",
+		"VIRTUAL_HOST: staging.foo.com,www.staging.foo.com",
+		"WORDPRESS_DB_NAME: wp_staging_foo",
+		"WORDPRESS_DB_USER: foo",
+		"/var/opt/sites/staging.foo.com/www:/var/www/html",
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("compose file lacks %q:\n%s", want, compose)
+		}
+	}
+	if w.sqlDump != "staging.foo.com/www/wp-content/wp_foo.sql" {
+		t.Errorf("sqlDump = %s", w.sqlDump)
+	}
+	if sql := got[w.sqlDump]; !strings.Contains(sql, "'https://staging.foo.com'") {
+		t.Errorf("SQL dump not rewritten: %s", sql)
+	}
+	if err := w.checkDatabase(); err != nil {
+		t.Errorf("checkDatabase() error = %v", err)
+	}
+}
+
+func TestSiteRewriterRefusesSharedDatabase(t *testing.T) {
+	w := &siteRewriter{from: "foo.com", to: "staging.foo.com"}
+	got := rewriteSite(t, w)
+	if !strings.Contains(got["staging.foo.com/docker-compose.yml"], "WORDPRESS_DB_NAME: wp_foo\n") {
+		t.Error("database name changed without a new one")
+	}
+	if err := w.checkDatabase(); err == nil || !strings.Contains(err.Error(), "wp_foo") {
+		t.Errorf("checkDatabase() error = %v, want one naming wp_foo", err)
+	}
+}
+
+func TestRestoreSiteDryRun(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	key := "backups/foo.com/foo.com-20240101-020000.tgz"
+	putTestObject(t, bm, key, string(buildTarball(t, siteFiles, siteOrder)))
+	target := t.TempDir()
+
+	opts := &RestoreSiteOptions{As: "staging.foo.com", TargetDir: target, DBName: "wp_staging_foo", DryRun: true}
+	if err := bm.RestoreSite(key, opts); err != nil {
+		t.Errorf("RestoreSite(dry run) error = %v", err)
+	}
+
+	opts.DBName = ""
+	if err := bm.RestoreSite(key, opts); err == nil {
+		t.Error("RestoreSite() without a new database name error = nil")
+	}
+
+	opts.DBName = "wp_staging_foo"
+	if err := os.Mkdir(filepath.Join(target, "staging.foo.com"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.RestoreSite(key, opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("RestoreSite() into an existing directory error = %v", err)
+	}
+
+	if err := bm.RestoreSite(key, &RestoreSiteOptions{As: "foo.com", DryRun: true}); err == nil {
+		t.Error("RestoreSite() as the source site error = nil")
+	}
+}
+
+func TestRestoreSiteNoStart(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	key := "backups/foo.com/foo.com-20240101-020000.tgz"
+	putTestObject(t, bm, key, string(buildTarball(t, siteFiles, siteOrder)))
+	target := t.TempDir()
+
+	if err := bm.RestoreSite(key, &RestoreSiteOptions{As: "staging.foo.com", TargetDir: target, DBName: "wp_staging_foo", NoStart: true}); err != nil {
+		t.Fatalf("RestoreSite(no start) error = %v", err)
+	}
+	compose, err := os.ReadFile(filepath.Join(target, "staging.foo.com", "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(compose), "WORDPRESS_DB_NAME: wp_staging_foo") {
+		t.Errorf("extracted compose file was not rewritten:\n%s", compose)
+	}
+	if _, err := os.Stat(filepath.Join(target, "staging.foo.com", "www", "index.php")); err != nil {
+		t.Errorf("site files not extracted: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func buildTarball(t *testing.T, files map[string]string, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range order {
+		body := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenSQLDump(t *testing.T) {
+	files := map[string]string{
+		"var/opt/sites/foo.com/www/index.php":              "<?php",
+		"var/opt/sites/foo.com/www/wp-content/old/a.sql":   "OLD",
+		"var/opt/sites/foo.com/www/wp-content/foo_db.sql":  "CURRENT",
+		"var/opt/sites/foo.com/www/wp-content/uploads/x.z": "zip",
+	}
+	order := []string{
+		"var/opt/sites/foo.com/www/index.php",
+		"var/opt/sites/foo.com/www/wp-content/old/a.sql",
+		"var/opt/sites/foo.com/www/wp-content/foo_db.sql",
+		"var/opt/sites/foo.com/www/wp-content/uploads/x.z",
+	}
+	tgz := buildTarball(t, files, order)
+
+	tests := []struct {
+		name     string
+		match    string
+		wantName string
+		wantBody string
+		wantErr  bool
+	}{
+		{name: "first sql entry", wantName: order[1], wantBody: "OLD"},
+		{name: "narrowed by path", match: "foo_db", wantName: order[2], wantBody: "CURRENT"},
+		{name: "no match", match: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, name, err := openSQLDump("backups/foo.com/foo.com-20240101-120000.tgz", bytes.NewReader(tgz), tt.match)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoSQLDump) {
+					t.Fatalf("expected ErrNoSQLDump, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openSQLDump() error = %v", err)
+			}
+			body, _ := io.ReadAll(r)
+			if name != tt.wantName || string(body) != tt.wantBody {
+				t.Errorf("openSQLDump() = %s %q, want %s %q", name, body, tt.wantName, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestOpenSQLDumpStandaloneObject(t *testing.T) {
+	r, name, err := openSQLDump("backups/foo.com/foo.sql", bytes.NewReader([]byte("PLAIN")), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(r)
+	if name != "backups/foo.com/foo.sql" || string(body) != "PLAIN" {
+		t.Errorf("unexpected standalone result %s %q", name, body)
+	}
+}
+
+func TestBuildDBImportCommand(t *testing.T) {
+	if _, err := buildDBImportCommand("wp_foo", "psql"); err == nil {
+		t.Error("expected error for unsupported import method")
+	}
+	cmd, err := buildDBImportCommand("wp_foo", "wp")
+	if err != nil || cmd != `docker exec -i -u 0 "wp_foo" wp --allow-root db import -` {
+		t.Errorf("buildDBImportCommand(wp) = %q, %v", cmd, err)
+	}
+}
+
+func TestBuildDBExportCommand(t *testing.T) {
+	if _, err := buildDBExportCommand("wp_foo", "psql"); err == nil {
+		t.Error("expected error for unsupported import method")
+	}
+	cmd, err := buildDBExportCommand("wp_foo", "mysql")
+	if err != nil || !strings.Contains(cmd, "mysqldump") || strings.Contains(cmd, " wp ") {
+		t.Errorf("buildDBExportCommand(mysql) = %q, %v", cmd, err)
+	}
+}
+
+func TestRemoteShellCommandKeepsContainerEnv(t *testing.T) {
+	cmd, err := buildDBImportCommand("wp_foo", "mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := remoteShellCommand(cmd)
+	if !strings.HasPrefix(wrapped, "bash -lc '") {
+		t.Fatalf("remoteShellCommand() = %q, want a single-quoted bash -lc", wrapped)
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	// What the remote shell hands to bash -lc must be cmd, $MYSQL_* intact.
+	out, err := exec.Command("bash", "-c", "MYSQL_USER=leak printf %s "+strings.TrimPrefix(wrapped, "bash -lc ")).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != cmd {
+		t.Errorf("remote shell passed %q, want %q", out, cmd)
+	}
+	if !strings.Contains(string(out), `-u"$MYSQL_USER"`) {
+		t.Errorf("$MYSQL_USER was expanded: %q", out)
+	}
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RetentionPreset is a named set of smart retention numbers so operators don't
+// have to re-type keep-daily/weekly/monthly on every invocation.
+type RetentionPreset struct {
+	Name        string `yaml:"-" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	KeepDaily   int    `yaml:"keep_daily" json:"keep_daily"`
+	KeepWeekly  int    `yaml:"keep_weekly" json:"keep_weekly"`
+	KeepMonthly int    `yaml:"keep_monthly" json:"keep_monthly"`
+	WeeklyDay   int    `yaml:"weekly_day,omitempty" json:"weekly_day"`
+	MonthlyDay  int    `yaml:"monthly_day,omitempty" json:"monthly_day"`
+	BuiltIn     bool   `yaml:"-" json:"built_in"`
+}
+
+// builtinRetentionPresets are always available and may be overridden by name
+// in the retention_presets section of the fleet file.
+var builtinRetentionPresets = []RetentionPreset{
+	{Name: "standard", Description: "Two weeks of dailies, six months of weeklies and monthlies", KeepDaily: 14, KeepWeekly: 26, KeepMonthly: 6, MonthlyDay: 1},
+	{Name: "aggressive", Description: "Minimal footprint for low-value or easily rebuilt sites", KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 3, MonthlyDay: 1},
+	{Name: "archive-heavy", Description: "Short daily window with a long weekly/monthly tail", KeepDaily: 7, KeepWeekly: 52, KeepMonthly: 24, MonthlyDay: 1},
+}
+
+// validateRetentionPresets checks the retention_presets section of the fleet
+// file.
+func (f *Fleet) validateRetentionPresets() error {
+	var errs []error
+	for _, name := range SortedRetentionPresetNames(f.RetentionPresets) {
+		if err := f.RetentionPresets[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("retention_presets: %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RetentionPresets returns the built-in presets merged with those of the
+// fleet file, which override built-ins of the same name. fleet may be nil.
+func RetentionPresets(fleet *Fleet) map[string]RetentionPreset {
+	presets := make(map[string]RetentionPreset, len(builtinRetentionPresets))
+	for _, p := range builtinRetentionPresets {
+		p.BuiltIn = true
+		presets[p.Name] = p
+	}
+	if fleet != nil {
+		for name, p := range fleet.RetentionPresets {
+			p.Name = name
+			presets[name] = p
+		}
+	}
+	return presets
+}
+
+// FindRetentionPreset returns the named preset of fleet (see
+// RetentionPresets).
+func FindRetentionPreset(fleet *Fleet, name string) (*RetentionPreset, error) {
+	presets := RetentionPresets(fleet)
+	p, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown retention preset '%s' (available: %v)", name, SortedRetentionPresetNames(presets))
+	}
+	return &p, nil
+}
+
+// SortedRetentionPresetNames returns the preset names in alphabetical order.
+func SortedRetentionPresetNames(presets map[string]RetentionPreset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the preset numbers are usable.
+func (p RetentionPreset) Validate() error {
+	if p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 {
+		return fmt.Errorf("keep counts must be >= 0")
+	}
+	if p.WeeklyDay < 0 || p.WeeklyDay > 6 {
+		return fmt.Errorf("weekly_day must be between 0 (Sunday) and 6")
+	}
+	if p.MonthlyDay < 0 || p.MonthlyDay > 28 {
+		return fmt.Errorf("monthly_day must be between 1 and 28")
+	}
+	return nil
+}
+
+// Policy converts the preset into an enabled SmartRetentionPolicy.
+func (p RetentionPreset) Policy() *SmartRetentionPolicy {
+	monthlyDay := p.MonthlyDay
+	if monthlyDay == 0 {
+		monthlyDay = 1
+	}
+	return &SmartRetentionPolicy{
+		Enabled:     true,
+		KeepDaily:   p.KeepDaily,
+		KeepWeekly:  p.KeepWeekly,
+		KeepMonthly: p.KeepMonthly,
+		WeeklyDay:   p.WeeklyDay,
+		MonthlyDay:  monthlyDay,
+	}
+}
@@ -0,0 +1,210 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy is one of the two ways pruning picks what to delete:
+// keeping the Remainder most recent backups, or Smart retention.
+type RetentionPolicy struct {
+	// Spec is the policy as written, e.g. "remainder:5".
+	Spec      string
+	Remainder int
+	Smart     *SmartRetentionPolicy
+}
+
+// ParseRetentionPolicy parses a policy for 'backup retention compare':
+//
+//	remainder:N            keep the N most recent backups (create --prune)
+//	smart:D/W/M[/WD/MD]    smart retention keeping D daily, W weekly and M
+//	                       monthly backups, weeklies taken on weekday WD
+//	                       (0=Sunday, default 0) and monthlies on day MD
+//	                       (default 1)
+//	preset:NAME            a retention preset of fleet (see RetentionPresets)
+func ParseRetentionPolicy(spec string, fleet *Fleet) (RetentionPolicy, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	p := RetentionPolicy{Spec: spec}
+	switch kind {
+	case "remainder":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid policy %q: remainder needs a count >= 0, e.g. remainder:5", spec)
+		}
+		p.Remainder = n
+	case "smart":
+		fields := strings.Split(value, "/")
+		if len(fields) != 3 && len(fields) != 5 {
+			return p, fmt.Errorf("invalid policy %q: use smart:DAILY/WEEKLY/MONTHLY[/WEEKDAY/MONTHDAY], e.g. smart:14/26/6", spec)
+		}
+		nums := []int{0, 0, 0, 0, 1}
+		for i, f := range fields {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return p, fmt.Errorf("invalid policy %q: %q is not a number", spec, f)
+			}
+			nums[i] = n
+		}
+		preset := RetentionPreset{KeepDaily: nums[0], KeepWeekly: nums[1], KeepMonthly: nums[2], WeeklyDay: nums[3], MonthlyDay: nums[4]}
+		if err := preset.Validate(); err != nil {
+			return p, fmt.Errorf("invalid policy %q: %w", spec, err)
+		}
+		p.Smart = preset.Policy()
+	case "preset":
+		preset, err := FindRetentionPreset(fleet, value)
+		if err != nil {
+			return p, err
+		}
+		p.Smart = preset.Policy()
+	default:
+		return p, fmt.Errorf("invalid policy %q: use remainder:N, smart:D/W/M or preset:NAME", spec)
+	}
+	return p, nil
+}
+
+// String describes the policy.
+func (p RetentionPolicy) String() string {
+	if s := p.Smart; s != nil {
+		return fmt.Sprintf("smart retention (daily=%d, weekly=%d on %s, monthly=%d on day %d)",
+			s.KeepDaily, s.KeepWeekly, time.Weekday(s.WeeklyDay), s.KeepMonthly, s.MonthlyDay)
+	}
+	return fmt.Sprintf("keep the %d most recent", p.Remainder)
+}
+
+// selectForDeletion returns the objects of one site's backups that p
+// deletes, the same way create --prune does.
+func (bm *BackupManager) selectForDeletion(objs []ObjectInfo, p RetentionPolicy) []ObjectInfo {
+	if p.Smart != nil {
+		return bm.SelectObjectsWithSmartRetention(objs, p.Smart)
+	}
+	return bm.SelectObjectsForOverwrite(objs, p.Remainder)
+}
+
+// RetentionOutcome counts what a policy keeps and deletes.
+type RetentionOutcome struct {
+	KeptObjects    int   `json:"kept_objects"`
+	KeptBytes      int64 `json:"kept_bytes"`
+	DeletedObjects int   `json:"deleted_objects"`
+	DeletedBytes   int64 `json:"deleted_bytes"`
+}
+
+func (o *RetentionOutcome) add(size int64, deleted bool) {
+	if deleted {
+		o.DeletedObjects++
+		o.DeletedBytes += size
+	} else {
+		o.KeptObjects++
+		o.KeptBytes += size
+	}
+}
+
+func (o *RetentionOutcome) merge(other RetentionOutcome) {
+	o.KeptObjects += other.KeptObjects
+	o.KeptBytes += other.KeptBytes
+	o.DeletedObjects += other.DeletedObjects
+	o.DeletedBytes += other.DeletedBytes
+}
+
+// RetentionDifference is an object the two policies disagree on.
+type RetentionDifference struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	// DeletedBy is "a" or "b", the policy that deletes the object.
+	DeletedBy string `json:"deleted_by"`
+}
+
+// SiteRetentionComparison compares the two policies on one site.
+type SiteRetentionComparison struct {
+	Site        string                `json:"site"`
+	A           RetentionOutcome      `json:"a"`
+	B           RetentionOutcome      `json:"b"`
+	Differences []RetentionDifference `json:"differences,omitempty"`
+}
+
+// StorageDelta is how many more bytes policy B keeps than policy A;
+// negative when B keeps less.
+func (c SiteRetentionComparison) StorageDelta() int64 {
+	return c.B.KeptBytes - c.A.KeptBytes
+}
+
+// RetentionComparison is the result of CompareRetention.
+type RetentionComparison struct {
+	Prefix  string                    `json:"prefix"`
+	PolicyA string                    `json:"policy_a"`
+	PolicyB string                    `json:"policy_b"`
+	Sites   []SiteRetentionComparison `json:"sites"`
+	TotalA  RetentionOutcome          `json:"total_a"`
+	TotalB  RetentionOutcome          `json:"total_b"`
+	// StorageDelta is the fleet-wide SiteRetentionComparison.StorageDelta.
+	StorageDelta int64 `json:"storage_delta_bytes"`
+}
+
+// CompareRetention previews pruning every site under prefix with policies
+// a and b, without deleting anything. Each site's backups are pruned on
+// their own, as create --prune does, and backups that are not complete are
+// kept by both.
+func (bm *BackupManager) CompareRetention(prefix string, a, b RetentionPolicy) (*RetentionComparison, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return bm.compareRetention(prefix, objs, a, b), nil
+}
+
+// objectsBySite groups objs by site, leaving out internal objects, and
+// returns the sites sorted.
+func objectsBySite(objs []ObjectInfo) (map[string][]ObjectInfo, []string) {
+	bySite := map[string][]ObjectInfo{}
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		site := ObjectSite(o.Key)
+		bySite[site] = append(bySite[site], o)
+	}
+	sites := make([]string, 0, len(bySite))
+	for site := range bySite {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	return bySite, sites
+}
+
+func (bm *BackupManager) compareRetention(prefix string, objs []ObjectInfo, a, b RetentionPolicy) *RetentionComparison {
+	bySite, sites := objectsBySite(objs)
+
+	cmp := &RetentionComparison{Prefix: prefix, PolicyA: a.Spec, PolicyB: b.Spec, Sites: []SiteRetentionComparison{}}
+	for _, site := range sites {
+		siteObjs := bySite[site]
+		deletedA, deletedB := keySet(bm.selectForDeletion(siteObjs, a)), keySet(bm.selectForDeletion(siteObjs, b))
+		sc := SiteRetentionComparison{Site: site}
+		for _, o := range siteObjs {
+			inA, inB := deletedA[o.Key], deletedB[o.Key]
+			sc.A.add(o.Size, inA)
+			sc.B.add(o.Size, inB)
+			switch {
+			case inA && !inB:
+				sc.Differences = append(sc.Differences, RetentionDifference{Key: o.Key, Size: o.Size, DeletedBy: "a"})
+			case inB && !inA:
+				sc.Differences = append(sc.Differences, RetentionDifference{Key: o.Key, Size: o.Size, DeletedBy: "b"})
+			}
+		}
+		sort.Slice(sc.Differences, func(i, j int) bool { return sc.Differences[i].Key < sc.Differences[j].Key })
+		cmp.TotalA.merge(sc.A)
+		cmp.TotalB.merge(sc.B)
+		cmp.Sites = append(cmp.Sites, sc)
+	}
+	cmp.StorageDelta = cmp.TotalB.KeptBytes - cmp.TotalA.KeptBytes
+	return cmp
+}
+
+func keySet(objs []ObjectInfo) map[string]bool {
+	set := make(map[string]bool, len(objs))
+	for _, o := range objs {
+		set[o.Key] = true
+	}
+	return set
+}
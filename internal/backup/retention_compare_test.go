@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	p, err := ParseRetentionPolicy("remainder:5", nil)
+	if err != nil || p.Smart != nil || p.Remainder != 5 {
+		t.Errorf("remainder:5 = %+v, %v", p, err)
+	}
+	p, err = ParseRetentionPolicy("smart:14/26/6", nil)
+	if err != nil || *p.Smart != (SmartRetentionPolicy{Enabled: true, KeepDaily: 14, KeepWeekly: 26, KeepMonthly: 6, MonthlyDay: 1}) {
+		t.Errorf("smart:14/26/6 = %+v, %v", p.Smart, err)
+	}
+	p, err = ParseRetentionPolicy("smart:7/4/12/6/15", nil)
+	if err != nil || p.Smart.WeeklyDay != 6 || p.Smart.MonthlyDay != 15 {
+		t.Errorf("smart:7/4/12/6/15 = %+v, %v", p.Smart, err)
+	}
+	p, err = ParseRetentionPolicy("preset:standard", nil)
+	if err != nil || p.Smart == nil || p.Smart.KeepDaily != 14 {
+		t.Errorf("preset:standard = %+v, %v", p, err)
+	}
+	for _, bad := range []string{"", "remainder", "remainder:-1", "smart:14/26", "smart:a/b/c", "smart:1/1/1/7/1", "preset:nope", "keep:5"} {
+		if _, err := ParseRetentionPolicy(bad, nil); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestCompareRetention(t *testing.T) {
+	withTimezone(t, "UTC")
+	var objs []ObjectInfo
+	// May 2026: the 1st is a Friday, the 3rd, 10th and 17th are Sundays.
+	for d := 1; d <= 20; d++ {
+		objs = append(objs, ObjectInfo{Key: fmt.Sprintf("backups/a.com/a.com-202605%02d-020000.tgz", d), Size: 100})
+	}
+	objs = append(objs,
+		ObjectInfo{Key: "backups/b.com/b.com-20260501-020000.tgz", Size: 7},
+		ObjectInfo{Key: "_meta/a.com/run/summary.json", Size: 1})
+
+	a, _ := ParseRetentionPolicy("remainder:5", nil)
+	b, _ := ParseRetentionPolicy("smart:7/2/1", nil)
+	cmp := NewBackupManager(nil, nil).compareRetention("", objs, a, b)
+	if len(cmp.Sites) != 2 || cmp.Sites[0].Site != "a.com" {
+		t.Fatalf("sites = %+v", cmp.Sites)
+	}
+	site := cmp.Sites[0]
+	// Smart keeps the 13th-20th less the weekly 17th (7 dailies), the 17th
+	// and 10th as weeklies and the 1st as the monthly.
+	if site.A.KeptObjects != 5 || site.A.DeletedObjects != 15 || site.B.KeptObjects != 10 || site.B.DeletedObjects != 10 {
+		t.Errorf("a.com = A %+v, B %+v", site.A, site.B)
+	}
+	if site.StorageDelta() != 500 || cmp.StorageDelta != 500 {
+		t.Errorf("storage delta = %d, %d", site.StorageDelta(), cmp.StorageDelta)
+	}
+	var onlyA []string
+	for _, d := range site.Differences {
+		if d.DeletedBy != "a" {
+			t.Errorf("%s deleted by %s only", d.Key, d.DeletedBy)
+		}
+		onlyA = append(onlyA, d.Key[len("backups/a.com/a.com-202605"):len("backups/a.com/a.com-20260501")])
+	}
+	if fmt.Sprint(onlyA) != "[01 10 13 14 15]" {
+		t.Errorf("deleted by A only = %v", onlyA)
+	}
+	if cmp.TotalA.KeptBytes != 507 || cmp.TotalB.KeptObjects != 11 || cmp.TotalB.DeletedBytes != 1000 {
+		t.Errorf("totals = A %+v, B %+v", cmp.TotalA, cmp.TotalB)
+	}
+}
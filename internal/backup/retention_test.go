@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetentionPresets(t *testing.T) {
+	presets := RetentionPresets(nil)
+	for _, name := range []string{"standard", "aggressive", "archive-heavy"} {
+		if p, ok := presets[name]; !ok || !p.BuiltIn {
+			t.Errorf("expected built-in preset %q", name)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	content := `retention_presets:
+  standard:
+    keep_daily: 30
+    keep_weekly: 26
+    keep_monthly: 12
+  client:
+    keep_daily: 7
+    keep_weekly: 12
+    keep_monthly: 24
+    weekly_day: 5
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fleet, err := LoadFleet(path)
+	if err != nil {
+		t.Fatalf("LoadFleet() error = %v", err)
+	}
+
+	presets = RetentionPresets(fleet)
+	if p := presets["standard"]; p.KeepDaily != 30 || p.BuiltIn {
+		t.Errorf("fleet preset should override built-in standard, got %+v", p)
+	}
+
+	client, err := FindRetentionPreset(fleet, "client")
+	if err != nil {
+		t.Fatalf("FindRetentionPreset() error = %v", err)
+	}
+	policy := client.Policy()
+	if client.Name != "client" || !policy.Enabled || policy.KeepMonthly != 24 || policy.WeeklyDay != 5 || policy.MonthlyDay != 1 {
+		t.Errorf("Policy() = %+v", policy)
+	}
+
+	if _, err := FindRetentionPreset(fleet, "nope"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestFleetRejectsInvalidRetentionPreset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleet.yaml")
+	if err := os.WriteFile(path, []byte("retention_presets:\n  bad:\n    keep_daily: -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFleet(path); err == nil {
+		t.Error("expected validation error for negative keep_daily")
+	}
+}
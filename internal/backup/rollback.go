@@ -0,0 +1,301 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Kinds of rollback point.
+const (
+	// RollbackSite is a site directory moved aside by `backup restore
+	// --replace`, with a dump of its database.
+	RollbackSite = "site"
+	// RollbackDatabase is the safety export of `backup restore-db`.
+	RollbackDatabase = "database"
+)
+
+// RollbackPoint is the state of a site before a restore overwrote it, which
+// `backup restore --rollback-last` returns it to. Paths are on Host.
+type RollbackPoint struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Host is the server the restore ran on, empty for the local host.
+	Host      string    `json:"host,omitempty"`
+	Site      string    `json:"site,omitempty"`
+	ObjectKey string    `json:"object_key"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// SiteDir is the restored site directory and SnapshotDir where the
+	// previous one was moved to.
+	SiteDir     string `json:"site_dir,omitempty"`
+	SnapshotDir string `json:"snapshot_dir,omitempty"`
+	// DatabaseDump is the export of the database taken before the import,
+	// from Container; empty when no container was running.
+	DatabaseDump string `json:"database_dump,omitempty"`
+	Container    string `json:"container,omitempty"`
+	// ImportMethod is how DatabaseDump is imported back ("wp" or "mysql");
+	// empty is "wp".
+	ImportMethod string `json:"import_method,omitempty"`
+
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+}
+
+// RollbackState is the registry of rollback points.
+type RollbackState struct {
+	Points []RollbackPoint `json:"points"`
+}
+
+// DefaultRollbackPath returns the default location of the rollback point
+// registry (~/.ciwg/restore-rollbacks.json).
+func DefaultRollbackPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-restore-rollbacks.json")
+	}
+	return filepath.Join(home, ".ciwg", "restore-rollbacks.json")
+}
+
+// LoadRollbackState reads the registry at path. A missing file yields an
+// empty registry.
+func LoadRollbackState(path string) (*RollbackState, error) {
+	state := &RollbackState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollback points: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid rollback points %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Save writes the registry to path through a temp file.
+func (s *RollbackState) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create rollback points directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollback points: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".restore-rollbacks-*")
+	if err != nil {
+		return fmt.Errorf("failed to write rollback points: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write rollback points: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write rollback points: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace rollback points: %w", err)
+	}
+	return nil
+}
+
+// Last returns the most recent point on host not rolled back yet, of site
+// when given.
+func (s *RollbackState) Last(host, site string) (*RollbackPoint, bool) {
+	for i := len(s.Points) - 1; i >= 0; i-- {
+		p := &s.Points[i]
+		if p.RolledBackAt == nil && p.Host == host && (site == "" || p.Site == site) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// LastRollbackPoint is Last for the host bm runs commands on.
+func (bm *BackupManager) LastRollbackPoint(s *RollbackState, site string) (*RollbackPoint, bool) {
+	return s.Last(bm.remoteHost(), site)
+}
+
+// registerRollbackPoint adds p to the registry at path.
+func registerRollbackPoint(path string, p RollbackPoint) error {
+	if path == "" {
+		path = DefaultRollbackPath()
+	}
+	state, err := LoadRollbackState(path)
+	if err != nil {
+		return err
+	}
+	state.Points = append(state.Points, p)
+	if err := state.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("↩️  Rollback point %s registered; revert with `backup restore --rollback-last`\n", p.ID)
+	return nil
+}
+
+// rollbackDir is where the snapshot of siteDir taken at now is kept: next to
+// the site directory, so moving it there is a rename on the same
+// filesystem.
+func rollbackDir(siteDir string, now time.Time) string {
+	return path.Join(path.Dir(siteDir), ".ciwg-rollback", path.Base(siteDir)+"-"+InTimezone(now).Format("20060102-150405"))
+}
+
+// snapshotSite moves the existing siteDir aside before a restore replaces
+// it: the database is exported from the running container (named container,
+// or the site's wp_ container), the stack is stopped and the directory is
+// renamed into the rollback directory. It returns the rollback point, not
+// yet registered.
+func (bm *BackupManager) snapshotSite(siteDir, container, objectName, site string) (*RollbackPoint, error) {
+	now := time.Now()
+	dir := rollbackDir(siteDir, now)
+	p := &RollbackPoint{
+		ID:          NewRunID(now),
+		Kind:        RollbackSite,
+		Host:        bm.remoteHost(),
+		Site:        site,
+		ObjectKey:   objectName,
+		CreatedAt:   now.UTC(),
+		SiteDir:     siteDir,
+		SnapshotDir: path.Join(dir, "site"),
+	}
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`mkdir -p %s`, shellQuote(dir))); err != nil {
+		return nil, fmt.Errorf("failed to create rollback directory %s: %w (stderr: %s)", dir, err, stderr)
+	}
+
+	stdout, _, _ := bm.executeCommand(fmt.Sprintf(`docker ps --filter %s --format '{{.Names}}'`,
+		shellQuote("label=com.docker.compose.project.working_dir="+siteDir)))
+	if container != "" || strings.TrimSpace(stdout) != "" {
+		name, err := bm.restoredContainer(siteDir, container)
+		if err != nil {
+			return nil, err
+		}
+		dump := path.Join(dir, "database.sql")
+		fmt.Printf("Exporting current database of %s to %s...\n", name, dump)
+		exportCmd := fmt.Sprintf(`docker exec -u 0 %s wp --allow-root db export - > %s`, shellQuote(name), shellQuote(dump))
+		if _, stderr, err := bm.executeCommand(exportCmd); err != nil {
+			return nil, fmt.Errorf("safety export failed, aborting restore: %w (stderr: %s)", err, stderr)
+		}
+		p.DatabaseDump, p.Container = dump, name
+	} else {
+		fmt.Printf("⚠️  No container of %s is running; the rollback point holds its files only\n", siteDir)
+	}
+
+	fmt.Printf("Stopping %s and moving it to %s...\n", siteDir, p.SnapshotDir)
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`cd %s && docker compose down`, shellQuote(siteDir))); err != nil {
+		fmt.Printf("⚠️  Failed to stop %s: %v (stderr: %s)\n", siteDir, err, stderr)
+	}
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`mv %s %s`, shellQuote(siteDir), shellQuote(p.SnapshotDir))); err != nil {
+		return nil, fmt.Errorf("failed to move %s aside: %w (stderr: %s)", siteDir, err, stderr)
+	}
+	return p, nil
+}
+
+// Rollback returns a site to rollback point p. For a site point the
+// restored directory is stopped and kept as failed-<time> in the rollback
+// directory, the snapshot is moved back and started and its database dump
+// imported; for a database point the safety export is imported into its
+// container. The point is then marked rolled back in registry.
+func (bm *BackupManager) Rollback(registry string, p *RollbackPoint, dryRun bool) error {
+	if registry == "" {
+		registry = DefaultRollbackPath()
+	}
+	switch p.Kind {
+	case RollbackSite:
+		if err := bm.rollbackSite(p, dryRun); err != nil {
+			return err
+		}
+	case RollbackDatabase:
+		if err := bm.importRollbackDump(p, dryRun); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown rollback point kind %q", p.Kind)
+	}
+	if dryRun {
+		return nil
+	}
+
+	state, err := LoadRollbackState(registry)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for i := range state.Points {
+		if state.Points[i].ID == p.ID && state.Points[i].Host == p.Host {
+			state.Points[i].RolledBackAt = &now
+		}
+	}
+	if err := state.Save(registry); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Rolled back to %s (before the restore of %s)\n", p.ID, p.ObjectKey)
+	return nil
+}
+
+func (bm *BackupManager) rollbackSite(p *RollbackPoint, dryRun bool) error {
+	failed := path.Join(path.Dir(p.SnapshotDir), "failed-"+InTimezone(time.Now()).Format("20060102-150405"))
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would stop %s and move it to %s\n", p.SiteDir, failed)
+		fmt.Printf("[DRY RUN] Would move %s back to %s and start it\n", p.SnapshotDir, p.SiteDir)
+		return bm.importRollbackDump(p, true)
+	}
+	if _, _, err := bm.executeCommand(fmt.Sprintf(`test -d %s`, shellQuote(p.SnapshotDir))); err != nil {
+		return fmt.Errorf("snapshot %s is gone", p.SnapshotDir)
+	}
+	if _, _, err := bm.executeCommand(fmt.Sprintf(`test -e %s`, shellQuote(p.SiteDir))); err == nil {
+		fmt.Printf("Stopping %s and moving it to %s...\n", p.SiteDir, failed)
+		if _, stderr, err := bm.executeCommand(fmt.Sprintf(`cd %s && docker compose down`, shellQuote(p.SiteDir))); err != nil {
+			fmt.Printf("⚠️  Failed to stop %s: %v (stderr: %s)\n", p.SiteDir, err, stderr)
+		}
+		if _, stderr, err := bm.executeCommand(fmt.Sprintf(`mv %s %s`, shellQuote(p.SiteDir), shellQuote(failed))); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w (stderr: %s)", p.SiteDir, err, stderr)
+		}
+	}
+	fmt.Printf("Moving %s back to %s...\n", p.SnapshotDir, p.SiteDir)
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`mv %s %s`, shellQuote(p.SnapshotDir), shellQuote(p.SiteDir))); err != nil {
+		return fmt.Errorf("failed to move %s back: %w (stderr: %s)", p.SnapshotDir, err, stderr)
+	}
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`cd %s && docker compose up -d`, shellQuote(p.SiteDir))); err != nil {
+		return fmt.Errorf("failed to start %s: %w (stderr: %s)", p.SiteDir, err, stderr)
+	}
+	return bm.importRollbackDump(p, false)
+}
+
+// importMethod returns how the database dump of p is imported.
+func (p *RollbackPoint) importMethod() string {
+	if p.ImportMethod == "" {
+		return "wp"
+	}
+	return p.ImportMethod
+}
+
+// importRollbackDump imports the database dump of p, if it has one.
+func (bm *BackupManager) importRollbackDump(p *RollbackPoint, dryRun bool) error {
+	if p.DatabaseDump == "" {
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would import %s into %s\n", p.DatabaseDump, p.Container)
+		return nil
+	}
+	method := p.importMethod()
+	importCmd, err := buildDBImportCommand(p.Container, method)
+	if err != nil {
+		return err
+	}
+	if err := bm.waitForDatabase(p.Container, method); err != nil {
+		return err
+	}
+	fmt.Printf("Importing %s into %s...\n", p.DatabaseDump, p.Container)
+	if _, stderr, err := bm.executeCommand(fmt.Sprintf(`%s < %s`, importCmd, shellQuote(p.DatabaseDump))); err != nil {
+		return fmt.Errorf("database import failed: %w (stderr: %s)", err, stderr)
+	}
+	return nil
+}
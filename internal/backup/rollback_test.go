@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreSiteReplace(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	key := "backups/foo.com/foo.com-20240101-020000.tgz"
+	putTestObject(t, bm, key, string(buildTarball(t, siteFiles, siteOrder)))
+	target := t.TempDir()
+	registry := filepath.Join(t.TempDir(), "rollbacks.json")
+	siteDir := filepath.Join(target, "foo.com")
+	os.MkdirAll(siteDir, 0o755)
+	os.WriteFile(filepath.Join(siteDir, "marker"), []byte("before"), 0o644)
+
+	opts := &RestoreSiteOptions{As: "foo.com", TargetDir: target, NoStart: true, RollbackFile: registry}
+	if err := bm.RestoreSite(key, opts); err == nil || !strings.Contains(err.Error(), "--replace") {
+		t.Fatalf("RestoreSite() onto the source site without --replace = %v", err)
+	}
+	opts.Replace = true
+	if err := bm.RestoreSite(key, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// In place, the site keeps its names and database.
+	compose, _ := os.ReadFile(filepath.Join(siteDir, "docker-compose.yml"))
+	if string(compose) != siteFiles["var/opt/sites/foo.com/docker-compose.yml"] {
+		t.Errorf("compose file was rewritten in place:\n%s", compose)
+	}
+	if _, err := os.Stat(filepath.Join(siteDir, "marker")); !os.IsNotExist(err) {
+		t.Error("the previous site was not moved aside")
+	}
+
+	state, err := LoadRollbackState(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, ok := bm.LastRollbackPoint(state, "foo.com")
+	if !ok || point.Kind != RollbackSite || point.SiteDir != siteDir || point.ObjectKey != key || point.DatabaseDump != "" {
+		t.Fatalf("rollback point = %+v", point)
+	}
+	if data, err := os.ReadFile(filepath.Join(point.SnapshotDir, "marker")); err != nil || string(data) != "before" {
+		t.Errorf("snapshot = %q, %v", data, err)
+	}
+	if !strings.HasPrefix(point.SnapshotDir, filepath.Join(target, ".ciwg-rollback", "foo.com-")) {
+		t.Errorf("snapshot dir = %s", point.SnapshotDir)
+	}
+}
+
+func TestRollbackStateLast(t *testing.T) {
+	done := time.Now()
+	s := &RollbackState{Points: []RollbackPoint{
+		{ID: "1", Host: "wp0", Site: "a.com"},
+		{ID: "2", Host: "wp0", Site: "b.com"},
+		{ID: "3", Host: "wp0", Site: "b.com", RolledBackAt: &done},
+		{ID: "4", Host: "wp1", Site: "a.com"},
+	}}
+	tests := []struct {
+		host, site, want string
+	}{
+		{"wp0", "", "2"},
+		{"wp0", "a.com", "1"},
+		{"wp1", "", "4"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		p, ok := s.Last(tt.host, tt.site)
+		if got := ""; ok {
+			got = p.ID
+			if got != tt.want {
+				t.Errorf("Last(%q, %q) = %s, want %s", tt.host, tt.site, got, tt.want)
+			}
+		} else if tt.want != "" {
+			t.Errorf("Last(%q, %q) found nothing, want %s", tt.host, tt.site, tt.want)
+		}
+	}
+}
+
+func TestRollbackDatabaseDryRun(t *testing.T) {
+	registry := filepath.Join(t.TempDir(), "rollbacks.json")
+	point := RollbackPoint{ID: "r1", Kind: RollbackDatabase, ObjectKey: "backups/a.com/a.com-1.tgz", DatabaseDump: "/var/tmp/x.sql", Container: "wp_a"}
+	if err := registerRollbackPoint(registry, point); err != nil {
+		t.Fatal(err)
+	}
+	bm, _ := newFileBackedManager(t)
+	if err := bm.Rollback(registry, &point, true); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := LoadRollbackState(registry)
+	if len(state.Points) != 1 || state.Points[0].RolledBackAt != nil {
+		t.Errorf("a dry run marked the point rolled back: %+v", state.Points)
+	}
+}
+
+func TestRollbackImportMethod(t *testing.T) {
+	if got := (&RollbackPoint{}).importMethod(); got != "wp" {
+		t.Errorf("default import method = %q, want wp", got)
+	}
+	if got := (&RollbackPoint{ImportMethod: "mysql"}).importMethod(); got != "mysql" {
+		t.Errorf("import method = %q, want mysql", got)
+	}
+	// The recorded method is used, so an unknown one fails before any
+	// command runs.
+	bm, _ := newFileBackedManager(t)
+	p := &RollbackPoint{DatabaseDump: "/var/tmp/x.sql", Container: "wp_a", ImportMethod: "psql"}
+	if err := bm.importRollbackDump(p, false); err == nil {
+		t.Error("expected an error for an unknown import method")
+	}
+}
@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runMetaPrefix is where the artifacts of runs are kept in the bucket, under
+// _meta/<site>/<run-id>/, so a run can be inspected from any machine. Like
+// .ciwg/ they are never backups.
+const runMetaPrefix = "_meta/"
+
+// Artifacts stored for each site of a run.
+const (
+	// RunSummaryArtifact is the run record as written to the local history.
+	RunSummaryArtifact = "summary.json"
+	// RunValidationArtifact is the site's SiteValidation report.
+	RunValidationArtifact = "validation.json"
+	// RunCommandsArtifact holds the audited commands of the site and those
+	// of the run that belong to no site (discovery, capacity checks), one
+	// CommandTrace per line.
+	RunCommandsArtifact = "commands.jsonl"
+)
+
+// DefaultRunArtifactRetention is how long run artifacts are kept when
+// --run-artifacts-retention-days is not set.
+const DefaultRunArtifactRetention = 90 * 24 * time.Hour
+
+func runMetaDir(site, runID string) string {
+	return runMetaPrefix + site + "/" + runID + "/"
+}
+
+// SiteValidation is what a run checked about one site: the post-upload and
+// Glacier checks of its uploads, its failures with their diagnosis and why
+// it was skipped.
+type SiteValidation struct {
+	RunID    string             `json:"run_id"`
+	Site     string             `json:"site"`
+	Uploads  []UploadValidation `json:"uploads,omitempty"`
+	Failures []ContainerFailure `json:"failures,omitempty"`
+	Skipped  []ContainerSkip    `json:"skipped,omitempty"`
+}
+
+// UploadValidation is the outcome of the checks on one uploaded object.
+type UploadValidation struct {
+	ObjectKey           string          `json:"object_key"`
+	Bytes               int64           `json:"bytes"`
+	PostUploadCheck     string          `json:"post_upload_check,omitempty"`
+	Checksum            string          `json:"checksum,omitempty"`
+	GlacierVerification string          `json:"glacier_verification,omitempty"`
+	TarWarnings         map[string]int  `json:"tar_warnings,omitempty"`
+	NestedArchives      []NestedArchive `json:"nested_archives,omitempty"`
+	Missed              []string        `json:"missed,omitempty"`
+}
+
+// Sites returns the sites rec uploaded, failed or skipped, sorted.
+func (r *RunRecord) Sites() []string {
+	seen := map[string]bool{}
+	for _, u := range r.Uploads {
+		seen[u.Site] = true
+	}
+	for _, f := range r.Failures {
+		seen[f.Site] = true
+	}
+	for _, s := range r.Skipped {
+		seen[s.Site] = true
+	}
+	delete(seen, "")
+	sites := make([]string, 0, len(seen))
+	for s := range seen {
+		sites = append(sites, s)
+	}
+	sort.Strings(sites)
+	return sites
+}
+
+// siteValidation extracts the validation report of site from rec.
+func siteValidation(rec *RunRecord, site string) *SiteValidation {
+	v := &SiteValidation{RunID: rec.ID, Site: site}
+	for _, u := range rec.Uploads {
+		if u.Site != site {
+			continue
+		}
+		uv := UploadValidation{ObjectKey: u.ObjectKey, Bytes: u.Bytes, PostUploadCheck: u.PostUploadCheck,
+			Checksum: u.Checksum, TarWarnings: u.TarWarnings, NestedArchives: u.NestedArchives, Missed: u.Missed}
+		if u.Glacier != nil {
+			uv.GlacierVerification = u.Glacier.Verification
+		}
+		v.Uploads = append(v.Uploads, uv)
+	}
+	for _, f := range rec.Failures {
+		if f.Site == site {
+			v.Failures = append(v.Failures, f)
+		}
+	}
+	for _, s := range rec.Skipped {
+		if s.Site == site {
+			v.Skipped = append(v.Skipped, s)
+		}
+	}
+	return v
+}
+
+// StoreRunArtifacts writes the summary, validation report and audited
+// commands of rec to _meta/<site>/<run-id>/ for every site of the run and
+// returns how many sites it stored.
+func (bm *BackupManager) StoreRunArtifacts(rec *RunRecord, traces []CommandTrace) (int, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	summary, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal run summary: %w", err)
+	}
+	stored := 0
+	for _, site := range rec.Sites() {
+		dir := runMetaDir(site, rec.ID)
+		validation, err := json.MarshalIndent(siteValidation(rec, site), "", "  ")
+		if err != nil {
+			return stored, fmt.Errorf("failed to marshal validation report of %s: %w", site, err)
+		}
+		artifacts := map[string][]byte{RunSummaryArtifact: summary, RunValidationArtifact: validation}
+		var commands bytes.Buffer
+		for _, t := range traces {
+			if t.Site == site || t.Site == "" {
+				line, err := json.Marshal(t)
+				if err != nil {
+					return stored, fmt.Errorf("failed to marshal command trace: %w", err)
+				}
+				commands.Write(append(line, '\n'))
+			}
+		}
+		if commands.Len() > 0 {
+			artifacts[RunCommandsArtifact] = commands.Bytes()
+		}
+		for name, data := range artifacts {
+			contentType := "application/json"
+			if name == RunCommandsArtifact {
+				contentType = "application/x-ndjson"
+			}
+			if _, err := bm.putObject(ctx, dir+name, bytes.NewReader(data), int64(len(data)), contentType, nil); err != nil {
+				return stored, fmt.Errorf("failed to store %s: %w", dir+name, err)
+			}
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// PruneRunArtifacts deletes the artifacts of site's runs stored more than
+// keep before now, a run at a time, and returns how many runs it removed.
+func (bm *BackupManager) PruneRunArtifacts(site string, keep time.Duration, now time.Time) (int, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	prefix := runMetaPrefix + site + "/"
+	objs, err := bm.listObjects(ctx, prefix, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list run artifacts of %s: %w", site, err)
+	}
+	runs := map[string][]ObjectInfo{}
+	newest := map[string]time.Time{}
+	for _, o := range objs {
+		runID, _, ok := strings.Cut(strings.TrimPrefix(o.Key, prefix), "/")
+		if !ok {
+			continue
+		}
+		runs[runID] = append(runs[runID], o)
+		if o.LastModified.After(newest[runID]) {
+			newest[runID] = o.LastModified
+		}
+	}
+	removed := 0
+	for runID, runObjs := range runs {
+		if now.Sub(newest[runID]) <= keep {
+			continue
+		}
+		for _, o := range runObjs {
+			if err := bm.removeObject(ctx, o.Key); err != nil {
+				return removed, fmt.Errorf("failed to delete %s: %w", o.Key, err)
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RunArtifacts is a run with its validation reports and audited commands,
+// as stored in the bucket.
+type RunArtifacts struct {
+	Record *RunRecord `json:"summary"`
+	// Sites are the sites the run stored artifacts for.
+	Sites       []string         `json:"sites"`
+	Validations []SiteValidation `json:"validations,omitempty"`
+	Commands    []CommandTrace   `json:"commands,omitempty"`
+}
+
+// NewRunArtifacts builds the artifacts StoreRunArtifacts would store for rec
+// and traces, for runs read from the local history.
+func NewRunArtifacts(rec *RunRecord, traces []CommandTrace) *RunArtifacts {
+	arts := &RunArtifacts{Record: rec, Sites: rec.Sites(), Commands: traces}
+	for _, site := range arts.Sites {
+		arts.Validations = append(arts.Validations, *siteValidation(rec, site))
+	}
+	return arts
+}
+
+// FetchRunArtifacts reads the artifacts of runID from the bucket, looking
+// only under site when it is set. It fails with ErrObjectNotFound when the
+// run stored none.
+func (bm *BackupManager) FetchRunArtifacts(runID, site string) (*RunArtifacts, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	prefix := runMetaPrefix
+	if site != "" {
+		prefix = runMetaDir(site, runID)
+	}
+	objs, err := bm.listObjects(ctx, prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list run artifacts: %w", err)
+	}
+	arts := &RunArtifacts{}
+	seen := map[string]bool{}
+	for _, o := range objs {
+		rest := strings.TrimPrefix(o.Key, runMetaPrefix)
+		parts := strings.Split(rest, "/")
+		if len(parts) != 3 || parts[1] != runID {
+			continue
+		}
+		objSite, name := parts[0], parts[2]
+		data, err := bm.readRunArtifact(ctx, o.Key)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case RunSummaryArtifact:
+			arts.Sites = append(arts.Sites, objSite)
+			if arts.Record == nil {
+				var rec RunRecord
+				if err := json.Unmarshal(data, &rec); err != nil {
+					return nil, fmt.Errorf("invalid run summary %s: %w", o.Key, err)
+				}
+				arts.Record = &rec
+			}
+		case RunValidationArtifact:
+			var v SiteValidation
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, fmt.Errorf("invalid validation report %s: %w", o.Key, err)
+			}
+			arts.Validations = append(arts.Validations, v)
+		case RunCommandsArtifact:
+			// Commands that belong to no site are stored with every site.
+			for _, line := range strings.Split(string(data), "\n") {
+				var t CommandTrace
+				if line == "" || json.Unmarshal([]byte(line), &t) != nil || seen[line] {
+					continue
+				}
+				seen[line] = true
+				arts.Commands = append(arts.Commands, t)
+			}
+		}
+	}
+	if arts.Record == nil {
+		return nil, fmt.Errorf("%w: no artifacts of run %s under %s", ErrObjectNotFound, runID, prefix)
+	}
+	sort.Slice(arts.Commands, func(i, j int) bool { return arts.Commands[i].StartedAt.Before(arts.Commands[j].StartedAt) })
+	return arts, nil
+}
+
+func (bm *BackupManager) readRunArtifact(ctx context.Context, key string) ([]byte, error) {
+	r, err := bm.getObject(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return data, nil
+}
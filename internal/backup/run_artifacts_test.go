@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func artifactRun(id string) *RunRecord {
+	return &RunRecord{
+		ID: id, Host: "web1", Succeeded: 1, Failed: 1,
+		Uploads:  []UploadStats{{Site: "a.com", ObjectKey: "backups/a.com/a-1.tgz", Bytes: 10, PostUploadCheck: "quick"}},
+		Failures: []ContainerFailure{{Container: "wp_b", Site: "b.com", Code: FailureDiskFull, Error: "no space left on device"}},
+	}
+}
+
+func TestStoreAndFetchRunArtifacts(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	traces := []CommandTrace{
+		{RunID: "r1", Command: "docker ps", StartedAt: time.Unix(1, 0)},
+		{RunID: "r1", Site: "a.com", Command: "tar czf a.tgz", StartedAt: time.Unix(2, 0)},
+		{RunID: "r1", Site: "b.com", Command: "wp db export", StartedAt: time.Unix(3, 0)},
+	}
+	stored, err := bm.StoreRunArtifacts(artifactRun("r1"), traces)
+	if err != nil || stored != 2 {
+		t.Fatalf("StoreRunArtifacts() = %d, %v", stored, err)
+	}
+
+	arts, err := bm.FetchRunArtifacts("r1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arts.Record.Host != "web1" || strings.Join(arts.Sites, ",") != "a.com,b.com" || len(arts.Validations) != 2 {
+		t.Errorf("artifacts = %+v", arts)
+	}
+	// The run-wide command is stored with both sites but listed once.
+	if len(arts.Commands) != 3 || arts.Commands[0].Command != "docker ps" {
+		t.Errorf("commands = %+v", arts.Commands)
+	}
+
+	arts, err = bm.FetchRunArtifacts("r1", "b.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arts.Validations) != 1 || len(arts.Validations[0].Failures) != 1 || len(arts.Commands) != 2 {
+		t.Errorf("artifacts of b.com = %+v", arts)
+	}
+
+	if _, err := bm.FetchRunArtifacts("r2", ""); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("FetchRunArtifacts(unknown run) error = %v", err)
+	}
+	if !isInternalObject(runMetaDir("a.com", "r1") + RunSummaryArtifact) {
+		t.Error("run artifacts are not internal objects")
+	}
+}
+
+func TestPruneRunArtifacts(t *testing.T) {
+	bm, dir := newFileBackedManager(t)
+	for _, id := range []string{"old", "new"} {
+		if _, err := bm.StoreRunArtifacts(artifactRun(id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	for _, name := range []string{RunSummaryArtifact, RunValidationArtifact} {
+		if err := os.Chtimes(filepath.Join(dir, runMetaDir("a.com", "old")+name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := bm.PruneRunArtifacts("a.com", DefaultRunArtifactRetention, time.Now())
+	if err != nil || removed != 1 {
+		t.Fatalf("PruneRunArtifacts() = %d, %v", removed, err)
+	}
+	if _, err := bm.FetchRunArtifacts("old", "a.com"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("old run still stored: %v", err)
+	}
+	for _, id := range []string{"new", "old"} {
+		if _, err := bm.FetchRunArtifacts(id, "b.com"); err != nil {
+			t.Errorf("run %s of b.com was pruned: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RunStatus is a snapshot of an in-flight CreateBackups run, as dumped on
+// SIGUSR1 or served on the status socket.
+type RunStatus struct {
+	Active             bool      `json:"active"`
+	RunID              string    `json:"run_id,omitempty"`
+	StartedAt          time.Time `json:"started_at,omitempty"`
+	ElapsedSeconds     float64   `json:"elapsed_seconds"`
+	Position           int       `json:"position"`
+	Total              int       `json:"total"`
+	Container          string    `json:"container,omitempty"`
+	ContainerStartedAt time.Time `json:"container_started_at,omitempty"`
+	Object             string    `json:"object,omitempty"`
+	ObjectBytes        int64     `json:"object_bytes"`
+	UploadedBytes      int64     `json:"uploaded_bytes"`
+	Succeeded          int       `json:"succeeded"`
+	Failed             int       `json:"failed"`
+	Remaining          []string  `json:"remaining"`
+}
+
+// runTracker records the progress of the current run for RunStatus. The
+// byte counter of the current object is updated by the upload stream, so it
+// is atomic; everything else changes between containers under mu.
+type runTracker struct {
+	mu                 sync.Mutex
+	active             bool
+	runID              string
+	startedAt          time.Time
+	queue              []string
+	position           int
+	containerStartedAt time.Time
+	object             string
+	doneBytes          int64
+	succeeded          int
+	failed             int
+	objectBytes        atomic.Int64
+}
+
+func (t *runTracker) begin(runID string, startedAt time.Time, containers []ContainerInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active, t.runID, t.startedAt = true, runID, startedAt
+	t.queue = make([]string, len(containers))
+	for i, c := range containers {
+		t.queue[i] = c.Name
+	}
+	t.position, t.object, t.doneBytes, t.succeeded, t.failed = 0, "", 0, 0, 0
+	t.objectBytes.Store(0)
+}
+
+func (t *runTracker) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = false
+}
+
+// startContainer marks queue[position-1] as the container being processed.
+func (t *runTracker) startContainer(position int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.position = position
+	t.containerStartedAt = time.Now()
+	t.object = ""
+	t.doneBytes += t.objectBytes.Swap(0)
+}
+
+func (t *runTracker) finishContainer(ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ok {
+		t.succeeded++
+	} else {
+		t.failed++
+	}
+}
+
+// trackUpload counts the bytes of objectName read from r as the current
+// object of the run.
+func (t *runTracker) trackUpload(objectName string, r io.Reader) io.Reader {
+	t.mu.Lock()
+	t.object = objectName
+	t.doneBytes += t.objectBytes.Swap(0)
+	t.mu.Unlock()
+	return &statusReader{r: r, n: &t.objectBytes}
+}
+
+func (t *runTracker) snapshot() RunStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := RunStatus{Active: t.active, Remaining: []string{}}
+	if !t.active {
+		return s
+	}
+	s.RunID = t.runID
+	s.StartedAt = t.startedAt
+	s.ElapsedSeconds = time.Since(t.startedAt).Seconds()
+	s.Position = t.position
+	s.Total = len(t.queue)
+	if t.position > 0 {
+		s.Container = t.queue[t.position-1]
+		s.ContainerStartedAt = t.containerStartedAt
+	}
+	s.Object = t.object
+	s.ObjectBytes = t.objectBytes.Load()
+	s.UploadedBytes = t.doneBytes + s.ObjectBytes
+	s.Succeeded, s.Failed = t.succeeded, t.failed
+	s.Remaining = append(s.Remaining, t.queue[min(t.position, len(t.queue)):]...)
+	return s
+}
+
+// statusReader adds the bytes read through it to n.
+type statusReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (s *statusReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n.Add(int64(n))
+	return n, err
+}
+
+// RunStatus returns the progress of the CreateBackups run in progress.
+// It is safe to call from another goroutine while the run is going.
+func (bm *BackupManager) RunStatus() RunStatus {
+	return bm.status.snapshot()
+}
+
+// WriteRunStatus writes s in the human-readable form used for SIGUSR1.
+func WriteRunStatus(w io.Writer, s RunStatus) {
+	if !s.Active {
+		fmt.Fprintln(w, "ℹ️  No backup run in progress")
+		return
+	}
+	mb := func(n int64) float64 { return float64(n) / (1024 * 1024) }
+	elapsed := time.Duration(s.ElapsedSeconds * float64(time.Second)).Round(time.Second)
+	fmt.Fprintf(w, "\n=== Backup run %s: %s elapsed ===\n", s.RunID, elapsed)
+	if s.Container == "" {
+		fmt.Fprintf(w, "Container: none started yet (%d queued)\n", s.Total)
+	} else {
+		fmt.Fprintf(w, "Container: [%d/%d] %s (running %s)\n", s.Position, s.Total, s.Container,
+			time.Since(s.ContainerStartedAt).Round(time.Second))
+	}
+	if s.Object != "" {
+		fmt.Fprintf(w, "Object:    %s (%.2f MB uploaded)\n", s.Object, mb(s.ObjectBytes))
+	}
+	fmt.Fprintf(w, "Uploaded:  %.2f MB this run, %d succeeded, %d failed\n", mb(s.UploadedBytes), s.Succeeded, s.Failed)
+	if len(s.Remaining) == 0 {
+		fmt.Fprintln(w, "Remaining: none")
+	} else {
+		fmt.Fprintf(w, "Remaining: %d (%s)\n", len(s.Remaining), strings.Join(s.Remaining, ", "))
+	}
+}
+
+// ServeRunStatus reports the manager's run status until the returned stop
+// function is called: on SIGUSR1 as text on stderr, and, when socketPath is
+// set, as JSON to every client connecting to that unix socket
+// (e.g. socat - UNIX-CONNECT:<path>).
+func (bm *BackupManager) ServeRunStatus(socketPath string) (func(), error) {
+	var ln net.Listener
+	if socketPath != "" {
+		var err error
+		ln, err = listenStatusSocket(socketPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-sigs:
+				WriteRunStatus(os.Stderr, bm.RunStatus())
+			case <-done:
+				return
+			}
+		}
+	}()
+	if ln != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+				enc := json.NewEncoder(conn)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(bm.RunStatus()); err != nil {
+					bm.logDebug("Failed to write run status: %v", err)
+				}
+				conn.Close()
+			}
+		}()
+	}
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+		if ln != nil {
+			ln.Close()
+		}
+		wg.Wait()
+	}, nil
+}
+
+// listenStatusSocket listens on path, replacing a socket left behind by a
+// run that did not shut down cleanly but refusing one that still answers.
+func listenStatusSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("status socket %s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("status socket %s is in use by another run", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale status socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on status socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict status socket: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTrackerSnapshot(t *testing.T) {
+	var tr runTracker
+	if s := tr.snapshot(); s.Active {
+		t.Fatalf("snapshot() before a run = %+v, want inactive", s)
+	}
+
+	tr.begin("run-1", time.Now().Add(-time.Minute), []ContainerInfo{{Name: "wp_a"}, {Name: "wp_b"}, {Name: "wp_c"}})
+	tr.startContainer(1)
+	io.Copy(io.Discard, tr.trackUpload("backups/a/a.tgz", strings.NewReader(strings.Repeat("x", 100))))
+	tr.finishContainer(true)
+	tr.startContainer(2)
+	io.Copy(io.Discard, tr.trackUpload("backups/b/b.tgz", strings.NewReader(strings.Repeat("x", 40))))
+
+	s := tr.snapshot()
+	if !s.Active || s.RunID != "run-1" || s.Position != 2 || s.Total != 3 || s.Container != "wp_b" {
+		t.Errorf("snapshot() = %+v", s)
+	}
+	if s.Object != "backups/b/b.tgz" || s.ObjectBytes != 40 || s.UploadedBytes != 140 {
+		t.Errorf("snapshot() bytes = %s %d/%d, want backups/b/b.tgz 40/140", s.Object, s.ObjectBytes, s.UploadedBytes)
+	}
+	if s.Succeeded != 1 || len(s.Remaining) != 1 || s.Remaining[0] != "wp_c" {
+		t.Errorf("snapshot() = succeeded %d, remaining %v", s.Succeeded, s.Remaining)
+	}
+	if s.ElapsedSeconds < 60 {
+		t.Errorf("ElapsedSeconds = %f, want at least 60", s.ElapsedSeconds)
+	}
+
+	var buf bytes.Buffer
+	WriteRunStatus(&buf, s)
+	if !strings.Contains(buf.String(), "[2/3] wp_b") || !strings.Contains(buf.String(), "Remaining: 1 (wp_c)") {
+		t.Errorf("WriteRunStatus() = %q", buf.String())
+	}
+
+	tr.end()
+	if tr.snapshot().Active {
+		t.Error("snapshot() after end() is still active")
+	}
+}
+
+func TestServeRunStatusSocket(t *testing.T) {
+	bm := NewBackupManager(nil, nil)
+	bm.status.begin("run-2", time.Now(), []ContainerInfo{{Name: "wp_a"}})
+	// Unix socket paths are limited to ~100 bytes, shorter than some TempDirs.
+	dir, err := os.MkdirTemp("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "s.sock")
+
+	stop, err := bm.ServeRunStatus(sock)
+	if err != nil {
+		t.Fatalf("ServeRunStatus() error = %v", err)
+	}
+	if _, err := bm.ServeRunStatus(sock); err == nil {
+		t.Error("ServeRunStatus() took over a socket that is in use")
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got RunStatus
+	if err := json.NewDecoder(conn).Decode(&got); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	conn.Close()
+	if !got.Active || got.RunID != "run-2" || len(got.Remaining) != 1 {
+		t.Errorf("socket status = %+v", got)
+	}
+	stop()
+
+	if _, err := net.Dial("unix", sock); err == nil {
+		t.Error("status socket still answers after stop")
+	}
+}
@@ -0,0 +1,263 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SanitizeRules controls what `backup sanitize` strips from a backup. Rules are
+// loaded from YAML (see examples/sanitize-rules.yml):
+//
+//	defaults: true            # also apply the built-in license key rules (default true)
+//	remove_options:           # wp_options names; SQL lines mentioning them are dropped
+//	  - my_plugin_license
+//	replacements:             # regex replacements applied to every SQL line
+//	  - name: mask-emails
+//	    pattern: '[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}'
+//	    replace: 'redacted@example.invalid'
+//	drop_tables:              # tables whose schema and data are removed (globs)
+//	  - wp_users
+//	  - "*_usermeta"
+//	exclude_files:            # files left out of the sanitized tarball (globs)
+//	  - "wp-content/uploads/private/*"
+//	  - "*.log"
+type SanitizeRules struct {
+	Defaults      *bool                 `yaml:"defaults"`
+	RemoveOptions []string              `yaml:"remove_options"`
+	Replacements  []SanitizeReplacement `yaml:"replacements"`
+	DropTables    []string              `yaml:"drop_tables"`
+	ExcludeFiles  []string              `yaml:"exclude_files"`
+}
+
+// SanitizeReplacement rewrites every match of Pattern in SQL files. Replace
+// may reference capture groups ($1, ${name}).
+type SanitizeReplacement struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+
+	re *regexp.Regexp
+}
+
+// defaultSanitizeReplacements zeroes the Astra addon license status transient
+// so the theme does not report an active license.
+var defaultSanitizeReplacements = []SanitizeReplacement{
+	{
+		Name:    "astra-license-status",
+		Pattern: `'_transient_astra-addon_license_status',(?:'1'|"1")`,
+		Replace: `'_transient_astra-addon_license_status','0'`,
+	},
+}
+
+// DefaultSanitizeRules returns the built-in rules: remove
+// DefaultLicenseKeysToRemove and reset the Astra license transient.
+func DefaultSanitizeRules() *SanitizeRules {
+	rules := &SanitizeRules{}
+	rules.mergeDefaults()
+	if err := rules.compile(); err != nil {
+		panic(err) // built-in patterns are constant
+	}
+	return rules
+}
+
+func (r *SanitizeRules) mergeDefaults() {
+	r.RemoveOptions = append(append([]string{}, DefaultLicenseKeysToRemove...), r.RemoveOptions...)
+	r.Replacements = append(append([]SanitizeReplacement{}, defaultSanitizeReplacements...), r.Replacements...)
+}
+
+// LoadSanitizeRules reads and validates a rules file. Built-in defaults are
+// merged in unless the file sets `defaults: false`.
+func LoadSanitizeRules(filePath string) (*SanitizeRules, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sanitize rules: %w", err)
+	}
+	var rules SanitizeRules
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to parse sanitize rules %s: %w", filePath, err)
+	}
+	if rules.Defaults == nil || *rules.Defaults {
+		rules.mergeDefaults()
+	}
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("invalid sanitize rules %s: %w", filePath, err)
+	}
+	return &rules, nil
+}
+
+// compile validates patterns and compiles the replacement regexes.
+func (r *SanitizeRules) compile() error {
+	for i := range r.Replacements {
+		rep := &r.Replacements[i]
+		if rep.Pattern == "" {
+			return fmt.Errorf("replacement %d has no pattern", i+1)
+		}
+		re, err := regexp.Compile(rep.Pattern)
+		if err != nil {
+			return fmt.Errorf("replacement %q: %w", rep.label(), err)
+		}
+		rep.re = re
+	}
+	for _, p := range append(append([]string{}, r.DropTables...), r.ExcludeFiles...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (rep *SanitizeReplacement) label() string {
+	if rep.Name != "" {
+		return rep.Name
+	}
+	return rep.Pattern
+}
+
+// excludes reports which exclude_files pattern, if any, matches relPath. A
+// pattern matches the full relative path, any trailing part of it, or the
+// base name.
+func (r *SanitizeRules) excludes(relPath string) (string, bool) {
+	relPath = strings.TrimPrefix(relPath, "./")
+	parts := strings.Split(relPath, "/")
+	for _, pattern := range r.ExcludeFiles {
+		for i := range parts {
+			if ok, _ := path.Match(pattern, strings.Join(parts[i:], "/")); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sqlTableStatement captures the table a mysqldump statement line refers to.
+var sqlTableStatement = regexp.MustCompile("^(?:DROP TABLE IF EXISTS|CREATE TABLE|INSERT INTO|REPLACE INTO|LOCK TABLES|/\\*!40000 ALTER TABLE) `([^`]+)`")
+
+func (r *SanitizeRules) dropsTable(table string) (string, bool) {
+	for _, pattern := range r.DropTables {
+		if ok, _ := path.Match(pattern, table); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// sanitizeReport counts rule matches and keeps a few before/after samples per
+// rule for the dry-run diff.
+type sanitizeReport struct {
+	counts  map[string]int
+	samples map[string][][]string
+}
+
+const sanitizeReportSamples = 3
+
+func newSanitizeReport() *sanitizeReport {
+	return &sanitizeReport{counts: map[string]int{}, samples: map[string][][]string{}}
+}
+
+// add records n matches of rule; sample is a group of diff lines shown for
+// the first few matches.
+func (rep *sanitizeReport) add(rule string, n int, sample ...string) {
+	if rep == nil {
+		return
+	}
+	rep.counts[rule] += n
+	if len(sample) > 0 && len(rep.samples[rule]) < sanitizeReportSamples {
+		rep.samples[rule] = append(rep.samples[rule], sample)
+	}
+}
+
+// Print writes the per-rule match counts and sample diffs.
+func (rep *sanitizeReport) Print() {
+	if len(rep.counts) == 0 {
+		fmt.Println("   No rules matched")
+		return
+	}
+	rules := make([]string, 0, len(rep.counts))
+	for rule := range rep.counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Printf("   %s: %d match(es)\n", rule, rep.counts[rule])
+		for _, group := range rep.samples[rule] {
+			for _, line := range group {
+				fmt.Printf("      %s\n", truncateSample(line, 160))
+			}
+		}
+	}
+}
+
+func truncateSample(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
+
+// sanitizeSQL applies the rules to a SQL dump and reports whether anything
+// changed. Lines are processed independently, which matches mysqldump and
+// `wp db export` output (one statement per line, multi-line CREATE TABLE).
+func (r *SanitizeRules) sanitizeSQL(content string, report *sanitizeReport) (string, bool) {
+	lines := strings.Split(content, "\n")
+	out := lines[:0]
+	modified := false
+	droppingCreate := false
+
+	for _, line := range lines {
+		if droppingCreate {
+			if strings.HasSuffix(strings.TrimSpace(line), ";") {
+				droppingCreate = false
+			}
+			continue
+		}
+		if m := sqlTableStatement.FindStringSubmatch(line); m != nil {
+			if pattern, ok := r.dropsTable(m[1]); ok {
+				report.add("drop_tables: "+pattern, 1, "- "+line)
+				modified = true
+				if strings.HasPrefix(line, "CREATE TABLE") && !strings.HasSuffix(strings.TrimSpace(line), ";") {
+					droppingCreate = true
+				}
+				continue
+			}
+		}
+
+		removed := false
+		for _, option := range r.RemoveOptions {
+			if strings.Contains(line, option) {
+				report.add("remove_options: "+option, 1, "- "+line)
+				removed = true
+				break
+			}
+		}
+		if removed {
+			modified = true
+			continue
+		}
+
+		for i := range r.Replacements {
+			rep := &r.Replacements[i]
+			matches := rep.re.FindAllStringIndex(line, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			replaced := rep.re.ReplaceAllString(line, rep.Replace)
+			if replaced == line {
+				continue
+			}
+			first := line[matches[0][0]:matches[0][1]]
+			report.add("replacements: "+rep.label(), len(matches), "- "+first, "+ "+rep.re.ReplaceAllString(first, rep.Replace))
+			line = replaced
+			modified = true
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n"), modified
+}
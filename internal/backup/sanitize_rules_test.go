@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleDump = "-- MySQL dump\n" +
+	"DROP TABLE IF EXISTS `wp_users`;\n" +
+	"CREATE TABLE `wp_users` (\n" +
+	"  `ID` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_email` varchar(100) NOT NULL DEFAULT '',\n" +
+	"  PRIMARY KEY (`ID`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"LOCK TABLES `wp_users` WRITE;\n" +
+	"INSERT INTO `wp_users` VALUES (1,'admin@example.com');\n" +
+	"UNLOCK TABLES;\n" +
+	"INSERT INTO `wp_options` VALUES (1,'admin_email','owner@example.com','yes');\n" +
+	"INSERT INTO `wp_options` VALUES (2,'license_number','ABC123','yes');\n" +
+	"INSERT INTO `wp_options` VALUES (3,'_transient_astra-addon_license_status','1','yes');\n" +
+	"INSERT INTO `wp_options` VALUES (4,'blogname','Test Site','yes');\n"
+
+func writeRules(t *testing.T, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "rules.yml")
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSanitizeSQLRules(t *testing.T) {
+	rules, err := LoadSanitizeRules(writeRules(t, `
+replacements:
+  - name: mask-emails
+    pattern: '[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}'
+    replace: 'redacted@example.invalid'
+drop_tables:
+  - "*_users"
+`))
+	if err != nil {
+		t.Fatalf("LoadSanitizeRules() error = %v", err)
+	}
+
+	report := newSanitizeReport()
+	got, modified := rules.sanitizeSQL(sampleDump, report)
+	if !modified {
+		t.Fatal("sanitizeSQL() reported no changes")
+	}
+	for _, gone := range []string{"wp_users", "user_email", "ENGINE=InnoDB", "license_number", "owner@example.com"} {
+		if strings.Contains(got, gone) {
+			t.Errorf("sanitized dump still contains %q:\n%s", gone, got)
+		}
+	}
+	for _, kept := range []string{"UNLOCK TABLES;", "'admin_email','redacted@example.invalid'", "'_transient_astra-addon_license_status','0'", "blogname"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("sanitized dump is missing %q:\n%s", kept, got)
+		}
+	}
+
+	if n := report.counts["drop_tables: *_users"]; n != 4 {
+		t.Errorf("drop_tables count = %d, want 4", n)
+	}
+	if n := report.counts["replacements: mask-emails"]; n != 1 {
+		t.Errorf("mask-emails count = %d, want 1", n)
+	}
+	if n := report.counts["remove_options: license_number"]; n != 1 {
+		t.Errorf("license_number count = %d, want 1", n)
+	}
+}
+
+func TestLoadSanitizeRulesWithoutDefaults(t *testing.T) {
+	rules, err := LoadSanitizeRules(writeRules(t, "defaults: false\nremove_options: [my_key]\n"))
+	if err != nil {
+		t.Fatalf("LoadSanitizeRules() error = %v", err)
+	}
+	if len(rules.RemoveOptions) != 1 || len(rules.Replacements) != 0 {
+		t.Errorf("rules = %+v, want only my_key", rules)
+	}
+	got, _ := rules.sanitizeSQL(sampleDump, nil)
+	if !strings.Contains(got, "license_number") {
+		t.Error("built-in option removed although defaults: false")
+	}
+}
+
+func TestLoadSanitizeRulesErrors(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown field": "remove_option: [x]\n",
+		"bad regex":     "replacements:\n  - pattern: '('\n",
+		"empty pattern": "replacements:\n  - name: x\n",
+		"bad glob":      "drop_tables: ['[']\n",
+	} {
+		if _, err := LoadSanitizeRules(writeRules(t, body)); err == nil {
+			t.Errorf("%s: LoadSanitizeRules() succeeded, want error", name)
+		}
+	}
+}
+
+func TestSanitizeRulesExcludes(t *testing.T) {
+	rules := &SanitizeRules{ExcludeFiles: []string{"*.log", "wp-content/uploads/private"}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"site/www/debug.log", true},
+		{"site/www/wp-content/uploads/private", true},
+		{"wp-content/uploads/private", true},
+		{"site/www/wp-content/uploads/public/a.jpg", false},
+		{"site/www/logs", false},
+	}
+	for _, tt := range tests {
+		if _, got := rules.excludes(tt.path); got != tt.want {
+			t.Errorf("excludes(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExampleSanitizeRulesFile(t *testing.T) {
+	if _, err := LoadSanitizeRules(filepath.Join("..", "..", "examples", "sanitize-rules.yml")); err != nil {
+		t.Fatalf("examples/sanitize-rules.yml is invalid: %v", err)
+	}
+}
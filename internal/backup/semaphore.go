@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// internalObjectPrefix holds coordination objects (locks, tickets) that live
+// in the backup bucket but are never backups themselves.
+const internalObjectPrefix = ".locks/"
+
+// stateObjectPrefix holds state the CLI keeps in the bucket (listing caches,
+// maintenance flags); like coordination objects these are never backups.
+const stateObjectPrefix = ".ciwg/"
+
+// isInternalObject reports whether key is a coordination, state or run
+// artifact object that bucket-wide operations (migration, capacity relief)
+// must leave alone.
+func isInternalObject(key string) bool {
+	return strings.HasPrefix(key, internalObjectPrefix) || strings.HasPrefix(key, stateObjectPrefix) ||
+		strings.HasPrefix(key, runMetaPrefix)
+}
+
+// DefaultUploadSemaphorePrefix is where upload semaphore tickets are stored
+// inside the backup bucket.
+const DefaultUploadSemaphorePrefix = internalObjectPrefix + "upload-semaphore/"
+
+// UploadSemaphoreConfig configures the optional fleet-wide upload semaphore.
+// Every uploader writes a ticket object under Prefix; tickets are ordered by
+// creation time and only the first MaxConcurrent live tickets may upload, so
+// runs started across many servers queue up fairly instead of saturating Minio.
+type UploadSemaphoreConfig struct {
+	// MaxConcurrent is the maximum number of concurrent uploads fleet-wide.
+	// Zero or less disables the semaphore.
+	MaxConcurrent int
+	// Prefix is the object prefix holding tickets (default:
+	// .locks/upload-semaphore/). It must be under .locks/ so tickets are
+	// internal objects that listings, gc, migrations and the delete hold
+	// leave alone.
+	Prefix string
+	// TTL is how long a ticket survives without a heartbeat before other
+	// uploaders treat it as abandoned (default: 10m).
+	TTL time.Duration
+	// PollInterval is how often a queued uploader re-checks its position (default: 15s).
+	PollInterval time.Duration
+	// MaxWait bounds how long to wait for a slot; zero waits indefinitely.
+	MaxWait time.Duration
+	// Holder identifies this uploader in ticket names and logs (default: hostname).
+	Holder string
+}
+
+// Validate rejects a ticket prefix outside the internal .locks/ prefix.
+func (c *UploadSemaphoreConfig) Validate() error {
+	if c.Prefix != "" && !strings.HasPrefix(c.Prefix, internalObjectPrefix) {
+		return fmt.Errorf("upload semaphore prefix %q must be under %s", c.Prefix, internalObjectPrefix)
+	}
+	return nil
+}
+
+// uploadSlot is a held position in the upload semaphore.
+type uploadSlot struct {
+	bm   *BackupManager
+	key  string
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (c *UploadSemaphoreConfig) withDefaults() UploadSemaphoreConfig {
+	out := *c
+	if out.Prefix == "" {
+		out.Prefix = DefaultUploadSemaphorePrefix
+	}
+	if !strings.HasSuffix(out.Prefix, "/") {
+		out.Prefix += "/"
+	}
+	if out.TTL <= 0 {
+		out.TTL = 10 * time.Minute
+	}
+	if out.PollInterval <= 0 {
+		out.PollInterval = 15 * time.Second
+	}
+	if out.Holder == "" {
+		if h, err := os.Hostname(); err == nil {
+			out.Holder = h
+		} else {
+			out.Holder = "unknown"
+		}
+	}
+	return out
+}
+
+// newTicketKey returns a ticket object key that sorts by creation time.
+func newTicketKey(prefix, holder string, now time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	holder = strings.Map(func(r rune) rune {
+		if r == '/' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, holder)
+	return fmt.Sprintf("%s%020d-%s-%s", prefix, now.UnixNano(), holder, hex.EncodeToString(b))
+}
+
+// uploadQueuePosition returns the zero-based position of key among the live
+// tickets (ordered by key, i.e. creation time) and the keys of tickets whose
+// heartbeat is older than ttl. Position is -1 if key is not present.
+func uploadQueuePosition(tickets []ObjectInfo, key string, now time.Time, ttl time.Duration) (int, []string) {
+	live := make([]string, 0, len(tickets))
+	var stale []string
+	for _, t := range tickets {
+		if t.Key != key && now.Sub(t.LastModified) > ttl {
+			stale = append(stale, t.Key)
+			continue
+		}
+		live = append(live, t.Key)
+	}
+	sort.Strings(live)
+	for i, k := range live {
+		if k == key {
+			return i, stale
+		}
+	}
+	return -1, stale
+}
+
+// putTicket writes (or refreshes) a ticket object.
+func (bm *BackupManager) putTicket(key, holder string) error {
+	body := []byte(fmt.Sprintf("holder=%s\nheartbeat=%s\n", holder, time.Now().UTC().Format(time.RFC3339)))
+	_, err := bm.putObject(context.Background(), key, bytes.NewReader(body), int64(len(body)), "text/plain", nil)
+	return err
+}
+
+// acquireUploadSlot blocks until this uploader holds one of the MaxConcurrent
+// fleet-wide upload slots. It returns nil, nil when the semaphore is disabled.
+func (bm *BackupManager) acquireUploadSlot(cfg *UploadSemaphoreConfig) (*uploadSlot, error) {
+	if cfg == nil || cfg.MaxConcurrent <= 0 {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	c := cfg.withDefaults()
+
+	key := newTicketKey(c.Prefix, c.Holder, time.Now())
+	if err := bm.putTicket(key, c.Holder); err != nil {
+		return nil, fmt.Errorf("failed to create upload semaphore ticket: %w", err)
+	}
+	slot := &uploadSlot{bm: bm, key: key, stop: make(chan struct{}), done: make(chan struct{})}
+	go slot.heartbeat(c)
+
+	start := time.Now()
+	lastPos := -2
+	for {
+		tickets, err := bm.ListBackups(c.Prefix, 0)
+		if err != nil {
+			slot.Release()
+			return nil, fmt.Errorf("failed to list upload semaphore tickets: %w", err)
+		}
+		pos, stale := uploadQueuePosition(tickets, key, time.Now(), c.TTL)
+		if len(stale) > 0 {
+			bm.logVerbose("Removing %d abandoned upload semaphore ticket(s)", len(stale))
+			_ = bm.DeleteObjects(stale)
+		}
+		if pos < 0 {
+			// Our ticket vanished (e.g. deleted as stale by a peer); re-queue.
+			if err := bm.putTicket(key, c.Holder); err != nil {
+				slot.Release()
+				return nil, fmt.Errorf("failed to recreate upload semaphore ticket: %w", err)
+			}
+			continue
+		}
+		if pos < c.MaxConcurrent {
+			if lastPos != -2 {
+				fmt.Printf("   🚦 Upload slot acquired after %s\n", time.Since(start).Round(time.Second))
+			}
+			return slot, nil
+		}
+		if pos != lastPos {
+			fmt.Printf("   🚦 Waiting for fleet-wide upload slot (queue position %d, max %d concurrent)...\n", pos-c.MaxConcurrent+1, c.MaxConcurrent)
+			lastPos = pos
+		}
+		if c.MaxWait > 0 && time.Since(start) >= c.MaxWait {
+			slot.Release()
+			return nil, fmt.Errorf("timed out after %s waiting for a fleet-wide upload slot", c.MaxWait)
+		}
+		time.Sleep(c.PollInterval)
+	}
+}
+
+// heartbeat refreshes the ticket so peers don't treat it as abandoned.
+func (s *uploadSlot) heartbeat(c UploadSemaphoreConfig) {
+	defer close(s.done)
+	ticker := time.NewTicker(c.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.bm.putTicket(s.key, c.Holder); err != nil {
+				s.bm.logDebug("Failed to refresh upload semaphore ticket %s: %v", s.key, err)
+			}
+		}
+	}
+}
+
+// Release gives up the slot. It is safe to call on a nil slot.
+func (s *uploadSlot) Release() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	if err := s.bm.DeleteObject(s.key); err != nil {
+		fmt.Printf("Warning: failed to release upload semaphore ticket %s: %v\n", s.key, err)
+	}
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadQueuePosition(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ttl := 10 * time.Minute
+	prefix := DefaultUploadSemaphorePrefix
+
+	older := newTicketKey(prefix, "wp1", now.Add(-3*time.Minute))
+	abandoned := newTicketKey(prefix, "wp2", now.Add(-2*time.Hour))
+	mine := newTicketKey(prefix, "wp0", now.Add(-time.Minute))
+	newer := newTicketKey(prefix, "wp3", now)
+
+	tickets := []ObjectInfo{
+		{Key: newer, LastModified: now},
+		{Key: mine, LastModified: now.Add(-time.Minute)},
+		{Key: abandoned, LastModified: now.Add(-2 * time.Hour)},
+		{Key: older, LastModified: now.Add(-time.Minute)},
+	}
+
+	pos, stale := uploadQueuePosition(tickets, mine, now, ttl)
+	if pos != 1 {
+		t.Errorf("position = %d, want 1 (only the older live ticket ahead)", pos)
+	}
+	if len(stale) != 1 || stale[0] != abandoned {
+		t.Errorf("stale = %v, want [%s]", stale, abandoned)
+	}
+
+	if pos, _ := uploadQueuePosition(tickets, "missing", now, ttl); pos != -1 {
+		t.Errorf("position for missing ticket = %d, want -1", pos)
+	}
+}
+
+func TestNewTicketKeySortsByTime(t *testing.T) {
+	now := time.Now()
+	a := newTicketKey(DefaultUploadSemaphorePrefix, "host/with space", now)
+	b := newTicketKey(DefaultUploadSemaphorePrefix, "aaa", now.Add(time.Second))
+	if a >= b {
+		t.Errorf("expected %s < %s", a, b)
+	}
+	if strings.Contains(strings.TrimPrefix(a, DefaultUploadSemaphorePrefix), "/") {
+		t.Errorf("ticket key %s must not contain nested path segments", a)
+	}
+	if !isInternalObject(a) {
+		t.Errorf("ticket key %s should be treated as an internal object", a)
+	}
+}
+
+func TestUploadSemaphorePrefixMustBeInternal(t *testing.T) {
+	for _, prefix := range []string{"", DefaultUploadSemaphorePrefix, ".locks/fleet-a/"} {
+		c := &UploadSemaphoreConfig{Prefix: prefix}
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%q) error = %v", prefix, err)
+		}
+		if !isInternalObject(newTicketKey(c.withDefaults().Prefix, "h", time.Now())) {
+			t.Errorf("tickets under %q are not internal objects", prefix)
+		}
+	}
+	for _, prefix := range []string{"locks/", "backups/.locks/", ".lock"} {
+		if err := (&UploadSemaphoreConfig{Prefix: prefix}).Validate(); err == nil {
+			t.Errorf("Validate(%q) accepted a prefix outside .locks/", prefix)
+		}
+	}
+	bm, _ := newFileBackedManager(t)
+	if _, err := bm.acquireUploadSlot(&UploadSemaphoreConfig{MaxConcurrent: 1, Prefix: "tickets/"}); err == nil {
+		t.Error("acquireUploadSlot() accepted a prefix outside .locks/")
+	}
+}
@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Findings of a site audit.
+const (
+	// AuditNeverBackedUp is a site directory with no backups in the bucket.
+	AuditNeverBackedUp = "never-backed-up"
+	// AuditUnbackedContainer is a running site with no backups in the
+	// bucket.
+	AuditUnbackedContainer = "container-not-backed-up"
+	// AuditDirWithoutContainer is a site directory no running container
+	// uses: a stopped or removed site whose files were left behind.
+	AuditDirWithoutContainer = "directory-without-container"
+	// AuditContainerWithoutDir is a running site whose working directory is
+	// not under the parent directory, so scans of it would miss it.
+	AuditContainerWithoutDir = "container-outside-parent-dir"
+	// AuditOrphanPrefix is a site in the bucket with no directory or
+	// container on any audited host: a candidate for archiving or cleanup.
+	AuditOrphanPrefix = "orphan-prefix"
+)
+
+// HostSites is what a host holds: the site directories under its parent
+// directory and the sites of its running containers.
+type HostSites struct {
+	Host       string
+	ParentDir  string
+	Dirs       []string
+	Containers []ContainerInfo
+}
+
+// SiteAuditFinding is one problem found by AuditSites.
+type SiteAuditFinding struct {
+	Kind string `json:"kind"`
+	Host string `json:"host,omitempty"`
+	Site string `json:"site"`
+	// Key is the site's object key (see ResolveSiteKey).
+	Key       string `json:"key"`
+	Path      string `json:"path,omitempty"`
+	Container string `json:"container,omitempty"`
+	// Objects, Bytes and LastBackup describe the backups of orphan
+	// prefixes.
+	Objects    int       `json:"objects,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	LastBackup time.Time `json:"last_backup,omitempty"`
+}
+
+// SiteAudit is the result of AuditSites.
+type SiteAudit struct {
+	Hosts    []string           `json:"hosts"`
+	Prefix   string             `json:"prefix"`
+	Findings []SiteAuditFinding `json:"findings"`
+}
+
+// Count returns how many findings are of kind.
+func (a *SiteAudit) Count(kind string) int {
+	n := 0
+	for _, f := range a.Findings {
+		if f.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// ListSiteDirs returns the names of the directories directly under
+// parentDir on the manager's host, sorted.
+func (bm *BackupManager) ListSiteDirs(parentDir string) ([]string, error) {
+	cmd := fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type d -printf '%%f\\n'", shellQuote(parentDir))
+	stdout, stderr, err := bm.executeCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w (stderr: %s)", parentDir, err, strings.TrimSpace(stderr))
+	}
+	var dirs []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if name := strings.TrimSpace(line); name != "" && !strings.HasPrefix(name, ".") {
+			dirs = append(dirs, name)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// AuditSites cross-references the site directories and running containers
+// of hosts with the backups under prefix. Sites are matched to prefixes by
+// their object key; it reads keys without claiming them.
+func (bm *BackupManager) AuditSites(hosts []HostSites, prefix string) (*SiteAudit, error) {
+	objs, err := bm.ListBackups(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buildSiteAudit(hosts, prefix, objs, func(site string) string {
+		key, err := bm.cachedSiteKey(site, false)
+		if err != nil {
+			bm.logVerbose("Could not resolve the object key of %s, using %s: %v", site, key, err)
+		}
+		return key
+	}), nil
+}
+
+func buildSiteAudit(hosts []HostSites, prefix string, objs []ObjectInfo, siteKey func(string) string) *SiteAudit {
+	audit := &SiteAudit{Prefix: prefix, Findings: []SiteAuditFinding{}}
+	type prefixStats struct {
+		objects int
+		bytes   int64
+		last    time.Time
+	}
+	backedUp := map[string]*prefixStats{}
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		site := ObjectSite(o.Key)
+		s, ok := backedUp[site]
+		if !ok {
+			s = &prefixStats{}
+			backedUp[site] = s
+		}
+		s.objects++
+		s.bytes += o.Size
+		if t := BackupTime(o); t.After(s.last) {
+			s.last = t
+		}
+	}
+
+	// seen holds the keys of the sites found on any host.
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		audit.Hosts = append(audit.Hosts, h.Host)
+		running := map[string]bool{}
+		for _, c := range h.Containers {
+			site := containerSite(c)
+			key := siteKey(site)
+			seen[key] = true
+			running[site] = true
+			if _, ok := backedUp[key]; !ok {
+				audit.Findings = append(audit.Findings, SiteAuditFinding{Kind: AuditUnbackedContainer, Host: h.Host, Site: site, Key: key, Path: c.WorkingDir, Container: c.Name})
+			}
+			if h.ParentDir != "" && path.Dir(c.WorkingDir) != path.Clean(h.ParentDir) {
+				audit.Findings = append(audit.Findings, SiteAuditFinding{Kind: AuditContainerWithoutDir, Host: h.Host, Site: site, Key: key, Path: c.WorkingDir, Container: c.Name})
+			}
+		}
+		for _, site := range h.Dirs {
+			key := siteKey(site)
+			seen[key] = true
+			dir := path.Join(h.ParentDir, site)
+			// A running site without backups is already reported as such.
+			if _, ok := backedUp[key]; !ok && !running[site] {
+				audit.Findings = append(audit.Findings, SiteAuditFinding{Kind: AuditNeverBackedUp, Host: h.Host, Site: site, Key: key, Path: dir})
+			}
+			if !running[site] {
+				audit.Findings = append(audit.Findings, SiteAuditFinding{Kind: AuditDirWithoutContainer, Host: h.Host, Site: site, Key: key, Path: dir})
+			}
+		}
+	}
+
+	var orphans []string
+	for key := range backedUp {
+		if !seen[key] {
+			orphans = append(orphans, key)
+		}
+	}
+	sort.Strings(orphans)
+	for _, key := range orphans {
+		s := backedUp[key]
+		audit.Findings = append(audit.Findings, SiteAuditFinding{Kind: AuditOrphanPrefix, Site: key, Key: key,
+			Path: strings.TrimSuffix(prefix, "/") + "/" + key + "/", Objects: s.objects, Bytes: s.bytes, LastBackup: s.last})
+	}
+	return audit
+}
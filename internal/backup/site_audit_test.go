@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildSiteAudit(t *testing.T) {
+	hosts := []HostSites{
+		{Host: "wp1", ParentDir: "/var/opt/sites/", Dirs: []string{"a.com", "b.com", "c.com"}, Containers: []ContainerInfo{
+			{Name: "a_wp", WorkingDir: "/var/opt/sites/a.com"},
+			{Name: "d_wp", WorkingDir: "/var/opt/sites/d.com"},
+			{Name: "e_wp", WorkingDir: "/srv/old/e.com"},
+		}},
+		{Host: "wp2", ParentDir: "/var/opt/sites", Dirs: []string{"f.com"}, Containers: []ContainerInfo{
+			{Name: "f_wp", WorkingDir: "/var/opt/sites/f.com"},
+		}},
+	}
+	objs := []ObjectInfo{
+		{Key: "backups/a.com/a.com-20260501-020000.tgz", Size: 10},
+		{Key: "backups/b.com/b.com-20260501-020000.tgz", Size: 10},
+		{Key: "backups/e.com/e.com-20260501-020000.tgz", Size: 10},
+		{Key: "backups/gone.com/gone.com-20260401-020000.tgz", Size: 1 << 20},
+		{Key: "backups/gone.com/gone.com-20260402-020000.tgz", Size: 1 << 20},
+		{Key: ".locks/a.com.lock"},
+		{Key: "_meta/a.com/run/summary.json"},
+	}
+	audit := buildSiteAudit(hosts, "backups/", objs, SiteKey)
+
+	type finding struct{ kind, host, site string }
+	got := map[finding]SiteAuditFinding{}
+	for _, f := range audit.Findings {
+		got[finding{f.Kind, f.Host, f.Site}] = f
+	}
+	want := []finding{
+		{AuditDirWithoutContainer, "wp1", "b.com"},
+		{AuditNeverBackedUp, "wp1", "c.com"},
+		{AuditDirWithoutContainer, "wp1", "c.com"},
+		{AuditUnbackedContainer, "wp1", "d.com"},
+		{AuditContainerWithoutDir, "wp1", "e.com"},
+		{AuditUnbackedContainer, "wp2", "f.com"},
+		{AuditOrphanPrefix, "", "gone.com"},
+	}
+	for _, w := range want {
+		if _, ok := got[w]; !ok {
+			t.Errorf("missing finding %+v", w)
+		}
+	}
+	if len(audit.Findings) != len(want) {
+		t.Errorf("findings = %+v", audit.Findings)
+	}
+
+	if f := got[finding{AuditNeverBackedUp, "wp1", "c.com"}]; f.Path != "/var/opt/sites/c.com" {
+		t.Errorf("never backed up path = %q", f.Path)
+	}
+	orphan := got[finding{AuditOrphanPrefix, "", "gone.com"}]
+	if orphan.Objects != 2 || orphan.Bytes != 2<<20 || orphan.Path != "backups/gone.com/" ||
+		!orphan.LastBackup.Equal(time.Date(2026, 4, 2, 2, 0, 0, 0, Timezone())) {
+		t.Errorf("orphan = %+v", orphan)
+	}
+	if audit.Count(AuditContainerWithoutDir) != 1 || len(audit.Hosts) != 2 {
+		t.Errorf("audit = %+v", audit)
+	}
+}
@@ -0,0 +1,401 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSiteKeyLength is the longest site name used in object keys as is.
+// Every backup key holds the site name twice (directory and file name), so
+// this keeps keys well inside filesystem and URL limits.
+const MaxSiteKeyLength = 80
+
+// SiteNameMetaKey is the user metadata holding the original, URL-escaped
+// site name of backups whose keys use a normalized one.
+const SiteNameMetaKey = "Ciwg-Site-Name"
+
+// siteKeyPrefix holds one claim object per normalized site key, naming the
+// site that owns it.
+const siteKeyPrefix = stateObjectPrefix + "site-keys/"
+
+// SiteKey returns the name site is stored under in object keys: letters
+// with their accents removed, digits, '.', '_' and '-', with every other run
+// of characters replaced by a single '-'. Names longer than
+// MaxSiteKeyLength are cut and get a hash of the full name appended. Names
+// that already follow these rules are returned unchanged.
+func SiteKey(site string) string {
+	stripped, _, err := transform.String(transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn))), site)
+	if err != nil {
+		stripped = site
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range stripped {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-') {
+			b.WriteRune(r)
+			dash = r == '-'
+			continue
+		}
+		if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	key := strings.Trim(b.String(), "-._")
+	if key == "" {
+		return "site-" + siteKeyHash(site)
+	}
+	if len(key) > MaxSiteKeyLength {
+		return hashedSiteKey(key, site)
+	}
+	return key
+}
+
+// hashedSiteKey returns key, cut to fit, with a hash of site appended: the
+// key used when the plain one is too long or taken by another site.
+func hashedSiteKey(key, site string) string {
+	suffix := "-" + siteKeyHash(site)
+	if len(key) > MaxSiteKeyLength-len(suffix) {
+		key = strings.TrimRight(key[:MaxSiteKeyLength-len(suffix)], "-._")
+	}
+	return key + suffix
+}
+
+func siteKeyHash(site string) string {
+	sum := sha256.Sum256([]byte(site))
+	return hex.EncodeToString(sum[:4])
+}
+
+// SiteKeyClaim records which site a normalized site key belongs to.
+type SiteKeyClaim struct {
+	Key     string    `json:"key"`
+	Site    string    `json:"site"`
+	Claimed time.Time `json:"claimed"`
+}
+
+func siteKeyClaimKey(key string) string {
+	return siteKeyPrefix + key + ".json"
+}
+
+// siteKeyCache remembers the keys resolved by a manager, so one run asks the
+// bucket once per site and two sites of a run never share a key.
+type siteKeyCache struct {
+	mu    sync.Mutex
+	keys  map[string]string // site -> key
+	owner map[string]string // key -> site
+}
+
+// ResolveSiteKey returns the key site's backups are stored under. A site
+// whose normalized key is claimed by another site, or is the name of a site
+// already in the bucket, gets a key with a hash of its name appended; a
+// normalized key that is free is claimed for the site so later runs, and
+// other hosts, resolve to the same key.
+func (bm *BackupManager) ResolveSiteKey(site string) (string, error) {
+	return bm.cachedSiteKey(site, true)
+}
+
+// cachedSiteKey resolves the key of site once per manager, claiming it
+// when claim is set.
+func (bm *BackupManager) cachedSiteKey(site string, claim bool) (string, error) {
+	bm.siteKeys.mu.Lock()
+	defer bm.siteKeys.mu.Unlock()
+	if key, ok := bm.siteKeys.keys[site]; ok {
+		return key, nil
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return SiteKey(site), err
+	}
+	key, err := bm.resolveSiteKey(context.Background(), site, claim)
+	if err != nil {
+		return SiteKey(site), err
+	}
+	if bm.siteKeys.keys == nil {
+		bm.siteKeys.keys, bm.siteKeys.owner = map[string]string{}, map[string]string{}
+	}
+	bm.siteKeys.keys[site], bm.siteKeys.owner[key] = key, site
+	return key, nil
+}
+
+// siteKeyOf returns the key of site resolved earlier in the run, resolving
+// it now when it was not.
+func (bm *BackupManager) siteKeyOf(site string) string {
+	key, err := bm.cachedSiteKey(site, true)
+	if err != nil {
+		bm.logVerbose("Could not resolve the object key of %s, using %s: %v", site, key, err)
+	}
+	return key
+}
+
+func (bm *BackupManager) resolveSiteKey(ctx context.Context, site string, claim bool) (string, error) {
+	key := SiteKey(site)
+	for _, candidate := range []string{key, hashedSiteKey(key, site)} {
+		if owner, ok := bm.siteKeys.owner[candidate]; ok && owner != site {
+			continue
+		}
+		existing, err := bm.loadSiteKeyClaim(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing != nil {
+			if existing.Site == site {
+				return candidate, nil
+			}
+			continue
+		}
+		if candidate == site {
+			// Names that need no normalizing own their key.
+			return candidate, nil
+		}
+		objs, err := bm.listObjects(ctx, "backups/"+candidate+"/", 1)
+		if err != nil {
+			return "", fmt.Errorf("failed to check site key %s: %w", candidate, err)
+		}
+		if len(objs) > 0 {
+			continue
+		}
+		if claim {
+			if err := bm.claimSiteKey(ctx, candidate, site); err != nil {
+				return "", err
+			}
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("site keys %s and %s are both taken by other sites", key, hashedSiteKey(key, site))
+}
+
+// loadSiteKeyClaim returns the claim of key, or nil when it is unclaimed.
+func (bm *BackupManager) loadSiteKeyClaim(ctx context.Context, key string) (*SiteKeyClaim, error) {
+	if _, err := bm.statObject(ctx, siteKeyClaimKey(key)); err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read site key claim %s: %w", key, err)
+	}
+	r, err := bm.getObject(ctx, siteKeyClaimKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site key claim %s: %w", key, err)
+	}
+	defer r.Close()
+	var claim SiteKeyClaim
+	if err := json.NewDecoder(r).Decode(&claim); err != nil {
+		return nil, fmt.Errorf("failed to read site key claim %s: %w", key, err)
+	}
+	return &claim, nil
+}
+
+func (bm *BackupManager) claimSiteKey(ctx context.Context, key, site string) error {
+	data, err := json.MarshalIndent(SiteKeyClaim{Key: key, Site: site, Claimed: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := bm.putObject(ctx, siteKeyClaimKey(key), bytes.NewReader(data), int64(len(data)), "application/json", nil); err != nil {
+		return fmt.Errorf("failed to claim site key %s for %s: %w", key, site, err)
+	}
+	return nil
+}
+
+// ListSiteKeys returns every claimed site key, sorted by key.
+func (bm *BackupManager) ListSiteKeys() ([]SiteKeyClaim, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	objs, err := bm.listObjects(ctx, siteKeyPrefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list site keys: %w", err)
+	}
+	var claims []SiteKeyClaim
+	for _, o := range objs {
+		if !strings.HasSuffix(o.Key, ".json") {
+			continue
+		}
+		claim, err := bm.loadSiteKeyClaim(ctx, strings.TrimSuffix(strings.TrimPrefix(o.Key, siteKeyPrefix), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if claim != nil {
+			claims = append(claims, *claim)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Key < claims[j].Key })
+	return claims, nil
+}
+
+// siteNameMetadata adds the original name of site to meta when its backups
+// are stored under a different key.
+func siteNameMetadata(meta map[string]string, site, key string) map[string]string {
+	if site == key {
+		return meta
+	}
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	meta[SiteNameMetaKey] = url.PathEscape(site)
+	return meta
+}
+
+// KeyRename is one object moved by NormalizeSiteKeys.
+type KeyRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SiteKeyMigration is the outcome of NormalizeSiteKeys for one site.
+type SiteKeyMigration struct {
+	Site    string      `json:"site"`
+	Key     string      `json:"key"`
+	Renames []KeyRename `json:"renames"`
+	// Error is set when the site was not (fully) moved; objects already
+	// moved stay moved and a rerun continues with the rest.
+	Error string `json:"error,omitempty"`
+}
+
+// NormalizeSiteKeys moves the backups of every site under prefix whose
+// directory name does not follow the SiteKey rules to the site's resolved
+// key, renaming the site name in the file names too. Each object is copied,
+// checked by size and only then removed from its old key; a target that
+// already exists is never overwritten. With dryRun only the plan is
+// returned and nothing is claimed or moved.
+func (bm *BackupManager) NormalizeSiteKeys(prefix string, dryRun bool) ([]SiteKeyMigration, error) {
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	objs, err := bm.listObjects(ctx, prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	bySite := map[string][]ObjectInfo{}
+	for _, o := range objs {
+		if isInternalObject(o.Key) {
+			continue
+		}
+		if site := ObjectSite(o.Key); site != "" && SiteKey(site) != site {
+			bySite[site] = append(bySite[site], o)
+		}
+	}
+	sites := make([]string, 0, len(bySite))
+	for site := range bySite {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+
+	var out []SiteKeyMigration
+	for _, site := range sites {
+		m := SiteKeyMigration{Site: site}
+		// A dry run claims nothing, but still keeps two sites of the plan
+		// from sharing a key.
+		m.Key, err = bm.cachedSiteKey(site, !dryRun)
+		if err != nil {
+			m.Error = err.Error()
+			out = append(out, m)
+			continue
+		}
+		for _, o := range bySite[site] {
+			r := KeyRename{From: o.Key, To: renameSiteKey(o.Key, site, m.Key)}
+			if !dryRun {
+				if err := bm.moveObject(ctx, r.From, r.To, site, m.Key); err != nil {
+					m.Error = err.Error()
+					break
+				}
+			}
+			m.Renames = append(m.Renames, r)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// renameSiteKey returns objectKey with the site directory renamed from site
+// to key, and the site name at the start of the file name too.
+func renameSiteKey(objectKey, site, key string) string {
+	dir, base := path.Split(objectKey)
+	dir = path.Join(path.Dir(strings.TrimSuffix(dir, "/")), key)
+	if strings.HasPrefix(base, site) {
+		base = key + strings.TrimPrefix(base, site)
+	}
+	return path.Join(dir, base)
+}
+
+// moveObject copies from to to, adding the original site name to its
+// metadata, and removes from once the copy has the same size.
+func (bm *BackupManager) moveObject(ctx context.Context, from, to, site, key string) error {
+	src, err := bm.statObject(ctx, from)
+	if err != nil {
+		return err
+	}
+	if _, err := bm.statObject(ctx, to); err == nil {
+		return fmt.Errorf("%s already exists; not overwriting it with %s", to, from)
+	} else if !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+
+	if bm.fileStore != nil {
+		r, err := bm.getObject(ctx, from)
+		if err != nil {
+			return err
+		}
+		_, err = bm.putObject(ctx, to, r, src.Size, "application/octet-stream", nil)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", from, to, err)
+		}
+	} else {
+		attrs, err := bm.objectAttrs(ctx, from)
+		if err != nil {
+			return err
+		}
+		meta := map[string]string{"Content-Type": attrs.ContentType}
+		for k, v := range attrs.UserMeta {
+			meta[k] = v
+		}
+		if err := bm.guardWrite("copy to", to); err != nil {
+			return err
+		}
+		bm.listingDirty.Store(true)
+		bm.touchSummary(to)
+		_, err = bm.minioClient.ComposeObject(ctx,
+			minio.CopyDestOptions{
+				Bucket:          bm.minioConfig.Bucket,
+				Object:          to,
+				ReplaceMetadata: true,
+				UserMetadata:    siteNameMetadata(meta, site, key),
+				ReplaceTags:     true,
+				UserTags:        attrs.Tags,
+			},
+			minio.CopySrcOptions{Bucket: bm.minioConfig.Bucket, Object: from},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", from, to, err)
+		}
+	}
+
+	dst, err := bm.statObject(ctx, to)
+	if err != nil {
+		return err
+	}
+	if dst.Size != src.Size {
+		return fmt.Errorf("copy of %s is %d bytes, want %d; leaving the original in place", from, dst.Size, src.Size)
+	}
+	if err := bm.removeObject(ctx, from); err != nil {
+		return fmt.Errorf("copied %s to %s but failed to remove the original: %w", from, to, err)
+	}
+	return nil
+}
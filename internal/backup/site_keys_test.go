@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSiteKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"foo.com":          "foo.com",
+		"Staging_Site-2":   "Staging_Site-2",
+		"Café Nuñez":       "Cafe-Nunez",
+		"  my site (old) ": "my-site-old",
+		"日本.jp":            "jp",
+		"--":               "site-" + siteKeyHash("--"),
+	} {
+		if got := SiteKey(in); got != want {
+			t.Errorf("SiteKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+	long := strings.Repeat("a", MaxSiteKeyLength+20)
+	got := SiteKey(long)
+	if len(got) != MaxSiteKeyLength || !strings.HasSuffix(got, "-"+siteKeyHash(long)) {
+		t.Errorf("SiteKey(long) = %q (%d bytes)", got, len(got))
+	}
+	if SiteKey(long+"b") == got {
+		t.Error("two long names that share a prefix got the same key")
+	}
+}
+
+func TestResolveSiteKey(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/my-site/my-site-20260501-020000.tgz", "conforming")
+
+	// The slug of "my site" belongs to the conforming site already stored.
+	key, err := bm.ResolveSiteKey("my site")
+	if err != nil || key != "my-site-"+siteKeyHash("my site") {
+		t.Fatalf("ResolveSiteKey(my site) = %q, %v", key, err)
+	}
+	if key, err := bm.ResolveSiteKey("café.com"); err != nil || key != "cafe.com" {
+		t.Fatalf("ResolveSiteKey(café.com) = %q, %v", key, err)
+	}
+	if key, err := bm.ResolveSiteKey("my-site"); err != nil || key != "my-site" {
+		t.Errorf("ResolveSiteKey(my-site) = %q, %v", key, err)
+	}
+
+	// A fresh manager, like another host, reads the claims back.
+	other := &BackupManager{minioConfig: bm.minioConfig, fileStore: bm.fileStore}
+	if key, _ := other.ResolveSiteKey("café.com"); key != "cafe.com" {
+		t.Errorf("second host resolved café.com to %q", key)
+	}
+	if key, _ := other.ResolveSiteKey("cafe.com"); key != "cafe.com-"+siteKeyHash("cafe.com") {
+		t.Errorf("cafe.com resolved to %q, a key claimed by café.com", key)
+	}
+	claims, err := other.ListSiteKeys()
+	if err != nil || len(claims) != 3 {
+		t.Errorf("ListSiteKeys() = %+v, %v", claims, err)
+	}
+}
+
+func TestNormalizeSiteKeys(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/Café Nuñez/Café Nuñez-20260501-020000.tgz", "one")
+	putTestObject(t, bm, "backups/Café Nuñez/Café Nuñez-20260502-020000.tgz", "two")
+	putTestObject(t, bm, "backups/foo.com/foo.com-20260501-020000.tgz", "foo")
+
+	plan, err := bm.NormalizeSiteKeys("backups/", true)
+	if err != nil || len(plan) != 1 || plan[0].Key != "Cafe-Nunez" || len(plan[0].Renames) != 2 {
+		t.Fatalf("NormalizeSiteKeys(dry run) = %+v, %v", plan, err)
+	}
+	if r := plan[0].Renames[0]; r.To != "backups/Cafe-Nunez/Cafe-Nunez-20260501-020000.tgz" {
+		t.Errorf("rename target = %s", r.To)
+	}
+	if _, err := bm.statObject(context.Background(), "backups/Café Nuñez/Café Nuñez-20260501-020000.tgz"); err != nil {
+		t.Fatalf("dry run moved the object: %v", err)
+	}
+
+	// A target that already exists is left alone along with its source.
+	putTestObject(t, bm, "backups/Cafe-Nunez/Cafe-Nunez-20260502-020000.tgz", "stray")
+	bm = &BackupManager{minioConfig: bm.minioConfig, fileStore: bm.fileStore}
+	done, err := bm.NormalizeSiteKeys("backups/", false)
+	if err != nil || len(done) != 1 {
+		t.Fatalf("NormalizeSiteKeys() = %+v, %v", done, err)
+	}
+	if done[0].Key != "Cafe-Nunez-"+siteKeyHash("Café Nuñez") {
+		t.Errorf("key = %s, want the hashed key since Cafe-Nunez holds objects", done[0].Key)
+	}
+	if done[0].Error != "" || len(done[0].Renames) != 2 {
+		t.Errorf("migration = %+v", done[0])
+	}
+	objs, _ := bm.listObjects(context.Background(), "backups/", 0)
+	for _, o := range objs {
+		if strings.Contains(o.Key, "é") {
+			t.Errorf("%s was not moved", o.Key)
+		}
+	}
+	if again, err := bm.NormalizeSiteKeys("backups/", false); err != nil || len(again) != 0 {
+		t.Errorf("second NormalizeSiteKeys() = %+v, %v", again, err)
+	}
+}
@@ -0,0 +1,525 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spool is a local directory where `backup create --spool` leaves
+// finished archives instead of streaming them to the bucket, so tar and the
+// SSH session end as soon as the archive is written, however slow the
+// uplink. `backup upload-spool` drains it. Each archive <id>.tgz has a
+// sidecar <id>.json (a SpoolEntry); archives being written are named
+// <id>.tgz.partial and are never drained.
+type Spool struct {
+	Dir string
+	// MaxSize bounds the bytes of archives in Dir, finished and being
+	// written; 0 means unbounded.
+	MaxSize int64
+
+	mu sync.Mutex
+	// inflight are the bytes reserved by archives being written by this
+	// process, and writing their partial files, which Usage leaves out.
+	inflight int64
+	writing  map[string]bool
+}
+
+// DefaultSpoolDir returns the default spool directory (~/.ciwg/spool).
+func DefaultSpoolDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return filepath.Join(os.TempDir(), "ciwg-spool")
+	}
+	return filepath.Join(home, ".ciwg", "spool")
+}
+
+// SpoolEntry describes a spooled archive and how far its upload got.
+type SpoolEntry struct {
+	ObjectKey string            `json:"object_key"`
+	Site      string            `json:"site,omitempty"`
+	Container string            `json:"container,omitempty"`
+	Host      string            `json:"host,omitempty"`
+	Size      int64             `json:"size"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	// Glacier is set when the backup also goes to Glacier; MinioDone
+	// records a Minio copy already made, so a retry only archives.
+	Glacier   bool `json:"glacier,omitempty"`
+	MinioDone bool `json:"minio_done,omitempty"`
+
+	Attempts    int       `json:"attempts,omitempty"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	id string
+}
+
+// spoolID names the files of the archive for objectName in the spool.
+func spoolID(objectName string) string {
+	sum := sha256.Sum256([]byte(objectName))
+	return hex.EncodeToString(sum[:8]) + "-" + strings.TrimSuffix(filepath.Base(objectName), ".tgz")
+}
+
+func (s *Spool) archivePath(id string) string { return filepath.Join(s.Dir, id+".tgz") }
+func (s *Spool) entryPath(id string) string   { return filepath.Join(s.Dir, id+".json") }
+
+// Entries returns the spooled archives, oldest first. Sidecars that are
+// malformed or whose archive is missing are skipped.
+func (s *Spool) Entries() ([]*SpoolEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spool: %w", err)
+	}
+	var out []*SpoolEntry
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var e SpoolEntry
+		if err := json.Unmarshal(data, &e); err != nil || e.ObjectKey == "" {
+			continue
+		}
+		e.id = strings.TrimSuffix(filepath.Base(p), ".json")
+		if _, err := os.Stat(s.archivePath(e.id)); err != nil {
+			continue
+		}
+		out = append(out, &e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Usage returns the bytes of archives in the spool, partial ones included.
+func (s *Spool) Usage() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage()
+}
+
+// usage is Usage without the partial files of this process, which are
+// counted by their reservations; s.mu is held.
+func (s *Spool) usage() (int64, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read spool: %w", err)
+	}
+	var total int64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".tgz") && !strings.HasSuffix(name, ".tgz.partial") || s.writing[name] {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// save writes the sidecar of e through a temp file.
+func (s *Spool) save(e *SpoolEntry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.entryPath(e.id)); err != nil {
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the archive and sidecar of e.
+func (s *Spool) remove(e *SpoolEntry) error {
+	if err := os.Remove(s.archivePath(e.id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spooled archive: %w", err)
+	}
+	if err := os.Remove(s.entryPath(e.id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool entry: %w", err)
+	}
+	return nil
+}
+
+// errSpoolFull fails an archive that outgrew the room left in the spool.
+var errSpoolFull = errors.New("spool is full")
+
+// spoolReservation is the room an archive being written holds in the
+// spool: its estimate, grown as the archive outgrows it.
+type spoolReservation struct {
+	spool    *Spool
+	reserved int64
+	written  int64
+	// partial is the name of the file being written.
+	partial string
+}
+
+// reserve holds estimate bytes for a new archive, or returns nil when the
+// spool does not have them.
+func (s *Spool) reserve(estimate int64) (*spoolReservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MaxSize > 0 {
+		used, err := s.usage()
+		if err != nil {
+			return nil, err
+		}
+		if used+s.inflight+estimate > s.MaxSize {
+			return nil, nil
+		}
+	}
+	s.inflight += estimate
+	return &spoolReservation{spool: s, reserved: estimate}, nil
+}
+
+// grow accounts n more written bytes, failing when they do not fit.
+func (r *spoolReservation) grow(n int64) error {
+	r.written += n
+	if r.written <= r.reserved {
+		return nil
+	}
+	s := r.spool
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	need := r.written - r.reserved
+	if s.MaxSize > 0 {
+		used, err := s.usage()
+		if err != nil {
+			return err
+		}
+		if used+s.inflight+need > s.MaxSize {
+			return fmt.Errorf("%w: %s is over its %.1f MB limit", errSpoolFull, s.Dir, float64(s.MaxSize)/(1024*1024))
+		}
+	}
+	s.inflight += need
+	r.reserved = r.written
+	return nil
+}
+
+func (r *spoolReservation) release() {
+	r.spool.mu.Lock()
+	defer r.spool.mu.Unlock()
+	r.spool.inflight -= r.reserved
+	r.reserved = 0
+	delete(r.spool.writing, r.partial)
+}
+
+// writes registers partial as the file r is written to.
+func (r *spoolReservation) writes(partial string) {
+	r.spool.mu.Lock()
+	defer r.spool.mu.Unlock()
+	if r.spool.writing == nil {
+		r.spool.writing = map[string]bool{}
+	}
+	r.partial = filepath.Base(partial)
+	r.spool.writing[r.partial] = true
+}
+
+// spoolWriter writes an archive into the spool within its reservation.
+type spoolWriter struct {
+	w io.Writer
+	r *spoolReservation
+}
+
+func (w *spoolWriter) Write(p []byte) (int, error) {
+	if err := w.r.grow(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// SetSpool makes backups go to the spool s instead of the bucket while it
+// has room for them; nil streams them directly again.
+func (bm *BackupManager) SetSpool(s *Spool) {
+	bm.spool = s
+}
+
+// reserveSpool returns the spool reservation for an archive of about
+// estimate bytes, or nil when backups are not spooled or the spool is full,
+// in which case the archive is streamed directly.
+func (bm *BackupManager) reserveSpool(estimate int64) *spoolReservation {
+	if bm.spool == nil || bm.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(bm.spool.Dir, 0o700); err != nil {
+		fmt.Printf("   ⚠️  Warning: cannot use spool, uploading directly: %v\n", err)
+		return nil
+	}
+	r, err := bm.spool.reserve(estimate)
+	if err != nil {
+		fmt.Printf("   ⚠️  Warning: cannot use spool, uploading directly: %v\n", err)
+		return nil
+	}
+	if r == nil {
+		fmt.Printf("   ⚠️  Spool %s has no room for %.1f MB, uploading directly\n", bm.spool.Dir, float64(estimate)/(1024*1024))
+	}
+	return r
+}
+
+// spoolArchive runs tarCmd into the spool as objectName. The host's tar and
+// SSH session end when the archive is written; `backup upload-spool`
+// uploads it later.
+func (bm *BackupManager) spoolArchive(ctx context.Context, r *spoolReservation, tarCmd string, tarInput io.Reader, tarStderr *tarStderr, workingDir, objectName string, glacier bool, stats *UploadStats, metadata map[string]string) (int64, error) {
+	s := bm.spool
+	e := &SpoolEntry{
+		ObjectKey: objectName,
+		Host:      bm.remoteHost(),
+		Metadata:  metadata,
+		CreatedAt: time.Now().UTC(),
+		Glacier:   glacier,
+		id:        spoolID(objectName),
+	}
+	if stats != nil {
+		e.Site, e.Container = stats.Site, stats.Container
+	}
+	partial := s.archivePath(e.id) + ".partial"
+	r.writes(partial)
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create spooled archive: %w", err)
+	}
+	defer os.Remove(partial)
+	defer f.Close()
+
+	started := time.Now()
+	stdout, wait, kill, err := bm.startTar(ctx, tarCmd, tarInput, tarStderr)
+	if err != nil {
+		return 0, err
+	}
+	n, copyErr := io.Copy(&spoolWriter{f, r}, stageReader{ctx, stdout})
+	if copyErr != nil {
+		kill()
+	}
+	tarErr := wait()
+	if copyErr != nil {
+		return 0, fmt.Errorf("failed to write spooled archive: %w", copyErr)
+	}
+	if tarErr != nil {
+		// Treat tar exit code 1 for "file changed as we read it" as a non-fatal warning
+		if exitStatus(tarErr) == 1 && tarStderr.fileChanged() {
+			fmt.Printf("⚠️  Warning: tar finished with non-fatal warnings (%s)\n", FormatTarWarnings(tarStderr.Counts()))
+		} else {
+			return 0, &TarError{Op: "create", Path: workingDir, Stderr: tarStderr.String(), Err: tarErr}
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to write spooled archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write spooled archive: %w", err)
+	}
+	if err := os.Rename(partial, s.archivePath(e.id)); err != nil {
+		return 0, fmt.Errorf("failed to finish spooled archive: %w", err)
+	}
+	e.Size = n
+	if err := s.save(e); err != nil {
+		os.Remove(s.archivePath(e.id))
+		return 0, err
+	}
+
+	if stats != nil {
+		stats.ObjectKey = objectName
+		stats.Bytes = n
+		stats.Spooled = true
+	}
+	elapsed := time.Since(started)
+	fmt.Printf("📥 Spooled %s (%.2f MB in %s) for `backup upload-spool`\n", objectName, float64(n)/(1024*1024), elapsed.Round(time.Second))
+	return n, nil
+}
+
+// startTar starts tarCmd on the host with its archive on the returned
+// reader. wait waits for tar to exit; kill stops it, as does ctx ending.
+func (bm *BackupManager) startTar(ctx context.Context, tarCmd string, stdin io.Reader, stderr io.Writer) (io.Reader, func() error, func(), error) {
+	if bm.sshClient == nil {
+		cmd := bm.shellCommand(tarCmd)
+		cmd.Stdin = stdin
+		cmd.Stderr = stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create stdout pipe for local tar: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to start local tar command: %w", err)
+		}
+		kill := func() { _ = cmd.Process.Kill() }
+		stop := context.AfterFunc(ctx, kill)
+		return stdout, func() error { defer stop(); return cmd.Wait() }, kill, nil
+	}
+
+	session, err := bm.sshClient.GetSession()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	session.Stdin = stdin
+	session.Stderr = stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, nil, nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := session.Start(fmt.Sprintf("bash -lc %q", tarCmd)); err != nil {
+		session.Close()
+		return nil, nil, nil, fmt.Errorf("failed to start tar command: %w", err)
+	}
+	kill := func() {
+		session.Signal("KILL")
+		session.Close()
+	}
+	stop := context.AfterFunc(ctx, kill)
+	wait := func() error {
+		defer stop()
+		defer session.Close()
+		return session.Wait()
+	}
+	return stdout, wait, kill, nil
+}
+
+// DrainSpoolOptions controls DrainSpool.
+type DrainSpoolOptions struct {
+	// Retries is how many times each archive is tried per drain; at least 1.
+	Retries int
+	// RetryDelay is the wait before the second try, doubled before each
+	// further one.
+	RetryDelay time.Duration
+	DryRun     bool
+}
+
+// DrainSpoolResult counts what DrainSpool did with the spool.
+type DrainSpoolResult struct {
+	Uploaded int
+	Failed   int
+	Bytes    int64
+}
+
+// DrainSpool uploads the archives in s, oldest first, to Minio and, for
+// backups made with --include-aws-glacier, to Glacier. An archive leaves
+// the spool once every destination has it; one that still fails after
+// opts.Retries tries stays with its attempt count and last error for the
+// next drain. Each drain starts a new run record of the manager (see
+// LastRunRecord) with the uploads it made.
+func (bm *BackupManager) DrainSpool(s *Spool, opts *DrainSpoolOptions) (*DrainSpoolResult, error) {
+	if opts == nil {
+		opts = &DrainSpoolOptions{}
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	res := &DrainSpoolResult{}
+	if len(entries) == 0 {
+		return res, nil
+	}
+	if opts.DryRun {
+		for _, e := range entries {
+			fmt.Printf("[DRY RUN] Would upload %s (%.2f MB, spooled %s, %d attempt(s))\n", e.ObjectKey, float64(e.Size)/(1024*1024), FormatTime(e.CreatedAt, time.RFC3339), e.Attempts)
+		}
+		return res, nil
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	rec := &RunRecord{ID: NewRunID(started), Kind: RunKindSpool, StartedAt: started}
+	bm.lastRun = rec
+	for _, e := range entries {
+		fmt.Printf("📤 Uploading %s (%.2f MB)\n", e.ObjectKey, float64(e.Size)/(1024*1024))
+		stats := &UploadStats{Site: e.Site, Container: e.Container, ObjectKey: e.ObjectKey}
+		delay := opts.RetryDelay
+		for attempt := 1; ; attempt++ {
+			e.Attempts++
+			e.LastAttempt = time.Now().UTC()
+			err = bm.uploadSpoolEntry(s, e, stats)
+			if err == nil || attempt >= max(opts.Retries, 1) || errors.Is(err, ErrResidencyViolation) {
+				break
+			}
+			fmt.Printf("   ⚠️  Attempt %d failed: %v; retrying in %s\n", attempt, err, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if stats.Bytes > 0 || stats.Glacier != nil {
+			rec.Uploads = append(rec.Uploads, *stats)
+		}
+		if err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			e.LastError = err.Error()
+			if saveErr := s.save(e); saveErr != nil {
+				fmt.Printf("   ⚠️  Warning: %v\n", saveErr)
+			}
+			rec.Failed++
+			res.Failed++
+			continue
+		}
+		if err := s.remove(e); err != nil {
+			fmt.Printf("   ⚠️  Warning: %v\n", err)
+		}
+		fmt.Printf("   ✓ Uploaded\n")
+		rec.Succeeded++
+		res.Uploaded++
+		res.Bytes += e.Size
+	}
+	rec.FinishedAt = time.Now()
+	return res, nil
+}
+
+// uploadSpoolEntry uploads the archive of e to the destinations it still
+// misses, recording each one done in its sidecar.
+func (bm *BackupManager) uploadSpoolEntry(s *Spool, e *SpoolEntry, stats *UploadStats) error {
+	f, err := os.Open(s.archivePath(e.id))
+	if err != nil {
+		return fmt.Errorf("failed to open spooled archive: %w", err)
+	}
+	defer f.Close()
+
+	if !e.MinioDone {
+		minioStartTime := time.Now()
+		uploaded, err := bm.putObject(context.Background(), e.ObjectKey, f, e.Size, "application/gzip", e.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to upload to Minio: %w", err)
+		}
+		bm.fillUploadStats(stats, e.ObjectKey, uploaded, time.Since(minioStartTime))
+		e.MinioDone = true
+		if err := s.save(e); err != nil {
+			return err
+		}
+	}
+	if e.Glacier {
+		if bm.awsConfig == nil || bm.awsConfig.Vault == "" {
+			return fmt.Errorf("the backup also goes to Glacier, but no AWS vault is configured")
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind spooled archive: %w", err)
+		}
+		glacierStats, err := bm.uploadToAWS(e.ObjectKey, f, e.Size)
+		if err != nil {
+			return fmt.Errorf("failed to upload to AWS Glacier: %w", err)
+		}
+		stats.Glacier = glacierStats
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func spoolSite(t *testing.T) string {
+	t.Helper()
+	site := filepath.Join(t.TempDir(), "a.com")
+	os.MkdirAll(filepath.Join(site, "www"), 0o755)
+	os.WriteFile(filepath.Join(site, "www", "index.php"), []byte(strings.Repeat("<?php echo 1; ?>\n", 100)), 0o644)
+	return site
+}
+
+func TestSpoolAndDrain(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	spool := &Spool{Dir: t.TempDir()}
+	bm.SetSpool(spool)
+	site := spoolSite(t)
+	ctx := context.Background()
+
+	stats := &UploadStats{Site: "a.com", Container: "wp_a"}
+	n, _, err := bm.streamBackupToMinio(site, "a.com-20261016-020000.tgz", "", "", "", 0, false, stats, map[string]string{"site": "a.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "backups/a.com/a.com-20261016-020000.tgz"
+	if n == 0 || !stats.Spooled || stats.ObjectKey != key || stats.Bytes != n {
+		t.Fatalf("stats = %+v, n = %d", stats, n)
+	}
+	if _, err := bm.statObject(ctx, key); err == nil {
+		t.Fatal("a spooled archive was uploaded")
+	}
+	entries, err := spool.Entries()
+	if err != nil || len(entries) != 1 || entries[0].ObjectKey != key || entries[0].Size != n || entries[0].Container != "wp_a" {
+		t.Fatalf("Entries() = %+v, %v", entries, err)
+	}
+
+	res, err := bm.DrainSpool(spool, &DrainSpoolOptions{Retries: 1})
+	if err != nil || res.Uploaded != 1 || res.Bytes != n {
+		t.Fatalf("DrainSpool() = %+v, %v", res, err)
+	}
+	if info, err := bm.statObject(ctx, key); err != nil || info.Size != n {
+		t.Fatalf("uploaded object = %+v, %v", info, err)
+	}
+	if entries, _ := spool.Entries(); len(entries) != 0 {
+		t.Errorf("spool after drain = %+v", entries)
+	}
+	if used, _ := spool.Usage(); used != 0 {
+		t.Errorf("Usage() after drain = %d", used)
+	}
+	rec := bm.LastRunRecord()
+	if rec == nil || rec.Kind != RunKindSpool || rec.Succeeded != 1 || len(rec.Uploads) != 1 || rec.Uploads[0].Bytes != n {
+		t.Errorf("run record = %+v", rec)
+	}
+}
+
+func TestSpoolFullUploadsDirectly(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	spool := &Spool{Dir: t.TempDir(), MaxSize: 1024}
+	bm.SetSpool(spool)
+	stats := &UploadStats{Site: "a.com"}
+	if _, _, err := bm.streamBackupToMinio(spoolSite(t), "a.com-1.tgz", "", "", "", 1<<20, false, stats, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Spooled {
+		t.Error("an archive larger than the spool was spooled")
+	}
+	if _, err := bm.statObject(context.Background(), "backups/a.com/a.com-1.tgz"); err != nil {
+		t.Errorf("direct upload missing: %v", err)
+	}
+}
+
+func TestSpoolReservationGrow(t *testing.T) {
+	spool := &Spool{Dir: t.TempDir(), MaxSize: 100}
+	r, err := spool.reserve(40)
+	if err != nil || r == nil {
+		t.Fatalf("reserve(40) = %v, %v", r, err)
+	}
+	if other, _ := spool.reserve(70); other != nil {
+		t.Error("reserve(70) fits next to 40 in 100")
+	}
+	if err := r.grow(90); err != nil {
+		t.Errorf("grow(90) = %v", err)
+	}
+	if err := r.grow(20); !errors.Is(err, errSpoolFull) {
+		t.Errorf("grow past the limit = %v", err)
+	}
+	r.release()
+	if spool.inflight != 0 {
+		t.Errorf("inflight after release = %d", spool.inflight)
+	}
+}
+
+func TestDrainSpoolKeepsFailures(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	spool := &Spool{Dir: t.TempDir()}
+	e := &SpoolEntry{ObjectKey: "backups/a.com/a.com-1.tgz", Size: 1, CreatedAt: time.Now(), Glacier: true, id: spoolID("backups/a.com/a.com-1.tgz")}
+	os.WriteFile(spool.archivePath(e.id), []byte("x"), 0o600)
+	if err := spool.save(e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Minio succeeds, Glacier has no vault: the entry stays, minus Minio.
+	res, err := bm.DrainSpool(spool, &DrainSpoolOptions{Retries: 2, RetryDelay: time.Millisecond})
+	if err != nil || res.Failed != 1 {
+		t.Fatalf("DrainSpool() = %+v, %v", res, err)
+	}
+	entries, _ := spool.Entries()
+	if len(entries) != 1 || !entries[0].MinioDone || entries[0].Attempts != 2 || !strings.Contains(entries[0].LastError, "no AWS vault") {
+		t.Errorf("entry after failed drain = %+v", entries)
+	}
+}
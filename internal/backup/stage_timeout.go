@@ -0,0 +1,281 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Stages of a container's backup that can be given a timeout.
+const (
+	StageExport    = "export"
+	StageTar       = "tar"
+	StageUpload    = "upload"
+	StageContainer = "container"
+)
+
+// StageTimeouts bound how long the stages of one container's backup may
+// run. Zero leaves a stage unbounded. Tar and upload run as one stream, so
+// whichever of the two expires first stops both.
+type StageTimeouts struct {
+	Export    time.Duration
+	Tar       time.Duration
+	Upload    time.Duration
+	Container time.Duration
+}
+
+func (t StageTimeouts) of(stage string) time.Duration {
+	switch stage {
+	case StageExport:
+		return t.Export
+	case StageTar:
+		return t.Tar
+	case StageUpload:
+		return t.Upload
+	case StageContainer:
+		return t.Container
+	}
+	return 0
+}
+
+// Enabled reports whether any stage has a timeout.
+func (t StageTimeouts) Enabled() bool {
+	return t.Export > 0 || t.Tar > 0 || t.Upload > 0 || t.Container > 0
+}
+
+// cleanupTimeout bounds the commands that undo a timed-out stage, which run
+// after its deadline has passed.
+const cleanupTimeout = time.Minute
+
+// killGrace is how long timeout(1) waits after TERM before it sends KILL.
+const killGrace = 10 * time.Second
+
+// stageClock tracks the deadlines of the container being backed up.
+type stageClock struct {
+	mu        sync.Mutex
+	timeouts  StageTimeouts
+	container string
+	started   time.Time
+	// stages are the stages running since stageStarted.
+	stages       []string
+	stageStarted time.Time
+}
+
+// beginContainerTimeouts starts the clock of container under t and returns
+// the function that stops it.
+func (bm *BackupManager) beginContainerTimeouts(container string, t StageTimeouts) func() {
+	c := &bm.stages
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeouts, c.container, c.started, c.stages = t, container, time.Now(), nil
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.timeouts, c.container, c.started, c.stages = StageTimeouts{}, "", time.Time{}, nil
+	}
+}
+
+// beginStage marks stages as running from now on and returns the function
+// that ends them.
+func (bm *BackupManager) beginStage(stages ...string) func() {
+	c := &bm.stages
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, prevStarted := c.stages, c.stageStarted
+	c.stages, c.stageStarted = stages, time.Now()
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.stages, c.stageStarted = prev, prevStarted
+	}
+}
+
+// deadline returns the earliest deadline of the container and its running
+// stages, with the error to report when it passes; the zero time when none
+// is bounded.
+func (c *stageClock) deadline() (time.Time, *StageTimeoutError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var at time.Time
+	var timeoutErr *StageTimeoutError
+	consider := func(stage string, started time.Time) {
+		d := c.timeouts.of(stage)
+		if d <= 0 || started.IsZero() {
+			return
+		}
+		if t := started.Add(d); at.IsZero() || t.Before(at) {
+			at, timeoutErr = t, &StageTimeoutError{Container: c.container, Stage: stage, Timeout: d}
+		}
+	}
+	consider(StageContainer, c.started)
+	for _, s := range c.stages {
+		consider(s, c.stageStarted)
+	}
+	return at, timeoutErr
+}
+
+// stageContext returns a context that is done at the current deadline, and
+// the error to report when it is.
+func (bm *BackupManager) stageContext() (context.Context, context.CancelFunc, *StageTimeoutError) {
+	at, timeoutErr := bm.stages.deadline()
+	if at.IsZero() {
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), at)
+	return ctx, cancel, timeoutErr
+}
+
+// timeoutCommand runs cmd under timeout(1) on the host, so it is killed
+// there even when the connection to it is lost, and the processes it
+// started with it.
+func timeoutCommand(cmd string, d time.Duration) string {
+	return fmt.Sprintf("timeout -k %d %d bash -c %s", int(killGrace.Seconds()), int(math.Ceil(d.Seconds())), shellQuote(cmd))
+}
+
+// runCommandUntil runs cmd like executeCommand, killing it at deadline:
+// timeout(1) stops it on the host, and the local process or SSH session is
+// torn down when that does not return in time.
+func (bm *BackupManager) runCommandUntil(cmd string, deadline time.Time, timeoutErr *StageTimeoutError) (string, string, error) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return "", "", timeoutErr
+	}
+	wrapped := timeoutCommand(cmd, remaining)
+	var out, errOut bytes.Buffer
+	var kill func()
+	var wait func() error
+	if bm.sshClient == nil {
+		c := bm.shellCommand(wrapped)
+		c.Stdout, c.Stderr = &out, &errOut
+		if err := c.Start(); err != nil {
+			return "", "", err
+		}
+		kill = func() { _ = c.Process.Kill() }
+		wait = c.Wait
+	} else {
+		session, err := bm.sshClient.GetSession()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create SSH session: %w", err)
+		}
+		defer session.Close()
+		session.Stdout, session.Stderr = &out, &errOut
+		if err := session.Start(wrapped); err != nil {
+			return "", "", err
+		}
+		kill = func() {
+			_ = session.Signal(ssh.SIGKILL)
+			_ = session.Close()
+		}
+		wait = session.Wait
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- wait() }()
+	timer := time.NewTimer(remaining + killGrace + 5*time.Second)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		// timeout(1) exits 124 when it stopped the command with TERM and
+		// 137 when it had to KILL it.
+		if status := exitStatus(err); (status == 124 || status == 137) && !time.Now().Before(deadline) {
+			timeoutErr.Err = err
+			return out.String(), errOut.String(), timeoutErr
+		}
+		return out.String(), errOut.String(), err
+	case <-timer.C:
+		kill()
+		<-done
+		return out.String(), errOut.String(), timeoutErr
+	}
+}
+
+// exportStage runs export as the export stage of container. An export that
+// timed out is killed inside the containers too.
+func (bm *BackupManager) exportStage(container ContainerInfo, export func() error) error {
+	end := bm.beginStage(StageExport)
+	err := export()
+	end()
+	if errors.Is(err, ErrStageTimeout) {
+		bm.killExportProcesses(container)
+	}
+	return err
+}
+
+// cleanupCommand runs cmd with its own short timeout, regardless of the
+// deadlines of the stage it cleans up after.
+func (bm *BackupManager) cleanupCommand(cmd string) (stdout, stderr string, err error) {
+	defer func(started time.Time) { bm.auditCommand(cmd, started, stdout, stderr, err) }(time.Now())
+	return bm.runCommandUntil(cmd, time.Now().Add(cleanupTimeout), &StageTimeoutError{Stage: "cleanup", Timeout: cleanupTimeout})
+}
+
+// exportProcessPatterns match the command lines of database exports. Each
+// has a bracket or quote in it, so the script that kills them does not
+// match its own command line.
+const exportProcessPatterns = `*"db e"xport*|*mysql[d]ump*|*mariadb-[d]ump*|*pg_[d]ump*|*mongo[d]ump*`
+
+// killExportProcesses kills the database exports still running inside the
+// containers of container after its export timed out. Killing docker exec
+// on the host leaves the process it started in the container running.
+func (bm *BackupManager) killExportProcesses(container ContainerInfo) {
+	targets := []string{container.Name}
+	if container.Config != nil && container.Config.Database.Container != "" && container.Config.Database.Container != container.Name {
+		targets = append(targets, container.Config.Database.Container)
+	}
+	script := `for p in /proc/[0-9]*; do pid=${p#/proc/}; [ "$pid" = "$$" ] && continue; ` +
+		`case "$(tr '\0' ' ' < "$p/cmdline" 2>/dev/null)" in ` + exportProcessPatterns + `) kill -9 "$pid" && echo "$pid";; esac; done`
+	for _, name := range targets {
+		out, stderr, err := bm.cleanupCommand(fmt.Sprintf(`docker exec -u 0 "%s" sh -c %s`, name, shellQuote(script)))
+		if err != nil {
+			fmt.Printf("   ⚠️  Warning: failed to stop the export in %s: %v (stderr: %s)\n", name, err, stderr)
+		} else if killed := bytes.Fields([]byte(out)); len(killed) > 0 {
+			fmt.Printf("   🛑 Killed %d export process(es) in %s\n", len(killed), name)
+		}
+	}
+}
+
+// discardPartialUpload removes what an aborted upload left of objectName:
+// the parts of an unfinished multipart upload, and the object itself when
+// the stream was cut short but still completed.
+func (bm *BackupManager) discardPartialUpload(objectName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if bm.fileStore == nil && bm.minioClient != nil {
+		if err := bm.minioClient.RemoveIncompleteUpload(ctx, bm.minioConfig.Bucket, objectName); err != nil {
+			fmt.Printf("   ⚠️  Warning: failed to abort the upload of %s: %v\n", objectName, err)
+		}
+	}
+	if _, err := bm.statObject(ctx, objectName); err != nil {
+		return
+	}
+	if err := bm.removeObject(ctx, objectName); err != nil {
+		fmt.Printf("   ⚠️  Warning: failed to remove partial object %s: %v\n", objectName, err)
+		return
+	}
+	fmt.Printf("   🗑️  Removed partial object %s\n", objectName)
+}
+
+// stageReader fails reads once ctx is done, so a stream cut short by a
+// timeout is not uploaded as if it had ended.
+type stageReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (s stageReader) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := s.r.Read(p)
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return n, ctxErr
+	}
+	return n, err
+}
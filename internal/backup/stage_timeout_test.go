@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageClockDeadline(t *testing.T) {
+	bm := NewBackupManager(nil, nil)
+	if at, _ := bm.stages.deadline(); !at.IsZero() {
+		t.Fatalf("deadline without timeouts = %s", at)
+	}
+	stop := bm.beginContainerTimeouts("wp_a", StageTimeouts{Tar: 10 * time.Minute, Upload: 5 * time.Minute, Container: time.Hour})
+	at, timeoutErr := bm.stages.deadline()
+	if timeoutErr.Stage != StageContainer || time.Until(at) < 59*time.Minute {
+		t.Errorf("container deadline = %s, %v", at, timeoutErr)
+	}
+	end := bm.beginStage(StageTar, StageUpload)
+	if _, timeoutErr := bm.stages.deadline(); timeoutErr.Stage != StageUpload || timeoutErr.Container != "wp_a" {
+		t.Errorf("stream deadline = %v", timeoutErr)
+	}
+	end()
+	if _, timeoutErr := bm.stages.deadline(); timeoutErr.Stage != StageContainer {
+		t.Errorf("after the stage = %v", timeoutErr)
+	}
+	stop()
+	if at, _ := bm.stages.deadline(); !at.IsZero() {
+		t.Errorf("deadline after the container = %s", at)
+	}
+}
+
+func TestExecuteCommandStageTimeout(t *testing.T) {
+	bm := NewBackupManager(nil, nil)
+	defer bm.beginContainerTimeouts("wp_a", StageTimeouts{Export: time.Second})()
+
+	// Commands outside the stage are not bounded by it.
+	if out, _, err := bm.executeCommand(`echo "it's fine"`); err != nil || out != "it's fine\n" {
+		t.Fatalf("executeCommand() = %q, %v", out, err)
+	}
+
+	end := bm.beginStage(StageExport)
+	defer end()
+	started := time.Now()
+	_, _, err := bm.executeCommand("sleep 30")
+	if !errors.Is(err, ErrStageTimeout) || time.Since(started) > 10*time.Second {
+		t.Fatalf("executeCommand() = %v after %s", err, time.Since(started))
+	}
+	var timeoutErr *StageTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Stage != StageExport || !strings.Contains(err.Error(), "export stage of wp_a timed out after 1s") {
+		t.Errorf("error = %v", err)
+	}
+	if diag := ClassifyBackupError(err); diag.Code != FailureTimeout {
+		t.Errorf("diagnosis = %+v", diag)
+	}
+
+	// Past the deadline nothing runs any more, but cleanup does.
+	if _, _, err := bm.executeCommand("true"); !errors.Is(err, ErrStageTimeout) {
+		t.Errorf("command past the deadline = %v", err)
+	}
+	if out, _, err := bm.cleanupCommand("echo cleaned"); err != nil || out != "cleaned\n" {
+		t.Errorf("cleanupCommand() = %q, %v", out, err)
+	}
+}
+
+func TestStageReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := stageReader{ctx, strings.NewReader("partial")}
+	buf := make([]byte, 3)
+	if n, err := r.Read(buf); n != 3 || err != nil {
+		t.Fatalf("Read() = %d, %v", n, err)
+	}
+	cancel()
+	// A stream cut short must not end like a complete one.
+	if _, err := io.ReadAll(r); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAll() after cancel = %v", err)
+	}
+}
+
+func TestDiscardPartialUpload(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "backups/a.com/a.com-20261016-020000.tgz"
+	if _, err := bm.putObject(ctx, key, strings.NewReader("truncated"), -1, "application/gzip", nil); err != nil {
+		t.Fatal(err)
+	}
+	bm.discardPartialUpload(key)
+	if _, err := bm.statObject(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("partial object still there: %v", err)
+	}
+	// Nothing left to remove is not an error.
+	bm.discardPartialUpload(key)
+}
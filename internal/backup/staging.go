@@ -0,0 +1,231 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStagingPrefix is where staged objects are kept when SetStaging is
+// given no prefix.
+const DefaultStagingPrefix = "staging/"
+
+// staging is the staging tier of a manager and the state of its background
+// drain to Glacier.
+type staging struct {
+	tier   *BackupManager
+	prefix string
+
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+	// pending is set when objects are staged after the running drain last
+	// listed the tier, so it lists again before stopping.
+	pending  bool
+	archived int
+	failed   int
+	err      error
+}
+
+// SetStaging routes Glacier migrations through a staging tier. Each object is
+// copied to tier under prefix, verified against the hot copy by SHA-256 and
+// deleted from the hot bucket straight away; the slow Glacier upload then
+// reads it from the tier in the background (see StartStagingDrain). A nil
+// tier stages into the hot bucket itself, which takes objects out of the
+// backup listings at once but only frees disk if the server tiers the prefix
+// elsewhere; a bucket on other drives frees it immediately.
+func (bm *BackupManager) SetStaging(tier *BackupManager, prefix string) {
+	if tier == nil {
+		tier = bm
+	}
+	if prefix == "" {
+		prefix = DefaultStagingPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	bm.staging.tier, bm.staging.prefix = tier, prefix
+}
+
+// StagingEnabled reports whether migrations go through a staging tier.
+func (bm *BackupManager) StagingEnabled() bool {
+	return bm.staging.tier != nil
+}
+
+// StagingLocation describes the staging tier as bucket/prefix.
+func (bm *BackupManager) StagingLocation() string {
+	if bm.staging.tier == nil {
+		return ""
+	}
+	bucket := bm.staging.tier.minioConfig.Bucket
+	if bucket == "" {
+		bucket = bm.staging.tier.minioConfig.Endpoint
+	}
+	return bucket + "/" + bm.staging.prefix
+}
+
+// IsStagedKey reports whether key is a staged object kept in the hot bucket
+// itself, which bucket-wide listings of backups must skip.
+func (bm *BackupManager) IsStagedKey(key string) bool {
+	return bm.staging.tier == bm && strings.HasPrefix(key, bm.staging.prefix)
+}
+
+// StageObject copies obj to the staging tier, checks that the staged copy
+// hashes the same as the hot one and deletes obj from the hot bucket. A copy
+// that does not match is removed and obj stays where it is.
+func (bm *BackupManager) StageObject(obj ObjectInfo) error {
+	st := &bm.staging
+	if st.tier == nil {
+		return fmt.Errorf("no staging tier configured")
+	}
+	if err := bm.initMinioClient(); err != nil {
+		return err
+	}
+	if err := st.tier.initMinioClient(); err != nil {
+		return fmt.Errorf("failed to initialize staging tier: %w", err)
+	}
+
+	ctx := context.Background()
+	// Staging deletes the hot copy, so it honors the delete hold before
+	// copying anything.
+	if _, err := bm.holdBack(ctx, []string{obj.Key}); err != nil {
+		return err
+	}
+	stagedKey := st.prefix + obj.Key
+	var sum string
+	var err error
+	if bm.sameMinioServer(st.tier) {
+		err = bm.copyObjectServerSide(ctx, st.tier, obj.Key, stagedKey)
+	} else {
+		_, sum, err = bm.streamObjectTo(ctx, st.tier, obj, stagedKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", obj.Key, err)
+	}
+	if sum == "" {
+		if _, sum, err = hashObject(ctx, bm, obj.Key); err != nil {
+			return fmt.Errorf("failed to hash %s: %w", obj.Key, err)
+		}
+	}
+	_, staged, err := hashObject(ctx, st.tier, stagedKey)
+	if err != nil {
+		return fmt.Errorf("failed to read back staged copy of %s: %w", obj.Key, err)
+	}
+	if staged != sum {
+		if rmErr := st.tier.removeObject(ctx, stagedKey); rmErr != nil {
+			bm.logVerbose("Failed to remove mismatched staged copy of %s: %v", obj.Key, rmErr)
+		}
+		return fmt.Errorf("staged copy of %s does not match: hot %s, staged %s", obj.Key, sum, staged)
+	}
+
+	if err := bm.removeObject(ctx, obj.Key); err != nil {
+		return fmt.Errorf("staged %s but failed to delete it from the hot bucket: %w", obj.Key, err)
+	}
+	st.mu.Lock()
+	st.pending = true
+	st.mu.Unlock()
+	return nil
+}
+
+// StartStagingDrain archives the staged objects to Glacier in the
+// background, including objects staged while it runs, and removes each one
+// from the tier once its archive is complete. It does nothing when a drain
+// is already running. Call WaitStaging before exiting.
+func (bm *BackupManager) StartStagingDrain() {
+	st := &bm.staging
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.tier == nil || st.draining {
+		return
+	}
+	st.draining = true
+	st.pending = true
+	st.wg.Add(1)
+	go func() {
+		defer st.wg.Done()
+		bm.drainStaging()
+	}()
+}
+
+func (bm *BackupManager) drainStaging() {
+	st := &bm.staging
+	failed := make(map[string]bool)
+	for {
+		st.mu.Lock()
+		if !st.pending {
+			st.draining = false
+			st.mu.Unlock()
+			return
+		}
+		st.pending = false
+		st.mu.Unlock()
+
+		objs, err := st.tier.ListBackups(st.prefix, 0)
+		if err != nil {
+			st.mu.Lock()
+			st.err = fmt.Errorf("failed to list staging tier: %w", err)
+			st.draining = false
+			st.mu.Unlock()
+			return
+		}
+		for _, o := range objs {
+			if failed[o.Key] {
+				continue
+			}
+			err := bm.archiveStaged(o)
+			st.mu.Lock()
+			if err != nil {
+				failed[o.Key] = true
+				st.failed++
+			} else {
+				st.archived++
+			}
+			st.mu.Unlock()
+			if err != nil {
+				fmt.Printf("  ⚠ [staging] %v\n", err)
+			}
+		}
+	}
+}
+
+// archiveStaged uploads one staged object to Glacier under its original key,
+// so the archive description and ledger entry match a direct migration.
+func (bm *BackupManager) archiveStaged(o ObjectInfo) error {
+	st := &bm.staging
+	key := strings.TrimPrefix(o.Key, st.prefix)
+	fmt.Printf("📤 [staging] Archiving %s (%.2f MB)\n", key, float64(o.Size)/(1024*1024))
+
+	started := time.Now()
+	stats, err := bm.migrateObjectToGlacier(st.tier, o.Key, key, o.Size)
+	bm.recordMigration(started, key, stats, err)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s; it stays staged: %w", key, err)
+	}
+	if err := st.tier.removeObject(context.Background(), o.Key); err != nil {
+		return fmt.Errorf("archived %s but failed to remove the staged copy: %w", key, err)
+	}
+	fmt.Printf("  ✓ [staging] %s archived (Archive ID: %s...)\n", key, stats.ArchiveID[:min(40, len(stats.ArchiveID))])
+	return nil
+}
+
+// WaitStaging waits for the background drain to finish. Objects that failed
+// to archive stay in the staging tier for the next drain and are reported
+// as an error.
+func (bm *BackupManager) WaitStaging() error {
+	st := &bm.staging
+	st.wg.Wait()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.err != nil {
+		return st.err
+	}
+	if st.archived > 0 || st.failed > 0 {
+		fmt.Printf("\n✓ Staging drained: %d archived to Glacier, %d left in %s\n", st.archived, st.failed, bm.StagingLocation())
+	}
+	if st.failed > 0 {
+		return fmt.Errorf("%d staged object(s) could not be archived and remain in %s", st.failed, bm.StagingLocation())
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStageObjectToSeparateTier(t *testing.T) {
+	hot, tier := newReplicaPair(t)
+	putTestObject(t, hot, "backups/a.com/a-1.tgz", "one")
+	putTestObject(t, hot, "backups/a.com/a-2.tgz", "two")
+	hot.SetStaging(tier, "")
+
+	objs, err := hot.ListBackups("backups/a.com/a-1.tgz", 1)
+	if err != nil || len(objs) != 1 {
+		t.Fatalf("ListBackups() = %v, %v", objs, err)
+	}
+	if err := hot.StageObject(objs[0]); err != nil {
+		t.Fatalf("StageObject() error = %v", err)
+	}
+
+	if got := listKeys(t, hot); !reflect.DeepEqual(got, []string{"backups/a.com/a-2.tgz"}) {
+		t.Errorf("hot keys = %v, want only a-2 left", got)
+	}
+	if got := listKeys(t, tier); !reflect.DeepEqual(got, []string{"staging/backups/a.com/a-1.tgz"}) {
+		t.Errorf("staging keys = %v", got)
+	}
+	if hot.IsStagedKey("staging/backups/a.com/a-1.tgz") {
+		t.Error("IsStagedKey() is true for a separate staging tier")
+	}
+}
+
+func TestStageObjectWithinHotBucket(t *testing.T) {
+	hot, _ := newReplicaPair(t)
+	putTestObject(t, hot, "backups/a.com/a-1.tgz", "one")
+	hot.SetStaging(nil, "parked")
+
+	objs, err := hot.ListBackups("backups/", 0)
+	if err != nil || len(objs) != 1 {
+		t.Fatalf("ListBackups() = %v, %v", objs, err)
+	}
+	if err := hot.StageObject(objs[0]); err != nil {
+		t.Fatalf("StageObject() error = %v", err)
+	}
+	if got := listKeys(t, hot); !reflect.DeepEqual(got, []string{"parked/backups/a.com/a-1.tgz"}) {
+		t.Errorf("keys = %v, want the object under the staging prefix", got)
+	}
+	if !hot.IsStagedKey("parked/backups/a.com/a-1.tgz") || hot.IsStagedKey("backups/a.com/a-1.tgz") {
+		t.Error("IsStagedKey() does not match the staging prefix")
+	}
+}
+
+func TestStageObjectKeepsHotCopyOnFailure(t *testing.T) {
+	hot, tier := newReplicaPair(t)
+	hot.SetStaging(tier, "")
+
+	// The object is not in the hot bucket, so the copy fails and nothing
+	// may be removed or left staged.
+	putTestObject(t, tier, "backups/a.com/other.tgz", "x")
+	if err := hot.StageObject(ObjectInfo{Key: "backups/a.com/missing.tgz", Size: 3}); err == nil {
+		t.Fatal("StageObject() succeeded for a missing object")
+	}
+	if got := listKeys(t, tier); !reflect.DeepEqual(got, []string{"backups/a.com/other.tgz"}) {
+		t.Errorf("staging keys = %v, want nothing staged", got)
+	}
+}
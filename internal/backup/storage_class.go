@@ -0,0 +1,144 @@
+package backup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Retention tiers a backup belongs to by the day it was taken, as smart
+// retention classifies them.
+const (
+	RetentionTierDaily   = "daily"
+	RetentionTierWeekly  = "weekly"
+	RetentionTierMonthly = "monthly"
+)
+
+// s3StorageClasses are the storage classes AWS S3 accepts on PutObject.
+// S3-compatible servers support a subset; MinIO only STANDARD and
+// REDUCED_REDUNDANCY.
+var s3StorageClasses = []string{
+	"STANDARD", "REDUCED_REDUNDANCY", "STANDARD_IA", "ONEZONE_IA",
+	"INTELLIGENT_TIERING", "GLACIER", "GLACIER_IR", "DEEP_ARCHIVE",
+}
+
+// StorageClassPolicy picks the storage class of each backup upload from its
+// retention tier, so S3-native deployments tier backups when they are written
+// instead of with separate migration jobs.
+type StorageClassPolicy struct {
+	// Classes maps retention tiers to storage classes. A tier without a
+	// class uses the class of the next lower tier (monthly, weekly, daily);
+	// with none the bucket's default applies.
+	Classes map[string]string
+	// WeeklyDay (0=Sunday) and MonthlyDay decide which backups are weekly
+	// and monthly, as in SmartRetentionPolicy.
+	WeeklyDay  int
+	MonthlyDay int
+}
+
+// ParseStorageClassPolicy parses "daily=STANDARD_IA,weekly=GLACIER_IR,
+// monthly=DEEP_ARCHIVE". Weekly backups are taken on Sundays and monthly ones
+// on the 1st until SetRetentionCalendar says otherwise.
+func ParseStorageClassPolicy(spec string) (*StorageClassPolicy, error) {
+	p := &StorageClassPolicy{Classes: map[string]string{}, MonthlyDay: 1}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		tier, class, ok := strings.Cut(part, "=")
+		tier = strings.ToLower(strings.TrimSpace(tier))
+		class = strings.ToUpper(strings.TrimSpace(class))
+		if !ok || class == "" {
+			return nil, fmt.Errorf("invalid storage class mapping %q (use tier=CLASS)", part)
+		}
+		switch tier {
+		case RetentionTierDaily, RetentionTierWeekly, RetentionTierMonthly:
+		default:
+			return nil, fmt.Errorf("unknown retention tier %q (use daily, weekly or monthly)", tier)
+		}
+		if !slices.Contains(s3StorageClasses, class) {
+			return nil, fmt.Errorf("unknown storage class %q (use one of %s)", class, strings.Join(s3StorageClasses, ", "))
+		}
+		p.Classes[tier] = class
+	}
+	if len(p.Classes) == 0 {
+		return nil, fmt.Errorf("no storage class mappings in %q", spec)
+	}
+	return p, nil
+}
+
+// RetentionTier returns the tier of a backup taken at taken: monthly on
+// monthlyDay, weekly on weeklyDay, daily otherwise, in the canonical
+// timezone.
+func RetentionTier(taken time.Time, weeklyDay, monthlyDay int) string {
+	taken = InTimezone(taken)
+	switch {
+	case taken.Day() == monthlyDay:
+		return RetentionTierMonthly
+	case int(taken.Weekday()) == weeklyDay:
+		return RetentionTierWeekly
+	default:
+		return RetentionTierDaily
+	}
+}
+
+// ClassFor returns the storage class to upload key with, or "" for the
+// bucket's default: for state objects, keys without a backup timestamp and
+// tiers nothing is mapped for.
+func (p *StorageClassPolicy) ClassFor(key string) string {
+	if p == nil || isInternalObject(key) {
+		return ""
+	}
+	_, taken, ok := parseBackupName(key)
+	if !ok {
+		return ""
+	}
+	tiers := []string{RetentionTierMonthly, RetentionTierWeekly, RetentionTierDaily}
+	switch RetentionTier(taken, p.WeeklyDay, p.MonthlyDay) {
+	case RetentionTierWeekly:
+		tiers = tiers[1:]
+	case RetentionTierDaily:
+		tiers = tiers[2:]
+	}
+	for _, tier := range tiers {
+		if class := p.Classes[tier]; class != "" {
+			return class
+		}
+	}
+	return ""
+}
+
+// String renders p like the spec it was parsed from.
+func (p *StorageClassPolicy) String() string {
+	var parts []string
+	for _, tier := range []string{RetentionTierDaily, RetentionTierWeekly, RetentionTierMonthly} {
+		if class := p.Classes[tier]; class != "" {
+			parts = append(parts, tier+"="+class)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// SetRetentionCalendar makes uploads use the weekly and monthly days of
+// policy to decide their tier. A nil policy keeps the defaults.
+func (bm *BackupManager) SetRetentionCalendar(policy *SmartRetentionPolicy) {
+	if policy == nil || bm.minioConfig == nil || bm.minioConfig.StorageClasses == nil {
+		return
+	}
+	p := *bm.minioConfig.StorageClasses
+	p.WeeklyDay, p.MonthlyDay = policy.WeeklyDay, policy.MonthlyDay
+	bm.storageClasses = &p
+}
+
+// storageClassPolicy returns the policy uploads are tiered with, or nil.
+func (bm *BackupManager) storageClassPolicy() *StorageClassPolicy {
+	if bm.storageClasses != nil {
+		return bm.storageClasses
+	}
+	if bm.minioConfig == nil {
+		return nil
+	}
+	return bm.minioConfig.StorageClasses
+}
@@ -0,0 +1,61 @@
+package backup
+
+import "testing"
+
+func TestStorageClassPolicy(t *testing.T) {
+	p, err := ParseStorageClassPolicy("daily=standard_ia, weekly=GLACIER_IR,monthly=DEEP_ARCHIVE")
+	if err != nil {
+		t.Fatalf("ParseStorageClassPolicy() error = %v", err)
+	}
+	if got := p.String(); got != "daily=STANDARD_IA,weekly=GLACIER_IR,monthly=DEEP_ARCHIVE" {
+		t.Errorf("String() = %q", got)
+	}
+	for key, want := range map[string]string{
+		"backups/a.com/a.com-20260401-020000.tgz":        "DEEP_ARCHIVE", // the 1st
+		"backups/a.com/a.com-20260405-020000.db.sql.gz":  "GLACIER_IR",   // a Sunday
+		"backups/a.com/a.com-20260407-020000.tgz":        "STANDARD_IA",
+		"backups/a.com/notes.txt":                        "",
+		stateObjectPrefix + "a.com-20260401-020000.json": "",
+	} {
+		if got := p.ClassFor(key); got != want {
+			t.Errorf("ClassFor(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	// Unmapped tiers fall back to the next lower tier.
+	p, _ = ParseStorageClassPolicy("daily=STANDARD_IA")
+	if got := p.ClassFor("backups/a.com/a.com-20260401-020000.tgz"); got != "STANDARD_IA" {
+		t.Errorf("monthly ClassFor() with only daily mapped = %q", got)
+	}
+	p, _ = ParseStorageClassPolicy("monthly=GLACIER")
+	if got := p.ClassFor("backups/a.com/a.com-20260407-020000.tgz"); got != "" {
+		t.Errorf("daily ClassFor() with only monthly mapped = %q, want the bucket default", got)
+	}
+	var none *StorageClassPolicy
+	if got := none.ClassFor("backups/a.com/a.com-20260401-020000.tgz"); got != "" {
+		t.Errorf("nil ClassFor() = %q", got)
+	}
+
+	for _, spec := range []string{"", "hourly=STANDARD", "daily=COLD", "daily"} {
+		if _, err := ParseStorageClassPolicy(spec); err == nil {
+			t.Errorf("ParseStorageClassPolicy(%q) error = nil", spec)
+		}
+	}
+}
+
+func TestSetRetentionCalendar(t *testing.T) {
+	classes, _ := ParseStorageClassPolicy("daily=STANDARD_IA,weekly=GLACIER_IR,monthly=DEEP_ARCHIVE")
+	cfg := &MinioConfig{Endpoint: "minio:9000", StorageClasses: classes}
+	bm := NewBackupManager(nil, cfg)
+	bm.SetRetentionCalendar(&SmartRetentionPolicy{WeeklyDay: 2, MonthlyDay: 15})
+
+	if got := bm.storageClassPolicy().ClassFor("backups/a.com/a.com-20260407-020000.tgz"); got != "GLACIER_IR" {
+		t.Errorf("Tuesday backup class = %q, want GLACIER_IR", got)
+	}
+	if got := bm.storageClassPolicy().ClassFor("backups/a.com/a.com-20260401-020000.tgz"); got != "STANDARD_IA" {
+		t.Errorf("backup on the 1st class = %q, want STANDARD_IA", got)
+	}
+	if cfg.StorageClasses.WeeklyDay != 0 || cfg.StorageClasses.MonthlyDay != 1 {
+		t.Errorf("SetRetentionCalendar() changed the shared config: %+v", cfg.StorageClasses)
+	}
+}
@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Categories of the warnings tar prints on stderr while archiving a site.
+const (
+	TarWarningFileChanged      = "file-changed"
+	TarWarningFileRemoved      = "file-removed"
+	TarWarningPermissionDenied = "permission-denied"
+	TarWarningSocketIgnored    = "socket-ignored"
+	TarWarningOther            = "other"
+)
+
+// DefaultTarWarningThreshold is how many tar warnings a run may collect before
+// it is reported as completed with warnings.
+const DefaultTarWarningThreshold = 10
+
+// tarWarningPatterns map stderr fragments of GNU tar to their category, in
+// the order they are tried.
+var tarWarningPatterns = []struct {
+	fragment string
+	category string
+}{
+	{"file changed as we read it", TarWarningFileChanged},
+	{"File removed before we read it", TarWarningFileRemoved},
+	{"No such file or directory", TarWarningFileRemoved},
+	{"Permission denied", TarWarningPermissionDenied},
+	{"socket ignored", TarWarningSocketIgnored},
+}
+
+// classifyTarLine returns the category of one line of tar stderr, or "" for
+// lines that are not warnings, like tar's closing "Exiting with failure
+// status" notice.
+func classifyTarLine(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.Contains(line, "Exiting with failure status due to previous errors") {
+		return ""
+	}
+	for _, p := range tarWarningPatterns {
+		if strings.Contains(line, p.fragment) {
+			return p.category
+		}
+	}
+	return TarWarningOther
+}
+
+// tarStderr receives the stderr of a backup's tar pipeline. Each complete
+// line is printed as it arrives, prefixed with the container, and counted by
+// category; the whole output is kept for TarError.
+type tarStderr struct {
+	mu      sync.Mutex
+	label   string
+	quiet   bool
+	all     bytes.Buffer
+	partial []byte
+	counts  map[string]int
+}
+
+func (bm *BackupManager) newTarStderr(label string) *tarStderr {
+	return &tarStderr{label: label, quiet: bm.verbosity < 1, counts: map[string]int{}}
+}
+
+func (t *tarStderr) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.all.Write(p)
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		t.line(string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+	}
+}
+
+// line counts and prints one line; the caller holds mu.
+func (t *tarStderr) line(s string) {
+	category := classifyTarLine(s)
+	if category == "" {
+		return
+	}
+	t.counts[category]++
+	if !t.quiet {
+		fmt.Printf("   [%s] %s (%s)\n", t.label, strings.TrimSpace(s), category)
+	}
+}
+
+// flush handles a last line without a newline.
+func (t *tarStderr) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.partial) > 0 {
+		t.line(string(t.partial))
+		t.partial = nil
+	}
+}
+
+func (t *tarStderr) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.all.String()
+}
+
+// Counts returns the warnings seen so far by category.
+func (t *tarStderr) Counts() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]int, len(t.counts))
+	for k, v := range t.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// fileChanged reports whether tar only failed because files changed while
+// they were read, which leaves a usable archive.
+func (t *tarStderr) fileChanged() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[TarWarningFileChanged] > 0
+}
+
+// addTarWarnings adds counts to the run's totals.
+func (r *RunRecord) addTarWarnings(counts map[string]int) {
+	if r == nil || len(counts) == 0 {
+		return
+	}
+	if r.TarWarnings == nil {
+		r.TarWarnings = map[string]int{}
+	}
+	for k, v := range counts {
+		r.TarWarnings[k] += v
+	}
+}
+
+// FormatTarWarnings renders counts as "file-changed=3, permission-denied=1",
+// sorted by category.
+func FormatTarWarnings(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// totalTarWarnings sums counts.
+func totalTarWarnings(counts map[string]int) int {
+	n := 0
+	for _, v := range counts {
+		n += v
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyTarLine(t *testing.T) {
+	for _, c := range []struct {
+		line, want string
+	}{
+		{"tar: ./wp-content/debug.log: file changed as we read it", TarWarningFileChanged},
+		{"tar: ./wp-content/cache/x: File removed before we read it", TarWarningFileRemoved},
+		{"tar: ./wp-content/tmp/y: Cannot stat: No such file or directory", TarWarningFileRemoved},
+		{"tar: ./wp-config.php: Cannot open: Permission denied", TarWarningPermissionDenied},
+		{"tar: ./mysqld.sock: socket ignored", TarWarningSocketIgnored},
+		{"tar: Removing leading `/' from member names", TarWarningOther},
+		{"tar: Exiting with failure status due to previous errors", ""},
+		{"   ", ""},
+	} {
+		if got := classifyTarLine(c.line); got != c.want {
+			t.Errorf("classifyTarLine(%q) = %q, want %q", c.line, got, c.want)
+		}
+	}
+}
+
+func TestTarStderr(t *testing.T) {
+	bm := &BackupManager{verbosity: 0}
+	w := bm.newTarStderr("wp_foo")
+	// Lines split across writes are only classified once complete.
+	w.Write([]byte("tar: ./a.log: file chan"))
+	if w.fileChanged() {
+		t.Fatal("fileChanged() = true before the line was complete")
+	}
+	w.Write([]byte("ged as we read it\ntar: ./b: Cannot open: Permission denied\ntar: ./c.log: file changed as we read it\n"))
+	w.Write([]byte("tar: Exiting with failure status due to previous errors"))
+	w.flush()
+
+	if !w.fileChanged() {
+		t.Error("fileChanged() = false")
+	}
+	counts := w.Counts()
+	if counts[TarWarningFileChanged] != 2 || counts[TarWarningPermissionDenied] != 1 || len(counts) != 2 {
+		t.Errorf("Counts() = %v", counts)
+	}
+	if !strings.HasSuffix(w.String(), "previous errors") || !strings.HasPrefix(w.String(), "tar: ./a.log: file changed") {
+		t.Errorf("String() = %q, want the whole stderr", w.String())
+	}
+	if got := FormatTarWarnings(counts); got != "file-changed=2, permission-denied=1" {
+		t.Errorf("FormatTarWarnings() = %q", got)
+	}
+
+	var rec *RunRecord
+	rec.addTarWarnings(counts) // a nil record is ignored
+	rec = &RunRecord{}
+	rec.addTarWarnings(counts)
+	rec.addTarWarnings(map[string]int{TarWarningFileChanged: 1, TarWarningOther: 4})
+	if totalTarWarnings(rec.TarWarnings) != 8 || rec.TarWarnings[TarWarningFileChanged] != 3 {
+		t.Errorf("run TarWarnings = %v", rec.TarWarnings)
+	}
+}
@@ -0,0 +1,215 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SetTempBudget makes stages that spill to the temp directory (the Glacier
+// buffer of dual uploads, multipart migration parts) reserve their
+// estimated size first. A stage starts only when the measured free space, minus what
+// running stages of this process are still expected to write, covers its
+// estimate plus headroom; otherwise it waits up to maxWait for space to be
+// released, then fails before writing anything rather than with ENOSPC
+// half-way through.
+func (bm *BackupManager) SetTempBudget(headroom int64, maxWait time.Duration) {
+	bm.tempHeadroom = headroom
+	bm.tempMaxWait = maxWait
+}
+
+// TempSpaceError reports a stage that could not get its temp space.
+type TempSpaceError struct {
+	Dir      string
+	Stage    string
+	Needed   int64
+	Free     int64
+	Reserved int64
+	Waited   time.Duration
+}
+
+func (e *TempSpaceError) Error() string {
+	return fmt.Sprintf("not enough temp space in %s for %s: needs %.1f MB, %.1f MB free with %.1f MB still reserved by running stages (waited %s)",
+		e.Dir, e.Stage, float64(e.Needed)/(1024*1024), float64(e.Free)/(1024*1024), float64(e.Reserved)/(1024*1024), e.Waited.Round(time.Second))
+}
+
+// tempBudgetPoll is how often a waiting stage re-measures free space, which
+// other processes may release.
+var tempBudgetPoll = 5 * time.Second
+
+// tempBudget tracks the reservations of one temp directory, shared by every
+// manager of the process.
+type tempBudget struct {
+	dir       string
+	mu        sync.Mutex
+	held      map[*tempReservation]struct{}
+	released  chan struct{}
+	freeSpace func(dir string) (int64, error)
+}
+
+var (
+	tempBudgetsMu sync.Mutex
+	tempBudgets   = map[string]*tempBudget{}
+)
+
+func tempBudgetFor(dir string) *tempBudget {
+	tempBudgetsMu.Lock()
+	defer tempBudgetsMu.Unlock()
+	b, ok := tempBudgets[dir]
+	if !ok {
+		b = &tempBudget{
+			dir:       dir,
+			held:      map[*tempReservation]struct{}{},
+			released:  make(chan struct{}),
+			freeSpace: statfsFree,
+		}
+		tempBudgets[dir] = b
+	}
+	return b
+}
+
+func statfsFree(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// tempReservation is the temp space granted to one stage. Its file is
+// tracked so that only the part not written yet counts against others.
+type tempReservation struct {
+	budget   *tempBudget
+	estimate int64
+	file     string
+}
+
+// track names the temp file the stage writes its reservation into.
+func (r *tempReservation) track(file string) {
+	if r == nil {
+		return
+	}
+	r.budget.mu.Lock()
+	r.file = file
+	r.budget.mu.Unlock()
+}
+
+// release returns the reservation; it is safe to call more than once.
+func (r *tempReservation) release() {
+	if r == nil {
+		return
+	}
+	b := r.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.held[r]; !ok {
+		return
+	}
+	delete(b.held, r)
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// outstandingLocked is what held reservations are still expected to write.
+func (b *tempBudget) outstandingLocked() int64 {
+	var n int64
+	for r := range b.held {
+		left := r.estimate
+		if r.file != "" {
+			if info, err := os.Stat(r.file); err == nil {
+				left -= info.Size()
+			}
+		}
+		if left > 0 {
+			n += left
+		}
+	}
+	return n
+}
+
+// acquire waits until estimate bytes plus headroom fit in the directory, or
+// maxWait passes.
+func (b *tempBudget) acquire(stage string, estimate, headroom int64, maxWait time.Duration) (*tempReservation, error) {
+	start := time.Now()
+	announced := false
+	for {
+		b.mu.Lock()
+		free, err := b.freeSpace(b.dir)
+		if err != nil {
+			// Without a measurement there is nothing to schedule on.
+			r := &tempReservation{budget: b, estimate: estimate}
+			b.held[r] = struct{}{}
+			b.mu.Unlock()
+			return r, nil
+		}
+		reserved := b.outstandingLocked()
+		if free-reserved-headroom >= estimate {
+			r := &tempReservation{budget: b, estimate: estimate}
+			b.held[r] = struct{}{}
+			b.mu.Unlock()
+			return r, nil
+		}
+		released := b.released
+		b.mu.Unlock()
+
+		waited := time.Since(start)
+		if waited >= maxWait {
+			return nil, &TempSpaceError{Dir: b.dir, Stage: stage, Needed: estimate + headroom, Free: free, Reserved: reserved, Waited: waited}
+		}
+		if !announced {
+			fmt.Printf("      ⏳ Waiting for temp space in %s for %s (%.1f MB needed, %.1f MB free)\n",
+				b.dir, stage, float64(estimate+headroom)/(1024*1024), float64(free-reserved)/(1024*1024))
+			announced = true
+		}
+		select {
+		case <-released:
+		case <-time.After(min(tempBudgetPoll, maxWait-waited)):
+		}
+	}
+}
+
+// reserveTemp reserves estimate bytes of the temp directory for stage.
+func (bm *BackupManager) reserveTemp(stage string, estimate int64) (*tempReservation, error) {
+	return tempBudgetFor(os.TempDir()).acquire(stage, estimate, bm.tempHeadroom, bm.tempMaxWait)
+}
+
+// glacierBufferEstimate estimates how much temp space buffering objectName
+// for Glacier takes: the size of the site's previous backup with a 10%
+// margin, or the uncompressed size when there is none.
+func (bm *BackupManager) glacierBufferEstimate(objectName string, uncompressedSize int64) int64 {
+	label, _, ok := parseBackupName(objectName)
+	if !ok {
+		return uncompressedSize
+	}
+	objs, err := bm.ListBackups(path.Dir(objectName)+"/", 0)
+	if err != nil {
+		return uncompressedSize
+	}
+	var prev *ObjectInfo
+	for i, o := range objs {
+		if l, _, ok := parseBackupName(o.Key); !ok || l != label || o.Key == objectName {
+			continue
+		}
+		if prev == nil || BackupTime(o).After(BackupTime(*prev)) {
+			prev = &objs[i]
+		}
+	}
+	if prev == nil || prev.Size <= 0 {
+		return uncompressedSize
+	}
+	return prev.Size + prev.Size/10
+}
+
+// prepareGlacier readies the Glacier copy of a dual upload: the AWS client
+// and the temp space its buffer needs. When the space cannot be had, the
+// copy is skipped (or the backup fails, if Glacier is required) before tar
+// writes anything to it.
+func (bm *BackupManager) prepareGlacier(objectName string, uncompressedSize int64) (*tempReservation, error) {
+	if err := bm.initAWSClient(); err != nil {
+		return nil, fmt.Errorf("failed to initialize AWS client: %w", err)
+	}
+	return bm.reserveTemp("Glacier buffer of "+objectName, bm.glacierBufferEstimate(objectName, uncompressedSize))
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBudget(t *testing.T, free int64) *tempBudget {
+	t.Helper()
+	return &tempBudget{
+		dir:       t.TempDir(),
+		held:      map[*tempReservation]struct{}{},
+		released:  make(chan struct{}),
+		freeSpace: func(string) (int64, error) { return free, nil },
+	}
+}
+
+func TestTempBudgetSerializesStages(t *testing.T) {
+	b := newTestBudget(t, 100)
+	first, err := b.acquire("first", 60, 10, 0)
+	if err != nil {
+		t.Fatalf("acquire(first) error = %v", err)
+	}
+
+	// 100 free - 60 reserved - 10 headroom leaves 30.
+	_, err = b.acquire("second", 40, 10, 0)
+	var spaceErr *TempSpaceError
+	if !errors.As(err, &spaceErr) || spaceErr.Reserved != 60 {
+		t.Fatalf("acquire(second) error = %v, want a TempSpaceError with 60 reserved", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		r, err := b.acquire("second", 40, 10, time.Minute)
+		r.release()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	first.release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("acquire(second) after release error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire(second) did not wake up on release")
+	}
+	first.release() // releasing twice is harmless
+}
+
+func TestTempBudgetCountsWrittenBytes(t *testing.T) {
+	b := newTestBudget(t, 100)
+	r, err := b.acquire("buffer", 80, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.release()
+	file := filepath.Join(b.dir, "glacier-upload.tmp")
+	if err := os.WriteFile(file, make([]byte, 50), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r.track(file)
+
+	// The measured free space already reflects the 50 bytes written, so only
+	// the remaining 30 count as reserved.
+	b.mu.Lock()
+	got := b.outstandingLocked()
+	b.mu.Unlock()
+	if got != 30 {
+		t.Errorf("outstanding = %d, want 30", got)
+	}
+}
+
+func TestTempBudgetTimesOut(t *testing.T) {
+	poll := tempBudgetPoll
+	tempBudgetPoll = 10 * time.Millisecond
+	defer func() { tempBudgetPoll = poll }()
+
+	b := newTestBudget(t, 100)
+	start := time.Now()
+	_, err := b.acquire("huge", 500, 0, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "huge") {
+		t.Errorf("acquire(huge) error = %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("acquire(huge) gave up after %s, want at least the max wait", waited)
+	}
+}
+
+func TestGlacierBufferEstimate(t *testing.T) {
+	bm, _ := newFileBackedManager(t)
+	if err := bm.initMinioClient(); err != nil {
+		t.Fatal(err)
+	}
+	putTestObject(t, bm, "backups/a.com/a.com-20240101-020000.tgz", strings.Repeat("x", 1000))
+	putTestObject(t, bm, "backups/a.com/a.com-20240102-020000.tgz", strings.Repeat("x", 2000))
+	putTestObject(t, bm, "backups/a.com/other.com-20240103-020000.tgz", strings.Repeat("x", 9000))
+
+	if got := bm.glacierBufferEstimate("backups/a.com/a.com-20240103-020000.tgz", 50000); got != 2200 {
+		t.Errorf("glacierBufferEstimate() = %d, want 2200 (previous backup plus 10%%)", got)
+	}
+	if got := bm.glacierBufferEstimate("backups/b.com/b.com-20240103-020000.tgz", 50000); got != 50000 {
+		t.Errorf("glacierBufferEstimate(first backup) = %d, want the uncompressed size", got)
+	}
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimezone is the canonical timezone unless --timezone says
+// otherwise.
+const DefaultTimezone = "UTC"
+
+// TimezoneLocal selects the timezone of the host the CLI runs on, the
+// behaviour before the canonical timezone existed.
+const TimezoneLocal = "local"
+
+var canonicalTimezone atomic.Pointer[time.Location]
+
+// SetTimezone sets the process-wide canonical timezone: an IANA name,
+// "UTC" or TimezoneLocal. Backup names are stamped, retention tiers decided
+// (weekday, day of month), backup windows read and times printed in it, so
+// hosts in different regions agree on all of them.
+func SetTimezone(name string) error {
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		return err
+	}
+	canonicalTimezone.Store(loc)
+	return nil
+}
+
+// LoadTimezone resolves a --timezone value; empty means DefaultTimezone.
+func LoadTimezone(name string) (*time.Location, error) {
+	switch {
+	case name == "":
+		return time.UTC, nil
+	case strings.EqualFold(name, TimezoneLocal):
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (use an IANA name such as Europe/Berlin, UTC or %s)", name, TimezoneLocal)
+	}
+	return loc, nil
+}
+
+// Timezone returns the canonical timezone set by SetTimezone, UTC by
+// default.
+func Timezone() *time.Location {
+	if loc := canonicalTimezone.Load(); loc != nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// InTimezone returns t in the canonical timezone.
+func InTimezone(t time.Time) time.Time {
+	return t.In(Timezone())
+}
+
+// FormatTime renders t for people: in the canonical timezone, named unless
+// layout already shows a zone, followed by the same time on this host's
+// clock when that differs, e.g. "2026-05-01 02:00 UTC (2026-04-30 22:00
+// EDT)".
+func FormatTime(t time.Time, layout string) string {
+	if !strings.Contains(layout, "MST") && !strings.Contains(layout, "Z07") && !strings.Contains(layout, "-07") {
+		layout += " MST"
+	}
+	canonical, local := t.In(Timezone()), t.In(time.Local)
+	s := canonical.Format(layout)
+	_, canonicalOffset := canonical.Zone()
+	if _, localOffset := local.Zone(); localOffset != canonicalOffset {
+		s += " (" + local.Format(layout) + ")"
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTimezone sets the canonical timezone for the duration of a test.
+func withTimezone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	if err := SetTimezone(name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { canonicalTimezone.Store(nil) })
+	return Timezone()
+}
+
+func TestSetTimezone(t *testing.T) {
+	if Timezone() != time.UTC {
+		t.Fatalf("default timezone = %s", Timezone())
+	}
+	if loc := withTimezone(t, "local"); loc != time.Local {
+		t.Errorf("local = %s", loc)
+	}
+	if loc := withTimezone(t, "Asia/Tokyo"); loc.String() != "Asia/Tokyo" {
+		t.Errorf("Asia/Tokyo = %s", loc)
+	}
+	if err := SetTimezone("Mars/Olympus"); err == nil {
+		t.Error("unknown timezone accepted")
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tokyo := withTimezone(t, "Asia/Tokyo")
+	at := time.Date(2026, 5, 1, 2, 0, 0, 0, time.UTC)
+	got := FormatTime(at, "2006-01-02 15:04")
+	if !strings.HasPrefix(got, "2026-05-01 11:00 JST") {
+		t.Errorf("FormatTime() = %q", got)
+	}
+	// The local clock is shown only when it reads differently.
+	local := at.In(time.Local)
+	if _, off := local.Zone(); off != 9*3600 {
+		if want := " (" + local.Format("2006-01-02 15:04 MST") + ")"; !strings.HasSuffix(got, want) {
+			t.Errorf("FormatTime() = %q, want local time %q", got, want)
+		}
+	}
+	if got := FormatTime(at.In(tokyo), time.RFC1123); strings.Count(got, "JST") != 1 {
+		t.Errorf("FormatTime(RFC1123) = %q", got)
+	}
+}
+
+func TestCanonicalTimezoneRetention(t *testing.T) {
+	withTimezone(t, "Asia/Tokyo")
+	// 16:00 UTC on Saturday is 01:00 on Sunday the 1st in Tokyo.
+	taken := time.Date(2026, 2, 28, 16, 0, 0, 0, time.UTC)
+	if got := RetentionTier(taken, 0, 1); got != RetentionTierMonthly {
+		t.Errorf("RetentionTier() = %s, want monthly", got)
+	}
+	if _, stamp, ok := parseBackupName("backups/a.com/a.com-20260301-010000.tgz"); !ok || !stamp.Equal(taken) {
+		t.Errorf("parseBackupName() = %v, %v", stamp, ok)
+	}
+	if id := NewRunID(taken); !strings.HasPrefix(id, "20260301-010000") {
+		t.Errorf("NewRunID() = %s", id)
+	}
+
+	w, err := NewBackupWindow([]string{"00:00-06:00"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocked, _ := w.Blocked(taken); !blocked {
+		t.Error("blackout not read in the canonical timezone")
+	}
+	if w.String() != "00:00-06:00 (Asia/Tokyo)" {
+		t.Errorf("window = %s", w)
+	}
+}
@@ -0,0 +1,71 @@
+package backup
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// buildTLSConfig assembles a tls.Config from an optional CA bundle, an
+// optional client certificate/key pair (mTLS) and the insecure-skip-verify
+// escape hatch. It returns nil when no TLS customisation was requested so
+// callers can keep the Go defaults. In FIPS mode the config is always
+// restricted to FIPS-approved algorithms and verification can't be skipped.
+func buildTLSConfig(label, caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	if insecure && currentCryptoPolicy().FIPS {
+		return nil, fmt.Errorf("%s insecure-skip-verify is not allowed in FIPS mode", label)
+	}
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return applyFIPSTLS(nil), nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s CA bundle %s: %w", label, caFile, err)
+		}
+		// Start from the system pool so public endpoints keep working when a
+		// private CA is only needed for some hops (e.g. an internal proxy).
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s CA bundle %s contains no valid PEM certificates", label, caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("%s client certificate and key must be provided together", label)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s client certificate: %w", label, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if insecure {
+		printInsecureTLSWarning(label)
+		cfg.InsecureSkipVerify = true
+	}
+
+	return applyFIPSTLS(cfg), nil
+}
+
+// printInsecureTLSWarning writes a prominent warning to stderr. Skipping
+// verification is a last resort and should never go unnoticed in cron logs.
+func printInsecureTLSWarning(label string) {
+	bar := strings.Repeat("!", 70)
+	fmt.Fprintln(os.Stderr, bar)
+	fmt.Fprintf(os.Stderr, "⚠️  WARNING: TLS certificate verification is DISABLED for %s\n", label)
+	fmt.Fprintln(os.Stderr, "   Connections are vulnerable to interception (MITM).")
+	fmt.Fprintln(os.Stderr, "   Provide a CA bundle instead of using insecure-skip-verify.")
+	fmt.Fprintln(os.Stderr, bar)
+}
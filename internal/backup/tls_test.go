@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedPair writes a throwaway self-signed certificate and key to dir.
+func writeSelfSignedPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ciwg-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSignedPair(t, dir)
+
+	badPEM := filepath.Join(dir, "bad.pem")
+	if err := os.WriteFile(badPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("failed to write bad pem: %v", err)
+	}
+
+	t.Run("no options keeps defaults", func(t *testing.T) {
+		cfg, err := buildTLSConfig("test", "", "", "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg != nil {
+			t.Errorf("expected nil config, got %+v", cfg)
+		}
+	})
+
+	t.Run("CA bundle", func(t *testing.T) {
+		cfg, err := buildTLSConfig("test", certPath, "", "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg == nil || cfg.RootCAs == nil {
+			t.Fatal("expected RootCAs to be set")
+		}
+		if cfg.InsecureSkipVerify {
+			t.Error("InsecureSkipVerify should be false")
+		}
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		if _, err := buildTLSConfig("test", badPEM, "", "", false); err == nil {
+			t.Error("expected error for bundle without certificates")
+		}
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		cfg, err := buildTLSConfig("test", "", certPath, keyPath, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Certificates) != 1 {
+			t.Errorf("expected 1 client certificate, got %d", len(cfg.Certificates))
+		}
+	})
+
+	t.Run("client certificate without key", func(t *testing.T) {
+		if _, err := buildTLSConfig("test", "", certPath, "", false); err == nil {
+			t.Error("expected error when key is missing")
+		}
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		cfg, err := buildTLSConfig("test", "", "", "", true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cfg.InsecureSkipVerify {
+			t.Error("expected InsecureSkipVerify to be true")
+		}
+	})
+}
@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrBackupWindowClosed is returned by CreateBackups when a run is refused or
+// paused because the current time falls inside a blackout range.
+var ErrBackupWindowClosed = errors.New("backup window closed")
+
+// Window actions for runs started inside a blackout range.
+const (
+	WindowActionAbort = "abort"
+	WindowActionWait  = "wait"
+)
+
+// TimeRange is a daily time-of-day range in minutes since midnight. End before
+// Start wraps past midnight (e.g. 22:00-06:00).
+type TimeRange struct {
+	Start int
+	End   int
+}
+
+// ParseTimeRange parses "HH:MM-HH:MM".
+func ParseTimeRange(s string) (TimeRange, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return TimeRange{}, fmt.Errorf("invalid time range %q (use HH:MM-HH:MM)", s)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: %w", s, err)
+	}
+	if start == end {
+		return TimeRange{}, fmt.Errorf("invalid time range %q: start and end are equal", s)
+	}
+	return TimeRange{Start: start, End: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (r TimeRange) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", r.Start/60, r.Start%60, r.End/60, r.End%60)
+}
+
+// contains reports whether minute-of-day m falls inside the range.
+func (r TimeRange) contains(m int) bool {
+	if r.Start < r.End {
+		return m >= r.Start && m < r.End
+	}
+	return m >= r.Start || m < r.End
+}
+
+// BackupWindow restricts when backups may run. Backups are not started (and
+// running backups pause between containers) inside any blackout range.
+type BackupWindow struct {
+	Blackouts []TimeRange
+	Location  *time.Location
+}
+
+// Blocked reports whether t falls inside a blackout range and, if so, when
+// that blackout ends.
+func (w *BackupWindow) Blocked(t time.Time) (bool, time.Time) {
+	if w == nil || len(w.Blackouts) == 0 {
+		return false, time.Time{}
+	}
+	loc := w.Location
+	if loc == nil {
+		loc = Timezone()
+	}
+	t = t.In(loc)
+	m := t.Hour()*60 + t.Minute()
+	for _, r := range w.Blackouts {
+		if !r.contains(m) {
+			continue
+		}
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		until := midnight.Add(time.Duration(r.End) * time.Minute)
+		if !until.After(t) {
+			until = until.AddDate(0, 0, 1)
+		}
+		return true, until
+	}
+	return false, time.Time{}
+}
+
+func (w *BackupWindow) String() string {
+	if w == nil || len(w.Blackouts) == 0 {
+		return "none"
+	}
+	ranges := make([]string, len(w.Blackouts))
+	for i, r := range w.Blackouts {
+		ranges[i] = r.String()
+	}
+	loc := Timezone().String()
+	if w.Location != nil {
+		loc = w.Location.String()
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(ranges, ", "), loc)
+}
+
+// NewBackupWindow builds a window from "HH:MM-HH:MM" blackout strings and an
+// optional IANA timezone (empty means the canonical timezone, see
+// SetTimezone).
+func NewBackupWindow(blackouts []string, timezone string) (*BackupWindow, error) {
+	w := &BackupWindow{}
+	for _, b := range blackouts {
+		if strings.TrimSpace(b) == "" {
+			continue
+		}
+		r, err := ParseTimeRange(b)
+		if err != nil {
+			return nil, err
+		}
+		w.Blackouts = append(w.Blackouts, r)
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		w.Location = loc
+	}
+	return w, nil
+}
+
+// backupWindowsFile is the on-disk layout of the backup windows file. The
+// first entry whose host patterns match wins.
+type backupWindowsFile struct {
+	Windows []struct {
+		Hosts    []string `yaml:"hosts"`
+		Blackout []string `yaml:"blackout"`
+		Timezone string   `yaml:"timezone"`
+	} `yaml:"windows"`
+}
+
+// DefaultBackupWindowsPath returns the default location of the backup windows
+// file (~/.ciwg/backup-windows.yaml).
+func DefaultBackupWindowsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ciwg", "backup-windows.yaml")
+}
+
+// LoadBackupWindow returns the window configured for host in the YAML file at
+// path, or nil when the file is missing or no entry matches. Host patterns use
+// shell globs (e.g. "wp*.example.com", "*").
+func LoadBackupWindow(filePath, host string) (*BackupWindow, error) {
+	if filePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup windows file: %w", err)
+	}
+	var file backupWindowsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse backup windows file: %w", err)
+	}
+	for _, entry := range file.Windows {
+		for _, pattern := range entry.Hosts {
+			if ok, _ := path.Match(pattern, host); ok {
+				return NewBackupWindow(entry.Blackout, entry.Timezone)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ResumeToken records the containers left over when a run paused at the end
+// of its window, so the next invocation for the same host picks up there.
+type ResumeToken struct {
+	RunID     string    `json:"run_id"`
+	Host      string    `json:"host"`
+	PausedAt  time.Time `json:"paused_at"`
+	Remaining []string  `json:"remaining"`
+}
+
+// DefaultResumeTokenPath returns where the resume token for host is kept
+// (~/.ciwg/resume/<host>.json).
+func DefaultResumeTokenPath(host string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	name := strings.NewReplacer("/", "_", "@", "_", ":", "_").Replace(host)
+	return filepath.Join(home, ".ciwg", "resume", name+".json")
+}
+
+// LoadResumeToken reads a resume token; a missing file returns nil, nil.
+func LoadResumeToken(filePath string) (*ResumeToken, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read resume token: %w", err)
+	}
+	var tok ResumeToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, fmt.Errorf("failed to parse resume token %s: %w", filePath, err)
+	}
+	return &tok, nil
+}
+
+// Save writes the token, creating the parent directory if needed.
+func (t *ResumeToken) Save(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create resume token directory: %w", err)
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write resume token: %w", err)
+	}
+	return nil
+}
+
+// applyResumeToken narrows containers to those the token still lists. It
+// returns the containers unchanged when none of them are in the token, e.g.
+// because the container selection changed since the run paused.
+func applyResumeToken(containers []ContainerInfo, tok *ResumeToken) []ContainerInfo {
+	if tok == nil || len(tok.Remaining) == 0 {
+		return containers
+	}
+	remaining := make(map[string]bool, len(tok.Remaining))
+	for _, name := range tok.Remaining {
+		remaining[name] = true
+	}
+	var out []ContainerInfo
+	for _, c := range containers {
+		if remaining[c.Name] {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return containers
+	}
+	return out
+}
+
+// checkBackupWindow enforces the window before a run starts: it returns
+// ErrBackupWindowClosed for the abort action, or sleeps until the blackout
+// ends for the wait action.
+func checkBackupWindow(options *BackupOptions) error {
+	blocked, until := options.Window.Blocked(time.Now())
+	if !blocked {
+		return nil
+	}
+	if options.WindowAction != WindowActionWait {
+		return fmt.Errorf("%w: blackout %s is in effect until %s", ErrBackupWindowClosed, options.Window, FormatTime(until, "15:04 MST"))
+	}
+	wait := time.Until(until)
+	fmt.Printf("⏸  Inside backup blackout %s; waiting %s until %s...\n", options.Window, wait.Round(time.Minute), FormatTime(until, "15:04 MST"))
+	if options.DryRun {
+		fmt.Println("[DRY RUN] Not waiting")
+		return nil
+	}
+	time.Sleep(wait)
+	return nil
+}
@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupWindowBlocked(t *testing.T) {
+	w, err := NewBackupWindow([]string{"08:00-20:00", "23:30-01:00"}, "UTC")
+	if err != nil {
+		t.Fatalf("NewBackupWindow() error = %v", err)
+	}
+	day := func(h, m int) time.Time { return time.Date(2025, 3, 10, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		blocked bool
+		until   time.Time
+	}{
+		{"before business hours", day(7, 59), false, time.Time{}},
+		{"start of blackout", day(8, 0), true, day(20, 0)},
+		{"afternoon", day(15, 30), true, day(20, 0)},
+		{"end is exclusive", day(20, 0), false, time.Time{}},
+		{"wraps midnight before", day(23, 45), true, day(25, 0)},
+		{"wraps midnight after", day(0, 30), true, day(1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocked, until := w.Blocked(tt.at)
+			if blocked != tt.blocked || !until.Equal(tt.until) {
+				t.Errorf("Blocked(%s) = %v, %s; want %v, %s", tt.at.Format("15:04"), blocked, until, tt.blocked, tt.until)
+			}
+		})
+	}
+
+	var none *BackupWindow
+	if blocked, _ := none.Blocked(day(12, 0)); blocked {
+		t.Error("nil window should never block")
+	}
+}
+
+func TestParseTimeRangeErrors(t *testing.T) {
+	for _, in := range []string{"08:00", "8am-8pm", "08:00-08:00", "25:00-01:00"} {
+		if _, err := ParseTimeRange(in); err == nil {
+			t.Errorf("ParseTimeRange(%q) expected error", in)
+		}
+	}
+}
+
+func TestLoadBackupWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "windows.yaml")
+	content := `windows:
+  - hosts: ["wp1*.example.com"]
+    blackout: ["08:00-20:00"]
+    timezone: America/New_York
+  - hosts: ["*"]
+    blackout: []
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := LoadBackupWindow(path, "wp12.example.com")
+	if err != nil {
+		t.Fatalf("LoadBackupWindow() error = %v", err)
+	}
+	if w == nil || len(w.Blackouts) != 1 || w.Location.String() != "America/New_York" {
+		t.Fatalf("LoadBackupWindow() = %+v", w)
+	}
+
+	w, err = LoadBackupWindow(path, "wp2.example.com")
+	if err != nil || w == nil || len(w.Blackouts) != 0 {
+		t.Errorf("catch-all entry: got %+v, %v", w, err)
+	}
+
+	if w, err := LoadBackupWindow(filepath.Join(t.TempDir(), "missing.yaml"), "any"); w != nil || err != nil {
+		t.Errorf("missing file: got %+v, %v", w, err)
+	}
+}
+
+func TestResumeToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume", "host.json")
+	tok := &ResumeToken{RunID: "r1", Remaining: []string{"wp_b", "wp_c"}}
+	if err := tok.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadResumeToken(path)
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadResumeToken() = %v, %v", loaded, err)
+	}
+
+	containers := []ContainerInfo{{Name: "wp_a"}, {Name: "wp_b"}, {Name: "wp_c"}}
+	got := applyResumeToken(containers, loaded)
+	if len(got) != 2 || got[0].Name != "wp_b" || got[1].Name != "wp_c" {
+		t.Errorf("applyResumeToken() = %+v", got)
+	}
+
+	stale := &ResumeToken{Remaining: []string{"gone"}}
+	if got := applyResumeToken(containers, stale); len(got) != 3 {
+		t.Errorf("token with no matching containers should be ignored, got %+v", got)
+	}
+}
+
+func TestCheckBackupWindowAbort(t *testing.T) {
+	w := &BackupWindow{Blackouts: []TimeRange{{Start: 0, End: 24*60 - 1}, {Start: 24*60 - 1, End: 0}}}
+	err := checkBackupWindow(&BackupOptions{Window: w, WindowAction: WindowActionAbort})
+	if !errors.Is(err, ErrBackupWindowClosed) {
+		t.Errorf("checkBackupWindow() error = %v, want ErrBackupWindowClosed", err)
+	}
+	if err := checkBackupWindow(&BackupOptions{}); err != nil {
+		t.Errorf("checkBackupWindow() without window error = %v", err)
+	}
+}
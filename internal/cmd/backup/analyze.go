@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupAnalyze(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	top := mustGetIntFlag(cmd, "top")
+	if top < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+
+	// A key names one object of a backup; a bare site name picks the site's
+	// newest complete backup. Either way every part of the set is analyzed.
+	target := args[0]
+	var keys []string
+	if strings.Contains(target, "/") {
+		keys, err = bm.ExpandToBackupSets([]string{target})
+		if err != nil {
+			return err
+		}
+	} else {
+		set, err := bm.LatestBackupSet(fmt.Sprintf("backups/%s/", target))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Resolved latest backup: %s\n", set.Stem)
+		keys = set.Keys()
+	}
+
+	res, err := bm.AnalyzeBackup(keys, top)
+	if err != nil {
+		return err
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal analysis to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+		return nil
+	}
+
+	mb := func(n int64) float64 { return float64(n) / (1024 * 1024) }
+	fmt.Printf("\nBackup: %s\n", strings.Join(res.Objects, ", "))
+	fmt.Printf("Files:  %d (%.2f MB uncompressed, %.2f MB stored)\n", res.Files, mb(res.TotalBytes), mb(res.CompressedBytes))
+	if res.Root != "" {
+		fmt.Printf("Root:   %s\n", res.Root)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	shares := func(title string, rows []backup.SizeShare) {
+		fmt.Fprintf(w, "\n%s\tMB\tSHARE\tFILES\n", title)
+		for _, s := range rows {
+			fmt.Fprintf(w, "%s\t%.2f\t%.1f%%\t%d\n", s.Name, mb(s.Bytes), s.Percent, s.Files)
+		}
+	}
+	shares("DIRECTORY", res.Directories)
+	shares("TYPE", res.Types)
+	if len(res.Largest) > 0 {
+		fmt.Fprintf(w, "\nLARGEST FILES\tMB\t\t\n")
+		for _, f := range res.Largest {
+			fmt.Fprintf(w, "%s\t%.2f\t\t\n", f.Path, mb(f.Bytes))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupAWSAudit(cmd *cobra.Command, args []string) error {
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+
+	inventoryPath := mustGetStringFlag(cmd, "inventory")
+	jobID := mustGetStringFlag(cmd, "job-id")
+	initiate := mustGetBoolFlag(cmd, "initiate")
+	sources := 0
+	for _, set := range []bool{inventoryPath != "", jobID != "", initiate} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("specify exactly one of --inventory, --job-id or --initiate")
+	}
+
+	var inv *backup.GlacierInventory
+	if inventoryPath != "" {
+		f, err := os.Open(inventoryPath)
+		if err != nil {
+			return fmt.Errorf("failed to open inventory: %w", err)
+		}
+		inv, err = backup.ReadGlacierInventory(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else {
+		awsConfig, err := getAWSConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if awsConfig == nil {
+			return fmt.Errorf("AWS Glacier vault not configured (set AWS_VAULT environment variable or --aws-vault flag)")
+		}
+		bm := backup.NewBackupManagerWithAWS(nil, nil, awsConfig)
+		if initiate {
+			id, err := bm.InitiateGlacierInventory()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✓ Started inventory job for vault %s\n", awsConfig.Vault)
+			fmt.Printf("  Job ID: %s\n", id)
+			fmt.Println("  Glacier usually completes inventory jobs in 3-5 hours; then run:")
+			fmt.Printf("  ciwg-cli backup aws-audit --job-id %s\n", id)
+			return nil
+		}
+		if inv, err = bm.FetchGlacierInventory(jobID); err != nil {
+			return err
+		}
+	}
+
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	records, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	ledger := backup.GlacierLedger(records)
+	report := backup.AuditGlacierInventory(inv, ledger)
+
+	if !mustGetBoolFlag(cmd, "no-backfill") && len(report.UnknownArchives) > 0 {
+		adopted, _, err := backup.BackfillGlacierLedger(historyPath, report)
+		if err != nil {
+			return fmt.Errorf("ledger backfill failed: %w", err)
+		}
+		if adopted != nil {
+			fmt.Fprintf(os.Stderr, "📥 Backfilled %d legacy archive(s) into %s as run %s\n", len(adopted.Uploads), historyPath, adopted.ID)
+			if records, err = backup.LoadRunRecords(historyPath); err != nil {
+				return err
+			}
+			ledger = backup.GlacierLedger(records)
+			report = backup.AuditGlacierInventory(inv, ledger)
+		}
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit report to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		printGlacierAudit(inv, len(ledger), report)
+	}
+
+	if mustGetBoolFlag(cmd, "repair") && !report.Clean() {
+		adopted, err := backup.RepairGlacierLedger(historyPath, report)
+		if err != nil {
+			return fmt.Errorf("ledger repair failed: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "\n🔧 Repaired ledger %s: dropped %d missing, corrected %d mismatched",
+			historyPath, len(report.MissingArchives), len(report.SizeMismatches)+len(report.HashMismatches))
+		if adopted != nil {
+			fmt.Fprintf(os.Stderr, ", adopted %d unknown archive(s) as run %s", len(adopted.Uploads), adopted.ID)
+		}
+		fmt.Fprintln(os.Stderr)
+		return nil
+	}
+	if !report.Clean() {
+		return fmt.Errorf("vault and ledger disagree (re-run with --repair to fix the ledger)")
+	}
+	return nil
+}
+
+func printGlacierAudit(inv *backup.GlacierInventory, ledgerSize int, report *backup.GlacierAuditReport) {
+	fmt.Println("===========================================")
+	fmt.Println("Glacier Vault Audit")
+	fmt.Println("===========================================")
+	fmt.Printf("Vault:          %s\n", inv.VaultARN)
+	fmt.Printf("Inventory date: %s\n", backup.FormatTime(inv.InventoryDate, "2006-01-02 15:04:05 MST"))
+	fmt.Printf("Vault archives: %d\n", len(inv.ArchiveList))
+	fmt.Printf("Ledger entries: %d\n", ledgerSize)
+	fmt.Println("===========================================")
+	fmt.Printf("✓ Matched:               %d\n", report.Matched)
+	fmt.Printf("? Unknown to ledger:     %d\n", len(report.UnknownArchives))
+	fmt.Printf("✗ Missing from vault:    %d\n", len(report.MissingArchives))
+	fmt.Printf("≠ Size mismatches:       %d\n", len(report.SizeMismatches))
+	fmt.Printf("≠ Tree hash mismatches:  %d\n", len(report.HashMismatches))
+	if len(report.TooRecent) > 0 {
+		fmt.Printf("… Newer than inventory:  %d (checked by the next inventory)\n", len(report.TooRecent))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(report.UnknownArchives) > 0 {
+		fmt.Fprintln(w, "\nUNKNOWN ARCHIVE\tCREATED\tSIZE MB\tDESCRIPTION")
+		for _, a := range report.UnknownArchives {
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", shortArchiveID(a.ArchiveID), backup.InTimezone(a.CreationDate).Format("2006-01-02"), float64(a.Size)/(1024*1024), a.ArchiveDescription)
+		}
+	}
+	if len(report.MissingArchives) > 0 {
+		fmt.Fprintln(w, "\nMISSING ARCHIVE\tRUN\tSIZE MB\tOBJECT")
+		for _, e := range report.MissingArchives {
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", shortArchiveID(e.ArchiveID), e.RunID, float64(e.Bytes)/(1024*1024), e.ObjectKey)
+		}
+	}
+	mismatches := append(append([]backup.GlacierMismatch{}, report.SizeMismatches...), report.HashMismatches...)
+	if len(mismatches) > 0 {
+		fmt.Fprintln(w, "\nMISMATCHED ARCHIVE\tLEDGER BYTES\tVAULT BYTES\tOBJECT")
+		for _, m := range mismatches {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", shortArchiveID(m.Archive.ArchiveID), m.Entry.Bytes, m.Archive.Size, m.Entry.ObjectKey)
+		}
+	}
+	w.Flush()
+}
+
+func shortArchiveID(id string) string {
+	if len(id) > 20 {
+		return id[:20] + "…"
+	}
+	return id
+}
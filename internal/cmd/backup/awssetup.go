@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// awsSetup returns the manager and options of the aws-setup commands.
+func awsSetup(cmd *cobra.Command) (*backup.BackupManager, backup.GlacierSetupOptions, error) {
+	opts := backup.GlacierSetupOptions{
+		SNSTopic:          mustGetStringFlag(cmd, "sns-topic"),
+		RetrievalStrategy: mustGetStringFlag(cmd, "retrieval-strategy"),
+		BytesPerHour:      mustGetInt64Flag(cmd, "bytes-per-hour"),
+	}
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return nil, opts, fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+	if err := backup.ValidateRetrievalStrategy(opts.RetrievalStrategy, opts.BytesPerHour); err != nil {
+		return nil, opts, fmt.Errorf("invalid --retrieval-strategy: %w", err)
+	}
+	awsConfig, err := getAWSConfig(cmd)
+	if err != nil {
+		return nil, opts, err
+	}
+	if awsConfig == nil {
+		return nil, opts, fmt.Errorf("AWS Glacier vault not configured (set AWS_VAULT environment variable or --aws-vault flag)")
+	}
+	return backup.NewBackupManagerWithAWS(nil, nil, awsConfig), opts, nil
+}
+
+func runBackupAWSSetupVerify(cmd *cobra.Command, args []string) error {
+	bm, opts, err := awsSetup(cmd)
+	if err != nil {
+		return err
+	}
+	rep, err := bm.VerifyGlacierSetup(opts)
+	if err != nil {
+		return err
+	}
+	return printGlacierSetup(cmd, rep)
+}
+
+func runBackupAWSSetupApply(cmd *cobra.Command, args []string) error {
+	bm, opts, err := awsSetup(cmd)
+	if err != nil {
+		return err
+	}
+	opts.DryRun = mustGetBoolFlag(cmd, "dry-run")
+	bm.SetDryRun(opts.DryRun)
+	rep, err := bm.ApplyGlacierSetup(opts)
+	if err != nil || rep == nil {
+		return err
+	}
+	fmt.Println()
+	return printGlacierSetup(cmd, rep)
+}
+
+func printGlacierSetup(cmd *cobra.Command, rep *backup.GlacierSetupReport) error {
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal setup report to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		fmt.Printf("Glacier setup of vault %s\n\n", rep.Vault)
+		for _, c := range rep.Checks {
+			mark := "✓"
+			switch c.Status {
+			case backup.SetupWarning:
+				mark = "⚠️ "
+			case backup.SetupMissing, backup.SetupDenied, backup.SetupError:
+				mark = "❌"
+			}
+			name := c.Name
+			if c.Action != "" {
+				name = c.Action
+			}
+			fmt.Printf("%s %-34s %s\n", mark, name, c.Detail)
+			if c.Status == backup.SetupDenied && c.UsedBy != "" {
+				fmt.Printf("   needed by: %s\n", c.UsedBy)
+			}
+			if c.Fix != "" && c.Status != backup.SetupDenied {
+				fmt.Printf("   fix: %s\n", c.Fix)
+			}
+		}
+		if policy := rep.PolicyStatement(); policy != "" {
+			fmt.Printf("\nThe AWS keys lack %d permission(s). Grant them with a policy such as:\n%s\n", len(rep.Denied), policy)
+		}
+	}
+	if !rep.OK() {
+		return fmt.Errorf("the Glacier setup is incomplete")
+	}
+	return nil
+}
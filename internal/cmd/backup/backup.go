@@ -15,7 +15,112 @@ import (
 var BackupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Backup management for WordPress containers",
-	Long:  `Create and manage backups of WordPress containers, streaming them to Minio storage.`,
+	Long: `Create and manage backups of WordPress containers, streaming them to Minio storage.
+
+--minio-endpoint takes host[:port], an IP address (IPv6 in brackets when a port
+follows, e.g. [2001:db8::1]:9000) or a URL such as https://minio.example.com. A
+URL scheme overrides --minio-ssl; without a port, 443 or 80 is used.
+
+Smaller deployments can skip object storage entirely by pointing --minio-endpoint
+(or MINIO_ENDPOINT) at a directory, e.g. file:///mnt/nas/backups. Backups are then
+written as plain files mirroring the object layout, and every command that lists,
+reads, prunes or deletes backups works against that tree. Use 'backup sync' to
+push the tree into Minio once it becomes available.
+
+A profile can restrict what it may do, e.g. for operators who should list, read
+and verify backups but never delete, migrate or prune them:
+
+  permissions:
+    read_only: true            # or deny: [delete, migrate, prune]
+
+Operations that can be denied: create, delete, prune, migrate, restore, sync and
+maintenance. --read-only (or BACKUP_READ_ONLY=true) denies all of them whatever
+the profile says. Dry runs are always allowed: every command that changes
+backups has --dry-run, and a dry run never uploads, copies or deletes an object
+or archive. Under --read-only or a read-only profile such commands run as dry
+runs instead of being refused; partial denials still refuse.
+
+No command deletes a backup object younger than --delete-hold (48h by default,
+env: BACKUP_DELETE_HOLD), whatever the retention policy, prune plan, garbage
+collection or migration asks for: prune, delete, gc, migrate --delete-after,
+staging and pending-delete retries keep such objects and report them. The
+window can be raised but not lowered; only --allow-young-deletes, given on the
+command line, switches it off.
+
+Shell completion scripts are generated with 'ciwg-cli completion bash|zsh|fish'.
+Object arguments and --prefix values complete against the bucket one directory
+at a time (e.g. 'backup read backups/<TAB>'), offering the newest backups first;
+listings are cached in ~/.ciwg/completion-cache for 30 seconds.
+
+Every command starts with a quick recovery scan that cleans up after crashed
+runs: Glacier buffers and other temp files older than --recovery-temp-age
+(default 24h), partially written state files in ~/.ciwg older than an hour,
+resume tokens older than a week, stale completion cache entries and a
+BACKUP_STATUS_SOCKET nobody listens on. What it removed is reported on stderr;
+--no-recovery-scan (or BACKUP_NO_RECOVERY_SCAN=true) skips it.
+
+--audit-commands (or BACKUP_AUDIT_COMMANDS=true) keeps an audit trail of every
+shell command run on a host: create, restore, discover, capacity, monitor and
+retry-pending append each one to ~/.ciwg/backup-command-audit.jsonl (or
+--command-audit-file) with its host, duration, exit status and the first
+--audit-output-limit bytes of its output, tagged with the run ID that
+'backup create' records in the run history. Database passwords on a command
+line are masked. --trace-commands prints each command as it finishes.
+
+--timezone (or BACKUP_TIMEZONE, default UTC) is the canonical timezone of the
+fleet: backup names and run IDs are stamped in it, smart retention picks weekly
+and monthly backups by its weekday and day of month, blackout windows without a
+timezone of their own are read in it and times are printed in it, followed by
+this machine's local time when that differs, e.g. "2026-05-01 02:00 UTC
+(2026-04-30 22:00 EDT)". Use an IANA name such as America/New_York, or "local"
+for this machine's timezone. Backups named before the switch in another zone
+are read as if they were stamped in the canonical one.
+
+--low-memory (or BACKUP_LOW_MEMORY=true) is for hosts with around 1 GB of RAM
+that run out of memory during backups. Minio uploads go in 16 MiB parts one
+at a time, whatever --minio-part-size and --minio-upload-concurrency say (a
+streamed backup is then limited to about 156 GiB), listings run in one pass
+without --list-parallelism or --list-cache-ttl, capacity estimates scan one
+container at a time, Glacier re-compression uses a small buffer and one zstd
+thread, and the Go heap is held to a 256 MiB soft limit unless GOMEMLIMIT is
+set. Expect slower uploads and listings in exchange. Backups still stream
+through this process, as there is no agent on the host to upload from; when
+the host cannot spare even that, run the command from another machine.
+
+--checksum selects the algorithm of the checksums the tool computes itself:
+sha256 (default), sha512 or blake3 (fastest). It is used to verify sync and
+staging copies, for export bundle checksum files and by post-upload checks,
+whose checksum is recorded in the run history. Glacier tree hashes are always
+SHA-256, as AWS requires. --fips (or BACKUP_FIPS=true) allows only
+FIPS-approved checksums (sha256, sha512), restricts Minio and AWS connections
+to TLS 1.2+ with AES-GCM suites and P-256/P-384 curves and refuses
+insecure-skip-verify. Run with GODEBUG=fips140=on so the Go runtime also uses
+its validated FIPS 140-3 module; --fips warns when it does not.
+
+--freshness-metrics-file and --pushgateway-url export one gauge series per
+site on every list, create and monitor run: seconds since the site's last
+complete backup (ciwg_backup_site_last_success_age_seconds), its Unix time
+and the size of that backup (ciwg_backup_site_latest_size_bytes), so alerting
+rules can be written per site rather than per job. Pushes replace the
+ciwg_backup_freshness job's group for the bucket.
+
+Exit codes are the same for every backup command, so cron jobs and wrappers
+can branch on them:
+
+  0  success
+  1  failure not covered below
+  2  partial failure: some sites, hosts or objects failed, the rest succeeded
+  3  configuration error: invalid flags, arguments, profile or credentials
+  4  connectivity error: SSH, Minio, Glacier or a database unreachable or
+     timed out
+  5  capacity abort: storage above --capacity-threshold, or a disk full
+  6  verification failure: 'backup verify', inventory verification, a
+     recovery drill or a Glacier checksum failed
+  7  refused by a safety guard: a permission gate, --delete-hold or a
+     retrieval budget cap
+
+A run paused by its backup window exits 0; the resume token continues it.`,
+	PersistentPreRunE: preRunBackup,
 }
 
 var backupCreateCmd = &cobra.Command{
@@ -23,15 +128,38 @@ var backupCreateCmd = &cobra.Command{
 	Short: "Create backups of WordPress containers",
 	Long: `Create backups of WordPress containers and stream them to Minio storage.
 
-Dry-run mode supports three compression estimation methods:
+Without --container-name/--container-file, sites are discovered from docker
+compose project labels: every project running a WordPress container is backed
+up once from its working directory, whatever its containers are named, and
+companion services (redis, cron, database) are grouped with it. Use
+--discovery prefix to select containers by the legacy wp_ name prefix instead.
+
+Hosts that expose only the Docker API (TLS on 2376) and no SSH are reached with
+--docker: discovery, database exports and the tar streams run in a short-lived
+helper container (--docker-helper-image) started over the API with the host's
+filesystem mounted, so no shell access is needed. Certificates are read from
+--docker-cert-path; --docker-context uses a docker context instead.
+
+Dry-run mode supports four compression estimation methods:
   - heuristic: Instant estimation based on file types (~80% accurate)
   - sample: Compress a sample and extrapolate (~90% accurate, uses --sample-size)
+  - adaptive: Compress segments spread over the whole archive (beginning, middle,
+    end and in between), doubling their number until the 95% confidence interval
+    is within --sample-target or --max-sample-size bytes were compressed, and
+    report the confidence reached. Suits sites with mixed content, where the
+    first --sample-size bytes aren't representative.
   - accurate: Full compression simulation (100% accurate, same speed as real backup)
 
 Examples:
   # Standard backup
   ciwg-cli backup create wp0.example.com
 
+  # Back up the hosts of an Ansible inventory group
+  ciwg-cli backup create --inventory ansible.yml --group wp_servers
+
+  # Backup through the host's Docker API instead of SSH
+  ciwg-cli backup create wp3.example.com --docker --docker-cert-path ~/.docker/wp3
+
   # Dry-run with instant estimation
   ciwg-cli backup create wp0.example.com --dry-run --estimate-method heuristic
 
@@ -42,7 +170,256 @@ Examples:
   ciwg-cli backup create wp0.example.com --dry-run --estimate-method accurate
 
   # Dry-run with larger sample size (200MB)
-  ciwg-cli backup create wp0.example.com --dry-run --estimate-method sample --sample-size 209715200`,
+  ciwg-cli backup create wp0.example.com --dry-run --estimate-method sample --sample-size 209715200
+
+  # Dry-run sampling until the estimate is within ±2% (up to 2GB per site)
+  ciwg-cli backup create wp0.example.com --dry-run --estimate-method adaptive --sample-target 0.02 --max-sample-size 2147483648
+
+  # Never back up during business hours; a run that reaches 08:00 pauses after the
+  # current container and the next invocation resumes with the remaining ones
+  ciwg-cli backup create wp0.example.com --blackout 08:00-20:00 --window-timezone America/New_York
+
+  # Leave out media that an offload plugin already keeps in S3
+  ciwg-cli backup create wp0.example.com --skip-offloaded-uploads
+
+  # Read each tarball back after upload to catch truncated uploads early
+  ciwg-cli backup create wp0.example.com --post-upload-check quick
+
+  # Fast gzip for Minio restores, dense zstd for the Glacier copy
+  ciwg-cli backup create wp0.example.com --include-aws-glacier --minio-compression gzip-1 --glacier-compression zstd-19
+
+  # Compare archive orders without uploading anything
+  ciwg-cli backup create wp0.example.com --dry-run --estimate-method accurate --archive-order smart
+
+  # Count a backup as done when either destination has it; retry the other later
+  ciwg-cli backup create wp0.example.com --include-aws-glacier --require any
+
+  # Back up and prune only the gold-tier sites of the fleet file
+  ciwg-cli backup create --group tier=gold --prune
+
+  # Prune from a stored plan that can be resumed and audited afterwards
+  ciwg-cli backup create wp0.example.com --prune --prune-plan
+
+Sites running a media offload plugin (WP Offload Media, Media Cloud, WP-Stateless)
+are detected from their active plugins and options; the bucket the media lives in
+is recorded in the backup manifest. With --skip-offloaded-uploads their
+wp-content/uploads is left out of the tarball, and the manifest says where to
+restore it from.
+
+Sites deployed from git have the commit, branch and whether tracked files had
+uncommitted changes recorded in the manifest and the Ciwg-Git-* object metadata,
+so a restore can be matched with the deployed code. --exclude-git leaves their
+.git directory out of the tarball.
+
+A hung command (a wedged wp-cli, a database that stopped answering) would
+otherwise stall the whole run. --export-timeout, --tar-timeout,
+--upload-timeout and --container-timeout abort a container that runs past
+them: its commands are killed on the host (and the export inside the
+container), an unfinished multipart upload is aborted and a partial object
+removed, a freeze is switched off and the backup manifest cleaned up. The
+container is marked failed with code timeout and the run goes on with the
+next. Tar and the upload are one stream, so whichever of their timeouts
+comes first stops both. Commands run under timeout(1) on the host.
+
+Earlier backups and full-site exports left inside a site directory would be
+archived again by every run. Archives (tarballs, zips, .wpress, .jpa and the
+like) of at least --nested-archive-threshold (default 100MB) are left out of
+the tarball with a notice, recorded with the upload in the run history and
+validation report, and listed at the end of the run so they can be removed at
+the source. --include-nested-archives keeps them in the backup but still lists
+them. A configured database.export_path is never treated as one.
+
+A database exported while editors are writing may not match the uploads
+archived with it. --export-freeze maintenance switches on wp-cli's maintenance
+mode for the length of the export, and --export-freeze plugin:<slug> activates
+a read-only plugin instead, which keeps the front end up. The freeze is
+switched off again whether the export succeeds or fails; a site that was
+already frozen is left as it is. A container's export_freeze config overrides
+the flag.
+
+Databases of custom containers that take hours to dump can be copied at the
+file level instead, with database.consistent_copy in the --config-file:
+mode pause flushes the database, freezes its container with docker pause while
+a second rsync pass (or a reflink copy) of data_dir runs, then unpauses it;
+the copy is crash-consistent and recovers like a snapshot. mode backup-mode
+(PostgreSQL 15+) keeps the database online between pg_backup_start and
+pg_backup_stop and writes the backup_label and WAL into the copy. timeout
+(default 60s) bounds how long the database stays paused or in backup mode: the
+copy fails rather than exceed it, and a watchdog unpauses the container even
+if the run is killed. The live data_dir is left out of the tarball.
+
+With --include-aws-glacier the same stream normally goes to both destinations.
+--glacier-compression gives the Glacier copy its own codec and level: the Minio
+stream is decoded and re-encoded through a bounded pipe on the way to Glacier,
+so a slow level such as zstd-19 also paces the Minio upload. zstd archives are
+described as <key>.tar.zst in the vault. Minio backups stay gzip (only the
+level and +rsyncable can be set with --minio-compression) because read and restore open
+them as .tgz. Both can be set per profile under compression: {minio, glacier}.
+
+When the Minio data directory is replicated onto deduplicating storage (ZFS
+dedup, borg, rsync), plain gzip defeats it: one changed file shifts every
+compressed block after it. --minio-compression gzip+rsyncable (or
+gzip-6+rsyncable) runs gzip --rsyncable, which restarts compression at
+content-defined boundaries so a small site change leaves most of the
+compressed backup identical to the last one, for about 1% more space. It needs
+GNU gzip 1.7+ (or pigz installed as gzip) on the host; Glacier copies are
+never rsyncable.
+
+--require decides which destinations of such a dual upload must succeed: minio
+(the default), glacier, both or any. When Minio is not required, a failed Minio
+upload no longer cuts the Glacier copy short. The destinations each backup
+reached are recorded in the run history, and a destination missed while the
+other succeeded is queued in --pending-file for 'backup retry-pending'.
+
+On slow uplinks, --spool decouples archiving from uploading: each archive is
+written to the local --spool-dir (default ~/.ciwg/spool), and tar and the SSH
+session end as soon as it is complete. 'backup upload-spool' uploads the
+spooled archives later, with retries, including their Glacier copy under
+--include-aws-glacier. The spool is bounded by --spool-max-size; a backup whose
+estimated size does not fit is streamed directly, and one that outgrows the
+room left fails. Spooled backups are marked as such in the run history and
+skip --post-upload-check.
+
+The Glacier copy is buffered in the temp directory, sized from the site's
+previous backup. Before the stream starts, that space is reserved against the
+measured free space minus --temp-headroom and what other uploads of the
+process still have to write. When it does not fit, the upload waits up to
+--temp-wait for space to be released. If there is still no room, the Glacier
+copy is skipped and queued, or the container fails under --require glacier or
+both. This replaces running out of space half-way through the upload.
+
+--archive-order smart writes directories first and then files grouped by
+extension and directory instead of tar's walk order, so similar PHP, CSS and JS
+sit within the compressor's window; how much it saves depends on the site, so
+compare with --dry-run --estimate-method accurate, which uses the same order.
+The extracted tree is identical. It needs GNU find, sed and sort on the host.
+
+Each site is walked once: the file scan taken to size it (and, in dry runs,
+for the heuristic estimate) is reused by the later phases of the run, and with
+--archive-order smart tar reads its file list from the scan instead of walking
+the tree again. A scan older than --scan-cache-ttl is discarded and the tree
+walked afresh. Files created after the scan are then left out of a smart-order
+archive and files deleted since are skipped with a warning.
+
+--post-upload-check validates each tarball right after it is uploaded and
+fails the container if it is damaged. 'quick' compares the stored size and the
+first and last MB with what was sent and decodes the first tar header, using
+ranged reads; 'full' reads the whole object back, compares its checksum and walks
+the gzip stream and tar archive to the end-of-archive blocks.
+
+Warnings tar prints while archiving are shown as they arrive, prefixed with the
+container, and counted by kind (file-changed, file-removed, permission-denied,
+socket-ignored, other). The counts are part of the run summary and history;
+once they reach --warning-threshold the run is reported as completed with
+warnings.
+
+On AWS S3 and compatible backends, --storage-classes (or MINIO_STORAGE_CLASSES)
+uploads each backup with the storage class of its retention tier, e.g.
+daily=STANDARD_IA,weekly=GLACIER_IR,monthly=DEEP_ARCHIVE, so no migration job
+is needed to tier them. Tiers follow --weekly-day and --monthly-day (or the
+retention preset); the class is recorded in the run history. MinIO servers
+only accept STANDARD and REDUCED_REDUNDANCY.
+
+Backup windows can also be configured per host in ~/.ciwg/backup-windows.yaml:
+
+  windows:
+    - hosts: ["wp1*.example.com"]
+      blackout: ["08:00-20:00"]
+      timezone: America/New_York
+
+Hosts and sites can be labelled in the fleet file (~/.ciwg/fleet.yaml, or
+--fleet-file); a site inherits the labels of its host:
+
+  hosts:
+    wp1.example.com:
+      labels: {tier: gold}
+  sites:
+    acme.com:
+      host: wp1.example.com
+      labels: {client: acme}
+
+--group tier=gold (key=value terms joined by commas must all match; repeat
+--group to match any of several) backs up and prunes only the matching sites,
+recording the others as skipped. Without a hostname or --server-range it runs
+on the labelled hosts and the hosts of the matching sites.
+
+--inventory (or BACKUP_INVENTORY) adds the hosts of an Ansible inventory (INI
+or YAML) or of Terraform outputs ('terraform output -json' or a state file,
+where each output listing hosts is a group) to the fleet. Every group a host
+belongs to, directly or through children, becomes a label, so
+--inventory hosts.yml --group wp_servers backs up the hosts of wp_servers.
+Without a hostname, --server-range or --group it runs on every inventory host.
+ansible_host, ansible_user and ansible_port set how each host is reached and
+ciwg_parent_dir replaces --container-parent-dir for it; host variables win
+over group variables and settings in the fleet file win over the inventory:
+
+  [wp_servers]
+  wp[01:20].example.com ansible_user=deploy
+  legacy ansible_host=10.0.0.7 ansible_port=2222
+
+  [wp_servers:vars]
+  ciwg_parent_dir=/srv/sites
+
+Rollout features switched on for the host in the fleet file (see 'backup
+features --help') fill in settings left at their default and are recorded in
+the run history.
+
+Host configuration a restored site depends on is captured when the
+host-config file (~/.ciwg/host-config.yaml, or --host-config-file) exists:
+the crontabs of crontab_users and the listed paths ({site} expands to the
+site name) are archived next to each backup as host-config/<site>/<backup>,
+apart from the site data. Paths missing on the host are listed in the
+archive's MISSING file. --no-host-config skips the capture.
+
+  crontab_users: [root, www-data]
+  paths:
+    - /etc/nginx/sites-enabled/{site}.conf
+    - /etc/traefik/dynamic/{site}.yml
+  sites:
+    legacy.com:
+      paths: [/etc/cron.d/legacy]
+
+When a container fails, the error is matched against known failure signatures
+(wp-cli missing, database credentials, disk full, ...) and a remediation hint
+and error code are printed, stored in the run history and sent to
+--failure-webhook.
+
+With --pagerduty-routing-key or --opsgenie-api-key, a site whose backup fails
+--incident-threshold times in a row (default 2) opens a PagerDuty incident or
+Opsgenie alert, deduplicated per site, and the next successful backup of the
+site resolves it. Failure streaks and open incidents are kept in
+--incident-state-file (default ~/.ciwg/backup-incidents.json).
+
+Sites with a residency in the fleet file only have their backups written to
+the allowed regions and endpoints:
+
+  sites:
+    acme.de:
+      residency:
+        regions: [eu-central-1]
+        endpoints: [minio-fra.example.com]
+
+The Minio bucket's region is what the server reports unless --minio-region
+says otherwise; Glacier's is --aws-region. A site whose destination is not
+allowed fails before anything is uploaded. 'backup sync', 'migrate-aws',
+'monitor' and 'retry-pending' apply the same checks. --residency-override
+"<reason>" lets such writes through, each one recorded with the reason, user
+and host in --residency-audit-log (default ~/.ciwg/residency-overrides.jsonl).
+
+Besides the local run history, each run stores its artifacts in the bucket
+under _meta/<site>/<run-id>/: summary.json (the run record), validation.json
+(post-upload and Glacier checks, failures and skips of the site) and, with
+--audit-commands, commands.jsonl (the site's audited commands and those of the
+run as a whole). 'backup runs show' reads them from there when the run is not
+in the local history. Artifacts are kept for --run-artifacts-retention-days
+(default 90, 0 keeps them forever); --no-run-artifacts stores none.
+
+Long runs can be inspected without stopping them: send SIGUSR1 to print the
+current container, bytes uploaded, elapsed time and remaining queue to stderr,
+or read the same status as JSON from --status-socket:
+
+  kill -USR1 $(pgrep -f 'ciwg-cli backup create')
+  socat - UNIX-CONNECT:/run/ciwg-backup.sock`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runBackupCreate,
 }
@@ -50,15 +427,33 @@ Examples:
 var backupTestMinioCmd = &cobra.Command{
 	Use:   "test-minio",
 	Short: "Test Minio connection and perform read/write test",
-	Long:  `Test the connection to Minio storage and perform a basic read/write test to verify bucket access.`,
-	RunE:  runTestMinio,
+	Long: `Test the connection to Minio storage and perform a basic read/write test to verify bucket access.
+
+Examples:
+  ciwg-cli backup test-minio
+
+  # Minio only listens on plain HTTP on the storage server's LAN; tunnel through SSH
+  ciwg-cli backup test-minio --minio-via-ssh root@storage1 --minio-endpoint 127.0.0.1:9000 --minio-ssl=false`,
+	RunE: runTestMinio,
 }
 
 var backupTestAWSCmd = &cobra.Command{
 	Use:   "test-aws",
 	Short: "Test AWS Glacier connection and perform read/write test",
-	Long:  `Test the connection to AWS Glacier storage and perform a basic read/write test to verify vault access.`,
-	RunE:  runTestAWS,
+	Long: `Test the connection to AWS Glacier storage and perform a basic read/write test to verify vault access.
+
+Without --aws-access-key (AWS_ACCESS_KEY) the default AWS credential chain is
+used: AWS_ACCESS_KEY_ID, the shared config and credentials files (including
+SSO and assumed roles), web identity tokens and the ECS or EC2 instance role.
+--aws-auth static|chain forces one or the other. The test reports which
+credential source was used.
+
+Examples:
+  ciwg-cli backup test-aws --aws-vault backups
+
+  # On an EC2 instance with an instance profile
+  ciwg-cli backup test-aws --aws-vault backups --aws-auth chain`,
+	RunE: runTestAWS,
 }
 
 var backupReadCmd = &cobra.Command{
@@ -72,9 +467,26 @@ var backupReadCmd = &cobra.Command{
 var backupListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List backup objects in Minio",
-	Long:  `List objects in the configured Minio bucket, optionally filtered by prefix.`,
-	Args:  cobra.NoArgs,
-	RunE:  runBackupList,
+	Long: `List objects in the configured Minio bucket, optionally filtered by prefix.
+
+With --group-by site the object count, total size and oldest and newest
+backup of every site are printed instead. They come from the bucket summary, a
+small state object holding per-directory totals, so the bucket is not listed
+again: every backup command that writes to or deletes from the bucket updates
+the directories it touched when it exits, and 'backup index' the prefix it
+lists. When
+the summary is missing or older than --summary-max-age it is rebuilt from a
+full listing. --no-cache skips it and lists the prefix, as does a prefix that
+is not a whole directory (ending in /). 'backup report compliance' reads the
+newest backup of every site the same way.
+
+Examples:
+  ciwg-cli backup list --prefix backups/acme.com/
+  ciwg-cli backup list --group-by site
+  ciwg-cli backup list --group-by site --prefix backups/ --json
+  ciwg-cli backup list --group-by site --no-cache`,
+	Args: cobra.NoArgs,
+	RunE: runBackupList,
 }
 
 var backupDeleteCmd = &cobra.Command{
@@ -89,6 +501,11 @@ Deletion modes (mutually exclusive):
   - Delete all: Use --delete-all to delete all objects (respects --prefix)
   - Numeric range: Use --delete-range "1-10" to delete the 1st through 10th most recent backups
   - Date range: Use --delete-range-by-date "YYYYMMDD-YYYYMMDD" or "YYYYMMDD:HHMMSS-YYYYMMDD:HHMMSS"
+  - Run: Use --run <run-id> to delete exactly the objects produced by one 'backup create' run
+
+Ranges, --latest and retention go by the time in the backup name
+(<label>-YYYYMMDD-HHMMSS), which survives copies and migrations; objects
+without one fall back to their last-modified time.
 
 Examples:
   # Delete a specific backup
@@ -103,8 +520,14 @@ Examples:
   # Delete backups from January 2024
   ciwg-cli backup delete --prefix backups/mysite.com- --delete-range-by-date 20240101-20240131
 
+  # Delete everything produced by a bad run (IDs are listed in ~/.ciwg/backup-history.jsonl)
+  ciwg-cli backup delete --run 20240101-120000-a1b2c3 --dry-run
+
   # Dry run to preview deletions
-  ciwg-cli backup delete --prefix backups/mysite.com- --delete-all --dry-run`,
+  ciwg-cli backup delete --prefix backups/mysite.com- --delete-all --dry-run
+
+  # Delete from the primary and the DR replica (profile 'dr' in ~/.ciwg/minio-profiles.yaml)
+  ciwg-cli backup delete --prefix backups/mysite.com- --delete-range 1-5 --replica-profile dr --propagate-deletes`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runBackupDelete,
 }
@@ -115,8 +538,10 @@ var backupMonitorCmd = &cobra.Command{
 	Long: `Monitor the storage capacity of the Minio storage server and automatically migrate
 the oldest backups to AWS Glacier when usage exceeds a threshold.
 
-This command can be run via cron to maintain storage capacity. When capacity exceeds
-the threshold (default 95%), it will:
+This command can be run via cron to maintain storage capacity. With --warn-threshold
+set, crossing that lower watermark only sends an alert (--alert-webhook) and updates
+metrics (--metrics-file) so capacity can be planned before migration kicks in.
+When capacity exceeds the migration threshold (default 95%), it will:
 	1. Select the oldest N% of backups (default 10%)
 	2. Upload them to AWS Glacier
 	3. Delete them from Minio
@@ -124,6 +549,20 @@ the threshold (default 95%), it will:
 	5. If --force-delete is specified, delete the oldest backups without migrating
 		 whenever the Glacier upload step fails (last-resort backpressure relief)
 
+Oldest-first selection can move every backup of one site to Glacier while
+other sites keep everything hot. With --fair-per-site K each round of step 1
+takes at most K of the oldest backups from every site (the directory of the
+key) in turn until the N% target is met, and each site's newest backup always
+stays in Minio.
+
+With --staging-profile (or --staging-prefix) step 2 becomes a verified copy to
+the staging tier, so space is freed without waiting for Glacier; the uploads
+run in the background and the command waits for them before it exits.
+
+Backups that were archived to Glacier but could not be deleted from Minio are
+recorded in --pending-deletes-file; each run retries those deletes before it
+checks capacity (see 'backup reconcile').
+
 Example:
   # Monitor and migrate if capacity exceeds 95%
   ciwg-cli backup monitor
@@ -131,8 +570,17 @@ Example:
   # Use custom threshold and migration percentage
   ciwg-cli backup monitor --threshold 90 --migrate-percent 15
 
+  # Alert at 85%, migrate at 95%
+  ciwg-cli backup monitor --warn-threshold 85 --alert-webhook https://hooks.example.com/backups
+
+  # Spread migrations across sites, two backups per site per round
+  ciwg-cli backup monitor --fair-per-site 2
+
   # Use specific storage path
-  ciwg-cli backup monitor --storage-path /mnt/minio-data`,
+  ciwg-cli backup monitor --storage-path /mnt/minio-data
+
+  # Stage to a bucket on other drives and archive in the background
+  ciwg-cli backup monitor --staging-profile staging`,
 	Args: cobra.NoArgs,
 	RunE: runBackupMonitor,
 }
@@ -178,8 +626,25 @@ Examples:
   # Custom SQL file pattern
   ciwg-cli backup sanitize --input backup.tgz --output clean.tgz --extract-file "*.sql,*.dump"
 
-  # Dry run to preview what would be extracted
-  ciwg-cli backup sanitize --input backup.tgz --output clean.tgz --dry-run`,
+  # Dry run to preview what would be extracted and which rules match
+  ciwg-cli backup sanitize --input backup.tgz --output clean.tgz --dry-run
+
+  # Custom rules: extra options, PII masking, table drops, file exclusions
+  ciwg-cli backup sanitize --input backup.tgz --output clean.tgz --rules sanitize-rules.yml
+
+Rules file schema (see examples/sanitize-rules.yml):
+
+  defaults: true          # keep the built-in license key rules (default true)
+  remove_options:         # wp_options names; SQL lines mentioning them are dropped
+    - my_plugin_license
+  replacements:           # regex replacements applied to every SQL line
+    - name: mask-emails
+      pattern: '[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}'
+      replace: 'redacted@example.invalid'
+  drop_tables:            # tables whose schema and data are removed (globs)
+    - wp_users
+  exclude_files:          # files left out of the tarball (globs on the path or name)
+    - "*.log"`,
 	Args: cobra.NoArgs,
 	RunE: runBackupSanitize,
 }
@@ -198,6 +663,25 @@ Migration strategies:
   - By age: Use --older-than to migrate backups older than a duration
   - Percentage: Use --percent to migrate oldest N% of all backups
 
+Objects are streamed to Glacier as multipart uploads in --aws-part-size parts,
+so only one part is buffered in temp space at a time. If a migration is
+interrupted, re-running it for the same object resumes from the parts already
+uploaded.
+
+With --aws-verify every part is re-hashed from its temp file after upload and
+compared with the checksum AWS returned, and the final archive checksum is
+checked too. A mismatch fails the migration and keeps the Minio copy (even with
+--delete-after). Each migration is recorded in the history file, with its
+verification state, so 'backup aws-audit' can reconcile it.
+
+With --staging-prefix or --staging-profile each backup is first copied to a
+staging tier (server-side when it is on the same Minio server), verified by
+checksum (--checksum) and deleted from the hot bucket; Glacier uploads then run from the
+staging tier in the background, and the command waits for them before exiting.
+Staging under a prefix of the hot bucket only hides backups from listings; use
+a staging bucket on other drives to free hot disk space before the upload ends.
+Objects that fail to archive stay staged for --drain-staging.
+
 Examples:
   # Migrate a specific backup object
   ciwg-cli backup migrate-aws --object backups/mysite.com/mysite.com-20241112-120000.tgz -vv
@@ -218,7 +702,19 @@ Examples:
   ciwg-cli backup migrate-aws --count 10 --dry-run -v
 
   # Delete from Minio after successful migration
-  ciwg-cli backup migrate-aws --count 5 --delete-after -vv`,
+  ciwg-cli backup migrate-aws --count 5 --delete-after -vv
+
+  # Migrate a very large backup with a small temp disk
+  ciwg-cli backup migrate-aws --object backups/big.com/big.com-20241112-120000.tgz --aws-part-size 64MB
+
+  # Verify checksums before deleting from Minio
+  ciwg-cli backup migrate-aws --older-than 720h --aws-verify --delete-after
+
+  # Free hot space fast by staging to a bucket on other drives first
+  ciwg-cli backup migrate-aws --percent 10 --staging-profile staging
+
+  # Archive whatever an interrupted staged run left behind
+  ciwg-cli backup migrate-aws --drain-staging --staging-profile staging`,
 	Args: cobra.NoArgs,
 	RunE: runBackupMigrateAWS,
 }
@@ -233,9 +729,19 @@ This command helps you plan storage capacity by:
   - Scanning live sites or using existing backups as baselines
   - Applying retention policies (daily/weekly/monthly)
   - Calculating hot storage (Minio) and cold storage (AWS Glacier) requirements
+  - Adding erasure-coding overhead to hot storage (--ec-data/--ec-parity or --ec-detect)
+  - Modeling the Glacier migration cadence (--migrate-after-days)
   - Projecting growth over time
   - Estimating AWS Glacier storage costs
 
+Daily backups only occupy Minio until the monitor or 'backup migrate-aws'
+moves them to Glacier. --migrate-after-days sets the age at which that
+happens in your setup (e.g. 5 for 'migrate-aws --older-than 120h' run
+daily); hot storage then counts only the daily backups younger than that and
+the rest move to cold storage. The size output compares three scenarios side
+by side: no migration, the given cadence, and an aggressive cadence
+(--aggressive-migrate-after-days).
+
 Examples:
   # Scan a single server with default retention (14 daily, 26 weekly, 6 monthly)
   ciwg-cli backup estimate-capacity wp0.ciwgserver.com --estimate-method sample
@@ -243,6 +749,15 @@ Examples:
   # Scan entire fleet with server range
   ciwg-cli backup estimate-capacity --server-range "wp%d.ciwgserver.com:0-41" --estimate-method heuristic
 
+  # Sample each site until its estimate is within ±5% at 95% confidence
+  ciwg-cli backup estimate-capacity wp0.ciwgserver.com --estimate-method adaptive
+
+  # Scan only the gold-tier sites of the fleet file (see 'backup create --help')
+  ciwg-cli backup estimate-capacity --group tier=gold
+
+  # Analyze 4 containers at a time on each server
+  ciwg-cli backup estimate-capacity wp0.ciwgserver.com --estimate-parallelism 4
+
   # Use existing backup as baseline
   ciwg-cli backup estimate-capacity --from-backup backups/mysite.com/backup.tgz
 
@@ -261,6 +776,17 @@ Examples:
   ciwg-cli backup estimate-capacity wp0.ciwgserver.com \
     --estimate-type cost --aws-glacier-price 0.004
 
+  # Daily backups move to Glacier after 5 days; compare against 2 days
+  ciwg-cli backup estimate-capacity --avg-compressed-size 125MB --site-count 42 \
+    --migrate-after-days 5 --aggressive-migrate-after-days 2 --available-storage 500GB
+
+  # Size hot storage on disk for EC:4 across 16 drives (12 data + 4 parity)
+  ciwg-cli backup estimate-capacity --avg-compressed-size 125MB --site-count 42 \
+    --ec-data 12 --ec-parity 4 --available-storage 20TB
+
+  # Read the erasure-coding layout from the Minio admin API
+  ciwg-cli backup estimate-capacity --avg-compressed-size 125MB --site-count 42 --ec-detect
+
   # Export to JSON
   ciwg-cli backup estimate-capacity --server-range "wp%d.ciwgserver.com:0-41" \
     --output json > capacity-report.json`,
@@ -268,6 +794,1213 @@ Examples:
 	RunE: runBackupEstimateCapacity,
 }
 
+var backupRestoreDBCmd = &cobra.Command{
+	Use:   "restore-db [object]",
+	Short: "Restore only the database from a backup into a running container",
+	Long: `Stream a backup object from Minio, locate the embedded SQL dump and import it
+into a running container without touching any site files.
+
+The object may be a full site tarball (the first .sql entry is used, narrow it with
+--sql-path) or a standalone .sql / .sql.gz database object. Before importing, the
+current database is exported to --safety-export-dir on the target host so the
+restore can be undone.
+
+Backups of WordPress multisite networks hold the tables of every sub-site, and
+their manifest lists the sub-sites. By default the full network is imported.
+--subsite N imports only the tables of blog N (the network tables and users
+are left alone), and --uploads-dir also extracts its uploads
+(wp-content/uploads/sites/N, or everything outside sites/ for the main site)
+into that directory on the target host.
+
+Examples:
+  # Restore the database of a site from a specific backup
+  ciwg-cli backup restore-db backups/foo.com/foo.com-20240101-120000.tgz --container wp_foo --host wp0.example.com
+
+  # Restore from the latest backup of a site, on the local Docker host
+  ciwg-cli backup restore-db --latest --prefix backups/foo.com/ --container wp_foo --local
+
+  # Preview without changing anything
+  ciwg-cli backup restore-db backups/foo.com/foo.com-20240101-120000.tgz --container wp_foo --host wp0.example.com --dry-run
+
+  # Restore sub-site 3 of a multisite network, tables and uploads
+  ciwg-cli backup restore-db backups/net.com/net.com-20240101-120000.tgz --container wp_net --host wp0.example.com \
+    --subsite 3 --uploads-dir /var/opt/net.com/www/wp-content/uploads`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackupRestoreDB,
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [object]",
+	Short: "Restore a site backup under a different site name",
+	Long: `Stream a site backup from Minio and restore it as a new site next to the
+original, for staging copies or for investigating an incident without touching
+the live site.
+
+The archive is rewritten while it is extracted: paths move from the original
+site directory to --as, the site name is replaced in docker-compose files, a new
+compose project name is set and container names are suffixed so they cannot
+collide with the running site. The target directory must not exist yet, unless
+--replace is given.
+
+--replace restores over an existing site, including the site the backup was
+taken from (which then keeps its names and database). Before anything is
+extracted, the current site is turned into a rollback point: its database is
+exported from the running container, its stack stopped and its directory moved
+to .ciwg-rollback/ next to it, which is a rename on the same filesystem. The
+point is registered in --rollback-file, as is the safety export of 'backup
+restore-db'. --rollback-last reverts the last restore on the host, or of --as:
+the restored directory is stopped and kept next to the snapshot, and the
+snapshot is moved back, started and its database re-imported. Snapshots stay
+in .ciwg-rollback/ until removed.
+
+Sites usually share a MySQL server, so a copy that would keep the original
+database name is refused: pass --db-name. After the containers are started the
+dump is imported and every --search-replace pair is applied, by default with
+'wp search-replace' (which keeps serialized PHP data intact); --rewrite-method sql
+rewrites the dump text instead, before import.
+
+The archive is decompressed by ciwg-cli (gzip or zstd) and sparse files are
+expanded, so the host's tar only receives a plain tar stream. Before extracting,
+the host's tar is identified; when it cannot restore what the archive holds
+(extended attributes need GNU tar 1.27+ or bsdtar, paths too long for ustar
+need GNU or PAX extensions), the archive is scanned first and the restore is
+refused before anything is written. --compat re-packages the stream for any tar
+instead: extended attributes are dropped and long paths use GNU long names.
+
+Examples:
+  # Restore a staging copy of foo.com on the same server
+  ciwg-cli backup restore backups/foo.com/foo.com-20240101-020000.tgz --as staging.foo.com \
+    --db-name wp_staging_foo --search-replace https://foo.com=https://staging.foo.com --host wp0.example.com
+
+  # Extract only, without starting containers or importing the database
+  ciwg-cli backup restore --latest --prefix backups/foo.com/ --as incident.foo.com \
+    --db-name wp_incident_foo --no-start --local
+
+  # Restore onto an old host whose tar cannot handle extended attributes
+  ciwg-cli backup restore --latest --prefix backups/foo.com/ --as staging.foo.com \
+    --db-name wp_staging_foo --host legacy1.example.com --compat
+
+  # Preview the rewrite
+  ciwg-cli backup restore backups/foo.com/foo.com-20240101-020000.tgz --as staging.foo.com \
+    --db-name wp_staging_foo --host wp0.example.com --dry-run
+
+  # Restore foo.com in place, then revert when it goes wrong
+  ciwg-cli backup restore --latest --prefix backups/foo.com/ --as foo.com --replace --host wp0.example.com
+  ciwg-cli backup restore --rollback-last --as foo.com --host wp0.example.com`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackupRestore,
+}
+
+var backupRetryPendingCmd = &cobra.Command{
+	Use:   "retry-pending",
+	Short: "Retry uploads that reached only one destination",
+	Long: `Work through the queue of destinations missed by dual uploads ('backup create
+--include-aws-glacier'), written when one destination failed and the other
+succeeded.
+
+A missed Glacier copy is archived from the Minio object, the same way
+migrate-aws does, and recorded in the run history for the Glacier ledger. A
+missed Minio copy cannot be read back from Glacier in reasonable time, so it is
+re-created by archiving the site directory again on the host that made the
+backup (--host, or --local); it holds the site as it is at retry time.
+Entries for other hosts are left queued.
+
+Completed entries leave the queue; failed ones stay with their attempt count
+and last error.
+
+Examples:
+  # Show the queue
+  ciwg-cli backup retry-pending --dry-run
+
+  # Retry missed Glacier copies, and missed Minio copies made on wp0
+  ciwg-cli backup retry-pending --host wp0.example.com`,
+	Args: cobra.NoArgs,
+	RunE: runBackupRetryPending,
+}
+
+var backupUploadSpoolCmd = &cobra.Command{
+	Use:   "upload-spool",
+	Short: "Upload the archives spooled by 'backup create --spool'",
+	Long: `Upload the archives 'backup create --spool' left in the local spool, oldest
+first, to Minio and, for backups made with --include-aws-glacier, to Glacier.
+
+Each archive is tried --retries times, waiting --retry-delay before the second
+try and twice as long before each further one. An archive leaves the spool once
+every destination has it; one that keeps failing stays with its attempt count
+and last error for the next run, and a Minio copy already made is not uploaded
+again. With --watch the spool is drained every --interval until interrupted, so
+the uploader can run as a service next to scheduled backups.
+
+Examples:
+  # Show what is spooled
+  ciwg-cli backup upload-spool --dry-run
+
+  # Drain the spool once
+  ciwg-cli backup upload-spool --spool-dir /var/spool/ciwg
+
+  # Keep draining as backups are spooled
+  ciwg-cli backup upload-spool --watch --interval 5m`,
+	Args: cobra.NoArgs,
+	RunE: runBackupUploadSpool,
+}
+
+var backupReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Finish deletes that failed after backups were migrated to Glacier",
+	Long: `Retry deleting Minio objects that a migration archived to Glacier but could not
+delete afterwards. Until they are deleted they take up hot space, so 'backup
+monitor' and 'backup migrate-aws' record them in --pending-deletes-file and skip
+them instead of migrating them again. The monitor also runs this before it
+migrates anything.
+
+An object is only deleted while its size matches the archived copy; an object
+that changed is reported and kept. Objects that are already gone leave the
+list, as do deleted ones; failed deletes stay with their attempt count and
+last error. Entries of other buckets are left alone.
+
+Examples:
+  # Show what would be deleted
+  ciwg-cli backup reconcile --dry-run
+
+  # Retry the deletes
+  ciwg-cli backup reconcile`,
+	Args: cobra.NoArgs,
+	RunE: runBackupReconcile,
+}
+
+var backupGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete zero-byte objects and find site prefixes without recent backups",
+	Long: `Clean up what failed uploads and deleted sites leave in the bucket, so they stop
+polluting listings and reports:
+
+  - zero-byte objects (uploads that failed after the object was created) and
+    empty prefix markers older than --min-age are deleted;
+  - site prefixes whose newest backup is older than --stale-days are reported,
+    and their backups deleted too with --prune-stale. Sites in maintenance are
+    left alone.
+
+On a filesystem backend (file://) directories left without files are removed
+as well. Locks and other internal objects are never touched. --json prints the
+report, e.g. for a weekly summary.
+
+Examples:
+  # Show what would be cleaned up
+  ciwg-cli backup gc --dry-run
+
+  # Delete zero-byte objects and list sites without a backup in 60 days
+  ciwg-cli backup gc --stale-days 60
+
+  # Also delete the backups of those sites
+  ciwg-cli backup gc --stale-days 60 --prune-stale`,
+	Args: cobra.NoArgs,
+	RunE: runBackupGC,
+}
+
+var backupImportCmd = &cobra.Command{
+	Use:   "import [file|object]...",
+	Short: "Import legacy .zip and .tgz backups into the standard layout",
+	Long: `Upload backups made by earlier tooling as if 'backup create' had made them:
+backups/<site>/<site>-YYYYMMDD-HHMMSS.tgz (or under --bucket-path).
+
+The site and time come from the file name (e.g. example.com_2024-01-31.zip,
+or an already standard name); names without a date use the file's modification
+time. A .zip is converted to a .tgz with the site directory at its root and a
+generated manifest recording where it came from; a .tgz is uploaded as it is.
+Zips are read in place, entry by entry, without unpacking them to disk.
+
+With --from-bucket the arguments are .zip objects already in the bucket, or
+every .zip under --prefix; they are converted next to the standard backups and
+kept unless --delete-source is given.
+
+'backup restore-db', 'backup restore', 'backup analyze' and verification also
+read .zip backups directly, so importing is only needed to list, prune and
+migrate them like the others.
+
+Examples:
+  # Import local legacy backups
+  ciwg-cli backup import /srv/old/example.com_2024-01-31.zip /srv/old/shop.com-20240101-120000.tgz
+
+  # Name the site when the file name doesn't
+  ciwg-cli backup import /srv/old/backup.zip --site example.com
+
+  # Convert every .zip left in the bucket by the old tooling
+  ciwg-cli backup import --from-bucket --prefix legacy/ --dry-run
+  ciwg-cli backup import --from-bucket --prefix legacy/ --delete-source`,
+	RunE: runBackupImport,
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Detect backups that were overwritten or corrupted",
+	Long: `Check the backups under --prefix for silent changes and corruption.
+
+Backups are written once and never modified, so their ETags should never
+change. verify keeps the ETag, size and modification time of every object it
+has seen in an index (~/.ciwg/etag-index.json by default, per endpoint and
+bucket) and compares the current listing against it: objects seen for the
+first time are recorded, objects whose ETag or size differ are flagged as
+CHANGED, and objects that are gone (pruned or deleted) are dropped from the
+index. Only a listing is needed, so --etag-only checks a whole bucket in
+minutes.
+
+Without --etag-only every object is also read end to end and checked to be a
+complete gzip stream and a readable tar archive (or zip).
+
+A flagged object keeps its recorded state until you have checked it and run
+verify again with --accept, which records the new state and keeps the old one
+in the object's history. verify exits with an error when anything changed or
+failed to read, so it can alert from cron.
+
+Examples:
+  # Record the current state, then check for changes nightly
+  ciwg-cli backup verify --etag-only
+  ciwg-cli backup verify --etag-only --prefix backups/example.com/
+
+  # Also read every backup of a site
+  ciwg-cli backup verify --prefix backups/example.com/
+
+  # Accept the new state of objects that were knowingly rewritten
+  ciwg-cli backup verify --etag-only --prefix backups/example.com/ --accept`,
+	RunE: runBackupVerify,
+}
+
+var backupBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure upload throughput and tune part sizes and concurrency",
+	Long: `Upload synthetic data to Minio or Glacier with several part sizes and
+concurrency levels, report the throughput of each run and recommend the
+fastest setting.
+
+Every run uploads --size bytes of incompressible data. Minio objects are
+written under .locks/bench/ and deleted after each run. Glacier runs upload the
+parts of a multipart upload and abort it instead of completing it, so no
+archive is created and no early deletion fee applies; migrations send one part
+at a time, so only part sizes are compared for Glacier.
+
+Among runs within 5% of the fastest, the one buffering the least (part size
+times concurrency) is recommended. It is saved in the tuning section of the
+active backup profile, which later uploads apply through MINIO_PART_SIZE,
+MINIO_UPLOAD_CONCURRENCY and AWS_GLACIER_PART_SIZE; flags and environment
+variables still take precedence. Use --save=false to only report.
+
+Examples:
+  ciwg-cli backup bench --target minio --size 2GB
+  ciwg-cli backup bench --target minio --part-sizes 16MB,64MB --concurrency 2,4,8
+  ciwg-cli backup bench --target glacier --size 512MB --part-sizes 8MB,32MB,128MB
+  ciwg-cli backup bench --target minio --profile staging --save=false --json
+  ciwg-cli backup bench --target minio --dry-run`,
+	RunE: runBackupBench,
+}
+
+var backupCacheLatestCmd = &cobra.Command{
+	Use:   "cache-latest",
+	Short: "Keep the latest backup of every site on a fast local disk or nearby bucket",
+	Long: `Mirror the newest complete backup of every site to a cache: a local directory
+(--cache-dir) or a bucket on a nearby Minio (--cache-profile, a storage profile).
+Run it on a schedule, e.g. from cron shortly after the nightly backups.
+
+Each run copies a site's backup when it is newer than the cached one and then
+evicts the older copy, so the cache holds one backup per site. Sites whose
+backups are all gone are evicted too. Split backups are cached only once every
+part is present.
+
+'backup read' and 'backup restore-db' consult the same cache when --cache-dir or
+--cache-profile (or BACKUP_CACHE_DIR / BACKUP_CACHE_PROFILE) is set: an object is
+served from the cache when the cached copy has the same size as the bucket's,
+and read from the bucket otherwise.
+
+Examples:
+  # Keep the latest backups on the support host's SSD
+  ciwg-cli backup cache-latest --cache-dir /srv/backup-cache
+
+  # Preview what a run would copy and evict
+  ciwg-cli backup cache-latest --cache-dir /srv/backup-cache --dry-run
+
+  # Cache into a bucket on a Minio close to the support team
+  ciwg-cli backup cache-latest --cache-profile office
+
+  # Reads then come from the cache transparently
+  export BACKUP_CACHE_DIR=/srv/backup-cache
+  ciwg-cli backup read --latest --prefix backups/mysite.com/ --save`,
+	Args: cobra.NoArgs,
+	RunE: runBackupCacheLatest,
+}
+
+var backupMaintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Exclude sites from fleet-wide backup runs while they are in maintenance",
+	Long: `Put sites in maintenance so every host's create, prune and migrate runs skip
+them, e.g. while a site is mid-migration between servers, without editing any
+cron job. Flags are stored in the backup bucket under .ciwg/maintenance/ and
+expire on their own when set with --until.
+
+Skipped sites are reported as "skipped (maintenance)" in run output and are
+listed under "skipped" in the run history.`,
+}
+
+var backupMaintenanceSetCmd = &cobra.Command{
+	Use:   "set <site>",
+	Short: "Put a site in maintenance",
+	Long: `Put a site in maintenance. The site is the directory its backups are stored
+under (backups/<site>/), normally its domain.
+
+Examples:
+  # Skip foo.com until New Year
+  ciwg-cli backup maintenance set foo.com --until 2025-01-01 --reason "moving to wp3"
+
+  # Skip until cleared
+  ciwg-cli backup maintenance set foo.com`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupMaintenanceSet,
+}
+
+var backupMaintenanceClearCmd = &cobra.Command{
+	Use:   "clear <site>...",
+	Short: "Take sites out of maintenance",
+	Long: `Take sites out of maintenance before their --until time.
+
+Examples:
+  ciwg-cli backup maintenance clear foo.com
+  ciwg-cli backup maintenance clear foo.com bar.com --dry-run`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBackupMaintenanceClear,
+}
+
+var backupMaintenanceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sites in maintenance",
+	Long: `List sites in maintenance. Expired entries are only shown with --all.
+
+Examples:
+  ciwg-cli backup maintenance list
+  ciwg-cli backup maintenance list --all --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupMaintenanceList,
+}
+
+var backupPrunePlanCmd = &cobra.Command{
+	Use:   "prune-plan",
+	Short: "Inspect, audit and resume two-phase prunes",
+	Long: `'backup create --prune --prune-plan' prunes in two phases: it first writes a
+plan of every object it is going to delete, with the size and ETag each was
+listed with, to the bucket under .ciwg/prune-plans/, and then deletes from that
+plan, recording each deletion in it. A prune that is interrupted can be resumed
+with 'prune-plan execute', and the plan stays behind as a record of exactly
+what the prune removed. --prune-plan-only writes the plan without deleting
+anything, for review, and 'prune-plan create' writes one for the bucket with
+the same retention flags and --retention-preset without taking backups.
+
+Objects replaced since the plan was made (different size or ETag) are left in
+place and reported. Glacier archives removed by --clean-aws are not part of the
+plan.`,
+}
+
+var backupPrunePlanCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Write a prune plan for the bucket without taking backups",
+	Long: `Plan the prune 'backup create --prune' would run on every site under
+--prefix, with the same retention flags and --retention-preset, and store it
+as --prune-plan-only does: nothing is deleted until 'prune-plan execute'.
+Sites in maintenance are left out.
+
+Examples:
+  ciwg-cli backup prune-plan create --retention-preset standard
+  ciwg-cli backup prune-plan create --prefix backups/example.com/ --remainder 10`,
+	Args: cobra.NoArgs,
+	RunE: runBackupPrunePlanCreate,
+}
+
+var backupPrunePlanListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List prune plans, newest first",
+	Long: `List the stored prune plans with their status and how many objects are still
+pending.
+
+Examples:
+  ciwg-cli backup prune-plan list
+  ciwg-cli backup prune-plan list --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupPrunePlanList,
+}
+
+var backupPrunePlanShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the objects of a prune plan and what happened to each",
+	Long: `Show every object of a prune plan: deleted (and when), pending, or left in
+place with the reason.
+
+Examples:
+  ciwg-cli backup prune-plan show 20260501-020000-a1b2c3`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupPrunePlanShow,
+}
+
+var backupPrunePlanExecuteCmd = &cobra.Command{
+	Use:   "execute <id>",
+	Short: "Execute or resume a prune plan",
+	Long: `Delete the objects of a prune plan that are not deleted yet: a plan written
+with --prune-plan-only, or one whose execution was interrupted.
+
+Examples:
+  ciwg-cli backup prune-plan execute 20260501-020000-a1b2c3
+  ciwg-cli backup prune-plan execute 20260501-020000-a1b2c3 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupPrunePlanExecute,
+}
+
+var backupFeaturesCmd = &cobra.Command{
+	Use:   "features",
+	Short: "Show the rollout features turned on per host in the fleet file",
+	Long: `New backup behaviour can be rolled out to a few hosts before it becomes the
+default, without a separate binary. Features are switched on fleet-wide or per
+host in the fleet file (~/.ciwg/fleet.yaml, or --fleet-file); a host's own
+setting wins:
+
+  features:
+    post-upload-check: true
+  hosts:
+    wp1.example.com:
+      features: {smart-order: true, glacier-zstd: true}
+    wp2.example.com:
+      features: {post-upload-check: false}
+
+'backup create' reads the features of each host it backs up and records them
+in the run history. A feature only changes a setting left at its default; an
+explicit flag still wins. Unknown feature names are rejected.`,
+}
+
+var backupFeaturesListCmd = &cobra.Command{
+	Use:   "list [host...]",
+	Short: "List the rollout features and the hosts they are on",
+	Long: `List every rollout feature with the hosts it is on: the hosts of the fleet
+file, or the hosts given.
+
+Examples:
+  ciwg-cli backup features list
+  ciwg-cli backup features list wp1.example.com wp9.example.com --json`,
+	RunE: runBackupFeaturesList,
+}
+
+var backupSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Copy backups into Minio or between Minio buckets/endpoints",
+	Long: `Copy backups written to a filesystem destination (file:// endpoint) into Minio,
+or move a bucket between two named storage profiles.
+
+Objects already present at the destination with the same size are skipped, so
+sync can be re-run safely. The source is left untouched; prune it separately
+with 'backup delete' once the copy is verified.
+
+Profiles are read from ~/.ciwg/minio-profiles.yaml (or --profiles-file):
+
+  profiles:
+    old:
+      endpoint: minio-old.internal:9000
+      access_key_env: OLD_MINIO_ACCESS_KEY
+      secret_key_env: OLD_MINIO_SECRET_KEY
+      bucket: backups
+    new:
+      endpoint: minio.internal:9000
+      access_key_env: MINIO_ACCESS_KEY
+      secret_key_env: MINIO_SECRET_KEY
+      bucket: backups
+
+Between profiles, objects are copied server-side when both use the same server
+and credentials and streamed through this host otherwise. Content type, user
+metadata and tags are preserved, and every copy is read back and compared by
+checksum (--checksum) unless --no-verify is given. Finished objects are recorded in
+--state-file (default ~/.ciwg/sync/<source>-to-<dest>.jsonl) so an interrupted
+sync resumes where it stopped.
+
+Examples:
+  # Push everything from the NAS into Minio
+  ciwg-cli backup sync --from-dir /mnt/nas/backups
+
+  # Preview what would be copied for a single site
+  ciwg-cli backup sync --from-dir /mnt/nas/backups --prefix backups/mysite.com/ --dry-run
+
+  # Move all backups from the old Minio server to the new one
+  ciwg-cli backup sync --source-profile old --dest-profile new --prefix backups/
+
+  # Machine-readable per-object report
+  ciwg-cli backup sync --source-profile old --dest-profile new --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupSync,
+}
+
+var backupEstimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Tune compression estimation",
+	Long:  `Tools for tuning the compression model used by --estimate-method heuristic.`,
+}
+
+var backupEstimateCalibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Learn per-extension compression ratios from recent real backups",
+	Long: `Download the most recent backups under --prefix, re-compress every file they
+contain to measure per-extension compression ratios, and save them together
+with a scale factor that makes the modelled total match the real object sizes.
+
+The heuristic estimator (create --dry-run and estimate-capacity) picks the model
+up from ~/.ciwg/compression-model.json or --compression-model.
+
+Examples:
+  # Calibrate from the 3 most recent backups in the bucket
+  ciwg-cli backup estimate calibrate
+
+  # Calibrate from a specific site's last 5 backups and save elsewhere
+  ciwg-cli backup estimate calibrate --prefix backups/mysite.com/ --count 5 --output ./model.json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupEstimateCalibrate,
+}
+
+var backupRetentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Inspect retention presets and compare retention policies",
+	Long:  `Inspect the named retention presets accepted by --retention-preset and preview how two retention policies would prune the bucket.`,
+}
+
+var backupRetentionShowPresetsCmd = &cobra.Command{
+	Use:   "show-presets",
+	Short: "List built-in and user-defined retention presets",
+	Long: `List the retention presets accepted by --retention-preset on create,
+prune-plan create, estimate-capacity and lifecycle export.
+
+Built-in presets (standard, aggressive, archive-heavy) can be overridden and new
+presets added in the retention_presets section of the fleet file
+(~/.ciwg/fleet.yaml, or --fleet-file):
+
+  retention_presets:
+    standard:
+      keep_daily: 14
+      keep_weekly: 26
+      keep_monthly: 6
+    client-longterm:
+      description: Contractual 2-year retention
+      keep_daily: 7
+      keep_weekly: 12
+      keep_monthly: 24
+      monthly_day: 1
+
+Examples:
+  ciwg-cli backup retention show-presets
+  ciwg-cli backup create wp0.example.com --prune --retention-preset aggressive
+  ciwg-cli backup estimate-capacity wp0.example.com --retention-preset archive-heavy`,
+	Args: cobra.NoArgs,
+	RunE: runBackupRetentionShowPresets,
+}
+
+var backupRetentionCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Preview what two retention policies keep and delete",
+	Long: `Prune every site under --prefix with two retention policies in memory and
+show, per site and fleet-wide, how many objects each keeps and deletes and how
+much more or less storage policy B keeps than policy A. Nothing is deleted.
+
+Policies are written as:
+
+  remainder:N              keep the N most recent backups (create --prune --remainder N)
+  smart:D/W/M[/WD/MD]      smart retention keeping D daily, W weekly and M monthly
+                           backups, weeklies on weekday WD (0=Sunday, default 0)
+                           and monthlies on day MD (default 1)
+  preset:NAME              a retention preset (see 'backup retention show-presets')
+
+Each site is pruned on its own, as create --prune does, with weekdays and days
+of the month read in --timezone. Incomplete backups are kept by both policies.
+--objects lists every object the policies disagree on.
+
+Examples:
+  # Preview moving one site from --remainder 5 to smart retention
+  ciwg-cli backup retention compare --policy-a remainder:5 --policy-b smart:14/26/6 --prefix backups/example.com/
+
+  # Fleet-wide, against a preset, as JSON
+  ciwg-cli backup retention compare --policy-a remainder:5 --policy-b preset:archive-heavy --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupRetentionCompare,
+}
+
+var backupLifecycleCmd = &cobra.Command{
+	Use:   "lifecycle",
+	Short: "Bucket lifecycle rules that match the retention policy",
+	Long:  `Render the retention policy and tiering rules as bucket lifecycle configuration.`,
+}
+
+var backupLifecycleExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the retention policy as Terraform or S3 lifecycle JSON",
+	Long: `Render the smart retention policy (--retention-preset or the --keep-* flags, as
+used by 'backup create --prune') and tiering rules as bucket lifecycle rules, so
+the lifecycle declared in Terraform matches what the CLI enforces.
+
+Smart retention counts backups per site, which lifecycle rules cannot express;
+they only know an object's age. The exported expiration is therefore a backstop
+set at the age of the oldest backup the policy keeps on a daily schedule (the
+longest of the daily, weekly and monthly tails, a month counted as 31 days)
+plus --grace-days. It never expires a backup the CLI would keep while backups
+run daily, and the CLI's pruning still removes the backups in between.
+
+Tiering rules:
+  --transition-days/--storage-class  move backups to a colder class or tier
+  --staging-prefix/--staging-storage-class  tier staged migrations as soon as they
+        land, which is what frees disk when staging into the hot bucket
+  --abort-multipart-days  clean up interrupted multipart uploads
+
+Formats:
+  terraform  an aws_s3_bucket_lifecycle_configuration resource
+  s3-json    the S3 lifecycle configuration, for
+             'aws s3api put-bucket-lifecycle-configuration' or 'mc ilm import'
+
+Examples:
+  # Terraform for the standard preset
+  ciwg-cli backup lifecycle export --retention-preset standard --out lifecycle.tf
+
+  # S3 JSON for Minio, tiering staged migrations to the remote tier COLD
+  ciwg-cli backup lifecycle export --format s3-json --staging-prefix staging/ \
+    --staging-storage-class COLD | mc ilm import myminio/backups`,
+	Args: cobra.NoArgs,
+	RunE: runBackupLifecycleExport,
+}
+
+var backupRunsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Inspect recorded backup runs",
+	Long:  `Inspect backup runs recorded in the local run history or stored in the bucket.`,
+}
+
+var backupRunsShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show the summary, validation report and commands of a run",
+	Long: `Show what a backup run did: its hosts and times, the uploads with their
+post-upload and Glacier checks, failures with their remediation hint and
+skipped containers. --commands adds the shell commands the run audited.
+
+The run is looked up in the local run history (--history-file) first. When it
+is not there, e.g. because it ran on another machine, its artifacts are
+fetched from _meta/ in the bucket, where 'backup create' stores them; --remote
+always reads the bucket. --site narrows the lookup to one site's artifacts.
+
+Examples:
+  ciwg-cli backup runs show 20260501-020000-a1b2c3
+  ciwg-cli backup runs show 20260501-020000-a1b2c3 --remote --site example.com --commands
+  ciwg-cli backup runs show 20260501-020000-a1b2c3 --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupRunsShow,
+}
+
+var backupReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports built from recorded backup run history",
+	Long:  `Summarize information recorded by previous 'backup create' runs.`,
+}
+
+var backupReportPerformanceCmd = &cobra.Command{
+	Use:   "performance",
+	Short: "Summarize upload throughput per server or site",
+	Long: `Aggregate per-upload throughput recorded by 'backup create' (Minio and AWS Glacier
+MB/s, Glacier buffering and checksum time) to compare servers and spot network
+paths that are getting slower before backups start missing their windows.
+
+A group is flagged as degrading when the average Minio throughput of its most
+recent half of uploads is at least 25% lower than that of the older half.
+
+Examples:
+  # Throughput per server
+  ciwg-cli backup report performance
+
+  # Throughput per site over the last 30 days
+  ciwg-cli backup report performance --group-by site --since 720h
+
+  # Only the sites labelled client=acme in the fleet file
+  ciwg-cli backup report performance --group-by site --group client=acme
+
+  # JSON output
+  ciwg-cli backup report performance --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupReportPerformance,
+}
+
+var backupReportComplianceCmd = &cobra.Command{
+	Use:   "compliance",
+	Short: "Check sites against their RPO and RTO targets",
+	Long: `Evaluate every site that declares a recovery point objective (rpo) or
+recovery time objective (rto) in the fleet file against what was achieved:
+
+  RPO  the age of the site's newest backup in the bucket, by the timestamp in
+       its name, must not exceed the target. It is read from the bucket
+       summary (see 'backup list'); --no-cache lists the bucket instead.
+  RTO  the duration of the site's most recent successful 'backup restore'
+       (a restore test), as recorded in the run history, must not exceed it.
+
+A site with a target but no backup or no recorded restore is reported as
+missing, which counts as a violation. Targets are Go durations (90m, 6h) or
+whole days and weeks (1d, 2w); a site inherits those of the host named in its
+entry:
+
+  hosts:
+    wp1.example.com: {rpo: 24h}
+  sites:
+    acme.com: {host: wp1.example.com, rpo: 6h, rto: 1h}
+
+The dashboard lists violations first and is printed as text, JSON or a
+standalone HTML page (--format). With --fail-on-violation the command exits
+with an error when any site is out of compliance, for alerting from cron.
+
+Examples:
+  ciwg-cli backup report compliance
+  ciwg-cli backup report compliance --group tier=gold --fail-on-violation
+  ciwg-cli backup report compliance --format html --out /var/www/status/backups.html
+  ciwg-cli backup report compliance --format json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupReportCompliance,
+}
+
+var backupExportInventoryCmd = &cobra.Command{
+	Use:   "export-inventory",
+	Short: "Export an inventory of every backup object as CSV or Parquet",
+	Long: `Walk the bucket and the run history (the ledger of Glacier copies) and emit one
+row per stored copy with its site, storage class (hot = Minio, cold = Glacier),
+size, timestamps, checksum (Minio ETag or Glacier SHA-256 tree hash) and
+verification status.
+
+Verification is 'unchecked' unless --verify is given, which downloads every hot
+object and checks that it is a complete gzip/tar stream. Cold copies cannot be
+read back without a Glacier retrieval job and are always reported as unchecked.
+
+Examples:
+  # CSV to stdout
+  ciwg-cli backup export-inventory
+
+  # Parquet file for the data warehouse
+  ciwg-cli backup export-inventory --format parquet --out inventory.parquet
+
+  # Verify every object for a single site
+  ciwg-cli backup export-inventory --prefix backups/mysite.com/ --verify --out mysite.csv
+
+  # Verify the gold-tier sites of the fleet file (see 'backup create --help')
+  ciwg-cli backup export-inventory --group tier=gold --verify --out gold.csv`,
+	Args: cobra.NoArgs,
+	RunE: runBackupExportInventory,
+}
+
+var backupExportBundleCmd = &cobra.Command{
+	Use:   "export-bundle",
+	Short: "Write a documented hand-over bundle of a site's backups",
+	Long: `Collect everything needed to hand a site's backups over to its owner, e.g. when
+offboarding a client, into one directory:
+
+  backup/               the newest complete backup, as stored or sanitized
+  manifest.json         the runtime manifest stored in the backup (container,
+                        image, PHP and WordPress versions, plugins)
+  inventory.csv         every historical backup copy of the site, in Minio and
+                        AWS Glacier (see 'backup export-inventory')
+  SUMMARY.md            what the bundle contains, with the checksum of each file
+  SUMMARY.md.sig        ed25519 signature of SUMMARY.md
+  signing-key.pub.pem   the public key to verify it with
+  SHA256SUMS            checksums of every file ('sha256sum -c SHA256SUMS');
+                        SHA512SUMS or B3SUMS with --checksum sha512 or blake3
+
+The summary lists the checksums of the other files, so its signature covers
+the whole bundle. The recipient verifies it with:
+
+  openssl pkeyutl -verify -pubin -inkey signing-key.pub.pem -rawin -in SUMMARY.md -sigfile SUMMARY.md.sig
+
+Create a signing key with 'openssl genpkey -algorithm ed25519 -out bundle-key.pem'.
+--sanitize applies the same rules as 'backup sanitize' and hands over only the
+sanitized copy; split and sharded backups cannot be sanitized.
+
+Examples:
+  # Bundle the newest backup of foo.com
+  ciwg-cli backup export-bundle --site foo.com --out ./foo.com-export --signing-key bundle-key.pem
+
+  # Hand over a sanitized copy with custom rules
+  ciwg-cli backup export-bundle --site foo.com --out ./foo.com-export --signing-key bundle-key.pem \
+    --sanitize --rules sanitize-rules.yml`,
+	Args: cobra.NoArgs,
+	RunE: runBackupExportBundle,
+}
+
+var backupAnalyzeCmd = &cobra.Command{
+	Use:   "analyze <object|site>",
+	Short: "Break down a backup's size by directory, file type and largest files",
+	Long: `Stream a backup from Minio and report where its uncompressed size goes: per
+top-level directory (wp-content children such as uploads and plugins are listed
+separately, SQL dumps are grouped as database), per file type, and the biggest
+files. Use it to decide on exclusions or to explain backup growth.
+
+The argument is either an object key, in which case every part of its backup set
+is analyzed, or a site name, which picks the site's newest complete backup.
+
+Examples:
+  # Analyze the newest backup of a site
+  ciwg-cli backup analyze example.com
+
+  # Analyze a specific backup and list the 50 biggest files as JSON
+  ciwg-cli backup analyze backups/example.com/example.com-20250101-020000.tgz --top 50 --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupAnalyze,
+}
+
+var backupDuplicatesCmd = &cobra.Command{
+	Use:   "duplicates [site...]",
+	Short: "Find sites whose backups are near-duplicates, such as staging clones",
+	Long: `Compare the newest complete backup of every site (or of the sites given) file
+by file and report the pairs that share at least --threshold of their content.
+Staging and dev clones are often backed up as full independent sites; a pair
+where one site is named like a clone of the other (staging.foo.com,
+foo-dev.com, ...) is reported with that site as the copy to exclude.
+
+Files are compared by checksum (--checksum), so a file renamed or moved between
+the sites still counts as shared. Similarity is the shared content over the
+content of the smaller site. Database parts are not compared. Every backup is
+streamed once, so limit the run to a few sites on large buckets.
+
+Examples:
+  # Check the whole bucket
+  ciwg-cli backup duplicates
+
+  # Compare two sites, reporting anything above 75% shared
+  ciwg-cli backup duplicates foo.com staging.foo.com --threshold 0.75 --json`,
+	RunE: runBackupDuplicates,
+}
+
+var backupDrillCmd = &cobra.Command{
+	Use:   "drill",
+	Short: "Restore randomly picked sites into a sandbox to prove backups restore",
+	Long: `Pick --sites sites at random, restore the latest complete backup of each into
+a throwaway sandbox directory without starting it, and check that files and a
+database dump came back. Each drill is recorded in the run history with its
+duration, result and issues.
+
+The command is meant to run from cron (e.g. hourly): it only drills when --every
+has passed since the last drill, and does nothing otherwise. --every and
+--sites default to the drill section of the fleet file:
+
+  drill:
+    every: 7d
+    sites: 3
+
+When a drill fails, or with --check when no drill succeeded within --every
+(plus a day of grace), the command posts to --alert-webhook and exits non-zero.
+
+Examples:
+  # Drill three random sites once a week (run from cron)
+  ciwg-cli backup drill --every 7d --sites 3
+
+  # Drill now, keeping the restored files for inspection
+  ciwg-cli backup drill --force --keep-sandbox --sandbox-dir /srv/drills
+
+  # Alert when drills are overdue
+  ciwg-cli backup drill --check --alert-webhook https://hooks.example.com/drills`,
+	Args: cobra.NoArgs,
+	RunE: runBackupDrill,
+}
+
+var backupNormalizeKeysCmd = &cobra.Command{
+	Use:   "normalize-keys",
+	Short: "Move backups of sites with unsafe names to normalized object keys",
+	Long: `Find sites whose directory names contain spaces, unicode or other characters
+that break shell tooling and URLs, or are longer than 80 characters, and move
+their backups to the key new backups of the site are written under: accents
+removed, other characters replaced by '-' (e.g. "Café Nuñez" becomes
+"Cafe-Nunez"). The site name in the file names is renamed too, and the
+original name is kept in the Ciwg-Site-Name metadata of the moved objects.
+
+When the normalized key is already used by another site, a hash of the site
+name is appended to it. Keys are claimed under .ciwg/site-keys/ so every host
+backing up the site resolves to the same key. Each object is copied, checked
+and only then removed from its old key, and existing objects are never
+overwritten; an interrupted run continues where it stopped when run again.
+
+Examples:
+  # Show what would move
+  ciwg-cli backup normalize-keys --dry-run
+
+  ciwg-cli backup normalize-keys --prefix backups/ --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupNormalizeKeys,
+}
+
+var backupIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Keep the bucket catalog and freshness gauges current from bucket notifications",
+	Long: `List --prefix once and store the result as the listing cache (the catalog
+commands run with --list-cache-ttl read instead of listing the bucket), and
+write the per-site freshness gauges to --freshness-metrics-file and/or
+--pushgateway-url together with gauges about the index. The bucket summary
+read by 'backup list --group-by site' is updated from the same listing.
+
+With --listen the command keeps running and follows object created and removed
+notifications instead of listing again, publishing the updated catalog and
+gauges at most every --publish-interval. Notifications come from:
+
+  --source minio     Minio's listen API; nothing to configure on the server
+  --source webhook   an HTTP server on --listen-addr for a Minio webhook target:
+                       mc admin config set ALIAS notify_webhook:ciwg \
+                         endpoint=http://indexer:9431/ auth_token=SECRET queue_dir=/data/events
+                       mc event add ALIAS/BUCKET arn:minio:sqs::ciwg:webhook \
+                         --event put,delete --prefix backups/
+                     with the same token in --webhook-token
+
+AMQP targets are not consumed directly; point Minio at the webhook instead, or
+use --source minio. The bucket is listed again every --resync-interval to pick
+up events missed while the listener was down. Stop it with Ctrl-C or SIGTERM;
+pending changes are published on the way out.
+
+Examples:
+  # Rebuild the catalog and gauges once, e.g. from cron
+  ciwg-cli backup index --freshness-metrics-file /var/lib/node_exporter/ciwg.prom
+
+  # Follow the bucket, pushing gauges to a Pushgateway
+  ciwg-cli backup index --listen --pushgateway-url http://pushgateway:9091
+
+  # Receive webhook notifications
+  ciwg-cli backup index --listen --source webhook --listen-addr :9431 --webhook-token "$TOKEN"`,
+	Args: cobra.NoArgs,
+	RunE: runBackupIndex,
+}
+
+var backupReconcileReplicaCmd = &cobra.Command{
+	Use:   "reconcile-replica",
+	Short: "Report divergence between the primary bucket and its DR replica",
+	Long: `Compare a prefix on the primary bucket with the same prefix on the DR replica
+named by --replica-profile (an entry in ~/.ciwg/minio-profiles.yaml) and report
+objects that were never replicated, objects whose sizes differ and objects that
+only remain on the replica.
+
+Objects only on the replica are expected for a write-once replica and reported
+as retained; with --propagate-deletes they mean a prune was not propagated and
+count as divergence. The command exits non-zero when the replica diverges.
+
+The replica and delete propagation can be set per backup profile:
+
+  replica:
+    profile: dr
+    propagate_deletes: false
+
+Examples:
+  # Check the whole backups/ tree against the DR replica
+  ciwg-cli backup reconcile-replica --replica-profile dr
+
+  # Check one site of a replica that mirrors deletions, as JSON
+  ciwg-cli backup reconcile-replica --replica-profile dr --propagate-deletes --prefix backups/mysite.com/ --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupReconcileReplica,
+}
+
+var backupAWSSetupCmd = &cobra.Command{
+	Use:   "aws-setup",
+	Short: "Check and set up the Glacier vault, its notifications and IAM permissions",
+	Long:  `Verify, and optionally put in place, what the Glacier commands rely on.`,
+}
+
+var backupAWSSetupVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the Glacier vault setup and the permissions of the AWS keys",
+	Long: `Check the Glacier setup the backup commands rely on:
+
+  vault             the vault exists in the region
+  notifications     an SNS topic is notified of completed archive retrieval and
+                    inventory jobs, so restores and 'aws-audit' can be automated
+                    instead of polled (--sns-topic requires that topic)
+  retrieval-policy  the region's data retrieval policy; without a BytesPerHour
+                    limit a large restore is billed in full, with FreeTier it
+                    is rejected (--retrieval-strategy requires one)
+  permissions       the keys may perform every Glacier action the commands use
+
+Permissions are probed with requests Glacier authorizes and then rejects
+(uploads with a wrong checksum, jobs and deletes of an archive that doesn't
+exist, a multipart upload that is aborted), so nothing in the vault changes.
+Each denied action is listed with the commands that need it, followed by an IAM
+policy granting them. The command exits with an error when a check fails.
+
+Examples:
+  ciwg-cli backup aws-setup verify
+  ciwg-cli backup aws-setup verify --sns-topic arn:aws:sns:us-east-1:123456789012:glacier-jobs
+  ciwg-cli backup aws-setup verify --retrieval-strategy BytesPerHour --bytes-per-hour 10737418240 --json`,
+	Args: cobra.NoArgs,
+	RunE: runBackupAWSSetupVerify,
+}
+
+var backupAWSSetupApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create the Glacier vault and configure its notifications and retrieval policy",
+	Long: `Create the vault if it doesn't exist, point its job notifications at
+--sns-topic and set the region's data retrieval policy to --retrieval-strategy,
+each only when requested and not already in place, then verify the setup as
+'aws-setup verify' does. IAM permissions are not changed; the verification
+prints the policy the keys are missing.
+
+Examples:
+  # Show what would change
+  ciwg-cli backup aws-setup apply --sns-topic arn:aws:sns:us-east-1:123456789012:glacier-jobs --dry-run
+
+  # Create the vault, notify the topic and cap retrievals at 10GB per hour
+  ciwg-cli backup aws-setup apply --sns-topic arn:aws:sns:us-east-1:123456789012:glacier-jobs \
+    --retrieval-strategy BytesPerHour --bytes-per-hour 10737418240`,
+	Args: cobra.NoArgs,
+	RunE: runBackupAWSSetupApply,
+}
+
+var backupAWSAuditCmd = &cobra.Command{
+	Use:   "aws-audit",
+	Short: "Reconcile a Glacier vault inventory with the backup ledger",
+	Long: `Compare a Glacier vault inventory against the ledger of Glacier uploads kept in
+the run history and report archives unknown to the ledger, ledger entries missing
+from the vault, and size or tree hash mismatches.
+
+Glacier cannot list a vault directly: an inventory job must be started first
+(--initiate) and its output fetched once complete (--job-id), usually 3-5 hours
+later. An inventory saved with 'aws glacier get-job-output' can be passed with
+--inventory instead. Ledger entries newer than the inventory are not reported as
+missing.
+
+Archives uploaded before the ledger existed only carry a "Backup: <key>" or
+"Migrated from Minio: <key>" description. Unknown archives with such a
+description are backfilled into the ledger first, with the site and object key
+parsed from the description, so they can be managed like recorded uploads. Use
+--no-backfill to report them as unknown instead.
+
+With --repair the ledger is rewritten to match the vault: entries for missing
+archives lose their Glacier record, sizes and hashes are taken from the vault,
+and unknown archives are adopted under a new run record.
+
+Examples:
+  # Start an inventory job
+  ciwg-cli backup aws-audit --initiate
+
+  # Audit once the job has completed
+  ciwg-cli backup aws-audit --job-id <job-id>
+
+  # Audit a saved inventory and repair the ledger
+  ciwg-cli backup aws-audit --inventory vault-inventory.json --repair`,
+	Args: cobra.NoArgs,
+	RunE: runBackupAWSAudit,
+}
+
+var backupGlacierRetrieveCmd = &cobra.Command{
+	Use:   "glacier-retrieve [object-key|archive-id]",
+	Short: "Retrieve an archive from Glacier within a cost budget",
+	Long: `Start a Glacier archive-retrieval job for a backup recorded in the Glacier ledger
+(the run history), or download the output of a completed job with --job-id.
+
+Retrievals are billed per GB and per request, so the cost is estimated from the
+archive size and --tier (expedited, standard or bulk; us-east-1 list prices
+unless --price-per-gb is given) before anything starts. A retrieval estimated
+above --confirm-above needs confirmation (or --yes). Started retrievals are
+recorded with their estimate in the retrieval ledger, and their sum for the
+calendar month is checked against --monthly-budget: a hard cap refuses a
+retrieval that would exceed it, a soft cap warns and asks for confirmation.
+
+Examples:
+  # Estimate a retrieval without starting it
+  ciwg-cli backup glacier-retrieve backups/example.com/example.com-20250101-020000.tgz --dry-run
+
+  # Retrieve with the Bulk tier, refusing to spend more than $20 this month
+  ciwg-cli backup glacier-retrieve backups/example.com/example.com-20250101-020000.tgz --tier bulk --monthly-budget 20
+
+  # Download the archive once the job has completed
+  ciwg-cli backup glacier-retrieve --job-id <job-id> --output example.com.tgz`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackupGlacierRetrieve,
+}
+
+var backupDiscoverCmd = &cobra.Command{
+	Use:   "discover [hostname...]",
+	Short: "List the sites backup create would find on a host",
+	Long: `Group the running containers on a host into sites the same way backup create
+does: by docker compose project, using the compose working_dir label, with the
+container overrides file consulted first. WordPress containers that have no
+working directory either way are listed as skipped.
+
+Containers started outside compose (or with the label stripped) can be mapped
+in the overrides file (~/.ciwg/container-overrides.yaml):
+
+  containers:
+    wp_legacy:
+      working_dir: /var/opt/sites/legacy.com
+      type: wordpress          # optional, default wordpress
+      parent_dir: /srv/old     # optional, replaces --container-parent-dir
+
+--write-overrides generates a starter file from the current docker state with
+every discovered site and a TODO entry for every skipped container. Fill in
+the TODO working directories before the next backup, as entries without one
+are rejected.
+
+--audit cross-references the directories under --container-parent-dir, the
+running sites and the site prefixes in the bucket, and reports:
+
+  never-backed-up               a directory with no backups
+  container-not-backed-up       a running site with no backups
+  directory-without-container   a directory no running site uses
+  container-outside-parent-dir  a running site outside --container-parent-dir
+  orphan-prefix                 a bucket prefix with no site on any audited
+                                host: a candidate for archiving or cleanup
+
+Pass every host of the fleet so that orphan prefixes are really orphaned.
+
+Examples:
+  # Show what would be backed up on a host
+  ciwg-cli backup discover wp0.ciwgserver.com
+
+  # Audit two hosts against the bucket, as JSON
+  ciwg-cli backup discover wp0.ciwgserver.com wp1.ciwgserver.com --audit --json
+
+  # Write a starter overrides file to ~/.ciwg/container-overrides.yaml
+  ciwg-cli backup discover wp0.ciwgserver.com --write-overrides
+
+  # Write it somewhere else, replacing an existing file
+  ciwg-cli backup discover --local --write-overrides ./overrides.yaml --force`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runBackupDiscover,
+}
+
+var backupInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up a backup profile interactively",
+	Long: `Walk through the Minio, AWS Glacier and SSH settings backup commands need,
+testing each connection as it is entered, and save them as a profile in
+~/.ciwg/profiles/<name>.yaml instead of a plaintext .env file.
+
+The profile is written with mode 0600 and is refused if other users can read
+it. The 'default' profile is applied automatically; others are selected with
+--profile or CIWG_BACKUP_PROFILE. Values from the environment and from flags
+always take precedence over the default profile.
+
+For automation, --from-answers reads the same settings from a YAML file
+instead of prompting. Secrets may be given there as *_env variable names:
+
+  name: default
+  ssh_test_host: wp0.ciwgserver.com   # optional
+  minio:
+    endpoint: minio.example.com:9000
+    access_key_env: MINIO_ACCESS_KEY
+    secret_key_env: MINIO_SECRET_KEY
+    bucket: backups
+  aws:                                # optional
+    vault: backups
+    region: us-east-1
+    access_key_env: AWS_ACCESS_KEY
+    secret_key_env: AWS_SECRET_ACCESS_KEY
+    # auth: chain                     # instance profile, SSO or web identity instead of keys
+  ssh:
+    user: deploy
+    key: ~/.ssh/id_ed25519
+
+Examples:
+  # Interactive setup of the default profile
+  ciwg-cli backup init
+
+  # A second profile for the staging cluster
+  ciwg-cli backup init --name staging
+  ciwg-cli backup list --profile staging
+
+  # Unattended setup from provisioning
+  ciwg-cli backup init --from-answers answers.yaml --force`,
+	Args: cobra.NoArgs,
+	RunE: runBackupInit,
+}
+
 func init() {
 	// Load .env early so getEnvWithDefault calls used during flag setup
 	// will see values from a local .env file in development.
@@ -278,6 +2011,17 @@ func init() {
 	// fall back to an explicit --env passed on the command line. If neither
 	// are available, call godotenv.Load() which will attempt to load a .env
 	// from the current working directory.
+	// A profile chosen with --profile (or CIWG_BACKUP_PROFILE) is applied
+	// first so it wins over .env files; the default profile, if any, only
+	// fills in what the .env files leave unset.
+	profile := findFlagArg(os.Args, "profile")
+	if profile == "" {
+		profile = os.Getenv("CIWG_BACKUP_PROFILE")
+	}
+	if profile != "" {
+		applyBackupProfile(profile, true, true)
+	}
+
 	const projectEnv = "/usr/local/bin/ciwg-cli-utils/.env"
 	if err := godotenv.Load(projectEnv); err == nil {
 		// loaded project-level .env successfully
@@ -289,8 +2033,29 @@ func init() {
 		}
 	}
 
+	if profile == "" {
+		applyBackupProfile(backup.DefaultBackupProfileName, false, false)
+	}
+
 	// Allow explicit env file via --env on the backup command and subcommands
 	BackupCmd.PersistentFlags().String("env", "", "Path to .env file to load (overrides defaults)")
+	BackupCmd.PersistentFlags().Bool("read-only", getEnvBoolWithDefault("BACKUP_READ_ONLY", false), "Refuse every operation that changes backups (create, delete, prune, migrate, restore, sync, maintenance); commands with --dry-run run as dry runs (env: BACKUP_READ_ONLY)")
+	BackupCmd.PersistentFlags().Duration("delete-hold", getEnvDurationWithDefault("BACKUP_DELETE_HOLD", backup.DefaultDeleteHold), "Never delete, prune or migrate-and-delete a backup object younger than this; at least 48h (env: BACKUP_DELETE_HOLD)")
+	BackupCmd.PersistentFlags().Bool("allow-young-deletes", false, "Switch off the --delete-hold window and allow deleting backups of any age")
+	BackupCmd.PersistentFlags().Bool("no-recovery-scan", getEnvBoolWithDefault("BACKUP_NO_RECOVERY_SCAN", false), "Skip the startup scan that removes temp files and state left by crashed runs (env: BACKUP_NO_RECOVERY_SCAN)")
+	BackupCmd.PersistentFlags().Duration("recovery-temp-age", getEnvDurationWithDefault("BACKUP_RECOVERY_TEMP_AGE", backup.DefaultRecoveryTempAge), "Age after which the startup scan removes Glacier buffers and other temp files (env: BACKUP_RECOVERY_TEMP_AGE)")
+	BackupCmd.PersistentFlags().Bool("audit-commands", getEnvBoolWithDefault("BACKUP_AUDIT_COMMANDS", false), "Record every shell command run on hosts in the command audit log (env: BACKUP_AUDIT_COMMANDS)")
+	BackupCmd.PersistentFlags().String("command-audit-file", getEnvWithDefault("BACKUP_COMMAND_AUDIT_FILE", ""), "Command audit log (default: ~/.ciwg/backup-command-audit.jsonl, env: BACKUP_COMMAND_AUDIT_FILE)")
+	BackupCmd.PersistentFlags().Int("audit-output-limit", getEnvIntWithDefault("BACKUP_AUDIT_OUTPUT_LIMIT", backup.DefaultCommandOutputLimit), "Bytes of each command's stdout and stderr kept in the audit log; -1 keeps none (env: BACKUP_AUDIT_OUTPUT_LIMIT)")
+	BackupCmd.PersistentFlags().Bool("trace-commands", getEnvBoolWithDefault("BACKUP_TRACE_COMMANDS", false), "Print every shell command run on hosts with its duration and exit status (env: BACKUP_TRACE_COMMANDS)")
+	BackupCmd.PersistentFlags().String("checksum", getEnvWithDefault("BACKUP_CHECKSUM", backup.ChecksumSHA256), "Checksum algorithm for verification, bundles and post-upload checks: sha256, sha512 or blake3 (env: BACKUP_CHECKSUM)")
+	BackupCmd.PersistentFlags().String("freshness-metrics-file", getEnvWithDefault("BACKUP_FRESHNESS_METRICS_FILE", ""), "Write per-site backup freshness gauges in Prometheus text format to this file on list, create and monitor runs (env: BACKUP_FRESHNESS_METRICS_FILE)")
+	BackupCmd.PersistentFlags().String("pushgateway-url", getEnvWithDefault("BACKUP_PUSHGATEWAY_URL", ""), "Push per-site backup freshness gauges to this Prometheus Pushgateway on list, create and monitor runs (env: BACKUP_PUSHGATEWAY_URL)")
+	BackupCmd.PersistentFlags().String("freshness-prefix", getEnvWithDefault("BACKUP_FRESHNESS_PREFIX", "backups/"), "Prefix whose site directories the freshness gauges cover (env: BACKUP_FRESHNESS_PREFIX)")
+	BackupCmd.PersistentFlags().String("timezone", getEnvWithDefault("BACKUP_TIMEZONE", backup.DefaultTimezone), "Canonical timezone for backup names, retention weekdays, backup windows and printed times: an IANA name, UTC or local (env: BACKUP_TIMEZONE)")
+	BackupCmd.PersistentFlags().Bool("low-memory", getEnvBoolWithDefault("BACKUP_LOW_MEMORY", false), "Cap upload part size, parallelism and buffers for hosts with little RAM, at the cost of speed (env: BACKUP_LOW_MEMORY)")
+	BackupCmd.PersistentFlags().Bool("fips", getEnvBoolWithDefault("BACKUP_FIPS", false), "Allow only FIPS-approved checksums and TLS settings (env: BACKUP_FIPS)")
+	BackupCmd.PersistentFlags().String("profile", "", "Backup profile written by 'backup init' (default: the 'default' profile when present, env: CIWG_BACKUP_PROFILE)")
 	BackupCmd.AddCommand(backupCreateCmd)
 	BackupCmd.AddCommand(backupTestMinioCmd)
 	BackupCmd.AddCommand(backupTestAWSCmd)
@@ -302,6 +2067,49 @@ func init() {
 	BackupCmd.AddCommand(backupDeleteCmd)
 	BackupCmd.AddCommand(backupMigrateAWSCmd)
 	BackupCmd.AddCommand(backupEstimateCapacityCmd)
+	BackupCmd.AddCommand(backupRestoreDBCmd)
+	BackupCmd.AddCommand(backupRestoreCmd)
+	BackupCmd.AddCommand(backupRetryPendingCmd)
+	BackupCmd.AddCommand(backupUploadSpoolCmd)
+	BackupCmd.AddCommand(backupReconcileCmd)
+	BackupCmd.AddCommand(backupGCCmd)
+	BackupCmd.AddCommand(backupImportCmd)
+	BackupCmd.AddCommand(backupVerifyCmd)
+	BackupCmd.AddCommand(backupBenchCmd)
+	BackupCmd.AddCommand(backupSyncCmd)
+	BackupCmd.AddCommand(backupEstimateCmd)
+	backupEstimateCmd.AddCommand(backupEstimateCalibrateCmd)
+	BackupCmd.AddCommand(backupRetentionCmd)
+	backupRetentionCmd.AddCommand(backupRetentionShowPresetsCmd, backupRetentionCompareCmd)
+	BackupCmd.AddCommand(backupLifecycleCmd)
+	backupLifecycleCmd.AddCommand(backupLifecycleExportCmd)
+	BackupCmd.AddCommand(backupReportCmd)
+	backupReportCmd.AddCommand(backupReportPerformanceCmd)
+	backupReportCmd.AddCommand(backupReportComplianceCmd)
+	BackupCmd.AddCommand(backupExportInventoryCmd)
+	BackupCmd.AddCommand(backupExportBundleCmd)
+	BackupCmd.AddCommand(backupAWSAuditCmd)
+	BackupCmd.AddCommand(backupGlacierRetrieveCmd)
+	BackupCmd.AddCommand(backupAWSSetupCmd)
+	backupAWSSetupCmd.AddCommand(backupAWSSetupVerifyCmd)
+	backupAWSSetupCmd.AddCommand(backupAWSSetupApplyCmd)
+	BackupCmd.AddCommand(backupDiscoverCmd)
+	BackupCmd.AddCommand(backupInitCmd)
+	BackupCmd.AddCommand(backupAnalyzeCmd)
+	BackupCmd.AddCommand(backupDuplicatesCmd)
+	BackupCmd.AddCommand(backupReconcileReplicaCmd)
+	BackupCmd.AddCommand(backupCacheLatestCmd)
+	BackupCmd.AddCommand(backupMaintenanceCmd)
+	backupMaintenanceCmd.AddCommand(backupMaintenanceSetCmd, backupMaintenanceClearCmd, backupMaintenanceListCmd)
+	BackupCmd.AddCommand(backupPrunePlanCmd)
+	backupPrunePlanCmd.AddCommand(backupPrunePlanCreateCmd, backupPrunePlanListCmd, backupPrunePlanShowCmd, backupPrunePlanExecuteCmd)
+	BackupCmd.AddCommand(backupRunsCmd)
+	backupRunsCmd.AddCommand(backupRunsShowCmd)
+	BackupCmd.AddCommand(backupFeaturesCmd)
+	backupFeaturesCmd.AddCommand(backupFeaturesListCmd)
+	BackupCmd.AddCommand(backupDrillCmd)
+	BackupCmd.AddCommand(backupNormalizeKeysCmd)
+	BackupCmd.AddCommand(backupIndexCmd)
 
 	initCreateFlags()
 	initTestMinioFlags()
@@ -314,6 +2122,46 @@ func init() {
 	initSanitizeFlags()
 	initMigrateAWSFlags()
 	initEstimateCapacityFlags()
+	initRestoreDBFlags()
+	initRestoreFlags()
+	initRetryPendingFlags()
+	initReconcileFlags()
+	initGCFlags()
+	initImportFlags()
+	initVerifyFlags()
+	initBenchFlags()
+	initRetentionFlags()
+	initLifecycleFlags()
+	initSyncFlags()
+	initEstimateCalibrateFlags()
+	initReportPerformanceFlags()
+	initReportComplianceFlags()
+	initExportInventoryFlags()
+	initExportBundleFlags()
+	initAWSAuditFlags()
+	initGlacierRetrieveFlags()
+	initAWSSetupFlags()
+	initDiscoverFlags()
+	initInitFlags()
+	initAnalyzeFlags()
+	initDuplicatesFlags()
+	initReconcileReplicaFlags()
+	initCacheLatestFlags()
+	initMaintenanceFlags()
+	initFeaturesFlags()
+	initPrunePlanFlags()
+	initRunsFlags()
+	initDrillFlags()
+	initNormalizeKeysFlags()
+	initIndexFlags()
+	initOperationGates()
+	flushSummariesAfterRun(BackupCmd)
+
+	registerKeyCompletion(
+		[]*cobra.Command{backupReadCmd, backupDeleteCmd, backupRestoreDBCmd, backupRestoreCmd, backupAnalyzeCmd},
+		[]*cobra.Command{backupReadCmd, backupListCmd, backupDeleteCmd, backupMigrateAWSCmd, backupRestoreDBCmd, backupRestoreCmd,
+			backupSyncCmd, backupCacheLatestCmd, backupEstimateCalibrateCmd, backupExportInventoryCmd, backupExportBundleCmd, backupReconcileReplicaCmd},
+	)
 }
 
 func initCreateFlags() {
@@ -321,18 +2169,29 @@ func initCreateFlags() {
 	backupCreateCmd.Flags().Int("log-level", 1, "Logging level: 0=quiet, 1=normal, 2=verbose, 3=debug, 4=trace (or use -v/-vv/-vvv/-vvvv, env: BACKUP_LOG_LEVEL)")
 	backupCreateCmd.Flags().CountP("vflag", "v", "Increase verbosity (-v=verbose, -vv=debug, -vvv=trace, -vvvv=ultra-trace)")
 	backupCreateCmd.Flags().Bool("dry-run", false, "Print actions without executing them")
-	backupCreateCmd.Flags().String("estimate-method", "", "Compression estimation method for dry-run: 'heuristic' (instant, ~80% accurate), 'sample' (fast, ~90% accurate), 'accurate' (same speed as backup, 100% accurate)")
-	backupCreateCmd.Flags().Int64("sample-size", 100*1024*1024, "Sample size in bytes for 'sample' estimation method (default: 100MB)")
+	backupCreateCmd.Flags().String("estimate-method", "", "Compression estimation method for dry-run: 'heuristic' (instant, ~80% accurate), 'sample' (fast, ~90% accurate), 'adaptive' (samples until --sample-target), 'accurate' (same speed as backup, 100% accurate)")
+	backupCreateCmd.Flags().Int64("sample-size", 100*1024*1024, "Sample size in bytes for 'sample' estimation method, and of the first round of 'adaptive' (default: 100MB)")
+	backupCreateCmd.Flags().Float64("sample-target", getEnvFloat64WithDefault("BACKUP_SAMPLE_TARGET", backup.DefaultSampleTarget), "Relative 95% confidence interval 'adaptive' estimation samples until, e.g. 0.05 for ±5% (env: BACKUP_SAMPLE_TARGET)")
+	backupCreateCmd.Flags().Int64("max-sample-size", backup.DefaultMaxSampleSize, "Bytes 'adaptive' estimation compresses per site at most (default: 1GB)")
+	addCompressionModelFlag(backupCreateCmd)
 	backupCreateCmd.Flags().Bool("delete", false, "Stop and remove containers, and delete associated directories after backup")
 	backupCreateCmd.Flags().String("container-name", "", "Pipe-delimited container names or working directories to process (e.g. wp_foo|wp_bar|/srv/foo)")
 	backupCreateCmd.Flags().String("container-names", "", "Comma-delimited container names to process (e.g. wp_foo,wp_bar)")
 	backupCreateCmd.Flags().Bool("local", false, "Run backups locally using host's Docker instead of SSH")
+	addDockerEndpointFlags(backupCreateCmd)
 	backupCreateCmd.Flags().String("container-file", "", "File with newline-delimited container names or working directories to process")
 	backupCreateCmd.Flags().String("container-parent-dir", "/var/opt/sites", "Parent directory where site working directories live (default: /var/opt/sites)")
+	backupCreateCmd.Flags().String("status-socket", getEnvWithDefault("BACKUP_STATUS_SOCKET", ""), "Unix socket serving the live run status as JSON; SIGUSR1 prints it to stderr either way (env: BACKUP_STATUS_SOCKET)")
+	addGroupFlags(backupCreateCmd)
+	addResidencyFlags(backupCreateCmd)
+	backupCreateCmd.Flags().String("overrides-file", getEnvWithDefault("BACKUP_CONTAINER_OVERRIDES", ""), "YAML file mapping containers to working directories, used before docker inspection (default: ~/.ciwg/container-overrides.yaml, env: BACKUP_CONTAINER_OVERRIDES)")
+	backupCreateCmd.Flags().String("discovery", getEnvWithDefault("BACKUP_DISCOVERY", backup.DiscoveryCompose), "How to find sites when no containers are given: 'compose' (project labels) or 'prefix' (wp_ names) (env: BACKUP_DISCOVERY)")
 	backupCreateCmd.Flags().String("server-range", "", "Server range pattern (e.g., 'wp%d.example.com:0-41')")
 	backupCreateCmd.Flags().Bool("prune", false, "After creating backup, delete all old backups except the N most recent (configure N with --remainder)")
 	backupCreateCmd.Flags().Int("remainder", 5, "Number of most recent backups to keep when using --prune (default: 5)")
 	backupCreateCmd.Flags().Bool("clean-aws", false, "Also clean up old backups from AWS S3 when using --prune (default: false, only cleans Minio)")
+	backupCreateCmd.Flags().Bool("prune-plan", getEnvBoolWithDefault("BACKUP_PRUNE_PLAN", false), "Prune in two phases: store a plan of the objects to delete in the bucket, then delete from it (see 'backup prune-plan --help') (env: BACKUP_PRUNE_PLAN)")
+	backupCreateCmd.Flags().Bool("prune-plan-only", false, "With --prune, only store the prune plan; run it later with 'backup prune-plan execute'")
 
 	// Smart retention flags
 	backupCreateCmd.Flags().Bool("smart-retention", getEnvBoolWithDefault("BACKUP_SMART_RETENTION", false), "Enable date-aware retention (preserves weekly/monthly from daily backups, env: BACKUP_SMART_RETENTION)")
@@ -341,10 +2200,53 @@ func initCreateFlags() {
 	backupCreateCmd.Flags().Int("keep-monthly", getEnvIntWithDefault("BACKUP_KEEP_MONTHLY", 6), "Monthly backups to keep with smart retention (default: 6, env: BACKUP_KEEP_MONTHLY)")
 	backupCreateCmd.Flags().Int("weekly-day", getEnvIntWithDefault("BACKUP_WEEKLY_DAY", 0), "Day of week for weekly backups, 0=Sunday (default: 0, env: BACKUP_WEEKLY_DAY)")
 	backupCreateCmd.Flags().Int("monthly-day", getEnvIntWithDefault("BACKUP_MONTHLY_DAY", 1), "Day of month for monthly backups (default: 1, env: BACKUP_MONTHLY_DAY)")
+	addRetentionPresetFlags(backupCreateCmd)
 
 	backupCreateCmd.Flags().Bool("respect-capacity-limit", getEnvBoolWithDefault("BACKUP_RESPECT_CAPACITY_LIMIT", false), "Check storage capacity before creating backup (env: BACKUP_RESPECT_CAPACITY_LIMIT)")
 	backupCreateCmd.Flags().Float64("capacity-threshold", getEnvFloat64WithDefault("BACKUP_CAPACITY_THRESHOLD", 95.0), "Storage capacity threshold percentage (default: 95.0, env: BACKUP_CAPACITY_THRESHOLD)")
 	backupCreateCmd.Flags().Bool("include-aws-glacier", getEnvBoolWithDefault("BACKUP_INCLUDE_AWS_GLACIER", false), "Upload backups to AWS Glacier in addition to Minio (env: BACKUP_INCLUDE_AWS_GLACIER)")
+	backupCreateCmd.Flags().Int("global-max-uploads", getEnvIntWithDefault("BACKUP_GLOBAL_MAX_UPLOADS", 0), "Maximum concurrent uploads across the whole fleet, coordinated via lock objects in the bucket; 0 disables (env: BACKUP_GLOBAL_MAX_UPLOADS)")
+	backupCreateCmd.Flags().String("global-lock-prefix", getEnvWithDefault("BACKUP_GLOBAL_LOCK_PREFIX", backup.DefaultUploadSemaphorePrefix), "Bucket prefix for fleet-wide upload semaphore tickets, under .locks/ (env: BACKUP_GLOBAL_LOCK_PREFIX)")
+	backupCreateCmd.Flags().Duration("global-lock-ttl", getEnvDurationWithDefault("BACKUP_GLOBAL_LOCK_TTL", 10*time.Minute), "Time after which a semaphore ticket without heartbeat is considered abandoned (env: BACKUP_GLOBAL_LOCK_TTL)")
+	backupCreateCmd.Flags().Duration("global-lock-max-wait", getEnvDurationWithDefault("BACKUP_GLOBAL_LOCK_MAX_WAIT", 0), "Maximum time to wait for a fleet-wide upload slot; 0 waits indefinitely (env: BACKUP_GLOBAL_LOCK_MAX_WAIT)")
+	backupCreateCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used for throughput reports (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupCreateCmd.Flags().Bool("no-history", false, "Do not record this run in the history file")
+	backupCreateCmd.Flags().Bool("no-run-artifacts", getEnvBoolWithDefault("BACKUP_NO_RUN_ARTIFACTS", false), "Do not store the run summary, validation report and audited commands under _meta/ in the bucket (env: BACKUP_NO_RUN_ARTIFACTS)")
+	backupCreateCmd.Flags().Int("run-artifacts-retention-days", getEnvIntWithDefault("BACKUP_RUN_ARTIFACTS_RETENTION_DAYS", int(backup.DefaultRunArtifactRetention/(24*time.Hour))), "Days to keep run artifacts under _meta/; 0 keeps them forever (env: BACKUP_RUN_ARTIFACTS_RETENTION_DAYS)")
+	backupCreateCmd.Flags().String("blackout", getEnvWithDefault("BACKUP_BLACKOUT", ""), "Comma-separated HH:MM-HH:MM ranges when backups must not run, e.g. 08:00-20:00 (env: BACKUP_BLACKOUT)")
+	backupCreateCmd.Flags().String("window-timezone", getEnvWithDefault("BACKUP_WINDOW_TZ", ""), "IANA timezone for --blackout ranges (default: --timezone, env: BACKUP_WINDOW_TZ)")
+	backupCreateCmd.Flags().String("window-file", getEnvWithDefault("BACKUP_WINDOW_FILE", ""), "Per-host backup windows YAML (default: ~/.ciwg/backup-windows.yaml, env: BACKUP_WINDOW_FILE)")
+	backupCreateCmd.Flags().String("window-action", getEnvWithDefault("BACKUP_WINDOW_ACTION", backup.WindowActionAbort), "When started inside a blackout: abort or wait (env: BACKUP_WINDOW_ACTION)")
+	backupCreateCmd.Flags().Bool("no-resume", false, "Ignore and do not write resume tokens for runs paused by a blackout")
+	backupCreateCmd.Flags().Bool("no-facts", getEnvBoolWithDefault("BACKUP_NO_FACTS", false), "Do not record runtime facts (image digest, PHP/WP/plugin versions, kernel) in the backup manifest and object metadata (env: BACKUP_NO_FACTS)")
+	backupCreateCmd.Flags().String("host-config-file", getEnvWithDefault("BACKUP_HOST_CONFIG", ""), "YAML listing crontab users and host config paths archived next to each backup (default: ~/.ciwg/host-config.yaml, env: BACKUP_HOST_CONFIG)")
+	backupCreateCmd.Flags().Bool("no-host-config", getEnvBoolWithDefault("BACKUP_NO_HOST_CONFIG", false), "Do not capture host config even when the host-config file exists (env: BACKUP_NO_HOST_CONFIG)")
+	backupCreateCmd.Flags().Bool("skip-offloaded-uploads", getEnvBoolWithDefault("BACKUP_SKIP_OFFLOADED_UPLOADS", false), "Leave wp-content/uploads out of sites whose media an offload plugin (WP Offload Media, Media Cloud, WP-Stateless) keeps in object storage; the bucket is recorded in the manifest (env: BACKUP_SKIP_OFFLOADED_UPLOADS)")
+	backupCreateCmd.Flags().String("export-freeze", getEnvWithDefault("BACKUP_EXPORT_FREEZE", backup.ExportFreezeNone), "Freeze WordPress sites while their database is exported: none, maintenance (wp maintenance-mode) or plugin:<slug> (a read-only plugin); switched off again whether the export succeeds or fails (env: BACKUP_EXPORT_FREEZE)")
+	backupCreateCmd.Flags().Bool("exclude-git", getEnvBoolWithDefault("BACKUP_EXCLUDE_GIT", false), "Leave the .git directory of sites deployed from git out of the archive; the commit, branch and dirty state are still recorded in the manifest (env: BACKUP_EXCLUDE_GIT)")
+	backupCreateCmd.Flags().Duration("export-timeout", getEnvDurationWithDefault("BACKUP_EXPORT_TIMEOUT", 0), "Abort a container whose database export runs longer than this, killing the export in the container; 0 waits indefinitely (env: BACKUP_EXPORT_TIMEOUT)")
+	backupCreateCmd.Flags().Duration("tar-timeout", getEnvDurationWithDefault("BACKUP_TAR_TIMEOUT", 0), "Abort a container whose tarball takes longer than this to write, killing tar and removing the partial upload; 0 waits indefinitely (env: BACKUP_TAR_TIMEOUT)")
+	backupCreateCmd.Flags().Duration("upload-timeout", getEnvDurationWithDefault("BACKUP_UPLOAD_TIMEOUT", 0), "Abort a container whose upload takes longer than this, aborting the multipart upload and removing the partial object; 0 waits indefinitely (env: BACKUP_UPLOAD_TIMEOUT)")
+	backupCreateCmd.Flags().Duration("container-timeout", getEnvDurationWithDefault("BACKUP_CONTAINER_TIMEOUT", 0), "Abort a container whose whole backup takes longer than this; 0 waits indefinitely (env: BACKUP_CONTAINER_TIMEOUT)")
+	backupCreateCmd.Flags().String("nested-archive-threshold", getEnvWithDefault("BACKUP_NESTED_ARCHIVE_THRESHOLD", "100MB"), "Archives (tarballs, zips, .wpress, .jpa and other backup exports) at least this large inside a site directory are left out of its backup and listed for cleanup; 0 disables the check (env: BACKUP_NESTED_ARCHIVE_THRESHOLD)")
+	backupCreateCmd.Flags().Bool("include-nested-archives", getEnvBoolWithDefault("BACKUP_INCLUDE_NESTED_ARCHIVES", false), "Keep the archives found by --nested-archive-threshold in the backup; they are still listed (env: BACKUP_INCLUDE_NESTED_ARCHIVES)")
+	backupCreateCmd.Flags().String("minio-compression", getEnvWithDefault("BACKUP_MINIO_COMPRESSION", ""), "Compression of Minio backups: gzip or gzip-1..9, with +rsyncable for dedup-friendly output, e.g. gzip-6+rsyncable (default: tar's gzip, env: BACKUP_MINIO_COMPRESSION)")
+	backupCreateCmd.Flags().String("glacier-compression", getEnvWithDefault("BACKUP_GLACIER_COMPRESSION", ""), "Compression of the Glacier copy with --include-aws-glacier, e.g. zstd-19 or gzip-9; re-compressed from the Minio stream when it differs (default: same as Minio, env: BACKUP_GLACIER_COMPRESSION)")
+	backupCreateCmd.Flags().String("archive-order", getEnvWithDefault("BACKUP_ARCHIVE_ORDER", backup.ArchiveOrderWalk), "Order of entries in the tarball: walk (tar's directory order) or smart (grouped by extension, then directory, for a better ratio) (env: BACKUP_ARCHIVE_ORDER)")
+	backupCreateCmd.Flags().Duration("scan-cache-ttl", getEnvDurationWithDefault("BACKUP_SCAN_CACHE_TTL", backup.DefaultScanCacheTTL), "Reuse a site's file scan across sizing, estimation and the smart-order tar walk for this long; 0 walks the tree in every phase (env: BACKUP_SCAN_CACHE_TTL)")
+	backupCreateCmd.Flags().String("post-upload-check", getEnvWithDefault("BACKUP_POST_UPLOAD_CHECK", backup.PostUploadCheckNone), "Validate each tarball after upload: none, quick (size plus head/tail ranged reads) or full (read back, checksum and tar walk) (env: BACKUP_POST_UPLOAD_CHECK)")
+	backupCreateCmd.Flags().Int("warning-threshold", getEnvIntWithDefault("BACKUP_WARNING_THRESHOLD", backup.DefaultTarWarningThreshold), "Tar warnings (changed, removed or unreadable files, sockets) after which the run is reported as completed with warnings; 0 never (env: BACKUP_WARNING_THRESHOLD)")
+	backupCreateCmd.Flags().String("require", getEnvWithDefault("BACKUP_REQUIRE", backup.RequireMinio), "Destinations that must succeed with --include-aws-glacier: minio, glacier, both or any (env: BACKUP_REQUIRE)")
+	backupCreateCmd.Flags().String("pending-file", getEnvWithDefault("BACKUP_PENDING_FILE", ""), "Queue of destinations missed by dual uploads, for 'backup retry-pending' (default: ~/.ciwg/pending-uploads.jsonl, env: BACKUP_PENDING_FILE)")
+	backupCreateCmd.Flags().Bool("spool", getEnvBoolWithDefault("BACKUP_SPOOL", false), "Write archives to the local spool instead of the bucket, for 'backup upload-spool' (env: BACKUP_SPOOL)")
+	backupCreateCmd.Flags().String("spool-dir", getEnvWithDefault("BACKUP_SPOOL_DIR", ""), "Spool directory for --spool (default: ~/.ciwg/spool, env: BACKUP_SPOOL_DIR)")
+	backupCreateCmd.Flags().String("spool-max-size", getEnvWithDefault("BACKUP_SPOOL_MAX_SIZE", "0"), "Size the spool may grow to; a backup that does not fit is uploaded directly, 0 for no limit (env: BACKUP_SPOOL_MAX_SIZE)")
+	backupCreateCmd.Flags().String("failure-webhook", getEnvWithDefault("BACKUP_FAILURE_WEBHOOK", ""), "URL that receives a JSON POST listing failed containers with error codes and remediation hints (env: BACKUP_FAILURE_WEBHOOK)")
+	backupCreateCmd.Flags().String("pagerduty-routing-key", getEnvWithDefault("BACKUP_PAGERDUTY_ROUTING_KEY", ""), "PagerDuty Events API v2 routing key; opens an incident per site after repeated failures and resolves it on success (env: BACKUP_PAGERDUTY_ROUTING_KEY)")
+	backupCreateCmd.Flags().String("opsgenie-api-key", getEnvWithDefault("BACKUP_OPSGENIE_API_KEY", ""), "Opsgenie API integration key; opens an alert per site after repeated failures and closes it on success (env: BACKUP_OPSGENIE_API_KEY)")
+	backupCreateCmd.Flags().String("opsgenie-api-url", getEnvWithDefault("BACKUP_OPSGENIE_API_URL", backup.DefaultOpsgenieURL), "Opsgenie API base URL, e.g. https://api.eu.opsgenie.com (env: BACKUP_OPSGENIE_API_URL)")
+	backupCreateCmd.Flags().Int("incident-threshold", getEnvIntWithDefault("BACKUP_INCIDENT_THRESHOLD", backup.DefaultIncidentThreshold), "Consecutive failed backups of a site that open an incident (env: BACKUP_INCIDENT_THRESHOLD)")
+	backupCreateCmd.Flags().String("incident-state-file", getEnvWithDefault("BACKUP_INCIDENT_STATE_FILE", ""), "Failure streaks and open incidents per site (default: ~/.ciwg/backup-incidents.json, env: BACKUP_INCIDENT_STATE_FILE)")
 
 	// Custom container / config file flags
 	backupCreateCmd.Flags().String("config-file", "", "Path to YAML configuration file for custom backup configurations")
@@ -363,6 +2265,10 @@ func initCreateFlags() {
 	backupCreateCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupCreateCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupCreateCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupCreateCmd)
+	addMinioUploadFlags(backupCreateCmd)
+	addMinioListingFlags(backupCreateCmd)
+	addReplicaFlags(backupCreateCmd)
 	backupCreateCmd.Flags().String("bucket-path", getEnvWithDefault("MINIO_BUCKET_PATH", ""), "Path prefix within Minio bucket (e.g., 'production/backups', env: MINIO_BUCKET_PATH)")
 
 	// AWS S3 configuration flags with environment variable support
@@ -370,8 +2276,12 @@ func initCreateFlags() {
 	backupCreateCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID, default: -)")
 	backupCreateCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
 	backupCreateCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupCreateCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
 	backupCreateCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION, default: us-east-1)")
 	backupCreateCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	backupCreateCmd.Flags().Bool("aws-verify", getEnvBoolWithDefault("AWS_GLACIER_VERIFY", false), "Re-hash buffered data after each Glacier upload and fail on a checksum mismatch, keeping the Minio copy (env: AWS_GLACIER_VERIFY)")
+	addAWSTLSFlags(backupCreateCmd)
+	addTempBudgetFlags(backupCreateCmd)
 
 	// SSH connection flags with environment variable support
 	backupCreateCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER, default: current user)")
@@ -381,6 +2291,38 @@ func initCreateFlags() {
 	backupCreateCmd.Flags().DurationP("timeout", "t", getEnvDurationWithDefault("SSH_TIMEOUT", 30*time.Second), "Connection timeout (env: SSH_TIMEOUT)")
 }
 
+func initInitFlags() {
+	backupInitCmd.Flags().String("name", backup.DefaultBackupProfileName, "Profile name")
+	backupInitCmd.Flags().String("from-answers", "", "Read the settings from this YAML file instead of prompting")
+	backupInitCmd.Flags().Bool("skip-tests", false, "Save the profile without testing the connections")
+	backupInitCmd.Flags().Bool("force", false, "Replace an existing profile without asking")
+}
+
+func initDiscoverFlags() {
+	backupDiscoverCmd.Flags().Bool("local", false, "Inspect the local Docker instead of connecting over SSH")
+	addDockerEndpointFlags(backupDiscoverCmd)
+	backupDiscoverCmd.Flags().String("overrides-file", getEnvWithDefault("BACKUP_CONTAINER_OVERRIDES", ""), "Container overrides file (default: ~/.ciwg/container-overrides.yaml, env: BACKUP_CONTAINER_OVERRIDES)")
+	backupDiscoverCmd.Flags().String("write-overrides", "", "Write a starter overrides file to this path (bare flag: the --overrides-file path)")
+	backupDiscoverCmd.Flags().Lookup("write-overrides").NoOptDefVal = writeOverridesDefault
+	backupDiscoverCmd.Flags().Bool("force", false, "Overwrite an existing file with --write-overrides")
+	backupDiscoverCmd.Flags().Bool("audit", false, "Report site directories, running sites and bucket prefixes that do not match up")
+	backupDiscoverCmd.Flags().Bool("json", false, "Print the --audit report as JSON")
+	backupDiscoverCmd.Flags().String("container-parent-dir", "/var/opt/sites", "Parent directory where site working directories live, for --audit (default: /var/opt/sites)")
+	backupDiscoverCmd.Flags().String("prefix", "backups/", "Prefix the site prefixes are under, for --audit")
+	backupDiscoverCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupDiscoverCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupDiscoverCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupDiscoverCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupDiscoverCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupDiscoverCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupDiscoverCmd)
+	backupDiscoverCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER, default: current user)")
+	backupDiscoverCmd.Flags().StringP("port", "p", getEnvWithDefault("SSH_PORT", "22"), "SSH port (env: SSH_PORT)")
+	backupDiscoverCmd.Flags().StringP("key", "k", getEnvWithDefault("SSH_KEY", ""), "Path to SSH private key (env: SSH_KEY)")
+	backupDiscoverCmd.Flags().BoolP("agent", "a", getEnvBoolWithDefault("SSH_AGENT", true), "Use SSH agent (env: SSH_AGENT)")
+	backupDiscoverCmd.Flags().DurationP("timeout", "t", getEnvDurationWithDefault("SSH_TIMEOUT", 30*time.Second), "Connection timeout (env: SSH_TIMEOUT)")
+}
+
 func initTestMinioFlags() {
 	backupTestMinioCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
 	backupTestMinioCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
@@ -388,6 +2330,7 @@ func initTestMinioFlags() {
 	backupTestMinioCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupTestMinioCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupTestMinioCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupTestMinioCmd)
 }
 
 func initTestAWSFlags() {
@@ -395,8 +2338,10 @@ func initTestAWSFlags() {
 	backupTestAWSCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID, default: -)")
 	backupTestAWSCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
 	backupTestAWSCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupTestAWSCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
 	backupTestAWSCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION, default: us-east-1)")
 	backupTestAWSCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	addAWSTLSFlags(backupTestAWSCmd)
 }
 
 func initReadFlags() {
@@ -404,24 +2349,30 @@ func initReadFlags() {
 	backupReadCmd.Flags().Bool("save", false, "Save backup object to current working directory (same as --output <basename>)")
 	backupReadCmd.Flags().String("prefix", "", "Prefix to search for when using --latest (e.g. backups/site-)")
 	backupReadCmd.Flags().Bool("latest", false, "If set, resolve the most recent object matching --prefix when object argument is omitted")
+	addCacheFlags(backupReadCmd)
 	backupReadCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
 	backupReadCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
 	backupReadCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
 	backupReadCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupReadCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupReadCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupReadCmd)
 }
 
 func initListFlags() {
 	backupListCmd.Flags().String("prefix", "", "Prefix to filter listed objects (e.g. backups/site-)")
 	backupListCmd.Flags().Int("limit", 100, "Maximum number of objects to list")
 	backupListCmd.Flags().Bool("json", false, "Output JSON")
+	backupListCmd.Flags().String("group-by", "", "Print totals per 'site' instead of objects, from the bucket summary")
+	addBucketSummaryFlags(backupListCmd)
 	backupListCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
 	backupListCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
 	backupListCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
 	backupListCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupListCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupListCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupListCmd)
+	addMinioListingFlags(backupListCmd)
 }
 
 func initDeleteFlags() {
@@ -433,12 +2384,18 @@ func initDeleteFlags() {
 	backupDeleteCmd.Flags().String("delete-range", "", "Delete backups by numeric range (e.g., '1-10' for 1st through 10th most recent)")
 	backupDeleteCmd.Flags().String("delete-range-by-date", "", "Delete backups by date range (YYYYMMDD-YYYYMMDD or YYYYMMDD:HHMMSS-YYYYMMDD:HHMMSS)")
 	backupDeleteCmd.Flags().Bool("skip-confirmation", false, "Skip interactive confirmation prompt")
+	backupDeleteCmd.Flags().String("run", "", "Delete exactly the objects produced by a recorded 'backup create' run (see run history)")
+	backupDeleteCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used by --run (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupDeleteCmd.Flags().Bool("allow-last", false, "With --run, also delete objects that are the last remaining backup of their site")
 	backupDeleteCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
 	backupDeleteCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
 	backupDeleteCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
 	backupDeleteCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupDeleteCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupDeleteCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupDeleteCmd)
+	addMinioListingFlags(backupDeleteCmd)
+	addReplicaFlags(backupDeleteCmd)
 }
 
 func initMonitorFlags() {
@@ -449,7 +2406,11 @@ func initMonitorFlags() {
 	backupMonitorCmd.Flags().String("storage-server", getEnvWithDefault("STORAGE_SERVER_ADDR", ""), "Remote storage server address for SSH capacity checking (env: STORAGE_SERVER_ADDR)")
 	backupMonitorCmd.Flags().String("storage-path", getEnvWithDefault("STORAGE_PATH", "/mnt/minio_nyc2"), "Path to monitor for storage capacity (env: STORAGE_PATH, default: /mnt/minio_nyc2)")
 	backupMonitorCmd.Flags().Float64("threshold", getEnvFloat64WithDefault("STORAGE_THRESHOLD", 95.0), "Storage usage threshold percentage to trigger migration (env: STORAGE_THRESHOLD, default: 95.0)")
+	backupMonitorCmd.Flags().Float64("warn-threshold", getEnvFloat64WithDefault("STORAGE_WARN_THRESHOLD", 0), "Storage usage percentage that triggers an alert without migrating, e.g. 85 (0 = disabled, env: STORAGE_WARN_THRESHOLD)")
+	backupMonitorCmd.Flags().String("alert-webhook", getEnvWithDefault("BACKUP_ALERT_WEBHOOK", ""), "URL that receives a JSON POST when usage crosses the warning or migration threshold (env: BACKUP_ALERT_WEBHOOK)")
+	backupMonitorCmd.Flags().String("metrics-file", getEnvWithDefault("BACKUP_METRICS_FILE", ""), "Write capacity metrics in Prometheus text format to this file, e.g. for node_exporter's textfile collector (env: BACKUP_METRICS_FILE)")
 	backupMonitorCmd.Flags().Float64("migrate-percent", getEnvFloat64WithDefault("MIGRATE_PERCENT", 10.0), "Percentage of oldest backups to migrate when threshold exceeded (env: MIGRATE_PERCENT, default: 10.0)")
+	backupMonitorCmd.Flags().Int("fair-per-site", getEnvIntWithDefault("MIGRATE_FAIR_PER_SITE", 0), "Migrate at most this many backups per site per round, round-robin by site, keeping each site's newest backup hot (0 = oldest first, env: MIGRATE_FAIR_PER_SITE)")
 	backupMonitorCmd.Flags().Bool("force-delete", getEnvBoolWithDefault("STORAGE_FORCE_DELETE", false), "Delete oldest backups without migrating when AWS fails (env: STORAGE_FORCE_DELETE)")
 	backupMonitorCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
 	backupMonitorCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
@@ -457,12 +2418,25 @@ func initMonitorFlags() {
 	backupMonitorCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupMonitorCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupMonitorCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupMonitorCmd)
+	addMinioListingFlags(backupMonitorCmd)
+	addListingCacheFlag(backupMonitorCmd)
+	addResidencyFlags(backupMonitorCmd)
 	backupMonitorCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
 	backupMonitorCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID, default: -)")
 	backupMonitorCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
 	backupMonitorCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupMonitorCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
 	backupMonitorCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION, default: us-east-1)")
 	backupMonitorCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	backupMonitorCmd.Flags().Bool("aws-verify", getEnvBoolWithDefault("AWS_GLACIER_VERIFY", false), "Re-hash buffered data after each Glacier upload and fail on a checksum mismatch, keeping the Minio copy (env: AWS_GLACIER_VERIFY)")
+	backupMonitorCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupMonitorCmd.Flags().Bool("no-history", false, "Do not record migrations in the history file")
+	backupMonitorCmd.Flags().String("aws-part-size", getEnvWithDefault("AWS_GLACIER_PART_SIZE", "128MB"), "Glacier multipart part size for migrations, rounded up to 1MB times a power of two; also the temp space needed (env: AWS_GLACIER_PART_SIZE)")
+	addAWSTLSFlags(backupMonitorCmd)
+	addStagingFlags(backupMonitorCmd)
+	addTempBudgetFlags(backupMonitorCmd)
+	addPendingDeletesFlag(backupMonitorCmd)
 
 	// SSH connection flags for remote storage server
 	backupMonitorCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username for storage server (env: SSH_USER, default: current user)")
@@ -479,12 +2453,15 @@ func initConnFlags() {
 	backupConnCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupConnCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupConnCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupConnCmd)
 	backupConnCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
 	backupConnCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID, default: -)")
 	backupConnCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
 	backupConnCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupConnCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
 	backupConnCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION, default: us-east-1)")
 	backupConnCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	addAWSTLSFlags(backupConnCmd)
 }
 
 func initSanitizeFlags() {
@@ -492,7 +2469,8 @@ func initSanitizeFlags() {
 	backupSanitizeCmd.Flags().String("output", "", "Path to output sanitized tarball (required)")
 	backupSanitizeCmd.Flags().String("extract-dir", "wp-content", "Comma-separated list of directories to extract from tarball (default: wp-content)")
 	backupSanitizeCmd.Flags().String("extract-file", "*.sql", "Comma-separated list of file patterns to extract (default: *.sql)")
-	backupSanitizeCmd.Flags().Bool("dry-run", false, "Preview what would be extracted without making changes")
+	backupSanitizeCmd.Flags().Bool("dry-run", false, "Preview what would be extracted and which rules match without making changes")
+	backupSanitizeCmd.Flags().String("rules", getEnvWithDefault("BACKUP_SANITIZE_RULES", ""), "YAML file with option removals, regex replacements, table drops and file exclusions (env: BACKUP_SANITIZE_RULES)")
 	backupSanitizeCmd.MarkFlagRequired("input")
 	backupSanitizeCmd.MarkFlagRequired("output")
 }
@@ -516,20 +2494,653 @@ func initMigrateAWSFlags() {
 	backupMigrateAWSCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupMigrateAWSCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
 	backupMigrateAWSCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupMigrateAWSCmd)
+	addMinioListingFlags(backupMigrateAWSCmd)
 
 	// AWS configuration for migrate-aws
 	backupMigrateAWSCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
 	backupMigrateAWSCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
 	backupMigrateAWSCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
 	backupMigrateAWSCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupMigrateAWSCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
 	backupMigrateAWSCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
 	backupMigrateAWSCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (env: AWS_HTTP_TIMEOUT)")
+	backupMigrateAWSCmd.Flags().Bool("aws-verify", getEnvBoolWithDefault("AWS_GLACIER_VERIFY", false), "Re-hash buffered data after each Glacier upload and fail on a checksum mismatch, keeping the Minio copy (env: AWS_GLACIER_VERIFY)")
+	backupMigrateAWSCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupMigrateAWSCmd.Flags().Bool("no-history", false, "Do not record migrations in the history file")
+	backupMigrateAWSCmd.Flags().String("aws-part-size", getEnvWithDefault("AWS_GLACIER_PART_SIZE", "128MB"), "Glacier multipart part size for migrations, rounded up to 1MB times a power of two; also the temp space needed (env: AWS_GLACIER_PART_SIZE)")
+	addAWSTLSFlags(backupMigrateAWSCmd)
+	addStagingFlags(backupMigrateAWSCmd)
+	addTempBudgetFlags(backupMigrateAWSCmd)
+	addPendingDeletesFlag(backupMigrateAWSCmd)
+	addResidencyFlags(backupMigrateAWSCmd)
+	backupMigrateAWSCmd.Flags().Bool("drain-staging", false, "Archive objects left in the staging tier by an earlier run (mutually exclusive with --object, --count, --percent, and --older-than)")
+}
+
+func initRestoreDBFlags() {
+	backupRestoreDBCmd.Flags().String("container", "", "Running container to import the database into (required)")
+	backupRestoreDBCmd.Flags().String("host", "", "Server hosting the container (required unless --local)")
+	backupRestoreDBCmd.Flags().Bool("local", false, "Restore into a container on the local Docker host instead of over SSH")
+	addDockerEndpointFlags(backupRestoreDBCmd)
+	backupRestoreDBCmd.Flags().String("import-method", "wp", "How to import the dump: 'wp' (wp db import) or 'mysql' (mysql client using the container's MYSQL_* env)")
+	backupRestoreDBCmd.Flags().String("sql-path", "", "Only use a .sql entry whose path contains this string (default: first .sql entry)")
+	backupRestoreDBCmd.Flags().String("safety-export-dir", "/var/tmp/ciwg-restore", "Directory on the target host for the pre-restore export of the current database")
+	backupRestoreDBCmd.Flags().Bool("skip-safety-export", false, "Do not export the current database before importing (not recommended)")
+	backupRestoreDBCmd.Flags().String("rollback-file", getEnvWithDefault("BACKUP_ROLLBACK_FILE", ""), "Registry the safety export is recorded in as a rollback point for 'backup restore --rollback-last' (default: ~/.ciwg/restore-rollbacks.json, env: BACKUP_ROLLBACK_FILE)")
+	backupRestoreDBCmd.Flags().Bool("dry-run", false, "Locate the dump and print actions without importing")
+	backupRestoreDBCmd.Flags().Int("subsite", 0, "Multisite: import only the tables of this blog ID (default: the full network)")
+	backupRestoreDBCmd.Flags().String("table-prefix", "", "Multisite: base table prefix of the network (default: wp db prefix in the container)")
+	backupRestoreDBCmd.Flags().String("uploads-dir", "", "Multisite: wp-content/uploads directory on the target host to extract the --subsite uploads into")
+	backupRestoreDBCmd.Flags().Bool("compat", false, "Re-package the --uploads-dir files for old tar on the host: drop extended attributes and use GNU long names")
+	backupRestoreDBCmd.Flags().String("prefix", "", "Prefix to search for when using --latest (e.g. backups/site-)")
+	backupRestoreDBCmd.Flags().Bool("latest", false, "If set, resolve the most recent object matching --prefix when object argument is omitted")
+	backupRestoreDBCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupRestoreDBCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupRestoreDBCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupRestoreDBCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupRestoreDBCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupRestoreDBCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupRestoreDBCmd)
+	addCacheFlags(backupRestoreDBCmd)
+	backupRestoreDBCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER, default: current user)")
+	backupRestoreDBCmd.Flags().StringP("port", "p", getEnvWithDefault("SSH_PORT", "22"), "SSH port (env: SSH_PORT)")
+	backupRestoreDBCmd.Flags().StringP("key", "k", getEnvWithDefault("SSH_KEY", ""), "Path to SSH private key (env: SSH_KEY)")
+	backupRestoreDBCmd.Flags().BoolP("agent", "a", getEnvBoolWithDefault("SSH_AGENT", true), "Use SSH agent (env: SSH_AGENT)")
+	backupRestoreDBCmd.Flags().DurationP("timeout", "t", getEnvDurationWithDefault("SSH_TIMEOUT", 30*time.Second), "Connection timeout (env: SSH_TIMEOUT)")
+}
+
+func initRestoreFlags() {
+	backupRestoreCmd.Flags().String("as", "", "New site name to restore the backup as (required)")
+	backupRestoreCmd.Flags().String("from", "", "Original site name to replace (default: the site label of the backup name)")
+	backupRestoreCmd.Flags().String("target-dir", "", "Directory to create the new site in (default: the directory the site was backed up from)")
+	backupRestoreCmd.Flags().String("db-name", "", "Database name for the new site (required when the compose file names a database)")
+	backupRestoreCmd.Flags().StringArray("search-replace", nil, "Rewrite 'from=to' in the database after restoring (repeatable)")
+	backupRestoreCmd.Flags().String("rewrite-method", backup.RewriteWP, "How to apply --search-replace: 'wp' (wp search-replace after import) or 'sql' (rewrite the dump before import)")
+	backupRestoreCmd.Flags().String("container", "", "Container to import the database into (default: the wp_ container of the restored project)")
+	backupRestoreCmd.Flags().Bool("no-start", false, "Extract and rewrite the files only; do not start containers or import the database")
+	backupRestoreCmd.Flags().Bool("replace", false, "Restore over an existing site directory, after moving the current site aside as a rollback point")
+	backupRestoreCmd.Flags().Bool("rollback-last", false, "Revert the last restore on the host (of --as, if given) to its rollback point")
+	backupRestoreCmd.Flags().String("rollback-file", getEnvWithDefault("BACKUP_ROLLBACK_FILE", ""), "Registry of rollback points (default: ~/.ciwg/restore-rollbacks.json, env: BACKUP_ROLLBACK_FILE)")
+	backupRestoreCmd.Flags().Bool("dry-run", false, "Show the rewrite plan without extracting anything")
+	backupRestoreCmd.Flags().Bool("compat", false, "Re-package the archive for old tar on the host: drop extended attributes and use GNU long names")
+	backupRestoreCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Run history file the restore and its duration are recorded in, for RTO reports (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupRestoreCmd.Flags().Bool("no-history", false, "Do not record the restore in the history file")
+	backupRestoreCmd.Flags().String("host", "", "Server to restore onto (required unless --local)")
+	backupRestoreCmd.Flags().Bool("local", false, "Restore onto the local Docker host instead of over SSH")
+	addDockerEndpointFlags(backupRestoreCmd)
+	backupRestoreCmd.Flags().String("prefix", "", "Prefix to search for when using --latest (e.g. backups/site-)")
+	backupRestoreCmd.Flags().Bool("latest", false, "If set, resolve the most recent object matching --prefix when object argument is omitted")
+	backupRestoreCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupRestoreCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupRestoreCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupRestoreCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupRestoreCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupRestoreCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupRestoreCmd)
+	addCacheFlags(backupRestoreCmd)
+	backupRestoreCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER, default: current user)")
+	backupRestoreCmd.Flags().StringP("port", "p", getEnvWithDefault("SSH_PORT", "22"), "SSH port (env: SSH_PORT)")
+	backupRestoreCmd.Flags().StringP("key", "k", getEnvWithDefault("SSH_KEY", ""), "Path to SSH private key (env: SSH_KEY)")
+	backupRestoreCmd.Flags().BoolP("agent", "a", getEnvBoolWithDefault("SSH_AGENT", true), "Use SSH agent (env: SSH_AGENT)")
+	backupRestoreCmd.Flags().DurationP("timeout", "t", getEnvDurationWithDefault("SSH_TIMEOUT", 30*time.Second), "Connection timeout (env: SSH_TIMEOUT)")
+}
+
+func initReconcileFlags() {
+	addPendingDeletesFlag(backupReconcileCmd)
+	backupReconcileCmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting anything")
+	backupReconcileCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupReconcileCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupReconcileCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupReconcileCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupReconcileCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupReconcileCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupReconcileCmd)
+}
+
+func initGCFlags() {
+	backupGCCmd.Flags().String("prefix", "backups/", "Prefix to clean up")
+	backupGCCmd.Flags().Duration("min-age", getEnvDurationWithDefault("BACKUP_GC_MIN_AGE", backup.DefaultGCMinAge), "Age a zero-byte object must reach to be deleted (env: BACKUP_GC_MIN_AGE)")
+	backupGCCmd.Flags().Int("stale-days", getEnvIntWithDefault("BACKUP_GC_STALE_DAYS", 0), "Report site prefixes without a backup newer than this many days (0 = off, env: BACKUP_GC_STALE_DAYS)")
+	backupGCCmd.Flags().Bool("prune-stale", false, "Delete the backups of the stale site prefixes")
+	backupGCCmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting anything")
+	backupGCCmd.Flags().Bool("json", false, "Print the report as JSON")
+	backupGCCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupGCCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupGCCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupGCCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupGCCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupGCCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupGCCmd)
+	addMinioListingFlags(backupGCCmd)
+	addGroupFlags(backupGCCmd)
+}
+
+func initImportFlags() {
+	backupImportCmd.Flags().String("site", "", "Site label of the backup, when its file name doesn't tell")
+	backupImportCmd.Flags().Bool("from-bucket", false, "Arguments are .zip objects in the bucket instead of local files")
+	backupImportCmd.Flags().String("prefix", "", "With --from-bucket, import every .zip object under this prefix")
+	backupImportCmd.Flags().Bool("delete-source", false, "With --from-bucket, delete each .zip object once it was converted")
+	backupImportCmd.Flags().Bool("dry-run", false, "Show where each backup would go without uploading anything")
+	backupImportCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupImportCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupImportCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupImportCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupImportCmd.Flags().String("bucket-path", getEnvWithDefault("MINIO_BUCKET_PATH", ""), "Object prefix to import into instead of backups/<site>/ (env: MINIO_BUCKET_PATH)")
+	backupImportCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupImportCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupImportCmd)
+	addMinioUploadFlags(backupImportCmd)
+}
+
+func initVerifyFlags() {
+	backupVerifyCmd.Flags().String("prefix", "backups/", "Verify the objects under this prefix")
+	backupVerifyCmd.Flags().Bool("etag-only", false, "Only compare ETags with the index; don't read the objects")
+	backupVerifyCmd.Flags().String("etag-index", getEnvWithDefault("BACKUP_ETAG_INDEX", ""), "ETag index file (default: ~/.ciwg/etag-index.json, env: BACKUP_ETAG_INDEX)")
+	backupVerifyCmd.Flags().Bool("accept", false, "Record the current state of changed objects as expected")
+	backupVerifyCmd.Flags().Bool("dry-run", false, "Compare without updating the index")
+	backupVerifyCmd.Flags().Bool("verbose", false, "Also list new and removed objects")
+	backupVerifyCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupVerifyCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupVerifyCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupVerifyCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupVerifyCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupVerifyCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupVerifyCmd)
+}
+
+func initBenchFlags() {
+	backupBenchCmd.Flags().String("target", backup.BenchTargetMinio, "Where to upload: minio or glacier")
+	backupBenchCmd.Flags().String("size", "1GB", "Bytes uploaded by each run")
+	backupBenchCmd.Flags().StringSlice("part-sizes", []string{"16MB", "64MB", "128MB"}, "Part sizes to try; Glacier rounds them up to 1MB times a power of two")
+	backupBenchCmd.Flags().IntSlice("concurrency", backup.DefaultBenchConcurrency, "Parts sent at once to try (Minio only)")
+	backupBenchCmd.Flags().Bool("save", true, "Save the recommendation in the active backup profile")
+	backupBenchCmd.Flags().Bool("json", false, "Print the results as JSON")
+	backupBenchCmd.Flags().Bool("dry-run", false, "Print the runs that would be made without uploading anything")
+	backupBenchCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupBenchCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupBenchCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupBenchCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupBenchCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupBenchCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupBenchCmd)
+	backupBenchCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+	backupBenchCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+	backupBenchCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+	backupBenchCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupBenchCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+	backupBenchCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+	backupBenchCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (env: AWS_HTTP_TIMEOUT)")
+	addAWSTLSFlags(backupBenchCmd)
+}
+
+func initRetryPendingFlags() {
+	backupRetryPendingCmd.Flags().String("pending-file", getEnvWithDefault("BACKUP_PENDING_FILE", ""), "Queue of missed destinations (default: ~/.ciwg/pending-uploads.jsonl, env: BACKUP_PENDING_FILE)")
+	backupRetryPendingCmd.Flags().Bool("dry-run", false, "List the queue without retrying anything")
+	backupRetryPendingCmd.Flags().String("host", "", "Server to re-create missed Minio copies on; without it (or --local) they stay queued")
+	backupRetryPendingCmd.Flags().Bool("local", false, "Re-create missed Minio copies of backups made on the local host")
+	addDockerEndpointFlags(backupRetryPendingCmd)
+	backupRetryPendingCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupRetryPendingCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupRetryPendingCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupRetryPendingCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupRetryPendingCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupRetryPendingCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupRetryPendingCmd)
+	addMinioUploadFlags(backupRetryPendingCmd)
+	backupRetryPendingCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+	backupRetryPendingCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+	backupRetryPendingCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+	backupRetryPendingCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupRetryPendingCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+	backupRetryPendingCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+	backupRetryPendingCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (env: AWS_HTTP_TIMEOUT)")
+	backupRetryPendingCmd.Flags().Bool("aws-verify", getEnvBoolWithDefault("AWS_GLACIER_VERIFY", false), "Re-hash buffered data after each Glacier upload and fail on a checksum mismatch (env: AWS_GLACIER_VERIFY)")
+	backupRetryPendingCmd.Flags().String("aws-part-size", getEnvWithDefault("AWS_GLACIER_PART_SIZE", "128MB"), "Glacier multipart part size, rounded up to 1MB times a power of two; also the temp space needed (env: AWS_GLACIER_PART_SIZE)")
+	addAWSTLSFlags(backupRetryPendingCmd)
+	addTempBudgetFlags(backupRetryPendingCmd)
+	addResidencyFlags(backupRetryPendingCmd)
+	backupRetryPendingCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupRetryPendingCmd.Flags().Bool("no-history", false, "Do not record retried Glacier copies in the history file")
+	backupRetryPendingCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER, default: current user)")
+	backupRetryPendingCmd.Flags().StringP("port", "p", getEnvWithDefault("SSH_PORT", "22"), "SSH port (env: SSH_PORT)")
+	backupRetryPendingCmd.Flags().StringP("key", "k", getEnvWithDefault("SSH_KEY", ""), "Path to SSH private key (env: SSH_KEY)")
+	backupRetryPendingCmd.Flags().BoolP("agent", "a", getEnvBoolWithDefault("SSH_AGENT", true), "Use SSH agent (env: SSH_AGENT)")
+	backupRetryPendingCmd.Flags().DurationP("timeout", "t", getEnvDurationWithDefault("SSH_TIMEOUT", 30*time.Second), "Connection timeout (env: SSH_TIMEOUT)")
+
+	// Upload-spool command flags
+	backupUploadSpoolCmd.Flags().String("spool-dir", getEnvWithDefault("BACKUP_SPOOL_DIR", ""), "Spool to upload (default: ~/.ciwg/spool, env: BACKUP_SPOOL_DIR)")
+	backupUploadSpoolCmd.Flags().Int("retries", getEnvIntWithDefault("BACKUP_SPOOL_RETRIES", 3), "Tries per archive and run (env: BACKUP_SPOOL_RETRIES)")
+	backupUploadSpoolCmd.Flags().Duration("retry-delay", getEnvDurationWithDefault("BACKUP_SPOOL_RETRY_DELAY", 30*time.Second), "Wait before the second try of an archive, doubled for each further one (env: BACKUP_SPOOL_RETRY_DELAY)")
+	backupUploadSpoolCmd.Flags().Bool("watch", false, "Keep draining the spool every --interval until interrupted")
+	backupUploadSpoolCmd.Flags().Duration("interval", getEnvDurationWithDefault("BACKUP_SPOOL_INTERVAL", time.Minute), "Time between drains with --watch (env: BACKUP_SPOOL_INTERVAL)")
+	backupUploadSpoolCmd.Flags().Bool("dry-run", false, "List the spool without uploading anything")
+	backupUploadSpoolCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupUploadSpoolCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupUploadSpoolCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupUploadSpoolCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupUploadSpoolCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupUploadSpoolCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupUploadSpoolCmd)
+	addMinioUploadFlags(backupUploadSpoolCmd)
+	backupUploadSpoolCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+	backupUploadSpoolCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+	backupUploadSpoolCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+	backupUploadSpoolCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupUploadSpoolCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+	backupUploadSpoolCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+	backupUploadSpoolCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (env: AWS_HTTP_TIMEOUT)")
+	backupUploadSpoolCmd.Flags().Bool("aws-verify", getEnvBoolWithDefault("AWS_GLACIER_VERIFY", false), "Re-hash buffered data after each Glacier upload and fail on a checksum mismatch (env: AWS_GLACIER_VERIFY)")
+	backupUploadSpoolCmd.Flags().String("aws-part-size", getEnvWithDefault("AWS_GLACIER_PART_SIZE", "128MB"), "Glacier multipart part size, rounded up to 1MB times a power of two; also the temp space needed (env: AWS_GLACIER_PART_SIZE)")
+	addAWSTLSFlags(backupUploadSpoolCmd)
+	addTempBudgetFlags(backupUploadSpoolCmd)
+	addResidencyFlags(backupUploadSpoolCmd)
+	backupUploadSpoolCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file, also the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupUploadSpoolCmd.Flags().Bool("no-history", false, "Do not record uploads in the history file")
+}
+
+func initSyncFlags() {
+	backupSyncCmd.Flags().String("from-dir", getEnvWithDefault("BACKUP_LOCAL_DIR", ""), "Filesystem backup directory to copy from (env: BACKUP_LOCAL_DIR)")
+	backupSyncCmd.Flags().String("source-profile", "", "Storage profile to copy from (instead of --from-dir)")
+	backupSyncCmd.Flags().String("dest-profile", "", "Storage profile to copy to (used with --source-profile)")
+	backupSyncCmd.Flags().String("profiles-file", getEnvWithDefault("BACKUP_MINIO_PROFILES", ""), "Storage profiles file (default: ~/.ciwg/minio-profiles.yaml, env: BACKUP_MINIO_PROFILES)")
+	backupSyncCmd.Flags().String("state-file", "", "Resume journal for profile syncs (default: ~/.ciwg/sync/<source>-to-<dest>.jsonl)")
+	backupSyncCmd.Flags().Bool("no-verify", false, "Skip reading each copied object back to compare checksums")
+	backupSyncCmd.Flags().Bool("json", false, "Print the per-object report as JSON (profile syncs)")
+	backupSyncCmd.Flags().String("prefix", "", "Only sync objects under this prefix")
+	backupSyncCmd.Flags().Bool("dry-run", false, "Show what would be copied without uploading")
+	backupSyncCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Destination Minio endpoint (env: MINIO_ENDPOINT)")
+	backupSyncCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupSyncCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupSyncCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupSyncCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupSyncCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupSyncCmd)
+	addResidencyFlags(backupSyncCmd)
+}
+
+func initCacheLatestFlags() {
+	backupCacheLatestCmd.Flags().String("prefix", "backups/", "Only cache sites under this prefix")
+	backupCacheLatestCmd.Flags().Bool("dry-run", false, "Show what would be cached and evicted without copying")
+	addCacheFlags(backupCacheLatestCmd)
+	backupCacheLatestCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupCacheLatestCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupCacheLatestCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupCacheLatestCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupCacheLatestCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupCacheLatestCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupCacheLatestCmd)
+	addMinioListingFlags(backupCacheLatestCmd)
+}
+
+func initPrunePlanFlags() {
+	backupPrunePlanListCmd.Flags().Bool("json", false, "Output as JSON")
+	backupPrunePlanShowCmd.Flags().Bool("json", false, "Output as JSON")
+	backupPrunePlanExecuteCmd.Flags().Bool("dry-run", false, "List the pending objects without deleting them")
+	c := backupPrunePlanCreateCmd
+	c.Flags().String("prefix", "backups/", "Prefix of the backups to prune; a site prefix (backups/example.com/) plans one site")
+	c.Flags().Int("remainder", 5, "Number of most recent backups to keep without smart retention (default: 5)")
+	c.Flags().Bool("smart-retention", getEnvBoolWithDefault("BACKUP_SMART_RETENTION", false), "Enable date-aware retention (preserves weekly/monthly from daily backups, env: BACKUP_SMART_RETENTION)")
+	c.Flags().Int("keep-daily", getEnvIntWithDefault("BACKUP_KEEP_DAILY", 14), "Daily backups to keep with smart retention (default: 14, env: BACKUP_KEEP_DAILY)")
+	c.Flags().Int("keep-weekly", getEnvIntWithDefault("BACKUP_KEEP_WEEKLY", 26), "Weekly backups to keep with smart retention (default: 26, env: BACKUP_KEEP_WEEKLY)")
+	c.Flags().Int("keep-monthly", getEnvIntWithDefault("BACKUP_KEEP_MONTHLY", 6), "Monthly backups to keep with smart retention (default: 6, env: BACKUP_KEEP_MONTHLY)")
+	c.Flags().Int("weekly-day", getEnvIntWithDefault("BACKUP_WEEKLY_DAY", 0), "Day of week for weekly backups, 0=Sunday (default: 0, env: BACKUP_WEEKLY_DAY)")
+	c.Flags().Int("monthly-day", getEnvIntWithDefault("BACKUP_MONTHLY_DAY", 1), "Day of month for monthly backups (default: 1, env: BACKUP_MONTHLY_DAY)")
+	addRetentionPresetFlags(c)
+	for _, c := range []*cobra.Command{backupPrunePlanCreateCmd, backupPrunePlanListCmd, backupPrunePlanShowCmd, backupPrunePlanExecuteCmd} {
+		c.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+		c.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+		c.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+		c.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+		c.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+		c.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+		addMinioTLSFlags(c)
+	}
+	addMinioListingFlags(backupPrunePlanCreateCmd)
+}
+
+func initRunsFlags() {
+	backupRunsShowCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupRunsShowCmd.Flags().Bool("remote", false, "Read the run's artifacts from the bucket even when it is in the local history")
+	backupRunsShowCmd.Flags().String("site", "", "Only read the artifacts stored for this site")
+	backupRunsShowCmd.Flags().Bool("commands", false, "Also list the audited shell commands of the run")
+	backupRunsShowCmd.Flags().Bool("json", false, "Output as JSON")
+	backupRunsShowCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupRunsShowCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupRunsShowCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupRunsShowCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupRunsShowCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupRunsShowCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupRunsShowCmd)
+}
+
+func initNormalizeKeysFlags() {
+	backupNormalizeKeysCmd.Flags().String("prefix", "backups/", "Only move sites under this prefix")
+	backupNormalizeKeysCmd.Flags().Bool("dry-run", false, "Print the moves without claiming keys or moving objects")
+	backupNormalizeKeysCmd.Flags().Bool("json", false, "Output as JSON")
+	backupNormalizeKeysCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupNormalizeKeysCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupNormalizeKeysCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupNormalizeKeysCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupNormalizeKeysCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupNormalizeKeysCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupNormalizeKeysCmd)
+}
+
+func initIndexFlags() {
+	c := backupIndexCmd
+	c.Flags().String("prefix", "backups/", "Prefix to index")
+	c.Flags().Bool("listen", false, "Keep the index current from bucket notifications until stopped")
+	c.Flags().String("source", getEnvWithDefault("BACKUP_INDEX_SOURCE", backup.IndexSourceMinio), "Notification source with --listen: minio or webhook (env: BACKUP_INDEX_SOURCE)")
+	c.Flags().String("listen-addr", getEnvWithDefault("BACKUP_INDEX_LISTEN_ADDR", backup.DefaultIndexListenAddr), "Address the webhook server listens on (env: BACKUP_INDEX_LISTEN_ADDR)")
+	c.Flags().String("webhook-token", getEnvWithDefault("BACKUP_INDEX_WEBHOOK_TOKEN", ""), "auth_token the Minio webhook target sends; requests without it are refused (env: BACKUP_INDEX_WEBHOOK_TOKEN)")
+	c.Flags().Duration("publish-interval", getEnvDurationWithDefault("BACKUP_INDEX_PUBLISH_INTERVAL", backup.DefaultIndexPublishInterval), "Publish a changed index at most this often (env: BACKUP_INDEX_PUBLISH_INTERVAL)")
+	c.Flags().Duration("resync-interval", getEnvDurationWithDefault("BACKUP_INDEX_RESYNC_INTERVAL", backup.DefaultIndexResyncInterval), "List the bucket again this often to catch missed events, 0 never (env: BACKUP_INDEX_RESYNC_INTERVAL)")
+	c.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	c.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	c.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	c.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	c.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	c.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(c)
+	addMinioListingFlags(c)
+}
+
+func initDrillFlags() {
+	backupDrillCmd.Flags().String("every", getEnvWithDefault("BACKUP_DRILL_EVERY", ""), "How often to drill, e.g. 7d or 36h (default: the fleet file's drill.every, else 7d, env: BACKUP_DRILL_EVERY)")
+	backupDrillCmd.Flags().Int("sites", getEnvIntWithDefault("BACKUP_DRILL_SITES", 0), "How many random sites to drill (default: the fleet file's drill.sites, else 3, env: BACKUP_DRILL_SITES)")
+	backupDrillCmd.Flags().String("prefix", "backups/", "Only drill sites under this prefix")
+	backupDrillCmd.Flags().String("sandbox-dir", getEnvWithDefault("BACKUP_DRILL_SANDBOX", ""), "Directory the sandboxes are created in (default: the system temp directory, env: BACKUP_DRILL_SANDBOX)")
+	backupDrillCmd.Flags().Bool("keep-sandbox", false, "Keep the restored files instead of removing them")
+	backupDrillCmd.Flags().Bool("force", false, "Drill now even if the next drill is not due")
+	backupDrillCmd.Flags().Bool("check", false, "Only report when drills last ran, alerting and exiting non-zero when they are overdue")
+	backupDrillCmd.Flags().String("alert-webhook", getEnvWithDefault("BACKUP_DRILL_WEBHOOK", ""), "Webhook URL posted to when a drill fails or drills are overdue (env: BACKUP_DRILL_WEBHOOK)")
+	backupDrillCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Run history file drills are scheduled from and recorded in (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupDrillCmd.Flags().Bool("no-history", false, "Do not record the drill in the history file")
+	backupDrillCmd.Flags().String("fleet-file", getEnvWithDefault("BACKUP_FLEET_FILE", ""), "YAML file whose drill section sets the schedule (default: ~/.ciwg/fleet.yaml, env: BACKUP_FLEET_FILE)")
+	backupDrillCmd.Flags().Bool("json", false, "Output as JSON")
+	backupDrillCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupDrillCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupDrillCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupDrillCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupDrillCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupDrillCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupDrillCmd)
+}
+
+func initFeaturesFlags() {
+	backupFeaturesListCmd.Flags().String("fleet-file", getEnvWithDefault("BACKUP_FLEET_FILE", ""), "YAML file with the fleet-wide and per-host features (default: ~/.ciwg/fleet.yaml, env: BACKUP_FLEET_FILE)")
+	backupFeaturesListCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+func initMaintenanceFlags() {
+	backupMaintenanceSetCmd.Flags().String("until", "", "When the site leaves maintenance: YYYY-MM-DD or RFC 3339 (default: until cleared)")
+	backupMaintenanceSetCmd.Flags().String("reason", "", "Why the site is in maintenance, shown when it is skipped")
+	backupMaintenanceListCmd.Flags().Bool("all", false, "Include expired entries")
+	backupMaintenanceListCmd.Flags().Bool("json", false, "Output as JSON")
+	backupMaintenanceSetCmd.Flags().Bool("dry-run", false, "Print the flag that would be set without writing it")
+	backupMaintenanceClearCmd.Flags().Bool("dry-run", false, "Print the flags that would be cleared without removing them")
+	for _, c := range []*cobra.Command{backupMaintenanceSetCmd, backupMaintenanceClearCmd, backupMaintenanceListCmd} {
+		c.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+		c.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+		c.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+		c.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+		c.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+		c.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+		addMinioTLSFlags(c)
+		addMinioListingFlags(c)
+	}
+}
+
+func initEstimateCalibrateFlags() {
+	backupEstimateCalibrateCmd.Flags().String("prefix", "", "Only calibrate from backups under this prefix (e.g. backups/mysite.com/)")
+	backupEstimateCalibrateCmd.Flags().Int("count", 3, "Number of most recent backups to analyze")
+	backupEstimateCalibrateCmd.Flags().String("min-sample", "1MB", "Minimum data per extension before its ratio replaces the default")
+	backupEstimateCalibrateCmd.Flags().String("output", "", "Where to save the model (default: ~/.ciwg/compression-model.json)")
+	backupEstimateCalibrateCmd.Flags().Bool("dry-run", false, "Print the learned ratios without saving them")
+	backupEstimateCalibrateCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupEstimateCalibrateCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupEstimateCalibrateCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupEstimateCalibrateCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupEstimateCalibrateCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupEstimateCalibrateCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupEstimateCalibrateCmd)
+}
+
+// addCompressionModelFlag registers --compression-model for commands that run
+// heuristic estimation.
+func addCompressionModelFlag(c *cobra.Command) {
+	c.Flags().String("compression-model", getEnvWithDefault("BACKUP_COMPRESSION_MODEL", ""), "Calibrated compression model for heuristic estimation (default: ~/.ciwg/compression-model.json if present, env: BACKUP_COMPRESSION_MODEL)")
+}
+
+func initRetentionFlags() {
+	addRetentionFleetFlag(backupRetentionShowPresetsCmd)
+	backupRetentionShowPresetsCmd.Flags().Bool("json", false, "Output JSON")
+
+	c := backupRetentionCompareCmd
+	c.Flags().String("policy-a", "", "Current retention policy: remainder:N, smart:D/W/M[/WD/MD] or preset:NAME")
+	c.Flags().String("policy-b", "", "Retention policy to compare against policy A, in the same form")
+	c.MarkFlagRequired("policy-a")
+	c.MarkFlagRequired("policy-b")
+	c.Flags().String("prefix", "backups/", "Prefix of the backups to compare; a site prefix (backups/example.com/) compares one site")
+	c.Flags().Bool("objects", false, "List the objects the two policies disagree on")
+	c.Flags().Bool("json", false, "Output JSON")
+	addRetentionFleetFlag(c)
+	c.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	c.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	c.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	c.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	c.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	c.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(c)
+	addMinioListingFlags(c)
+}
+
+func initLifecycleFlags() {
+	c := backupLifecycleExportCmd
+	c.Flags().String("format", "terraform", "Output format: terraform or s3-json")
+	c.Flags().String("out", "", "Output file (default: stdout)")
+	c.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Bucket the rules are for (env: MINIO_BUCKET)")
+	c.Flags().String("prefix", "backups/", "Prefix holding the backups the retention applies to")
+	c.Flags().Int("keep-daily", getEnvIntWithDefault("BACKUP_KEEP_DAILY", 14), "Daily backups to keep with smart retention (default: 14, env: BACKUP_KEEP_DAILY)")
+	c.Flags().Int("keep-weekly", getEnvIntWithDefault("BACKUP_KEEP_WEEKLY", 26), "Weekly backups to keep with smart retention (default: 26, env: BACKUP_KEEP_WEEKLY)")
+	c.Flags().Int("keep-monthly", getEnvIntWithDefault("BACKUP_KEEP_MONTHLY", 6), "Monthly backups to keep with smart retention (default: 6, env: BACKUP_KEEP_MONTHLY)")
+	c.Flags().Int("weekly-day", getEnvIntWithDefault("BACKUP_WEEKLY_DAY", 0), "Day of week for weekly backups, 0=Sunday (default: 0, env: BACKUP_WEEKLY_DAY)")
+	c.Flags().Int("monthly-day", getEnvIntWithDefault("BACKUP_MONTHLY_DAY", 1), "Day of month for monthly backups (default: 1, env: BACKUP_MONTHLY_DAY)")
+	addRetentionPresetFlags(c)
+	c.Flags().Int("grace-days", 7, "Days added to the retention horizon before backups expire")
+	c.Flags().Int("transition-days", 0, "Move backups to --storage-class after this many days (0: no transition)")
+	c.Flags().String("storage-class", "GLACIER", "Storage class or Minio tier for --transition-days")
+	c.Flags().String("staging-prefix", getEnvWithDefault("BACKUP_STAGING_PREFIX", ""), "Tier objects staged for Glacier under this prefix as soon as they land (env: BACKUP_STAGING_PREFIX)")
+	c.Flags().String("staging-storage-class", "", "Minio remote tier for --staging-prefix; staged objects must stay readable for the Glacier upload")
+	c.Flags().Int("abort-multipart-days", 7, "Abort incomplete multipart uploads after this many days (0: no rule)")
+}
+
+// addRetentionPresetFlags registers --retention-preset and the fleet file
+// the presets are defined in.
+func addRetentionPresetFlags(c *cobra.Command) {
+	c.Flags().String("retention-preset", getEnvWithDefault("BACKUP_RETENTION_PRESET", ""), "Named retention preset (see 'backup retention show-presets'); explicit retention flags override its values (env: BACKUP_RETENTION_PRESET)")
+	addRetentionFleetFlag(c)
+}
+
+// addRetentionFleetFlag registers --fleet-file, whose retention_presets
+// section defines presets next to the built-in ones, unless the command
+// already has it.
+func addRetentionFleetFlag(c *cobra.Command) {
+	if c.Flags().Lookup("fleet-file") == nil {
+		c.Flags().String("fleet-file", getEnvWithDefault("BACKUP_FLEET_FILE", ""), "YAML file whose retention_presets section defines retention presets (default: ~/.ciwg/fleet.yaml, env: BACKUP_FLEET_FILE)")
+	}
+}
+
+func initReportPerformanceFlags() {
+	backupReportPerformanceCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupReportPerformanceCmd.Flags().String("group-by", "host", "Group results by 'host' or 'site'")
+	backupReportPerformanceCmd.Flags().Duration("since", 0, "Only include runs started within this duration (e.g. 168h; 0 for all)")
+	backupReportPerformanceCmd.Flags().Bool("json", false, "Output JSON")
+	addGroupFlags(backupReportPerformanceCmd)
+}
+
+func initReportComplianceFlags() {
+	backupReportComplianceCmd.Flags().String("format", "text", "Output format: text, json or html")
+	backupReportComplianceCmd.Flags().String("out", "", "Write the report to this file instead of stdout")
+	backupReportComplianceCmd.Flags().String("prefix", "backups/", "Prefix holding the backups of every site (<prefix><site>/)")
+	backupReportComplianceCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Run history file with the recorded restores (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupReportComplianceCmd.Flags().Bool("fail-on-violation", false, "Exit with an error when any site misses a target")
+	addBucketSummaryFlags(backupReportComplianceCmd)
+	backupReportComplianceCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupReportComplianceCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupReportComplianceCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupReportComplianceCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupReportComplianceCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupReportComplianceCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupReportComplianceCmd)
+	addMinioListingFlags(backupReportComplianceCmd)
+	addListingCacheFlag(backupReportComplianceCmd)
+	addGroupFlags(backupReportComplianceCmd)
+}
+
+func initExportInventoryFlags() {
+	backupExportInventoryCmd.Flags().String("format", "csv", "Output format: csv or parquet")
+	backupExportInventoryCmd.Flags().String("out", "", "Output file (default: stdout for csv; required for parquet)")
+	backupExportInventoryCmd.Flags().String("prefix", "backups/", "Only include objects under this prefix")
+	backupExportInventoryCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupExportInventoryCmd.Flags().Bool("verify", false, "Download every hot object and check that it is a complete gzip/tar stream")
+	backupExportInventoryCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupExportInventoryCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupExportInventoryCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupExportInventoryCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupExportInventoryCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupExportInventoryCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupExportInventoryCmd)
+	addGroupFlags(backupExportInventoryCmd)
+	addMinioListingFlags(backupExportInventoryCmd)
+	addListingCacheFlag(backupExportInventoryCmd)
+}
+
+func initExportBundleFlags() {
+	backupExportBundleCmd.Flags().String("site", "", "Site to export (required)")
+	backupExportBundleCmd.Flags().String("out", "", "Directory to write the bundle to; must not exist or be empty (required)")
+	backupExportBundleCmd.Flags().String("prefix", "", "Prefix holding the site's backups (default: backups/<site>/)")
+	backupExportBundleCmd.Flags().Bool("sanitize", false, "Hand over a sanitized copy of the backup instead of the stored one")
+	backupExportBundleCmd.Flags().String("rules", getEnvWithDefault("BACKUP_SANITIZE_RULES", ""), "YAML sanitize rules used with --sanitize (env: BACKUP_SANITIZE_RULES)")
+	backupExportBundleCmd.Flags().String("signing-key", getEnvWithDefault("BACKUP_BUNDLE_SIGNING_KEY", ""), "ed25519 private key (PKCS#8 PEM) that signs the summary (env: BACKUP_BUNDLE_SIGNING_KEY)")
+	backupExportBundleCmd.Flags().Bool("no-sign", false, "Write the bundle without a signature")
+	backupExportBundleCmd.Flags().String("operator", getEnvWithDefault("USER", ""), "Name recorded in the summary as who produced the bundle (env: USER)")
+	backupExportBundleCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupExportBundleCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupExportBundleCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupExportBundleCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupExportBundleCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupExportBundleCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupExportBundleCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupExportBundleCmd)
+}
+
+func initAWSAuditFlags() {
+	backupAWSAuditCmd.Flags().String("inventory", "", "Vault inventory JSON saved from a completed inventory job")
+	backupAWSAuditCmd.Flags().String("job-id", "", "Fetch the inventory from this completed Glacier inventory job")
+	backupAWSAuditCmd.Flags().Bool("initiate", false, "Start a Glacier inventory job and print its ID")
+	backupAWSAuditCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupAWSAuditCmd.Flags().Bool("repair", false, "Rewrite the ledger to match the vault")
+	backupAWSAuditCmd.Flags().Bool("no-backfill", false, "Do not adopt legacy archives whose description names a backup object")
+	backupAWSAuditCmd.Flags().Bool("json", false, "Output the reconciliation report as JSON")
+	backupAWSAuditCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+	backupAWSAuditCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+	backupAWSAuditCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+	backupAWSAuditCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupAWSAuditCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+	backupAWSAuditCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+	backupAWSAuditCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	addAWSTLSFlags(backupAWSAuditCmd)
+}
+
+func initGlacierRetrieveFlags() {
+	backupGlacierRetrieveCmd.Flags().String("job-id", "", "Download the output of this completed retrieval job instead of starting one")
+	backupGlacierRetrieveCmd.Flags().String("output", "", "File the retrieved archive is written to with --job-id")
+	backupGlacierRetrieveCmd.Flags().String("tier", getEnvWithDefault("BACKUP_RETRIEVAL_TIER", backup.RetrievalTierStandard), "Retrieval tier: expedited (minutes), standard (3-5 hours) or bulk (5-12 hours, cheapest) (env: BACKUP_RETRIEVAL_TIER)")
+	backupGlacierRetrieveCmd.Flags().Float64("price-per-gb", getEnvFloat64WithDefault("BACKUP_RETRIEVAL_PRICE_PER_GB", 0), "Retrieval price per GB in USD for the estimate (default: the tier's us-east-1 list price, env: BACKUP_RETRIEVAL_PRICE_PER_GB)")
+	backupGlacierRetrieveCmd.Flags().Float64("confirm-above", getEnvFloat64WithDefault("BACKUP_RETRIEVAL_CONFIRM_ABOVE", 1.0), "Ask for confirmation when a retrieval is estimated above this many USD (env: BACKUP_RETRIEVAL_CONFIRM_ABOVE)")
+	backupGlacierRetrieveCmd.Flags().Float64("monthly-budget", getEnvFloat64WithDefault("BACKUP_RETRIEVAL_BUDGET", 0), "Retrieval spend allowed per calendar month in USD; 0 means no cap (env: BACKUP_RETRIEVAL_BUDGET)")
+	backupGlacierRetrieveCmd.Flags().String("budget-enforcement", getEnvWithDefault("BACKUP_RETRIEVAL_BUDGET_ENFORCEMENT", "hard"), "What exceeding --monthly-budget does: hard (refuse) or soft (warn and confirm) (env: BACKUP_RETRIEVAL_BUDGET_ENFORCEMENT)")
+	backupGlacierRetrieveCmd.Flags().String("retrieval-ledger", getEnvWithDefault("BACKUP_RETRIEVAL_LEDGER", ""), "Ledger of started retrievals and their estimated cost (default: ~/.ciwg/glacier-retrievals.jsonl, env: BACKUP_RETRIEVAL_LEDGER)")
+	backupGlacierRetrieveCmd.Flags().Bool("yes", false, "Start the retrieval without asking for confirmation")
+	backupGlacierRetrieveCmd.Flags().Bool("dry-run", false, "Print the estimate and budget check without starting or downloading anything")
+	backupGlacierRetrieveCmd.Flags().String("history-file", getEnvWithDefault("BACKUP_HISTORY_FILE", ""), "Path to the run history file used as the Glacier ledger (default: ~/.ciwg/backup-history.jsonl, env: BACKUP_HISTORY_FILE)")
+	backupGlacierRetrieveCmd.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+	backupGlacierRetrieveCmd.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+	backupGlacierRetrieveCmd.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+	backupGlacierRetrieveCmd.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+	backupGlacierRetrieveCmd.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+	backupGlacierRetrieveCmd.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+	backupGlacierRetrieveCmd.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+	addAWSTLSFlags(backupGlacierRetrieveCmd)
+}
+
+func initAWSSetupFlags() {
+	for _, c := range []*cobra.Command{backupAWSSetupVerifyCmd, backupAWSSetupApplyCmd} {
+		c.Flags().String("sns-topic", getEnvWithDefault("AWS_GLACIER_SNS_TOPIC", ""), "SNS topic ARN notified of completed Glacier jobs (env: AWS_GLACIER_SNS_TOPIC)")
+		c.Flags().String("retrieval-strategy", getEnvWithDefault("AWS_GLACIER_RETRIEVAL_STRATEGY", ""), "Data retrieval policy: BytesPerHour, FreeTier or None (env: AWS_GLACIER_RETRIEVAL_STRATEGY)")
+		c.Flags().Int64("bytes-per-hour", 0, "Retrieval limit of the BytesPerHour strategy")
+		c.Flags().Bool("json", false, "Output the checks as JSON")
+		c.Flags().String("aws-vault", getEnvWithDefault("AWS_VAULT", ""), "AWS Glacier vault name (env: AWS_VAULT)")
+		c.Flags().String("aws-account-id", getEnvWithDefault("AWS_ACCOUNT_ID", "-"), "AWS account ID or '-' for current account (env: AWS_ACCOUNT_ID)")
+		c.Flags().String("aws-access-key", "", "AWS access key (env: AWS_ACCESS_KEY)")
+		c.Flags().String("aws-secret-access-key", "", "AWS secret access key (env: AWS_SECRET_ACCESS_KEY)")
+		c.Flags().String("aws-auth", getEnvWithDefault("AWS_AUTH", ""), "AWS credential source: static (the access keys) or chain (AWS_ACCESS_KEY_ID, shared config and SSO, web identity, instance profile); default: static when an access key is set, else chain (env: AWS_AUTH)")
+		c.Flags().String("aws-region", getEnvWithDefault("AWS_REGION", "us-east-1"), "AWS region (env: AWS_REGION)")
+		c.Flags().Duration("aws-http-timeout", getEnvDurationWithDefault("AWS_HTTP_TIMEOUT", 0), "AWS HTTP client timeout (e.g., 0s for no timeout) (env: AWS_HTTP_TIMEOUT)")
+		addAWSTLSFlags(c)
+	}
+	backupAWSSetupApplyCmd.Flags().Bool("dry-run", false, "Show what would change without changing anything")
+}
+
+func initAnalyzeFlags() {
+	backupAnalyzeCmd.Flags().Int("top", 20, "Number of largest files to list")
+	backupAnalyzeCmd.Flags().Bool("json", false, "Output JSON")
+	backupAnalyzeCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupAnalyzeCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupAnalyzeCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupAnalyzeCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupAnalyzeCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupAnalyzeCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupAnalyzeCmd)
+}
+
+func initDuplicatesFlags() {
+	backupDuplicatesCmd.Flags().String("prefix", "backups/", "Only compare sites under this prefix")
+	backupDuplicatesCmd.Flags().Float64("threshold", backup.DefaultDuplicateThreshold, "Share of the smaller site's content two sites must have in common to be reported (0-1)")
+	backupDuplicatesCmd.Flags().Bool("json", false, "Output JSON")
+	backupDuplicatesCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Minio endpoint (env: MINIO_ENDPOINT)")
+	backupDuplicatesCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupDuplicatesCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupDuplicatesCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupDuplicatesCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupDuplicatesCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupDuplicatesCmd)
+}
+
+func initReconcileReplicaFlags() {
+	backupReconcileReplicaCmd.Flags().String("prefix", "backups/", "Only compare objects under this prefix")
+	backupReconcileReplicaCmd.Flags().Bool("json", false, "Output the report as JSON")
+	addReplicaFlags(backupReconcileReplicaCmd)
+	backupReconcileReplicaCmd.Flags().String("minio-endpoint", getEnvWithDefault("MINIO_ENDPOINT", ""), "Primary Minio endpoint (env: MINIO_ENDPOINT)")
+	backupReconcileReplicaCmd.Flags().String("minio-access-key", "", "Minio access key (env: MINIO_ACCESS_KEY)")
+	backupReconcileReplicaCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
+	backupReconcileReplicaCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
+	backupReconcileReplicaCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupReconcileReplicaCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupReconcileReplicaCmd)
+	addMinioListingFlags(backupReconcileReplicaCmd)
 }
 
 func initEstimateCapacityFlags() {
 	backupEstimateCapacityCmd.Flags().String("server-range", "", "Server range pattern (e.g., 'wp%d.example.com:0-41')")
-	backupEstimateCapacityCmd.Flags().String("estimate-method", "heuristic", "Compression estimation method: 'heuristic' (~20s/site, 80% accurate), 'sample' (~30s/site, 90% accurate), 'accurate' (~3-5min/site over SSH, 100% accurate)")
-	backupEstimateCapacityCmd.Flags().Int64("sample-size", 100*1024*1024, "Sample size in bytes for 'sample' estimation method (default: 100MB)")
+	backupEstimateCapacityCmd.Flags().String("estimate-method", "heuristic", "Compression estimation method: 'heuristic' (~20s/site, 80% accurate), 'sample' (~30s/site, 90% accurate), 'adaptive' (samples until --sample-target), 'accurate' (~3-5min/site over SSH, 100% accurate)")
+	backupEstimateCapacityCmd.Flags().Int64("sample-size", 100*1024*1024, "Sample size in bytes for 'sample' estimation method, and of the first round of 'adaptive' (default: 100MB)")
+	backupEstimateCapacityCmd.Flags().Float64("sample-target", getEnvFloat64WithDefault("BACKUP_SAMPLE_TARGET", backup.DefaultSampleTarget), "Relative 95% confidence interval 'adaptive' estimation samples until, e.g. 0.05 for ±5% (env: BACKUP_SAMPLE_TARGET)")
+	backupEstimateCapacityCmd.Flags().Int64("max-sample-size", backup.DefaultMaxSampleSize, "Bytes 'adaptive' estimation compresses per site at most (default: 1GB)")
+	backupEstimateCapacityCmd.Flags().Int("estimate-parallelism", getEnvIntWithDefault("BACKUP_ESTIMATE_PARALLELISM", 1), "Containers analyzed at once per server; keep below the server's sshd MaxSessions (default 10) (env: BACKUP_ESTIMATE_PARALLELISM)")
+	addCompressionModelFlag(backupEstimateCapacityCmd)
+	addGroupFlags(backupEstimateCapacityCmd)
 
 	// Baseline input methods
 	backupEstimateCapacityCmd.Flags().String("from-backup", "", "Use existing backup file as baseline (path to backup in Minio)")
@@ -540,6 +3151,9 @@ func initEstimateCapacityFlags() {
 	backupEstimateCapacityCmd.Flags().Int("daily-retention", getEnvIntWithDefault("BACKUP_DAILY_RETENTION", 14), "Number of daily backups to retain (default: 14, env: BACKUP_DAILY_RETENTION)")
 	backupEstimateCapacityCmd.Flags().Int("weekly-retention", getEnvIntWithDefault("BACKUP_WEEKLY_RETENTION", 26), "Number of weekly backups to retain (default: 26, env: BACKUP_WEEKLY_RETENTION)")
 	backupEstimateCapacityCmd.Flags().Int("monthly-retention", getEnvIntWithDefault("BACKUP_MONTHLY_RETENTION", 6), "Number of monthly backups to retain (default: 6, env: BACKUP_MONTHLY_RETENTION)")
+	addRetentionPresetFlags(backupEstimateCapacityCmd)
+	backupEstimateCapacityCmd.Flags().Int("migrate-after-days", getEnvIntWithDefault("BACKUP_MIGRATE_AFTER_DAYS", 0), "Daily backups older than this many days are modeled in Glacier instead of Minio (0 = all daily backups stay hot, env: BACKUP_MIGRATE_AFTER_DAYS)")
+	backupEstimateCapacityCmd.Flags().Int("aggressive-migrate-after-days", backup.DefaultAggressiveMigrateAfterDays, "Migration cadence of the aggressive scenario compared against --migrate-after-days")
 
 	// Focus and output control
 	backupEstimateCapacityCmd.Flags().String("estimate-focus", "all", "Focus: 'growth-modeling', 'static-capacity', or 'all' (default: all)")
@@ -558,6 +3172,11 @@ func initEstimateCapacityFlags() {
 	// Storage recommendations
 	backupEstimateCapacityCmd.Flags().String("available-storage", "", "Available Minio storage capacity (e.g., '500GB', '2TB') for recommendations")
 
+	// Erasure coding
+	backupEstimateCapacityCmd.Flags().Int("ec-data", getEnvIntWithDefault("BACKUP_EC_DATA", 0), "Data shards per erasure set of the Minio deployment; 0 sizes hot storage as raw object bytes (env: BACKUP_EC_DATA)")
+	backupEstimateCapacityCmd.Flags().Int("ec-parity", getEnvIntWithDefault("BACKUP_EC_PARITY", 0), "Parity shards per erasure set (the N of EC:N) (env: BACKUP_EC_PARITY)")
+	backupEstimateCapacityCmd.Flags().Bool("ec-detect", false, "Read the erasure-coding layout from the Minio admin API (needs admin:StorageInfo)")
+
 	// SSH flags for live scanning
 	backupEstimateCapacityCmd.Flags().StringP("user", "u", getEnvWithDefault("SSH_USER", ""), "SSH username (env: SSH_USER)")
 	backupEstimateCapacityCmd.Flags().StringP("port", "p", getEnvWithDefault("SSH_PORT", "22"), "SSH port (env: SSH_PORT)")
@@ -571,97 +3190,77 @@ func initEstimateCapacityFlags() {
 	backupEstimateCapacityCmd.Flags().String("minio-secret-key", "", "Minio secret key (env: MINIO_SECRET_KEY)")
 	backupEstimateCapacityCmd.Flags().String("minio-bucket", getEnvWithDefault("MINIO_BUCKET", "backups"), "Minio bucket name (env: MINIO_BUCKET)")
 	backupEstimateCapacityCmd.Flags().Bool("minio-ssl", getEnvBoolWithDefault("MINIO_SSL", true), "Use SSL for Minio connection (env: MINIO_SSL)")
+	backupEstimateCapacityCmd.Flags().Duration("minio-http-timeout", getEnvDurationWithDefault("MINIO_HTTP_TIMEOUT", 0), "Minio HTTP client timeout (e.g., 0s for no timeout) (env: MINIO_HTTP_TIMEOUT)")
+	addMinioTLSFlags(backupEstimateCapacityCmd)
 
 	// Optional: container parent directory
 	backupEstimateCapacityCmd.Flags().String("container-parent-dir", "/var/opt/sites", "Parent directory where site working directories live (default: /var/opt/sites)")
 }
 
-// getMinioConfig creates Minio configuration from command flags
-func getMinioConfig(cmd *cobra.Command) (*backup.MinioConfig, error) {
-	endpoint := mustGetStringFlag(cmd, "minio-endpoint")
-	if endpoint == "" {
-		endpoint = getEnvWithDefault("MINIO_ENDPOINT", "")
-	}
-	if endpoint == "" {
-		return nil, fmt.Errorf("minio-endpoint is required (use --minio-endpoint or set MINIO_ENDPOINT)")
-	}
-
-	accessKey := mustGetStringFlag(cmd, "minio-access-key")
-	if accessKey == "" {
-		accessKey = getEnvWithDefault("MINIO_ACCESS_KEY", "")
-	}
-	if accessKey == "" {
-		return nil, fmt.Errorf("minio-access-key is required (use --minio-access-key or set MINIO_ACCESS_KEY)")
-	}
-
-	secretKey := mustGetStringFlag(cmd, "minio-secret-key")
-	if secretKey == "" {
-		secretKey = getEnvWithDefault("MINIO_SECRET_KEY", "")
-	}
-	if secretKey == "" {
-		return nil, fmt.Errorf("minio-secret-key is required (use --minio-secret-key or set MINIO_SECRET_KEY)")
-	}
+// addMinioTLSFlags registers the Minio TLS trust flags (CA bundle, mTLS client
+// certificate and insecure-skip-verify) on a command.
+func addMinioTLSFlags(c *cobra.Command) {
+	c.Flags().String("minio-ca-bundle", getEnvWithDefault("MINIO_CA_BUNDLE", ""), "PEM CA bundle used to verify the Minio certificate (env: MINIO_CA_BUNDLE)")
+	c.Flags().String("minio-client-cert", getEnvWithDefault("MINIO_CLIENT_CERT", ""), "Client certificate for Minio mTLS (env: MINIO_CLIENT_CERT)")
+	c.Flags().String("minio-client-key", getEnvWithDefault("MINIO_CLIENT_KEY", ""), "Client private key for Minio mTLS (env: MINIO_CLIENT_KEY)")
+	c.Flags().Bool("minio-insecure-skip-verify", getEnvBoolWithDefault("MINIO_INSECURE_SKIP_VERIFY", false), "DANGEROUS: skip Minio TLS certificate verification (env: MINIO_INSECURE_SKIP_VERIFY)")
+	c.Flags().String("minio-via-ssh", getEnvWithDefault("MINIO_VIA_SSH", ""), "Tunnel Minio traffic through SSH to this [user@]host; --minio-endpoint is then resolved from that host (env: MINIO_VIA_SSH)")
+}
 
-	bucket := mustGetStringFlag(cmd, "minio-bucket")
-	useSSL := mustGetBoolFlag(cmd, "minio-ssl")
-	httpTimeout := mustGetDurationFlag(cmd, "minio-http-timeout")
+// addMinioListingFlags registers the flags that tune listing of large buckets.
+func addMinioListingFlags(c *cobra.Command) {
+	c.Flags().Int("list-parallelism", getEnvIntWithDefault("MINIO_LIST_PARALLELISM", 1), "List each top-level directory under the prefix as its own shard, this many at a time (env: MINIO_LIST_PARALLELISM)")
+	c.Flags().Float64("list-rate", getEnvFloat64WithDefault("MINIO_LIST_RATE", 0), "Maximum Minio LIST requests per second, 0 for unlimited (env: MINIO_LIST_RATE)")
+	c.Flags().Int("list-page-size", getEnvIntWithDefault("MINIO_LIST_PAGE_SIZE", 0), "Keys requested per LIST call, 0 for the server default of 1000 (env: MINIO_LIST_PAGE_SIZE)")
+}
 
-	// Get bucket path if available
-	var bucketPath string
-	if cmd.Flags().Lookup("bucket-path") != nil {
-		bucketPath = mustGetStringFlag(cmd, "bucket-path")
-	}
+// addMinioUploadFlags registers the flags that tune multipart uploads to
+// Minio; `backup bench` recommends values for them.
+func addMinioUploadFlags(c *cobra.Command) {
+	c.Flags().String("minio-part-size", getEnvWithDefault("MINIO_PART_SIZE", ""), "Multipart part size of Minio uploads, 5MB to 5GB; empty lets the SDK choose (env: MINIO_PART_SIZE)")
+	c.Flags().String("storage-classes", getEnvWithDefault("MINIO_STORAGE_CLASSES", ""), "S3 storage class per retention tier set on upload, e.g. daily=STANDARD_IA,weekly=GLACIER_IR,monthly=DEEP_ARCHIVE; unmapped tiers use the next lower tier's class (env: MINIO_STORAGE_CLASSES)")
+	c.Flags().Int("minio-upload-concurrency", getEnvIntWithDefault("MINIO_UPLOAD_CONCURRENCY", 0), "Parts of a Minio upload sent at once, each buffered in memory; 0 or 1 sends them in turn (env: MINIO_UPLOAD_CONCURRENCY)")
+}
 
-	return &backup.MinioConfig{
-		Endpoint:    endpoint,
-		AccessKey:   accessKey,
-		SecretKey:   secretKey,
-		Bucket:      bucket,
-		UseSSL:      useSSL,
-		BucketPath:  bucketPath,
-		HTTPTimeout: httpTimeout,
-	}, nil
+// addBucketSummaryFlags registers the flags of commands that read the
+// bucket summary.
+func addBucketSummaryFlags(c *cobra.Command) {
+	c.Flags().Bool("no-cache", false, "Ignore the bucket summary and list the bucket")
+	c.Flags().Duration("summary-max-age", getEnvDurationWithDefault("BACKUP_SUMMARY_MAX_AGE", backup.DefaultBucketSummaryMaxAge), "Rebuild the bucket summary from a full listing when it is older than this, 0 never (env: BACKUP_SUMMARY_MAX_AGE)")
 }
 
-// getAWSConfig creates AWS configuration from command flags
-func getAWSConfig(cmd *cobra.Command) (*backup.AWSConfig, error) {
-	vault := mustGetStringFlag(cmd, "aws-vault")
-	if vault == "" {
-		vault = getEnvWithDefault("AWS_VAULT", "")
-	}
-	if vault == "" {
-		// AWS is optional, so return nil if not configured
-		return nil, nil
-	}
+// addListingCacheFlag registers --list-cache-ttl on read-mostly commands.
+func addListingCacheFlag(c *cobra.Command) {
+	c.Flags().Duration("list-cache-ttl", getEnvDurationWithDefault("MINIO_LIST_CACHE_TTL", 0), "Serve full listings from a cache object in the bucket while younger than this and refresh it when older; objects added by other hosts within the TTL are not seen (0 disables, env: MINIO_LIST_CACHE_TTL)")
+}
 
-	accessKey := mustGetStringFlag(cmd, "aws-access-key")
-	if accessKey == "" {
-		accessKey = getEnvWithDefault("AWS_ACCESS_KEY", "")
-	}
+// addAWSTLSFlags registers the AWS TLS trust flags on a command.
+func addAWSTLSFlags(c *cobra.Command) {
+	c.Flags().String("aws-ca-bundle", getEnvWithDefault("AWS_CA_BUNDLE", ""), "PEM CA bundle used to verify AWS endpoints (env: AWS_CA_BUNDLE)")
+	c.Flags().String("aws-client-cert", getEnvWithDefault("AWS_CLIENT_CERT", ""), "Client certificate presented to AWS endpoints/proxies (env: AWS_CLIENT_CERT)")
+	c.Flags().String("aws-client-key", getEnvWithDefault("AWS_CLIENT_KEY", ""), "Client private key for --aws-client-cert (env: AWS_CLIENT_KEY)")
+	c.Flags().Bool("aws-insecure-skip-verify", getEnvBoolWithDefault("AWS_INSECURE_SKIP_VERIFY", false), "DANGEROUS: skip AWS TLS certificate verification (env: AWS_INSECURE_SKIP_VERIFY)")
+}
 
-	secretKey := mustGetStringFlag(cmd, "aws-secret-access-key")
-	if secretKey == "" {
-		secretKey = getEnvWithDefault("AWS_SECRET_ACCESS_KEY", "")
+// getMinioConfig returns the Minio configuration of cmd, failing when no
+// endpoint is configured or any connection setting is invalid.
+func getMinioConfig(cmd *cobra.Command) (*backup.MinioConfig, error) {
+	cfg, err := commandConfig(cmd)
+	if err != nil {
+		return nil, err
 	}
-
-	region := mustGetStringFlag(cmd, "aws-region")
-	if region == "" {
-		region = getEnvWithDefault("AWS_REGION", "us-east-1")
+	if cfg.Minio == nil {
+		return nil, fmt.Errorf("minio-endpoint is required (use --minio-endpoint or set MINIO_ENDPOINT)")
 	}
+	return cfg.Minio, nil
+}
 
-	accountID := mustGetStringFlag(cmd, "aws-account-id")
-	if accountID == "" {
-		accountID = getEnvWithDefault("AWS_ACCOUNT_ID", "-")
+// getAWSConfig returns the AWS configuration of cmd, or nil when no vault is
+// configured.
+func getAWSConfig(cmd *cobra.Command) (*backup.AWSConfig, error) {
+	cfg, err := commandConfig(cmd)
+	if err != nil {
+		return nil, err
 	}
-
-	httpTimeout := mustGetDurationFlag(cmd, "aws-http-timeout")
-
-	return &backup.AWSConfig{
-		Vault:       vault,
-		AccountID:   accountID,
-		AccessKey:   accessKey,
-		SecretKey:   secretKey,
-		Region:      region,
-		HTTPTimeout: httpTimeout,
-	}, nil
+	return cfg.AWS, nil
 }
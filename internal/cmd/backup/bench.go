@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupBench(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	target := mustGetStringFlag(cmd, "target")
+	size, err := parseSize(mustGetStringFlag(cmd, "size"))
+	if err != nil {
+		return fmt.Errorf("invalid --size: %w", err)
+	}
+	opts := backup.BenchOptions{Target: target, Size: size}
+	partSizes, err := cmd.Flags().GetStringSlice("part-sizes")
+	if err != nil {
+		return err
+	}
+	for _, s := range partSizes {
+		ps, err := parseSize(s)
+		if err != nil {
+			return fmt.Errorf("invalid --part-sizes: %w", err)
+		}
+		opts.PartSizes = append(opts.PartSizes, ps)
+	}
+	if opts.Concurrency, err = cmd.Flags().GetIntSlice("concurrency"); err != nil {
+		return err
+	}
+	jsonOut := mustGetBoolFlag(cmd, "json")
+	if !jsonOut {
+		opts.Progress = func(i, n int, partSize int64, concurrency int) {
+			fmt.Fprintf(os.Stderr, "Run %d/%d: %s parts, concurrency %d...\n", i, n, benchSize(partSize), concurrency)
+		}
+	}
+
+	var manager *backup.BackupManager
+	switch target {
+	case backup.BenchTargetMinio:
+		minioConfig, err := getMinioConfig(cmd)
+		if err != nil {
+			return err
+		}
+		manager = backup.NewBackupManager(nil, minioConfig)
+	case backup.BenchTargetGlacier:
+		awsConfig, err := getAWSConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if awsConfig == nil {
+			return fmt.Errorf("aws-vault is required for --target glacier (use --aws-vault or set AWS_VAULT)")
+		}
+		manager = backup.NewBackupManagerWithAWS(nil, nil, awsConfig)
+	default:
+		return fmt.Errorf("unknown --target %q (want minio or glacier)", target)
+	}
+
+	if mustGetBoolFlag(cmd, "dry-run") {
+		return printBenchPlan(opts)
+	}
+	rep, err := manager.BenchUploads(opts)
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PART SIZE\tCONCURRENCY\tSECONDS\tMB/s\t")
+		for _, r := range rep.Results {
+			if r.Error != "" {
+				fmt.Fprintf(w, "%s\t%d\t-\tfailed: %s\t\n", benchSize(r.PartSize), r.Concurrency, r.Error)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.1f\t%.1f\t\n", benchSize(r.PartSize), r.Concurrency, r.Seconds, r.MBps)
+		}
+		w.Flush()
+	}
+
+	rec := rep.Recommended
+	if rec == nil {
+		return fmt.Errorf("every bench run failed")
+	}
+	tuning := &backup.BackupProfileTuning{}
+	if target == backup.BenchTargetMinio {
+		tuning.MinioPartSize, tuning.MinioConcurrency = benchSize(rec.PartSize), rec.Concurrency
+		fmt.Fprintf(os.Stderr, "\nRecommended: --minio-part-size %s --minio-upload-concurrency %d (%.1f MB/s)\n", tuning.MinioPartSize, rec.Concurrency, rec.MBps)
+	} else {
+		tuning.GlacierPartSize = benchSize(rec.PartSize)
+		fmt.Fprintf(os.Stderr, "\nRecommended: --aws-part-size %s (%.1f MB/s)\n", tuning.GlacierPartSize, rec.MBps)
+	}
+	if !mustGetBoolFlag(cmd, "save") {
+		return nil
+	}
+	if activeProfile == "" {
+		fmt.Fprintf(os.Stderr, "No backup profile is active; run 'backup init' to keep these settings, or set them in your .env\n")
+		return nil
+	}
+	return saveBenchTuning(activeProfile, tuning)
+}
+
+// printBenchPlan prints the runs a bench with opts would make.
+func printBenchPlan(opts backup.BenchOptions) error {
+	runs, err := backup.BenchRuns(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[DRY RUN] Would upload %s to %s %d time(s):\n", benchSize(opts.Size), opts.Target, len(runs))
+	for _, r := range runs {
+		fmt.Printf("  - %s parts, concurrency %d\n", benchSize(r.PartSize), r.Concurrency)
+	}
+	return nil
+}
+
+// saveBenchTuning merges the settings bench recommends into the tuning of
+// the named profile, keeping those of the other target.
+func saveBenchTuning(name string, t *backup.BackupProfileTuning) error {
+	path := backup.BackupProfilePath(name)
+	p, err := backup.LoadBackupProfile(path)
+	if err != nil {
+		return err
+	}
+	if p.Tuning == nil {
+		p.Tuning = &backup.BackupProfileTuning{}
+	}
+	if t.MinioPartSize != "" {
+		p.Tuning.MinioPartSize, p.Tuning.MinioConcurrency = t.MinioPartSize, t.MinioConcurrency
+	}
+	if t.GlacierPartSize != "" {
+		p.Tuning.GlacierPartSize = t.GlacierPartSize
+	}
+	if err := backup.SaveBackupProfile(path, p); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✓ Saved to profile %q (%s)\n", name, path)
+	return nil
+}
+
+// benchSize formats a part size, a whole number of MiB, as parseSize reads it.
+func benchSize(n int64) string {
+	if n%(1024*1024*1024) == 0 {
+		return fmt.Sprintf("%dGB", n/(1024*1024*1024))
+	}
+	return fmt.Sprintf("%dMB", n/(1024*1024))
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupExportBundle(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	opts := backup.ExportBundleOptions{
+		Site:     mustGetStringFlag(cmd, "site"),
+		Prefix:   mustGetStringFlag(cmd, "prefix"),
+		OutDir:   mustGetStringFlag(cmd, "out"),
+		Sanitize: mustGetBoolFlag(cmd, "sanitize"),
+		Operator: mustGetStringFlag(cmd, "operator"),
+	}
+	if opts.Site == "" {
+		return fmt.Errorf("--site is required")
+	}
+	if opts.OutDir == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	if keyPath := mustGetStringFlag(cmd, "signing-key"); keyPath != "" {
+		key, err := backup.LoadSigningKey(keyPath)
+		if err != nil {
+			return err
+		}
+		opts.SigningKey = key
+	} else if !mustGetBoolFlag(cmd, "no-sign") {
+		return fmt.Errorf("--signing-key is required (or pass --no-sign for an unsigned bundle)")
+	}
+
+	if rulesPath := mustGetStringFlag(cmd, "rules"); rulesPath != "" {
+		if !opts.Sanitize {
+			return fmt.Errorf("--rules requires --sanitize")
+		}
+		rules, err := backup.LoadSanitizeRules(rulesPath)
+		if err != nil {
+			return err
+		}
+		opts.Rules = rules
+	}
+
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	history, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	opts.History = history
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+
+	res, err := bm.ExportBundle(opts)
+	if err != nil {
+		return fmt.Errorf("failed to export bundle: %w", err)
+	}
+
+	fmt.Printf("\n✓ Bundle for %s written to %s\n", opts.Site, res.Dir)
+	fmt.Printf("  Backup:    %s\n", res.Backup.Stem)
+	if res.Sanitized {
+		fmt.Printf("  Sanitized: yes\n")
+	}
+	if res.Manifest == nil {
+		fmt.Printf("  Manifest:  none (the backup predates runtime manifests)\n")
+	}
+	fmt.Printf("  History:   %d stored cop(ies) in %s\n", res.History, backup.BundleInventoryFile)
+	if res.Signed {
+		fmt.Printf("  Signed:    %s (public key in %s)\n", backup.BundleSignatureFile, backup.BundlePublicKeyFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "⚠️  The bundle is not signed\n")
+	}
+	return nil
+}
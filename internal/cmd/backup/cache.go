@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addCacheFlags registers the latest-backup cache flags. Commands that read
+// backups consult the cache when one is configured.
+func addCacheFlags(c *cobra.Command) {
+	c.Flags().String("cache-dir", getEnvWithDefault("BACKUP_CACHE_DIR", ""), "Local directory holding the latest backup of every site (env: BACKUP_CACHE_DIR)")
+	c.Flags().String("cache-profile", getEnvWithDefault("BACKUP_CACHE_PROFILE", ""), "Storage profile of a nearby bucket holding the latest backup of every site, instead of --cache-dir (env: BACKUP_CACHE_PROFILE)")
+	if c.Flags().Lookup("profiles-file") == nil {
+		c.Flags().String("profiles-file", getEnvWithDefault("BACKUP_MINIO_PROFILES", ""), "Storage profiles file (default: ~/.ciwg/minio-profiles.yaml, env: BACKUP_MINIO_PROFILES)")
+	}
+}
+
+// loadCache returns the manager of the configured cache and a description of
+// it, or nil when no cache is configured.
+func loadCache(cmd *cobra.Command) (*backup.BackupManager, string, error) {
+	dir := mustGetStringFlag(cmd, "cache-dir")
+	name := mustGetStringFlag(cmd, "cache-profile")
+	switch {
+	case dir != "" && name != "":
+		return nil, "", fmt.Errorf("--cache-dir and --cache-profile cannot be used together")
+	case dir != "":
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid --cache-dir: %w", err)
+		}
+		return backup.NewBackupManager(nil, &backup.MinioConfig{Endpoint: "file://" + abs}), abs, nil
+	case name != "":
+		profilesFile := mustGetStringFlag(cmd, "profiles-file")
+		if profilesFile == "" {
+			profilesFile = backup.DefaultMinioProfilesPath()
+		}
+		cfg, err := backup.LoadMinioProfile(profilesFile, name)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load cache profile: %w", err)
+		}
+		return backup.NewBackupManager(nil, cfg), "profile " + name, nil
+	}
+	return nil, "", nil
+}
+
+// applyReadCache makes bm serve downloads from the configured cache.
+func applyReadCache(cmd *cobra.Command, bm *backup.BackupManager) error {
+	cache, _, err := loadCache(cmd)
+	if err != nil || cache == nil {
+		return err
+	}
+	bm.SetReadCache(cache)
+	return nil
+}
+
+func runBackupCacheLatest(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	cache, where, err := loadCache(cmd)
+	if err != nil {
+		return err
+	}
+	if cache == nil {
+		return fmt.Errorf("--cache-dir or --cache-profile is required (or set BACKUP_CACHE_DIR / BACKUP_CACHE_PROFILE)")
+	}
+
+	prefix := mustGetStringFlag(cmd, "prefix")
+	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	cache.SetDryRun(dryRun)
+	fmt.Printf("Caching the latest backup of every site under %q in %s\n", prefix, where)
+
+	res, err := backup.NewBackupManager(nil, minioConfig).CacheLatest(cache, prefix, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "cached"
+	if dryRun {
+		verb = "would cache"
+	}
+	fmt.Printf("\n%d site(s): %s %d (%.2f MB), %d already current, %d evicted, %d failed\n",
+		res.Sites, verb, res.Cached, float64(res.Bytes)/(1024*1024), res.Fresh, res.Evicted, res.Failed)
+	if res.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d site(s) failed to cache", res.Failed), res.Cached+res.Fresh)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// loadCompressionModel returns the heuristic compression model selected by
+// --compression-model, falling back to the default location.
+func loadCompressionModel(cmd *cobra.Command) (*backup.CompressionModel, error) {
+	path := mustGetStringFlag(cmd, "compression-model")
+	if path == "" {
+		path = backup.DefaultCompressionModelPath()
+	}
+	return backup.LoadCompressionModel(path)
+}
+
+func runBackupEstimateCalibrate(cmd *cobra.Command, args []string) error {
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+
+	count := mustGetIntFlag(cmd, "count")
+	if count <= 0 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	minSample, err := parseSize(mustGetStringFlag(cmd, "min-sample"))
+	if err != nil {
+		return fmt.Errorf("invalid --min-sample: %w", err)
+	}
+	output := mustGetStringFlag(cmd, "output")
+	if output == "" {
+		output = backup.DefaultCompressionModelPath()
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+
+	objects, err := bm.ListBackups(mustGetStringFlag(cmd, "prefix"), 0)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	var tarballs []backup.ObjectInfo
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Key, ".tgz") || strings.HasSuffix(obj.Key, ".tar.gz") {
+			tarballs = append(tarballs, obj)
+		}
+	}
+	if len(tarballs) == 0 {
+		return fmt.Errorf("no backup tarballs found to calibrate from")
+	}
+	sort.Slice(tarballs, func(i, j int) bool {
+		return tarballs[i].LastModified.After(tarballs[j].LastModified)
+	})
+	if len(tarballs) > count {
+		tarballs = tarballs[:count]
+	}
+	keys := make([]string, 0, len(tarballs))
+	for _, obj := range tarballs {
+		keys = append(keys, obj.Key)
+	}
+
+	result, err := bm.CalibrateCompressionModel(keys, minSample)
+	if err != nil {
+		return err
+	}
+
+	exts := make([]string, 0, len(result.Model.Ratios))
+	for ext := range result.Model.Ratios {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	fmt.Printf("\nCalibrated from %d backup(s), %d file(s), %.2f MB uncompressed\n",
+		result.Objects, result.Files, float64(result.Uncompressed)/(1024*1024))
+	fmt.Printf("  Default ratio: %.3f\n", result.Model.DefaultRatio)
+	fmt.Printf("  Scale:         %.3f\n", result.Model.Scale)
+	for _, ext := range exts {
+		fmt.Printf("  %-12s %.3f\n", ext, result.Model.Ratios[ext])
+	}
+	fmt.Printf("\nActual compressed: %.2f MB, per-file ratios alone predict: %.2f MB\n",
+		float64(result.Compressed)/(1024*1024), float64(result.Predicted)/(1024*1024))
+
+	if mustGetBoolFlag(cmd, "dry-run") {
+		fmt.Println("[DRY RUN] Model not saved")
+		return nil
+	}
+	if err := result.Model.Save(output); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Compression model saved to %s\n", output)
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
 )
 
 func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
@@ -24,12 +25,31 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 	serverRange := mustGetStringFlag(cmd, "server-range")
 	estimateMethod := mustGetStringFlag(cmd, "estimate-method")
 	sampleSize := mustGetInt64Flag(cmd, "sample-size")
+	parallelism := mustGetIntFlag(cmd, "estimate-parallelism")
+	if parallelism < 1 {
+		return fmt.Errorf("--estimate-parallelism must be at least 1")
+	}
 	fromBackup := mustGetStringFlag(cmd, "from-backup")
 	avgSizeStr := mustGetStringFlag(cmd, "avg-compressed-size")
 	siteCount := mustGetIntFlag(cmd, "site-count")
 	dailyRetention := mustGetIntFlag(cmd, "daily-retention")
 	weeklyRetention := mustGetIntFlag(cmd, "weekly-retention")
 	monthlyRetention := mustGetIntFlag(cmd, "monthly-retention")
+	preset, presetErr := resolveRetentionPreset(cmd)
+	if presetErr != nil {
+		return presetErr
+	}
+	if preset != nil {
+		dailyRetention, weeklyRetention, monthlyRetention = preset.KeepDaily, preset.KeepWeekly, preset.KeepMonthly
+		overrideIntFromFlag(cmd, "daily-retention", &dailyRetention)
+		overrideIntFromFlag(cmd, "weekly-retention", &weeklyRetention)
+		overrideIntFromFlag(cmd, "monthly-retention", &monthlyRetention)
+	}
+	migrateAfterDays := mustGetIntFlag(cmd, "migrate-after-days")
+	aggressiveMigrateAfterDays := mustGetIntFlag(cmd, "aggressive-migrate-after-days")
+	if migrateAfterDays < 0 || aggressiveMigrateAfterDays < 1 {
+		return fmt.Errorf("--migrate-after-days must not be negative and --aggressive-migrate-after-days must be at least 1")
+	}
 	estimateFocus := mustGetStringFlag(cmd, "estimate-focus")
 	estimateType := mustGetStringFlag(cmd, "estimate-type")
 	outputFormat := mustGetStringFlag(cmd, "output")
@@ -73,8 +93,13 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 	}
 
 	// Validate input methods
+	group, err := loadGroup(cmd)
+	if err != nil {
+		return err
+	}
+
 	inputCount := 0
-	if hostname != "" || serverRange != "" {
+	if hostname != "" || serverRange != "" || group != nil {
 		inputCount++
 	}
 	if fromBackup != "" {
@@ -85,7 +110,7 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 	}
 
 	if inputCount == 0 {
-		return fmt.Errorf("must specify one data source: hostname/--server-range, --from-backup, or --avg-compressed-size")
+		return fmt.Errorf("must specify one data source: hostname/--server-range/--group, --from-backup, or --avg-compressed-size")
 	}
 	if inputCount > 1 {
 		return fmt.Errorf("only one data source can be specified at a time")
@@ -101,15 +126,24 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 		DailyRetention:      dailyRetention,
 		WeeklyRetention:     weeklyRetention,
 		MonthlyRetention:    monthlyRetention,
+		MigrateAfterDays:    migrateAfterDays,
 		GrowthRate:          growthRate,
 		ProjectionMonths:    projectionMonths,
 		BufferPercent:       bufferPercent,
 		GlacierPricePerGB:   glacierPrice,
 		RetrievalPricePerGB: retrievalPrice,
+		Parallelism:         parallelism,
+
+		AggressiveMigrateAfterDays: aggressiveMigrateAfterDays,
 	}
 
+	erasure, err := resolveErasureCoding(cmd)
+	if err != nil {
+		return err
+	}
+	capacityOpts.Erasure = erasure
+
 	var estimate *backup.CapacityEstimate
-	var err error
 
 	// Process based on data source
 	if avgSizeStr != "" {
@@ -141,6 +175,10 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 
 	} else {
 		// Live scanning mode (hostname or server-range)
+		model, modelErr := loadCompressionModel(cmd)
+		if modelErr != nil {
+			return modelErr
+		}
 		if hostname != "" {
 			// Single server scan
 			sshClient, sshErr := createSSHClient(cmd, hostname)
@@ -150,6 +188,9 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 			defer sshClient.Close()
 
 			manager := backup.NewBackupManager(sshClient, nil)
+			manager.SetCompressionModel(model)
+			applyCommandAudit(cmd, manager)
+			manager.SetAdaptiveSampling(mustGetFloat64Flag(cmd, "sample-target"), mustGetInt64Flag(cmd, "max-sample-size"))
 
 			// Get containers
 			containers, containerErr := manager.GetContainersFromOptions(&backup.BackupOptions{
@@ -158,6 +199,7 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 			if containerErr != nil {
 				return fmt.Errorf("failed to get containers: %w", containerErr)
 			}
+			containers = group.FilterContainers(containers, hostname)
 
 			estimate, err = manager.EstimateCapacityFromScan(containers, estimateMethod, sampleSize, capacityOpts)
 			if err != nil {
@@ -165,8 +207,18 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 			}
 
 		} else {
-			// Server range scan
-			estimate, err = processCapacityEstimateForServerRange(cmd, serverRange, estimateMethod, sampleSize, parentDir, capacityOpts, outputFormat)
+			// Server range or group scan
+			hosts, source := group.Hosts(), "group "+group.String()
+			if serverRange != "" {
+				if hosts, err = serverRangeHosts(serverRange); err != nil {
+					return err
+				}
+				source = "server range: " + serverRange
+			}
+			if len(hosts) == 0 {
+				return fmt.Errorf("no hosts in the fleet file match group %s", group)
+			}
+			estimate, err = processCapacityEstimateForServerRange(cmd, hosts, source, estimateMethod, sampleSize, parentDir, group, capacityOpts, outputFormat)
 			if err != nil {
 				return err
 			}
@@ -189,12 +241,29 @@ func runBackupEstimateCapacity(cmd *cobra.Command, args []string) error {
 	}
 }
 
-// processCapacityEstimateForServerRange handles server range processing
-func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, estimateMethod string, sampleSize int64, parentDir string, options *backup.CapacityEstimateOptions, outputFormat string) (*backup.CapacityEstimate, error) {
+// serverRangeHosts expands a --server-range pattern into its hostnames,
+// leaving out the excluded ones.
+func serverRangeHosts(serverRange string) ([]string, error) {
 	pattern, start, end, exclusions, err := parseServerRange(serverRange)
 	if err != nil {
 		return nil, err
 	}
+	var hosts []string
+	for i := start; i <= end; i++ {
+		if !exclusions[i] {
+			hosts = append(hosts, fmt.Sprintf(pattern, i))
+		}
+	}
+	return hosts, nil
+}
+
+// processCapacityEstimateForServerRange scans every host of a server range
+// or group (described by source) and aggregates the estimates.
+func processCapacityEstimateForServerRange(cmd *cobra.Command, hosts []string, source, estimateMethod string, sampleSize int64, parentDir string, group *backup.FleetGroup, options *backup.CapacityEstimateOptions, outputFormat string) (*backup.CapacityEstimate, error) {
+	model, err := loadCompressionModel(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	// Collect estimates from each server
 	var serverEstimates []*backup.CapacityEstimate
@@ -207,16 +276,12 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 	quiet := outputFormat == "json" || outputFormat == "csv"
 
 	if !quiet {
-		fmt.Printf("🌐 Scanning server range: %s\n\n", serverRange)
+		fmt.Printf("🌐 Scanning %s\n\n", source)
 	}
 
-	for i := start; i <= end; i++ {
-		if exclusions[i] {
-			continue
-		}
+	for _, hostname := range hosts {
 		totalServers++
 
-		hostname := fmt.Sprintf(pattern, i)
 		if !quiet {
 			fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 			fmt.Printf("Server: %s\n", hostname)
@@ -232,6 +297,9 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 		}
 
 		manager := backup.NewBackupManager(sshClient, nil)
+		manager.SetCompressionModel(model)
+		applyCommandAudit(cmd, manager)
+		manager.SetAdaptiveSampling(mustGetFloat64Flag(cmd, "sample-target"), mustGetInt64Flag(cmd, "max-sample-size"))
 		containers, err := manager.GetContainersFromOptions(&backup.BackupOptions{
 			ParentDir: parentDir,
 		})
@@ -243,6 +311,7 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 			sshClient.Close()
 			continue
 		}
+		containers = group.FilterContainers(containers, hostname)
 
 		if len(containers) == 0 {
 			if !quiet {
@@ -302,7 +371,10 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 		WeeklyRetention:     options.WeeklyRetention,
 		MonthlyRetention:    options.MonthlyRetention,
 		TotalBackupsPerSite: options.DailyRetention + options.WeeklyRetention + options.MonthlyRetention,
+		MigrateAfterDays:    options.MigrateAfterDays,
+		HotBackupsPerSite:   options.HotBackups(),
 		BufferPercent:       options.BufferPercent,
+		Erasure:             options.Erasure,
 		Sites:               allSites,
 	}
 
@@ -323,8 +395,8 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 	}
 
 	// Calculate per-site storage requirements
-	combinedEstimate.PerSiteHotStorage = combinedEstimate.AvgCompressedSize * int64(options.DailyRetention)
-	combinedEstimate.PerSiteColdStorage = combinedEstimate.AvgCompressedSize * int64(options.WeeklyRetention+options.MonthlyRetention)
+	combinedEstimate.PerSiteHotStorage = options.Erasure.OnDisk(combinedEstimate.AvgCompressedSize * int64(options.HotBackups()))
+	combinedEstimate.PerSiteColdStorage = combinedEstimate.AvgCompressedSize * int64(options.ColdBackups())
 	combinedEstimate.PerSiteTotalStorage = combinedEstimate.PerSiteHotStorage + combinedEstimate.PerSiteColdStorage
 
 	// Calculate fleet-wide storage
@@ -356,6 +428,8 @@ func processCapacityEstimateForServerRange(cmd *cobra.Command, serverRange, esti
 		}
 	}
 
+	combinedEstimate.Scenarios = backup.CapacityScenarios(combinedEstimate.AvgCompressedSize, combinedEstimate.SitesScanned, options)
+
 	return combinedEstimate, nil
 }
 
@@ -394,14 +468,14 @@ func calculateGrowthProjections(hotStorage, coldStorage int64, growthRate float6
 
 // outputCapacityJSON outputs estimate as JSON
 func outputCapacityJSON(estimate *backup.CapacityEstimate) error {
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(output.Data())
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(estimate)
 }
 
 // outputCapacityCSV outputs estimate as CSV
 func outputCapacityCSV(estimate *backup.CapacityEstimate, focus, estimateType string) error {
-	writer := csv.NewWriter(os.Stdout)
+	writer := csv.NewWriter(output.Data())
 	defer writer.Flush()
 
 	// Write header
@@ -420,6 +494,8 @@ func outputCapacityCSV(estimate *backup.CapacityEstimate, focus, estimateType st
 	writeCSVRow("Weekly Retention", fmt.Sprintf("%d", estimate.WeeklyRetention), "weeks")
 	writeCSVRow("Monthly Retention", fmt.Sprintf("%d", estimate.MonthlyRetention), "months")
 	writeCSVRow("Total Backups Per Site", fmt.Sprintf("%d", estimate.TotalBackupsPerSite), "backups")
+	writeCSVRow("Migrate After", fmt.Sprintf("%d", estimate.MigrateAfterDays), "days")
+	writeCSVRow("Hot Backups Per Site", fmt.Sprintf("%d", estimate.HotBackupsPerSite), "backups")
 
 	if focus == "static-capacity" || focus == "all" {
 		if estimateType == "size" || estimateType == "all" {
@@ -429,6 +505,9 @@ func outputCapacityCSV(estimate *backup.CapacityEstimate, focus, estimateType st
 			writeCSVRow("Per-Site Cold Storage", fmt.Sprintf("%.2f", float64(estimate.PerSiteColdStorage)/(1024*1024)), "MB")
 			writeCSVRow("Per-Site Total", fmt.Sprintf("%.2f", float64(estimate.PerSiteTotalStorage)/(1024*1024)), "MB")
 			writeCSVRow("Fleet Hot Storage", fmt.Sprintf("%.2f", float64(estimate.FleetHotStorage)/(1024*1024*1024)), "GB")
+			if estimate.Erasure != nil {
+				writeCSVRow("Erasure Coding Overhead", fmt.Sprintf("%.2f", estimate.Erasure.Overhead()), "x")
+			}
 			writeCSVRow("Fleet Cold Storage", fmt.Sprintf("%.2f", float64(estimate.FleetColdStorage)/(1024*1024*1024)), "GB")
 			writeCSVRow("Fleet Total", fmt.Sprintf("%.2f", float64(estimate.FleetTotalStorage)/(1024*1024*1024)), "GB")
 			writeCSVRow("Fleet Total With Buffer", fmt.Sprintf("%.2f", float64(estimate.FleetTotalWithBuffer)/(1024*1024*1024)), "GB")
@@ -438,6 +517,26 @@ func outputCapacityCSV(estimate *backup.CapacityEstimate, focus, estimateType st
 			writeCSVRow("Monthly Storage Cost", fmt.Sprintf("%.2f", estimate.MonthlyCost), "USD")
 			writeCSVRow("Retrieval Cost (10%)", fmt.Sprintf("%.2f", estimate.RetrievalCost10Pct), "USD")
 		}
+
+		if len(estimate.Scenarios) > 1 {
+			writer.Write([]string{}) // Blank line
+			writer.Write([]string{"Migration Scenarios", "", "", "", "", ""})
+			writer.Write([]string{"Scenario", "Migrate After (days)", "Hot Backups", "Fleet Hot (GB)", "Fleet Cold (GB)", "Monthly Cost (USD)"})
+			for _, sc := range estimate.Scenarios {
+				costStr := ""
+				if sc.MonthlyCost > 0 {
+					costStr = fmt.Sprintf("%.2f", sc.MonthlyCost)
+				}
+				writer.Write([]string{
+					sc.Name,
+					fmt.Sprintf("%d", sc.MigrateAfterDays),
+					fmt.Sprintf("%d", sc.HotBackupsPerSite),
+					fmt.Sprintf("%.2f", float64(sc.FleetHotStorage)/(1024*1024*1024)),
+					fmt.Sprintf("%.2f", float64(sc.FleetColdStorage)/(1024*1024*1024)),
+					costStr,
+				})
+			}
+		}
 	}
 
 	// Growth projections
@@ -476,6 +575,9 @@ func outputCapacityStdout(estimate *backup.CapacityEstimate, focus, estimateType
 	fmt.Printf("  Weekly backups:   %d weeks\n", estimate.WeeklyRetention)
 	fmt.Printf("  Monthly backups:  %d months\n", estimate.MonthlyRetention)
 	fmt.Printf("  Total per site:   %d backups\n", estimate.TotalBackupsPerSite)
+	if estimate.MigrateAfterDays > 0 {
+		fmt.Printf("  Glacier after:    %d days (%d daily backups hot)\n", estimate.MigrateAfterDays, estimate.HotBackupsPerSite)
+	}
 	fmt.Println()
 
 	if focus == "static-capacity" || focus == "all" {
@@ -504,23 +606,59 @@ func outputCapacityStdout(estimate *backup.CapacityEstimate, focus, estimateType
 			fmt.Println("Per-Site Storage Requirements:")
 			fmt.Printf("  Hot storage (Minio):  %.2f GB (%d daily backups)\n",
 				float64(estimate.PerSiteHotStorage)/(1024*1024*1024),
-				estimate.DailyRetention)
-			fmt.Printf("  Cold storage (AWS):   %.2f GB (%d weekly + %d monthly)\n",
-				float64(estimate.PerSiteColdStorage)/(1024*1024*1024),
-				estimate.WeeklyRetention,
-				estimate.MonthlyRetention)
+				estimate.HotBackupsPerSite)
+			if migrated := estimate.DailyRetention - estimate.HotBackupsPerSite; migrated > 0 {
+				fmt.Printf("  Cold storage (AWS):   %.2f GB (%d daily + %d weekly + %d monthly)\n",
+					float64(estimate.PerSiteColdStorage)/(1024*1024*1024),
+					migrated,
+					estimate.WeeklyRetention,
+					estimate.MonthlyRetention)
+			} else {
+				fmt.Printf("  Cold storage (AWS):   %.2f GB (%d weekly + %d monthly)\n",
+					float64(estimate.PerSiteColdStorage)/(1024*1024*1024),
+					estimate.WeeklyRetention,
+					estimate.MonthlyRetention)
+			}
 			fmt.Printf("  Total per site:       %.2f GB\n",
 				float64(estimate.PerSiteTotalStorage)/(1024*1024*1024))
 			fmt.Println()
 
 			fmt.Printf("Fleet-Wide Storage (%d sites):\n", estimate.SitesScanned)
 			fmt.Printf("  Hot storage (Minio):  %.2f GB\n", float64(estimate.FleetHotStorage)/(1024*1024*1024))
+			if estimate.Erasure != nil {
+				fmt.Printf("    includes %s; %.2f GB of objects\n", estimate.Erasure,
+					float64(estimate.FleetHotStorage)/estimate.Erasure.Overhead()/(1024*1024*1024))
+			}
 			fmt.Printf("  Cold storage (AWS):   %.2f GB\n", float64(estimate.FleetColdStorage)/(1024*1024*1024))
 			fmt.Printf("  Total required:       %.2f GB\n", float64(estimate.FleetTotalStorage)/(1024*1024*1024))
 			fmt.Printf("  With %.0f%% buffer:      %.2f GB\n",
 				estimate.BufferPercent,
 				float64(estimate.FleetTotalWithBuffer)/(1024*1024*1024))
 			fmt.Println()
+
+			if len(estimate.Scenarios) > 1 {
+				fmt.Println("Migration Scenarios (fleet-wide):")
+				fmt.Println("  Scenario     | Glacier After | Hot Backups | Hot Storage | Cold Storage | Monthly Cost")
+				fmt.Println("  -------------|---------------|-------------|-------------|--------------|-------------")
+				for _, sc := range estimate.Scenarios {
+					after := "never"
+					if sc.MigrateAfterDays > 0 {
+						after = fmt.Sprintf("%d days", sc.MigrateAfterDays)
+					}
+					costStr := "N/A"
+					if sc.MonthlyCost > 0 {
+						costStr = fmt.Sprintf("$%.2f", sc.MonthlyCost)
+					}
+					fmt.Printf("  %-12s | %13s | %11d | %8.2f GB | %9.2f GB | %s\n",
+						sc.Name,
+						after,
+						sc.HotBackupsPerSite,
+						float64(sc.FleetHotStorage)/(1024*1024*1024),
+						float64(sc.FleetColdStorage)/(1024*1024*1024),
+						costStr)
+				}
+				fmt.Println()
+			}
 		}
 
 		if estimateType == "cost" || estimateType == "all" {
@@ -589,9 +727,29 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 	fmt.Println("===========================================")
 	fmt.Printf("Available Minio Storage: %.2f GB\n", availableStorageGB)
 	fmt.Printf("Required Hot Storage:    %.2f GB (%d daily backups)\n",
-		requiredHotStorageGB, estimate.DailyRetention)
+		requiredHotStorageGB, estimate.HotBackupsPerSite)
+	if estimate.MigrateAfterDays > 0 {
+		fmt.Printf("Glacier Migration:       after %d days\n", estimate.MigrateAfterDays)
+	}
+	if estimate.Erasure != nil {
+		fmt.Printf("Erasure Coding:          %s\n", estimate.Erasure)
+	}
 	fmt.Println()
 
+	if len(estimate.Scenarios) > 1 {
+		fmt.Println("Hot storage by migration cadence:")
+		for _, sc := range estimate.Scenarios {
+			hotGB := float64(sc.FleetHotStorage) / (1024 * 1024 * 1024)
+			fit := "✓ fits"
+			if hotGB > availableStorageGB {
+				fit = "✗ over capacity"
+			}
+			fmt.Printf("  %-12s %8.2f GB (%5.1f%% of available) %s\n",
+				sc.Name, hotGB, (hotGB/availableStorageGB)*100, fit)
+		}
+		fmt.Println()
+	}
+
 	// Calculate shortfall
 	shortfall := requiredHotStorageGB - availableStorageGB
 	utilizationPct := (requiredHotStorageGB / availableStorageGB) * 100
@@ -606,7 +764,7 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 
 		// Option 1: Reduce retention
 		for _, days := range []int{7, 5, 3} {
-			reducedHot := float64(estimate.AvgCompressedSize*int64(days)*int64(estimate.SitesScanned)) / (1024 * 1024 * 1024)
+			reducedHot := float64(estimate.Erasure.OnDisk(estimate.AvgCompressedSize*int64(days)*int64(estimate.SitesScanned))) / (1024 * 1024 * 1024)
 			if reducedHot <= availableStorageGB {
 				fmt.Printf("1️⃣  REDUCE RETENTION to %d daily backups\n", days)
 				fmt.Printf("   Required: %.2f GB (%.1f%% of available)\n", reducedHot, (reducedHot/availableStorageGB)*100)
@@ -619,13 +777,13 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 
 		// Option 2: Faster glacier migration
 		for _, days := range []int{7, 5, 3, 2, 1} {
-			if days < estimate.DailyRetention {
-				reducedHot := float64(estimate.AvgCompressedSize*int64(days)*int64(estimate.SitesScanned)) / (1024 * 1024 * 1024)
+			if days < estimate.HotBackupsPerSite {
+				reducedHot := float64(estimate.Erasure.OnDisk(estimate.AvgCompressedSize*int64(days)*int64(estimate.SitesScanned))) / (1024 * 1024 * 1024)
 				if reducedHot <= availableStorageGB {
 					fmt.Printf("2️⃣  MIGRATE FASTER to Glacier (keep %d days hot, rest in Glacier)\n", days)
 					fmt.Printf("   Required: %.2f GB (%.1f%% of available)\n", reducedHot, (reducedHot/availableStorageGB)*100)
 					fmt.Printf("   Trade-off: Higher retrieval costs if needed\n")
-					fmt.Printf("   Action: Run monitor more frequently, migrate after %d days\n", days)
+					fmt.Printf("   Action: Run monitor more frequently, migrate after %d days (model with --migrate-after-days %d)\n", days, days)
 					fmt.Println()
 					break
 				}
@@ -642,7 +800,7 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 
 		// Option 4: Reduce site count (less common)
 		if estimate.SitesScanned > 100 {
-			maxSites := int(availableStorageGB / (float64(estimate.AvgCompressedSize*int64(estimate.DailyRetention)) / (1024 * 1024 * 1024)))
+			maxSites := int(availableStorageGB / (float64(estimate.Erasure.OnDisk(estimate.AvgCompressedSize*int64(estimate.HotBackupsPerSite))) / (1024 * 1024 * 1024)))
 			fmt.Printf("4️⃣  REDUCE ACTIVE SITES to ~%d sites\n", maxSites)
 			fmt.Printf("   Archive/disable %d sites\n", estimate.SitesScanned-maxSites)
 			fmt.Printf("   Trade-off: Service fewer sites\n")
@@ -656,8 +814,10 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 		fmt.Println()
 		fmt.Printf("  • Monitor storage closely - only %.2f GB headroom\n", availableStorageGB-requiredHotStorageGB)
 		fmt.Printf("  • Plan for expansion if growth rate >%.1f%% monthly\n", (20.0/utilizationPct)*100)
-		fmt.Printf("  • Consider migrating to Glacier after %d days instead of %d\n",
-			estimate.DailyRetention-3, estimate.DailyRetention)
+		if estimate.HotBackupsPerSite > 3 {
+			fmt.Printf("  • Consider migrating to Glacier after %d days instead of %d\n",
+				estimate.HotBackupsPerSite-3, estimate.HotBackupsPerSite)
+		}
 		fmt.Println()
 
 	} else {
@@ -685,3 +845,38 @@ func outputCapacityRecommendations(estimate *backup.CapacityEstimate, availableS
 		fmt.Println()
 	}
 }
+
+// resolveErasureCoding returns the erasure-coding layout to size hot storage
+// with: from --ec-data/--ec-parity, from the Minio admin API with
+// --ec-detect, or nil for raw object bytes.
+func resolveErasureCoding(cmd *cobra.Command) (*backup.ErasureCoding, error) {
+	data := mustGetIntFlag(cmd, "ec-data")
+	parity := mustGetIntFlag(cmd, "ec-parity")
+	if mustGetBoolFlag(cmd, "ec-detect") {
+		if data != 0 || parity != 0 {
+			return nil, fmt.Errorf("--ec-detect cannot be combined with --ec-data/--ec-parity")
+		}
+		minioConfig, err := getMinioConfig(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("Minio configuration required for --ec-detect: %w", err)
+		}
+		ec, err := backup.NewBackupManager(nil, minioConfig).DetectErasureCoding()
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect erasure coding: %w", err)
+		}
+		if ec == nil {
+			fmt.Fprintln(os.Stderr, "ℹ️  Minio is not erasure-coded; sizing hot storage as raw object bytes")
+			return nil, nil
+		}
+		fmt.Fprintf(os.Stderr, "✓ Detected erasure coding %s\n", ec)
+		return ec, nil
+	}
+	if data == 0 && parity == 0 {
+		return nil, nil
+	}
+	ec := &backup.ErasureCoding{Data: data, Parity: parity}
+	if err := ec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid --ec-data/--ec-parity: %w", err)
+	}
+	return ec, nil
+}
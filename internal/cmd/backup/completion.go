@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// completeObjectKeys completes object keys and site directories from the
+// configured bucket, one directory level at a time. Errors (no endpoint
+// configured, Minio unreachable) yield no candidates rather than a message
+// in the middle of the user's command line.
+func completeObjectKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeKeys(cmd, toComplete)
+}
+
+// completePrefixFlag completes the value of a --prefix flag.
+func completePrefixFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeKeys(cmd, toComplete)
+}
+
+func completeKeys(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if envPath, _ := cmd.Flags().GetString("env"); envPath != "" {
+		_ = godotenv.Load(envPath)
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+	keys, err := bm.CompleteKeys(toComplete, backup.DefaultCompletionCacheDir())
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	directive := cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	for _, k := range keys {
+		if k[len(k)-1] == '/' {
+			// Let the user keep typing (or TAB again) into the directory.
+			directive |= cobra.ShellCompDirectiveNoSpace
+			break
+		}
+	}
+	return keys, directive
+}
+
+// registerKeyCompletion completes backup keys for the object argument of
+// objectCmds and the --prefix flag of prefixCmds.
+func registerKeyCompletion(objectCmds, prefixCmds []*cobra.Command) {
+	for _, c := range objectCmds {
+		c.ValidArgsFunction = completeObjectKeys
+	}
+	for _, c := range prefixCmds {
+		_ = c.RegisterFlagCompletionFunc("prefix", completePrefixFlag)
+	}
+}
@@ -0,0 +1,265 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
+)
+
+// commandConfigs caches the connection configuration of each command so a
+// server range run builds and validates it once rather than once per host.
+// It must first be read after --env has been loaded.
+var commandConfigs = struct {
+	sync.Mutex
+	m map[*cobra.Command]*commandConfigResult
+}{m: map[*cobra.Command]*commandConfigResult{}}
+
+type commandConfigResult struct {
+	cfg *backup.CommandConfig
+	err error
+}
+
+// commandConfig returns the connection configuration of cmd, building it
+// from the flags and environment on first use. Every flag error and
+// validation problem is reported in the one error.
+func commandConfig(cmd *cobra.Command) (*backup.CommandConfig, error) {
+	commandConfigs.Lock()
+	defer commandConfigs.Unlock()
+	if r, ok := commandConfigs.m[cmd]; ok {
+		return r.cfg, r.err
+	}
+	cfg, err := buildCommandConfig(cmd)
+	commandConfigs.m[cmd] = &commandConfigResult{cfg: cfg, err: err}
+	return cfg, err
+}
+
+func buildCommandConfig(cmd *cobra.Command) (*backup.CommandConfig, error) {
+	f := newFlagReader(cmd)
+	cfg := &backup.CommandConfig{
+		SSH: auth.SSHConfig{Username: getCurrentUser(), KeepAlive: 30 * time.Second},
+	}
+
+	// Each group of connection flags is read only from the commands that
+	// register it, so a command without Minio or AWS flags never picks up
+	// (and fails validation on) settings from the environment. Within a
+	// group every flag must be registered.
+	if f.has("user") {
+		if user := f.str("user"); user != "" {
+			cfg.SSH.Username = user
+		}
+		cfg.SSH.Port = f.str("port")
+		cfg.SSH.KeyPath = f.str("key")
+		cfg.SSH.UseAgent = f.boolean("agent")
+		cfg.SSH.Timeout = f.duration("timeout")
+	}
+
+	if f.has("minio-endpoint") {
+		readMinioConfig(f, cfg)
+	}
+	if f.has("aws-vault") {
+		readAWSConfig(f, cfg)
+	}
+
+	if err := errors.Join(f.err(), cfg.Validate()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func readMinioConfig(f *flagReader, cfg *backup.CommandConfig) {
+	endpoint := f.strOrEnv("minio-endpoint", "MINIO_ENDPOINT", "")
+	if endpoint == "" {
+		return
+	}
+	m := &backup.MinioConfig{Endpoint: endpoint}
+	cfg.Minio = m
+	// Only the commands that write objects under a path or to another
+	// region offer these.
+	if f.has("bucket-path") {
+		m.BucketPath = f.str("bucket-path")
+	}
+	if f.has("minio-region") {
+		m.Region = f.strOrEnv("minio-region", "MINIO_REGION", "")
+	}
+	if backup.IsFileEndpoint(endpoint) {
+		// Filesystem backend: credentials, bucket and TLS settings don't apply.
+		return
+	}
+
+	m.AccessKey = f.strOrEnv("minio-access-key", "MINIO_ACCESS_KEY", "")
+	m.SecretKey = f.strOrEnv("minio-secret-key", "MINIO_SECRET_KEY", "")
+	m.Bucket = f.str("minio-bucket")
+	m.UseSSL = f.boolean("minio-ssl")
+	m.HTTPTimeout = f.duration("minio-http-timeout")
+	// addMinioTLSFlags
+	m.CACertFile = f.str("minio-ca-bundle")
+	m.ClientCertFile = f.str("minio-client-cert")
+	m.ClientKeyFile = f.str("minio-client-key")
+	m.InsecureSkipVerify = f.boolean("minio-insecure-skip-verify")
+	if f.has("list-parallelism") { // addMinioListingFlags
+		m.Listing.Parallelism = f.integer("list-parallelism")
+		m.Listing.RequestsPerSecond = f.float("list-rate")
+		m.Listing.PageSize = f.integer("list-page-size")
+	}
+	if f.has("list-cache-ttl") { // addListingCacheFlag
+		m.Listing.CacheTTL = f.duration("list-cache-ttl")
+	}
+	if f.has("minio-part-size") { // addMinioUploadFlags
+		if v := f.str("minio-part-size"); v != "" {
+			size, err := parseSize(v)
+			f.check("minio-part-size", err)
+			m.PartSize = size
+		}
+		m.UploadConcurrency = f.integer("minio-upload-concurrency")
+		if v := f.str("storage-classes"); v != "" {
+			classes, err := backup.ParseStorageClassPolicy(v)
+			f.check("storage-classes", err)
+			m.StorageClasses = classes
+		}
+	}
+	if err := m.NormalizeEndpoint(); err != nil {
+		f.errs = append(f.errs, err)
+	}
+	if via := f.str("minio-via-ssh"); via != "" {
+		tunnel := cfg.SSHTarget(via)
+		m.SSHTunnel = &tunnel
+	}
+}
+
+func readAWSConfig(f *flagReader, cfg *backup.CommandConfig) {
+	vault := f.strOrEnv("aws-vault", "AWS_VAULT", "")
+	if vault == "" {
+		return
+	}
+	a := &backup.AWSConfig{
+		Vault:       vault,
+		AccountID:   f.strOrEnv("aws-account-id", "AWS_ACCOUNT_ID", "-"),
+		AccessKey:   f.strOrEnv("aws-access-key", "AWS_ACCESS_KEY", ""),
+		SecretKey:   f.strOrEnv("aws-secret-access-key", "AWS_SECRET_ACCESS_KEY", ""),
+		Region:      f.strOrEnv("aws-region", "AWS_REGION", "us-east-1"),
+		HTTPTimeout: f.duration("aws-http-timeout"),
+		// addAWSTLSFlags
+		CACertFile:         f.str("aws-ca-bundle"),
+		ClientCertFile:     f.str("aws-client-cert"),
+		ClientKeyFile:      f.str("aws-client-key"),
+		InsecureSkipVerify: f.boolean("aws-insecure-skip-verify"),
+	}
+	cfg.AWS = a
+	// An invalid value is kept as is and reported by Validate.
+	a.Auth = f.strOrEnv("aws-auth", "AWS_AUTH", "")
+	if auth, err := backup.ParseAWSAuth(a.Auth); err == nil {
+		a.Auth = auth
+	}
+	// Only the commands that upload to or download from the vault offer
+	// these; the others never transfer archives.
+	if f.has("aws-verify") {
+		a.VerifyChecksums = f.boolean("aws-verify")
+	}
+	if f.has("aws-part-size") {
+		if v := f.str("aws-part-size"); v != "" {
+			size, err := parseSize(v)
+			f.check("aws-part-size", err)
+			a.PartSize = size
+		}
+	}
+}
+
+// flagReader reads the flags of a command and collects every read error.
+// Reading a flag the command does not register is an error like any other,
+// so a command that reads a flag it never offers fails instead of silently
+// getting the zero value; flags a command may or may not offer are tested
+// with has first.
+type flagReader struct {
+	cmd  *cobra.Command
+	errs []error
+}
+
+func newFlagReader(cmd *cobra.Command) *flagReader {
+	return &flagReader{cmd: cmd}
+}
+
+// err returns every error collected so far, or nil.
+func (f *flagReader) err() error {
+	return errors.Join(f.errs...)
+}
+
+// must panics with the collected errors: for the mustGet helpers, where a
+// bad read is a programming error.
+func (f *flagReader) must() {
+	if err := f.err(); err != nil {
+		panic(fmt.Sprintf("%s: %v", f.cmd.CommandPath(), err))
+	}
+}
+
+func (f *flagReader) has(name string) bool {
+	return f.cmd.Flags().Lookup(name) != nil
+}
+
+func (f *flagReader) check(name string, err error) {
+	if err != nil {
+		f.errs = append(f.errs, fmt.Errorf("--%s: %w", name, err))
+	}
+}
+
+func (f *flagReader) str(name string) string {
+	v, err := f.cmd.Flags().GetString(name)
+	f.check(name, err)
+	return v
+}
+
+// strOrEnv reads a string flag, falling back to the environment variable
+// for values loaded from --env after the flag defaults were taken.
+func (f *flagReader) strOrEnv(name, env, def string) string {
+	if v := f.str(name); v != "" {
+		return v
+	}
+	return getEnvWithDefault(env, def)
+}
+
+func (f *flagReader) boolean(name string) bool {
+	v, err := f.cmd.Flags().GetBool(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) duration(name string) time.Duration {
+	v, err := f.cmd.Flags().GetDuration(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) integer(name string) int {
+	v, err := f.cmd.Flags().GetInt(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) int64(name string) int64 {
+	v, err := f.cmd.Flags().GetInt64(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) float(name string) float64 {
+	v, err := f.cmd.Flags().GetFloat64(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) count(name string) int {
+	v, err := f.cmd.Flags().GetCount(name)
+	f.check(name, err)
+	return v
+}
+
+func (f *flagReader) strArray(name string) []string {
+	v, err := f.cmd.Flags().GetStringArray(name)
+	f.check(name, err)
+	return v
+}
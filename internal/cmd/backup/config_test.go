@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// walkCommands calls fn for root and every command under it.
+func walkCommands(root *cobra.Command, fn func(*cobra.Command)) {
+	fn(root)
+	for _, c := range root.Commands() {
+		walkCommands(c, fn)
+	}
+}
+
+func TestCommandConfigReadsOnlyRegisteredFlags(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "localhost:9000")
+	t.Setenv("MINIO_ACCESS_KEY", "access")
+	t.Setenv("MINIO_SECRET_KEY", "secret")
+	t.Setenv("AWS_VAULT", "vault")
+	t.Setenv("AWS_ACCESS_KEY", "access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	walkCommands(BackupCmd, func(c *cobra.Command) {
+		if _, err := buildCommandConfig(c); err != nil {
+			t.Errorf("%s: %v", c.CommandPath(), err)
+		}
+	})
+}
+
+func TestCommandConfigIgnoresStorageEnvWithoutStorageFlags(t *testing.T) {
+	t.Setenv("MINIO_ENDPOINT", "localhost:9000")
+	t.Setenv("AWS_VAULT", "vault")
+
+	cmd := &cobra.Command{Use: "ssh-only"}
+	cmd.Flags().String("user", "deploy", "")
+	cmd.Flags().String("port", "2222", "")
+	cmd.Flags().String("key", "", "")
+	cmd.Flags().Bool("agent", true, "")
+	cmd.Flags().Duration("timeout", time.Minute, "")
+
+	// Without keys the Minio settings would fail validation.
+	cfg, err := buildCommandConfig(cmd)
+	if err != nil {
+		t.Fatalf("buildCommandConfig() error = %v", err)
+	}
+	if cfg.Minio != nil || cfg.AWS != nil {
+		t.Errorf("storage configured from the environment: minio %+v, aws %+v", cfg.Minio, cfg.AWS)
+	}
+	if cfg.SSH.Username != "deploy" || cfg.SSH.Port != "2222" || cfg.SSH.Timeout != time.Minute {
+		t.Errorf("SSH = %+v", cfg.SSH)
+	}
+}
+
+func TestFlagReaderCollectsErrors(t *testing.T) {
+	cmd := &cobra.Command{Use: "reader"}
+	cmd.Flags().Int("count", 3, "")
+
+	f := newFlagReader(cmd)
+	if got := f.integer("count"); got != 3 {
+		t.Errorf("integer(count) = %d, want 3", got)
+	}
+	if f.err() != nil {
+		t.Fatalf("err() = %v after a good read", f.err())
+	}
+	f.str("missing")
+	f.str("count")
+	if f.err() == nil || len(f.errs) != 2 {
+		t.Errorf("errs = %v, want an unregistered and a mistyped flag", f.errs)
+	}
+}
+
+func TestMustGetFlagPanicsOnUnregisteredFlag(t *testing.T) {
+	cmd := &cobra.Command{Use: "reader"}
+	cmd.Flags().Bool("dry-run", true, "")
+	if !mustGetBoolFlag(cmd, "dry-run") {
+		t.Error("mustGetBoolFlag(dry-run) = false")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("mustGetStringFlag did not panic on an unregistered flag")
+		}
+	}()
+	mustGetStringFlag(cmd, "missing")
+}
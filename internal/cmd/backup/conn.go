@@ -24,7 +24,9 @@ func runTestMinio(cmd *cobra.Command, args []string) error {
 	fmt.Println("Testing Minio connection...")
 	fmt.Printf("Endpoint: %s\n", minioConfig.Endpoint)
 	fmt.Printf("Bucket: %s\n", minioConfig.Bucket)
-	fmt.Printf("Use SSL: %v\n\n", minioConfig.UseSSL)
+	fmt.Printf("Use SSL: %v\n", minioConfig.UseSSL)
+	printMinioTLSSummary("", minioConfig)
+	fmt.Println()
 
 	// Create a temporary backup manager without SSH client for testing
 	backupManager := backup.NewBackupManager(nil, minioConfig)
@@ -57,7 +59,8 @@ func runTestAWS(cmd *cobra.Command, args []string) error {
 	fmt.Println("Testing AWS Glacier connection...")
 	fmt.Printf("Vault: %s\n", awsConfig.Vault)
 	fmt.Printf("Account ID: %s\n", awsConfig.AccountID)
-	fmt.Printf("Region: %s\n\n", awsConfig.Region)
+	fmt.Printf("Region: %s\n", awsConfig.Region)
+	fmt.Printf("Auth: %s\n\n", awsConfig.AuthMode())
 
 	// Create a temporary backup manager without SSH client for testing
 	backupManager := backup.NewBackupManagerWithAWS(nil, nil, awsConfig)
@@ -66,6 +69,7 @@ func runTestAWS(cmd *cobra.Command, args []string) error {
 	if err := backupManager.TestAWSConnection(); err != nil {
 		return fmt.Errorf("AWS Glacier connection test failed: %w", err)
 	}
+	fmt.Printf("Credentials: %s\n", backupManager.AWSCredentialSource())
 
 	fmt.Println("✓ AWS Glacier connection test successful!")
 	return nil
@@ -91,7 +95,9 @@ func runBackupConn(cmd *cobra.Command, args []string) error {
 		fmt.Println("📦 Testing Minio Connection...")
 		fmt.Printf("   Endpoint: %s\n", minioConfig.Endpoint)
 		fmt.Printf("   Bucket:   %s\n", minioConfig.Bucket)
-		fmt.Printf("   Use SSL:  %v\n\n", minioConfig.UseSSL)
+		fmt.Printf("   Use SSL:  %v\n", minioConfig.UseSSL)
+		printMinioTLSSummary("   ", minioConfig)
+		fmt.Println()
 
 		backupManager := backup.NewBackupManager(nil, minioConfig)
 		if err := backupManager.TestMinioConnection(); err != nil {
@@ -113,13 +119,15 @@ func runBackupConn(cmd *cobra.Command, args []string) error {
 		fmt.Println("☁️  Testing AWS Glacier Connection...")
 		fmt.Printf("   Vault:      %s\n", awsConfig.Vault)
 		fmt.Printf("   Account ID: %s\n", awsConfig.AccountID)
-		fmt.Printf("   Region:     %s\n\n", awsConfig.Region)
+		fmt.Printf("   Region:     %s\n", awsConfig.Region)
+		fmt.Printf("   Auth:       %s\n\n", awsConfig.AuthMode())
 
 		backupManager := backup.NewBackupManagerWithAWS(nil, nil, awsConfig)
 		if err := backupManager.TestAWSConnection(); err != nil {
 			fmt.Printf("   ❌ AWS Glacier test failed: %v\n\n", err)
 		} else {
 			fmt.Println("   ✓ AWS Glacier connection successful!")
+			fmt.Printf("   Credentials: %s\n", backupManager.AWSCredentialSource())
 		}
 	}
 
@@ -129,3 +137,20 @@ func runBackupConn(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// printMinioTLSSummary shows which TLS trust options are in effect so
+// connection tests make private-CA and mTLS setups easy to diagnose.
+func printMinioTLSSummary(indent string, cfg *backup.MinioConfig) {
+	if !cfg.UseSSL {
+		return
+	}
+	if cfg.CACertFile != "" {
+		fmt.Printf("%sCA bundle: %s\n", indent, cfg.CACertFile)
+	}
+	if cfg.ClientCertFile != "" {
+		fmt.Printf("%sClient cert (mTLS): %s\n", indent, cfg.ClientCertFile)
+	}
+	if cfg.InsecureSkipVerify {
+		fmt.Printf("%sTLS verify: DISABLED (insecure)\n", indent)
+	}
+}
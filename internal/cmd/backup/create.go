@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,36 +24,87 @@ func runBackupCreate(cmd *cobra.Command, args []string) error {
 	}
 	serverRange := mustGetStringFlag(cmd, "server-range")
 
-	// Validate Minio configuration
-	minioConfig, err := getMinioConfig(cmd)
+	// Build and validate the connection configuration once for all hosts
+	if _, err := getMinioConfig(cmd); err != nil {
+		return err
+	}
+	cfg, err := commandConfig(cmd)
 	if err != nil {
 		return err
 	}
 
-	// Get AWS configuration (optional)
-	awsConfig, err := getAWSConfig(cmd)
+	group, err := loadGroup(cmd)
 	if err != nil {
 		return err
 	}
 
 	if serverRange != "" {
-		return processBackupCreateForServerRange(cmd, serverRange, minioConfig, awsConfig)
+		return processBackupCreateForServerRange(cmd, serverRange, cfg, group)
 	}
 
 	if len(args) < 1 {
-		return fmt.Errorf("hostname argument is required when --server-range is not used")
+		if group == nil && mustGetStringFlag(cmd, "inventory") != "" {
+			return processBackupCreateForInventory(cmd, cfg)
+		}
+		if group == nil {
+			return fmt.Errorf("hostname argument is required when --server-range, --group or --inventory is not used")
+		}
+		return processBackupCreateForGroup(cmd, cfg, group)
 	}
 
 	hostname := args[0]
-	return createBackupForHost(cmd, hostname, minioConfig, awsConfig)
+	return createBackupForHost(cmd, hostname, cfg, group)
+}
+
+// processBackupCreateForGroup backs up the hosts of the fleet file that have
+// sites in group.
+func processBackupCreateForGroup(cmd *cobra.Command, cfg *backup.CommandConfig, group *backup.FleetGroup) error {
+	hosts := group.Hosts()
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts in the fleet file match group %s (label the hosts, set the host of the sites, or pass a hostname or --server-range)", group)
+	}
+	var failed []error
+	for _, hostname := range hosts {
+		fmt.Printf("--- Processing server: %s (group %s) ---\n", hostname, group)
+		if err := createBackupForHost(cmd, hostname, cfg, group); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", hostname, err)
+			failed = append(failed, fmt.Errorf("%s: %w", hostname, err))
+		}
+		fmt.Println()
+	}
+	return hostFailures(failed, len(hosts))
+}
+
+// processBackupCreateForInventory backs up every host of --inventory.
+func processBackupCreateForInventory(cmd *cobra.Command, cfg *backup.CommandConfig) error {
+	inv, err := backup.LoadHostInventory(mustGetStringFlag(cmd, "inventory"))
+	if err != nil {
+		return err
+	}
+	hosts := inv.HostNames()
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts in inventory %s", mustGetStringFlag(cmd, "inventory"))
+	}
+	var failed []error
+	for _, hostname := range hosts {
+		fmt.Printf("--- Processing server: %s (inventory) ---\n", hostname)
+		if err := createBackupForHost(cmd, hostname, cfg, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", hostname, err)
+			failed = append(failed, fmt.Errorf("%s: %w", hostname, err))
+		}
+		fmt.Println()
+	}
+	return hostFailures(failed, len(hosts))
 }
 
-func processBackupCreateForServerRange(cmd *cobra.Command, serverRange string, minioConfig *backup.MinioConfig, awsConfig *backup.AWSConfig) error {
+func processBackupCreateForServerRange(cmd *cobra.Command, serverRange string, cfg *backup.CommandConfig, group *backup.FleetGroup) error {
 	pattern, start, end, exclusions, err := parseServerRange(serverRange)
 	if err != nil {
 		return fmt.Errorf("error parsing server range: %w", err)
 	}
 
+	var failed []error
+	total := 0
 	for i := start; i <= end; i++ {
 		if exclusions[i] {
 			fmt.Printf("Skipping excluded server: %s\n", fmt.Sprintf(pattern, i))
@@ -60,37 +112,52 @@ func processBackupCreateForServerRange(cmd *cobra.Command, serverRange string, m
 		}
 		hostname := fmt.Sprintf(pattern, i)
 		fmt.Printf("--- Processing server: %s ---\n", hostname)
-		err := createBackupForHost(cmd, hostname, minioConfig, awsConfig)
+		total++
+		err := createBackupForHost(cmd, hostname, cfg, group)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", hostname, err)
+			failed = append(failed, fmt.Errorf("%s: %w", hostname, err))
 		}
 		fmt.Println()
 	}
 
-	return nil
+	return hostFailures(failed, total)
 }
 
-func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backup.MinioConfig, awsConfig *backup.AWSConfig) error {
+func createBackupForHost(cmd *cobra.Command, hostname string, cfg *backup.CommandConfig, group *backup.FleetGroup) error {
 
 	// Determine if running locally
 	localMode := mustGetBoolFlag(cmd, "local")
+	docker, err := dockerEndpoint(cmd, hostname)
+	if err != nil {
+		return err
+	}
+
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	// Inventory hosts carry their own address, SSH user and port and parent
+	// directory.
+	fleetName := hostname
+	if _, h, ok := strings.Cut(hostname, "@"); ok {
+		fleetName = h
+	}
+	fleetHost := fleet.Hosts[fleetName]
 
 	var sshClient *auth.SSHClient
-	if !localMode {
-		var err error
-		sshClient, err = createSSHClient(cmd, hostname)
+	if !localMode && docker == nil {
+		sshClient, err = auth.NewSSHClient(fleetHost.SSHTarget(cfg.SSHTarget(hostname), hostname))
 		if err != nil {
 			return err
 		}
 		defer sshClient.Close()
 	}
 
-	// Create backup manager with AWS config if available
-	var backupManager *backup.BackupManager
-	if awsConfig != nil {
-		backupManager = backup.NewBackupManagerWithAWS(sshClient, minioConfig, awsConfig)
-	} else {
-		backupManager = backup.NewBackupManager(sshClient, minioConfig)
+	backupManager := backup.NewBackupManagerFromConfig(sshClient, cfg)
+	backupManager.SetDryRun(mustGetBoolFlag(cmd, "dry-run"))
+	if docker != nil {
+		backupManager.SetDockerEndpoint(docker)
 	}
 
 	// Set verbosity level
@@ -101,6 +168,52 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 		verbosity = 1 + vflag // -v=2, -vv=3, -vvv=4, -vvvv=5
 	}
 	backupManager.SetVerbosity(verbosity)
+	applyCommandAudit(cmd, backupManager)
+	backupManager.SetAdaptiveSampling(mustGetFloat64Flag(cmd, "sample-target"), mustGetInt64Flag(cmd, "max-sample-size"))
+
+	model, err := loadCompressionModel(cmd)
+	if err != nil {
+		return err
+	}
+	backupManager.SetCompressionModel(model)
+
+	overrides, err := loadContainerOverrides(cmd)
+	if err != nil {
+		return err
+	}
+	backupManager.SetContainerOverrides(overrides)
+	backupManager.SetGroup(group, hostname)
+	applyResidency(cmd, fleet, hostname, backupManager)
+
+	if features := fleet.HostFeatures(hostname); len(features.Names()) > 0 {
+		fmt.Printf("🚩 Rollout features on %s: %s\n", hostname, features)
+		backupManager.SetFeatures(features)
+	}
+
+	minioCompression, err := backup.ParseCompressionSpec(mustGetStringFlag(cmd, "minio-compression"))
+	if err != nil {
+		return fmt.Errorf("invalid --minio-compression: %w", err)
+	}
+	glacierCompression, err := backup.ParseCompressionSpec(mustGetStringFlag(cmd, "glacier-compression"))
+	if err != nil {
+		return fmt.Errorf("invalid --glacier-compression: %w", err)
+	}
+	if err := backupManager.SetCompression(minioCompression, glacierCompression); err != nil {
+		return fmt.Errorf("invalid --minio-compression: %w", err)
+	}
+	if err := backupManager.SetArchiveOrder(mustGetStringFlag(cmd, "archive-order")); err != nil {
+		return fmt.Errorf("invalid --archive-order: %w", err)
+	}
+	backupManager.SetScanCacheTTL(mustGetDurationFlag(cmd, "scan-cache-ttl"))
+	if err := backupManager.SetRequiredDestinations(mustGetStringFlag(cmd, "require")); err != nil {
+		return fmt.Errorf("invalid --require: %w", err)
+	}
+	if err := applyTempBudget(cmd, backupManager); err != nil {
+		return err
+	}
+	if err := applySpool(cmd, backupManager); err != nil {
+		return err
+	}
 
 	// Parse container-names (comma-delimited)
 	var containerNames []string
@@ -116,57 +229,152 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 	estimateMethod := mustGetStringFlag(cmd, "estimate-method")
 	sampleSize := mustGetInt64Flag(cmd, "sample-size")
 
-	// Parse smart retention options
-	var smartRetention *backup.SmartRetentionPolicy
-	if mustGetBoolFlag(cmd, "smart-retention") {
-		keepDaily := mustGetIntFlag(cmd, "keep-daily")
-		keepWeekly := mustGetIntFlag(cmd, "keep-weekly")
-		keepMonthly := mustGetIntFlag(cmd, "keep-monthly")
-		weeklyDay := mustGetIntFlag(cmd, "weekly-day")
-		monthlyDay := mustGetIntFlag(cmd, "monthly-day")
-
-		smartRetention = &backup.SmartRetentionPolicy{
-			Enabled:     true,
-			KeepDaily:   keepDaily,
-			KeepWeekly:  keepWeekly,
-			KeepMonthly: keepMonthly,
-			WeeklyDay:   weeklyDay,
-			MonthlyDay:  monthlyDay,
-		}
+	smartRetention, err := smartRetentionFromFlags(cmd)
+	if err != nil {
+		return err
 	}
 
+	// Storage classes follow the retention calendar even when retention
+	// itself keeps the N most recent backups.
+	calendar := smartRetention
+	if calendar == nil {
+		calendar = &backup.SmartRetentionPolicy{WeeklyDay: mustGetIntFlag(cmd, "weekly-day"), MonthlyDay: mustGetIntFlag(cmd, "monthly-day")}
+	}
+	backupManager.SetRetentionCalendar(calendar)
+	if cfg.Minio != nil && cfg.Minio.StorageClasses != nil {
+		fmt.Printf("🗄️  Storage classes by retention tier: %s\n", cfg.Minio.StorageClasses)
+	}
+
+	parentDir := mustGetStringFlag(cmd, "container-parent-dir")
+	if fleetHost.ParentDir != "" && !cmd.Flags().Changed("container-parent-dir") {
+		parentDir = fleetHost.ParentDir
+	}
+	f := newFlagReader(cmd)
 	options := &backup.BackupOptions{
-		DryRun:               mustGetBoolFlag(cmd, "dry-run"),
-		Delete:               mustGetBoolFlag(cmd, "delete"),
-		ContainerName:        mustGetStringFlag(cmd, "container-name"),
-		ContainerFile:        mustGetStringFlag(cmd, "container-file"),
-		ContainerNames:       containerNames,
-		Local:                localMode,
-		ParentDir:            mustGetStringFlag(cmd, "container-parent-dir"),
-		ConfigFile:           mustGetStringFlag(cmd, "config-file"),
-		DatabaseType:         mustGetStringFlag(cmd, "database-type"),
-		DatabaseExportDir:    mustGetStringFlag(cmd, "database-export-dir"),
-		CustomAppDir:         mustGetStringFlag(cmd, "custom-app-dir"),
-		DatabaseContainer:    mustGetStringFlag(cmd, "database-container"),
-		DatabaseName:         mustGetStringFlag(cmd, "database-name"),
-		DatabaseUser:         mustGetStringFlag(cmd, "database-user"),
-		RespectCapacityLimit: mustGetBoolFlag(cmd, "respect-capacity-limit"),
-		CapacityThreshold:    mustGetFloat64Flag(cmd, "capacity-threshold"),
-		IncludeAWSGlacier:    mustGetBoolFlag(cmd, "include-aws-glacier"),
-		EstimateMethod:       estimateMethod,
-		SampleSize:           sampleSize,
-		SmartRetention:       smartRetention,
+		DryRun:                f.boolean("dry-run"),
+		Delete:                f.boolean("delete"),
+		ContainerName:         f.str("container-name"),
+		ContainerFile:         f.str("container-file"),
+		ContainerNames:        containerNames,
+		Local:                 localMode,
+		ParentDir:             parentDir,
+		ConfigFile:            f.str("config-file"),
+		DatabaseType:          f.str("database-type"),
+		DatabaseExportDir:     f.str("database-export-dir"),
+		CustomAppDir:          f.str("custom-app-dir"),
+		DatabaseContainer:     f.str("database-container"),
+		DatabaseName:          f.str("database-name"),
+		DatabaseUser:          f.str("database-user"),
+		RespectCapacityLimit:  f.boolean("respect-capacity-limit"),
+		CapacityThreshold:     f.float("capacity-threshold"),
+		IncludeAWSGlacier:     f.boolean("include-aws-glacier"),
+		EstimateMethod:        estimateMethod,
+		SampleSize:            sampleSize,
+		SmartRetention:        smartRetention,
+		SkipFacts:             f.boolean("no-facts"),
+		SkipOffloadedUploads:  f.boolean("skip-offloaded-uploads"),
+		ExcludeGit:            f.boolean("exclude-git"),
+		WindowAction:          f.str("window-action"),
+		Discovery:             f.str("discovery"),
+		PostUploadCheck:       f.str("post-upload-check"),
+		TarWarningThreshold:   f.integer("warning-threshold"),
+		ExportFreeze:          f.str("export-freeze"),
+		IncludeNestedArchives: f.boolean("include-nested-archives"),
+		Timeouts: backup.StageTimeouts{
+			Export:    f.duration("export-timeout"),
+			Tar:       f.duration("tar-timeout"),
+			Upload:    f.duration("upload-timeout"),
+			Container: f.duration("container-timeout"),
+		},
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+	nestedThreshold, err := parseSize(mustGetStringFlag(cmd, "nested-archive-threshold"))
+	if err != nil || nestedThreshold < 0 {
+		return fmt.Errorf("invalid --nested-archive-threshold: %s (use a size like 100MB, or 0 to disable)", mustGetStringFlag(cmd, "nested-archive-threshold"))
+	}
+	options.NestedArchiveThreshold = nestedThreshold
+	if _, err := backup.ParseExportFreeze(options.ExportFreeze); err != nil {
+		return fmt.Errorf("invalid --export-freeze: %w", err)
+	}
+	if options.Discovery != backup.DiscoveryCompose && options.Discovery != backup.DiscoveryPrefix {
+		return fmt.Errorf("invalid --discovery: %s (use 'compose' or 'prefix')", options.Discovery)
+	}
+	if options.WindowAction != backup.WindowActionAbort && options.WindowAction != backup.WindowActionWait {
+		return fmt.Errorf("invalid --window-action: %s (use 'abort' or 'wait')", options.WindowAction)
+	}
+	switch options.PostUploadCheck {
+	case backup.PostUploadCheckNone, backup.PostUploadCheckQuick, backup.PostUploadCheckFull:
+	default:
+		return fmt.Errorf("invalid --post-upload-check: %s (use 'none', 'quick' or 'full')", options.PostUploadCheck)
+	}
+	if !mustGetBoolFlag(cmd, "no-host-config") {
+		path := mustGetStringFlag(cmd, "host-config-file")
+		if path == "" {
+			path = backup.DefaultHostConfigPath()
+		}
+		if options.HostConfig, err = backup.LoadHostConfig(path); err != nil {
+			return err
+		}
+	}
+	options.Window, err = resolveBackupWindow(cmd, hostname)
+	if err != nil {
+		return err
+	}
+	if options.Window != nil && !mustGetBoolFlag(cmd, "no-resume") {
+		options.ResumeFile = backup.DefaultResumeTokenPath(hostname)
+	}
+	if options.IncludeAWSGlacier && !options.DryRun {
+		options.PendingUploadsFile = mustGetStringFlag(cmd, "pending-file")
+		if options.PendingUploadsFile == "" {
+			options.PendingUploadsFile = backup.DefaultPendingUploadsPath()
+		}
+	}
+	if maxUploads := mustGetIntFlag(cmd, "global-max-uploads"); maxUploads > 0 {
+		options.UploadSemaphore = &backup.UploadSemaphoreConfig{
+			MaxConcurrent: maxUploads,
+			Prefix:        mustGetStringFlag(cmd, "global-lock-prefix"),
+			TTL:           mustGetDurationFlag(cmd, "global-lock-ttl"),
+			MaxWait:       mustGetDurationFlag(cmd, "global-lock-max-wait"),
+			Holder:        hostname,
+		}
+		if err := options.UploadSemaphore.Validate(); err != nil {
+			return fmt.Errorf("invalid --global-lock-prefix: %w", err)
+		}
 	}
 
+	stopStatus, err := backupManager.ServeRunStatus(mustGetStringFlag(cmd, "status-socket"))
+	if err != nil {
+		return err
+	}
+	defer stopStatus()
+
 	fmt.Printf("Creating backups on %s...\n\n", hostname)
-	err := backupManager.CreateBackups(options)
+	err = backupManager.CreateBackups(options)
+	recordBackupRun(cmd, hostname, backupManager)
+	notifyBackupFailures(cmd, hostname, backupManager)
+	notifyIncidents(cmd, hostname, backupManager)
+	if !options.DryRun {
+		storeRunArtifacts(cmd, hostname, backupManager)
+		exportSiteFreshness(cmd, backupManager)
+	}
+	if errors.Is(err, backup.ErrBackupWindowClosed) {
+		// Expected outside the backup window; not a failure for cron.
+		fmt.Printf("⏸  %v\n", err)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	runErr := containerFailures(backupManager.LastRunRecord())
 
 	// Handle prune mode: clean up old backups
 	prune := mustGetBoolFlag(cmd, "prune")
 	if prune {
+		if err := applyReplica(cmd, backupManager); err != nil {
+			return err
+		}
 		remainder := mustGetIntFlag(cmd, "remainder")
 		if remainder == 0 {
 			remainder = 5 // default value
@@ -184,7 +392,7 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 			fmt.Printf("Weekly backups: every %s | Monthly backups: day %d of month\n",
 				time.Weekday(smartRetention.WeeklyDay), smartRetention.MonthlyDay)
 		} else {
-			if cleanAWS && awsConfig != nil && awsConfig.Vault != "" {
+			if cleanAWS && cfg.AWS != nil {
 				fmt.Printf("\n--- Pruning old backups from Minio and AWS Glacier (keeping %d most recent) ---\n", remainder)
 			} else {
 				fmt.Printf("\n--- Pruning old backups from Minio (keeping %d most recent) ---\n", remainder)
@@ -196,9 +404,28 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 		if err != nil {
 			return fmt.Errorf("failed to get containers for cleanup: %w", err)
 		}
+		maintenance, err := backupManager.MaintenanceSites(time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to read maintenance flags: %w", err)
+		}
+
+		// In two-phase mode the deletions are collected into a plan that is
+		// stored before anything is deleted, then executed from the plan.
+		planOnly := mustGetBoolFlag(cmd, "prune-plan-only")
+		var plan *backup.PrunePlan
+		if planOnly || mustGetBoolFlag(cmd, "prune-plan") {
+			plan = backup.NewPrunePlan(hostname, time.Now().UTC())
+		}
 
 		for _, container := range containers {
 			siteName := filepath.Base(container.WorkingDir)
+			if entry, ok := maintenance[siteName]; ok {
+				fmt.Printf("Site %s: skipped (maintenance %s)\n", siteName, entry)
+				continue
+			}
+			if !group.HasSite(siteName, hostname) {
+				continue
+			}
 			// If the container has a configured bucket_path, it supersedes the
 			// default backups/<siteName>/ prefix. Otherwise prefer global
 			// MinioConfig.BucketPath. If neither is set, use the default.
@@ -217,21 +444,25 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 				continue
 			}
 
+			// Count backups rather than objects: a split or sharded backup is
+			// several objects that are kept or deleted together.
+			backupCount := len(backup.GroupBackupSets(objs))
+
 			// Use smart retention or simple retention based on configuration
 			var toDelete []backup.ObjectInfo
 			if smartRetention != nil && smartRetention.Enabled {
 				toDelete = backupManager.SelectObjectsWithSmartRetention(objs, smartRetention)
 
 				if len(toDelete) == 0 {
-					fmt.Printf("Site %s: Found %d backup(s), all preserved by retention policy\n", siteName, len(objs))
+					fmt.Printf("Site %s: Found %d backup(s), all preserved by retention policy\n", siteName, backupCount)
 					continue
 				}
 
 				fmt.Printf("Site %s: Found %d backup(s), preserving backups per policy, deleting %d older backup(s)\n",
-					siteName, len(objs), len(toDelete))
+					siteName, backupCount, len(backup.GroupBackupSets(toDelete)))
 			} else {
-				if len(objs) <= remainder {
-					fmt.Printf("Site %s: Found %d backup(s), keeping all\n", siteName, len(objs))
+				if backupCount <= remainder {
+					fmt.Printf("Site %s: Found %d backup(s), keeping all\n", siteName, backupCount)
 					continue
 				}
 
@@ -241,22 +472,26 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 				}
 
 				fmt.Printf("Site %s: Found %d backup(s), keeping %d most recent, deleting %d older backup(s)\n",
-					siteName, len(objs), remainder, len(toDelete))
-			}
-			var deleteKeys []string
-			for _, o := range toDelete {
-				deleteKeys = append(deleteKeys, o.Key)
+					siteName, backupCount, remainder, len(backup.GroupBackupSets(toDelete)))
 			}
-
-			// Delete from Minio
-			if err := backupManager.DeleteObjects(deleteKeys); err != nil {
-				fmt.Printf("Warning: failed to delete old Minio backups for %s: %v\n", siteName, err)
+			if plan != nil {
+				plan.Add(siteName, toDelete)
 			} else {
-				fmt.Printf("Successfully cleaned up old Minio backups for %s\n", siteName)
+				var deleteKeys []string
+				for _, o := range toDelete {
+					deleteKeys = append(deleteKeys, o.Key)
+				}
+
+				// Delete from Minio
+				if err := backupManager.DeleteObjects(deleteKeys); err != nil {
+					fmt.Printf("Warning: failed to delete old Minio backups for %s: %v\n", siteName, err)
+				} else {
+					fmt.Printf("Successfully cleaned up old Minio backups for %s\n", siteName)
+				}
 			}
 
 			// If AWS cleanup is enabled and AWS is configured, also clean up AWS backups
-			if cleanAWS && awsConfig != nil && awsConfig.Vault != "" {
+			if cleanAWS && cfg.AWS != nil && !planOnly {
 				awsObjs, err := backupManager.ListAWSBackups(prefix, 0)
 				if err != nil {
 					fmt.Printf("Warning: failed to list AWS backups for %s: %v\n", siteName, err)
@@ -276,7 +511,187 @@ func createBackupForHost(cmd *cobra.Command, hostname string, minioConfig *backu
 				}
 			}
 		}
+		if plan != nil {
+			if err := runPrunePlan(backupManager, plan, planOnly); err != nil {
+				return err
+			}
+		}
+	}
+
+	return runErr
+}
+
+// containerFailures reports the failed containers of rec as an error: a
+// partial failure when others were backed up, a failure when none were.
+func containerFailures(rec *backup.RunRecord) error {
+	if rec == nil || rec.Failed == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d of %d container(s) failed to back up", rec.Failed, rec.Failed+rec.Succeeded)
+	return backup.PartialFailure(err, rec.Succeeded)
+}
+
+// hostFailures reports the hosts of a multi-host run that failed, keeping the
+// exit code of the first failure when every host failed.
+func hostFailures(failed []error, total int) error {
+	if len(failed) == 0 {
+		return nil
 	}
+	err := fmt.Errorf("%d of %d host(s) failed: %w", len(failed), total, errors.Join(failed...))
+	return backup.PartialFailure(err, total-len(failed))
+}
 
+// runPrunePlan stores plan and, unless planOnly, executes it.
+func runPrunePlan(bm *backup.BackupManager, plan *backup.PrunePlan, planOnly bool) error {
+	if len(plan.Entries) == 0 {
+		fmt.Println("Nothing to prune; no plan written.")
+		return nil
+	}
+	if err := bm.SavePrunePlan(plan); err != nil {
+		return err
+	}
+	objects, bytes := plan.Pending()
+	fmt.Printf("📝 Prune plan %s: %d object(s), %.2f MB\n", plan.ID, objects, float64(bytes)/(1024*1024))
+	if planOnly {
+		fmt.Printf("Review it with 'ciwg-cli backup prune-plan show %s' and run it with 'ciwg-cli backup prune-plan execute %s'\n", plan.ID, plan.ID)
+		return nil
+	}
+	if err := bm.ExecutePrunePlan(plan); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Prune plan %s completed\n", plan.ID)
 	return nil
 }
+
+// recordBackupRun appends the manager's last run (with its upload throughput
+// stats) to the history file so `backup report performance` can aggregate it.
+// Failures are reported as warnings; they never fail the backup itself.
+func recordBackupRun(cmd *cobra.Command, hostname string, backupManager *backup.BackupManager) {
+	if mustGetBoolFlag(cmd, "no-history") {
+		return
+	}
+	rec := backupManager.LastRunRecord()
+	if rec == nil {
+		return
+	}
+	rec.Host = hostname
+	rec.AuditedCommands = backupManager.AuditedCommands()
+	if err := backupManager.CommandAuditError(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: command audit log is incomplete: %v\n", err)
+	}
+
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	if err := backup.AppendRunRecord(historyPath, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record backup run history: %v\n", err)
+	}
+}
+
+// storeRunArtifacts keeps the summary, validation report and audited commands
+// of the last run under _meta/<site>/<run-id>/ in the bucket, so 'backup runs
+// show' works from any machine, and drops artifacts older than
+// --run-artifacts-retention-days. Failures are only warnings.
+func storeRunArtifacts(cmd *cobra.Command, hostname string, backupManager *backup.BackupManager) {
+	if mustGetBoolFlag(cmd, "no-run-artifacts") {
+		return
+	}
+	rec := backupManager.LastRunRecord()
+	if rec == nil {
+		return
+	}
+	rec.Host = hostname
+	rec.AuditedCommands = backupManager.AuditedCommands()
+
+	var traces []backup.CommandTrace
+	if mustGetBoolFlag(cmd, "audit-commands") {
+		auditPath := mustGetStringFlag(cmd, "command-audit-file")
+		if auditPath == "" {
+			auditPath = backup.DefaultCommandAuditPath()
+		}
+		var err error
+		if traces, err = backup.LoadCommandTraces(auditPath, rec.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read the command audit log: %v\n", err)
+		}
+	}
+	stored, err := backupManager.StoreRunArtifacts(rec, traces)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to store run artifacts: %v\n", err)
+	}
+	if stored > 0 {
+		fmt.Printf("🗂  Stored run artifacts of %d site(s) under _meta/\n", stored)
+	}
+
+	days := mustGetIntFlag(cmd, "run-artifacts-retention-days")
+	if days <= 0 {
+		return
+	}
+	for _, site := range rec.Sites() {
+		removed, err := backupManager.PruneRunArtifacts(site, time.Duration(days)*24*time.Hour, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to prune run artifacts of %s: %v\n", site, err)
+			continue
+		}
+		if removed > 0 {
+			fmt.Printf("🗑  Removed the artifacts of %d old run(s) of %s\n", removed, site)
+		}
+	}
+}
+
+// notifyBackupFailures posts the failed containers of the last run, with their
+// diagnosis, to --failure-webhook. Delivery problems are only warnings.
+func notifyBackupFailures(cmd *cobra.Command, hostname string, backupManager *backup.BackupManager) {
+	url := mustGetStringFlag(cmd, "failure-webhook")
+	rec := backupManager.LastRunRecord()
+	if url == "" || rec == nil || len(rec.Failures) == 0 {
+		return
+	}
+	rec.Host = hostname
+	if rec.DryRun {
+		fmt.Printf("[DRY RUN] Would send %d failure(s) to webhook\n", len(rec.Failures))
+		return
+	}
+	if err := backup.SendFailureNotice(url, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to send failure notification: %v\n", err)
+		return
+	}
+	fmt.Printf("📣 Sent %d failure(s) to webhook\n", len(rec.Failures))
+}
+
+// notifyIncidents opens and resolves the PagerDuty and Opsgenie incidents of
+// the sites of the run. Delivery problems are only warnings.
+func notifyIncidents(cmd *cobra.Command, hostname string, backupManager *backup.BackupManager) {
+	cfg := &backup.IncidentConfig{
+		PagerDutyRoutingKey: mustGetStringFlag(cmd, "pagerduty-routing-key"),
+		OpsgenieAPIKey:      mustGetStringFlag(cmd, "opsgenie-api-key"),
+		OpsgenieURL:         mustGetStringFlag(cmd, "opsgenie-api-url"),
+		Threshold:           mustGetIntFlag(cmd, "incident-threshold"),
+		StateFile:           mustGetStringFlag(cmd, "incident-state-file"),
+		Source:              hostname,
+	}
+	rec := backupManager.LastRunRecord()
+	if !cfg.Enabled() || rec == nil {
+		return
+	}
+	if rec.DryRun {
+		fmt.Println("[DRY RUN] Would update PagerDuty/Opsgenie incidents")
+		return
+	}
+	if err := backup.NotifyIncidents(cfg, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update incidents: %v\n", err)
+	}
+}
+
+// resolveBackupWindow returns the backup window for hostname: --blackout wins,
+// otherwise the first matching entry in the windows file. Nil means no window.
+func resolveBackupWindow(cmd *cobra.Command, hostname string) (*backup.BackupWindow, error) {
+	if blackout := mustGetStringFlag(cmd, "blackout"); blackout != "" {
+		return backup.NewBackupWindow(strings.Split(blackout, ","), mustGetStringFlag(cmd, "window-timezone"))
+	}
+	path := mustGetStringFlag(cmd, "window-file")
+	if path == "" {
+		path = backup.DefaultBackupWindowsPath()
+	}
+	return backup.LoadBackupWindow(path, hostname)
+}
@@ -2,7 +2,9 @@ package backup
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
@@ -24,6 +26,10 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 	}
 
 	bm := backup.NewBackupManager(nil, minioConfig)
+	bm.SetDryRun(mustGetBoolFlag(cmd, "dry-run"))
+	if err := applyReplica(cmd, bm); err != nil {
+		return err
+	}
 
 	var objectName string
 	if len(args) > 0 {
@@ -37,6 +43,7 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 	deleteRangeByDate := mustGetStringFlag(cmd, "delete-range-by-date")
 	skipConfirm := mustGetBoolFlag(cmd, "skip-confirmation")
 	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	runID := mustGetStringFlag(cmd, "run")
 
 	// Validate mutually exclusive flags
 	flagCount := 0
@@ -55,13 +62,45 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 	if deleteRangeByDate != "" {
 		flagCount++
 	}
+	if runID != "" {
+		flagCount++
+	}
 	if flagCount > 1 {
-		return fmt.Errorf("only one of: object argument, --latest, --delete-all, --delete-range, or --delete-range-by-date can be specified")
+		return fmt.Errorf("only one of: object argument, --run, --latest, --delete-all, --delete-range, or --delete-range-by-date can be specified")
 	}
 
 	// Resolve object(s) to delete
 	var toDelete []string
-	if objectName != "" {
+	if runID != "" {
+		historyPath := mustGetStringFlag(cmd, "history-file")
+		if historyPath == "" {
+			historyPath = backup.DefaultHistoryPath()
+		}
+		rec, err := backup.FindRunRecord(historyPath, runID)
+		if err != nil {
+			return err
+		}
+		if rec.DryRun {
+			return fmt.Errorf("run %s was a dry run and produced no objects", runID)
+		}
+		plan, err := bm.PlanRunDeletion(rec, mustGetBoolFlag(cmd, "allow-last"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Run %s on %s (started %s) recorded %d object(s)\n",
+			rec.ID, rec.Host, backup.FormatTime(rec.StartedAt, time.RFC3339), len(rec.ObjectKeys()))
+		for _, k := range plan.Missing {
+			fmt.Printf("  already gone: %s\n", k)
+		}
+		for _, k := range plan.Protected {
+			fmt.Printf("  protected (last backup of its site, use --allow-last to delete): %s\n", k)
+		}
+		if len(plan.Delete) == 0 {
+			fmt.Println("No objects to delete for this run")
+			return nil
+		}
+		toDelete = plan.Delete
+	} else if objectName != "" {
 		toDelete = append(toDelete, objectName)
 	} else if prefix != "" || deleteAll || deleteRange != "" || deleteRangeByDate != "" {
 		limit := 0 // Get all objects for these operations
@@ -81,11 +120,11 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 		if latest {
 			// pick latest
 			latestKey := objs[0].Key
-			latestTime := objs[0].LastModified
+			latestTime := backup.BackupTime(objs[0])
 			for _, o := range objs[1:] {
-				if o.LastModified.After(latestTime) {
+				if t := backup.BackupTime(o); t.After(latestTime) {
 					latestKey = o.Key
-					latestTime = o.LastModified
+					latestTime = t
 				}
 			}
 			toDelete = append(toDelete, latestKey)
@@ -122,7 +161,20 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 			}
 		}
 	} else {
-		return fmt.Errorf("object name argument or --prefix is required")
+		return fmt.Errorf("object name argument, --run or --prefix is required")
+	}
+
+	// Deleting part of a split or sharded backup would leave an unrestorable
+	// remainder, so pull in the other objects of every backup touched.
+	if runID == "" {
+		expanded, err := bm.ExpandToBackupSets(toDelete)
+		if err != nil {
+			return fmt.Errorf("failed to resolve related backup objects: %w", err)
+		}
+		if extra := len(expanded) - len(toDelete); extra > 0 {
+			fmt.Printf("Including %d related object(s) so no backup is left incomplete\n", extra)
+		}
+		toDelete = expanded
 	}
 
 	// Confirmation
@@ -136,7 +188,8 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 	}
 
 	if !skipConfirm {
-		fmt.Printf("About to delete %d object(s). Continue? [y/N]: ", len(toDelete))
+		// Prompt on stderr: --quiet discards stdout.
+		fmt.Fprintf(os.Stderr, "About to delete %d object(s). Continue? [y/N]: ", len(toDelete))
 		var resp string
 		if _, err := fmt.Scanln(&resp); err != nil {
 			return fmt.Errorf("confirmation failed: %w", err)
@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// loadContainerOverrides reads --overrides-file, falling back to the default
+// overrides file when the flag is empty.
+func loadContainerOverrides(cmd *cobra.Command) (backup.ContainerOverrides, error) {
+	path := mustGetStringFlag(cmd, "overrides-file")
+	if path == "" {
+		path = backup.DefaultContainerOverridesPath()
+	}
+	return backup.LoadContainerOverrides(path)
+}
+
+// writeOverridesDefault is the value of a bare --write-overrides, which writes
+// to the overrides file itself.
+const writeOverridesDefault = "__DEFAULT__"
+
+func runBackupDiscover(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	if mustGetBoolFlag(cmd, "audit") {
+		return runBackupDiscoverAudit(cmd, args)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("accepts at most 1 arg(s), received %d (only --audit takes several hosts)", len(args))
+	}
+
+	localMode := mustGetBoolFlag(cmd, "local")
+	var hostname string
+	if len(args) > 0 {
+		hostname = args[0]
+	}
+	docker, err := dockerEndpoint(cmd, hostname)
+	if err != nil {
+		return err
+	}
+	if !localMode && docker == nil && hostname == "" {
+		return fmt.Errorf("hostname argument is required unless --local is used")
+	}
+
+	writePath := mustGetStringFlag(cmd, "write-overrides")
+	if writePath == writeOverridesDefault {
+		writePath = mustGetStringFlag(cmd, "overrides-file")
+		if writePath == "" {
+			writePath = backup.DefaultContainerOverridesPath()
+		}
+	}
+	if writePath != "" && !mustGetBoolFlag(cmd, "force") {
+		if _, err := os.Stat(writePath); err == nil {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", writePath)
+		}
+	}
+
+	overrides, err := loadContainerOverrides(cmd)
+	if err != nil {
+		return err
+	}
+	manager, closeHost, err := discoverManager(cmd, hostname, docker)
+	if err != nil {
+		return err
+	}
+	defer closeHost()
+	manager.SetContainerOverrides(overrides)
+	sites, skipped, err := manager.DiscoverComposeStacks()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Discovered %d site(s):\n", len(sites))
+	for _, s := range sites {
+		source := "compose"
+		if _, ok := overrides[s.Name]; ok {
+			source = "override"
+		}
+		fmt.Printf("  %-30s %-8s %s (%s)\n", s.Name, s.Type, s.WorkingDir, source)
+		if len(s.Services) > 0 {
+			fmt.Printf("  %-30s with %s\n", "", strings.Join(s.Services, ", "))
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("\nSkipped %d WordPress container(s) without a working directory:\n", len(skipped))
+		for _, name := range skipped {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	if writePath == "" {
+		return nil
+	}
+	data, err := backup.StarterContainerOverrides(sites, skipped)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(writePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	if err := os.WriteFile(writePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write overrides file: %w", err)
+	}
+	fmt.Printf("\n✓ Wrote %s\n", writePath)
+	if len(skipped) > 0 {
+		fmt.Println("💡 Fill in working_dir for the TODO entries before using the file")
+	}
+	return nil
+}
+
+// discoverManager returns a manager running commands on hostname: over SSH,
+// through docker, or locally with --local. The returned func closes the
+// connection.
+func discoverManager(cmd *cobra.Command, hostname string, docker *backup.DockerEndpoint) (*backup.BackupManager, func(), error) {
+	var sshClient *auth.SSHClient
+	if !mustGetBoolFlag(cmd, "local") && docker == nil {
+		var err error
+		sshClient, err = createSSHClient(cmd, hostname)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	manager := backup.NewBackupManager(sshClient, nil)
+	if docker != nil {
+		manager.SetDockerEndpoint(docker)
+	}
+	applyCommandAudit(cmd, manager)
+	return manager, func() {
+		if sshClient != nil {
+			sshClient.Close()
+		}
+	}, nil
+}
+
+// runBackupDiscoverAudit cross-references the site directories and running
+// sites of every host given with the backups in the bucket.
+func runBackupDiscoverAudit(cmd *cobra.Command, hosts []string) error {
+	if mustGetStringFlag(cmd, "write-overrides") != "" {
+		return fmt.Errorf("--write-overrides cannot be used with --audit")
+	}
+	if mustGetBoolFlag(cmd, "local") {
+		if len(hosts) > 0 {
+			return fmt.Errorf("--local audits this machine and takes no hostnames")
+		}
+		hosts = []string{""}
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("at least one hostname is required unless --local is used")
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	overrides, err := loadContainerOverrides(cmd)
+	if err != nil {
+		return err
+	}
+	parentDir := mustGetStringFlag(cmd, "container-parent-dir")
+
+	var states []backup.HostSites
+	for _, hostname := range hosts {
+		docker, err := dockerEndpoint(cmd, hostname)
+		if err != nil {
+			return err
+		}
+		manager, closeHost, err := discoverManager(cmd, hostname, docker)
+		if err != nil {
+			return err
+		}
+		name := hostname
+		if name == "" {
+			name, _ = os.Hostname()
+		}
+		manager.SetContainerOverrides(overrides)
+		sites, _, err := manager.DiscoverComposeStacks()
+		if err == nil {
+			var dirs []string
+			if dirs, err = manager.ListSiteDirs(parentDir); err == nil {
+				states = append(states, backup.HostSites{Host: name, ParentDir: parentDir, Dirs: dirs, Containers: sites})
+			}
+		}
+		closeHost()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	audit, err := backup.NewBackupManager(nil, minioConfig).AuditSites(states, mustGetStringFlag(cmd, "prefix"))
+	if err != nil {
+		return err
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(audit)
+	}
+
+	if len(audit.Findings) == 0 {
+		fmt.Printf("✓ Every site on %s is running and backed up, and every prefix under %s has a site\n", strings.Join(audit.Hosts, ", "), audit.Prefix)
+		return nil
+	}
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FINDING\tHOST\tSITE\tPATH\tCONTAINER\tLAST BACKUP\tOBJECTS\tMB")
+	for _, f := range audit.Findings {
+		host, container, last, objects, mb := orDash(f.Host), orDash(f.Container), "-", "-", "-"
+		if !f.LastBackup.IsZero() {
+			last = backup.FormatTime(f.LastBackup, "2006-01-02 15:04")
+		}
+		if f.Kind == backup.AuditOrphanPrefix {
+			objects, mb = fmt.Sprint(f.Objects), fmt.Sprintf("%.2f", float64(f.Bytes)/(1024*1024))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.Kind, host, f.Site, orDash(f.Path), container, last, objects, mb)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d never backed up, %d running without backups, %d directories without a container, %d containers outside %s, %d orphan prefixes\n",
+		audit.Count(backup.AuditNeverBackedUp), audit.Count(backup.AuditUnbackedContainer), audit.Count(backup.AuditDirWithoutContainer),
+		audit.Count(backup.AuditContainerWithoutDir), parentDir, audit.Count(backup.AuditOrphanPrefix))
+	if audit.Count(backup.AuditOrphanPrefix) > 0 {
+		fmt.Println("💡 Orphan prefixes have no site on the audited hosts; audit every host before archiving or deleting them")
+	}
+	return nil
+}
+
+// orDash returns s, or "-" for an empty table cell.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addDockerEndpointFlags registers the flags reaching hosts through their
+// Docker API instead of SSH.
+func addDockerEndpointFlags(c *cobra.Command) {
+	c.Flags().Bool("docker", getEnvBoolWithDefault("BACKUP_DOCKER", false), "Reach the host through its Docker API (tcp://<host>:<docker-port>) instead of SSH (env: BACKUP_DOCKER)")
+	c.Flags().Int("docker-port", getEnvIntWithDefault("BACKUP_DOCKER_PORT", 2376), "Port of the Docker API with --docker (env: BACKUP_DOCKER_PORT)")
+	c.Flags().String("docker-context", getEnvWithDefault("BACKUP_DOCKER_CONTEXT", ""), "Docker context to use with --docker instead of the host's address (env: BACKUP_DOCKER_CONTEXT)")
+	c.Flags().Bool("docker-tls-verify", getEnvBoolWithDefault("BACKUP_DOCKER_TLS_VERIFY", true), "Verify the Docker API's TLS certificate (env: BACKUP_DOCKER_TLS_VERIFY)")
+	c.Flags().String("docker-cert-path", getEnvWithDefault("DOCKER_CERT_PATH", ""), "Directory with ca.pem, cert.pem and key.pem for the Docker API (default: ~/.docker, env: DOCKER_CERT_PATH)")
+	c.Flags().String("docker-helper-image", getEnvWithDefault("BACKUP_DOCKER_HELPER_IMAGE", backup.DefaultDockerHelperImage), "Image of the helper container host commands run in with --docker (env: BACKUP_DOCKER_HELPER_IMAGE)")
+}
+
+// dockerEndpoint returns the Docker endpoint of hostname from --docker, or
+// nil when the host is reached over SSH. --local and --docker exclude each
+// other.
+func dockerEndpoint(cmd *cobra.Command, hostname string) (*backup.DockerEndpoint, error) {
+	if !mustGetBoolFlag(cmd, "docker") {
+		return nil, nil
+	}
+	if cmd.Flags().Lookup("local") != nil && mustGetBoolFlag(cmd, "local") {
+		return nil, fmt.Errorf("--docker and --local are mutually exclusive")
+	}
+	context := mustGetStringFlag(cmd, "docker-context")
+	if hostname == "" && context == "" {
+		return nil, fmt.Errorf("--docker needs a host or --docker-context")
+	}
+	ep := backup.NewDockerEndpoint(hostname, mustGetIntFlag(cmd, "docker-port"))
+	ep.Context = context
+	ep.TLSVerify = mustGetBoolFlag(cmd, "docker-tls-verify")
+	ep.CertPath = mustGetStringFlag(cmd, "docker-cert-path")
+	ep.HelperImage = mustGetStringFlag(cmd, "docker-helper-image")
+	return ep, nil
+}
@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// drillReport is the JSON output of `backup drill`.
+type drillReport struct {
+	Status  backup.DrillStatus `json:"status"`
+	Skipped bool               `json:"skipped"`
+	Run     *backup.RunRecord  `json:"run,omitempty"`
+}
+
+func runBackupDrill(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	every, sites, err := drillSchedule(cmd)
+	if err != nil {
+		return err
+	}
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	history, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	webhook := mustGetStringFlag(cmd, "alert-webhook")
+	jsonOut := mustGetBoolFlag(cmd, "json")
+	status := backup.DrillStatusOf(history, every, backup.DefaultDrillGrace, time.Now())
+
+	if mustGetBoolFlag(cmd, "check") {
+		if jsonOut {
+			if err := printDrillReport(drillReport{Status: status, Skipped: true}); err != nil {
+				return err
+			}
+		} else {
+			printDrillStatus(status)
+		}
+		if !status.Overdue {
+			return nil
+		}
+		msg := fmt.Sprintf("no successful recovery drill within %s", formatDrillEvery(every))
+		if webhook != "" {
+			alert := &backup.DrillAlert{Event: backup.DrillEventOverdue, Host: host, Message: msg, LastSuccess: status.LastSuccess}
+			if err := backup.SendDrillAlert(webhook, alert); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to send drill alert: %v\n", err)
+			}
+		}
+		return fmt.Errorf("recovery drill overdue: %s", msg)
+	}
+
+	if !status.Due && !mustGetBoolFlag(cmd, "force") {
+		if jsonOut {
+			return printDrillReport(drillReport{Status: status, Skipped: true})
+		}
+		fmt.Printf("Next recovery drill is due %s; nothing to do (use --force to drill now).\n", backup.FormatTime(status.NextDue, time.RFC1123))
+		return nil
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+	rec, err := bm.RunDrill(backup.DrillOptions{
+		Prefix:      mustGetStringFlag(cmd, "prefix"),
+		Sites:       sites,
+		SandboxDir:  mustGetStringFlag(cmd, "sandbox-dir"),
+		KeepSandbox: mustGetBoolFlag(cmd, "keep-sandbox"),
+	})
+	if err != nil {
+		return err
+	}
+	rec.Host = host
+	if !mustGetBoolFlag(cmd, "no-history") {
+		if err := backup.AppendRunRecord(historyPath, rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record drill in history: %v\n", err)
+		}
+	}
+	status = backup.DrillStatusOf(append(history, *rec), every, backup.DefaultDrillGrace, time.Now())
+
+	if jsonOut {
+		if err := printDrillReport(drillReport{Status: status, Run: rec}); err != nil {
+			return err
+		}
+	} else if err := printDrillResults(rec); err != nil {
+		return err
+	}
+
+	if rec.Failed == 0 {
+		return nil
+	}
+	var failures []backup.DrillResult
+	var failed []string
+	for _, d := range rec.Drills {
+		if !d.Success {
+			failures = append(failures, d)
+			failed = append(failed, d.Site)
+		}
+	}
+	msg := fmt.Sprintf("recovery drill failed for %d of %d site(s): %s", rec.Failed, len(rec.Drills), strings.Join(failed, ", "))
+	if webhook != "" {
+		alert := &backup.DrillAlert{Event: backup.DrillEventFailed, RunID: rec.ID, Host: host, Message: msg, LastSuccess: status.LastSuccess, Failures: failures}
+		if err := backup.SendDrillAlert(webhook, alert); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send drill alert: %v\n", err)
+		}
+	}
+	return backup.WithExitCode(backup.ExitVerification, fmt.Errorf("%s", msg))
+}
+
+// drillSchedule returns the drill interval and site count: the flags, then
+// the drill section of the fleet file, then the defaults.
+func drillSchedule(cmd *cobra.Command) (time.Duration, int, error) {
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return 0, 0, err
+	}
+	spec, sites := mustGetStringFlag(cmd, "every"), mustGetIntFlag(cmd, "sites")
+	if fleet.Drill != nil {
+		if spec == "" {
+			spec = fleet.Drill.Every
+		}
+		if sites == 0 {
+			sites = fleet.Drill.Sites
+		}
+	}
+	every := backup.DefaultDrillEvery
+	if spec != "" {
+		if every, err = backup.ParseObjective(spec); err != nil {
+			return 0, 0, fmt.Errorf("invalid --every: %w", err)
+		}
+	}
+	if sites < 0 {
+		return 0, 0, fmt.Errorf("--sites must not be negative")
+	}
+	if sites == 0 {
+		sites = backup.DefaultDrillSites
+	}
+	return every, sites, nil
+}
+
+// formatDrillEvery renders whole days as "7d" and anything else as a Go
+// duration.
+func formatDrillEvery(every time.Duration) string {
+	if every%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", every/(24*time.Hour))
+	}
+	return every.String()
+}
+
+func printDrillStatus(s backup.DrillStatus) {
+	when := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return backup.FormatTime(t, time.RFC1123)
+	}
+	fmt.Printf("Last drill:         %s\n", when(s.LastDrill))
+	fmt.Printf("Last successful:    %s\n", when(s.LastSuccess))
+	if s.Overdue {
+		fmt.Println("Status:             ⚠️  overdue")
+	} else {
+		fmt.Printf("Status:             ok (next due %s)\n", when(s.NextDue))
+	}
+}
+
+func printDrillResults(rec *backup.RunRecord) error {
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nSITE\tRESULT\tSECONDS\tFILES\tISSUES")
+	for _, d := range rec.Drills {
+		result := "passed"
+		if !d.Success {
+			result = "FAILED"
+		}
+		issues := strings.Join(d.Issues, "; ")
+		if issues == "" {
+			issues = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.1f\t%d\t%s\n", d.Site, result, d.Seconds, d.Files, issues)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nDrill %s: %d passed, %d failed\n", rec.ID, rec.Succeeded, rec.Failed)
+	return nil
+}
+
+func printDrillReport(rep drillReport) error {
+	enc := json.NewEncoder(output.Data())
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
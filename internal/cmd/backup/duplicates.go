@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupDuplicates(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+
+	rep, err := bm.FindDuplicateSites(mustGetStringFlag(cmd, "prefix"), args, mustGetFloat64Flag(cmd, "threshold"))
+	if err != nil {
+		return err
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal duplicate report to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+		return nil
+	}
+
+	mb := func(n int64) float64 { return float64(n) / (1024 * 1024) }
+	fmt.Printf("\nCompared %d site(s); %d pair(s) share at least %.0f%% of their files.\n", rep.Sites, len(rep.Pairs), rep.Threshold*100)
+	if len(rep.Pairs) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\nSITE\tSITE\tSHARED\tSHARED MB\tFILES\tSUGGESTION")
+		for _, p := range rep.Pairs {
+			fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%.2f\t%d\t%s\n", p.Sites[0], p.Sites[1], p.Similarity*100, mb(p.SharedBytes), p.SharedFiles, p.Suggestion)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if rep.ReclaimableBytes > 0 {
+		fmt.Printf("\nExcluding the staging copies would save about %.2f MB (uncompressed) per backup.\n", mb(rep.ReclaimableBytes))
+	}
+	if len(rep.Skipped) > 0 {
+		sites := make([]string, 0, len(rep.Skipped))
+		for site := range rep.Skipped {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+		fmt.Printf("\nNot compared:\n")
+		for _, site := range sites {
+			fmt.Printf("  %s: %s\n", site, rep.Skipped[site])
+		}
+	}
+	return nil
+}
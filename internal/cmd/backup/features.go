@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// hostFeatures is one row of `backup features list --json`.
+type hostFeatures struct {
+	Host     string   `json:"host"`
+	Features []string `json:"features"`
+}
+
+func runBackupFeaturesList(cmd *cobra.Command, args []string) error {
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	hosts := args
+	if len(hosts) == 0 {
+		for host := range fleet.Hosts {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+	}
+
+	rows := make([]hostFeatures, 0, len(hosts))
+	for _, host := range hosts {
+		names := fleet.HostFeatures(host).Names()
+		if names == nil {
+			names = []string{}
+		}
+		rows = append(rows, hostFeatures{Host: host, Features: names})
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Known []backup.FeatureInfo `json:"known"`
+			Hosts []hostFeatures       `json:"hosts"`
+		}{backup.KnownFeatures, rows})
+	}
+
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FEATURE\tDESCRIPTION\tFLEET-WIDE\tHOSTS")
+	for _, f := range backup.KnownFeatures {
+		var on []string
+		for _, row := range rows {
+			for _, name := range row.Features {
+				if name == f.Name {
+					on = append(on, row.Host)
+				}
+			}
+		}
+		where := "-"
+		if len(on) > 0 {
+			where = fmt.Sprintf("%s (%d of %d)", strings.Join(on, ", "), len(on), len(rows))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Name, f.Description, onOff(fleet.Features.Enabled(f.Name)), where)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Println("\nNo hosts in the fleet file; pass hostnames to see what they would get.")
+	}
+	return nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
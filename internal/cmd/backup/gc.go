@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupGC(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	group, err := loadGroup(cmd)
+	if err != nil {
+		return err
+	}
+	opts := backup.GCOptions{
+		Prefix:     mustGetStringFlag(cmd, "prefix"),
+		MinAge:     mustGetDurationFlag(cmd, "min-age"),
+		StaleAfter: time.Duration(mustGetIntFlag(cmd, "stale-days")) * 24 * time.Hour,
+		PruneStale: mustGetBoolFlag(cmd, "prune-stale"),
+		Group:      group,
+		DryRun:     mustGetBoolFlag(cmd, "dry-run"),
+	}
+	if opts.PruneStale && opts.StaleAfter <= 0 {
+		return fmt.Errorf("--prune-stale needs --stale-days")
+	}
+
+	manager := backup.NewBackupManager(nil, minioConfig)
+	manager.SetDryRun(opts.DryRun)
+	rep, err := manager.CollectGarbage(opts)
+	if err != nil {
+		return err
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal gc report to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		for _, sp := range rep.StalePrefixes {
+			status := "reported"
+			switch {
+			case sp.Skipped != "":
+				status = "skipped (" + sp.Skipped + ")"
+			case opts.PruneStale:
+				status = "pruned"
+			}
+			fmt.Printf("🕸  %s: %d object(s), %.2f MB, newest %s — %s\n", sp.Prefix, sp.Objects,
+				float64(sp.Bytes)/(1024*1024), backup.InTimezone(sp.Newest).Format("2006-01-02"), status)
+		}
+		verb := "deleted"
+		if opts.DryRun {
+			verb = "to delete"
+		}
+		fmt.Printf("\nGC: %d zero-byte object(s) %s, %d stale prefix(es), %d empty director(ies)",
+			len(rep.ZeroByte), verb, len(rep.StalePrefixes), rep.EmptyDirs)
+		if !opts.DryRun {
+			fmt.Printf(", %d deleted (%.2f MB freed), %d failed", rep.Deleted, float64(rep.FreedBytes)/(1024*1024), rep.Failed)
+		}
+		fmt.Println()
+	}
+	if rep.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d delete(s) failed", rep.Failed), rep.Deleted)
+	}
+	return nil
+}
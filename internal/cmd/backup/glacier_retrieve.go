@@ -0,0 +1,166 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupGlacierRetrieve(cmd *cobra.Command, args []string) error {
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+
+	jobID := mustGetStringFlag(cmd, "job-id")
+	if (len(args) == 1) == (jobID != "") {
+		return fmt.Errorf("specify either an object key or archive ID to retrieve, or --job-id to download a completed retrieval")
+	}
+	awsConfig, err := getAWSConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if awsConfig == nil {
+		return fmt.Errorf("AWS Glacier vault not configured (set AWS_VAULT environment variable or --aws-vault flag)")
+	}
+	bm := backup.NewBackupManagerWithAWS(nil, nil, awsConfig)
+	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	bm.SetDryRun(dryRun)
+
+	if jobID != "" {
+		return downloadGlacierRetrieval(bm, jobID, mustGetStringFlag(cmd, "output"), dryRun)
+	}
+
+	tier, err := backup.ParseRetrievalTier(mustGetStringFlag(cmd, "tier"))
+	if err != nil {
+		return fmt.Errorf("invalid --tier: %w", err)
+	}
+	enforcement := mustGetStringFlag(cmd, "budget-enforcement")
+	if enforcement != "hard" && enforcement != "soft" {
+		return fmt.Errorf("invalid --budget-enforcement: %s (use 'hard' or 'soft')", enforcement)
+	}
+	budget := backup.RetrievalBudget{
+		ConfirmAbove: mustGetFloat64Flag(cmd, "confirm-above"),
+		MonthlyCap:   mustGetFloat64Flag(cmd, "monthly-budget"),
+		Soft:         enforcement == "soft",
+	}
+
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	records, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	entry, ok := backup.FindLedgerEntry(backup.GlacierLedger(records), args[0])
+	if !ok {
+		return fmt.Errorf("%s is not in the Glacier ledger %s (run 'backup aws-audit' to backfill it)", args[0], historyPath)
+	}
+
+	ledgerPath := mustGetStringFlag(cmd, "retrieval-ledger")
+	if ledgerPath == "" {
+		ledgerPath = backup.DefaultRetrievalLedgerPath()
+	}
+	spendRecords, err := backup.LoadRetrievalRecords(ledgerPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	spent := backup.MonthlyRetrievalSpend(spendRecords, now)
+	est := backup.EstimateRetrievalCost(entry.Bytes, tier, mustGetFloat64Flag(cmd, "price-per-gb"))
+
+	fmt.Printf("Archive:    %s\n", entry.ArchiveID)
+	fmt.Printf("Object:     %s (%.2f MB)\n", entry.ObjectKey, float64(entry.Bytes)/(1024*1024))
+	fmt.Printf("Tier:       %s\n", tier)
+	fmt.Printf("Estimate:   $%.2f\n", est.Cost)
+	if budget.MonthlyCap > 0 {
+		fmt.Printf("This month: $%.2f of $%.2f (%s cap)\n", spent, budget.MonthlyCap, enforcement)
+	} else {
+		fmt.Printf("This month: $%.2f\n", spent)
+	}
+
+	decision, err := budget.Check(est, spent)
+	if err != nil {
+		return err
+	}
+	if decision.Warning != "" {
+		fmt.Printf("⚠️  Warning: %s\n", decision.Warning)
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would start a %s retrieval of %s\n", tier, entry.ArchiveID)
+		return nil
+	}
+	if decision.Confirm && !mustGetBoolFlag(cmd, "yes") {
+		// Prompt on stderr: --quiet discards stdout.
+		fmt.Fprintf(os.Stderr, "Start this retrieval for about $%.2f? [y/N]: ", est.Cost)
+		var resp string
+		if _, err := fmt.Scanln(&resp); err != nil {
+			return fmt.Errorf("confirmation failed (pass --yes to confirm non-interactively): %w", err)
+		}
+		resp = strings.TrimSpace(strings.ToLower(resp))
+		if resp != "y" && resp != "yes" {
+			fmt.Println("Aborted by user")
+			return nil
+		}
+	}
+
+	id, err := bm.InitiateGlacierRetrieval(entry.ArchiveID, tier, "ciwg-cli retrieval of "+entry.ObjectKey)
+	if err != nil {
+		return err
+	}
+	rec := backup.RetrievalRecord{
+		JobID:         id,
+		ArchiveID:     entry.ArchiveID,
+		ObjectKey:     entry.ObjectKey,
+		Tier:          tier,
+		Bytes:         entry.Bytes,
+		EstimatedCost: est.Cost,
+		InitiatedAt:   now.UTC(),
+	}
+	if err := backup.AppendRetrievalRecord(ledgerPath, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to record the retrieval in %s: %v\n", ledgerPath, err)
+	}
+	fmt.Printf("✓ Started %s retrieval of %s\n", tier, entry.ObjectKey)
+	fmt.Printf("  Job ID: %s\n", id)
+	fmt.Println("  Once Glacier completes the job, download it with:")
+	fmt.Printf("  ciwg-cli backup glacier-retrieve --job-id %s --output <file>\n", id)
+	return nil
+}
+
+// downloadGlacierRetrieval writes the output of a completed retrieval job to
+// output, through a temp file so a failed download leaves nothing behind.
+func downloadGlacierRetrieval(bm *backup.BackupManager, jobID, output string, dryRun bool) error {
+	if output == "" {
+		return fmt.Errorf("--output is required with --job-id")
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would download job %s to %s\n", jobID, output)
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".glacier-retrieval-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := bm.FetchGlacierRetrieval(jobID, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("✓ Downloaded %.2f MB to %s\n", float64(n)/(1024*1024), output)
+	return nil
+}
@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addGroupFlags registers --group, --fleet-file and --inventory on a command
+// that can target a labelled subset of the fleet.
+func addGroupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("group", nil, "Only sites whose fleet labels match, e.g. tier=gold or tier=gold,client=acme; repeat to match any of several groups")
+	cmd.Flags().String("fleet-file", getEnvWithDefault("BACKUP_FLEET_FILE", ""), "YAML file labelling hosts and sites for --group (default: ~/.ciwg/fleet.yaml, env: BACKUP_FLEET_FILE)")
+	cmd.Flags().String("inventory", getEnvWithDefault("BACKUP_INVENTORY", ""), "Ansible inventory (INI or YAML) or Terraform output JSON adding hosts to the fleet; its groups can be selected with --group <name> (env: BACKUP_INVENTORY)")
+}
+
+// loadGroup returns the --group selection of cmd, or nil when --group is not
+// given.
+func loadGroup(cmd *cobra.Command) (*backup.FleetGroup, error) {
+	groups := mustGetStringArrayFlag(cmd, "group")
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewFleetGroup(fleet, groups)
+}
+
+// loadFleet reads the --fleet-file of cmd, with the hosts of --inventory
+// when the command has one.
+func loadFleet(cmd *cobra.Command) (*backup.Fleet, error) {
+	path := mustGetStringFlag(cmd, "fleet-file")
+	if path == "" {
+		path = backup.DefaultFleetPath()
+	}
+	fleet, err := backup.LoadFleet(path)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Flags().Lookup("inventory") == nil {
+		return fleet, nil
+	}
+	if inventoryPath := mustGetStringFlag(cmd, "inventory"); inventoryPath != "" {
+		inv, err := backup.LoadHostInventory(inventoryPath)
+		if err != nil {
+			return nil, err
+		}
+		fleet.MergeInventory(inv)
+	}
+	return fleet, nil
+}
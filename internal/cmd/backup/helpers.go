@@ -10,62 +10,96 @@ import (
 	"github.com/spf13/cobra"
 
 	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
 )
 
 // findEnvArg inspects argv for an explicit --env argument and returns
 // the value if present. Supports `--env=path` and `--env path` forms.
 func findEnvArg(argv []string) string {
+	return findFlagArg(argv, "env")
+}
+
+// findFlagArg returns the value of --name in argv, in either the
+// `--name=value` or `--name value` form, before flags are parsed.
+func findFlagArg(argv []string, name string) string {
+	flag := "--" + name
 	for i := 0; i < len(argv); i++ {
 		a := argv[i]
-		if strings.HasPrefix(a, "--env=") {
-			return strings.TrimPrefix(a, "--env=")
+		if strings.HasPrefix(a, flag+"=") {
+			return strings.TrimPrefix(a, flag+"=")
 		}
-		if a == "--env" && i+1 < len(argv) {
+		if a == flag && i+1 < len(argv) {
 			return argv[i+1]
 		}
 	}
 	return ""
 }
 
+// The mustGet helpers read one flag of cmd. They panic when cmd does not
+// register the flag with that type: reading a flag a command never offers is
+// a programming error, not an unset value.
+
 // mustGetStringFlag gets a string flag value from a cobra command
 func mustGetStringFlag(cmd *cobra.Command, name string) string {
-	val, _ := cmd.Flags().GetString(name)
+	f := newFlagReader(cmd)
+	val := f.str(name)
+	f.must()
 	return val
 }
 
 // mustGetBoolFlag gets a boolean flag value from a cobra command
 func mustGetBoolFlag(cmd *cobra.Command, name string) bool {
-	val, _ := cmd.Flags().GetBool(name)
+	f := newFlagReader(cmd)
+	val := f.boolean(name)
+	f.must()
 	return val
 }
 
 // mustGetFloat64Flag gets a float64 flag value from a cobra command
 func mustGetFloat64Flag(cmd *cobra.Command, name string) float64 {
-	val, _ := cmd.Flags().GetFloat64(name)
+	f := newFlagReader(cmd)
+	val := f.float(name)
+	f.must()
 	return val
 }
 
 // mustGetDurationFlag gets a duration flag value from a cobra command
 func mustGetDurationFlag(cmd *cobra.Command, name string) time.Duration {
-	val, _ := cmd.Flags().GetDuration(name)
+	f := newFlagReader(cmd)
+	val := f.duration(name)
+	f.must()
 	return val
 }
 
 // mustGetIntFlag gets an int flag value from a cobra command
 func mustGetIntFlag(cmd *cobra.Command, name string) int {
-	val, _ := cmd.Flags().GetInt(name)
+	f := newFlagReader(cmd)
+	val := f.integer(name)
+	f.must()
 	return val
 }
 
 // mustGetInt64Flag gets an int64 flag value from a cobra command
 func mustGetInt64Flag(cmd *cobra.Command, name string) int64 {
-	val, _ := cmd.Flags().GetInt64(name)
+	f := newFlagReader(cmd)
+	val := f.int64(name)
+	f.must()
+	return val
+}
+
+// mustGetStringArrayFlag gets a string array flag value from a cobra command
+func mustGetStringArrayFlag(cmd *cobra.Command, name string) []string {
+	f := newFlagReader(cmd)
+	val := f.strArray(name)
+	f.must()
 	return val
 }
 
 // mustGetCountFlag gets a count flag value from a cobra command
 func mustGetCountFlag(cmd *cobra.Command, name string) int {
-	val, _ := cmd.Flags().GetCount(name)
+	f := newFlagReader(cmd)
+	val := f.count(name)
+	f.must()
 	return val
 }
 
@@ -192,37 +226,63 @@ func parseServerRange(pattern string) (string, int, int, map[int]bool, error) {
 
 // createSSHClient creates an SSH client from command flags and target hostname
 func createSSHClient(cmd *cobra.Command, target string) (*auth.SSHClient, error) {
-	// Parse target into user@host format
-	parts := strings.Split(target, "@")
-	var username, hostname string
-
-	if len(parts) == 2 {
-		username = parts[0]
-		hostname = parts[1]
-	} else {
-		username, _ = cmd.Flags().GetString("user")
-		if username == "" {
-			username = getCurrentUser()
+	cfg, err := commandConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewSSHClient(cfg.SSHTarget(target))
+}
+
+// applyCommandAudit enables the command audit trail and live trace of
+// manager from the --audit-commands and --trace-commands flags.
+func applyCommandAudit(cmd *cobra.Command, manager *backup.BackupManager) {
+	cfg := backup.CommandAuditConfig{
+		Trace:       mustGetBoolFlag(cmd, "trace-commands"),
+		OutputLimit: mustGetIntFlag(cmd, "audit-output-limit"),
+	}
+	if mustGetBoolFlag(cmd, "audit-commands") {
+		cfg.File = mustGetStringFlag(cmd, "command-audit-file")
+		if cfg.File == "" {
+			cfg.File = backup.DefaultCommandAuditPath()
 		}
-		hostname = target
 	}
+	manager.SetCommandAudit(cfg)
+}
 
-	port, _ := cmd.Flags().GetString("port")
-	keyPath, _ := cmd.Flags().GetString("key")
-	useAgent, _ := cmd.Flags().GetBool("agent")
-	timeout, _ := cmd.Flags().GetDuration("timeout")
-
-	config := auth.SSHConfig{
-		Hostname:  hostname,
-		Username:  username,
-		Port:      port,
-		KeyPath:   keyPath,
-		UseAgent:  useAgent,
-		Timeout:   timeout,
-		KeepAlive: 30 * time.Second,
+// exportSiteFreshness refreshes the per-site freshness gauges when
+// --freshness-metrics-file or --pushgateway-url is set. Failures only warn so
+// metrics never fail the command that triggered them.
+func exportSiteFreshness(cmd *cobra.Command, manager *backup.BackupManager) {
+	cfg := backup.FreshnessMetricsConfig{
+		File:           mustGetStringFlag(cmd, "freshness-metrics-file"),
+		PushgatewayURL: mustGetStringFlag(cmd, "pushgateway-url"),
+		Prefix:         mustGetStringFlag(cmd, "freshness-prefix"),
 	}
+	if err := manager.ExportSiteFreshness(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: %v\n", err)
+	}
+}
 
-	return auth.NewSSHClient(config)
+// flushSummariesAfterRun makes every command under root update the bucket
+// summary with what its managers wrote or deleted once it returns, whether
+// it failed or not (PersistentPostRunE only runs on success). Failing to is
+// only a warning: the next rebuild catches up.
+func flushSummariesAfterRun(root *cobra.Command) {
+	for _, c := range root.Commands() {
+		flushSummariesAfterRun(c)
+	}
+	run := root.RunE
+	if run == nil {
+		return
+	}
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		defer func() {
+			if err := backup.FlushBucketSummaries(); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Warning: failed to update the bucket summary: %v\n", err)
+			}
+		}()
+		return run(cmd, args)
+	}
 }
 
 // getCurrentUser returns the current user (defaults to "root")
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupImport(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	fromBucket := mustGetBoolFlag(cmd, "from-bucket")
+	prefix := mustGetStringFlag(cmd, "prefix")
+	opts := &backup.ImportOptions{
+		Site:         mustGetStringFlag(cmd, "site"),
+		DeleteSource: mustGetBoolFlag(cmd, "delete-source"),
+		DryRun:       mustGetBoolFlag(cmd, "dry-run"),
+	}
+	if opts.DeleteSource && !fromBucket {
+		return fmt.Errorf("--delete-source only applies to --from-bucket; local files are never deleted")
+	}
+	if prefix != "" && !fromBucket {
+		return fmt.Errorf("--prefix needs --from-bucket")
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	manager := backup.NewBackupManager(nil, minioConfig)
+	manager.SetDryRun(opts.DryRun)
+
+	sources := args
+	if fromBucket && prefix != "" {
+		objs, err := manager.ListBackups(prefix, 0)
+		if err != nil {
+			return err
+		}
+		for _, o := range objs {
+			if strings.HasSuffix(strings.ToLower(o.Key), ".zip") {
+				sources = append(sources, o.Key)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("nothing to import: pass backup files, or --from-bucket with object keys or --prefix")
+	}
+	if opts.Site != "" && len(sources) > 1 {
+		return fmt.Errorf("--site names a single backup; %d were given", len(sources))
+	}
+
+	failed := 0
+	for _, src := range sources {
+		var res *backup.ImportResult
+		if fromBucket {
+			res, err = manager.ImportBackupObject(src, opts)
+		} else {
+			res, err = manager.ImportBackupFile(src, opts)
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", src, err)
+			failed++
+			continue
+		}
+		switch {
+		case opts.DryRun && res.Converted:
+			fmt.Printf("[DRY RUN] Would convert %s (%d files) to %s\n", src, res.Files, res.ObjectKey)
+		case opts.DryRun:
+			fmt.Printf("[DRY RUN] Would upload %s as %s\n", src, res.ObjectKey)
+		case res.Converted:
+			fmt.Printf("✓ Converted %s (%d files) to %s (%.2f MB)\n", src, res.Files, res.ObjectKey, float64(res.Size)/(1024*1024))
+		default:
+			fmt.Printf("✓ Uploaded %s as %s (%.2f MB)\n", src, res.ObjectKey, float64(res.Size)/(1024*1024))
+		}
+		if fromBucket && opts.DeleteSource && !opts.DryRun {
+			fmt.Printf("  Deleted %s\n", src)
+		}
+	}
+	if failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d of %d backup(s) failed to import", failed, len(sources)), len(sources)-failed)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupIndex(cmd *cobra.Command, args []string) error {
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	prefix := mustGetStringFlag(cmd, "prefix")
+	metrics := backup.FreshnessMetricsConfig{
+		File:           mustGetStringFlag(cmd, "freshness-metrics-file"),
+		PushgatewayURL: mustGetStringFlag(cmd, "pushgateway-url"),
+		Prefix:         prefix,
+	}
+
+	if !mustGetBoolFlag(cmd, "listen") {
+		ix, err := bm.BuildBackupIndex(prefix)
+		if err != nil {
+			return err
+		}
+		if err := bm.PublishBackupIndex(ix, metrics); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Indexed %d object(s) under %q\n", len(ix.Objects()), prefix)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = bm.ServeBackupIndex(ctx, backup.IndexListenOptions{
+		Prefix:          prefix,
+		Source:          mustGetStringFlag(cmd, "source"),
+		ListenAddr:      mustGetStringFlag(cmd, "listen-addr"),
+		WebhookToken:    mustGetStringFlag(cmd, "webhook-token"),
+		PublishInterval: mustGetDurationFlag(cmd, "publish-interval"),
+		ResyncInterval:  mustGetDurationFlag(cmd, "resync-interval"),
+		Metrics:         metrics,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("✓ Index listener stopped")
+	return nil
+}
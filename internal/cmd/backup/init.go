@@ -0,0 +1,404 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
+)
+
+var validProfileName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// activeProfile is the name of the backup profile applied at startup, or
+// empty when none was.
+var activeProfile string
+
+// initAnswers is the --from-answers file: a backup profile plus the answers
+// the wizard would otherwise prompt for.
+type initAnswers struct {
+	Name                 string `yaml:"name"`
+	SSHTestHost          string `yaml:"ssh_test_host"`
+	backup.BackupProfile `yaml:",inline"`
+}
+
+// applyBackupProfile exports the named profile as environment variables so
+// flag defaults pick it up. Variables that are already set are kept unless
+// override is set. A missing profile is only reported when required.
+func applyBackupProfile(name string, override, required bool) {
+	p, err := backup.LoadBackupProfile(backup.BackupProfilePath(name))
+	if err != nil {
+		if required || !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Warning: backup profile %q not applied: %v\n", name, err)
+		}
+		return
+	}
+	activeProfile = name
+	if p.Permissions != nil {
+		if err := p.Permissions.Validate(); err != nil {
+			// Fail closed: a mistyped deny list must not grant everything.
+			fmt.Fprintf(os.Stderr, "Warning: backup profile %q has invalid permissions, treating it as read-only: %v\n", name, err)
+			p.Permissions = &backup.BackupProfilePermissions{ReadOnly: true}
+		}
+		profilePermissions, permissionsProfile = p.Permissions, name
+	}
+	for k, v := range p.Env() {
+		if _, set := os.LookupEnv(k); set && !override {
+			continue
+		}
+		os.Setenv(k, v)
+	}
+}
+
+func runBackupInit(cmd *cobra.Command, args []string) error {
+	name := mustGetStringFlag(cmd, "name")
+	skipTests := mustGetBoolFlag(cmd, "skip-tests")
+	force := mustGetBoolFlag(cmd, "force")
+
+	var profile *backup.BackupProfile
+	if answersPath := mustGetStringFlag(cmd, "from-answers"); answersPath != "" {
+		answers, err := loadInitAnswers(answersPath)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("name") && answers.Name != "" {
+			name = answers.Name
+		}
+		if err := checkProfileName(name); err != nil {
+			return err
+		}
+		profile = &answers.BackupProfile
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid answers file: %w", err)
+		}
+		if !skipTests {
+			if err := testMinioProfile(profile); err != nil {
+				return err
+			}
+			if profile.AWS != nil {
+				if err := testAWSProfile(profile); err != nil {
+					return err
+				}
+			}
+			if answers.SSHTestHost != "" {
+				if err := testSSHProfile(profile, answers.SSHTestHost); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		if err := checkProfileName(name); err != nil {
+			return err
+		}
+		var err error
+		profile, err = runInitWizard(skipTests)
+		if err != nil {
+			return err
+		}
+	}
+
+	path := backup.BackupProfilePath(name)
+	if path == "" {
+		return fmt.Errorf("cannot determine the home directory for the profile")
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		if mustGetStringFlag(cmd, "from-answers") != "" {
+			return fmt.Errorf("profile %s already exists; use --force to replace it", path)
+		}
+		replace := false
+		if err := survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("Profile %s exists. Replace it?", path)}, &replace); err != nil || !replace {
+			return fmt.Errorf("profile %s already exists; not replaced", path)
+		}
+	}
+	if err := backup.SaveBackupProfile(path, profile); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✓ Saved profile %q to %s (readable only by you)\n", name, path)
+	if name == backup.DefaultBackupProfileName {
+		fmt.Println("Backup commands use it automatically; environment variables and flags still take precedence.")
+	} else {
+		fmt.Printf("Use it with --profile %s or CIWG_BACKUP_PROFILE=%s\n", name, name)
+	}
+	return nil
+}
+
+func checkProfileName(name string) error {
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+func loadInitAnswers(path string) (*initAnswers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers file: %w", err)
+	}
+	var answers initAnswers
+	if err := yaml.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("failed to parse answers file %s: %w", path, err)
+	}
+	return &answers, nil
+}
+
+// runInitWizard prompts for each section and tests it before moving on.
+func runInitWizard(skipTests bool) (*backup.BackupProfile, error) {
+	p := &backup.BackupProfile{}
+	fmt.Println("This wizard writes a backup profile with your Minio, AWS and SSH settings.")
+	fmt.Println()
+
+	fmt.Println("── Minio ──")
+	for {
+		if err := askMinio(p); err != nil {
+			return nil, err
+		}
+		if skipTests || testMinioProfile(p) == nil || !askRetry("Minio") {
+			break
+		}
+	}
+
+	fmt.Println("\n── AWS Glacier ──")
+	useAWS := p.AWS != nil
+	if err := survey.AskOne(&survey.Confirm{Message: "Also copy backups to AWS Glacier?", Default: useAWS}, &useAWS); err != nil {
+		return nil, err
+	}
+	if useAWS {
+		for {
+			if err := askAWS(p); err != nil {
+				return nil, err
+			}
+			if skipTests || testAWSProfile(p) == nil || !askRetry("AWS") {
+				break
+			}
+		}
+	}
+
+	fmt.Println("\n── SSH ──")
+	for {
+		host, err := askSSH(p)
+		if err != nil {
+			return nil, err
+		}
+		if skipTests || host == "" || testSSHProfile(p, host) == nil || !askRetry("SSH") {
+			break
+		}
+	}
+	return p, nil
+}
+
+func askRetry(section string) bool {
+	retry := true
+	if err := survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("Re-enter the %s settings? (no keeps them as entered)", section), Default: true}, &retry); err != nil {
+		return false
+	}
+	return retry
+}
+
+// askSecret prompts for a secret, keeping current when the answer is empty.
+func askSecret(message, current string) (string, error) {
+	if current != "" {
+		message += " (leave empty to keep)"
+	}
+	var v string
+	if err := survey.AskOne(&survey.Password{Message: message}, &v); err != nil {
+		return "", err
+	}
+	if v == "" {
+		return current, nil
+	}
+	return v, nil
+}
+
+func askMinio(p *backup.BackupProfile) error {
+	m := &p.Minio
+	if m.Bucket == "" {
+		m.Bucket = "backups"
+	}
+	validEndpoint := func(ans interface{}) error {
+		if s, _ := ans.(string); !backup.IsFileEndpoint(s) {
+			_, err := backup.ParseMinioEndpoint(s, true)
+			return err
+		}
+		return nil
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Endpoint (host:port, [IPv6]:port, https://host, or file:///path for a filesystem target):", Default: m.Endpoint}, &m.Endpoint, survey.WithValidator(survey.Required), survey.WithValidator(validEndpoint)); err != nil {
+		return err
+	}
+	if backup.IsFileEndpoint(m.Endpoint) {
+		return nil
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Access key:", Default: m.AccessKey}, &m.AccessKey, survey.WithValidator(survey.Required)); err != nil {
+		return err
+	}
+	secret, err := askSecret("Secret key:", m.SecretKey)
+	if err != nil {
+		return err
+	}
+	m.SecretKey = secret
+	if err := survey.AskOne(&survey.Input{Message: "Bucket:", Default: m.Bucket}, &m.Bucket, survey.WithValidator(survey.Required)); err != nil {
+		return err
+	}
+	useSSL := m.SSL == nil || *m.SSL
+	if err := survey.AskOne(&survey.Confirm{Message: "Use TLS?", Default: useSSL}, &useSSL); err != nil {
+		return err
+	}
+	m.SSL = &useSSL
+	return survey.AskOne(&survey.Input{Message: "Path prefix within the bucket (optional):", Default: m.BucketPath}, &m.BucketPath)
+}
+
+func askAWS(p *backup.BackupProfile) error {
+	if p.AWS == nil {
+		p.AWS = &backup.BackupProfileAWS{Region: "us-east-1", AccountID: "-"}
+	}
+	a := p.AWS
+	if err := survey.AskOne(&survey.Input{Message: "Glacier vault:", Default: a.Vault}, &a.Vault, survey.WithValidator(survey.Required)); err != nil {
+		return err
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Region:", Default: a.Region}, &a.Region); err != nil {
+		return err
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Account ID ('-' for the credentials' account):", Default: a.AccountID}, &a.AccountID); err != nil {
+		return err
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Access key:", Default: a.AccessKey}, &a.AccessKey, survey.WithValidator(survey.Required)); err != nil {
+		return err
+	}
+	secret, err := askSecret("Secret access key:", a.SecretKey)
+	if err != nil {
+		return err
+	}
+	a.SecretKey = secret
+	return nil
+}
+
+// askSSH prompts for the SSH defaults and returns the host to test them
+// against, if any.
+func askSSH(p *backup.BackupProfile) (string, error) {
+	s := &p.SSH
+	if s.User == "" {
+		s.User = getCurrentUser()
+	}
+	if s.Port == "" {
+		s.Port = "22"
+	}
+	if s.Key == "" {
+		s.Key = defaultSSHKey()
+	}
+	if s.Timeout == "" {
+		s.Timeout = "30s"
+	}
+	if err := survey.AskOne(&survey.Input{Message: "User:", Default: s.User}, &s.User); err != nil {
+		return "", err
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Port:", Default: s.Port}, &s.Port); err != nil {
+		return "", err
+	}
+	if err := survey.AskOne(&survey.Input{Message: "Private key (optional with an agent):", Default: s.Key}, &s.Key); err != nil {
+		return "", err
+	}
+	useAgent := s.Agent == nil || *s.Agent
+	if err := survey.AskOne(&survey.Confirm{Message: "Use the SSH agent?", Default: useAgent}, &useAgent); err != nil {
+		return "", err
+	}
+	s.Agent = &useAgent
+	if err := survey.AskOne(&survey.Input{Message: "Connection timeout:", Default: s.Timeout}, &s.Timeout, survey.WithValidator(validDuration)); err != nil {
+		return "", err
+	}
+	var host string
+	if err := survey.AskOne(&survey.Input{Message: "Server to test the connection against (optional):"}, &host); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(host), nil
+}
+
+func validDuration(ans interface{}) error {
+	if _, err := time.ParseDuration(fmt.Sprint(ans)); err != nil {
+		return fmt.Errorf("not a duration (e.g. 30s): %v", err)
+	}
+	return nil
+}
+
+// defaultSSHKey returns the first standard private key found in ~/.ssh.
+func defaultSSHKey() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		if _, err := os.Stat(filepath.Join(home, ".ssh", name)); err == nil {
+			return "~/.ssh/" + name
+		}
+	}
+	return ""
+}
+
+func testMinioProfile(p *backup.BackupProfile) error {
+	cfg, err := p.MinioConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nTesting Minio connection to %s...\n", cfg.Endpoint)
+	if err := backup.NewBackupManager(nil, cfg).TestMinioConnection(); err != nil {
+		fmt.Printf("✗ Minio connection failed: %v\n", err)
+		return fmt.Errorf("minio connection test failed: %w", err)
+	}
+	fmt.Println("✓ Minio connection OK")
+	return nil
+}
+
+func testAWSProfile(p *backup.BackupProfile) error {
+	minioCfg, err := p.MinioConfig()
+	if err != nil {
+		return err
+	}
+	awsCfg := p.AWSConfig()
+	fmt.Printf("\nTesting AWS Glacier vault %s in %s...\n", awsCfg.Vault, awsCfg.Region)
+	if err := backup.NewBackupManagerWithAWS(nil, minioCfg, awsCfg).TestAWSConnection(); err != nil {
+		fmt.Printf("✗ AWS connection failed: %v\n", err)
+		return fmt.Errorf("aws connection test failed: %w", err)
+	}
+	fmt.Println("✓ AWS connection OK")
+	return nil
+}
+
+// testSSHProfile connects to host with the profile's SSH settings and checks
+// that the user can run docker there.
+func testSSHProfile(p *backup.BackupProfile, host string) error {
+	env := p.Env()
+	timeout, _ := time.ParseDuration(env["SSH_TIMEOUT"])
+	cfg := auth.SSHConfig{
+		Hostname: host,
+		Username: env["SSH_USER"],
+		Port:     env["SSH_PORT"],
+		KeyPath:  env["SSH_KEY"],
+		UseAgent: p.SSH.Agent == nil || *p.SSH.Agent,
+		Timeout:  timeout,
+	}
+	if cfg.Username == "" {
+		cfg.Username = getCurrentUser()
+	}
+	fmt.Printf("\nTesting SSH connection to %s@%s...\n", cfg.Username, host)
+	client, err := auth.NewSSHClient(cfg)
+	if err != nil {
+		fmt.Printf("✗ SSH connection failed: %v\n", err)
+		return fmt.Errorf("ssh connection test failed: %w", err)
+	}
+	defer client.Close()
+	if _, stderr, err := client.ExecuteCommand("docker ps -q > /dev/null"); err != nil {
+		fmt.Printf("⚠️  Connected, but docker is not usable by %s: %s\n", cfg.Username, strings.TrimSpace(stderr))
+		return nil
+	}
+	fmt.Println("✓ SSH connection OK (docker available)")
+	return nil
+}
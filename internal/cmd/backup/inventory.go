@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupExportInventory(cmd *cobra.Command, args []string) error {
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+
+	format := mustGetStringFlag(cmd, "format")
+	outPath := mustGetStringFlag(cmd, "out")
+	switch format {
+	case "csv":
+	case "parquet":
+		if outPath == "" || outPath == "-" {
+			return fmt.Errorf("--out is required for parquet output")
+		}
+	default:
+		return fmt.Errorf("invalid --format %q (use csv or parquet)", format)
+	}
+
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	history, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+
+	group, err := loadGroup(cmd)
+	if err != nil {
+		return err
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+
+	rows, err := bm.BuildInventory(backup.InventoryOptions{
+		Prefix:  mustGetStringFlag(cmd, "prefix"),
+		History: history,
+		Verify:  mustGetBoolFlag(cmd, "verify"),
+		Group:   group,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build inventory: %w", err)
+	}
+
+	w := output.Data()
+	if outPath != "" && outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", outPath, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "parquet" {
+		err = backup.WriteInventoryParquet(w, rows)
+	} else {
+		err = backup.WriteInventoryCSV(w, rows)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	hot, cold, failed := 0, 0, 0
+	for _, r := range rows {
+		if r.Class == backup.StorageClassCold {
+			cold++
+		} else {
+			hot++
+		}
+		if r.Verification == backup.VerifyFailed {
+			failed++
+		}
+	}
+	if outPath != "" && outPath != "-" {
+		fmt.Printf("✓ Wrote %d row(s) to %s (%d hot, %d cold)\n", len(rows), outPath, hot, cold)
+	}
+	if failed > 0 {
+		return backup.WithExitCode(backup.ExitVerification, fmt.Errorf("%d object(s) failed verification", failed))
+	}
+	return nil
+}
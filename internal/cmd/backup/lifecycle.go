@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupLifecycleExport(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	format := mustGetStringFlag(cmd, "format")
+	if format != "terraform" && format != "s3-json" {
+		return fmt.Errorf("invalid --format %q (use terraform or s3-json)", format)
+	}
+
+	// The same numbers `backup create --prune` would use.
+	policy := &backup.SmartRetentionPolicy{
+		Enabled:     true,
+		KeepDaily:   mustGetIntFlag(cmd, "keep-daily"),
+		KeepWeekly:  mustGetIntFlag(cmd, "keep-weekly"),
+		KeepMonthly: mustGetIntFlag(cmd, "keep-monthly"),
+		WeeklyDay:   mustGetIntFlag(cmd, "weekly-day"),
+		MonthlyDay:  mustGetIntFlag(cmd, "monthly-day"),
+	}
+	preset, err := resolveRetentionPreset(cmd)
+	if err != nil {
+		return err
+	}
+	if preset != nil {
+		policy = preset.Policy()
+		overrideIntFromFlag(cmd, "keep-daily", &policy.KeepDaily)
+		overrideIntFromFlag(cmd, "keep-weekly", &policy.KeepWeekly)
+		overrideIntFromFlag(cmd, "keep-monthly", &policy.KeepMonthly)
+		overrideIntFromFlag(cmd, "weekly-day", &policy.WeeklyDay)
+		overrideIntFromFlag(cmd, "monthly-day", &policy.MonthlyDay)
+	}
+
+	bucket := mustGetStringFlag(cmd, "minio-bucket")
+	plan, err := backup.BuildLifecyclePlan(policy, backup.LifecycleOptions{
+		Bucket:              bucket,
+		Prefix:              mustGetStringFlag(cmd, "prefix"),
+		GraceDays:           mustGetIntFlag(cmd, "grace-days"),
+		TransitionDays:      mustGetIntFlag(cmd, "transition-days"),
+		StorageClass:        mustGetStringFlag(cmd, "storage-class"),
+		StagingPrefix:       mustGetStringFlag(cmd, "staging-prefix"),
+		StagingStorageClass: mustGetStringFlag(cmd, "staging-storage-class"),
+		AbortMultipartDays:  mustGetIntFlag(cmd, "abort-multipart-days"),
+	})
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if format == "s3-json" {
+		if data, err = plan.S3JSON(); err != nil {
+			return fmt.Errorf("failed to render lifecycle JSON: %w", err)
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(plan.Terraform([]string{
+			"Generated by 'ciwg-cli backup lifecycle export'. Do not edit by hand; re-export",
+			"when the retention policy changes.",
+			fmt.Sprintf("Smart retention: daily=%d, weekly=%d (day %d), monthly=%d (day %d).",
+				policy.KeepDaily, policy.KeepWeekly, policy.WeeklyDay, policy.KeepMonthly, policy.MonthlyDay),
+			fmt.Sprintf("The oldest backup it keeps is %d days old; expiration is a backstop after that,", plan.HorizonDays),
+			"the CLI's pruning removes the backups in between.",
+		}))
+	}
+
+	outPath := mustGetStringFlag(cmd, "out")
+	if outPath == "" || outPath == "-" {
+		_, err = output.Data().Write(data)
+		return err
+	}
+	if err := os.WriteFile(outPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	fmt.Printf("✓ Wrote %d lifecycle rule(s) for bucket %s to %s (expire after %d days)\n",
+		len(plan.Rules), bucket, outPath, plan.Rules[0].ExpirationDays)
+	return nil
+}
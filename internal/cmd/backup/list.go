@@ -3,12 +3,15 @@ package backup
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 
 	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
 )
 
 func runBackupList(cmd *cobra.Command, args []string) error {
@@ -26,6 +29,13 @@ func runBackupList(cmd *cobra.Command, args []string) error {
 	backupManager := backup.NewBackupManager(nil, minioConfig)
 
 	prefix := mustGetStringFlag(cmd, "prefix")
+	switch groupBy := mustGetStringFlag(cmd, "group-by"); groupBy {
+	case "":
+	case "site":
+		return listBackupsBySite(cmd, backupManager, prefix)
+	default:
+		return fmt.Errorf("invalid --group-by: %s (use 'site')", groupBy)
+	}
 	limit := mustGetIntFlag(cmd, "limit")
 	if limit == 0 {
 		limit = 100 // default value
@@ -35,6 +45,7 @@ func runBackupList(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to list backups: %w", err)
 	}
+	exportSiteFreshness(cmd, backupManager)
 
 	if len(objs) == 0 {
 		fmt.Println("No objects found")
@@ -46,13 +57,61 @@ func runBackupList(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to marshal objects to JSON: %w", err)
 		}
-		fmt.Println(string(b))
+		fmt.Fprintln(output.Data(), string(b))
 		return nil
 	}
 
 	for _, o := range objs {
-		fmt.Printf("%s\t%d\t%s\n", o.Key, o.Size, o.LastModified.Format(time.RFC3339))
+		fmt.Printf("%s\t%d\t%s\n", o.Key, o.Size, backup.InTimezone(backup.BackupTime(o)).Format(time.RFC3339))
 	}
 
 	return nil
 }
+
+// listBackupsBySite prints the object count, size and oldest and newest
+// backup of every site under prefix, from the bucket summary unless
+// --no-cache is set or the summary cannot answer for prefix.
+func listBackupsBySite(cmd *cobra.Command, manager *backup.BackupManager, prefix string) error {
+	summary, err := bucketSummary(cmd, manager, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	sites := summary.BySite(prefix)
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(sites, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal sites to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+		return nil
+	}
+	if len(sites) == 0 {
+		fmt.Println("No objects found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SITE\tOBJECTS\tSIZE\tOLDEST\tNEWEST")
+	for _, s := range sites {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", s.Site, s.Objects, s.Bytes,
+			backup.InTimezone(s.Oldest).Format(time.RFC3339), backup.InTimezone(s.Newest).Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// bucketSummary returns the bucket summary for prefix, or a summary of a
+// full listing of prefix with --no-cache or when the stored summary cannot
+// answer for it.
+func bucketSummary(cmd *cobra.Command, manager *backup.BackupManager, prefix string) (*backup.BucketSummary, error) {
+	if !mustGetBoolFlag(cmd, "no-cache") {
+		summary, err := manager.BucketSummary(mustGetDurationFlag(cmd, "summary-max-age"))
+		if err != nil {
+			return nil, err
+		}
+		if summary.Covers(prefix) {
+			return summary, nil
+		}
+		fmt.Fprintf(os.Stderr, "The bucket summary covers whole directories only; listing %q...\n", prefix)
+	}
+	return manager.SummarizeBackups(prefix)
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// maintenanceManager loads --env and returns a manager for the bucket that
+// holds the maintenance flags.
+func maintenanceManager(cmd *cobra.Command) (*backup.BackupManager, error) {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return nil, fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewBackupManager(nil, minioConfig), nil
+}
+
+func runBackupMaintenanceSet(cmd *cobra.Command, args []string) error {
+	manager, err := maintenanceManager(cmd)
+	if err != nil {
+		return err
+	}
+	entry := backup.MaintenanceEntry{
+		Site:   args[0],
+		Reason: mustGetStringFlag(cmd, "reason"),
+		SetAt:  time.Now().UTC(),
+	}
+	if until := mustGetStringFlag(cmd, "until"); until != "" {
+		if entry.Until, err = backup.ParseMaintenanceUntil(until); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		if !entry.Until.After(time.Now()) {
+			return fmt.Errorf("--until %s is in the past", until)
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		entry.SetBy = host
+	}
+	if mustGetBoolFlag(cmd, "dry-run") {
+		fmt.Printf("[DRY RUN] Would put %s in maintenance %s\n", entry.Site, entry)
+		return nil
+	}
+	if err := manager.SetMaintenance(entry); err != nil {
+		return err
+	}
+	fmt.Printf("🚧 %s is in maintenance %s; create, prune and migrate will skip it\n", entry.Site, entry)
+	return nil
+}
+
+func runBackupMaintenanceClear(cmd *cobra.Command, args []string) error {
+	manager, err := maintenanceManager(cmd)
+	if err != nil {
+		return err
+	}
+	for _, site := range args {
+		if mustGetBoolFlag(cmd, "dry-run") {
+			fmt.Printf("[DRY RUN] Would take %s out of maintenance\n", site)
+			continue
+		}
+		if err := manager.ClearMaintenance(site); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s is out of maintenance\n", site)
+	}
+	return nil
+}
+
+func runBackupMaintenanceList(cmd *cobra.Command, args []string) error {
+	manager, err := maintenanceManager(cmd)
+	if err != nil {
+		return err
+	}
+	entries, err := manager.ListMaintenance()
+	if err != nil {
+		return err
+	}
+	if !mustGetBoolFlag(cmd, "all") {
+		now := time.Now()
+		active := entries[:0]
+		for _, e := range entries {
+			if e.Active(now) {
+				active = append(active, e)
+			}
+		}
+		entries = active
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		if entries == nil {
+			entries = []backup.MaintenanceEntry{}
+		}
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No sites are in maintenance.")
+		return nil
+	}
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SITE\tUNTIL\tREASON\tSET BY\tSET AT")
+	now := time.Now()
+	for _, e := range entries {
+		until := "cleared"
+		if !e.Until.IsZero() {
+			until = backup.FormatTime(e.Until, "2006-01-02 15:04")
+			if !e.Active(now) {
+				until += " (expired)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Site, until, e.Reason, e.SetBy, backup.FormatTime(e.SetAt, "2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
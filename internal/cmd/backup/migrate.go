@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -26,6 +27,7 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 	olderThan := mustGetDurationFlag(cmd, "older-than")
 	deleteAfter := mustGetBoolFlag(cmd, "delete-after")
 	limit := mustGetIntFlag(cmd, "limit")
+	drainStaging := mustGetBoolFlag(cmd, "drain-staging")
 
 	// Validate mutually exclusive flags
 	strategyCount := 0
@@ -41,12 +43,15 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 	if olderThan > 0 {
 		strategyCount++
 	}
+	if drainStaging {
+		strategyCount++
+	}
 
 	if strategyCount == 0 {
-		return fmt.Errorf("must specify one of: --object, --count, --percent, or --older-than")
+		return fmt.Errorf("must specify one of: --object, --count, --percent, --older-than, or --drain-staging")
 	}
 	if strategyCount > 1 {
-		return fmt.Errorf("only one of --object, --count, --percent, or --older-than can be specified")
+		return fmt.Errorf("only one of --object, --count, --percent, --older-than, or --drain-staging can be specified")
 	}
 
 	// Get Minio configuration
@@ -65,18 +70,9 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("AWS Glacier vault not configured (set AWS_VAULT environment variable or --aws-vault flag)")
 	}
 
-	// Validate required AWS configuration
-	if !dryRun {
-		if awsConfig.AccessKey == "" {
-			return fmt.Errorf("aws-access-key is required for migration")
-		}
-		if awsConfig.SecretKey == "" {
-			return fmt.Errorf("aws-secret-access-key is required for migration")
-		}
-	}
-
 	// Create backup manager
 	manager := backup.NewBackupManagerWithAWS(nil, minioConfig, awsConfig)
+	manager.SetDryRun(dryRun)
 
 	// Set verbosity level
 	logLevel, _ := cmd.Flags().GetInt("log-level")
@@ -86,6 +82,21 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		verbosity = 1 + vflag // -v=2, -vv=3, -vvv=4, -vvvv=5
 	}
 	manager.SetVerbosity(verbosity)
+	manager.SetPendingDeletesFile(pendingDeletesPath(cmd))
+	if err := applyTempBudget(cmd, manager); err != nil {
+		return err
+	}
+	if err := applyFleetResidency(cmd, "", manager); err != nil {
+		return err
+	}
+
+	staged, err := applyStaging(cmd, manager)
+	if err != nil {
+		return err
+	}
+	if drainStaging && !staged {
+		return fmt.Errorf("--drain-staging needs --staging-prefix or --staging-profile")
+	}
 
 	// Display configuration
 	fmt.Println("===========================================")
@@ -110,8 +121,13 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Strategy:        Migrate oldest %.1f%% of backups\n", percent)
 	} else if olderThan > 0 {
 		fmt.Printf("Strategy:        Migrate backups older than %s\n", olderThan)
+	} else if drainStaging {
+		fmt.Println("Strategy:        Archive objects left in the staging tier")
 	}
-	if deleteAfter {
+	if staged {
+		fmt.Printf("Staging:         %s (verified copy, then Glacier upload in the background)\n", manager.StagingLocation())
+		fmt.Println("Delete After:    YES (staged backups leave Minio once their copy is verified)")
+	} else if deleteAfter {
 		fmt.Println("Delete After:    YES (will delete from Minio after successful migration)")
 	} else {
 		fmt.Println("Delete After:    NO (will keep in Minio)")
@@ -119,6 +135,17 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 	fmt.Println("===========================================")
 	fmt.Println()
 
+	if drainStaging {
+		if dryRun {
+			fmt.Println("✓ Dry run complete. Staged backups were not archived.")
+			return nil
+		}
+		manager.StartStagingDrain()
+		err := manager.WaitStaging()
+		recordBackupRun(cmd, "migrate-aws", manager)
+		return err
+	}
+
 	// Select backups to migrate based on strategy
 	var toMigrate []backup.ObjectInfo
 
@@ -145,7 +172,7 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Found object: %s (%.2f MB, %s)\n\n",
 			objs[0].Key,
 			float64(objs[0].Size)/(1024*1024),
-			objs[0].LastModified.Format("2006-01-02 15:04:05"))
+			backup.FormatTime(objs[0].LastModified, "2006-01-02 15:04:05"))
 	} else {
 		// List backups from Minio
 		fmt.Println("Fetching backups from Minio...")
@@ -153,6 +180,21 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to list backups: %w", err)
 		}
+		if objs, err = manager.ExcludeMaintenance(objs); err != nil {
+			return fmt.Errorf("failed to read maintenance flags: %w", err)
+		}
+		if objs, err = manager.ExcludePendingDeletes(objs); err != nil {
+			return fmt.Errorf("failed to read pending deletes: %w", err)
+		}
+		if staged {
+			hot := objs[:0]
+			for _, obj := range objs {
+				if !manager.IsStagedKey(obj.Key) {
+					hot = append(hot, obj)
+				}
+			}
+			objs = hot
+		}
 
 		if len(objs) == 0 {
 			fmt.Println("No backups found matching criteria.")
@@ -203,7 +245,7 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 			i+1,
 			obj.Key,
 			float64(obj.Size)/(1024*1024),
-			obj.LastModified.Format("2006-01-02 15:04:05"))
+			backup.FormatTime(obj.LastModified, "2006-01-02 15:04:05"))
 		totalSize += obj.Size
 	}
 	fmt.Println("-------------------------------------------")
@@ -222,18 +264,28 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 	for i, obj := range toMigrate {
 		fmt.Printf("\n[%d/%d] Migrating: %s (%.2f MB)\n", i+1, len(toMigrate), obj.Key, float64(obj.Size)/(1024*1024))
 
-		// Download from Minio
-		reader, err := manager.DownloadBackup(obj.Key)
-		if err != nil {
-			fmt.Printf("   ❌ Failed to download from Minio: %v\n", err)
-			failedCount++
+		if staged {
+			if err := manager.StageObject(obj); err != nil {
+				fmt.Printf("   ❌ Failed to stage: %v\n", err)
+				failedCount++
+				continue
+			}
+			migratedCount++
+			migratedSize += obj.Size
+			fmt.Printf("   ✓ Staged to %s and deleted from Minio\n", manager.StagingLocation())
+			manager.StartStagingDrain()
 			continue
 		}
 
-		// Upload to AWS Glacier
-		err = manager.UploadToAWS(obj.Key, reader, obj.Size)
+		// Stream from Minio to AWS Glacier one part at a time
+		stats, err := manager.MigrateObjectToGlacier(obj.Key, obj.Size)
 		if err != nil {
-			fmt.Printf("   ❌ Failed to upload to AWS Glacier: %v\n", err)
+			fmt.Printf("   ❌ Failed to migrate to AWS Glacier: %v\n", err)
+			if errors.Is(err, backup.ErrGlacierChecksumMismatch) {
+				fmt.Printf("   ℹ️  Checksum mismatch recorded in the ledger; Minio copy kept\n")
+			} else {
+				fmt.Printf("   ℹ️  Uploaded parts are kept; re-run to resume\n")
+			}
 			failedCount++
 			continue
 		}
@@ -246,6 +298,7 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 			fmt.Printf("   Deleting from Minio...\n")
 			if err := manager.DeleteObjects([]string{obj.Key}); err != nil {
 				fmt.Printf("   ⚠️  Failed to delete from Minio: %v\n", err)
+				manager.QueuePendingDelete(obj, stats.ArchiveID, err)
 			} else {
 				fmt.Printf("   ✓ Deleted from Minio\n")
 			}
@@ -254,20 +307,35 @@ func runBackupMigrateAWS(cmd *cobra.Command, args []string) error {
 		fmt.Printf("   ✓ Migration complete\n")
 	}
 
+	var stagingErr error
+	if staged {
+		fmt.Println("\nWaiting for staged backups to finish archiving...")
+		stagingErr = manager.WaitStaging()
+	}
+
+	recordBackupRun(cmd, "migrate-aws", manager)
+
 	// Summary
 	fmt.Println("\n===========================================")
 	fmt.Println("Migration Summary")
 	fmt.Println("===========================================")
 	fmt.Printf("Total backups:     %d\n", len(toMigrate))
-	fmt.Printf("Migrated:          %d (%.2f MB)\n", migratedCount, float64(migratedSize)/(1024*1024))
+	if staged {
+		fmt.Printf("Staged:            %d (%.2f MB)\n", migratedCount, float64(migratedSize)/(1024*1024))
+	} else {
+		fmt.Printf("Migrated:          %d (%.2f MB)\n", migratedCount, float64(migratedSize)/(1024*1024))
+	}
 	fmt.Printf("Failed:            %d\n", failedCount)
-	if deleteAfter {
+	if deleteAfter || staged {
 		fmt.Printf("Deleted from Minio: %d\n", migratedCount)
 	}
 	fmt.Println("===========================================")
 
 	if failedCount > 0 {
-		return fmt.Errorf("%d backup(s) failed to migrate", failedCount)
+		return backup.PartialFailure(fmt.Errorf("%d backup(s) failed to migrate", failedCount), migratedCount)
+	}
+	if stagingErr != nil {
+		return stagingErr
 	}
 
 	return nil
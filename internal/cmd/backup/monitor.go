@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -14,10 +15,12 @@ func runBackupMonitor(cmd *cobra.Command, args []string) error {
 	storageServer := mustGetStringFlag(cmd, "storage-server")
 	storagePath := mustGetStringFlag(cmd, "storage-path")
 	threshold := mustGetFloat64Flag(cmd, "threshold")
+	warnThreshold := mustGetFloat64Flag(cmd, "warn-threshold")
 	migratePercent := mustGetFloat64Flag(cmd, "migrate-percent")
 	dryRun := mustGetBoolFlag(cmd, "dry-run")
 	showMounts := mustGetBoolFlag(cmd, "show-mounts")
 	forceDelete := mustGetBoolFlag(cmd, "force-delete")
+	fairPerSite := mustGetIntFlag(cmd, "fair-per-site")
 
 	// Create SSH client if storage server specified
 	var sshClient *auth.SSHClient
@@ -59,6 +62,14 @@ func runBackupMonitor(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if fairPerSite < 0 {
+		return fmt.Errorf("--fair-per-site must not be negative")
+	}
+
+	if warnThreshold > 0 && warnThreshold >= threshold {
+		return fmt.Errorf("--warn-threshold (%.1f) must be below --threshold (%.1f)", warnThreshold, threshold)
+	}
+
 	// Validate storage server is provided
 	if storageServer == "" {
 		return fmt.Errorf("storage-server is required (use --storage-server or set STORAGE_SERVER_ADDR environment variable)")
@@ -97,17 +108,11 @@ func runBackupMonitor(cmd *cobra.Command, args []string) error {
 	if awsConfig.Vault == "" {
 		return fmt.Errorf("aws-vault is required for migration")
 	}
-	if !dryRun {
-		if awsConfig.AccessKey == "" {
-			return fmt.Errorf("aws-access-key is required for migration")
-		}
-		if awsConfig.SecretKey == "" {
-			return fmt.Errorf("aws-secret-access-key is required for migration")
-		}
-	}
 
 	// Create backup manager with SSH client for remote storage capacity checking
 	manager := backup.NewBackupManagerWithAWS(sshClient, &minioConfig, awsConfig)
+	manager.SetDryRun(dryRun)
+	applyCommandAudit(cmd, manager)
 
 	// Set verbosity level
 	logLevel, _ := cmd.Flags().GetInt("log-level")
@@ -117,6 +122,24 @@ func runBackupMonitor(cmd *cobra.Command, args []string) error {
 		verbosity = 1 + vflag // -v=2, -vv=3, -vvv=4, -vvvv=5
 	}
 	manager.SetVerbosity(verbosity)
+	manager.SetMigrationFairness(fairPerSite)
+	if err := applyFleetResidency(cmd, "", manager); err != nil {
+		return err
+	}
+	manager.SetPendingDeletesFile(pendingDeletesPath(cmd))
+	if err := applyTempBudget(cmd, manager); err != nil {
+		return err
+	}
+	staged, err := applyStaging(cmd, manager)
+	if err != nil {
+		return err
+	}
+	manager.SetCapacityAlerts(&backup.CapacityAlertConfig{
+		WarnThreshold: warnThreshold,
+		WebhookURL:    mustGetStringFlag(cmd, "alert-webhook"),
+		MetricsFile:   mustGetStringFlag(cmd, "metrics-file"),
+		Source:        storageServer,
+	})
 
 	// Run monitoring and migration
 	fmt.Println("===========================================")
@@ -129,12 +152,31 @@ func runBackupMonitor(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("Storage Server:    %s\n", storageServer)
 	fmt.Printf("Storage Path:      %s\n", storagePath)
+	if warnThreshold > 0 {
+		fmt.Printf("Warn Threshold:    %.1f%%\n", warnThreshold)
+	}
 	fmt.Printf("Threshold:         %.1f%%\n", threshold)
 	fmt.Printf("Migrate Percent:   %.1f%%\n", migratePercent)
+	if fairPerSite > 0 {
+		fmt.Printf("Fair Per Site:     %d per round\n", fairPerSite)
+	}
 	fmt.Printf("Force Delete:      %v\n", forceDelete)
 	fmt.Printf("Minio Bucket:      %s\n", minioConfig.Bucket)
 	fmt.Printf("AWS Glacier Vault: %s\n", awsConfig.Vault)
+	if staged {
+		fmt.Printf("Staging:           %s\n", manager.StagingLocation())
+	}
 	fmt.Println("===========================================")
 
-	return manager.MonitorAndMigrateIfNeeded(storagePath, threshold, migratePercent, dryRun, forceDelete)
+	err = manager.MonitorAndMigrateIfNeeded(storagePath, threshold, migratePercent, dryRun, forceDelete)
+	if staged {
+		// Background Glacier uploads outlive the capacity loop; finish them
+		// before recording the run.
+		err = errors.Join(err, manager.WaitStaging())
+	}
+	recordBackupRun(cmd, "monitor", manager)
+	if !dryRun {
+		exportSiteFreshness(cmd, manager)
+	}
+	return err
 }
@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// profilePermissions are the permissions of the backup profile applied at
+// startup, and permissionsProfile is its name.
+var (
+	profilePermissions *backup.BackupProfilePermissions
+	permissionsProfile string
+)
+
+// operationGate ties a command, or one of its boolean flags, to a gated
+// operation.
+type operationGate struct {
+	// flag gates the operation only when set; empty gates the command.
+	flag string
+	op   string
+}
+
+var operationGates = map[*cobra.Command][]operationGate{}
+
+func initOperationGates() {
+	operationGates[backupCreateCmd] = []operationGate{{op: backup.OpCreate}, {flag: "prune", op: backup.OpPrune}, {flag: "delete", op: backup.OpDelete}}
+	operationGates[backupDeleteCmd] = []operationGate{{op: backup.OpDelete}}
+	operationGates[backupMonitorCmd] = []operationGate{{op: backup.OpMigrate}, {flag: "force-delete", op: backup.OpDelete}}
+	operationGates[backupMigrateAWSCmd] = []operationGate{{op: backup.OpMigrate}}
+	operationGates[backupRestoreDBCmd] = []operationGate{{op: backup.OpRestore}}
+	operationGates[backupRestoreCmd] = []operationGate{{op: backup.OpRestore}}
+	operationGates[backupGlacierRetrieveCmd] = []operationGate{{op: backup.OpRestore}}
+	operationGates[backupRetryPendingCmd] = []operationGate{{op: backup.OpCreate}}
+	operationGates[backupReconcileCmd] = []operationGate{{op: backup.OpMigrate}}
+	operationGates[backupAWSSetupApplyCmd] = []operationGate{{op: backup.OpMigrate}}
+	operationGates[backupGCCmd] = []operationGate{{op: backup.OpPrune}, {flag: "prune-stale", op: backup.OpDelete}}
+	operationGates[backupImportCmd] = []operationGate{{op: backup.OpCreate}, {flag: "delete-source", op: backup.OpDelete}}
+	operationGates[backupBenchCmd] = []operationGate{{op: backup.OpCreate}}
+	operationGates[backupSyncCmd] = []operationGate{{op: backup.OpSync}}
+	operationGates[backupMaintenanceSetCmd] = []operationGate{{op: backup.OpMaintenance}}
+	operationGates[backupMaintenanceClearCmd] = []operationGate{{op: backup.OpMaintenance}}
+	operationGates[backupPrunePlanExecuteCmd] = []operationGate{{op: backup.OpPrune}}
+	operationGates[backupNormalizeKeysCmd] = []operationGate{{op: backup.OpMigrate}}
+}
+
+// checkPermissions refuses gated operations denied by --read-only or the
+// profile's permissions. Dry runs change nothing and are always allowed, so
+// under --read-only or a read-only profile a command with --dry-run is run as
+// a dry run instead of being refused.
+func checkPermissions(cmd *cobra.Command, args []string) error {
+	gates := operationGates[cmd]
+	if len(gates) == 0 {
+		return nil
+	}
+	hasDryRun := cmd.Flags().Lookup("dry-run") != nil
+	if hasDryRun && mustGetBoolFlag(cmd, "dry-run") {
+		return nil
+	}
+	readOnly := mustGetBoolFlag(cmd, "read-only")
+	for _, g := range gates {
+		if g.flag != "" && !mustGetBoolFlag(cmd, g.flag) {
+			continue
+		}
+		var by string
+		switch {
+		case readOnly:
+			by = "--read-only is set (env: BACKUP_READ_ONLY)"
+		case profilePermissions != nil && profilePermissions.ReadOnly:
+			by = fmt.Sprintf("profile %q is read-only", permissionsProfile)
+		case !profilePermissions.Allows(g.op):
+			return deniedError(g.op, fmt.Sprintf("profile %q denies it", permissionsProfile), hasDryRun)
+		default:
+			continue
+		}
+		if !hasDryRun {
+			return deniedError(g.op, by, false)
+		}
+		if err := cmd.Flags().Set("dry-run", "true"); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "🔒 %s: running '%s' as a dry run; nothing will be changed\n", by, cmd.CommandPath())
+		return nil
+	}
+	return nil
+}
+
+func deniedError(op, by string, hasDryRun bool) error {
+	hint := "list, read and verify commands are still available"
+	if hasDryRun {
+		hint += ", and --dry-run shows what would happen"
+	}
+	return fmt.Errorf("%w; %s", &backup.OperationDeniedError{Op: op, By: by}, hint)
+}
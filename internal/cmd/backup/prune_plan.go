@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// prunePlanManager loads --env and returns a manager for the bucket that
+// holds the prune plans.
+func prunePlanManager(cmd *cobra.Command) (*backup.BackupManager, error) {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return nil, fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewBackupManager(nil, minioConfig), nil
+}
+
+func runBackupPrunePlanCreate(cmd *cobra.Command, args []string) error {
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	policy := backup.RetentionPolicy{Remainder: mustGetIntFlag(cmd, "remainder")}
+	if policy.Remainder < 0 {
+		return fmt.Errorf("--remainder must be >= 0")
+	}
+	if policy.Smart, err = smartRetentionFromFlags(cmd); err != nil {
+		return err
+	}
+	fmt.Printf("Planning prune of %s: %s\n", mustGetStringFlag(cmd, "prefix"), policy)
+
+	maintenance, err := bm.MaintenanceSites(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to read maintenance flags: %w", err)
+	}
+	skip := func(site string) bool {
+		entry, ok := maintenance[site]
+		if ok {
+			fmt.Printf("Site %s: skipped (maintenance %s)\n", site, entry)
+		}
+		return ok
+	}
+	host, _ := os.Hostname()
+	plan := backup.NewPrunePlan(host, time.Now().UTC())
+	if err := bm.PlanPrune(plan, mustGetStringFlag(cmd, "prefix"), policy, skip); err != nil {
+		return err
+	}
+	return runPrunePlan(bm, plan, true)
+}
+
+func runBackupPrunePlanList(cmd *cobra.Command, args []string) error {
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	plans, err := bm.ListPrunePlans()
+	if err != nil {
+		return err
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		if plans == nil {
+			plans = []*backup.PrunePlan{}
+		}
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(plans)
+	}
+	if len(plans) == 0 {
+		fmt.Println("No prune plans.")
+		return nil
+	}
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tHOST\tCREATED\tSTATUS\tOBJECTS\tPENDING\tMB")
+	for _, p := range plans {
+		pending, _ := p.Pending()
+		var size int64
+		for _, e := range p.Entries {
+			size += e.Size
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%.2f\n", p.ID, p.Host, backup.FormatTime(p.CreatedAt, "2006-01-02 15:04"),
+			p.Status, len(p.Entries), pending, float64(size)/(1024*1024))
+	}
+	return w.Flush()
+}
+
+func runBackupPrunePlanShow(cmd *cobra.Command, args []string) error {
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	plan, err := bm.LoadPrunePlan(args[0])
+	if err != nil {
+		return err
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	fmt.Printf("Plan %s (host %s), created %s: %s\n", plan.ID, plan.Host, backup.FormatTime(plan.CreatedAt, "2006-01-02 15:04"), plan.Status)
+	if !plan.FinishedAt.IsZero() {
+		fmt.Printf("Last executed %s to %s\n", backup.FormatTime(plan.StartedAt, "2006-01-02 15:04:05"), backup.FormatTime(plan.FinishedAt, "15:04:05"))
+	}
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nSITE\tKEY\tMB\tETAG\tSTATE")
+	for _, e := range plan.Entries {
+		state := "pending"
+		switch {
+		case !e.DeletedAt.IsZero():
+			state = "deleted " + backup.FormatTime(e.DeletedAt, "2006-01-02 15:04")
+		case e.Error != "":
+			state = "left: " + e.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\n", e.Site, e.Key, float64(e.Size)/(1024*1024), e.ETag, state)
+	}
+	return w.Flush()
+}
+
+func runBackupPrunePlanExecute(cmd *cobra.Command, args []string) error {
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	plan, err := bm.LoadPrunePlan(args[0])
+	if err != nil {
+		return err
+	}
+	if plan.Status == backup.PrunePlanCompleted {
+		fmt.Printf("Prune plan %s already completed %s\n", plan.ID, backup.FormatTime(plan.FinishedAt, "2006-01-02 15:04"))
+		return nil
+	}
+	objects, bytes := plan.Pending()
+	if mustGetBoolFlag(cmd, "dry-run") {
+		fmt.Printf("[DRY RUN] Would execute prune plan %s: %d pending object(s), %.2f MB\n", plan.ID, objects, float64(bytes)/(1024*1024))
+		for _, e := range plan.Entries {
+			if e.DeletedAt.IsZero() {
+				fmt.Printf("  - %s (%.2f MB)\n", e.Key, float64(e.Size)/(1024*1024))
+			}
+		}
+		return nil
+	}
+	fmt.Printf("Executing prune plan %s: %d pending object(s), %.2f MB\n", plan.ID, objects, float64(bytes)/(1024*1024))
+	if err := bm.ExecutePrunePlan(plan); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Prune plan %s completed\n", plan.ID)
+	return nil
+}
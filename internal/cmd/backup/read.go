@@ -1,7 +1,9 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
 
 	"github.com/joho/godotenv"
@@ -28,6 +30,9 @@ func runBackupRead(cmd *cobra.Command, args []string) error {
 	}
 
 	backupManager := backup.NewBackupManager(nil, minioConfig)
+	if err := applyReadCache(cmd, backupManager); err != nil {
+		return err
+	}
 
 	// If object name not provided, optionally resolve latest by prefix
 	if objectName == "" {
@@ -54,5 +59,9 @@ func runBackupRead(cmd *cobra.Command, args []string) error {
 		outputPath = filepath.Base(objectName)
 	}
 
-	return backupManager.ReadBackup(objectName, outputPath)
+	err = backupManager.ReadBackup(objectName, outputPath)
+	if errors.Is(err, backup.ErrObjectNotFound) {
+		return fmt.Errorf("%w\n💡 Run 'backup list --prefix %s' to see the available backups", err, path.Dir(objectName)+"/")
+	}
+	return err
 }
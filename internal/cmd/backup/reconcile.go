@@ -0,0 +1,60 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addPendingDeletesFlag registers --pending-deletes-file on a command that
+// migrates or reconciles backups.
+func addPendingDeletesFlag(cmd *cobra.Command) {
+	cmd.Flags().String("pending-deletes-file", getEnvWithDefault("BACKUP_PENDING_DELETES_FILE", ""), "List of migrated objects whose delete from Minio failed, for 'backup reconcile' (default: ~/.ciwg/pending-deletes.jsonl, env: BACKUP_PENDING_DELETES_FILE)")
+}
+
+// pendingDeletesPath returns the --pending-deletes-file of cmd, or the
+// default list.
+func pendingDeletesPath(cmd *cobra.Command) string {
+	if path := mustGetStringFlag(cmd, "pending-deletes-file"); path != "" {
+		return path
+	}
+	return backup.DefaultPendingDeletesPath()
+}
+
+func runBackupReconcile(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	manager := backup.NewBackupManager(nil, minioConfig)
+
+	path := pendingDeletesPath(cmd)
+	opts := &backup.ReconcileOptions{DryRun: mustGetBoolFlag(cmd, "dry-run")}
+	manager.SetDryRun(opts.DryRun)
+	res, err := manager.ReconcilePendingDeletes(path, opts)
+	if err != nil {
+		return err
+	}
+	if res.Deleted+res.Gone+res.Changed+res.Failed+res.Skipped == 0 {
+		fmt.Println("No pending deletes.")
+		return nil
+	}
+	fmt.Printf("\nReconciled: %d deleted (%.2f MB freed), %d already gone, %d changed, %d failed, %d left for later\n",
+		res.Deleted, float64(res.FreedBytes)/(1024*1024), res.Gone, res.Changed, res.Failed, res.Skipped)
+	if res.Changed > 0 {
+		fmt.Printf("Objects that changed since they were archived stay in Minio and on the list; remove them from %s once checked.\n", path)
+	}
+	if res.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d pending delete(s) failed again", res.Failed), res.Deleted+res.Gone)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// preRunBackup runs before every backup subcommand: it sets the crypto
+// policy, canonical timezone, delete hold and low-memory mode, cleans up
+// after crashed runs, then applies the permission gates.
+func preRunBackup(cmd *cobra.Command, args []string) error {
+	if err := applyCryptoPolicy(cmd); err != nil {
+		return err
+	}
+	if err := backup.SetTimezone(mustGetStringFlag(cmd, "timezone")); err != nil {
+		return fmt.Errorf("invalid --timezone: %w", err)
+	}
+	if err := applyDeleteHold(cmd); err != nil {
+		return err
+	}
+	applyLowMemory(cmd)
+	runRecoveryScan(cmd)
+	return checkPermissions(cmd, args)
+}
+
+// applyCryptoPolicy sets the process-wide checksum algorithm and FIPS mode
+// from --checksum and --fips.
+func applyCryptoPolicy(cmd *cobra.Command) error {
+	policy := backup.CryptoPolicy{
+		Checksum: mustGetStringFlag(cmd, "checksum"),
+		FIPS:     mustGetBoolFlag(cmd, "fips"),
+	}
+	if err := backup.SetCryptoPolicy(policy); err != nil {
+		return fmt.Errorf("invalid --checksum: %w", err)
+	}
+	if policy.FIPS && !backup.FIPSModuleEnabled() {
+		fmt.Fprintln(os.Stderr, "⚠️  Warning: --fips without GODEBUG=fips140=on; the Go runtime is not using its FIPS 140-3 module")
+	}
+	return nil
+}
+
+// applyDeleteHold sets the process-wide delete hold-back window from
+// --delete-hold. It can be raised freely, but lowering it below
+// backup.DefaultDeleteHold takes --allow-young-deletes, which switches the
+// hold off; the environment alone can never weaken it.
+func applyDeleteHold(cmd *cobra.Command) error {
+	if mustGetBoolFlag(cmd, "allow-young-deletes") {
+		backup.SetDeleteHold(0)
+		return nil
+	}
+	hold := mustGetDurationFlag(cmd, "delete-hold")
+	if hold < backup.DefaultDeleteHold {
+		return fmt.Errorf("--delete-hold %s is below the %s minimum; pass --allow-young-deletes to delete recent backups", hold, backup.DefaultDeleteHold)
+	}
+	backup.SetDeleteHold(hold)
+	return nil
+}
+
+// applyLowMemory sets process-wide low-memory mode from --low-memory and,
+// unless GOMEMLIMIT already does, holds the Go heap to
+// backup.LowMemoryHeapLimit so the collector runs before the host runs out.
+func applyLowMemory(cmd *cobra.Command) {
+	on := mustGetBoolFlag(cmd, "low-memory")
+	backup.SetLowMemory(on)
+	if on && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(backup.LowMemoryHeapLimit)
+	}
+}
+
+// runRecoveryScan removes the temp files, partial state files and stale
+// sockets earlier runs left behind, unless --no-recovery-scan is set. The
+// report goes to stderr so data on stdout stays clean.
+func runRecoveryScan(cmd *cobra.Command) {
+	if mustGetBoolFlag(cmd, "no-recovery-scan") {
+		return
+	}
+	socket := os.Getenv("BACKUP_STATUS_SOCKET")
+	if cmd.Flags().Lookup("status-socket") != nil {
+		socket = mustGetStringFlag(cmd, "status-socket")
+	}
+	report := backup.RecoveryScan(backup.RecoveryOptions{
+		StateDir:     backup.DefaultRecoveryStateDir(),
+		StatusSocket: socket,
+		TempAge:      mustGetDurationFlag(cmd, "recovery-temp-age"),
+	})
+	report.Write(os.Stderr)
+}
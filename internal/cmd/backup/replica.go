@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// addReplicaFlags registers the DR replica flags on commands that delete
+// from the primary bucket.
+func addReplicaFlags(c *cobra.Command) {
+	c.Flags().String("replica-profile", getEnvWithDefault("BACKUP_REPLICA_PROFILE", ""), "Storage profile of the DR replica bucket (env: BACKUP_REPLICA_PROFILE)")
+	c.Flags().Bool("propagate-deletes", getEnvBoolWithDefault("BACKUP_PROPAGATE_DELETES", false), "Also delete pruned backups from the replica; leave unset for a write-once DR copy (env: BACKUP_PROPAGATE_DELETES)")
+	if c.Flags().Lookup("profiles-file") == nil {
+		c.Flags().String("profiles-file", getEnvWithDefault("BACKUP_MINIO_PROFILES", ""), "Storage profiles file (default: ~/.ciwg/minio-profiles.yaml, env: BACKUP_MINIO_PROFILES)")
+	}
+}
+
+// loadReplica returns the manager of --replica-profile, or nil when no
+// replica is configured.
+func loadReplica(cmd *cobra.Command) (*backup.BackupManager, string, error) {
+	name := mustGetStringFlag(cmd, "replica-profile")
+	if name == "" {
+		return nil, "", nil
+	}
+	profilesFile := mustGetStringFlag(cmd, "profiles-file")
+	if profilesFile == "" {
+		profilesFile = backup.DefaultMinioProfilesPath()
+	}
+	cfg, err := backup.LoadMinioProfile(profilesFile, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load replica profile: %w", err)
+	}
+	return backup.NewBackupManager(nil, cfg), name, nil
+}
+
+// applyReplica registers the configured replica with bm.
+func applyReplica(cmd *cobra.Command, bm *backup.BackupManager) error {
+	replica, name, err := loadReplica(cmd)
+	if err != nil || replica == nil {
+		return err
+	}
+	bm.SetReplica(name, replica, mustGetBoolFlag(cmd, "propagate-deletes"))
+	return nil
+}
+
+func runBackupReconcileReplica(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	replica, name, err := loadReplica(cmd)
+	if err != nil {
+		return err
+	}
+	if replica == nil {
+		return fmt.Errorf("--replica-profile is required (or set BACKUP_REPLICA_PROFILE)")
+	}
+	writeOnce := !mustGetBoolFlag(cmd, "propagate-deletes")
+
+	primary := backup.NewBackupManager(nil, minioConfig)
+	diff, err := primary.CompareReplica(replica, mustGetStringFlag(cmd, "prefix"))
+	if err != nil {
+		return err
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		printReplicaDiff(diff, name, writeOnce)
+	}
+	if diff.Diverged(writeOnce) {
+		return fmt.Errorf("replica %s diverges from the primary under %q", name, diff.Prefix)
+	}
+	return nil
+}
+
+func printReplicaDiff(diff *backup.ReplicaDiff, name string, writeOnce bool) {
+	mb := func(n int64) float64 { return float64(n) / (1024 * 1024) }
+	fmt.Printf("Primary: %d object(s), replica %s: %d object(s) under %q\n",
+		diff.PrimaryObjects, name, diff.ReplicaObjects, diff.Prefix)
+
+	for _, o := range diff.MissingOnReplica {
+		fmt.Printf("  ✗ Not replicated: %s (%.2f MB)\n", o.Key, mb(o.Size))
+	}
+	for _, m := range diff.SizeMismatch {
+		fmt.Printf("  ✗ Size differs: %s (primary %d bytes, replica %d bytes)\n", m.Key, m.PrimarySize, m.ReplicaSize)
+	}
+	for _, o := range diff.OnlyOnReplica {
+		if writeOnce {
+			fmt.Printf("  ℹ️  Retained on replica: %s (%.2f MB)\n", o.Key, mb(o.Size))
+		} else {
+			fmt.Printf("  ✗ Deleted on primary only: %s (%.2f MB)\n", o.Key, mb(o.Size))
+		}
+	}
+
+	if !diff.Diverged(writeOnce) {
+		fmt.Println("✓ Replica is in sync")
+		return
+	}
+	if len(diff.MissingOnReplica) > 0 || len(diff.SizeMismatch) > 0 {
+		fmt.Println("💡 Run 'backup sync --source-profile <primary> --dest-profile " + name + "' to copy missing objects")
+	}
+	if !writeOnce && len(diff.OnlyOnReplica) > 0 {
+		fmt.Println("💡 Deletions were not propagated; remove them with 'backup delete' against the replica")
+	}
+}
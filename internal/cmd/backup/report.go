@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupReportPerformance(cmd *cobra.Command, args []string) error {
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+
+	records, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	group, err := loadGroup(cmd)
+	if err != nil {
+		return err
+	}
+	records = group.FilterRunRecords(records)
+
+	var since time.Time
+	if d := mustGetDurationFlag(cmd, "since"); d > 0 {
+		since = time.Now().Add(-d)
+	}
+
+	summaries, err := backup.SummarizePerformance(records, mustGetStringFlag(cmd, "group-by"), since)
+	if err != nil {
+		return err
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal performance report to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+		return nil
+	}
+
+	if len(summaries) == 0 {
+		fmt.Printf("No upload statistics recorded in %s\n", historyPath)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tRUNS\tUPLOADS\tTOTAL MB\tMINIO MB/s\tGLACIER MB/s\tBUFFER s\tCHECKSUM s\tTREND")
+	for _, s := range summaries {
+		glacier, buffer, checksum := "-", "-", "-"
+		if s.GlacierUploads > 0 {
+			glacier = fmt.Sprintf("%.2f", s.AvgGlacierMBps)
+			buffer = fmt.Sprintf("%.1f", s.AvgBufferSeconds)
+			checksum = fmt.Sprintf("%.1f", s.AvgChecksumSeconds)
+		}
+		trend := "-"
+		if s.EarlierMinioMBps > 0 {
+			trend = fmt.Sprintf("%+.1f%%", s.TrendPercent)
+			if s.Degrading {
+				trend += " ⚠️  degrading"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%.2f\t%s\t%s\t%s\t%s\n",
+			s.Group, s.Runs, s.Uploads, float64(s.TotalBytes)/(1024*1024),
+			s.AvgMinioMBps, glacier, buffer, checksum, trend)
+	}
+	return w.Flush()
+}
+
+func runBackupReportCompliance(cmd *cobra.Command, args []string) error {
+	format := mustGetStringFlag(cmd, "format")
+	if format != "text" && format != "json" && format != "html" {
+		return fmt.Errorf("invalid --format %q (use text, json or html)", format)
+	}
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	history, err := backup.LoadRunRecords(historyPath)
+	if err != nil {
+		return err
+	}
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	group, err := loadGroup(cmd)
+	if err != nil {
+		return err
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	prefix := mustGetStringFlag(cmd, "prefix")
+	summary, err := bucketSummary(cmd, backup.NewBackupManager(nil, minioConfig), prefix)
+	if err != nil {
+		return err
+	}
+	latest := summary.LatestBackupTimes(prefix)
+	rep := backup.EvaluateCompliance(fleet, group, latest, history, time.Now())
+
+	w := output.Data()
+	if outPath := mustGetStringFlag(cmd, "out"); outPath != "" && outPath != "-" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", outPath, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal compliance report to JSON: %w", err)
+		}
+		fmt.Fprintln(w, string(b))
+	case "html":
+		if err := backup.WriteComplianceHTML(w, rep); err != nil {
+			return fmt.Errorf("failed to write compliance report: %w", err)
+		}
+	default:
+		if len(rep.Sites) == 0 {
+			fmt.Fprintln(w, "No site declares an rpo or rto in the fleet file")
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SITE\tRPO\tBACKUP AGE\tRPO STATUS\tRTO\tRESTORE TOOK\tRTO STATUS")
+		for _, c := range rep.Sites {
+			rpo, rto, age, took := "-", "-", "-", "-"
+			if c.RPO != "" {
+				rpo = c.RPO
+			}
+			if c.RTO != "" {
+				rto = c.RTO
+			}
+			if !c.LastBackup.IsZero() {
+				age = (time.Duration(c.BackupAgeSec) * time.Second).Round(time.Minute).String()
+			}
+			if !c.LastRestore.IsZero() {
+				took = (time.Duration(c.RestoreSeconds) * time.Second).Round(time.Second).String()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Site, rpo, age, complianceMark(c.RPOStatus),
+				rto, took, complianceMark(c.RTOStatus))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n%d site(s) compliant, %d in violation\n", rep.Compliant, rep.Violations)
+	}
+
+	if rep.Violations > 0 && mustGetBoolFlag(cmd, "fail-on-violation") {
+		return fmt.Errorf("%d site(s) miss their RPO or RTO", rep.Violations)
+	}
+	return nil
+}
+
+func complianceMark(status string) string {
+	switch status {
+	case backup.ComplianceOK:
+		return "✓ ok"
+	case backup.ComplianceViolated:
+		return "❌ VIOLATED"
+	case backup.ComplianceMissing:
+		return "⚠️  missing"
+	}
+	return "-"
+}
@@ -0,0 +1,75 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addResidencyFlags registers the data residency flags on a command that
+// writes backups to Minio or Glacier.
+func addResidencyFlags(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("fleet-file") == nil {
+		cmd.Flags().String("fleet-file", getEnvWithDefault("BACKUP_FLEET_FILE", ""), "YAML file with the residency of sites (default: ~/.ciwg/fleet.yaml, env: BACKUP_FLEET_FILE)")
+	}
+	cmd.Flags().String("minio-region", getEnvWithDefault("MINIO_REGION", ""), "Region of the Minio bucket for residency checks (default: as reported by the server, env: MINIO_REGION)")
+	cmd.Flags().String("residency-override", "", "Write backups where the residency of their site forbids it, recording each write with this reason in the residency audit log")
+	cmd.Flags().String("residency-audit-log", getEnvWithDefault("BACKUP_RESIDENCY_AUDIT_LOG", ""), "JSON-lines log of residency overrides (default: ~/.ciwg/residency-overrides.jsonl, env: BACKUP_RESIDENCY_AUDIT_LOG)")
+}
+
+// applyResidency makes managers check the residency of the sites of fleet
+// on every write; host names where the command runs in the override audit
+// log.
+func applyResidency(cmd *cobra.Command, fleet *backup.Fleet, host string, managers ...*backup.BackupManager) {
+	policy := fleet.ResidencyPolicy()
+	reason := strings.TrimSpace(mustGetStringFlag(cmd, "residency-override"))
+	if len(policy) == 0 {
+		if reason != "" {
+			fmt.Println("ℹ️  --residency-override has no effect: no site in the fleet file has a residency")
+		}
+		return
+	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	override := backup.ResidencyOverride{
+		Reason:   reason,
+		AuditLog: mustGetStringFlag(cmd, "residency-audit-log"),
+		Host:     host,
+		User:     residencyUser(),
+	}
+	if reason != "" {
+		fmt.Printf("⚠️  Residency override in effect (%s); writes outside a site's residency are logged\n", reason)
+	}
+	for _, m := range managers {
+		m.SetResidency(policy, override)
+	}
+}
+
+// applyFleetResidency is applyResidency for commands that do not otherwise
+// read the fleet file.
+func applyFleetResidency(cmd *cobra.Command, host string, managers ...*backup.BackupManager) error {
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	applyResidency(cmd, fleet, host, managers...)
+	return nil
+}
+
+// residencyUser names the person behind the command for the audit log: the
+// user that ran sudo, if any, otherwise the current user.
+func residencyUser() string {
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
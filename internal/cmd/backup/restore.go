@@ -0,0 +1,241 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupRestoreDB(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	container := mustGetStringFlag(cmd, "container")
+	if container == "" {
+		return fmt.Errorf("--container is required")
+	}
+
+	backupManager, closeManager, err := restoreManager(cmd, false)
+	if err != nil {
+		return err
+	}
+	defer closeManager()
+
+	objectName, err := resolveRestoreObject(cmd, backupManager, args)
+	if err != nil {
+		return err
+	}
+
+	f := newFlagReader(cmd)
+	options := &backup.RestoreDBOptions{
+		Container:        container,
+		ImportMethod:     f.str("import-method"),
+		SQLPath:          f.str("sql-path"),
+		SafetyExportDir:  f.str("safety-export-dir"),
+		SkipSafetyExport: f.boolean("skip-safety-export"),
+		RollbackFile:     f.str("rollback-file"),
+		Subsite:          f.integer("subsite"),
+		TablePrefix:      f.str("table-prefix"),
+		UploadsDir:       f.str("uploads-dir"),
+		Compat:           f.boolean("compat"),
+		DryRun:           f.boolean("dry-run"),
+	}
+	if err := f.err(); err != nil {
+		return err
+	}
+	return backupManager.RestoreDatabase(objectName, options)
+}
+
+func runBackupRestore(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	as := mustGetStringFlag(cmd, "as")
+	if mustGetBoolFlag(cmd, "rollback-last") {
+		if len(args) > 0 {
+			return fmt.Errorf("--rollback-last takes no object")
+		}
+		return runRestoreRollback(cmd, as)
+	}
+	if as == "" {
+		return fmt.Errorf("--as is required")
+	}
+	var rewrites []backup.SearchReplace
+	for _, v := range mustGetStringArrayFlag(cmd, "search-replace") {
+		sr, err := backup.ParseSearchReplace(v)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, sr)
+	}
+
+	backupManager, closeManager, err := restoreManager(cmd, false)
+	if err != nil {
+		return err
+	}
+	defer closeManager()
+
+	objectName, err := resolveRestoreObject(cmd, backupManager, args)
+	if err != nil {
+		return err
+	}
+
+	f := newFlagReader(cmd)
+	options := &backup.RestoreSiteOptions{
+		As:            as,
+		From:          f.str("from"),
+		TargetDir:     f.str("target-dir"),
+		DBName:        f.str("db-name"),
+		SearchReplace: rewrites,
+		RewriteMethod: f.str("rewrite-method"),
+		Container:     f.str("container"),
+		NoStart:       f.boolean("no-start"),
+		Replace:       f.boolean("replace"),
+		RollbackFile:  f.str("rollback-file"),
+		Compat:        f.boolean("compat"),
+		DryRun:        f.boolean("dry-run"),
+	}
+	noHistory := f.boolean("no-history")
+	if err := f.err(); err != nil {
+		return err
+	}
+	started := time.Now()
+	err = backupManager.RestoreSite(objectName, options)
+	if !options.DryRun && !noHistory {
+		recordRestore(cmd, objectName, as, started, err)
+	}
+	return err
+}
+
+// recordRestore appends the restore to the run history, where `backup report
+// compliance` measures it against the site's RTO.
+func recordRestore(cmd *cobra.Command, objectName, as string, started time.Time, restoreErr error) {
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	host := mustGetStringFlag(cmd, "host")
+	if host == "" {
+		host = "local"
+	}
+	rec := &backup.RunRecord{
+		ID:         backup.NewRunID(started),
+		Host:       host,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Kind:       backup.RunKindRestore,
+		Restore:    &backup.RestoreStats{Site: backup.ObjectSite(objectName), ObjectKey: objectName, As: as},
+	}
+	if restoreErr != nil {
+		rec.Failed = 1
+	} else {
+		rec.Succeeded = 1
+	}
+	if err := backup.AppendRunRecord(historyPath, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record restore in history: %v\n", err)
+	}
+}
+
+// restoreManager connects to --host (or the local Docker host with --local)
+// and returns a manager for restoring there, with the read cache applied.
+// A rollback only works on the host, so it does without storage.
+func restoreManager(cmd *cobra.Command, rollback bool) (*backup.BackupManager, func(), error) {
+	localMode := mustGetBoolFlag(cmd, "local")
+	hostname := mustGetStringFlag(cmd, "host")
+	docker, err := dockerEndpoint(cmd, hostname)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !localMode && docker == nil && hostname == "" {
+		return nil, nil, fmt.Errorf("--host is required unless --local is used")
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil && !rollback {
+		return nil, nil, err
+	}
+
+	var sshClient *auth.SSHClient
+	closeFn := func() {}
+	if !localMode && docker == nil {
+		sshClient, err = createSSHClient(cmd, hostname)
+		if err != nil {
+			return nil, nil, err
+		}
+		closeFn = func() { sshClient.Close() }
+	}
+
+	backupManager := backup.NewBackupManager(sshClient, minioConfig)
+	backupManager.SetDryRun(mustGetBoolFlag(cmd, "dry-run"))
+	if docker != nil {
+		backupManager.SetDockerEndpoint(docker)
+	}
+	applyCommandAudit(cmd, backupManager)
+	if err := applyReadCache(cmd, backupManager); err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+	return backupManager, closeFn, nil
+}
+
+// resolveRestoreObject returns the object argument, or the latest object
+// under --prefix with --latest.
+func resolveRestoreObject(cmd *cobra.Command, bm *backup.BackupManager, args []string) (string, error) {
+	if len(args) > 0 && args[0] != "" {
+		return args[0], nil
+	}
+	prefix := mustGetStringFlag(cmd, "prefix")
+	if !mustGetBoolFlag(cmd, "latest") || prefix == "" {
+		return "", fmt.Errorf("object name argument is required unless --latest and --prefix are used")
+	}
+	latestObj, err := bm.GetLatestObject(prefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve latest object for prefix '%s': %w", prefix, err)
+	}
+	fmt.Printf("Resolved latest object: %s\n", latestObj)
+	return latestObj, nil
+}
+
+// runRestoreRollback reverts the last restore on the host, of site as when
+// given, to its rollback point.
+func runRestoreRollback(cmd *cobra.Command, as string) error {
+	backupManager, closeManager, err := restoreManager(cmd, true)
+	if err != nil {
+		return err
+	}
+	defer closeManager()
+
+	path := mustGetStringFlag(cmd, "rollback-file")
+	if path == "" {
+		path = backup.DefaultRollbackPath()
+	}
+	state, err := backup.LoadRollbackState(path)
+	if err != nil {
+		return err
+	}
+	point, ok := backupManager.LastRollbackPoint(state, as)
+	if !ok {
+		where := "the local host"
+		if host := mustGetStringFlag(cmd, "host"); host != "" {
+			where = host
+		}
+		if as != "" {
+			where = as + " on " + where
+		}
+		return fmt.Errorf("no rollback point for %s in %s", where, path)
+	}
+	fmt.Printf("Rolling back %s to before the restore of %s (%s, %s)...\n", point.Site, point.ObjectKey, point.Kind, backup.FormatTime(point.CreatedAt, time.RFC3339))
+	return backupManager.Rollback(path, point, mustGetBoolFlag(cmd, "dry-run"))
+}
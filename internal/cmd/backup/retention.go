@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+// resolveRetentionPreset returns the preset selected by --retention-preset, or
+// nil when none was requested.
+func resolveRetentionPreset(cmd *cobra.Command) (*backup.RetentionPreset, error) {
+	name := mustGetStringFlag(cmd, "retention-preset")
+	if name == "" {
+		return nil, nil
+	}
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return nil, err
+	}
+	preset, err := backup.FindRetentionPreset(fleet, name)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Using retention preset '%s' (daily=%d, weekly=%d, monthly=%d)\n",
+		preset.Name, preset.KeepDaily, preset.KeepWeekly, preset.KeepMonthly)
+	return preset, nil
+}
+
+// smartRetentionFromFlags returns the smart retention policy of
+// --retention-preset, with explicit --keep-*, --weekly-day and --monthly-day
+// flags overriding its numbers, or of those flags with --smart-retention. It
+// returns nil when neither is given.
+func smartRetentionFromFlags(cmd *cobra.Command) (*backup.SmartRetentionPolicy, error) {
+	preset, err := resolveRetentionPreset(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if preset != nil {
+		policy := preset.Policy()
+		overrideIntFromFlag(cmd, "keep-daily", &policy.KeepDaily)
+		overrideIntFromFlag(cmd, "keep-weekly", &policy.KeepWeekly)
+		overrideIntFromFlag(cmd, "keep-monthly", &policy.KeepMonthly)
+		overrideIntFromFlag(cmd, "weekly-day", &policy.WeeklyDay)
+		overrideIntFromFlag(cmd, "monthly-day", &policy.MonthlyDay)
+		return policy, nil
+	}
+	if !mustGetBoolFlag(cmd, "smart-retention") {
+		return nil, nil
+	}
+	return &backup.SmartRetentionPolicy{
+		Enabled:     true,
+		KeepDaily:   mustGetIntFlag(cmd, "keep-daily"),
+		KeepWeekly:  mustGetIntFlag(cmd, "keep-weekly"),
+		KeepMonthly: mustGetIntFlag(cmd, "keep-monthly"),
+		WeeklyDay:   mustGetIntFlag(cmd, "weekly-day"),
+		MonthlyDay:  mustGetIntFlag(cmd, "monthly-day"),
+	}, nil
+}
+
+// overrideIntFromFlag replaces *dst with the flag value only when the user set
+// the flag explicitly on the command line.
+func overrideIntFromFlag(cmd *cobra.Command, name string, dst *int) {
+	if cmd.Flags().Changed(name) {
+		*dst = mustGetIntFlag(cmd, name)
+	}
+}
+
+func runBackupRetentionShowPresets(cmd *cobra.Command, args []string) error {
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	presets := backup.RetentionPresets(fleet)
+	names := backup.SortedRetentionPresetNames(presets)
+
+	if mustGetBoolFlag(cmd, "json") {
+		list := make([]backup.RetentionPreset, 0, len(names))
+		for _, name := range names {
+			list = append(list, presets[name])
+		}
+		b, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal presets to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDAILY\tWEEKLY\tMONTHLY\tWEEKLY DAY\tMONTHLY DAY\tSOURCE\tDESCRIPTION")
+	for _, name := range names {
+		p := presets[name]
+		source := "fleet"
+		if p.BuiltIn {
+			source = "built-in"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%d\t%s\t%s\n",
+			p.Name, p.KeepDaily, p.KeepWeekly, p.KeepMonthly, time.Weekday(p.WeeklyDay), p.Policy().MonthlyDay, source, p.Description)
+	}
+	return w.Flush()
+}
+
+func runBackupRetentionCompare(cmd *cobra.Command, args []string) error {
+	fleet, err := loadFleet(cmd)
+	if err != nil {
+		return err
+	}
+	a, err := backup.ParseRetentionPolicy(mustGetStringFlag(cmd, "policy-a"), fleet)
+	if err != nil {
+		return fmt.Errorf("--policy-a: %w", err)
+	}
+	b, err := backup.ParseRetentionPolicy(mustGetStringFlag(cmd, "policy-b"), fleet)
+	if err != nil {
+		return fmt.Errorf("--policy-b: %w", err)
+	}
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		return err
+	}
+	cmp, err := bm.CompareRetention(mustGetStringFlag(cmd, "prefix"), a, b)
+	if err != nil {
+		return err
+	}
+	showObjects := mustGetBoolFlag(cmd, "objects")
+	if !showObjects {
+		for i := range cmp.Sites {
+			cmp.Sites[i].Differences = nil
+		}
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(cmp)
+	}
+
+	mb := func(n int64) string { return fmt.Sprintf("%.2f", float64(n)/(1024*1024)) }
+	fmt.Printf("Policy A (%s): %s\n", a.Spec, a)
+	fmt.Printf("Policy B (%s): %s\n", b.Spec, b)
+	if len(cmp.Sites) == 0 {
+		fmt.Printf("\nNo backups under %q\n", cmp.Prefix)
+		return nil
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SITE\tA KEPT\tA DELETED\tB KEPT\tB DELETED\tB-A MB")
+	for _, s := range cmp.Sites {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%+.2f\n", s.Site, s.A.KeptObjects, s.A.DeletedObjects, s.B.KeptObjects, s.B.DeletedObjects, float64(s.StorageDelta())/(1024*1024))
+	}
+	if len(cmp.Sites) > 1 {
+		fmt.Fprintf(w, "TOTAL (%d sites)\t%d\t%d\t%d\t%d\t%+.2f\n", len(cmp.Sites), cmp.TotalA.KeptObjects, cmp.TotalA.DeletedObjects,
+			cmp.TotalB.KeptObjects, cmp.TotalB.DeletedObjects, float64(cmp.StorageDelta)/(1024*1024))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nPolicy A keeps %s MB and deletes %s MB; policy B keeps %s MB and deletes %s MB.\n",
+		mb(cmp.TotalA.KeptBytes), mb(cmp.TotalA.DeletedBytes), mb(cmp.TotalB.KeptBytes), mb(cmp.TotalB.DeletedBytes))
+	switch {
+	case cmp.StorageDelta > 0:
+		fmt.Printf("Switching to policy B would keep %s MB more.\n", mb(cmp.StorageDelta))
+	case cmp.StorageDelta < 0:
+		fmt.Printf("Switching to policy B would free %s MB.\n", mb(-cmp.StorageDelta))
+	default:
+		fmt.Println("Both policies keep the same amount of data.")
+	}
+
+	if !showObjects {
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OBJECT\tMB\tDELETED BY")
+	for _, s := range cmp.Sites {
+		for _, d := range s.Differences {
+			fmt.Fprintf(w, "%s\t%s\t%s only\n", d.Key, mb(d.Size), strings.ToUpper(d.DeletedBy))
+		}
+	}
+	return w.Flush()
+}
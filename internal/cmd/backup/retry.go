@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/auth"
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupRetryPending(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	queuePath := mustGetStringFlag(cmd, "pending-file")
+	if queuePath == "" {
+		queuePath = backup.DefaultPendingUploadsPath()
+	}
+	hostname := mustGetStringFlag(cmd, "host")
+	localMode := mustGetBoolFlag(cmd, "local")
+	if localMode && hostname != "" {
+		return fmt.Errorf("--host and --local are mutually exclusive")
+	}
+	opts := &backup.RetryPendingOptions{
+		Host:      hostname,
+		SkipMinio: !localMode && hostname == "",
+		DryRun:    mustGetBoolFlag(cmd, "dry-run"),
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	awsConfig, err := getAWSConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	docker, err := dockerEndpoint(cmd, hostname)
+	if err != nil {
+		return err
+	}
+	if docker != nil {
+		// Backups made through the endpoint are queued under its name.
+		opts.Host, opts.SkipMinio = docker.Name(), false
+	}
+	var sshClient *auth.SSHClient
+	if hostname != "" && docker == nil && !opts.DryRun {
+		sshClient, err = createSSHClient(cmd, hostname)
+		if err != nil {
+			return err
+		}
+		defer sshClient.Close()
+	}
+
+	manager := backup.NewBackupManagerWithAWS(sshClient, minioConfig, awsConfig)
+	manager.SetDryRun(opts.DryRun)
+	if docker != nil {
+		manager.SetDockerEndpoint(docker)
+	}
+	applyCommandAudit(cmd, manager)
+	if err := applyTempBudget(cmd, manager); err != nil {
+		return err
+	}
+	if err := applyFleetResidency(cmd, hostname, manager); err != nil {
+		return err
+	}
+	res, err := manager.RetryPendingUploads(queuePath, opts)
+	if res != nil {
+		fmt.Printf("\nRetried: %d completed, %d failed, %d left queued\n", res.Completed, res.Failed, res.Skipped)
+		if !opts.DryRun {
+			recordBackupRun(cmd, "retry-pending", manager)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if res.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d pending upload(s) failed again", res.Failed), res.Completed)
+	}
+	return nil
+}
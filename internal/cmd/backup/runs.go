@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupRunsShow(cmd *cobra.Command, args []string) error {
+	runID := args[0]
+	arts, source, err := findRun(cmd, runID)
+	if err != nil {
+		return err
+	}
+	if !mustGetBoolFlag(cmd, "commands") {
+		arts.Commands = nil
+	}
+	if mustGetBoolFlag(cmd, "json") {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(arts)
+	}
+
+	rec := arts.Record
+	kind := "Run"
+	if rec.Kind != "" {
+		kind = fmt.Sprintf("Run (%s)", rec.Kind)
+	}
+	if rec.DryRun {
+		kind += " [dry run]"
+	}
+	fmt.Printf("%s %s on %s, %s to %s: %d succeeded, %d failed\n", kind, rec.ID, rec.Host,
+		backup.FormatTime(rec.StartedAt, "2006-01-02 15:04:05"), backup.FormatTime(rec.FinishedAt, "15:04:05"), rec.Succeeded, rec.Failed)
+	fmt.Printf("Source: %s\n", source)
+
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	if len(rec.Uploads) > 0 {
+		fmt.Fprintln(w, "\nSITE\tKEY\tMB\tCHECK\tGLACIER\tMISSED")
+		for _, u := range rec.Uploads {
+			check, glacier := u.PostUploadCheck, ""
+			if check == "" {
+				check = "-"
+			}
+			if u.Glacier != nil {
+				glacier = u.Glacier.Verification
+			}
+			if glacier == "" {
+				glacier = "-"
+			}
+			missed := "-"
+			if len(u.Missed) > 0 {
+				missed = fmt.Sprint(u.Missed)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\n", u.Site, u.ObjectKey, float64(u.Bytes)/(1024*1024), check, glacier, missed)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(rec.Failures) > 0 {
+		fmt.Printf("\nFailures:\n")
+		for _, f := range rec.Failures {
+			fmt.Printf("  ✗ %s (%s) [%s]: %s\n", f.Container, f.Site, f.Code, f.Error)
+			if f.Remediation != "" {
+				fmt.Printf("    💡 %s\n", f.Remediation)
+			}
+		}
+	}
+	if len(rec.Skipped) > 0 {
+		fmt.Printf("\nSkipped:\n")
+		for _, s := range rec.Skipped {
+			fmt.Printf("  - %s (%s): %s\n", s.Container, s.Site, s.Reason)
+		}
+	}
+	if !mustGetBoolFlag(cmd, "commands") {
+		return nil
+	}
+	if len(arts.Commands) == 0 {
+		fmt.Println("\nNo audited commands (the run was not made with --audit-commands).")
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tHOST\tSITE\tEXIT\tSECONDS\tCOMMAND")
+	for _, t := range arts.Commands {
+		site := t.Site
+		if site == "" {
+			site = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.2f\t%s\n", backup.FormatTime(t.StartedAt, "15:04:05"), t.Host, site, t.ExitStatus, t.DurationSeconds, t.Command)
+	}
+	return w.Flush()
+}
+
+// findRun looks runID up in the local run history, then in the artifacts
+// stored in the bucket, and says where it was found.
+func findRun(cmd *cobra.Command, runID string) (*backup.RunArtifacts, string, error) {
+	historyPath := mustGetStringFlag(cmd, "history-file")
+	if historyPath == "" {
+		historyPath = backup.DefaultHistoryPath()
+	}
+	var localErr error
+	if !mustGetBoolFlag(cmd, "remote") {
+		rec, err := backup.FindRunRecord(historyPath, runID)
+		if err == nil {
+			auditPath := mustGetStringFlag(cmd, "command-audit-file")
+			if auditPath == "" {
+				auditPath = backup.DefaultCommandAuditPath()
+			}
+			traces, err := backup.LoadCommandTraces(auditPath, runID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to read the command audit log: %v\n", err)
+			}
+			return backup.NewRunArtifacts(rec, traces), "local history " + historyPath, nil
+		}
+		localErr = err
+	}
+
+	bm, err := prunePlanManager(cmd)
+	if err != nil {
+		if localErr != nil {
+			return nil, "", fmt.Errorf("%v; cannot look in the bucket: %w", localErr, err)
+		}
+		return nil, "", err
+	}
+	arts, err := bm.FetchRunArtifacts(runID, mustGetStringFlag(cmd, "site"))
+	if err != nil {
+		if localErr != nil {
+			return nil, "", fmt.Errorf("%v; %w", localErr, err)
+		}
+		return nil, "", err
+	}
+	return arts, fmt.Sprintf("bucket artifacts of %d site(s) under _meta/", len(arts.Sites)), nil
+}
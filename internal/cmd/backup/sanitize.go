@@ -16,6 +16,7 @@ func runBackupSanitize(cmd *cobra.Command, args []string) error {
 	extractDirStr := mustGetStringFlag(cmd, "extract-dir")
 	extractFileStr := mustGetStringFlag(cmd, "extract-file")
 	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	rulesPath := mustGetStringFlag(cmd, "rules")
 
 	// Parse comma-separated lists
 	var extractDirs []string
@@ -45,6 +46,14 @@ func runBackupSanitize(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("input file does not exist: %s", inputPath)
 	}
 
+	rules := backup.DefaultSanitizeRules()
+	if rulesPath != "" {
+		var err error
+		if rules, err = backup.LoadSanitizeRules(rulesPath); err != nil {
+			return err
+		}
+	}
+
 	fmt.Println("===========================================")
 	fmt.Println("Backup Sanitization")
 	fmt.Println("===========================================")
@@ -57,6 +66,9 @@ func runBackupSanitize(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Output:        %s\n", outputPath)
 	fmt.Printf("Extract Dirs:  %v\n", extractDirs)
 	fmt.Printf("Extract Files: %v\n", extractFiles)
+	if rulesPath != "" {
+		fmt.Printf("Rules:         %s\n", rulesPath)
+	}
 	fmt.Println("===========================================")
 
 	// Create a backup manager (no SSH or Minio needed for sanitization)
@@ -68,6 +80,7 @@ func runBackupSanitize(cmd *cobra.Command, args []string) error {
 		ExtractDirs:  extractDirs,
 		ExtractFiles: extractFiles,
 		DryRun:       dryRun,
+		Rules:        rules,
 	}
 
 	if err := bm.SanitizeBackup(options); err != nil {
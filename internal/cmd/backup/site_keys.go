@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupNormalizeKeys(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	bm := backup.NewBackupManager(nil, minioConfig)
+	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	bm.SetDryRun(dryRun)
+
+	migrations, err := bm.NormalizeSiteKeys(mustGetStringFlag(cmd, "prefix"), dryRun)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, m := range migrations {
+		if m.Error != "" {
+			failed++
+		}
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		if migrations == nil {
+			migrations = []backup.SiteKeyMigration{}
+		}
+		b, err := json.MarshalIndent(migrations, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal key migrations to JSON: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		if len(migrations) == 0 {
+			fmt.Println("Every site key already follows the naming rules.")
+			return nil
+		}
+		verb := "Moved"
+		if dryRun {
+			verb = "[DRY RUN] Would move"
+		}
+		for _, m := range migrations {
+			fmt.Printf("\n%s → %s\n", m.Site, m.Key)
+			for _, r := range m.Renames {
+				fmt.Printf("   %s %s → %s\n", verb, r.From, r.To)
+			}
+			if m.Error != "" {
+				fmt.Fprintf(os.Stderr, "   ❌ %s\n", m.Error)
+			}
+		}
+		fmt.Printf("\n%d site(s) with non-conforming keys, %d failed\n", len(migrations), failed)
+	}
+	if failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d site(s) could not be moved; rerun to continue", failed), len(migrations)-failed)
+	}
+	return nil
+}
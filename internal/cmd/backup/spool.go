@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// spoolDir returns --spool-dir, or the default spool.
+func spoolDir(cmd *cobra.Command) string {
+	if dir := mustGetStringFlag(cmd, "spool-dir"); dir != "" {
+		return dir
+	}
+	return backup.DefaultSpoolDir()
+}
+
+// applySpool makes create write archives to the local spool with --spool.
+func applySpool(cmd *cobra.Command, bm *backup.BackupManager) error {
+	if !mustGetBoolFlag(cmd, "spool") {
+		return nil
+	}
+	maxSize, err := parseSize(mustGetStringFlag(cmd, "spool-max-size"))
+	if err != nil || maxSize < 0 {
+		return fmt.Errorf("invalid --spool-max-size: %s (use a size like 50GB, or 0 for no limit)", mustGetStringFlag(cmd, "spool-max-size"))
+	}
+	spool := &backup.Spool{Dir: spoolDir(cmd), MaxSize: maxSize}
+	fmt.Printf("📥 Spooling archives to %s for 'backup upload-spool'\n", spool.Dir)
+	bm.SetSpool(spool)
+	return nil
+}
+
+func runBackupUploadSpool(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	spool := &backup.Spool{Dir: spoolDir(cmd)}
+	opts := &backup.DrainSpoolOptions{
+		Retries:    mustGetIntFlag(cmd, "retries"),
+		RetryDelay: mustGetDurationFlag(cmd, "retry-delay"),
+		DryRun:     mustGetBoolFlag(cmd, "dry-run"),
+	}
+	watch := mustGetBoolFlag(cmd, "watch")
+	interval := mustGetDurationFlag(cmd, "interval")
+	if watch && interval <= 0 {
+		return fmt.Errorf("invalid --interval: %s", interval)
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	awsConfig, err := getAWSConfig(cmd)
+	if err != nil {
+		return err
+	}
+	manager := backup.NewBackupManagerWithAWS(nil, minioConfig, awsConfig)
+	manager.SetDryRun(opts.DryRun)
+	applyCommandAudit(cmd, manager)
+	if err := applyTempBudget(cmd, manager); err != nil {
+		return err
+	}
+	if err := applyFleetResidency(cmd, "", manager); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		res, err := manager.DrainSpool(spool, opts)
+		if res != nil && !opts.DryRun {
+			if res.Uploaded+res.Failed > 0 {
+				fmt.Printf("\nSpool: %d uploaded (%.2f MB), %d failed and left spooled\n", res.Uploaded, float64(res.Bytes)/(1024*1024), res.Failed)
+				recordBackupRun(cmd, "upload-spool", manager)
+			} else if !watch {
+				fmt.Println("Spool is empty.")
+			}
+		}
+		if !watch {
+			if err != nil {
+				return err
+			}
+			if res.Failed > 0 {
+				return backup.PartialFailure(fmt.Errorf("%d spooled archive(s) failed to upload", res.Failed), res.Uploaded)
+			}
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("✓ Spool uploader stopped")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addStagingFlags registers the staging tier flags on commands that migrate
+// to Glacier.
+func addStagingFlags(c *cobra.Command) {
+	c.Flags().String("staging-prefix", getEnvWithDefault("BACKUP_STAGING_PREFIX", ""), "Stage migrations under this prefix (default with --staging-profile: "+backup.DefaultStagingPrefix+") and upload to Glacier from there in the background (env: BACKUP_STAGING_PREFIX)")
+	c.Flags().String("staging-profile", getEnvWithDefault("BACKUP_STAGING_PROFILE", ""), "Storage profile of the staging bucket; without it objects are staged in the hot bucket (env: BACKUP_STAGING_PROFILE)")
+	if c.Flags().Lookup("profiles-file") == nil {
+		c.Flags().String("profiles-file", getEnvWithDefault("BACKUP_MINIO_PROFILES", ""), "Storage profiles file (default: ~/.ciwg/minio-profiles.yaml, env: BACKUP_MINIO_PROFILES)")
+	}
+}
+
+// applyStaging configures the staging tier of bm from --staging-prefix and
+// --staging-profile, and reports whether staging is enabled.
+func applyStaging(cmd *cobra.Command, bm *backup.BackupManager) (bool, error) {
+	prefix := mustGetStringFlag(cmd, "staging-prefix")
+	name := mustGetStringFlag(cmd, "staging-profile")
+	if prefix == "" && name == "" {
+		return false, nil
+	}
+
+	var tier *backup.BackupManager
+	if name != "" {
+		profilesFile := mustGetStringFlag(cmd, "profiles-file")
+		if profilesFile == "" {
+			profilesFile = backup.DefaultMinioProfilesPath()
+		}
+		cfg, err := backup.LoadMinioProfile(profilesFile, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to load staging profile: %w", err)
+		}
+		tier = backup.NewBackupManager(nil, cfg)
+	}
+	bm.SetStaging(tier, prefix)
+	return true, nil
+}
@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+	"ciwg-cli/internal/output"
+)
+
+func runBackupSync(cmd *cobra.Command, args []string) error {
+	if envFile := mustGetStringFlag(cmd, "env"); envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("error loading .env file from %s: %w", envFile, err)
+		}
+	}
+
+	srcProfile := mustGetStringFlag(cmd, "source-profile")
+	dstProfile := mustGetStringFlag(cmd, "dest-profile")
+	if srcProfile != "" || dstProfile != "" {
+		return runBackupProfileSync(cmd, srcProfile, dstProfile)
+	}
+
+	fromDir := mustGetStringFlag(cmd, "from-dir")
+	if fromDir == "" {
+		return fmt.Errorf("--from-dir or --source-profile/--dest-profile is required")
+	}
+	absDir, err := filepath.Abs(fromDir)
+	if err != nil {
+		return fmt.Errorf("invalid --from-dir: %w", err)
+	}
+
+	dstConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if backup.IsFileEndpoint(dstConfig.Endpoint) {
+		return fmt.Errorf("sync destination must be a Minio endpoint, not %s", dstConfig.Endpoint)
+	}
+
+	src := backup.NewBackupManager(nil, &backup.MinioConfig{Endpoint: "file://" + absDir})
+	dst := backup.NewBackupManager(nil, dstConfig)
+
+	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	dst.SetDryRun(dryRun)
+	if err := applyFleetResidency(cmd, "", dst); err != nil {
+		return err
+	}
+	fmt.Printf("Syncing %s → %s/%s\n", absDir, dstConfig.Endpoint, dstConfig.Bucket)
+	res, err := src.SyncTo(dst, mustGetStringFlag(cmd, "prefix"), dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Copied"
+	if dryRun {
+		verb = "Would copy"
+	}
+	fmt.Printf("\n%s %d object(s) (%.2f MB), %d already present, %d failed\n",
+		verb, res.Copied, float64(res.Bytes)/(1024*1024), res.Skipped, res.Failed)
+	if res.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d object(s) failed to sync", res.Failed), res.Copied+res.Skipped)
+	}
+	return nil
+}
+
+// runBackupProfileSync copies a bucket between two named profiles.
+func runBackupProfileSync(cmd *cobra.Command, srcProfile, dstProfile string) error {
+	if srcProfile == "" || dstProfile == "" {
+		return fmt.Errorf("--source-profile and --dest-profile must be used together")
+	}
+	if srcProfile == dstProfile {
+		return fmt.Errorf("source and destination profiles are the same")
+	}
+	profilesFile := mustGetStringFlag(cmd, "profiles-file")
+	if profilesFile == "" {
+		profilesFile = backup.DefaultMinioProfilesPath()
+	}
+	srcConfig, err := backup.LoadMinioProfile(profilesFile, srcProfile)
+	if err != nil {
+		return err
+	}
+	dstConfig, err := backup.LoadMinioProfile(profilesFile, dstProfile)
+	if err != nil {
+		return err
+	}
+
+	opts := backup.BucketSyncOptions{
+		Prefix:    mustGetStringFlag(cmd, "prefix"),
+		DryRun:    mustGetBoolFlag(cmd, "dry-run"),
+		Verify:    !mustGetBoolFlag(cmd, "no-verify"),
+		StateFile: mustGetStringFlag(cmd, "state-file"),
+	}
+	if opts.StateFile == "" {
+		opts.StateFile = backup.DefaultBucketSyncStatePath(srcProfile, dstProfile)
+	}
+
+	src := backup.NewBackupManager(nil, srcConfig)
+	dst := backup.NewBackupManager(nil, dstConfig)
+	dst.SetDryRun(opts.DryRun)
+	if err := applyFleetResidency(cmd, "", dst); err != nil {
+		return err
+	}
+	fmt.Printf("Syncing %s (%s) → %s (%s)\n", srcProfile, profileLocation(srcConfig), dstProfile, profileLocation(dstConfig))
+	report, err := src.SyncBuckets(dst, opts)
+	if err != nil {
+		return err
+	}
+
+	if mustGetBoolFlag(cmd, "json") {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Fprintln(output.Data(), string(b))
+	} else {
+		printBucketSyncReport(report, opts.DryRun)
+	}
+	if report.Failed > 0 {
+		return backup.PartialFailure(fmt.Errorf("%d object(s) failed to sync", report.Failed), report.Copied+report.Skipped)
+	}
+	return nil
+}
+
+func profileLocation(cfg *backup.MinioConfig) string {
+	if backup.IsFileEndpoint(cfg.Endpoint) {
+		return cfg.Endpoint
+	}
+	return cfg.Endpoint + "/" + cfg.Bucket
+}
+
+func printBucketSyncReport(report *backup.BucketSyncReport, dryRun bool) {
+	for _, o := range report.Objects {
+		mb := float64(o.Size) / (1024 * 1024)
+		switch o.Status {
+		case backup.BucketSyncCopied:
+			verified := ""
+			if o.Verified {
+				verified = ", verified"
+			}
+			fmt.Printf("✓ Copied %s (%.2f MB, %s%s) in %.1fs\n", o.Key, mb, o.Method, verified, o.Seconds)
+		case backup.BucketSyncWouldCopy:
+			fmt.Printf("[DRY RUN] Would copy %s (%.2f MB)\n", o.Key, mb)
+		case backup.BucketSyncFailed:
+			fmt.Printf("✗ Failed %s: %s\n", o.Key, o.Error)
+		}
+	}
+
+	verb := "Copied"
+	if dryRun {
+		verb = "Would copy"
+	}
+	fmt.Printf("\n%s %d object(s) (%.2f MB), %d already present, %d done in a previous run, %d failed\n",
+		verb, report.Copied, float64(report.Bytes)/(1024*1024), report.Skipped, report.Resumed, report.Failed)
+}
@@ -0,0 +1,28 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+// addTempBudgetFlags registers the temp space budget flags on commands that
+// buffer Glacier uploads in the temp directory.
+func addTempBudgetFlags(c *cobra.Command) {
+	c.Flags().String("temp-headroom", getEnvWithDefault("BACKUP_TEMP_HEADROOM", "512MB"), "Free space to keep in the temp directory when admitting Glacier buffers and parts (env: BACKUP_TEMP_HEADROOM)")
+	c.Flags().Duration("temp-wait", getEnvDurationWithDefault("BACKUP_TEMP_WAIT", 30*time.Minute), "How long a stage waits for temp space before it is skipped or fails (env: BACKUP_TEMP_WAIT)")
+}
+
+// applyTempBudget configures the temp space budget of bm from
+// --temp-headroom and --temp-wait.
+func applyTempBudget(cmd *cobra.Command, bm *backup.BackupManager) error {
+	headroom, err := parseSize(mustGetStringFlag(cmd, "temp-headroom"))
+	if err != nil || headroom < 0 {
+		return fmt.Errorf("invalid --temp-headroom: %s", mustGetStringFlag(cmd, "temp-headroom"))
+	}
+	bm.SetTempBudget(headroom, mustGetDurationFlag(cmd, "temp-wait"))
+	return nil
+}
@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+
+	"ciwg-cli/internal/backup"
+)
+
+func runBackupVerify(cmd *cobra.Command, args []string) error {
+	if envPath := mustGetStringFlag(cmd, "env"); envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", envPath, err)
+		}
+	}
+
+	prefix := mustGetStringFlag(cmd, "prefix")
+	etagOnly := mustGetBoolFlag(cmd, "etag-only")
+	accept := mustGetBoolFlag(cmd, "accept")
+	dryRun := mustGetBoolFlag(cmd, "dry-run")
+	indexPath := mustGetStringFlag(cmd, "etag-index")
+	if indexPath == "" {
+		indexPath = backup.DefaultETagIndexPath()
+	}
+
+	minioConfig, err := getMinioConfig(cmd)
+	if err != nil {
+		return err
+	}
+	manager := backup.NewBackupManager(nil, minioConfig)
+
+	rep, err := manager.VerifyETags(prefix, indexPath, accept, dryRun)
+	if err != nil {
+		return err
+	}
+	for _, c := range rep.Checks {
+		switch c.Status {
+		case backup.ETagChanged:
+			fmt.Printf("❌ CHANGED  %s: recorded %s (%d bytes, %s), now %s (%d bytes, %s)\n", c.Key,
+				c.Recorded.ETag, c.Recorded.Size, backup.FormatTime(c.Recorded.LastModified, "2006-01-02 15:04:05"),
+				c.Current.ETag, c.Current.Size, backup.FormatTime(c.Current.LastModified, "2006-01-02 15:04:05"))
+		case backup.ETagAccepted:
+			fmt.Printf("✓ ACCEPTED %s: now %s (%d bytes), was %s (%d bytes)\n", c.Key, c.Current.ETag, c.Current.Size, c.Recorded.ETag, c.Recorded.Size)
+		case backup.ETagNew:
+			if mustGetBoolFlag(cmd, "verbose") {
+				fmt.Printf("+ NEW      %s (%s, %d bytes)\n", c.Key, c.Current.ETag, c.Current.Size)
+			}
+		case backup.ETagRemoved:
+			if mustGetBoolFlag(cmd, "verbose") {
+				fmt.Printf("- REMOVED  %s\n", c.Key)
+			}
+		}
+	}
+	fmt.Printf("\nETags: %d unchanged, %d new, %d removed, %d accepted, %d CHANGED\n", rep.Unchanged, rep.New, rep.Removed, rep.Accepted, rep.Changed)
+	if dryRun {
+		fmt.Printf("[DRY RUN] %s was not updated\n", indexPath)
+	}
+
+	failed := 0
+	if !etagOnly {
+		checked, failures, err := manager.VerifyBackupObjects(prefix, func(i, n int, key string) {
+			// Progress goes to stderr so the findings on stdout stay readable.
+			fmt.Fprintf(os.Stderr, "Verifying [%d/%d] %s...\n", i, n, key)
+		})
+		if err != nil {
+			return err
+		}
+		for _, f := range failures {
+			fmt.Printf("❌ CORRUPT  %s: %v\n", f.Key, f.Err)
+		}
+		failed = len(failures)
+		fmt.Printf("Contents: %d read, %d corrupt\n", checked, failed)
+	}
+
+	switch {
+	case rep.Changed > 0 && failed > 0:
+		err = fmt.Errorf("%d object(s) changed since they were recorded and %d failed verification", rep.Changed, failed)
+	case rep.Changed > 0:
+		err = fmt.Errorf("%d object(s) changed since they were recorded; check them, then run with --accept to record their new state", rep.Changed)
+	case failed > 0:
+		err = fmt.Errorf("%d object(s) failed verification", failed)
+	}
+	return backup.WithExitCode(backup.ExitVerification, err)
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"ciwg-cli/internal/backup"
 	backupcmd "ciwg-cli/internal/cmd/backup"
 	dnsbackupcmd "ciwg-cli/internal/cmd/dnsbackup"
+	"ciwg-cli/internal/output"
 )
 
 var (
@@ -28,12 +30,22 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// ExitCode returns the process exit code for an error returned by Execute,
+// following the contract documented in 'ciwg backup --help'.
+func ExitCode(err error) int {
+	return backup.ExitCode(err)
+}
+
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initOutput)
+	cobra.OnFinalize(output.Restore)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.ciwg.yaml)")
 	rootCmd.PersistentFlags().Bool("verbose", false, "verbose output")
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	rootCmd.PersistentFlags().BoolVarP(&outputOpts.Quiet, "quiet", "q", getEnvBoolWithDefault("CIWG_QUIET", false), "Only print errors; command results (JSON, CSV, downloads) are still written (env: CIWG_QUIET)")
+	rootCmd.PersistentFlags().BoolVar(&outputOpts.NoEmoji, "no-emoji", getEnvBoolWithDefault("CIWG_NO_EMOJI", false), "Replace emoji in output with plain-text tags like [OK] and [WARN] (env: CIWG_NO_EMOJI)")
+	rootCmd.PersistentFlags().BoolVar(&outputOpts.NoColor, "no-color", os.Getenv("NO_COLOR") != "", "Strip ANSI colors from output (env: NO_COLOR)")
 
 	// Add backup command from the backup subpackage
 	rootCmd.AddCommand(backupcmd.BackupCmd)
@@ -50,6 +62,17 @@ func init() {
 	}
 }
 
+// outputOpts holds the global --quiet, --no-emoji and --no-color flags.
+var outputOpts output.Options
+
+// initOutput applies the --quiet, --no-emoji and --no-color flags to
+// everything the command prints.
+func initOutput() {
+	if err := output.Install(outputOpts); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to configure output filtering: %v\n", err)
+	}
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
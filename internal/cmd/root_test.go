@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	backupcmd "ciwg-cli/internal/cmd/backup"
+)
+
+// executeRoot runs rootCmd with args and returns what it printed.
+func executeRoot(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("ciwg %s: %v\n%s", strings.Join(args, " "), err, out.String())
+	}
+	return out.String()
+}
+
+func TestBackupCommandIsRegisteredOnce(t *testing.T) {
+	var found int
+	for _, c := range rootCmd.Commands() {
+		if c.Name() != "backup" {
+			continue
+		}
+		found++
+		if c != backupcmd.BackupCmd {
+			t.Errorf("ciwg backup is %p, want backupcmd.BackupCmd", c)
+		}
+	}
+	if found != 1 {
+		t.Errorf("%d commands named backup registered, want 1", found)
+	}
+}
+
+func TestBackupSubcommandHelp(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"backup", "restore-db", "--help"}, "ciwg backup restore-db [object]"},
+		{[]string{"backup", "init", "--help"}, "ciwg backup init"},
+		{[]string{"backup", "create", "--help"}, "--minio-ca-bundle"},
+		{[]string{"backup", "list", "--help"}, "--group-by"},
+	} {
+		if out := executeRoot(t, tc.args...); !strings.Contains(out, tc.want) {
+			t.Errorf("ciwg %s does not mention %q:\n%s", strings.Join(tc.args, " "), tc.want, out)
+		}
+	}
+}
+
+func TestBackupHelpDocumentsExitCodes(t *testing.T) {
+	out := executeRoot(t, "backup", "--help")
+	for _, want := range []string{"Exit codes are the same for every backup command", "2  partial failure", "7  refused by a safety guard"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ciwg backup --help does not mention %q", want)
+		}
+	}
+}
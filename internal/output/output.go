@@ -0,0 +1,235 @@
+// Package output applies the global --quiet, --no-emoji and --no-color flags
+// to everything the CLI prints. Commands keep writing to os.Stdout and
+// os.Stderr as usual; Install swaps those for filtered pipes so progress lines
+// from any package are covered without threading a logger through them.
+package output
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// Options selects how output is filtered.
+type Options struct {
+	// Quiet discards standard output; errors on stderr are still shown.
+	Quiet bool
+	// NoEmoji replaces status marks (✓ ✗ ⚠) with ASCII tags and drops other
+	// pictographs.
+	NoEmoji bool
+	// NoColor strips ANSI escape sequences.
+	NoColor bool
+}
+
+func (o Options) filtering() bool { return o.NoEmoji || o.NoColor }
+
+var (
+	mu      sync.Mutex
+	data    io.Writer = os.Stdout
+	restore func()
+)
+
+// Data returns the writer for command results (JSON, CSV, downloaded
+// objects). It is the real standard output, unaffected by Install, so
+// machine-readable output survives --quiet and binary data is never altered.
+func Data() io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	return data
+}
+
+// Install applies opts to os.Stdout and os.Stderr until Restore is called.
+// It is a no-op when no option is set.
+func Install(opts Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if restore != nil || (!opts.Quiet && !opts.filtering()) {
+		return nil
+	}
+
+	origOut, origErr := os.Stdout, os.Stderr
+	var undo []func()
+	var wg sync.WaitGroup
+
+	// pipe replaces *target with a pipe whose contents are filtered into dst.
+	pipe := func(target **os.File, dst io.Writer) error {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		orig := *target
+		*target = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(&filter{opts: opts, w: dst}, r)
+			r.Close()
+		}()
+		undo = append(undo, func() {
+			*target = orig
+			w.Close()
+		})
+		return nil
+	}
+
+	if opts.Quiet {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		os.Stdout = devNull
+		undo = append(undo, func() {
+			os.Stdout = origOut
+			devNull.Close()
+		})
+	} else if err := pipe(&os.Stdout, origOut); err != nil {
+		return err
+	}
+	if opts.filtering() {
+		if err := pipe(&os.Stderr, origErr); err != nil {
+			os.Stdout = origOut
+			return err
+		}
+	}
+
+	data = origOut
+	restore = func() {
+		for _, f := range undo {
+			f()
+		}
+		wg.Wait()
+	}
+	return nil
+}
+
+// Restore flushes pending filtered output and puts the original streams back.
+func Restore() {
+	mu.Lock()
+	defer mu.Unlock()
+	if restore != nil {
+		restore()
+		restore = nil
+	}
+}
+
+// Filter returns s with opts applied, for callers that format text themselves.
+func Filter(s string, opts Options) string {
+	var b stringWriter
+	f := &filter{opts: opts, w: &b}
+	f.Write([]byte(s))
+	f.Write(nil)
+	return string(b)
+}
+
+type stringWriter []byte
+
+func (s *stringWriter) Write(p []byte) (int, error) {
+	*s = append(*s, p...)
+	return len(p), nil
+}
+
+// statusMarks are emoji with a meaning worth keeping in plain-text logs.
+var statusMarks = map[rune]string{
+	'✓': "[OK]", '✔': "[OK]", '✅': "[OK]",
+	'✗': "[FAIL]", '✘': "[FAIL]", '❌': "[FAIL]",
+	'⚠': "[WARN]",
+}
+
+// isPictograph reports whether r is an emoji or symbol that carries no
+// meaning in plain text.
+func isPictograph(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // emoticons, pictographs, transport, flags
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols, dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // stars, large arrows
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // ⏱ ⏳ ⌛
+		return true
+	case r == 0x2139 || r == 0x21BB: // ℹ ↻
+		return true
+	}
+	return false
+}
+
+// isEmojiModifier reports whether r only alters how the preceding emoji is
+// rendered (presentation selector, joiner, keycap).
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0x200D || r == 0x20E3
+}
+
+// filter is an io.Writer that applies Options to a byte stream. It keeps
+// incomplete UTF-8 sequences and escape sequences across writes, so it can sit
+// behind a pipe that delivers arbitrary chunks.
+type filter struct {
+	opts    Options
+	w       io.Writer
+	pending []byte
+	// esc is 1 after ESC, 2 inside a CSI sequence.
+	esc int
+	// skipSpace drops spaces following a removed pictograph.
+	skipSpace bool
+}
+
+func (f *filter) Write(p []byte) (int, error) {
+	n := len(p)
+	buf := append(f.pending, p...)
+	f.pending = nil
+	out := make([]byte, 0, len(buf))
+
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(buf) && p != nil {
+			f.pending = append(f.pending, buf...)
+			break
+		}
+		raw := buf[:size]
+		buf = buf[size:]
+
+		if f.opts.NoColor {
+			switch {
+			case f.esc == 1:
+				f.esc = 0
+				if r == '[' {
+					f.esc = 2
+				}
+				continue
+			case f.esc == 2:
+				if r >= 0x40 && r <= 0x7E {
+					f.esc = 0
+				}
+				continue
+			case r == 0x1B:
+				f.esc = 1
+				continue
+			}
+		}
+		if f.opts.NoEmoji {
+			if tag, ok := statusMarks[r]; ok {
+				out = append(out, tag...)
+				f.skipSpace = false
+				continue
+			}
+			if isEmojiModifier(r) {
+				continue
+			}
+			if isPictograph(r) {
+				f.skipSpace = true
+				continue
+			}
+			if f.skipSpace && r == ' ' {
+				continue
+			}
+			f.skipSpace = false
+		}
+		out = append(out, raw...)
+	}
+	if len(out) > 0 {
+		if _, err := f.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
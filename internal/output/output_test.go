@@ -0,0 +1,41 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts Options
+		want string
+	}{
+		{"  ✓ Deleted from Minio\n", Options{NoEmoji: true}, "  [OK] Deleted from Minio\n"},
+		{"⚠️  Warning: low space\n", Options{NoEmoji: true}, "[WARN]  Warning: low space\n"},
+		{"  📦 Size: 10 MB\n", Options{NoEmoji: true}, "  Size: 10 MB\n"},
+		{"  ℹ️  File size: 3\n", Options{NoEmoji: true}, "  File size: 3\n"},
+		{"a → b ≠ c…\n", Options{NoEmoji: true}, "a → b ≠ c…\n"},
+		{"\033[32m✓\033[0m host\n", Options{NoColor: true}, "✓ host\n"},
+		{"\033[31m✗\033[0m host\n", Options{NoColor: true, NoEmoji: true}, "[FAIL] host\n"},
+		{"🔍 DRY RUN\n", Options{}, "🔍 DRY RUN\n"},
+	}
+	for _, tt := range tests {
+		if got := Filter(tt.in, tt.opts); got != tt.want {
+			t.Errorf("Filter(%q, %+v) = %q, want %q", tt.in, tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestFilterSplitWrites(t *testing.T) {
+	// Pipes deliver arbitrary chunks; runes and escape sequences may be split.
+	in := []byte("\033[33m⚠️ \033[0m🚀 Started ✓\n")
+	var out bytes.Buffer
+	f := &filter{opts: Options{NoEmoji: true, NoColor: true}, w: &out}
+	for i := range in {
+		f.Write(in[i : i+1])
+	}
+	if got, want := out.String(), "[WARN] Started [OK]\n"; got != want {
+		t.Errorf("split writes produced %q, want %q", got, want)
+	}
+}